@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 内置的负载生成器：直接在连接上写入 RESP 多条批量请求（multi-bulk），每次写入 pipeline 条后
+// 依次读取回复，不依赖 redis-benchmark。每条命令的延迟从所在批次写入前开始计算，到读到它的回复为止，
+// 与 redis-benchmark 的 -P 相同
+
+// benchCommands 支持的命令
+var benchCommands = []string{"PING", "SET", "GET", "INCR", "LPUSH", "HSET", "XADD", "ZADD"}
+
+// loadConfig 一次测试的参数
+type loadConfig struct {
+	addr     string
+	password string
+	clients  int
+	pipeline int
+	requests int64
+	minSize  int // 值的长度在 [minSize, maxSize] 中均匀分布
+	maxSize  int
+	keyspace uint64 // 键的个数，键名为 bench:<类型>:<序号>
+	dist     string // 键的分布：uniform 或 zipfian
+	zipfS    float64
+	seed     uint64
+}
+
+// weightedCommand 命令组合中的一项
+type weightedCommand struct {
+	name   string
+	weight int
+}
+
+// commandMix 按权重随机选择命令
+type commandMix []weightedCommand
+
+// parseMix 解析命令组合，如 "SET=20,GET=80"；省略权重时为 1，"SET,GET" 各占一半
+func parseMix(spec string) (commandMix, error) {
+	var mix commandMix
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, weightStr, hasWeight := strings.Cut(part, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if !isBenchCommand(name) {
+			return nil, fmt.Errorf("unsupported command '%s' (supported: %s)", name, strings.Join(benchCommands, ","))
+		}
+		weight := 1
+		if hasWeight {
+			w, err := strconv.Atoi(strings.TrimSpace(weightStr))
+			if err != nil || w <= 0 {
+				return nil, fmt.Errorf("invalid weight '%s' for command '%s'", weightStr, name)
+			}
+			weight = w
+		}
+		mix = append(mix, weightedCommand{name: name, weight: weight})
+	}
+	if len(mix) == 0 {
+		return nil, errors.New("empty command mix")
+	}
+	return mix, nil
+}
+
+func isBenchCommand(name string) bool {
+	for _, c := range benchCommands {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// String 返回 "SET=20,GET=80" 形式的组合，单个命令时只返回命令名
+func (m commandMix) String() string {
+	if len(m) == 1 {
+		return m[0].name
+	}
+	parts := make([]string, len(m))
+	for i, c := range m {
+		parts[i] = c.name + "=" + strconv.Itoa(c.weight)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (m commandMix) pick(r *rand.Rand) string {
+	if len(m) == 1 {
+		return m[0].name
+	}
+	total := 0
+	for _, c := range m {
+		total += c.weight
+	}
+	n := r.IntN(total)
+	for _, c := range m {
+		if n < c.weight {
+			return c.name
+		}
+		n -= c.weight
+	}
+	return m[len(m)-1].name
+}
+
+// parseSize 解析值的长度："100" 或 "64-4096"（均匀分布）
+func parseSize(spec string) (int, int, error) {
+	lo, hi, isRange := strings.Cut(spec, "-")
+	minSize, err := strconv.Atoi(strings.TrimSpace(lo))
+	if err != nil || minSize < 0 {
+		return 0, 0, fmt.Errorf("invalid data size '%s'", spec)
+	}
+	maxSize := minSize
+	if isRange {
+		maxSize, err = strconv.Atoi(strings.TrimSpace(hi))
+		if err != nil || maxSize < minSize {
+			return 0, 0, fmt.Errorf("invalid data size '%s'", spec)
+		}
+	}
+	return minSize, maxSize, nil
+}
+
+// generator 一个连接的请求生成器，不在连接间共享
+type generator struct {
+	cfg   loadConfig
+	mix   commandMix
+	rand  *rand.Rand
+	zipf  *rand.Zipf
+	value []byte
+	buf   []byte
+}
+
+func newGenerator(cfg loadConfig, mix commandMix, id int) (*generator, error) {
+	// #nosec G115 - id 为非负的连接序号
+	r := rand.New(rand.NewPCG(cfg.seed, uint64(id)))
+	g := &generator{cfg: cfg, mix: mix, rand: r}
+	switch cfg.dist {
+	case "uniform":
+	case "zipfian":
+		// 序号越小访问越频繁
+		g.zipf = rand.NewZipf(r, cfg.zipfS, 1, cfg.keyspace-1)
+		if g.zipf == nil {
+			return nil, fmt.Errorf("invalid zipfian exponent %v, must be > 1", cfg.zipfS)
+		}
+	default:
+		return nil, fmt.Errorf("unknown key distribution '%s' (uniform or zipfian)", cfg.dist)
+	}
+	// 随机的小写字母，避免服务器压缩重复的值使结果失真
+	g.value = make([]byte, cfg.maxSize)
+	for i := range g.value {
+		g.value[i] = byte('a' + r.IntN(26))
+	}
+	return g, nil
+}
+
+func (g *generator) key() uint64 {
+	if g.zipf != nil {
+		return g.zipf.Uint64()
+	}
+	return g.rand.Uint64N(g.cfg.keyspace)
+}
+
+func (g *generator) nextValue() []byte {
+	size := g.cfg.minSize
+	if g.cfg.maxSize > size {
+		size += g.rand.IntN(g.cfg.maxSize - size + 1)
+	}
+	return g.value[:size]
+}
+
+// appendCommand 把下一条随机命令追加到 g.buf
+func (g *generator) appendCommand() {
+	b := g.buf
+	switch cmd := g.mix.pick(g.rand); cmd {
+	case "PING":
+		b = appendArrayHeader(b, 1)
+		b = appendBulk(b, []byte(cmd))
+	case "SET":
+		b = appendArrayHeader(b, 3)
+		b = appendBulk(b, []byte(cmd))
+		b = appendKey(b, "bench:string:", g.key())
+		b = appendBulk(b, g.nextValue())
+	case "GET":
+		b = appendArrayHeader(b, 2)
+		b = appendBulk(b, []byte(cmd))
+		b = appendKey(b, "bench:string:", g.key())
+	case "INCR":
+		b = appendArrayHeader(b, 2)
+		b = appendBulk(b, []byte(cmd))
+		b = appendKey(b, "bench:counter:", g.key())
+	case "LPUSH":
+		b = appendArrayHeader(b, 3)
+		b = appendBulk(b, []byte(cmd))
+		b = appendKey(b, "bench:list:", g.key())
+		b = appendBulk(b, g.nextValue())
+	case "HSET":
+		b = appendArrayHeader(b, 4)
+		b = appendBulk(b, []byte(cmd))
+		b = appendKey(b, "bench:hash:", g.key())
+		b = appendKey(b, "field:", g.rand.Uint64N(g.cfg.keyspace))
+		b = appendBulk(b, g.nextValue())
+	case "XADD":
+		b = appendArrayHeader(b, 5)
+		b = appendBulk(b, []byte(cmd))
+		b = appendKey(b, "bench:stream:", g.key())
+		b = appendBulk(b, []byte("*"))
+		b = appendBulk(b, []byte("field"))
+		b = appendBulk(b, g.nextValue())
+	case "ZADD":
+		b = appendArrayHeader(b, 4)
+		b = appendBulk(b, []byte(cmd))
+		b = appendKey(b, "bench:zset:", g.key())
+		b = appendBulk(b, strconv.AppendUint(nil, g.rand.Uint64N(1<<20), 10))
+		b = appendKey(b, "member:", g.rand.Uint64N(g.cfg.keyspace))
+	}
+	g.buf = b
+}
+
+func appendArrayHeader(b []byte, n int) []byte {
+	b = append(b, '*')
+	b = strconv.AppendInt(b, int64(n), 10)
+	return append(b, '\r', '\n')
+}
+
+func appendBulk(b, arg []byte) []byte {
+	b = append(b, '$')
+	b = strconv.AppendInt(b, int64(len(arg)), 10)
+	b = append(b, '\r', '\n')
+	b = append(b, arg...)
+	return append(b, '\r', '\n')
+}
+
+// appendKey 追加 prefix 加十进制序号组成的参数
+func appendKey(b []byte, prefix string, n uint64) []byte {
+	var scratch [64]byte
+	arg := strconv.AppendUint(append(scratch[:0], prefix...), n, 10)
+	return appendBulk(b, arg)
+}
+
+// readReply 读取并丢弃一条回复（RESP2 与 RESP3），返回它是否为错误回复
+func readReply(r *bufio.Reader) (bool, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		return false, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return false, fmt.Errorf("malformed reply line %q", line)
+	}
+	kind := line[0]
+	body := string(line[1 : len(line)-2])
+	switch kind {
+	case '-':
+		return true, nil
+	case '+', ':', '_', ',', '#', '(':
+		return false, nil
+	case '$', '=', '!':
+		// RESP3 的批量错误（!）与逐字字符串（=）的格式与批量字符串相同
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return false, fmt.Errorf("invalid bulk length %q", body)
+		}
+		if n >= 0 {
+			_, err = r.Discard(n + 2)
+		}
+		return kind == '!', err
+	case '*', '%', '~', '>':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return false, fmt.Errorf("invalid aggregate length %q", body)
+		}
+		if kind == '%' {
+			n *= 2
+		}
+		for i := 0; i < n; i++ {
+			if _, err := readReply(r); err != nil {
+				return false, err
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown reply type %q", kind)
+}
+
+// loadResult 一次测试的结果
+type loadResult struct {
+	requests int64
+	errors   int64
+	elapsed  time.Duration
+	latency  *latencyHistogram
+}
+
+// dialBench 建立一个连接，设置了密码时先认证
+func dialBench(cfg loadConfig) (net.Conn, *bufio.Reader, error) {
+	conn, err := net.DialTimeout("tcp", cfg.addr, 5*time.Second)
+	if err != nil {
+		return nil, nil, err
+	}
+	r := bufio.NewReaderSize(conn, 64<<10)
+	if cfg.password != "" {
+		var b []byte
+		b = appendArrayHeader(b, 2)
+		b = appendBulk(b, []byte("AUTH"))
+		b = appendBulk(b, []byte(cfg.password))
+		if _, err := conn.Write(b); err != nil {
+			_ = conn.Close()
+			return nil, nil, err
+		}
+		isErr, err := readReply(r)
+		if err == nil && isErr {
+			err = errors.New("AUTH failed")
+		}
+		if err != nil {
+			_ = conn.Close()
+			return nil, nil, err
+		}
+	}
+	return conn, r, nil
+}
+
+// runLoad 用 cfg.clients 个连接发送 cfg.requests 条按 mix 随机选择的命令
+func runLoad(cfg loadConfig, mix commandMix) (*loadResult, error) {
+	if cfg.clients < 1 || cfg.pipeline < 1 || cfg.requests < 1 || cfg.keyspace < 1 {
+		return nil, errors.New("clients, pipeline, requests and keyspace must be positive")
+	}
+	// 先建立所有连接，连接的耗时不计入结果
+	gens := make([]*generator, cfg.clients)
+	conns := make([]net.Conn, cfg.clients)
+	readers := make([]*bufio.Reader, cfg.clients)
+	defer func() {
+		for _, conn := range conns {
+			if conn != nil {
+				_ = conn.Close()
+			}
+		}
+	}()
+	for i := range conns {
+		g, err := newGenerator(cfg, mix, i)
+		if err != nil {
+			return nil, err
+		}
+		conn, r, err := dialBench(cfg)
+		if err != nil {
+			return nil, err
+		}
+		gens[i], conns[i], readers[i] = g, conn, r
+	}
+
+	var issued, errCount atomic.Int64
+	hists := make([]*latencyHistogram, cfg.clients)
+	errs := make([]error, cfg.clients)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range conns {
+		hists[i] = newLatencyHistogram()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			g, conn, r, hist := gens[i], conns[i], readers[i], hists[i]
+			batch := int64(cfg.pipeline)
+			for {
+				first := issued.Add(batch) - batch
+				if first >= cfg.requests {
+					return
+				}
+				n := min(batch, cfg.requests-first)
+				g.buf = g.buf[:0]
+				for j := int64(0); j < n; j++ {
+					g.appendCommand()
+				}
+				sent := time.Now()
+				if _, err := conn.Write(g.buf); err != nil {
+					errs[i] = err
+					return
+				}
+				for j := int64(0); j < n; j++ {
+					isErr, err := readReply(r)
+					if err != nil {
+						errs[i] = err
+						return
+					}
+					if isErr {
+						errCount.Add(1)
+					}
+					hist.record(time.Since(sent))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, h := range hists[1:] {
+		hists[0].merge(h)
+	}
+	return &loadResult{
+		requests: hists[0].count,
+		errors:   errCount.Load(),
+		elapsed:  elapsed,
+		latency:  hists[0],
+	}, nil
+}
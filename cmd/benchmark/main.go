@@ -1,9 +1,17 @@
+// benchmark 对 BoltDB 进行压力测试，使用内置的 RESP 负载生成器，不需要安装 redis-tools。
+// 默认启动 ./build/boltDB 并依次测试 -t 中的命令；-addr 指定时直接测试已运行的服务器，-mix 测试按权重混合的命令：
+//
+//	go run ./cmd/benchmark -t SET,GET -c 50 -n 100000 -P 16
+//	go run ./cmd/benchmark -addr 127.0.0.1:6379 -mix SET=20,GET=80 -dist zipfian -d 64-1024 -json report.json
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"flag"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"os/exec"
 	"strconv"
@@ -11,147 +19,241 @@ import (
 	"time"
 )
 
-// BoltDBBenchmark runs redis-benchmark against BoltDB server
 func main() {
-	dbPath := flag.String("dir", "/tmp/bolt_bench", "badger dir")
-	logLevel := flag.String("log-level", "ERROR", "log level")
+	dbPath := flag.String("dir", "/tmp/bolt_bench", "badger dir of the server started by the benchmark")
+	logLevel := flag.String("log-level", "ERROR", "log level of the server started by the benchmark")
+	serverBin := flag.String("server", "./build/boltDB", "boltDB binary started when --addr is empty")
+	listeners := flag.Int("listeners", 1, "SO_REUSEPORT listeners started by the server, 0 for one per CPU")
+	addr := flag.String("addr", "", "benchmark a running server at host:port instead of starting one")
+	password := flag.String("a", "", "password sent with AUTH on every connection")
 	clients := flag.Int("c", 50, "number of concurrent clients")
-	requests := flag.Int("n", 100000, "total number of requests")
-	dataSize := flag.Int("d", 100, "data size in bytes")
+	requests := flag.Int64("n", 100000, "total number of requests per test")
+	pipeline := flag.Int("P", 1, "pipeline <numreq> requests per round trip")
+	dataSize := flag.String("d", "100", "value size in bytes, or a min-max range picked uniformly")
+	tests := flag.String("t", "PING,SET,GET", "comma separated commands tested one after another: "+strings.Join(benchCommands, ","))
+	mixSpec := flag.String("mix", "", "also run one test mixing weighted commands, e.g. SET=20,GET=80,LPUSH=5")
+	keyspace := flag.Uint64("r", 100000, "number of distinct keys per data type")
+	dist := flag.String("dist", "uniform", "key distribution: uniform or zipfian")
+	zipfS := flag.Float64("zipf-s", 1.1, "zipfian exponent (> 1), larger values make the hot keys hotter")
+	seed := flag.Uint64("seed", uint64(time.Now().UnixNano()), "random seed for keys, values and command mixes") // #nosec G115 - 当前时间为正数
+	jsonPath := flag.String("json", "", "write a JSON report to this file")
+	csvPath := flag.String("csv", "", "write a CSV report to this file")
 	flag.Parse()
 
-	// 清理旧数据
-	_ = os.RemoveAll(*dbPath)
-	_ = os.MkdirAll(*dbPath, 0755)
+	testsSet := false
+	flag.Visit(func(f *flag.Flag) { testsSet = testsSet || f.Name == "t" })
+
+	var mixes []commandMix
+	// 只指定 -mix 时不运行默认的 -t
+	if *mixSpec == "" || testsSet {
+		for _, name := range strings.Split(*tests, ",") {
+			if strings.TrimSpace(name) == "" {
+				continue
+			}
+			mix, err := parseMix(name)
+			if err != nil {
+				fail(err.Error())
+			}
+			mixes = append(mixes, mix)
+		}
+	}
+	if *mixSpec != "" {
+		mix, err := parseMix(*mixSpec)
+		if err != nil {
+			fail(err.Error())
+		}
+		mixes = append(mixes, mix)
+	}
+	if len(mixes) == 0 {
+		fail("no tests to run")
+	}
+	minSize, maxSize, err := parseSize(*dataSize)
+	if err != nil {
+		fail(err.Error())
+	}
 
 	fmt.Println("==============================================")
 	fmt.Println("BoltDB Benchmark Results")
 	fmt.Println("==============================================")
 
-	// 启动 BoltDB 服务器 (使用固定端口)
-	fmt.Println("Starting BoltDB server...")
-	boltCmd := exec.Command("./build/boltDB",
-		"-addr", ":6388",
-		"-dir", *dbPath,
-		"-log-level", *logLevel,
-	)
-
-	var boltStdout, boltStderr bytes.Buffer
-	boltCmd.Stdout = &boltStdout
-	boltCmd.Stderr = &boltStderr
-
-	if err := boltCmd.Start(); err != nil {
-		fmt.Printf("Failed to start BoltDB: %v\n", err)
-		fmt.Println("Make sure to build boltDB first: go build -o ./build/boltDB ./cmd/boltDB/main.go")
-		os.Exit(1)
+	target := *addr
+	stop := func() {}
+	if target == "" {
+		target = "127.0.0.1:6388"
+		stop = startServer(*serverBin, *dbPath, *logLevel, *listeners, target)
+	} else if err := waitReady(target, *password, 5*time.Second); err != nil {
+		fail(fmt.Sprintf("Failed to connect to %s: %v", target, err))
 	}
-	defer func() {
-		_ = boltCmd.Process.Kill()
-		_, _ = boltCmd.Process.Wait()
-	}()
-
-	// 等待服务器启动
-	time.Sleep(2 * time.Second)
 
-	// 验证服务器已启动
-	port := "6388"
-	cmd := exec.Command("redis-cli", "-p", port, "PING")
-	if err := cmd.Run(); err != nil {
-		fmt.Printf("Failed to connect to BoltDB on port %s: %v\n", port, err)
-		fmt.Printf("BoltDB stdout: %s\n", boltStdout.String())
-		fmt.Printf("BoltDB stderr: %s\n", boltStderr.String())
-		os.Exit(1)
+	cfg := loadConfig{
+		addr:     target,
+		password: *password,
+		clients:  *clients,
+		pipeline: *pipeline,
+		requests: *requests,
+		minSize:  minSize,
+		maxSize:  maxSize,
+		keyspace: *keyspace,
+		dist:     *dist,
+		zipfS:    *zipfS,
+		seed:     *seed,
+	}
+	report := benchReport{
+		Time: time.Now(),
+		Settings: benchSettings{
+			Addr:         target,
+			Clients:      *clients,
+			Pipeline:     *pipeline,
+			Requests:     *requests,
+			DataSize:     *dataSize,
+			Keyspace:     *keyspace,
+			Distribution: *dist,
+		},
+	}
+	if *dist == "zipfian" {
+		report.Settings.ZipfS = *zipfS
 	}
 
-	fmt.Printf("Server: BoltDB 127.0.0.1:%s\n", port)
-	fmt.Printf("Clients: %d | Data Size: %d bytes | Requests: %d\n", *clients, *dataSize, *requests)
+	fmt.Printf("Server: BoltDB %s\n", target)
+	fmt.Printf("Clients: %d | Pipeline: %d | Data Size: %s bytes | Requests: %d\n", *clients, *pipeline, *dataSize, *requests)
+	fmt.Printf("Keys: %d per type, %s distribution | Seed: %d\n", *keyspace, *dist, *seed)
 	fmt.Println("==============================================")
 	fmt.Println()
 
-	// 测试单个命令
-	testCommands := []string{"PING", "SET", "GET"}
 	var totalRequests int64
 	var totalTime time.Duration
-
-	for _, testCmd := range testCommands {
-		fmt.Printf("Testing %s...\n", testCmd)
-
-		benchmarkCmd := exec.Command("redis-benchmark",
-			"-h", "127.0.0.1",
-			"-p", port,
-			"-t", testCmd,
-			"-c", strconv.Itoa(*clients),
-			"-d", strconv.Itoa(*dataSize),
-			"-n", strconv.Itoa(*requests),
-		)
-
-		var benchStdout bytes.Buffer
-		benchmarkCmd.Stdout = &benchStdout
-		benchmarkCmd.Stderr = nil
-
-		cmdStart := time.Now()
-		if err := benchmarkCmd.Run(); err != nil {
-			fmt.Printf("  %s test failed: %v\n", testCmd, err)
+	for _, mix := range mixes {
+		res, err := runLoad(cfg, mix)
+		if err != nil {
+			fmt.Printf("  %s test failed: %v\n\n", mix, err)
 			continue
 		}
-		cmdTime := time.Since(cmdStart)
-		totalTime += cmdTime
-
-		output := benchStdout.String()
-		lines := strings.Split(output, "\n")
-
-		// 提取结果
-		for _, line := range lines {
-			if strings.Contains(line, testCmd+"_") || strings.HasPrefix(line, "====== "+testCmd) {
-				// 提取 rps
-				for _, l := range lines {
-					if strings.Contains(l, "requests per second") {
-						// 解析 throughput
-						parts := strings.Fields(l)
-						for i, part := range parts {
-							if part == "requests" && i > 0 {
-								if rps, err := strconv.ParseFloat(parts[i-1], 64); err == nil {
-									fmt.Printf("  %s: %.2f requests/sec\n", testCmd, rps)
-								}
-								break
-							}
-						}
-						break
-					}
-				}
-				fmt.Println("  " + line)
-				for _, l := range lines {
-					if strings.Contains(l, "completed") {
-						fmt.Println("  " + l)
-						// 统计请求数
-						parts := strings.Fields(l)
-						for _, part := range parts {
-							if n, err := strconv.ParseInt(part, 10, 64); err == nil {
-								totalRequests += n
-							}
-						}
-						break
-					}
-				}
-				break
-			}
-		}
-		fmt.Println()
+		result := newBenchResult(mix.String(), res)
+		printResult(os.Stdout, result)
+		report.Results = append(report.Results, result)
+		totalRequests += res.requests
+		totalTime += res.elapsed
 	}
 
 	// 输出汇总
 	fmt.Println("==============================================")
 	fmt.Println("Benchmark Summary:")
 	fmt.Println("----------------------------------------------")
-
 	if totalRequests > 0 && totalTime > 0 {
-		opsPerSec := float64(totalRequests) / totalTime.Seconds()
 		fmt.Printf("Total requests: %d\n", totalRequests)
 		fmt.Printf("Total time: %v\n", totalTime)
-		fmt.Printf("Overall throughput: %.2f ops/sec\n", opsPerSec)
+		fmt.Printf("Overall throughput: %.2f ops/sec\n", float64(totalRequests)/totalTime.Seconds())
+	}
+	failed := len(report.Results) < len(mixes)
+	if *jsonPath != "" {
+		failed = !writeReport(*jsonPath, report, writeJSONReport) || failed
+	}
+	if *csvPath != "" {
+		failed = !writeReport(*csvPath, report, writeCSVReport) || failed
+	}
+	stop()
+	if failed {
+		os.Exit(1)
 	}
 
 	fmt.Println("\n==============================================")
 	fmt.Println("Benchmark completed successfully!")
 	fmt.Println("==============================================")
 }
+
+// startServer 用空的数据目录启动 boltDB 并等待它接受连接，返回停止服务器的函数
+func startServer(bin, dbPath, logLevel string, listeners int, addr string) func() {
+	// 清理旧数据
+	_ = os.RemoveAll(dbPath)
+	_ = os.MkdirAll(dbPath, 0755)
+
+	fmt.Println("Starting BoltDB server...")
+	_, port, _ := net.SplitHostPort(addr)
+	// #nosec G204 - 服务器路径与参数来自命令行
+	boltCmd := exec.Command(bin,
+		"-addr", ":"+port,
+		"-dir", dbPath,
+		"-log-level", logLevel,
+		"-listeners", strconv.Itoa(listeners),
+	)
+	var boltStdout, boltStderr bytes.Buffer
+	boltCmd.Stdout = &boltStdout
+	boltCmd.Stderr = &boltStderr
+	if err := boltCmd.Start(); err != nil {
+		fmt.Printf("Failed to start BoltDB: %v\n", err)
+		fmt.Println("Make sure to build boltDB first: go build -o ./build/boltDB ./cmd/boltDB/main.go")
+		os.Exit(1)
+	}
+	stop := func() {
+		_ = boltCmd.Process.Kill()
+		_, _ = boltCmd.Process.Wait()
+	}
+	if err := waitReady(addr, "", 10*time.Second); err != nil {
+		stop()
+		fmt.Printf("Failed to connect to BoltDB on %s: %v\n", addr, err)
+		fmt.Printf("BoltDB stdout: %s\n", boltStdout.String())
+		fmt.Printf("BoltDB stderr: %s\n", boltStderr.String())
+		os.Exit(1)
+	}
+	return stop
+}
+
+// waitReady 重试 PING 直到服务器回复或超时
+func waitReady(addr, password string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := ping(addr, password)
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func ping(addr, password string) error {
+	conn, r, err := dialBench(loadConfig{addr: addr, password: password})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Write(appendBulk(appendArrayHeader(nil, 1), []byte("PING"))); err != nil {
+		return err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "+PONG") {
+		return fmt.Errorf("unexpected reply %q", strings.TrimSpace(line))
+	}
+	return nil
+}
+
+// writeReport 把报告写入文件，失败时输出错误并返回 false
+func writeReport(path string, report benchReport, write func(io.Writer, benchReport) error) bool {
+	f, err := os.Create(path) // #nosec G304 - 路径来自命令行
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "benchmark: %v\n", err)
+		return false
+	}
+	w := bufio.NewWriter(f)
+	err = write(w, report)
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "benchmark: %v\n", err)
+		return false
+	}
+	fmt.Printf("Report written to %s\n", path)
+	return true
+}
+
+func fail(msg string) {
+	fmt.Fprintln(os.Stderr, "benchmark: "+msg)
+	os.Exit(1)
+}
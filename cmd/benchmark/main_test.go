@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/server"
+	"github.com/lbp0200/BoltDB/internal/store"
+	"github.com/zeebo/assert"
+)
+
+// startTestServer 在随机端口上启动进程内的服务器，返回它的地址
+func startTestServer(t *testing.T) (string, *store.BotreonStore) {
+	db, err := store.NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	handler := &server.Handler{Db: db}
+	go func() { _ = handler.ServeTCP(ln) }()
+	t.Cleanup(func() {
+		_ = ln.Close()
+		_ = db.Close()
+	})
+	return ln.Addr().String(), db
+}
+
+func TestParseMix(t *testing.T) {
+	mix, err := parseMix("set=20, GET=80")
+	assert.NoError(t, err)
+	assert.Equal(t, "SET=20,GET=80", mix.String())
+	mix, err = parseMix("LPUSH")
+	assert.NoError(t, err)
+	assert.Equal(t, "LPUSH", mix.String())
+
+	for _, spec := range []string{"", "DEL", "SET=0", "SET=x"} {
+		_, err := parseMix(spec)
+		assert.Error(t, err)
+	}
+
+	minSize, maxSize, err := parseSize("64-4096")
+	assert.NoError(t, err)
+	assert.Equal(t, 64, minSize)
+	assert.Equal(t, 4096, maxSize)
+	_, _, err = parseSize("10-1")
+	assert.Error(t, err)
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	// 每个桶的上界属于该桶，下一个值属于下一个桶
+	for i := 0; i < 1000; i++ {
+		assert.Equal(t, i, latencyBucket(latencyBucketMax(i)))
+		assert.Equal(t, i+1, latencyBucket(latencyBucketMax(i)+1))
+	}
+
+	h := newLatencyHistogram()
+	other := newLatencyHistogram()
+	for us := 1; us <= 1000; us++ {
+		target := h
+		if us%2 == 0 {
+			target = other
+		}
+		target.record(time.Duration(us) * time.Microsecond)
+	}
+	h.merge(other)
+	assert.Equal(t, int64(1000), h.count)
+	assert.Equal(t, 1000*time.Microsecond, h.max)
+	assert.Equal(t, 500500*time.Microsecond/1000, h.mean())
+	assert.Equal(t, 100*time.Microsecond, h.percentile(10))
+	// 相对误差不超过 1/64
+	for _, p := range []float64{50, 90, 99, 99.9} {
+		want := float64(time.Duration(p*10) * time.Microsecond)
+		got := float64(h.percentile(p))
+		assert.True(t, got >= want && got <= want*(1+1.0/latencySubBuckets))
+	}
+	assert.Equal(t, 1000*time.Microsecond, h.percentile(100))
+}
+
+func TestRunLoadPipelined(t *testing.T) {
+	addr, db := startTestServer(t)
+	mix, err := parseMix(strings.Join(benchCommands, ","))
+	assert.NoError(t, err)
+	cfg := loadConfig{
+		addr:     addr,
+		clients:  4,
+		pipeline: 16,
+		requests: 1000,
+		minSize:  1,
+		maxSize:  64,
+		keyspace: 50,
+		dist:     "zipfian",
+		zipfS:    1.1,
+		seed:     1,
+	}
+	res, err := runLoad(cfg, mix)
+	assert.NoError(t, err)
+	// 请求数不是批次大小的整数倍时最后一批较小
+	assert.Equal(t, int64(1000), res.requests)
+	assert.Equal(t, int64(0), res.errors)
+	assert.True(t, res.latency.percentile(99) > 0)
+
+	// 计数器的值不是整数时 INCR 回复错误，计入错误数
+	assert.NoError(t, db.Set("bench:counter:0", "x"))
+	mix, err = parseMix("PING,INCR")
+	assert.NoError(t, err)
+	cfg.dist, cfg.keyspace, cfg.pipeline = "uniform", 1, 1
+	res, err = runLoad(cfg, mix)
+	assert.NoError(t, err)
+	assert.True(t, res.errors > 0)
+
+	report := benchReport{Settings: benchSettings{Addr: addr, Clients: 4, Distribution: "uniform"}}
+	report.Results = append(report.Results, newBenchResult(mix.String(), res))
+	var buf bytes.Buffer
+	assert.NoError(t, writeCSVReport(&buf, report))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+	assert.True(t, strings.HasPrefix(lines[1], `"PING=1,INCR=1",1000,`))
+	buf.Reset()
+	assert.NoError(t, writeJSONReport(&buf, report))
+	var decoded benchReport
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, int64(1000), decoded.Results[0].Requests)
+
+	_, err = runLoad(loadConfig{addr: addr, clients: 1, pipeline: 1, requests: 1, keyspace: 1, dist: "pareto"}, mix)
+	assert.Error(t, err)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/bits"
+	"strconv"
+	"time"
+)
+
+// latencySubBuckets 每个 2 的幂区间内的桶数，决定直方图的相对误差（1/64）
+const latencySubBuckets = 64
+
+// latencyHistogram 以微秒为单位的对数线性直方图：小于 2*latencySubBuckets 微秒时每微秒一个桶，
+// 之后每个 2 的幂区间分为 latencySubBuckets 个桶。内存与请求数无关，各连接的直方图可以合并
+type latencyHistogram struct {
+	counts []int64
+	count  int64
+	sum    time.Duration
+	max    time.Duration
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{}
+}
+
+// latencyBucket 微秒数 us 所在的桶
+func latencyBucket(us uint64) int {
+	if us < 2*latencySubBuckets {
+		return int(us)
+	}
+	shift := bits.Len64(us) - 7
+	return 2*latencySubBuckets + (shift-1)*latencySubBuckets + int(us>>shift) - latencySubBuckets
+}
+
+// latencyBucketMax 桶 i 中的最大微秒数
+func latencyBucketMax(i int) uint64 {
+	if i < 2*latencySubBuckets {
+		return uint64(i)
+	}
+	shift := (i-2*latencySubBuckets)/latencySubBuckets + 1
+	top := uint64((i-2*latencySubBuckets)%latencySubBuckets + latencySubBuckets)
+	return (top+1)<<shift - 1
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	// #nosec G115 - 耗时非负
+	i := latencyBucket(uint64(d.Microseconds()))
+	if i >= len(h.counts) {
+		h.counts = append(h.counts, make([]int64, i+1-len(h.counts))...)
+	}
+	h.counts[i]++
+	h.count++
+	h.sum += d
+	h.max = max(h.max, d)
+}
+
+func (h *latencyHistogram) merge(o *latencyHistogram) {
+	if len(o.counts) > len(h.counts) {
+		h.counts = append(h.counts, make([]int64, len(o.counts)-len(h.counts))...)
+	}
+	for i, n := range o.counts {
+		h.counts[i] += n
+	}
+	h.count += o.count
+	h.sum += o.sum
+	h.max = max(h.max, o.max)
+}
+
+// percentile 返回不小于 p（0-100）比例的请求的延迟上界，不超过记录到的最大值
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := int64(float64(h.count)*p/100 + 0.5)
+	rank = max(rank, 1)
+	var seen int64
+	for i, n := range h.counts {
+		if seen += n; seen >= rank {
+			// #nosec G115 - 桶的上界远小于 int64 的范围
+			return min(time.Duration(latencyBucketMax(i))*time.Microsecond, h.max)
+		}
+	}
+	return h.max
+}
+
+func (h *latencyHistogram) mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// benchResult 报告中的一项测试结果，延迟以毫秒为单位
+type benchResult struct {
+	Test      string  `json:"test"`
+	Requests  int64   `json:"requests"`
+	Errors    int64   `json:"errors"`
+	Seconds   float64 `json:"seconds"`
+	OpsPerSec float64 `json:"ops_per_sec"`
+	AvgMs     float64 `json:"avg_ms"`
+	P50Ms     float64 `json:"p50_ms"`
+	P90Ms     float64 `json:"p90_ms"`
+	P99Ms     float64 `json:"p99_ms"`
+	P999Ms    float64 `json:"p999_ms"`
+	MaxMs     float64 `json:"max_ms"`
+}
+
+func newBenchResult(test string, r *loadResult) benchResult {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return benchResult{
+		Test:      test,
+		Requests:  r.requests,
+		Errors:    r.errors,
+		Seconds:   r.elapsed.Seconds(),
+		OpsPerSec: float64(r.requests) / r.elapsed.Seconds(),
+		AvgMs:     ms(r.latency.mean()),
+		P50Ms:     ms(r.latency.percentile(50)),
+		P90Ms:     ms(r.latency.percentile(90)),
+		P99Ms:     ms(r.latency.percentile(99)),
+		P999Ms:    ms(r.latency.percentile(99.9)),
+		MaxMs:     ms(r.latency.max),
+	}
+}
+
+// benchSettings 报告中记录的测试参数
+type benchSettings struct {
+	Addr         string  `json:"addr"`
+	Clients      int     `json:"clients"`
+	Pipeline     int     `json:"pipeline"`
+	Requests     int64   `json:"requests"`
+	DataSize     string  `json:"data_size"`
+	Keyspace     uint64  `json:"keyspace"`
+	Distribution string  `json:"distribution"`
+	ZipfS        float64 `json:"zipf_s,omitempty"`
+}
+
+// benchReport -json 输出的报告
+type benchReport struct {
+	Time     time.Time     `json:"time"`
+	Settings benchSettings `json:"settings"`
+	Results  []benchResult `json:"results"`
+}
+
+// printResult 以类似 redis-benchmark 的格式输出一项结果
+func printResult(w io.Writer, r benchResult) {
+	_, _ = fmt.Fprintf(w, "====== %s ======\n", r.Test)
+	_, _ = fmt.Fprintf(w, "  %d requests completed in %.2f seconds, %d errors\n", r.Requests, r.Seconds, r.Errors)
+	_, _ = fmt.Fprintf(w, "  throughput: %.2f requests per second\n", r.OpsPerSec)
+	_, _ = fmt.Fprintf(w, "  latency (msec): avg %.3f  p50 %.3f  p90 %.3f  p99 %.3f  p99.9 %.3f  max %.3f\n\n",
+		r.AvgMs, r.P50Ms, r.P90Ms, r.P99Ms, r.P999Ms, r.MaxMs)
+}
+
+// writeJSONReport 写入 JSON 报告
+func writeJSONReport(w io.Writer, report benchReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// writeCSVReport 写入 CSV 报告，每项测试一行，并附上测试参数
+func writeCSVReport(w io.Writer, report benchReport) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{
+		"test", "requests", "errors", "seconds", "ops_per_sec",
+		"avg_ms", "p50_ms", "p90_ms", "p99_ms", "p999_ms", "max_ms",
+		"clients", "pipeline", "data_size", "keyspace", "distribution",
+	})
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	s := report.Settings
+	dist := s.Distribution
+	if s.ZipfS > 0 {
+		dist += ":" + strconv.FormatFloat(s.ZipfS, 'g', -1, 64)
+	}
+	for _, r := range report.Results {
+		_ = cw.Write([]string{
+			r.Test, strconv.FormatInt(r.Requests, 10), strconv.FormatInt(r.Errors, 10), f(r.Seconds), f(r.OpsPerSec),
+			f(r.AvgMs), f(r.P50Ms), f(r.P90Ms), f(r.P99Ms), f(r.P999Ms), f(r.MaxMs),
+			strconv.Itoa(s.Clients), strconv.Itoa(s.Pipeline), s.DataSize,
+			strconv.FormatUint(s.Keyspace, 10), dist,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -1,64 +1,187 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"flag"
 	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/lbp0200/BoltDB/internal/aof"
 	"github.com/lbp0200/BoltDB/internal/backup"
 	"github.com/lbp0200/BoltDB/internal/cluster"
+	"github.com/lbp0200/BoltDB/internal/config"
 	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/mirror"
 	"github.com/lbp0200/BoltDB/internal/replication"
 	"github.com/lbp0200/BoltDB/internal/server"
+	"github.com/lbp0200/BoltDB/internal/tlsconf"
 
 	"github.com/lbp0200/BoltDB/internal/store"
 )
 
 func main() {
 	addr := flag.String("addr", ":6379", "listen addr")
+	listeners := flag.Int("listeners", 1, "number of SO_REUSEPORT listeners on addr to spread accept/read load across cores, 0 for one per CPU")
+	tlsCert := flag.String("tls-cert", "", "PEM certificate; when set with --tls-key, clients must connect over TLS")
+	tlsKey := flag.String("tls-key", "", "PEM private key for --tls-cert")
+	tlsCA := flag.String("tls-ca", "", "PEM CA bundle used to verify client certificates and, with --tls-replication, the master's certificate")
+	tlsAuthClients := flag.Bool("tls-auth-clients", false, "require every client to present a certificate signed by --tls-ca")
+	tlsAuthReplicas := flag.Bool("tls-auth-replicas", false, "require replicas (PSYNC) to present a certificate signed by --tls-ca")
+	tlsReplication := flag.Bool("tls-replication", false, "connect to the --replicaof master over TLS, presenting --tls-cert as client certificate")
 	dbPath := flag.String("dir", os.TempDir(), "badger dir")
 	logLevel := flag.String("log-level", "", "log level: DEBUG, INFO, WARNING, ERROR (default: WARNING, or from BOLTDB_LOG_LEVEL env)")
 	clusterEnabled := flag.Bool("cluster", false, "enable cluster mode")
+	clusterAnnounceIP := flag.String("cluster-announce-ip", "", "IP this node reports in MOVED/ASK, CLUSTER SLOTS and CLUSTER NODES (default: the --addr host, or 127.0.0.1)")
 	replicaof := flag.String("replicaof", "", "replicaof master host:port")
+	iteratorPrefetch := flag.Int("iterator-prefetch", store.DefaultIteratorTuning.LargePrefetchSize, "values prefetched by iterators on large range reads (HGETALL, LRANGE 0 -1, ...)")
+	pubsubRetention := flag.Int64("pubsub-retention", store.DefaultDurableRetention, "messages retained per channel for SUBSCRIBE ... RESUME <token>")
+	maxBlockedClients := flag.Int("max-blocked-clients", store.DefaultBlockingLimits.MaxTotal, "max clients blocked in BLPOP/BRPOP/BLMOVE/XREAD BLOCK at once, -1 for unlimited")
+	maxBlockedPerKey := flag.Int("max-blocked-per-key", store.DefaultBlockingLimits.MaxPerKey, "max clients blocked on a single key; extra clients get an immediate empty reply, -1 for unlimited")
+	trashRetention := flag.Duration("trash-retention", 0, "keep keys removed by DEL/FLUSHDB in a recycle bin for this long (UNDELETE/PURGE); 0 disables")
+	encryptionKey := flag.String("encryption-key", "", "encrypt data at rest with the AES key from file:<path>, env:<NAME> or cmd:<command> (hex, base64 or raw 16/24/32 bytes); rotate it offline with cmd/rotate-key")
+	dataKeyRotation := flag.Duration("data-key-rotation", store.DefaultDataKeyRotation, "how often Badger generates a new data key when encryption is enabled")
+	maxValueSize := flag.Int64("max-value-size", store.DefaultMaxValueSize, "max bytes of a single value (string, field value, member, ...); larger writes fail, capped below the Badger value log file size")
+	decompressCacheSize := flag.Int64("decompress-cache-size", store.DefaultDecompressCacheSize, "bytes of decompressed values cached for repeated reads of large compressed values, -1 disables")
+	tierDir := flag.String("tier-dir", "", "move string values not read for --tier-cold-after to a secondary Badger dir on cheaper storage, read back transparently; empty disables")
+	tierMinValueSize := flag.Int64("tier-min-value-size", store.DefaultTierMinValueSize, "only values at least this many bytes are moved to --tier-dir")
+	tierColdAfter := flag.Duration("tier-cold-after", store.DefaultTierColdAfter, "values not accessed for this long are moved to --tier-dir")
+	databases := flag.Int("databases", server.DefaultDatabases, "number of logical databases selectable with SELECT (0 to databases-1)")
+	maxCollectionReply := flag.Int64("max-collection-reply", server.DefaultMaxCollectionReply, "max elements returned by LRANGE/HGETALL/HKEYS/HVALS/SMEMBERS without FORCE; larger collections must be paged, -1 for unlimited")
+	shadowPercent := flag.Float64("shadow-percent", 0, "percent of read commands with a registered alternate implementation to also run through it, logging mismatches (BOLTREON.SHADOW); 0 disables")
+	writeStats := flag.Bool("write-stats", false, "record Badger keys/bytes written per command from startup (BOLTREON.WRITESTATS)")
+	metricsAddr := flag.String("metrics-addr", "", "serve OpenMetrics metrics (command latency and counts, connections, Badger, pub/sub and stream backlogs) on http://<addr>/metrics, e.g. :9121; empty disables")
+	latencyBuckets := flag.String("latency-buckets", "", "comma-separated latency histogram bucket bounds, e.g. 100us,1ms,10ms,100ms (default 50us..2.5s)")
+	mirrorUpstream := flag.String("mirror-upstream", "", "asynchronously forward every write command to this upstream Redis host:port (BOLTREON.MIRROR PAUSE|RESUME); password from BOLTREON_MIRROR_PASSWORD")
+	mirrorQueueSize := flag.Int("mirror-queue-size", mirror.DefaultQueueSize, "max write commands waiting to be forwarded to --mirror-upstream; further writes are dropped and counted")
+	warmup := flag.String("warmup", "", "comma-separated key patterns to preload before serving, e.g. user:*,session:*")
+	warmupHotKeys := flag.Bool("warmup-hotkeys", true, "preload the hot-key list persisted by the previous run")
+	warmupLimit := flag.Int("warmup-limit", store.DefaultHotKeyLimit, "max number of keys to preload on startup")
+	appendOnly := flag.Bool("appendonly", false, "log every write command to --appendfilename; on startup an empty --dir is rebuilt by replaying the file (BGREWRITEAOF compacts it)")
+	appendFsync := flag.String("appendfsync", aof.FsyncEverysec, "when to fsync the append-only file: always, everysec or no")
+	appendFilename := flag.String("appendfilename", "appendonly.aof", "append-only file name, relative to --dir unless absolute")
+	dbFilename := flag.String("dbfilename", backup.DefaultDBFilename, "RDB file written by SAVE/BGSAVE, relative to <dir>/backup unless absolute")
+	backupS3Bucket := flag.String("backup-s3-bucket", "", "also upload backups (BOLTREON.BACKUP, backup-schedule, SAVE/BGSAVE) to this S3 bucket; credentials from AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY unless --backup-s3-access-key-id is set")
+	backupS3Endpoint := flag.String("backup-s3-endpoint", "", "S3-compatible endpoint, e.g. https://minio.local:9000 (default: AWS S3 in --backup-s3-region)")
+	backupS3Region := flag.String("backup-s3-region", "", "S3 region (default: AWS_REGION, or us-east-1)")
+	backupS3Prefix := flag.String("backup-s3-prefix", "", "object name prefix for uploaded backups, e.g. boltdb/prod")
+	backupS3PathStyle := flag.Bool("backup-s3-path-style", false, "address objects as <endpoint>/<bucket>/<key> instead of <bucket>.<endpoint host> (MinIO and most self-hosted stores)")
+	backupS3PartSize := flag.Int64("backup-s3-part-size", backup.DefaultS3PartSize, "multipart upload part size in bytes (at least 5MB); larger backups are streamed in parts")
+	backupS3SSE := flag.String("backup-s3-sse", "", "server-side encryption for uploaded backups: AES256 or aws:kms; empty uses the bucket default")
+	backupS3KMSKeyID := flag.String("backup-s3-sse-kms-key-id", "", "KMS key for --backup-s3-sse aws:kms (default: the AWS managed key)")
+	backupS3AccessKeyID := flag.String("backup-s3-access-key-id", "", "S3 access key id (default: AWS_ACCESS_KEY_ID)")
+	backupS3SecretAccessKey := flag.String("backup-s3-secret-access-key", "", "S3 secret access key (default: AWS_SECRET_ACCESS_KEY); prefer the environment or a config file over the command line")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "on SIGINT/SIGTERM, how long to wait for connections to finish the commands already read before closing them")
+	configFile := flag.String("config", "", "redis.conf-style file with flag names (dir, max-value-size, ...) and CONFIG parameters (maxmemory, loglevel, slowlog-log-slower-than, ...); command-line flags take precedence, CONFIG REWRITE writes runtime changes back")
 	flag.Parse()
 
+	// 配置文件中与命令行参数同名的参数在命令行未指定时生效，其余为 CONFIG 参数，创建 Handler 后应用
+	explicitFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicitFlags[f.Name] = true })
+	var confFile *config.File
+	if *configFile != "" {
+		f, err := config.Load(*configFile)
+		if err != nil {
+			logger.Logger.Fatal().Err(err).Msg("Failed to load config file")
+		}
+		for _, d := range f.Directives() {
+			if flag.Lookup(d.Name) == nil || explicitFlags[d.Name] {
+				continue
+			}
+			value := d.Value()
+			// 布尔参数与 redis.conf 相同地接受 yes/no
+			if b, ok := flag.Lookup(d.Name).Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+				switch strings.ToLower(value) {
+				case "yes":
+					value = "true"
+				case "no":
+					value = "false"
+				}
+			}
+			if err := flag.Set(d.Name, value); err != nil {
+				logger.Logger.Fatal().Err(err).Str("path", f.Path()).Int("line", d.Line).Msg("Invalid config directive")
+			}
+		}
+		confFile = f
+	}
+
 	// 设置日志级别
 	if *logLevel != "" {
 		logger.SetLevelFromString(*logLevel)
 	}
 
-	db, err := store.NewBotreonStore(*dbPath)
+	db, err := store.NewBotreonStoreWithOptions(*dbPath, store.StoreOptions{
+		Iterator:            store.IteratorTuning{LargePrefetchSize: *iteratorPrefetch},
+		Blocking:            store.BlockingLimits{MaxPerKey: *maxBlockedPerKey, MaxTotal: *maxBlockedClients},
+		TrashRetention:      *trashRetention,
+		EncryptionKeySource: *encryptionKey,
+		DataKeyRotation:     *dataKeyRotation,
+		MaxValueSize:        *maxValueSize,
+		DecompressCacheSize: *decompressCacheSize,
+		Tiering: store.TieringOptions{
+			Dir:          *tierDir,
+			MinValueSize: *tierMinValueSize,
+			ColdAfter:    *tierColdAfter,
+		},
+	})
 	if err != nil {
 		logger.Logger.Fatal().Err(err).Msg("Failed to create store")
 	}
+	db.EnableWriteStats(*writeStats)
 	defer func() {
 		if err := db.Close(); err != nil {
 			logger.Logger.Error().Err(err).Msg("failed to close database")
 		}
 	}()
 
-	// 启动时恢复数据状态
-	if err := db.NextStartup(); err != nil {
-		logger.Logger.Error().Err(err).Msg("Failed to run nextStartup")
-	}
-
 	// 初始化复制管理器
 	replMgr := replication.NewReplicationManager(db)
-
-	// 如果指定了 -replicaof 参数，启动从复制
-	if *replicaof != "" {
-		logger.Logger.Info().Str("master", *replicaof).Msg("Starting slave replication")
-		if err := replication.StartSlaveReplication(replMgr, db, *replicaof); err != nil {
-			logger.Logger.Fatal().Err(err).Str("master", *replicaof).Msg("Failed to start slave replication")
+	if _, port, err := net.SplitHostPort(*addr); err == nil {
+		if p, err := strconv.Atoi(port); err == nil {
+			replMgr.SetListeningPort(p)
 		}
 	}
 
 	// 初始化备份管理器
 	backupDir := *dbPath + "/backup"
 	backupMgr := backup.NewBackupManager(db, backupDir)
+	backupMgr.SetDBFilename(*dbFilename)
+	if *backupS3Bucket != "" {
+		target, err := backup.NewS3Target(backup.S3Config{
+			Endpoint:        *backupS3Endpoint,
+			Region:          *backupS3Region,
+			Bucket:          *backupS3Bucket,
+			Prefix:          *backupS3Prefix,
+			AccessKeyID:     *backupS3AccessKeyID,
+			SecretAccessKey: *backupS3SecretAccessKey,
+			PathStyle:       *backupS3PathStyle,
+			PartSize:        *backupS3PartSize,
+			SSE:             *backupS3SSE,
+			SSEKMSKeyID:     *backupS3KMSKeyID,
+		})
+		if err != nil {
+			logger.Logger.Fatal().Err(err).Msg("Invalid S3 backup target")
+		}
+		backupMgr.AddTarget(target)
+	}
+	// 停止 backup-schedule 的定时备份，在关闭存储之前执行
+	defer backupMgr.Close()
 
 	// 初始化Pub/Sub管理器
 	pubsubMgr := store.NewPubSubManager()
+	pubsubMgr.EnableDurable(db, *pubsubRetention)
+	// 排行榜前 N 名变化事件通过 Pub/Sub 发布
+	db.SetZWatchPublisher(func(channel string, message []byte) {
+		pubsubMgr.Publish(channel, message)
+	})
 
 	handler := &server.Handler{
 		Db:          db,
@@ -67,23 +190,202 @@ func main() {
 		PubSub:      pubsubMgr,
 	}
 
+	// 启动参数可用 CONFIG GET 读取（加密密钥来源与 S3 密钥除外）
+	flag.VisitAll(func(f *flag.Flag) {
+		if f.Name != "encryption-key" && f.Name != "backup-s3-secret-access-key" {
+			handler.SetStartupConfig(f.Name, f.Value.String())
+		}
+	})
+	if confFile != nil {
+		err := handler.LoadConfigFile(confFile, func(name string) bool {
+			return flag.Lookup(name) != nil || (name == "loglevel" && explicitFlags["log-level"])
+		})
+		if err != nil {
+			logger.Logger.Fatal().Err(err).Msg("Invalid config file")
+		}
+	}
+	aofPath := *appendFilename
+	if !filepath.IsAbs(aofPath) {
+		aofPath = filepath.Join(*dbPath, aofPath)
+	}
+	if err := handler.ConfigureAOF(aofPath, strings.ToLower(*appendFsync)); err != nil {
+		logger.Logger.Fatal().Err(err).Msg("Invalid append-only file configuration")
+	}
+	defer func() {
+		if err := handler.CloseAOF(); err != nil {
+			logger.Logger.Error().Err(err).Msg("failed to close append-only file")
+		}
+	}()
+	handler.SetMaxCollectionReply(*maxCollectionReply)
+	if *databases <= 0 {
+		logger.Logger.Fatal().Int("databases", *databases).Msg("Invalid number of databases")
+	}
+	handler.SetDatabases(*databases)
+	if err := handler.SetShadowPercent(*shadowPercent); err != nil {
+		logger.Logger.Fatal().Err(err).Msg("Invalid shadow percent")
+	}
+	tlsOpts := tlsconf.Options{CertFile: *tlsCert, KeyFile: *tlsKey, CAFile: *tlsCA, AuthClients: *tlsAuthClients}
+	if *tlsAuthReplicas {
+		if !tlsOpts.Enabled() || *tlsCA == "" {
+			logger.Logger.Fatal().Msg("--tls-auth-replicas requires --tls-cert, --tls-key and --tls-ca")
+		}
+		handler.SetRequireReplicaCert(true)
+	}
+	if *tlsReplication {
+		masterTLS, err := tlsconf.ClientConfig(tlsOpts)
+		if err != nil {
+			logger.Logger.Fatal().Err(err).Msg("Invalid TLS configuration")
+		}
+		replMgr.SetMasterTLS(masterTLS)
+	}
+	if *mirrorUpstream != "" {
+		handler.Mirror = mirror.New(*mirrorUpstream, mirror.Options{
+			QueueSize: *mirrorQueueSize,
+			Password:  os.Getenv("BOLTREON_MIRROR_PASSWORD"),
+		})
+		defer handler.Mirror.Close()
+		logger.Logger.Info().Str("upstream", *mirrorUpstream).Msg("Mirroring writes to upstream Redis")
+	}
+	if *latencyBuckets != "" {
+		buckets, err := server.ParseLatencyBuckets(*latencyBuckets)
+		if err != nil {
+			logger.Logger.Fatal().Err(err).Msg("Invalid latency buckets")
+		}
+		handler.SetLatencyBuckets(buckets)
+	}
+	if *metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", handler.MetricsHandler())
+		metricsServer := &http.Server{Addr: *metricsAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil {
+				logger.Logger.Error().Err(err).Str("addr", *metricsAddr).Msg("Metrics server failed")
+			}
+		}()
+	}
+
 	// 初始化集群（如果启用了集群模式）
 	if *clusterEnabled {
-		c, err := cluster.NewCluster(db, "", *addr)
+		announce := *addr
+		if *clusterAnnounceIP != "" {
+			_, port, err := net.SplitHostPort(*addr)
+			if err != nil {
+				logger.Logger.Fatal().Err(err).Str("addr", *addr).Msg("Invalid listen address")
+			}
+			announce = net.JoinHostPort(*clusterAnnounceIP, port)
+		}
+		c, err := cluster.NewCluster(db, "", announce)
 		if err != nil {
 			logger.Logger.Fatal().Err(err).Msg("Failed to create cluster")
 		}
 		handler.Cluster = c
 		logger.Logger.Info().Msg("Cluster mode enabled")
 	}
-	ln, err := net.Listen("tcp", *addr)
+	// 多个监听器时使用 SO_REUSEPORT，由内核把连接分配到各个监听器
+	listenerCount := *listeners
+	if listenerCount == 0 {
+		listenerCount = runtime.NumCPU()
+	}
+	var lns []net.Listener
+	if listenerCount > 1 {
+		lns, err = server.ListenReusePort(*addr, listenerCount)
+	} else {
+		var ln net.Listener
+		ln, err = net.Listen("tcp", *addr)
+		lns = []net.Listener{ln}
+	}
 	if err != nil {
 		logger.Logger.Fatal().Err(err).Str("addr", *addr).Msg("Failed to listen")
 	}
+	if tlsOpts.Enabled() {
+		tlsConfig, err := tlsconf.ServerConfig(tlsOpts)
+		if err != nil {
+			logger.Logger.Fatal().Err(err).Msg("Invalid TLS configuration")
+		}
+		for i, ln := range lns {
+			lns[i] = tls.NewListener(ln, tlsConfig)
+		}
+		logger.Logger.Info().Bool("auth_clients", *tlsAuthClients).Bool("auth_replicas", *tlsAuthReplicas).Msg("TLS enabled")
+	}
 	// 启动信息使用 WARN 级别，确保默认配置下也能显示
-	logger.Warning("BoltDB 服务器启动，监听地址: %s", *addr)
+	logger.Warning("BoltDB 服务器启动，监听地址: %s（%d 个监听器）", *addr, len(lns))
 	logger.Warning("当前日志级别: %s", logger.GetLevelString())
-	if err := handler.ServeTCP(ln); err != nil {
-		logger.Logger.Fatal().Err(err).Msg("Server failed")
+	// 在接受连接之前进入加载状态，恢复结束时由 RunLoading 清除：
+	// 恢复期间已接受连接，但除 PING/INFO/SHUTDOWN 外的命令返回 -LOADING
+	handler.SetLoading(true)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- handler.ServeListeners(lns)
+	}()
+
+	_ = handler.RunLoading(func() error {
+		// 启动时恢复数据状态
+		if err := db.NextStartup(); err != nil {
+			logger.Logger.Error().Err(err).Msg("Failed to run nextStartup")
+		}
+
+		// 开启 AOF：存储目录为空时先重放 AOF 文件
+		if *appendOnly {
+			if err := handler.StartAOF(); err != nil {
+				logger.Logger.Fatal().Err(err).Str("path", aofPath).Msg("Failed to start append-only file")
+			}
+		}
+
+		// 预热热点键（加载状态结束之前完成）
+		warmupOpts := store.WarmupOptions{
+			UseHotKeys: *warmupHotKeys,
+			MaxKeys:    *warmupLimit,
+		}
+		for _, pattern := range strings.Split(*warmup, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				warmupOpts.Patterns = append(warmupOpts.Patterns, pattern)
+			}
+		}
+		if warmupOpts.UseHotKeys || len(warmupOpts.Patterns) > 0 {
+			warmed, err := db.Warmup(warmupOpts)
+			if err != nil {
+				logger.Logger.Error().Err(err).Msg("Failed to warm up keys")
+			} else if warmed > 0 {
+				logger.Logger.Info().Int("keys", warmed).Msg("Warmup completed")
+			}
+		}
+		return nil
+	})
+
+	// 加载完成后开始执行到期的定时命令（BOLTREON.SCHEDULE）、主动过期和 value log 回收
+	stopScheduler := make(chan struct{})
+	defer close(stopScheduler)
+	go handler.RunScheduler(server.DefaultScheduleInterval, stopScheduler)
+	go handler.RunExpireSweeper(stopScheduler)
+	go handler.RunValueLogGC(stopScheduler)
+
+	// 如果指定了 -replicaof 参数，启动从复制
+	if *replicaof != "" {
+		logger.Logger.Info().Str("master", *replicaof).Msg("Starting slave replication")
+		if err := replication.StartSlaveReplication(replMgr, db, *replicaof); err != nil {
+			logger.Logger.Fatal().Err(err).Str("master", *replicaof).Msg("Failed to start slave replication")
+		}
+	}
+
+	// SIGINT/SIGTERM 或 SHUTDOWN 命令时停止接受连接，等待已有连接处理完已读入的命令，
+	// 断开复制连接，再由 defer 关闭 AOF 与存储（Badger 在 Close 时刷盘）
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-serveErr:
+		if err != nil {
+			logger.Logger.Fatal().Err(err).Msg("Server failed")
+		}
+	case sig := <-signals:
+		logger.Warning("收到信号 %s，开始关闭", sig)
+	case <-handler.ShutdownRequested():
+		logger.Warning("收到 SHUTDOWN 命令，开始关闭")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	if err := handler.Shutdown(ctx); err != nil {
+		logger.Logger.Warn().Err(err).Msg("连接未在 shutdown-timeout 内关闭，已强制关闭")
 	}
+	cancel()
+	replMgr.Stop()
+	logger.Warning("BoltDB 服务器已关闭")
 }
@@ -0,0 +1,147 @@
+// gen-fixtures 对照真实 Redis 生成 handler 测试使用的黄金文件
+//
+// 对目录中的每个 *.cmds 语料文件，先 FLUSHALL 清空参考 Redis，再逐条发送命令，
+// 记录 Redis 返回的原始 RESP 字节，写出同名的 *.golden 文件。
+//
+//	redis-server --port 6399 --save "" --appendonly no &
+//	go run ./cmd/gen-fixtures -addr 127.0.0.1:6399
+//	go test ./internal/server -run TestGoldenFixtures
+//
+// 注意：FLUSHALL 会清空参考实例的全部数据，不要指向生产 Redis。
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/fixtures"
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:6379", "参考 Redis 地址（会被 FLUSHALL 清空）")
+	dir := flag.String("dir", "internal/server/testdata/fixtures", "语料与黄金文件所在目录")
+	only := flag.String("only", "", "只生成指定语料（不含扩展名），多个用逗号分隔")
+	flag.Parse()
+
+	corpora, err := filepath.Glob(filepath.Join(*dir, "*.cmds"))
+	if err != nil {
+		fatalf("glob corpora: %v", err)
+	}
+	if len(corpora) == 0 {
+		fatalf("no *.cmds corpora found in %s", *dir)
+	}
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(*only, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			selected[name] = true
+		}
+	}
+
+	conn, err := net.DialTimeout("tcp", *addr, 5*time.Second)
+	if err != nil {
+		fatalf("connect %s: %v", *addr, err)
+	}
+	defer func() { _ = conn.Close() }()
+	c := &client{conn: conn, reader: bufio.NewReader(conn)}
+
+	version, err := c.serverVersion()
+	if err != nil {
+		fatalf("read server version: %v", err)
+	}
+
+	for _, path := range corpora {
+		name := strings.TrimSuffix(filepath.Base(path), ".cmds")
+		if len(selected) > 0 && !selected[name] {
+			continue
+		}
+		n, err := generate(c, path, version)
+		if err != nil {
+			fatalf("%s: %v", name, err)
+		}
+		fmt.Printf("%s: %d commands\n", name, n)
+	}
+}
+
+// generate 执行一个语料文件并写出对应的黄金文件
+func generate(c *client, corpusPath, version string) (int, error) {
+	f, err := os.Open(corpusPath)
+	if err != nil {
+		return 0, err
+	}
+	cmds, err := fixtures.ReadCorpus(f)
+	_ = f.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := c.do("FLUSHALL"); err != nil {
+		return 0, err
+	}
+	cases := make([]fixtures.Case, 0, len(cmds))
+	for _, args := range cmds {
+		reply, err := c.do(args...)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", fixtures.FormatCommandLine(args), err)
+		}
+		cases = append(cases, fixtures.Case{Args: args, Reply: reply})
+	}
+
+	goldenPath := strings.TrimSuffix(corpusPath, ".cmds") + ".golden"
+	out, err := os.Create(goldenPath)
+	if err != nil {
+		return 0, err
+	}
+	header := fmt.Sprintf("Code generated by cmd/gen-fixtures from %s against redis %s. DO NOT EDIT.",
+		filepath.Base(corpusPath), version)
+	if err := fixtures.WriteGolden(out, header, cases); err != nil {
+		_ = out.Close()
+		return 0, err
+	}
+	return len(cases), out.Close()
+}
+
+// client 最简单的同步 RESP 客户端，按原始字节返回响应
+type client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (c *client) do(args ...string) ([]byte, error) {
+	req := &proto.Array{Args: make([][]byte, len(args))}
+	for i, arg := range args {
+		req.Args[i] = []byte(arg)
+	}
+	if err := c.conn.SetDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		return nil, err
+	}
+	if _, err := c.conn.Write([]byte(req.String())); err != nil {
+		return nil, err
+	}
+	return fixtures.ReadRawReply(c.reader)
+}
+
+// serverVersion 从 INFO server 中读取 redis_version
+func (c *client) serverVersion() (string, error) {
+	reply, err := c.do("INFO", "server")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(reply), "\r\n") {
+		if v, ok := strings.CutPrefix(line, "redis_version:"); ok {
+			return v, nil
+		}
+	}
+	return "unknown", nil
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "gen-fixtures: "+format+"\n", args...)
+	os.Exit(1)
+}
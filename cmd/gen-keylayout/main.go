@@ -0,0 +1,24 @@
+// gen-keylayout 根据存储层的键编码注册表生成 docs/KEY_LAYOUT.md
+//
+// 修改 internal/store/key_layout.go 中的注册表后，在仓库根目录执行：
+//
+//	go generate ./internal/store
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+func main() {
+	out := flag.String("out", "docs/KEY_LAYOUT.md", "生成的文档")
+	flag.Parse()
+
+	if err := os.WriteFile(*out, []byte(store.KeyLayoutDoc()), 0o644); err != nil { // #nosec G306 - generated documentation
+		fmt.Fprintf(os.Stderr, "gen-keylayout: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,284 @@
+// gen-validators 根据命令参数表生成 handler 使用的参数校验代码
+//
+// 参数表格式见 internal/server/commands.spec，修改后在仓库根目录执行：
+//
+//	go generate ./internal/server
+//
+// 生成的 validators_gen.go 包含 commandArity（参数个数）和
+// commandArgValidators（整数、浮点数、分数区间、枚举参数的校验函数）；
+// 指定 -dispatch 时还包含 knownCommands：handler.go 中 executeCommand 处理的全部命令。
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// argKind 参数类型
+type argKind struct {
+	name     string   // key、string、integer、float、score 或 enum
+	values   []string // enum 的可选值
+	optional bool
+}
+
+// commandSpec 参数表中的一行
+type commandSpec struct {
+	name  string
+	arity int
+	args  []argKind
+	line  int
+}
+
+func main() {
+	in := flag.String("in", "commands.spec", "命令参数表")
+	out := flag.String("out", "validators_gen.go", "生成的 Go 文件")
+	pkg := flag.String("package", "server", "生成文件的包名")
+	dispatch := flag.String("dispatch", "", "包含 executeCommand 的 Go 文件，从中收集已实现的命令")
+	flag.Parse()
+
+	f, err := os.Open(*in)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	specs, err := parseSpec(f)
+	_ = f.Close()
+	if err != nil {
+		fatalf("%s:%v", *in, err)
+	}
+
+	var known []string
+	if *dispatch != "" {
+		if known, err = dispatchCommands(*dispatch, "executeCommand"); err != nil {
+			fatalf("%s: %v", *dispatch, err)
+		}
+	}
+
+	src, err := generate(*pkg, filepath.Base(*in), specs, known)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil { // #nosec G306 - generated source file
+		fatalf("%v", err)
+	}
+}
+
+// parseSpec 解析参数表，检查命令重复、arity 与必选参数是否矛盾
+func parseSpec(r io.Reader) ([]commandSpec, error) {
+	var specs []commandSpec
+	seen := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%d: expected <command> <arity> [args...]", lineNo)
+		}
+		spec := commandSpec{name: strings.ToUpper(fields[0]), line: lineNo}
+		if prev, dup := seen[spec.name]; dup {
+			return nil, fmt.Errorf("%d: %s already defined on line %d", lineNo, spec.name, prev)
+		}
+		seen[spec.name] = lineNo
+		arity, err := strconv.Atoi(fields[1])
+		if err != nil || arity == 0 {
+			return nil, fmt.Errorf("%d: invalid arity %q", lineNo, fields[1])
+		}
+		spec.arity = arity
+
+		for _, field := range fields[2:] {
+			kind, err := parseKind(field)
+			if err != nil {
+				return nil, fmt.Errorf("%d: %v", lineNo, err)
+			}
+			if !kind.optional && len(spec.args) > 0 && spec.args[len(spec.args)-1].optional {
+				return nil, fmt.Errorf("%d: required argument %q after optional one", lineNo, field)
+			}
+			spec.args = append(spec.args, kind)
+		}
+
+		// 必选参数必须由 arity 保证存在，生成的代码才能直接按下标访问
+		minArgs := arity - 1
+		if arity < 0 {
+			minArgs = -arity - 1
+		}
+		required := 0
+		for _, kind := range spec.args {
+			if !kind.optional {
+				required++
+			}
+		}
+		if required > minArgs {
+			return nil, fmt.Errorf("%d: %s has %d required arguments but arity %d allows %d", lineNo, spec.name, required, arity, minArgs)
+		}
+		if arity > 0 && len(spec.args) > minArgs {
+			return nil, fmt.Errorf("%d: %s lists %d arguments but arity %d allows %d", lineNo, spec.name, len(spec.args), arity, minArgs)
+		}
+		specs = append(specs, spec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].name < specs[j].name })
+	return specs, nil
+}
+
+func parseKind(field string) (argKind, error) {
+	var kind argKind
+	if strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]") {
+		kind.optional = true
+		field = field[1 : len(field)-1]
+	}
+	switch field {
+	case "key", "string", "integer", "float", "score":
+		kind.name = field
+	default:
+		values := strings.Split(field, "|")
+		for _, v := range values {
+			if v == "" || strings.ToUpper(v) != v {
+				return kind, fmt.Errorf("unknown argument kind %q", field)
+			}
+		}
+		kind.name = "enum"
+		kind.values = values
+	}
+	return kind, nil
+}
+
+// checked 是否需要为该类型生成校验代码
+func (k argKind) checked() bool {
+	return k.name != "key" && k.name != "string"
+}
+
+// dispatchCommands 收集 file 中函数 fn 第一层 switch 的字符串 case，即它处理的命令名
+func dispatchCommands(file, fn string) ([]string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), file, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, decl := range f.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Name.Name != fn || fd.Body == nil {
+			continue
+		}
+		for _, stmt := range fd.Body.List {
+			sw, ok := stmt.(*ast.SwitchStmt)
+			if !ok {
+				continue
+			}
+			var names []string
+			for _, clause := range sw.Body.List {
+				for _, expr := range clause.(*ast.CaseClause).List {
+					if lit, ok := expr.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+						name, err := strconv.Unquote(lit.Value)
+						if err != nil {
+							return nil, err
+						}
+						names = append(names, name)
+					}
+				}
+			}
+			sort.Strings(names)
+			return names, nil
+		}
+		return nil, fmt.Errorf("%s has no switch statement", fn)
+	}
+	return nil, fmt.Errorf("function %s not found", fn)
+}
+
+func generate(pkg, source string, specs []commandSpec, known []string) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by cmd/gen-validators from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	buf.WriteString("import \"github.com/lbp0200/BoltDB/internal/proto\"\n\n")
+
+	buf.WriteString("// commandArity 命令参数个数（含命令名），负数表示最少个数\n")
+	buf.WriteString("var commandArity = map[string]int{\n")
+	for _, spec := range specs {
+		fmt.Fprintf(&buf, "\t%q: %d,\n", spec.name, spec.arity)
+	}
+	buf.WriteString("}\n\n")
+
+	var validated []commandSpec
+	for _, spec := range specs {
+		for _, kind := range spec.args {
+			if kind.checked() {
+				validated = append(validated, spec)
+				break
+			}
+		}
+	}
+
+	buf.WriteString("// commandArgValidators 命令参数类型校验，args 不含命令名，调用前已通过 checkArity\n")
+	buf.WriteString("var commandArgValidators = map[string]func(args [][]byte) proto.RESP{\n")
+	for _, spec := range validated {
+		fmt.Fprintf(&buf, "\t%q: %s,\n", spec.name, validatorName(spec.name))
+	}
+	buf.WriteString("}\n")
+
+	for _, spec := range validated {
+		fmt.Fprintf(&buf, "\nfunc %s(args [][]byte) proto.RESP {\n", validatorName(spec.name))
+		for i, kind := range spec.args {
+			if !kind.checked() {
+				continue
+			}
+			var cond, errConst string
+			switch kind.name {
+			case "integer":
+				cond, errConst = fmt.Sprintf("!isIntegerArg(args[%d])", i), "errNotInteger"
+			case "float":
+				cond, errConst = fmt.Sprintf("!isFloatArg(args[%d])", i), "errNotFloat"
+			case "score":
+				cond, errConst = fmt.Sprintf("!isScoreArg(args[%d])", i), "errScoreNotFloat"
+			case "enum":
+				quoted := make([]string, len(kind.values))
+				for j, v := range kind.values {
+					quoted[j] = strconv.Quote(v)
+				}
+				cond, errConst = fmt.Sprintf("!isEnumArg(args[%d], %s)", i, strings.Join(quoted, ", ")), "errSyntax"
+			}
+			if kind.optional {
+				cond = fmt.Sprintf("len(args) > %d && %s", i, cond)
+			}
+			fmt.Fprintf(&buf, "\tif %s {\n\t\treturn proto.NewError(%s)\n\t}\n", cond, errConst)
+		}
+		buf.WriteString("\treturn nil\n}\n")
+	}
+
+	if len(known) > 0 {
+		buf.WriteString("\n// knownCommands executeCommand 处理的命令，事务入队时据此拒绝未知命令\n")
+		buf.WriteString("var knownCommands = map[string]bool{\n")
+		for _, name := range known {
+			fmt.Fprintf(&buf, "\t%q: true,\n", name)
+		}
+		buf.WriteString("}\n")
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// validatorName INCRBYFLOAT -> validateIncrbyfloat
+func validatorName(cmd string) string {
+	lower := strings.ToLower(strings.NewReplacer(".", "_", "-", "_").Replace(cmd))
+	return "validate" + strings.ToUpper(lower[:1]) + lower[1:]
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "gen-validators: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -2,6 +2,7 @@ package integration
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"strings"
 	"testing"
@@ -349,12 +350,12 @@ func TestClusterMGetMSet(t *testing.T) {
 
 	ctx := context.Background()
 
-	// MSET
-	err := clusterClient.MSet(ctx, "k1", "v1", "k2", "v2").Err()
+	// MSET（多键命令的键必须在同一槽位，使用 hash tag）
+	err := clusterClient.MSet(ctx, "{k}1", "v1", "{k}2", "v2").Err()
 	assert.NoError(t, err)
 
 	// MGET
-	values, err := clusterClient.MGet(ctx, "k1", "k2").Result()
+	values, err := clusterClient.MGet(ctx, "{k}1", "{k}2").Result()
 	assert.NoError(t, err)
 	// values is already []interface{} from MGet
 	assert.Equal(t, 2, len(values))
@@ -368,16 +369,16 @@ func TestClusterDel(t *testing.T) {
 	ctx := context.Background()
 
 	// 准备测试数据
-	_ = clusterClient.Set(ctx, "key1", "value1", 0).Err()
-	_ = clusterClient.Set(ctx, "key2", "value2", 0).Err()
+	_ = clusterClient.Set(ctx, "{key}1", "value1", 0).Err()
+	_ = clusterClient.Set(ctx, "{key}2", "value2", 0).Err()
 
 	// DEL 单个键
-	deleted, err := clusterClient.Del(ctx, "key1").Result()
+	deleted, err := clusterClient.Del(ctx, "{key}1").Result()
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), deleted)
 
 	// DEL 多个键
-	deleted, err = clusterClient.Del(ctx, "key1", "key2").Result()
+	deleted, err = clusterClient.Del(ctx, "{key}1", "{key}2").Result()
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), deleted)
 }
@@ -390,15 +391,15 @@ func TestClusterExists(t *testing.T) {
 	ctx := context.Background()
 
 	// 准备测试数据
-	_ = clusterClient.Set(ctx, "key1", "value1", 0).Err()
+	_ = clusterClient.Set(ctx, "{key}1", "value1", 0).Err()
 
 	// EXISTS 单个键
-	exists, err := clusterClient.Exists(ctx, "key1").Result()
+	exists, err := clusterClient.Exists(ctx, "{key}1").Result()
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), exists)
 
 	// EXISTS 多个键
-	exists, err = clusterClient.Exists(ctx, "key1", "key2").Result()
+	exists, err = clusterClient.Exists(ctx, "{key}1", "{key}2").Result()
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), exists)
 }
@@ -455,3 +456,191 @@ func TestClusterHashTag(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "value1", val)
 }
+
+// clusterTestNode 双节点集群测试中的一个节点
+type clusterTestNode struct {
+	db       *store.BotreonStore
+	cluster  *cluster.Cluster
+	listener net.Listener
+	addr     string
+}
+
+// setupClusterPair 启动两个互相知晓的 BoltDB 集群节点：
+// A 负责槽位 0-8191，B 负责槽位 8192-16383
+func setupClusterPair(t *testing.T) (*clusterTestNode, *clusterTestNode) {
+	nodes := make([]*clusterTestNode, 2)
+	for i := range nodes {
+		db, err := store.NewBotreonStore(t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to create store: %v", err)
+		}
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		c, err := cluster.NewCluster(db, "", l.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to create cluster: %v", err)
+		}
+		handler := &server.Handler{Db: db, Cluster: c}
+		go func() {
+			_ = handler.ServeTCP(l)
+		}()
+		nodes[i] = &clusterTestNode{db: db, cluster: c, listener: l, addr: l.Addr().String()}
+	}
+	a, b := nodes[0], nodes[1]
+	for _, n := range nodes {
+		for _, peer := range nodes {
+			if peer != n {
+				node := cluster.NewNode(peer.cluster.Myself.ID, peer.addr)
+				node.Flags = append(node.Flags, "master")
+				n.cluster.AddNode(node)
+			}
+		}
+		assert.NoError(t, n.cluster.AssignSlotRange(0, 8191, a.cluster.Myself.ID))
+		assert.NoError(t, n.cluster.AssignSlotRange(8192, cluster.SlotCount-1, b.cluster.Myself.ID))
+	}
+	t.Cleanup(func() {
+		for _, n := range nodes {
+			n.listener.Close()
+			n.db.Close()
+		}
+	})
+	time.Sleep(50 * time.Millisecond)
+	return a, b
+}
+
+// keyInRange 返回一个槽位落在 [start, end] 的键
+func keyInRange(prefix string, start, end uint32) string {
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("%s%d", prefix, i)
+		if slot := cluster.Slot(key); slot >= start && slot <= end {
+			return key
+		}
+	}
+}
+
+// TestClusterSlotsReply 测试 CLUSTER SLOTS 返回 go-redis 可解析的结构
+func TestClusterSlotsReply(t *testing.T) {
+	a, b := setupClusterPair(t)
+	ctx := context.Background()
+
+	client := redis.NewClient(&redis.Options{Addr: a.addr})
+	defer client.Close()
+
+	slots, err := client.ClusterSlots(ctx).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(slots))
+	for _, s := range slots {
+		assert.Equal(t, 1, len(s.Nodes))
+		switch s.Start {
+		case 0:
+			assert.Equal(t, 8191, s.End)
+			assert.Equal(t, a.addr, s.Nodes[0].Addr)
+			assert.Equal(t, a.cluster.Myself.ID, s.Nodes[0].ID)
+		case 8192:
+			assert.Equal(t, 16383, s.End)
+			assert.Equal(t, b.addr, s.Nodes[0].Addr)
+		default:
+			t.Fatalf("unexpected slot range %d-%d", s.Start, s.End)
+		}
+	}
+}
+
+// TestClusterRedirectErrors 测试重定向错误字符串与 Redis 完全一致
+func TestClusterRedirectErrors(t *testing.T) {
+	a, b := setupClusterPair(t)
+	ctx := context.Background()
+
+	clientA := redis.NewClient(&redis.Options{Addr: a.addr})
+	defer clientA.Close()
+	clientB := redis.NewClient(&redis.Options{Addr: b.addr})
+	defer clientB.Close()
+
+	// MOVED <slot> <ip:port>
+	keyB := keyInRange("moved", 8192, cluster.SlotCount-1)
+	err := clientA.Get(ctx, keyB).Err()
+	assert.Equal(t, fmt.Sprintf("MOVED %d %s", cluster.Slot(keyB), b.addr), err.Error())
+
+	// CROSSSLOT
+	keyA := keyInRange("cross", 0, 8191)
+	err = clientA.MSet(ctx, keyA, "1", keyB, "2").Err()
+	assert.Equal(t, "CROSSSLOT Keys in request don't hash to the same slot", err.Error())
+
+	// 槽位迁移：A 迁出，B 导入（使用 hash tag 让多个键落在同一槽位）
+	tag := "{" + keyInRange("mig", 0, 8191) + "}"
+	slot := cluster.Slot(tag)
+	assert.NoError(t, clientA.Do(ctx, "CLUSTER", "SETSLOT", slot, "MIGRATING", b.cluster.Myself.ID).Err())
+	assert.NoError(t, clientB.Do(ctx, "CLUSTER", "SETSLOT", slot, "IMPORTING", a.cluster.Myself.ID).Err())
+
+	// 本地不存在的键：ASK <slot> <ip:port>
+	err = clientA.Get(ctx, tag+"missing").Err()
+	assert.Equal(t, fmt.Sprintf("ASK %d %s", slot, b.addr), err.Error())
+
+	// 导入方未收到 ASKING：MOVED 回到槽位所有者
+	err = clientB.Get(ctx, tag+"missing").Err()
+	assert.Equal(t, fmt.Sprintf("MOVED %d %s", slot, a.addr), err.Error())
+
+	// 部分键已迁走的多键请求：TRYAGAIN
+	assert.NoError(t, a.db.Set(tag+"present", "v"))
+	err = clientA.MGet(ctx, tag+"present", tag+"missing").Err()
+	assert.Equal(t, "TRYAGAIN Multiple keys request during rehashing of slot", err.Error())
+
+	// 迁移结束后恢复正常
+	assert.NoError(t, clientA.Do(ctx, "CLUSTER", "SETSLOT", slot, "STABLE").Err())
+	assert.NoError(t, clientA.MGet(ctx, tag+"present", tag+"missing").Err())
+
+	// 槽位未分配：CLUSTERDOWN
+	assert.NoError(t, clientA.Do(ctx, "CLUSTER", "FLUSHSLOTS").Err())
+	err = clientA.Get(ctx, keyA).Err()
+	assert.Equal(t, "CLUSTERDOWN Hash slot not served", err.Error())
+}
+
+// TestClusterClientRedirects 测试 go-redis ClusterClient 能跟随 MOVED/ASK 重定向
+func TestClusterClientRedirects(t *testing.T) {
+	a, b := setupClusterPair(t)
+	ctx := context.Background()
+
+	client := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{a.addr}})
+	defer client.Close()
+
+	// 按槽位路由到两个节点
+	keyA := keyInRange("route", 0, 8191)
+	keyB := keyInRange("route", 8192, cluster.SlotCount-1)
+	assert.NoError(t, client.Set(ctx, keyA, "a", 0).Err())
+	assert.NoError(t, client.Set(ctx, keyB, "b", 0).Err())
+	val, err := a.db.Get(keyA)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", val)
+	val, err = b.db.Get(keyB)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", val)
+
+	// 槽位所有权变更后客户端缓存的路由过期，B 返回 MOVED，客户端跟随到 A
+	slot := cluster.Slot(keyB)
+	for _, n := range []*clusterTestNode{a, b} {
+		assert.NoError(t, n.cluster.AssignSlot(slot, a.cluster.Myself.ID))
+	}
+	assert.NoError(t, client.Set(ctx, keyB, "moved", 0).Err())
+	val, err = a.db.Get(keyB)
+	assert.NoError(t, err)
+	assert.Equal(t, "moved", val)
+
+	// 槽位迁移中，A 上不存在的键通过 ASK 写入 B
+	keyM := keyInRange("ask", 0, 8191)
+	slot = cluster.Slot(keyM)
+	a.cluster.SetSlotMigrating(slot, b.cluster.Myself.ID)
+	b.cluster.SetSlotImporting(slot, a.cluster.Myself.ID)
+	assert.NoError(t, client.Set(ctx, keyM, "asked", 0).Err())
+	val, err = b.db.Get(keyM)
+	assert.NoError(t, err)
+	assert.Equal(t, "asked", val)
+	got, err := client.Get(ctx, keyM).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, "asked", got)
+
+	// 跨槽位多键命令返回 CROSSSLOT
+	err = client.Do(ctx, "MSET", keyA, "1", keyB, "2").Err()
+	assert.Error(t, err)
+	assert.Equal(t, "CROSSSLOT Keys in request don't hash to the same slot", err.Error())
+}
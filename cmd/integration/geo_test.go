@@ -132,3 +132,77 @@ func TestGeoSearchStore(t *testing.T) {
 	// 验证返回了结果
 	assert.True(t, result != nil)
 }
+
+// TestGeoSearchOptions 测试 GEOSEARCH 的 BYBOX、FROMMEMBER、ASC/DESC、COUNT ANY 与 GEOSEARCHSTORE
+func TestGeoSearchOptions(t *testing.T) {
+	setupTestServer(t)
+	defer teardownTestServer(t)
+
+	ctx := context.Background()
+
+	_, err := testClient.Do(ctx, "GEOADD", "Sicily", "13.361389", "38.115556", "Palermo", "15.087269", "37.502669", "Catania", "12.496366", "41.902782", "Rome").Result()
+	assert.NoError(t, err)
+
+	search := func(args ...interface{}) []interface{} {
+		t.Helper()
+		result, err := testClient.Do(ctx, append([]interface{}{"GEOSEARCH", "Sicily"}, args...)...).Result()
+		assert.NoError(t, err)
+		arr, ok := result.([]interface{})
+		assert.True(t, ok)
+		return arr
+	}
+
+	// 按距离排序
+	assert.DeepEqual(t, []interface{}{"Catania", "Palermo"}, search("FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "ASC"))
+	assert.DeepEqual(t, []interface{}{"Palermo", "Catania"}, search("FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "DESC"))
+
+	// 矩形范围：Rome 在 400km x 400km 之外
+	assert.DeepEqual(t, []interface{}{"Catania", "Palermo"}, search("FROMLONLAT", "15", "37", "BYBOX", "400", "400", "km", "ASC"))
+	assert.Equal(t, 3, len(search("FROMLONLAT", "15", "37", "BYBOX", "1000", "1200", "km")))
+
+	// COUNT 不带 ANY 时取最近的成员，带 ANY 时找到即返回
+	assert.DeepEqual(t, []interface{}{"Catania"}, search("FROMLONLAT", "15", "37", "BYRADIUS", "1000", "km", "COUNT", "1"))
+	assert.Equal(t, 1, len(search("FROMLONLAT", "15", "37", "BYRADIUS", "1000", "km", "COUNT", "1", "ANY")))
+
+	// 以成员的位置为中心
+	assert.DeepEqual(t, []interface{}{"Palermo"}, search("FROMMEMBER", "Palermo", "BYRADIUS", "100", "km"))
+	assert.DeepEqual(t, []interface{}{"Palermo", "Catania"}, search("FROMMEMBER", "Palermo", "BYBOX", "400", "400", "km", "ASC"))
+
+	// WITH* 选项时每个成员一个数组
+	withDist := search("FROMMEMBER", "Palermo", "BYRADIUS", "200", "km", "ASC", "WITHDIST", "WITHCOORD")
+	assert.Equal(t, 2, len(withDist))
+	first, ok := withDist[0].([]interface{})
+	assert.True(t, ok)
+	assert.Equal(t, 3, len(first))
+	assert.Equal(t, "Palermo", first[0])
+	assert.Equal(t, "0.0000", first[1])
+
+	// GEOSEARCHSTORE 覆盖目标键；STOREDIST 以距离为分数
+	stored, err := testClient.Do(ctx, "GEOSEARCHSTORE", "near", "Sicily", "FROMLONLAT", "15", "37", "BYBOX", "400", "400", "km").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stored)
+	result, err := testClient.Do(ctx, "GEOSEARCH", "near", "FROMLONLAT", "15", "37", "BYRADIUS", "5000", "km", "ASC").Result()
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{"Catania", "Palermo"}, result)
+	stored, err = testClient.Do(ctx, "GEOSEARCHSTORE", "dists", "Sicily", "FROMMEMBER", "Catania", "BYRADIUS", "1000", "km", "COUNT", "2", "STOREDIST").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stored)
+	members, err := testClient.ZRangeWithScores(ctx, "dists", 0, -1).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(members))
+	assert.Equal(t, "Catania", members[0].Member)
+	assert.Equal(t, 0.0, members[0].Score)
+	assert.True(t, members[1].Score > 150 && members[1].Score < 200)
+
+	// 参数错误
+	for _, args := range [][]interface{}{
+		{"FROMMEMBER", "Palermo", "FROMLONLAT", "15", "37", "BYRADIUS", "10", "km"},
+		{"FROMLONLAT", "15", "37", "BYRADIUS", "10", "km", "BYBOX", "1", "1", "km"},
+		{"FROMLONLAT", "15", "37", "BYRADIUS", "10", "km", "ANY"},
+		{"FROMLONLAT", "15", "37", "BYRADIUS", "10", "parsec"},
+		{"FROMMEMBER", "Naples", "BYRADIUS", "10", "km"},
+	} {
+		err := testClient.Do(ctx, append([]interface{}{"GEOSEARCH", "Sicily"}, args...)...).Err()
+		assert.Error(t, err)
+	}
+}
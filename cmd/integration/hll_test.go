@@ -2,6 +2,7 @@ package integration
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/zeebo/assert"
@@ -169,3 +170,33 @@ func TestPFInfo(t *testing.T) {
 	}
 	assert.True(t, found)
 }
+
+// TestHyperLogLogKeyspace 测试 HyperLogLog 与其他键类型及键空间命令的交互
+func TestHyperLogLogKeyspace(t *testing.T) {
+	setupTestServer(t)
+	defer teardownTestServer(t)
+
+	ctx := context.Background()
+
+	// 其他类型的键上返回 WRONGTYPE
+	assert.NoError(t, testClient.RPush(ctx, "hlllist", "a").Err())
+	err := testClient.PFAdd(ctx, "hlllist", "a").Err()
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "WRONGTYPE"))
+	assert.NoError(t, testClient.PFAdd(ctx, "hllks", "a", "b").Err())
+	err = testClient.PFCount(ctx, "hllks", "hlllist").Err()
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "WRONGTYPE"))
+
+	// 与 Redis 相同，TYPE 返回 string
+	assert.Equal(t, "string", testClient.Type(ctx, "hllks").Val())
+
+	// 不存在的源键被忽略
+	assert.NoError(t, testClient.PFMerge(ctx, "hllks", "hllnosuchkey").Err())
+	assert.Equal(t, int64(2), testClient.PFCount(ctx, "hllks").Val())
+
+	// DEL 后重新添加从空集开始
+	assert.Equal(t, int64(1), testClient.Del(ctx, "hllks").Val())
+	assert.Equal(t, int64(1), testClient.PFAdd(ctx, "hllks", "c").Val())
+	assert.Equal(t, int64(1), testClient.PFCount(ctx, "hllks").Val())
+}
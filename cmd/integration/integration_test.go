@@ -64,11 +64,12 @@ func setupTestServer(t *testing.T) {
 	// 等待服务器启动
 	time.Sleep(50 * time.Millisecond)
 
-	// 创建Redis客户端
+	// 创建Redis客户端；这些测试检查 RESP2 的回复格式，RESP3 见 TestRESP3Client
 	testClient = redis.NewClient(&redis.Options{
 		Addr:     listener.Addr().String(),
 		Password: "",
 		DB:       0,
+		Protocol: 2,
 	})
 
 	// 测试连接
@@ -599,6 +600,18 @@ func TestSetExtended(t *testing.T) {
 	card, _ := testClient.SCard(ctx, "spopset").Result()
 	assert.Equal(t, int64(2), card)
 
+	// SPOP count - 返回数组，超过集合大小时弹出全部
+	vals, err := testClient.SPopN(ctx, "spopset", 5).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(vals))
+	card, _ = testClient.SCard(ctx, "spopset").Result()
+	assert.Equal(t, int64(0), card)
+	vals, err = testClient.SPopN(ctx, "spopset", 1).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(vals))
+	err = testClient.Do(ctx, "SPOP", "spopset", "-1").Err()
+	assert.Error(t, err)
+
 	// SMOVE - 移动元素
 	_ = testClient.SAdd(ctx, "setmove1", "a", "b").Err()
 	_ = testClient.SAdd(ctx, "setmove2", "c").Err()
@@ -681,6 +694,96 @@ func TestStringAdvanced(t *testing.T) {
 	assert.Equal(t, "golang", val)
 }
 
+// TestGetExGetDel 测试 GETEX 与 GETDEL 命令
+func TestGetExGetDel(t *testing.T) {
+	setupTestServer(t)
+	defer teardownTestServer(t)
+
+	ctx := context.Background()
+
+	// GETEX - 设置过期时间并返回值
+	_ = testClient.Set(ctx, "getexkey", "value", 0).Err()
+	val, err := testClient.GetEx(ctx, "getexkey", 100*time.Second).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+	ttl := testClient.TTL(ctx, "getexkey").Val()
+	assert.True(t, ttl > 90*time.Second && ttl <= 100*time.Second)
+
+	// GETEX 不带选项时不修改过期时间
+	val, err = testClient.Do(ctx, "GETEX", "getexkey").Text()
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+	assert.True(t, testClient.TTL(ctx, "getexkey").Val() > 90*time.Second)
+
+	// GETEX PERSIST - 移除过期时间
+	val, err = testClient.Do(ctx, "GETEX", "getexkey", "PERSIST").Text()
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, time.Duration(-1), testClient.TTL(ctx, "getexkey").Val())
+
+	// GETEX PXAT - 已经过去的时间点删除键
+	val, err = testClient.Do(ctx, "GETEX", "getexkey", "PXAT", "1000").Text()
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, int64(0), testClient.Exists(ctx, "getexkey").Val())
+
+	// 键不存在时返回 nil
+	err = testClient.GetEx(ctx, "getexkey", time.Second).Err()
+	assert.Equal(t, redis.Nil, err)
+
+	// 参数错误
+	_ = testClient.Set(ctx, "getexkey", "value", 0).Err()
+	err = testClient.Do(ctx, "GETEX", "getexkey", "EX", "0").Err()
+	assert.Error(t, err)
+	assert.Equal(t, "ERR invalid expire time in 'getex' command", err.Error())
+	err = testClient.Do(ctx, "GETEX", "getexkey", "EX", "10", "PERSIST").Err()
+	assert.Error(t, err)
+	assert.Equal(t, "ERR syntax error", err.Error())
+
+	// GETDEL - 返回值并删除键
+	val, err = testClient.GetDel(ctx, "getexkey").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+	assert.Equal(t, int64(0), testClient.Exists(ctx, "getexkey").Val())
+	err = testClient.GetDel(ctx, "getexkey").Err()
+	assert.Equal(t, redis.Nil, err)
+
+	// 其他类型的键返回 WRONGTYPE
+	_ = testClient.RPush(ctx, "getexlist", "a").Err()
+	err = testClient.GetDel(ctx, "getexlist").Err()
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "WRONGTYPE"))
+}
+
+// TestSetArgs 测试 SET 的 NX/XX、GET、KEEPTTL 与过期选项
+func TestSetArgs(t *testing.T) {
+	setupTestServer(t)
+	defer teardownTestServer(t)
+
+	ctx := context.Background()
+
+	// 分布式锁：SET key value NX PX
+	ok, err := testClient.SetNX(ctx, "setargslock", "owner1", 10*time.Second).Result()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = testClient.SetNX(ctx, "setargslock", "owner2", 10*time.Second).Result()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// SET ... XX GET 返回旧值
+	old, err := testClient.SetArgs(ctx, "setargslock", "owner3", redis.SetArgs{Mode: "XX", Get: true, KeepTTL: true}).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, "owner1", old)
+	assert.True(t, testClient.TTL(ctx, "setargslock").Val() > 5*time.Second)
+	_, err = testClient.SetArgs(ctx, "setargsmissing", "v", redis.SetArgs{Mode: "XX", Get: true}).Result()
+	assert.Equal(t, redis.Nil, err)
+
+	// EXAT
+	at := time.Now().Add(time.Minute)
+	assert.NoError(t, testClient.SetArgs(ctx, "setargsexat", "v", redis.SetArgs{ExpireAt: at}).Err())
+	assert.True(t, testClient.TTL(ctx, "setargsexat").Val() > 50*time.Second)
+}
+
 // TestKeyAdvanced 测试高级Key命令
 func TestKeyAdvanced(t *testing.T) {
 	setupTestServer(t)
@@ -865,8 +968,7 @@ func TestSetAdvanced(t *testing.T) {
 	assert.Equal(t, 3, len(vals))
 }
 
-// TestTransaction 测试事务命令（MULTI/EXEC/DISCARD/WATCH/UNWATCH）
-// 由于go-redis客户端对MULTI/EXEC的支持有限，我们验证命令被正确识别
+// TestTransaction 测试事务命令（MULTI/EXEC/DISCARD/WATCH/UNWATCH）与 go-redis 的 Watch 乐观锁
 func TestTransaction(t *testing.T) {
 	setupTestServer(t)
 	defer teardownTestServer(t)
@@ -878,10 +980,10 @@ func TestTransaction(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "OK", result)
 
-	// 测试 WATCH - 监控键（无论键是否存在都返回监控的键数量）
+	// 测试 WATCH - 监控键（无论键是否存在都返回 OK）
 	result, err = testClient.Do(ctx, "WATCH", "watchkey").Result()
 	assert.NoError(t, err)
-	assert.Equal(t, int64(1), result)
+	assert.Equal(t, "OK", result)
 
 	// 测试 MULTI - 开始事务
 	result, err = testClient.Do(ctx, "MULTI").Result()
@@ -909,7 +1011,50 @@ func TestTransaction(t *testing.T) {
 	// 测试 WATCH 多个键
 	result, err = testClient.Do(ctx, "WATCH", "key1", "key2", "key3").Result()
 	assert.NoError(t, err)
-	assert.Equal(t, int64(3), result)
+	assert.Equal(t, "OK", result)
+
+	// TxPipelined：命令入队，EXEC 返回各命令的结果
+	cmds, err := testClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, "txcount", "1", 0)
+		pipe.Incr(ctx, "txcount")
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(cmds))
+	assert.Equal(t, int64(2), cmds[1].(*redis.IntCmd).Val())
+
+	// WATCH 的键在 EXEC 前被其他连接修改时事务失败
+	err = testClient.Watch(ctx, func(tx *redis.Tx) error {
+		n, err := tx.Get(ctx, "txcount").Int()
+		if err != nil {
+			return err
+		}
+		assert.NoError(t, testClient.Set(ctx, "txcount", "100", 0).Err())
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, "txcount", n+1, 0)
+			return nil
+		})
+		return err
+	}, "txcount")
+	assert.Equal(t, redis.TxFailedErr, err)
+	val, _ = testClient.Get(ctx, "txcount").Result()
+	assert.Equal(t, "100", val)
+
+	// 未被修改时事务成功
+	err = testClient.Watch(ctx, func(tx *redis.Tx) error {
+		n, err := tx.Get(ctx, "txcount").Int()
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, "txcount", n+1, 0)
+			return nil
+		})
+		return err
+	}, "txcount")
+	assert.NoError(t, err)
+	val, _ = testClient.Get(ctx, "txcount").Result()
+	assert.Equal(t, "101", val)
 }
 
 // TestCOPY 测试COPY命令
@@ -996,17 +1141,17 @@ func TestSetAdvancedCommands(t *testing.T) {
 	_ = testClient.SAdd(ctx, "smismem2", "b", "c", "e").Err()
 
 	// SINTERCARD - 返回交集基数
-	result, err := testClient.Do(ctx, "SINTERCARD", "smismem1", "smismem2").Result()
+	result, err := testClient.Do(ctx, "SINTERCARD", 2, "smismem1", "smismem2").Result()
 	assert.NoError(t, err)
 	assert.Equal(t, int64(2), result) // 交集是 {b, c}
 
 	// SINTERCARD - 单个集合
-	result, err = testClient.Do(ctx, "SINTERCARD", "smismem1").Result()
+	result, err = testClient.Do(ctx, "SINTERCARD", 1, "smismem1").Result()
 	assert.NoError(t, err)
 	assert.Equal(t, int64(4), result) // 只有smismem1时，返回其基数
 
 	// SINTERCARD - 无交集
-	result, err = testClient.Do(ctx, "SINTERCARD", "smismem1", "noset").Result()
+	result, err = testClient.Do(ctx, "SINTERCARD", 2, "smismem1", "noset").Result()
 	assert.NoError(t, err)
 	assert.Equal(t, int64(0), result)
 
@@ -1127,10 +1272,24 @@ func TestSWAPDB(t *testing.T) {
 
 	ctx := context.Background()
 
-	// BoltDB 是单数据库实现，SWAPDB 返回OK但不做任何操作
+	assert.NoError(t, testClient.Set(ctx, "swapkey", "v0", 0).Err())
 	result, err := testClient.Do(ctx, "SWAPDB", 0, 1).Result()
 	assert.NoError(t, err)
 	assert.Equal(t, "OK", result)
+
+	// 交换后 0 号数据库为空，键在 1 号数据库中
+	n, err := testClient.DBSize(ctx).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	conn := testClient.Conn()
+	defer conn.Close()
+	assert.NoError(t, conn.Select(ctx, 1).Err())
+	val, err := conn.Get(ctx, "swapkey").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, "v0", val)
+
+	_, err = testClient.Do(ctx, "SWAPDB", 0, 16).Result()
+	assert.Error(t, err)
 }
 
 // TestXAutoClaim 测试XAUTOCLAIM命令
@@ -1153,7 +1312,7 @@ func TestXAutoClaim(t *testing.T) {
 	assert.NoError(t, err)
 
 	// 读取消息以创建pending条目 - 使用原始命令
-	_, err = testClient.Do(ctx, "XREADGROUP", "GROUP", "mygroup", "consumer1", "COUNT", "1", "STREAMS", "mystream", "0").Result()
+	_, err = testClient.Do(ctx, "XREADGROUP", "GROUP", "mygroup", "consumer1", "COUNT", "1", "STREAMS", "mystream", ">").Result()
 	assert.NoError(t, err)
 
 	// 等待一段时间让消息idle
@@ -1166,8 +1325,11 @@ func TestXAutoClaim(t *testing.T) {
 	// 解析结果
 	arr, ok := autoClaimResult.([]interface{})
 	assert.True(t, ok)
-	// 格式: [nextID, [claimedIDs...], [messages...]]
-	assert.Equal(t, 1, len(arr)) // 至少返回nextID
+	// 格式: [nextID, [[id, [field, value...]]...], [deletedIDs...]]，start 本身包含在扫描范围内
+	assert.Equal(t, 3, len(arr))
+	assert.Equal(t, "0-0", arr[0])
+	assert.DeepEqual(t, []interface{}{[]interface{}{id1, []interface{}{"field1", "value1"}}}, arr[1])
+	assert.DeepEqual(t, []interface{}{}, arr[2])
 }
 
 // TestXInfoHelp 测试XINFO HELP命令
@@ -1424,9 +1586,10 @@ func TestJSONArrAppend(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Test JSON.ARRAPPEND
+	// JSONPath 为每个匹配的数组返回一个长度
 	count, err := testClient.Do(ctx, "JSON.ARRAPPEND", "arr", "$", "1", "2", "3").Result()
 	assert.NoError(t, err)
-	assert.Equal(t, int64(3), count)
+	assert.DeepEqual(t, []interface{}{int64(3)}, count)
 
 	// Verify array length
 	result, err := testClient.Do(ctx, "JSON.GET", "arr").Result()
@@ -1490,7 +1653,7 @@ func TestJSONNumIncrBy(t *testing.T) {
 	// Test JSON.NUMINCRBY
 	result, err := testClient.Do(ctx, "JSON.NUMINCRBY", "counter", "$", "5").Result()
 	assert.NoError(t, err)
-	assert.Equal(t, "15", result)
+	assert.Equal(t, "[15]", result)
 }
 
 // TestJSONNumMultBy 测试 JSON.NUMMULTBY 命令
@@ -1507,7 +1670,7 @@ func TestJSONNumMultBy(t *testing.T) {
 	// Test JSON.NUMMULTBY
 	result, err := testClient.Do(ctx, "JSON.NUMMULTBY", "counter", "$", "2").Result()
 	assert.NoError(t, err)
-	assert.Equal(t, "20", result)
+	assert.Equal(t, "[20]", result)
 }
 
 // TestJSONClear 测试 JSON.CLEAR 命令
@@ -1625,15 +1788,25 @@ func TestSelect(t *testing.T) {
 
 	ctx := context.Background()
 
-	// SELECT should return OK for any database number (BoltDB is single-db)
-	result, err := testClient.Do(ctx, "SELECT", "0").Result()
+	// 每个数据库的键互相独立；SELECT 只影响当前连接
+	assert.NoError(t, testClient.Set(ctx, "selkey", "db0", 0).Err())
+	conn := testClient.Conn()
+	defer conn.Close()
+	result, err := conn.Do(ctx, "SELECT", "15").Result()
 	assert.NoError(t, err)
 	assert.Equal(t, "OK", result)
+	assert.Equal(t, redis.Nil, conn.Get(ctx, "selkey").Err())
+	assert.NoError(t, conn.Set(ctx, "selkey", "db15", 0).Err())
 
-	// SELECT with other database numbers should also return OK
-	result, err = testClient.Do(ctx, "SELECT", "15").Result()
+	val, err := testClient.Get(ctx, "selkey").Result()
 	assert.NoError(t, err)
-	assert.Equal(t, "OK", result)
+	assert.Equal(t, "db0", val)
+	keys, err := conn.Keys(ctx, "*").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"selkey"}, keys)
+
+	_, err = conn.Do(ctx, "SELECT", "16").Result()
+	assert.Error(t, err)
 }
 
 // TestMove 测试 MOVE 命令
@@ -1643,10 +1816,23 @@ func TestMove(t *testing.T) {
 
 	ctx := context.Background()
 
-	// MOVE always returns 0 in single-db implementation
+	// 源键不存在时不移动
 	result, err := testClient.Do(ctx, "MOVE", "key", "1").Result()
 	assert.NoError(t, err)
 	assert.Equal(t, int64(0), result)
+
+	assert.NoError(t, testClient.Set(ctx, "key", "v", 0).Err())
+	result, err = testClient.Do(ctx, "MOVE", "key", "1").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), result)
+	assert.Equal(t, redis.Nil, testClient.Get(ctx, "key").Err())
+
+	conn := testClient.Conn()
+	defer conn.Close()
+	assert.NoError(t, conn.Select(ctx, 1).Err())
+	val, err := conn.Get(ctx, "key").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, "v", val)
 }
 
 // TestWait 测试 WAIT 命令
@@ -1784,11 +1970,19 @@ func TestLatency(t *testing.T) {
 	assert.True(t, ok)
 	assert.True(t, len(arr) > 0)
 
-	// LATENCY DOCTOR should return info
+	// LATENCY DOCTOR should return a human readable report
 	result, err = testClient.Do(ctx, "LATENCY", "DOCTOR").Result()
 	assert.NoError(t, err)
-	_, ok = result.([]interface{})
+	report, ok := result.(string)
+	assert.True(t, ok)
+	assert.True(t, strings.Contains(report, "latency-monitor-threshold"))
+
+	// LATENCY HISTORY of an event without spikes should return empty array
+	result, err = testClient.Do(ctx, "LATENCY", "HISTORY", "command").Result()
+	assert.NoError(t, err)
+	arr, ok = result.([]interface{})
 	assert.True(t, ok)
+	assert.Equal(t, 0, len(arr))
 }
 
 // TestReadOnlyReadWrite 测试 READONLY 和 READWRITE 命令
@@ -2,6 +2,7 @@ package integration
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/redis/go-redis/v9"
@@ -124,6 +125,48 @@ func TestScanType(t *testing.T) {
 	assert.True(t, found)
 }
 
+// TestScanIterator 测试 go-redis 的 Scan 迭代器在并发写入时完整遍历键空间和集合
+func TestScanIterator(t *testing.T) {
+	setupTestServer(t)
+	defer teardownTestServer(t)
+
+	ctx := context.Background()
+
+	for i := 0; i < 300; i++ {
+		assert.NoError(t, testClient.Set(ctx, fmt.Sprintf("iter:%03d", i), "v", 0).Err())
+		assert.NoError(t, testClient.HSet(ctx, "iterhash", fmt.Sprintf("f%03d", i), i).Err())
+		assert.NoError(t, testClient.SAdd(ctx, "iterset", i).Err())
+		assert.NoError(t, testClient.ZAdd(ctx, "iterzset", redis.Z{Score: float64(i), Member: i}).Err())
+	}
+
+	// 遍历期间写入其他键
+	seen := make(map[string]bool)
+	iter := testClient.Scan(ctx, 0, "iter:*", 25).Iterator()
+	n := 0
+	for iter.Next(ctx) {
+		assert.False(t, seen[iter.Val()])
+		seen[iter.Val()] = true
+		if n++; n%10 == 0 {
+			assert.NoError(t, testClient.Set(ctx, fmt.Sprintf("during:%d", n), "v", 0).Err())
+		}
+	}
+	assert.NoError(t, iter.Err())
+	assert.Equal(t, 300, len(seen))
+
+	count := func(iter *redis.ScanIterator) int {
+		n := 0
+		for iter.Next(ctx) {
+			n++
+		}
+		assert.NoError(t, iter.Err())
+		return n
+	}
+	assert.Equal(t, 600, count(testClient.HScan(ctx, "iterhash", 0, "", 40).Iterator()))
+	assert.Equal(t, 300, count(testClient.SScan(ctx, "iterset", 0, "", 40).Iterator()))
+	assert.Equal(t, 600, count(testClient.ZScan(ctx, "iterzset", 0, "", 40).Iterator()))
+	assert.Equal(t, 1, count(testClient.ScanType(ctx, 0, "iter*", 100, "set").Iterator()))
+}
+
 // TestDump 测试 DUMP 命令
 func TestDump(t *testing.T) {
 	setupTestServer(t)
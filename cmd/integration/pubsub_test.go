@@ -3,6 +3,7 @@ package integration
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/zeebo/assert"
@@ -250,3 +251,114 @@ func TestTimeoutUnsubscribe(t *testing.T) {
 	_, err := pubClient.Publish(ctx, "timeout_test", "message").Result()
 	assert.NoError(t, err)
 }
+
+// TestPubSubShardAndStats 测试 PUBSUB SHARDCHANNELS / SHARDNUMSUB / STATS
+func TestPubSubShardAndStats(t *testing.T) {
+	setupTestServer(t)
+	defer teardownTestServer(t)
+
+	ctx := context.Background()
+
+	channels, err := testClient.PubSubShardChannels(ctx, "*").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(channels))
+
+	numSub, err := testClient.PubSubShardNumSub(ctx, "orders").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), numSub["orders"])
+
+	// 发布后可以查询频道投递统计
+	_, err = testClient.Publish(ctx, "stats_channel", "m1").Result()
+	assert.NoError(t, err)
+	_, err = testClient.Publish(ctx, "stats_channel", "m2").Result()
+	assert.NoError(t, err)
+
+	result, err := testClient.Do(ctx, "PUBSUB", "STATS", "stats_*").Result()
+	assert.NoError(t, err)
+	arr, ok := result.([]interface{})
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(arr))
+	entry := arr[0].([]interface{})
+	assert.Equal(t, "stats_channel", entry[0])
+	assert.Equal(t, "published", entry[1])
+	assert.Equal(t, int64(2), entry[2])
+
+	_, err = testClient.Do(ctx, "PUBSUB", "STATS", "RESET").Result()
+	assert.NoError(t, err)
+	result, err = testClient.Do(ctx, "PUBSUB", "STATS").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(result.([]interface{})))
+}
+
+// TestPubSubMessageDelivery 测试 SUBSCRIBE/PSUBSCRIBE/SSUBSCRIBE 收到发布的消息以及 PUBSUB 查询
+func TestPubSubMessageDelivery(t *testing.T) {
+	setupTestServer(t)
+	defer teardownTestServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	subClient := redis.NewClient(&redis.Options{Addr: listener.Addr().String(), Protocol: 2})
+	defer subClient.Close()
+
+	pubsub := subClient.Subscribe(ctx, "news")
+	defer pubsub.Close()
+	assert.NoError(t, pubsub.PSubscribe(ctx, "events.*", "user:[0-9]*"))
+	_, err := pubsub.Receive(ctx)
+	assert.NoError(t, err)
+
+	// 等待三个订阅确认都已处理
+	for {
+		numPat, err := testClient.PubSubNumPat(ctx).Result()
+		assert.NoError(t, err)
+		if numPat == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	numSub, err := testClient.PubSubNumSub(ctx, "news", "other").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), numSub["news"])
+	assert.Equal(t, int64(0), numSub["other"])
+	channels, err := testClient.PubSubChannels(ctx, "n*").Result()
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"news"}, channels)
+
+	count, err := testClient.Publish(ctx, "news", "hello").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	msg, err := pubsub.ReceiveMessage(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "news", msg.Channel)
+	assert.Equal(t, "hello", msg.Payload)
+
+	count, err = testClient.Publish(ctx, "events.login", "alice").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	msg, err = pubsub.ReceiveMessage(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "events.*", msg.Pattern)
+	assert.Equal(t, "events.login", msg.Channel)
+	assert.Equal(t, "alice", msg.Payload)
+
+	count, err = testClient.Publish(ctx, "user:bob", "ignored").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	// 分片频道
+	shard := subClient.SSubscribe(ctx, "orders")
+	defer shard.Close()
+	_, err = shard.Receive(ctx)
+	assert.NoError(t, err)
+	count, err = testClient.SPublish(ctx, "orders", "o1").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	msg, err = shard.ReceiveMessage(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "orders", msg.Channel)
+	assert.Equal(t, "o1", msg.Payload)
+	shardSub, err := testClient.PubSubShardNumSub(ctx, "orders").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), shardSub["orders"])
+}
@@ -3,6 +3,7 @@ package integration
 import (
 	"context"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
@@ -412,3 +413,73 @@ func TestReplicationMasterSlaveRole(t *testing.T) {
 	assert.True(t, ok)
 	assert.Equal(t, "slave", arr[0])
 }
+
+// TestReplicationMasterSlaveStream 测试流与消费者组的复制：从节点的条目 ID 与待确认列表与主节点相同
+func TestReplicationMasterSlaveStream(t *testing.T) {
+	masterClient, slaveClient, cleanup := setupMasterSlaveServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	// 自动 ID 在从节点上必须与主节点生成的相同
+	for _, v := range []string{"a", "b", "c"} {
+		err := masterClient.XAdd(ctx, &redis.XAddArgs{Stream: "test_stream", Values: []string{"v", v}}).Err()
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, masterClient.XGroupCreate(ctx, "test_stream", "g", "0").Err())
+	streams, err := masterClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: "g", Consumer: "c1", Streams: []string{"test_stream", ">"}, Count: 2,
+	}).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(streams[0].Messages))
+	assert.NoError(t, masterClient.XAck(ctx, "test_stream", "g", streams[0].Messages[0].ID).Err())
+	claimed, err := masterClient.Do(ctx, "XAUTOCLAIM", "test_stream", "g", "c2", "0", "0", "JUSTID").Slice()
+	assert.NoError(t, err)
+	// 回复为 [下次扫描的起点, 认领的 ID, 已删除的 ID]
+	assert.DeepEqual(t, []interface{}{"0-0", []interface{}{streams[0].Messages[1].ID}, []interface{}{}}, claimed)
+
+	// 等待复制
+	time.Sleep(200 * time.Millisecond)
+
+	want, err := masterClient.XRange(ctx, "test_stream", "-", "+").Result()
+	assert.NoError(t, err)
+	got, err := slaveClient.XRange(ctx, "test_stream", "-", "+").Result()
+	assert.NoError(t, err)
+	assert.DeepEqual(t, want, got)
+
+	// XPENDING 回复 [数量, 最小 ID, 最大 ID, [[消费者, 条目数], ...]]
+	pending, err := slaveClient.Do(ctx, "XPENDING", "test_stream", "g").Slice()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), pending[0])
+	assert.Equal(t, streams[0].Messages[1].ID, pending[1])
+	assert.DeepEqual(t, []interface{}{[]interface{}{"c2", "1"}}, pending[3])
+
+	// XREADGROUP 修改待确认列表，只读从节点拒绝
+	err = slaveClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: "g", Consumer: "c3", Streams: []string{"test_stream", ">"}, Block: -1,
+	}).Err()
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "READONLY"))
+}
+
+// TestReplicationMasterSlavePublish 测试 PUBLISH 的复制：订阅从节点的客户端收到主节点发布的消息
+func TestReplicationMasterSlavePublish(t *testing.T) {
+	masterClient, slaveClient, cleanup := setupMasterSlaveServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	sub := slaveClient.Subscribe(ctx, "test_channel")
+	defer sub.Close()
+	_, err := sub.Receive(ctx)
+	assert.NoError(t, err)
+
+	assert.NoError(t, masterClient.Publish(ctx, "test_channel", "hello").Err())
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	msg, err := sub.ReceiveMessage(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "test_channel", msg.Channel)
+	assert.Equal(t, "hello", msg.Payload)
+}
@@ -0,0 +1,209 @@
+package integration
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/backup"
+	"github.com/lbp0200/BoltDB/internal/replication"
+	"github.com/lbp0200/BoltDB/internal/sentinel"
+	"github.com/lbp0200/BoltDB/internal/server"
+	"github.com/lbp0200/BoltDB/internal/store"
+	"github.com/redis/go-redis/v9"
+	"github.com/zeebo/assert"
+)
+
+// tcpProxy 把连接转发到 target，Close 时断开所有连接，用来模拟节点宕机
+type tcpProxy struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func startTCPProxy(t *testing.T, target string) *tcpProxy {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	p := &tcpProxy{ln: ln}
+	go func() {
+		for {
+			client, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			backend, err := net.Dial("tcp", target)
+			if err != nil {
+				_ = client.Close()
+				continue
+			}
+			p.mu.Lock()
+			p.conns = append(p.conns, client, backend)
+			p.mu.Unlock()
+			go func() {
+				_, _ = io.Copy(backend, client)
+				_ = backend.Close()
+			}()
+			go func() {
+				_, _ = io.Copy(client, backend)
+				_ = client.Close()
+			}()
+		}
+	}()
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *tcpProxy) Addr() string {
+	return p.ln.Addr().String()
+}
+
+func (p *tcpProxy) Close() {
+	_ = p.ln.Close()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, conn := range p.conns {
+		_ = conn.Close()
+	}
+	p.conns = nil
+}
+
+// startSentinelNode 启动一个进程内的数据节点，返回其地址与复制管理器
+func startSentinelNode(t *testing.T) (string, *replication.ReplicationManager) {
+	dbPath := t.TempDir()
+	db, err := store.NewBotreonStore(dbPath)
+	assert.NoError(t, err)
+	replMgr := replication.NewReplicationManager(db)
+	handler := &server.Handler{
+		Db:          db,
+		Replication: replMgr,
+		Backup:      backup.NewBackupManager(db, dbPath+"/backup"),
+		PubSub:      store.NewPubSubManager(),
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() {
+		_ = handler.ServeTCP(ln)
+	}()
+	t.Cleanup(func() {
+		_ = ln.Close()
+		_ = db.Close()
+	})
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	replMgr.SetListeningPort(portNum)
+	return ln.Addr().String(), replMgr
+}
+
+// startSentinel 启动一个进程内的哨兵，返回连接它的客户端
+func startSentinel(t *testing.T, downAfter time.Duration) (*sentinel.Sentinel, *redis.Client) {
+	s := sentinel.NewSentinel(downAfter)
+	handler := sentinel.NewSentinelHandler(s)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	announcePort, _ := strconv.Atoi(port)
+	s.SetAnnounceAddr("127.0.0.1", announcePort)
+	s.Start()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go handler.HandleConnection(conn)
+		}
+	}()
+	client := redis.NewClient(&redis.Options{Addr: ln.Addr().String(), Protocol: 2, DisableIndentity: true})
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = ln.Close()
+		s.Stop()
+	})
+	return s, client
+}
+
+// TestSentinelHelloDiscovery 监控同一主节点的哨兵通过主节点上的 __sentinel__:hello 频道互相发现
+func TestSentinelHelloDiscovery(t *testing.T) {
+	ctx := context.Background()
+	masterAddr, _ := startSentinelNode(t)
+	host, port, _ := net.SplitHostPort(masterAddr)
+
+	s1, client1 := startSentinel(t, time.Second)
+	s2, client2 := startSentinel(t, time.Second)
+	assert.NoError(t, client1.Do(ctx, "SENTINEL", "MONITOR", "mymaster", host, port, "2").Err())
+	assert.NoError(t, client2.Do(ctx, "SENTINEL", "MONITOR", "mymaster", host, port, "2").Err())
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		m1 := s1.GetMaster("mymaster").GetSentinels()
+		m2 := s2.GetMaster("mymaster").GetSentinels()
+		if len(m1) == 1 && len(m2) == 1 {
+			assert.Equal(t, s2.GetRunID(), m1[0].RunID)
+			assert.Equal(t, s1.GetRunID(), m2[0].RunID)
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("sentinels did not discover each other")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// TestSentinelAutomaticFailover 主节点宕机后哨兵提升从节点并发布 +switch-master
+func TestSentinelAutomaticFailover(t *testing.T) {
+	ctx := context.Background()
+
+	masterAddr, _ := startSentinelNode(t)
+	proxy := startTCPProxy(t, masterAddr)
+	slaveAddr, _ := startSentinelNode(t)
+	_, proxyPort, _ := net.SplitHostPort(proxy.Addr())
+	_, slavePort, _ := net.SplitHostPort(slaveAddr)
+
+	slaveClient := redis.NewClient(&redis.Options{Addr: slaveAddr, Protocol: 2, DisableIndentity: true})
+	defer slaveClient.Close()
+	assert.NoError(t, slaveClient.Do(ctx, "REPLICAOF", "127.0.0.1", proxyPort).Err())
+
+	_, client := startSentinel(t, 500*time.Millisecond)
+	assert.NoError(t, client.Do(ctx, "SENTINEL", "MONITOR", "mymaster", "127.0.0.1", proxyPort, "1").Err())
+	assert.NoError(t, client.Do(ctx, "SENTINEL", "SET", "mymaster", "failover-timeout", "10000").Err())
+
+	pubsub := client.Subscribe(ctx, "+switch-master")
+	defer pubsub.Close()
+	_, err := pubsub.Receive(ctx)
+	assert.NoError(t, err)
+
+	// 等待哨兵通过主节点的 INFO 发现从节点
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		replicas, err := client.Do(ctx, "SENTINEL", "REPLICAS", "mymaster").Slice()
+		assert.NoError(t, err)
+		if len(replicas) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("sentinel did not discover the replica")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	proxy.Close()
+
+	waitCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	msg, err := pubsub.ReceiveMessage(waitCtx)
+	assert.NoError(t, err)
+	assert.Equal(t, "mymaster 127.0.0.1 "+proxyPort+" 127.0.0.1 "+slavePort, msg.Payload)
+
+	info, err := slaveClient.Info(ctx, "replication").Result()
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(info, "role:master"))
+
+	addr, err := client.Do(ctx, "SENTINEL", "GET-MASTER-ADDR-BY-NAME", "mymaster").StringSlice()
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"127.0.0.1", slavePort}, addr)
+}
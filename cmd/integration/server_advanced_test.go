@@ -2,9 +2,14 @@ package integration
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/lbp0200/BoltDB/internal/config"
+	"github.com/redis/go-redis/v9"
 	"github.com/zeebo/assert"
 )
 
@@ -62,10 +67,22 @@ func TestConfigSet(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "OK", result)
 
-	// CONFIG SET - 持久化配置
+	// CONFIG REWRITE - 没有配置文件时返回错误
+	_, err = testClient.Do(ctx, "CONFIG", "REWRITE").Result()
+	assert.Error(t, err)
+
+	// CONFIG REWRITE - 持久化配置到启动时加载的配置文件
+	path := filepath.Join(t.TempDir(), "boltreon.conf")
+	assert.NoError(t, os.WriteFile(path, []byte("# boltreon\n"), 0o600))
+	f, err := config.Load(path)
+	assert.NoError(t, err)
+	assert.NoError(t, testServer.LoadConfigFile(f, nil))
 	result, err = testClient.Do(ctx, "CONFIG", "REWRITE").Result()
 	assert.NoError(t, err)
 	assert.Equal(t, "OK", result)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(data), "maxclients 1000\n"))
 }
 
 // TestClientList 测试 CLIENT LIST 命令
@@ -201,10 +218,14 @@ func TestClientKill(t *testing.T) {
 
 	ctx := context.Background()
 
-	// CLIENT KILL - 杀死客户端连接（格式: ip:port）
-	result, err := testClient.Do(ctx, "CLIENT", "KILL", "127.0.0.1:12345").Result()
+	// CLIENT KILL - 杀死客户端连接（格式: ip:port），连接不存在时返回错误
+	_, err := testClient.Do(ctx, "CLIENT", "KILL", "127.0.0.1:12345").Result()
+	assert.Error(t, err)
+	assert.Equal(t, "ERR No such client", err.Error())
+
+	// 过滤格式返回关闭的连接数
+	result, err := testClient.Do(ctx, "CLIENT", "KILL", "ADDR", "127.0.0.1:12345").Result()
 	assert.NoError(t, err)
-	// 如果连接不存在，返回0
 	assert.Equal(t, int64(0), result)
 }
 
@@ -291,3 +312,47 @@ func TestModuleList(t *testing.T) {
 	// 没有加载模块时返回空数组
 	assert.Equal(t, 0, len(arr))
 }
+
+// TestRESP3Client 测试以 RESP3 连接的客户端收到带类型的回复
+func TestRESP3Client(t *testing.T) {
+	setupTestServer(t)
+	defer teardownTestServer(t)
+
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: listener.Addr().String(), Protocol: 3})
+	defer client.Close()
+
+	assert.NoError(t, client.HSet(ctx, "resp3:h", "f1", "v1", "f2", "v2").Err())
+	assert.NoError(t, client.SAdd(ctx, "resp3:s", "a", "b").Err())
+	assert.NoError(t, client.ZAdd(ctx, "resp3zset", redis.Z{Score: 1.5, Member: "m"}, redis.Z{Score: 2, Member: "n"}).Err())
+
+	// 原始回复的类型
+	raw, err := client.Do(ctx, "HGETALL", "resp3:h").Result()
+	assert.NoError(t, err)
+	m, ok := raw.(map[interface{}]interface{})
+	assert.True(t, ok)
+	assert.Equal(t, "v1", m["f1"])
+	raw, err = client.Do(ctx, "SMEMBERS", "resp3:s").Result()
+	assert.NoError(t, err)
+	_, ok = raw.([]interface{})
+	assert.True(t, ok)
+	raw, err = client.Do(ctx, "ZSCORE", "resp3zset", "m").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, raw)
+	_, err = client.Do(ctx, "GET", "resp3:missing").Result()
+	assert.Equal(t, redis.Nil, err)
+
+	// 类型化的客户端方法
+	fields, err := client.HGetAll(ctx, "resp3:h").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"f1": "v1", "f2": "v2"}, fields)
+	members, err := client.ZRangeWithScores(ctx, "resp3zset", 0, -1).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, []redis.Z{{Score: 1.5, Member: "m"}, {Score: 2, Member: "n"}}, members)
+	popped, err := client.ZPopMin(ctx, "resp3zset").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, []redis.Z{{Score: 1.5, Member: "m"}}, popped)
+	score, err := client.ZScore(ctx, "resp3zset", "n").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, score)
+}
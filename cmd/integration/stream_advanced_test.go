@@ -96,7 +96,7 @@ func TestXAck(t *testing.T) {
 	assert.NoError(t, err)
 
 	// 读取消息
-	_, err = testClient.Do(ctx, "XREADGROUP", "GROUP", "mygroup", "consumer1", "COUNT", "1", "STREAMS", "ackstream", "0").Result()
+	_, err = testClient.Do(ctx, "XREADGROUP", "GROUP", "mygroup", "consumer1", "COUNT", "1", "STREAMS", "ackstream", ">").Result()
 	assert.NoError(t, err)
 
 	// XACK - 确认消息
@@ -309,7 +309,7 @@ func TestXPending(t *testing.T) {
 
 	arr, ok := result.([]interface{})
 	assert.True(t, ok)
-	// [pending_count, min_id, consumer_count, entries_list]
+	// [pending_count, min_id, max_id, [[consumer, count], ...]]
 	assert.Equal(t, 4, len(arr))
 }
 
@@ -391,10 +391,15 @@ func TestXTrim(t *testing.T) {
 	}
 
 	// XTRIM - 修剪流，只保留最后5条
-	result, err := testClient.Do(ctx, "XTRIM", "trimstream", "~", "5").Result()
+	result, err := testClient.Do(ctx, "XTRIM", "trimstream", "MAXLEN", "5").Result()
 	assert.NoError(t, err)
 	assert.Equal(t, int64(5), result) // 删除了5条
 
+	// 近似裁剪只删除整批记录，超出不足一批时不删除
+	result, err = testClient.Do(ctx, "XTRIM", "trimstream", "MAXLEN", "~", "1").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), result)
+
 	// 验证长度
 	length, _ := testClient.XLen(ctx, "trimstream").Result()
 	assert.Equal(t, int64(5), length)
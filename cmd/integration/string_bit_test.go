@@ -2,8 +2,10 @@ package integration
 
 import (
 	"context"
+	"strings"
 	"testing"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/zeebo/assert"
 )
 
@@ -56,6 +58,20 @@ func TestBitCount(t *testing.T) {
 	result, err := testClient.Do(ctx, "BITCOUNT", "bitcountkey", "0", "0").Result()
 	assert.NoError(t, err)
 	assert.Equal(t, int64(4), result)
+
+	// 按位指定范围：第 1、2 位都是 1
+	count, err = testClient.BitCount(ctx, "bitcountkey", &redis.BitCount{Start: 1, End: 2, Unit: redis.BitCountIndexBit}).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	err = testClient.Do(ctx, "BITCOUNT", "bitcountkey", "0").Err()
+	assert.Error(t, err)
+	assert.Equal(t, "ERR syntax error", err.Error())
+
+	_ = testClient.LPush(ctx, "bitcountlist", "a").Err()
+	err = testClient.BitCount(ctx, "bitcountlist", nil).Err()
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "WRONGTYPE"))
 }
 
 // TestBitOp 测试 BITOP 命令
@@ -116,28 +132,39 @@ func TestBitField(t *testing.T) {
 
 	ctx := context.Background()
 
-	// BITFIELD mykey SET u8 0 255
-	result, err := testClient.Do(ctx, "BITFIELD", "bitfieldkey", "SET", "u8", "0", "255").Result()
+	// BITFIELD mykey SET u8 0 255，与 Redis 相同总是返回数组
+	result, err := testClient.BitField(ctx, "bitfieldkey", "SET", "u8", "0", "255").Result()
 	assert.NoError(t, err)
-	assert.Equal(t, int64(0), result)
+	assert.DeepEqual(t, []int64{0}, result)
 
 	// GET u8 0
-	result, err = testClient.Do(ctx, "BITFIELD", "bitfieldkey", "GET", "u8", "0").Result()
+	result, err = testClient.BitField(ctx, "bitfieldkey", "GET", "u8", "0").Result()
 	assert.NoError(t, err)
-	assert.Equal(t, int64(255), result)
+	assert.DeepEqual(t, []int64{255}, result)
 
 	// BITFIELD mykey INCRBY u8 0 1
-	result, err = testClient.Do(ctx, "BITFIELD", "bitfieldkey", "INCRBY", "u8", "0", "1").Result()
+	result, err = testClient.BitField(ctx, "bitfieldkey", "INCRBY", "u8", "0", "1").Result()
 	assert.NoError(t, err)
-	assert.Equal(t, int64(0), result) // 溢出回绕
+	assert.DeepEqual(t, []int64{0}, result) // 溢出回绕
 
 	// 多操作
-	result, err = testClient.Do(ctx, "BITFIELD", "bitfieldkey2", "SET", "i8", "8", "100", "GET", "u8", "8").Result()
+	result, err = testClient.BitField(ctx, "bitfieldkey2", "SET", "i8", "8", "100", "GET", "u8", "8").Result()
 	assert.NoError(t, err)
 	// 返回数组 [old_value, new_value]
-	arr, ok := result.([]interface{})
-	assert.True(t, ok)
-	assert.Equal(t, 2, len(arr))
+	assert.DeepEqual(t, []int64{0, 100}, result)
+
+	// OVERFLOW FAIL 时溢出的操作返回 nil 且不写入
+	raw, err := testClient.Do(ctx, "BITFIELD", "bitfieldkey", "OVERFLOW", "FAIL", "INCRBY", "u2", "#0", "5", "GET", "u2", "0").Slice()
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{nil, int64(0)}, raw)
+
+	// BITFIELD_RO 只接受 GET
+	ro, err := testClient.BitFieldRO(ctx, "bitfieldkey2", "u8", "8").Result()
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []int64{100}, ro)
+	err = testClient.Do(ctx, "BITFIELD_RO", "bitfieldkey2", "SET", "u8", "8", "1").Err()
+	assert.Error(t, err)
+	assert.Equal(t, "ERR BITFIELD_RO only supports the GET subcommand", err.Error())
 }
 
 // TestBitFieldIncr 测试 BITFIELD INCR 命令
@@ -148,24 +175,24 @@ func TestBitFieldIncr(t *testing.T) {
 	ctx := context.Background()
 
 	// 设置初始值
-	_, err := testClient.Do(ctx, "BITFIELD", "incrkey", "SET", "i8", "0", "10").Result()
+	_, err := testClient.BitField(ctx, "incrkey", "SET", "i8", "0", "10").Result()
 	assert.NoError(t, err)
 
 	// INCRBY i8 0 5 - 增加5
-	result, err := testClient.Do(ctx, "BITFIELD", "incrkey", "INCRBY", "i8", "0", "5").Result()
+	result, err := testClient.BitField(ctx, "incrkey", "INCRBY", "i8", "0", "5").Result()
 	assert.NoError(t, err)
-	assert.Equal(t, int64(15), result)
+	assert.DeepEqual(t, []int64{15}, result)
 
 	// INCRBY i8 0 -10 - 减少10
-	result, err = testClient.Do(ctx, "BITFIELD", "incrkey", "INCRBY", "i8", "0", "-10").Result()
+	result, err = testClient.BitField(ctx, "incrkey", "INCRBY", "i8", "0", "-10").Result()
 	assert.NoError(t, err)
-	assert.Equal(t, int64(5), result)
+	assert.DeepEqual(t, []int64{5}, result)
 
 	// INCRBY i8 0 200 - 溢出测试 (5 + 200 = 205, i8范围-128到127，溢出)
-	result, err = testClient.Do(ctx, "BITFIELD", "incrkey", "INCRBY", "i8", "0", "200").Result()
+	result, err = testClient.BitField(ctx, "incrkey", "INCRBY", "i8", "0", "200").Result()
 	assert.NoError(t, err)
 	// 205 - 256 = -51
-	assert.Equal(t, int64(-51), result)
+	assert.DeepEqual(t, []int64{-51}, result)
 }
 
 // TestBitPos 测试 BITPOS 命令
@@ -198,6 +225,20 @@ func TestBitPos(t *testing.T) {
 	result, err = testClient.Do(ctx, "BITPOS", "bitposkey3", "1").Result()
 	assert.NoError(t, err)
 	assert.Equal(t, int64(0), result)
+
+	// 按位指定区间
+	result, err = testClient.Do(ctx, "BITPOS", "bitposkey2", "0", "3", "10", "BIT").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), result)
+
+	// 全为 1 时查找 0：没有指定终点返回末尾之后的第一位，指定终点返回 -1
+	_ = testClient.Set(ctx, "bitposones", "\xff", 0).Err()
+	result, err = testClient.Do(ctx, "BITPOS", "bitposones", "0").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), result)
+	result, err = testClient.Do(ctx, "BITPOS", "bitposones", "0", "0", "-1").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), result)
 }
 
 // TestBitLen 测试 BITLEN 命令
@@ -0,0 +1,128 @@
+// restore 把 BOLTREON.BACKUP 与 backup-schedule 生成的 Badger 备份恢复到新的数据目录，
+// 可以指定时间点：使用该时间（含）之前最近的全量备份及其后的增量备份。
+//
+//	go run ./cmd/restore -backup-dir /data/old/backup -dir /data/new -time 2026-03-04T10:00:00Z
+//	go run ./cmd/restore -backup-dir /data/old/backup -list
+//
+// 备份上传到 S3 时（boltDB --backup-s3-bucket），可以直接从 S3 恢复，凭据从 AWS_ACCESS_KEY_ID 等环境变量读取：
+//
+//	go run ./cmd/restore -s3-bucket backups -s3-prefix boltdb/prod -dir /data/new -time 2026-03-04T10:00:00Z
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/backup"
+	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+func main() {
+	backupDir := flag.String("backup-dir", "", "backup directory of the source server (<dir>/backup by default), or a copy of it")
+	dbPath := flag.String("dir", "", "new, empty badger dir to restore into; start boltDB with --dir pointing at it afterwards")
+	at := flag.String("time", "", "restore the data as of this time: RFC 3339 (2026-03-04T10:00:00Z) or unix seconds (default: latest backup)")
+	list := flag.Bool("list", false, "list the backups in --backup-dir and exit")
+	encryptionKey := flag.String("encryption-key", "", "encryption key source the server uses (file:, env:, cmd:); empty for unencrypted data")
+	s3Bucket := flag.String("s3-bucket", "", "read the backups from this S3 bucket instead of --backup-dir")
+	s3Endpoint := flag.String("s3-endpoint", "", "S3-compatible endpoint (default: AWS S3 in --s3-region)")
+	s3Region := flag.String("s3-region", "", "S3 region (default: AWS_REGION, or us-east-1)")
+	s3Prefix := flag.String("s3-prefix", "", "object name prefix the server uploaded the backups under (--backup-s3-prefix)")
+	s3PathStyle := flag.Bool("s3-path-style", false, "address objects as <endpoint>/<bucket>/<key>")
+	logLevel := flag.String("log-level", "", "log level: DEBUG, INFO, WARNING, ERROR (default: WARNING, or from BOLTDB_LOG_LEVEL env)")
+	flag.Parse()
+
+	if *logLevel != "" {
+		logger.SetLevelFromString(*logLevel)
+	}
+	var source backup.Target
+	switch {
+	case *s3Bucket != "":
+		t, err := backup.NewS3Target(backup.S3Config{
+			Endpoint:  *s3Endpoint,
+			Region:    *s3Region,
+			Bucket:    *s3Bucket,
+			Prefix:    *s3Prefix,
+			PathStyle: *s3PathStyle,
+		})
+		if err != nil {
+			fail(err.Error())
+		}
+		source = t
+	case *backupDir != "":
+		source = backup.NewDirTarget(*backupDir)
+	default:
+		fail("--backup-dir or --s3-bucket is required")
+	}
+	ctx := context.Background()
+
+	if *list {
+		entries, err := backup.ReadCatalog(ctx, source)
+		if err != nil {
+			fail(err.Error())
+		}
+		for _, e := range entries {
+			kind := "incremental"
+			if e.Full {
+				kind = "full"
+			}
+			fmt.Printf("%s\t%-11s\t%s\n", e.Time.Format(time.RFC3339), kind, e.File)
+		}
+		return
+	}
+
+	if *dbPath == "" {
+		fail("--dir is required")
+	}
+	target := time.Now()
+	if *at != "" {
+		t, err := parseTime(*at)
+		if err != nil {
+			fail(err.Error())
+		}
+		target = t
+	}
+	// 只恢复到新的目录，Badger 的 Load 不能与已有数据合并
+	if entries, err := os.ReadDir(*dbPath); err == nil && len(entries) > 0 {
+		fail(fmt.Sprintf("--dir %s is not empty", *dbPath))
+	}
+
+	db, err := store.NewBotreonStoreWithOptions(*dbPath, store.StoreOptions{
+		EncryptionKeySource: *encryptionKey,
+	})
+	if err != nil {
+		fail(err.Error())
+	}
+	chain, err := backup.RestoreFromTarget(ctx, db.GetDB(), source, target)
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fail(err.Error())
+	}
+	for _, e := range chain {
+		fmt.Printf("loaded %s (%s)\n", e.File, e.Time.Format(time.RFC3339))
+	}
+	fmt.Printf("restored %s as of %s\n", *dbPath, chain[len(chain)-1].Time.Format(time.RFC3339))
+}
+
+// parseTime 解析 RFC 3339 时间或 Unix 秒数
+func parseTime(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --time %q: use RFC 3339 or unix seconds", s)
+	}
+	return t, nil
+}
+
+func fail(msg string) {
+	fmt.Fprintln(os.Stderr, "restore:", msg)
+	os.Exit(1)
+}
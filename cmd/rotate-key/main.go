@@ -0,0 +1,54 @@
+// rotate-key 离线更换静态加密的主密钥：用旧密钥读出 KEYREGISTRY 中的数据密钥，再用新密钥加密写回，
+// 数据文件不需要重写。先停止 boltDB，轮换后用新的 --encryption-key 启动。
+// 使用了 --tier-dir 时冷层使用同一个主密钥，需要一起轮换。
+//
+//	go run ./cmd/rotate-key -dir /data/boltdb -old-key file:/etc/boltdb/old.key -new-key file:/etc/boltdb/new.key
+//	go run ./cmd/rotate-key -dir /data/boltdb -tier-dir /cold/boltdb -old-key env:OLD_KEY -new-key cmd:"kms-get boltdb"
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+func main() {
+	dbPath := flag.String("dir", "", "badger dir of the stopped server (its --dir)")
+	tierDir := flag.String("tier-dir", "", "cold tier dir of the server (its --tier-dir), rotated with the same keys")
+	oldKey := flag.String("old-key", "", "current master key source: file:<path>, env:<NAME> or cmd:<command>")
+	newKey := flag.String("new-key", "", "new master key source, in the same form; pass it as --encryption-key when starting boltDB")
+	flag.Parse()
+
+	if *dbPath == "" {
+		fail("--dir is required")
+	}
+	if *oldKey == "" || *newKey == "" {
+		fail("--old-key and --new-key are required")
+	}
+	oldMaster, err := store.LoadEncryptionKey(*oldKey)
+	if err != nil {
+		fail(fmt.Sprintf("--old-key: %v", err))
+	}
+	newMaster, err := store.LoadEncryptionKey(*newKey)
+	if err != nil {
+		fail(fmt.Sprintf("--new-key: %v", err))
+	}
+
+	dirs := []string{*dbPath}
+	if *tierDir != "" {
+		dirs = append(dirs, *tierDir)
+	}
+	for _, dir := range dirs {
+		if err := store.RotateMasterKey(dir, oldMaster, newMaster); err != nil {
+			fail(err.Error())
+		}
+		fmt.Printf("rotated master key of %s\n", dir)
+	}
+}
+
+func fail(msg string) {
+	fmt.Fprintln(os.Stderr, "rotate-key:", msg)
+	os.Exit(1)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/sentinel"
+)
+
+// monitorFlag --monitor <name>,<host:port>,<quorum>，可以重复指定
+type monitorFlag []string
+
+func (m *monitorFlag) String() string     { return strings.Join(*m, " ") }
+func (m *monitorFlag) Set(v string) error { *m = append(*m, v); return nil }
+
+func main() {
+	addr := flag.String("addr", ":26379", "listen addr")
+	announceIP := flag.String("announce-ip", "", "IP advertised to other sentinels in hello messages (default: the local address of the connection to each monitored instance)")
+	announcePort := flag.Int("announce-port", 0, "port advertised to other sentinels (default: the --addr port)")
+	downAfter := flag.Duration("down-after", sentinel.DefaultDownAfter, "default down-after-milliseconds for masters added with SENTINEL MONITOR")
+	logLevel := flag.String("log-level", "", "log level: DEBUG, INFO, WARNING, ERROR (default: WARNING, or from BOLTDB_LOG_LEVEL env)")
+	var monitors monitorFlag
+	flag.Var(&monitors, "monitor", "monitor a master on startup: <name>,<host:port>,<quorum> (repeatable; SENTINEL MONITOR adds more at runtime)")
+	flag.Parse()
+
+	if *logLevel != "" {
+		logger.SetLevelFromString(*logLevel)
+	}
+
+	s := sentinel.NewSentinel(*downAfter)
+	port := *announcePort
+	if port == 0 {
+		_, p, err := net.SplitHostPort(*addr)
+		if err == nil {
+			port, _ = strconv.Atoi(p)
+		}
+	}
+	s.SetAnnounceAddr(*announceIP, port)
+	for _, m := range monitors {
+		name, masterAddr, quorum, err := parseMonitor(m)
+		if err == nil {
+			err = s.AddMaster(name, masterAddr, quorum)
+		}
+		if err != nil {
+			logger.Logger.Fatal().Err(err).Str("monitor", m).Msg("Invalid --monitor")
+		}
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		logger.Logger.Fatal().Err(err).Str("addr", *addr).Msg("Failed to listen")
+	}
+	s.Start()
+	handler := sentinel.NewSentinelHandler(s)
+	// 启动信息使用 WARN 级别，确保默认配置下也能显示
+	logger.Warning("BoltDB 哨兵启动，监听地址: %s，运行ID: %s", *addr, s.GetRunID())
+
+	go func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		<-sigCh
+		s.Stop()
+		_ = ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			logger.Logger.Info().Err(err).Msg("哨兵停止监听")
+			return
+		}
+		go handler.HandleConnection(conn)
+	}
+}
+
+// parseMonitor 解析 <name>,<host:port>,<quorum>
+func parseMonitor(v string) (string, string, int, error) {
+	parts := strings.Split(v, ",")
+	if len(parts) != 3 {
+		return "", "", 0, fmt.Errorf("expected <name>,<host:port>,<quorum>")
+	}
+	if _, _, err := net.SplitHostPort(parts[1]); err != nil {
+		return "", "", 0, err
+	}
+	quorum, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return "", "", 0, fmt.Errorf("invalid quorum %q", parts[2])
+	}
+	return parts[0], parts[1], quorum, nil
+}
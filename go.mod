@@ -4,12 +4,13 @@ go 1.25
 
 require (
 	github.com/dgraph-io/badger/v4 v4.8.0
-	github.com/google/uuid v1.6.0
 	github.com/klauspost/compress v1.18.1
 	github.com/pierrec/lz4/v4 v4.1.21
 	github.com/redis/go-redis/v9 v9.17.2
 	github.com/rs/zerolog v1.33.0
+	github.com/yuin/gopher-lua v1.1.2
 	github.com/zeebo/assert v1.3.1
+	golang.org/x/sys v0.37.0
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1
 )
 
@@ -28,6 +29,5 @@ require (
 	go.opentelemetry.io/otel/metric v1.38.0 // indirect
 	go.opentelemetry.io/otel/trace v1.38.0 // indirect
 	golang.org/x/net v0.46.0 // indirect
-	golang.org/x/sys v0.37.0 // indirect
 	google.golang.org/protobuf v1.36.10 // indirect
 )
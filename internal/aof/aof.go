@@ -0,0 +1,447 @@
+// Package aof 追加写命令日志（append-only file）：成功执行的写命令以 RESP 数组格式依次追加到文件末尾，
+// 启动时可以按顺序重放到空的存储目录中。fsync 策略与 Redis 的 appendfsync 相同；
+// Rewrite 用当前数据集生成等价的命令序列原子替换旧文件（BGREWRITEAOF），避免文件无限增长
+package aof
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fsync 策略
+const (
+	// FsyncAlways 每次追加后立即 fsync，崩溃时不丢失已回复的写命令
+	FsyncAlways = "always"
+	// FsyncEverysec 后台每秒 fsync 一次，崩溃时最多丢失约一秒的写命令
+	FsyncEverysec = "everysec"
+	// FsyncNo 不主动 fsync，由操作系统决定何时落盘
+	FsyncNo = "no"
+)
+
+const (
+	// maxArgs 一条命令最多的参数个数，超出视为文件损坏
+	maxArgs = 1 << 20
+	// maxBulkLen 单个参数的最大长度，与 Redis 的 proto-max-bulk-len 默认值相同
+	maxBulkLen = 512 << 20
+)
+
+// ErrClosed 日志已关闭
+var ErrClosed = errors.New("aof: log is closed")
+
+// ValidFsync policy 是否为合法的 fsync 策略
+func ValidFsync(policy string) bool {
+	return policy == FsyncAlways || policy == FsyncEverysec || policy == FsyncNo
+}
+
+// Stats 日志的当前状态
+type Stats struct {
+	Size     int64 // 当前文件大小
+	BaseSize int64 // 打开或最近一次重写后的文件大小
+	// LastWriteErr 最近一次追加或 fsync 的错误，成功后清空
+	LastWriteErr error
+}
+
+// Log 打开的 AOF 文件，可被多个 goroutine 并发追加
+type Log struct {
+	mu       sync.Mutex
+	path     string
+	f        *os.File
+	fsync    string
+	size     int64
+	baseSize int64
+	unsynced bool
+	writeErr error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Open 以追加方式打开（不存在时创建）path，fsync 为 FsyncAlways、FsyncEverysec 或 FsyncNo
+func Open(path, fsync string) (*Log, error) {
+	if !ValidFsync(fsync) {
+		return nil, fmt.Errorf("aof: invalid fsync policy '%s'", fsync)
+	}
+	f, err := openAppend(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	l := &Log{
+		path:     path,
+		f:        f,
+		fsync:    fsync,
+		size:     info.Size(),
+		baseSize: info.Size(),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go l.syncLoop()
+	return l, nil
+}
+
+func openAppend(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644) // #nosec G302 G304 - 路径由启动参数指定，权限与 Redis 的 AOF 文件相同
+}
+
+// Path 文件路径
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Fsync 当前的 fsync 策略
+func (l *Log) Fsync() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fsync
+}
+
+// SetFsync 修改 fsync 策略，切换到 FsyncAlways 时立即同步已写入的内容
+func (l *Log) SetFsync(policy string) error {
+	if !ValidFsync(policy) {
+		return fmt.Errorf("aof: invalid fsync policy '%s'", policy)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fsync = policy
+	if policy == FsyncAlways && l.unsynced && l.f != nil {
+		_ = l.syncLocked()
+	}
+	return nil
+}
+
+// Stats 返回当前状态
+func (l *Log) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Stats{Size: l.size, BaseSize: l.baseSize, LastWriteErr: l.writeErr}
+}
+
+// Append 把 cmds 作为一次写入追加到文件末尾，FsyncAlways 时返回前完成 fsync。
+// 多条命令需要原子重放时（事务、脚本）由调用方用 MULTI/EXEC 包围
+func (l *Log) Append(cmds ...[][]byte) error {
+	if len(cmds) == 0 {
+		return nil
+	}
+	var buf []byte
+	for _, args := range cmds {
+		buf = appendCommand(buf, args)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return ErrClosed
+	}
+	n, err := l.f.Write(buf)
+	l.size += int64(n)
+	if err != nil {
+		l.writeErr = err
+		return err
+	}
+	l.writeErr = nil
+	switch l.fsync {
+	case FsyncAlways:
+		return l.syncLocked()
+	case FsyncEverysec:
+		l.unsynced = true
+	}
+	return nil
+}
+
+// syncLocked 同步文件，调用方持有 l.mu
+func (l *Log) syncLocked() error {
+	l.unsynced = false
+	if err := l.f.Sync(); err != nil {
+		l.writeErr = err
+		return err
+	}
+	return nil
+}
+
+// syncLoop FsyncEverysec 时每秒同步一次有新写入的文件。fsync 在锁外执行，不阻塞追加
+func (l *Log) syncLoop() {
+	defer close(l.done)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			f := l.f
+			pending := l.unsynced && l.fsync == FsyncEverysec
+			l.unsynced = l.unsynced && !pending
+			l.mu.Unlock()
+			if !pending || f == nil {
+				continue
+			}
+			// 重写替换文件时旧文件可能已关闭，此时内容已由重写同步
+			if err := f.Sync(); err != nil && !errors.Is(err, os.ErrClosed) {
+				l.mu.Lock()
+				l.writeErr = err
+				l.mu.Unlock()
+			}
+		}
+	}
+}
+
+// Rewrite 用 snapshot 写出的命令生成新文件，原子替换当前文件，之后的追加写入新文件。
+// 重写期间追加会等待；调用方需保证 snapshot 读取数据集时没有正在执行的写命令，
+// 否则新文件可能遗漏或重复这些写入
+func (l *Log) Rewrite(snapshot func(w *Writer) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return ErrClosed
+	}
+	if err := WriteFile(l.path, snapshot); err != nil {
+		return err
+	}
+	f, err := openAppend(l.path)
+	if err != nil {
+		l.writeErr = err
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	_ = l.f.Close()
+	l.f = f
+	l.size, l.baseSize = info.Size(), info.Size()
+	l.unsynced = false
+	l.writeErr = nil
+	return nil
+}
+
+// Close 同步并关闭文件
+func (l *Log) Close() error {
+	l.mu.Lock()
+	if l.f == nil {
+		l.mu.Unlock()
+		return nil
+	}
+	err := l.f.Sync()
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	l.mu.Unlock()
+	close(l.stop)
+	<-l.done
+	return err
+}
+
+// Writer 重写时写入新文件的命令
+type Writer struct {
+	w *bufio.Writer
+}
+
+// Write 写入一条命令
+func (w *Writer) Write(args [][]byte) error {
+	_, err := w.w.Write(appendCommand(nil, args))
+	return err
+}
+
+// WriteFile 用 snapshot 写出的命令生成 path：先写入同目录下的临时文件并同步，再重命名为 path，
+// 失败时原文件保持不变
+func WriteFile(path string, snapshot func(w *Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "temp-rewriteaof-*.aof")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	w := &Writer{w: bufio.NewWriterSize(tmp, 64*1024)}
+	err = snapshot(w)
+	if err == nil {
+		err = w.w.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644) // #nosec G302 - 与 Open 创建的文件权限相同
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// appendCommand 把 args 编码为 RESP 数组追加到 buf
+func appendCommand(buf []byte, args [][]byte) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, a := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(a)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, a...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
+
+// LoadResult 重放结果
+type LoadResult struct {
+	Commands  int   // 重放的命令数（不含 MULTI/EXEC）
+	Truncated int64 // 文件末尾被截掉的不完整内容的字节数
+}
+
+// Load 按顺序把 path 中的命令交给 apply。MULTI 与 EXEC 之间的命令读到 EXEC 后才重放。
+// 文件末尾不完整的命令或事务（写入中途崩溃）被截掉，与 Redis 的 aof-load-truncated yes 相同；
+// 其余格式错误与 apply 的错误会停止重放并返回
+func Load(path string, apply func(args [][]byte) error) (LoadResult, error) {
+	var result LoadResult
+	f, err := os.Open(path) // #nosec G304 - 路径由启动参数指定
+	if err != nil {
+		return result, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	r := &reader{r: bufio.NewReaderSize(f, 64*1024)}
+
+	var (
+		multi      [][][]byte
+		inMulti    bool
+		multiStart int64
+	)
+	for {
+		start := r.offset
+		args, err := r.readCommand()
+		if errors.Is(err, io.EOF) && start == r.offset && !inMulti {
+			return result, nil
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// 末尾不完整：截到最后一条完整命令（或未结束的事务）之前
+			end := start
+			if inMulti {
+				end = multiStart
+			}
+			info, serr := f.Stat()
+			if serr != nil {
+				return result, serr
+			}
+			result.Truncated = info.Size() - end
+			return result, os.Truncate(path, end)
+		}
+		if err != nil {
+			return result, fmt.Errorf("aof: bad file format at offset %d: %w", start, err)
+		}
+		switch name := string(args[0]); {
+		case strings.EqualFold(name, "MULTI"):
+			if inMulti {
+				return result, fmt.Errorf("aof: nested MULTI at offset %d", start)
+			}
+			inMulti, multiStart, multi = true, start, multi[:0]
+		case strings.EqualFold(name, "EXEC"):
+			if !inMulti {
+				return result, fmt.Errorf("aof: EXEC without MULTI at offset %d", start)
+			}
+			for _, cmd := range multi {
+				if err := apply(cmd); err != nil {
+					return result, err
+				}
+				result.Commands++
+			}
+			inMulti = false
+		case inMulti:
+			multi = append(multi, args)
+		default:
+			if err := apply(args); err != nil {
+				return result, err
+			}
+			result.Commands++
+		}
+	}
+}
+
+// reader 解析 RESP 数组并记录已读取的字节数
+type reader struct {
+	r      *bufio.Reader
+	offset int64
+}
+
+// readCommand 读取一条命令。文件在命令开始处结束返回 io.EOF，在命令中间结束返回 io.ErrUnexpectedEOF
+func (r *reader) readCommand() ([][]byte, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return nil, fmt.Errorf("expected '*', got %q", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 1 || n > maxArgs {
+		return nil, fmt.Errorf("invalid argument count %q", line)
+	}
+	args := make([][]byte, n)
+	for i := range args {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("expected '$', got %q", line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, fmt.Errorf("invalid bulk length %q", line)
+		}
+		buf := make([]byte, size+2)
+		read, err := io.ReadFull(r.r, buf)
+		r.offset += int64(read)
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		if buf[size] != '\r' || buf[size+1] != '\n' {
+			return nil, errors.New("bulk string not terminated by CRLF")
+		}
+		args[i] = buf[:size]
+	}
+	return args, nil
+}
+
+// readLine 读取以 CRLF 结尾的一行（不含 CRLF）
+func (r *reader) readLine() (string, error) {
+	line, err := r.r.ReadString('\n')
+	r.offset += int64(len(line))
+	if err != nil {
+		if errors.Is(err, io.EOF) && line != "" {
+			return "", io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", errors.New("line not terminated by CRLF")
+	}
+	return line[:len(line)-2], nil
+}
+
+// unexpected 命令中间遇到文件结尾时返回 io.ErrUnexpectedEOF
+func unexpected(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,131 @@
+package aof
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func cmd(args ...string) [][]byte {
+	out := make([][]byte, len(args))
+	for i, a := range args {
+		out[i] = []byte(a)
+	}
+	return out
+}
+
+func loadAll(t *testing.T, path string) ([]string, LoadResult) {
+	var got []string
+	result, err := Load(path, func(args [][]byte) error {
+		s := ""
+		for i, a := range args {
+			if i > 0 {
+				s += " "
+			}
+			s += string(a)
+		}
+		got = append(got, s)
+		return nil
+	})
+	assert.NoError(t, err)
+	return got, result
+}
+
+func TestAppendAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	l, err := Open(path, FsyncAlways)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Append(cmd("SET", "k", "v\r\nwith crlf")))
+	assert.NoError(t, l.Append(cmd("MULTI"), cmd("INCR", "n"), cmd("LPUSH", "l", ""), cmd("EXEC")))
+	stats := l.Stats()
+	assert.Equal(t, int64(0), stats.BaseSize)
+	assert.NoError(t, stats.LastWriteErr)
+	assert.NoError(t, l.Close())
+	assert.Equal(t, ErrClosed, l.Append(cmd("DEL", "k")))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, stats.Size, int64(len(data)))
+	assert.Equal(t, "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$12\r\nv\r\nwith crlf\r\n", string(data[:39]))
+
+	got, result := loadAll(t, path)
+	assert.DeepEqual(t, []string{"SET k v\r\nwith crlf", "INCR n", "LPUSH l "}, got)
+	assert.Equal(t, 3, result.Commands)
+	assert.Equal(t, int64(0), result.Truncated)
+
+	// 重新打开后继续追加
+	l, err = Open(path, FsyncEverysec)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), l.Stats().BaseSize)
+	assert.NoError(t, l.Append(cmd("DEL", "k")))
+	assert.NoError(t, l.Close())
+	got, _ = loadAll(t, path)
+	assert.Equal(t, 4, len(got))
+}
+
+func TestLoadTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	complete := "*2\r\n$4\r\nINCR\r\n$1\r\nn\r\n"
+	for _, tail := range []string{
+		"*2\r\n$4\r\nIN",
+		"*2\r\n$4\r\nINCR\r\n$1\r\nn\r",
+		"*1\r\n$5\r\nMULTI\r\n*2\r\n$4\r\nINCR\r\n$1\r\nn\r\n",
+	} {
+		assert.NoError(t, os.WriteFile(path, []byte(complete+tail), 0o600))
+		got, result := loadAll(t, path)
+		assert.DeepEqual(t, []string{"INCR n"}, got)
+		assert.Equal(t, int64(len(tail)), result.Truncated)
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, complete, string(data))
+	}
+
+	// 中间的格式错误不截断
+	assert.NoError(t, os.WriteFile(path, []byte("+OK\r\n"+complete), 0o600))
+	_, err := Load(path, func([][]byte) error { return nil })
+	assert.Error(t, err)
+	assert.NoError(t, os.WriteFile(path, []byte("*1\r\n$4\r\nEXEC\r\n"), 0o600))
+	_, err = Load(path, func([][]byte) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestRewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	l, err := Open(path, FsyncNo)
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, l.Close())
+	}()
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, l.Append(cmd("INCR", "n")))
+	}
+
+	// 生成失败时原文件不变
+	assert.Error(t, l.Rewrite(func(w *Writer) error {
+		_ = w.Write(cmd("SET", "n", "bad"))
+		return os.ErrInvalid
+	}))
+	got, _ := loadAll(t, path)
+	assert.Equal(t, 10, len(got))
+
+	assert.NoError(t, l.Rewrite(func(w *Writer) error {
+		return w.Write(cmd("SET", "n", "10"))
+	}))
+	assert.NoError(t, l.Append(cmd("INCR", "n")))
+	got, _ = loadAll(t, path)
+	assert.DeepEqual(t, []string{"SET n 10", "INCR n"}, got)
+	stats := l.Stats()
+	assert.True(t, stats.Size > stats.BaseSize)
+
+	assert.NoError(t, l.SetFsync(FsyncAlways))
+	assert.Equal(t, FsyncAlways, l.Fsync())
+	assert.Error(t, l.SetFsync("sometimes"))
+	_, err = Open(path, "sometimes")
+	assert.Error(t, err)
+
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(path), "temp-rewriteaof-*"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(matches))
+}
@@ -1,7 +1,10 @@
 package backup
 
 import (
+	"errors"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/lbp0200/BoltDB/internal/store"
@@ -15,21 +18,90 @@ type BackupManager struct {
 	lastSaveTime    int64
 	lastSaveTimeMu  sync.RWMutex
 	backupDir       string
+	dbFilename      string
+	// 保存状态，见 Status
+	saving          atomic.Bool
+	saveStatusMu    sync.Mutex
+	lastSaveErr     error
+	lastSaveElapsed time.Duration
+	saves           int64
+	// Badger 全量与增量备份的定时、保留策略与状态，见 schedule.go
+	schedule        backupSchedule
+	// 备份上传的目的地，见 target.go
+	targetsMu       sync.Mutex
+	targets         []Target
+}
+
+// DefaultDBFilename SAVE/BGSAVE 写入的 RDB 文件名
+const DefaultDBFilename = "dump.rdb"
+
+// ErrSaveInProgress 已有保存在进行中
+var ErrSaveInProgress = errors.New("background save already in progress")
+
+// SaveStatus 保存状态，用于 INFO persistence
+type SaveStatus struct {
+	InProgress   bool          // 正在保存
+	LastOK       bool          // 最近一次保存成功（尚未保存过时为 true）
+	LastDuration time.Duration // 最近一次保存的耗时
+	Saves        int64         // 成功保存的次数
 }
 
 // NewBackupManager 创建新的备份管理器
 func NewBackupManager(store *store.BotreonStore, backupDir string) *BackupManager {
 	return &BackupManager{
-		store:     store,
-		badgerMgr: NewBadgerBackupManager(store.GetDB()),
-		rdbMgr:    NewRDBBackupManager(store),
-		backupDir: backupDir,
+		store:      store,
+		badgerMgr:  NewBadgerBackupManager(store.GetDB()),
+		rdbMgr:     NewRDBBackupManager(store),
+		backupDir:  backupDir,
+		dbFilename: DefaultDBFilename,
+		// 与 Redis 相同，启动时视为已保存
+		lastSaveTime: time.Now().Unix(),
+	}
+}
+
+// SetDBFilename 设置 SAVE/BGSAVE 写入的文件名，相对路径位于备份目录下
+func (bm *BackupManager) SetDBFilename(name string) {
+	bm.saveStatusMu.Lock()
+	defer bm.saveStatusMu.Unlock()
+	bm.dbFilename = name
+}
+
+// RDBPath SAVE/BGSAVE 写入的 RDB 文件路径
+func (bm *BackupManager) RDBPath() string {
+	bm.saveStatusMu.Lock()
+	defer bm.saveStatusMu.Unlock()
+	if filepath.IsAbs(bm.dbFilename) {
+		return bm.dbFilename
 	}
+	return filepath.Join(bm.backupDir, bm.dbFilename)
 }
 
-// Save 同步保存RDB
+// Save 同步保存RDB，覆盖 RDBPath 处的文件
 func (bm *BackupManager) Save() error {
-	backupFile, err := bm.rdbMgr.Backup(bm.backupDir)
+	if !bm.saving.CompareAndSwap(false, true) {
+		return ErrSaveInProgress
+	}
+	return bm.save()
+}
+
+// save 执行保存并记录状态，调用前需将 saving 置为 true
+func (bm *BackupManager) save() error {
+	defer bm.saving.Store(false)
+	start := time.Now()
+	path := bm.RDBPath()
+	err := bm.rdbMgr.SaveTo(path)
+	if err == nil {
+		// 上传到备份目的地，同名的旧文件被替换
+		err = bm.uploadFile(path, filepath.Base(path))
+	}
+
+	bm.saveStatusMu.Lock()
+	bm.lastSaveErr = err
+	bm.lastSaveElapsed = time.Since(start)
+	if err == nil {
+		bm.saves++
+	}
+	bm.saveStatusMu.Unlock()
 	if err != nil {
 		return err
 	}
@@ -37,24 +109,37 @@ func (bm *BackupManager) Save() error {
 	bm.lastSaveTimeMu.Lock()
 	bm.lastSaveTime = time.Now().Unix()
 	bm.lastSaveTimeMu.Unlock()
-
-	_ = backupFile // 记录备份文件路径
 	return nil
 }
 
-// BGSave 后台保存RDB
-func (bm *BackupManager) BGSave() error {
+// BGSave 后台保存RDB，保存结束后调用 done（可以为 nil）
+func (bm *BackupManager) BGSave(done func(error)) error {
+	if !bm.saving.CompareAndSwap(false, true) {
+		return ErrSaveInProgress
+	}
 	// 在goroutine中执行备份
 	go func() {
-		if err := bm.Save(); err != nil {
-			// 记录错误日志
-			return
+		err := bm.save()
+		if done != nil {
+			done(err)
 		}
 	}()
 
 	return nil
 }
 
+// Status 返回保存状态
+func (bm *BackupManager) Status() SaveStatus {
+	bm.saveStatusMu.Lock()
+	defer bm.saveStatusMu.Unlock()
+	return SaveStatus{
+		InProgress:   bm.saving.Load(),
+		LastOK:       bm.lastSaveErr == nil,
+		LastDuration: bm.lastSaveElapsed,
+		Saves:        bm.saves,
+	}
+}
+
 // LastSave 获取最后保存时间
 func (bm *BackupManager) LastSave() int64 {
 	bm.lastSaveTimeMu.RLock()
@@ -260,3 +260,24 @@ func CopyBackup(src, dst string) error {
 
 	return nil
 }
+
+// backupToFile 把版本号大于 since 的数据（since 为 0 时为全部数据）写入新文件 path，
+// 返回备份包含的最大版本，没有数据时为 0。失败时删除不完整的文件
+func (bbm *BadgerBackupManager) backupToFile(path string, since uint64) (uint64, error) {
+	file, err := os.OpenFile(filepath.Clean(path), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("create backup file failed: %w", err)
+	}
+	version, err := bbm.db.Backup(file, since)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return 0, fmt.Errorf("backup failed: %w", err)
+	}
+	return version, nil
+}
@@ -0,0 +1,176 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/lbp0200/BoltDB/internal/logger"
+)
+
+// catalogFile 备份目录中记录 Badger 全量与增量备份的清单
+const catalogFile = "badger_backups.json"
+
+// ErrNoBackup 备份目录中没有指定时间之前的全量备份
+var ErrNoBackup = errors.New("no backup taken at or before the requested time")
+
+// BackupEntry 清单中的一次 Badger 备份。全量备份包含备份时的全部数据；
+// 增量备份包含版本号大于 Since 的变更（包括删除），依次加载之前最近的全量备份与其后的增量备份得到备份时的数据
+type BackupEntry struct {
+	File    string    `json:"file"` // 备份目录中的文件名
+	Time    time.Time `json:"time"` // 备份完成的时间
+	Full    bool      `json:"full"`
+	Since   uint64    `json:"since"`   // 增量备份包含版本号大于 Since 的变更，全量备份为 0
+	Version uint64    `json:"version"` // 备份包含的最大版本，下一次增量备份的 Since
+	// DropEpoch 备份时存储的 DropEpoch，之后变化时下一次备份必须是全量备份
+	DropEpoch uint64 `json:"drop_epoch"`
+}
+
+// Retention 备份的保留策略，以全量备份及其后的增量备份为单位删除；最近的一组总是保留
+type Retention struct {
+	Keep   int           // 保留最近的 Keep 个全量备份，0 表示不限
+	MaxAge time.Duration // 删除最后一次备份早于 MaxAge 之前的组，0 表示不限
+}
+
+// ListCatalog 返回备份目录中由定时备份与 BOLTREON.BACKUP 生成的 Badger 备份，按时间先后排列
+func ListCatalog(dir string) ([]BackupEntry, error) {
+	return ReadCatalog(context.Background(), NewDirTarget(dir))
+}
+
+// ReadCatalog 返回备份目的地中的 Badger 备份清单，按时间先后排列
+func ReadCatalog(ctx context.Context, t Target) ([]BackupEntry, error) {
+	r, err := t.Get(ctx, catalogFile)
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read backup catalog failed: %w", err)
+	}
+	data, err := io.ReadAll(r)
+	if closeErr := r.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read backup catalog failed: %w", err)
+	}
+	var entries []BackupEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse backup catalog failed: %w", err)
+	}
+	return entries, nil
+}
+
+// writeCatalog 把清单写入备份目的地，DirTarget 先写临时文件再改名，中途失败不会留下不完整的清单
+func writeCatalog(t Target, entries []BackupEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := t.Put(context.Background(), catalogFile, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("write backup catalog to %s failed: %w", t, err)
+	}
+	return nil
+}
+
+// chainAt 恢复到 at 时需要依次加载的备份：at（含）之前最近的全量备份，以及它之后、at 之前的增量备份
+func chainAt(entries []BackupEntry, at time.Time) ([]BackupEntry, error) {
+	start := -1
+	end := 0
+	for i, e := range entries {
+		if e.Time.After(at) {
+			break
+		}
+		if e.Full {
+			start = i
+		}
+		end = i + 1
+	}
+	if start < 0 {
+		return nil, ErrNoBackup
+	}
+	return entries[start:end], nil
+}
+
+// RestoreToTime 把数据恢复到备份目录中 at（含）之前最近一次备份时的状态：依次加载对应的全量备份与增量备份。
+// db 应为新建的空数据库，加载期间不能有其他写入。返回加载的备份
+func RestoreToTime(db *badger.DB, dir string, at time.Time) ([]BackupEntry, error) {
+	return RestoreFromTarget(context.Background(), db, NewDirTarget(dir), at)
+}
+
+// RestoreFromTarget 与 RestoreToTime 相同，从备份目的地（如 S3）读取清单与备份
+func RestoreFromTarget(ctx context.Context, db *badger.DB, t Target, at time.Time) ([]BackupEntry, error) {
+	entries, err := ReadCatalog(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := chainAt(entries, at)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range chain {
+		if err := loadBackup(ctx, db, t, e.File); err != nil {
+			return nil, err
+		}
+		logger.Logger.Info().
+			Str("backup_file", e.File).
+			Bool("full", e.Full).
+			Time("time", e.Time).
+			Msg("已加载备份")
+	}
+	return chain, nil
+}
+
+// loadBackup 把目的地中的一个 Badger 备份加载到 db
+func loadBackup(ctx context.Context, db *badger.DB, t Target, name string) error {
+	r, err := t.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			logger.Logger.Error().Err(err).Str("backup_file", name).Msg("failed to close backup file")
+		}
+	}()
+	if err := db.Load(r, 16); err != nil {
+		return fmt.Errorf("load backup %s failed: %w", name, err)
+	}
+	return nil
+}
+
+// pruneCatalog 按保留策略找出过期的备份组（全量备份及其后的增量备份），返回保留的与过期的备份
+func pruneCatalog(entries []BackupEntry, r Retention, now time.Time) (kept, expired []BackupEntry) {
+	// 每组的起点，最后一组总是保留
+	var starts []int
+	for i, e := range entries {
+		if e.Full || i == 0 {
+			starts = append(starts, i)
+		}
+	}
+	drop := 0
+	for g := 0; g < len(starts)-1; g++ {
+		last := entries[starts[g+1]-1]
+		tooMany := r.Keep > 0 && len(starts)-g > r.Keep
+		tooOld := r.MaxAge > 0 && now.Sub(last.Time) > r.MaxAge
+		if !tooMany && !tooOld {
+			break
+		}
+		drop = starts[g+1]
+	}
+	return entries[drop:], entries[:drop]
+}
+
+// removeBackups 删除备份文件，失败只记录日志
+func removeBackups(dir string, entries []BackupEntry) {
+	for _, e := range entries {
+		if err := os.Remove(filepath.Join(dir, e.File)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.Logger.Warn().Err(err).Str("backup_file", e.File).Msg("删除过期备份失败")
+		}
+	}
+}
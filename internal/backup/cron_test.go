@@ -0,0 +1,41 @@
+package backup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestCronNext(t *testing.T) {
+	// 2026-03-04 是星期三
+	from := time.Date(2026, 3, 4, 10, 17, 30, 0, time.UTC)
+	for _, tt := range []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 3, 4, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2026, 3, 5, 3, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 3, 4, 11, 0, 0, 0, time.UTC)},
+		{"30 2 1 * *", time.Date(2026, 4, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", time.Date(2026, 3, 4, 13, 0, 0, 0, time.UTC)},
+		{"5,10 0 * 2 *", time.Date(2027, 2, 1, 0, 5, 0, 0, time.UTC)},
+		// 日与星期都有限制时满足其一即可：6 日或星期五
+		{"0 0 6 * 5", time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)},
+	} {
+		c, err := ParseCron(tt.expr)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, c.Next(from))
+	}
+
+	c, err := ParseCron("0 0 30 2 *")
+	assert.NoError(t, err)
+	assert.True(t, c.Next(from).IsZero())
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := ParseCron(expr)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,342 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// RDB 文件格式与 Redis 相同：文件头 "REDIS0009"、AUX 字段、数据库选择器，
+// 每个键为可选的毫秒过期时间、类型号、键名与值，最后是 0xFF 与 CRC64 校验和，
+// 可以被 redis-server 与 redis-rdb-tools 加载。
+// 每个键的内容来自 DUMP 的序列化结果（store.Dump），写入时转换为 Redis 的类型号与长度编码
+const rdbVersion = "0009"
+
+const (
+	rdbOpcodeAux          = 0xFA
+	rdbOpcodeExpireTimeMS = 0xFC
+	rdbOpcodeExpireTime   = 0xFD
+	rdbOpcodeSelectDB     = 0xFE
+	rdbOpcodeEOF          = 0xFF
+
+	rdbTypeString = 0
+	rdbTypeList   = 1
+	rdbTypeSet    = 2
+	rdbTypeHash   = 4
+	rdbTypeZSet2  = 5 // 分数为 8 字节二进制 double
+)
+
+// DUMP 序列化使用的类型号，与 Redis 的哈希、有序集合类型号不同
+const (
+	dumpTypeString = 0
+	dumpTypeList   = 1
+	dumpTypeSet    = 2
+	dumpTypeHash   = 3
+	dumpTypeZSet   = 4
+)
+
+// ErrUnsupportedType 键的类型在 RDB 中没有对应的编码（如 Stream）
+var ErrUnsupportedType = errors.New("type has no RDB encoding")
+
+// crc64Table Redis 使用的 CRC-64/Jones（反射多项式，初值与结果均不取反）
+var crc64Table = func() *[256]uint64 {
+	const poly = 0x95ac9329ac4bc9b5 // 0xad93d23594c935a9 按位反转
+	var t [256]uint64
+	for i := range t {
+		crc := uint64(i)
+		for j := 0; j < 8; j++ {
+			if crc&1 == 1 {
+				crc = crc>>1 ^ poly
+			} else {
+				crc >>= 1
+			}
+		}
+		t[i] = crc
+	}
+	return &t
+}()
+
+// crc64 在 crc 的基础上继续计算 p 的校验和
+func crc64(crc uint64, p []byte) uint64 {
+	for _, b := range p {
+		crc = crc64Table[byte(crc)^b] ^ crc>>8
+	}
+	return crc
+}
+
+// RDBWriter 逐个键写入 RDB 文件
+type RDBWriter struct {
+	w    *bufio.Writer
+	crc  uint64
+	err  error
+	keys int
+}
+
+// NewRDBWriter 写入文件头、AUX 字段与 0 号数据库的选择器
+func NewRDBWriter(w io.Writer) *RDBWriter {
+	rw := &RDBWriter{w: bufio.NewWriter(w)}
+	rw.write([]byte("REDIS" + rdbVersion))
+	rw.aux("redis-bits", "64")
+	rw.aux("ctime", strconv.FormatInt(time.Now().Unix(), 10))
+	rw.write([]byte{rdbOpcodeSelectDB, 0})
+	return rw
+}
+
+// Keys 已写入的键数
+func (rw *RDBWriter) Keys() int {
+	return rw.keys
+}
+
+// WriteDump 写入一个 DUMP 序列化结果。类型没有 RDB 编码时返回 ErrUnsupportedType，
+// 格式错误时返回错误，两种情况都不写入任何内容；空集合与 Redis 相同地不写入
+func (rw *RDBWriter) WriteDump(payload []byte) error {
+	if rw.err != nil {
+		return rw.err
+	}
+	obj, err := convertDump(payload)
+	if err != nil {
+		return err
+	}
+	if len(obj) == 0 {
+		return nil
+	}
+	rw.write(obj)
+	rw.keys++
+	return rw.err
+}
+
+// Close 写入文件尾与校验和并刷新缓冲区，不关闭底层的 Writer
+func (rw *RDBWriter) Close() error {
+	rw.write([]byte{rdbOpcodeEOF})
+	var sum [8]byte
+	binary.LittleEndian.PutUint64(sum[:], rw.crc)
+	if rw.err == nil {
+		_, rw.err = rw.w.Write(sum[:])
+	}
+	if rw.err == nil {
+		rw.err = rw.w.Flush()
+	}
+	return rw.err
+}
+
+func (rw *RDBWriter) write(p []byte) {
+	if rw.err != nil {
+		return
+	}
+	rw.crc = crc64(rw.crc, p)
+	_, rw.err = rw.w.Write(p)
+}
+
+func (rw *RDBWriter) aux(name, value string) {
+	var b bytes.Buffer
+	b.WriteByte(rdbOpcodeAux)
+	writeString(&b, []byte(name))
+	writeString(&b, []byte(value))
+	rw.write(b.Bytes())
+}
+
+// convertDump 把 DUMP 序列化结果转换为 RDB 中的一个键
+func convertDump(payload []byte) ([]byte, error) {
+	r := &dumpReader{data: payload}
+	header, err := r.next(9)
+	if err != nil || string(header[:5]) != "REDIS" {
+		return nil, errors.New("invalid DUMP payload")
+	}
+	expireAt := int64(-1)
+	typ, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case rdbOpcodeExpireTimeMS:
+		b, err := r.next(8)
+		if err != nil {
+			return nil, err
+		}
+		// #nosec G115 - 毫秒时间戳在 int64 范围内
+		expireAt = int64(binary.LittleEndian.Uint64(b))
+		typ, err = r.byte()
+		if err != nil {
+			return nil, err
+		}
+	case rdbOpcodeExpireTime:
+		b, err := r.next(4)
+		if err != nil {
+			return nil, err
+		}
+		expireAt = int64(binary.LittleEndian.Uint32(b)) * 1000
+		typ, err = r.byte()
+		if err != nil {
+			return nil, err
+		}
+	}
+	key, err := r.string()
+	if err != nil {
+		return nil, err
+	}
+
+	var obj bytes.Buffer
+	if expireAt >= 0 {
+		obj.WriteByte(rdbOpcodeExpireTimeMS)
+		var b [8]byte
+		// #nosec G115 - expireAt 非负
+		binary.LittleEndian.PutUint64(b[:], uint64(expireAt))
+		obj.Write(b[:])
+	}
+	switch typ {
+	case dumpTypeString:
+		value, err := r.string()
+		if err != nil {
+			return nil, err
+		}
+		obj.WriteByte(rdbTypeString)
+		writeString(&obj, key)
+		writeString(&obj, value)
+		return obj.Bytes(), nil
+	case dumpTypeList, dumpTypeSet, dumpTypeHash, dumpTypeZSet:
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedType, typ)
+	}
+
+	n, err := r.length()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	switch typ {
+	case dumpTypeList:
+		obj.WriteByte(rdbTypeList)
+	case dumpTypeSet:
+		obj.WriteByte(rdbTypeSet)
+	case dumpTypeHash:
+		obj.WriteByte(rdbTypeHash)
+	case dumpTypeZSet:
+		obj.WriteByte(rdbTypeZSet2)
+	}
+	writeString(&obj, key)
+	writeLength(&obj, n)
+	for i := uint64(0); i < n; i++ {
+		elem, err := r.string()
+		if err != nil {
+			return nil, err
+		}
+		writeString(&obj, elem)
+		switch typ {
+		case dumpTypeHash:
+			value, err := r.string()
+			if err != nil {
+				return nil, err
+			}
+			writeString(&obj, value)
+		case dumpTypeZSet:
+			score, err := r.string()
+			if err != nil {
+				return nil, err
+			}
+			f, err := strconv.ParseFloat(string(score), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid score %q: %w", score, err)
+			}
+			var b [8]byte
+			binary.LittleEndian.PutUint64(b[:], math.Float64bits(f))
+			obj.Write(b[:])
+		}
+	}
+	return obj.Bytes(), nil
+}
+
+// writeLength Redis 的长度编码：6 位、14 位，或 0x80/0x81 后跟大端序的 32/64 位长度
+func writeLength(b *bytes.Buffer, n uint64) {
+	switch {
+	case n < 1<<6:
+		b.WriteByte(byte(n))
+	case n < 1<<14:
+		b.WriteByte(byte(n>>8) | 0x40)
+		b.WriteByte(byte(n))
+	case n <= math.MaxUint32:
+		b.WriteByte(0x80)
+		var buf [4]byte
+		binary.BigEndian.PutUint32(buf[:], uint32(n))
+		b.Write(buf[:])
+	default:
+		b.WriteByte(0x81)
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], n)
+		b.Write(buf[:])
+	}
+}
+
+func writeString(b *bytes.Buffer, s []byte) {
+	writeLength(b, uint64(len(s)))
+	b.Write(s)
+}
+
+// dumpReader 读取 DUMP 序列化结果
+type dumpReader struct {
+	data []byte
+	pos  int
+}
+
+var errShortDump = errors.New("unexpected end of DUMP payload")
+
+func (r *dumpReader) next(n int) ([]byte, error) {
+	if n < 0 || len(r.data)-r.pos < n {
+		return nil, errShortDump
+	}
+	p := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return p, nil
+}
+
+func (r *dumpReader) byte() (byte, error) {
+	p, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return p[0], nil
+}
+
+// length DUMP 的长度编码与 Redis 相同，但 32 位长度为小端序
+func (r *dumpReader) length() (uint64, error) {
+	b, err := r.byte()
+	if err != nil {
+		return 0, err
+	}
+	switch b >> 6 {
+	case 0:
+		return uint64(b & 0x3F), nil
+	case 1:
+		b2, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		return uint64(b&0x3F)<<8 | uint64(b2), nil
+	case 2:
+		p, err := r.next(4)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(binary.LittleEndian.Uint32(p)), nil
+	default:
+		return 0, fmt.Errorf("unsupported length encoding: 0x%02x", b)
+	}
+}
+
+func (r *dumpReader) string() ([]byte, error) {
+	n, err := r.length()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, errShortDump
+	}
+	return r.next(int(n))
+}
@@ -1,13 +1,16 @@
 package backup
 
 import (
+	"compress/gzip"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/lbp0200/BoltDB/internal/logger"
-	"github.com/lbp0200/BoltDB/internal/replication"
 	"github.com/lbp0200/BoltDB/internal/store"
 )
 
@@ -23,38 +26,95 @@ func NewRDBBackupManager(store *store.BotreonStore) *RDBBackupManager {
 	}
 }
 
-// Backup 执行RDB备份
-func (rbm *RDBBackupManager) Backup(backupDir string) (string, error) {
-	// 创建备份目录
-	if err := os.MkdirAll(backupDir, 0750); err != nil {
-		return "", fmt.Errorf("create backup directory failed: %w", err)
+// WriteRDB 把所有键以 RDB 格式写入 w，返回写入的键数。
+// 每个键单独读取，不是所有键同一时刻的快照；读取期间过期的键与没有 RDB 编码的类型不写入
+func (rbm *RDBBackupManager) WriteRDB(w io.Writer) (int, error) {
+	rw := NewRDBWriter(w)
+	skipped := 0
+	var cursor uint64
+	for {
+		page, err := rbm.store.Scan(cursor, "*", 1000, "")
+		if err != nil {
+			return 0, err
+		}
+		for _, key := range page.Keys {
+			payload, err := rbm.store.Dump(key)
+			if err != nil {
+				switch msg := err.Error(); {
+				case strings.Contains(msg, "no such key"):
+					// 读取期间过期或被删除
+				case strings.Contains(msg, "unsupported key type"):
+					skipped++
+				default:
+					return 0, fmt.Errorf("dump key %q: %w", key, err)
+				}
+				continue
+			}
+			if err := rw.WriteDump(payload); err != nil {
+				if !errors.Is(err, ErrUnsupportedType) {
+					return 0, fmt.Errorf("encode key %q: %w", key, err)
+				}
+				skipped++
+			}
+		}
+		cursor = page.Cursor
+		if cursor == 0 {
+			break
+		}
 	}
+	if skipped > 0 {
+		logger.Logger.Warn().Int("skipped", skipped).Msg("RDB 不支持的键类型（Stream、JSON、TimeSeries 等）未写入")
+	}
+	return rw.Keys(), rw.Close()
+}
 
-	// 生成备份文件名
-	timestamp := time.Now().Format("20060102_150405")
-	backupFile := filepath.Join(backupDir, fmt.Sprintf("dump_%s.rdb", timestamp))
-
-	// 生成RDB数据
-	rdbData, err := replication.GenerateRDB(rbm.store)
+// SaveTo 将 RDB 写入同目录下的临时文件，同步后重命名为 path，写入中途失败不会损坏已有的文件
+func (rbm *RDBBackupManager) SaveTo(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("create backup directory failed: %w", err)
+	}
+	tmp := filepath.Join(dir, fmt.Sprintf("temp-%d.rdb", os.Getpid()))
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600) // #nosec G304 - 路径来自启动参数
 	if err != nil {
-		return "", fmt.Errorf("generate RDB failed: %w", err)
+		return fmt.Errorf("create RDB file failed: %w", err)
 	}
-
-	// 写入文件
-	if err := os.WriteFile(backupFile, rdbData, 0600); err != nil {
-		logger.Logger.Error().Err(err).Str("backup_file", backupFile).Msg("write RDB file failed")
-		return "", fmt.Errorf("write RDB file failed: %w", err)
+	defer func() {
+		_ = os.Remove(tmp)
+	}()
+	keys, err := rbm.WriteRDB(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		logger.Logger.Error().Err(err).Str("backup_file", path).Msg("write RDB file failed")
+		return fmt.Errorf("write RDB file failed: %w", err)
 	}
 
 	logger.Logger.Info().
-		Str("backup_file", backupFile).
-		Int("size", len(rdbData)).
+		Str("backup_file", path).
+		Int("keys", keys).
 		Msg("RDB备份完成")
+	return nil
+}
 
+// Backup 在 backupDir 下写入带时间戳的 RDB 备份
+func (rbm *RDBBackupManager) Backup(backupDir string) (string, error) {
+	timestamp := time.Now().Format("20060102_150405")
+	backupFile := filepath.Join(backupDir, fmt.Sprintf("dump_%s.rdb", timestamp))
+	if err := rbm.SaveTo(backupFile); err != nil {
+		return "", err
+	}
 	return backupFile, nil
 }
 
-// BackupWithCompression 执行压缩RDB备份
+// BackupWithCompression 执行 gzip 压缩的RDB备份
 func (rbm *RDBBackupManager) BackupWithCompression(backupDir string) (string, error) {
 	// 创建备份目录
 	if err := os.MkdirAll(backupDir, 0750); err != nil {
@@ -65,26 +125,26 @@ func (rbm *RDBBackupManager) BackupWithCompression(backupDir string) (string, er
 	timestamp := time.Now().Format("20060102_150405")
 	backupFile := filepath.Join(backupDir, fmt.Sprintf("dump_%s.rdb.gz", timestamp))
 
-	// 生成RDB数据
-	rdbData, err := replication.GenerateRDB(rbm.store)
+	f, err := os.OpenFile(backupFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600) // #nosec G304 - 路径由备份目录生成
 	if err != nil {
-		return "", fmt.Errorf("generate RDB failed: %w", err)
+		return "", fmt.Errorf("create compressed RDB file failed: %w", err)
 	}
-
-	// 压缩数据（简化实现，实际应该使用gzip）
-	// 这里暂时不压缩，直接保存
-	compressedData := rdbData
-
-	// 写入文件
-	if err := os.WriteFile(backupFile, compressedData, 0600); err != nil {
+	zw := gzip.NewWriter(f)
+	_, err = rbm.WriteRDB(zw)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(backupFile)
 		logger.Logger.Error().Err(err).Str("backup_file", backupFile).Msg("write compressed RDB file failed")
 		return "", fmt.Errorf("write compressed RDB file failed: %w", err)
 	}
 
 	logger.Logger.Info().
 		Str("backup_file", backupFile).
-		Int("original_size", len(rdbData)).
-		Int("compressed_size", len(compressedData)).
 		Msg("压缩RDB备份完成")
 
 	return backupFile, nil
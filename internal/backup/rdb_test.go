@@ -0,0 +1,163 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/store"
+	"github.com/zeebo/assert"
+)
+
+// rdbReader 按 Redis 的格式读取测试生成的 RDB 文件
+type rdbReader struct {
+	t    *testing.T
+	data []byte
+	pos  int
+}
+
+func (r *rdbReader) byte() byte {
+	assert.True(r.t, r.pos < len(r.data))
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *rdbReader) next(n int) []byte {
+	assert.True(r.t, r.pos+n <= len(r.data))
+	p := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return p
+}
+
+func (r *rdbReader) length() uint64 {
+	b := r.byte()
+	switch {
+	case b>>6 == 0:
+		return uint64(b)
+	case b>>6 == 1:
+		return uint64(b&0x3F)<<8 | uint64(r.byte())
+	case b == 0x80:
+		return uint64(binary.BigEndian.Uint32(r.next(4)))
+	case b == 0x81:
+		return binary.BigEndian.Uint64(r.next(8))
+	}
+	r.t.Fatalf("unexpected length encoding 0x%02x", b)
+	return 0
+}
+
+func (r *rdbReader) string() string {
+	return string(r.next(int(r.length())))
+}
+
+func TestCRC64(t *testing.T) {
+	// Redis src/crc64.c 中的测试向量
+	assert.Equal(t, uint64(0xe9c6d914c4b8d9ca), crc64(0, []byte("123456789")))
+	assert.Equal(t, crc64(0, []byte("123456789")), crc64(crc64(0, []byte("1234")), []byte("56789")))
+}
+
+func TestSaveTo(t *testing.T) {
+	db, err := store.NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	big := strings.Repeat("x", 70000)
+	assert.NoError(t, db.Set("str", "hello"))
+	assert.NoError(t, db.Set("big", big))
+	assert.NoError(t, db.SetWithTTL("ttl", "v", time.Hour))
+	_, err = db.RPush("list", "a", "b", "c")
+	assert.NoError(t, err)
+	_, err = db.SAdd("set", "m")
+	assert.NoError(t, err)
+	assert.NoError(t, db.HSet("hash", "f", "v"))
+	assert.NoError(t, db.ZAdd("zset", []store.ZSetMember{{Member: "a", Score: 0.1 + 0.2}, {Member: "b", Score: math.Inf(1)}}))
+	_, err = db.XAdd("stream", store.StreamXAddOptions{}, "1-1", map[string]string{"f": "v"})
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "sub", "dump.rdb")
+	assert.NoError(t, NewRDBBackupManager(db).SaveTo(path))
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(path), "temp-*"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(matches))
+
+	// 校验和覆盖 0xFF 及之前的所有内容
+	assert.Equal(t, crc64(0, data[:len(data)-8]), binary.LittleEndian.Uint64(data[len(data)-8:]))
+
+	r := &rdbReader{t: t, data: data}
+	assert.Equal(t, "REDIS0009", string(r.next(9)))
+	got := make(map[string]string)
+	expires := make(map[string]int64)
+	var expireAt int64
+	for done := false; !done; {
+		switch op := r.byte(); op {
+		case rdbOpcodeAux:
+			r.string()
+			r.string()
+		case rdbOpcodeSelectDB:
+			assert.Equal(t, uint64(0), r.length())
+		case rdbOpcodeExpireTimeMS:
+			// #nosec G115 - 测试数据
+			expireAt = int64(binary.LittleEndian.Uint64(r.next(8)))
+		case rdbOpcodeEOF:
+			done = true
+		default:
+			key := r.string()
+			if expireAt > 0 {
+				expires[key] = expireAt
+				expireAt = 0
+			}
+			var parts []string
+			switch op {
+			case rdbTypeString:
+				parts = append(parts, r.string())
+			case rdbTypeList, rdbTypeSet:
+				for n := r.length(); n > 0; n-- {
+					parts = append(parts, r.string())
+				}
+			case rdbTypeHash:
+				for n := r.length(); n > 0; n-- {
+					parts = append(parts, r.string()+"="+r.string())
+				}
+			case rdbTypeZSet2:
+				for n := r.length(); n > 0; n-- {
+					member := r.string()
+					score := math.Float64frombits(binary.LittleEndian.Uint64(r.next(8)))
+					parts = append(parts, member)
+					if member == "a" {
+						assert.Equal(t, 0.1+0.2, score)
+					} else {
+						assert.True(t, math.IsInf(score, 1))
+					}
+				}
+			default:
+				t.Fatalf("unexpected RDB type %d", op)
+			}
+			got[key] = strings.Join(parts, ",")
+		}
+	}
+	assert.Equal(t, len(data), r.pos+8)
+
+	assert.DeepEqual(t, map[string]string{
+		"str": "hello", "big": big, "ttl": "v", "list": "a,b,c", "set": "m", "hash": "f=v", "zset": "a,b",
+	}, got)
+	assert.Equal(t, 1, len(expires))
+	remaining := time.Until(time.UnixMilli(expires["ttl"]))
+	assert.True(t, remaining > 59*time.Minute && remaining <= time.Hour)
+}
+
+func TestWriteDumpInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	rw := NewRDBWriter(&buf)
+	assert.Error(t, rw.WriteDump([]byte("string:legacy")))
+	assert.Error(t, rw.WriteDump([]byte("REDIS0009\x00\x03key\x05ab")))
+	assert.Equal(t, 0, rw.Keys())
+	assert.NoError(t, rw.Close())
+}
@@ -0,0 +1,429 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultS3PartSize 分段上传每段的大小，备份不超过一段时用一次 PUT 上传
+	DefaultS3PartSize = 16 << 20
+	// MinS3PartSize S3 要求除最后一段外每段至少 5MB
+	MinS3PartSize = 5 << 20
+	// s3MaxParts 一次分段上传最多的段数
+	s3MaxParts = 10000
+	// s3RequestTimeout 单个请求（上传一段）的超时
+	s3RequestTimeout = 10 * time.Minute
+)
+
+// S3Config S3 兼容对象存储的连接参数。凭据为空时从 AWS_ACCESS_KEY_ID、AWS_SECRET_ACCESS_KEY、
+// AWS_SESSION_TOKEN 读取，Region 为空时从 AWS_REGION、AWS_DEFAULT_REGION 读取，默认 us-east-1
+type S3Config struct {
+	Endpoint        string // 如 https://minio.local:9000，默认 https://s3.<region>.amazonaws.com
+	Region          string
+	Bucket          string
+	Prefix          string // 对象名前缀，如 boltdb/prod
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// PathStyle 使用 <endpoint>/<bucket>/<key> 形式的地址（MinIO 等通常需要），否则为 <bucket>.<endpoint host>
+	PathStyle bool
+	PartSize  int64 // 分段上传每段的大小，默认 DefaultS3PartSize，至少 MinS3PartSize
+	// SSE 服务端加密：空、AES256 或 aws:kms；SSEKMSKeyID 为 aws:kms 使用的密钥，空时使用默认密钥
+	SSE         string
+	SSEKMSKeyID string
+	Client      *http.Client // 默认每个请求超时 10 分钟
+}
+
+// S3Target 以 S3 兼容对象存储为备份目的地，请求使用 AWS Signature Version 4 签名
+type S3Target struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3Target 检查配置并创建 S3Target，不访问网络
+func NewS3Target(cfg S3Config) (*S3Target, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3 bucket is required")
+	}
+	if cfg.AccessKeyID == "" && cfg.SecretAccessKey == "" {
+		cfg.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		cfg.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		if cfg.SessionToken == "" {
+			cfg.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+		}
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("s3 credentials are required: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if cfg.Region == "" {
+		cfg.Region = os.Getenv("AWS_REGION")
+	}
+	if cfg.Region == "" {
+		cfg.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	if !strings.Contains(cfg.Endpoint, "://") {
+		cfg.Endpoint = "https://" + cfg.Endpoint
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.PartSize == 0 {
+		cfg.PartSize = DefaultS3PartSize
+	}
+	if cfg.PartSize < MinS3PartSize {
+		return nil, fmt.Errorf("s3 part size must be at least %d bytes", MinS3PartSize)
+	}
+	switch cfg.SSE {
+	case "", "AES256", "aws:kms":
+	default:
+		return nil, fmt.Errorf("invalid s3 server-side encryption %q: use AES256 or aws:kms", cfg.SSE)
+	}
+	if cfg.SSEKMSKeyID != "" && cfg.SSE != "aws:kms" {
+		return nil, errors.New("s3 KMS key id requires server-side encryption aws:kms")
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: s3RequestTimeout}
+	}
+	return &S3Target{cfg: cfg, endpoint: endpoint, client: client, now: time.Now}, nil
+}
+
+func (s *S3Target) String() string {
+	if s.cfg.Prefix == "" {
+		return "s3://" + s.cfg.Bucket
+	}
+	return "s3://" + s.cfg.Bucket + "/" + s.cfg.Prefix
+}
+
+// key 返回 name 对应的对象名
+func (s *S3Target) key(name string) string {
+	if s.cfg.Prefix == "" {
+		return name
+	}
+	return s.cfg.Prefix + "/" + name
+}
+
+// Put 上传备份。不超过一段时用一次 PUT，否则边读边分段上传，只在内存中保留一段；失败时中止分段上传
+func (s *S3Target) Put(ctx context.Context, name string, r io.Reader) error {
+	key := s.key(name)
+	buf := make([]byte, s.cfg.PartSize)
+	n, err := io.ReadFull(r, buf)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		resp, err := s.do(ctx, http.MethodPut, key, nil, s.sseHeaders(), buf[:n])
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	uploadID, err := s.createMultipartUpload(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := s.uploadParts(ctx, key, uploadID, r, buf); err != nil {
+		// 中止后服务端释放已上传的段
+		if abortErr := s.abortMultipartUpload(ctx, key, uploadID); abortErr != nil {
+			return fmt.Errorf("%w (abort multipart upload: %v)", err, abortErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// uploadParts 上传 buf 中已读到的第一段与 r 中剩余的数据，然后完成分段上传
+func (s *S3Target) uploadParts(ctx context.Context, key, uploadID string, r io.Reader, buf []byte) error {
+	var complete s3CompleteMultipartUpload
+	n := len(buf)
+	for part := 1; ; part++ {
+		if part > s3MaxParts {
+			return fmt.Errorf("backup larger than %d parts of %d bytes: increase the s3 part size", s3MaxParts, len(buf))
+		}
+		etag, err := s.uploadPart(ctx, key, uploadID, part, buf[:n])
+		if err != nil {
+			return err
+		}
+		complete.Parts = append(complete.Parts, s3CompletedPart{PartNumber: part, ETag: etag})
+
+		var readErr error
+		n, readErr = io.ReadFull(r, buf)
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil && !errors.Is(readErr, io.ErrUnexpectedEOF) {
+			return readErr
+		}
+	}
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+	query := url.Values{"uploadId": {uploadID}}
+	resp, err := s.do(ctx, http.MethodPost, key, query, nil, body)
+	if err != nil {
+		return err
+	}
+	// 完成请求可能在返回 200 之后才在响应体中报告错误
+	data, err := io.ReadAll(resp.Body)
+	if closeErr := resp.Body.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(data, []byte("<Error>")) {
+		return parseS3Error(resp.StatusCode, data)
+	}
+	return nil
+}
+
+func (s *S3Target) createMultipartUpload(ctx context.Context, key string) (string, error) {
+	resp, err := s.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, s.sseHeaders(), nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("parse s3 CreateMultipartUpload response failed: %w", err)
+	}
+	if result.UploadID == "" {
+		return "", errors.New("s3 CreateMultipartUpload returned no upload id")
+	}
+	return result.UploadID, nil
+}
+
+func (s *S3Target) uploadPart(ctx context.Context, key, uploadID string, part int, data []byte) (string, error) {
+	query := url.Values{"partNumber": {strconv.Itoa(part)}, "uploadId": {uploadID}}
+	resp, err := s.do(ctx, http.MethodPut, key, query, nil, data)
+	if err != nil {
+		return "", err
+	}
+	etag := resp.Header.Get("ETag")
+	if err := resp.Body.Close(); err != nil {
+		return "", err
+	}
+	if etag == "" {
+		return "", fmt.Errorf("s3 UploadPart %d returned no ETag", part)
+	}
+	return etag, nil
+}
+
+func (s *S3Target) abortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Get 下载对象
+func (s *S3Target) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.key(name), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Delete 删除对象，S3 删除不存在的对象同样成功
+func (s *S3Target) Delete(ctx context.Context, name string) error {
+	resp, err := s.do(ctx, http.MethodDelete, s.key(name), nil, nil, nil)
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// sseHeaders 创建对象（PUT 与开始分段上传）时的服务端加密请求头
+func (s *S3Target) sseHeaders() http.Header {
+	h := http.Header{}
+	if s.cfg.SSE != "" {
+		h.Set("X-Amz-Server-Side-Encryption", s.cfg.SSE)
+	}
+	if s.cfg.SSEKMSKeyID != "" {
+		h.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", s.cfg.SSEKMSKeyID)
+	}
+	return h
+}
+
+// do 发送签名的请求，状态码不是 2xx 时关闭响应并返回 S3 的错误；404 返回 ErrObjectNotFound
+func (s *S3Target) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	if s.cfg.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.cfg.Bucket + "/" + key
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	u.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.ContentLength = int64(len(body))
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+	sum := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(sum[:]), s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s %s failed: %w", method, key, err)
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && method != http.MethodPost {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	return nil, fmt.Errorf("s3 %s %s failed: %w", method, key, parseS3Error(resp.StatusCode, data))
+}
+
+// sign 为请求加上 AWS Signature Version 4 的 Authorization 头，签名包含 Host 与已设置的请求头
+func (s *S3Target) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.cfg.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3EscapePath 按 SigV4 的规则编码路径：除字母、数字、-_.~ 与 / 以外的字节都编码
+func s3EscapePath(path string) string {
+	return s3Escape(path, true)
+}
+
+// s3CanonicalQuery 按参数名排序并编码查询参数，同时用作请求的查询字符串
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (keepSlash && c == '/') {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// parseS3Error 从 S3 的 XML 错误响应中取出错误码与信息
+func parseS3Error(status int, data []byte) error {
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.Unmarshal(data, &e); err != nil || e.Code == "" {
+		return fmt.Errorf("http status %d", status)
+	}
+	return fmt.Errorf("%s: %s (http status %d)", e.Code, e.Message, status)
+}
+
+// s3CompleteMultipartUpload 完成分段上传的请求体
+type s3CompleteMultipartUpload struct {
+	XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletedPart `xml:"Part"`
+}
+
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
@@ -0,0 +1,249 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+)
+
+// DefaultFullBackupEvery 默认每做这么多次增量备份之后做一次全量备份，限制恢复时需要加载的文件数
+const DefaultFullBackupEvery = 24
+
+// backupSchedule Badger 备份的定时与状态
+type backupSchedule struct {
+	// run 同一时间只做一个备份，读写清单也在其中
+	run sync.Mutex
+
+	mu        sync.Mutex
+	cron      *CronSchedule
+	stop      chan struct{}
+	done      chan struct{}
+	retention Retention
+	// fullEvery 连续增量备份的上限，0 表示只在必要时做全量备份
+	fullEvery    int
+	fullEverySet bool
+	lastTime     time.Time
+	lastErr      error
+}
+
+// BackupStatus 定时备份与 BOLTREON.BACKUP 的最近一次结果，用于 INFO persistence
+type BackupStatus struct {
+	LastTime time.Time // 最近一次备份的时间，尚未备份过时为零值
+	LastOK   bool      // 最近一次备份成功（尚未备份过时为 true）
+}
+
+// SetSchedule 设置定时备份的 cron 表达式（见 ParseCron），为空时停止定时备份。
+// 每次到时做一次增量备份，必要时为全量备份（见 RunBackup）
+func (bm *BackupManager) SetSchedule(expr string) error {
+	var c *CronSchedule
+	if expr != "" {
+		var err error
+		if c, err = ParseCron(expr); err != nil {
+			return err
+		}
+	}
+	sc := &bm.schedule
+	sc.mu.Lock()
+	stop, done := sc.stop, sc.done
+	sc.cron, sc.stop, sc.done = c, nil, nil
+	if c != nil {
+		sc.stop, sc.done = make(chan struct{}), make(chan struct{})
+		go bm.runSchedule(c, sc.stop, sc.done)
+	}
+	sc.mu.Unlock()
+	stopSchedule(stop, done)
+	return nil
+}
+
+// Schedule 返回定时备份的 cron 表达式，没有定时备份时为空
+func (bm *BackupManager) Schedule() string {
+	sc := &bm.schedule
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.cron == nil {
+		return ""
+	}
+	return sc.cron.String()
+}
+
+// SetRetention 设置备份的保留策略，下一次备份后生效
+func (bm *BackupManager) SetRetention(r Retention) {
+	sc := &bm.schedule
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.retention = r
+}
+
+// Retention 返回备份的保留策略
+func (bm *BackupManager) Retention() Retention {
+	sc := &bm.schedule
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.retention
+}
+
+// SetFullBackupEvery 设置连续增量备份的上限，达到后下一次做全量备份；0 表示只在必要时做全量备份
+func (bm *BackupManager) SetFullBackupEvery(n int) {
+	sc := &bm.schedule
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.fullEvery, sc.fullEverySet = n, true
+}
+
+// FullBackupEvery 返回连续增量备份的上限，未设置时为 DefaultFullBackupEvery
+func (bm *BackupManager) FullBackupEvery() int {
+	sc := &bm.schedule
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if !sc.fullEverySet {
+		return DefaultFullBackupEvery
+	}
+	return sc.fullEvery
+}
+
+// BackupStatus 返回最近一次 Badger 备份的结果
+func (bm *BackupManager) BackupStatus() BackupStatus {
+	sc := &bm.schedule
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return BackupStatus{LastTime: sc.lastTime, LastOK: sc.lastErr == nil}
+}
+
+// Close 停止定时备份，等待进行中的备份完成
+func (bm *BackupManager) Close() {
+	_ = bm.SetSchedule("")
+	sc := &bm.schedule
+	sc.run.Lock()
+	defer sc.run.Unlock()
+}
+
+// stopSchedule 停止定时备份的 goroutine 并等待它退出（包括进行中的备份）。
+// 备份期间会获取 schedule.mu，调用时不能持有
+func stopSchedule(stop, done chan struct{}) {
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// runSchedule 按 cron 表达式定时备份，直到 stop 关闭
+func (bm *BackupManager) runSchedule(c *CronSchedule, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	for {
+		next := c.Next(time.Now())
+		if next.IsZero() {
+			logger.Logger.Warn().Str("schedule", c.String()).Msg("定时备份的 cron 表达式不会再匹配")
+			return
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if _, err := bm.RunBackup(false); err != nil {
+			logger.Logger.Error().Err(err).Str("schedule", c.String()).Msg("定时备份失败")
+		}
+	}
+}
+
+// RunBackup 做一次 Badger 备份并记入备份目录的清单，之后按保留策略删除旧的备份。
+// full 为 false 时做增量备份，以下情况改为全量备份：还没有全量备份、上次备份后 FLUSHALL/FLUSHDB 等
+// 直接删除了数据（增量备份看不到，见 store.DropEpoch）、连续增量备份达到 FullBackupEvery
+func (bm *BackupManager) RunBackup(full bool) (BackupEntry, error) {
+	sc := &bm.schedule
+	sc.run.Lock()
+	defer sc.run.Unlock()
+	entry, err := bm.runBackup(full)
+
+	sc.mu.Lock()
+	sc.lastTime, sc.lastErr = time.Now(), err
+	sc.mu.Unlock()
+	return entry, err
+}
+
+func (bm *BackupManager) runBackup(full bool) (BackupEntry, error) {
+	if err := os.MkdirAll(bm.backupDir, 0750); err != nil {
+		return BackupEntry{}, fmt.Errorf("create backup directory failed: %w", err)
+	}
+	entries, err := ListCatalog(bm.backupDir)
+	if err != nil {
+		return BackupEntry{}, err
+	}
+
+	dropEpoch := bm.store.DropEpoch()
+	var since uint64
+	if !full && len(entries) > 0 {
+		last := entries[len(entries)-1]
+		incrementals := 0
+		for i := len(entries) - 1; i >= 0 && !entries[i].Full; i-- {
+			incrementals++
+		}
+		fullEvery := bm.FullBackupEvery()
+		full = last.DropEpoch != dropEpoch || (fullEvery > 0 && incrementals >= fullEvery)
+		since = last.Version
+	} else {
+		full = true
+	}
+	if full {
+		since = 0
+	}
+
+	now := time.Now()
+	kind := "inc"
+	if full {
+		kind = "full"
+	}
+	name := fmt.Sprintf("badger_%s_%s_%03d", kind, now.Format("20060102_150405"), now.Nanosecond()/int(time.Millisecond))
+	version, err := bm.badgerMgr.backupToFile(filepath.Join(bm.backupDir, name), since)
+	if err != nil {
+		return BackupEntry{}, err
+	}
+	// 没有新数据时备份为空，版本保持不变
+	if version < since {
+		version = since
+	}
+	// 先上传备份再更新清单：上传失败时丢弃这次备份，下一次从同一版本重新做
+	path := filepath.Join(bm.backupDir, name)
+	if err := bm.uploadFile(path, name); err != nil {
+		_ = os.Remove(path)
+		bm.deleteFromTargets([]BackupEntry{{File: name}})
+		return BackupEntry{}, err
+	}
+	entry := BackupEntry{File: name, Time: now, Full: full, Since: since, Version: version, DropEpoch: dropEpoch}
+	kept, expired := pruneCatalog(append(entries, entry), bm.Retention(), now)
+	if err := writeCatalog(NewDirTarget(bm.backupDir), kept); err != nil {
+		_ = os.Remove(path)
+		bm.deleteFromTargets([]BackupEntry{entry})
+		return BackupEntry{}, err
+	}
+	removeBackups(bm.backupDir, expired)
+	// 目的地的清单更新之后才删除其中过期的备份，清单引用的文件总是存在
+	for _, t := range bm.Targets() {
+		if err := writeCatalog(t, kept); err != nil {
+			return entry, err
+		}
+	}
+	bm.deleteFromTargets(expired)
+	logger.Logger.Info().
+		Str("backup_file", name).
+		Bool("full", full).
+		Uint64("since", since).
+		Uint64("version", version).
+		Msg("BadgerDB备份完成")
+	return entry, nil
+}
+
+// Backups 返回备份目录清单中的 Badger 备份，按时间先后排列
+func (bm *BackupManager) Backups() ([]BackupEntry, error) {
+	sc := &bm.schedule
+	sc.run.Lock()
+	defer sc.run.Unlock()
+	return ListCatalog(bm.backupDir)
+}
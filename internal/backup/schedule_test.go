@@ -0,0 +1,133 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/store"
+	"github.com/zeebo/assert"
+)
+
+func TestRunBackupIncremental(t *testing.T) {
+	db, err := store.NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	defer db.Close()
+	dir := t.TempDir()
+	bm := NewBackupManager(db, dir)
+
+	assert.NoError(t, db.Set("a", "1"))
+	first, err := bm.RunBackup(false)
+	assert.NoError(t, err)
+	assert.True(t, first.Full)
+
+	assert.NoError(t, db.Set("b", "2"))
+	_, err = db.Del("a")
+	assert.NoError(t, err)
+	second, err := bm.RunBackup(false)
+	assert.NoError(t, err)
+	assert.False(t, second.Full)
+	assert.Equal(t, first.Version, second.Since)
+
+	// 没有新数据时版本不变
+	third, err := bm.RunBackup(false)
+	assert.NoError(t, err)
+	assert.False(t, third.Full)
+	assert.Equal(t, second.Version, third.Version)
+
+	// FLUSHALL 不写删除标记，之后的备份是全量备份
+	assert.NoError(t, db.FlushDB())
+	assert.NoError(t, db.Set("c", "3"))
+	fourth, err := bm.RunBackup(false)
+	assert.NoError(t, err)
+	assert.True(t, fourth.Full)
+
+	entries, err := bm.Backups()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(entries))
+
+	// 恢复到第二次备份：a 已删除，b 存在
+	restored := restoreAt(t, dir, second.Time)
+	defer restored.Close()
+	_, err = restored.Get("a")
+	assert.Error(t, err)
+	val, err := restored.Get("b")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", val)
+	// 恢复后的数据库可以继续写入
+	assert.NoError(t, restored.Set("d", "4"))
+	val, err = restored.Get("d")
+	assert.NoError(t, err)
+	assert.Equal(t, "4", val)
+
+	latest := restoreAt(t, dir, time.Now())
+	defer latest.Close()
+	keys, err := latest.Keys("*")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"c"}, keys)
+
+	target, err := store.NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	defer target.Close()
+	_, err = RestoreToTime(target.GetDB(), dir, first.Time.Add(-time.Second))
+	assert.Equal(t, ErrNoBackup, err)
+}
+
+// restoreAt 把备份恢复到新的数据库
+func restoreAt(t *testing.T, dir string, at time.Time) *store.BotreonStore {
+	db, err := store.NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	_, err = RestoreToTime(db.GetDB(), dir, at)
+	assert.NoError(t, err)
+	return db
+}
+
+func TestRunBackupRetention(t *testing.T) {
+	db, err := store.NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	defer db.Close()
+	dir := t.TempDir()
+	bm := NewBackupManager(db, dir)
+	bm.SetFullBackupEvery(1)
+	bm.SetRetention(Retention{Keep: 2})
+
+	for i := 0; i < 6; i++ {
+		assert.NoError(t, db.Set("k", string(rune('a'+i))))
+		_, err := bm.RunBackup(false)
+		assert.NoError(t, err)
+	}
+	// 每个全量备份之后一个增量备份，保留最近两组
+	entries, err := bm.Backups()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(entries))
+	assert.True(t, entries[0].Full)
+	assert.False(t, entries[1].Full)
+	assert.True(t, entries[2].Full)
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(files))
+	for _, e := range entries {
+		_, err := os.Stat(filepath.Join(dir, e.File))
+		assert.NoError(t, err)
+	}
+
+	kept, expired := pruneCatalog(entries, Retention{MaxAge: time.Hour}, time.Now().Add(2*time.Hour))
+	assert.Equal(t, 2, len(kept))
+	assert.Equal(t, 2, len(expired))
+}
+
+func TestSetSchedule(t *testing.T) {
+	db, err := store.NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	defer db.Close()
+	bm := NewBackupManager(db, t.TempDir())
+	defer bm.Close()
+
+	assert.Error(t, bm.SetSchedule("* *"))
+	assert.Equal(t, "", bm.Schedule())
+	assert.NoError(t, bm.SetSchedule("@daily"))
+	assert.Equal(t, "@daily", bm.Schedule())
+	assert.NoError(t, bm.SetSchedule(""))
+	assert.Equal(t, "", bm.Schedule())
+}
@@ -0,0 +1,161 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+)
+
+// ErrObjectNotFound 目的地中没有这个备份文件
+var ErrObjectNotFound = errors.New("backup object not found")
+
+// Target 备份的目的地。RunBackup 与 SAVE/BGSAVE 写完本地文件后上传到每个目的地，
+// 备份清单随之更新，过期的备份也从目的地删除，本地磁盘损坏后仍可以从目的地恢复（见 RestoreFromTarget）
+type Target interface {
+	// String 用于日志，不包含凭据
+	String() string
+	// Put 上传名为 name 的文件，读到 r 结束为止；同名文件被替换
+	Put(ctx context.Context, name string, r io.Reader) error
+	// Get 读取名为 name 的文件，不存在时返回 ErrObjectNotFound
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	// Delete 删除名为 name 的文件，不存在时不报错
+	Delete(ctx context.Context, name string) error
+}
+
+// DirTarget 以本地目录（通常是挂载的网络存储）为目的地
+type DirTarget struct {
+	Dir string
+}
+
+// NewDirTarget 创建以 dir 为目的地的 DirTarget
+func NewDirTarget(dir string) *DirTarget {
+	return &DirTarget{Dir: dir}
+}
+
+func (d *DirTarget) String() string {
+	return "dir:" + d.Dir
+}
+
+// path 返回 name 在目录中的路径，拒绝越出目录的名字
+func (d *DirTarget) path(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid backup name %q", name)
+	}
+	return filepath.Join(d.Dir, name), nil
+}
+
+// Put 先写临时文件再改名，中途失败不会留下不完整的文件
+func (d *DirTarget) Put(ctx context.Context, name string, r io.Reader) error {
+	path, err := d.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.Dir, 0750); err != nil {
+		return fmt.Errorf("create backup directory failed: %w", err)
+	}
+	tmp, err := os.CreateTemp(d.Dir, "."+name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create backup file failed: %w", err)
+	}
+	_, err = io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write backup %s failed: %w", name, err)
+	}
+	return nil
+}
+
+// Get 打开目录中的文件
+func (d *DirTarget) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	path, err := d.path(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open backup file failed: %w", err)
+	}
+	return f, nil
+}
+
+// Delete 删除目录中的文件
+func (d *DirTarget) Delete(ctx context.Context, name string) error {
+	path, err := d.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// AddTarget 增加一个备份目的地，之后的备份都会上传
+func (bm *BackupManager) AddTarget(t Target) {
+	bm.targetsMu.Lock()
+	defer bm.targetsMu.Unlock()
+	bm.targets = append(bm.targets, t)
+}
+
+// Targets 返回备份目的地
+func (bm *BackupManager) Targets() []Target {
+	bm.targetsMu.Lock()
+	defer bm.targetsMu.Unlock()
+	return append([]Target(nil), bm.targets...)
+}
+
+// uploadFile 把本地文件以 name 上传到每个目的地
+func (bm *BackupManager) uploadFile(path, name string) error {
+	for _, t := range bm.Targets() {
+		if err := uploadTo(t, path, name); err != nil {
+			return err
+		}
+		logger.Logger.Info().Str("backup_file", name).Str("target", t.String()).Msg("备份已上传")
+	}
+	return nil
+}
+
+func uploadTo(t Target, path, name string) error {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("open backup file failed: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			logger.Logger.Error().Err(err).Str("backup_file", path).Msg("failed to close backup file")
+		}
+	}()
+	if err := t.Put(context.Background(), name, file); err != nil {
+		return fmt.Errorf("upload %s to %s failed: %w", name, t, err)
+	}
+	return nil
+}
+
+// deleteFromTargets 从每个目的地删除过期的备份，失败只记录日志
+func (bm *BackupManager) deleteFromTargets(entries []BackupEntry) {
+	for _, t := range bm.Targets() {
+		for _, e := range entries {
+			if err := t.Delete(context.Background(), e.File); err != nil {
+				logger.Logger.Warn().Err(err).Str("backup_file", e.File).Str("target", t.String()).Msg("删除过期备份失败")
+			}
+		}
+	}
+}
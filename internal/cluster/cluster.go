@@ -4,6 +4,10 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/lbp0200/BoltDB/internal/store"
@@ -30,7 +34,7 @@ func NewCluster(store *store.BotreonStore, nodeID, addr string) (*Cluster, error
 		}
 	}
 
-	myself := NewNode(nodeID, addr)
+	myself := NewNode(nodeID, announceAddr(addr))
 	myself.SetMyself()
 	myself.Flags = append(myself.Flags, "master")
 
@@ -51,6 +55,19 @@ func NewCluster(store *store.BotreonStore, nodeID, addr string) (*Cluster, error
 	return cluster, nil
 }
 
+// announceAddr 监听地址没有主机或为 0.0.0.0 等通配地址时，以 127.0.0.1 作为对外公布的地址，
+// 使 MOVED 与 CLUSTER SLOTS 返回客户端可以连接的地址
+func announceAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
+
 // generateNodeID 生成40字符的十六进制节点ID
 func generateNodeID() (string, error) {
 	bytes := make([]byte, 20)
@@ -90,11 +107,16 @@ func (c *Cluster) RemoveNode(nodeID string) {
 	defer c.mu.Unlock()
 	delete(c.Nodes, nodeID)
 	// 将该节点负责的槽位重新分配给当前节点
+	moved := false
 	for i := uint32(0); i < SlotCount; i++ {
 		if c.Slots[i] != nil && c.Slots[i].ID == nodeID {
 			c.Slots[i] = c.Myself
+			moved = true
 		}
 	}
+	if moved {
+		c.refreshSlotRanges(c.Myself)
+	}
 }
 
 // AssignSlot 将槽位分配给指定节点
@@ -111,15 +133,62 @@ func (c *Cluster) AssignSlot(slot uint32, nodeID string) error {
 		return fmt.Errorf("node %s not found", nodeID)
 	}
 
-	// 从旧节点移除槽位（简化处理）
-	_ = c.Slots[slot]
-
+	old := c.Slots[slot]
+	if old == node {
+		return nil
+	}
 	c.Slots[slot] = node
-	node.AddSlotRange(slot, slot)
+	c.refreshSlotRanges(old, node)
 
 	return nil
 }
 
+// UnassignSlot 取消槽位的分配（CLUSTER DELSLOTS）
+func (c *Cluster) UnassignSlot(slot uint32) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if slot >= SlotCount {
+		return fmt.Errorf("slot %d out of range", slot)
+	}
+	old := c.Slots[slot]
+	if old == nil {
+		return fmt.Errorf("Slot %d is already unassigned", slot)
+	}
+	c.Slots[slot] = nil
+	c.refreshSlotRanges(old)
+	return nil
+}
+
+// refreshSlotRanges 按槽位映射重新计算节点的 Slots，调用方持有 c.mu
+func (c *Cluster) refreshSlotRanges(nodes ...*Node) {
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+		ranges := c.slotRangesOf(node)
+		node.mu.Lock()
+		node.Slots = ranges
+		node.mu.Unlock()
+	}
+}
+
+// slotRangesOf 节点负责的槽位，按槽位号排序并合并连续的槽位，调用方持有 c.mu
+func (c *Cluster) slotRangesOf(node *Node) []SlotRange {
+	ranges := []SlotRange{}
+	for i := uint32(0); i < SlotCount; i++ {
+		if c.Slots[i] != node {
+			continue
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].End+1 == i {
+			ranges[n-1].End = i
+		} else {
+			ranges = append(ranges, SlotRange{Start: i, End: i})
+		}
+	}
+	return ranges
+}
+
 // AssignSlotRange 将槽位范围分配给指定节点
 func (c *Cluster) AssignSlotRange(start, end uint32, nodeID string) error {
 	c.mu.Lock()
@@ -134,10 +203,14 @@ func (c *Cluster) AssignSlotRange(start, end uint32, nodeID string) error {
 		return fmt.Errorf("node %s not found", nodeID)
 	}
 
+	previous := []*Node{node}
 	for i := start; i <= end; i++ {
+		if old := c.Slots[i]; old != nil && old != node && old != previous[len(previous)-1] {
+			previous = append(previous, old)
+		}
 		c.Slots[i] = node
 	}
-	node.AddSlotRange(start, end)
+	c.refreshSlotRanges(previous...)
 
 	return nil
 }
@@ -159,22 +232,34 @@ func (c *Cluster) GetClusterNodes() []string {
 	defer c.mu.RUnlock()
 
 	var result []string
-	for _, node := range c.Nodes {
+	for _, node := range c.sortedNodes() {
 		line := c.formatNodeLine(node)
 		result = append(result, line)
 	}
 	return result
 }
 
-// formatNodeLine 格式化节点行为CLUSTER NODES格式
-func (c *Cluster) formatNodeLine(node *Node) string {
-	// 格式: <id> <ip:port> <flags> <master> <ping-sent> <pong-recv> <epoch> <link-state> <slots>
-	flags := ""
-	if len(node.Flags) > 0 {
-		flags = node.Flags[0]
-		for i := 1; i < len(node.Flags); i++ {
-			flags += "," + node.Flags[i]
+// sortedNodes 所有节点，当前节点在前，其余按节点ID排序，使输出稳定，调用方持有 c.mu
+func (c *Cluster) sortedNodes() []*Node {
+	nodes := make([]*Node, 0, len(c.Nodes))
+	for _, node := range c.Nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if (nodes[i] == c.Myself) != (nodes[j] == c.Myself) {
+			return nodes[i] == c.Myself
 		}
+		return nodes[i].ID < nodes[j].ID
+	})
+	return nodes
+}
+
+// formatNodeLine 格式化节点行为CLUSTER NODES格式，槽位取自槽位映射，调用方持有 c.mu
+func (c *Cluster) formatNodeLine(node *Node) string {
+	// 格式: <id> <ip:port> <flags> <master> <ping-sent> <pong-recv> <epoch> <link-state> <slot> <slot> ...
+	flags := strings.Join(node.Flags, ",")
+	if flags == "" {
+		flags = "noflags"
 	}
 
 	masterID := "-"
@@ -183,16 +268,12 @@ func (c *Cluster) formatNodeLine(node *Node) string {
 	}
 
 	slots := ""
-	if len(node.Slots) > 0 {
-		slotStrs := []string{}
-		for _, r := range node.Slots {
-			if r.Start == r.End {
-				slotStrs = append(slotStrs, fmt.Sprintf("%d", r.Start))
-			} else {
-				slotStrs = append(slotStrs, fmt.Sprintf("%d-%d", r.Start, r.End))
-			}
+	for _, r := range c.slotRangesOf(node) {
+		if r.Start == r.End {
+			slots += fmt.Sprintf(" %d", r.Start)
+		} else {
+			slots += fmt.Sprintf(" %d-%d", r.Start, r.End)
 		}
-		slots = fmt.Sprintf(" %s", fmt.Sprintf("%v", slotStrs))
 	}
 
 	return fmt.Sprintf("%s %s %s %s %d %d %d connected%s",
@@ -215,46 +296,108 @@ func (c *Cluster) GetClusterSlots() [][]interface{} {
 			continue
 		}
 
-		// 格式: [start, end, [ip, port, nodeid], ...]
-		host, port, err := node.GetHostPort()
-		if err != nil {
+		// 格式: [start, end, [ip, port, nodeid], [replica ip, port, nodeid]...]，port 为整数
+		master, ok := nodeEndpoint(node)
+		if !ok {
 			continue
 		}
-
-		slotInfo := []interface{}{
-			int64(r.Start),
-			int64(r.End),
-			[]interface{}{host, port, node.ID},
+		slotInfo := []interface{}{int64(r.Start), int64(r.End), master}
+		for _, replica := range c.replicasOf(node) {
+			if endpoint, ok := nodeEndpoint(replica); ok {
+				slotInfo = append(slotInfo, endpoint)
+			}
 		}
 
-		// 如果有replica，添加replica信息
-		// 这里简化处理，实际应该查找该节点的slave节点
-
 		result = append(result, slotInfo)
 	}
 
 	return result
 }
 
-// mergeSlotRanges 合并连续的槽位范围
-func (c *Cluster) mergeSlotRanges() []SlotRange {
-	// 按节点分组槽位
-	nodeSlots := make(map[string][]uint32)
-	for i := uint32(0); i < SlotCount; i++ {
-		if c.Slots[i] != nil {
-			nodeID := c.Slots[i].ID
-			nodeSlots[nodeID] = append(nodeSlots[nodeID], i)
+// nodeEndpoint CLUSTER SLOTS 中的节点：[ip, port, nodeid]
+func nodeEndpoint(node *Node) ([]interface{}, bool) {
+	host, port, err := node.GetHostPort()
+	if err != nil {
+		return nil, false
+	}
+	portNum, err := strconv.ParseInt(port, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return []interface{}{host, portNum, node.ID}, true
+}
+
+// replicasOf 复制 master 的节点，按节点ID排序，调用方持有 c.mu
+func (c *Cluster) replicasOf(master *Node) []*Node {
+	var replicas []*Node
+	for _, node := range c.sortedNodes() {
+		if node.MasterID == master.ID && node != master {
+			replicas = append(replicas, node)
+		}
+	}
+	return replicas
+}
+
+// GetClusterShards 获取分片信息（用于CLUSTER SHARDS命令）。
+// 每个主节点与它的从节点组成一个分片：[slots [start end ...] nodes [{id port ip endpoint role replication-offset health}...]]
+func (c *Cluster) GetClusterShards() []interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var shards []interface{}
+	for _, node := range c.sortedNodes() {
+		if node.MasterID != "" && c.Nodes[node.MasterID] != nil {
+			continue // 从节点归入其主节点的分片
+		}
+		slots := []interface{}{}
+		for _, r := range c.slotRangesOf(node) {
+			slots = append(slots, int64(r.Start), int64(r.End))
+		}
+		nodes := []interface{}{shardNode(node, "master")}
+		for _, replica := range c.replicasOf(node) {
+			nodes = append(nodes, shardNode(replica, "replica"))
 		}
+		shards = append(shards, []interface{}{"slots", slots, "nodes", nodes})
 	}
+	return shards
+}
 
-	// 为每个节点合并连续的槽位
-	var allRanges []SlotRange
-	for _, slots := range nodeSlots {
-		ranges := mergeConsecutiveSlots(slots)
-		allRanges = append(allRanges, ranges...)
+// shardNode CLUSTER SHARDS 中的一个节点，字段与 Redis 7 相同
+func shardNode(node *Node, role string) []interface{} {
+	host, port, err := node.GetHostPort()
+	if err != nil {
+		host, port = node.Addr, "0"
+	}
+	portNum, _ := strconv.ParseInt(port, 10, 64)
+	health := "online"
+	if node.IsFailed() {
+		health = "fail"
+	}
+	return []interface{}{
+		"id", node.ID,
+		"port", portNum,
+		"ip", host,
+		"endpoint", host,
+		"role", role,
+		"replication-offset", int64(0),
+		"health", health,
 	}
+}
 
-	return allRanges
+// mergeSlotRanges 将已分配的槽位按槽位号顺序合并为属于同一节点的连续范围
+func (c *Cluster) mergeSlotRanges() []SlotRange {
+	var ranges []SlotRange
+	for i := uint32(0); i < SlotCount; i++ {
+		if c.Slots[i] == nil {
+			continue
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].End+1 == i && c.Slots[ranges[n-1].Start] == c.Slots[i] {
+			ranges[n-1].End = i
+		} else {
+			ranges = append(ranges, SlotRange{Start: i, End: i})
+		}
+	}
+	return ranges
 }
 
 // mergeConsecutiveSlots 合并连续的槽位
@@ -263,12 +406,16 @@ func mergeConsecutiveSlots(slots []uint32) []SlotRange {
 		return nil
 	}
 
-	// 排序槽位（这里假设已经排序，实际应该先排序）
+	slots = append([]uint32(nil), slots...)
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
 	ranges := []SlotRange{}
 	start := slots[0]
 	end := slots[0]
 
 	for i := 1; i < len(slots); i++ {
+		if slots[i] == end {
+			continue // 重复的槽位
+		}
 		if slots[i] == end+1 {
 			end = slots[i]
 		} else {
@@ -282,6 +429,21 @@ func mergeConsecutiveSlots(slots []uint32) []SlotRange {
 	return ranges
 }
 
+// SlotStats 已分配的槽位数、负责至少一个槽位的节点数与已知节点数（用于CLUSTER INFO）
+func (c *Cluster) SlotStats() (assigned int, size int, knownNodes int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	serving := make(map[*Node]bool)
+	for i := uint32(0); i < SlotCount; i++ {
+		if node := c.Slots[i]; node != nil {
+			assigned++
+			serving[node] = true
+		}
+	}
+	return assigned, len(serving), len(c.Nodes)
+}
+
 // GetMyself 获取当前节点
 func (c *Cluster) GetMyself() *Node {
 	c.mu.RLock()
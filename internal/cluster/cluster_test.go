@@ -1,6 +1,7 @@
 package cluster
 
 import (
+	"fmt"
 	"os"
 	"strings"
 	"testing"
@@ -203,3 +204,125 @@ func TestRedirectError(t *testing.T) {
 	_ = node // suppress unused variable warning
 }
 
+
+func TestCheckKeysRedirect(t *testing.T) {
+	cluster, cleanup := setupTestCluster(t)
+	defer cleanup()
+
+	nodeID, _ := generateNodeID()
+	cluster.AddNode(NewNode(nodeID, "127.0.0.1:6380"))
+
+	existing := map[string]bool{}
+	keyExists := func(key string) bool { return existing[key] }
+
+	// 所有槽位在本地
+	assert.NoError(t, cluster.CheckKeysRedirect([]string{"{t}a", "{t}b"}, false, false, keyExists))
+
+	// 跨槽位
+	err := cluster.CheckKeysRedirect([]string{"a", "b"}, false, false, keyExists)
+	assert.Equal(t, "CROSSSLOT Keys in request don't hash to the same slot", err.Error())
+
+	// 槽位属于其他节点
+	slot := Slot("{t}a")
+	assert.NoError(t, cluster.AssignSlot(slot, nodeID))
+	err = cluster.CheckKeysRedirect([]string{"{t}a"}, false, false, keyExists)
+	assert.Equal(t, fmt.Sprintf("MOVED %d 127.0.0.1:6380", slot), err.Error())
+	// READONLY 连接：本节点是槽所属主节点的从节点时只读命令在本地执行
+	assert.Error(t, cluster.CheckKeysRedirect([]string{"{t}a"}, false, true, keyExists))
+	flags := cluster.Myself.Flags
+	cluster.Myself.Flags, cluster.Myself.MasterID = []string{"slave", "myself"}, nodeID
+	assert.NoError(t, cluster.CheckKeysRedirect([]string{"{t}a"}, false, true, keyExists))
+	err = cluster.CheckKeysRedirect([]string{"{t}a"}, false, false, keyExists)
+	assert.Equal(t, fmt.Sprintf("MOVED %d 127.0.0.1:6380", slot), err.Error())
+	cluster.Myself.Flags, cluster.Myself.MasterID = flags, ""
+
+	// 导入中：只有带 ASKING 的请求在本地执行
+	cluster.SetSlotImporting(slot, nodeID)
+	err = cluster.CheckKeysRedirect([]string{"{t}a"}, false, false, keyExists)
+	assert.Equal(t, fmt.Sprintf("MOVED %d 127.0.0.1:6380", slot), err.Error())
+	assert.NoError(t, cluster.CheckKeysRedirect([]string{"{t}a"}, true, false, keyExists))
+	existing["{t}a"] = true
+	err = cluster.CheckKeysRedirect([]string{"{t}a", "{t}b"}, true, false, keyExists)
+	assert.Equal(t, "TRYAGAIN Multiple keys request during rehashing of slot", err.Error())
+	cluster.ClearSlotMigration(slot)
+
+	// 迁出中：键全部缺失返回 ASK，部分缺失返回 TRYAGAIN
+	assert.NoError(t, cluster.AssignSlot(slot, cluster.Myself.ID))
+	cluster.SetSlotMigrating(slot, nodeID)
+	assert.NoError(t, cluster.CheckKeysRedirect([]string{"{t}a"}, false, false, keyExists))
+	err = cluster.CheckKeysRedirect([]string{"{t}b"}, false, false, keyExists)
+	assert.Equal(t, fmt.Sprintf("ASK %d 127.0.0.1:6380", slot), err.Error())
+	err = cluster.CheckKeysRedirect([]string{"{t}a", "{t}b"}, false, false, keyExists)
+	assert.Equal(t, "TRYAGAIN Multiple keys request during rehashing of slot", err.Error())
+
+	// 槽位未分配
+	cluster.Slots[slot] = nil
+	err = cluster.CheckKeysRedirect([]string{"{t}a"}, false, false, keyExists)
+	assert.Equal(t, "CLUSTERDOWN Hash slot not served", err.Error())
+}
+
+func TestClusterTopology(t *testing.T) {
+	cluster, cleanup := setupTestCluster(t)
+	defer cleanup()
+	cmd := NewClusterCommands(cluster)
+	myID := cluster.Myself.ID
+
+	otherID := strings.Repeat("b", 40)
+	other := NewNode(otherID, "127.0.0.1:6380")
+	other.Flags = []string{"master"}
+	cluster.AddNode(other)
+	replica := NewNode(strings.Repeat("c", 40), "127.0.0.1:6381")
+	replica.Flags = []string{"slave"}
+	replica.MasterID = otherID
+	cluster.AddNode(replica)
+	assert.NoError(t, cluster.AssignSlotRange(100, 199, otherID))
+	assert.NoError(t, cluster.AssignSlot(16383, otherID))
+	assert.DeepEqual(t, []SlotRange{{0, 99}, {200, 16382}}, cluster.Myself.GetSlotRanges())
+
+	nodes, err := cmd.HandleCommand([]string{"NODES"})
+	assert.NoError(t, err)
+	lines := strings.Split(nodes.(string), "\n")
+	assert.Equal(t, 3, len(lines))
+	assert.Equal(t, myID+" 127.0.0.1:6379 myself,master - 0 0 0 connected 0-99 200-16382", lines[0])
+	assert.Equal(t, otherID+" 127.0.0.1:6380 master - 0 0 0 connected 100-199 16383", lines[1])
+	assert.Equal(t, replica.ID+" 127.0.0.1:6381 slave "+otherID+" 0 0 0 connected", lines[2])
+
+	slots, err := cmd.HandleCommand([]string{"SLOTS"})
+	assert.NoError(t, err)
+	assert.DeepEqual(t, [][]interface{}{
+		{int64(0), int64(99), []interface{}{"127.0.0.1", int64(6379), myID}},
+		{int64(100), int64(199), []interface{}{"127.0.0.1", int64(6380), otherID}, []interface{}{"127.0.0.1", int64(6381), replica.ID}},
+		{int64(200), int64(16382), []interface{}{"127.0.0.1", int64(6379), myID}},
+		{int64(16383), int64(16383), []interface{}{"127.0.0.1", int64(6380), otherID}, []interface{}{"127.0.0.1", int64(6381), replica.ID}},
+	}, slots)
+
+	shards, err := cmd.HandleCommand([]string{"SHARDS"})
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{
+		[]interface{}{"slots", []interface{}{int64(0), int64(99), int64(200), int64(16382)}, "nodes", []interface{}{
+			[]interface{}{"id", myID, "port", int64(6379), "ip", "127.0.0.1", "endpoint", "127.0.0.1", "role", "master", "replication-offset", int64(0), "health", "online"},
+		}},
+		[]interface{}{"slots", []interface{}{int64(100), int64(199), int64(16383), int64(16383)}, "nodes", []interface{}{
+			[]interface{}{"id", otherID, "port", int64(6380), "ip", "127.0.0.1", "endpoint", "127.0.0.1", "role", "master", "replication-offset", int64(0), "health", "online"},
+			[]interface{}{"id", replica.ID, "port", int64(6381), "ip", "127.0.0.1", "endpoint", "127.0.0.1", "role", "replica", "replication-offset", int64(0), "health", "online"},
+		}},
+	}, shards)
+
+	// 有未分配的槽位时集群状态为 fail
+	_, err = cmd.HandleCommand([]string{"DELSLOTS", "0"})
+	assert.NoError(t, err)
+	_, err = cmd.HandleCommand([]string{"DELSLOTS", "0"})
+	assert.Error(t, err)
+	info, err := cmd.HandleCommand([]string{"INFO"})
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(info.(string), "cluster_state:fail\ncluster_slots_assigned:16383\n"))
+	assert.True(t, strings.Contains(info.(string), "cluster_known_nodes:3\ncluster_size:2\n"))
+}
+
+func TestAnnounceAddr(t *testing.T) {
+	assert.Equal(t, "127.0.0.1:6379", announceAddr(":6379"))
+	assert.Equal(t, "127.0.0.1:6379", announceAddr("0.0.0.0:6379"))
+	assert.Equal(t, "127.0.0.1:6379", announceAddr("[::]:6379"))
+	assert.Equal(t, "10.0.0.5:7000", announceAddr("10.0.0.5:7000"))
+	assert.Equal(t, "", announceAddr(""))
+}
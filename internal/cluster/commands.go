@@ -20,7 +20,7 @@ func NewClusterCommands(cluster *Cluster) *ClusterCommands {
 // HandleCommand 处理CLUSTER命令
 func (cc *ClusterCommands) HandleCommand(args []string) (interface{}, error) {
 	if len(args) == 0 {
-		return nil, fmt.Errorf("ERR wrong number of arguments for 'CLUSTER' command")
+		return nil, fmt.Errorf("ERR wrong number of arguments for 'cluster' command")
 	}
 
 	subcommand := strings.ToUpper(args[0])
@@ -31,6 +31,8 @@ func (cc *ClusterCommands) HandleCommand(args []string) (interface{}, error) {
 		return cc.handleNodes(subArgs)
 	case "SLOTS":
 		return cc.handleSlots(subArgs)
+	case "SHARDS":
+		return cc.handleShards(subArgs)
 	case "INFO":
 		return cc.handleInfo(subArgs)
 	case "KEYSLOT":
@@ -84,32 +86,35 @@ func (cc *ClusterCommands) handleSlots(args []string) (interface{}, error) {
 	return slots, nil
 }
 
+// handleShards 处理CLUSTER SHARDS命令
+func (cc *ClusterCommands) handleShards(args []string) (interface{}, error) {
+	return cc.cluster.GetClusterShards(), nil
+}
+
 // handleInfo 处理CLUSTER INFO命令
 func (cc *ClusterCommands) handleInfo(args []string) (string, error) {
 	myself := cc.cluster.GetMyself()
 	epoch := cc.cluster.GetEpoch()
 
-	// 统计信息
-	totalNodes := len(cc.cluster.Nodes)
-	totalSlots := 0
-	for i := uint32(0); i < SlotCount; i++ {
-		if cc.cluster.Slots[i] == myself {
-			totalSlots++
-		}
+	// 统计信息：与 Redis 相同，所有槽位都已分配时集群状态才是 ok
+	assigned, size, knownNodes := cc.cluster.SlotStats()
+	state := "ok"
+	if assigned < SlotCount {
+		state = "fail"
 	}
 
-	info := fmt.Sprintf(`cluster_state:ok
+	info := fmt.Sprintf(`cluster_state:%s
 cluster_slots_assigned:%d
 cluster_slots_ok:%d
 cluster_slots_pfail:0
 cluster_slots_fail:0
 cluster_known_nodes:%d
-cluster_size:1
+cluster_size:%d
 cluster_current_epoch:%d
 cluster_my_epoch:%d
 cluster_stats_messages_sent:0
 cluster_stats_messages_received:0`,
-		totalSlots, totalSlots, totalNodes, epoch, myself.Epoch)
+		state, assigned, assigned, knownNodes, size, epoch, myself.Epoch)
 
 	return info, nil
 }
@@ -117,7 +122,7 @@ cluster_stats_messages_received:0`,
 // handleKeySlot 处理CLUSTER KEYSLOT命令
 func (cc *ClusterCommands) handleKeySlot(args []string) (int64, error) {
 	if len(args) < 1 {
-		return 0, fmt.Errorf("ERR wrong number of arguments for 'CLUSTER KEYSLOT' command")
+		return 0, fmt.Errorf("ERR wrong number of arguments for 'cluster|keyslot' command")
 	}
 	key := args[0]
 	slot := Slot(key)
@@ -127,7 +132,7 @@ func (cc *ClusterCommands) handleKeySlot(args []string) (int64, error) {
 // handleGetKeysInSlot 处理CLUSTER GETKEYSINSLOT命令
 func (cc *ClusterCommands) handleGetKeysInSlot(args []string) ([]string, error) {
 	if len(args) < 2 {
-		return nil, fmt.Errorf("ERR wrong number of arguments for 'CLUSTER GETKEYSINSLOT' command")
+		return nil, fmt.Errorf("ERR wrong number of arguments for 'cluster|getkeysinslot' command")
 	}
 
 	slot, err := strconv.ParseUint(args[0], 10, 32)
@@ -152,7 +157,7 @@ func (cc *ClusterCommands) handleGetKeysInSlot(args []string) ([]string, error)
 // handleSetSlot 处理CLUSTER SETSLOT命令
 func (cc *ClusterCommands) handleSetSlot(args []string) (string, error) {
 	if len(args) < 2 {
-		return "", fmt.Errorf("ERR wrong number of arguments for 'CLUSTER SETSLOT' command")
+		return "", fmt.Errorf("ERR wrong number of arguments for 'cluster|setslot' command")
 	}
 
 	slot, err := strconv.ParseUint(args[0], 10, 32)
@@ -165,16 +170,22 @@ func (cc *ClusterCommands) handleSetSlot(args []string) (string, error) {
 
 	switch subcommand {
 	case "IMPORTING":
-		// 导入槽位（迁移中）
+		// 导入槽位（迁移中），带 ASKING 的请求由本节点处理
+		if len(subArgs) < 1 {
+			return "", fmt.Errorf("ERR wrong number of arguments")
+		}
+		cc.cluster.SetSlotImporting(uint32(slot), subArgs[0])
 		return "OK", nil
 	case "MIGRATING":
-		// 迁移槽位（迁移中）
+		// 迁移槽位（迁移中），本地已不存在的键返回 ASK 重定向
 		if len(subArgs) < 1 {
 			return "", fmt.Errorf("ERR wrong number of arguments")
 		}
+		cc.cluster.SetSlotMigrating(uint32(slot), subArgs[0])
 		return "OK", nil
 	case "STABLE":
-		// 稳定状态
+		// 稳定状态，清除迁移/导入标记
+		cc.cluster.ClearSlotMigration(uint32(slot))
 		return "OK", nil
 	case "NODE":
 		// 设置槽位所属节点
@@ -186,6 +197,8 @@ func (cc *ClusterCommands) handleSetSlot(args []string) (string, error) {
 		if err != nil {
 			return "", err
 		}
+		// 槽位归属确定后迁移结束
+		cc.cluster.ClearSlotMigration(uint32(slot))
 		return "OK", nil
 	default:
 		return "", fmt.Errorf("ERR unknown subcommand '%s'", subcommand)
@@ -195,7 +208,7 @@ func (cc *ClusterCommands) handleSetSlot(args []string) (string, error) {
 // handleMeet 处理CLUSTER MEET命令
 func (cc *ClusterCommands) handleMeet(args []string) (string, error) {
 	if len(args) < 2 {
-		return "", fmt.Errorf("ERR wrong number of arguments for 'CLUSTER MEET' command")
+		return "", fmt.Errorf("ERR wrong number of arguments for 'cluster|meet' command")
 	}
 
 	ip := args[0]
@@ -221,7 +234,7 @@ func (cc *ClusterCommands) handleMeet(args []string) (string, error) {
 // handleForget 处理CLUSTER FORGET命令
 func (cc *ClusterCommands) handleForget(args []string) (string, error) {
 	if len(args) < 1 {
-		return "", fmt.Errorf("ERR wrong number of arguments for 'CLUSTER FORGET' command")
+		return "", fmt.Errorf("ERR wrong number of arguments for 'cluster|forget' command")
 	}
 
 	nodeID := args[0]
@@ -236,7 +249,7 @@ func (cc *ClusterCommands) handleForget(args []string) (string, error) {
 // handleReplicate 处理CLUSTER REPLICATE命令
 func (cc *ClusterCommands) handleReplicate(args []string) (string, error) {
 	if len(args) < 1 {
-		return "", fmt.Errorf("ERR wrong number of arguments for 'CLUSTER REPLICATE' command")
+		return "", fmt.Errorf("ERR wrong number of arguments for 'cluster|replicate' command")
 	}
 
 	masterID := args[0]
@@ -269,7 +282,7 @@ func (cc *ClusterCommands) handleSaveConfig(args []string) (string, error) {
 // handleAddSlots 处理CLUSTER ADDSLOTS命令
 func (cc *ClusterCommands) handleAddSlots(args []string) (string, error) {
 	if len(args) == 0 {
-		return "", fmt.Errorf("ERR wrong number of arguments for 'CLUSTER ADDSLOTS' command")
+		return "", fmt.Errorf("ERR wrong number of arguments for 'cluster|addslots' command")
 	}
 
 	for _, arg := range args {
@@ -300,7 +313,7 @@ func (cc *ClusterCommands) handleAddSlots(args []string) (string, error) {
 // handleDelSlots 处理CLUSTER DELSLOTS命令
 func (cc *ClusterCommands) handleDelSlots(args []string) (string, error) {
 	if len(args) == 0 {
-		return "", fmt.Errorf("ERR wrong number of arguments for 'CLUSTER DELSLOTS' command")
+		return "", fmt.Errorf("ERR wrong number of arguments for 'cluster|delslots' command")
 	}
 
 	for _, arg := range args {
@@ -313,8 +326,9 @@ func (cc *ClusterCommands) handleDelSlots(args []string) (string, error) {
 			return "", fmt.Errorf("ERR slot %d out of range", slot)
 		}
 
-		// 移除槽位分配（设置为nil或重新分配）
-		// 简化实现：不删除，只是标记
+		if err := cc.cluster.UnassignSlot(uint32(slot)); err != nil {
+			return "", err
+		}
 	}
 
 	return "OK", nil
@@ -337,7 +351,7 @@ func (cc *ClusterCommands) handleFlushSlots(args []string) (string, error) {
 // handleCountKeysInSlot 处理CLUSTER COUNTKEYSINSLOT命令
 func (cc *ClusterCommands) handleCountKeysInSlot(args []string) (int64, error) {
 	if len(args) < 1 {
-		return 0, fmt.Errorf("ERR wrong number of arguments for 'CLUSTER COUNTKEYSINSLOT' command")
+		return 0, fmt.Errorf("ERR wrong number of arguments for 'cluster|countkeysinslot' command")
 	}
 
 	slot, err := strconv.ParseUint(args[0], 10, 32)
@@ -367,7 +381,7 @@ func (cc *ClusterCommands) handleEpoch(args []string) (int64, error) {
 // handleSlaves 处理CLUSTER SLAVES命令
 func (cc *ClusterCommands) handleSlaves(args []string) ([]string, error) {
 	if len(args) < 1 {
-		return nil, fmt.Errorf("ERR wrong number of arguments for 'CLUSTER SLAVES' command")
+		return nil, fmt.Errorf("ERR wrong number of arguments for 'cluster|slaves' command")
 	}
 
 	nodeID := args[0]
@@ -476,7 +490,7 @@ func (cc *ClusterCommands) handleCalls(args []string) ([]interface{}, error) {
 // handleTotalKeys 处理CLUSTER TOTALKEYS命令
 func (cc *ClusterCommands) handleTotalKeys(args []string) (int64, error) {
 	if len(args) < 1 {
-		return 0, fmt.Errorf("ERR wrong number of arguments for 'CLUSTER TOTALKEYS' command")
+		return 0, fmt.Errorf("ERR wrong number of arguments for 'cluster|totalkeys' command")
 	}
 
 	slot, err := strconv.ParseUint(args[0], 10, 32)
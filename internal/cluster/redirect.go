@@ -1,9 +1,17 @@
 package cluster
 
 import (
+	"errors"
 	"fmt"
 )
 
+// 集群错误，字符串与 Redis 完全一致：客户端库按这些字符串解析并驱动重试/重定向
+var (
+	ErrCrossSlot          = errors.New("CROSSSLOT Keys in request don't hash to the same slot")
+	ErrTryAgain           = errors.New("TRYAGAIN Multiple keys request during rehashing of slot")
+	ErrClusterDownUnbound = errors.New("CLUSTERDOWN Hash slot not served")
+)
+
 // RedirectError 表示需要重定向的错误
 type RedirectError struct {
 	Type    string // "MOVED" 或 "ASK"
@@ -11,6 +19,7 @@ type RedirectError struct {
 	Address string
 }
 
+// Error 格式: "MOVED <slot> <host:port>" 或 "ASK <slot> <host:port>"
 func (e *RedirectError) Error() string {
 	return fmt.Sprintf("%s %d %s", e.Type, e.Slot, e.Address)
 }
@@ -51,6 +60,72 @@ func (c *Cluster) CheckSlotRedirect(key string) *RedirectError {
 	return nil
 }
 
+// CheckKeysRedirect 按 Redis 的规则检查一条命令涉及的所有键应由哪个节点处理。
+// 返回 nil 表示可以在当前节点执行；否则返回 *RedirectError（MOVED/ASK）、
+// ErrCrossSlot、ErrTryAgain 或 ErrClusterDownUnbound。
+// asking 表示客户端在本命令前发送了 ASKING；readFromReplica 表示连接执行过 READONLY 且命令只读，
+// 本节点是槽所属主节点的从节点时在本地执行；keyExists 用于迁移中的槽判断键是否仍在本地。
+func (c *Cluster) CheckKeysRedirect(keys []string, asking, readFromReplica bool, keyExists func(string) bool) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	slot := Slot(keys[0])
+	node := c.GetNodeBySlot(slot)
+	if node == nil {
+		return ErrClusterDownUnbound
+	}
+	multipleKeys := false
+	for _, key := range keys[1:] {
+		if Slot(key) != slot {
+			return ErrCrossSlot
+		}
+		if key != keys[0] {
+			multipleKeys = true
+		}
+	}
+
+	isMyself := node.ID == c.Myself.ID
+	migrating := isMyself && c.Myself.IsMigratingSlot(slot)
+	importing := c.Myself.IsImportingSlot(slot)
+
+	// 迁移/导入中的槽需要知道哪些键已经不在本地
+	missingKeys, existingKeys := 0, 0
+	if migrating || importing {
+		for _, key := range keys {
+			if keyExists(key) {
+				existingKeys++
+			} else {
+				missingKeys++
+			}
+		}
+	}
+
+	// 正在迁出且缺少键：全部缺失时 ASK 到目标节点，部分缺失时只能让客户端稍后重试
+	if migrating && missingKeys > 0 {
+		if existingKeys > 0 {
+			return ErrTryAgain
+		}
+		return NewAskError(slot, c.Myself.GetMigratingSlotTarget(slot))
+	}
+
+	// 正在导入且客户端带有 ASKING：多键请求必须所有键都已迁入
+	if importing && asking {
+		if multipleKeys && missingKeys > 0 {
+			return ErrTryAgain
+		}
+		return nil
+	}
+
+	if !isMyself {
+		if readFromReplica && c.Myself.IsSlave() && c.Myself.MasterID == node.ID {
+			return nil
+		}
+		return NewMovedError(slot, node.Addr)
+	}
+	return nil
+}
+
 // GetRedirectAddress 获取重定向地址
 func (c *Cluster) GetRedirectAddress(slot uint32) (string, error) {
 	node := c.GetNodeBySlot(slot)
@@ -0,0 +1,356 @@
+// Package config boltreon.conf 配置文件：与 redis.conf 相同的"参数名 值..."格式，
+// # 开头的行为注释，值可以用双引号或单引号括起。CONFIG REWRITE 原地更新参数所在的行，
+// 保留注释与其余内容，文件中没有的参数追加到末尾
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// rewriteMarker CONFIG REWRITE 追加的参数写在这一行之后
+const rewriteMarker = "# Generated by CONFIG REWRITE"
+
+// Directive 配置文件中的一条参数
+type Directive struct {
+	Name string   // 小写的参数名
+	Args []string // 参数值，大多数参数只有一个
+	Line int      // 所在行号，从 1 开始
+}
+
+// Value 用 Args 的空格拼接作为参数值，与 CONFIG SET 接受的格式相同
+func (d Directive) Value() string {
+	return strings.Join(d.Args, " ")
+}
+
+// Setting CONFIG REWRITE 写入的参数
+type Setting struct {
+	Name  string
+	Value string
+	// Default 为 true 表示当前值为默认值：文件中已有该参数时仍更新，没有时不追加
+	Default bool
+}
+
+// File 已加载的配置文件
+type File struct {
+	mu         sync.Mutex
+	path       string
+	directives []Directive
+}
+
+// Load 读取并解析配置文件
+func Load(filename string) (*File, error) {
+	data, err := os.ReadFile(filename) // #nosec G304 - 配置文件路径由启动参数指定
+	if err != nil {
+		return nil, err
+	}
+	directives, err := parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return &File{path: filename, directives: directives}, nil
+}
+
+// Path 配置文件路径
+func (f *File) Path() string {
+	return f.path
+}
+
+// Directives 加载时文件中的参数，按出现顺序排列
+func (f *File) Directives() []Directive {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Directive(nil), f.directives...)
+}
+
+// parse 解析配置文件内容
+func parse(data string) ([]Directive, error) {
+	var directives []Directive
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		name, args, err := parseLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if name == "" {
+			continue
+		}
+		directives = append(directives, Directive{Name: name, Args: args, Line: n})
+	}
+	return directives, scanner.Err()
+}
+
+// parseLine 解析一行，空行与注释返回空的参数名
+func parseLine(line string) (string, []string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return "", nil, nil
+	}
+	fields, err := SplitArgs(line)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(fields) == 0 {
+		return "", nil, nil
+	}
+	return strings.ToLower(fields[0]), fields[1:], nil
+}
+
+// Rewrite 把 settings 写回配置文件：已有的参数更新第一次出现的行并删除重复的行，
+// 其余非默认值的参数追加到末尾。先写入临时文件再替换，写入中途失败不会损坏原文件
+func (f *File) Rewrite(settings []Setting) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := os.ReadFile(f.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	byName := make(map[string]Setting, len(settings))
+	for _, s := range settings {
+		byName[strings.ToLower(s.Name)] = s
+	}
+
+	var lines []string
+	written := make(map[string]bool)
+	hasMarker := false
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+	out := make([]string, 0, len(lines)+len(settings))
+	for _, line := range lines {
+		if strings.TrimSpace(line) == rewriteMarker {
+			hasMarker = true
+		}
+		name, _, err := parseLine(line)
+		s, ok := byName[name]
+		if err != nil || name == "" || !ok {
+			out = append(out, line)
+			continue
+		}
+		if !written[name] {
+			out = append(out, s.Name+" "+Quote(s.Value))
+			written[name] = true
+		}
+	}
+	for _, s := range settings {
+		if written[strings.ToLower(s.Name)] || s.Default {
+			continue
+		}
+		if !hasMarker {
+			out = append(out, rewriteMarker)
+			hasMarker = true
+		}
+		out = append(out, s.Name+" "+Quote(s.Value))
+		written[strings.ToLower(s.Name)] = true
+	}
+
+	if err := writeFileAtomic(f.path, []byte(strings.Join(out, "\n")+"\n")); err != nil {
+		return err
+	}
+	directives, err := parse(strings.Join(out, "\n"))
+	if err != nil {
+		return err
+	}
+	f.directives = directives
+	return nil
+}
+
+// writeFileAtomic 写入同目录下的临时文件，同步后重命名为 filename
+func writeFileAtomic(filename string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// SplitArgs 按空白拆分一行参数。双引号内支持 \n、\r、\t、\b、\a、\\、\" 与 \xHH 转义，
+// 单引号内只支持 \'，引号结束后必须是空白或行尾。与 Redis 的 sdssplitargs 相同
+func SplitArgs(line string) ([]string, error) {
+	var args []string
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i >= len(line) {
+			return args, nil
+		}
+		var b strings.Builder
+		switch line[i] {
+		case '"':
+			i++
+			for {
+				if i >= len(line) {
+					return nil, errors.New("unbalanced quotes")
+				}
+				c := line[i]
+				if c == '"' {
+					i++
+					break
+				}
+				if c == '\\' && i+1 < len(line) {
+					if line[i+1] == 'x' && i+3 < len(line) && isHex(line[i+2]) && isHex(line[i+3]) {
+						v, _ := strconv.ParseUint(line[i+2:i+4], 16, 8)
+						b.WriteByte(byte(v))
+						i += 4
+						continue
+					}
+					switch line[i+1] {
+					case 'n':
+						c = '\n'
+					case 'r':
+						c = '\r'
+					case 't':
+						c = '\t'
+					case 'b':
+						c = '\b'
+					case 'a':
+						c = '\a'
+					default:
+						c = line[i+1]
+					}
+					b.WriteByte(c)
+					i += 2
+					continue
+				}
+				b.WriteByte(c)
+				i++
+			}
+			if i < len(line) && !isSpace(line[i]) {
+				return nil, errors.New("closing quote must be followed by a space")
+			}
+		case '\'':
+			i++
+			for {
+				if i >= len(line) {
+					return nil, errors.New("unbalanced quotes")
+				}
+				c := line[i]
+				if c == '\'' {
+					i++
+					break
+				}
+				if c == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					c = '\''
+					i++
+				}
+				b.WriteByte(c)
+				i++
+			}
+			if i < len(line) && !isSpace(line[i]) {
+				return nil, errors.New("closing quote must be followed by a space")
+			}
+		default:
+			for i < len(line) && !isSpace(line[i]) {
+				b.WriteByte(line[i])
+				i++
+			}
+		}
+		args = append(args, b.String())
+	}
+}
+
+// Quote 在值为空或含空白、引号、控制字符时加上双引号，使 SplitArgs 能还原出相同的值
+func Quote(value string) string {
+	needQuote := value == ""
+	for i := 0; i < len(value) && !needQuote; i++ {
+		c := value[i]
+		needQuote = isSpace(c) || c == '"' || c == '\'' || c == '\\' || c < ' ' || c == 0x7f
+	}
+	if !needQuote {
+		return value
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if c < ' ' || c == 0x7f {
+				fmt.Fprintf(&b, `\x%02x`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// Match 参数名是否匹配 CONFIG GET 的 glob 模式（不区分大小写）
+func Match(pattern, name string) bool {
+	ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(name))
+	return err == nil && ok
+}
+
+// ParseMemory 解析 maxmemory 等内存大小：纯数字为字节数，可带 k/kb/m/mb/g/gb 后缀（不区分大小写），
+// k/m/g 为 1000 的倍数，kb/mb/gb 为 1024 的倍数，与 redis.conf 相同
+func ParseMemory(value string) (int64, error) {
+	s := strings.ToLower(strings.TrimSpace(value))
+	units := []struct {
+		suffix string
+		mul    int64
+	}{
+		{"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10},
+		{"g", 1000 * 1000 * 1000}, {"m", 1000 * 1000}, {"k", 1000}, {"b", 1},
+	}
+	mul := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s, mul = strings.TrimSuffix(s, u.suffix), u.mul
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 || n > (1<<62)/mul {
+		return 0, fmt.Errorf("invalid memory size '%s'", value)
+	}
+	return n * mul, nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestSplitArgs(t *testing.T) {
+	args, err := SplitArgs(`notify-keyspace-events "Ex"  'it\'s' "a\tb\x41" plain`)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"notify-keyspace-events", "Ex", "it's", "a\tbA", "plain"}, args)
+
+	args, err = SplitArgs(`save ""`)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"save", ""}, args)
+
+	_, err = SplitArgs(`name "unbalanced`)
+	assert.Error(t, err)
+	_, err = SplitArgs(`name "a"b`)
+	assert.Error(t, err)
+
+	for _, v := range []string{"", "simple", "two words", `quote"and\slash`, "line\nbreak\x01"} {
+		args, err := SplitArgs("name " + Quote(v))
+		assert.NoError(t, err)
+		assert.DeepEqual(t, []string{"name", v}, args)
+	}
+}
+
+func TestParseMemory(t *testing.T) {
+	for in, want := range map[string]int64{
+		"0": 0, "100": 100, "1k": 1000, "1kb": 1024, "2MB": 2 << 20, "1g": 1000000000, "3gb": 3 << 30,
+	} {
+		n, err := ParseMemory(in)
+		assert.NoError(t, err)
+		assert.Equal(t, want, n)
+	}
+	for _, in := range []string{"", "-1", "1x", "kb"} {
+		_, err := ParseMemory(in)
+		assert.Error(t, err)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	assert.True(t, Match("*", "maxmemory"))
+	assert.True(t, Match("max*", "maxmemory-policy"))
+	assert.True(t, Match("MAXCLIENTS", "maxclients"))
+	assert.True(t, Match("slowlog-*-len", "slowlog-max-len"))
+	assert.False(t, Match("max*", "loglevel"))
+}
+
+func TestLoadAndRewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "boltreon.conf")
+	content := "# 内存上限\nmaxmemory 100mb\n\nloglevel notice\nMAXMEMORY 1gb\ndir /data\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	f, err := Load(path)
+	assert.NoError(t, err)
+	directives := f.Directives()
+	assert.Equal(t, 4, len(directives))
+	assert.Equal(t, "maxmemory", directives[2].Name)
+	assert.Equal(t, "1gb", directives[2].Value())
+	assert.Equal(t, 5, directives[2].Line)
+
+	// 已有的参数原地更新并删除重复的行，非默认值追加到末尾，默认值不追加
+	err = f.Rewrite([]Setting{
+		{Name: "maxmemory", Value: "2000"},
+		{Name: "loglevel", Value: "warning", Default: true},
+		{Name: "notify-keyspace-events", Value: "Ex"},
+		{Name: "slowlog-max-len", Value: "128", Default: true},
+		{Name: "save", Value: ""},
+	})
+	assert.NoError(t, err)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "# 内存上限\nmaxmemory 2000\n\nloglevel warning\ndir /data\n"+
+		rewriteMarker+"\nnotify-keyspace-events Ex\nsave \"\"\n", string(data))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// 再次改写时复用已有的标记行
+	assert.NoError(t, f.Rewrite([]Setting{{Name: "notify-keyspace-events", Value: "KA"}, {Name: "maxclients", Value: "50"}}))
+	data, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "# 内存上限\nmaxmemory 2000\n\nloglevel warning\ndir /data\n"+
+		rewriteMarker+"\nnotify-keyspace-events KA\nsave \"\"\nmaxclients 50\n", string(data))
+	assert.Equal(t, 6, len(f.Directives()))
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.conf"))
+	assert.Error(t, err)
+	assert.NoError(t, os.WriteFile(path, []byte("name \"oops\n"), 0o600))
+	_, err = Load(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,210 @@
+// Package fixtures 读写命令语料（*.cmds）与黄金文件（*.golden）
+//
+// 语料文件每行一条命令，参数以空格分隔，包含空格或特殊字符的参数用 Go 风格的
+// 双引号字符串书写；空行和以 # 开头的行会被忽略。
+//
+// 黄金文件由 cmd/gen-fixtures 对照真实 Redis 生成，每条命令占两行：
+//
+//	> SET k v
+//	"+OK\r\n"
+//
+// 第一行是命令，第二行是 Redis 返回的原始 RESP 字节（strconv.Quote 编码）。
+// handler 测试逐条执行命令并按字节比对响应。
+package fixtures
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Case 一条命令及其期望的原始响应
+type Case struct {
+	Line  int      // 在黄金文件中的行号，用于报告差异
+	Args  []string // 命令参数（含命令名）
+	Reply []byte   // 期望的原始 RESP 响应
+}
+
+// ParseCommandLine 解析一行命令，支持双引号包裹的参数（Go 字符串转义规则）
+func ParseCommandLine(line string) ([]string, error) {
+	var args []string
+	rest := strings.TrimSpace(line)
+	for rest != "" {
+		if rest[0] == '"' {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted argument: %s", rest)
+			}
+			arg, err := strconv.Unquote(quoted)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			rest = rest[len(quoted):]
+			if rest != "" && rest[0] != ' ' {
+				return nil, fmt.Errorf("missing space after quoted argument: %s", line)
+			}
+		} else {
+			end := strings.IndexByte(rest, ' ')
+			if end < 0 {
+				end = len(rest)
+			}
+			args = append(args, rest[:end])
+			rest = rest[end:]
+		}
+		rest = strings.TrimLeft(rest, " ")
+	}
+	return args, nil
+}
+
+// FormatCommandLine 将命令参数格式化为一行，必要时加引号
+func FormatCommandLine(args []string) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \"\\") || !strconv.CanBackquote(arg) {
+			parts[i] = strconv.Quote(arg)
+		} else {
+			parts[i] = arg
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ReadCorpus 读取语料文件中的全部命令
+func ReadCorpus(r io.Reader) ([][]string, error) {
+	var cmds [][]string
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		args, err := ParseCommandLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		cmds = append(cmds, args)
+	}
+	return cmds, scanner.Err()
+}
+
+// ReadGolden 读取黄金文件
+func ReadGolden(r io.Reader) ([]Case, error) {
+	var cases []Case
+	var pending *Case
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		switch {
+		case strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "> "):
+			if pending != nil {
+				return nil, fmt.Errorf("line %d: command without reply", pending.Line)
+			}
+			args, err := ParseCommandLine(line[2:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			pending = &Case{Line: lineNo, Args: args}
+		default:
+			if pending == nil {
+				return nil, fmt.Errorf("line %d: reply without command", lineNo)
+			}
+			reply, err := strconv.Unquote(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid reply: %w", lineNo, err)
+			}
+			pending.Reply = []byte(reply)
+			cases = append(cases, *pending)
+			pending = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		return nil, fmt.Errorf("line %d: command without reply", pending.Line)
+	}
+	return cases, nil
+}
+
+// WriteGolden 写出黄金文件，header 的每一行会以 # 注释的形式写在文件开头
+func WriteGolden(w io.Writer, header string, cases []Case) error {
+	bw := bufio.NewWriter(w)
+	for _, line := range strings.Split(strings.TrimRight(header, "\n"), "\n") {
+		if _, err := fmt.Fprintf(bw, "# %s\n", line); err != nil {
+			return err
+		}
+	}
+	for _, c := range cases {
+		if _, err := fmt.Fprintf(bw, "\n> %s\n%s\n", FormatCommandLine(c.Args), strconv.Quote(string(c.Reply))); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadRawReply 从连接读取一条完整的 RESP 响应，返回未经解析的原始字节
+// 支持 RESP2 的全部类型，以及 RESP3 的聚合和单行类型
+func ReadRawReply(r *bufio.Reader) ([]byte, error) {
+	var buf []byte
+	if err := readRawReply(r, &buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func readRawReply(r *bufio.Reader, buf *[]byte) error {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return fmt.Errorf("malformed reply line: %q", line)
+	}
+	*buf = append(*buf, line...)
+	header := string(line[1 : len(line)-2])
+
+	switch line[0] {
+	case '+', '-', ':', '_', '#', ',', '(':
+		return nil
+	case '$', '=', '!':
+		n, err := strconv.Atoi(header)
+		if err != nil {
+			return fmt.Errorf("invalid bulk length: %q", header)
+		}
+		if n < 0 {
+			return nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		*buf = append(*buf, data...)
+		return nil
+	case '*', '~', '>', '%', '|':
+		n, err := strconv.Atoi(header)
+		if err != nil {
+			return fmt.Errorf("invalid aggregate length: %q", header)
+		}
+		if line[0] == '%' || line[0] == '|' {
+			n *= 2
+		}
+		for i := 0; i < n; i++ {
+			if err := readRawReply(r, buf); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown reply type: %q", line[0])
+	}
+}
@@ -0,0 +1,59 @@
+package fixtures
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestParseCommandLine(t *testing.T) {
+	args, err := ParseCommandLine(`SET  key "hello world" "a\"b" ""`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"SET", "key", "hello world", `a"b`, ""}, args)
+
+	_, err = ParseCommandLine(`SET "unterminated`)
+	assert.Error(t, err)
+
+	// 格式化后可以原样解析回来
+	line := FormatCommandLine(args)
+	again, err := ParseCommandLine(line)
+	assert.NoError(t, err)
+	assert.Equal(t, args, again)
+}
+
+func TestGoldenRoundTrip(t *testing.T) {
+	cases := []Case{
+		{Args: []string{"SET", "k", "v"}, Reply: []byte("+OK\r\n")},
+		{Args: []string{"LRANGE", "l", "0", "-1"}, Reply: []byte("*2\r\n$1\r\na\r\n$-1\r\n")},
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, WriteGolden(&buf, "header line 1\nheader line 2", cases))
+
+	got, err := ReadGolden(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, len(cases), len(got))
+	for i := range cases {
+		assert.Equal(t, cases[i].Args, got[i].Args)
+		assert.Equal(t, string(cases[i].Reply), string(got[i].Reply))
+	}
+}
+
+func TestReadRawReply(t *testing.T) {
+	stream := "*3\r\n$1\r\na\r\n*2\r\n:1\r\n$-1\r\n-ERR x\r\n%1\r\n+k\r\n:2\r\n+PONG\r\n"
+	r := bufio.NewReader(strings.NewReader(stream))
+
+	reply, err := ReadRawReply(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "*3\r\n$1\r\na\r\n*2\r\n:1\r\n$-1\r\n-ERR x\r\n", string(reply))
+
+	reply, err = ReadRawReply(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "%1\r\n+k\r\n:2\r\n", string(reply))
+
+	reply, err = ReadRawReply(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "+PONG\r\n", string(reply))
+}
@@ -0,0 +1,248 @@
+// Package metrics 服务器运行统计：连接数、命令执行次数与耗时、每秒操作数、键空间命中率，
+// 以及上次保存以来的写命令数，供 INFO 的 server、clients、stats、persistence、commandstats 等部分使用
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// opsSamples 计算每秒操作数的样本数，与 Redis 相同：按默认 100ms 的采样间隔为最近 1.6 秒的平均值
+	opsSamples = 16
+)
+
+// CommandStats 单个命令的统计
+type CommandStats struct {
+	Name          string // 小写的命令名
+	Calls         int64  // 执行次数（包括执行失败，不包括被拒绝）
+	Usec          int64  // 执行耗时合计（微秒）
+	RejectedCalls int64  // 执行前被拒绝的次数（参数个数错误等）
+	FailedCalls   int64  // 执行后返回错误的次数
+}
+
+// commandCounters CommandStats 的并发计数
+type commandCounters struct {
+	calls    atomic.Int64
+	usec     atomic.Int64
+	rejected atomic.Int64
+	failed   atomic.Int64
+}
+
+// Snapshot 某一时刻的统计
+type Snapshot struct {
+	StartTime         time.Time
+	ConnectedClients  int64
+	TotalConnections  int64
+	RejectedConns     int64 // 因超过 maxclients 被拒绝的连接数
+	TotalCommands     int64
+	OpsPerSec         int64
+	KeyspaceHits      int64
+	KeyspaceMisses    int64
+	ChangesSinceSave  int64
+	StatResetUnixTime int64 // 最近一次 CONFIG RESETSTAT 的时间，0 表示从未重置
+}
+
+// opsSample 采样时刻的累计命令数
+type opsSample struct {
+	at       time.Time
+	commands int64
+}
+
+// Stats 服务器运行统计。零值可用，所有方法可并发调用
+type Stats struct {
+	startTime atomic.Int64 // Unix 纳秒，0 表示尚未开始
+
+	connected        atomic.Int64
+	totalConnections atomic.Int64
+	rejectedConns    atomic.Int64
+	totalCommands    atomic.Int64
+	hits             atomic.Int64
+	misses           atomic.Int64
+	dirty            atomic.Int64
+	resetTime        atomic.Int64
+
+	mu       sync.RWMutex
+	commands map[string]*commandCounters
+
+	opsMu      sync.Mutex
+	opsHistory [opsSamples]float64
+	opsNext    int
+	lastSample opsSample
+}
+
+// Start 记录服务器启动时间，只有第一次调用生效
+func (s *Stats) Start(now time.Time) {
+	s.startTime.CompareAndSwap(0, now.UnixNano())
+}
+
+// StartTime 服务器启动时间，尚未调用 Start 时为第一次读取统计的时间
+func (s *Stats) StartTime() time.Time {
+	s.Start(time.Now())
+	return time.Unix(0, s.startTime.Load())
+}
+
+// ConnectionOpened 接受了一个新连接
+func (s *Stats) ConnectionOpened() {
+	s.connected.Add(1)
+	s.totalConnections.Add(1)
+}
+
+// ConnectionClosed 一个连接已关闭
+func (s *Stats) ConnectionClosed() {
+	s.connected.Add(-1)
+}
+
+// ConnectionRejected 拒绝了一个新连接（超过 maxclients）
+func (s *Stats) ConnectionRejected() {
+	s.rejectedConns.Add(1)
+}
+
+// ConnectedClients 当前连接数
+func (s *Stats) ConnectedClients() int64 {
+	return s.connected.Load()
+}
+
+// RecordCommand 记录一次命令执行。name 为小写的命令名，failed 表示执行后返回了错误
+func (s *Stats) RecordCommand(name string, d time.Duration, failed bool) {
+	s.totalCommands.Add(1)
+	c := s.command(name)
+	c.calls.Add(1)
+	c.usec.Add(d.Microseconds())
+	if failed {
+		c.failed.Add(1)
+	}
+}
+
+// RecordRejected 记录一次执行前被拒绝的命令（参数个数错误、加载中等），不计入执行次数
+func (s *Stats) RecordRejected(name string) {
+	s.totalCommands.Add(1)
+	s.command(name).rejected.Add(1)
+}
+
+func (s *Stats) command(name string) *commandCounters {
+	s.mu.RLock()
+	c, ok := s.commands[name]
+	s.mu.RUnlock()
+	if ok {
+		return c
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.commands == nil {
+		s.commands = make(map[string]*commandCounters)
+	}
+	if c, ok = s.commands[name]; !ok {
+		c = &commandCounters{}
+		s.commands[name] = c
+	}
+	return c
+}
+
+// KeyspaceLookup 记录读命令查找键的结果
+func (s *Stats) KeyspaceLookup(hits, misses int64) {
+	if hits > 0 {
+		s.hits.Add(hits)
+	}
+	if misses > 0 {
+		s.misses.Add(misses)
+	}
+}
+
+// AddChanges 记录成功执行的写命令
+func (s *Stats) AddChanges(n int64) {
+	s.dirty.Add(n)
+}
+
+// Changes 上次保存以来的写命令数
+func (s *Stats) Changes() int64 {
+	return s.dirty.Load()
+}
+
+// Saved 保存成功：扣除保存开始时读取的 Changes，保存期间的写命令仍计入
+func (s *Stats) Saved(changesAtStart int64) {
+	s.dirty.Add(-changesAtStart)
+}
+
+// Sample 记录一个每秒操作数的样本，应按固定间隔调用
+func (s *Stats) Sample(now time.Time) {
+	commands := s.totalCommands.Load()
+	s.opsMu.Lock()
+	defer s.opsMu.Unlock()
+	last := s.lastSample
+	s.lastSample = opsSample{at: now, commands: commands}
+	if last.at.IsZero() {
+		return
+	}
+	elapsed := now.Sub(last.at)
+	if elapsed <= 0 {
+		return
+	}
+	s.opsHistory[s.opsNext] = float64(commands-last.commands) / elapsed.Seconds()
+	s.opsNext = (s.opsNext + 1) % opsSamples
+}
+
+// opsPerSec 最近 opsSamples 个样本的平均每秒操作数
+func (s *Stats) opsPerSec() int64 {
+	s.opsMu.Lock()
+	defer s.opsMu.Unlock()
+	var sum float64
+	for _, v := range s.opsHistory {
+		sum += v
+	}
+	return int64(sum/opsSamples + 0.5)
+}
+
+// Snapshot 返回当前的统计
+func (s *Stats) Snapshot() Snapshot {
+	return Snapshot{
+		StartTime:         s.StartTime(),
+		ConnectedClients:  s.connected.Load(),
+		TotalConnections:  s.totalConnections.Load(),
+		RejectedConns:     s.rejectedConns.Load(),
+		TotalCommands:     s.totalCommands.Load(),
+		OpsPerSec:         s.opsPerSec(),
+		KeyspaceHits:      s.hits.Load(),
+		KeyspaceMisses:    s.misses.Load(),
+		ChangesSinceSave:  s.dirty.Load(),
+		StatResetUnixTime: s.resetTime.Load(),
+	}
+}
+
+// CommandStats 返回执行过的命令的统计，按命令名排序
+func (s *Stats) CommandStats() []CommandStats {
+	s.mu.RLock()
+	list := make([]CommandStats, 0, len(s.commands))
+	for name, c := range s.commands {
+		list = append(list, CommandStats{
+			Name:          name,
+			Calls:         c.calls.Load(),
+			Usec:          c.usec.Load(),
+			RejectedCalls: c.rejected.Load(),
+			FailedCalls:   c.failed.Load(),
+		})
+	}
+	s.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Reset 清空命令统计、命中率与累计连接数（CONFIG RESETSTAT）。
+// 当前连接数、启动时间与上次保存以来的写命令数不受影响
+func (s *Stats) Reset(now time.Time) {
+	s.mu.Lock()
+	s.commands = nil
+	s.mu.Unlock()
+	s.totalCommands.Store(0)
+	s.totalConnections.Store(0)
+	s.rejectedConns.Store(0)
+	s.hits.Store(0)
+	s.misses.Store(0)
+	s.opsMu.Lock()
+	s.opsHistory = [opsSamples]float64{}
+	s.lastSample = opsSample{}
+	s.opsMu.Unlock()
+	s.resetTime.Store(now.Unix())
+}
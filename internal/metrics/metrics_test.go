@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestStats(t *testing.T) {
+	var s Stats
+	start := time.Unix(1700000000, 0)
+	s.Start(start)
+	s.Start(start.Add(time.Hour))
+	assert.Equal(t, start, s.StartTime())
+
+	s.ConnectionOpened()
+	s.ConnectionOpened()
+	s.ConnectionClosed()
+	s.RecordCommand("get", 3*time.Microsecond, false)
+	s.RecordCommand("get", 5*time.Microsecond, true)
+	s.RecordRejected("set")
+	s.KeyspaceLookup(2, 1)
+
+	snap := s.Snapshot()
+	assert.Equal(t, int64(1), snap.ConnectedClients)
+	assert.Equal(t, int64(2), snap.TotalConnections)
+	assert.Equal(t, int64(3), snap.TotalCommands)
+	assert.Equal(t, int64(2), snap.KeyspaceHits)
+	assert.Equal(t, int64(1), snap.KeyspaceMisses)
+	assert.DeepEqual(t, []CommandStats{
+		{Name: "get", Calls: 2, Usec: 8, FailedCalls: 1},
+		{Name: "set", RejectedCalls: 1},
+	}, s.CommandStats())
+
+	// 保存期间的写命令仍计入
+	s.AddChanges(3)
+	changes := s.Changes()
+	s.AddChanges(2)
+	s.Saved(changes)
+	assert.Equal(t, int64(2), s.Changes())
+
+	// 每秒操作数为最近 opsSamples 个样本的平均值
+	now := time.Unix(1700000100, 0)
+	s.Sample(now)
+	for i := 0; i < opsSamples; i++ {
+		for j := 0; j < 10; j++ {
+			s.RecordCommand("ping", 0, false)
+		}
+		now = now.Add(100 * time.Millisecond)
+		s.Sample(now)
+	}
+	assert.Equal(t, int64(100), s.Snapshot().OpsPerSec)
+
+	s.Reset(now)
+	snap = s.Snapshot()
+	assert.Equal(t, int64(0), snap.TotalCommands)
+	assert.Equal(t, int64(0), snap.OpsPerSec)
+	assert.Equal(t, int64(1), snap.ConnectedClients)
+	assert.Equal(t, int64(2), snap.ChangesSinceSave)
+	assert.Equal(t, now.Unix(), snap.StatResetUnixTime)
+	assert.Equal(t, 0, len(s.CommandStats()))
+}
@@ -0,0 +1,321 @@
+// Package mirror 将写命令异步转发到上游 Redis：迁移期间 Boltreon 作为主库，
+// 旧的 Redis 仍然收到全部写入，随时可以切回
+package mirror
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+const (
+	// DefaultQueueSize 等待转发的写命令队列的默认长度，队列满时新的写命令被丢弃
+	DefaultQueueSize = 10000
+
+	// pipelineSize 一次写出的最多命令数，写出后再依次读取回复
+	pipelineSize = 128
+	dialTimeout  = 5 * time.Second
+	ioTimeout    = 5 * time.Second
+	minBackoff   = 100 * time.Millisecond
+	maxBackoff   = 5 * time.Second
+)
+
+// Options 镜像参数，零值使用默认值
+type Options struct {
+	QueueSize int    // 队列长度
+	Password  string // 上游需要认证时使用的密码（AUTH）
+}
+
+// Stats 镜像状态与计数
+type Stats struct {
+	Upstream    string
+	Paused      bool
+	Connected   bool
+	QueueLen    int
+	QueueCap    int
+	Forwarded   int64     // 上游成功执行的命令数
+	Failed      int64     // 上游返回错误或因连接失败没有送达的命令数
+	Dropped     int64     // 队列满时丢弃的命令数
+	Skipped     int64     // 暂停期间没有转发的命令数
+	LastError   string    // 最近一次失败的原因
+	LastErrorAt time.Time // 最近一次失败的时间
+}
+
+// Mirror 到一个上游 Redis 的异步转发。Forward 不阻塞调用方：命令进入有界队列，
+// 由后台 goroutine 按顺序以流水线方式发送；连接断开时自动重连，期间出队的命令计入失败
+type Mirror struct {
+	addr  string
+	opts  Options
+	queue chan [][]byte
+
+	paused    atomic.Bool
+	connected atomic.Bool
+	forwarded atomic.Int64
+	failed    atomic.Int64
+	dropped   atomic.Int64
+	skipped   atomic.Int64
+
+	mu          sync.Mutex
+	lastError   string
+	lastErrorAt time.Time
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New 创建到 addr 的镜像并在后台开始转发
+func New(addr string, opts Options) *Mirror {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	m := &Mirror{
+		addr:  addr,
+		opts:  opts,
+		queue: make(chan [][]byte, opts.QueueSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go m.run()
+	return m
+}
+
+// Forward 将写命令加入转发队列（复制参数，调用方可以继续使用 args）。
+// 暂停时计入 Skipped，队列满时计入 Dropped，两者都不会转发
+func (m *Mirror) Forward(args [][]byte) {
+	if m.paused.Load() {
+		m.skipped.Add(1)
+		return
+	}
+	cmd := make([][]byte, len(args))
+	for i, a := range args {
+		cmd[i] = append([]byte(nil), a...)
+	}
+	select {
+	case m.queue <- cmd:
+	default:
+		m.dropped.Add(1)
+	}
+}
+
+// Pause 暂停转发：之后的写命令不再进入队列，已在队列中的命令仍会发送
+func (m *Mirror) Pause() {
+	m.paused.Store(true)
+}
+
+// Resume 恢复转发。暂停期间的写入不会补发，上游与 Boltreon 可能已不一致
+func (m *Mirror) Resume() {
+	m.paused.Store(false)
+}
+
+// Stats 返回当前状态与计数
+func (m *Mirror) Stats() Stats {
+	m.mu.Lock()
+	lastError, lastErrorAt := m.lastError, m.lastErrorAt
+	m.mu.Unlock()
+	return Stats{
+		Upstream:    m.addr,
+		Paused:      m.paused.Load(),
+		Connected:   m.connected.Load(),
+		QueueLen:    len(m.queue),
+		QueueCap:    cap(m.queue),
+		Forwarded:   m.forwarded.Load(),
+		Failed:      m.failed.Load(),
+		Dropped:     m.dropped.Load(),
+		Skipped:     m.skipped.Load(),
+		LastError:   lastError,
+		LastErrorAt: lastErrorAt,
+	}
+}
+
+// Close 停止转发并关闭连接，队列中尚未发送的命令被丢弃
+func (m *Mirror) Close() {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+		<-m.done
+	})
+}
+
+func (m *Mirror) run() {
+	defer close(m.done)
+	var conn *upstreamConn
+	defer func() {
+		if conn != nil {
+			_ = conn.Close()
+		}
+	}()
+	backoff := minBackoff
+	for {
+		var first [][]byte
+		select {
+		case <-m.stop:
+			return
+		case first = <-m.queue:
+		}
+		batch := [][][]byte{first}
+	drain:
+		for len(batch) < pipelineSize {
+			select {
+			case cmd := <-m.queue:
+				batch = append(batch, cmd)
+			default:
+				break drain
+			}
+		}
+
+		if conn == nil {
+			c, err := m.dial()
+			if err != nil {
+				m.fail(int64(len(batch)), err)
+				// 等待后重连，期间继续接收的命令留在队列中，队列满后丢弃
+				select {
+				case <-m.stop:
+					return
+				case <-time.After(backoff):
+				}
+				backoff = min(backoff*2, maxBackoff)
+				continue
+			}
+			conn = c
+			backoff = minBackoff
+			m.connected.Store(true)
+		}
+
+		if err := m.send(conn, batch); err != nil {
+			_ = conn.Close()
+			conn = nil
+			m.connected.Store(false)
+		}
+	}
+}
+
+// send 以流水线方式写出 batch 并读取全部回复。连接出错时返回错误，未确认的命令计入失败
+func (m *Mirror) send(conn *upstreamConn, batch [][][]byte) error {
+	_ = conn.SetDeadline(time.Now().Add(ioTimeout))
+	for _, cmd := range batch {
+		if err := proto.WriteRESP(conn.w, &proto.Array{Args: cmd}); err != nil {
+			m.fail(int64(len(batch)), err)
+			return err
+		}
+	}
+	if err := conn.w.Flush(); err != nil {
+		m.fail(int64(len(batch)), err)
+		return err
+	}
+	for i := range batch {
+		msg, err := readReply(conn.r)
+		if err != nil {
+			m.fail(int64(len(batch)-i), err)
+			return err
+		}
+		if msg != "" {
+			m.fail(1, errors.New(msg))
+			continue
+		}
+		m.forwarded.Add(1)
+	}
+	return nil
+}
+
+// fail 记录 n 条命令失败
+func (m *Mirror) fail(n int64, err error) {
+	m.failed.Add(n)
+	m.mu.Lock()
+	first := m.lastError == ""
+	m.lastError = err.Error()
+	m.lastErrorAt = time.Now()
+	m.mu.Unlock()
+	if first {
+		logger.Logger.Warn().Err(err).Str("upstream", m.addr).Msg("镜像写命令到上游失败")
+	}
+}
+
+// upstreamConn 到上游的连接
+type upstreamConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func (m *Mirror) dial() (*upstreamConn, error) {
+	c, err := net.DialTimeout("tcp", m.addr, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("dial upstream %s: %w", m.addr, err)
+	}
+	conn := &upstreamConn{Conn: c, r: bufio.NewReader(c), w: bufio.NewWriter(c)}
+	if m.opts.Password != "" {
+		if err := conn.auth(m.opts.Password); err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// auth 发送 AUTH 并检查回复，不计入转发的命令数
+func (c *upstreamConn) auth(password string) error {
+	_ = c.SetDeadline(time.Now().Add(ioTimeout))
+	if err := proto.WriteRESP(c.w, &proto.Array{Args: [][]byte{[]byte("AUTH"), []byte(password)}}); err != nil {
+		return err
+	}
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	msg, err := readReply(c.r)
+	if err != nil {
+		return err
+	}
+	if msg != "" {
+		return fmt.Errorf("upstream AUTH: %s", msg)
+	}
+	return nil
+}
+
+// readReply 读取一个完整的 RESP 回复，错误回复返回其内容（不含 "-"），其余返回空字符串
+func readReply(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("invalid reply line %q", line)
+	}
+	line = line[:len(line)-2]
+	switch line[0] {
+	case '-':
+		return line[1:], nil
+	case '+', ':':
+		return "", nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("invalid bulk length %q", line[1:])
+		}
+		if n < 0 {
+			return "", nil
+		}
+		_, err = io.CopyN(io.Discard, r, int64(n)+2)
+		return "", err
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("invalid array length %q", line[1:])
+		}
+		for i := 0; i < n; i++ {
+			if _, err := readReply(r); err != nil {
+				return "", err
+			}
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("unexpected reply type %q", line[0])
+}
@@ -0,0 +1,128 @@
+package mirror
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/zeebo/assert"
+)
+
+// fakeUpstream 模拟上游 Redis：记录收到的命令，对 FAIL 返回错误；password 不为空时要求先 AUTH
+func fakeUpstream(t *testing.T, password string) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	received := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				authed := password == ""
+				for {
+					req, err := proto.ReadRESP(r)
+					if err != nil {
+						return
+					}
+					cmd := strings.ToUpper(string(req.Args[0]))
+					reply := "+OK\r\n"
+					switch {
+					case cmd == "AUTH":
+						authed = string(req.Args[1]) == password
+						if !authed {
+							reply = "-WRONGPASS invalid password\r\n"
+						}
+					case !authed:
+						reply = "-NOAUTH Authentication required.\r\n"
+					case cmd == "FAIL":
+						reply = "-ERR unknown command 'fail'\r\n"
+					}
+					if cmd != "AUTH" {
+						received <- req.String()
+					}
+					if _, err := conn.Write([]byte(reply)); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMirrorForward(t *testing.T) {
+	addr, received := fakeUpstream(t, "secret")
+	m := New(addr, Options{Password: "secret"})
+	defer m.Close()
+
+	args := [][]byte{[]byte("SET"), []byte("k"), []byte("v")}
+	m.Forward(args)
+	args[2][0] = 'x' // Forward 已复制参数
+	m.Forward([][]byte{[]byte("FAIL")})
+	assert.Equal(t, "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n", <-received)
+	assert.Equal(t, "*1\r\n$4\r\nFAIL\r\n", <-received)
+	waitFor(t, func() bool { return m.Stats().Failed == 1 })
+	stats := m.Stats()
+	assert.Equal(t, int64(1), stats.Forwarded)
+	assert.True(t, stats.Connected)
+	assert.Equal(t, "ERR unknown command 'fail'", stats.LastError)
+
+	// 暂停期间的写入不转发
+	m.Pause()
+	m.Forward(args)
+	assert.Equal(t, int64(1), m.Stats().Skipped)
+	m.Resume()
+	m.Forward([][]byte{[]byte("DEL"), []byte("k")})
+	assert.Equal(t, "*2\r\n$3\r\nDEL\r\n$1\r\nk\r\n", <-received)
+	waitFor(t, func() bool { return m.Stats().Forwarded == 2 })
+}
+
+func TestMirrorUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := ln.Addr().String()
+	assert.NoError(t, ln.Close())
+
+	m := New(addr, Options{QueueSize: 1})
+	defer m.Close()
+	for i := 0; i < 5; i++ {
+		m.Forward([][]byte{[]byte("SET"), []byte("k"), []byte("v")})
+	}
+	waitFor(t, func() bool { return m.Stats().Failed >= 1 })
+	stats := m.Stats()
+	assert.False(t, stats.Connected)
+	assert.True(t, stats.Dropped >= 3)
+	assert.True(t, strings.Contains(stats.LastError, "dial upstream"))
+}
+
+func TestReadReply(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("+OK\r\n:1\r\n$3\r\nfoo\r\n$-1\r\n*2\r\n$1\r\na\r\n*1\r\n:2\r\n-ERR bad\r\n"))
+	for i := 0; i < 5; i++ {
+		msg, err := readReply(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "", msg)
+	}
+	msg, err := readReply(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "ERR bad", msg)
+	_, err = readReply(r)
+	assert.Error(t, err)
+}
@@ -3,6 +3,7 @@ package proto
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -63,16 +64,44 @@ func (n *NestedArray) String() string {
 	return b.String()
 }
 
-func ReadRESP(r *bufio.Reader) (*Array, error) {
-	line, err := readLine(r)
-	if err != nil {
-		logger.Logger.Debug().Err(err).Msg("ReadRESP readLine 失败")
-		return nil, err
-	}
+// MaxBulkLen 请求中单个 bulk string 的最大长度。超出时返回协议错误（连接随之关闭），
+// 避免按客户端声明的长度分配过大的内存；值的大小限制（max-value-size）由存储层检查
+var MaxBulkLen = 1 << 30
 
-	if len(line) == 0 {
-		logger.Logger.Debug().Msg("ReadRESP 收到空行")
-		return nil, fmt.Errorf("empty line")
+// MaxMultiBulkLen 请求数组的最大元素数，超出时返回协议错误
+var MaxMultiBulkLen = 1024 * 1024
+
+// maxInlineLen 内联命令与 *、$ 头部行的最大长度，与 Redis 的 PROTO_INLINE_MAX_SIZE 相同，
+// 避免一直没有换行的输入占用无限的内存
+const maxInlineLen = 64 << 10
+
+// arrayPrealloc 按声明的数组长度预分配的上限，更长的数组边读边扩容
+const arrayPrealloc = 1024
+
+// ProtocolError 请求不符合协议。与 Redis 相同，服务端回复 -ERR Protocol error: <原因> 后关闭连接：
+// 出错位置之后的字节无法确定命令的边界，继续读取会把参数当作命令执行
+type ProtocolError struct {
+	Reason string
+}
+
+func (e *ProtocolError) Error() string { return "Protocol error: " + e.Reason }
+
+// errLineTooLong 一行超过 maxInlineLen 仍没有换行，由调用方转换为相应的协议错误
+var errLineTooLong = errors.New("line too long")
+
+// ReadRESP 读取一条请求：RESP 数组，或以空格分隔、可用引号包含空格的内联命令（telnet 等）。
+// 空行被忽略；格式错误返回 *ProtocolError，其他错误为连接的读取错误
+func ReadRESP(r *bufio.Reader) (*Array, error) {
+	var line []byte
+	for len(line) == 0 {
+		var err error
+		if line, err = readLine(r); err != nil {
+			if errors.Is(err, errLineTooLong) {
+				return nil, &ProtocolError{Reason: "too big inline request"}
+			}
+			logger.Logger.Debug().Err(err).Msg("ReadRESP readLine 失败")
+			return nil, err
+		}
 	}
 
 	logger.Logger.Debug().
@@ -82,29 +111,33 @@ func ReadRESP(r *bufio.Reader) (*Array, error) {
 
 	switch line[0] {
 	case '*': // Array
-		if len(line) == 1 {
-			return nil, fmt.Errorf("invalid array prefix")
-		}
 		n, err := strconv.Atoi(string(line[1:]))
-		if err != nil || n < 0 {
-			return nil, fmt.Errorf("invalid array length: %s", line[1:])
+		if err != nil || n > MaxMultiBulkLen {
+			return nil, &ProtocolError{Reason: "invalid multibulk length"}
 		}
-		args := make([][]byte, n)
+		args := make([][]byte, 0, min(max(n, 0), arrayPrealloc))
 		for i := 0; i < n; i++ {
 			// 先读 $xxx\r\n
 			lenLine, err := readLine(r)
+			if errors.Is(err, errLineTooLong) {
+				return nil, &ProtocolError{Reason: "too big bulk count string"}
+			}
 			if err != nil {
 				return nil, err
 			}
 			if len(lenLine) == 0 || lenLine[0] != '$' {
-				return nil, fmt.Errorf("expected $, got %q", lenLine)
+				got := "\\r"
+				if len(lenLine) > 0 {
+					got = string(lenLine[:1])
+				}
+				return nil, &ProtocolError{Reason: fmt.Sprintf("expected '$', got '%s'", got)}
 			}
 			bulkLen, err := strconv.Atoi(string(lenLine[1:]))
-			if err != nil || bulkLen < -1 {
-				return nil, err
+			if err != nil || bulkLen < -1 || bulkLen > MaxBulkLen {
+				return nil, &ProtocolError{Reason: "invalid bulk length"}
 			}
 			if bulkLen == -1 {
-				args[i] = nil
+				args = append(args, nil)
 				continue
 			}
 
@@ -114,7 +147,7 @@ func ReadRESP(r *bufio.Reader) (*Array, error) {
 			if err != nil {
 				return nil, err
 			}
-			args[i] = data[:bulkLen] // 去掉 \r\n
+			args = append(args, data[:bulkLen]) // 去掉 \r\n
 		}
 		return &Array{Args: args}, nil
 	case '+': // Simple String (用于响应，如 PING 返回 PONG)
@@ -130,11 +163,14 @@ func ReadRESP(r *bufio.Reader) (*Array, error) {
 		// 这不应该出现在命令中，但为了健壮性处理
 		bulkLen, err := strconv.Atoi(string(line[1:]))
 		if err != nil || bulkLen < -1 {
-			return nil, fmt.Errorf("invalid bulk string length: %s", line[1:])
+			return nil, &ProtocolError{Reason: "invalid bulk length"}
 		}
 		if bulkLen == -1 {
 			return nil, fmt.Errorf("null bulk string not supported as command")
 		}
+		if bulkLen > MaxBulkLen {
+			return nil, &ProtocolError{Reason: "invalid bulk length"}
+		}
 		data := make([]byte, bulkLen+2)
 		_, err = io.ReadFull(r, data)
 		if err != nil {
@@ -152,35 +188,123 @@ func ReadRESP(r *bufio.Reader) (*Array, error) {
 
 // parseInlineCommand 解析内联命令
 // 内联命令格式: "PING" 或 "GET key" 或 "SET key value"
-// 参数用空格分隔
+// 参数用空格分隔；与 redis-cli 相同，参数可以用双引号（支持 \n、\xHH 等转义）或单引号包含空格，
+// 引号不配对时返回协议错误
 func parseInlineCommand(line []byte) (*Array, error) {
-	// 将字节数组转换为字符串，然后按空格分割
-	cmdStr := strings.TrimSpace(string(line))
-	if cmdStr == "" {
-		return nil, fmt.Errorf("empty inline command")
+	args, ok := splitInlineArgs(line)
+	if !ok {
+		return nil, &ProtocolError{Reason: "unbalanced quotes in request"}
 	}
-
-	// 按空格分割命令和参数
-	// 注意：Redis 内联命令中，参数中的空格需要用引号包裹，但大多数客户端不使用这个特性
-	parts := strings.Fields(cmdStr)
-	if len(parts) == 0 {
+	if len(args) == 0 {
 		return nil, fmt.Errorf("empty inline command")
 	}
 
-	// 转换为字节数组
-	args := make([][]byte, len(parts))
-	for i, part := range parts {
-		args[i] = []byte(part)
-	}
-
 	logger.Logger.Debug().
-		Str("inline_command", cmdStr).
+		Str("inline_command", string(line)).
 		Int("arg_count", len(args)).
 		Msg("解析内联命令")
 
 	return &Array{Args: args}, nil
 }
 
+// splitInlineArgs 按 Redis 的 sdssplitargs 规则拆分内联命令，引号不配对或闭合引号后紧跟其他字符时 ok 为 false
+func splitInlineArgs(line []byte) (args [][]byte, ok bool) {
+	isSpace := func(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == 0 }
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, true
+		}
+		arg := []byte{}
+		switch line[i] {
+		case '"':
+			i++
+			for {
+				if i >= len(line) {
+					return nil, false
+				}
+				c := line[i]
+				if c == '"' {
+					i++
+					break
+				}
+				if c == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHex(line[i+2]) && isHex(line[i+3]) {
+					arg = append(arg, hexValue(line[i+2])<<4|hexValue(line[i+3]))
+					i += 4
+					continue
+				}
+				if c == '\\' && i+1 < len(line) {
+					i++
+					switch line[i] {
+					case 'n':
+						c = '\n'
+					case 'r':
+						c = '\r'
+					case 't':
+						c = '\t'
+					case 'b':
+						c = '\b'
+					case 'a':
+						c = '\a'
+					default:
+						c = line[i]
+					}
+				}
+				arg = append(arg, c)
+				i++
+			}
+			if i < len(line) && !isSpace(line[i]) {
+				return nil, false
+			}
+		case '\'':
+			i++
+			for {
+				if i >= len(line) {
+					return nil, false
+				}
+				c := line[i]
+				if c == '\'' {
+					i++
+					break
+				}
+				if c == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					c = '\''
+					i++
+				}
+				arg = append(arg, c)
+				i++
+			}
+			if i < len(line) && !isSpace(line[i]) {
+				return nil, false
+			}
+		default:
+			for i < len(line) && !isSpace(line[i]) {
+				arg = append(arg, line[i])
+				i++
+			}
+		}
+		args = append(args, arg)
+	}
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func hexValue(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
 func WriteRESP(w io.Writer, resp RESP) error {
 	respStr := resp.String()
 	logger.Logger.Debug().
@@ -211,10 +335,21 @@ func truncateString(s string, maxLen int) string {
 }
 
 // helpers
+// readLine 读取一行并去掉行尾的 \r\n，超过 maxInlineLen 仍没有换行时返回 errLineTooLong
 func readLine(r *bufio.Reader) ([]byte, error) {
-	line, err := r.ReadBytes('\n')
-	if err != nil {
-		return nil, err
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+		if len(line) > maxInlineLen {
+			return nil, errLineTooLong
+		}
 	}
 	// 去掉 \r\n
 	if len(line) > 0 && line[len(line)-1] == '\n' {
@@ -292,3 +427,82 @@ func NewInteger(i int64) RESP { r := Integer(i); return &r }
 var (
 	OK = NewSimpleString("OK")
 )
+
+// ReadReply 读取一条任意类型的回复（RESP2 与 RESP3），用于解析 RawString 等已编码的回复
+func ReadReply(r *bufio.Reader) (RESP, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, fmt.Errorf("empty line")
+	}
+	body := string(line[1:])
+	switch line[0] {
+	case '+':
+		return NewSimpleString(body), nil
+	case '-':
+		return NewError(body), nil
+	case ':':
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer: %s", body)
+		}
+		return NewInteger(n), nil
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < -1 || n > MaxBulkLen {
+			return nil, fmt.Errorf("invalid bulk length: %s", body)
+		}
+		if n == -1 {
+			return NewBulkString(nil), nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return NewBulkString(buf[:n]), nil
+	case '_':
+		return Null{}, nil
+	case ',':
+		f, err := strconv.ParseFloat(body, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid double: %s", body)
+		}
+		return NewDouble(f), nil
+	case '#':
+		return NewBoolean(body == "t"), nil
+	case '(':
+		return NewBigNumber(body), nil
+	case '*', '%', '~', '>':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("invalid aggregate length: %s", body)
+		}
+		if n == -1 {
+			return NewBulkString(nil), nil
+		}
+		count := n
+		if line[0] == '%' {
+			count = 2 * n
+		}
+		elems := make([]RESP, 0, min(count, arrayPrealloc))
+		for i := 0; i < count; i++ {
+			elem, err := ReadReply(r)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, elem)
+		}
+		switch line[0] {
+		case '%':
+			return NewMap(elems), nil
+		case '~':
+			return NewSet(elems), nil
+		case '>':
+			return NewPush(elems), nil
+		}
+		return &NestedArray{Elems: elems}, nil
+	}
+	return nil, fmt.Errorf("unknown reply type %q", line[0])
+}
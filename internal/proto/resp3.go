@@ -0,0 +1,148 @@
+package proto
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RESP3 类型：连接通过 HELLO 3 协商后，回复中的空值、浮点数、映射、集合与推送
+// 使用专门的类型编码；RESP2 连接不会收到这些类型
+
+// Null RESP3 空值，对应 RESP2 的 $-1 与 *-1
+type Null struct{}
+
+func (Null) String() string { return "_\r\n" }
+
+// Double RESP3 浮点数
+type Double float64
+
+func (d Double) String() string {
+	f := float64(d)
+	switch {
+	case math.IsInf(f, 1):
+		return ",inf\r\n"
+	case math.IsInf(f, -1):
+		return ",-inf\r\n"
+	case math.IsNaN(f):
+		return ",nan\r\n"
+	}
+	return "," + strconv.FormatFloat(f, 'g', -1, 64) + "\r\n"
+}
+
+// Boolean RESP3 布尔值
+type Boolean bool
+
+func (b Boolean) String() string {
+	if b {
+		return "#t\r\n"
+	}
+	return "#f\r\n"
+}
+
+// BigNumber RESP3 大整数，超出 int64 范围的十进制整数
+type BigNumber string
+
+func (n BigNumber) String() string { return "(" + string(n) + "\r\n" }
+
+// Map RESP3 映射，Elems 为键、值交替排列
+type Map struct {
+	Elems []RESP
+}
+
+func (m *Map) String() string { return aggregateString('%', len(m.Elems)/2, m.Elems) }
+
+// Set RESP3 集合
+type Set struct {
+	Elems []RESP
+}
+
+func (s *Set) String() string { return aggregateString('~', len(s.Elems), s.Elems) }
+
+// Push RESP3 推送（订阅消息等带外数据），第一个元素是推送类型
+type Push struct {
+	Elems []RESP
+}
+
+func (p *Push) String() string { return aggregateString('>', len(p.Elems), p.Elems) }
+
+func aggregateString(prefix byte, n int, elems []RESP) string {
+	var b strings.Builder
+	b.WriteByte(prefix)
+	b.WriteString(strconv.Itoa(n))
+	b.WriteString("\r\n")
+	for _, elem := range elems {
+		b.WriteString(elem.String())
+	}
+	return b.String()
+}
+
+func NewDouble(f float64) RESP   { r := Double(f); return &r }
+func NewBoolean(v bool) RESP     { r := Boolean(v); return &r }
+func NewBigNumber(s string) RESP { r := BigNumber(s); return &r }
+func NewMap(elems []RESP) RESP   { return &Map{Elems: elems} }
+func NewSet(elems []RESP) RESP   { return &Set{Elems: elems} }
+func NewPush(elems []RESP) RESP  { return &Push{Elems: elems} }
+func NewNull() RESP              { return Null{} }
+
+// Elems 返回数组类回复（Array、NestedArray、Map、Set、Push）的元素，其他类型返回 false
+func Elems(r RESP) ([]RESP, bool) {
+	switch v := r.(type) {
+	case *Array:
+		elems := make([]RESP, len(v.Args))
+		for i, arg := range v.Args {
+			elems[i] = NewBulkString(arg)
+		}
+		return elems, true
+	case *NestedArray:
+		return v.Elems, true
+	case *Map:
+		return v.Elems, true
+	case *Set:
+		return v.Elems, true
+	case *Push:
+		return v.Elems, true
+	}
+	return nil, false
+}
+
+// ToRESP3 将 RESP2 回复中的空值（$-1、*-1）换成 RESP3 的 Null，数组逐层转换；
+// 已经是 RESP3 类型的部分原样保留，因此可以重复调用
+func ToRESP3(r RESP) RESP {
+	switch v := r.(type) {
+	case nil:
+		return nil
+	case *BulkString:
+		if v == nil || *v == nil {
+			return Null{}
+		}
+	case RawString:
+		if v == "$-1\r\n" || v == "*-1\r\n" {
+			return Null{}
+		}
+	case *Array:
+		for _, arg := range v.Args {
+			if arg == nil {
+				elems, _ := Elems(v)
+				return &NestedArray{Elems: toRESP3Elems(elems)}
+			}
+		}
+	case *NestedArray:
+		return &NestedArray{Elems: toRESP3Elems(v.Elems)}
+	case *Map:
+		return &Map{Elems: toRESP3Elems(v.Elems)}
+	case *Set:
+		return &Set{Elems: toRESP3Elems(v.Elems)}
+	case *Push:
+		return &Push{Elems: toRESP3Elems(v.Elems)}
+	}
+	return r
+}
+
+func toRESP3Elems(elems []RESP) []RESP {
+	out := make([]RESP, len(elems))
+	for i, e := range elems {
+		out[i] = ToRESP3(e)
+	}
+	return out
+}
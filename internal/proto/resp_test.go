@@ -3,6 +3,9 @@ package proto
 import (
 	"bufio"
 	"bytes"
+	"errors"
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/zeebo/assert"
@@ -160,6 +163,11 @@ func TestReadRESPArray(t *testing.T) {
 			expected: &Array{Args: [][]byte{[]byte("CMD"), nil}},
 			wantErr:  false,
 		},
+		{
+			name:    "bulk longer than MaxBulkLen",
+			input:   "*2\r\n$3\r\nSET\r\n$4294967296\r\n",
+			wantErr: true,
+		},
 		{
 			name:     "inline PING",
 			input:    "PING\r\n",
@@ -364,3 +372,93 @@ func TestTruncateString(t *testing.T) {
 		})
 	}
 }
+
+func TestRESP3Types(t *testing.T) {
+	assert.Equal(t, "_\r\n", NewNull().String())
+	assert.Equal(t, ",1.5\r\n", NewDouble(1.5).String())
+	assert.Equal(t, ",10\r\n", NewDouble(10).String())
+	assert.Equal(t, ",inf\r\n", NewDouble(math.Inf(1)).String())
+	assert.Equal(t, ",-inf\r\n", NewDouble(math.Inf(-1)).String())
+	assert.Equal(t, "#t\r\n", NewBoolean(true).String())
+	assert.Equal(t, "#f\r\n", NewBoolean(false).String())
+	assert.Equal(t, "(3492890328409238509324850943850943825024385\r\n", NewBigNumber("3492890328409238509324850943850943825024385").String())
+
+	k, v := NewBulkString([]byte("k")), NewInteger(1)
+	assert.Equal(t, "%1\r\n$1\r\nk\r\n:1\r\n", NewMap([]RESP{k, v}).String())
+	assert.Equal(t, "~2\r\n$1\r\nk\r\n:1\r\n", NewSet([]RESP{k, v}).String())
+	assert.Equal(t, ">2\r\n$1\r\nk\r\n:1\r\n", NewPush([]RESP{k, v}).String())
+}
+
+func TestToRESP3(t *testing.T) {
+	assert.Equal(t, "_\r\n", ToRESP3(NewBulkString(nil)).String())
+	assert.Equal(t, "_\r\n", ToRESP3(RawString("*-1\r\n")).String())
+	assert.Equal(t, "$1\r\nv\r\n", ToRESP3(NewBulkString([]byte("v"))).String())
+	assert.Equal(t, "*2\r\n$1\r\na\r\n_\r\n", ToRESP3(&Array{Args: [][]byte{[]byte("a"), nil}}).String())
+	nested := &NestedArray{Elems: []RESP{NewMap([]RESP{NewBulkString([]byte("f")), NewBulkString(nil)})}}
+	converted := ToRESP3(nested)
+	assert.Equal(t, "*1\r\n%1\r\n$1\r\nf\r\n_\r\n", converted.String())
+	// 重复转换结果不变
+	assert.Equal(t, converted.String(), ToRESP3(converted).String())
+}
+
+func TestReadReply(t *testing.T) {
+	input := "+OK\r\n-ERR bad\r\n:42\r\n$3\r\nfoo\r\n$-1\r\n*-1\r\n*2\r\n:1\r\n*1\r\n$1\r\nx\r\n%1\r\n+k\r\n,1.5\r\n_\r\n#t\r\n"
+	r := bufio.NewReader(bytes.NewBufferString(input))
+	for _, want := range []string{
+		"+OK\r\n", "-ERR bad\r\n", ":42\r\n", "$3\r\nfoo\r\n", "$-1\r\n", "$-1\r\n",
+		"*2\r\n:1\r\n*1\r\n$1\r\nx\r\n", "%1\r\n+k\r\n,1.5\r\n", "_\r\n", "#t\r\n",
+	} {
+		reply, err := ReadReply(r)
+		assert.NoError(t, err)
+		assert.Equal(t, want, reply.String())
+	}
+	_, err := ReadReply(r)
+	assert.Error(t, err)
+	_, err = ReadReply(bufio.NewReader(bytes.NewBufferString("?bad\r\n")))
+	assert.Error(t, err)
+}
+
+func TestReadRESPProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		reason string
+	}{
+		{"non-numeric multibulk length", "*abc\r\n", "invalid multibulk length"},
+		{"multibulk too long", "*2000000\r\n", "invalid multibulk length"},
+		{"missing dollar", "*1\r\n:1\r\n", "expected '$', got ':'"},
+		{"non-numeric bulk length", "*1\r\n$x\r\n", "invalid bulk length"},
+		{"negative bulk length", "*1\r\n$-2\r\n", "invalid bulk length"},
+		{"unbalanced quotes", "SET k \"v\r\n", "unbalanced quotes in request"},
+		{"text after closing quote", "SET k \"v\"x\r\n", "unbalanced quotes in request"},
+		{"inline too long", strings.Repeat("a", 2*maxInlineLen), "too big inline request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadRESP(bufio.NewReader(strings.NewReader(tt.input)))
+			var perr *ProtocolError
+			assert.True(t, errors.As(err, &perr))
+			assert.Equal(t, tt.reason, perr.Reason)
+		})
+	}
+
+	// 连接关闭不是协议错误
+	_, err := ReadRESP(bufio.NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n")))
+	var perr *ProtocolError
+	assert.False(t, errors.As(err, &perr))
+}
+
+func TestReadRESPInline(t *testing.T) {
+	// 空行被忽略，引号内可以包含空格与转义字符
+	r := bufio.NewReader(strings.NewReader("\r\n\r\nSET \"hello world\" 'it\\'s' \"a\\x41\\n\"\r\nPING\n"))
+	req, err := ReadRESP(r)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, [][]byte{[]byte("SET"), []byte("hello world"), []byte("it's"), []byte("aA\n")}, req.Args)
+	req, err = ReadRESP(r)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, [][]byte{[]byte("PING")}, req.Args)
+
+	args, ok := splitInlineArgs([]byte(`GET ""`))
+	assert.True(t, ok)
+	assert.DeepEqual(t, [][]byte{[]byte("GET"), {}}, args)
+}
@@ -3,6 +3,7 @@ package replication
 import (
 	"bufio"
 	"bytes"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -27,9 +28,16 @@ type MasterConnection struct {
 	closeOnce     sync.Once
 }
 
-// NewMasterConnection 创建新的主节点连接
-func NewMasterConnection(addr string) (*MasterConnection, error) {
-	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+// NewMasterConnection 创建新的主节点连接，tlsConfig 不为 nil 时使用 TLS
+func NewMasterConnection(addr string, tlsConfig *tls.Config) (*MasterConnection, error) {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("dial master failed: %w", err)
 	}
@@ -44,6 +52,7 @@ func NewMasterConnection(addr string) (*MasterConnection, error) {
 
 	logger.Logger.Info().
 		Str("master_addr", addr).
+		Bool("tls", tlsConfig != nil).
 		Msg("连接到主节点")
 
 	logger.Logger.Debug().Msg("MasterConnection created, about to send commands")
@@ -117,10 +117,15 @@ func StartSlaveReplication(rm *ReplicationManager, store *store.BotreonStore, ma
 	rm.mu.Lock()
 	rm.role = RoleSlave
 	rm.masterAddr = masterAddr
+	tlsConfig := rm.masterTLS
+	listeningPort := rm.listeningPort
 	rm.mu.Unlock()
+	if listeningPort == 0 {
+		listeningPort = 6379
+	}
 
 	// 连接到主节点
-	masterConn, err := NewMasterConnection(masterAddr)
+	masterConn, err := NewMasterConnection(masterAddr, tlsConfig)
 	if err != nil {
 		return fmt.Errorf("connect to master failed: %w", err)
 	}
@@ -156,7 +161,7 @@ func StartSlaveReplication(rm *ReplicationManager, store *store.BotreonStore, ma
 		if err := masterConn.SendCommand([][]byte{
 			[]byte("REPLCONF"),
 			[]byte("listening-port"),
-			[]byte("6380"),
+			[]byte(strconv.Itoa(listeningPort)),
 		}); err != nil {
 			logger.Logger.Error().Err(err).Msg("发送REPLCONF listening-port失败")
 			return
@@ -251,6 +256,9 @@ func StartSlaveReplication(rm *ReplicationManager, store *store.BotreonStore, ma
 			logger.Logger.Info().Msg("开始增量同步，接收命令流")
 		}
 
+		// 定期向主节点确认偏移量，WAIT 依赖这些确认
+		go ackLoop(rm, masterConn)
+
 		// 持续接收命令并执行
 		for {
 			req, err := proto.ReadRESP(masterConn.Reader)
@@ -265,8 +273,19 @@ func StartSlaveReplication(rm *ReplicationManager, store *store.BotreonStore, ma
 				Str("cmd", string(req.Args[0])).
 				Msg("从主节点收到命令")
 
-			// 执行命令并更新偏移量
-			executeReplicatedCommand(store, req.Args)
+			// REPLCONF GETACK：立即报告处理到此命令之前的偏移量，与 Redis 相同地计入偏移量但不执行
+			if len(req.Args) >= 2 && strings.EqualFold(string(req.Args[0]), "REPLCONF") &&
+				strings.EqualFold(string(req.Args[1]), "GETACK") {
+				if err := sendAck(rm, masterConn); err != nil {
+					logger.Logger.Warn().Err(err).Msg("发送REPLCONF ACK失败")
+				}
+			} else if exec := rm.commandExecutor(); exec != nil && executorCommands[strings.ToUpper(string(req.Args[0]))] {
+				exec(req.Args)
+			} else {
+				// 执行命令
+				executeReplicatedCommand(store, req.Args)
+			}
+			// 更新偏移量
 			cmdBytes := serializeCommand(req.Args)
 			rm.IncrementReplOffset(int64(len(cmdBytes)))
 		}
@@ -293,6 +312,14 @@ func StopSlaveReplication(rm *ReplicationManager) {
 	}
 }
 
+// executorCommands 交给 SetCommandExecutor 设置的函数执行的复制命令：流的消费者组状态与
+// 订阅者都在服务器中，主节点已把 XADD 的自动 ID、XREADGROUP 与 XAUTOCLAIM 改写为确定的形式
+var executorCommands = map[string]bool{
+	"XADD": true, "XDEL": true, "XTRIM": true, "XSETID": true,
+	"XGROUP": true, "XREADGROUP": true, "XACK": true, "XCLAIM": true,
+	"PUBLISH": true,
+}
+
 // executeReplicatedCommand 执行从主节点接收到的复制命令
 func executeReplicatedCommand(s *store.BotreonStore, args [][]byte) {
 	if len(args) == 0 {
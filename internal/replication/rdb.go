@@ -271,15 +271,10 @@ func GenerateRDB(s *store.BotreonStore) ([]byte, error) {
 			}
 			keyType := string(typeVal)
 
-			// 获取TTL
+			// 获取TTL，所有类型的过期时间都由 TTL 读取
 			ttl := int64(0)
-			if item.ExpiresAt() > 0 {
-			// #nosec G115 - expiresAt is a valid Unix timestamp within int64 range
-				expireTime := time.Unix(int64(item.ExpiresAt()), 0)
-				now := time.Now()
-				if expireTime.After(now) {
-					ttl = int64(expireTime.Sub(now).Seconds())
-				}
+			if remaining, err := s.TTL(key); err == nil && remaining > 0 {
+				ttl = remaining
 			}
 
 			// 根据类型获取值并写入
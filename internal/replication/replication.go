@@ -2,6 +2,7 @@ package replication
 
 import (
 	"crypto/rand"
+	"crypto/tls"
 	"encoding/hex"
 	"fmt"
 	"sync"
@@ -28,6 +29,11 @@ type ReplicationManager struct {
 	store           *store.BotreonStore       // 数据存储
 	stopCh          chan struct{}             // 停止信号
 	closeOnce       sync.Once                 // 确保关闭只执行一次
+	masterTLS       *tls.Config               // 连接主节点使用的 TLS 配置，nil 表示明文
+	listeningPort   int                       // 本节点的服务端口，作为从节点时通过 REPLCONF listening-port 告知主节点
+	ackMu           sync.Mutex                // 保护 ackCh
+	ackCh           chan struct{}             // 从节点确认偏移量时关闭，唤醒 WAIT，见 WaitForAcks
+	executor        func(args [][]byte)       // 从节点执行 executorCommands 中命令的函数，见 SetCommandExecutor
 }
 
 // NewReplicationManager 创建新的复制管理器
@@ -45,6 +51,20 @@ func NewReplicationManager(store *store.BotreonStore) *ReplicationManager {
 	return rm
 }
 
+// SetCommandExecutor 设置从节点执行主节点传播的流与发布订阅命令（见 executorCommands）的函数，
+// 这些命令需要消费者组与订阅等服务器级状态，由服务器按普通命令执行。未设置时只记录日志
+func (rm *ReplicationManager) SetCommandExecutor(fn func(args [][]byte)) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.executor = fn
+}
+
+func (rm *ReplicationManager) commandExecutor() func(args [][]byte) {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.executor
+}
+
 // generateReplicationID 生成40字符的十六进制复制ID
 func generateReplicationID() (string, error) {
 	bytes := make([]byte, 20)
@@ -154,13 +174,40 @@ func (rm *ReplicationManager) GetMasterAddr() string {
 	return rm.masterAddr
 }
 
+// MasterLinkUp 从节点到主节点的复制连接是否仍然打开，主节点断开后复制协程关闭连接
+func (rm *ReplicationManager) MasterLinkUp() bool {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return rm.masterConn != nil && !rm.masterConn.IsClosed()
+}
+
 // SetMasterConnection 设置主节点连接
 func (rm *ReplicationManager) SetMasterConnection(conn *MasterConnection) {
 	rm.mu.Lock()
 	defer rm.mu.Unlock()
+	// 切换到新主节点（如哨兵故障转移后重新配置）时关闭旧连接，避免两路命令流同时执行
+	if rm.masterConn != nil && rm.masterConn != conn {
+		if err := rm.masterConn.Close(); err != nil {
+			logger.Logger.Debug().Err(err).Msg("failed to close master connection")
+		}
+	}
 	rm.masterConn = conn
 }
 
+// SetMasterTLS 设置连接主节点使用的 TLS 配置（-tls-replication），nil 表示明文连接
+func (rm *ReplicationManager) SetMasterTLS(cfg *tls.Config) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.masterTLS = cfg
+}
+
+// SetListeningPort 设置本节点的服务端口，主节点在 INFO replication 中据此报告从节点地址，哨兵据此发现从节点
+func (rm *ReplicationManager) SetListeningPort(port int) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.listeningPort = port
+}
+
 // GetMasterConnection 获取主节点连接
 func (rm *ReplicationManager) GetMasterConnection() *MasterConnection {
 	rm.mu.RLock()
@@ -175,8 +222,8 @@ func (rm *ReplicationManager) GetBacklog() *ReplicationBacklog {
 	return rm.backlog
 }
 
-// PropagateCommand 传播命令到所有从节点
-func (rm *ReplicationManager) PropagateCommand(cmd [][]byte) {
+// PropagateCommand 传播命令到所有从节点，返回传播后的主节点复制偏移量
+func (rm *ReplicationManager) PropagateCommand(cmd [][]byte) int64 {
 	rm.mu.RLock()
 	slaves := make([]*SlaveConnection, 0, len(rm.slaves))
 	for _, slave := range rm.slaves {
@@ -186,7 +233,7 @@ func (rm *ReplicationManager) PropagateCommand(cmd [][]byte) {
 	rm.mu.RUnlock()
 
 	if len(slaves) == 0 {
-		return
+		return rm.GetMasterReplOffset()
 	}
 
 	// 将命令添加到backlog
@@ -207,7 +254,10 @@ func (rm *ReplicationManager) PropagateCommand(cmd [][]byte) {
 	}
 
 	// 更新复制偏移量
-	rm.IncrementReplOffset(int64(len(cmdBytes)))
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.masterReplOffset += int64(len(cmdBytes))
+	return rm.masterReplOffset
 }
 
 // serializeCommand 序列化命令为RESP格式
@@ -268,6 +318,7 @@ func (rm *ReplicationManager) UpdateSlaveAckOffset(slaveID string, offset int64)
 			Str("slave_id", slaveID).
 			Int64("ack_offset", offset).
 			Msg("更新从节点ACK偏移量")
+		rm.notifyAcks()
 	}
 }
 
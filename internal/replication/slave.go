@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 
@@ -22,6 +23,7 @@ type SlaveConnection struct {
 	ReplAckOffset int64  // 从节点确认的偏移量
 	Ready         bool   // 是否准备好接收命令
 	LastAckTime   int64  // 最后一次ACK时间
+	ListeningPort int    // 从节点通过 REPLCONF listening-port 告知的服务端口，0 表示未告知
 	mu            sync.RWMutex
 	closeOnce     sync.Once
 }
@@ -84,6 +86,13 @@ func (sc *SlaveConnection) UpdateReplAck(offset int64) {
 	sc.LastAckTime = time.Now().Unix()
 }
 
+// GetReplAckOffset 获取从节点确认的偏移量
+func (sc *SlaveConnection) GetReplAckOffset() int64 {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return sc.ReplAckOffset
+}
+
 // SendCommand 发送命令到从节点
 func (sc *SlaveConnection) SendCommand(cmdBytes []byte, offset int64) error {
 	sc.mu.Lock()
@@ -182,6 +191,29 @@ func (sc *SlaveConnection) Close() error {
 	return err
 }
 
+// SetListeningPort 记录从节点的服务端口
+func (sc *SlaveConnection) SetListeningPort(port int) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.ListeningPort = port
+}
+
+// ServiceAddr 从节点接受客户端连接的地址：连接的来源 IP 加上它告知的服务端口，
+// 未告知时为连接的来源地址
+func (sc *SlaveConnection) ServiceAddr() (host string, port int) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	host, portStr, err := net.SplitHostPort(sc.Addr)
+	if err != nil {
+		return sc.Addr, sc.ListeningPort
+	}
+	if sc.ListeningPort > 0 {
+		return host, sc.ListeningPort
+	}
+	port, _ = strconv.Atoi(portStr)
+	return host, port
+}
+
 // GetLastAckTime 获取最后ACK时间
 func (sc *SlaveConnection) GetLastAckTime() int64 {
 	sc.mu.RLock()
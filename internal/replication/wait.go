@@ -0,0 +1,100 @@
+package replication
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+)
+
+// ackInterval 从节点定期发送 REPLCONF ACK 的间隔，与 Redis 相同
+const ackInterval = time.Second
+
+// getAckCommand 主节点请求从节点立即确认偏移量
+var getAckCommand = [][]byte{[]byte("REPLCONF"), []byte("GETACK"), []byte("*")}
+
+// ackWaitChan 返回下一次从节点确认偏移量时关闭的 channel
+func (rm *ReplicationManager) ackWaitChan() chan struct{} {
+	rm.ackMu.Lock()
+	defer rm.ackMu.Unlock()
+	if rm.ackCh == nil {
+		rm.ackCh = make(chan struct{})
+	}
+	return rm.ackCh
+}
+
+// notifyAcks 唤醒所有等待从节点确认的 WAIT
+func (rm *ReplicationManager) notifyAcks() {
+	rm.ackMu.Lock()
+	defer rm.ackMu.Unlock()
+	if rm.ackCh != nil {
+		close(rm.ackCh)
+		rm.ackCh = nil
+	}
+}
+
+// CountAcked 已确认复制到 offset 的从节点数
+func (rm *ReplicationManager) CountAcked(offset int64) int {
+	n := 0
+	for _, slave := range rm.GetSlaves() {
+		if slave.IsReady() && slave.GetReplAckOffset() >= offset {
+			n++
+		}
+	}
+	return n
+}
+
+// WaitForAcks 等待至少 numReplicas 个从节点确认复制到 offset，返回已确认的从节点数。
+// 确认数不足时先向从节点发送 REPLCONF GETACK *，之后每次收到 ACK 重新计数，
+// 直到满足要求或超过 timeout（0 表示一直等待）
+func (rm *ReplicationManager) WaitForAcks(offset int64, numReplicas int, timeout time.Duration) int {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	requested := false
+	for {
+		// 先取得 channel 再计数，避免错过两者之间到达的 ACK
+		ch := rm.ackWaitChan()
+		acked := rm.CountAcked(offset)
+		if acked >= numReplicas {
+			return acked
+		}
+		if !requested {
+			rm.PropagateCommand(getAckCommand)
+			requested = true
+		}
+		select {
+		case <-ch:
+		case <-deadline:
+			return rm.CountAcked(offset)
+		case <-rm.stopCh:
+			return rm.CountAcked(offset)
+		}
+	}
+}
+
+// sendAck 从节点向主节点报告已处理的复制偏移量
+func sendAck(rm *ReplicationManager, mc *MasterConnection) error {
+	offset := strconv.FormatInt(rm.GetMasterReplOffset(), 10)
+	return mc.SendCommand([][]byte{[]byte("REPLCONF"), []byte("ACK"), []byte(offset)})
+}
+
+// ackLoop 从节点每秒发送一次 REPLCONF ACK，直到与主节点的连接关闭
+func ackLoop(rm *ReplicationManager, mc *MasterConnection) {
+	ticker := time.NewTicker(ackInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-mc.stopCh:
+			return
+		case <-ticker.C:
+			if err := sendAck(rm, mc); err != nil {
+				logger.Logger.Debug().Err(err).Str("master_addr", mc.Addr).Msg("发送REPLCONF ACK失败")
+				return
+			}
+		}
+	}
+}
@@ -1,11 +1,11 @@
 package sentinel
 
 import (
-	"fmt"
-	"strings"
+	"strconv"
+	"time"
 )
 
-// ConfigProvider 配置提供者
+// ConfigProvider 生成 SENTINEL MASTERS/MASTER/REPLICAS/SENTINELS 的字段，字段名与顺序与 Redis Sentinel 相同
 type ConfigProvider struct {
 	sentinel *Sentinel
 }
@@ -21,81 +21,138 @@ func NewConfigProvider(sentinel *Sentinel) *ConfigProvider {
 func (cp *ConfigProvider) GetMasterAddrByName(name string) (string, error) {
 	master := cp.sentinel.GetMaster(name)
 	if master == nil {
-		return "", fmt.Errorf("master %s not found", name)
+		return "", ErrNoSuchMaster
 	}
-
 	return master.GetAddr(), nil
 }
 
-// GetMasters 获取所有主节点信息
-func (cp *ConfigProvider) GetMasters() []map[string]string {
-	masters := cp.sentinel.GetAllMasters()
-	result := make([]map[string]string, 0, len(masters))
+// GetMasters 所有主节点的字段，按名称排序
+func (cp *ConfigProvider) GetMasters() [][]string {
+	masters := cp.sentinel.Masters()
+	result := make([][]string, 0, len(masters))
+	for _, master := range masters {
+		result = append(result, cp.GetMaster(master))
+	}
+	return result
+}
 
-	for name, master := range masters {
-		info := map[string]string{
-			"name": name,
-			"ip":   strings.Split(master.GetAddr(), ":")[0],
-			"port": strings.Split(master.GetAddr(), ":")[1],
-			"runid": "",
-			"flags": master.GetState(),
-			"link-pending-commands": "0",
-			"link-refcount": "1",
-			"last-ping-sent": "0",
-			"last-ok-ping-reply": "0",
-			"last-ping-reply": "0",
-			"down-after-milliseconds": fmt.Sprintf("%d", master.downAfter.Milliseconds()),
-			"info-refresh": "0",
-			"role-reported": "master",
-			"role-reported-time": "0",
-			"config-epoch": fmt.Sprintf("%d", cp.sentinel.GetConfigEpoch()),
-			"num-slaves": fmt.Sprintf("%d", len(master.GetSlaves())),
-			"num-other-sentinels": fmt.Sprintf("%d", len(master.sentinels)),
-			"quorum": fmt.Sprintf("%d", master.quorum),
-			"failover-timeout": "180000",
-			"parallel-syncs": "1",
-		}
-		result = append(result, info)
+// GetMaster 一个主节点的字段（交替的字段名与值）
+func (cp *ConfigProvider) GetMaster(mi *MasterInstance) []string {
+	flags := mi.flags()
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	host, port := splitAddr(mi.addr)
+	fields := []string{
+		"name", mi.name,
+		"ip", host,
+		"port", port,
+		"runid", mi.runID,
+		"flags", flags,
+		"link-pending-commands", "0",
+		"link-refcount", "1",
+		"last-ping-sent", strconv.FormatInt(pingPending(mi.lastPingTime, mi.lastPongTime), 10),
+		"last-ok-ping-reply", strconv.FormatInt(sinceMs(mi.lastPongTime), 10),
+		"last-ping-reply", strconv.FormatInt(sinceMs(mi.lastPongTime), 10),
+	}
+	if mi.sdown {
+		fields = append(fields, "s-down-time", strconv.FormatInt(sinceMs(mi.sdownSince), 10))
 	}
+	if mi.odown {
+		fields = append(fields, "o-down-time", strconv.FormatInt(sinceMs(mi.odownSince), 10))
+	}
+	fields = append(fields,
+		"down-after-milliseconds", strconv.FormatInt(mi.downAfter.Milliseconds(), 10),
+		"info-refresh", strconv.FormatInt(sinceMs(mi.infoRefresh), 10),
+		"role-reported", "master",
+		"role-reported-time", strconv.FormatInt(sinceMs(mi.infoRefresh), 10),
+		"config-epoch", strconv.FormatInt(mi.configEpoch, 10),
+		"num-slaves", strconv.Itoa(len(mi.slaves)),
+		"num-other-sentinels", strconv.Itoa(len(mi.sentinels)),
+		"quorum", strconv.Itoa(mi.quorum),
+		"failover-timeout", strconv.FormatInt(mi.failoverTimeout.Milliseconds(), 10),
+		"parallel-syncs", strconv.Itoa(mi.parallelSyncs),
+	)
+	if mi.failoverState != "" {
+		fields = append(fields, "failover-state", mi.failoverState)
+	}
+	return fields
+}
 
+// GetSlaves 主节点的所有从节点的字段，按地址排序
+func (cp *ConfigProvider) GetSlaves(mi *MasterInstance) [][]string {
+	slaves := mi.GetSlaves()
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	result := make([][]string, 0, len(slaves))
+	for _, slave := range slaves {
+		host, port := splitAddr(slave.Addr)
+		result = append(result, []string{
+			"name", slave.Addr,
+			"ip", host,
+			"port", port,
+			"runid", slave.RunID,
+			"flags", slave.flags(),
+			"link-pending-commands", "0",
+			"link-refcount", "1",
+			"last-ping-sent", strconv.FormatInt(pingPending(slave.lastPingTime, slave.lastPongTime), 10),
+			"last-ok-ping-reply", strconv.FormatInt(sinceMs(slave.lastPongTime), 10),
+			"last-ping-reply", strconv.FormatInt(sinceMs(slave.lastPongTime), 10),
+			"down-after-milliseconds", strconv.FormatInt(mi.downAfter.Milliseconds(), 10),
+			"info-refresh", strconv.FormatInt(sinceMs(slave.infoRefresh), 10),
+			"role-reported", slave.RoleReported,
+			"role-reported-time", strconv.FormatInt(sinceMs(slave.roleReportedTime), 10),
+			"master-link-down-time", "0",
+			"master-link-status", slave.MasterLinkStatus,
+			"master-host", slave.MasterHost,
+			"master-port", strconv.Itoa(slave.MasterPort),
+			"slave-priority", strconv.Itoa(slave.Priority),
+			"slave-repl-offset", strconv.FormatInt(slave.Offset, 10),
+		})
+	}
 	return result
 }
 
-// GetSlaves 获取主节点的所有从节点
-func (cp *ConfigProvider) GetSlaves(masterName string) []map[string]string {
-	master := cp.sentinel.GetMaster(masterName)
-	if master == nil {
-		return nil
+// GetSentinels 监控同一主节点的其他哨兵的字段，按地址排序
+func (cp *ConfigProvider) GetSentinels(mi *MasterInstance) [][]string {
+	sentinels := mi.GetSentinels()
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	result := make([][]string, 0, len(sentinels))
+	for _, si := range sentinels {
+		host, port := splitAddr(si.Addr)
+		result = append(result, []string{
+			"name", si.RunID,
+			"ip", host,
+			"port", port,
+			"runid", si.RunID,
+			"flags", "sentinel",
+			"last-hello-message", strconv.FormatInt(sinceMs(si.lastHello), 10),
+			"voted-leader", leaderOrQuestion(si.leader),
+			"voted-leader-epoch", strconv.FormatInt(si.leaderEpoch, 10),
+		})
 	}
+	return result
+}
 
-	slaves := master.GetSlaves()
-	result := make([]map[string]string, 0, len(slaves))
+// sinceMs 距 t 的毫秒数，t 为零值时为 0
+func sinceMs(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return time.Since(t).Milliseconds()
+}
 
-	for _, slave := range slaves {
-		info := map[string]string{
-			"name": slave.ID,
-			"ip":   strings.Split(slave.Addr, ":")[0],
-			"port": strings.Split(slave.Addr, ":")[1],
-			"runid": "",
-			"flags": strings.Join(slave.Flags, ","),
-			"link-pending-commands": "0",
-			"link-refcount": "1",
-			"last-ping-sent": "0",
-			"last-ok-ping-reply": "0",
-			"last-ping-reply": "0",
-			"down-after-milliseconds": "5000",
-			"info-refresh": "0",
-			"role-reported": "slave",
-			"role-reported-time": "0",
-			"master-link-down-time": "0",
-			"master-link-status": "ok",
-			"master-host": strings.Split(master.GetAddr(), ":")[0],
-			"master-port": strings.Split(master.GetAddr(), ":")[1],
-			"slave-priority": "100",
-			"slave-repl-offset": fmt.Sprintf("%d", slave.Offset),
-		}
-		result = append(result, info)
+// pingPending 最近一次 PING 没有回复时距发送的毫秒数，否则为 0
+func pingPending(ping, pong time.Time) int64 {
+	if ping.IsZero() || !pong.Before(ping) {
+		return 0
 	}
+	return sinceMs(ping)
+}
 
-	return result
+func leaderOrQuestion(leader string) string {
+	if leader == "" {
+		return "?"
+	}
+	return leader
 }
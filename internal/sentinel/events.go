@@ -0,0 +1,119 @@
+package sentinel
+
+import (
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// subscriberBuffer 每个订阅者缓冲的事件数，写不出去的客户端超过后丢弃事件
+const subscriberBuffer = 256
+
+// eventBus 哨兵事件的发布订阅。与 Redis Sentinel 相同，客户端连接哨兵端口后订阅以事件类型命名的频道
+// （如 SUBSCRIBE +switch-master，或 PSUBSCRIBE * 接收全部事件）
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[*subscriber]struct{}
+}
+
+// subscriber 一个处于订阅状态的客户端连接，消息经 out 由连接的写协程发出
+type subscriber struct {
+	channels map[string]bool
+	patterns map[string]bool
+	out      chan proto.RESP
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subs: make(map[*subscriber]struct{})}
+}
+
+// newSubscriber 注册一个订阅者，连接关闭时调用 remove
+func (b *eventBus) newSubscriber() *subscriber {
+	sub := &subscriber{
+		channels: make(map[string]bool),
+		patterns: make(map[string]bool),
+		out:      make(chan proto.RESP, subscriberBuffer),
+	}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// remove 注销订阅者
+func (b *eventBus) remove(sub *subscriber) {
+	b.mu.Lock()
+	delete(b.subs, sub)
+	b.mu.Unlock()
+}
+
+// update 订阅（on 为 true）或退订频道与模式，返回之后的订阅总数
+func (b *eventBus) update(sub *subscriber, pattern, on bool, name string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	set := sub.channels
+	if pattern {
+		set = sub.patterns
+	}
+	if on {
+		set[name] = true
+	} else {
+		delete(set, name)
+	}
+	return len(sub.channels) + len(sub.patterns)
+}
+
+// subscriptions 订阅者当前订阅的频道或模式，按名称排序
+func (b *eventBus) subscriptions(sub *subscriber, pattern bool) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	set := sub.channels
+	if pattern {
+		set = sub.patterns
+	}
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// count 订阅总数
+func (b *eventBus) count(sub *subscriber) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(sub.channels) + len(sub.patterns)
+}
+
+// publish 向订阅了 channel 或匹配模式的客户端发送消息，返回接收者数
+func (b *eventBus) publish(channel, message string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for sub := range b.subs {
+		if sub.channels[channel] {
+			n += sub.send(&proto.Array{Args: [][]byte{[]byte("message"), []byte(channel), []byte(message)}})
+		}
+		for pattern := range sub.patterns {
+			if ok, _ := path.Match(pattern, channel); ok {
+				n += sub.send(&proto.Array{Args: [][]byte{[]byte("pmessage"), []byte(pattern), []byte(channel), []byte(message)}})
+			}
+		}
+	}
+	return n
+}
+
+// send 不阻塞地放入发送队列，队列已满时丢弃
+func (sub *subscriber) send(msg proto.RESP) int {
+	select {
+	case sub.out <- msg:
+		return 1
+	default:
+		logger.Logger.Warn().Msg("哨兵事件订阅者的发送队列已满，丢弃事件")
+		return 0
+	}
+}
@@ -1,209 +1,339 @@
 package sentinel
 
 import (
+	"errors"
 	"fmt"
-	"strings"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/proto"
 )
 
-// FailoverManager 故障转移管理器
-type FailoverManager struct {
-	sentinel *Sentinel
-}
+const (
+	// electionTimeout 等待当选领头者的最长时间（不超过 failover-timeout）
+	electionTimeout = 10 * time.Second
+	// maxDesync 开始选举前的随机等待上限，避免多个哨兵同时发起选举导致选票分散
+	maxDesync = time.Second
+)
+
+var (
+	// ErrNoGoodSlave 没有可以提升的从节点
+	ErrNoGoodSlave = errors.New("no suitable replica to promote")
+	// ErrFailoverInProgress 已有进行中的故障转移
+	ErrFailoverInProgress = errors.New("failover already in progress")
+)
 
-// NewFailoverManager 创建新的故障转移管理器
-func NewFailoverManager(sentinel *Sentinel) *FailoverManager {
-	return &FailoverManager{
-		sentinel: sentinel,
+// StartFailover SENTINEL FAILOVER：不经其他哨兵同意立即在后台执行故障转移
+func (s *Sentinel) StartFailover(name string) error {
+	mi := s.GetMaster(name)
+	if mi == nil {
+		return ErrNoSuchMaster
 	}
+	if mi.selectSlave() == nil {
+		return ErrNoGoodSlave
+	}
+	if !mi.tryStartFailover(true) {
+		return ErrFailoverInProgress
+	}
+	go s.failover(mi, true)
+	return nil
 }
 
-// StartFailover 启动故障转移
-func (fm *FailoverManager) StartFailover(masterName string) error {
-	master := fm.sentinel.GetMaster(masterName)
-	if master == nil {
-		return fmt.Errorf("master %s not found", masterName)
+// failover 执行一次故障转移：增加纪元并（非强制时）当选领头者，提升最合适的从节点，
+// 让其他从节点复制新主节点，最后切换配置并发布 +switch-master
+func (s *Sentinel) failover(mi *MasterInstance, forced bool) {
+	defer mi.endFailover()
+
+	epoch := s.nextEpoch()
+	mi.mu.Lock()
+	mi.failoverEpoch = epoch
+	timeout := mi.failoverTimeout
+	mi.mu.Unlock()
+	s.event("+try-failover", mi.describe())
+
+	if forced {
+		mi.mu.Lock()
+		mi.leader, mi.leaderEpoch = s.runID, epoch
+		mi.mu.Unlock()
+	} else if !s.waitElection(mi, epoch, min(electionTimeout, timeout)) {
+		s.event("-failover-abort-not-elected", mi.describe())
+		return
 	}
-
-	// 检查是否已经在下线状态
-	if !master.IsDown() {
-		return fmt.Errorf("master %s is not down", masterName)
+	s.event("+elected-leader", mi.describe())
+	deadline := time.Now().Add(timeout)
+
+	mi.setFailoverState("select-slave")
+	s.event("+failover-state-select-slave", mi.describe())
+	slave := mi.selectSlave()
+	if slave == nil {
+		s.event("-failover-abort-no-good-slave", mi.describe())
+		return
 	}
-
-	// 检查是否已经有其他哨兵在执行故障转移
-	if master.GetState() == "failover" {
-		return fmt.Errorf("failover already in progress for master %s", masterName)
+	newAddr := slave.Addr
+	slaveDesc := mi.describeOther("slave", newAddr, newAddr)
+	mi.mu.Lock()
+	mi.promotedSlave = newAddr
+	mi.mu.Unlock()
+	s.event("+selected-slave", slaveDesc)
+
+	mi.setFailoverState("send-slaveof-noone")
+	s.event("+failover-state-send-slaveof-noone", slaveDesc)
+	for {
+		err := SendSlaveOfNoOne(newAddr)
+		if err == nil {
+			break
+		}
+		logger.Logger.Warn().Err(err).Str("slave", newAddr).Msg("提升从节点失败")
+		if time.Now().After(deadline) {
+			s.event("-failover-abort-slave-timeout", slaveDesc)
+			return
+		}
+		if !mi.sleep(mi.period()) {
+			return
+		}
 	}
 
-	// 设置状态为故障转移中
-	master.SetState("failover")
-
-	logger.Logger.Info().
-		Str("master_name", masterName).
-		Str("master_addr", master.GetAddr()).
-		Msg("开始故障转移")
-
-	// 选择新的主节点
-	newMaster := fm.selectNewMaster(master)
-	if newMaster == nil {
-		master.SetState("odown")
-		return fmt.Errorf("no suitable slave found for failover")
+	mi.setFailoverState("wait-promotion")
+	s.event("+failover-state-wait-promotion", slaveDesc)
+	for {
+		if role, err := GetRole(newAddr); err == nil && role == "master" {
+			break
+		}
+		if time.Now().After(deadline) {
+			s.event("-failover-abort-slave-timeout", slaveDesc)
+			return
+		}
+		if !mi.sleep(mi.period()) {
+			return
+		}
 	}
-
-	logger.Logger.Info().
-		Str("new_master", newMaster.Addr).
-		Str("old_master", master.GetAddr()).
-		Msg("选中的新主节点")
-
-	// 执行故障转移
-	if err := fm.executeFailover(master, newMaster); err != nil {
-		master.SetState("odown")
-		return fmt.Errorf("failover failed: %w", err)
+	s.event("+promoted-slave", slaveDesc)
+
+	mi.setFailoverState("reconf-slaves")
+	s.event("+failover-state-reconf-slaves", mi.describe())
+	for _, other := range mi.GetSlaves() {
+		mi.mu.RLock()
+		skip := other.Addr == newAddr || other.sdown
+		mi.mu.RUnlock()
+		if skip {
+			continue
+		}
+		if err := SendReplicaOf(other.Addr, newAddr); err != nil {
+			logger.Logger.Warn().Err(err).Str("slave", other.Addr).Msg("重新配置从节点失败")
+			continue
+		}
+		s.event("+slave-reconf-sent", mi.describeOther("slave", other.Addr, other.Addr))
 	}
 
-	// 更新配置
-	fm.updateConfiguration(master, newMaster)
-
-	logger.Logger.Info().
-		Str("master_name", masterName).
-		Str("new_master", newMaster.Addr).
-		Msg("故障转移完成")
+	s.event("+failover-end", mi.describe())
+	s.switchMaster(mi, newAddr, epoch)
+}
 
-	return nil
+// setFailoverState 记录故障转移进行到的阶段
+func (mi *MasterInstance) setFailoverState(state string) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	mi.failoverState = state
 }
 
-// selectNewMaster 选择新的主节点
-func (fm *FailoverManager) selectNewMaster(oldMaster *MasterInstance) *SlaveInstance {
-	slaves := oldMaster.GetSlaves()
+// endFailover 结束（完成或放弃）故障转移，放弃时两倍 failover-timeout 内不再自动尝试
+func (mi *MasterInstance) endFailover() {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	mi.failoverState = ""
+	mi.promotedSlave = ""
+}
 
-	if len(slaves) == 0 {
-		return nil
+// waitElection 为自己拉票直到当选或超时
+func (s *Sentinel) waitElection(mi *MasterInstance, epoch int64, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	// #nosec G404 - 随机等待不需要密码学随机数
+	if !mi.sleep(time.Duration(rand.Int63n(int64(maxDesync)))) {
+		return false
 	}
-
-	// 选择优先级最高且数据最新的从节点
-	var bestSlave *SlaveInstance
-	bestScore := int64(-1)
-
-	for _, slave := range slaves {
-		if slave.State != "online" {
-			continue
+	for {
+		s.voteLeader(mi, s.runID, epoch)
+		s.askSentinels(mi, s.runID)
+		if s.electedLeader(mi, epoch) == s.runID {
+			return true
 		}
-
-		// 简单评分：复制偏移量（越大越好，表示数据更新）
-		// 实际应该从节点上报偏移量，这里使用已知的Offset
-		score := slave.Offset
-		if score > bestScore {
-			bestScore = score
-			bestSlave = slave
+		if time.Now().After(deadline) || !mi.sleep(mi.period()) {
+			return false
 		}
 	}
-
-	return bestSlave
 }
 
-// executeFailover 执行故障转移
-func (fm *FailoverManager) executeFailover(oldMaster *MasterInstance, newMaster *SlaveInstance) error {
-	// 1. 将选中的从节点提升为主节点
-	// 发送 SLAVEOF NO ONE 命令
-	logger.Logger.Info().
-		Str("slave_addr", newMaster.Addr).
-		Msg("发送 SLAVEOF NO ONE 到新主节点")
-
-	if err := SendSlaveOfNoOne(newMaster.Addr); err != nil {
-		logger.Logger.Error().
-			Str("slave_addr", newMaster.Addr).
-			Err(err).
-			Msg("发送 SLAVEOF NO ONE 失败")
-		return fmt.Errorf("failed to promote slave: %w", err)
-	}
-
-	// 2. 等待新主节点就绪
-	logger.Logger.Info().Msg("等待新主节点就绪...")
-	time.Sleep(1 * time.Second)
-
-	// 3. 验证新主节点已提升
-	role, err := GetRole(newMaster.Addr)
-	if err != nil {
-		logger.Logger.Warn().
-			Str("addr", newMaster.Addr).
-			Err(err).
-			Msg("无法验证新主节点角色")
-	} else {
-		if !strings.HasPrefix(role, "+master") {
-			logger.Logger.Warn().
-				Str("addr", newMaster.Addr).
-				Str("role", role).
-				Msg("新主节点角色验证失败")
-		} else {
-			logger.Logger.Info().
-				Str("addr", newMaster.Addr).
-				Str("role", role).
-				Msg("新主节点已就绪")
-		}
-	}
-
-	// 4. 将其他从节点重新配置为复制新主节点
-	slaves := oldMaster.GetSlaves()
-	for _, slave := range slaves {
-		if slave.ID != newMaster.ID && slave.State == "online" {
-			logger.Logger.Info().
-				Str("slave_addr", slave.Addr).
-				Str("new_master", newMaster.Addr).
-				Msg("重新配置从节点")
-
-			if err := SendReplicaOf(slave.Addr, newMaster.Addr); err != nil {
-				logger.Logger.Warn().
-					Str("slave", slave.Addr).
-					Err(err).
-					Msg("重新配置从节点失败")
-			}
+// voteLeader 在 epoch 中为 runID 投票：每个纪元只投给第一个请求者。返回本哨兵在已投票的最新纪元中选择的领头者。
+// 投给其他哨兵后，本哨兵在两倍 failover-timeout 内不会自己发起故障转移
+func (s *Sentinel) voteLeader(mi *MasterInstance, runID string, epoch int64) (string, int64) {
+	s.observeEpoch(epoch)
+	current := s.CurrentEpoch()
+
+	mi.mu.Lock()
+	voted := mi.leaderEpoch < epoch && current <= epoch
+	if voted {
+		mi.leader, mi.leaderEpoch = runID, epoch
+		if runID != s.runID {
+			mi.failoverStartTime = time.Now()
 		}
 	}
+	leader, leaderEpoch := mi.leader, mi.leaderEpoch
+	mi.mu.Unlock()
 
-	return nil
+	if voted && runID != s.runID {
+		s.event("+vote-for-leader", fmt.Sprintf("%s %d", runID, epoch))
+	}
+	return leader, leaderEpoch
 }
 
-// updateConfiguration 更新配置
-func (fm *FailoverManager) updateConfiguration(oldMaster *MasterInstance, newMaster *SlaveInstance) {
-	// 更新配置纪元
-	fm.sentinel.IncrementConfigEpoch()
-
-	// 更新主节点地址
-	oldMaster.mu.Lock()
-	oldMaster.addr = newMaster.Addr
-	oldMaster.state = "ok"
-	oldMaster.mu.Unlock()
-
-	logger.Logger.Info().
-		Str("master_name", oldMaster.GetName()).
-		Str("new_addr", newMaster.Addr).
-		Msg("更新主节点配置")
+// electedLeader 统计 epoch 中的选票：得票最多且不少于 max(quorum, 哨兵总数的多数) 的哨兵当选，否则返回空字符串
+func (s *Sentinel) electedLeader(mi *MasterInstance, epoch int64) string {
+	mi.mu.RLock()
+	votes := make(map[string]int)
+	for _, si := range mi.sentinels {
+		if si.leader != "" && si.leaderEpoch == epoch {
+			votes[si.leader]++
+		}
+	}
+	if mi.leader != "" && mi.leaderEpoch == epoch {
+		votes[mi.leader]++
+	}
+	voters := len(mi.sentinels) + 1
+	quorum := mi.quorum
+	mi.mu.RUnlock()
+
+	winner, most := "", 0
+	for runID, n := range votes {
+		if n > most || n == most && runID > winner {
+			winner, most = runID, n
+		}
+	}
+	if most < max(quorum, voters/2+1) {
+		return ""
+	}
+	return winner
 }
 
-// AutoFailover 自动故障转移（检测到客观下线时触发）
-func (fm *FailoverManager) AutoFailover(masterName string) error {
-	// 检查是否满足自动故障转移条件
-	master := fm.sentinel.GetMaster(masterName)
-	if master == nil {
-		return fmt.Errorf("master %s not found", masterName)
+// askSentinels 并发向其他哨兵发送 SENTINEL IS-MASTER-DOWN-BY-ADDR，记录它们是否认为主节点下线；
+// runID 不为 * 时同时请求它们在当前纪元投票给 runID
+func (s *Sentinel) askSentinels(mi *MasterInstance, runID string) {
+	host, port := splitAddr(mi.GetAddr())
+	epoch := strconv.FormatInt(s.CurrentEpoch(), 10)
+	timeout := mi.period()
+	var wg sync.WaitGroup
+	for _, si := range mi.GetSentinels() {
+		wg.Add(1)
+		go func(si *SentinelInstance) {
+			defer wg.Done()
+			reply, err := si.link.call(si.Addr, timeout, "SENTINEL", "is-master-down-by-addr", host, port, epoch, runID)
+			if err != nil {
+				return
+			}
+			elems, ok := proto.Elems(reply)
+			if !ok || len(elems) != 3 {
+				return
+			}
+			leaderEpoch, err := strconv.ParseInt(replyString(elems[2]), 10, 64)
+			if err != nil {
+				return
+			}
+			mi.mu.Lock()
+			defer mi.mu.Unlock()
+			si.masterDown = replyString(elems[0]) == "1"
+			si.downReplyTime = time.Now()
+			if leader := replyString(elems[1]); leader != "*" {
+				si.leader, si.leaderEpoch = leader, leaderEpoch
+			}
+		}(si)
 	}
+	wg.Wait()
+}
 
-	// 检查是否满足客观下线条件
-	if !master.IsODown() {
-		return fmt.Errorf("master %s is not objectively down", masterName)
+// selectSlave 选择提升的从节点：排除主观下线、最近没有回复以及优先级为 0 的从节点，
+// 依次按优先级（小者优先）、复制偏移量（大者优先）、运行ID 排序
+func (mi *MasterInstance) selectSlave() *SlaveInstance {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	maxAge := 5 * min(maxPingPeriod, mi.downAfter)
+	var candidates []*SlaveInstance
+	for _, slave := range mi.slaves {
+		if slave.sdown || slave.Priority == 0 || time.Since(slave.lastPongTime) > maxAge {
+			continue
+		}
+		candidates = append(candidates, slave)
+	}
+	if len(candidates) == 0 {
+		return nil
 	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		if a.Offset != b.Offset {
+			return a.Offset > b.Offset
+		}
+		if a.RunID != b.RunID {
+			return a.RunID != "" && (b.RunID == "" || a.RunID < b.RunID)
+		}
+		return a.Addr < b.Addr
+	})
+	return candidates[0]
+}
 
-	// 检查是否已经有其他哨兵在执行故障转移
-	if master.GetState() == "failover" {
-		return fmt.Errorf("failover already in progress for master %s", masterName)
+// switchMaster 把主节点切换到 newAddr：原有从节点与原主节点成为新主节点的从节点，下线与故障转移状态清零
+func (s *Sentinel) switchMaster(mi *MasterInstance, newAddr string, epoch int64) {
+	mi.mu.Lock()
+	oldAddr := mi.addr
+	if newAddr == oldAddr {
+		mi.configEpoch = max(mi.configEpoch, epoch)
+		mi.mu.Unlock()
+		return
 	}
+	if promoted, ok := mi.slaves[newAddr]; ok {
+		promoted.link.close()
+		delete(mi.slaves, newAddr)
+	}
+	if _, ok := mi.slaves[oldAddr]; !ok {
+		mi.slaves[oldAddr] = NewSlaveInstance(oldAddr)
+	}
+	now := time.Now()
+	mi.addr = newAddr
+	mi.configEpoch = epoch
+	mi.runID, mi.roleReported = "", ""
+	mi.lastPongTime, mi.infoRefresh = now, time.Time{}
+	mi.sdown, mi.odown = false, false
+	mi.failoverState, mi.failoverStartTime, mi.promotedSlave = "", time.Time{}, ""
+	for _, si := range mi.sentinels {
+		si.masterDown = false
+	}
+	mi.mu.Unlock()
+	mi.link.close()
 
-	logger.Logger.Info().
-		Str("master_name", masterName).
-		Str("master_addr", master.GetAddr()).
-		Msg("自动故障转移条件满足，开始故障转移")
+	oldHost, oldPort := splitAddr(oldAddr)
+	newHost, newPort := splitAddr(newAddr)
+	s.event("+switch-master", fmt.Sprintf("%s %s %s %s %s", mi.name, oldHost, oldPort, newHost, newPort))
+}
 
-	return fm.StartFailover(masterName)
+// isMasterDownByAddr SENTINEL IS-MASTER-DOWN-BY-ADDR：返回本哨兵是否认为该地址的主节点主观下线，
+// runID 不为 * 时在 epoch 中为其投票并返回本哨兵选择的领头者
+func (s *Sentinel) isMasterDownByAddr(host string, port int, epoch int64, runID string) (bool, string, int64) {
+	mi := s.masterByAddr(net.JoinHostPort(host, strconv.Itoa(port)))
+	if mi == nil {
+		return false, "*", 0
+	}
+	down := mi.IsDown()
+	if runID == "*" {
+		return down, "*", 0
+	}
+	leader, leaderEpoch := s.voteLeader(mi, runID, epoch)
+	if leader == "" {
+		leader = "*"
+	}
+	return down, leader, leaderEpoch
 }
@@ -2,10 +2,12 @@ package sentinel
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/lbp0200/BoltDB/internal/logger"
 	"github.com/lbp0200/BoltDB/internal/proto"
@@ -13,9 +15,8 @@ import (
 
 // SentinelHandler 哨兵命令处理器
 type SentinelHandler struct {
-	sentinel      *Sentinel
+	sentinel       *Sentinel
 	configProvider *ConfigProvider
-	failoverMgr   *FailoverManager
 }
 
 // NewSentinelHandler 创建新的哨兵处理器
@@ -23,58 +24,127 @@ func NewSentinelHandler(sentinel *Sentinel) *SentinelHandler {
 	return &SentinelHandler{
 		sentinel:       sentinel,
 		configProvider: NewConfigProvider(sentinel),
-		failoverMgr:    NewFailoverManager(sentinel),
 	}
 }
 
+// sentinelConn 一个客户端连接：命令回复与订阅的事件共用写缓冲区，由 mu 串行化
+type sentinelConn struct {
+	mu     sync.Mutex
+	writer *bufio.Writer
+	sub    *subscriber
+	done   chan struct{}
+}
+
+// write 写入并刷新一条回复
+func (c *sentinelConn) write(resp proto.RESP) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := proto.WriteRESP(c.writer, resp); err != nil {
+		return err
+	}
+	return c.writer.Flush()
+}
+
 // HandleConnection 处理连接
 func (sh *SentinelHandler) HandleConnection(conn net.Conn) {
+	c := &sentinelConn{writer: bufio.NewWriter(conn), done: make(chan struct{})}
 	defer func() {
+		close(c.done)
+		if c.sub != nil {
+			sh.sentinel.events.remove(c.sub)
+		}
 		if err := conn.Close(); err != nil {
 			logger.Logger.Debug().Err(err).Msg("failed to close connection")
 		}
 	}()
 
 	reader := bufio.NewReader(conn)
-	writer := bufio.NewWriter(conn)
-	defer func() {
-		if err := writer.Flush(); err != nil {
-			logger.Logger.Debug().Err(err).Msg("failed to flush writer")
-		}
-	}()
-
 	for {
 		req, err := proto.ReadRESP(reader)
 		if err != nil {
 			logger.Logger.Debug().Err(err).Msg("Sentinel: read request failed")
 			return
 		}
-
 		if len(req.Args) == 0 {
-			_ = proto.WriteRESP(writer, proto.NewError("ERR no command"))
-			if err := writer.Flush(); err != nil {
-				logger.Logger.Debug().Err(err).Msg("failed to flush writer")
-			}
 			continue
 		}
 
 		cmd := strings.ToUpper(string(req.Args[0]))
 		args := req.Args[1:]
 
-		resp := sh.executeCommand(cmd, args)
-		if resp == nil {
-			resp = proto.NewError("ERR internal error")
+		var resp proto.RESP
+		switch cmd {
+		case "SUBSCRIBE", "PSUBSCRIBE", "UNSUBSCRIBE", "PUNSUBSCRIBE":
+			err = sh.handleSubscribe(c, cmd, args)
+		case "QUIT":
+			_ = c.write(proto.OK)
+			return
+		default:
+			if c.sub != nil && sh.sentinel.events.count(c.sub) > 0 && cmd != "PING" {
+				resp = proto.NewError(fmt.Sprintf("ERR Can't execute '%s': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context", strings.ToLower(cmd)))
+			} else {
+				resp = sh.executeCommand(cmd, args)
+			}
+			err = c.write(resp)
 		}
-
-		if err := proto.WriteRESP(writer, resp); err != nil {
+		if err != nil {
 			logger.Logger.Debug().Err(err).Msg("Sentinel: write response failed")
 			return
 		}
-		if err := writer.Flush(); err != nil {
-			logger.Logger.Debug().Err(err).Msg("Sentinel: flush failed")
-			return
+	}
+}
+
+// handleSubscribe 处理 (P)SUBSCRIBE 与 (P)UNSUBSCRIBE，第一次订阅时启动转发事件的协程
+func (sh *SentinelHandler) handleSubscribe(c *sentinelConn, cmd string, args [][]byte) error {
+	bus := sh.sentinel.events
+	subscribe := cmd == "SUBSCRIBE" || cmd == "PSUBSCRIBE"
+	pattern := cmd == "PSUBSCRIBE" || cmd == "PUNSUBSCRIBE"
+	if subscribe && len(args) == 0 {
+		return c.write(proto.NewError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd))))
+	}
+	if c.sub == nil {
+		c.sub = bus.newSubscriber()
+		go func(sub *subscriber) {
+			for {
+				select {
+				case msg := <-sub.out:
+					if err := c.write(msg); err != nil {
+						logger.Logger.Debug().Err(err).Msg("Sentinel: push event failed")
+					}
+				case <-c.done:
+					return
+				}
+			}
+		}(c.sub)
+	}
+
+	names := make([]string, 0, len(args))
+	for _, arg := range args {
+		names = append(names, string(arg))
+	}
+	if !subscribe && len(names) == 0 {
+		names = bus.subscriptions(c.sub, pattern)
+	}
+	kind := strings.ToLower(cmd)
+	if len(names) == 0 {
+		return c.write(subscriptionReply(kind, nil, 0))
+	}
+	for _, name := range names {
+		count := bus.update(c.sub, pattern, subscribe, name)
+		if err := c.write(subscriptionReply(kind, []byte(name), count)); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+// subscriptionReply 订阅确认：[kind, channel, count]
+func subscriptionReply(kind string, channel []byte, count int) proto.RESP {
+	return &proto.NestedArray{Elems: []proto.RESP{
+		proto.NewBulkString([]byte(kind)),
+		proto.NewBulkString(channel),
+		proto.NewInteger(int64(count)),
+	}}
 }
 
 // executeCommand 执行命令
@@ -83,9 +153,23 @@ func (sh *SentinelHandler) executeCommand(cmd string, args [][]byte) proto.RESP
 	case "PING":
 		return proto.NewSimpleString("PONG")
 
+	case "ROLE":
+		masters := sh.sentinel.Masters()
+		names := make([]proto.RESP, 0, len(masters))
+		for _, master := range masters {
+			names = append(names, proto.NewBulkString([]byte(master.GetName())))
+		}
+		return &proto.NestedArray{Elems: []proto.RESP{
+			proto.NewBulkString([]byte("sentinel")),
+			&proto.NestedArray{Elems: names},
+		}}
+
+	case "INFO":
+		return proto.NewBulkString([]byte(sh.info()))
+
 	case "SENTINEL":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'SENTINEL' command")
+			return proto.NewError("ERR wrong number of arguments for 'sentinel' command")
 		}
 		subcommand := strings.ToUpper(string(args[0]))
 		return sh.handleSentinelCommand(subcommand, args[1:])
@@ -95,77 +179,210 @@ func (sh *SentinelHandler) executeCommand(cmd string, args [][]byte) proto.RESP
 	}
 }
 
+// info INFO 的 Sentinel 部分
+func (sh *SentinelHandler) info() string {
+	masters := sh.sentinel.Masters()
+	var b strings.Builder
+	b.WriteString("# Server\r\n")
+	b.WriteString("redis_mode:sentinel\r\n")
+	b.WriteString("run_id:" + sh.sentinel.GetRunID() + "\r\n")
+	b.WriteString("\r\n# Sentinel\r\n")
+	b.WriteString(fmt.Sprintf("sentinel_masters:%d\r\n", len(masters)))
+	b.WriteString("sentinel_tilt:0\r\n")
+	running := 0
+	for _, master := range masters {
+		if master.FailoverInProgress() {
+			running++
+		}
+	}
+	b.WriteString("sentinel_running_scripts:0\r\n")
+	b.WriteString(fmt.Sprintf("sentinel_failovers_in_progress:%d\r\n", running))
+	for i, master := range masters {
+		status := "ok"
+		if master.IsODown() {
+			status = "odown"
+		} else if master.IsDown() {
+			status = "sdown"
+		}
+		b.WriteString(fmt.Sprintf("master%d:name=%s,status=%s,address=%s,slaves=%d,sentinels=%d\r\n",
+			i, master.GetName(), status, master.GetAddr(), len(master.GetSlaves()), len(master.GetSentinels())+1))
+	}
+	return b.String()
+}
+
 // handleSentinelCommand 处理SENTINEL子命令
 func (sh *SentinelHandler) handleSentinelCommand(subcommand string, args [][]byte) proto.RESP {
+	wrongArgs := proto.NewError(fmt.Sprintf("ERR wrong number of arguments for 'sentinel|%s' command", strings.ToLower(subcommand)))
+	// master 按名称取出被监控的主节点
+	master := func() (*MasterInstance, proto.RESP) {
+		if len(args) < 1 {
+			return nil, wrongArgs
+		}
+		mi := sh.sentinel.GetMaster(string(args[0]))
+		if mi == nil {
+			return nil, proto.NewError("ERR No such master with that name")
+		}
+		return mi, nil
+	}
+
 	switch subcommand {
 	case "MONITOR":
-		if len(args) < 4 {
-			return proto.NewError("ERR wrong number of arguments for 'SENTINEL MONITOR' command")
+		if len(args) != 4 {
+			return wrongArgs
+		}
+		name, ip := string(args[0]), string(args[1])
+		port, err := strconv.Atoi(string(args[2]))
+		if err != nil || port <= 0 || port > 65535 {
+			return proto.NewError("ERR Invalid port number")
 		}
-		name := string(args[0])
-		ip := string(args[1])
-		port := string(args[2])
 		quorum, err := strconv.Atoi(string(args[3]))
 		if err != nil {
-			return proto.NewError("ERR invalid quorum")
+			return proto.NewError("ERR value is not an integer or out of range")
 		}
-		addr := fmt.Sprintf("%s:%s", ip, port)
-		if err := sh.sentinel.AddMaster(name, addr, quorum); err != nil {
+		if net.ParseIP(ip) == nil {
+			addrs, err := net.LookupHost(ip)
+			if err != nil || len(addrs) == 0 {
+				return proto.NewError("ERR Invalid IP address or hostname specified")
+			}
+		}
+		err = sh.sentinel.AddMaster(name, net.JoinHostPort(ip, strconv.Itoa(port)), quorum)
+		switch {
+		case errors.Is(err, ErrDuplicatedMaster):
+			return proto.NewError("ERR Duplicated master name")
+		case errors.Is(err, ErrInvalidQuorum):
+			return proto.NewError("ERR Quorum must be 1 or greater.")
+		case err != nil:
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
 		return proto.OK
 
+	case "REMOVE":
+		if len(args) != 1 {
+			return wrongArgs
+		}
+		if err := sh.sentinel.RemoveMaster(string(args[0])); err != nil {
+			return proto.NewError("ERR No such master with that name")
+		}
+		return proto.OK
+
+	case "SET":
+		mi, errResp := master()
+		if errResp != nil {
+			return errResp
+		}
+		if len(args) < 3 || len(args)%2 != 1 {
+			return wrongArgs
+		}
+		for i := 1; i+1 < len(args); i += 2 {
+			option, value := string(args[i]), string(args[i+1])
+			if err := mi.set(option, value); err != nil {
+				return proto.NewError(fmt.Sprintf("ERR Invalid argument '%s' for SENTINEL SET '%s'", value, option))
+			}
+			sh.sentinel.event("+set", fmt.Sprintf("%s %s %s", mi.describe(), option, value))
+		}
+		return proto.OK
+
 	case "MASTERS":
 		masters := sh.configProvider.GetMasters()
-		results := make([][]byte, 0)
-		for _, master := range masters {
-			// 格式化为数组
-			for k, v := range master {
-				results = append(results, []byte(k), []byte(v))
-			}
+		elems := make([]proto.RESP, 0, len(masters))
+		for _, fields := range masters {
+			elems = append(elems, fieldsReply(fields))
 		}
-		return &proto.Array{Args: results}
+		return &proto.NestedArray{Elems: elems}
 
-	case "SLAVES":
-		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'SENTINEL SLAVES' command")
-		}
-		masterName := string(args[0])
-		slaves := sh.configProvider.GetSlaves(masterName)
-		results := make([][]byte, 0)
-		for _, slave := range slaves {
-			for k, v := range slave {
-				results = append(results, []byte(k), []byte(v))
-			}
+	case "MASTER":
+		mi, errResp := master()
+		if errResp != nil {
+			return errResp
+		}
+		return fieldsReply(sh.configProvider.GetMaster(mi))
+
+	case "REPLICAS", "SLAVES":
+		mi, errResp := master()
+		if errResp != nil {
+			return errResp
+		}
+		slaves := sh.configProvider.GetSlaves(mi)
+		elems := make([]proto.RESP, 0, len(slaves))
+		for _, fields := range slaves {
+			elems = append(elems, fieldsReply(fields))
+		}
+		return &proto.NestedArray{Elems: elems}
+
+	case "SENTINELS":
+		mi, errResp := master()
+		if errResp != nil {
+			return errResp
+		}
+		sentinels := sh.configProvider.GetSentinels(mi)
+		elems := make([]proto.RESP, 0, len(sentinels))
+		for _, fields := range sentinels {
+			elems = append(elems, fieldsReply(fields))
 		}
-		return &proto.Array{Args: results}
+		return &proto.NestedArray{Elems: elems}
 
 	case "GET-MASTER-ADDR-BY-NAME":
-		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'SENTINEL GET-MASTER-ADDR-BY-NAME' command")
+		if len(args) != 1 {
+			return wrongArgs
 		}
-		masterName := string(args[0])
-		addr, err := sh.configProvider.GetMasterAddrByName(masterName)
+		addr, err := sh.configProvider.GetMasterAddrByName(string(args[0]))
 		if err != nil {
-			return &proto.Array{Args: [][]byte{}}
+			return proto.RawString("*-1\r\n")
+		}
+		host, port := splitAddr(addr)
+		return &proto.Array{Args: [][]byte{[]byte(host), []byte(port)}}
+
+	case "IS-MASTER-DOWN-BY-ADDR":
+		// SENTINEL IS-MASTER-DOWN-BY-ADDR <ip> <port> <current-epoch> <runid>
+		if len(args) != 4 {
+			return wrongArgs
 		}
-		parts := strings.Split(addr, ":")
-		if len(parts) != 2 {
-			return &proto.Array{Args: [][]byte{}}
+		port, err1 := strconv.Atoi(string(args[1]))
+		epoch, err2 := strconv.ParseInt(string(args[2]), 10, 64)
+		if err1 != nil || err2 != nil {
+			return proto.NewError("ERR value is not an integer or out of range")
 		}
-		return &proto.Array{Args: [][]byte{[]byte(parts[0]), []byte(parts[1])}}
+		down, leader, leaderEpoch := sh.sentinel.isMasterDownByAddr(string(args[0]), port, epoch, string(args[3]))
+		downFlag := int64(0)
+		if down {
+			downFlag = 1
+		}
+		return &proto.NestedArray{Elems: []proto.RESP{
+			proto.NewInteger(downFlag),
+			proto.NewBulkString([]byte(leader)),
+			proto.NewInteger(leaderEpoch),
+		}}
 
 	case "FAILOVER":
-		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'SENTINEL FAILOVER' command")
+		if len(args) != 1 {
+			return wrongArgs
 		}
-		masterName := string(args[0])
-		if err := sh.failoverMgr.StartFailover(masterName); err != nil {
+		err := sh.sentinel.StartFailover(string(args[0]))
+		switch {
+		case errors.Is(err, ErrNoSuchMaster):
+			return proto.NewError("ERR No such master with that name")
+		case errors.Is(err, ErrNoGoodSlave):
+			return proto.NewError("NOGOODSLAVE No suitable replica to promote")
+		case errors.Is(err, ErrFailoverInProgress):
+			return proto.NewError("INPROG Failover already in progress")
+		case err != nil:
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
 		return proto.OK
 
+	case "MYID":
+		return proto.NewBulkString([]byte(sh.sentinel.GetRunID()))
+
 	default:
 		return proto.NewError(fmt.Sprintf("ERR unknown subcommand '%s'", subcommand))
 	}
 }
+
+// fieldsReply 把交替的字段名与值编码为数组
+func fieldsReply(fields []string) proto.RESP {
+	args := make([][]byte, len(fields))
+	for i, f := range fields {
+		args[i] = []byte(f)
+	}
+	return &proto.Array{Args: args}
+}
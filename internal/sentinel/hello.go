@@ -0,0 +1,208 @@
+package sentinel
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// 哨兵之间通过被监控节点上的 __sentinel__:hello 频道互相发现并传播配置，与 Redis Sentinel 相同。
+// 每个哨兵每隔 helloPeriod 向主节点与从节点发布：
+//
+//	<ip>,<port>,<runid>,<current-epoch>,<master-name>,<master-ip>,<master-port>,<master-config-epoch>
+//
+// 收到的 hello 中主节点配置纪元更大时采用其中的主节点地址，故障转移的结果由此传到没有参与的哨兵
+
+// helloSubscription 在一个被监控节点上订阅 hello 频道的连接，断开后每秒重连
+type helloSubscription struct {
+	addr string
+	stop chan struct{}
+	mu   sync.Mutex
+	conn net.Conn
+	once sync.Once
+}
+
+// syncHelloSubscriptions 保证主节点与每个从节点上都有一个 hello 订阅
+func (mi *MasterInstance) syncHelloSubscriptions(s *Sentinel) {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	want := map[string]bool{mi.addr: true}
+	for addr := range mi.slaves {
+		want[addr] = true
+	}
+	for addr, hs := range mi.helloSubs {
+		if !want[addr] {
+			hs.close()
+			delete(mi.helloSubs, addr)
+		}
+	}
+	for addr := range want {
+		if _, ok := mi.helloSubs[addr]; !ok {
+			hs := &helloSubscription{addr: addr, stop: make(chan struct{})}
+			mi.helloSubs[addr] = hs
+			go hs.run(s, mi)
+		}
+	}
+}
+
+// run 保持订阅直到 close
+func (hs *helloSubscription) run(s *Sentinel, mi *MasterInstance) {
+	for {
+		hs.receive(s, mi)
+		select {
+		case <-hs.stop:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// receive 订阅 hello 频道并处理收到的消息，连接出错时返回
+func (hs *helloSubscription) receive(s *Sentinel, mi *MasterInstance) {
+	conn, err := net.DialTimeout("tcp", hs.addr, defaultCallTimeout)
+	if err != nil {
+		return
+	}
+	hs.mu.Lock()
+	select {
+	case <-hs.stop:
+		hs.mu.Unlock()
+		_ = conn.Close()
+		return
+	default:
+	}
+	hs.conn = conn
+	hs.mu.Unlock()
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if err := writeCommand(conn, []string{"SUBSCRIBE", helloChannel}); err != nil {
+		return
+	}
+	r := bufio.NewReader(conn)
+	for {
+		reply, err := proto.ReadReply(r)
+		if err != nil {
+			return
+		}
+		elems, ok := proto.Elems(reply)
+		if ok && len(elems) == 3 && replyString(elems[0]) == "message" {
+			mi.processHello(s, replyString(elems[2]))
+		}
+	}
+}
+
+// close 停止订阅
+func (hs *helloSubscription) close() {
+	hs.once.Do(func() {
+		hs.mu.Lock()
+		defer hs.mu.Unlock()
+		close(hs.stop)
+		if hs.conn != nil {
+			_ = hs.conn.Close()
+		}
+	})
+}
+
+// sendHello 每隔 helloPeriod 向主节点与在线的从节点发布 hello
+func (mi *MasterInstance) sendHello(s *Sentinel) {
+	type target struct {
+		addr string
+		link *instanceLink
+	}
+	mi.mu.Lock()
+	if time.Since(mi.lastHello) < helloPeriod {
+		mi.mu.Unlock()
+		return
+	}
+	mi.lastHello = time.Now()
+	var targets []target
+	if !mi.sdown {
+		targets = append(targets, target{mi.addr, &mi.link})
+	}
+	for addr, slave := range mi.slaves {
+		if !slave.sdown {
+			targets = append(targets, target{addr, &slave.link})
+		}
+	}
+	mhost, mport := splitAddr(mi.addr)
+	masterPart := fmt.Sprintf("%s,%s,%s,%d", mi.name, mhost, mport, mi.configEpoch)
+	timeout := min(maxPingPeriod, mi.downAfter)
+	mi.mu.Unlock()
+
+	ip, port := s.announceAddr()
+	if port == 0 {
+		return
+	}
+	epoch := s.CurrentEpoch()
+	for _, t := range targets {
+		announceIP := ip
+		if announceIP == "" {
+			// 未配置公布地址时使用连接该节点的本地地址
+			if announceIP = t.link.localIP(); announceIP == "" {
+				continue
+			}
+		}
+		payload := fmt.Sprintf("%s,%d,%s,%d,%s", announceIP, port, s.runID, epoch, masterPart)
+		_, _ = t.link.call(t.addr, timeout, "PUBLISH", helloChannel, payload)
+	}
+}
+
+// processHello 处理收到的 hello：登记新发现的哨兵，跟进更大的纪元，采用配置纪元更大的主节点地址
+func (mi *MasterInstance) processHello(s *Sentinel, payload string) {
+	parts := strings.Split(payload, ",")
+	if len(parts) != 8 {
+		return
+	}
+	ip, port, runID, masterName := parts[0], parts[1], parts[2], parts[4]
+	currentEpoch, err1 := strconv.ParseInt(parts[3], 10, 64)
+	configEpoch, err2 := strconv.ParseInt(parts[7], 10, 64)
+	if err1 != nil || err2 != nil || runID == s.runID || masterName != mi.name {
+		return
+	}
+	addr := net.JoinHostPort(ip, port)
+	masterAddr := net.JoinHostPort(parts[5], parts[6])
+
+	mi.mu.Lock()
+	si, known := mi.sentinels[runID]
+	if !known {
+		// 同一地址出现新的运行ID说明哨兵重启过，替换旧记录
+		for id, other := range mi.sentinels {
+			if other.Addr == addr {
+				other.link.close()
+				delete(mi.sentinels, id)
+			}
+		}
+		si = NewSentinelInstance(addr, runID)
+		mi.sentinels[runID] = si
+	} else if si.Addr != addr {
+		si.Addr = addr
+		si.link.close()
+	}
+	si.lastHello = time.Now()
+	switchTo := ""
+	if configEpoch > mi.configEpoch {
+		if masterAddr != mi.addr {
+			switchTo = masterAddr
+		} else {
+			mi.configEpoch = configEpoch
+		}
+	}
+	mi.mu.Unlock()
+
+	if !known {
+		s.event("+sentinel", mi.describeOther("sentinel", runID, addr))
+	}
+	s.observeEpoch(currentEpoch)
+	if switchTo != "" {
+		s.event("+config-update-from", mi.describeOther("sentinel", runID, addr))
+		s.switchMaster(mi, switchTo, configEpoch)
+	}
+}
@@ -1,264 +1,567 @@
 package sentinel
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/lbp0200/BoltDB/internal/logger"
 )
 
-// MasterInstance 主节点实例
+const (
+	// maxPingPeriod PING 被监控节点的最长间隔，down-after 更短时按 down-after 的间隔
+	maxPingPeriod = time.Second
+	// infoPeriod INFO 的间隔；主节点下线或故障转移期间与 PING 相同
+	infoPeriod = 10 * time.Second
+	// reconfWait 节点上报的角色或主节点与配置不一致持续这么久后才重新配置，
+	// 给其他哨兵通过 hello 传播新配置的时间
+	reconfWait = 4 * helloPeriod
+)
+
+// errInvalidArgument SENTINEL SET 的选项或值无效
+var errInvalidArgument = errors.New("invalid argument")
+
+// MasterInstance 被监控的主节点，以及通过它发现的从节点与其他哨兵
 type MasterInstance struct {
-	mu            sync.RWMutex
-	name          string
-	addr          string
-	quorum        int
-	slaves        []*SlaveInstance
-	sentinels     []*SentinelInstance
-	state         string // "ok" | "odown" | "sdown" | "failover"
-	lastPingTime  time.Time
-	lastPongTime  time.Time
-	downAfter     time.Duration
-	stopCh        chan struct{}
-	// 主观下线计数
-	sdownCount int
-	// 已知哨兵数量
-	knownSentinelCount int
+	mu              sync.RWMutex
+	name            string
+	addr            string
+	quorum          int
+	downAfter       time.Duration
+	failoverTimeout time.Duration
+	parallelSyncs   int
+	configEpoch     int64
+	runID           string
+	slaves          map[string]*SlaveInstance    // 地址 -> 从节点
+	sentinels       map[string]*SentinelInstance // 运行ID -> 哨兵
+
+	link         instanceLink
+	helloSubs    map[string]*helloSubscription // 地址 -> hello 订阅
+	lastHello    time.Time
+	lastPingTime time.Time
+	lastPongTime time.Time
+	infoRefresh  time.Time
+	roleReported string
+
+	sdown      bool
+	sdownSince time.Time
+	odown      bool
+	odownSince time.Time
+
+	// 本纪元投票选出的领头者
+	leader      string
+	leaderEpoch int64
+	// failoverState 为空表示没有进行中的故障转移
+	failoverState     string
+	failoverEpoch     int64
+	failoverStartTime time.Time
+	promotedSlave     string
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
 }
 
 // NewMasterInstance 创建新的主节点实例
 func NewMasterInstance(name, addr string, quorum int) *MasterInstance {
 	return &MasterInstance{
-		name:               name,
-		addr:               addr,
-		quorum:             quorum,
-		slaves:             make([]*SlaveInstance, 0),
-		sentinels:          make([]*SentinelInstance, 0),
-		state:              "ok",
-		downAfter:          30 * time.Second,
-		stopCh:             make(chan struct{}),
-		sdownCount:         0,
-		knownSentinelCount: 1,
+		name:            name,
+		addr:            addr,
+		quorum:          quorum,
+		downAfter:       DefaultDownAfter,
+		failoverTimeout: DefaultFailoverTimeout,
+		parallelSyncs:   1,
+		slaves:          make(map[string]*SlaveInstance),
+		sentinels:       make(map[string]*SentinelInstance),
+		helloSubs:       make(map[string]*helloSubscription),
+		lastPongTime:    time.Now(),
+		stopCh:          make(chan struct{}),
 	}
 }
 
-// StartMonitoring 开始监控主节点
-func (mi *MasterInstance) StartMonitoring(sentinel *Sentinel) {
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-mi.stopCh:
-			return
-		case <-ticker.C:
-			mi.checkMaster(sentinel)
-		}
-	}
+// GetName 获取名称
+func (mi *MasterInstance) GetName() string {
+	return mi.name
 }
 
-// checkMaster 检查主节点状态
-func (mi *MasterInstance) checkMaster(sentinel *Sentinel) {
-	// 发送PING
-	mi.mu.Lock()
-	mi.lastPingTime = time.Now()
-	mi.mu.Unlock()
+// GetAddr 获取当前地址，故障转移后为新主节点的地址
+func (mi *MasterInstance) GetAddr() string {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	return mi.addr
+}
 
-	// 检查是否超时
-	elapsed := time.Since(mi.lastPingTime)
-
-	if elapsed > mi.downAfter {
-		// 主节点可能下线
-		mi.mu.Lock()
-		if mi.state == "ok" {
-			mi.state = "sdown" // 主观下线
-			mi.sdownCount++
-			logger.Logger.Warn().
-				Str("master_name", mi.name).
-				Str("master_addr", mi.addr).
-				Int("sdown_count", mi.sdownCount).
-				Int("quorum", mi.quorum).
-				Msg("主节点主观下线")
-
-			// 尝试同步sdown状态到其他哨兵
-			sentinel.BroadcastSdown(mi.name)
-		}
-		mi.mu.Unlock()
-	} else {
-		// 主节点在线
-		mi.mu.Lock()
-		if mi.state == "sdown" {
-			mi.state = "ok"
-			mi.sdownCount = 0
-			logger.Logger.Info().
-				Str("master_name", mi.name).
-				Str("master_addr", mi.addr).
-				Msg("主节点恢复")
-		}
-		mi.mu.Unlock()
-	}
+// GetQuorum 获取quorum数量
+func (mi *MasterInstance) GetQuorum() int {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	return mi.quorum
 }
 
-// AddSlave 添加从节点
-func (mi *MasterInstance) AddSlave(slave *SlaveInstance) {
-	mi.mu.Lock()
-	defer mi.mu.Unlock()
-	mi.slaves = append(mi.slaves, slave)
+// ConfigEpoch 当前配置对应的纪元
+func (mi *MasterInstance) ConfigEpoch() int64 {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	return mi.configEpoch
 }
 
-// GetSlaves 获取所有从节点
+// GetSlaves 获取所有从节点，按地址排序
 func (mi *MasterInstance) GetSlaves() []*SlaveInstance {
 	mi.mu.RLock()
 	defer mi.mu.RUnlock()
-	result := make([]*SlaveInstance, len(mi.slaves))
-	copy(result, mi.slaves)
+	result := make([]*SlaveInstance, 0, len(mi.slaves))
+	for _, slave := range mi.slaves {
+		result = append(result, slave)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Addr < result[j].Addr })
 	return result
 }
 
-// AddSentinel 添加哨兵实例
-func (mi *MasterInstance) AddSentinel(sentinel *SentinelInstance) {
-	mi.mu.Lock()
-	defer mi.mu.Unlock()
-	mi.sentinels = append(mi.sentinels, sentinel)
-	mi.knownSentinelCount++
+// GetSentinels 获取监控同一主节点的其他哨兵，按地址排序
+func (mi *MasterInstance) GetSentinels() []*SentinelInstance {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	result := make([]*SentinelInstance, 0, len(mi.sentinels))
+	for _, si := range mi.sentinels {
+		result = append(result, si)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Addr < result[j].Addr })
+	return result
 }
 
-// GetState 获取状态
-func (mi *MasterInstance) GetState() string {
+// IsDown 是否主观下线
+func (mi *MasterInstance) IsDown() bool {
 	mi.mu.RLock()
 	defer mi.mu.RUnlock()
-	return mi.state
+	return mi.sdown
 }
 
-// SetState 设置状态
-func (mi *MasterInstance) SetState(state string) {
-	mi.mu.Lock()
-	defer mi.mu.Unlock()
-	mi.state = state
+// IsODown 是否客观下线：包括本哨兵在内至少 quorum 个哨兵认为主节点下线
+func (mi *MasterInstance) IsODown() bool {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	return mi.odown
 }
 
-// GetName 获取名称
-func (mi *MasterInstance) GetName() string {
-	return mi.name
+// FailoverInProgress 是否有进行中的故障转移
+func (mi *MasterInstance) FailoverInProgress() bool {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	return mi.failoverState != ""
 }
 
-// GetAddr 获取地址
-func (mi *MasterInstance) GetAddr() string {
-	return mi.addr
+// Stop 停止监控
+func (mi *MasterInstance) Stop() {
+	mi.stopOnce.Do(func() {
+		close(mi.stopCh)
+	})
 }
 
-// SetAddr 设置地址
-func (mi *MasterInstance) SetAddr(addr string) {
-	mi.mu.Lock()
-	defer mi.mu.Unlock()
-	mi.addr = addr
+// describe 事件中主节点的描述：master <name> <ip> <port>
+func (mi *MasterInstance) describe() string {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	return mi.describeLocked()
 }
 
-// GetQuorum 获取quorum数量
-func (mi *MasterInstance) GetQuorum() int {
+func (mi *MasterInstance) describeLocked() string {
+	host, port := splitAddr(mi.addr)
+	return fmt.Sprintf("master %s %s %s", mi.name, host, port)
+}
+
+// describeOther 事件中从节点或哨兵的描述：<type> <name> <ip> <port> @ <master> <master-ip> <master-port>
+func (mi *MasterInstance) describeOther(typ, name, addr string) string {
 	mi.mu.RLock()
 	defer mi.mu.RUnlock()
-	return mi.quorum
+	host, port := splitAddr(addr)
+	mhost, mport := splitAddr(mi.addr)
+	return fmt.Sprintf("%s %s %s %s @ %s %s %s", typ, name, host, port, mi.name, mhost, mport)
 }
 
-// GetSdownCount 获取主观下线计数
-func (mi *MasterInstance) GetSdownCount() int {
+// flags SENTINEL MASTERS 中的 flags 字段
+func (mi *MasterInstance) flags() string {
 	mi.mu.RLock()
 	defer mi.mu.RUnlock()
-	return mi.sdownCount
+	flags := []string{"master"}
+	if mi.sdown {
+		flags = append(flags, "s_down")
+	}
+	if mi.odown {
+		flags = append(flags, "o_down")
+	}
+	if mi.failoverState != "" {
+		flags = append(flags, "failover_in_progress")
+	}
+	return strings.Join(flags, ",")
 }
 
-// IncrSdownCount 增加主观下线计数
-func (mi *MasterInstance) IncrSdownCount() {
+// period PING 与检查下线状态的间隔
+func (mi *MasterInstance) period() time.Duration {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	return min(maxPingPeriod, mi.downAfter)
+}
+
+// sleep 等待 d，停止监控时返回 false
+func (mi *MasterInstance) sleep(d time.Duration) bool {
+	select {
+	case <-mi.stopCh:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// set SENTINEL SET 修改一个选项
+func (mi *MasterInstance) set(option, value string) error {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		return errInvalidArgument
+	}
 	mi.mu.Lock()
 	defer mi.mu.Unlock()
-	mi.sdownCount++
+	switch strings.ToLower(option) {
+	case "down-after-milliseconds":
+		mi.downAfter = time.Duration(n) * time.Millisecond
+	case "failover-timeout":
+		mi.failoverTimeout = time.Duration(n) * time.Millisecond
+	case "parallel-syncs":
+		mi.parallelSyncs = int(n)
+	case "quorum":
+		mi.quorum = int(n)
+	default:
+		return errInvalidArgument
+	}
+	return nil
 }
 
-// IsDown 检查是否下线（主观或客观）
-func (mi *MasterInstance) IsDown() bool {
+// monitor 周期性检查主节点、从节点与其他哨兵，直到停止监控
+func (mi *MasterInstance) monitor(s *Sentinel) {
+	defer mi.closeLinks()
+	for {
+		select {
+		case <-mi.stopCh:
+			return
+		case <-s.stopCh:
+			return
+		case <-time.After(mi.period()):
+		}
+		mi.tick(s)
+	}
+}
+
+// tick 一次检查：刷新主从状态、交换 hello、判断主观与客观下线，需要时开始故障转移
+func (mi *MasterInstance) tick(s *Sentinel) {
+	mi.refreshMaster(s)
+	mi.refreshSlaves(s)
+	mi.syncHelloSubscriptions(s)
+	mi.sendHello(s)
+	mi.checkSubjectivelyDown(s)
+	mi.checkObjectivelyDown(s)
+	if mi.tryStartFailover(false) {
+		go s.failover(mi, false)
+	}
+}
+
+// refreshMaster PING 主节点，需要时读取 INFO 发现从节点
+func (mi *MasterInstance) refreshMaster(s *Sentinel) {
+	timeout := mi.period()
 	mi.mu.RLock()
-	defer mi.mu.RUnlock()
-	return mi.state == "sdown" || mi.state == "odown" || mi.state == "failover"
+	addr := mi.addr
+	needInfo := mi.sdown || mi.failoverState != "" || time.Since(mi.infoRefresh) >= infoPeriod
+	mi.mu.RUnlock()
+
+	reply, err := mi.link.call(addr, timeout, "PING")
+	now := time.Now()
+	mi.mu.Lock()
+	mi.lastPingTime = now
+	if err == nil && validPingReply(reply) {
+		mi.lastPongTime = now
+	}
+	mi.mu.Unlock()
+	if err != nil || !needInfo {
+		return
+	}
+	reply, err = mi.link.call(addr, timeout, "INFO")
+	if err != nil {
+		return
+	}
+	info := parseInfo(replyString(reply))
+
+	var discovered []string
+	mi.mu.Lock()
+	if mi.addr != addr {
+		// 故障转移期间地址已经改变
+		mi.mu.Unlock()
+		return
+	}
+	mi.infoRefresh = now
+	mi.runID = info["run_id"]
+	mi.roleReported = info["role"]
+	if mi.roleReported == "master" {
+		for key, value := range info {
+			if !strings.HasPrefix(key, "slave") || !isDigits(key[len("slave"):]) {
+				continue
+			}
+			slaveAddr := parseSlaveLine(value)
+			if slaveAddr == "" || slaveAddr == mi.addr {
+				continue
+			}
+			if _, ok := mi.slaves[slaveAddr]; !ok {
+				mi.slaves[slaveAddr] = NewSlaveInstance(slaveAddr)
+				discovered = append(discovered, slaveAddr)
+			}
+		}
+	}
+	mi.mu.Unlock()
+	sort.Strings(discovered)
+	for _, slaveAddr := range discovered {
+		s.event("+slave", mi.describeOther("slave", slaveAddr, slaveAddr))
+	}
 }
 
-// IsODown 检查是否客观下线
-// 客观下线需要满足：
-// 1. 超过 quorum 数量的哨兵报告主观下线
-// 2. 多个哨兵之间需要达成共识
-func (mi *MasterInstance) IsODown() bool {
+// refreshSlaves 并发 PING 所有从节点并读取 INFO，更新下线状态，纠正角色或主节点不符合配置的节点
+func (mi *MasterInstance) refreshSlaves(s *Sentinel) {
+	timeout := mi.period()
 	mi.mu.RLock()
-	state := mi.state
-	sdownCount := mi.sdownCount
-	quorum := mi.quorum
+	fast := mi.sdown || mi.failoverState != ""
 	mi.mu.RUnlock()
 
-	// 如果已经是客观下线状态，直接返回
-	if state == "odown" || state == "failover" {
-		return true
+	var wg sync.WaitGroup
+	for _, slave := range mi.GetSlaves() {
+		wg.Add(1)
+		go func(slave *SlaveInstance) {
+			defer wg.Done()
+			reply, err := slave.link.call(slave.Addr, timeout, "PING")
+			now := time.Now()
+			mi.mu.Lock()
+			slave.lastPingTime = now
+			if err == nil && validPingReply(reply) {
+				slave.lastPongTime = now
+			}
+			needInfo := fast || time.Since(slave.infoRefresh) >= infoPeriod
+			mi.mu.Unlock()
+			if err != nil || !needInfo {
+				return
+			}
+			reply, err = slave.link.call(slave.Addr, timeout, "INFO")
+			if err != nil {
+				return
+			}
+			info := parseInfo(replyString(reply))
+			mi.mu.Lock()
+			slave.applyInfo(info, now)
+			mi.mu.Unlock()
+		}(slave)
 	}
+	wg.Wait()
 
-	// 检查是否达到quorum
-	// 实际应该从其他哨兵同步sdown状态，这里简化处理
-	if sdownCount >= quorum {
-		logger.Logger.Info().
-			Str("master_name", mi.name).
-			Int("sdown_count", sdownCount).
-			Int("quorum", quorum).
-			Msg("主节点已达到客观下线条件")
-		return true
+	for _, slave := range mi.GetSlaves() {
+		mi.checkSlaveDown(s, slave)
+		mi.reconfigureSlave(s, slave)
 	}
+}
 
-	return false
+// checkSlaveDown 从节点超过 down-after 没有有效回复时主观下线
+func (mi *MasterInstance) checkSlaveDown(s *Sentinel, slave *SlaveInstance) {
+	mi.mu.Lock()
+	down := time.Since(slave.lastPongTime) > mi.downAfter
+	changed := down != slave.sdown
+	slave.sdown = down
+	mi.mu.Unlock()
+	if !changed {
+		return
+	}
+	desc := mi.describeOther("slave", slave.Addr, slave.Addr)
+	if down {
+		s.event("+sdown", desc)
+	} else {
+		s.event("-sdown", desc)
+	}
 }
 
-// SetODown 设置为客观下线状态
-func (mi *MasterInstance) SetODown() {
+// reconfigureSlave 主节点正常且没有故障转移时，把自认为是主节点或复制其他主节点的从节点重新指向当前主节点。
+// 不一致需要持续 reconfWait，以免在其他哨兵的新配置通过 hello 到达之前把刚提升的节点改回去
+func (mi *MasterInstance) reconfigureSlave(s *Sentinel, slave *SlaveInstance) {
 	mi.mu.Lock()
-	defer mi.mu.Unlock()
-	if mi.state != "failover" {
-		mi.state = "odown"
+	sane := !mi.sdown && mi.roleReported == "master" && mi.failoverState == "" && time.Since(mi.infoRefresh) < 2*infoPeriod
+	if !sane || slave.sdown || slave.infoRefresh.IsZero() || time.Since(slave.reconfSent) < reconfWait {
+		mi.mu.Unlock()
+		return
+	}
+	event := ""
+	switch {
+	case slave.RoleReported == "master" && time.Since(slave.roleReportedTime) > reconfWait:
+		event = "+convert-to-slave"
+	case slave.RoleReported == "slave" && slave.MasterLinkStatus != "up" &&
+		net.JoinHostPort(slave.MasterHost, strconv.Itoa(slave.MasterPort)) != mi.addr &&
+		time.Since(slave.masterChangeTime) > reconfWait:
+		event = "+fix-slave-config"
+	}
+	if event == "" {
+		mi.mu.Unlock()
+		return
+	}
+	slave.reconfSent = time.Now()
+	masterAddr := mi.addr
+	mi.mu.Unlock()
+
+	if err := SendReplicaOf(slave.Addr, masterAddr); err != nil {
+		logger.Logger.Warn().Err(err).Str("slave", slave.Addr).Msg("重新配置从节点失败")
+		return
 	}
+	s.event(event, mi.describeOther("slave", slave.Addr, slave.Addr))
 }
 
-// Stop 停止监控
-func (mi *MasterInstance) Stop() {
-	close(mi.stopCh)
+// checkSubjectivelyDown 主节点超过 down-after 没有有效回复时主观下线，恢复后同时取消客观下线
+func (mi *MasterInstance) checkSubjectivelyDown(s *Sentinel) {
+	mi.mu.Lock()
+	down := time.Since(mi.lastPongTime) > mi.downAfter
+	var events []string
+	switch {
+	case down && !mi.sdown:
+		mi.sdown, mi.sdownSince = true, time.Now()
+		events = append(events, "+sdown")
+	case !down && mi.sdown:
+		mi.sdown = false
+		events = append(events, "-sdown")
+		if mi.odown {
+			mi.odown = false
+			events = append(events, "-odown")
+		}
+		for _, si := range mi.sentinels {
+			si.masterDown = false
+		}
+	}
+	desc := mi.describeLocked()
+	mi.mu.Unlock()
+	for _, ev := range events {
+		s.event(ev, desc)
+	}
 }
 
-// GetSentinelCount 获取已知哨兵数量
-func (mi *MasterInstance) GetSentinelCount() int {
-	mi.mu.RLock()
-	defer mi.mu.RUnlock()
-	return mi.knownSentinelCount
+// checkObjectivelyDown 主观下线时询问其他哨兵，包括本哨兵在内认为下线的哨兵达到 quorum 时客观下线
+func (mi *MasterInstance) checkObjectivelyDown(s *Sentinel) {
+	if !mi.IsDown() {
+		return
+	}
+	s.askSentinels(mi, "*")
+
+	mi.mu.Lock()
+	validity := 5 * min(maxPingPeriod, mi.downAfter)
+	votes := 1
+	for _, si := range mi.sentinels {
+		if si.masterDown && time.Since(si.downReplyTime) < validity {
+			votes++
+		}
+	}
+	var event string
+	switch {
+	case votes >= mi.quorum && !mi.odown:
+		mi.odown, mi.odownSince = true, time.Now()
+		event = "+odown"
+	case votes < mi.quorum && mi.odown:
+		mi.odown = false
+		event = "-odown"
+	}
+	desc := mi.describeLocked()
+	quorum := mi.quorum
+	mi.mu.Unlock()
+	switch event {
+	case "+odown":
+		s.event(event, fmt.Sprintf("%s #quorum %d/%d", desc, votes, quorum))
+	case "-odown":
+		s.event(event, desc)
+	}
 }
 
-// UpdateSlaveOffset 更新从节点偏移量
-func (mi *MasterInstance) UpdateSlaveOffset(slaveAddr string, offset int64) {
-	mi.mu.RLock()
-	defer mi.mu.RUnlock()
+// tryStartFailover 标记开始故障转移。自动故障转移要求客观下线，且距上一次尝试超过两倍 failover-timeout
+func (mi *MasterInstance) tryStartFailover(forced bool) bool {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	if mi.failoverState != "" {
+		return false
+	}
+	if !forced && (!mi.odown || time.Since(mi.failoverStartTime) < 2*mi.failoverTimeout) {
+		return false
+	}
+	mi.failoverState = "wait-start"
+	mi.failoverStartTime = time.Now()
+	mi.promotedSlave = ""
+	return true
+}
+
+// closeLinks 关闭到主节点、从节点与其他哨兵的连接
+func (mi *MasterInstance) closeLinks() {
+	mi.link.close()
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	for addr, hs := range mi.helloSubs {
+		hs.close()
+		delete(mi.helloSubs, addr)
+	}
 	for _, slave := range mi.slaves {
-		if slave.Addr == slaveAddr {
-			slave.Offset = offset
-			break
+		slave.link.close()
+	}
+	for _, si := range mi.sentinels {
+		si.link.close()
+	}
+}
+
+// parseInfo 解析 INFO 输出的 key:value 行
+func parseInfo(text string) map[string]string {
+	info := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			info[key] = value
 		}
 	}
+	return info
 }
 
-// GetBestSlave 获取最佳从节点（偏移量最大的）
-func (mi *MasterInstance) GetBestSlave() *SlaveInstance {
-	mi.mu.RLock()
-	defer mi.mu.RUnlock()
+// parseSlaveLine 解析 INFO 中的 slaveN:ip=...,port=...,state=...,offset=...,lag=...，返回从节点地址
+func parseSlaveLine(value string) string {
+	var ip, port string
+	for _, field := range strings.Split(value, ",") {
+		k, v, _ := strings.Cut(field, "=")
+		switch k {
+		case "ip":
+			ip = v
+		case "port":
+			port = v
+		}
+	}
+	if ip == "" || port == "" || port == "0" {
+		return ""
+	}
+	return net.JoinHostPort(ip, port)
+}
 
-	var best *SlaveInstance
-	var bestOffset int64 = -1
+// splitAddr 拆分 host:port，格式错误时整体作为 host
+func splitAddr(addr string) (string, string) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, ""
+	}
+	return host, port
+}
 
-	for _, slave := range mi.slaves {
-		if slave.State == "online" && slave.Offset > bestOffset {
-			bestOffset = slave.Offset
-			best = slave
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
 		}
 	}
-
-	return best
+	return true
 }
@@ -2,126 +2,161 @@ package sentinel
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/proto"
 )
 
-// SentinelConnection 哨兵连接
-type SentinelConnection struct {
-	conn   net.Conn
-	reader *bufio.Reader
-	writer *bufio.Writer
+// defaultCallTimeout 单条命令（含建立连接）的超时
+const defaultCallTimeout = time.Second
+
+// instanceLink 到一个节点（主节点、从节点或其他哨兵）的命令连接。
+// 连接在第一次调用时建立，出错或目标地址变化时关闭，下一次调用重新连接
+type instanceLink struct {
+	mu   sync.Mutex
+	addr string
+	conn net.Conn
+	r    *bufio.Reader
 }
 
-// NewSentinelConnection 创建新的哨兵连接
-func NewSentinelConnection(addr string) (*SentinelConnection, error) {
-	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+// call 向 addr 发送一条命令并读取回复；错误回复（如 -LOADING）作为 *proto.Error 返回，err 只表示网络错误
+func (l *instanceLink) call(addr string, timeout time.Duration, args ...string) (proto.RESP, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn != nil && l.addr != addr {
+		l.closeLocked()
+	}
+	if l.conn == nil {
+		conn, err := net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			return nil, err
+		}
+		l.addr, l.conn, l.r = addr, conn, bufio.NewReader(conn)
+	}
+	if err := l.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		l.closeLocked()
+		return nil, err
+	}
+	if err := writeCommand(l.conn, args); err != nil {
+		l.closeLocked()
+		return nil, err
+	}
+	reply, err := proto.ReadReply(l.r)
 	if err != nil {
-		return nil, fmt.Errorf("connect to %s failed: %w", addr, err)
+		l.closeLocked()
+		return nil, err
 	}
+	return reply, nil
+}
 
-	return &SentinelConnection{
-		conn:   conn,
-		reader: bufio.NewReader(conn),
-		writer: bufio.NewWriter(conn),
-	}, nil
+// localIP 当前连接的本地 IP，未连接时返回空字符串
+func (l *instanceLink) localIP() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(l.conn.LocalAddr().String())
+	if err != nil {
+		return ""
+	}
+	return host
 }
 
-// Close 关闭连接
-func (sc *SentinelConnection) Close() error {
-	return sc.conn.Close()
+// close 关闭连接
+func (l *instanceLink) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closeLocked()
 }
 
-// SendCommand 发送命令
-func (sc *SentinelConnection) SendCommand(cmd string) error {
-	_, err := sc.writer.WriteString(cmd)
-	if err != nil {
-		return err
+func (l *instanceLink) closeLocked() {
+	if l.conn == nil {
+		return
+	}
+	if err := l.conn.Close(); err != nil {
+		logger.Logger.Debug().Err(err).Str("addr", l.addr).Msg("failed to close sentinel link")
 	}
-	return sc.writer.Flush()
+	l.conn, l.r = nil, nil
 }
 
-// ReadResponse 读取响应
-func (sc *SentinelConnection) ReadResponse() (string, error) {
-	line, err := sc.reader.ReadString('\n')
-	if err != nil {
-		return "", err
+// writeCommand 以 RESP 数组写入一条命令
+func writeCommand(w net.Conn, args []string) error {
+	req := &proto.Array{Args: make([][]byte, len(args))}
+	for i, arg := range args {
+		req.Args[i] = []byte(arg)
 	}
-	return strings.TrimSpace(line), nil
+	_, err := w.Write([]byte(req.String()))
+	return err
 }
 
-// SendSlaveOfNoOne 发送 SLAVEOF NO ONE 命令将节点提升为主节点
-func SendSlaveOfNoOne(addr string) error {
-	sc, err := NewSentinelConnection(addr)
+// callOnce 建立临时连接执行一条命令
+func callOnce(addr string, args ...string) (proto.RESP, error) {
+	var l instanceLink
+	defer l.close()
+	reply, err := l.call(addr, defaultCallTimeout, args...)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("%s %s: %w", args[0], addr, err)
 	}
-	defer func() {
-		if err := sc.Close(); err != nil {
-			logger.Logger.Debug().Err(err).Msg("Failed to close sentinel connection")
-		}
-	}()
-
-	// 发送 SLAVEOF NO ONE
-	cmd := "*3\r\n$8\r\nSLAVEOF\r\n$2\r\nNO\r\n$3\r\nONE\r\n"
-	if err := sc.SendCommand(cmd); err != nil {
-		return fmt.Errorf("send SLAVEOF NO ONE failed: %w", err)
+	if e, ok := reply.(*proto.Error); ok {
+		return nil, fmt.Errorf("%s %s: %s", args[0], addr, string(*e))
 	}
+	return reply, nil
+}
 
-	// 读取响应
-	resp, err := sc.ReadResponse()
-	if err != nil {
-		return fmt.Errorf("read SLAVEOF NO ONE response failed: %w", err)
+// replyString 取出字符串或整数回复的内容，其他类型返回空字符串
+func replyString(reply proto.RESP) string {
+	switch r := reply.(type) {
+	case *proto.BulkString:
+		if r == nil {
+			return ""
+		}
+		return string(*r)
+	case *proto.SimpleString:
+		return string(*r)
+	case *proto.Integer:
+		return strconv.FormatInt(int64(*r), 10)
 	}
+	return ""
+}
 
-	if !strings.HasPrefix(resp, "+OK") && resp != "+OK" {
-		return fmt.Errorf("SLAVEOF NO ONE failed: %s", resp)
+// validPingReply 与 Redis 相同，PONG 以及 LOADING、MASTERDOWN 错误都说明节点仍然存活
+func validPingReply(reply proto.RESP) bool {
+	switch r := reply.(type) {
+	case *proto.SimpleString:
+		return string(*r) == "PONG"
+	case *proto.Error:
+		msg := string(*r)
+		return strings.HasPrefix(msg, "LOADING") || strings.HasPrefix(msg, "MASTERDOWN")
 	}
+	return false
+}
 
-	logger.Logger.Info().Str("addr", addr).Msg("Successfully sent SLAVEOF NO ONE")
+// SendSlaveOfNoOne 发送 REPLICAOF NO ONE 将从节点提升为主节点
+func SendSlaveOfNoOne(addr string) error {
+	if _, err := callOnce(addr, "REPLICAOF", "NO", "ONE"); err != nil {
+		return err
+	}
+	logger.Logger.Info().Str("addr", addr).Msg("Successfully sent REPLICAOF NO ONE")
 	return nil
 }
 
 // SendReplicaOf 发送 REPLICAOF 命令配置从节点复制新主节点
 func SendReplicaOf(slaveAddr, masterAddr string) error {
-	sc, err := NewSentinelConnection(slaveAddr)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err := sc.Close(); err != nil {
-			logger.Logger.Debug().Err(err).Msg("Failed to close sentinel connection")
-		}
-	}()
-
-	// 解析master地址
-	masterHost, masterPort, err := net.SplitHostPort(masterAddr)
+	host, port, err := net.SplitHostPort(masterAddr)
 	if err != nil {
 		return fmt.Errorf("invalid master address %s: %w", masterAddr, err)
 	}
-
-	// 发送 REPLICAOF <host> <port>
-	cmd := fmt.Sprintf("*4\r\n$8\r\nREPLICAOF\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n",
-		len(masterHost), masterHost, len(masterPort), masterPort)
-
-	if err := sc.SendCommand(cmd); err != nil {
-		return fmt.Errorf("send REPLICAOF failed: %w", err)
-	}
-
-	// 读取响应
-	resp, err := sc.ReadResponse()
-	if err != nil {
-		return fmt.Errorf("read REPLICAOF response failed: %w", err)
-	}
-
-	if !strings.HasPrefix(resp, "+OK") && resp != "+OK" {
-		return fmt.Errorf("REPLICAOF failed: %s", resp)
+	if _, err := callOnce(slaveAddr, "REPLICAOF", host, port); err != nil {
+		return err
 	}
-
 	logger.Logger.Info().
 		Str("slave", slaveAddr).
 		Str("master", masterAddr).
@@ -131,88 +166,33 @@ func SendReplicaOf(slaveAddr, masterAddr string) error {
 
 // SendPing 发送PING命令检查节点是否存活
 func SendPing(addr string) (bool, error) {
-	sc, err := NewSentinelConnection(addr)
+	var l instanceLink
+	defer l.close()
+	reply, err := l.call(addr, defaultCallTimeout, "PING")
 	if err != nil {
 		return false, err
 	}
-	defer func() {
-		if err := sc.Close(); err != nil {
-			logger.Logger.Debug().Err(err).Msg("Failed to close sentinel connection")
-		}
-	}()
-
-	// 发送 PING
-	cmd := "*1\r\n$4\r\nPING\r\n"
-	if err := sc.SendCommand(cmd); err != nil {
-		return false, err
-	}
-
-	// 读取响应
-	resp, err := sc.ReadResponse()
-	if err != nil {
-		return false, err
-	}
-
-	return strings.HasPrefix(resp, "+PONG") || resp == "+PONG", nil
+	return validPingReply(reply), nil
 }
 
 // SendInfoReplication 发送 INFO replication 命令获取复制信息
 func SendInfoReplication(addr string) (string, error) {
-	sc, err := NewSentinelConnection(addr)
+	reply, err := callOnce(addr, "INFO", "replication")
 	if err != nil {
 		return "", err
 	}
-	defer func() {
-		if err := sc.Close(); err != nil {
-			logger.Logger.Debug().Err(err).Msg("Failed to close sentinel connection")
-		}
-	}()
-
-	// 发送 INFO replication
-	cmd := "*2\r\n$4\r\nINFO\r\n$11\r\nreplication\r\n"
-	if err := sc.SendCommand(cmd); err != nil {
-		return "", err
-	}
-
-	// 读取响应（多行）
-	var resp strings.Builder
-	for {
-		line, err := sc.reader.ReadString('\n')
-		if err != nil {
-			break
-		}
-		resp.WriteString(line)
-		if line == "\r\n" || line == "\n" {
-			break
-		}
-	}
-
-	return resp.String(), nil
+	return replyString(reply), nil
 }
 
-// GetRole 获取节点角色
+// GetRole 获取节点角色（ROLE 回复的第一个元素：master、slave 或 sentinel）
 func GetRole(addr string) (string, error) {
-	sc, err := NewSentinelConnection(addr)
+	reply, err := callOnce(addr, "ROLE")
 	if err != nil {
 		return "", err
 	}
-	defer func() {
-		if err := sc.Close(); err != nil {
-			logger.Logger.Debug().Err(err).Msg("Failed to close sentinel connection")
-		}
-	}()
-
-	// 发送 ROLE
-	cmd := "*1\r\n$4\r\nROLE\r\n"
-	if err := sc.SendCommand(cmd); err != nil {
-		return "", err
-	}
-
-	// 读取响应
-	resp, err := sc.ReadResponse()
-	if err != nil {
-		return "", err
+	elems, ok := proto.Elems(reply)
+	if !ok || len(elems) == 0 {
+		return "", errors.New("unexpected ROLE reply")
 	}
-
-	return resp, nil
+	return replyString(elems[0]), nil
 }
@@ -1,78 +1,116 @@
 package sentinel
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/lbp0200/BoltDB/internal/logger"
 )
 
-// Sentinel 哨兵实例
-type Sentinel struct {
-	mu            sync.RWMutex
-	masters       map[string]*MasterInstance
-	quorum        int
-	downAfter     time.Duration
-	parallelSync  int
-	runID         string
-	configEpoch   int64
-	stopCh        chan struct{}
-	// 其他哨兵地址列表
-	otherSentinels []string
-	// gossip消息通道
-	gossipCh chan *GossipMessage
-}
+const (
+	// DefaultDownAfter 新监控的主节点默认的 down-after-milliseconds
+	DefaultDownAfter = 30 * time.Second
+	// DefaultFailoverTimeout 默认的 failover-timeout
+	DefaultFailoverTimeout = 3 * time.Minute
 
-// GossipMessage 哨兵间通信消息
-type GossipMessage struct {
-	Type         string // "sdown" | "hello" | "masters"
-	MasterName   string
-	SourceRunID  string
-	SentinelAddr string
-	State        string
-	SdownCount   int
-	Timestamp    time.Time
-}
+	// helloChannel 哨兵在被监控节点上互相发现的频道
+	helloChannel = "__sentinel__:hello"
+	// helloPeriod 发布 hello 的间隔
+	helloPeriod = 2 * time.Second
+)
+
+var (
+	// ErrNoSuchMaster 没有以该名称监控的主节点
+	ErrNoSuchMaster = errors.New("no such master with that name")
+	// ErrDuplicatedMaster 该名称已被监控
+	ErrDuplicatedMaster = errors.New("duplicated master name")
+	// ErrInvalidQuorum quorum 小于 1
+	ErrInvalidQuorum = errors.New("quorum must be 1 or greater")
+)
 
-// NewSentinel 创建新的哨兵实例
-func NewSentinel(quorum int, downAfter time.Duration) *Sentinel {
+// Sentinel 哨兵实例：按名称监控一组主节点，发现其从节点与其他哨兵，
+// 主节点客观下线后与其他哨兵选出领头者执行故障转移
+type Sentinel struct {
+	mu           sync.RWMutex
+	masters      map[string]*MasterInstance
+	downAfter    time.Duration
+	runID        string
+	currentEpoch int64
+	announceIP   string
+	announcePort int
+	started      bool
+	stopCh       chan struct{}
+	events       *eventBus
+}
+
+// NewSentinel 创建新的哨兵实例，downAfter 为之后 SENTINEL MONITOR 的主节点默认的下线判定时间
+func NewSentinel(downAfter time.Duration) *Sentinel {
+	if downAfter <= 0 {
+		downAfter = DefaultDownAfter
+	}
 	return &Sentinel{
-		masters:      make(map[string]*MasterInstance),
-		quorum:       quorum,
-		downAfter:    downAfter,
-		parallelSync: 1,
-		runID:        generateRunID(),
-		configEpoch:  0,
-		stopCh:       make(chan struct{}),
-		gossipCh:     make(chan *GossipMessage, 100),
+		masters:   make(map[string]*MasterInstance),
+		downAfter: downAfter,
+		runID:     generateRunID(),
+		stopCh:    make(chan struct{}),
+		events:    newEventBus(),
 	}
 }
 
-// generateRunID 生成运行ID
+// generateRunID 生成40字符的十六进制运行ID
 func generateRunID() string {
-	// 简化实现，实际应该生成40字符的十六进制字符串
-	return fmt.Sprintf("sentinel-%d", time.Now().UnixNano())
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%040x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// SetAnnounceAddr 设置在 hello 消息中向其他哨兵公布的地址；ip 为空时使用连接被监控节点的本地地址
+func (s *Sentinel) SetAnnounceAddr(ip string, port int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.announceIP, s.announcePort = ip, port
+}
+
+// announceAddr 公布的地址
+func (s *Sentinel) announceAddr() (string, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.announceIP, s.announcePort
 }
 
-// AddMaster 添加主节点监控
+// AddMaster 添加主节点监控，哨兵已启动时立即开始监控
 func (s *Sentinel) AddMaster(name, addr string, quorum int) error {
+	if quorum <= 0 {
+		return ErrInvalidQuorum
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if _, exists := s.masters[name]; exists {
-		return fmt.Errorf("master %s already exists", name)
+		return ErrDuplicatedMaster
 	}
 
 	master := NewMasterInstance(name, addr, quorum)
+	master.downAfter = s.downAfter
 	s.masters[name] = master
+	if s.started {
+		go master.monitor(s)
+	}
 
 	logger.Logger.Info().
 		Str("master_name", name).
 		Str("master_addr", addr).
 		Int("quorum", quorum).
 		Msg("添加主节点监控")
-
+	s.event("+monitor", fmt.Sprintf("%s quorum %d", master.describe(), quorum))
 	return nil
 }
 
@@ -81,68 +119,65 @@ func (s *Sentinel) RemoveMaster(name string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if master, exists := s.masters[name]; exists {
-		master.Stop()
-		delete(s.masters, name)
-		logger.Logger.Info().
-			Str("master_name", name).
-			Msg("移除主节点监控")
-		return nil
+	master, exists := s.masters[name]
+	if !exists {
+		return ErrNoSuchMaster
 	}
-
-	return fmt.Errorf("master %s not found", name)
+	master.Stop()
+	delete(s.masters, name)
+	logger.Logger.Info().
+		Str("master_name", name).
+		Msg("移除主节点监控")
+	s.event("-monitor", master.describe())
+	return nil
 }
 
-// GetMaster 获取主节点实例
+// GetMaster 获取主节点实例，未监控时返回 nil
 func (s *Sentinel) GetMaster(name string) *MasterInstance {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return s.masters[name]
 }
 
-// GetAllMasters 获取所有主节点
-func (s *Sentinel) GetAllMasters() map[string]*MasterInstance {
+// Masters 所有被监控的主节点，按名称排序
+func (s *Sentinel) Masters() []*MasterInstance {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	result := make(map[string]*MasterInstance)
-	for k, v := range s.masters {
-		result[k] = v
+	names := make([]string, 0, len(s.masters))
+	for name := range s.masters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]*MasterInstance, 0, len(names))
+	for _, name := range names {
+		result = append(result, s.masters[name])
 	}
 	return result
 }
 
-// AddSentinel 添加其他哨兵地址
-func (s *Sentinel) AddSentinel(addr string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for _, existing := range s.otherSentinels {
-		if existing == addr {
-			return
+// masterByAddr 按当前地址查找被监控的主节点
+func (s *Sentinel) masterByAddr(addr string) *MasterInstance {
+	for _, master := range s.Masters() {
+		if master.GetAddr() == addr {
+			return master
 		}
 	}
-	s.otherSentinels = append(s.otherSentinels, addr)
-	logger.Logger.Info().Str("sentinel", addr).Msg("添加哨兵")
+	return nil
 }
 
-// Start 启动哨兵
+// Start 启动哨兵，开始监控已添加的主节点
 func (s *Sentinel) Start() {
-	logger.Logger.Info().Msg("启动哨兵")
+	logger.Logger.Info().Str("run_id", s.runID).Msg("启动哨兵")
 
-	// 启动所有主节点的监控
-	s.mu.RLock()
-	masters := make([]*MasterInstance, 0, len(s.masters))
-	for _, master := range s.masters {
-		masters = append(masters, master)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
 	}
-	s.mu.RUnlock()
-
-	for _, master := range masters {
-		go master.StartMonitoring(s)
+	s.started = true
+	for _, master := range s.masters {
+		go master.monitor(s)
 	}
-
-	// 启动gossip协程
-	go s.startGossipProcessor()
 }
 
 // Stop 停止哨兵
@@ -150,8 +185,12 @@ func (s *Sentinel) Stop() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	select {
+	case <-s.stopCh:
+		return
+	default:
+	}
 	close(s.stopCh)
-
 	for _, master := range s.masters {
 		master.Stop()
 	}
@@ -159,148 +198,45 @@ func (s *Sentinel) Stop() {
 
 // GetRunID 获取运行ID
 func (s *Sentinel) GetRunID() string {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
 	return s.runID
 }
 
-// GetConfigEpoch 获取配置纪元
-func (s *Sentinel) GetConfigEpoch() int64 {
+// CurrentEpoch 当前纪元，每次尝试故障转移加一，收到更大的纪元时跟进
+func (s *Sentinel) CurrentEpoch() int64 {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.configEpoch
+	return s.currentEpoch
 }
 
-// IncrementConfigEpoch 增加配置纪元
-func (s *Sentinel) IncrementConfigEpoch() int64 {
+// nextEpoch 为新的故障转移增加当前纪元
+func (s *Sentinel) nextEpoch() int64 {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.configEpoch++
-	return s.configEpoch
+	s.currentEpoch++
+	epoch := s.currentEpoch
+	s.mu.Unlock()
+	s.event("+new-epoch", fmt.Sprintf("%d", epoch))
+	return epoch
 }
 
-// BroadcastSdown 广播主观下线消息到其他哨兵
-func (s *Sentinel) BroadcastSdown(masterName string) {
-	master := s.GetMaster(masterName)
-	if master == nil {
-		return
-	}
-
-	msg := &GossipMessage{
-		Type:         "sdown",
-		MasterName:   masterName,
-		SourceRunID:  s.runID,
-		SentinelAddr: "", // 稍后填充
-		State:        "sdown",
-		SdownCount:   master.GetSdownCount(),
-		Timestamp:    time.Now(),
-	}
-
-	// 发送到gossip处理器
-	select {
-	case s.gossipCh <- msg:
-	default:
-		// 通道满，忽略
+// observeEpoch 其他哨兵的纪元更大时跟进
+func (s *Sentinel) observeEpoch(epoch int64) {
+	s.mu.Lock()
+	updated := epoch > s.currentEpoch
+	if updated {
+		s.currentEpoch = epoch
 	}
-}
-
-// startGossipProcessor 启动gossip消息处理器
-func (s *Sentinel) startGossipProcessor() {
-	for {
-		select {
-		case <-s.stopCh:
-			return
-		case msg := <-s.gossipCh:
-			s.processGossipMessage(msg)
-		}
+	s.mu.Unlock()
+	if updated {
+		s.event("+new-epoch", fmt.Sprintf("%d", epoch))
 	}
 }
 
-// processGossipMessage 处理gossip消息
-func (s *Sentinel) processGossipMessage(msg *GossipMessage) {
-	switch msg.Type {
-	case "sdown":
-		s.handleSdownMessage(msg)
-	case "hello":
-		s.handleHelloMessage(msg)
+// event 记录日志并向订阅了该事件类型的客户端发布，不使用 s.mu，持有锁时也可以调用
+func (s *Sentinel) event(typ, msg string) {
+	level := logger.Logger.Info()
+	if strings.HasPrefix(typ, "-failover-abort") || typ == "+odown" || typ == "+sdown" {
+		level = logger.Logger.Warn()
 	}
-}
-
-// handleSdownMessage 处理主观下线消息
-func (s *Sentinel) handleSdownMessage(msg *GossipMessage) {
-	master := s.GetMaster(msg.MasterName)
-	if master == nil {
-		return
-	}
-
-	// 更新sdown计数（简化实现，实际应该记录每个哨兵的报告）
-	master.IncrSdownCount()
-
-	logger.Logger.Info().
-		Str("master_name", msg.MasterName).
-		Str("from_sentinel", msg.SourceRunID).
-		Int("sdown_count", master.GetSdownCount()).
-		Int("quorum", master.GetQuorum()).
-		Msg("收到其他哨兵的sdown报告")
-
-	// 检查是否达到客观下线
-	if master.IsODown() {
-		logger.Logger.Info().
-			Str("master_name", msg.MasterName).
-			Msg("主节点已客观下线，触发故障转移")
-
-		// 触发故障转移
-		fm := NewFailoverManager(s)
-		go func() {
-			if err := fm.AutoFailover(msg.MasterName); err != nil {
-				logger.Logger.Error().
-					Str("master_name", msg.MasterName).
-					Err(err).
-					Msg("自动故障转移失败")
-			}
-		}()
-	}
-}
-
-// handleHelloMessage 处理hello消息
-func (s *Sentinel) handleHelloMessage(msg *GossipMessage) {
-	// 添加到已知哨兵列表
-	s.AddSentinel(msg.SentinelAddr)
-
-	logger.Logger.Debug().
-		Str("from_sentinel", msg.SourceRunID).
-		Str("addr", msg.SentinelAddr).
-		Msg("收到哨兵hello消息")
-}
-
-// SendHello 发送hello消息到指定哨兵
-func (s *Sentinel) SendHello(sentinelAddr string) error {
-	// 简化实现：实际应该建立TCP连接并发送hello消息
-	logger.Logger.Debug().Str("sentinel", sentinelAddr).Msg("发送hello消息")
-	return nil
-}
-
-// StartGossip 启动与指定哨兵的gossip通信
-func (s *Sentinel) StartGossip(sentinelAddr string) {
-	s.AddSentinel(sentinelAddr)
-
-	// 定期发送hello消息
-	go func() {
-		ticker := time.NewTicker(10 * time.Second)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-s.stopCh:
-				return
-			case <-ticker.C:
-				if err := s.SendHello(sentinelAddr); err != nil {
-					logger.Logger.Warn().
-						Str("sentinel", sentinelAddr).
-						Err(err).
-						Msg("发送hello消息失败")
-				}
-			}
-		}
-	}()
+	level.Str("event", typ).Msg(msg)
+	s.events.publish(typ, msg)
 }
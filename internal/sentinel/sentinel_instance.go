@@ -1,18 +1,25 @@
 package sentinel
 
-// SentinelInstance 其他哨兵实例
+import "time"
+
+// SentinelInstance 监控同一主节点的其他哨兵，通过 hello 消息发现，字段由所属 MasterInstance 的锁保护
 type SentinelInstance struct {
-	ID     string
-	Addr   string
-	RunID  string
-	Epoch  int64
-	Quorum int
+	Addr  string
+	RunID string
+
+	link      instanceLink
+	lastHello time.Time
+	// 最近一次 IS-MASTER-DOWN-BY-ADDR 的回复
+	masterDown    bool
+	downReplyTime time.Time
+	leader        string
+	leaderEpoch   int64
 }
 
 // NewSentinelInstance 创建新的哨兵实例
-func NewSentinelInstance(id, addr string) *SentinelInstance {
+func NewSentinelInstance(addr, runID string) *SentinelInstance {
 	return &SentinelInstance{
-		ID:   id,
-		Addr: addr,
+		Addr:  addr,
+		RunID: runID,
 	}
 }
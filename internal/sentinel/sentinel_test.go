@@ -0,0 +1,190 @@
+package sentinel
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/zeebo/assert"
+)
+
+// testClient 直接收发 RESP 的哨兵客户端
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func startTestSentinel(t *testing.T) (*Sentinel, *testClient) {
+	s := NewSentinel(time.Second)
+	handler := NewSentinelHandler(s)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go handler.HandleConnection(conn)
+		}
+	}()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+		_ = ln.Close()
+		s.Stop()
+	})
+	return s, &testClient{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *testClient) do(t *testing.T, args ...string) proto.RESP {
+	assert.NoError(t, writeCommand(c.conn, args))
+	return c.read(t)
+}
+
+func (c *testClient) read(t *testing.T) proto.RESP {
+	assert.NoError(t, c.conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	reply, err := proto.ReadReply(c.r)
+	assert.NoError(t, err)
+	return reply
+}
+
+// replyStrings 把数组回复展开为字符串
+func replyStrings(t *testing.T, reply proto.RESP) []string {
+	elems, ok := proto.Elems(reply)
+	assert.True(t, ok)
+	out := make([]string, len(elems))
+	for i, e := range elems {
+		out[i] = replyString(e)
+	}
+	return out
+}
+
+func fieldValue(fields []string, name string) string {
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i] == name {
+			return fields[i+1]
+		}
+	}
+	return ""
+}
+
+func errorText(reply proto.RESP) string {
+	if e, ok := reply.(*proto.Error); ok {
+		return string(*e)
+	}
+	return ""
+}
+
+func TestSentinelMonitorAndSet(t *testing.T) {
+	s, c := startTestSentinel(t)
+
+	assert.Equal(t, "OK", replyString(c.do(t, "SENTINEL", "MONITOR", "mymaster", "127.0.0.1", "6390", "2")))
+	assert.Equal(t, "ERR Duplicated master name", errorText(c.do(t, "SENTINEL", "MONITOR", "mymaster", "127.0.0.1", "6391", "2")))
+	assert.Equal(t, "ERR Quorum must be 1 or greater.", errorText(c.do(t, "SENTINEL", "MONITOR", "other", "127.0.0.1", "6391", "0")))
+	assert.Equal(t, "ERR Invalid port number", errorText(c.do(t, "SENTINEL", "MONITOR", "other", "127.0.0.1", "70000", "1")))
+
+	fields := replyStrings(t, c.do(t, "SENTINEL", "MASTER", "mymaster"))
+	assert.Equal(t, "mymaster", fieldValue(fields, "name"))
+	assert.Equal(t, "127.0.0.1", fieldValue(fields, "ip"))
+	assert.Equal(t, "6390", fieldValue(fields, "port"))
+	assert.Equal(t, "2", fieldValue(fields, "quorum"))
+	assert.Equal(t, "1000", fieldValue(fields, "down-after-milliseconds"))
+
+	assert.Equal(t, "OK", replyString(c.do(t, "SENTINEL", "SET", "mymaster", "down-after-milliseconds", "5000", "quorum", "3")))
+	assert.Equal(t, "ERR Invalid argument 'abc' for SENTINEL SET 'quorum'", errorText(c.do(t, "SENTINEL", "SET", "mymaster", "quorum", "abc")))
+	mi := s.GetMaster("mymaster")
+	assert.Equal(t, 3, mi.GetQuorum())
+	fields = replyStrings(t, c.do(t, "SENTINEL", "MASTER", "mymaster"))
+	assert.Equal(t, "5000", fieldValue(fields, "down-after-milliseconds"))
+
+	assert.DeepEqual(t, []string{"127.0.0.1", "6390"}, replyStrings(t, c.do(t, "SENTINEL", "GET-MASTER-ADDR-BY-NAME", "mymaster")))
+	nilReply, ok := c.do(t, "SENTINEL", "GET-MASTER-ADDR-BY-NAME", "unknown").(*proto.BulkString)
+	assert.True(t, ok)
+	assert.Nil(t, *nilReply)
+	assert.Equal(t, "ERR No such master with that name", errorText(c.do(t, "SENTINEL", "MASTER", "unknown")))
+	assert.Equal(t, "ERR unknown subcommand 'NOPE'", errorText(c.do(t, "SENTINEL", "NOPE")))
+
+	assert.Equal(t, "OK", replyString(c.do(t, "SENTINEL", "REMOVE", "mymaster")))
+	assert.Nil(t, s.GetMaster("mymaster"))
+	assert.Equal(t, "ERR No such master with that name", errorText(c.do(t, "SENTINEL", "REMOVE", "mymaster")))
+}
+
+func TestSentinelLeaderVoting(t *testing.T) {
+	s := NewSentinel(time.Second)
+	assert.NoError(t, s.AddMaster("mymaster", "127.0.0.1:6390", 2))
+	mi := s.GetMaster("mymaster")
+
+	// 每个纪元只投给第一个请求者
+	down, leader, epoch := s.isMasterDownByAddr("127.0.0.1", 6390, 1, "sentinel-a")
+	assert.False(t, down)
+	assert.Equal(t, "sentinel-a", leader)
+	assert.Equal(t, int64(1), epoch)
+	_, leader, _ = s.isMasterDownByAddr("127.0.0.1", 6390, 1, "sentinel-b")
+	assert.Equal(t, "sentinel-a", leader)
+	assert.Equal(t, int64(1), s.CurrentEpoch())
+
+	// 更大的纪元重新投票
+	_, leader, epoch = s.isMasterDownByAddr("127.0.0.1", 6390, 2, "sentinel-b")
+	assert.Equal(t, "sentinel-b", leader)
+	assert.Equal(t, int64(2), epoch)
+
+	// 只询问下线状态时不投票
+	_, leader, _ = s.isMasterDownByAddr("127.0.0.1", 6390, 3, "*")
+	assert.Equal(t, "*", leader)
+	_, _, unknownEpoch := s.isMasterDownByAddr("127.0.0.1", 6391, 3, "sentinel-b")
+	assert.Equal(t, int64(0), unknownEpoch)
+
+	// 三个哨兵、quorum 为 2 时需要两票
+	mi.sentinels["a"] = NewSentinelInstance("127.0.0.1:26380", "sentinel-a")
+	mi.sentinels["c"] = NewSentinelInstance("127.0.0.1:26381", "sentinel-c")
+	assert.Equal(t, "", s.electedLeader(mi, 2))
+	mi.sentinels["a"].leader, mi.sentinels["a"].leaderEpoch = "sentinel-b", 2
+	assert.Equal(t, "sentinel-b", s.electedLeader(mi, 2))
+	assert.Equal(t, "", s.electedLeader(mi, 3))
+}
+
+func TestSentinelSelectSlave(t *testing.T) {
+	mi := NewMasterInstance("mymaster", "127.0.0.1:6390", 1)
+	assert.Nil(t, mi.selectSlave())
+
+	for _, addr := range []string{"127.0.0.1:6391", "127.0.0.1:6392", "127.0.0.1:6393", "127.0.0.1:6394"} {
+		mi.slaves[addr] = NewSlaveInstance(addr)
+	}
+	mi.slaves["127.0.0.1:6391"].Offset = 100
+	mi.slaves["127.0.0.1:6392"].Offset = 300
+	mi.slaves["127.0.0.1:6393"].Offset = 500
+	mi.slaves["127.0.0.1:6393"].sdown = true
+	mi.slaves["127.0.0.1:6394"].Offset = 900
+	mi.slaves["127.0.0.1:6394"].Priority = 0
+	assert.Equal(t, "127.0.0.1:6392", mi.selectSlave().Addr)
+
+	// 优先级小者优先于复制偏移量
+	mi.slaves["127.0.0.1:6391"].Priority = 10
+	assert.Equal(t, "127.0.0.1:6391", mi.selectSlave().Addr)
+}
+
+func TestSentinelSubscribeEvents(t *testing.T) {
+	s, c := startTestSentinel(t)
+
+	assert.DeepEqual(t, []string{"subscribe", "+monitor", "1"}, replyStrings(t, c.do(t, "SUBSCRIBE", "+monitor")))
+	assert.DeepEqual(t, []string{"psubscribe", "+*", "2"}, replyStrings(t, c.do(t, "PSUBSCRIBE", "+*")))
+	assert.Equal(t, "ERR Can't execute 'sentinel': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context",
+		errorText(c.do(t, "SENTINEL", "MASTERS")))
+
+	assert.NoError(t, s.AddMaster("mymaster", "127.0.0.1:6390", 1))
+	got := map[string][]string{}
+	for i := 0; i < 2; i++ {
+		msg := replyStrings(t, c.read(t))
+		got[msg[0]] = msg
+	}
+	assert.DeepEqual(t, []string{"message", "+monitor", "master mymaster 127.0.0.1 6390 quorum 1"}, got["message"])
+	assert.DeepEqual(t, []string{"pmessage", "+*", "+monitor", "master mymaster 127.0.0.1 6390 quorum 1"}, got["pmessage"])
+
+	assert.DeepEqual(t, []string{"punsubscribe", "+*", "1"}, replyStrings(t, c.do(t, "PUNSUBSCRIBE")))
+	assert.DeepEqual(t, []string{"unsubscribe", "+monitor", "0"}, replyStrings(t, c.do(t, "UNSUBSCRIBE", "+monitor")))
+	assert.Equal(t, "OK", replyString(c.do(t, "SENTINEL", "REMOVE", "mymaster")))
+}
@@ -1,22 +1,69 @@
 package sentinel
 
-// SlaveInstance 从节点实例
+import (
+	"strconv"
+	"time"
+)
+
+// SlaveInstance 从节点实例，字段由所属 MasterInstance 的锁保护
 type SlaveInstance struct {
-	ID       string
-	Addr     string
-	Master   *MasterInstance
-	Flags    []string
-	State    string
-	Offset   int64
-	Lag      int64
+	Addr             string
+	RunID            string
+	Offset           int64
+	Priority         int
+	RoleReported     string
+	MasterHost       string
+	MasterPort       int
+	MasterLinkStatus string
+
+	link             instanceLink
+	lastPingTime     time.Time
+	lastPongTime     time.Time
+	infoRefresh      time.Time
+	roleReportedTime time.Time
+	masterChangeTime time.Time
+	reconfSent       time.Time
+	sdown            bool
 }
 
 // NewSlaveInstance 创建新的从节点实例
-func NewSlaveInstance(id, addr string) *SlaveInstance {
+func NewSlaveInstance(addr string) *SlaveInstance {
+	now := time.Now()
 	return &SlaveInstance{
-		ID:    id,
-		Addr:  addr,
-		Flags: []string{"slave"},
-		State: "online",
+		Addr:             addr,
+		Priority:         100,
+		lastPongTime:     now,
+		roleReportedTime: now,
+		masterChangeTime: now,
+	}
+}
+
+// applyInfo 根据从节点的 INFO 更新状态
+func (si *SlaveInstance) applyInfo(info map[string]string, now time.Time) {
+	si.infoRefresh = now
+	si.RunID = info["run_id"]
+	if role := info["role"]; role != si.RoleReported {
+		si.RoleReported, si.roleReportedTime = role, now
+	}
+	port, _ := strconv.Atoi(info["master_port"])
+	if host := info["master_host"]; host != si.MasterHost || port != si.MasterPort {
+		si.MasterHost, si.MasterPort, si.masterChangeTime = host, port, now
+	}
+	si.MasterLinkStatus = info["master_link_status"]
+	if offset, err := strconv.ParseInt(info["slave_repl_offset"], 10, 64); err == nil {
+		si.Offset = offset
+	}
+	if priority, err := strconv.Atoi(info["slave_priority"]); err == nil {
+		si.Priority = priority
+	} else if priority, err := strconv.Atoi(info["replica_priority"]); err == nil {
+		si.Priority = priority
+	}
+}
+
+// flags SENTINEL REPLICAS 中的 flags 字段
+func (si *SlaveInstance) flags() string {
+	if si.sdown {
+		return "slave,s_down"
 	}
+	return "slave"
 }
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// handleAnalyze 处理 ANALYZE 命令（键空间分析，用于容量规划）：
+//
+//	ANALYZE START [RATE n] [TOP n] [DELIMITER d]  在后台扫描键空间，RATE 为每秒最多读取的 Badger 键数
+//	ANALYZE STATUS                                返回 running、scanned_keys、last_finished_at（Unix 毫秒）
+//	ANALYZE [REPORT]                              返回最近一次完成的分析结果
+//	ANALYZE CANCEL                                停止正在运行的分析
+func (h *Handler) handleAnalyze(args [][]byte) proto.RESP {
+	sub := "REPORT"
+	if len(args) > 0 {
+		sub = strings.ToUpper(string(args[0]))
+	}
+	switch sub {
+	case "START":
+		var opts store.AnalyzeOptions
+		for i := 1; i < len(args); i += 2 {
+			if i+1 >= len(args) {
+				return proto.NewError(errSyntax)
+			}
+			value := string(args[i+1])
+			switch strings.ToUpper(string(args[i])) {
+			case "RATE", "TOP":
+				n, err := strconv.Atoi(value)
+				if err != nil || n <= 0 {
+					return proto.NewError(errNotInteger)
+				}
+				if strings.ToUpper(string(args[i])) == "RATE" {
+					opts.ReadsPerSecond = n
+				} else {
+					opts.TopPrefixes = n
+				}
+			case "DELIMITER":
+				if value == "" {
+					return proto.NewError(errSyntax)
+				}
+				opts.PrefixDelimiter = value
+			default:
+				return proto.NewError(errSyntax)
+			}
+		}
+		if err := h.Db.StartAnalyze(opts); err != nil {
+			if errors.Is(err, store.ErrAnalyzeRunning) {
+				return proto.NewError("ERR keyspace analysis already running")
+			}
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		return proto.OK
+
+	case "STATUS":
+		if len(args) != 1 {
+			return wrongArgsError("ANALYZE STATUS")
+		}
+		running, scanned, last := h.Db.AnalyzeStatus()
+		var finished int64
+		if last != nil {
+			finished = last.FinishedAt.UnixMilli()
+		}
+		return &proto.NestedArray{Elems: []proto.RESP{
+			proto.NewBulkString([]byte("running")), proto.NewInteger(int64(boolToInt(running))),
+			proto.NewBulkString([]byte("scanned_keys")), proto.NewInteger(scanned),
+			proto.NewBulkString([]byte("last_finished_at")), proto.NewInteger(finished),
+		}}
+
+	case "REPORT":
+		if len(args) > 1 {
+			return wrongArgsError("ANALYZE REPORT")
+		}
+		_, _, last := h.Db.AnalyzeStatus()
+		if last == nil {
+			return proto.NewError("ERR no keyspace analysis available, run ANALYZE START first")
+		}
+		return proto.NewBulkString([]byte(formatKeyspaceAnalysis(last)))
+
+	case "CANCEL":
+		if len(args) != 1 {
+			return wrongArgsError("ANALYZE CANCEL")
+		}
+		return proto.NewInteger(int64(boolToInt(h.Db.CancelAnalyze())))
+	}
+	return proto.NewError(fmt.Sprintf("ERR unknown subcommand '%s'", string(args[0])))
+}
+
+// formatKeyspaceAnalysis 以 INFO 的格式输出分析结果，直方图只列出非空的桶
+func formatKeyspaceAnalysis(a *store.KeyspaceAnalysis) string {
+	var b strings.Builder
+	writeHistogram := func(name string, hist store.Histogram) {
+		for i, n := range hist.Buckets {
+			if n > 0 {
+				b.WriteString(fmt.Sprintf("%s_le_%d:%d\n", name, store.HistogramBound(i), n))
+			}
+		}
+	}
+
+	b.WriteString("# Keyspace\n")
+	b.WriteString(fmt.Sprintf("started_at:%d\n", a.StartedAt.UnixMilli()))
+	b.WriteString(fmt.Sprintf("finished_at:%d\n", a.FinishedAt.UnixMilli()))
+	b.WriteString(fmt.Sprintf("keys:%d\n", a.Keys))
+	b.WriteString(fmt.Sprintf("bytes:%d\n", a.Bytes))
+
+	b.WriteString("\n# KeySize\n")
+	writeHistogram("bytes", a.KeySize)
+
+	types := make([]string, 0, len(a.Types))
+	for t := range a.Types {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	b.WriteString("\n# Types\n")
+	for _, t := range types {
+		b.WriteString(fmt.Sprintf("type_%s:keys=%d,bytes=%d\n", strings.ToLower(t), a.Types[t].Keys, a.Types[t].Bytes))
+	}
+	b.WriteString("\n# Members\n")
+	for _, t := range types {
+		writeHistogram(strings.ToLower(t)+"_members", a.Types[t].Members)
+	}
+
+	b.WriteString("\n# TTL\n")
+	b.WriteString(fmt.Sprintf("ttl_none:%d\n", a.NoTTL))
+	for i, bucket := range store.AnalyzeTTLBuckets {
+		b.WriteString(fmt.Sprintf("ttl_%s:%d\n", bucket.Name, a.TTL[i]))
+	}
+
+	writePrefixes := func(section string, list []store.PrefixAnalysis) {
+		b.WriteString("\n# " + section + "\n")
+		for i, p := range list {
+			b.WriteString(fmt.Sprintf("prefix%d:name=%s,keys=%d,bytes=%d\n", i, p.Prefix, p.Keys, p.Bytes))
+		}
+	}
+	writePrefixes("PrefixesByKeys", a.PrefixesByKeys)
+	writePrefixes("PrefixesByBytes", a.PrefixesByBytes)
+	return b.String()
+}
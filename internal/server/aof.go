@@ -0,0 +1,645 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/aof"
+	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// aofRemoteAddr 重放 AOF 时命令的来源地址
+const aofRemoteAddr = "aof"
+
+// aofExtraCommands 除 isWriteCommand 外，同样写入 AOF 的命令
+var aofExtraCommands = map[string]bool{
+	"SETBIT": true, "BITOP": true, "BITFIELD": true, "PFADD": true, "PFMERGE": true,
+	"RESTORE": true, "COPY": true, "FLUSHDB": true, "FLUSHALL": true, "BOLTREON.SCHEDULE": true,
+	"LMOVE": true, "BLPOP": true, "BRPOP": true, "BRPOPLPUSH": true, "BLMOVE": true,
+	"ZPOPMIN": true, "ZPOPMAX": true, "BZPOPMIN": true, "BZPOPMAX": true, "ZMPOP": true, "BZMPOP": true,
+	"ZUNIONSTORE": true, "ZINTERSTORE": true, "ZDIFFSTORE": true, "ZRANGESTORE": true,
+	"ZREMRANGEBYRANK": true, "ZREMRANGEBYSCORE": true, "ZREMRANGEBYLEX": true,
+	"XREADGROUP": true, "XAUTOCLAIM": true,
+	"JSON.SET": true, "JSON.DEL": true, "JSON.ARRAPPEND": true, "JSON.NUMINCRBY": true,
+	"JSON.NUMMULTBY": true, "JSON.CLEAR": true, "JSON.ARRINSERT": true, "JSON.ARRPOP": true,
+	"JSON.ARRTRIM": true, "JSON.STRAPPEND": true, "JSON.TOGGLE": true,
+	"JSON.MERGE": true, "JSON.MSET": true,
+	"FT.CREATE": true, "FT.DROPINDEX": true,
+	"TS.CREATE": true, "TS.ADD": true, "TS.DEL": true,
+}
+
+// aofBlockingCommands 可能阻塞的写命令：执行时不持有重写屏障，避免 BGREWRITEAOF 等待阻塞的客户端，
+// 而阻塞的客户端又在等待被屏障挡住的写命令。与重写同时完成的弹出可能在新文件中重复
+var aofBlockingCommands = map[string]bool{
+	"BLPOP": true, "BRPOP": true, "BRPOPLPUSH": true, "BLMOVE": true,
+	"BZPOPMIN": true, "BZPOPMAX": true, "BZMPOP": true, "XREADGROUP": true,
+}
+
+// aofState 追加写命令日志（只保存在服务器级），见 ConfigureAOF
+type aofState struct {
+	// barrier 写命令从执行到写入 AOF 期间持有读锁，重写读取数据集期间持有写锁，
+	// 保证新文件既不遗漏也不重复重写期间的写命令
+	barrier sync.RWMutex
+
+	mu    sync.Mutex
+	log   *aof.Log
+	path  string
+	fsync string
+	// enabled appendonly 的设置；开启后首次重写完成前 log 仍为 nil
+	enabled atomic.Bool
+
+	rewriting     atomic.Bool
+	rewriteStart  atomic.Int64 // 进行中的重写开始的 Unix 时间（毫秒）
+	rewrites      atomic.Int64
+	lastRewriteMs atomic.Int64 // 最近一次重写的耗时（毫秒）
+	rewriteFailed atomic.Bool
+}
+
+// ConfigureAOF 设置 AOF 文件路径与 fsync 策略，StartAOF 或 CONFIG SET appendonly yes 时生效
+func (h *Handler) ConfigureAOF(path, fsync string) error {
+	if !aof.ValidFsync(fsync) {
+		return fmt.Errorf("invalid appendfsync '%s', must be one of always, everysec, no", fsync)
+	}
+	a := &h.root().aof
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.path, a.fsync = path, fsync
+	return nil
+}
+
+// StartAOF 在启动加载期间开启 AOF：存储为空而 AOF 文件不为空时（例如换到新的存储目录）先重放文件；
+// 存储已有数据而 AOF 文件为空时先用当前数据集生成文件；之后的写命令追加到文件末尾
+func (h *Handler) StartAOF() error {
+	a := &h.root().aof
+	a.mu.Lock()
+	path := a.path
+	a.mu.Unlock()
+	if path == "" {
+		return errors.New("appendonly file path is not configured")
+	}
+	keys, err := h.Db.KeyCount()
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.Size() > 0 && keys == 0:
+		if err := h.replayAOF(path); err != nil {
+			return err
+		}
+	case err != nil && !errors.Is(err, os.ErrNotExist):
+		return err
+	case keys > 0 && (err != nil || info.Size() == 0):
+		// 已有的数据不在 AOF 中：先写入当前数据集，否则重放文件时会丢失这些键
+		if err := h.rewriteAOF(); err != nil {
+			return err
+		}
+	}
+	return h.openAOF()
+}
+
+// CloseAOF 关闭 AOF 文件，关闭前同步已写入的内容
+func (h *Handler) CloseAOF() error {
+	a := &h.root().aof
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.enabled.Store(false)
+	if a.log == nil {
+		return nil
+	}
+	err := a.log.Close()
+	a.log = nil
+	return err
+}
+
+// openAOF 打开 AOF 文件开始追加
+func (h *Handler) openAOF() error {
+	a := &h.root().aof
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.log != nil {
+		return nil
+	}
+	l, err := aof.Open(a.path, a.fsync)
+	if err != nil {
+		return err
+	}
+	a.log = l
+	a.enabled.Store(true)
+	return nil
+}
+
+// replayAOF 按顺序执行 AOF 文件中的命令。命令返回的错误只记录日志，
+// 末尾不完整的命令被截掉，其余格式错误停止重放
+func (h *Handler) replayAOF(path string) error {
+	exec := h.newConnection()
+	failed := 0
+	result, err := aof.Load(path, func(args [][]byte) error {
+		cmd := strings.ToUpper(string(args[0]))
+		exec.resolveDBKeys(cmd, args[1:])
+		resp := exec.runCommand(cmd, args[1:], aofRemoteAddr)
+		if e, ok := resp.(*proto.Error); ok {
+			failed++
+			logger.Logger.Warn().Str("command", cmd).Str("error", string(*e)).Msg("重放 AOF 命令失败")
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("loading %s: %w", path, err)
+	}
+	if result.Truncated > 0 {
+		logger.Logger.Warn().Int64("bytes", result.Truncated).Str("path", path).Msg("AOF 文件末尾不完整，已截断")
+	}
+	logger.Logger.Info().Int("commands", result.Commands).Int("failed", failed).Str("path", path).Msg("AOF 重放完成")
+	return nil
+}
+
+// aofLog 当前打开的 AOF，未开启时为 nil
+func (h *Handler) aofLog() *aof.Log {
+	a := &h.root().aof
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.log
+}
+
+// isAOFCommand 成功执行后需要写入 AOF 的命令
+func isAOFCommand(cmd string, args [][]byte) bool {
+	if cmd == "SORT" {
+		for _, a := range args {
+			if strings.EqualFold(string(a), "STORE") {
+				return true
+			}
+		}
+		return false
+	}
+	return isWriteCommand(cmd) || aofExtraCommands[cmd]
+}
+
+// beginAOF 在写命令执行前调用，返回的函数在写入 AOF 后调用。
+// EXEC 与脚本中的写命令先缓存，结束时用 MULTI/EXEC 包围写入，重放时整体执行
+func (h *Handler) beginAOF(cmd string, args [][]byte) func() {
+	batch := cmd == "EXEC" || cmd == "EVAL" || cmd == "EVALSHA"
+	if !batch && (!isAOFCommand(cmd, args) || aofBlockingCommands[cmd]) {
+		return func() {}
+	}
+	a := &h.root().aof
+	a.barrier.RLock()
+	if batch {
+		h.aofBatch, h.aofBatching = h.aofBatch[:0], true
+	}
+	return func() {
+		if batch {
+			h.aofBatching = false
+			cmds := h.aofBatch
+			if len(cmds) > 1 {
+				cmds = append(append([][][]byte{{[]byte("MULTI")}}, cmds...), [][]byte{[]byte("EXEC")})
+			}
+			h.appendAOF(cmds...)
+			h.aofBatch = nil
+		}
+		a.barrier.RUnlock()
+	}
+}
+
+// feedAOF 把成功执行的写命令写入 AOF（EXEC 或脚本中时先缓存）
+func (h *Handler) feedAOF(cmd string, args [][]byte, resp proto.RESP) {
+	if h.aofLog() == nil {
+		return
+	}
+	cmds := h.aofCommands(cmd, args, resp)
+	if len(cmds) == 0 {
+		return
+	}
+	for i, c := range cmds {
+		cmds[i] = h.canonicalDBKeys(c)
+	}
+	if h.aofBatching {
+		h.aofBatch = append(h.aofBatch, cmds...)
+		return
+	}
+	h.appendAOF(cmds...)
+}
+
+// appendAOF 直接追加命令，写入失败只记录日志
+func (h *Handler) appendAOF(cmds ...[][]byte) {
+	l := h.aofLog()
+	if l == nil || len(cmds) == 0 {
+		return
+	}
+	if err := l.Append(cmds...); err != nil {
+		logger.Logger.Error().Err(err).Str("path", l.Path()).Msg("写入 AOF 失败")
+	}
+}
+
+// aofCommands 返回写入 AOF 的命令，失败或没有修改数据的命令返回 nil。
+// 重放结果与执行时不同的命令改写为确定的形式：相对过期时间改为绝对时间，
+// 阻塞弹出改为非阻塞弹出，SPOP 改为 SREM，自动生成的 Stream ID 与时间戳改为实际的值
+func (h *Handler) aofCommands(cmd string, args [][]byte, resp proto.RESP) [][][]byte {
+	if resp == nil || !isAOFCommand(cmd, args) {
+		return nil
+	}
+	if _, isErr := resp.(*proto.Error); isErr {
+		return nil
+	}
+	line := func(parts ...[]byte) [][]byte { return parts }
+	var key []byte
+	if len(args) > 0 {
+		key = args[0]
+	}
+	now := h.Db.Clock().Now().UnixMilli()
+	switch cmd {
+	case "EXPIRE", "PEXPIRE", "EXPIREAT":
+		if n, ok := resp.(*proto.Integer); !ok || *n == 0 || len(args) < 2 {
+			return nil
+		}
+		v, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return nil
+		}
+		switch cmd {
+		case "EXPIRE":
+			v = now + v*1000
+		case "PEXPIRE":
+			v += now
+		case "EXPIREAT":
+			v *= 1000
+		}
+		return [][][]byte{line([]byte("PEXPIREAT"), key, []byte(strconv.FormatInt(v, 10)))}
+	case "HEXPIRE", "HPEXPIRE", "HEXPIREAT":
+		// 与 EXPIRE 相同改为绝对时间的 HPEXPIREAT，条件与字段原样保留
+		if len(args) < 2 {
+			return nil
+		}
+		v, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return nil
+		}
+		switch cmd {
+		case "HEXPIRE":
+			v = now + v*1000
+		case "HPEXPIRE":
+			v += now
+		case "HEXPIREAT":
+			v *= 1000
+		}
+		out := append(line([]byte("HPEXPIREAT"), key, []byte(strconv.FormatInt(v, 10))), args[2:]...)
+		return [][][]byte{out}
+	case "SET", "GETEX":
+		// EX/PX 改为 PXAT；SET 的选项从值之后开始
+		out := append([][]byte{[]byte(cmd)}, args...)
+		start := 2
+		if cmd == "SET" {
+			start = 3
+		}
+		for i := start; i < len(out)-1; i++ {
+			option := strings.ToUpper(string(out[i]))
+			if option != "EX" && option != "PX" {
+				continue
+			}
+			v, err := strconv.ParseInt(string(out[i+1]), 10, 64)
+			if err != nil {
+				return nil
+			}
+			if option == "EX" {
+				v *= 1000
+			}
+			out[i], out[i+1] = []byte("PXAT"), []byte(strconv.FormatInt(now+v, 10))
+			break
+		}
+		return [][][]byte{out}
+	case "SETEX", "PSETEX":
+		if len(args) < 3 {
+			return nil
+		}
+		v, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return nil
+		}
+		if cmd == "SETEX" {
+			v *= 1000
+		}
+		return [][][]byte{
+			line([]byte("SET"), key, args[2]),
+			line([]byte("PEXPIREAT"), key, []byte(strconv.FormatInt(now+v, 10))),
+		}
+	case "BLPOP", "BRPOP":
+		popped, ok := resp.(*proto.Array)
+		if !ok || len(popped.Args) != 2 {
+			return nil
+		}
+		return [][][]byte{line([]byte(cmd[1:]), popped.Args[0])}
+	case "BZPOPMIN", "BZPOPMAX":
+		popped, ok := resp.(*proto.Array)
+		if !ok || len(popped.Args) != 3 {
+			return nil
+		}
+		return [][][]byte{line([]byte("ZREM"), popped.Args[0], popped.Args[1])}
+	case "BZMPOP":
+		// [key, [[member, score], ...]]
+		popped, ok := resp.(*proto.NestedArray)
+		if !ok || len(popped.Elems) != 2 {
+			return nil
+		}
+		popKey, ok := popped.Elems[0].(*proto.BulkString)
+		members, isArray := popped.Elems[1].(*proto.NestedArray)
+		if !ok || !isArray {
+			return nil
+		}
+		out := [][]byte{[]byte("ZREM"), *popKey}
+		for _, m := range members.Elems {
+			if pair, ok := m.(*proto.Array); ok && len(pair.Args) == 2 {
+				out = append(out, pair.Args[0])
+			}
+		}
+		return [][][]byte{out}
+	case "BRPOPLPUSH", "BLMOVE":
+		if b, ok := resp.(*proto.BulkString); !ok || *b == nil || len(args) < 2 {
+			return nil
+		}
+		return [][][]byte{append([][]byte{[]byte(cmd[1:])}, args[:len(args)-1]...)}
+	case "SPOP":
+		members := [][]byte{}
+		switch r := resp.(type) {
+		case *proto.BulkString:
+			if *r != nil {
+				members = append(members, *r)
+			}
+		case *proto.Array:
+			members = r.Args
+		}
+		if len(members) == 0 {
+			return nil
+		}
+		return [][][]byte{append([][]byte{[]byte("SREM"), key}, members...)}
+	case "XADD":
+		id, ok := resp.(*proto.BulkString)
+		if !ok || *id == nil {
+			return nil
+		}
+		out := append([][]byte{[]byte(cmd)}, args...)
+		for i := 2; i < len(out); i++ {
+			if s := string(out[i]); s == "*" || strings.HasSuffix(s, "-*") {
+				out[i] = *id
+				break
+			}
+		}
+		return [][][]byte{out}
+	case "TS.ADD":
+		ts, ok := resp.(*proto.Integer)
+		out := append([][]byte{[]byte(cmd)}, args...)
+		if ok && len(args) > 1 && string(args[1]) == "*" {
+			out[2] = []byte(strconv.FormatInt(int64(*ts), 10))
+		}
+		return [][][]byte{out}
+	case "XREADGROUP":
+		if a, ok := resp.(*proto.Array); ok && len(a.Args) == 0 {
+			return nil
+		}
+		return [][][]byte{append([][]byte{[]byte(cmd)}, nonBlockingArgs(cmd, args)...)}
+	case "FLUSHDB":
+		// 其他命令的键已是存储中的键名，只有 FLUSHDB 需要知道连接选择的数据库
+		if h.db != 0 {
+			return [][][]byte{
+				line([]byte("SELECT"), []byte(strconv.Itoa(h.db))),
+				append([][]byte{[]byte(cmd)}, args...),
+				line([]byte("SELECT"), []byte("0")),
+			}
+		}
+	}
+	return [][][]byte{append([][]byte{[]byte(cmd)}, args...)}
+}
+
+// rewriteAOF 用当前数据集生成新的 AOF 文件：AOF 已打开时替换正在追加的文件，否则只写入文件。
+// 读取数据集期间持有重写屏障的写锁，写命令等待重写完成，读命令不受影响
+func (h *Handler) rewriteAOF() error {
+	a := &h.root().aof
+	a.mu.Lock()
+	path := a.path
+	a.mu.Unlock()
+	if path == "" {
+		return errors.New("appendonly file path is not configured")
+	}
+
+	a.barrier.Lock()
+	defer a.barrier.Unlock()
+	start := time.Now()
+	a.rewriteStart.Store(start.UnixMilli())
+	var err error
+	if l := h.aofLog(); l != nil {
+		err = l.Rewrite(h.writeAOFSnapshot)
+	} else {
+		err = aof.WriteFile(path, h.writeAOFSnapshot)
+	}
+	a.lastRewriteMs.Store(time.Since(start).Milliseconds())
+	a.rewriteFailed.Store(err != nil)
+	if err != nil {
+		logger.Logger.Error().Err(err).Str("path", path).Msg("重写 AOF 失败")
+		return err
+	}
+	a.rewrites.Add(1)
+	logger.Logger.Info().Str("path", path).Dur("duration", time.Since(start)).Msg("AOF 重写完成")
+	return nil
+}
+
+// writeHashFieldTTLs 哈希中设置了过期时间的字段写成 HPEXPIREAT，DUMP 的数据不含字段的过期时间。
+// 读取存储中的键 key，命令中写入 AOF 使用的键名 name
+func (h *Handler) writeHashFieldTTLs(w *aof.Writer, key, name string) error {
+	values, err := h.Db.HGetAll(key)
+	if err != nil || len(values) == 0 {
+		return nil
+	}
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
+	}
+	times, err := h.Db.HFieldExpireTimes(key, fields...)
+	if err != nil {
+		return nil
+	}
+	for i, at := range times {
+		if at < 0 {
+			continue
+		}
+		cmd := [][]byte{[]byte("HPEXPIREAT"), []byte(name), []byte(strconv.FormatInt(at, 10)), []byte("FIELDS"), []byte("1"), []byte(fields[i])}
+		if err := w.Write(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeAOFSnapshot 把每个键写成一条命令：RESTORE（DUMP 的序列化数据，含过期时间），
+// JSON 用 JSON.SET 与 PEXPIREAT。DUMP 不支持的类型（时间序列、地理位置）记录日志后跳过。
+// 搜索索引的定义写在键之前，重放时键在写入时被索引
+func (h *Handler) writeAOFSnapshot(w *aof.Writer) error {
+	for _, name := range h.Db.SearchIndexNames() {
+		info, err := h.Db.SearchIndexInfo(name)
+		if err != nil {
+			continue
+		}
+		if err := w.Write(append([][]byte{[]byte("FT.CREATE")}, searchCreateArgs(info.SearchIndex)...)); err != nil {
+			return err
+		}
+	}
+
+	var cursor uint64
+	skipped := 0
+	for {
+		page, err := h.Db.Scan(cursor, "*", 1000, "")
+		if err != nil {
+			return err
+		}
+		for _, key := range page.Keys {
+			// 正在回收的旧代不写入，其余的键写成第 0 代的键名
+			name, live := h.Db.CanonicalDBKey(key)
+			if !live {
+				continue
+			}
+			typ, err := h.Db.Type(key)
+			if err != nil {
+				return err
+			}
+			switch typ {
+			case "json":
+				doc, err := h.Db.JSONGet(key)
+				if err != nil || doc == "" {
+					continue
+				}
+				if err := w.Write([][]byte{[]byte("JSON.SET"), []byte(name), []byte("$"), []byte(doc)}); err != nil {
+					return err
+				}
+				if ttl, err := h.Db.PTTL(key); err == nil && ttl > 0 {
+					at := h.Db.Clock().Now().UnixMilli() + ttl
+					if err := w.Write([][]byte{[]byte("PEXPIREAT"), []byte(name), []byte(strconv.FormatInt(at, 10))}); err != nil {
+						return err
+					}
+				}
+			case "ts", "none":
+				skipped++
+			default:
+				payload, err := h.Db.Dump(key)
+				if err != nil {
+					// 读取期间过期的键不写入
+					if !strings.Contains(err.Error(), "no such key") {
+						skipped++
+						logger.Logger.Warn().Err(err).Str("key", key).Msg("AOF 重写跳过无法序列化的键")
+					}
+					continue
+				}
+				if err := w.Write([][]byte{[]byte("RESTORE"), []byte(name), []byte("0"), payload, []byte("REPLACE")}); err != nil {
+					return err
+				}
+				if typ == "hash" {
+					if err := h.writeHashFieldTTLs(w, key, name); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		cursor = page.Cursor
+		if cursor == 0 {
+			break
+		}
+	}
+	if skipped > 0 {
+		logger.Logger.Warn().Int("keys", skipped).Msg("AOF 重写跳过了不支持 DUMP 的键")
+	}
+	return nil
+}
+
+// handleBgRewriteAOF BGREWRITEAOF：在后台重写 AOF 文件
+func (h *Handler) handleBgRewriteAOF() proto.RESP {
+	a := &h.root().aof
+	a.mu.Lock()
+	path := a.path
+	a.mu.Unlock()
+	if path == "" {
+		return proto.NewError("ERR appendonly file path is not configured")
+	}
+	if !a.rewriting.CompareAndSwap(false, true) {
+		return proto.NewError("ERR Background append only file rewriting already in progress")
+	}
+	go func() {
+		defer a.rewriting.Store(false)
+		_ = h.rewriteAOF()
+	}()
+	return proto.NewSimpleString("Background append only file rewriting started")
+}
+
+// setAppendOnly CONFIG SET appendonly：开启时在后台用当前数据集生成文件后开始追加，关闭时关闭文件
+func (h *Handler) setAppendOnly(on bool) error {
+	a := &h.root().aof
+	if !on {
+		return h.CloseAOF()
+	}
+	a.mu.Lock()
+	path := a.path
+	a.mu.Unlock()
+	if path == "" {
+		return errors.New("appendonly file path is not configured")
+	}
+	if a.enabled.Swap(true) {
+		return nil
+	}
+	go func() {
+		for !a.rewriting.CompareAndSwap(false, true) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		defer a.rewriting.Store(false)
+		err := h.rewriteAOF()
+		if err == nil && a.enabled.Load() {
+			err = h.openAOF()
+		}
+		if err != nil {
+			a.enabled.Store(false)
+			logger.Logger.Error().Err(err).Msg("开启 AOF 失败")
+		}
+	}()
+	return nil
+}
+
+// writeAOFInfo 写入 INFO persistence 中的 AOF 状态
+func (h *Handler) writeAOFInfo(b *strings.Builder) {
+	a := &h.root().aof
+	rewriting := a.rewriting.Load()
+	current := int64(-1)
+	if rewriting {
+		current = (time.Now().UnixMilli() - a.rewriteStart.Load()) / 1000
+	}
+	last := a.lastRewriteMs.Load()
+	if last > 0 {
+		last /= 1000
+	} else if a.rewrites.Load() == 0 && !a.rewriteFailed.Load() {
+		last = -1
+	}
+	status := func(failed bool) string {
+		if failed {
+			return "err"
+		}
+		return "ok"
+	}
+	l := h.aofLog()
+	var stats aof.Stats
+	if l != nil {
+		stats = l.Stats()
+	}
+	b.WriteString(fmt.Sprintf("aof_enabled:%d\n", boolToInt(a.enabled.Load())))
+	b.WriteString(fmt.Sprintf("aof_rewrite_in_progress:%d\n", boolToInt(rewriting)))
+	b.WriteString("aof_rewrite_scheduled:0\n")
+	b.WriteString(fmt.Sprintf("aof_last_rewrite_time_sec:%d\n", last))
+	b.WriteString(fmt.Sprintf("aof_current_rewrite_time_sec:%d\n", current))
+	b.WriteString(fmt.Sprintf("aof_last_bgrewrite_status:%s\n", status(a.rewriteFailed.Load())))
+	b.WriteString(fmt.Sprintf("aof_rewrites:%d\n", a.rewrites.Load()))
+	b.WriteString(fmt.Sprintf("aof_last_write_status:%s\n", status(stats.LastWriteErr != nil)))
+	if l != nil {
+		b.WriteString(fmt.Sprintf("aof_current_size:%d\n", stats.Size))
+		b.WriteString(fmt.Sprintf("aof_base_size:%d\n", stats.BaseSize))
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/backup"
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// handleBackup 处理 BOLTREON.BACKUP：
+//
+//	BOLTREON.BACKUP [INCREMENTAL]  做一次 Badger 备份，必要时为全量备份（见 backup.BackupManager.RunBackup）
+//	BOLTREON.BACKUP FULL           做一次全量备份
+//	BOLTREON.BACKUP LIST           按时间列出 [文件名, full|incremental, unix_ms, 版本]
+//
+// 备份记入备份目录的清单，由 backup-schedule 定时执行、按 backup-retention 删除，
+// 用 cmd/restore 恢复到某个时间点
+func (h *Handler) handleBackup(args [][]byte) proto.RESP {
+	if h.Backup == nil {
+		return proto.NewError("ERR backup not enabled")
+	}
+	sub := "INCREMENTAL"
+	if len(args) == 1 {
+		sub = strings.ToUpper(string(args[0]))
+	}
+	if sub == "LIST" {
+		entries, err := h.Backup.Backups()
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		elems := make([]proto.RESP, len(entries))
+		for i, e := range entries {
+			elems[i] = &proto.Array{Args: [][]byte{
+				[]byte(e.File),
+				[]byte(backupKind(e)),
+				[]byte(strconv.FormatInt(e.Time.UnixMilli(), 10)),
+				[]byte(strconv.FormatUint(e.Version, 10)),
+			}}
+		}
+		return &proto.NestedArray{Elems: elems}
+	}
+	start := time.Now()
+	entry, err := h.Backup.RunBackup(sub == "FULL")
+	h.addLatencySample(latencyEventSnapshot, time.Since(start))
+	if err != nil {
+		return proto.NewError(fmt.Sprintf("ERR %v", err))
+	}
+	return &proto.Array{Args: [][]byte{[]byte(entry.File), []byte(backupKind(entry))}}
+}
+
+func backupKind(e backup.BackupEntry) string {
+	if e.Full {
+		return "full"
+	}
+	return "incremental"
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// parseBitRange 解析 BITCOUNT/BITPOS 的 [start [end [BYTE|BIT]]]
+func parseBitRange(args [][]byte) (store.BitRange, proto.RESP) {
+	var r store.BitRange
+	if len(args) > 3 {
+		return r, proto.NewError(errSyntax)
+	}
+	var err error
+	if len(args) >= 1 {
+		if r.Start, err = strconv.ParseInt(string(args[0]), 10, 64); err != nil {
+			return r, proto.NewError(errNotInteger)
+		}
+	}
+	if len(args) >= 2 {
+		if r.End, err = strconv.ParseInt(string(args[1]), 10, 64); err != nil {
+			return r, proto.NewError(errNotInteger)
+		}
+		r.HasEnd = true
+	}
+	if len(args) == 3 {
+		switch strings.ToUpper(string(args[2])) {
+		case "BYTE":
+			r.Unit = store.BitUnitByte
+		case "BIT":
+			r.Unit = store.BitUnitBit
+		default:
+			return r, proto.NewError(errSyntax)
+		}
+	}
+	return r, nil
+}
+
+// parseBitOffset 解析 SETBIT/GETBIT 的位偏移
+func parseBitOffset(arg []byte) (int, proto.RESP) {
+	offset, err := strconv.ParseUint(string(arg), 10, 32)
+	if err != nil {
+		return 0, proto.NewError("ERR bit offset is not an integer or out of range")
+	}
+	return int(offset), nil
+}
+
+// bitFieldReply BITFIELD 的回复：每个 GET/SET/INCRBY 一个整数，OVERFLOW FAIL 下溢出的为 nil
+func bitFieldReply(results []*int64) proto.RESP {
+	elems := make([]proto.RESP, len(results))
+	for i, r := range results {
+		if r == nil {
+			elems[i] = proto.NewBulkString(nil)
+			continue
+		}
+		elems[i] = proto.NewInteger(*r)
+	}
+	return &proto.NestedArray{Elems: elems}
+}
@@ -0,0 +1,552 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// errInvalidClientName CLIENT SETNAME 与 HELLO SETNAME 的名称含空格、换行或其他特殊字符
+const errInvalidClientName = "ERR Client names cannot contain spaces, newlines or special characters."
+
+// subcommandContainers CLIENT LIST 的 cmd 字段对这些命令显示为 "命令|子命令"，与 Redis 相同
+var subcommandContainers = map[string]bool{
+	"CLIENT": true, "CONFIG": true, "COMMAND": true, "CLUSTER": true, "DEBUG": true,
+	"LATENCY": true, "MEMORY": true, "OBJECT": true, "PUBSUB": true, "SCRIPT": true,
+	"SLOWLOG": true, "XGROUP": true, "XINFO": true,
+}
+
+// pauseWriteCommands 除 isWriteCommand 外，CLIENT PAUSE WRITE 期间同样暂停的命令
+var pauseWriteCommands = map[string]bool{
+	"EVAL": true, "EVALSHA": true, "PUBLISH": true, "SPUBLISH": true, "FLUSHDB": true, "FLUSHALL": true,
+}
+
+// clientRegistry 服务器上所有客户端连接（只保存在服务器级），CLIENT LIST/KILL 据此查找连接
+type clientRegistry struct {
+	nextID  atomic.Int64
+	mu      sync.RWMutex
+	clients map[int64]*ClientInfo
+}
+
+// register 为新连接分配 ID 并登记
+func (r *clientRegistry) register(conn net.Conn) *ClientInfo {
+	now := time.Now()
+	c := &ClientInfo{
+		ID:              r.nextID.Add(1),
+		Addr:            conn.RemoteAddr().String(),
+		FD:              connFD(conn),
+		laddr:           conn.LocalAddr().String(),
+		conn:            conn,
+		created:         now,
+		lastInteraction: now,
+		Multi:           -1,
+	}
+	r.mu.Lock()
+	if r.clients == nil {
+		r.clients = make(map[int64]*ClientInfo)
+	}
+	r.clients[c.ID] = c
+	r.mu.Unlock()
+	return c
+}
+
+// unregister 连接关闭后移除登记
+func (r *clientRegistry) unregister(c *ClientInfo) {
+	r.mu.Lock()
+	delete(r.clients, c.ID)
+	r.mu.Unlock()
+}
+
+// list 返回当前所有连接，按 ID 排序
+func (r *clientRegistry) list() []*ClientInfo {
+	r.mu.RLock()
+	list := make([]*ClientInfo, 0, len(r.clients))
+	for _, c := range r.clients {
+		list = append(list, c)
+	}
+	r.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// connFD 返回连接的文件描述符，取不到时为 -1
+func connFD(conn net.Conn) int {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	sc, ok := conn.(interface {
+		SyscallConn() (syscall.RawConn, error)
+	})
+	if !ok {
+		return -1
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return -1
+	}
+	fd := -1
+	_ = raw.Control(func(f uintptr) {
+		fd = int(f) // #nosec G115 - 文件描述符不会超过 int
+	})
+	return fd
+}
+
+// validClientName 客户端名称只能由可见的 ASCII 字符组成，空字符串表示清除名称
+func validClientName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] < '!' || name[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// setName 设置客户端名称
+func (c *ClientInfo) setName(name string) {
+	c.mu.Lock()
+	c.Name = name
+	c.mu.Unlock()
+}
+
+// name 返回客户端名称
+func (c *ClientInfo) name() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Name
+}
+
+// setNoEvict CLIENT NO-EVICT 的设置，CLIENT LIST 中显示为 e 标志
+func (c *ClientInfo) setNoEvict(on bool) {
+	c.mu.Lock()
+	c.noEvict = on
+	c.mu.Unlock()
+}
+
+// setPubSub 连接进入或离开订阅模式
+func (c *ClientInfo) setPubSub(on bool) {
+	c.mu.Lock()
+	c.pubsub = on
+	c.mu.Unlock()
+}
+
+// isPubSub 连接是否处于订阅模式
+func (c *ClientInfo) isPubSub() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pubsub
+}
+
+// age 连接建立以来的时间
+func (c *ClientInfo) age(now time.Time) time.Duration {
+	return now.Sub(c.created)
+}
+
+// kill 关闭连接，连接的读取随即失败并退出处理循环
+func (c *ClientInfo) kill() {
+	if c.conn != nil {
+		_ = c.conn.Close()
+	}
+}
+
+// line 返回 CLIENT LIST/INFO 中的一行，字段与 Redis 相同（不支持的字段省略）
+func (c *ClientInfo) line(now time.Time) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	flags := ""
+	if c.pubsub {
+		flags += "P"
+	}
+	if c.Multi >= 0 {
+		flags += "x"
+	}
+	if c.noEvict {
+		flags += "e"
+	}
+	if c.ReadOnly {
+		flags += "r"
+	}
+	if flags == "" {
+		flags = "N"
+	}
+	var age, idle int64
+	if !c.created.IsZero() {
+		age = int64(now.Sub(c.created) / time.Second)
+		idle = int64(now.Sub(c.lastInteraction) / time.Second)
+	}
+	return fmt.Sprintf("id=%d addr=%s laddr=%s fd=%d name=%s age=%d idle=%d flags=%s db=%d multi=%d watch=%d qbuf=%d obl=%d omem=%d events=r cmd=%s user=default resp=%d",
+		c.ID, c.Addr, c.laddr, c.FD, c.Name, age, idle, flags, c.DB, c.Multi, c.watch,
+		c.qbuf, c.obuf, c.obuf, c.Cmd, c.resp)
+}
+
+// trackCommand 记录连接收到的命令：最近命令、交互时间、事务状态与读写缓冲区中的字节数。
+// 未经 ServeTCP 建立的 Handler（测试、嵌入式使用）没有客户端信息，不做任何事
+func (h *Handler) trackCommand(cmd string, args [][]byte, reader *bufio.Reader, writer *bufio.Writer) {
+	c := h.clientInfo
+	if c == nil {
+		return
+	}
+	name := strings.ToLower(cmd)
+	if subcommandContainers[cmd] && len(args) > 0 {
+		name += "|" + strings.ToLower(string(args[0]))
+	}
+	multi, watch := -1, 0
+	if h.inMulti() {
+		multi = len(h.transaction.Commands)
+	}
+	if h.transaction != nil {
+		watch = len(h.transaction.WatchKeys)
+	}
+	resp := 2
+	if h.resp3() {
+		resp = 3
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Cmd = name
+	c.lastInteraction = time.Now()
+	c.Multi, c.watch, c.resp = multi, watch, resp
+	c.DB = h.db
+	c.qbuf, c.obuf = 0, 0
+	if reader != nil {
+		c.qbuf = reader.Buffered()
+	}
+	if writer != nil {
+		c.obuf = writer.Buffered()
+	}
+}
+
+// clientPause CLIENT PAUSE 的状态（只保存在服务器级）
+type clientPause struct {
+	mu        sync.Mutex
+	until     time.Time
+	writeOnly bool          // WRITE 模式只暂停写命令
+	changed   chan struct{} // 暂停被修改或解除时关闭，唤醒等待的连接
+}
+
+// pause 暂停客户端到 until。已有暂停时取较晚的结束时间，ALL 模式优先于 WRITE 模式
+func (p *clientPause) pause(until time.Time, writeOnly bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Now().Before(p.until) {
+		if until.Before(p.until) {
+			until = p.until
+		}
+		writeOnly = writeOnly && p.writeOnly
+	}
+	p.until, p.writeOnly = until, writeOnly
+	p.notify()
+}
+
+// unpause 解除暂停
+func (p *clientPause) unpause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.until = time.Time{}
+	p.notify()
+}
+
+func (p *clientPause) notify() {
+	if p.changed != nil {
+		close(p.changed)
+	}
+	p.changed = make(chan struct{})
+}
+
+// wait 暂停期间阻塞，直到暂停结束或被解除。write 表示命令会修改数据
+func (p *clientPause) wait(write bool) {
+	for {
+		p.mu.Lock()
+		until, writeOnly, changed := p.until, p.writeOnly, p.changed
+		p.mu.Unlock()
+		d := time.Until(until)
+		if d <= 0 || (writeOnly && !write) {
+			return
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-changed:
+		}
+		timer.Stop()
+	}
+}
+
+// waitClientPause CLIENT PAUSE 期间延迟命令的执行。CLIENT 命令本身不受影响，以便执行 CLIENT UNPAUSE；
+// EXEC 按事务中是否有写命令判断
+func (h *Handler) waitClientPause(cmd string) {
+	if cmd == "CLIENT" {
+		return
+	}
+	write := isWriteCommand(cmd) || pauseWriteCommands[cmd]
+	if cmd == "EXEC" && h.transaction != nil {
+		for _, c := range h.transaction.Commands {
+			if isWriteCommand(c.Command) || pauseWriteCommands[c.Command] {
+				write = true
+				break
+			}
+		}
+	}
+	h.root().clientPause.wait(write)
+}
+
+// handleClientCommand CLIENT 子命令
+func (h *Handler) handleClientCommand(args [][]byte) proto.RESP {
+	subcommand := strings.ToUpper(string(args[0]))
+	switch subcommand {
+	case "LIST":
+		return h.clientList(args[1:])
+	case "INFO":
+		if len(args) != 1 {
+			return proto.NewError("ERR wrong number of arguments for 'client|info' command")
+		}
+		c := h.clientInfo
+		if c == nil {
+			c = &ClientInfo{Multi: -1}
+		}
+		return proto.NewBulkString([]byte(c.line(time.Now()) + "\n"))
+	case "ID":
+		if h.clientInfo != nil && h.clientInfo.ID != 0 {
+			return proto.NewInteger(h.clientInfo.ID)
+		}
+		return proto.NewInteger(1)
+	case "GETNAME":
+		if h.clientInfo != nil {
+			if name := h.clientInfo.name(); name != "" {
+				return proto.NewBulkString([]byte(name))
+			}
+		}
+		// 未设置名称时返回 nil BulkString（go-redis 会将其作为 nil 返回）
+		return proto.NewBulkString(nil)
+	case "SETNAME":
+		if len(args) != 2 {
+			return proto.NewError("ERR wrong number of arguments for 'client|setname' command")
+		}
+		name := string(args[1])
+		if !validClientName(name) {
+			return proto.NewError(errInvalidClientName)
+		}
+		if h.clientInfo == nil {
+			h.clientInfo = &ClientInfo{Multi: -1}
+		}
+		h.clientInfo.setName(name)
+		return proto.OK
+	case "KILL":
+		if len(args) < 2 {
+			return proto.NewError("ERR wrong number of arguments for 'client|kill' command")
+		}
+		return h.clientKill(args[1:])
+	case "PAUSE":
+		if len(args) != 2 && len(args) != 3 {
+			return proto.NewError("ERR wrong number of arguments for 'client|pause' command")
+		}
+		ms, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return proto.NewError("ERR timeout is not an integer or out of range")
+		}
+		if ms < 0 {
+			return proto.NewError("ERR timeout is negative")
+		}
+		writeOnly := false
+		if len(args) == 3 {
+			switch strings.ToUpper(string(args[2])) {
+			case "WRITE":
+				writeOnly = true
+			case "ALL":
+			default:
+				return proto.NewError(errSyntax)
+			}
+		}
+		h.root().clientPause.pause(time.Now().Add(time.Duration(ms)*time.Millisecond), writeOnly)
+		return proto.OK
+	case "UNPAUSE":
+		h.root().clientPause.unpause()
+		return proto.OK
+	case "NO-EVICT", "NOEVICT":
+		if len(args) != 2 {
+			return proto.NewError("ERR wrong number of arguments for 'client|no-evict' command")
+		}
+		mode := strings.ToUpper(string(args[1]))
+		if mode != "ON" && mode != "OFF" {
+			return proto.NewError(errSyntax)
+		}
+		if h.clientInfo != nil {
+			h.clientInfo.setNoEvict(mode == "ON")
+		}
+		return proto.OK
+	case "TRACKING":
+		if len(args) < 2 {
+			return proto.NewError("ERR wrong number of arguments for 'client|tracking' command")
+		}
+		mode := strings.ToUpper(string(args[1]))
+		if mode != "ON" && mode != "OFF" {
+			return proto.NewError(errSyntax)
+		}
+		// tracking 模式（简化实现）
+		return proto.OK
+	default:
+		return proto.NewError(fmt.Sprintf("ERR unknown subcommand '%s'", string(args[0])))
+	}
+}
+
+// clientList CLIENT LIST [TYPE normal|master|replica|pubsub] [ID client-id ...]
+func (h *Handler) clientList(args [][]byte) proto.RESP {
+	var typ string
+	var ids map[int64]bool
+	if len(args) > 0 {
+		switch strings.ToUpper(string(args[0])) {
+		case "TYPE":
+			if len(args) != 2 {
+				return proto.NewError(errSyntax)
+			}
+			typ = strings.ToLower(string(args[1]))
+			if !validClientType(typ) {
+				return proto.NewError(fmt.Sprintf("ERR Unknown client type '%s'", string(args[1])))
+			}
+		case "ID":
+			if len(args) < 2 {
+				return proto.NewError(errSyntax)
+			}
+			ids = make(map[int64]bool, len(args)-1)
+			for _, a := range args[1:] {
+				id, err := strconv.ParseInt(string(a), 10, 64)
+				if err != nil || id <= 0 {
+					return proto.NewError("ERR Invalid client ID")
+				}
+				ids[id] = true
+			}
+		default:
+			return proto.NewError(errSyntax)
+		}
+	}
+	now := time.Now()
+	var b strings.Builder
+	for _, c := range h.root().clients.list() {
+		if (typ != "" && !c.isType(typ)) || (ids != nil && !ids[c.ID]) {
+			continue
+		}
+		b.WriteString(c.line(now))
+		b.WriteByte('\n')
+	}
+	return proto.NewBulkString([]byte(b.String()))
+}
+
+// validClientType CLIENT LIST/KILL 的 TYPE 参数（slave 为 replica 的别名）
+func validClientType(typ string) bool {
+	switch typ {
+	case "normal", "master", "replica", "slave", "pubsub":
+		return true
+	}
+	return false
+}
+
+// isType 连接是否属于 TYPE 过滤的类型。复制连接由复制处理接管后不再登记，master 与 replica 不匹配任何连接
+func (c *ClientInfo) isType(typ string) bool {
+	switch typ {
+	case "normal":
+		return !c.isPubSub()
+	case "pubsub":
+		return c.isPubSub()
+	}
+	return false
+}
+
+// clientKill CLIENT KILL ip:port，或 CLIENT KILL <filter> <value> ...：
+// 按 ID、ADDR、LADDR、USER、TYPE、MAXAGE 过滤，SKIPME no 时可以关闭当前连接。
+// 旧格式找不到连接时返回错误，新格式返回关闭的连接数。当前连接在回复写出后关闭
+func (h *Handler) clientKill(args [][]byte) proto.RESP {
+	var (
+		filterID     int64
+		addr, laddr  string
+		user, typ    string
+		maxAge       time.Duration
+		skipMe       = true
+		legacyFormat = len(args) == 1
+	)
+	if legacyFormat {
+		addr = string(args[0])
+		skipMe = false
+	} else {
+		if len(args)%2 != 0 {
+			return proto.NewError(errSyntax)
+		}
+		for i := 0; i < len(args); i += 2 {
+			value := string(args[i+1])
+			switch strings.ToUpper(string(args[i])) {
+			case "ID":
+				id, err := strconv.ParseInt(value, 10, 64)
+				if err != nil || id <= 0 {
+					return proto.NewError("ERR client-id should be greater than 0")
+				}
+				filterID = id
+			case "ADDR":
+				addr = value
+			case "LADDR":
+				laddr = value
+			case "USER":
+				user = value
+			case "TYPE":
+				typ = strings.ToLower(value)
+				if !validClientType(typ) {
+					return proto.NewError(fmt.Sprintf("ERR Unknown client type '%s'", value))
+				}
+			case "MAXAGE":
+				secs, err := strconv.ParseInt(value, 10, 64)
+				if err != nil || secs <= 0 {
+					return proto.NewError("ERR maxage should be greater than 0")
+				}
+				maxAge = time.Duration(secs) * time.Second
+			case "SKIPME":
+				switch strings.ToLower(value) {
+				case "yes":
+					skipMe = true
+				case "no":
+					skipMe = false
+				default:
+					return proto.NewError(errSyntax)
+				}
+			default:
+				return proto.NewError(errSyntax)
+			}
+		}
+	}
+
+	now := time.Now()
+	killed := int64(0)
+	for _, c := range h.root().clients.list() {
+		switch {
+		case filterID != 0 && c.ID != filterID,
+			addr != "" && c.Addr != addr,
+			laddr != "" && c.laddr != laddr,
+			user != "" && user != "default",
+			typ != "" && !c.isType(typ),
+			maxAge > 0 && c.age(now) < maxAge:
+			continue
+		}
+		if c == h.clientInfo {
+			if skipMe {
+				continue
+			}
+			h.closeAfterReply = true
+		} else {
+			c.kill()
+		}
+		killed++
+	}
+	if legacyFormat {
+		if killed == 0 {
+			return proto.NewError("ERR No such client")
+		}
+		return proto.OK
+	}
+	return proto.NewInteger(killed)
+}
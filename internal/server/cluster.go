@@ -0,0 +1,199 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// keySpec 命令参数（不含命令名）中键的位置：从 first 开始每隔 step 个参数一个键直到 last，
+// last 为负数时从末尾倒数（-1 为最后一个参数）
+type keySpec struct {
+	first, last, step int
+}
+
+var (
+	singleKey   = keySpec{0, 0, 1}
+	allKeys     = keySpec{0, -1, 1}
+	twoKeys     = keySpec{0, 1, 1}
+	blockingPop = keySpec{0, -2, 1} // 最后一个参数是超时
+)
+
+// commandKeySpecs 键位置固定的命令；numkeys、STREAMS、STORE 等位置取决于参数的命令由 commandKeys 单独解析
+var commandKeySpecs = map[string]keySpec{
+	// 字符串
+	"GET": singleKey, "SET": singleKey, "SETEX": singleKey, "PSETEX": singleKey, "SETNX": singleKey,
+	"GETSET": singleKey, "GETEX": singleKey, "GETDEL": singleKey, "INCR": singleKey, "INCRBY": singleKey, "DECR": singleKey, "DECRBY": singleKey,
+	"INCRBYFLOAT": singleKey, "APPEND": singleKey, "STRLEN": singleKey, "GETRANGE": singleKey, "SETRANGE": singleKey,
+	"SETBIT": singleKey, "GETBIT": singleKey, "BITCOUNT": singleKey, "BITFIELD": singleKey, "BITPOS": singleKey,
+	"BITFIELD_RO": singleKey, "BITLEN": singleKey, "MGET": allKeys, "MSET": {0, -1, 2}, "MSETNX": {0, -1, 2}, "BITOP": {1, -1, 1},
+	"PFADD": singleKey, "PFCOUNT": allKeys, "PFMERGE": allKeys, "PFINFO": singleKey,
+
+	// 键
+	"DEL": allKeys, "UNLINK": allKeys, "EXISTS": allKeys, "TOUCH": allKeys, "WATCH": allKeys, "TYPE": singleKey,
+	"DUMP": singleKey, "RESTORE": singleKey, "EXPIRE": singleKey, "EXPIREAT": singleKey, "PEXPIRE": singleKey,
+	"PEXPIREAT": singleKey, "TTL": singleKey, "PTTL": singleKey, "PERSIST": singleKey, "MOVE": singleKey,
+	"RENAME": twoKeys, "RENAMENX": twoKeys, "COPY": twoKeys,
+	"SSCAN": singleKey, "HSCAN": singleKey, "ZSCAN": singleKey,
+
+	// 列表
+	"LPUSH": singleKey, "RPUSH": singleKey, "LPUSHX": singleKey, "RPUSHX": singleKey, "LPOP": singleKey,
+	"RPOP": singleKey, "LLEN": singleKey, "LINDEX": singleKey, "LRANGE": singleKey, "LSET": singleKey,
+	"LTRIM": singleKey, "LINSERT": singleKey, "LPOS": singleKey, "LREM": singleKey,
+	"RPOPLPUSH": twoKeys, "LMOVE": twoKeys, "BLMOVE": twoKeys, "BRPOPLPUSH": twoKeys,
+	"BLPOP": blockingPop, "BRPOP": blockingPop,
+
+	// 哈希
+	"HSET": singleKey, "HGET": singleKey, "HDEL": singleKey, "HLEN": singleKey, "HGETALL": singleKey,
+	"HEXISTS": singleKey, "HKEYS": singleKey, "HVALS": singleKey, "HMSET": singleKey, "HMGET": singleKey,
+	"HSETNX": singleKey, "HINCRBY": singleKey, "HINCRBYFLOAT": singleKey, "HSTRLEN": singleKey, "HRANDFIELD": singleKey,
+	"HEXPIRE": singleKey, "HPEXPIRE": singleKey, "HEXPIREAT": singleKey, "HPEXPIREAT": singleKey, "HPERSIST": singleKey,
+	"HTTL": singleKey, "HPTTL": singleKey, "HEXPIRETIME": singleKey, "HPEXPIRETIME": singleKey,
+
+	// 集合
+	"SADD": singleKey, "SREM": singleKey, "SCARD": singleKey, "SISMEMBER": singleKey, "SMISMEMBER": singleKey,
+	"SMEMBERS": singleKey, "SPOP": singleKey, "SRANDMEMBER": singleKey, "SMOVE": twoKeys,
+	"SINTER": allKeys, "SUNION": allKeys, "SDIFF": allKeys,
+	"SINTERSTORE": allKeys, "SUNIONSTORE": allKeys, "SDIFFSTORE": allKeys,
+
+	// 有序集合
+	"ZADD": singleKey, "ZREM": singleKey, "ZCARD": singleKey, "ZSCORE": singleKey, "ZMSCORE": singleKey,
+	"ZRANGE": singleKey, "ZREVRANGE": singleKey, "ZRANGEBYSCORE": singleKey, "ZREVRANGEBYSCORE": singleKey,
+	"ZRANK": singleKey, "ZREVRANK": singleKey, "ZCOUNT": singleKey, "ZINCRBY": singleKey,
+	"ZREMRANGEBYRANK": singleKey, "ZREMRANGEBYSCORE": singleKey, "ZPOPMAX": singleKey, "ZPOPMIN": singleKey,
+	"ZLEXCOUNT": singleKey, "ZRANGEBYLEX": singleKey, "ZREVRANGEBYLEX": singleKey, "ZREMRANGEBYLEX": singleKey,
+	"ZRANGESTORE": twoKeys, "BZPOPMAX": blockingPop, "BZPOPMIN": blockingPop,
+	"BOLTREON.ZWATCH": singleKey, "BOLTREON.ZUNWATCH": singleKey, "BOLTREON.ZMERGE": twoKeys,
+
+	// 地理位置
+	"GEOADD": singleKey, "GEOPOS": singleKey, "GEOHASH": singleKey, "GEODIST": singleKey,
+	"GEOSEARCH": singleKey, "GEOSEARCHSTORE": twoKeys,
+
+	// 队列与 Stream
+	"QPUSH": singleKey, "QPOP": singleKey, "QACK": singleKey,
+	"XADD": singleKey, "XLEN": singleKey, "XRANGE": singleKey, "XREVRANGE": singleKey, "XDEL": singleKey,
+	"XACK": singleKey, "XCLAIM": singleKey, "XAUTOCLAIM": singleKey, "XPENDING": singleKey, "XTRIM": singleKey, "XSETID": singleKey,
+
+	// JSON 与时间序列
+	"JSON.SET": singleKey, "JSON.GET": singleKey, "JSON.DEL": singleKey, "JSON.TYPE": singleKey,
+	"JSON.ARRAPPEND": singleKey, "JSON.ARRLEN": singleKey, "JSON.OBJKEYS": singleKey, "JSON.NUMINCRBY": singleKey,
+	"JSON.NUMMULTBY": singleKey, "JSON.CLEAR": singleKey, "JSON.DEBUG": singleKey, "JSON.MGET": {0, -2, 1},
+	"JSON.ARRINSERT": singleKey, "JSON.ARRPOP": singleKey, "JSON.ARRINDEX": singleKey, "JSON.ARRTRIM": singleKey,
+	"JSON.STRAPPEND": singleKey, "JSON.STRLEN": singleKey, "JSON.TOGGLE": singleKey, "JSON.MERGE": singleKey, "JSON.MSET": {0, -1, 3},
+	"TS.CREATE": singleKey, "TS.ADD": singleKey, "TS.GET": singleKey, "TS.RANGE": singleKey, "TS.DEL": singleKey,
+	"TS.INFO": singleKey, "TS.LEN": singleKey,
+
+	// 分片频道与键一样按槽分配
+	"SPUBLISH": singleKey, "SSUBSCRIBE": allKeys, "SUNSUBSCRIBE": allKeys,
+}
+
+// commandKeys 命令涉及的键，用于集群模式下判断应由哪个节点执行。
+// 参数不完整时返回能确定的部分，参数错误由命令自己报告
+func commandKeys(cmd string, args [][]byte) []string {
+	var keys []string
+	for _, i := range commandKeyPositions(cmd, args) {
+		keys = append(keys, string(args[i]))
+	}
+	return keys
+}
+
+// commandKeyPositions 命令涉及的键在 args 中的下标，规则与 commandKeys 相同
+func commandKeyPositions(cmd string, args [][]byte) []int {
+	if spec, ok := commandKeySpecs[cmd]; ok {
+		return positionsBySpec(len(args), 0, spec)
+	}
+	switch cmd {
+	case "EVAL", "EVALSHA", "SINTERCARD", "ZMPOP", "BZMPOP":
+		// EVAL script numkeys key...；SINTERCARD/ZMPOP numkeys key...；BZMPOP timeout numkeys key...
+		pos := 1
+		if cmd == "SINTERCARD" || cmd == "ZMPOP" {
+			pos = 0
+		}
+		return numKeyPositions(args, pos)
+	case "ZUNIONSTORE", "ZINTERSTORE", "ZDIFFSTORE":
+		// destination numkeys key...
+		if len(args) == 0 {
+			return nil
+		}
+		return append([]int{0}, numKeyPositions(args, 1)...)
+	case "XREAD", "XREADGROUP":
+		// STREAMS 之后前一半是键，后一半是 ID
+		for i, arg := range args {
+			if strings.EqualFold(string(arg), "STREAMS") {
+				return positionsBySpec((len(args)-i-1)/2, i+1, allKeys)
+			}
+		}
+		return nil
+	case "SORT":
+		positions := positionsBySpec(len(args), 0, singleKey)
+		for i := 1; i+1 < len(args); i++ {
+			if strings.EqualFold(string(args[i]), "STORE") {
+				positions = append(positions, i+1)
+			}
+		}
+		return positions
+	case "OBJECT", "XINFO", "XGROUP":
+		// OBJECT ENCODING key；XINFO STREAM key；XGROUP CREATE key ...
+		return positionsBySpec(len(args), 0, keySpec{1, 1, 1})
+	case "MEMORY":
+		if len(args) > 0 && strings.EqualFold(string(args[0]), "USAGE") {
+			return positionsBySpec(len(args), 0, keySpec{1, 1, 1})
+		}
+	case "BOLTREON.SUMRANGE":
+		if len(args) > 0 && strings.EqualFold(string(args[0]), "HASH") {
+			return positionsBySpec(len(args), 0, keySpec{1, 1, 1})
+		}
+	}
+	return nil
+}
+
+// positionsBySpec 按 spec 取出 n 个参数中键的下标并加上 offset，超出参数范围的位置忽略
+func positionsBySpec(n, offset int, spec keySpec) []int {
+	last := spec.last
+	if last < 0 {
+		last += n
+	}
+	if last >= n {
+		last = n - 1
+	}
+	var positions []int
+	for i := spec.first; i <= last; i += spec.step {
+		positions = append(positions, offset+i)
+	}
+	return positions
+}
+
+// numKeyPositions args[pos] 指定个数、紧随其后的键的下标
+func numKeyPositions(args [][]byte, pos int) []int {
+	if pos >= len(args) {
+		return nil
+	}
+	n, err := strconv.Atoi(string(args[pos]))
+	if err != nil || n <= 0 {
+		return nil
+	}
+	return positionsBySpec(len(args)-pos-1, pos+1, keySpec{0, n - 1, 1})
+}
+
+// checkClusterRedirect 集群模式下检查命令的键是否由本节点负责，不是时返回与 Redis 一致的
+// MOVED/ASK/TRYAGAIN/CROSSSLOT/CLUSTERDOWN 错误。与 Redis 相同，在执行或事务入队前检查，
+// ASKING 只对紧随其后的一条命令生效
+func (h *Handler) checkClusterRedirect(cmd string, args [][]byte) proto.RESP {
+	if h.Cluster == nil || cmd == "ASKING" {
+		return nil
+	}
+	asking := h.clusterAsking
+	h.clusterAsking = false
+
+	// 执行过 READONLY 的连接的读命令可以由从节点处理
+	readFromReplica := h.clusterReadOnly && !isDatasetWrite(cmd, args)
+	err := h.Cluster.CheckKeysRedirect(commandKeys(cmd, args), asking, readFromReplica, func(key string) bool {
+		exists, err := h.Db.Exists(key)
+		return err == nil && exists
+	})
+	if err != nil {
+		return proto.NewError(err.Error())
+	}
+	return nil
+}
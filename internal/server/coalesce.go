@@ -0,0 +1,262 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// coalesceMaxCommands 一次最多合并的命令数，限制单个事务的大小
+const coalesceMaxCommands = 1024
+
+// writeCoalescer 流水线写命令合并的开关与统计（只保存在服务器级）
+type writeCoalescer struct {
+	disabled atomic.Bool // CONFIG SET write-coalescing no
+	batches  atomic.Int64
+	commands atomic.Int64
+}
+
+// coalescedWrite 合并执行的一条命令
+type coalescedWrite struct {
+	cmd      string
+	args     [][]byte // 含命令名
+	ops      []store.BatchOp
+	first    int // ops 在整个批量中的起始位置
+	executed bool
+	drop     bool // DEBUG FAULT drop：执行但不回复
+	resp     proto.RESP
+}
+
+// coalescible 请求是否可以合并执行：不带选项的 SET 与 HSET、SADD、RPUSH
+func coalescible(args [][]byte) bool {
+	if len(args) < 3 {
+		return false
+	}
+	switch strings.ToUpper(string(args[0])) {
+	case "SET":
+		return len(args) == 3
+	case "HSET":
+		return len(args)%2 == 0
+	case "SADD", "RPUSH":
+		return true
+	}
+	return false
+}
+
+// canCoalesce 连接当前是否可以合并写命令。事务、加载、命名空间配额与写放大统计需要逐条执行
+func (h *Handler) canCoalesce() bool {
+	return h.Db != nil && h.db == 0 && !h.root().coalescer.disabled.Load() && !h.inMulti() && !h.IsLoading() &&
+		!h.Db.HasNamespaces() && !h.Db.WriteStatsEnabled()
+}
+
+// coalesceWrites 流水线中从 req 开始连续的可合并写命令在同一个 Badger 事务中执行，只提交（fsync）一次。
+// 只读取已经缓冲的请求，不等待更多数据；req 之后没有缓冲的请求时不合并，返回 nil 与 req。
+// 每条命令的检查、AOF、复制与统计与单独执行时相同，回复按顺序返回；
+// 同时返回之后读到的第一个不可合并的请求，没有时为 nil；读取之后的请求失败时返回读取错误，
+// 已经读到的请求照常执行
+func (h *Handler) coalesceWrites(req *proto.Array, reader *bufio.Reader, remoteAddr string, writer *bufio.Writer) ([]proto.RESP, *proto.Array, error) {
+	if reader.Buffered() == 0 || !coalescible(req.Args) || !h.canCoalesce() {
+		return nil, req, nil
+	}
+	reqs := []*proto.Array{req}
+	var next *proto.Array
+	var readErr error
+	for len(reqs) < coalesceMaxCommands && reader.Buffered() > 0 {
+		r, err := proto.ReadRESP(reader)
+		if err != nil {
+			logger.Logger.Debug().Str("remote_addr", remoteAddr).Err(err).Msg("Pipeline 中读取请求失败")
+			readErr = err
+			break
+		}
+		if !coalescible(r.Args) {
+			next = r
+			break
+		}
+		reqs = append(reqs, r)
+	}
+
+	writes := make([]coalescedWrite, len(reqs))
+	var ops []store.BatchOp
+	for i, r := range reqs {
+		w := &writes[i]
+		w.cmd, w.args = strings.ToUpper(string(r.Args[0])), r.Args
+		if w.resp = h.admitCoalescedWrite(w, reader, writer); w.resp != nil || !w.executed {
+			continue
+		}
+		w.ops = coalescedOps(w.cmd, w.args[1:])
+		w.first = len(ops)
+		ops = append(ops, w.ops...)
+	}
+
+	start := time.Now()
+	unlock := h.lockCommand(writes[0].cmd)
+	endAOF := h.beginAOF(writes[0].cmd, writes[0].args[1:])
+	if len(ops) > 0 {
+		results, err := h.Db.ApplyBatch(ops)
+		for i := range writes {
+			w := &writes[i]
+			if !w.executed || w.resp != nil {
+				continue
+			}
+			if err != nil {
+				// 整个批量失败（超过事务大小上限等）时逐条执行
+				w.resp = h.runCommand(w.cmd, w.args[1:], remoteAddr)
+				continue
+			}
+			w.resp = coalescedReply(w.cmd, results[w.first:w.first+len(w.ops)])
+			h.touchKeys(w.cmd, w.args[1:])
+		}
+		if err == nil {
+			c := &h.root().coalescer
+			c.batches.Add(1)
+			for i := range writes {
+				if len(writes[i].ops) > 0 {
+					c.commands.Add(1)
+				}
+			}
+		}
+	}
+	elapsed := time.Since(start)
+	executed := 0
+	for i := range writes {
+		if writes[i].executed {
+			h.feedAOF(writes[i].cmd, writes[i].args[1:], writes[i].resp)
+			executed++
+		}
+	}
+	endAOF()
+	unlock()
+	if executed > 0 {
+		h.addLatencySample(latencyEventCommand, elapsed)
+	}
+
+	// 耗时按命令平均分摊
+	share := elapsed
+	if executed > 0 {
+		share /= time.Duration(executed)
+	}
+	responses := make([]proto.RESP, len(writes))
+	for i := range writes {
+		w := &writes[i]
+		responses[i] = w.resp
+		if !w.executed {
+			continue
+		}
+		h.recordLatency(w.cmd, w.resp, share)
+		h.recordCommandStats(w.cmd, w.args[1:], w.resp, share)
+		h.recordSlowLog(w.cmd, w.args, start, share, remoteAddr)
+		h.propagateWrite(w.cmd, w.args, w.resp)
+		h.mirrorWrite(w.cmd, w.args, w.resp)
+		if w.drop {
+			responses[i] = proto.RawString("")
+			continue
+		}
+		responses[i] = h.compressReply(h.adaptReply(w.cmd, w.args[1:], w.resp))
+	}
+	return responses, next, readErr
+}
+
+// admitCoalescedWrite 执行前的检查，顺序与 processRequest、runCommand 相同。
+// 在 processRequest 阶段被拒绝的命令返回错误且 executed 为 false；
+// 参数类型与值大小检查失败的命令返回错误，executed 为 true，与单独执行时一样计入统计
+func (h *Handler) admitCoalescedWrite(w *coalescedWrite, reader *bufio.Reader, writer *bufio.Writer) proto.RESP {
+	cmd, args := w.cmd, w.args[1:]
+	h.trackCommand(cmd, args, reader, writer)
+	if resp := checkArity(cmd, args); resp != nil {
+		h.recordRejectedCommand(cmd)
+		return resp
+	}
+	if resp := h.checkClusterRedirect(cmd, args); resp != nil {
+		return resp
+	}
+	if resp := h.checkReadOnlyReplica(cmd, args); resp != nil {
+		h.recordRejectedCommand(cmd)
+		return resp
+	}
+	if resp := h.checkRateLimit(cmd, args); resp != nil {
+		h.recordRejectedCommand(cmd)
+		return resp
+	}
+	h.waitClientPause(cmd)
+	fault := h.matchFaults(cmd)
+	if fault.delay > 0 {
+		time.Sleep(fault.delay)
+	}
+	if fault.message != "" {
+		return proto.NewError(fault.message)
+	}
+	w.drop = fault.drop
+	if resp := h.checkMaxmemory(cmd); resp != nil {
+		h.recordRejectedCommand(cmd)
+		return resp
+	}
+
+	w.executed = true
+	if resp := checkArgKinds(cmd, args); resp != nil {
+		return resp
+	}
+	if resp := h.checkValueSizes(cmd, args); resp != nil {
+		return resp
+	}
+	h.expireAccessedKeys(cmd, args)
+	if h.Db.UnlinkPending() > 0 {
+		h.Db.AwaitUnlink(commandKeys(cmd, args)...)
+	}
+	return nil
+}
+
+// coalescedOps 把命令转换为批量操作，HSET 的每个字段一个操作
+func coalescedOps(cmd string, args [][]byte) []store.BatchOp {
+	key := string(args[0])
+	values := make([]string, len(args)-1)
+	for i, arg := range args[1:] {
+		values[i] = string(arg)
+	}
+	switch cmd {
+	case "SET":
+		return []store.BatchOp{{Kind: store.BatchSet, Key: key, Value: values[0]}}
+	case "HSET":
+		ops := make([]store.BatchOp, 0, len(values)/2)
+		for i := 0; i+1 < len(values); i += 2 {
+			ops = append(ops, store.BatchOp{Kind: store.BatchHSet, Key: key, Field: values[i], Value: values[i+1]})
+		}
+		return ops
+	case "SADD":
+		return []store.BatchOp{{Kind: store.BatchSAdd, Key: key, Values: values}}
+	default:
+		return []store.BatchOp{{Kind: store.BatchRPush, Key: key, Values: values}}
+	}
+}
+
+// coalescedReply 由命令对应操作的结果生成回复：SET 回复 OK，HSET 为新增字段数之和，
+// SADD 为新增成员数，RPUSH 为追加后的长度。键类型不符时回复 WRONGTYPE
+func coalescedReply(cmd string, results []store.BatchResult) proto.RESP {
+	var n int64
+	for _, r := range results {
+		if errors.Is(r.Err, store.ErrBatchWrongType) {
+			return proto.NewError(wrongTypeErrorMessage)
+		}
+		if r.Err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", r.Err))
+		}
+		n += r.N
+	}
+	if cmd == "SET" {
+		return proto.OK
+	}
+	return proto.NewInteger(n)
+}
+
+// writeCoalesceStats 写入 INFO stats 中的写命令合并统计
+func (h *Handler) writeCoalesceStats(b *strings.Builder) {
+	c := &h.root().coalescer
+	b.WriteString(fmt.Sprintf("coalesced_write_batches:%d\n", c.batches.Load()))
+	b.WriteString(fmt.Sprintf("coalesced_write_commands:%d\n", c.commands.Load()))
+}
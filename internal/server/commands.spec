@@ -0,0 +1,183 @@
+# 命令参数表：go generate ./internal/server 据此生成 validators_gen.go
+#
+# 每行：命令名  arity  参数类型...
+#   arity 与 Redis COMMAND 返回的定义相同（含命令名）：正数为固定个数，负数为最少个数
+#   参数类型按位置对应命令名之后的参数：
+#     key、string  不检查
+#     integer      64 位整数，否则 ERR value is not an integer or out of range
+#     float        浮点数（允许 ±inf），否则 ERR value is not a valid float
+#     score        分数区间端点（可带 "(" 前缀，允许 ±inf），否则 ERR min or max is not a float
+#     A|B|C        其中之一（不区分大小写），否则 ERR syntax error
+#   用 [] 括起的类型表示可选参数，只能出现在末尾
+#   参数格式更复杂的命令（SET、ZADD、ZRANGE 等）只列出 arity，其余由命令自己解析；
+#   Redis 对某个参数使用专门错误信息的（如 LPOP 的 count、EXPIRE 的 NX/XX 选项）也由命令自己检查
+
+# 连接
+HELLO             -1
+ECHO               2   string
+DBSIZE             1
+FLUSHDB           -1   [ASYNC|SYNC]
+FLUSHALL          -1   [ASYNC|SYNC]
+SELECT             2   integer
+CLIENT            -2   string
+BOLTREON.WRITESTATS -1  [ON|OFF|RESET]
+BOLTREON.MIRROR   -1   [STATUS|PAUSE|RESUME]
+BOLTREON.SHADOW   -1
+BOLTREON.BACKUP   -1   [FULL|INCREMENTAL|LIST]
+BOLTREON.COMPACT   1
+DEBUG             -2   string
+ANALYZE           -1   [START|STATUS|REPORT|CANCEL]
+
+# 键
+DEL               -2   key
+UNLINK            -2   key
+EXISTS            -2   key
+TYPE               2   key
+TTL                2   key
+PTTL               2   key
+EXPIRE            -3   key integer
+PEXPIRE           -3   key integer
+EXPIREAT          -3   key integer
+PEXPIREAT         -3   key integer
+PERSIST            2   key
+RENAME             3   key key
+MOVE               3   key integer
+SWAPDB             3   string string
+RENAMENX           3   key key
+UNDELETE          -2   string [REPLACE]
+PURGE             -1   [string]
+BOLTREON.SCHEDULE -2   string
+NAMESPACE         -2   string
+SCAN              -2
+
+# 字符串
+GET                2   key
+SET               -3   key string
+SETNX              3   key string
+SETEX              4   key integer string
+PSETEX             4   key integer string
+GETSET             3   key string
+GETEX             -2   key
+GETDEL             2   key
+APPEND             3   key string
+STRLEN             2   key
+GETRANGE           4   key integer integer
+SETRANGE           4   key integer string
+INCR               2   key
+DECR               2   key
+INCRBY             3   key integer
+DECRBY             3   key integer
+INCRBYFLOAT        3   key float
+MGET              -2   key
+MSET              -3   key string
+MSETNX            -3   key string
+
+# 位图
+SETBIT             4   key string string
+GETBIT             3   key string
+BITCOUNT          -2   key
+BITPOS            -3   key
+BITOP             -4   string key
+BITFIELD          -2   key
+BITFIELD_RO       -2   key
+BITLEN             2   key
+
+# HyperLogLog
+PFADD             -2   key
+PFCOUNT           -2   key
+PFMERGE           -2   key
+PFINFO             2   key
+
+# 列表
+LPUSH             -3   key string
+RPUSH             -3   key string
+LPUSHX            -3   key string
+RPUSHX            -3   key string
+LPOP              -2   key
+RPOP              -2   key
+LLEN               2   key
+LRANGE            -4   key integer integer [FORCE]
+LINDEX             3   key integer
+LSET               4   key integer string
+LREM               4   key integer string
+LTRIM              4   key integer integer
+LINSERT            5   key BEFORE|AFTER string string
+
+# 哈希
+HSET              -4   key string string
+HSETNX             4   key string string
+HGET               3   key string
+HMGET             -3   key string
+HDEL              -3   key string
+HEXISTS            3   key string
+HLEN               2   key
+HKEYS             -2   key [FORCE]
+HVALS             -2   key [FORCE]
+HGETALL           -2   key [FORCE]
+HSCAN             -3   key
+HSTRLEN            3   key string
+HINCRBY            4   key string integer
+HINCRBYFLOAT       4   key string float
+HEXPIRE           -6   key integer
+HPEXPIRE          -6   key integer
+HEXPIREAT         -6   key integer
+HPEXPIREAT        -6   key integer
+HPERSIST          -5   key
+HTTL              -5   key
+HPTTL             -5   key
+HEXPIRETIME       -5   key
+HPEXPIRETIME      -5   key
+BOLTREON.SUMRANGE -3   HASH|KEYS string
+
+# 集合
+SADD              -3   key string
+SREM              -3   key string
+SISMEMBER          3   key string
+SMISMEMBER        -3   key string
+SCARD              2   key
+SMEMBERS          -2   key [FORCE]
+SSCAN             -3   key
+SMOVE              4   key key string
+SPOP              -2   key [integer]
+SRANDMEMBER       -2   key [integer]
+
+# 延迟队列
+QPUSH              4   key integer string
+QPOP               3   key integer
+QACK              -3   key string
+
+# 有序集合
+ZADD              -4   key
+ZREM              -3   key string
+ZCARD              2   key
+ZSCORE             3   key string
+ZINCRBY            4   key float string
+ZRANK             -3   key string
+ZREVRANK          -3   key string
+ZCOUNT             4   key score score
+ZRANGEBYSCORE     -4   key score score
+ZREVRANGEBYSCORE  -4   key score score
+ZREMRANGEBYRANK    4   key integer integer
+ZREMRANGEBYSCORE   4   key score score
+ZSCAN             -3   key
+BOLTREON.ZMERGE   -3   key key [MAX|MIN]
+
+# 脚本
+EVAL              -3   string integer
+EVALSHA           -3   string integer
+SCRIPT            -2   string
+
+# 持久化
+BGREWRITEAOF       1
+SHUTDOWN          -1   [NOSAVE|SAVE|NOW|FORCE|ABORT]
+
+# 发布订阅
+PUBLISH            3   string string
+SPUBLISH           3   key string
+SUBSCRIBE         -2   string
+PSUBSCRIBE        -2   string
+SSUBSCRIBE        -2   key
+UNSUBSCRIBE       -1
+PUNSUBSCRIBE      -1
+SUNSUBSCRIBE      -1
+PUBSUB            -2   string
@@ -0,0 +1,770 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/aof"
+	"github.com/lbp0200/BoltDB/internal/backup"
+	"github.com/lbp0200/BoltDB/internal/config"
+	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+const (
+	// DefaultMaxClients 默认最多同时连接的客户端数，与 Redis 相同
+	DefaultMaxClients = 10000
+	// defaultMaxmemoryPolicy 默认的 maxmemory-policy
+	defaultMaxmemoryPolicy = "noeviction"
+)
+
+// maxmemoryPolicies maxmemory-policy 可选的值，与 Redis 相同
+var maxmemoryPolicies = []string{
+	"noeviction", "allkeys-lru", "allkeys-lfu", "allkeys-random",
+	"volatile-lru", "volatile-lfu", "volatile-random", "volatile-ttl",
+}
+
+// logLevels loglevel 可选的值，Redis 的 verbose 与 notice 对应 info
+var logLevels = map[string]string{
+	"debug": "DEBUG", "verbose": "INFO", "info": "INFO", "notice": "INFO", "warning": "WARNING", "error": "ERROR",
+}
+
+// runtimeConfigParam CONFIG GET/SET 的参数
+type runtimeConfigParam struct {
+	name string
+	// def 默认值，CONFIG REWRITE 不追加值为默认值的参数
+	def string
+	get func(h *Handler) string
+	// set 校验并应用新值，返回的错误说明原因；nil 表示只能在启动时设置
+	set func(h *Handler, value string) error
+}
+
+// configState CONFIG 参数中不属于其他模块的值与配置文件（只保存在服务器级）
+type configState struct {
+	maxmemory  atomic.Int64
+	maxclients atomic.Int64 // 0 表示 DefaultMaxClients
+	// replicaWritable replica-read-only 为 no：从节点接受客户端的写命令
+	replicaWritable atomic.Bool
+
+	mu              sync.Mutex
+	maxmemoryPolicy string
+	file            *config.File
+	// startup 启动参数（只读），见 SetStartupConfig
+	startup []runtimeConfigParam
+}
+
+// runtimeConfigParams 按 CONFIG GET * 返回的顺序排列，之后是启动参数
+var runtimeConfigParams = []runtimeConfigParam{
+	{
+		name: "save",
+		def:  "",
+		get:  func(h *Handler) string { return "" },
+	},
+	{
+		// cron 表达式，按时做 Badger 增量备份（必要时为全量备份），为空时不定时备份
+		name: "backup-schedule",
+		def:  "",
+		get: func(h *Handler) string {
+			if h.Backup == nil {
+				return ""
+			}
+			return h.Backup.Schedule()
+		},
+		set: func(h *Handler, value string) error {
+			if h.Backup == nil {
+				return errors.New("backup not enabled")
+			}
+			return h.Backup.SetSchedule(value)
+		},
+	},
+	{
+		// 保留最近的多少个全量备份（及其后的增量备份），0 表示不限
+		name: "backup-retention",
+		def:  "0",
+		get: func(h *Handler) string {
+			if h.Backup == nil {
+				return "0"
+			}
+			return strconv.Itoa(h.Backup.Retention().Keep)
+		},
+		set: func(h *Handler, value string) error {
+			n, err := parseConfigInt(value, 0, math.MaxInt32)
+			if err != nil {
+				return err
+			}
+			if h.Backup == nil {
+				return errors.New("backup not enabled")
+			}
+			r := h.Backup.Retention()
+			r.Keep = int(n)
+			h.Backup.SetRetention(r)
+			return nil
+		},
+	},
+	{
+		// 删除最后一次备份早于这么多秒之前的全量备份组，0 表示不限
+		name: "backup-retention-seconds",
+		def:  "0",
+		get: func(h *Handler) string {
+			if h.Backup == nil {
+				return "0"
+			}
+			return strconv.FormatInt(int64(h.Backup.Retention().MaxAge/time.Second), 10)
+		},
+		set: func(h *Handler, value string) error {
+			seconds, err := parseConfigInt(value, 0, math.MaxInt32)
+			if err != nil {
+				return err
+			}
+			if h.Backup == nil {
+				return errors.New("backup not enabled")
+			}
+			r := h.Backup.Retention()
+			r.MaxAge = time.Duration(seconds) * time.Second
+			h.Backup.SetRetention(r)
+			return nil
+		},
+	},
+	{
+		// 连续增量备份的上限，达到后下一次做全量备份，0 表示只在必要时做全量备份
+		name: "backup-full-every",
+		def:  strconv.Itoa(backup.DefaultFullBackupEvery),
+		get: func(h *Handler) string {
+			if h.Backup == nil {
+				return strconv.Itoa(backup.DefaultFullBackupEvery)
+			}
+			return strconv.Itoa(h.Backup.FullBackupEvery())
+		},
+		set: func(h *Handler, value string) error {
+			n, err := parseConfigInt(value, 0, math.MaxInt32)
+			if err != nil {
+				return err
+			}
+			if h.Backup == nil {
+				return errors.New("backup not enabled")
+			}
+			h.Backup.SetFullBackupEvery(int(n))
+			return nil
+		},
+	},
+	{
+		name: "appendonly",
+		def:  "no",
+		get: func(h *Handler) string {
+			if h.root().aof.enabled.Load() {
+				return "yes"
+			}
+			return "no"
+		},
+		set: func(h *Handler, value string) error {
+			on, err := parseConfigBool(value)
+			if err != nil {
+				return err
+			}
+			return h.setAppendOnly(on)
+		},
+	},
+	{
+		name: "appendfsync",
+		def:  aof.FsyncEverysec,
+		get: func(h *Handler) string {
+			a := &h.root().aof
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			if a.fsync == "" {
+				return aof.FsyncEverysec
+			}
+			return a.fsync
+		},
+		set: func(h *Handler, value string) error {
+			value = strings.ToLower(value)
+			if !aof.ValidFsync(value) {
+				return errors.New("argument must be one of the following: always, everysec, no")
+			}
+			a := &h.root().aof
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			a.fsync = value
+			if a.log != nil {
+				return a.log.SetFsync(value)
+			}
+			return nil
+		},
+	},
+	{
+		name: "maxmemory",
+		def:  "0",
+		get: func(h *Handler) string {
+			return strconv.FormatInt(h.root().conf.maxmemory.Load(), 10)
+		},
+		set: func(h *Handler, value string) error {
+			n, err := config.ParseMemory(value)
+			if err != nil {
+				return err
+			}
+			h.root().conf.maxmemory.Store(n)
+			return nil
+		},
+	},
+	{
+		name: "maxmemory-policy",
+		def:  defaultMaxmemoryPolicy,
+		get:  func(h *Handler) string { return h.maxmemoryPolicy() },
+		set: func(h *Handler, value string) error {
+			value = strings.ToLower(value)
+			for _, p := range maxmemoryPolicies {
+				if p == value {
+					c := &h.root().conf
+					c.mu.Lock()
+					c.maxmemoryPolicy = value
+					c.mu.Unlock()
+					return nil
+				}
+			}
+			return fmt.Errorf("argument must be one of the following: %s", strings.Join(maxmemoryPolicies, ", "))
+		},
+	},
+	{
+		name: "maxclients",
+		def:  strconv.Itoa(DefaultMaxClients),
+		get: func(h *Handler) string {
+			return strconv.FormatInt(h.maxClients(), 10)
+		},
+		set: func(h *Handler, value string) error {
+			n, err := parseConfigInt(value, 1, math.MaxInt32)
+			if err != nil {
+				return err
+			}
+			h.root().conf.maxclients.Store(n)
+			return nil
+		},
+	},
+	{
+		name: "loglevel",
+		def:  "warning",
+		get: func(h *Handler) string {
+			switch level := logger.GetLevelString(); level {
+			case "warn":
+				return "warning"
+			default:
+				return level
+			}
+		},
+		set: func(h *Handler, value string) error {
+			level, ok := logLevels[strings.ToLower(value)]
+			if !ok {
+				return errors.New("argument must be one of the following: debug, verbose, info, notice, warning, error")
+			}
+			logger.SetLevelFromString(level)
+			return nil
+		},
+	},
+	{
+		name: "active-expire-interval",
+		def:  strconv.FormatInt(DefaultExpireInterval.Milliseconds(), 10),
+		get: func(h *Handler) string {
+			return strconv.FormatInt(h.expireInterval().Milliseconds(), 10)
+		},
+		set: func(h *Handler, value string) error {
+			ms, err := parseConfigInt(value, 1, int64(time.Minute/time.Millisecond))
+			if err != nil {
+				return err
+			}
+			h.root().expireSweep.intervalMs.Store(ms)
+			return nil
+		},
+	},
+	{
+		name: "active-expire-keys",
+		def:  strconv.Itoa(defaultExpireKeys),
+		get: func(h *Handler) string {
+			return strconv.Itoa(h.expireKeys())
+		},
+		set: func(h *Handler, value string) error {
+			n, err := parseConfigInt(value, 1, 1000000)
+			if err != nil {
+				return err
+			}
+			h.root().expireSweep.keys.Store(n)
+			return nil
+		},
+	},
+	{
+		name: "notify-keyspace-events",
+		def:  "",
+		get: func(h *Handler) string {
+			return formatKeyspaceEvents(int(h.root().notifier.flags.Load()))
+		},
+		set: func(h *Handler, value string) error {
+			flags, ok := parseKeyspaceEvents(value)
+			if !ok {
+				return errors.New("invalid event class character, use 'Ag$lshzxeKEtmn'")
+			}
+			h.root().notifier.flags.Store(int64(flags))
+			return nil
+		},
+	},
+	{
+		name: "slowlog-log-slower-than",
+		def:  strconv.Itoa(defaultSlowlogSlowerThan),
+		get: func(h *Handler) string {
+			slowerThan, _ := h.root().slowlog.settings()
+			return strconv.FormatInt(slowerThan, 10)
+		},
+		set: func(h *Handler, value string) error {
+			us, err := parseConfigInt(value, -1, math.MaxInt64)
+			if err != nil {
+				return err
+			}
+			h.root().slowlog.configure(&us, nil)
+			return nil
+		},
+	},
+	{
+		name: "slowlog-max-len",
+		def:  strconv.Itoa(defaultSlowlogMaxLen),
+		get: func(h *Handler) string {
+			_, maxLen := h.root().slowlog.settings()
+			return strconv.FormatInt(maxLen, 10)
+		},
+		set: func(h *Handler, value string) error {
+			n, err := parseConfigInt(value, 0, math.MaxInt32)
+			if err != nil {
+				return err
+			}
+			h.root().slowlog.configure(nil, &n)
+			return nil
+		},
+	},
+	{
+		// 命令限速，如 "keys 10 flushall 0.1"：整个服务器每秒最多执行的次数，超过时回复 -LIMIT
+		name: "rate-limit-commands",
+		def:  "",
+		get: func(h *Handler) string {
+			return h.root().rateLimit.commandsConfig()
+		},
+		set: func(h *Handler, value string) error {
+			return h.root().rateLimit.setCommands(value)
+		},
+	},
+	{
+		// 每个连接每秒最多执行的命令数，0 表示不限制
+		name: "rate-limit-client",
+		def:  "0",
+		get: func(h *Handler) string {
+			return strconv.FormatInt(h.root().rateLimit.clientRate.Load(), 10)
+		},
+		set: func(h *Handler, value string) error {
+			n, err := parseConfigInt(value, 0, math.MaxInt32)
+			if err != nil {
+				return err
+			}
+			l := &h.root().rateLimit
+			l.clientRate.Store(n)
+			l.updateEnabled()
+			return nil
+		},
+	},
+	{
+		// 每个键每秒最多被访问的次数（所有连接合计），0 表示不限制
+		name: "rate-limit-key",
+		def:  "0",
+		get: func(h *Handler) string {
+			return strconv.FormatInt(h.root().rateLimit.keyRate.Load(), 10)
+		},
+		set: func(h *Handler, value string) error {
+			n, err := parseConfigInt(value, 0, math.MaxInt32)
+			if err != nil {
+				return err
+			}
+			l := &h.root().rateLimit
+			l.keyRate.Store(n)
+			l.updateEnabled()
+			return nil
+		},
+	},
+	{
+		// 秒，定时回收 Badger value log 的间隔，0 表示不定时回收
+		name: "badger-gc-interval",
+		def:  strconv.Itoa(int(DefaultValueLogGCInterval / time.Second)),
+		get: func(h *Handler) string {
+			return strconv.FormatInt(int64(h.valueLogGCInterval()/time.Second), 10)
+		},
+		set: func(h *Handler, value string) error {
+			seconds, err := parseConfigInt(value, 0, math.MaxInt32)
+			if err != nil {
+				return err
+			}
+			g := &h.root().valueLogGC
+			g.intervalSec.Store(seconds)
+			g.intervalSet.Store(true)
+			return nil
+		},
+	},
+	{
+		// value log 文件中可回收的数据达到这一比例时才重写，取值大于 0 小于 1
+		name: "badger-gc-discard-ratio",
+		def:  strconv.FormatFloat(store.DefaultValueLogGCDiscardRatio, 'g', -1, 64),
+		get: func(h *Handler) string {
+			return strconv.FormatFloat(h.valueLogGCDiscardRatio(), 'g', -1, 64)
+		},
+		set: func(h *Handler, value string) error {
+			ratio, err := strconv.ParseFloat(value, 64)
+			if err != nil || !(ratio > 0 && ratio < 1) {
+				return errors.New("argument must be greater than 0 and less than 1")
+			}
+			h.root().valueLogGC.discardRatio.Store(math.Float64bits(ratio))
+			return nil
+		},
+	},
+	{
+		// 毫秒，LATENCY 记录达到这一耗时的事件，0 表示不记录
+		name: "latency-monitor-threshold",
+		def:  "0",
+		get: func(h *Handler) string {
+			return strconv.FormatInt(h.root().latencyMonitor.thresholdMs.Load(), 10)
+		},
+		set: func(h *Handler, value string) error {
+			ms, err := parseConfigInt(value, 0, math.MaxInt64)
+			if err != nil {
+				return err
+			}
+			h.root().latencyMonitor.thresholdMs.Store(ms)
+			return nil
+		},
+	},
+	{
+		name: "cache-max-bytes",
+		def:  strconv.Itoa(store.DefaultReadCacheSize),
+		get: func(h *Handler) string {
+			if h.Db == nil {
+				return strconv.Itoa(store.DefaultReadCacheSize)
+			}
+			return strconv.FormatInt(h.Db.ReadCacheSize(), 10)
+		},
+		set: func(h *Handler, value string) error {
+			n, err := config.ParseMemory(value)
+			if err != nil {
+				return err
+			}
+			if h.Db != nil {
+				h.Db.SetReadCacheSize(n)
+			}
+			return nil
+		},
+	},
+	{
+		name: "cache-ttl",
+		def:  strconv.Itoa(int(store.DefaultReadCacheTTL / time.Second)),
+		get: func(h *Handler) string {
+			if h.Db == nil {
+				return strconv.Itoa(int(store.DefaultReadCacheTTL / time.Second))
+			}
+			return strconv.FormatInt(int64(h.Db.ReadCacheTTL()/time.Second), 10)
+		},
+		set: func(h *Handler, value string) error {
+			seconds, err := parseConfigInt(value, 0, math.MaxInt32)
+			if err != nil {
+				return err
+			}
+			if h.Db != nil {
+				h.Db.SetReadCacheTTL(time.Duration(seconds) * time.Second)
+			}
+			return nil
+		},
+	},
+	{
+		name: "tcp-keepalive",
+		def:  strconv.Itoa(defaultTCPKeepalive),
+		get: func(h *Handler) string {
+			return strconv.FormatInt(int64(h.tcpKeepalive()/time.Second), 10)
+		},
+		set: func(h *Handler, value string) error {
+			seconds, err := parseConfigInt(value, 0, math.MaxInt32)
+			if err != nil {
+				return err
+			}
+			if seconds == 0 {
+				seconds = -1
+			}
+			h.root().conns.keepalive.Store(seconds)
+			return nil
+		},
+	},
+	{
+		name: "tcp-nodelay",
+		def:  "yes",
+		get: func(h *Handler) string {
+			if h.root().conns.noDelayOff.Load() {
+				return "no"
+			}
+			return "yes"
+		},
+		set: func(h *Handler, value string) error {
+			on, err := parseConfigBool(value)
+			if err != nil {
+				return err
+			}
+			h.root().conns.noDelayOff.Store(!on)
+			return nil
+		},
+	},
+	{
+		name: "write-coalescing",
+		def:  "yes",
+		get: func(h *Handler) string {
+			if h.root().coalescer.disabled.Load() {
+				return "no"
+			}
+			return "yes"
+		},
+		set: func(h *Handler, value string) error {
+			on, err := parseConfigBool(value)
+			if err != nil {
+				return err
+			}
+			h.root().coalescer.disabled.Store(!on)
+			return nil
+		},
+	},
+	{
+		// 从节点拒绝客户端的写命令（READWRITE 的连接除外），见 readonly.go
+		name: "replica-read-only",
+		def:  "yes",
+		get:  replicaReadOnlyConfig,
+		set:  setReplicaReadOnlyConfig,
+	},
+	{
+		// replica-read-only 的旧名称
+		name: "slave-read-only",
+		def:  "yes",
+		get:  replicaReadOnlyConfig,
+		set:  setReplicaReadOnlyConfig,
+	},
+}
+
+func replicaReadOnlyConfig(h *Handler) string {
+	if h.root().conf.replicaWritable.Load() {
+		return "no"
+	}
+	return "yes"
+}
+
+func setReplicaReadOnlyConfig(h *Handler, value string) error {
+	on, err := parseConfigBool(value)
+	if err != nil {
+		return err
+	}
+	h.root().conf.replicaWritable.Store(!on)
+	return nil
+}
+
+// configParams 返回全部参数：运行时参数在前，启动参数在后
+func (h *Handler) configParams() []runtimeConfigParam {
+	c := &h.root().conf
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append(append([]runtimeConfigParam(nil), runtimeConfigParams...), c.startup...)
+}
+
+// findRuntimeConfig 按名称（不区分大小写）查找参数
+func (h *Handler) findRuntimeConfig(name string) *runtimeConfigParam {
+	params := h.configParams()
+	for i := range params {
+		if strings.EqualFold(params[i].name, name) {
+			return &params[i]
+		}
+	}
+	return nil
+}
+
+// SetStartupConfig 登记只能在启动时设置的参数（命令行参数等），CONFIG GET 可以读取，CONFIG SET 返回错误。
+// 与运行时参数同名时忽略
+func (h *Handler) SetStartupConfig(name, value string) {
+	if h.findRuntimeConfig(name) != nil {
+		return
+	}
+	c := &h.root().conf
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.startup = append(c.startup, runtimeConfigParam{
+		name: name,
+		def:  value,
+		get:  func(*Handler) string { return value },
+	})
+}
+
+// LoadConfigFile 应用配置文件中的运行时参数，并把文件作为 CONFIG REWRITE 的目标。
+// handled 返回 true 的参数已由调用方处理（如命令行参数），其余未知参数返回错误
+func (h *Handler) LoadConfigFile(f *config.File, handled func(name string) bool) error {
+	for _, d := range f.Directives() {
+		if handled != nil && handled(d.Name) {
+			continue
+		}
+		p := h.findRuntimeConfig(d.Name)
+		if p == nil {
+			return fmt.Errorf("%s:%d: unknown directive '%s'", f.Path(), d.Line, d.Name)
+		}
+		if p.set == nil {
+			continue
+		}
+		if err := p.set(h, d.Value()); err != nil {
+			return fmt.Errorf("%s:%d: '%s': %v", f.Path(), d.Line, d.Name, err)
+		}
+	}
+	c := &h.root().conf
+	c.mu.Lock()
+	c.file = f
+	c.mu.Unlock()
+	return nil
+}
+
+// maxmemoryPolicy 当前的 maxmemory-policy
+func (h *Handler) maxmemoryPolicy() string {
+	c := &h.root().conf
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.maxmemoryPolicy == "" {
+		return defaultMaxmemoryPolicy
+	}
+	return c.maxmemoryPolicy
+}
+
+// maxClients 当前的 maxclients
+func (h *Handler) maxClients() int64 {
+	if n := h.root().conf.maxclients.Load(); n > 0 {
+		return n
+	}
+	return DefaultMaxClients
+}
+
+// handleConfig CONFIG GET/SET/REWRITE/RESETSTAT
+func (h *Handler) handleConfig(args [][]byte) proto.RESP {
+	switch strings.ToUpper(string(args[0])) {
+	case "GET":
+		return h.configGet(args[1:])
+	case "SET":
+		return h.configSet(args[1:])
+	case "REWRITE":
+		return h.configRewrite()
+	case "RESETSTAT":
+		// 清空 INFO stats 与 commandstats 中的累计统计
+		h.root().stats.Reset(time.Now())
+		return proto.OK
+	default:
+		return proto.NewError(fmt.Sprintf("ERR unknown subcommand '%s'", string(args[0])))
+	}
+}
+
+// configGet CONFIG GET pattern [pattern ...]：返回名称匹配任一 glob 模式的参数与值
+func (h *Handler) configGet(patterns [][]byte) proto.RESP {
+	if len(patterns) == 0 {
+		patterns = [][]byte{[]byte("*")}
+	}
+	var results [][]byte
+	for _, p := range h.configParams() {
+		for _, pattern := range patterns {
+			if config.Match(string(pattern), p.name) {
+				results = append(results, []byte(p.name), []byte(p.get(h)))
+				break
+			}
+		}
+	}
+	if results == nil {
+		results = [][]byte{}
+	}
+	return &proto.Array{Args: results}
+}
+
+// configSet CONFIG SET parameter value [parameter value ...]：任一参数失败时已修改的参数恢复原值
+func (h *Handler) configSet(args [][]byte) proto.RESP {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return proto.NewError("ERR wrong number of arguments for 'config|set' command")
+	}
+	type change struct {
+		param *runtimeConfigParam
+		value string
+	}
+	changes := make([]change, 0, len(args)/2)
+	seen := make(map[string]bool, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		name := string(args[i])
+		p := h.findRuntimeConfig(name)
+		if p == nil {
+			return proto.NewError(fmt.Sprintf("ERR Unknown option or number of arguments for CONFIG SET - '%s'", name))
+		}
+		if p.set == nil {
+			return proto.NewError(fmt.Sprintf("ERR CONFIG SET failed (possibly related to argument '%s') - can't set immutable config", p.name))
+		}
+		if seen[p.name] {
+			return proto.NewError(fmt.Sprintf("ERR CONFIG SET failed (possibly related to argument '%s') - duplicate parameter", p.name))
+		}
+		seen[p.name] = true
+		changes = append(changes, change{param: p, value: string(args[i+1])})
+	}
+	for i, c := range changes {
+		old := c.param.get(h)
+		if err := c.param.set(h, c.value); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				_ = changes[j].param.set(h, changes[j].value)
+			}
+			return proto.NewError(fmt.Sprintf("ERR CONFIG SET failed (possibly related to argument '%s') - %v", c.param.name, err))
+		}
+		changes[i].value = old
+	}
+	return proto.OK
+}
+
+// configRewrite CONFIG REWRITE：把运行时参数的当前值写回启动时加载的配置文件
+func (h *Handler) configRewrite() proto.RESP {
+	c := &h.root().conf
+	c.mu.Lock()
+	f := c.file
+	c.mu.Unlock()
+	if f == nil {
+		return proto.NewError("ERR The server is running without a config file")
+	}
+	var settings []config.Setting
+	for _, p := range runtimeConfigParams {
+		if p.set == nil {
+			continue
+		}
+		value := p.get(h)
+		settings = append(settings, config.Setting{Name: p.name, Value: value, Default: value == p.def})
+	}
+	if err := f.Rewrite(settings); err != nil {
+		logger.Logger.Error().Err(err).Str("path", f.Path()).Msg("CONFIG REWRITE 失败")
+		return proto.NewError(fmt.Sprintf("ERR Rewriting config file: %v", err))
+	}
+	return proto.OK
+}
+
+// parseConfigBool 解析 yes/no 参数
+func parseConfigBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes":
+		return true, nil
+	case "no":
+		return false, nil
+	}
+	return false, errors.New("argument must be 'yes' or 'no'")
+}
+
+// parseConfigInt 解析 [min, max] 范围内的整数参数
+func parseConfigInt(value string, lo, hi int64) (int64, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, errors.New("argument couldn't be parsed into an integer")
+	}
+	if n < lo || n > hi {
+		return 0, fmt.Errorf("argument must be between %d and %d inclusive", lo, hi)
+	}
+	return n, nil
+}
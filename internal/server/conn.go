@@ -0,0 +1,200 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+)
+
+const (
+	// defaultTCPKeepalive 默认的 TCP keepalive 间隔（秒），与 Redis 的 tcp-keepalive 相同
+	defaultTCPKeepalive = 300
+	// connBufferSize 每个连接读写缓冲区的大小，流水线中的多个命令可以一次读入、一次写出
+	connBufferSize = 16 << 10
+	// connWorkerIdle 处理连接的 goroutine 空闲超过这个时间后退出
+	connWorkerIdle = 30 * time.Second
+)
+
+// ErrServerClosed Shutdown 之后 ServeTCP 返回的错误
+var ErrServerClosed = errors.New("server closed")
+
+// connState 监听器、连接 goroutine 池与 TCP 选项（只保存在服务器级）
+type connState struct {
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	draining  atomic.Bool    // Shutdown 之后不再接受连接，连接处理完已读入的命令后关闭
+	active    sync.WaitGroup // 正在处理的连接
+	workers   connWorkers
+
+	keepalive  atomic.Int64 // TCP keepalive 间隔（秒），0 表示 defaultTCPKeepalive，-1 表示关闭
+	noDelayOff atomic.Bool  // CONFIG SET tcp-nodelay no
+}
+
+// connWorkers 处理连接的 goroutine 池：连接关闭后 goroutine 等待下一个连接，复用已经增长的栈，
+// 空闲超过 connWorkerIdle 后退出。同时存在的 goroutine 数不超过连接数，连接数由 maxclients 限制
+type connWorkers struct {
+	tasks chan func() // 无缓冲，只有空闲的 goroutine 在接收时才能交给它
+	once  sync.Once
+	idle  atomic.Int64
+	total atomic.Int64
+}
+
+// run 交给空闲的 goroutine 执行 task，没有空闲的 goroutine 时新建
+func (p *connWorkers) run(task func()) {
+	p.once.Do(func() { p.tasks = make(chan func()) })
+	select {
+	case p.tasks <- task:
+	default:
+		p.total.Add(1)
+		go p.work(task)
+	}
+}
+
+func (p *connWorkers) work(task func()) {
+	defer p.total.Add(-1)
+	timer := time.NewTimer(connWorkerIdle)
+	defer timer.Stop()
+	for {
+		task()
+		timer.Reset(connWorkerIdle)
+		p.idle.Add(1)
+		select {
+		case task = <-p.tasks:
+			p.idle.Add(-1)
+		case <-timer.C:
+			p.idle.Add(-1)
+			return
+		}
+	}
+}
+
+// 连接关闭后归还读写缓冲区，新连接不需要重新分配
+var (
+	connReaders = sync.Pool{New: func() any { return bufio.NewReaderSize(nil, connBufferSize) }}
+	connWriters = sync.Pool{New: func() any { return bufio.NewWriterSize(nil, connBufferSize) }}
+)
+
+func acquireConnBuffers(conn net.Conn) (*bufio.Reader, *bufio.Writer) {
+	reader := connReaders.Get().(*bufio.Reader)
+	reader.Reset(conn)
+	writer := connWriters.Get().(*bufio.Writer)
+	writer.Reset(conn)
+	return reader, writer
+}
+
+// releaseConnBuffers 归还缓冲区，之后不能再使用 reader 和 writer
+func releaseConnBuffers(reader *bufio.Reader, writer *bufio.Writer) {
+	reader.Reset(nil)
+	writer.Reset(nil)
+	connReaders.Put(reader)
+	connWriters.Put(writer)
+}
+
+// trackListener 登记监听器供 Shutdown 关闭；已经在关闭时返回 ErrServerClosed
+func (s *connState) trackListener(l net.Listener) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining.Load() {
+		return ErrServerClosed
+	}
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]struct{})
+	}
+	s.listeners[l] = struct{}{}
+	return nil
+}
+
+func (s *connState) untrackListener(l net.Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.listeners, l)
+}
+
+// begin 开始处理一个新连接；已经在关闭时返回 false
+func (s *connState) begin() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining.Load() {
+		return false
+	}
+	s.active.Add(1)
+	return true
+}
+
+// tcpKeepalive 当前的 TCP keepalive 间隔，0 表示关闭
+func (h *Handler) tcpKeepalive() time.Duration {
+	switch n := h.root().conns.keepalive.Load(); n {
+	case 0:
+		return defaultTCPKeepalive * time.Second
+	case -1:
+		return 0
+	default:
+		return time.Duration(n) * time.Second
+	}
+}
+
+// configureConn 按 tcp-nodelay 与 tcp-keepalive 设置新连接；TLS 连接设置在底层的 TCP 连接上
+func (h *Handler) configureConn(conn net.Conn) {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+	// TCP_NODELAY 减少延迟，回复已经按批写出
+	if err := tcpConn.SetNoDelay(!h.root().conns.noDelayOff.Load()); err != nil {
+		logger.Logger.Debug().Err(err).Msg("failed to set TCP_NODELAY")
+	}
+	keepalive := h.tcpKeepalive()
+	if err := tcpConn.SetKeepAlive(keepalive > 0); err != nil {
+		logger.Logger.Debug().Err(err).Msg("failed to set SO_KEEPALIVE")
+		return
+	}
+	if keepalive > 0 {
+		if err := tcpConn.SetKeepAlivePeriod(keepalive); err != nil {
+			logger.Logger.Debug().Err(err).Msg("failed to set TCP keepalive period")
+		}
+	}
+}
+
+// Shutdown 停止接受连接并等待已有连接处理完已经读入的命令：空闲的连接立即关闭，
+// 正在执行命令的连接写出回复后关闭。ctx 结束时仍未关闭的连接（如阻塞在 BLPOP 中）被强制关闭，
+// 不再等待，返回 ctx 的错误。复制连接不受影响。之后 ServeTCP 返回 ErrServerClosed
+func (h *Handler) Shutdown(ctx context.Context) error {
+	s := &h.root().conns
+	s.mu.Lock()
+	s.draining.Store(true)
+	for l := range s.listeners {
+		if err := l.Close(); err != nil {
+			logger.Logger.Debug().Err(err).Msg("failed to close listener")
+		}
+	}
+	s.mu.Unlock()
+
+	// 阻塞在读取上的连接立即返回
+	for _, c := range h.root().clients.list() {
+		_ = c.conn.SetReadDeadline(time.Now())
+	}
+	done := make(chan struct{})
+	go func() {
+		s.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, c := range h.root().clients.list() {
+			_ = c.conn.Close()
+		}
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// DefaultDatabases 默认的逻辑数据库个数，与 Redis 相同
+const DefaultDatabases = 16
+
+// dbUnprefixedCommands 参数中的键不在 processRequest 中加数据库前缀的命令：
+// 脚本的键在脚本执行每条命令时再加前缀；分片频道不属于任何数据库
+var dbUnprefixedCommands = map[string]bool{
+	"EVAL": true, "EVALSHA": true, "SPUBLISH": true, "SSUBSCRIBE": true, "SUNSUBSCRIBE": true,
+}
+
+// dbKeyReplyCommands 回复中包含键名的命令，回复给客户端之前去掉数据库前缀
+var dbKeyReplyCommands = map[string]bool{
+	"BLPOP": true, "BRPOP": true, "BZPOPMIN": true, "BZPOPMAX": true, "ZMPOP": true, "BZMPOP": true,
+	"XREAD": true, "XREADGROUP": true,
+}
+
+// SetDatabases 设置逻辑数据库的个数（SELECT 可用的编号为 0 到 n-1），n <= 0 使用默认值
+func (h *Handler) SetDatabases(n int) {
+	h.root().databases = n
+}
+
+func (h *Handler) databaseCount() int {
+	if n := h.root().databases; n > 0 {
+		return n
+	}
+	return DefaultDatabases
+}
+
+// parseDBIndex 解析数据库编号，不是整数时返回 notInteger，超出 databases 时返回 ERR DB index is out of range
+func (h *Handler) parseDBIndex(arg []byte, notInteger string) (int, proto.RESP) {
+	db, err := strconv.Atoi(string(arg))
+	if err != nil {
+		return 0, proto.NewError(notInteger)
+	}
+	if db < 0 || db >= h.databaseCount() {
+		return 0, proto.NewError("ERR DB index is out of range")
+	}
+	return db, nil
+}
+
+// selectDBKeys 把命令参数中的键替换为当前数据库中的键在存储中的键名（原地修改 args）。
+// 在入队、执行、AOF 与复制之前替换，之后的各环节都使用存储中的键名，不需要知道连接选择的数据库
+func (h *Handler) selectDBKeys(cmd string, args [][]byte) {
+	if h.db == 0 || dbUnprefixedCommands[cmd] {
+		return
+	}
+	for _, i := range commandKeyPositions(cmd, args) {
+		args[i] = []byte(h.Db.StoreKey(h.db, string(args[i])))
+	}
+}
+
+// canonicalDBKeys 把命令（含命令名）中的键换成第 0 代的键名，用于写入 AOF、复制与镜像：
+// FLUSHDB ASYNC 产生的代只在本实例的存储中有意义，接收方按自己当前的代换算（见 resolveDBKeys）。
+// 没有需要替换的键时返回原切片，否则返回副本
+func (h *Handler) canonicalDBKeys(cmdArgs [][]byte) [][]byte {
+	if len(cmdArgs) == 0 {
+		return cmdArgs
+	}
+	out, copied := cmdArgs, false
+	for _, i := range commandKeyPositions(strings.ToUpper(string(cmdArgs[0])), cmdArgs[1:]) {
+		key, _ := h.Db.CanonicalDBKey(string(cmdArgs[1+i]))
+		if key == string(cmdArgs[1+i]) {
+			continue
+		}
+		if !copied {
+			out, copied = append([][]byte(nil), cmdArgs...), true
+		}
+		out[1+i] = []byte(key)
+	}
+	return out
+}
+
+// resolveDBKeys canonicalDBKeys 的逆运算：重放 AOF 时把参数中第 0 代的键名换成存储中的键名（原地修改 args）
+func (h *Handler) resolveDBKeys(cmd string, args [][]byte) {
+	for _, i := range commandKeyPositions(cmd, args) {
+		args[i] = []byte(h.Db.ResolveDBKey(string(args[i])))
+	}
+}
+
+// dbReply 去掉回复中键名的数据库前缀。回复中的键一定是命令参数中的某个键，只替换与之完全相同的元素，
+// 返回新的回复，原回复不变（AOF 根据原回复改写阻塞命令）
+func (h *Handler) dbReply(cmd string, args [][]byte, resp proto.RESP) proto.RESP {
+	if h.db == 0 || !dbKeyReplyCommands[cmd] {
+		return resp
+	}
+	keys := make(map[string]string)
+	for _, key := range commandKeys(cmd, args) {
+		_, keys[key] = store.SplitDBKey(key)
+	}
+	return replaceReplyKeys(resp, keys)
+}
+
+// replaceReplyKeys 把回复（含嵌套数组）中等于 keys 某个键的元素替换为对应的值
+func replaceReplyKeys(resp proto.RESP, keys map[string]string) proto.RESP {
+	switch r := resp.(type) {
+	case *proto.Array:
+		out := make([][]byte, len(r.Args))
+		for i, arg := range r.Args {
+			if key, ok := keys[string(arg)]; ok {
+				arg = []byte(key)
+			}
+			out[i] = arg
+		}
+		return &proto.Array{Args: out}
+	case *proto.NestedArray:
+		out := make([]proto.RESP, len(r.Elems))
+		for i, elem := range r.Elems {
+			out[i] = replaceReplyKeys(elem, keys)
+		}
+		return &proto.NestedArray{Elems: out}
+	case *proto.BulkString:
+		if *r != nil {
+			if key, ok := keys[string(*r)]; ok {
+				return proto.NewBulkString([]byte(key))
+			}
+		}
+	}
+	return resp
+}
+
+// handleSelect SELECT index：切换连接使用的数据库。集群模式只有 0 号数据库
+func (h *Handler) handleSelect(args [][]byte) proto.RESP {
+	db, errResp := h.parseDBIndex(args[0], errNotInteger)
+	if errResp != nil {
+		return errResp
+	}
+	if h.Cluster != nil && db != 0 {
+		return proto.NewError("ERR SELECT is not allowed in cluster mode")
+	}
+	h.db = db
+	if c := h.clientInfo; c != nil {
+		c.mu.Lock()
+		c.DB = db
+		c.mu.Unlock()
+	}
+	return proto.OK
+}
+
+// handleMove MOVE key db：把键移到另一个数据库，目标数据库中已有同名键时不移动。
+// key 已由 selectDBKeys 替换为存储中的键名，源数据库从键名中取得
+func (h *Handler) handleMove(args [][]byte) proto.RESP {
+	if h.Cluster != nil {
+		return proto.NewError("ERR MOVE is not allowed in cluster mode")
+	}
+	db, errResp := h.parseDBIndex(args[1], errNotInteger)
+	if errResp != nil {
+		return errResp
+	}
+	key := string(args[0])
+	if src, _ := h.Db.SplitStoreKey(key); src == db {
+		return proto.NewError("ERR source and destination objects are the same")
+	}
+	moved, err := h.Db.MoveKey(key, db)
+	if err != nil {
+		return proto.NewError(fmt.Sprintf("ERR %v", err))
+	}
+	if moved {
+		return proto.NewInteger(1)
+	}
+	return proto.NewInteger(0)
+}
+
+// handleSwapDB SWAPDB index1 index2：交换两个数据库的全部键，之后选择其中一个数据库的连接看到的是另一个数据库的数据
+func (h *Handler) handleSwapDB(args [][]byte) proto.RESP {
+	if h.Cluster != nil {
+		return proto.NewError("ERR SWAPDB is not allowed in cluster mode")
+	}
+	a, errResp := h.parseDBIndex(args[0], "ERR invalid first DB index")
+	if errResp != nil {
+		return errResp
+	}
+	b, errResp := h.parseDBIndex(args[1], "ERR invalid second DB index")
+	if errResp != nil {
+		return errResp
+	}
+	if err := h.Db.SwapDB(a, b); err != nil {
+		return proto.NewError(fmt.Sprintf("ERR %v", err))
+	}
+	return proto.OK
+}
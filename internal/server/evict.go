@@ -0,0 +1,88 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// errOOM 数据大小超过 maxmemory 且无法淘汰时写命令的回复，与 Redis 相同
+const errOOM = "OOM command not allowed when used memory > 'maxmemory'."
+
+// oomAllowedCommands 超过 maxmemory 时仍允许执行的写命令：只删除数据或修改过期时间，
+// 对应 Redis 中没有 denyoom 标志的写命令
+var oomAllowedCommands = map[string]bool{
+	"DEL": true, "UNLINK": true, "FLUSHDB": true, "FLUSHALL": true, "GETDEL": true,
+	"EXPIRE": true, "PEXPIRE": true, "EXPIREAT": true, "PEXPIREAT": true, "PERSIST": true, "HPERSIST": true,
+	"LPOP": true, "RPOP": true, "LREM": true, "LTRIM": true, "SPOP": true, "SREM": true, "HDEL": true,
+	"ZREM": true, "ZREMRANGEBYSCORE": true, "ZREMRANGEBYRANK": true, "ZREMRANGEBYLEX": true,
+	"ZPOPMIN": true, "ZPOPMAX": true, "ZMPOP": true, "BZMPOP": true, "XDEL": true, "XTRIM": true,
+	"FT.DROPINDEX": true, "MOVE": true, "SWAPDB": true,
+}
+
+// noTouchCommands 不更新键的访问信息的命令，与 Redis 中以 LOOKUP_NOTOUCH 读取键的命令相同
+var noTouchCommands = map[string]bool{
+	"OBJECT": true, "TYPE": true, "TTL": true, "PTTL": true, "EXPIRETIME": true, "PEXPIRETIME": true,
+	"HTTL": true, "HPTTL": true, "HEXPIRETIME": true, "HPEXPIRETIME": true,
+	"EXISTS": true, "MEMORY": true,
+}
+
+// checkMaxmemory 写命令执行前检查 maxmemory：数据大小超过上限时按 maxmemory-policy 淘汰键，
+// 淘汰后仍超过（noeviction 或没有可淘汰的键）时拒绝会增加数据的写命令。
+// 从节点不淘汰，等待主节点的 DEL
+func (h *Handler) checkMaxmemory(cmd string) proto.RESP {
+	if h.Db == nil || !isWriteCommand(cmd) {
+		return nil
+	}
+	maxmemory := h.root().conf.maxmemory.Load()
+	h.Db.SetEvictionTracking(maxmemory > 0)
+	if maxmemory <= 0 || h.Db.DataSize() <= maxmemory {
+		return nil
+	}
+	if h.Replication != nil && !h.Replication.IsMaster() {
+		return nil
+	}
+	h.evictKeys(maxmemory)
+	if h.Db.DataSize() <= maxmemory || oomAllowedCommands[cmd] {
+		return nil
+	}
+	return proto.NewError(errOOM)
+}
+
+// evictKeys 按 maxmemory-policy 淘汰键。淘汰的键以 DEL 复制到从节点并写入 AOF，并发布 evicted 键空间通知
+func (h *Handler) evictKeys(maxmemory int64) {
+	policy := h.maxmemoryPolicy()
+	if policy == store.EvictNoEviction {
+		return
+	}
+	endAOF := h.beginAOF("DEL", nil)
+	defer endAOF()
+	evicted, err := h.Db.Evict(policy, maxmemory)
+	if err != nil {
+		logger.Logger.Error().Err(err).Str("policy", policy).Msg("maxmemory 淘汰失败")
+	}
+	for _, key := range evicted {
+		del := [][]byte{[]byte("DEL"), []byte(key)}
+		h.propagateWrite("DEL", del, nil)
+		h.appendAOF(del)
+		h.notifyKeyspaceEvent(notifyEvicted, "evicted", key)
+	}
+}
+
+// touchKeys 记录命令访问的键，供 LRU/LFU 淘汰与 OBJECT IDLETIME/FREQ 使用
+func (h *Handler) touchKeys(cmd string, args [][]byte) {
+	if h.Db == nil || noTouchCommands[cmd] {
+		return
+	}
+	if keys := commandKeys(cmd, args); len(keys) > 0 {
+		h.Db.TouchKeys(keys...)
+	}
+}
+
+// writeEvictStats 写入 INFO stats 中的淘汰统计
+func (h *Handler) writeEvictStats(b *strings.Builder) {
+	b.WriteString(fmt.Sprintf("evicted_keys:%d\n", h.Db.EvictedKeys()))
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+const (
+	// DefaultExpireInterval 主动过期的默认间隔
+	DefaultExpireInterval = 100 * time.Millisecond
+	// defaultExpireKeys 每轮默认检查的键数：按默认间隔每秒检查 2000 个键
+	defaultExpireKeys = 200
+	// expireRepeatPerc 一轮中过期的键达到检查键数的这一比例（百分比）时立即再执行一轮，
+	// 批量 SETEX 后大量键同时过期时尽快回收，不必等下一个间隔
+	expireRepeatPerc = 10
+	// expireTimeBudgetPerc 连续执行的各轮合计最多占用间隔的这一比例（百分比）
+	expireTimeBudgetPerc = 25
+)
+
+// expireSweeper 主动过期的参数，0 表示默认值
+type expireSweeper struct {
+	intervalMs atomic.Int64 // CONFIG active-expire-interval
+	keys       atomic.Int64 // CONFIG active-expire-keys
+	disabled   atomic.Bool  // DEBUG SET-ACTIVE-EXPIRE 0 暂停主动过期，只在访问时过期
+}
+
+// expireInterval 主动过期的间隔
+func (h *Handler) expireInterval() time.Duration {
+	if ms := h.root().expireSweep.intervalMs.Load(); ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return DefaultExpireInterval
+}
+
+// expireKeys 每轮主动过期检查的键数
+func (h *Handler) expireKeys() int {
+	if n := h.root().expireSweep.keys.Load(); n > 0 {
+		return int(n)
+	}
+	return defaultExpireKeys
+}
+
+// RunExpireSweeper 每隔 active-expire-interval 执行一次主动过期，直到 stop 关闭。
+// 间隔在每轮结束后重新读取，CONFIG SET 的修改在下一轮生效；每次的耗时计入 LATENCY 的 expire-cycle 事件
+func (h *Handler) RunExpireSweeper(stop <-chan struct{}) {
+	timer := time.NewTimer(h.expireInterval())
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+			start := time.Now()
+			h.runExpireCycle()
+			h.addLatencySample(latencyEventExpireCycle, time.Since(start))
+			timer.Reset(h.expireInterval())
+		}
+	}
+}
+
+// runExpireCycle 执行主动过期：过期的键较多时在时间预算内连续执行多轮。
+// 删除的键以 DEL 复制到从节点并写入 AOF，并发布 expired 键空间通知；之后以同样方式过期哈希字段，
+// 以 HDEL 复制并发布 hexpired 通知。
+// 从节点不主动过期，等待主节点的 DEL；DEBUG SET-ACTIVE-EXPIRE 0 时也不执行
+func (h *Handler) runExpireCycle() {
+	if h.Replication != nil && !h.Replication.IsMaster() || h.root().expireSweep.disabled.Load() {
+		return
+	}
+	limit := h.expireKeys()
+	deadline := time.Now().Add(h.expireInterval() * expireTimeBudgetPerc / 100)
+	for {
+		endAOF := h.beginAOF("DEL", nil)
+		expired, err := h.Db.ExpireCycle(limit)
+		if err != nil {
+			logger.Logger.Error().Err(err).Msg("主动过期失败")
+		}
+		for _, key := range expired {
+			del := [][]byte{[]byte("DEL"), []byte(key)}
+			h.propagateWrite("DEL", del, nil)
+			h.appendAOF(del)
+			h.notifyKeyspaceEvent(notifyExpired, "expired", key)
+		}
+		endAOF()
+		if err != nil || len(expired)*100 < limit*expireRepeatPerc || time.Now().After(deadline) {
+			break
+		}
+	}
+	for {
+		endAOF := h.beginAOF("HDEL", nil)
+		expired, err := h.Db.ExpireHashFieldCycle(limit)
+		if err != nil {
+			logger.Logger.Error().Err(err).Msg("主动过期哈希字段失败")
+		}
+		fields := 0
+		for _, e := range expired {
+			for _, cmd := range hashFieldExpiryCommands(e) {
+				h.propagateWrite(string(cmd[0]), cmd, nil)
+				h.appendAOF(cmd)
+			}
+			h.notifyKeyspaceEvent(notifyHash, "hexpired", e.Key)
+			fields += len(e.Fields)
+		}
+		endAOF()
+		if err != nil || fields*100 < limit*expireRepeatPerc || time.Now().After(deadline) {
+			return
+		}
+	}
+}
+
+// hashFieldExpiryCommands 过期的哈希字段复制到从节点与写入 AOF 的命令：HDEL，键随之删除时再加 DEL
+func hashFieldExpiryCommands(e store.HashFieldExpiry) [][][]byte {
+	hdel := [][]byte{[]byte("HDEL"), []byte(e.Key)}
+	for _, field := range e.Fields {
+		hdel = append(hdel, []byte(field))
+	}
+	cmds := [][][]byte{hdel}
+	if e.Deleted {
+		cmds = append(cmds, [][]byte{[]byte("DEL"), []byte(e.Key)})
+	}
+	return cmds
+}
+
+// expireAccessedKeys 惰性过期：命令访问的键已过期时先删除，命令按键不存在执行。
+// 与主动过期相同，删除以 DEL 复制到从节点并写入 AOF（EXEC 与脚本中随其他写命令一起写入），
+// 并发布 expired 键空间通知；从节点不删除，等待主节点的 DEL。哈希中已到期的字段同样先删除
+func (h *Handler) expireAccessedKeys(cmd string, args [][]byte) {
+	if h.Db == nil || h.Replication != nil && !h.Replication.IsMaster() {
+		return
+	}
+	keys := commandKeys(cmd, args)
+	expired, err := h.Db.ExpireAccessed(keys...)
+	if err != nil {
+		logger.Logger.Error().Err(err).Msg("惰性过期失败")
+	}
+	for _, key := range expired {
+		del := [][]byte{[]byte("DEL"), []byte(key)}
+		h.propagateWrite("DEL", del, nil)
+		h.feedAOF("DEL", del[1:], proto.NewInteger(1))
+		h.notifyKeyspaceEvent(notifyExpired, "expired", key)
+	}
+	fieldsExpired, err := h.Db.ExpireHashFields(keys...)
+	if err != nil {
+		logger.Logger.Error().Err(err).Msg("惰性过期哈希字段失败")
+	}
+	for _, e := range fieldsExpired {
+		for _, c := range hashFieldExpiryCommands(e) {
+			h.propagateWrite(string(c[0]), c, nil)
+			h.feedAOF(string(c[0]), c[1:], proto.NewInteger(1))
+		}
+		h.notifyKeyspaceEvent(notifyHash, "hexpired", e.Key)
+	}
+}
+
+// writeExpireStats 写入 INFO stats 中的过期统计：expired_keys、expired_subkeys（过期的哈希字段）、expired_stale_perc，
+// 以及最近一次过期索引的完整扫描中带过期时间的键数（expires_scanned_keys）与 TTL 分布（expires_ttl_<桶>）
+func (h *Handler) writeExpireStats(b *strings.Builder) {
+	stats := h.Db.ExpireStats()
+	b.WriteString(fmt.Sprintf("expired_keys:%d\n", stats.ExpiredKeys))
+	b.WriteString(fmt.Sprintf("expired_subkeys:%d\n", stats.ExpiredFields))
+	b.WriteString(fmt.Sprintf("expired_stale_perc:%.2f\n", stats.StalePerc))
+	b.WriteString(fmt.Sprintf("expires_scan_passes:%d\n", stats.Passes))
+	b.WriteString(fmt.Sprintf("expires_scanned_keys:%d\n", stats.Keys))
+	for i, bucket := range store.AnalyzeTTLBuckets {
+		var n int64
+		if i < len(stats.TTL) {
+			n = stats.TTL[i]
+		}
+		b.WriteString(fmt.Sprintf("expires_ttl_%s:%d\n", bucket.Name, n))
+	}
+	b.WriteString(fmt.Sprintf("expires_ttl_max_same_second:%d\n", stats.MaxSameSecond))
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// 故障规则的类型
+const (
+	faultLatency = "latency" // 执行前等待
+	faultError   = "error"   // 不执行，返回错误
+	faultDrop    = "drop"    // 执行但不回复，客户端只能等到超时
+)
+
+// faultRule 一条故障注入规则：命令名匹配 pattern 时按 percent 的概率生效
+type faultRule struct {
+	id      int64
+	pattern string // 大写的命令名 glob（* ? [...]）
+	kind    string
+	delay   time.Duration
+	message string
+	percent float64
+	hits    atomic.Int64
+}
+
+// faultInjector 故障注入规则（只保存在服务器级），供客户端测试超时与重试逻辑
+type faultInjector struct {
+	mu       sync.RWMutex
+	rules    []*faultRule
+	nextID   int64
+	disabled atomic.Bool // DEBUG FAULT OFF 暂停所有规则，但不删除
+}
+
+// faultAction 对一条命令生效的故障
+type faultAction struct {
+	delay   time.Duration
+	message string
+	drop    bool
+}
+
+// matchFaults 按添加顺序检查规则：匹配的 latency 规则的延迟累加，
+// 第一条生效的 error 或 drop 规则决定结果。DEBUG 命令不受影响，以便随时关闭故障注入
+func (h *Handler) matchFaults(cmd string) faultAction {
+	var action faultAction
+	f := &h.root().faults
+	if cmd == "DEBUG" || f.disabled.Load() {
+		return action
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, r := range f.rules {
+		if ok, _ := path.Match(r.pattern, cmd); !ok {
+			continue
+		}
+		// #nosec G404 - 只用于按比例抽样，不需要密码学随机数
+		if r.percent < 100 && rand.Float64()*100 >= r.percent {
+			continue
+		}
+		r.hits.Add(1)
+		switch r.kind {
+		case faultLatency:
+			action.delay += r.delay
+		case faultError:
+			action.message = r.message
+			return action
+		case faultDrop:
+			action.drop = true
+			return action
+		}
+	}
+	return action
+}
+
+// handleDebugFault 处理 DEBUG FAULT 子命令：
+//
+//	DEBUG FAULT ADD pattern LATENCY ms [PERCENT p]    执行前等待 ms 毫秒
+//	DEBUG FAULT ADD pattern ERROR message [PERCENT p]  不执行，返回错误 message
+//	DEBUG FAULT ADD pattern DROP [PERCENT p]           执行但不回复
+//	DEBUG FAULT LIST                                   [编号, 模式, 类型, 参数, 百分比, 命中次数]
+//	DEBUG FAULT DEL id | CLEAR                         删除一条或全部规则
+//	DEBUG FAULT ON | OFF                               恢复或暂停所有规则
+//
+// pattern 为命令名的 glob，不区分大小写；PERCENT 默认 100
+func (h *Handler) handleDebugFault(args [][]byte) proto.RESP {
+	if len(args) == 0 {
+		return wrongArgsError("DEBUG FAULT")
+	}
+	f := &h.root().faults
+	switch strings.ToUpper(string(args[0])) {
+	case "ADD":
+		rule, resp := parseFaultRule(args[1:])
+		if resp != nil {
+			return resp
+		}
+		f.mu.Lock()
+		f.nextID++
+		rule.id = f.nextID
+		f.rules = append(f.rules, rule)
+		f.mu.Unlock()
+		logger.Logger.Warn().
+			Int64("id", rule.id).
+			Str("pattern", rule.pattern).
+			Str("kind", rule.kind).
+			Float64("percent", rule.percent).
+			Msg("已添加故障注入规则")
+		return proto.NewInteger(rule.id)
+	case "LIST":
+		if len(args) != 1 {
+			return wrongArgsError("DEBUG FAULT LIST")
+		}
+		f.mu.RLock()
+		defer f.mu.RUnlock()
+		elems := make([]proto.RESP, len(f.rules))
+		for i, r := range f.rules {
+			param := r.message
+			if r.kind == faultLatency {
+				param = strconv.FormatInt(r.delay.Milliseconds(), 10)
+			}
+			elems[i] = &proto.NestedArray{Elems: []proto.RESP{
+				proto.NewInteger(r.id),
+				proto.NewBulkString([]byte(r.pattern)),
+				proto.NewBulkString([]byte(r.kind)),
+				proto.NewBulkString([]byte(param)),
+				proto.NewBulkString([]byte(strconv.FormatFloat(r.percent, 'f', -1, 64))),
+				proto.NewInteger(r.hits.Load()),
+			}}
+		}
+		return &proto.NestedArray{Elems: elems}
+	case "DEL":
+		if len(args) != 2 {
+			return wrongArgsError("DEBUG FAULT DEL")
+		}
+		id, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return proto.NewError("ERR value is not an integer or out of range")
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for i, r := range f.rules {
+			if r.id == id {
+				f.rules = append(f.rules[:i:i], f.rules[i+1:]...)
+				return proto.NewInteger(1)
+			}
+		}
+		return proto.NewInteger(0)
+	case "CLEAR":
+		if len(args) != 1 {
+			return wrongArgsError("DEBUG FAULT CLEAR")
+		}
+		f.mu.Lock()
+		f.rules = nil
+		f.mu.Unlock()
+		return proto.OK
+	case "ON", "OFF":
+		if len(args) != 1 {
+			return wrongArgsError("DEBUG FAULT " + string(args[0]))
+		}
+		f.disabled.Store(strings.EqualFold(string(args[0]), "OFF"))
+		return proto.OK
+	}
+	return proto.NewError(fmt.Sprintf("ERR unknown subcommand '%s'", string(args[0])))
+}
+
+// parseFaultRule 解析 ADD 之后的参数
+func parseFaultRule(args [][]byte) (*faultRule, proto.RESP) {
+	if len(args) < 2 {
+		return nil, wrongArgsError("DEBUG FAULT ADD")
+	}
+	rule := &faultRule{pattern: strings.ToUpper(string(args[0])), percent: 100}
+	if _, err := path.Match(rule.pattern, ""); err != nil {
+		return nil, proto.NewError(fmt.Sprintf("ERR invalid pattern '%s'", string(args[0])))
+	}
+	rest := args[2:]
+	switch strings.ToUpper(string(args[1])) {
+	case "LATENCY":
+		if len(rest) == 0 {
+			return nil, wrongArgsError("DEBUG FAULT ADD")
+		}
+		ms, err := strconv.ParseInt(string(rest[0]), 10, 64)
+		if err != nil || ms < 0 {
+			return nil, proto.NewError("ERR latency must be a non-negative integer (milliseconds)")
+		}
+		rule.kind = faultLatency
+		rule.delay = time.Duration(ms) * time.Millisecond
+		rest = rest[1:]
+	case "ERROR":
+		if len(rest) == 0 {
+			return nil, wrongArgsError("DEBUG FAULT ADD")
+		}
+		rule.kind = faultError
+		rule.message = faultErrorMessage(string(rest[0]))
+		rest = rest[1:]
+	case "DROP":
+		rule.kind = faultDrop
+	default:
+		return nil, proto.NewError("ERR syntax error")
+	}
+	switch {
+	case len(rest) == 0:
+	case len(rest) == 2 && strings.EqualFold(string(rest[0]), "PERCENT"):
+		p, err := strconv.ParseFloat(string(rest[1]), 64)
+		if err != nil || p <= 0 || p > 100 {
+			return nil, proto.NewError("ERR percent must be in (0, 100]")
+		}
+		rule.percent = p
+	default:
+		return nil, proto.NewError("ERR syntax error")
+	}
+	return rule, nil
+}
+
+// faultErrorMessage 错误消息的第一个词不是大写错误码（如 TRYAGAIN、READONLY）时加上 ERR 前缀
+func faultErrorMessage(msg string) string {
+	code, _, _ := strings.Cut(msg, " ")
+	if code == "" || strings.ToUpper(code) != code {
+		return "ERR " + msg
+	}
+	return msg
+}
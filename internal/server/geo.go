@@ -0,0 +1,183 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// geoSearchArgs GEOSEARCH 与 GEOSEARCHSTORE 在键之后的参数
+type geoSearchArgs struct {
+	opts                          store.GeoSearchOptions
+	withCoord, withDist, withHash bool
+	storeDist                     bool
+}
+
+// parseGeoSearchArgs 解析 GEOSEARCH(STORE) 的选项，与 Redis 相同选项顺序任意：
+// FROMMEMBER member | FROMLONLAT lon lat、BYRADIUS radius unit | BYBOX width height unit 各恰好一个，
+// 以及 ASC|DESC、COUNT count [ANY]；GEOSEARCH 另有 WITHCOORD、WITHDIST、WITHHASH，GEOSEARCHSTORE 另有 STOREDIST
+func parseGeoSearchArgs(cmd string, args [][]byte) (geoSearchArgs, proto.RESP) {
+	var a geoSearchArgs
+	isStore := cmd == "GEOSEARCHSTORE"
+	name := strings.ToLower(cmd)
+	fromMember, fromLonLat, byRadius, byBox, anyOpt := false, false, false, false, false
+	parseFloat := func(b []byte) (float64, proto.RESP) {
+		v, err := strconv.ParseFloat(string(b), 64)
+		if err != nil {
+			return 0, proto.NewError(errNotFloat)
+		}
+		return v, nil
+	}
+	for i := 0; i < len(args); i++ {
+		left := len(args) - i - 1
+		switch option := strings.ToUpper(string(args[i])); {
+		case option == "FROMMEMBER" && left >= 1:
+			a.opts.FromMember = string(args[i+1])
+			fromMember = true
+			i++
+		case option == "FROMLONLAT" && left >= 2:
+			lon, errResp := parseFloat(args[i+1])
+			if errResp != nil {
+				return a, errResp
+			}
+			lat, errResp := parseFloat(args[i+2])
+			if errResp != nil {
+				return a, errResp
+			}
+			if !store.ValidGeoCoord(lon, lat) {
+				return a, proto.NewError(fmt.Sprintf("ERR invalid longitude,latitude pair %f,%f", lon, lat))
+			}
+			a.opts.Lon, a.opts.Lat = lon, lat
+			fromLonLat = true
+			i += 2
+		case option == "BYRADIUS" && left >= 2:
+			radius, errResp := parseFloat(args[i+1])
+			if errResp != nil {
+				return a, errResp
+			}
+			if radius < 0 {
+				return a, proto.NewError("ERR radius cannot be negative")
+			}
+			a.opts.Radius, a.opts.Unit = radius, string(args[i+2])
+			byRadius = true
+			i += 2
+		case option == "BYBOX" && left >= 3:
+			width, errResp := parseFloat(args[i+1])
+			if errResp != nil {
+				return a, errResp
+			}
+			height, errResp := parseFloat(args[i+2])
+			if errResp != nil {
+				return a, errResp
+			}
+			if width < 0 || height < 0 {
+				return a, proto.NewError("ERR height or width cannot be negative")
+			}
+			a.opts.ByBox, a.opts.Width, a.opts.Height, a.opts.Unit = true, width, height, string(args[i+3])
+			byBox = true
+			i += 3
+		case option == "ASC" || option == "DESC":
+			a.opts.Order = option
+		case option == "COUNT" && left >= 1:
+			count, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return a, proto.NewError(errNotInteger)
+			}
+			if count <= 0 {
+				return a, proto.NewError("ERR COUNT must be > 0")
+			}
+			a.opts.Count = int(count)
+			i++
+			if i+1 < len(args) && strings.EqualFold(string(args[i+1]), "ANY") {
+				anyOpt = true
+				i++
+			}
+		case option == "ANY":
+			anyOpt = true
+		case option == "WITHCOORD" && !isStore:
+			a.withCoord = true
+		case option == "WITHDIST" && !isStore:
+			a.withDist = true
+		case option == "WITHHASH" && !isStore:
+			a.withHash = true
+		case option == "STOREDIST" && isStore:
+			a.storeDist = true
+		default:
+			return a, proto.NewError(errSyntax)
+		}
+	}
+	if fromMember == fromLonLat {
+		return a, proto.NewError(fmt.Sprintf("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for %s", name))
+	}
+	if byRadius == byBox {
+		return a, proto.NewError(fmt.Sprintf("ERR exactly one of BYRADIUS and BYBOX can be specified for %s", name))
+	}
+	if anyOpt && a.opts.Count == 0 {
+		return a, proto.NewError("ERR the ANY argument requires COUNT argument")
+	}
+	a.opts.Any = anyOpt
+	if _, err := store.GeoUnitMeters(a.opts.Unit); err != nil {
+		return a, proto.NewError(fmt.Sprintf("ERR %v", err))
+	}
+	return a, nil
+}
+
+// handleGeoSearch 处理 GEOSEARCH key <选项>。没有 WITH* 选项时回复成员数组，
+// 否则每个成员一个数组：成员、距离、geohash、[经度, 纬度]（按选项依次出现）
+func (h *Handler) handleGeoSearch(args [][]byte) proto.RESP {
+	if len(args) < 4 {
+		return proto.NewError("ERR wrong number of arguments for 'geosearch' command")
+	}
+	a, errResp := parseGeoSearchArgs("GEOSEARCH", args[1:])
+	if errResp != nil {
+		return errResp
+	}
+	results, err := h.Db.GeoSearch(string(args[0]), a.opts)
+	if err != nil {
+		return proto.NewError(fmt.Sprintf("ERR %v", err))
+	}
+	if !a.withCoord && !a.withDist && !a.withHash {
+		members := make([][]byte, len(results))
+		for i, r := range results {
+			members[i] = []byte(r.Member)
+		}
+		return &proto.Array{Args: members}
+	}
+	reply := make([]proto.RESP, len(results))
+	for i, r := range results {
+		item := []proto.RESP{proto.NewBulkString([]byte(r.Member))}
+		if a.withDist {
+			item = append(item, proto.NewBulkString([]byte(fmt.Sprintf("%.4f", r.Dist))))
+		}
+		if a.withHash {
+			item = append(item, proto.NewBulkString([]byte(r.Hash)))
+		}
+		if a.withCoord {
+			item = append(item, &proto.Array{Args: [][]byte{
+				[]byte(fmt.Sprintf("%.6f", r.Lon)),
+				[]byte(fmt.Sprintf("%.6f", r.Lat)),
+			}})
+		}
+		reply[i] = &proto.NestedArray{Elems: item}
+	}
+	return &proto.NestedArray{Elems: reply}
+}
+
+// handleGeoSearchStore 处理 GEOSEARCHSTORE destination source <选项>，回复写入的成员数
+func (h *Handler) handleGeoSearchStore(args [][]byte) proto.RESP {
+	if len(args) < 5 {
+		return proto.NewError("ERR wrong number of arguments for 'geosearchstore' command")
+	}
+	a, errResp := parseGeoSearchArgs("GEOSEARCHSTORE", args[2:])
+	if errResp != nil {
+		return errResp
+	}
+	stored, err := h.Db.GeoSearchStore(string(args[0]), string(args[1]), a.opts, a.storeDist)
+	if err != nil {
+		return proto.NewError(fmt.Sprintf("ERR %v", err))
+	}
+	return proto.NewInteger(stored)
+}
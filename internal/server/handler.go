@@ -7,16 +7,21 @@ import (
 	"math"
 	"net"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/lbp0200/BoltDB/internal/backup"
 	"github.com/lbp0200/BoltDB/internal/cluster"
 	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/metrics"
+	"github.com/lbp0200/BoltDB/internal/mirror"
 	"github.com/lbp0200/BoltDB/internal/proto"
 	"github.com/lbp0200/BoltDB/internal/replication"
 	"github.com/lbp0200/BoltDB/internal/store"
+	"github.com/lbp0200/BoltDB/internal/tlsconf"
 )
 
 type Handler struct {
@@ -25,14 +30,92 @@ type Handler struct {
 	Replication *replication.ReplicationManager
 	Backup      *backup.BackupManager
 	PubSub      *store.PubSubManager
+	// 写命令镜像到的上游 Redis（只在服务器级设置），nil 表示不镜像
+	Mirror *mirror.Mirror
 	// 事务状态（每个连接独立）
 	transaction *TransactionState
 	// 客户端信息（连接级别）
 	clientInfo *ClientInfo
 	// 集群ASKING状态
 	clusterAsking bool
+	// READONLY：集群中作为从节点时在本地处理读命令；READWRITE：允许在只读从节点上写入，见 readonly.go
+	clusterReadOnly bool
+	readWrite       bool
+	// HELLO 协商的回复压缩（连接级别），nil 表示不压缩
+	replyCompression *replyCompression
+	// HELLO 协商的协议版本（连接级别），0 与 2 为 RESP2，3 为 RESP3
+	protocol int
+	// SELECT 选择的逻辑数据库（连接级别），见 database.go
+	db int
+	// 逻辑数据库的个数（只保存在服务器级），见 SetDatabases
+	databases int
+	// 启动恢复期间的加载状态
+	loading loadingState
+	// 各监听器的连接统计
+	listeners listenerRegistry
+	// 命令延迟直方图与滚动百分位（只保存在服务器级）
+	latency latencyTracker
+	// LATENCY 命令记录的各事件延迟尖峰（只保存在服务器级），见 latency_monitor.go
+	latencyMonitor latencyMonitor
+	// Badger value log 回收与 BOLTREON.COMPACT 的设置与状态（只保存在服务器级），见 value_log_gc.go
+	valueLogGC valueLogGC
+	// 命令、连接与键的限速设置与计数（只保存在服务器级），见 ratelimit.go
+	rateLimit rateLimiter
+	// rate-limit-client 的令牌桶（连接级别）
+	rateBucket tokenBucket
+	// DEBUG FAULT 故障注入规则（只保存在服务器级）
+	faults faultInjector
+	// LRANGE、HGETALL 等命令最多返回的元素数（只保存在服务器级），见 SetMaxCollectionReply
+	maxCollectionReply int64
+	// 复制连接（PSYNC）必须出示已校验的 TLS 客户端证书（只保存在服务器级），见 SetRequireReplicaCert
+	requireReplicaCert bool
+	// EVAL/SCRIPT LOAD 加载的脚本（只保存在服务器级）
+	scripts scriptRegistry
+	// 命令的隔离锁（只保存在服务器级），见 lockCommand
+	isolation sync.RWMutex
+	// 读命令影子执行的抽样比例与统计（只保存在服务器级），见 SetShadowPercent
+	shadow shadowState
+	// 主动过期的间隔与每轮检查的键数（只保存在服务器级），可用 CONFIG SET 调整
+	expireSweep expireSweeper
+	// notify-keyspace-events 设置（只保存在服务器级）
+	notifier keyspaceNotifier
+	// INFO 使用的连接、命令与键空间统计（只保存在服务器级）
+	stats metrics.Stats
+	// /metrics 导出的 Stream 积压缓存（只保存在服务器级）
+	streamBacklog streamBacklogCache
+	// 所有客户端连接（只保存在服务器级），见 client.go
+	clients clientRegistry
+	// 监听器、连接 goroutine 池与 TCP 选项（只保存在服务器级），见 conn.go
+	conns connState
+	// SHUTDOWN 命令发出的关闭请求（只保存在服务器级），见 shutdown.go
+	shutdown shutdownState
+	// CLIENT PAUSE 的状态（只保存在服务器级）
+	clientPause clientPause
+	// SLOWLOG 记录的慢命令（只保存在服务器级）
+	slowlog slowLog
+	// CONFIG 参数与配置文件（只保存在服务器级），见 config.go
+	conf configState
+	// 追加写命令日志（只保存在服务器级），见 aof.go
+	aof aofState
+	// 流水线写命令合并的开关与统计（只保存在服务器级），见 coalesce.go
+	coalescer writeCoalescer
+	// EXEC 与脚本执行期间缓存的 AOF 命令（连接级别）
+	aofBatch    [][][]byte
+	aofBatching bool
+	// CLIENT KILL 关闭了当前连接：写出回复后关闭（连接级别）
+	closeAfterReply bool
+	// 最近一次传播写命令后的主节点复制偏移量，WAIT 等待从节点确认到这里（连接级别）
+	replOffset int64
+	// 从节点通过 REPLCONF listening-port 告知的服务端口，PSYNC 时记录到从节点连接（连接级别）
+	replListeningPort int
+	// 连接级 Handler 指向共享的服务器级 Handler（加载状态等只保存在服务器级）
+	server *Handler
 }
 
+// blockedClientsLimitMessage 全局阻塞客户端数达到上限时返回的错误
+// 单个键上的阻塞客户端达到上限时则立即返回空结果（与超时相同），客户端可以自行重试
+const blockedClientsLimitMessage = "ERR max number of blocked clients reached"
+
 // ClientInfo 客户端连接信息
 type ClientInfo struct {
 	ID       int64               // 客户端 ID
@@ -51,13 +134,27 @@ type ClientInfo struct {
 	Events   string              // 事件处理标志
 	Keys     map[string]struct{} // 客户端监控的键
 	ReadOnly bool                // 只读模式
+
+	// 以下字段由连接自己更新、CLIENT LIST 等从其他连接读取，Name、DB、Cmd、Multi 也受 mu 保护
+	mu              sync.Mutex
+	laddr           string    // 服务器端地址
+	conn            net.Conn  // CLIENT KILL 关闭的连接
+	created         time.Time // 连接建立时间
+	lastInteraction time.Time // 最近一次收到命令的时间
+	watch           int       // WATCH 的键数
+	qbuf            int       // 收到最近一条命令时读缓冲区中尚未处理的字节数
+	obuf            int       // 收到最近一条命令时写缓冲区中尚未发送的字节数
+	resp            int       // 协议版本
+	noEvict         bool      // CLIENT NO-EVICT ON
+	pubsub          bool      // 处于订阅模式
 }
 
 // TransactionState 事务状态
 type TransactionState struct {
-	Commands   []TransactionCommand // 排队的命令
-	WatchKeys  map[string]struct{}  // 监控的键
-	IsWatching bool                 // 是否处于WATCH状态
+	Commands   []TransactionCommand      // 排队的命令
+	WatchKeys  map[string]store.KeyWatch // 监控的键 -> WATCH 时的版本
+	IsWatching bool                      // 是否处于WATCH状态
+	state      txState                   // MULTI 状态，见 transaction.go
 }
 
 // TransactionCommand 事务中的命令
@@ -66,70 +163,93 @@ type TransactionCommand struct {
 	Args    [][]byte
 }
 
-// checkAndHandleRedirect 检查键是否需要重定向到其他节点
-// 返回 nil 表示不需要重定向，可以继续执行命令
-// 返回非 nil 表示需要重定向，包含重定向信息
-func (h *Handler) checkAndHandleRedirect(key string) proto.RESP {
-	if h.Cluster == nil {
-		return nil // 不在集群模式，直接执行
+// clusterReplyToRESP 将 CLUSTER 子命令返回的嵌套结构转换为 RESP（用于 CLUSTER SLOTS 等）
+func clusterReplyToRESP(v interface{}) proto.RESP {
+	switch val := v.(type) {
+	case string:
+		return proto.NewBulkString([]byte(val))
+	case int64:
+		return proto.NewInteger(val)
+	case int:
+		return proto.NewInteger(int64(val))
+	case []interface{}:
+		elems := make([]proto.RESP, len(val))
+		for i, item := range val {
+			elems[i] = clusterReplyToRESP(item)
+		}
+		return &proto.NestedArray{Elems: elems}
+	case [][]interface{}:
+		elems := make([]proto.RESP, len(val))
+		for i, item := range val {
+			elems[i] = clusterReplyToRESP(item)
+		}
+		return &proto.NestedArray{Elems: elems}
+	default:
+		return proto.NewBulkString([]byte(fmt.Sprintf("%v", val)))
 	}
+}
 
-	// 如果处于 ASKING 状态，检查是否是导入中的槽
-	if h.clusterAsking {
-		slot := cluster.Slot(key)
-		if h.Cluster.IsImportingSlot(slot) {
-			// 导入中的槽，允许执行命令
-			return nil
-		}
-		// ASKING 状态已过期，重置
-		h.clusterAsking = false
+// ServeTCP 监听并处理连接。连接交给 goroutine 池处理，Shutdown 之后返回 ErrServerClosed
+func (h *Handler) ServeTCP(l net.Listener) error {
+	conns := &h.root().conns
+	if err := conns.trackListener(l); err != nil {
+		return err
 	}
-
-	redirect := h.Cluster.CheckSlotRedirect(key)
-	if redirect != nil {
-		// 如果是 MOVED 重定向，返回错误
-		if redirect.Type == "MOVED" {
-			return proto.NewError(redirect.Error())
+	defer conns.untrackListener(l)
+	stats := h.root().listeners.add(l.Addr().String())
+	h.root().stats.Start(time.Now())
+	if h.Replication != nil {
+		h.Replication.SetCommandExecutor(h.root().replicaExecutor())
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if conns.draining.Load() {
+				return ErrServerClosed
+			}
+			return err
 		}
-		// 如果是 ASK 重定向，返回错误
-		if redirect.Type == "ASK" {
-			return proto.NewError(redirect.Error())
+		// 超过 maxclients 时回复错误后关闭，与 Redis 相同
+		if h.root().stats.ConnectedClients() >= h.maxClients() {
+			_, _ = conn.Write([]byte("-ERR max number of clients reached\r\n"))
+			_ = conn.Close()
+			h.root().stats.ConnectionRejected()
+			continue
 		}
+		if !conns.begin() {
+			_ = conn.Close()
+			return ErrServerClosed
+		}
+		stats.connected.Add(1)
+		stats.total.Add(1)
+		h.root().stats.ConnectionOpened()
+		conns.workers.run(func() {
+			defer conns.active.Done()
+			defer stats.connected.Add(-1)
+			defer h.root().stats.ConnectionClosed()
+			h.newConnection().handleConnection(conn)
+		})
 	}
-	return nil
 }
 
-// checkAndHandleMultiKeyRedirect 检查多个键是否需要重定向
-// 如果所有键都在当前节点，返回 nil
-// 如果有键需要重定向，返回 MOVED 错误
-func (h *Handler) checkAndHandleMultiKeyRedirect(keys []string) proto.RESP {
-	if h.Cluster == nil {
-		return nil // 不在集群模式，直接执行
-	}
-	var movedError *cluster.RedirectError
-	for _, key := range keys {
-		redirect := h.Cluster.CheckSlotRedirect(key)
-		if redirect != nil {
-			if redirect.Type == "MOVED" {
-				movedError = redirect
-			}
-		}
+// newConnection 为新连接创建独立的 Handler：事务、客户端信息等连接状态互不影响
+func (h *Handler) newConnection() *Handler {
+	return &Handler{
+		Db:          h.Db,
+		Cluster:     h.Cluster,
+		Replication: h.Replication,
+		Backup:      h.Backup,
+		PubSub:      h.PubSub,
+		server:      h.root(),
 	}
-	if movedError != nil {
-		return proto.NewError(movedError.Error())
-	}
-	return nil
 }
 
-// ServeTCP 监听并处理连接
-func (h *Handler) ServeTCP(l net.Listener) error {
-	for {
-		conn, err := l.Accept()
-		if err != nil {
-			return err
-		}
-		go h.handleConnection(conn)
+// root 返回服务器级 Handler
+func (h *Handler) root() *Handler {
+	if h.server != nil {
+		return h.server
 	}
+	return h
 }
 
 func (h *Handler) handleConnection(conn net.Conn) {
@@ -140,7 +260,12 @@ func (h *Handler) handleConnection(conn net.Conn) {
 	// 如果为true，主handler不关闭连接，由复制处理的goroutine负责关闭
 	replicationOwned := false
 
+	h.clientInfo = h.root().clients.register(conn)
+	defer h.root().clients.unregister(h.clientInfo)
+
 	defer func() {
+		// 释放未执行事务中 WATCH 的键
+		h.resetTransaction()
 		if !replicationOwned {
 			logger.Logger.Debug().Str("remote_addr", remoteAddr).Msg("连接关闭")
 			if err := conn.Close(); err != nil {
@@ -151,8 +276,13 @@ func (h *Handler) handleConnection(conn net.Conn) {
 		}
 	}()
 
-	reader := bufio.NewReader(conn)
-	writer := bufio.NewWriter(conn)
+	reader, writer := acquireConnBuffers(conn)
+	// 复制接管后 reader/writer 由复制处理继续使用，不归还
+	defer func() {
+		if !replicationOwned {
+			releaseConnBuffers(reader, writer)
+		}
+	}()
 
 	// 在复制接管时，需要关闭reader/writer以防止defer尝试Flush
 	// 但连接本身保持打开，由handleSlaveReplicationConnection负责关闭
@@ -167,21 +297,23 @@ func (h *Handler) handleConnection(conn net.Conn) {
 		}
 	}()
 
-	// 设置 TCP_NODELAY 以减少延迟
-	if tcpConn, ok := conn.(*net.TCPConn); ok {
-		if err := tcpConn.SetNoDelay(true); err != nil {
-			logger.Logger.Debug().Err(err).Msg("failed to set TCP_NODELAY")
-		}
-	}
+	h.configureConn(conn)
 
 	for {
+		// Shutdown 之后不再读取新的命令
+		if h.root().conns.draining.Load() {
+			return
+		}
 		// 尝试读取所有可用的命令（支持 Pipeline）
 		// 先尝试读取第一个命令
 		req, err := proto.ReadRESP(reader)
 		if err != nil {
-			// 连接关闭或读取错误，直接返回
-			// 不发送错误响应，因为连接可能已关闭
+			// 协议错误时回复错误后关闭连接（由 defer 刷新）；
+			// 其他错误是连接关闭或读取错误，不发送响应
 			// 这可能是正常的连接关闭（如 redis-benchmark 完成测试后关闭连接）
+			if resp := protocolErrorReply(err); resp != nil {
+				_ = proto.WriteRESP(writer, resp)
+			}
 			logger.Logger.Debug().Str("remote_addr", remoteAddr).Err(err).Msg("读取请求失败")
 			return
 		}
@@ -189,9 +321,30 @@ func (h *Handler) handleConnection(conn net.Conn) {
 		// 收集所有响应
 		var responses []proto.RESP
 		commandsProcessed := 0
+		// Pipeline 中读取失败：写出已有的回复后关闭连接，出错位置之后的数据无法继续解析
+		readFailed := false
+
+		// 处理第一个命令，再处理已缓冲的命令（Pipeline）
+		for req != nil {
+			// 连续的 SET、HSET、SADD、RPUSH 合并为一个事务提交
+			if batch, next, readErr := h.coalesceWrites(req, reader, remoteAddr, writer); batch != nil {
+				responses = append(responses, batch...)
+				commandsProcessed += len(batch)
+				req = next
+				if readErr != nil {
+					if resp := protocolErrorReply(readErr); resp != nil {
+						responses = append(responses, resp)
+					}
+					readFailed = true
+				}
+				continue
+			}
 
-		// 处理第一个命令
-		if resp := h.processRequest(req, reader, remoteAddr, writer, conn); resp != nil {
+			resp := h.processRequest(req, reader, remoteAddr, writer, conn)
+			if resp == nil {
+				// 处理失败或连接已由复制接管，直接返回
+				return
+			}
 			// 检查是否是复制接管信号
 			if _, isTakeover := resp.(ReplicationTakeoverSignal); isTakeover {
 				replicationOwned = true
@@ -202,34 +355,17 @@ func (h *Handler) handleConnection(conn net.Conn) {
 			}
 			responses = append(responses, resp)
 			commandsProcessed++
-		} else {
-			// 处理失败或连接已由复制接管，直接返回
-			return
-		}
 
-		// 尝试读取更多已缓冲的命令（Pipeline）
-		for reader.Buffered() > 0 {
-			req, err := proto.ReadRESP(reader)
-			if err != nil {
-				// 如果读取失败，可能是连接关闭
-				logger.Logger.Debug().Str("remote_addr", remoteAddr).Err(err).Msg("Pipeline 中读取请求失败")
-				break
-			}
-
-			if resp := h.processRequest(req, reader, remoteAddr, writer, conn); resp != nil {
-				// 检查是否是复制接管信号
-				if _, isTakeover := resp.(ReplicationTakeoverSignal); isTakeover {
-					replicationOwned = true
-					logger.Logger.Debug().
-						Str("remote_addr", remoteAddr).
-						Msg("复制接管连接")
-					return
+			req = nil
+			if reader.Buffered() > 0 {
+				if req, err = proto.ReadRESP(reader); err != nil {
+					// 如果读取失败，可能是连接关闭或协议错误
+					logger.Logger.Debug().Str("remote_addr", remoteAddr).Err(err).Msg("Pipeline 中读取请求失败")
+					if resp := protocolErrorReply(err); resp != nil {
+						responses = append(responses, resp)
+					}
+					req, readFailed = nil, true
 				}
-				responses = append(responses, resp)
-				commandsProcessed++
-			} else {
-				// 处理失败或连接已由复制接管，直接返回
-				return
 			}
 		}
 
@@ -252,6 +388,9 @@ func (h *Handler) handleConnection(conn net.Conn) {
 				Msg("刷新缓冲区失败")
 			return
 		}
+		if h.closeAfterReply || readFailed {
+			return
+		}
 
 		logger.Logger.Debug().
 			Str("remote_addr", remoteAddr).
@@ -260,6 +399,15 @@ func (h *Handler) handleConnection(conn net.Conn) {
 	}
 }
 
+// protocolErrorReply 读取请求遇到协议错误时回复给客户端的错误，其他读取错误返回 nil
+func protocolErrorReply(err error) proto.RESP {
+	var perr *proto.ProtocolError
+	if errors.As(err, &perr) {
+		return proto.NewError("ERR " + perr.Error())
+	}
+	return nil
+}
+
 // processRequest 处理单个请求，返回响应
 // PSYNC特殊处理：如果需要全量同步，会在返回响应后发送RDB数据
 // 返回 nil 表示连接已由复制接管，需要关闭处理循环
@@ -275,6 +423,17 @@ func (h *Handler) processRequest(req *proto.Array, reader *bufio.Reader, remoteA
 		Str("command", cmd).
 		Int("arg_count", len(args)-1).
 		Msg("执行命令")
+	h.trackCommand(cmd, args[1:], reader, writer)
+
+	// 加载数据期间只响应 PING/INFO/SHUTDOWN 等命令
+	if resp := h.checkLoading(cmd); resp != nil {
+		return resp
+	}
+
+	// -tls-auth-replicas：从节点必须通过 TLS 出示由 -tls-ca 签发的证书
+	if cmd == "PSYNC" && h.root().requireReplicaCert && !tlsconf.VerifiedPeer(conn) {
+		return proto.NewError("ERR replication requires a verified TLS client certificate")
+	}
 
 	// PSYNC特殊处理
 	if cmd == "PSYNC" && h.Replication != nil && h.Replication.IsMaster() {
@@ -286,7 +445,74 @@ func (h *Handler) processRequest(req *proto.Array, reader *bufio.Reader, remoteA
 		return resp
 	}
 
-	resp := h.executeCommand(cmd, args[1:], remoteAddr)
+	// 非 0 号数据库的键换成存储中的键名，入队的命令、AOF 与复制都使用替换后的参数
+	h.selectDBKeys(cmd, args[1:])
+
+	// MULTI 之后的命令除 EXEC/DISCARD 等外都加入队列，EXEC 时执行
+	if h.inMulti() && !txControlCommands[cmd] {
+		return h.queueCommand(cmd, args[1:])
+	}
+
+	// 参数个数与类型按 commands.spec 校验；与 Redis 相同，参数个数在事务入队时就检查
+	if resp := checkArity(cmd, args[1:]); resp != nil {
+		h.recordRejectedCommand(cmd)
+		return resp
+	}
+
+	// 集群模式下键不属于本节点时返回 MOVED/ASK 等重定向
+	if resp := h.checkClusterRedirect(cmd, args[1:]); resp != nil {
+		return resp
+	}
+
+	// 只读从节点拒绝写命令
+	if resp := h.checkReadOnlyReplica(cmd, args[1:]); resp != nil {
+		h.recordRejectedCommand(cmd)
+		return resp
+	}
+
+	// 超过 rate-limit-* 的限速时回复 -LIMIT，定时命令不受限制
+	if remoteAddr != scheduleRemoteAddr {
+		if resp := h.checkRateLimit(cmd, args[1:]); resp != nil {
+			h.recordRejectedCommand(cmd)
+			return resp
+		}
+	}
+
+	// (P|S)SUBSCRIBE 使连接进入订阅模式，确认与消息由 handleSubscribe 直接写出
+	if subscribeCommands[cmd] {
+		return h.handleSubscribe(cmd, args[1:], remoteAddr, reader, writer, conn)
+	}
+
+	// CLIENT PAUSE 期间等待暂停结束
+	h.waitClientPause(cmd)
+
+	// DEBUG FAULT 注入的延迟与错误在执行前生效，丢弃回复在执行后生效
+	fault := h.matchFaults(cmd)
+	if fault.delay > 0 {
+		time.Sleep(fault.delay)
+	}
+	if fault.message != "" {
+		return proto.NewError(fault.message)
+	}
+
+	// 超过 maxmemory 时先按策略淘汰键，无法淘汰时拒绝会增加数据的写命令
+	if resp := h.checkMaxmemory(cmd); resp != nil {
+		h.recordRejectedCommand(cmd)
+		return resp
+	}
+
+	start := time.Now()
+	unlock := h.lockCommand(cmd)
+	endAOF := h.beginAOF(cmd, args[1:])
+	resp := h.runCommand(cmd, args[1:], remoteAddr)
+	elapsed := time.Since(start)
+	h.feedAOF(cmd, args[1:], resp)
+	endAOF()
+	unlock()
+	h.recordLatency(cmd, resp, elapsed)
+	h.monitorCommandLatency(cmd, elapsed)
+	h.recordCommandStats(cmd, args[1:], resp, elapsed)
+	h.recordSlowLog(cmd, args, start, elapsed, remoteAddr)
 	if resp == nil {
 		logger.Logger.Error().
 			Str("remote_addr", remoteAddr).
@@ -295,12 +521,9 @@ func (h *Handler) processRequest(req *proto.Array, reader *bufio.Reader, remoteA
 		return proto.NewError("ERR internal error")
 	}
 
-	// 如果是主节点且是写命令，传播到从节点
-	if h.Replication != nil && h.Replication.IsMaster() && isWriteCommand(cmd) {
-		if cmd != "REPLICAOF" && cmd != "PSYNC" && cmd != "REPLCONF" {
-			h.Replication.PropagateCommand(req.Args)
-		}
-	}
+	h.propagateWrite(cmd, req.Args, resp)
+	h.mirrorWrite(cmd, req.Args, resp)
+	h.shadowRead(cmd, args[1:], resp, time.Since(start))
 
 	logger.Logger.Debug().
 		Str("remote_addr", remoteAddr).
@@ -308,9 +531,71 @@ func (h *Handler) processRequest(req *proto.Array, reader *bufio.Reader, remoteA
 		Str("response_type", getResponseType(resp)).
 		Msg("命令执行完成")
 
+	if fault.drop {
+		// 不写出任何内容，客户端等到超时
+		return proto.RawString("")
+	}
+	resp = h.adaptReply(cmd, args[1:], h.dbReply(cmd, args[1:], resp))
+	// HELLO 的回复不压缩，客户端据此确认协商结果
+	if cmd != "HELLO" {
+		resp = h.compressReply(resp)
+	}
+	return resp
+}
+
+// runCommand 检查参数类型和键类型后执行命令，写命令遵守命名空间配额与默认 TTL。
+// 事务中的命令在 EXEC 时经由这里执行
+func (h *Handler) runCommand(cmd string, args [][]byte, remoteAddr string) proto.RESP {
+	// 参数类型不符返回对应错误，键类型与命令不符时返回 WRONGTYPE
+	if resp := checkArgKinds(cmd, args); resp != nil {
+		return resp
+	}
+	// 访问的键已过期时先删除，之后的检查与执行都按键不存在处理
+	h.expireAccessedKeys(cmd, args)
+	if resp := h.checkWrongType(cmd, args); resp != nil {
+		return resp
+	}
+	// 超过 max-value-size 的写入与超过 max-collection-reply 的整集合读取直接返回错误
+	if resp := h.checkValueSizes(cmd, args); resp != nil {
+		return resp
+	}
+	args, resp := h.checkCollectionReply(cmd, args)
+	if resp != nil {
+		return resp
+	}
+	// UNLINK 的键在后台回收完成之前不能重新使用
+	if h.Db != nil && h.Db.UnlinkPending() > 0 {
+		h.Db.AwaitUnlink(commandKeys(cmd, args)...)
+	}
+	// 写放大统计：命令通过计入该命令的存储句柄执行，结束后恢复（未开启时句柄不变）
+	if h.Db != nil && isWriteCommand(cmd) {
+		db, done := h.Db.TrackWrites(cmd)
+		prev := h.Db
+		h.Db = db
+		defer func() {
+			h.Db = prev
+			done()
+		}()
+	}
+	resp = h.runWithNamespacePolicy(cmd, args, func() proto.RESP {
+		return h.executeCommand(cmd, args, remoteAddr)
+	})
+	h.touchKeys(cmd, args)
 	return resp
 }
 
+// propagateWrite 如果是主节点且是写命令，传播到从节点。resp 为命令的回复（过期与淘汰产生的 DEL 为 nil），
+// 用于把结果取决于执行时状态的命令改写为确定的形式，见 replicationCommands
+func (h *Handler) propagateWrite(cmd string, cmdArgs [][]byte, resp proto.RESP) {
+	if h.Replication != nil && h.Replication.IsMaster() && (isWriteCommand(cmd) || replicatedExtraCommands[cmd]) {
+		if cmd != "REPLICAOF" && cmd != "PSYNC" && cmd != "REPLCONF" {
+			for _, c := range h.replicationCommands(cmd, cmdArgs, resp) {
+				h.replOffset = h.Replication.PropagateCommand(h.canonicalDBKeys(c))
+			}
+		}
+	}
+}
+
 // getResponseType 获取响应类型（用于日志）
 func getResponseType(resp proto.RESP) string {
 	switch resp.(type) {
@@ -341,7 +626,7 @@ func (ReplicationTakeoverSignal) IsError() bool            { return false }
 
 func (h *Handler) handlePSyncWithRDB(args [][]byte, remoteAddr string, conn net.Conn, reader *bufio.Reader, writer *bufio.Writer) proto.RESP {
 	if len(args) < 2 {
-		return proto.NewError("ERR wrong number of arguments for 'PSYNC' command")
+		return proto.NewError("ERR wrong number of arguments for 'psync' command")
 	}
 
 	replId := string(args[0])
@@ -404,6 +689,7 @@ func (h *Handler) handlePSyncWithRDB(args [][]byte, remoteAddr string, conn net.
 
 		// 创建从节点连接并注册
 		slaveConn := replication.NewSlaveConnection(conn)
+		slaveConn.SetListeningPort(h.replListeningPort)
 		// 设置初始复制偏移量
 		slaveConn.SetReplOffset(result.Offset)
 		// 标记为已就绪（可以接收命令）
@@ -496,6 +782,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	switch cmd {
 	// 连接命令
 	case "PING":
+		if len(args) > 1 {
+			return proto.NewError("ERR wrong number of arguments for 'ping' command")
+		}
+		if len(args) == 1 {
+			return proto.NewBulkString(args[0])
+		}
 		return proto.NewSimpleString("PONG")
 
 	case "ROLE":
@@ -541,135 +833,51 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			[]byte("0"),
 		}}
 
+	case "HELLO":
+		return h.handleHello(args, remoteAddr)
+
 	case "ECHO":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'ECHO' command")
+			return proto.NewError("ERR wrong number of arguments for 'echo' command")
 		}
 		return proto.NewBulkString(args[0])
 
 	case "CLIENT":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'CLIENT' command")
-		}
-		subcommand := strings.ToUpper(string(args[0]))
-		switch subcommand {
-		case "LIST":
-			// 返回当前客户端列表（简化实现）
-			return proto.NewBulkString([]byte("id=1 addr=127.0.0.1:12345 fd=6 name= age=0 idle=0 flags=N db=0 sub=0 psub=0 multi=-1 cmd=client events=r oFlags= keys=0"))
-		case "GETNAME":
-			if h.clientInfo != nil && h.clientInfo.Name != "" {
-				return proto.NewBulkString([]byte(h.clientInfo.Name))
-			}
-			// 返回 nil BulkString（go-redis 会将其作为 nil 返回）
-			nilResp := proto.NewBulkString(nil)
-			return nilResp
-		case "SETNAME":
-			if len(args) < 2 {
-				return proto.NewError("ERR wrong number of arguments for 'CLIENT SETNAME' command")
-			}
-			// 设置客户端名称（仅内存存储）
-			name := string(args[1])
-			if h.clientInfo == nil {
-				h.clientInfo = &ClientInfo{}
-			}
-			h.clientInfo.Name = name
-			return proto.OK
-		case "ID":
-			if h.clientInfo != nil {
-				return proto.NewInteger(h.clientInfo.ID)
-			}
-			return proto.NewInteger(1)
-		case "KILL":
-			if len(args) < 2 {
-				return proto.NewError("ERR wrong number of arguments for 'CLIENT KILL' command")
-			}
-			addr := string(args[1])
-			// 简化实现：检查地址格式，但不真正关闭连接
-			// 由于无法真正关闭连接，总是返回0（连接不存在）
-			if addr == "" {
-				return proto.NewError("ERR Invalid address")
-			}
-			// 返回0表示没有杀死任何连接（简化实现）
-			return proto.NewInteger(0)
-		case "PAUSE":
-			// 暂停客户端（简化实现：空操作）
-			return proto.OK
-		case "UNPAUSE":
-			// 取消暂停（简化实现：空操作）
-			return proto.OK
-		case "INFO":
-			// 返回客户端连接信息
-			addr := "127.0.0.1:6379"
-			clientID := int64(0)
-			fd := 0
-			clientName := ""
-			if h.clientInfo != nil {
-				if h.clientInfo.Addr != "" {
-					addr = h.clientInfo.Addr
-				}
-				clientID = h.clientInfo.ID
-				fd = h.clientInfo.FD
-				clientName = h.clientInfo.Name
-			}
-			clientAge := "0"
-			idleTime := "0"
-			flags := "N"
-			db := "0"
-			sub := "0"
-			psub := "0"
-			multi := "-1"
-			keys := "0"
-			info := fmt.Sprintf("id=%d addr=%s fd=%d name=%s age=%s idle=%s flags=%s db=%s sub=%s psub=%s multi=%s cmd=client events=r oFlags= keys=%s",
-				clientID, addr, fd, clientName, clientAge, idleTime, flags, db, sub, psub, multi, keys)
-			return proto.NewBulkString([]byte(info))
-		case "NOEVICT":
-			if len(args) < 2 {
-				return proto.NewError("ERR wrong number of arguments for 'CLIENT NOEVICT' command")
-			}
-			mode := strings.ToUpper(string(args[1]))
-			if mode != "ON" && mode != "OFF" {
-				return proto.NewError("ERR syntax error")
-			}
-			// noevict 模式（简化实现）
-			return proto.OK
-		case "TRACKING":
-			if len(args) < 2 {
-				return proto.NewError("ERR wrong number of arguments for 'CLIENT TRACKING' command")
-			}
-			mode := strings.ToUpper(string(args[1]))
-			if mode != "ON" && mode != "OFF" {
-				return proto.NewError("ERR syntax error")
-			}
-			// tracking 模式（简化实现）
-			return proto.OK
-		default:
-			return proto.NewError("ERR syntax error")
+			return proto.NewError("ERR wrong number of arguments for 'client' command")
 		}
+		return h.handleClientCommand(args)
 
 	// String命令
 	case "SET":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'SET' command")
+			return proto.NewError("ERR wrong number of arguments for 'set' command")
 		}
 		key, value := string(args[0]), string(args[1])
-		// 检查集群重定向
-		if resp := h.checkAndHandleRedirect(key); resp != nil {
-			return resp
+		if len(args) == 2 {
+			if err := h.Db.Set(key, value); err != nil {
+				return proto.NewError(fmt.Sprintf("ERR %v", err))
+			}
+			return proto.OK
+		}
+		opts, errResp := h.parseSetOptions(args[2:])
+		if errResp != nil {
+			return errResp
+		}
+		result, err := h.Db.SetWithOptions(key, value, opts)
+		if errors.Is(err, store.ErrStringWrongType) {
+			return proto.NewError(wrongTypeErrorMessage)
 		}
-		if err := h.Db.Set(key, value); err != nil {
+		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
-		return proto.OK
+		return setReply(opts, result)
 
 	case "GET":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'GET' command")
+			return proto.NewError("ERR wrong number of arguments for 'get' command")
 		}
 		key := string(args[0])
-		// 检查集群重定向
-		if resp := h.checkAndHandleRedirect(key); resp != nil {
-			return resp
-		}
 		value, err := h.Db.Get(key)
 		if err != nil {
 			if errors.Is(err, store.ErrKeyNotFound) {
@@ -681,12 +889,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "SETEX":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'SETEX' command")
+			return proto.NewError("ERR wrong number of arguments for 'setex' command")
 		}
 		key, value := string(args[0]), string(args[2])
 		seconds, err := strconv.Atoi(string(args[1]))
 		if err != nil {
-			return proto.NewError("ERR invalid integer")
+			return proto.NewError(errNotInteger)
 		}
 		if err := h.Db.SetEX(key, value, seconds); err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
@@ -695,12 +903,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "PSETEX":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'PSETEX' command")
+			return proto.NewError("ERR wrong number of arguments for 'psetex' command")
 		}
 		key, value := string(args[0]), string(args[2])
 		milliseconds, err := strconv.ParseInt(string(args[1]), 10, 64)
 		if err != nil {
-			return proto.NewError("ERR invalid integer")
+			return proto.NewError(errNotInteger)
 		}
 		if err := h.Db.PSETEX(key, value, milliseconds); err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
@@ -709,7 +917,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "SETNX":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'SETNX' command")
+			return proto.NewError("ERR wrong number of arguments for 'setnx' command")
 		}
 		key, value := string(args[0]), string(args[1])
 		success, err := h.Db.SetNX(key, value)
@@ -720,7 +928,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "GETSET":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'GETSET' command")
+			return proto.NewError("ERR wrong number of arguments for 'getset' command")
 		}
 		key, value := string(args[0]), string(args[1])
 		oldValue, err := h.Db.GetSet(key, value)
@@ -729,18 +937,54 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		}
 		return proto.NewBulkString([]byte(oldValue))
 
+	case "GETEX":
+		key := string(args[0])
+		var expiresAt time.Time
+		persist := false
+		if len(args) > 1 {
+			option := strings.ToUpper(string(args[1]))
+			if option == "PERSIST" {
+				if len(args) != 2 {
+					return proto.NewError(errSyntax)
+				}
+				persist = true
+			} else {
+				if len(args) != 3 || (option != "EX" && option != "PX" && option != "EXAT" && option != "PXAT") {
+					return proto.NewError(errSyntax)
+				}
+				var errResp proto.RESP
+				if expiresAt, errResp = h.parseExpireOption("GETEX", option, args[2]); errResp != nil {
+					return errResp
+				}
+			}
+		}
+		value, err := h.Db.GetEx(key, expiresAt, persist)
+		if errors.Is(err, store.ErrKeyNotFound) {
+			return proto.NewBulkString(nil)
+		}
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		return proto.NewBulkString([]byte(value))
+
+	case "GETDEL":
+		value, err := h.Db.GetDel(string(args[0]))
+		if errors.Is(err, store.ErrKeyNotFound) {
+			return proto.NewBulkString(nil)
+		}
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		return proto.NewBulkString([]byte(value))
+
 	case "MGET":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'MGET' command")
+			return proto.NewError("ERR wrong number of arguments for 'mget' command")
 		}
 		keys := make([]string, len(args))
 		for i, arg := range args {
 			keys[i] = string(arg)
 		}
-		// 检查集群重定向
-		if resp := h.checkAndHandleMultiKeyRedirect(keys); resp != nil {
-			return resp
-		}
 		values, err := h.Db.MGet(keys...)
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
@@ -757,20 +1001,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "MSET":
 		if len(args) < 2 || len(args)%2 != 0 {
-			return proto.NewError("ERR wrong number of arguments for 'MSET' command")
+			return proto.NewError("ERR wrong number of arguments for 'mset' command")
 		}
 		pairs := make([]string, len(args))
 		for i, arg := range args {
 			pairs[i] = string(arg)
 		}
-		// 检查集群重定向（取所有键中的第一个作为检查点）
-		keys := make([]string, 0, len(args)/2)
-		for i := 0; i < len(args); i += 2 {
-			keys = append(keys, string(args[i]))
-		}
-		if resp := h.checkAndHandleMultiKeyRedirect(keys); resp != nil {
-			return resp
-		}
 		if err := h.Db.MSet(pairs...); err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
@@ -778,20 +1014,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "MSETNX":
 		if len(args) < 2 || len(args)%2 != 0 {
-			return proto.NewError("ERR wrong number of arguments for 'MSETNX' command")
+			return proto.NewError("ERR wrong number of arguments for 'msetnx' command")
 		}
 		pairs := make([]string, len(args))
 		for i, arg := range args {
 			pairs[i] = string(arg)
 		}
-		// 检查集群重定向（取所有键中的第一个作为检查点）
-		keys := make([]string, 0, len(args)/2)
-		for i := 0; i < len(args); i += 2 {
-			keys = append(keys, string(args[i]))
-		}
-		if resp := h.checkAndHandleMultiKeyRedirect(keys); resp != nil {
-			return resp
-		}
 		success, err := h.Db.MSetNX(pairs...)
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
@@ -800,13 +1028,9 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "INCR":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'INCR' command")
+			return proto.NewError("ERR wrong number of arguments for 'incr' command")
 		}
 		key := string(args[0])
-		// 检查集群重定向
-		if resp := h.checkAndHandleRedirect(key); resp != nil {
-			return resp
-		}
 		value, err := h.Db.INCR(key)
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
@@ -815,16 +1039,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "INCRBY":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'INCRBY' command")
+			return proto.NewError("ERR wrong number of arguments for 'incrby' command")
 		}
 		key := string(args[0])
-		// 检查集群重定向
-		if resp := h.checkAndHandleRedirect(key); resp != nil {
-			return resp
-		}
 		increment, err := strconv.ParseInt(string(args[1]), 10, 64)
 		if err != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
 		value, err := h.Db.INCRBY(key, increment)
 		if err != nil {
@@ -834,13 +1054,9 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "DECR":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'DECR' command")
+			return proto.NewError("ERR wrong number of arguments for 'decr' command")
 		}
 		key := string(args[0])
-		// 检查集群重定向
-		if resp := h.checkAndHandleRedirect(key); resp != nil {
-			return resp
-		}
 		value, err := h.Db.DECR(key)
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
@@ -849,16 +1065,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "DECRBY":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'DECRBY' command")
+			return proto.NewError("ERR wrong number of arguments for 'decrby' command")
 		}
 		key := string(args[0])
-		// 检查集群重定向
-		if resp := h.checkAndHandleRedirect(key); resp != nil {
-			return resp
-		}
 		decrement, err := strconv.ParseInt(string(args[1]), 10, 64)
 		if err != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
 		value, err := h.Db.DECRBY(key, decrement)
 		if err != nil {
@@ -868,16 +1080,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "INCRBYFLOAT":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'INCRBYFLOAT' command")
+			return proto.NewError("ERR wrong number of arguments for 'incrbyfloat' command")
 		}
 		key := string(args[0])
-		// 检查集群重定向
-		if resp := h.checkAndHandleRedirect(key); resp != nil {
-			return resp
-		}
 		increment, err := strconv.ParseFloat(string(args[1]), 64)
 		if err != nil {
-			return proto.NewError("ERR value is not a valid float")
+			return proto.NewError(errNotFloat)
 		}
 		value, err := h.Db.INCRBYFLOAT(key, increment)
 		if err != nil {
@@ -887,13 +1095,9 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "APPEND":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'APPEND' command")
+			return proto.NewError("ERR wrong number of arguments for 'append' command")
 		}
 		key, value := string(args[0]), string(args[1])
-		// 检查集群重定向
-		if resp := h.checkAndHandleRedirect(key); resp != nil {
-			return resp
-		}
 		length, err := h.Db.APPEND(key, value)
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
@@ -903,13 +1107,9 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "STRLEN":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'STRLEN' command")
+			return proto.NewError("ERR wrong number of arguments for 'strlen' command")
 		}
 		key := string(args[0])
-		// 检查集群重定向
-		if resp := h.checkAndHandleRedirect(key); resp != nil {
-			return resp
-		}
 		length, err := h.Db.StrLen(key)
 		if err != nil {
 			return proto.NewInteger(0)
@@ -919,160 +1119,109 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	// Bitmap commands
 	case "SETBIT":
-		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'SETBIT' command")
-		}
 		key := string(args[0])
-		offset, err := strconv.ParseUint(string(args[1]), 10, 64)
-		if err != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+		offset, errResp := parseBitOffset(args[1])
+		if errResp != nil {
+			return errResp
 		}
-		bit, err := strconv.ParseUint(string(args[2]), 10, 8)
-		if err != nil || (bit != 0 && bit != 1) {
+		bit := string(args[2])
+		if bit != "0" && bit != "1" {
 			return proto.NewError("ERR bit is not an integer or out of range")
 		}
-		newBit, err := h.Db.SetBit(key, int(offset), int(bit))
+		oldBit, err := h.Db.SetBit(key, offset, int(bit[0]-'0'))
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
-		return proto.NewInteger(int64(newBit))
+		return proto.NewInteger(int64(oldBit))
 
 	case "GETBIT":
-		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'GETBIT' command")
-		}
 		key := string(args[0])
-		offset, err := strconv.ParseUint(string(args[1]), 10, 64)
-		if err != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+		offset, errResp := parseBitOffset(args[1])
+		if errResp != nil {
+			return errResp
 		}
-		bit, err := h.Db.GetBit(key, int(offset))
+		bit, err := h.Db.GetBit(key, offset)
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
 		return proto.NewInteger(int64(bit))
 
 	case "BITCOUNT":
-		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'BITCOUNT' command")
+		// BITCOUNT key [start end [BYTE | BIT]]
+		if len(args) == 2 {
+			return proto.NewError(errSyntax)
 		}
-		key := string(args[0])
-		// BITCOUNT key [start end]
-		start := 0
-		end := -1
-		if len(args) >= 3 {
-			s, err := strconv.Atoi(string(args[1]))
-			if err != nil {
-				return proto.NewError("ERR value is not an integer or out of range")
-			}
-			start = s
-			e, err := strconv.Atoi(string(args[2]))
-			if err != nil {
-				return proto.NewError("ERR value is not an integer or out of range")
-			}
-			end = e
+		r, errResp := parseBitRange(args[1:])
+		if errResp != nil {
+			return errResp
 		}
-		count, err := h.Db.BitCount(key, start, end)
+		count, err := h.Db.BitCount(string(args[0]), r)
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
-		return proto.NewInteger(int64(count))
+		return proto.NewInteger(count)
 
 	case "BITOP":
-		// BITOP operation destkey key [key ...]
-		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'BITOP' command")
-		}
+		// BITOP AND | OR | XOR | NOT destkey key [key ...]
 		operation := strings.ToUpper(string(args[0]))
 		destKey := string(args[1])
 		sourceKeys := make([]string, len(args)-2)
 		for i := 2; i < len(args); i++ {
 			sourceKeys[i-2] = string(args[i])
 		}
-		// 验证操作类型
 		if operation != "AND" && operation != "OR" && operation != "XOR" && operation != "NOT" {
-			return proto.NewError("ERR syntax error")
+			return proto.NewError(errSyntax)
 		}
-		// NOT 只能有一个源键
 		if operation == "NOT" && len(sourceKeys) != 1 {
-			return proto.NewError("ERR BITOP NOT must be called with exactly one source key")
+			return proto.NewError("ERR BITOP NOT must be called with a single source key.")
 		}
 		length, err := h.Db.BitOp(operation, destKey, sourceKeys...)
+		if errors.Is(err, store.ErrBitmapWrongType) {
+			return proto.NewError(wrongTypeErrorMessage)
+		}
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
 		return proto.NewInteger(int64(length))
 
-	case "BITFIELD":
-		// BITFIELD key [GET type offset | SET type offset value | INCRBY type offset increment] ...
-		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'BITFIELD' command")
+	case "BITFIELD", "BITFIELD_RO":
+		// BITFIELD key [GET type offset] [SET type offset value] [INCRBY type offset increment] [OVERFLOW WRAP|SAT|FAIL] ...
+		// BITFIELD_RO key [GET type offset ...]
+		subArgs := make([]string, len(args)-1)
+		for i, arg := range args[1:] {
+			subArgs[i] = string(arg)
 		}
-		key := string(args[0])
-		operations := make([]string, 0, len(args)-1)
-		for i := 1; i < len(args); i++ {
-			operations = append(operations, string(args[i]))
-		}
-		results, err := h.Db.BitField(key, operations)
+		ops, err := store.ParseBitFieldOps(subArgs, cmd == "BITFIELD_RO")
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
-		// Single operation returns integer, multiple operations return array
-		if len(results) == 1 {
-			switch v := results[0].(type) {
-			case int64:
-				return proto.NewInteger(v)
-			case []interface{}:
-				// Overflow case
-				return proto.NewError(fmt.Sprintf("ERR overflow: %v", v))
-			}
-		}
-		// Convert results to RESP array for multiple operations
-		respArgs := make([][]byte, len(results))
-		for i, r := range results {
-			switch v := r.(type) {
-			case int64:
-				respArgs[i] = []byte(strconv.FormatInt(v, 10))
-			case []interface{}:
-				respArgs[i] = []byte(fmt.Sprintf("%v:%v", v[0], v[1]))
-			}
+		results, err := h.Db.BitField(string(args[0]), ops)
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
-		return &proto.Array{Args: respArgs}
+		return bitFieldReply(results)
 
 	case "BITPOS":
 		// BITPOS key bit [start [end [BYTE | BIT]]]
-		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'BITPOS' command")
-		}
-		key := string(args[0])
-		bit, err := strconv.Atoi(string(args[1]))
+		bit, err := strconv.ParseInt(string(args[1]), 10, 64)
 		if err != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
-		start, end := 0, -1
-		if len(args) >= 3 {
-			start, err = strconv.Atoi(string(args[2]))
-			if err != nil {
-				return proto.NewError("ERR value is not an integer or out of range")
-			}
+		if bit != 0 && bit != 1 {
+			return proto.NewError("ERR The bit argument must be 1 or 0.")
 		}
-		if len(args) >= 4 {
-			end, err = strconv.Atoi(string(args[3]))
-			if err != nil {
-				return proto.NewError("ERR value is not an integer or out of range")
-			}
+		r, errResp := parseBitRange(args[2:])
+		if errResp != nil {
+			return errResp
 		}
-		pos, err := h.Db.BitPos(key, bit, start, end)
+		pos, err := h.Db.BitPos(string(args[0]), int(bit), r)
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
-		return proto.NewInteger(int64(pos))
+		return proto.NewInteger(pos)
 
 	case "BITLEN":
 		// BITLEN key
-		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'BITLEN' command")
-		}
 		key := string(args[0])
 		length, err := h.Db.BitLen(key)
 		if err != nil {
@@ -1082,13 +1231,13 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "GETRANGE":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'GETRANGE' command")
+			return proto.NewError("ERR wrong number of arguments for 'getrange' command")
 		}
 		key := string(args[0])
 		start, err1 := strconv.Atoi(string(args[1]))
 		end, err2 := strconv.Atoi(string(args[2]))
 		if err1 != nil || err2 != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
 		value, err := h.Db.GetRange(key, start, end)
 		if err != nil {
@@ -1098,12 +1247,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "SETRANGE":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'SETRANGE' command")
+			return proto.NewError("ERR wrong number of arguments for 'setrange' command")
 		}
 		key, value := string(args[0]), string(args[2])
 		offset, err := strconv.Atoi(string(args[1]))
 		if err != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
 		length, err := h.Db.SetRange(key, offset, value)
 		if err != nil {
@@ -1115,20 +1264,16 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	// 通用键管理命令
 	case "DEL":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'DEL' command")
+			return proto.NewError("ERR wrong number of arguments for 'del' command")
 		}
 		keys := make([]string, len(args))
 		for i, arg := range args {
 			keys[i] = string(arg)
 		}
-		// 检查集群重定向
-		if resp := h.checkAndHandleMultiKeyRedirect(keys); resp != nil {
-			return resp
-		}
 		count := int64(0)
 		for _, arg := range args {
 			key := string(arg)
-			deleted, err := h.Db.Del(key)
+			deleted, err := h.Db.DelToTrash(key)
 			if err == nil {
 				count += deleted
 			}
@@ -1136,18 +1281,25 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		// #nosec G115 - count is bounded by practical data size limits
 		return proto.NewInteger(count)
 
+	case "UNLINK":
+		// 与 DEL 相同，但只同步删除类型键，集合类型的成员由后台回收
+		count := int64(0)
+		for _, arg := range args {
+			deleted, err := h.Db.Unlink(string(arg))
+			if err == nil {
+				count += deleted
+			}
+		}
+		return proto.NewInteger(count)
+
 	case "EXISTS":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'EXISTS' command")
+			return proto.NewError("ERR wrong number of arguments for 'exists' command")
 		}
 		keys := make([]string, len(args))
 		for i, arg := range args {
 			keys[i] = string(arg)
 		}
-		// 检查集群重定向
-		if resp := h.checkAndHandleMultiKeyRedirect(keys); resp != nil {
-			return resp
-		}
 		count := 0
 		for _, arg := range args {
 			key := string(arg)
@@ -1160,90 +1312,72 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		return proto.NewInteger(int64(count))
 
 	case "PFADD":
-		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'PFADD' command")
-		}
 		key := string(args[0])
-		// 检查集群重定向
-		if resp := h.checkAndHandleRedirect(key); resp != nil {
-			return resp
-		}
 		elements := make([]string, len(args)-1)
 		for i := 1; i < len(args); i++ {
 			elements[i-1] = string(args[i])
 		}
 		changed, err := h.Db.PFAdd(key, elements...)
+		if errors.Is(err, store.ErrHyperLogLogWrongType) {
+			return proto.NewError(wrongTypeErrorMessage)
+		}
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
 		return proto.NewInteger(changed)
 
 	case "PFCOUNT":
-		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'PFCOUNT' command")
-		}
 		keys := make([]string, len(args))
 		for i, arg := range args {
 			keys[i] = string(arg)
 		}
-		// 检查集群重定向
-		if resp := h.checkAndHandleMultiKeyRedirect(keys); resp != nil {
-			return resp
-		}
 		count, err := h.Db.PFCount(keys...)
+		if errors.Is(err, store.ErrHyperLogLogWrongType) {
+			return proto.NewError(wrongTypeErrorMessage)
+		}
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
 		return proto.NewInteger(count)
 
 	case "PFMERGE":
-		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'PFMERGE' command")
-		}
 		destKey := string(args[0])
 		sourceKeys := make([]string, len(args)-1)
 		for i := 1; i < len(args); i++ {
 			sourceKeys[i-1] = string(args[i])
 		}
-		// 检查集群重定向
-		if resp := h.checkAndHandleRedirect(destKey); resp != nil {
-			return resp
-		}
 		err := h.Db.PFMerge(destKey, sourceKeys...)
+		if errors.Is(err, store.ErrHyperLogLogWrongType) {
+			return proto.NewError(wrongTypeErrorMessage)
+		}
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
 		return proto.OK
 
 	case "PFINFO":
-		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'PFINFO' command")
-		}
-		key := string(args[0])
-		// 检查集群重定向
-		if resp := h.checkAndHandleRedirect(key); resp != nil {
-			return resp
+		info, err := h.Db.PFInfo(string(args[0]))
+		if errors.Is(err, store.ErrHyperLogLogWrongType) {
+			return proto.NewError(wrongTypeErrorMessage)
 		}
-		info, err := h.Db.PFInfo(key)
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
-		// 返回数组格式: [key1, value1, key2, value2, ...]
-		result := make([][]byte, 0, len(info)*2)
-		for k, v := range info {
-			result = append(result, []byte(k), []byte(strconv.FormatInt(v, 10)))
+		if info == nil {
+			return proto.NewError("ERR no such key")
 		}
-		return &proto.Array{Args: result}
+		// 返回数组格式: [key1, value1, key2, value2, ...]
+		return &proto.Array{Args: [][]byte{
+			[]byte("encoding"), []byte(info.Encoding),
+			[]byte("card"), []byte(strconv.FormatInt(info.Card, 10)),
+			[]byte("size"), []byte(strconv.FormatInt(info.Size, 10)),
+		}}
 
 	case "TYPE":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'TYPE' command")
+			return proto.NewError("ERR wrong number of arguments for 'type' command")
 		}
 		key := string(args[0])
-		// 检查集群重定向
-		if resp := h.checkAndHandleRedirect(key); resp != nil {
-			return resp
-		}
 		keyType, err := h.Db.Type(key)
 		if err != nil {
 			return proto.NewSimpleString("none")
@@ -1252,7 +1386,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "DUMP":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'DUMP' command")
+			return proto.NewError("ERR wrong number of arguments for 'dump' command")
 		}
 		key := string(args[0])
 		data, err := h.Db.Dump(key)
@@ -1263,7 +1397,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "RESTORE":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'RESTORE' command")
+			return proto.NewError("ERR wrong number of arguments for 'restore' command")
 		}
 		key := string(args[0])
 		// 解析 TTL（毫秒）
@@ -1275,7 +1409,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			if ttlMS, err := strconv.ParseInt(ttlArg, 10, 64); err == nil {
 				// 参数位置偏移：key, ttl, serializedData, [REPLACE|ABSTTL]
 				if len(args) < 4 {
-					return proto.NewError("ERR wrong number of arguments for 'RESTORE' command")
+					return proto.NewError("ERR wrong number of arguments for 'restore' command")
 				}
 				// 序列化数据现在在 args[2]
 				absttl := false
@@ -1289,7 +1423,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 				}
 				if absttl {
 					// ABSTTL: TTL 是绝对时间戳（毫秒）
-					now := time.Now().UnixMilli()
+					now := h.Db.Clock().Now().UnixMilli()
 					if ttlMS > now {
 						ttl = time.Duration(ttlMS-now) * time.Millisecond
 					}
@@ -1321,7 +1455,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "OBJECT":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'OBJECT' command")
+			return proto.NewError("ERR wrong number of arguments for 'object' command")
 		}
 		subcommand := strings.ToUpper(string(args[0]))
 		key := string(args[1])
@@ -1347,25 +1481,38 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			return proto.NewBulkString([]byte(encoding))
 		case "IDLETIME":
 			idletime, err := h.Db.ObjectIdleTime(key)
+			if errors.Is(err, store.ErrKeyNotFound) {
+				return proto.NewBulkString(nil)
+			}
 			if err != nil {
 				return proto.NewError(fmt.Sprintf("ERR %v", err))
 			}
 			return proto.NewInteger(idletime)
 		case "FREQ":
-			// BoltDB doesn't support LFU, return 0
-			return proto.NewInteger(0)
+			// 只有 LFU 策略下计数器才有意义，其他策略返回 0
+			freq, err := h.Db.ObjectFreq(key)
+			if errors.Is(err, store.ErrKeyNotFound) {
+				return proto.NewBulkString(nil)
+			}
+			if err != nil {
+				return proto.NewError(fmt.Sprintf("ERR %v", err))
+			}
+			if policy := h.maxmemoryPolicy(); policy != store.EvictAllKeysLFU && policy != store.EvictVolatileLFU {
+				freq = 0
+			}
+			return proto.NewInteger(freq)
 		default:
-			return proto.NewError("ERR syntax error")
+			return proto.NewError(errSyntax)
 		}
 
 	case "EXPIRE":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'EXPIRE' command")
+			return proto.NewError("ERR wrong number of arguments for 'expire' command")
 		}
 		key := string(args[0])
 		seconds, err := strconv.Atoi(string(args[1]))
 		if err != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
 		success, err := h.Db.Expire(key, seconds)
 		if err != nil {
@@ -1375,12 +1522,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "EXPIREAT":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'EXPIREAT' command")
+			return proto.NewError("ERR wrong number of arguments for 'expireat' command")
 		}
 		key := string(args[0])
 		timestamp, err := strconv.ParseInt(string(args[1]), 10, 64)
 		if err != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
 		success, err := h.Db.ExpireAt(key, timestamp)
 		if err != nil {
@@ -1390,12 +1537,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "PEXPIRE":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'PEXPIRE' command")
+			return proto.NewError("ERR wrong number of arguments for 'pexpire' command")
 		}
 		key := string(args[0])
 		milliseconds, err := strconv.ParseInt(string(args[1]), 10, 64)
 		if err != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
 		success, err := h.Db.PExpire(key, milliseconds)
 		if err != nil {
@@ -1405,12 +1552,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "PEXPIREAT":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'PEXPIREAT' command")
+			return proto.NewError("ERR wrong number of arguments for 'pexpireat' command")
 		}
 		key := string(args[0])
 		timestamp, err := strconv.ParseInt(string(args[1]), 10, 64)
 		if err != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
 		success, err := h.Db.PExpireAt(key, timestamp)
 		if err != nil {
@@ -1420,7 +1567,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "TTL":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'TTL' command")
+			return proto.NewError("ERR wrong number of arguments for 'ttl' command")
 		}
 		key := string(args[0])
 		ttl, err := h.Db.TTL(key)
@@ -1431,7 +1578,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "PTTL":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'PTTL' command")
+			return proto.NewError("ERR wrong number of arguments for 'pttl' command")
 		}
 		key := string(args[0])
 		pttl, err := h.Db.PTTL(key)
@@ -1442,7 +1589,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "PERSIST":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'PERSIST' command")
+			return proto.NewError("ERR wrong number of arguments for 'persist' command")
 		}
 		key := string(args[0])
 		success, err := h.Db.Persist(key)
@@ -1453,7 +1600,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "RENAME":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'RENAME' command")
+			return proto.NewError("ERR wrong number of arguments for 'rename' command")
 		}
 		key, newKey := string(args[0]), string(args[1])
 		if err := h.Db.Rename(key, newKey); err != nil {
@@ -1463,7 +1610,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "RENAMENX":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'RENAMENX' command")
+			return proto.NewError("ERR wrong number of arguments for 'renamenx' command")
 		}
 		key, newKey := string(args[0]), string(args[1])
 		success, err := h.Db.RenameNX(key, newKey)
@@ -1474,12 +1621,11 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "COPY":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'COPY' command")
+			return proto.NewError("ERR wrong number of arguments for 'copy' command")
 		}
 		srcKey := string(args[0])
 		dstKey := string(args[1])
 		replace := false
-		db := int(0)
 		i := 2
 		for i < len(args) {
 			opt := strings.ToUpper(string(args[i]))
@@ -1488,71 +1634,40 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 				replace = true
 				i++
 			case "DB":
+				// 目标键换成指定数据库中的同名键
 				if i+1 >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
-				dbNum, err := strconv.Atoi(string(args[i+1]))
-				if err != nil {
-					return proto.NewError("ERR value is not an integer")
+				db, errResp := h.parseDBIndex(args[i+1], errNotInteger)
+				if errResp != nil {
+					return errResp
 				}
-				db = dbNum
+				_, name := store.SplitDBKey(dstKey)
+				dstKey = h.Db.StoreKey(db, name)
 				i += 2
 			default:
 				return proto.NewError(fmt.Sprintf("ERR syntax error, unknown option '%s'", opt))
 			}
 		}
-		// 不支持跨数据库COPY
-		if db != 0 {
-			return proto.NewError("ERR DB option not supported")
+		if srcKey == dstKey {
+			return proto.NewError("ERR source and destination objects are the same")
 		}
-		// 获取源键类型
-		srcType, err := h.Db.Type(srcKey)
+		// 按存储中的编码逐个复制 Badger 键，保留过期时间，不把整个结构读入内存
+		copied, err := h.Db.Copy(srcKey, dstKey, replace)
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
-		if srcType == "none" {
-			return proto.NewInteger(0) // 源键不存在
-		}
-		// 检查目标键是否存在
-		dstExists, _ := h.Db.Exists(dstKey)
-		if dstExists && !replace {
-			return proto.NewInteger(0) // 目标存在且不替换
-		}
-		// 根据类型复制
-		var copied bool
-		switch srcType {
-		case "string":
-			val, err := h.Db.Get(srcKey)
-			if err == nil {
-				err = h.Db.Set(dstKey, val)
-			}
-			copied = err == nil
-		case "list":
-			copied = h.copyList(srcKey, dstKey)
-		case "hash":
-			copied = h.copyHash(srcKey, dstKey)
-		case "set":
-			copied = h.copySet(srcKey, dstKey)
-		case "zset":
-			copied = h.copySortedSet(srcKey, dstKey)
-		default:
-			return proto.NewError("ERR unknown type")
-		}
-		if !copied {
-			return proto.NewError("ERR copy failed")
-		}
-		return proto.NewInteger(1)
+		return proto.NewInteger(int64(boolToInt(copied)))
 
 	case "SWAPDB":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'SWAPDB' command")
+			return proto.NewError("ERR wrong number of arguments for 'swapdb' command")
 		}
-		// BoltDB 是单数据库实现，SWAPDB 是空操作
-		return proto.NewSimpleString("OK")
+		return h.handleSwapDB(args)
 
 	case "TOUCH":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'TOUCH' command")
+			return proto.NewError("ERR wrong number of arguments for 'touch' command")
 		}
 		// TOUCH 返回键的数量（BadgerDB 不维护访问时间，所以只是 EXIST 的变体）
 		count := int64(0)
@@ -1566,15 +1681,14 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		return proto.NewInteger(count)
 
 	case "SHUTDOWN":
-		// SHUTDOWN 命令（简化实现：返回错误，因为没有优雅关闭机制）
-		return proto.NewError("ERR Redis is running in read-only mode. To shutdown use SHUTDOWN NOSAVE or SHUTDOWN SAVE")
+		return h.handleShutdown(args)
 
 	case "KEYS":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'KEYS' command")
+			return proto.NewError("ERR wrong number of arguments for 'keys' command")
 		}
 		pattern := string(args[0])
-		keys, err := h.Db.Keys(pattern)
+		keys, err := h.Db.DBKeys(h.db, pattern)
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
@@ -1584,36 +1698,11 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		}
 		return &proto.Array{Args: results}
 
-	case "SCAN":
-		cursor := uint64(0)
-		pattern := "*"
-		count := 10
-		if len(args) >= 1 {
-			var err error
-			cursor, err = strconv.ParseUint(string(args[0]), 10, 64)
-			if err != nil {
-				return proto.NewError("ERR invalid cursor")
-			}
-		}
-		if len(args) >= 3 && strings.ToUpper(string(args[1])) == "MATCH" {
-			pattern = string(args[2])
-		}
-		if len(args) >= 5 && strings.ToUpper(string(args[3])) == "COUNT" {
-			var err error
-			count, err = strconv.Atoi(string(args[4]))
-			if err != nil {
-				return proto.NewError("ERR value is not an integer or out of range")
-			}
-		}
-		result, err := h.Db.Scan(cursor, pattern, count)
-		if err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
-		}
-		// 返回嵌套数组格式: [cursor, [key1, key2, ...]]
-		return proto.NewScanResponse(result.Cursor, result.Keys)
+	case "SCAN", "SSCAN", "HSCAN", "ZSCAN":
+		return h.handleScan(cmd, args)
 
 	case "RANDOMKEY":
-		key, err := h.Db.RandomKey()
+		key, err := h.Db.DBRandomKey(h.db)
 		if err != nil || key == "" {
 			return proto.NewBulkString(nil)
 		}
@@ -1622,7 +1711,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	// List命令
 	case "LPUSH":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'LPUSH' command")
+			return proto.NewError("ERR wrong number of arguments for 'lpush' command")
 		}
 		key := string(args[0])
 		values := make([]string, len(args)-1)
@@ -1638,7 +1727,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "RPUSH":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'RPUSH' command")
+			return proto.NewError("ERR wrong number of arguments for 'rpush' command")
 		}
 		key := string(args[0])
 		values := make([]string, len(args)-1)
@@ -1652,31 +1741,46 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		// #nosec G115 - count is bounded by practical data size limits
 		return proto.NewInteger(int64(count))
 
-	case "LPOP":
-		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'LPOP' command")
+	case "LPOP", "RPOP":
+		// LPOP key [count]：带 count 时返回数组（键不存在时为 *-1），元素可以是空字符串
+		if len(args) < 1 || len(args) > 2 {
+			return proto.NewError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd)))
 		}
 		key := string(args[0])
-		value, err := h.Db.LPop(key)
-		if err != nil || value == "" {
-			return proto.NewBulkString(nil)
+		count := int64(1)
+		if len(args) == 2 {
+			n, err := strconv.ParseInt(string(args[1]), 10, 64)
+			if err != nil || n < 0 {
+				return proto.NewError("ERR value is out of range, must be positive")
+			}
+			count = n
 		}
-		return proto.NewBulkString([]byte(value))
-
-	case "RPOP":
-		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'RPOP' command")
+		pop := h.Db.LPopCount
+		if cmd == "RPOP" {
+			pop = h.Db.RPopCount
 		}
-		key := string(args[0])
-		value, err := h.Db.RPop(key)
-		if err != nil || value == "" {
-			return proto.NewBulkString(nil)
+		values, ok, err := pop(key, count)
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
-		return proto.NewBulkString([]byte(value))
+		if len(args) == 1 {
+			if len(values) == 0 {
+				return proto.NewBulkString(nil)
+			}
+			return proto.NewBulkString([]byte(values[0]))
+		}
+		if !ok {
+			return proto.RawString("*-1\r\n")
+		}
+		results := make([][]byte, len(values))
+		for i, v := range values {
+			results[i] = []byte(v)
+		}
+		return &proto.Array{Args: results}
 
 	case "LLEN":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'LLEN' command")
+			return proto.NewError("ERR wrong number of arguments for 'llen' command")
 		}
 		key := string(args[0])
 		length, err := h.Db.LLen(key)
@@ -1688,12 +1792,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "LINDEX":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'LINDEX' command")
+			return proto.NewError("ERR wrong number of arguments for 'lindex' command")
 		}
 		key := string(args[0])
 		index, err := strconv.ParseInt(string(args[1]), 10, 64)
 		if err != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
 		value, err := h.Db.LIndex(key, index)
 		if err != nil || value == "" {
@@ -1703,13 +1807,13 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "LRANGE":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'LRANGE' command")
+			return proto.NewError("ERR wrong number of arguments for 'lrange' command")
 		}
 		key := string(args[0])
 		start, err1 := strconv.ParseInt(string(args[1]), 10, 64)
 		stop, err2 := strconv.ParseInt(string(args[2]), 10, 64)
 		if err1 != nil || err2 != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
 		values, err := h.Db.LRange(key, start, stop)
 		if err != nil {
@@ -1723,12 +1827,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "LSET":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'LSET' command")
+			return proto.NewError("ERR wrong number of arguments for 'lset' command")
 		}
 		key, value := string(args[0]), string(args[2])
 		index, err := strconv.ParseInt(string(args[1]), 10, 64)
 		if err != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
 		if err := h.Db.LSet(key, index, value); err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
@@ -1737,13 +1841,13 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "LTRIM":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'LTRIM' command")
+			return proto.NewError("ERR wrong number of arguments for 'ltrim' command")
 		}
 		key := string(args[0])
 		start, err1 := strconv.ParseInt(string(args[1]), 10, 64)
 		stop, err2 := strconv.ParseInt(string(args[2]), 10, 64)
 		if err1 != nil || err2 != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
 		if err := h.Db.LTrim(key, start, stop); err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
@@ -1752,12 +1856,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "LINSERT":
 		if len(args) < 4 {
-			return proto.NewError("ERR wrong number of arguments for 'LINSERT' command")
+			return proto.NewError("ERR wrong number of arguments for 'linsert' command")
 		}
 		key, pivot, value := string(args[0]), string(args[2]), string(args[3])
 		where := strings.ToUpper(string(args[1]))
 		if where != "BEFORE" && where != "AFTER" {
-			return proto.NewError("ERR syntax error")
+			return proto.NewError(errSyntax)
 		}
 		count, err := h.Db.LInsert(key, where, pivot, value)
 		if err != nil {
@@ -1768,7 +1872,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "LPOS":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'LPOS' command")
+			return proto.NewError("ERR wrong number of arguments for 'lpos' command")
 		}
 		key := string(args[0])
 		element := string(args[1])
@@ -1793,7 +1897,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 				maxlen = m
 				i += 2
 			} else {
-				return proto.NewError("ERR syntax error")
+				return proto.NewError(errSyntax)
 			}
 		}
 
@@ -1820,12 +1924,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "LREM":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'LREM' command")
+			return proto.NewError("ERR wrong number of arguments for 'lrem' command")
 		}
 		key, value := string(args[0]), string(args[2])
 		count, err := strconv.ParseInt(string(args[1]), 10, 64)
 		if err != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
 		removed, err := h.Db.LRem(key, count, value)
 		if err != nil {
@@ -1835,7 +1939,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "RPOPLPUSH":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'RPOPLPUSH' command")
+			return proto.NewError("ERR wrong number of arguments for 'rpoplpush' command")
 		}
 		source, destination := string(args[0]), string(args[1])
 		value, err := h.Db.RPopLPush(source, destination)
@@ -1846,7 +1950,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "LMOVE":
 		if len(args) < 4 {
-			return proto.NewError("ERR wrong number of arguments for 'LMOVE' command")
+			return proto.NewError("ERR wrong number of arguments for 'lmove' command")
 		}
 		source := string(args[0])
 		destination := string(args[1])
@@ -1863,7 +1967,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "BLMOVE":
 		if len(args) < 5 {
-			return proto.NewError("ERR wrong number of arguments for 'BLMOVE' command")
+			return proto.NewError("ERR wrong number of arguments for 'blmove' command")
 		}
 		source := string(args[0])
 		destination := string(args[1])
@@ -1874,6 +1978,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			return proto.NewError("ERR timeout is not a float")
 		}
 		value, err := h.Db.BLMoveBlocking(source, destination, sourceDirection, destinationDirection, timeout)
+		if errors.Is(err, store.ErrBlockedClientsLimit) {
+			return proto.NewError(blockedClientsLimitMessage)
+		}
+		if errors.Is(err, store.ErrBlockedKeyLimit) {
+			return proto.NewBulkString(nil)
+		}
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
@@ -1884,7 +1994,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "LPUSHX":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'LPUSHX' command")
+			return proto.NewError("ERR wrong number of arguments for 'lpushx' command")
 		}
 		key := string(args[0])
 		values := make([]string, len(args)-1)
@@ -1900,7 +2010,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "RPUSHX":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'RPUSHX' command")
+			return proto.NewError("ERR wrong number of arguments for 'rpushx' command")
 		}
 		key := string(args[0])
 		values := make([]string, len(args)-1)
@@ -1916,7 +2026,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "BLPOP":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'BLPOP' command")
+			return proto.NewError("ERR wrong number of arguments for 'blpop' command")
 		}
 		keys := make([]string, len(args)-1)
 		for i := 0; i < len(args)-1; i++ {
@@ -1927,14 +2037,18 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			return proto.NewError("ERR timeout is not an integer or out of range")
 		}
 		key, value, err := h.Db.BLPOPBlocking(keys, timeout)
+		if errors.Is(err, store.ErrBlockedClientsLimit) {
+			return proto.NewError(blockedClientsLimitMessage)
+		}
+		// 超时或没有数据（包括事务中不阻塞执行）时与 Redis 相同返回空数组 *-1
 		if err != nil || key == "" {
-			return &proto.Array{Args: [][]byte{}}
+			return proto.RawString("*-1\r\n")
 		}
 		return &proto.Array{Args: [][]byte{[]byte(key), []byte(value)}}
 
 	case "BRPOP":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'BRPOP' command")
+			return proto.NewError("ERR wrong number of arguments for 'brpop' command")
 		}
 		keys := make([]string, len(args)-1)
 		for i := 0; i < len(args)-1; i++ {
@@ -1945,14 +2059,18 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			return proto.NewError("ERR timeout is not an integer or out of range")
 		}
 		key, value, err := h.Db.BRPOPBlocking(keys, timeout)
+		if errors.Is(err, store.ErrBlockedClientsLimit) {
+			return proto.NewError(blockedClientsLimitMessage)
+		}
+		// 超时或没有数据（包括事务中不阻塞执行）时与 Redis 相同返回空数组 *-1
 		if err != nil || key == "" {
-			return &proto.Array{Args: [][]byte{}}
+			return proto.RawString("*-1\r\n")
 		}
 		return &proto.Array{Args: [][]byte{[]byte(key), []byte(value)}}
 
 	case "BRPOPLPUSH":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'BRPOPLPUSH' command")
+			return proto.NewError("ERR wrong number of arguments for 'brpoplpush' command")
 		}
 		source, destination := string(args[0]), string(args[1])
 		timeout, err := strconv.Atoi(string(args[2]))
@@ -1960,6 +2078,9 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			return proto.NewError("ERR timeout is not an integer or out of range")
 		}
 		value, err := h.Db.BRPOPLPUSHBlocking(source, destination, timeout)
+		if errors.Is(err, store.ErrBlockedClientsLimit) {
+			return proto.NewError(blockedClientsLimitMessage)
+		}
 		if err != nil || value == "" {
 			return proto.NewBulkString(nil)
 		}
@@ -1967,17 +2088,16 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	// Hash命令
 	case "HSET":
-		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'HSET' command")
+		if len(args) < 3 || len(args)%2 == 0 {
+			return proto.NewError("ERR wrong number of arguments for 'hset' command")
 		}
 		key := string(args[0])
 		count := 0
 		for i := 1; i < len(args); i += 2 {
-			if i+1 >= len(args) {
-				break
-			}
 			field, value := string(args[i]), args[i+1]
-			if err := h.Db.HSet(key, field, string(value)); err == nil {
+			// 只统计新增的字段，覆盖已有字段不计数
+			exists, _ := h.Db.HExists(key, field)
+			if err := h.Db.HSet(key, field, string(value)); err == nil && !exists {
 				count++
 			}
 		}
@@ -1986,7 +2106,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "HGET":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'HGET' command")
+			return proto.NewError("ERR wrong number of arguments for 'hget' command")
 		}
 		key, field := string(args[0]), string(args[1])
 		value, err := h.Db.HGet(key, field)
@@ -1997,7 +2117,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "HDEL":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'HDEL' command")
+			return proto.NewError("ERR wrong number of arguments for 'hdel' command")
 		}
 		key := string(args[0])
 		fields := make([]string, len(args)-1)
@@ -2011,9 +2131,15 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		// #nosec G115 - count is bounded by practical data size limits
 		return proto.NewInteger(int64(count))
 
+	case "HEXPIRE", "HPEXPIRE", "HEXPIREAT", "HPEXPIREAT":
+		return h.handleHashFieldExpire(cmd, args)
+
+	case "HPERSIST", "HTTL", "HPTTL", "HEXPIRETIME", "HPEXPIRETIME":
+		return h.handleHashFieldTTL(cmd, args)
+
 	case "HLEN":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'HLEN' command")
+			return proto.NewError("ERR wrong number of arguments for 'hlen' command")
 		}
 		key := string(args[0])
 		length, err := h.Db.HLen(key)
@@ -2025,7 +2151,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "HGETALL":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'HGETALL' command")
+			return proto.NewError("ERR wrong number of arguments for 'hgetall' command")
 		}
 		key := string(args[0])
 		data, err := h.Db.HGetAll(key)
@@ -2038,9 +2164,59 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		}
 		return &proto.Array{Args: results}
 
+	case "BOLTREON.SUMRANGE":
+		// BOLTREON.SUMRANGE HASH key [MATCH fieldpattern] [AGG SUM|MIN|MAX|AVG|COUNT]
+		// BOLTREON.SUMRANGE KEYS pattern [FIELD field] [AGG SUM|MIN|MAX|AVG|COUNT]
+		// 在服务端一次扫描中聚合数值，非数值忽略；COUNT 返回整数，其余返回数值字符串，
+		// 没有数值时 SUM 返回 0，MIN/MAX/AVG 返回 nil
+		mode := strings.ToUpper(string(args[0]))
+		target := string(args[1])
+		op := store.AggregateSum
+		pattern, field := "*", ""
+		for i := 2; i < len(args); i += 2 {
+			if i+1 >= len(args) {
+				return proto.NewError(errSyntax)
+			}
+			opt, val := strings.ToUpper(string(args[i])), string(args[i+1])
+			switch {
+			case opt == "AGG":
+				parsed, err := store.ParseAggregateOp(val)
+				if err != nil {
+					return proto.NewError(errSyntax)
+				}
+				op = parsed
+			case opt == "MATCH" && mode == "HASH":
+				pattern = val
+			case opt == "FIELD" && mode == "KEYS":
+				field = val
+			default:
+				return proto.NewError(errSyntax)
+			}
+		}
+		var result store.AggregateResult
+		var err error
+		if mode == "HASH" {
+			result, err = h.Db.AggregateHash(target, pattern, op)
+		} else {
+			result, err = h.Db.AggregateKeys(target, field, op)
+		}
+		if errors.Is(err, store.ErrAggregateWrongType) {
+			return proto.NewError(wrongTypeErrorMessage)
+		}
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		if op == store.AggregateCount {
+			return proto.NewInteger(result.Count)
+		}
+		if result.Count == 0 && op != store.AggregateSum {
+			return proto.NewBulkString(nil)
+		}
+		return proto.NewBulkString([]byte(strconv.FormatFloat(result.Value, 'f', -1, 64)))
+
 	case "HEXISTS":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'HEXISTS' command")
+			return proto.NewError("ERR wrong number of arguments for 'hexists' command")
 		}
 		key, field := string(args[0]), string(args[1])
 		exists, err := h.Db.HExists(key, field)
@@ -2051,7 +2227,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "HKEYS":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'HKEYS' command")
+			return proto.NewError("ERR wrong number of arguments for 'hkeys' command")
 		}
 		key := string(args[0])
 		keys, err := h.Db.HKeys(key)
@@ -2066,7 +2242,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "HVALS":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'HVALS' command")
+			return proto.NewError("ERR wrong number of arguments for 'hvals' command")
 		}
 		key := string(args[0])
 		values, err := h.Db.HVals(key)
@@ -2079,7 +2255,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "HMSET":
 		if len(args) < 3 || len(args)%2 == 0 {
-			return proto.NewError("ERR wrong number of arguments for 'HMSET' command")
+			return proto.NewError("ERR wrong number of arguments for 'hmset' command")
 		}
 		key := string(args[0])
 		for i := 1; i < len(args); i += 2 {
@@ -2095,7 +2271,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "HMGET":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'HMGET' command")
+			return proto.NewError("ERR wrong number of arguments for 'hmget' command")
 		}
 		key := string(args[0])
 		fields := make([]string, len(args)-1)
@@ -2118,7 +2294,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "HSETNX":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'HSETNX' command")
+			return proto.NewError("ERR wrong number of arguments for 'hsetnx' command")
 		}
 		key, field, value := string(args[0]), string(args[1]), string(args[2])
 		success, err := h.Db.HSetNX(key, field, value)
@@ -2129,12 +2305,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "HINCRBY":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'HINCRBY' command")
+			return proto.NewError("ERR wrong number of arguments for 'hincrby' command")
 		}
 		key, field := string(args[0]), string(args[1])
 		increment, err := strconv.ParseInt(string(args[2]), 10, 64)
 		if err != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
 		value, err := h.Db.HIncrBy(key, field, increment)
 		if err != nil {
@@ -2144,12 +2320,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "HINCRBYFLOAT":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'HINCRBYFLOAT' command")
+			return proto.NewError("ERR wrong number of arguments for 'hincrbyfloat' command")
 		}
 		key, field := string(args[0]), string(args[1])
 		increment, err := strconv.ParseFloat(string(args[2]), 64)
 		if err != nil {
-			return proto.NewError("ERR value is not a valid float")
+			return proto.NewError(errNotFloat)
 		}
 		value, err := h.Db.HIncrByFloat(key, field, increment)
 		if err != nil {
@@ -2159,7 +2335,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "HSTRLEN":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'HSTRLEN' command")
+			return proto.NewError("ERR wrong number of arguments for 'hstrlen' command")
 		}
 		key, field := string(args[0]), string(args[1])
 		length, err := h.Db.HStrLen(key, field)
@@ -2171,7 +2347,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "HRANDFIELD":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'HRANDFIELD' command")
+			return proto.NewError("ERR wrong number of arguments for 'hrandfield' command")
 		}
 		key := string(args[0])
 		count := 1
@@ -2184,7 +2360,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 				// 是 count
 				c, err := strconv.Atoi(string(args[1]))
 				if err != nil {
-					return proto.NewError("ERR value is not an integer or out of range")
+					return proto.NewError(errNotInteger)
 				}
 				count = c
 			}
@@ -2219,7 +2395,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	// Set命令
 	case "SADD":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'SADD' command")
+			return proto.NewError("ERR wrong number of arguments for 'sadd' command")
 		}
 		key := string(args[0])
 		members := make([]string, len(args)-1)
@@ -2235,7 +2411,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "SREM":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'SREM' command")
+			return proto.NewError("ERR wrong number of arguments for 'srem' command")
 		}
 		key := string(args[0])
 		members := make([]string, len(args)-1)
@@ -2251,7 +2427,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "SCARD":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'SCARD' command")
+			return proto.NewError("ERR wrong number of arguments for 'scard' command")
 		}
 		key := string(args[0])
 		count, err := h.Db.SCard(key)
@@ -2263,7 +2439,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "SISMEMBER":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'SISMEMBER' command")
+			return proto.NewError("ERR wrong number of arguments for 'sismember' command")
 		}
 		key, member := string(args[0]), string(args[1])
 		exists, err := h.Db.SIsMember(key, member)
@@ -2274,7 +2450,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "SMEMBERS":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'SMEMBERS' command")
+			return proto.NewError("ERR wrong number of arguments for 'smembers' command")
 		}
 		key := string(args[0])
 		members, err := h.Db.SMembers(key)
@@ -2289,14 +2465,33 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "SPOP":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'SPOP' command")
+			return proto.NewError("ERR wrong number of arguments for 'spop' command")
 		}
 		key := string(args[0])
-		member, err := h.Db.SPop(key)
-		if err != nil || member == "" {
-			return proto.NewBulkString(nil)
+		if len(args) == 1 {
+			member, err := h.Db.SPop(key)
+			if err != nil || member == "" {
+				return proto.NewBulkString(nil)
+			}
+			return proto.NewBulkString([]byte(member))
+		}
+		// SPOP key count - 返回数组，键不存在时为空数组
+		count, err := strconv.Atoi(string(args[1]))
+		if err != nil {
+			return proto.NewError(errNotInteger)
+		}
+		if count < 0 {
+			return proto.NewError("ERR value is out of range, must be positive")
+		}
+		members, err := h.Db.SPopN(key, count)
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		results := make([][]byte, len(members))
+		for i, m := range members {
+			results[i] = []byte(m)
 		}
-		return proto.NewBulkString([]byte(member))
+		return &proto.Array{Args: results}
 
 	case "SRANDMEMBER":
 		key := string(args[0])
@@ -2311,7 +2506,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		// SRANDMEMBER key count - return array of members
 		count, err := strconv.Atoi(string(args[1]))
 		if err != nil {
-			return proto.NewError("ERR value is not an integer")
+			return proto.NewError(errNotInteger)
 		}
 		members, err := h.Db.SRandMemberN(key, count)
 		if err != nil {
@@ -2325,7 +2520,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "SMOVE":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'SMOVE' command")
+			return proto.NewError("ERR wrong number of arguments for 'smove' command")
 		}
 		source, destination, member := string(args[0]), string(args[1]), string(args[2])
 		success, err := h.Db.SMove(source, destination, member)
@@ -2336,7 +2531,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "SINTER":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'SINTER' command")
+			return proto.NewError("ERR wrong number of arguments for 'sinter' command")
 		}
 		keys := make([]string, len(args))
 		for i, arg := range args {
@@ -2354,7 +2549,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "SUNION":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'SUNION' command")
+			return proto.NewError("ERR wrong number of arguments for 'sunion' command")
 		}
 		keys := make([]string, len(args))
 		for i, arg := range args {
@@ -2372,7 +2567,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "SDIFF":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'SDIFF' command")
+			return proto.NewError("ERR wrong number of arguments for 'sdiff' command")
 		}
 		keys := make([]string, len(args))
 		for i, arg := range args {
@@ -2390,7 +2585,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "SINTERSTORE":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'SINTERSTORE' command")
+			return proto.NewError("ERR wrong number of arguments for 'sinterstore' command")
 		}
 		destination := string(args[0])
 		keys := make([]string, len(args)-1)
@@ -2406,7 +2601,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "SMISMEMBER":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'SMISMEMBER' command")
+			return proto.NewError("ERR wrong number of arguments for 'smismember' command")
 		}
 		key := string(args[0])
 		members := make([]string, len(args)-1)
@@ -2417,22 +2612,41 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
-		// 转换为响应数组
-		resp := make([][]byte, len(results))
+		// 转换为整数数组
+		resp := make([]proto.RESP, len(results))
 		for i, v := range results {
-			resp[i] = []byte(strconv.FormatInt(v, 10))
+			resp[i] = proto.NewInteger(v)
 		}
-		return &proto.Array{Args: resp}
+		return &proto.NestedArray{Elems: resp}
 
 	case "SINTERCARD":
-		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'SINTERCARD' command")
+		// SINTERCARD numkeys key [key ...] [LIMIT limit]
+		if len(args) < 2 {
+			return proto.NewError("ERR wrong number of arguments for 'sintercard' command")
 		}
-		sinterKeys := make([]string, len(args))
-		for i, arg := range args {
-			sinterKeys[i] = string(arg)
+		numKeys, err := strconv.Atoi(string(args[0]))
+		if err != nil || numKeys <= 0 {
+			return proto.NewError("ERR numkeys should be greater than 0")
+		}
+		if numKeys > len(args)-1 {
+			return proto.NewError("ERR Number of keys can't be greater than number of args")
+		}
+		sinterKeys := make([]string, numKeys)
+		for i := range sinterKeys {
+			sinterKeys[i] = string(args[i+1])
+		}
+		// LIMIT 0 表示不限制
+		limit := int64(0)
+		for i := numKeys + 1; i < len(args); i += 2 {
+			if !strings.EqualFold(string(args[i]), "LIMIT") || i+1 >= len(args) {
+				return proto.NewError(errSyntax)
+			}
+			limit, err = strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil || limit < 0 {
+				return proto.NewError("ERR LIMIT can't be negative")
+			}
 		}
-		count, err := h.Db.SInterCard(sinterKeys...)
+		count, err := h.Db.SInterCard(limit, sinterKeys...)
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
@@ -2440,7 +2654,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "SUNIONSTORE":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'SUNIONSTORE' command")
+			return proto.NewError("ERR wrong number of arguments for 'sunionstore' command")
 		}
 		destination := string(args[0])
 		keys := make([]string, len(args)-1)
@@ -2456,7 +2670,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "SDIFFSTORE":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'SDIFFSTORE' command")
+			return proto.NewError("ERR wrong number of arguments for 'sdiffstore' command")
 		}
 		destination := string(args[0])
 		keys := make([]string, len(args)-1)
@@ -2470,77 +2684,44 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		// #nosec G115 - count is bounded by practical data size limits
 		return proto.NewInteger(int64(count))
 
-	case "SSCAN":
-		// SSCAN key cursor [MATCH pattern] [COUNT count]
-		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'SSCAN' command")
+	// SortedSet命令 - 由于代码太长，这里只实现主要命令
+	case "ZADD":
+		if len(args) < 3 {
+			return proto.NewError("ERR wrong number of arguments for 'zadd' command")
 		}
 		key := string(args[0])
-		cursor, err := strconv.ParseUint(string(args[1]), 10, 64)
-		if err != nil {
-			return proto.NewError("ERR value is not an integer")
+		opts, members, errResp := parseZAddArgs(args[1:])
+		if errResp != nil {
+			return errResp
 		}
-		pattern := ""
-		count := 10
-		// Parse optional MATCH and COUNT
-		if len(args) > 2 {
-			for i := 2; i < len(args); i++ {
-				opt := strings.ToUpper(string(args[i]))
-				if opt == "MATCH" && i+1 < len(args) {
-					pattern = string(args[i+1])
-					i++
-				} else if opt == "COUNT" && i+1 < len(args) {
-					count, err = strconv.Atoi(string(args[i+1]))
-					if err != nil {
-						return proto.NewError("ERR value is not an integer")
-					}
-					i++
-				}
-			}
+		result, err := h.Db.ZAddWithOptions(key, members, opts)
+		if errors.Is(err, store.ErrZSetScoreNaN) {
+			return proto.NewError(err.Error())
 		}
-		result, err := h.Db.SScan(key, cursor, pattern, count)
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
-		// 返回格式: [cursor, [member1, member2, ...]]
-		response := make([][]byte, 0, 2+len(result.Members))
-		response = append(response, []byte(strconv.FormatUint(result.Cursor, 10)))
-		for _, m := range result.Members {
-			response = append(response, []byte(m))
-		}
-		return &proto.Array{Args: response}
-
-	// SortedSet命令 - 由于代码太长，这里只实现主要命令
-	case "ZADD":
-		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'ZADD' command")
-		}
-		key := string(args[0])
-		members := make([]store.ZSetMember, 0)
-		for i := 1; i < len(args); i += 2 {
-			if i+1 >= len(args) {
-				break
-			}
-			score, err := strconv.ParseFloat(string(args[i]), 64)
-			if err != nil {
-				return proto.NewError("ERR value is not a valid float")
+		if opts.Incr {
+			// NX/XX/GT/LT 条件不满足时返回 nil
+			if !result.Applied {
+				return proto.NewBulkString(nil)
 			}
-			member := string(args[i+1])
-			members = append(members, store.ZSetMember{Member: member, Score: score})
-		}
-		if err := h.Db.ZAdd(key, members); err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
+			return proto.NewBulkString([]byte(fmt.Sprintf("%.10g", result.Score)))
 		}
-		return proto.NewInteger(int64(len(members)))
+		return proto.NewInteger(result.Count)
 
 	case "ZREM":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'ZREM' command")
+			return proto.NewError("ERR wrong number of arguments for 'zrem' command")
 		}
 		key := string(args[0])
 		count := 0
 		for i := 1; i < len(args); i++ {
 			member := string(args[i])
+			// ZRem 对不存在的成员不报错，先确认成员存在再计数
+			if _, exists, _ := h.Db.ZScore(key, member); !exists {
+				continue
+			}
 			if err := h.Db.ZRem(key, member); err == nil {
 				count++
 			}
@@ -2550,7 +2731,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "ZCARD":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'ZCARD' command")
+			return proto.NewError("ERR wrong number of arguments for 'zcard' command")
 		}
 		key := string(args[0])
 		count, err := h.Db.ZCard(key)
@@ -2561,7 +2742,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "ZSCORE":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'ZSCORE' command")
+			return proto.NewError("ERR wrong number of arguments for 'zscore' command")
 		}
 		key, member := string(args[0]), string(args[1])
 		score, exists, err := h.Db.ZScore(key, member)
@@ -2572,7 +2753,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "ZMSCORE":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'ZMSCORE' command")
+			return proto.NewError("ERR wrong number of arguments for 'zmscore' command")
 		}
 		key := string(args[0])
 		members := make([]string, len(args)-1)
@@ -2592,13 +2773,13 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "ZRANGE":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'ZRANGE' command")
+			return proto.NewError("ERR wrong number of arguments for 'zrange' command")
 		}
 		key := string(args[0])
 		start, err1 := strconv.ParseInt(string(args[1]), 10, 64)
 		stop, err2 := strconv.ParseInt(string(args[2]), 10, 64)
 		if err1 != nil || err2 != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
 		// 检查是否有 WITHSCORES 选项
 		withScores := false
@@ -2625,13 +2806,13 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "ZREVRANGE":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'ZREVRANGE' command")
+			return proto.NewError("ERR wrong number of arguments for 'zrevrange' command")
 		}
 		key := string(args[0])
 		start, err1 := strconv.ParseInt(string(args[1]), 10, 64)
 		stop, err2 := strconv.ParseInt(string(args[2]), 10, 64)
 		if err1 != nil || err2 != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
 		// 检查是否有 WITHSCORES 选项
 		withScores := false
@@ -2659,7 +2840,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	case "ZRANGEBYSCORE":
 		// ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'ZRANGEBYSCORE' command")
+			return proto.NewError("ERR wrong number of arguments for 'zrangebyscore' command")
 		}
 		key := string(args[0])
 		minStr := string(args[1])
@@ -2668,11 +2849,11 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		// 解析分数范围（包含排除标志）
 		minScore, minExclusive, err := parseScoreExclusive(minStr)
 		if err != nil {
-			return proto.NewError("ERR min or max is not a float")
+			return proto.NewError(errScoreNotFloat)
 		}
 		maxScore, maxExclusive, err := parseScoreExclusive(maxStr)
 		if err != nil {
-			return proto.NewError("ERR min or max is not a float")
+			return proto.NewError(errScoreNotFloat)
 		}
 
 		// 解析可选参数
@@ -2688,7 +2869,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 				withScores = true
 			} else if arg == "LIMIT" {
 				if i+1 >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				offset, err = strconv.Atoi(string(args[i]))
 				i++
@@ -2723,7 +2904,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	case "ZREVRANGEBYSCORE":
 		// ZREVRANGEBYSCORE key max min [WITHSCORES] [LIMIT offset count]
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'ZREVRANGEBYSCORE' command")
+			return proto.NewError("ERR wrong number of arguments for 'zrevrangebyscore' command")
 		}
 		key := string(args[0])
 		maxStr := string(args[1])
@@ -2732,11 +2913,11 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		// 解析分数范围（包含排除标志）
 		maxScore, maxExclusive, err := parseScoreExclusive(maxStr)
 		if err != nil {
-			return proto.NewError("ERR min or max is not a float")
+			return proto.NewError(errScoreNotFloat)
 		}
 		minScore, minExclusive, err := parseScoreExclusive(minStr)
 		if err != nil {
-			return proto.NewError("ERR min or max is not a float")
+			return proto.NewError(errScoreNotFloat)
 		}
 
 		// 解析可选参数
@@ -2752,7 +2933,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 				withScores = true
 			} else if arg == "LIMIT" {
 				if i+1 >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				offset, err = strconv.Atoi(string(args[i]))
 				i++
@@ -2786,52 +2967,121 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "ZRANK":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'ZRANK' command")
+			return proto.NewError("ERR wrong number of arguments for 'zrank' command")
 		}
 		key, member := string(args[0]), string(args[1])
 		rank, err := h.Db.ZRank(key, member)
-		if err != nil {
+		if err != nil || rank < 0 {
 			return proto.NewBulkString(nil)
 		}
 		return proto.NewInteger(rank)
 
 	case "ZREVRANK":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'ZREVRANK' command")
+			return proto.NewError("ERR wrong number of arguments for 'zrevrank' command")
 		}
 		key, member := string(args[0]), string(args[1])
 		rank, err := h.Db.ZRevRank(key, member)
-		if err != nil {
+		if err != nil || rank < 0 {
 			return proto.NewBulkString(nil)
 		}
 		return proto.NewInteger(rank)
 
+	case "BOLTREON.ZWATCH":
+		// BOLTREON.ZWATCH key topN channel [REV]
+		// 成员进入、离开前 N 名或在前 N 名内名次变化时向 channel 发布 JSON 事件
+		if len(args) != 3 && len(args) != 4 {
+			return proto.NewError("ERR wrong number of arguments for 'boltreon.zwatch' command")
+		}
+		topN, err := strconv.Atoi(string(args[1]))
+		if err != nil || topN <= 0 {
+			return proto.NewError("ERR top-N must be a positive integer")
+		}
+		config := store.ZWatchConfig{TopN: topN, Channel: string(args[2])}
+		if len(args) == 4 {
+			if !strings.EqualFold(string(args[3]), "REV") {
+				return proto.NewError(errSyntax)
+			}
+			config.Rev = true
+		}
+		if err := h.Db.ZWatch(string(args[0]), config); err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		return proto.OK
+
+	case "BOLTREON.ZUNWATCH":
+		if len(args) != 1 {
+			return proto.NewError("ERR wrong number of arguments for 'boltreon.zunwatch' command")
+		}
+		removed, err := h.Db.ZUnwatch(string(args[0]))
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		if removed {
+			return proto.NewInteger(1)
+		}
+		return proto.NewInteger(0)
+
+	case "BOLTREON.ZMERGE":
+		// BOLTREON.ZMERGE destination source [MAX|MIN]
+		// 将 source 合并到 destination，同一成员保留较大（MIN 时较小）的分数，返回新增或更新的成员数
+		if len(args) > 3 {
+			return proto.NewError(errSyntax)
+		}
+		source := string(args[1])
+		if keyType, err := h.Db.Type(source); err == nil && keyType != "none" && keyType != "zset" {
+			return proto.NewError(wrongTypeErrorMessage)
+		}
+		useMin := len(args) == 3 && strings.EqualFold(string(args[2]), "MIN")
+		changed, err := h.Db.ZMerge(string(args[0]), source, useMin)
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		return proto.NewInteger(changed)
+
 	case "ZCOUNT":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'ZCOUNT' command")
+			return proto.NewError("ERR wrong number of arguments for 'zcount' command")
 		}
 		key := string(args[0])
-		min, err1 := strconv.ParseFloat(string(args[1]), 64)
-		max, err2 := strconv.ParseFloat(string(args[2]), 64)
+		min, minExclusive, err1 := parseScoreExclusive(string(args[1]))
+		max, maxExclusive, err2 := parseScoreExclusive(string(args[2]))
 		if err1 != nil || err2 != nil {
-			return proto.NewError("ERR value is not a valid float")
+			return proto.NewError(errScoreNotFloat)
 		}
 		count, err := h.Db.ZCount(key, min, max)
 		if err != nil {
 			return proto.NewInteger(0)
 		}
+		// 排除边界：减去分数恰好等于边界的成员
+		if minExclusive && !math.IsInf(min, 0) {
+			if n, err := h.Db.ZCount(key, min, min); err == nil {
+				count -= n
+			}
+		}
+		if maxExclusive && !math.IsInf(max, 0) && (max != min || !minExclusive) {
+			if n, err := h.Db.ZCount(key, max, max); err == nil {
+				count -= n
+			}
+		}
+		if count < 0 {
+			count = 0
+		}
 		return proto.NewInteger(count)
 
 	case "ZINCRBY":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'ZINCRBY' command")
+			return proto.NewError("ERR wrong number of arguments for 'zincrby' command")
 		}
 		key, member := string(args[0]), string(args[2])
 		increment, err := strconv.ParseFloat(string(args[1]), 64)
 		if err != nil {
-			return proto.NewError("ERR value is not a valid float")
+			return proto.NewError(errNotFloat)
 		}
 		score, err := h.Db.ZIncrBy(key, member, increment)
+		if errors.Is(err, store.ErrZSetScoreNaN) {
+			return proto.NewError(err.Error())
+		}
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
@@ -2839,16 +3089,16 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "ZREMRANGEBYRANK":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'ZREMRANGEBYRANK' command")
+			return proto.NewError("ERR wrong number of arguments for 'zremrangebyrank' command")
 		}
 		key := string(args[0])
 		start, err := strconv.ParseInt(string(args[1]), 10, 64)
 		if err != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
 		stop, err := strconv.ParseInt(string(args[2]), 10, 64)
 		if err != nil {
-			return proto.NewError("ERR value is not an integer or out of range")
+			return proto.NewError(errNotInteger)
 		}
 		count, err := h.Db.ZRemRangeByRank(key, start, stop)
 		if err != nil {
@@ -2858,16 +3108,16 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "ZREMRANGEBYSCORE":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'ZREMRANGEBYSCORE' command")
+			return proto.NewError("ERR wrong number of arguments for 'zremrangebyscore' command")
 		}
 		key := string(args[0])
 		min, minExclusive, err := parseScoreExclusive(string(args[1]))
 		if err != nil {
-			return proto.NewError("ERR value is not a valid float")
+			return proto.NewError(errNotFloat)
 		}
 		max, maxExclusive, err := parseScoreExclusive(string(args[2]))
 		if err != nil {
-			return proto.NewError("ERR value is not a valid float")
+			return proto.NewError(errNotFloat)
 		}
 		count, err := h.Db.ZRemRangeByScore(key, min, max, minExclusive, maxExclusive)
 		if err != nil {
@@ -2877,14 +3127,14 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "ZPOPMAX":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'ZPOPMAX' command")
+			return proto.NewError("ERR wrong number of arguments for 'zpopmax' command")
 		}
 		key := string(args[0])
 		count := 1
 		if len(args) >= 2 {
 			c, err := strconv.Atoi(string(args[1]))
 			if err != nil {
-				return proto.NewError("ERR value is not an integer")
+				return proto.NewError(errNotInteger)
 			}
 			count = c
 		}
@@ -2901,14 +3151,14 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "ZPOPMIN":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'ZPOPMIN' command")
+			return proto.NewError("ERR wrong number of arguments for 'zpopmin' command")
 		}
 		key := string(args[0])
 		count := 1
 		if len(args) >= 2 {
 			c, err := strconv.Atoi(string(args[1]))
 			if err != nil {
-				return proto.NewError("ERR value is not an integer")
+				return proto.NewError(errNotInteger)
 			}
 			count = c
 		}
@@ -2923,51 +3173,76 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		}
 		return &proto.Array{Args: result}
 
-	case "BZPOPMAX":
+	case "BZPOPMAX", "BZPOPMIN":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'BZPOPMAX' command")
+			return proto.NewError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd)))
 		}
 		keys := make([]string, len(args)-1)
 		for i := 0; i < len(args)-1; i++ {
 			keys[i] = string(args[i])
 		}
-		timeout, err := strconv.Atoi(string(args[len(args)-1]))
-		if err != nil {
-			return proto.NewError("ERR timeout is not an integer or out of range")
+		timeout, errResp := parseBlockingTimeout(args[len(args)-1])
+		if errResp != nil {
+			return errResp
+		}
+		pop := h.Db.BZPopMin
+		if cmd == "BZPOPMAX" {
+			pop = h.Db.BZPopMax
+		}
+		key, member, err := pop(keys, timeout)
+		if errors.Is(err, store.ErrBlockedClientsLimit) {
+			return proto.NewError(blockedClientsLimitMessage)
 		}
-		key, member, err := h.Db.BZPopMax(keys, timeout)
+		// 超时时与 BZMPOP 一样返回空数组 *-1
 		if err != nil || key == "" {
-			return &proto.Array{Args: [][]byte{}}
+			return proto.RawString("*-1\r\n")
 		}
 		return &proto.Array{Args: [][]byte{[]byte(key), []byte(member.Member), []byte(fmt.Sprintf("%.10g", member.Score))}}
 
-	case "BZPOPMIN":
-		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'BZPOPMIN' command")
+	case "ZMPOP":
+		if len(args) < 3 {
+			return proto.NewError("ERR wrong number of arguments for 'zmpop' command")
 		}
-		keys := make([]string, len(args)-1)
-		for i := 0; i < len(args)-1; i++ {
-			keys[i] = string(args[i])
+		keys, max, count, errResp := parseZMPopArgs(args)
+		if errResp != nil {
+			return errResp
 		}
-		timeout, err := strconv.Atoi(string(args[len(args)-1]))
+		key, members, err := h.Db.ZMPop(keys, max, count)
 		if err != nil {
-			return proto.NewError("ERR timeout is not an integer or out of range")
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
-		key, member, err := h.Db.BZPopMin(keys, timeout)
-		if err != nil || key == "" {
-			return &proto.Array{Args: [][]byte{}}
+		return zmpopReply(key, members)
+
+	case "BZMPOP":
+		if len(args) < 4 {
+			return proto.NewError("ERR wrong number of arguments for 'bzmpop' command")
 		}
-		return &proto.Array{Args: [][]byte{[]byte(key), []byte(member.Member), []byte(fmt.Sprintf("%.10g", member.Score))}}
+		timeout, errResp := parseBlockingTimeout(args[0])
+		if errResp != nil {
+			return errResp
+		}
+		keys, max, count, errResp := parseZMPopArgs(args[1:])
+		if errResp != nil {
+			return errResp
+		}
+		key, members, err := h.Db.BZMPop(keys, max, count, timeout)
+		if errors.Is(err, store.ErrBlockedClientsLimit) {
+			return proto.NewError(blockedClientsLimitMessage)
+		}
+		if err != nil {
+			return zmpopReply("", nil)
+		}
+		return zmpopReply(key, members)
 
 	case "ZUNIONSTORE":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'ZUNIONSTORE' command")
+			return proto.NewError("ERR wrong number of arguments for 'zunionstore' command")
 		}
 		destination := string(args[0])
 		// 解析参数: ZUNIONSTORE destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX]
 		numKeys, err := strconv.Atoi(string(args[1]))
 		if err != nil {
-			return proto.NewError("ERR value is not an integer")
+			return proto.NewError(errNotInteger)
 		}
 		keys := make([]string, numKeys)
 		for i := 0; i < numKeys; i++ {
@@ -2982,7 +3257,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			switch opt {
 			case "WEIGHTS":
 				if i+numKeys >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				weights = make([]float64, numKeys)
 				for j := 0; j < numKeys; j++ {
@@ -2995,11 +3270,11 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 				i += 1 + numKeys
 			case "AGGREGATE":
 				if i+1 >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				aggregate = strings.ToUpper(string(args[i+1]))
 				if aggregate != "SUM" && aggregate != "MIN" && aggregate != "MAX" {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				i += 2
 			default:
@@ -3014,13 +3289,13 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "ZINTERSTORE":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'ZINTERSTORE' command")
+			return proto.NewError("ERR wrong number of arguments for 'zinterstore' command")
 		}
 		destination := string(args[0])
 		// 解析参数
 		numKeys, err := strconv.Atoi(string(args[1]))
 		if err != nil {
-			return proto.NewError("ERR value is not an integer")
+			return proto.NewError(errNotInteger)
 		}
 		keys := make([]string, numKeys)
 		for i := 0; i < numKeys; i++ {
@@ -3035,7 +3310,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			switch opt {
 			case "WEIGHTS":
 				if i+numKeys >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				weights = make([]float64, numKeys)
 				for j := 0; j < numKeys; j++ {
@@ -3048,11 +3323,11 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 				i += 1 + numKeys
 			case "AGGREGATE":
 				if i+1 >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				aggregate = strings.ToUpper(string(args[i+1]))
 				if aggregate != "SUM" && aggregate != "MIN" && aggregate != "MAX" {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				i += 2
 			default:
@@ -3067,15 +3342,15 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "ZDIFFSTORE":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'ZDIFFSTORE' command")
+			return proto.NewError("ERR wrong number of arguments for 'zdiffstore' command")
 		}
 		destination := string(args[0])
 		numKeys, err := strconv.Atoi(string(args[1]))
 		if err != nil {
-			return proto.NewError("ERR value is not an integer")
+			return proto.NewError(errNotInteger)
 		}
 		if numKeys < 1 {
-			return proto.NewError("ERR syntax error")
+			return proto.NewError(errSyntax)
 		}
 		keys := make([]string, numKeys)
 		for i := 0; i < numKeys; i++ {
@@ -3090,7 +3365,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	case "ZLEXCOUNT":
 		// ZLEXCOUNT key min max
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'ZLEXCOUNT' command")
+			return proto.NewError("ERR wrong number of arguments for 'zlexcount' command")
 		}
 		zSetName := string(args[0])
 		min := string(args[1])
@@ -3104,7 +3379,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	case "ZRANGEBYLEX":
 		// ZRANGEBYLEX key min max [LIMIT offset count]
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'ZRANGEBYLEX' command")
+			return proto.NewError("ERR wrong number of arguments for 'zrangebylex' command")
 		}
 		zSetName := string(args[0])
 		min := string(args[1])
@@ -3119,11 +3394,11 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 				if opt == "LIMIT" && i+2 < len(args) {
 					offset, err = strconv.Atoi(string(args[i+1]))
 					if err != nil {
-						return proto.NewError("ERR value is not an integer")
+						return proto.NewError(errNotInteger)
 					}
 					count, err = strconv.Atoi(string(args[i+2]))
 					if err != nil {
-						return proto.NewError("ERR value is not an integer")
+						return proto.NewError(errNotInteger)
 					}
 					i += 2
 				}
@@ -3142,7 +3417,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	case "ZREVRANGEBYLEX":
 		// ZREVRANGEBYLEX key max min [LIMIT offset count]
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'ZREVRANGEBYLEX' command")
+			return proto.NewError("ERR wrong number of arguments for 'zrevrangebylex' command")
 		}
 		zSetName := string(args[0])
 		max := string(args[1])
@@ -3157,11 +3432,11 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 				if opt == "LIMIT" && i+2 < len(args) {
 					offset, err = strconv.Atoi(string(args[i+1]))
 					if err != nil {
-						return proto.NewError("ERR value is not an integer")
+						return proto.NewError(errNotInteger)
 					}
 					count, err = strconv.Atoi(string(args[i+2]))
 					if err != nil {
-						return proto.NewError("ERR value is not an integer")
+						return proto.NewError(errNotInteger)
 					}
 					i += 2
 				}
@@ -3180,7 +3455,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	case "ZREMRANGEBYLEX":
 		// ZREMRANGEBYLEX key min max
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'ZREMRANGEBYLEX' command")
+			return proto.NewError("ERR wrong number of arguments for 'zremrangebylex' command")
 		}
 		zSetName := string(args[0])
 		min := string(args[1])
@@ -3191,51 +3466,6 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		}
 		return proto.NewInteger(removed)
 
-	case "ZSCAN":
-		// ZSCAN key cursor [MATCH pattern] [COUNT count]
-		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'ZSCAN' command")
-		}
-		zSetName := string(args[0])
-		cursor, err := strconv.ParseUint(string(args[1]), 10, 64)
-		if err != nil {
-			return proto.NewError("ERR value is not an integer")
-		}
-		pattern := ""
-		count := 10
-		// Parse optional MATCH and COUNT
-		if len(args) > 2 {
-			for i := 2; i < len(args); i++ {
-				opt := strings.ToUpper(string(args[i]))
-				if opt == "MATCH" && i+1 < len(args) {
-					pattern = string(args[i+1])
-					i++
-				} else if opt == "COUNT" && i+1 < len(args) {
-					count, err = strconv.Atoi(string(args[i+1]))
-					if err != nil {
-						return proto.NewError("ERR value is not an integer")
-					}
-					i++
-				}
-			}
-		}
-		result, err := h.Db.ZScan(zSetName, cursor, pattern, count)
-		if err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
-		}
-		// 返回格式: [cursor, [member1, score1, member2, score2, ...]]
-		membersArray := make([][]byte, len(result.Members)*2)
-		for i, m := range result.Members {
-			membersArray[i*2] = []byte(m.Member)
-			membersArray[i*2+1] = []byte(fmt.Sprintf("%.10g", m.Score))
-		}
-		return &proto.NestedArray{
-			Elems: []proto.RESP{
-				proto.Integer(result.Cursor),
-				&proto.Array{Args: membersArray},
-			},
-		}
-
 	// ASKING 命令（用于集群槽迁移）
 	case "ASKING":
 		// ASKING 命令标记当前连接已发送 ASKING，允许执行针对导入中槽的命令
@@ -3249,7 +3479,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			return proto.NewError("ERR This instance has cluster support disabled")
 		}
 		if len(args) == 0 {
-			return proto.NewError("ERR wrong number of arguments for 'CLUSTER' command")
+			return proto.NewError("ERR wrong number of arguments for 'cluster' command")
 		}
 		clusterCmd := cluster.NewClusterCommands(h.Cluster)
 		subcommandArgs := make([]string, len(args))
@@ -3258,11 +3488,18 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		}
 		result, err := clusterCmd.HandleCommand(subcommandArgs)
 		if err != nil {
+			// 子命令的错误大多已带有 ERR 前缀
+			if msg := err.Error(); strings.HasPrefix(msg, "ERR ") {
+				return proto.NewError(msg)
+			}
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
 		// 根据返回类型转换
 		switch v := result.(type) {
 		case string:
+			if v == "OK" {
+				return proto.OK
+			}
 			// 使用 BulkString 以正确处理多行响应（如 CLUSTER INFO）
 			return proto.NewBulkString([]byte(v))
 		case int64:
@@ -3270,14 +3507,9 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		case []string:
 			// 对于CLUSTER NODES，返回多行字符串
 			return proto.NewBulkString([]byte(strings.Join(v, "\n")))
-		case []interface{}:
-			// 对于CLUSTER SLOTS，返回数组
-			// 简化处理：转换为字符串数组
-			strs := make([][]byte, len(v))
-			for i, item := range v {
-				strs[i] = []byte(fmt.Sprintf("%v", item))
-			}
-			return &proto.Array{Args: strs}
+		case []interface{}, [][]interface{}:
+			// 对于CLUSTER SLOTS/CALLS，返回嵌套数组（槽位和端口为整数）
+			return clusterReplyToRESP(v)
 		default:
 			return proto.NewSimpleString(fmt.Sprintf("%v", v))
 		}
@@ -3285,61 +3517,9 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	// CONFIG 命令（用于 redis-benchmark 兼容性）
 	case "CONFIG":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'CONFIG' command")
-		}
-		subcommand := strings.ToUpper(string(args[0]))
-		switch subcommand {
-		case "GET":
-			// CONFIG GET 返回键值对数组
-			// 格式: [key1, value1, key2, value2, ...]
-			// 返回一些基本配置以兼容 redis-benchmark
-			if len(args) == 1 || (len(args) >= 2 && string(args[1]) == "*") {
-				// CONFIG GET 或 CONFIG GET * - 返回所有配置
-				configs := []string{
-					"save", "",
-					"appendonly", "no",
-					"maxmemory", "0",
-					"maxmemory-policy", "noeviction",
-				}
-				results := make([][]byte, len(configs))
-				for i, cfg := range configs {
-					results[i] = []byte(cfg)
-				}
-				return &proto.Array{Args: results}
-			} else if len(args) >= 2 {
-				// CONFIG GET key - 返回特定配置
-				key := string(args[1])
-				var value string
-				switch strings.ToLower(key) {
-				case "save":
-					value = ""
-				case "appendonly":
-					value = "no"
-				case "maxmemory":
-					value = "0"
-				case "maxmemory-policy":
-					value = "noeviction"
-				default:
-					value = ""
-				}
-				return &proto.Array{Args: [][]byte{[]byte(key), []byte(value)}}
-			} else {
-				return proto.NewError("ERR wrong number of arguments for 'CONFIG GET' command")
-			}
-		case "SET":
-			// CONFIG SET <key> <value>
-			if len(args) < 3 {
-				return proto.NewError("ERR wrong number of arguments for 'CONFIG SET' command")
-			}
-			// 简化实现：仅验证参数存在，返回 OK
-			return proto.OK
-		case "REWRITE":
-			// CONFIG REWRITE - 简化实现，将配置重写到配置文件
-			// 由于 BoltDB 使用动态配置，不写入文件
-			return proto.OK
-		default:
-			return proto.NewError(fmt.Sprintf("ERR unknown subcommand '%s'", subcommand))
+			return proto.NewError("ERR wrong number of arguments for 'config' command")
 		}
+		return h.handleConfig(args)
 
 	// 复制命令
 	case "REPLICAOF", "SLAVEOF":
@@ -3347,7 +3527,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			return proto.NewError("ERR replication not enabled")
 		}
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'REPLICAOF' command")
+			return proto.NewError("ERR wrong number of arguments for 'replicaof' command")
 		}
 		host := string(args[0])
 		port := string(args[1])
@@ -3371,7 +3551,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			return proto.NewError("ERR replication not enabled")
 		}
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'REPLCONF' command")
+			return proto.NewError("ERR wrong number of arguments for 'replconf' command")
 		}
 		subcommand := strings.ToUpper(string(args[0]))
 		switch subcommand {
@@ -3379,8 +3559,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			// REPLCONF listening-port <port>
 			// 记录从节点的监听端口，兼容 redis-sentinel
 			if len(args) >= 2 {
-				port := string(args[1])
-				logger.Logger.Debug().Str("remote_addr", remoteAddr).Str("port", port).Msg("从节点监听端口")
+				port, err := strconv.Atoi(string(args[1]))
+				if err != nil || port <= 0 || port > 65535 {
+					return proto.NewError("ERR invalid listening port")
+				}
+				h.replListeningPort = port
+				logger.Logger.Debug().Str("remote_addr", remoteAddr).Int("port", port).Msg("从节点监听端口")
 			}
 			return proto.OK
 		case "CAPA":
@@ -3395,7 +3579,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			// REPLCONF ACK <offset>
 			// 从节点确认已复制的偏移量，redis-sentinel 依赖此功能
 			if len(args) < 2 {
-				return proto.NewError("ERR wrong number of arguments for 'REPLCONF ACK' command")
+				return proto.NewError("ERR wrong number of arguments for 'replconf|ack' command")
 			}
 			offset, err := strconv.ParseInt(string(args[1]), 10, 64)
 			if err != nil {
@@ -3405,12 +3589,8 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			if h.Replication.IsMaster() {
 				slave := h.Replication.GetSlaveByAddr(remoteAddr)
 				if slave != nil {
-					slave.UpdateReplAck(offset)
-					logger.Logger.Debug().
-						Str("slave_id", slave.ID).
-						Str("remote_addr", remoteAddr).
-						Int64("ack_offset", offset).
-						Msg("更新从节点ACK偏移量")
+					// 同时唤醒等待确认的 WAIT
+					h.Replication.UpdateSlaveAckOffset(slave.ID, offset)
 				}
 			}
 			return proto.OK
@@ -3447,20 +3627,43 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		if h.Backup == nil {
 			return proto.NewError("ERR backup not enabled")
 		}
+		changes := h.root().stats.Changes()
+		start := time.Now()
 		if err := h.Backup.Save(); err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
+			return saveError(err)
 		}
+		h.addLatencySample(latencyEventSnapshot, time.Since(start))
+		h.root().stats.Saved(changes)
 		return proto.OK
 
 	case "BGSAVE":
 		if h.Backup == nil {
 			return proto.NewError("ERR backup not enabled")
 		}
-		if err := h.Backup.BGSave(); err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		changes := h.root().stats.Changes()
+		start := time.Now()
+		err := h.Backup.BGSave(func(err error) {
+			h.addLatencySample(latencyEventSnapshot, time.Since(start))
+			if err == nil {
+				h.root().stats.Saved(changes)
+			}
+		})
+		if err != nil {
+			return saveError(err)
 		}
 		return proto.NewSimpleString("Background saving started")
 
+	case "BGREWRITEAOF":
+		return h.handleBgRewriteAOF()
+
+	case "BOLTREON.COMPACT":
+		// BOLTREON.COMPACT：后台合并 LSM 树并回收 value log，见 value_log_gc.go
+		return h.handleCompact()
+
+	case "BOLTREON.BACKUP":
+		// BOLTREON.BACKUP [FULL|INCREMENTAL|LIST]：Badger 全量与增量备份，见 backup.go
+		return h.handleBackup(args)
+
 	case "LASTSAVE":
 		if h.Backup == nil {
 			return proto.NewError("ERR backup not enabled")
@@ -3469,11 +3672,11 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		return proto.NewInteger(lastSave)
 
 	case "DBSIZE":
-		keys, err := h.Db.Keys("*")
+		n, err := h.Db.DBSize(h.db)
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
-		return proto.NewInteger(int64(len(keys)))
+		return proto.NewInteger(n)
 
 	case "TIME":
 		sec, usec, err := h.Db.Time()
@@ -3486,83 +3689,167 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		}}
 
 	case "FLUSHDB":
-		err := h.Db.FlushDB()
+		// FLUSHDB [ASYNC|SYNC]：只清空当前数据库，FLUSHALL 清空全部数据库
+		if len(args) > 1 {
+			return proto.NewError(errSyntax)
+		}
+		err := h.Db.FlushDatabase(h.db, len(args) == 1 && strings.EqualFold(string(args[0]), "ASYNC"))
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
 		return proto.OK
 
 	case "FLUSHALL":
-		err := h.Db.FlushDB()
+		// FLUSHALL [ASYNC|SYNC]：DropAll 直接删除文件，耗时不随数据量增长，ASYNC 与 SYNC 相同
+		if len(args) > 1 {
+			return proto.NewError(errSyntax)
+		}
+		_, err := h.Db.FlushDBToTrash()
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
 		return proto.OK
 
+	case "UNDELETE":
+		// UNDELETE pattern [REPLACE]：从回收站恢复键，已存在的键不覆盖（REPLACE 时覆盖）
+		if len(args) > 2 {
+			return proto.NewError(errSyntax)
+		}
+		replace := len(args) == 2
+		restored, err := h.Db.Undelete(string(args[0]), replace)
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		return proto.NewInteger(int64(restored))
+
+	case "PURGE":
+		// PURGE [pattern]：永久删除回收站中的键，默认全部
+		if len(args) > 1 {
+			return proto.NewError(errSyntax)
+		}
+		pattern := "*"
+		if len(args) == 1 {
+			pattern = string(args[0])
+		}
+		purged, err := h.Db.PurgeTrash(pattern)
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		return proto.NewInteger(int64(purged))
+
+	case "ANALYZE":
+		// ANALYZE START|STATUS|REPORT|CANCEL：后台扫描键空间，统计大小、成员数、TTL 分布和前缀
+		return h.handleAnalyze(args)
+
+	case "DEBUG":
+		// DEBUG KEYSPACE-LAYOUT|OBJECT|SLEEP|SET-ACTIVE-EXPIRE|STRINGMATCH-LEN|FAULT：调试与测试用的子命令
+		return h.handleDebug(args)
+
+	case "NAMESPACE":
+		// NAMESPACE SET|DEL|LIST|INFO|FLUSH：按键前缀划分的命名空间
+		return h.handleNamespace(args)
+
+	case "BOLTREON.SCHEDULE":
+		// BOLTREON.SCHEDULE <unix_ms> <command> [arg ...] | CANCEL <id> | LIST
+		return h.handleSchedule(args)
+
+	case "BOLTREON.WRITESTATS":
+		// BOLTREON.WRITESTATS [ON|OFF|RESET]：按命令统计的写放大（写入/删除的 Badger 键数与字节数）
+		if len(args) == 1 {
+			switch strings.ToUpper(string(args[0])) {
+			case "ON":
+				h.Db.EnableWriteStats(true)
+			case "OFF":
+				h.Db.EnableWriteStats(false)
+			case "RESET":
+				h.Db.ResetWriteStats()
+			}
+			return proto.OK
+		}
+		var b strings.Builder
+		b.WriteString("# WriteStats\n")
+		b.WriteString(fmt.Sprintf("enabled:%d\n", boolToInt(h.Db.WriteStatsEnabled())))
+		for _, stats := range h.Db.WriteStatsByCommand() {
+			var keysPerCall, bytesPerCall float64
+			if stats.Calls > 0 {
+				keysPerCall = float64(stats.KeysWritten+stats.KeysDeleted) / float64(stats.Calls)
+				bytesPerCall = float64(stats.BytesWritten) / float64(stats.Calls)
+			}
+			b.WriteString(fmt.Sprintf("cmdstat_%s:calls=%d,keys_written=%d,keys_deleted=%d,bytes_written=%d,keys_per_call=%.2f,bytes_per_call=%.2f\n",
+				strings.ToLower(stats.Command), stats.Calls, stats.KeysWritten, stats.KeysDeleted, stats.BytesWritten, keysPerCall, bytesPerCall))
+		}
+		return proto.NewBulkString([]byte(b.String()))
+
+	case "BOLTREON.MIRROR":
+		// BOLTREON.MIRROR [STATUS|PAUSE|RESUME]：写命令镜像到上游 Redis 的状态与暂停/恢复
+		return h.handleMirror(args)
+
+	case "EVAL", "EVALSHA":
+		// EVAL script numkeys [key ...] [arg ...] / EVALSHA sha1 numkeys [key ...] [arg ...]
+		return h.handleEval(cmd, args, remoteAddr)
+
+	case "SCRIPT":
+		// SCRIPT LOAD script | SCRIPT EXISTS sha1 [sha1 ...] | SCRIPT FLUSH [ASYNC|SYNC]
+		return h.handleScript(args)
+
+	case "BOLTREON.SHADOW":
+		// BOLTREON.SHADOW [STATUS|RESET|PERCENT percent]：读命令影子执行的比例与比较结果
+		return h.handleShadow(args)
+
 	case "SELECT":
-		// BoltDB is a single-database implementation
-		// Always return OK regardless of the database number
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'SELECT' command")
+			return proto.NewError("ERR wrong number of arguments for 'select' command")
 		}
-		return proto.OK
+		return h.handleSelect(args)
 
 	case "MOVE":
-		// BoltDB is a single-database implementation
-		// MOVE always returns 0 (key was not moved)
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'MOVE' command")
+			return proto.NewError("ERR wrong number of arguments for 'move' command")
 		}
-		// #nosec G115 - result is always 0 for single-db implementation
-		return proto.NewInteger(0)
+		return h.handleMove(args)
 
 	case "WAIT":
-		// BoltDB does not support replication yet
-		// Return 0 (number of replicas acknowledged)
-		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'WAIT' command")
+		// WAIT numreplicas timeout：阻塞到至少 numreplicas 个从节点确认了本连接最近一次写命令，
+		// 或超时（毫秒，0 表示一直等待），返回已确认的从节点数
+		if len(args) != 2 {
+			return proto.NewError("ERR wrong number of arguments for 'wait' command")
 		}
-		// #nosec G115 - result is always 0 for non-replicated implementation
-		return proto.NewInteger(0)
+		numReplicas, err := strconv.Atoi(string(args[0]))
+		if err != nil {
+			return proto.NewError("ERR value is not an integer or out of range")
+		}
+		timeout, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return proto.NewError("ERR timeout is not an integer or out of range")
+		}
+		if timeout < 0 {
+			return proto.NewError("ERR timeout is negative")
+		}
+		if h.Replication == nil {
+			return proto.NewInteger(0)
+		}
+		if h.Replication.IsSlave() {
+			return proto.NewError("ERR WAIT cannot be used with replica instances")
+		}
+		acked := h.Replication.WaitForAcks(h.replOffset, numReplicas, time.Duration(timeout)*time.Millisecond)
+		return proto.NewInteger(int64(acked))
 
 	case "SLOWLOG":
-		// BoltDB does not implement slow query logging yet
-		// Return empty list for all subcommands
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'SLOWLOG' command")
-		}
-		subCommand := strings.ToUpper(string(args[0]))
-		switch subCommand {
-		case "GET":
-			// Return empty array for GET
-			return &proto.Array{Args: [][]byte{}}
-		case "LEN":
-			// Return 0 (no slowlog entries)
-			return proto.NewInteger(0)
-		case "RESET":
-			// Return OK for RESET
-			return proto.OK
-		case "HELP":
-			return &proto.Array{Args: [][]byte{
-				[]byte("SLOWLOG GET <count> - returns top <count> entries from the slowlog"),
-				[]byte("SLOWLOG LEN - returns the length of the slowlog"),
-				[]byte("SLOWLOG RESET - clears the slowlog"),
-				[]byte("SLOWLOG HELP - shows this help message"),
-			}}
-		default:
-			return proto.NewError("ERR unknown subcommand for 'SLOWLOG'")
+			return proto.NewError("ERR wrong number of arguments for 'slowlog' command")
 		}
+		return h.handleSlowlog(args)
 
 	case "MEMORY":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'MEMORY' command")
+			return proto.NewError("ERR wrong number of arguments for 'memory' command")
 		}
 		subCommand := strings.ToUpper(string(args[0]))
 		switch subCommand {
 		case "USAGE":
 			// MEMORY USAGE key [SAMPLES count]
 			if len(args) < 2 {
-				return proto.NewError("ERR wrong number of arguments for 'MEMORY USAGE' command")
+				return proto.NewError("ERR wrong number of arguments for 'memory|usage' command")
 			}
 			key := string(args[1])
 			// Estimate memory usage - use key type size approximation
@@ -3593,7 +3880,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	// ==================== MODULE ====================
 	case "MODULE":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'MODULE' command")
+			return proto.NewError("ERR wrong number of arguments for 'module' command")
 		}
 		subCommand := strings.ToUpper(string(args[0]))
 		switch subCommand {
@@ -3623,51 +3910,19 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	// ==================== LATENCY ====================
 	case "LATENCY":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'LATENCY' command")
-		}
-		subCmd := strings.ToUpper(string(args[0]))
-		switch subCmd {
-		case "LATEST":
-			// LATENCY LATEST - return latest latency samples
-			return &proto.Array{Args: [][]byte{}}
-		case "RESET":
-			// LATENCY RESET [EVENT ...] - reset latency data
-			return proto.NewInteger(0)
-		case "HELP":
-			return &proto.Array{Args: [][]byte{
-				[]byte("LATENCY LATEST - returns the latest latency samples"),
-				[]byte("LATENCY RESET [EVENT ...] - reset latency data for events"),
-				[]byte("LATENCY DOCTOR - analyzes latency issues"),
-				[]byte("LATENCY HELP - shows this help message"),
-			}}
-		case "DOCTOR":
-			// Return a diagnostic message
-			return &proto.Array{Args: [][]byte{
-				[]byte("Latency doctor report:"),
-				[]byte("- No latency issues detected"),
-				[]byte("- BoltDB uses BadgerDB for disk-based storage"),
-				[]byte("- Expected latency < 5ms for SSD, < 50ms for HDD"),
-			}}
-		default:
-			return proto.NewError("ERR unknown subcommand for 'LATENCY'")
+			return proto.NewError("ERR wrong number of arguments for 'latency' command")
 		}
+		return h.handleLatency(args)
 
 	// ==================== READONLY ====================
-	case "READONLY":
-		// READONLY - enter read-only mode (for replicas in read-write splitting scenarios)
-		// This is primarily used in Redis Cluster for replica nodes
-		return proto.NewSimpleString("OK")
-
-	// ==================== READWRITE ====================
-	case "READWRITE":
-		// READWRITE - exit read-only mode
-		return proto.NewSimpleString("OK")
+	case "READONLY", "READWRITE":
+		return h.handleReadOnly(cmd)
 
 	// ==================== ZRANGESTORE ====================
 	case "ZRANGESTORE":
 		// ZRANGESTORE dstkey srckey min max [BYSCORE | BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
 		if len(args) < 4 {
-			return proto.NewError("ERR wrong number of arguments for 'ZRANGESTORE' command")
+			return proto.NewError("ERR wrong number of arguments for 'zrangestore' command")
 		}
 		dstKey := string(args[0])
 		srcKey := string(args[1])
@@ -3695,7 +3950,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 				i++
 			case "LIMIT":
 				if i+2 >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				offset, err := strconv.ParseInt(string(args[i+1]), 10, 64)
 				if err != nil {
@@ -3786,19 +4041,14 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			}
 		}
 
-		// Delete destination if it exists
-		_, _ = h.Db.Del(dstKey)
-
-		// Add members to destination
-		if len(members) > 0 {
-			err = h.Db.ZAdd(dstKey, members)
-			if err != nil {
-				return proto.NewError(fmt.Sprintf("ERR %v", err))
-			}
+		// Replace destination atomically, deleting it when the range is empty
+		stored, err := h.Db.ZReplace(dstKey, members)
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
 
 		// ZRANGESTORE always returns the count of elements stored
-		return proto.NewInteger(int64(len(members)))
+		return proto.NewInteger(stored)
 
 	// Pub/Sub命令
 	case "PUBLISH":
@@ -3806,7 +4056,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			return proto.NewError("ERR pubsub not enabled")
 		}
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'PUBLISH' command")
+			return proto.NewError("ERR wrong number of arguments for 'publish' command")
 		}
 		channel := string(args[0])
 		message := args[1]
@@ -3814,80 +4064,20 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		// #nosec G115 - count is bounded by practical data size limits
 		return proto.NewInteger(int64(count))
 
-	case "SUBSCRIBE":
-		if h.PubSub == nil {
-			return proto.NewError("ERR pubsub not enabled")
-		}
-		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'SUBSCRIBE' command")
-		}
-		// 简化实现：返回订阅确认
-		channels := make([]string, len(args))
-		for i, arg := range args {
-			channels[i] = string(arg)
-		}
-		// 实际应该创建订阅者并持续发送消息
-		// 这里简化处理
-		return &proto.Array{Args: [][]byte{
-			[]byte("subscribe"),
-			[]byte(channels[0]),
-			[]byte("1"),
-		}}
-
-	case "PSUBSCRIBE":
-		if h.PubSub == nil {
-			return proto.NewError("ERR pubsub not enabled")
-		}
-		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'PSUBSCRIBE' command")
-		}
-		// 简化实现
-		patterns := make([]string, len(args))
-		for i, arg := range args {
-			patterns[i] = string(arg)
-		}
-		return &proto.Array{Args: [][]byte{
-			[]byte("psubscribe"),
-			[]byte(patterns[0]),
-			[]byte("1"),
-		}}
-
-	case "UNSUBSCRIBE":
-		if h.PubSub == nil {
-			return proto.NewError("ERR pubsub not enabled")
-		}
-		// 返回取消订阅确认
-		channel := ""
-		if len(args) >= 1 {
-			channel = string(args[0])
-		}
-		return &proto.Array{Args: [][]byte{
-			[]byte("unsubscribe"),
-			[]byte(channel),
-			[]byte("0"),
-		}}
-
-	case "PUNSUBSCRIBE":
+	case "SPUBLISH":
+		// 分片频道只投递给 SSUBSCRIBE 的订阅者，集群模式下由频道所在槽的节点处理
 		if h.PubSub == nil {
 			return proto.NewError("ERR pubsub not enabled")
 		}
-		// 返回取消模式订阅确认
-		pattern := ""
-		if len(args) >= 1 {
-			pattern = string(args[0])
-		}
-		return &proto.Array{Args: [][]byte{
-			[]byte("punsubscribe"),
-			[]byte(pattern),
-			[]byte("0"),
-		}}
+		count := h.PubSub.SPublish(string(args[0]), args[1])
+		return proto.NewInteger(int64(count))
 
 	case "PUBSUB":
 		if h.PubSub == nil {
 			return proto.NewError("ERR pubsub not enabled")
 		}
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'PUBSUB' command")
+			return proto.NewError("ERR wrong number of arguments for 'pubsub' command")
 		}
 		subcommand := strings.ToUpper(string(args[0]))
 		switch subcommand {
@@ -3904,7 +4094,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			return &proto.Array{Args: results}
 		case "NUMSUB":
 			if len(args) < 2 {
-				return proto.NewError("ERR wrong number of arguments for 'PUBSUB NUMSUB' command")
+				return proto.NewError("ERR wrong number of arguments for 'pubsub|numsub' command")
 			}
 			results := make([][]byte, 0)
 			for i := 1; i < len(args); i++ {
@@ -3916,11 +4106,60 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		case "NUMPAT":
 			count := h.PubSub.GetPatternCount()
 			return proto.NewInteger(int64(count))
+		case "SHARDCHANNELS":
+			pattern := "*"
+			if len(args) >= 2 {
+				pattern = string(args[1])
+			}
+			channels := h.PubSub.GetShardChannels(pattern)
+			results := make([][]byte, len(channels))
+			for i, ch := range channels {
+				results[i] = []byte(ch)
+			}
+			return &proto.Array{Args: results}
+		case "SHARDNUMSUB":
+			elems := make([]proto.RESP, 0, 2*(len(args)-1))
+			for i := 1; i < len(args); i++ {
+				channel := string(args[i])
+				count := h.PubSub.GetShardSubscriberCount(channel)
+				elems = append(elems, proto.NewBulkString([]byte(channel)), proto.NewInteger(int64(count)))
+			}
+			return &proto.NestedArray{Elems: elems}
+		case "STATS":
+			// PUBSUB STATS [pattern] | PUBSUB STATS RESET
+			if len(args) >= 2 && strings.ToUpper(string(args[1])) == "RESET" {
+				h.PubSub.ResetChannelStats()
+				return proto.OK
+			}
+			pattern := "*"
+			if len(args) >= 2 {
+				pattern = string(args[1])
+			}
+			stats := h.PubSub.GetChannelStats(pattern)
+			channels := make([]string, 0, len(stats))
+			for ch := range stats {
+				channels = append(channels, ch)
+			}
+			sort.Strings(channels)
+			elems := make([]proto.RESP, 0, len(channels))
+			for _, ch := range channels {
+				st := stats[ch]
+				elems = append(elems, &proto.NestedArray{Elems: []proto.RESP{
+					proto.NewBulkString([]byte(ch)),
+					proto.NewBulkString([]byte("published")), proto.NewInteger(st.Published),
+					proto.NewBulkString([]byte("delivered")), proto.NewInteger(st.Delivered),
+					proto.NewBulkString([]byte("dropped")), proto.NewInteger(st.Dropped),
+				}})
+			}
+			return &proto.NestedArray{Elems: elems}
 		case "HELP":
 			return &proto.Array{Args: [][]byte{
 				[]byte("PUBSUB CHANNELS [pattern]  -- Return the list of active channels matching a pattern."),
 				[]byte("PUBSUB NUMSUB [channel ...] -- Return the number of subscribers for the specified channels."),
 				[]byte("PUBSUB NUMPAT              -- Return the number of subscriptions to patterns."),
+				[]byte("PUBSUB SHARDCHANNELS [pattern] -- Return the list of active shard channels matching a pattern."),
+				[]byte("PUBSUB SHARDNUMSUB [shardchannel ...] -- Return the number of subscribers for the specified shard channels."),
+				[]byte("PUBSUB STATS [pattern|RESET] -- Return (or reset) per-channel published/delivered/dropped counters."),
 				[]byte("PUBSUB HELP                -- Show helpful text about this subcommand."),
 			}}
 		default:
@@ -3929,85 +4168,63 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	// Transaction commands - 事务命令
 	case "MULTI":
-		// 开始事务
-		if h.transaction != nil && len(h.transaction.Commands) > 0 {
+		// 开始事务，保留之前 WATCH 的键
+		if h.inMulti() {
 			return proto.NewError("ERR MULTI calls can not be nested")
 		}
-		h.transaction = &TransactionState{
-			Commands:   make([]TransactionCommand, 0),
-			WatchKeys:  make(map[string]struct{}),
-			IsWatching: false,
+		if h.transaction == nil {
+			h.transaction = &TransactionState{WatchKeys: make(map[string]store.KeyWatch)}
 		}
+		h.transaction.state = txMulti
 		return proto.NewSimpleString("OK")
 
 	case "EXEC":
 		// 执行事务
-		if h.transaction == nil {
+		if !h.inMulti() {
 			return proto.NewError("ERR EXEC without MULTI")
 		}
-		// 检查WATCH的键是否被修改
-		if h.transaction.IsWatching {
-			for key := range h.transaction.WatchKeys {
-				exists, _ := h.Db.Exists(key)
-				if exists {
-					// 键被修改，事务失败
-					h.transaction = nil
-					return nil // 返回 nil 表示 WATCH 失败
-				}
-			}
-		}
-
-		// 执行所有排队的命令
-		results := make([]proto.RESP, len(h.transaction.Commands))
-		for i, tc := range h.transaction.Commands {
-			results[i] = h.executeQueuedCommand(tc.Command, tc.Args)
-		}
-		h.transaction = nil
-		// 转换为 [][]byte
-		flatArgs := make([][]byte, 0)
-		for _, r := range results {
-			flatArgs = append(flatArgs, []byte(r.String()))
-		}
-		return &proto.Array{Args: flatArgs}
+		return h.execTransaction(remoteAddr)
 
 	case "DISCARD":
-		// 放弃事务
-		if h.transaction == nil {
+		// 放弃事务，同时取消 WATCH
+		if !h.inMulti() {
 			return proto.NewError("ERR DISCARD without MULTI")
 		}
-		h.transaction = nil
+		h.resetTransaction()
 		return proto.NewSimpleString("OK")
 
 	case "WATCH":
 		// 监控键
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'WATCH' command")
+			return proto.NewError("ERR wrong number of arguments for 'watch' command")
 		}
 		// WATCH 只能在事务外使用
-		if h.transaction != nil && len(h.transaction.Commands) > 0 {
+		if h.inMulti() {
 			return proto.NewError("ERR WATCH inside MULTI is not allowed")
 		}
-		// 初始化或重置事务状态用于WATCH
-		h.transaction = &TransactionState{
-			Commands:   make([]TransactionCommand, 0),
-			WatchKeys:  make(map[string]struct{}),
-			IsWatching: true,
+		// 多次 WATCH 的键累加，直到 EXEC/DISCARD/UNWATCH
+		if h.transaction == nil {
+			h.transaction = &TransactionState{WatchKeys: make(map[string]store.KeyWatch)}
 		}
+		h.transaction.IsWatching = true
 		for _, arg := range args {
+			// 重复 WATCH 同一个键保留第一次的版本
 			key := string(arg)
-			h.transaction.WatchKeys[key] = struct{}{}
+			if _, ok := h.transaction.WatchKeys[key]; !ok {
+				h.transaction.WatchKeys[key] = h.Db.WatchKey(key)
+			}
 		}
-		return proto.NewInteger(int64(len(args)))
+		return proto.NewSimpleString("OK")
 
 	case "UNWATCH":
 		// 取消监控所有键
-		h.transaction = nil
+		h.resetTransaction()
 		return proto.NewSimpleString("OK")
 
 	// ==================== GEOADD ====================
 	case "GEOADD":
 		if len(args) < 4 {
-			return proto.NewError("ERR wrong number of arguments for 'GEOADD' command")
+			return proto.NewError("ERR wrong number of arguments for 'geoadd' command")
 		}
 		key := string(args[0])
 		members := make([]store.GeoMember, 0)
@@ -4015,7 +4232,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			lon, err1 := strconv.ParseFloat(string(args[i]), 64)
 			lat, err2 := strconv.ParseFloat(string(args[i+1]), 64)
 			if err1 != nil || err2 != nil {
-				return proto.NewError("ERR value is not a valid float")
+				return proto.NewError(errNotFloat)
 			}
 			members = append(members, store.GeoMember{
 				Lat:    lat,
@@ -4032,7 +4249,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	// ==================== GEOPOS ====================
 	case "GEOPOS":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'GEOPOS' command")
+			return proto.NewError("ERR wrong number of arguments for 'geopos' command")
 		}
 		key := string(args[0])
 		members := make([]string, len(args)-1)
@@ -4056,7 +4273,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	// ==================== GEOHASH ====================
 	case "GEOHASH":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'GEOHASH' command")
+			return proto.NewError("ERR wrong number of arguments for 'geohash' command")
 		}
 		key := string(args[0])
 		members := make([]string, len(args)-1)
@@ -4076,7 +4293,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	// ==================== GEODIST ====================
 	case "GEODIST":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'GEODIST' command")
+			return proto.NewError("ERR wrong number of arguments for 'geodist' command")
 		}
 		key := string(args[0])
 		member1 := string(args[1])
@@ -4093,292 +4310,94 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	// ==================== GEOSEARCH ====================
 	case "GEOSEARCH":
-		if len(args) < 4 {
-			return proto.NewError("ERR wrong number of arguments for 'GEOSEARCH' command")
-		}
-		key := string(args[0])
-		// Parse: FROMMEMBER member [FROMLONLAT lon lat] [BYRADIUS radius unit | BYBOX width height unit] [ASC | DESC] [COUNT count] [WITHCOORD] [WITHDIST] [WITHHASH]
-		var centerLon, centerLat float64
-		var radius float64
-		var unit string
-		var count int = 0
-		var withDist, withHash, withCoord bool
+		return h.handleGeoSearch(args)
 
-		i := 1
-		// Check for FROMMEMBER or FROMLONLAT
-		if strings.ToUpper(string(args[i])) == "FROMMEMBER" {
-			if i+1 >= len(args) {
-				return proto.NewError("ERR syntax error")
-			}
-			member := string(args[i+1])
-			positions, err := h.Db.GeoPos(key, member)
-			if err != nil || len(positions) == 0 || (positions[0][0] == 0 && positions[0][1] == 0) {
-				return proto.NewError("ERR could not decode query zset member")
-			}
-			centerLon = positions[0][1]
-			centerLat = positions[0][0]
-			i += 2
-		} else if strings.ToUpper(string(args[i])) == "FROMLONLAT" {
-			if i+2 >= len(args) {
-				return proto.NewError("ERR syntax error")
-			}
-			var err1, err2 error
-			centerLon, err1 = strconv.ParseFloat(string(args[i+1]), 64)
-			centerLat, err2 = strconv.ParseFloat(string(args[i+2]), 64)
-			if err1 != nil || err2 != nil {
-				return proto.NewError("ERR value is not a valid float")
-			}
-			i += 3
-		} else {
-			return proto.NewError("ERR syntax error")
-		}
+	// ==================== GEOSEARCHSTORE ====================
+	case "GEOSEARCHSTORE":
+		return h.handleGeoSearchStore(args)
 
-		// BYRADIUS or BYBOX
-		if i >= len(args) {
-			return proto.NewError("ERR syntax error")
+	// ==================== 延迟队列 ====================
+	case "QPUSH":
+		// QPUSH key delay-ms payload：delay 毫秒后才能被 QPOP 取出，返回消息 ID
+		delay, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil || delay < 0 {
+			return proto.NewError("ERR delay must be a non-negative integer")
 		}
-		if strings.ToUpper(string(args[i])) == "BYRADIUS" {
-			if i+2 >= len(args) {
-				return proto.NewError("ERR syntax error")
-			}
-			var err error
-			radius, err = strconv.ParseFloat(string(args[i+1]), 64)
-			if err != nil {
-				return proto.NewError("ERR value is not a valid float")
-			}
-			unit = string(args[i+2])
-			i += 3
-		} else if strings.ToUpper(string(args[i])) == "BYBOX" {
-			// Simplified: treat as radius with width
-			if i+2 >= len(args) {
-				return proto.NewError("ERR syntax error")
-			}
-			width, err := strconv.ParseFloat(string(args[i+1]), 64)
-			if err != nil {
-				return proto.NewError("ERR value is not a valid float")
-			}
-			unit = string(args[i+3])
-			radius = width / 2
-			i += 4
-		} else {
-			return proto.NewError("ERR syntax error")
+		id, err := h.Db.QPush(string(args[0]), time.Duration(delay)*time.Millisecond, string(args[2]))
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
+		return proto.NewBulkString([]byte(id))
 
-		// Optional modifiers
-		for i < len(args) {
-			opt := strings.ToUpper(string(args[i]))
-			switch opt {
-			case "ASC", "DESC":
-				i++
-			case "COUNT":
-				if i+1 >= len(args) {
-					return proto.NewError("ERR syntax error")
-				}
-				c, err := strconv.Atoi(string(args[i+1]))
-				if err != nil {
-					return proto.NewError("ERR value is not an integer")
-				}
-				count = c
-				i += 2
-			case "WITHCOORD":
-				withCoord = true
-				i++
-			case "WITHDIST":
-				withDist = true
-				i++
-			case "WITHHASH":
-				withHash = true
-				i++
-			default:
-				return proto.NewError(fmt.Sprintf("ERR syntax error, unknown option %s", opt))
-			}
+	case "QPOP":
+		// QPOP key visibility-timeout-ms：取出一条到期消息，返回 [id, payload, 投递次数]；
+		// 超时未 QACK 的消息会再次投递
+		visibility, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil || visibility <= 0 {
+			return proto.NewError("ERR visibility timeout must be a positive integer")
 		}
-
-		results, err := h.Db.GeoSearch(key, centerLon, centerLat, radius, unit, count, withDist, withHash, withCoord)
+		msg, err := h.Db.QPop(string(args[0]), time.Duration(visibility)*time.Millisecond)
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
-
-		// Format results
-		var response [][]byte
-		for _, r := range results {
-			if withCoord {
-				response = append(response, []byte(r.Member))
-				response = append(response, []byte(fmt.Sprintf("%.6f", r.Lon)))
-				response = append(response, []byte(fmt.Sprintf("%.6f", r.Lat)))
-			} else if withDist && withHash {
-				response = append(response, []byte(r.Member))
-				response = append(response, []byte(fmt.Sprintf("%.4f", r.Dist)))
-				response = append(response, []byte(r.Hash))
-			} else if withDist {
-				response = append(response, []byte(r.Member))
-				response = append(response, []byte(fmt.Sprintf("%.4f", r.Dist)))
-			} else if withHash {
-				response = append(response, []byte(r.Member))
-				response = append(response, []byte(r.Hash))
-			} else {
-				response = append(response, []byte(r.Member))
-			}
+		if msg == nil {
+			return proto.NewBulkString(nil)
 		}
-		return &proto.Array{Args: response}
+		return &proto.NestedArray{Elems: []proto.RESP{
+			proto.NewBulkString([]byte(msg.ID)),
+			proto.NewBulkString([]byte(msg.Payload)),
+			proto.NewInteger(msg.Deliveries),
+		}}
 
-	// ==================== GEOSEARCHSTORE ====================
-	case "GEOSEARCHSTORE":
-		if len(args) < 4 {
-			return proto.NewError("ERR wrong number of arguments for 'GEOSEARCHSTORE' command")
+	case "QACK":
+		// QACK key id [id ...]：确认消息已处理并删除
+		ids := make([]string, len(args)-1)
+		for i, arg := range args[1:] {
+			ids[i] = string(arg)
 		}
-		dstKey := string(args[0])
-		srcKey := string(args[1])
-
-		var centerLon, centerLat float64
-		var radius float64
-		var unit string
-		var count int = 0
-		var storeDist bool
-
-		i := 2
-		// Check for FROMMEMBER or FROMLONLAT
-		if i < len(args) && strings.ToUpper(string(args[i])) == "FROMMEMBER" {
-			if i+1 >= len(args) {
-				return proto.NewError("ERR syntax error")
-			}
-			member := string(args[i+1])
-			positions, err := h.Db.GeoPos(srcKey, member)
-			if err != nil || len(positions) == 0 || (positions[0][0] == 0 && positions[0][1] == 0) {
-				return proto.NewError("ERR could not decode query zset member")
-			}
-			centerLon = positions[0][1]
-			centerLat = positions[0][0]
-			i += 2
-		} else if i < len(args) && strings.ToUpper(string(args[i])) == "FROMLONLAT" {
-			if i+2 >= len(args) {
-				return proto.NewError("ERR syntax error")
-			}
-			var err1, err2 error
-			centerLon, err1 = strconv.ParseFloat(string(args[i+1]), 64)
-			centerLat, err2 = strconv.ParseFloat(string(args[i+2]), 64)
-			if err1 != nil || err2 != nil {
-				return proto.NewError("ERR value is not a valid float")
-			}
-			i += 3
+		acked, err := h.Db.QAck(string(args[0]), ids...)
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
+		return proto.NewInteger(acked)
 
-		// BYRADIUS or BYBOX
-		if i >= len(args) {
-			return proto.NewError("ERR syntax error")
-		}
-		if strings.ToUpper(string(args[i])) == "BYRADIUS" {
-			if i+2 >= len(args) {
-				return proto.NewError("ERR syntax error")
-			}
-			var err error
-			radius, err = strconv.ParseFloat(string(args[i+1]), 64)
-			if err != nil {
-				return proto.NewError("ERR value is not a valid float")
-			}
-			unit = string(args[i+2])
-			i += 3
-		} else {
-			return proto.NewError("ERR syntax error")
-		}
-
-		// Optional modifiers
-		for i < len(args) {
-			opt := strings.ToUpper(string(args[i]))
-			switch opt {
-			case "ASC", "DESC":
-				i++
-			case "COUNT":
-				if i+1 >= len(args) {
-					return proto.NewError("ERR syntax error")
-				}
-				c, err := strconv.Atoi(string(args[i+1]))
-				if err != nil {
-					return proto.NewError("ERR value is not an integer")
-				}
-				count = c
-				i += 2
-			case "STOREDIST":
-				storeDist = true
-				i++
-			default:
-				i++
-			}
-		}
-
-		stored, err := h.Db.GeoSearchStore(dstKey, srcKey, centerLon, centerLat, radius, unit, count, storeDist)
-		if err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
-		}
-		return proto.NewInteger(stored)
-
-	// ==================== XADD ====================
-	case "XADD":
-		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'XADD' command")
+	// ==================== XADD ====================
+	case "XADD":
+		if len(args) < 3 {
+			return proto.NewError("ERR wrong number of arguments for 'xadd' command")
 		}
 		key := string(args[0])
-		var opts store.StreamXAddOptions
-		var id string
-		var fields = make(map[string]string)
-
-		// Parse options
-		i := 1
-		for i < len(args)-2 && string(args[i])[0] == '-' {
-			opt := strings.ToUpper(string(args[i]))
-			switch opt {
-			case "MAXLEN":
-				if i+1 >= len(args)-2 {
-					return proto.NewError("ERR syntax error")
-				}
-				maxlen, err := strconv.ParseInt(string(args[i+1]), 10, 64)
-				if err != nil {
-					return proto.NewError("ERR value is not an integer")
-				}
-				opts.MaxLen = maxlen
-				i += 2
-			case "MINID":
-				if i+1 >= len(args)-2 {
-					return proto.NewError("ERR syntax error")
-				}
-				opts.MinID = string(args[i+1])
-				i += 2
-			default:
-				return proto.NewError(fmt.Sprintf("ERR syntax error, unknown option %s", opt))
-			}
+		opts, i, errResp := parseStreamAddOrTrimArgs(args, true)
+		if errResp != nil {
+			return errResp
 		}
-
-		// ID or field name
-		id = string(args[i])
-		if id == "*" || (len(id) > 0 && id[0] == '-') {
-			// It's the ID (* or an option), skip it
-			i++
-		} else {
-			// It's the ID
-			i++
+		// ID 之后为 field value 对
+		if i >= len(args) || (len(args)-i-1)%2 != 0 || len(args)-i-1 == 0 {
+			return proto.NewError("ERR wrong number of arguments for 'xadd' command")
 		}
-
-		// Remaining args are field-value pairs
-		for i < len(args) {
-			field := string(args[i])
-			if i+1 >= len(args) {
-				return proto.NewError("ERR syntax error")
-			}
-			value := string(args[i+1])
-			fields[field] = value
-			i += 2
+		id := string(args[i])
+		fields := make(map[string]string)
+		for j := i + 1; j < len(args); j += 2 {
+			fields[string(args[j])] = string(args[j+1])
 		}
 
 		resultID, err := h.Db.XAdd(key, opts, id, fields)
 		if err != nil {
+			if strings.HasPrefix(err.Error(), "ERR ") {
+				return proto.NewError(err.Error())
+			}
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
+		if resultID == "" {
+			// NOMKSTREAM 且 Stream 不存在
+			return proto.NewBulkString(nil)
+		}
 		return proto.NewBulkString([]byte(resultID))
 
 	// ==================== XLEN ====================
 	case "XLEN":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'XLEN' command")
+			return proto.NewError("ERR wrong number of arguments for 'xlen' command")
 		}
 		key := string(args[0])
 		length, err := h.Db.XLen(key)
@@ -4390,28 +4409,28 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	// ==================== XREAD ====================
 	case "XREAD":
 		var count int64 = 0
-		var block int64 = 0
+		var block int64 = -1 // 没有 BLOCK 时不阻塞，BLOCK 0 一直等待
 
 		// Parse options
 		i := 0
 		if i < len(args) && strings.ToUpper(string(args[i])) == "COUNT" {
 			if i+1 >= len(args) {
-				return proto.NewError("ERR syntax error")
+				return proto.NewError(errSyntax)
 			}
 			c, err := strconv.ParseInt(string(args[i+1]), 10, 64)
 			if err != nil {
-				return proto.NewError("ERR value is not an integer")
+				return proto.NewError(errNotInteger)
 			}
 			count = c
 			i += 2
 		}
 		if i < len(args) && strings.ToUpper(string(args[i])) == "BLOCK" {
 			if i+1 >= len(args) {
-				return proto.NewError("ERR syntax error")
+				return proto.NewError(errSyntax)
 			}
 			b, err := strconv.ParseInt(string(args[i+1]), 10, 64)
 			if err != nil {
-				return proto.NewError("ERR value is not an integer")
+				return proto.NewError(errNotInteger)
 			}
 			block = b
 			i += 2
@@ -4445,6 +4464,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		}
 
 		results, err := h.Db.XRead(count, block, allArgs...)
+		if errors.Is(err, store.ErrBlockedClientsLimit) {
+			return proto.NewError(blockedClientsLimitMessage)
+		}
+		if errors.Is(err, store.ErrBlockedKeyLimit) {
+			return proto.NewBulkString(nil)
+		}
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
@@ -4475,7 +4500,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	// ==================== XRANGE ====================
 	case "XRANGE":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'XRANGE' command")
+			return proto.NewError("ERR wrong number of arguments for 'xrange' command")
 		}
 		key := string(args[0])
 		start := string(args[1])
@@ -4486,11 +4511,11 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		for i := 3; i < len(args); i++ {
 			if strings.ToUpper(string(args[i])) == "COUNT" {
 				if i+1 >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				c, err := strconv.ParseInt(string(args[i+1]), 10, 64)
 				if err != nil {
-					return proto.NewError("ERR value is not an integer")
+					return proto.NewError(errNotInteger)
 				}
 				count = c
 				break
@@ -4530,7 +4555,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	// ==================== XREVRANGE ====================
 	case "XREVRANGE":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'XREVRANGE' command")
+			return proto.NewError("ERR wrong number of arguments for 'xrevrange' command")
 		}
 		key := string(args[0])
 		start := string(args[1])
@@ -4541,11 +4566,11 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		for i := 3; i < len(args); i++ {
 			if strings.ToUpper(string(args[i])) == "COUNT" {
 				if i+1 >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				c, err := strconv.ParseInt(string(args[i+1]), 10, 64)
 				if err != nil {
-					return proto.NewError("ERR value is not an integer")
+					return proto.NewError(errNotInteger)
 				}
 				count = c
 				break
@@ -4582,7 +4607,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	// ==================== XDEL ====================
 	case "XDEL":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'XDEL' command")
+			return proto.NewError("ERR wrong number of arguments for 'xdel' command")
 		}
 		key := string(args[0])
 		ids := make([]string, len(args)-1)
@@ -4598,7 +4623,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	// ==================== XACK ====================
 	case "XACK":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'XACK' command")
+			return proto.NewError("ERR wrong number of arguments for 'xack' command")
 		}
 		key := string(args[0])
 		group := string(args[1])
@@ -4615,27 +4640,30 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	// ==================== XGROUP ====================
 	case "XGROUP":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'XGROUP' command")
+			return proto.NewError("ERR wrong number of arguments for 'xgroup' command")
 		}
 		subcommand := strings.ToUpper(string(args[0]))
 
 		switch subcommand {
 		case "CREATE":
 			if len(args) < 4 {
-				return proto.NewError("ERR wrong number of arguments for 'XGROUP CREATE' command")
+				return proto.NewError("ERR wrong number of arguments for 'xgroup|create' command")
 			}
 			key := string(args[1])
 			group := string(args[2])
 			startID := string(args[3])
 			// Skip MKSTREAM option for now
 			err := h.Db.XGroupCreate(key, group, startID)
+			if errors.Is(err, store.ErrStreamInvalidID) {
+				return proto.NewError(err.Error())
+			}
 			if err != nil {
 				return proto.NewError(fmt.Sprintf("ERR %v", err))
 			}
 			return proto.OK
 		case "DESTROY":
 			if len(args) < 3 {
-				return proto.NewError("ERR wrong number of arguments for 'XGROUP DESTROY' command")
+				return proto.NewError("ERR wrong number of arguments for 'xgroup|destroy' command")
 			}
 			key := string(args[1])
 			group := string(args[2])
@@ -4646,19 +4674,22 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			return proto.NewInteger(1)
 		case "SETID":
 			if len(args) < 4 {
-				return proto.NewError("ERR wrong number of arguments for 'XGROUP SETID' command")
+				return proto.NewError("ERR wrong number of arguments for 'xgroup|setid' command")
 			}
 			key := string(args[1])
 			group := string(args[2])
 			id := string(args[3])
 			err := h.Db.XGroupSetID(key, group, id)
+			if errors.Is(err, store.ErrStreamInvalidID) {
+				return proto.NewError(err.Error())
+			}
 			if err != nil {
 				return proto.NewError(fmt.Sprintf("ERR %v", err))
 			}
 			return proto.OK
 		case "DELCONSUMER":
 			if len(args) < 4 {
-				return proto.NewError("ERR wrong number of arguments for 'XGROUP DELCONSUMER' command")
+				return proto.NewError("ERR wrong number of arguments for 'xgroup|delconsumer' command")
 			}
 			key := string(args[1])
 			group := string(args[2])
@@ -4669,13 +4700,13 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			}
 			return proto.NewInteger(removed)
 		default:
-			return proto.NewError("ERR syntax error")
+			return proto.NewError(errSyntax)
 		}
 
 	// ==================== XREADGROUP ====================
 	case "XREADGROUP":
 		var count int64 = 0
-		var block int64 = 0
+		var block int64 = -1 // 没有 BLOCK 时不阻塞，BLOCK 0 一直等待
 		var group, consumer string
 
 		// Find GROUP keyword first
@@ -4697,21 +4728,21 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			switch opt {
 			case "COUNT":
 				if i+1 >= groupIdx {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				c, err := strconv.ParseInt(string(args[i+1]), 10, 64)
 				if err != nil {
-					return proto.NewError("ERR value is not an integer")
+					return proto.NewError(errNotInteger)
 				}
 				count = c
 				i += 2
 			case "BLOCK":
 				if i+1 >= groupIdx {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				b, err := strconv.ParseInt(string(args[i+1]), 10, 64)
 				if err != nil {
-					return proto.NewError("ERR value is not an integer")
+					return proto.NewError(errNotInteger)
 				}
 				block = b
 				i += 2
@@ -4722,13 +4753,14 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 		// Parse group and consumer
 		if groupIdx+2 >= len(args) {
-			return proto.NewError("ERR syntax error")
+			return proto.NewError(errSyntax)
 		}
 		group = string(args[groupIdx+1])
 		consumer = string(args[groupIdx+2])
 		i = groupIdx + 3
 
-		// Parse options (COUNT, BLOCK) after group/consumer
+		// Parse options (COUNT, BLOCK, NOACK) after group/consumer
+		noAck := false
 		for i < len(args) {
 			opt := strings.ToUpper(string(args[i]))
 			if opt == "STREAMS" {
@@ -4737,24 +4769,27 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			switch opt {
 			case "COUNT":
 				if i+1 >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				c, err := strconv.ParseInt(string(args[i+1]), 10, 64)
 				if err != nil {
-					return proto.NewError("ERR value is not an integer")
+					return proto.NewError(errNotInteger)
 				}
 				count = c
 				i += 2
 			case "BLOCK":
 				if i+1 >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				b, err := strconv.ParseInt(string(args[i+1]), 10, 64)
 				if err != nil {
-					return proto.NewError("ERR value is not an integer")
+					return proto.NewError(errNotInteger)
 				}
 				block = b
 				i += 2
+			case "NOACK":
+				noAck = true
+				i++
 			default:
 				return proto.NewError(fmt.Sprintf("ERR syntax error, unknown option %s at index %d", opt, i))
 			}
@@ -4769,52 +4804,58 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		// Parse stream IDs
 		remaining := len(args) - i
 		if remaining < 2 || remaining%2 != 0 {
-			return proto.NewError(fmt.Sprintf("ERR syntax error: remaining=%d, i=%d, len(args)=%d", remaining, i, len(args)))
+			return proto.NewError("ERR Unbalanced 'xreadgroup' list of streams: for each stream key an ID or '>' must be specified.")
 		}
 		numStreams := remaining / 2
 		streamKeys := make([]string, numStreams)
 		streamIDs := make([]string, numStreams)
 		for j := 0; j < numStreams; j++ {
-			streamKeys[j] = string(args[i+j*2])
-			streamIDs[j] = string(args[i+j*2+1])
+			streamKeys[j] = string(args[i+j])
+			streamIDs[j] = string(args[i+numStreams+j])
 		}
 
-		results, err := h.Db.XReadGroup(group, consumer, count, block, streamKeys...)
+		results, err := h.Db.XReadGroupStreams(group, consumer,
+			store.XReadGroupOptions{Count: count, NoAck: noAck, Block: block >= 0, Timeout: block}, streamKeys, streamIDs)
+		if errors.Is(err, store.ErrBlockedClientsLimit) {
+			return proto.NewError(blockedClientsLimitMessage)
+		}
+		if errors.Is(err, store.ErrBlockedKeyLimit) {
+			return proto.NewBulkString(nil)
+		}
 		if err != nil {
+			if errors.Is(err, store.ErrStreamNoGroup) || errors.Is(err, store.ErrStreamInvalidID) {
+				return proto.NewError(err.Error())
+			}
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
 
-		// Format response - XREADGROUP returns [[stream, [[entry1], [entry2], ...]], ...]
-		// Test expects format: [streamKey, [[entries]]]
-		// This produces nested arrays: [key, [entries]]
+		// XREADGROUP returns [[stream, [[id, [field, value, ...]], ...]], ...]；
+		// 读取历史时已被删除的条目返回 [id, nil]
 		var response []proto.RESP
-		for _, streamMap := range results {
-			for streamKey, entries := range streamMap {
-				// Build entries array for this stream
-				var entryArrayElems []proto.RESP
-				for _, entry := range entries {
-					// Build field array for this entry: [id, [field1, value1, ...]]
-					var fieldElems []proto.RESP
-					for k, v := range entry.Fields {
-						bsK := proto.BulkString(k)
-						bsV := proto.BulkString(v)
-						fieldElems = append(fieldElems, &bsK, &bsV)
-					}
-					// Entry is [id, [fields...]]
-					bsID := proto.BulkString(entry.ID)
+		for _, r := range results {
+			var entryArrayElems []proto.RESP
+			for _, entry := range r.Entries {
+				bsID := proto.BulkString(entry.ID)
+				if entry.Fields == nil {
 					entryArrayElems = append(entryArrayElems, &proto.NestedArray{
-						Elems: []proto.RESP{&bsID, &proto.NestedArray{Elems: fieldElems}},
+						Elems: []proto.RESP{&bsID, proto.RawString("*-1\r\n")},
 					})
+					continue
+				}
+				var fieldElems []proto.RESP
+				for k, v := range entry.Fields {
+					bsK := proto.BulkString(k)
+					bsV := proto.BulkString(v)
+					fieldElems = append(fieldElems, &bsK, &bsV)
 				}
-				// Stream result is [streamKey, entriesArray]
-				bsKey := proto.BulkString(streamKey)
-				response = append(response, &proto.NestedArray{
-					Elems: []proto.RESP{
-						&bsKey,
-						&proto.NestedArray{Elems: entryArrayElems},
-					},
+				entryArrayElems = append(entryArrayElems, &proto.NestedArray{
+					Elems: []proto.RESP{&bsID, &proto.NestedArray{Elems: fieldElems}},
 				})
 			}
+			bsKey := proto.BulkString(r.Key)
+			response = append(response, &proto.NestedArray{
+				Elems: []proto.RESP{&bsKey, &proto.NestedArray{Elems: entryArrayElems}},
+			})
 		}
 		if len(response) == 0 {
 			return proto.NewBulkString(nil)
@@ -4824,14 +4865,14 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	// ==================== XCLAIM ====================
 	case "XCLAIM":
 		if len(args) < 5 {
-			return proto.NewError("ERR wrong number of arguments for 'XCLAIM' command")
+			return proto.NewError("ERR wrong number of arguments for 'xclaim' command")
 		}
 		key := string(args[0])
 		group := string(args[1])
 		consumer := string(args[2])
 		minIdleTime, err := strconv.ParseInt(string(args[3]), 10, 64)
 		if err != nil {
-			return proto.NewError("ERR value is not an integer")
+			return proto.NewError(errNotInteger)
 		}
 		ids := make([]string, len(args)-4)
 		for i := 4; i < len(args); i++ {
@@ -4851,14 +4892,14 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	// ==================== XAUTOCLAIM ====================
 	case "XAUTOCLAIM":
 		if len(args) < 5 {
-			return proto.NewError("ERR wrong number of arguments for 'XAUTOCLAIM' command")
+			return proto.NewError("ERR wrong number of arguments for 'xautoclaim' command")
 		}
 		key := string(args[0])
 		group := string(args[1])
 		consumer := string(args[2])
 		minIdleTime, err := strconv.ParseInt(string(args[3]), 10, 64)
 		if err != nil {
-			return proto.NewError("ERR value is not an integer")
+			return proto.NewError(errNotInteger)
 		}
 		start := string(args[4])
 
@@ -4870,11 +4911,14 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			switch opt {
 			case "COUNT":
 				if i+1 >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				count, err := strconv.ParseInt(string(args[i+1]), 10, 64)
 				if err != nil {
-					return proto.NewError("ERR value is not an integer")
+					return proto.NewError(errNotInteger)
+				}
+				if count < 1 {
+					return proto.NewError("ERR COUNT must be > 0")
 				}
 				opts.Count = count
 				i += 2
@@ -4882,86 +4926,86 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 				opts.JustID = true
 				i++
 			default:
-				return proto.NewError("ERR syntax error")
+				return proto.NewError(errSyntax)
 			}
 		}
 
 		result, err := h.Db.XAutoClaim(key, group, consumer, minIdleTime, start, opts)
+		if errors.Is(err, store.ErrStreamInvalidID) {
+			return proto.NewError(err.Error())
+		}
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
 
-		// Build response
-		response := make([][]byte, 0)
-		response = append(response, []byte(result.NextID))
+		// XAUTOCLAIM returns [next-cursor, [[id, [field, value, ...]], ...], [deleted-id, ...]]；
+		// JUSTID 时第二个元素只有认领的 ID
+		var claimed proto.RESP
 		if opts.JustID {
-			for _, id := range result.ClaimedIDs {
-				response = append(response, []byte(id))
+			ids := make([][]byte, len(result.ClaimedIDs))
+			for i, id := range result.ClaimedIDs {
+				ids[i] = []byte(id)
 			}
+			claimed = &proto.Array{Args: ids}
 		} else {
-			for _, id := range result.ClaimedIDs {
-				response = append(response, []byte(id))
-			}
+			entryArrayElems := []proto.RESP{}
 			for _, msg := range result.Messages {
-				entry := [][]byte{[]byte("id"), []byte(msg.ID)}
+				bsID := proto.BulkString(msg.ID)
+				fieldElems := []proto.RESP{}
 				for k, v := range msg.Fields {
-					entry = append(entry, []byte(k), []byte(v))
+					bsK := proto.BulkString(k)
+					bsV := proto.BulkString(v)
+					fieldElems = append(fieldElems, &bsK, &bsV)
 				}
-				response = append(response, entry...)
+				entryArrayElems = append(entryArrayElems, &proto.NestedArray{
+					Elems: []proto.RESP{&bsID, &proto.NestedArray{Elems: fieldElems}},
+				})
 			}
+			claimed = &proto.NestedArray{Elems: entryArrayElems}
 		}
-		return &proto.Array{Args: response}
+		deleted := make([][]byte, len(result.DeletedIDs))
+		for i, id := range result.DeletedIDs {
+			deleted[i] = []byte(id)
+		}
+		return &proto.NestedArray{Elems: []proto.RESP{
+			proto.NewBulkString([]byte(result.NextID)), claimed, &proto.Array{Args: deleted},
+		}}
 
 	// ==================== XPENDING ====================
 	case "XPENDING":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'XPENDING' command")
+			return proto.NewError("ERR wrong number of arguments for 'xpending' command")
 		}
 		key := string(args[0])
 		group := string(args[1])
-		entries, err := h.Db.XPending(key, group)
+		summary, err := h.Db.XPendingSummary(key, group)
 		if err != nil {
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
-		// Redis XPENDING format: [pending_count, min_id, max_id, [[id, consumer, delivery_count, last_delivery_time], ...]]
-		response := make([]proto.RESP, 0)
-
-		// Count pending entries
-		count := len(entries)
-		response = append(response, proto.Integer(count))
-
-		// Find min and max IDs
-		var minID, maxID string
-		for _, e := range entries {
-			if minID == "" || e.ID < minID {
-				minID = e.ID
-			}
-			if maxID == "" || e.ID > maxID {
-				maxID = e.ID
-			}
-		}
-		response = append(response, proto.NewBulkString([]byte(minID)))
-		response = append(response, proto.NewBulkString([]byte(maxID)))
-
-		// Build entries array
-		var entriesArray []proto.RESP
-		for _, e := range entries {
-			entryArray := []proto.RESP{
-				proto.NewBulkString([]byte(e.ID)),
-				proto.NewBulkString([]byte(e.Consumer)),
-				proto.Integer(e.DeliveryCount),
-				proto.Integer(e.LastDelivery),
-			}
-			entriesArray = append(entriesArray, &proto.NestedArray{Elems: entryArray})
+		// Redis XPENDING format: [pending_count, min_id, max_id, [[consumer, count], ...]]，
+		// 没有待确认条目时后三项为 nil
+		if summary.Count == 0 {
+			return &proto.NestedArray{Elems: []proto.RESP{
+				proto.Integer(0), proto.NewBulkString(nil), proto.NewBulkString(nil), proto.RawString("*-1\r\n"),
+			}}
 		}
-		response = append(response, &proto.NestedArray{Elems: entriesArray})
-
-		return &proto.NestedArray{Elems: response}
+		var consumers []proto.RESP
+		for _, c := range summary.Consumers {
+			consumers = append(consumers, &proto.Array{Args: [][]byte{
+				[]byte(c.Name), []byte(strconv.FormatInt(c.Count, 10)),
+			}})
+		}
+		return &proto.NestedArray{Elems: []proto.RESP{
+			proto.Integer(summary.Count),
+			proto.NewBulkString([]byte(summary.MinID)),
+			proto.NewBulkString([]byte(summary.MaxID)),
+			&proto.NestedArray{Elems: consumers},
+		}}
 
 	// ==================== XINFO ====================
 	case "XINFO":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'XINFO' command")
+			return proto.NewError("ERR wrong number of arguments for 'xinfo' command")
 		}
 		subcommand := strings.ToUpper(string(args[0]))
 
@@ -4986,9 +5030,34 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			return &proto.Array{Args: response}
 		case "STREAM":
 			if len(args) < 2 {
-				return proto.NewError("ERR wrong number of arguments for 'XINFO STREAM' command")
+				return proto.NewError("ERR wrong number of arguments for 'xinfo|stream' command")
 			}
 			key := string(args[1])
+			if len(args) > 2 {
+				// XINFO STREAM key FULL [COUNT count]，COUNT 默认 10，0 表示全部
+				if strings.ToUpper(string(args[2])) != "FULL" {
+					return proto.NewError(errSyntax)
+				}
+				count := int64(10)
+				if len(args) > 3 {
+					if len(args) != 5 || strings.ToUpper(string(args[3])) != "COUNT" {
+						return proto.NewError(errSyntax)
+					}
+					n, err := strconv.ParseInt(string(args[4]), 10, 64)
+					if err != nil {
+						return proto.NewError(errNotInteger)
+					}
+					count = max(n, 0)
+				}
+				info, err := h.Db.XInfoFull(key, count)
+				if err != nil {
+					if strings.HasPrefix(err.Error(), "ERR ") {
+						return proto.NewError(err.Error())
+					}
+					return proto.NewError(fmt.Sprintf("ERR %v", err))
+				}
+				return xinfoStreamFullReply(info)
+			}
 			info, err := h.Db.XInfo(key)
 			if err != nil {
 				return proto.NewError(fmt.Sprintf("ERR %v", err))
@@ -5006,7 +5075,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			return &proto.Array{Args: response}
 		case "GROUPS":
 			if len(args) < 2 {
-				return proto.NewError("ERR wrong number of arguments for 'XINFO GROUPS' command")
+				return proto.NewError("ERR wrong number of arguments for 'xinfo|groups' command")
 			}
 			key := string(args[1])
 			groups, err := h.Db.XInfoGroups(key)
@@ -5029,7 +5098,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			return &proto.NestedArray{Elems: response}
 		case "CONSUMERS":
 			if len(args) < 3 {
-				return proto.NewError("ERR wrong number of arguments for 'XINFO CONSUMERS' command")
+				return proto.NewError("ERR wrong number of arguments for 'xinfo|consumers' command")
 			}
 			key := string(args[1])
 			group := string(args[2])
@@ -5046,89 +5115,70 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			}
 			return &proto.Array{Args: response}
 		default:
-			return proto.NewError("ERR syntax error")
+			return proto.NewError(errSyntax)
 		}
 
 	// ==================== XTRIM ====================
 	case "XTRIM":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'XTRIM' command")
+			return proto.NewError("ERR wrong number of arguments for 'xtrim' command")
 		}
 		key := string(args[0])
-		var maxLen int64 = 0
-		var minID string
-		approximate := false
+		opts, i, errResp := parseStreamAddOrTrimArgs(args, false)
+		if errResp != nil {
+			return errResp
+		}
+		if i < len(args) {
+			return proto.NewError(errSyntax)
+		}
+		trimmed, err := h.Db.XTrim(key, opts.Trim)
+		if err != nil {
+			if strings.HasPrefix(err.Error(), "ERR ") {
+				return proto.NewError(err.Error())
+			}
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		return proto.NewInteger(trimmed)
 
-		// Parse options
-		i := 1
-		for i < len(args) {
-			opt := strings.ToUpper(string(args[i]))
-			switch opt {
-			case "MAXLEN":
-				if i+1 >= len(args) {
-					return proto.NewError("ERR syntax error")
-				}
-				// Handle ~ approximation option
-				nextArg := strings.ToUpper(string(args[i+1]))
-				if nextArg == "~" {
-					approximate = true
-					if i+2 >= len(args) {
-						return proto.NewError("ERR syntax error")
-					}
-					maxlen, err := strconv.ParseInt(string(args[i+2]), 10, 64)
-					if err != nil {
-						return proto.NewError("ERR value is not an integer")
-					}
-					maxLen = maxlen
-					i += 3
-				} else {
-					maxlen, err := strconv.ParseInt(string(args[i+1]), 10, 64)
-					if err != nil {
-						return proto.NewError("ERR value is not an integer")
-					}
-					maxLen = maxlen
-					i += 2
-				}
-			case "MINID":
-				if i+1 >= len(args) {
-					return proto.NewError("ERR syntax error")
-				}
-				minID = string(args[i+1])
-				i += 2
-			case "~":
-				// ~ can come before MAXLEN with its value
-				// e.g., XTRIM key ~ count (defaults to MAXLEN)
-				_ = approximate
-				if i+1 >= len(args) {
-					return proto.NewError("ERR syntax error")
-				}
-				maxlen, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+	// ==================== XSETID ====================
+	case "XSETID":
+		if len(args) < 2 {
+			return proto.NewError("ERR wrong number of arguments for 'xsetid' command")
+		}
+		key, id := string(args[0]), string(args[1])
+		opts := store.XSetIDOptions{EntriesAdded: -1}
+		for i := 2; i < len(args); i += 2 {
+			if i+1 >= len(args) {
+				return proto.NewError(errSyntax)
+			}
+			switch strings.ToUpper(string(args[i])) {
+			case "ENTRIESADDED":
+				n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
 				if err != nil {
-					return proto.NewError("ERR value is not an integer")
+					return proto.NewError(errNotInteger)
 				}
-				maxLen = maxlen
-				i += 2
-			default:
-				// Try to parse as a number (shorthand for MAXLEN)
-				if _, err := strconv.ParseInt(opt, 10, 64); err == nil {
-					maxLen, _ = strconv.ParseInt(opt, 10, 64)
-					i++
-				} else {
-					return proto.NewError(fmt.Sprintf("ERR syntax error, unknown option %s", opt))
+				if n < 0 {
+					return proto.NewError("ERR entries_added must be positive")
 				}
+				opts.EntriesAdded = n
+			case "MAXDELETEDID":
+				opts.MaxDeletedID = string(args[i+1])
+			default:
+				return proto.NewError(errSyntax)
 			}
 		}
-
-		trimmed, err := h.Db.XTrim(key, maxLen, minID)
-		if err != nil {
+		if err := h.Db.XSetID(key, id, opts); err != nil {
+			if strings.HasPrefix(err.Error(), "ERR ") {
+				return proto.NewError(err.Error())
+			}
 			return proto.NewError(fmt.Sprintf("ERR %v", err))
 		}
-		return proto.NewInteger(trimmed)
+		return proto.OK
 
 	// ==================== SORT ====================
 	case "SORT":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'SORT' command")
+			return proto.NewError("ERR wrong number of arguments for 'sort' command")
 		}
 		key := string(args[0])
 
@@ -5146,27 +5196,27 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			switch opt {
 			case "BY":
 				if i+1 >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				byPattern = string(args[i+1])
 				i += 2
 			case "LIMIT":
 				if i+2 >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				parseResult, err := strconv.ParseInt(string(args[i+1]), 10, 64)
 				if err != nil {
-					return proto.NewError("ERR value is not an integer")
+					return proto.NewError(errNotInteger)
 				}
 				offset = parseResult
 				count, err = strconv.ParseInt(string(args[i+2]), 10, 64)
 				if err != nil {
-					return proto.NewError("ERR value is not an integer")
+					return proto.NewError(errNotInteger)
 				}
 				i += 3
 			case "GET":
 				if i+1 >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				getPatterns = append(getPatterns, string(args[i+1]))
 				i += 2
@@ -5181,7 +5231,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 				i++
 			case "STORE":
 				if i+1 >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				destKey = string(args[i+1])
 				i += 2
@@ -5358,7 +5408,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 	// ==================== JSON ====================
 	case "JSON.SET":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'JSON.SET' command")
+			return proto.NewError("ERR wrong number of arguments for 'json.set' command")
 		}
 		key, path := string(args[0]), string(args[1])
 		value := string(args[2])
@@ -5374,13 +5424,40 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		}
 		result, err := h.Db.JSONSet(key, path, value, nx, xx)
 		if err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
+			return jsonError(err)
+		}
+		if result == "" {
+			// NX/XX 条件不满足或路径没有匹配
+			return proto.NewBulkString(nil)
 		}
 		return proto.NewSimpleString(result)
 
+	case "JSON.MSET":
+		if len(args) < 3 || len(args)%3 != 0 {
+			return proto.NewError("ERR wrong number of arguments for 'json.mset' command")
+		}
+		n := len(args) / 3
+		keys, paths, values := make([]string, n), make([]string, n), make([]string, n)
+		for i := 0; i < n; i++ {
+			keys[i], paths[i], values[i] = string(args[3*i]), string(args[3*i+1]), string(args[3*i+2])
+		}
+		if err := h.Db.JSONMSet(keys, paths, values); err != nil {
+			return jsonError(err)
+		}
+		return proto.OK
+
+	case "JSON.MERGE":
+		if len(args) != 3 {
+			return proto.NewError("ERR wrong number of arguments for 'json.merge' command")
+		}
+		if err := h.Db.JSONMerge(string(args[0]), string(args[1]), string(args[2])); err != nil {
+			return jsonError(err)
+		}
+		return proto.OK
+
 	case "JSON.GET":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'JSON.GET' command")
+			return proto.NewError("ERR wrong number of arguments for 'json.get' command")
 		}
 		key := string(args[0])
 		paths := make([]string, 0)
@@ -5392,21 +5469,13 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			if errors.Is(err, store.ErrKeyNotFound) {
 				return proto.NewBulkString(nil)
 			}
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
-		}
-		if len(result) == 1 {
-			return proto.NewBulkString([]byte(result[0]))
+			return jsonError(err)
 		}
-		// Multiple paths
-		arr := make([][]byte, len(result))
-		for i, v := range result {
-			arr[i] = []byte(v)
-		}
-		return &proto.Array{Args: arr}
+		return proto.NewBulkString([]byte(result))
 
 	case "JSON.DEL":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'JSON.DEL' command")
+			return proto.NewError("ERR wrong number of arguments for 'json.del' command")
 		}
 		key := string(args[0])
 		paths := make([]string, 0)
@@ -5415,13 +5484,13 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		}
 		count, err := h.Db.JSONDel(key, paths...)
 		if err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
+			return jsonError(err)
 		}
 		return proto.NewInteger(count)
 
 	case "JSON.TYPE":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'JSON.TYPE' command")
+			return proto.NewError("ERR wrong number of arguments for 'json.type' command")
 		}
 		key := string(args[0])
 		path := "$"
@@ -5433,13 +5502,13 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			if errors.Is(err, store.ErrKeyNotFound) {
 				return proto.NewBulkString(nil)
 			}
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
+			return jsonError(err)
 		}
 		return proto.NewBulkString([]byte(result))
 
 	case "JSON.MGET":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'JSON.MGET' command")
+			return proto.NewError("ERR wrong number of arguments for 'json.mget' command")
 		}
 		path := string(args[len(args)-1])
 		keys := make([]string, 0)
@@ -5448,7 +5517,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		}
 		result, err := h.Db.JSONMGet(path, keys...)
 		if err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
+			return jsonError(err)
 		}
 		arr := make([][]byte, len(result))
 		for i, v := range result {
@@ -5462,22 +5531,22 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "JSON.ARRAPPEND":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'JSON.ARRAPPEND' command")
+			return proto.NewError("ERR wrong number of arguments for 'json.arrappend' command")
 		}
 		key, path := string(args[0]), string(args[1])
 		values := make([]string, 0)
 		for i := 2; i < len(args); i++ {
 			values = append(values, string(args[i]))
 		}
-		count, err := h.Db.JSONArrAppend(key, path, values...)
+		counts, err := h.Db.JSONArrAppend(key, path, values...)
 		if err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
+			return jsonError(err)
 		}
-		return proto.NewInteger(count)
+		return jsonIntsReply(path, counts)
 
 	case "JSON.ARRLEN":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'JSON.ARRLEN' command")
+			return proto.NewError("ERR wrong number of arguments for 'json.arrlen' command")
 		}
 		key := string(args[0])
 		path := "$"
@@ -5486,13 +5555,13 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		}
 		count, err := h.Db.JSONArrLen(key, path)
 		if err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
+			return jsonError(err)
 		}
 		return proto.NewInteger(count)
 
 	case "JSON.OBJKEYS":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'JSON.OBJKEYS' command")
+			return proto.NewError("ERR wrong number of arguments for 'json.objkeys' command")
 		}
 		key := string(args[0])
 		path := "$"
@@ -5501,7 +5570,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		}
 		keys, err := h.Db.JSONObjKeys(key, path)
 		if err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
+			return jsonError(err)
 		}
 		arr := make([][]byte, len(keys))
 		for i, k := range keys {
@@ -5511,37 +5580,37 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "JSON.NUMINCRBY":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'JSON.NUMINCRBY' command")
+			return proto.NewError("ERR wrong number of arguments for 'json.numincrby' command")
 		}
 		key, path := string(args[0]), string(args[1])
 		increment, err := strconv.ParseFloat(string(args[2]), 64)
 		if err != nil {
 			return proto.NewError("ERR increment must be a valid number")
 		}
-		result, err := h.Db.JSONNumIncrBy(key, path, increment)
+		results, err := h.Db.JSONNumIncrBy(key, path, increment)
 		if err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
+			return jsonError(err)
 		}
-		return proto.NewBulkString([]byte(strconv.FormatFloat(result, 'f', -1, 64)))
+		return jsonNumbersReply(path, results)
 
 	case "JSON.NUMMULTBY":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'JSON.NUMMULTBY' command")
+			return proto.NewError("ERR wrong number of arguments for 'json.nummultby' command")
 		}
 		key, path := string(args[0]), string(args[1])
 		multiplier, err := strconv.ParseFloat(string(args[2]), 64)
 		if err != nil {
 			return proto.NewError("ERR multiplier must be a valid number")
 		}
-		result, err := h.Db.JSONNumMultBy(key, path, multiplier)
+		results, err := h.Db.JSONNumMultBy(key, path, multiplier)
 		if err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
+			return jsonError(err)
 		}
-		return proto.NewBulkString([]byte(strconv.FormatFloat(result, 'f', -1, 64)))
+		return jsonNumbersReply(path, results)
 
 	case "JSON.CLEAR":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'JSON.CLEAR' command")
+			return proto.NewError("ERR wrong number of arguments for 'json.clear' command")
 		}
 		key := string(args[0])
 		path := "$"
@@ -5550,17 +5619,153 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		}
 		count, err := h.Db.JSONClear(key, path)
 		if err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
+			return jsonError(err)
 		}
 		return proto.NewInteger(count)
 
+	case "JSON.ARRINSERT":
+		if len(args) < 4 {
+			return proto.NewError("ERR wrong number of arguments for 'json.arrinsert' command")
+		}
+		key, path := string(args[0]), string(args[1])
+		index, err := strconv.Atoi(string(args[2]))
+		if err != nil {
+			return proto.NewError(errNotInteger)
+		}
+		values := make([]string, 0, len(args)-3)
+		for i := 3; i < len(args); i++ {
+			values = append(values, string(args[i]))
+		}
+		counts, err := h.Db.JSONArrInsert(key, path, index, values...)
+		if err != nil {
+			return jsonError(err)
+		}
+		return jsonIntsReply(path, counts)
+
+	case "JSON.ARRPOP":
+		if len(args) < 1 || len(args) > 3 {
+			return proto.NewError("ERR wrong number of arguments for 'json.arrpop' command")
+		}
+		key := string(args[0])
+		path := "."
+		if len(args) >= 2 {
+			path = string(args[1])
+		}
+		index := -1
+		if len(args) == 3 {
+			var err error
+			if index, err = strconv.Atoi(string(args[2])); err != nil {
+				return proto.NewError(errNotInteger)
+			}
+		}
+		popped, err := h.Db.JSONArrPop(key, path, index)
+		if err != nil {
+			if errors.Is(err, store.ErrKeyNotFound) {
+				return proto.NewBulkString(nil)
+			}
+			return jsonError(err)
+		}
+		if store.IsLegacyJSONPath(path) {
+			return jsonBulkReply(popped[0])
+		}
+		elems := make([]proto.RESP, len(popped))
+		for i, p := range popped {
+			elems[i] = jsonBulkReply(p)
+		}
+		return &proto.NestedArray{Elems: elems}
+
+	case "JSON.ARRINDEX":
+		if len(args) < 3 || len(args) > 5 {
+			return proto.NewError("ERR wrong number of arguments for 'json.arrindex' command")
+		}
+		key, path, value := string(args[0]), string(args[1]), string(args[2])
+		var start, stop int
+		for i, dst := range []*int{&start, &stop} {
+			if len(args) > 3+i {
+				n, err := strconv.Atoi(string(args[3+i]))
+				if err != nil {
+					return proto.NewError(errNotInteger)
+				}
+				*dst = n
+			}
+		}
+		indexes, err := h.Db.JSONArrIndex(key, path, value, start, stop)
+		if err != nil {
+			return jsonError(err)
+		}
+		return jsonIntsReply(path, indexes)
+
+	case "JSON.ARRTRIM":
+		if len(args) != 4 {
+			return proto.NewError("ERR wrong number of arguments for 'json.arrtrim' command")
+		}
+		key, path := string(args[0]), string(args[1])
+		start, err := strconv.Atoi(string(args[2]))
+		if err != nil {
+			return proto.NewError(errNotInteger)
+		}
+		stop, err := strconv.Atoi(string(args[3]))
+		if err != nil {
+			return proto.NewError(errNotInteger)
+		}
+		counts, err := h.Db.JSONArrTrim(key, path, start, stop)
+		if err != nil {
+			return jsonError(err)
+		}
+		return jsonIntsReply(path, counts)
+
+	case "JSON.STRAPPEND":
+		if len(args) < 2 || len(args) > 3 {
+			return proto.NewError("ERR wrong number of arguments for 'json.strappend' command")
+		}
+		key := string(args[0])
+		path := "."
+		value := string(args[len(args)-1])
+		if len(args) == 3 {
+			path = string(args[1])
+		}
+		counts, err := h.Db.JSONStrAppend(key, path, value)
+		if err != nil {
+			return jsonError(err)
+		}
+		return jsonIntsReply(path, counts)
+
+	case "JSON.STRLEN":
+		if len(args) < 1 || len(args) > 2 {
+			return proto.NewError("ERR wrong number of arguments for 'json.strlen' command")
+		}
+		key := string(args[0])
+		path := "."
+		if len(args) == 2 {
+			path = string(args[1])
+		}
+		counts, err := h.Db.JSONStrLen(key, path)
+		if err != nil {
+			if errors.Is(err, store.ErrKeyNotFound) {
+				return proto.NewBulkString(nil)
+			}
+			return jsonError(err)
+		}
+		return jsonIntsReply(path, counts)
+
+	case "JSON.TOGGLE":
+		if len(args) != 2 {
+			return proto.NewError("ERR wrong number of arguments for 'json.toggle' command")
+		}
+		path := string(args[1])
+		results, err := h.Db.JSONToggle(string(args[0]), path)
+		if err != nil {
+			return jsonError(err)
+		}
+		return jsonIntsReply(path, results)
+
 	case "JSON.DEBUG":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'JSON.DEBUG' command")
+			return proto.NewError("ERR wrong number of arguments for 'json.debug' command")
 		}
 		subCmd := strings.ToUpper(string(args[0]))
 		if subCmd != "MEMORY" {
-			return proto.NewError("ERR syntax error")
+			return proto.NewError(errSyntax)
 		}
 		key := string(args[1])
 		path := "$"
@@ -5572,14 +5777,42 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			if errors.Is(err, store.ErrKeyNotFound) {
 				return proto.NewBulkString(nil)
 			}
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
+			return jsonError(err)
 		}
 		return proto.NewInteger(memory)
 
+	// ==================== Search ====================
+	case "FT.CREATE":
+		if len(args) < 4 {
+			return proto.NewError("ERR wrong number of arguments for 'ft.create' command")
+		}
+		return h.handleFTCreate(args)
+
+	case "FT.SEARCH":
+		if len(args) < 2 {
+			return proto.NewError("ERR wrong number of arguments for 'ft.search' command")
+		}
+		return h.handleFTSearch(args)
+
+	case "FT.DROPINDEX":
+		if len(args) < 1 {
+			return proto.NewError("ERR wrong number of arguments for 'ft.dropindex' command")
+		}
+		return h.handleFTDropIndex(args)
+
+	case "FT.INFO":
+		if len(args) != 1 {
+			return proto.NewError("ERR wrong number of arguments for 'ft.info' command")
+		}
+		return h.handleFTInfo(args)
+
+	case "FT._LIST":
+		return h.handleFTList()
+
 	// ==================== Time Series ====================
 	case "TS.CREATE":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'TS.CREATE' command")
+			return proto.NewError("ERR wrong number of arguments for 'ts.create' command")
 		}
 		key := string(args[0])
 		opts := store.TSCreateOptions{}
@@ -5590,7 +5823,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			case "RETENTION":
 				i++
 				if i >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				retention, err := strconv.ParseInt(string(args[i]), 10, 64)
 				if err != nil {
@@ -5600,13 +5833,13 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 			case "ENCODING":
 				i++
 				if i >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				opts.Encoding = string(args[i])
 			case "DUPLICATE_POLICY":
 				i++
 				if i >= len(args) {
-					return proto.NewError("ERR syntax error")
+					return proto.NewError(errSyntax)
 				}
 				opts.DuplicatePolicy = string(args[i])
 			default:
@@ -5621,12 +5854,12 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "TS.ADD":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'TS.ADD' command")
+			return proto.NewError("ERR wrong number of arguments for 'ts.add' command")
 		}
 		key := string(args[0])
 		var timestamp int64
 		if string(args[1]) == "*" {
-			timestamp = time.Now().UnixNano() / int64(time.Millisecond)
+			timestamp = h.Db.Clock().Now().UnixNano() / int64(time.Millisecond)
 		} else {
 			var err error
 			timestamp, err = strconv.ParseInt(string(args[1]), 10, 64)
@@ -5653,7 +5886,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "TS.GET":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'TS.GET' command")
+			return proto.NewError("ERR wrong number of arguments for 'ts.get' command")
 		}
 		key := string(args[0])
 		dp, err := h.Db.TSGet(key)
@@ -5673,7 +5906,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "TS.RANGE":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'TS.RANGE' command")
+			return proto.NewError("ERR wrong number of arguments for 'ts.range' command")
 		}
 		key := string(args[0])
 		start := string(args[1])
@@ -5702,7 +5935,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "TS.DEL":
 		if len(args) < 3 {
-			return proto.NewError("ERR wrong number of arguments for 'TS.DEL' command")
+			return proto.NewError("ERR wrong number of arguments for 'ts.del' command")
 		}
 		key := string(args[0])
 		start := string(args[1])
@@ -5715,7 +5948,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "TS.INFO":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'TS.INFO' command")
+			return proto.NewError("ERR wrong number of arguments for 'ts.info' command")
 		}
 		key := string(args[0])
 		info, err := h.Db.TSInfo(key)
@@ -5740,7 +5973,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "TS.LEN":
 		if len(args) < 1 {
-			return proto.NewError("ERR wrong number of arguments for 'TS.LEN' command")
+			return proto.NewError("ERR wrong number of arguments for 'ts.len' command")
 		}
 		key := string(args[0])
 		length, err := h.Db.TSLen(key)
@@ -5754,7 +5987,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 
 	case "TS.MGET":
 		if len(args) < 2 {
-			return proto.NewError("ERR wrong number of arguments for 'TS.MGET' command")
+			return proto.NewError("ERR wrong number of arguments for 'ts.mget' command")
 		}
 		filter := string(args[0])
 		keys := make([]string, len(args)-1)
@@ -5778,15 +6011,7 @@ func (h *Handler) executeCommand(cmd string, args [][]byte, remoteAddr string) p
 		return &proto.Array{Args: arr}
 
 	default:
-		// 如果在事务中，将命令加入队列
-		if h.transaction != nil {
-			h.transaction.Commands = append(h.transaction.Commands, TransactionCommand{
-				Command: cmd,
-				Args:    args,
-			})
-			return proto.NewSimpleString("QUEUED")
-		}
-		return proto.NewError(fmt.Sprintf("ERR unknown command '%s'", cmd))
+		return proto.NewError(unknownCommandError(cmd, args))
 	}
 }
 
@@ -5848,312 +6073,3 @@ func parseScoreExclusive(s string) (float64, bool, error) {
 	return val, exclusive, nil
 }
 
-// executeQueuedCommand 执行事务队列中的命令
-func (h *Handler) executeQueuedCommand(cmd string, args [][]byte) proto.RESP {
-	switch cmd {
-	case "SET":
-		key, value := string(args[0]), string(args[1])
-		if err := h.Db.Set(key, value); err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
-		}
-		return proto.OK
-	case "GET":
-		key := string(args[0])
-		value, err := h.Db.Get(key)
-		if err != nil {
-			if errors.Is(err, store.ErrKeyNotFound) {
-				return nil
-			}
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
-		}
-		return proto.NewBulkString([]byte(value))
-	case "DEL":
-		count := int64(0)
-		for _, arg := range args {
-			deleted, _ := h.Db.Del(string(arg))
-			count += deleted
-		}
-		return proto.NewInteger(count)
-	case "INCR":
-		key := string(args[0])
-		val, err := h.Db.INCR(key)
-		if err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
-		}
-		return proto.NewInteger(val)
-	case "DECR":
-		key := string(args[0])
-		val, err := h.Db.DECR(key)
-		if err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
-		}
-		return proto.NewInteger(val)
-	case "INCRBY":
-		key := string(args[0])
-		delta, _ := strconv.ParseInt(string(args[1]), 10, 64)
-		val, err := h.Db.INCRBY(key, delta)
-		if err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
-		}
-		return proto.NewInteger(val)
-	case "DECRBY":
-		key := string(args[0])
-		delta, _ := strconv.ParseInt(string(args[1]), 10, 64)
-		val, err := h.Db.DECRBY(key, delta)
-		if err != nil {
-			return proto.NewError(fmt.Sprintf("ERR %v", err))
-		}
-		return proto.NewInteger(val)
-	case "APPEND":
-		key, value := string(args[0]), string(args[1])
-		length, _ := h.Db.APPEND(key, value)
-		return proto.NewInteger(int64(length))
-	case "STRLEN":
-		key := string(args[0])
-		length, _ := h.Db.StrLen(key)
-		return proto.NewInteger(int64(length))
-	case "EXISTS":
-		key := string(args[0])
-		exists, _ := h.Db.Exists(key)
-		if exists {
-			return proto.NewInteger(1)
-		}
-		return proto.NewInteger(0)
-	case "EXPIRE":
-		key := string(args[0])
-		seconds, _ := strconv.Atoi(string(args[1]))
-		success, _ := h.Db.Expire(key, seconds)
-		if success {
-			return proto.NewInteger(1)
-		}
-		return proto.NewInteger(0)
-	case "TTL":
-		key := string(args[0])
-		ttl, _ := h.Db.TTL(key)
-		return proto.NewInteger(ttl)
-	case "PERSIST":
-		key := string(args[0])
-		success, _ := h.Db.Persist(key)
-		if success {
-			return proto.NewInteger(1)
-		}
-		return proto.NewInteger(0)
-	case "TYPE":
-		key := string(args[0])
-		keyType, _ := h.Db.Type(key)
-		return proto.NewSimpleString(keyType)
-	case "LPUSH":
-		key := string(args[0])
-		values := make([]string, len(args)-1)
-		for i := 1; i < len(args); i++ {
-			values[i-1] = string(args[i])
-		}
-		count, _ := h.Db.LPush(key, values...)
-		return proto.NewInteger(int64(count))
-	case "RPUSH":
-		key := string(args[0])
-		values := make([]string, len(args)-1)
-		for i := 1; i < len(args); i++ {
-			values[i-1] = string(args[i])
-		}
-		count, _ := h.Db.RPush(key, values...)
-		return proto.NewInteger(int64(count))
-	case "LPOP":
-		key := string(args[0])
-		val, _ := h.Db.LPop(key)
-		if val == "" {
-			return nil
-		}
-		return proto.NewBulkString([]byte(val))
-	case "RPOP":
-		key := string(args[0])
-		val, _ := h.Db.RPop(key)
-		if val == "" {
-			return nil
-		}
-		return proto.NewBulkString([]byte(val))
-	case "LLEN":
-		key := string(args[0])
-		length, _ := h.Db.LLen(key)
-		return proto.NewInteger(int64(length))
-	case "LRANGE":
-		key := string(args[0])
-		start, _ := strconv.ParseInt(string(args[1]), 10, 64)
-		stop, _ := strconv.ParseInt(string(args[2]), 10, 64)
-		items, _ := h.Db.LRange(key, start, stop)
-		results := make([][]byte, len(items))
-		for i, item := range items {
-			results[i] = []byte(item)
-		}
-		return &proto.Array{Args: results}
-	case "HSET":
-		key := string(args[0])
-		field, value := string(args[1]), string(args[2])
-		_ = h.Db.HSet(key, field, value)
-		return proto.NewInteger(1)
-	case "HGET":
-		key, field := string(args[0]), string(args[1])
-		val, _ := h.Db.HGet(key, field)
-		if len(val) == 0 {
-			return nil
-		}
-		return proto.NewBulkString(val)
-	case "HGETALL":
-		key := string(args[0])
-		data, _ := h.Db.HGetAll(key)
-		flatArgs := make([][]byte, 0)
-		for k, v := range data {
-			flatArgs = append(flatArgs, []byte(k), []byte(v))
-		}
-		return &proto.Array{Args: flatArgs}
-	case "HDEL":
-		key := string(args[0])
-		fields := make([]string, len(args)-1)
-		for i := 1; i < len(args); i++ {
-			fields[i-1] = string(args[i])
-		}
-		count, _ := h.Db.HDel(key, fields...)
-		return proto.NewInteger(int64(count))
-	case "SADD":
-		key := string(args[0])
-		members := make([]string, len(args)-1)
-		for i := 1; i < len(args); i++ {
-			members[i-1] = string(args[i])
-		}
-		count, _ := h.Db.SAdd(key, members...)
-		return proto.NewInteger(int64(count))
-	case "SMEMBERS":
-		key := string(args[0])
-		members, _ := h.Db.SMembers(key)
-		results := make([][]byte, len(members))
-		for i, m := range members {
-			results[i] = []byte(m)
-		}
-		return &proto.Array{Args: results}
-	case "SISMEMBER":
-		key, member := string(args[0]), string(args[1])
-		exists, _ := h.Db.SIsMember(key, member)
-		if exists {
-			return proto.NewInteger(1)
-		}
-		return proto.NewInteger(0)
-	case "SCARD":
-		key := string(args[0])
-		count, _ := h.Db.SCard(key)
-		return proto.NewInteger(int64(count))
-	case "SREM":
-		key := string(args[0])
-		members := make([]string, len(args)-1)
-		for i := 1; i < len(args); i++ {
-			members[i-1] = string(args[i])
-		}
-		count, _ := h.Db.SRem(key, members...)
-		return proto.NewInteger(int64(count))
-	case "ZADD":
-		key := string(args[0])
-		members := make([]store.ZSetMember, 0)
-		for i := 1; i < len(args); i += 2 {
-			score, _ := strconv.ParseFloat(string(args[i]), 64)
-			members = append(members, store.ZSetMember{Score: score, Member: string(args[i+1])})
-		}
-		_ = h.Db.ZAdd(key, members)
-		return proto.NewInteger(int64(len(members)))
-	case "ZREM":
-		key := string(args[0])
-		member := string(args[1])
-		_ = h.Db.ZRem(key, member)
-		return proto.NewInteger(1)
-	case "ZCARD":
-		key := string(args[0])
-		count, _ := h.Db.ZCard(key)
-		return proto.NewInteger(int64(count))
-	case "ZSCORE":
-		key, member := string(args[0]), string(args[1])
-		score, _, _ := h.Db.ZScore(key, member)
-		return proto.NewBulkString([]byte(strconv.FormatFloat(score, 'f', -1, 64)))
-	case "ZINCRBY":
-		key, member := string(args[0]), string(args[2])
-		delta, _ := strconv.ParseFloat(string(args[1]), 64)
-		newScore, _ := h.Db.ZIncrBy(key, member, delta)
-		return proto.NewBulkString([]byte(strconv.FormatFloat(newScore, 'f', -1, 64)))
-	default:
-		return proto.NewError(fmt.Sprintf("ERR command '%s' not supported in transaction", cmd))
-	}
-}
-
-// copyList 复制列表
-func (h *Handler) copyList(srcKey, dstKey string) bool {
-	length, err := h.Db.LLen(srcKey)
-	if err != nil {
-		return false
-	}
-	if length == 0 {
-		return true
-	}
-	// 获取所有元素
-	items, err := h.Db.LRange(srcKey, 0, int64(length-1))
-	if err != nil {
-		return false
-	}
-	// 先删除目标
-	_, _ = h.Db.Del(dstKey)
-	// 添加到目标列表
-	_, err = h.Db.RPush(dstKey, items...)
-	return err == nil
-}
-
-// copyHash 复制Hash
-func (h *Handler) copyHash(srcKey, dstKey string) bool {
-	data, err := h.Db.HGetAll(srcKey)
-	if err != nil {
-		return false
-	}
-	if len(data) == 0 {
-		return true
-	}
-	// 先删除目标
-	_, _ = h.Db.Del(dstKey)
-	// 设置所有字段
-	for k, v := range data {
-		if err := h.Db.HSet(dstKey, k, v); err != nil {
-			return false
-		}
-	}
-	return true
-}
-
-// copySet 复制Set
-func (h *Handler) copySet(srcKey, dstKey string) bool {
-	members, err := h.Db.SMembers(srcKey)
-	if err != nil {
-		return false
-	}
-	if len(members) == 0 {
-		return true
-	}
-	// 先删除目标
-	_, _ = h.Db.Del(dstKey)
-	// 添加所有成员
-	_, err = h.Db.SAdd(dstKey, members...)
-	return err == nil
-}
-
-// copySortedSet 复制SortedSet
-func (h *Handler) copySortedSet(srcKey, dstKey string) bool {
-	members, err := h.Db.ZRange(srcKey, 0, -1)
-	if err != nil {
-		return false
-	}
-	if len(members) == 0 {
-		return true
-	}
-	// 先删除目标
-	_, _ = h.Db.Del(dstKey)
-	// 添加所有成员
-	zMembers := make([]store.ZSetMember, len(members))
-	for i, m := range members {
-		zMembers[i] = store.ZSetMember{Score: m.Score, Member: m.Member}
-	}
-	err = h.Db.ZAdd(dstKey, zMembers)
-	return err == nil
-}
@@ -3,16 +3,31 @@ package server
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
+	"github.com/lbp0200/BoltDB/internal/aof"
+	"github.com/lbp0200/BoltDB/internal/backup"
+	"github.com/lbp0200/BoltDB/internal/cluster"
+	"github.com/lbp0200/BoltDB/internal/config"
+	"github.com/lbp0200/BoltDB/internal/fixtures"
+	"github.com/lbp0200/BoltDB/internal/mirror"
 	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/replication"
 	"github.com/lbp0200/BoltDB/internal/store"
+	"github.com/lbp0200/BoltDB/internal/tlsconf"
 	"github.com/zeebo/assert"
 )
 
@@ -408,6 +423,490 @@ func TestSortedSetCommands(t *testing.T) {
 	assert.Equal(t, "1", string(*bulk))
 }
 
+// TestHelloReplyCompression 测试 HELLO 协商回复压缩
+func TestHelloReplyCompression(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+	dec, err := zstd.NewReader(nil)
+	assert.NoError(t, err)
+	defer dec.Close()
+	// 压缩的回复是一个 bulk string，内容为原回复 RESP 编码的 zstd 帧
+	decode := func(reply string) string {
+		r := bufio.NewReader(strings.NewReader(reply))
+		header, err := r.ReadString('\n')
+		assert.NoError(t, err)
+		n, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+		assert.NoError(t, err)
+		frame := make([]byte, n)
+		_, err = io.ReadFull(r, frame)
+		assert.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(frame, zstdFrameMagic))
+		raw, err := dec.DecodeAll(frame, nil)
+		assert.NoError(t, err)
+		return string(raw)
+	}
+
+	reply := run("HELLO")
+	assert.True(t, strings.Contains(reply, "$5\r\nproto\r\n:2\r\n"))
+	assert.True(t, strings.Contains(reply, "$11\r\ncompression\r\n$4\r\nnone\r\n"))
+	assert.Equal(t, "-NOPROTO sorry, this protocol version is not supported\r\n", run("HELLO", "4"))
+	assert.Equal(t, "-ERR Protocol version is not an integer or out of range\r\n", run("HELLO", "two"))
+	assert.Equal(t, "-ERR unsupported reply compression 'gzip'\r\n", run("HELLO", "2", "COMPRESS", "gzip"))
+	assert.Equal(t, "-ERR Syntax error in HELLO option 'COMPRESS'\r\n", run("HELLO", "2", "COMPRESS"))
+
+	large := strings.Repeat("abcdefgh", 1024)
+	run("SET", "big", large)
+	run("SET", "small", "v")
+	// 未协商时不压缩
+	assert.Equal(t, "$8192\r\n"+large+"\r\n", run("GET", "big"))
+
+	reply = run("HELLO", "2", "SETNAME", "analytics", "COMPRESS", "zstd", "THRESHOLD", "1024")
+	assert.True(t, strings.Contains(reply, "$4\r\nzstd\r\n$21\r\ncompression-threshold\r\n:1024\r\n"))
+	assert.Equal(t, "$9\r\nanalytics\r\n", run("CLIENT", "GETNAME"))
+	compressed := run("GET", "big")
+	assert.True(t, len(compressed) < 1024)
+	assert.Equal(t, "$8192\r\n"+large+"\r\n", decode(compressed))
+	assert.Equal(t, "$1\r\nv\r\n", run("GET", "small"))
+
+	// 本身以 zstd 帧头开头的值即使很小也压缩，避免与压缩帧混淆
+	run("SET", "magic", string(zstdFrameMagic)+"x")
+	assert.Equal(t, "$5\r\n"+string(zstdFrameMagic)+"x\r\n", decode(run("GET", "magic")))
+
+	// 不带 COMPRESS 的 HELLO 保留协商结果，COMPRESS none 关闭
+	assert.True(t, strings.Contains(run("HELLO", "2"), "$4\r\nzstd\r\n"))
+	run("HELLO", "2", "COMPRESS", "none")
+	assert.Equal(t, "$8192\r\n"+large+"\r\n", run("GET", "big"))
+}
+
+// TestZMergeCommand 测试 BOLTREON.ZMERGE
+func TestZMergeCommand(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	run("ZADD", "dst", "5", "a", "1", "b")
+	run("ZADD", "src", "3", "a", "4", "b", "2", "c")
+	assert.Equal(t, ":2\r\n", run("BOLTREON.ZMERGE", "dst", "src"))
+	assert.Equal(t, ":1\r\n", run("BOLTREON.ZMERGE", "dst", "src", "min"))
+	assert.Equal(t, "$1\r\n3\r\n", run("ZSCORE", "dst", "a"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("BOLTREON.ZMERGE", "dst", "src", "SUM"))
+
+	run("SET", "str", "v")
+	assert.Equal(t, "-"+wrongTypeErrorMessage+"\r\n", run("BOLTREON.ZMERGE", "dst", "str"))
+	assert.Equal(t, "-"+wrongTypeErrorMessage+"\r\n", run("BOLTREON.ZMERGE", "str", "src"))
+}
+
+// TestSumRangeCommand 测试 BOLTREON.SUMRANGE
+func TestSumRangeCommand(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	run("HSET", "page", "view:a", "3", "view:b", "4.5", "title", "home")
+	assert.Equal(t, "$3\r\n7.5\r\n", run("BOLTREON.SUMRANGE", "HASH", "page"))
+	assert.Equal(t, "$1\r\n3\r\n", run("BOLTREON.SUMRANGE", "hash", "page", "MATCH", "view:*", "AGG", "min"))
+	assert.Equal(t, ":2\r\n", run("BOLTREON.SUMRANGE", "HASH", "page", "AGG", "COUNT"))
+	assert.Equal(t, "$1\r\n0\r\n", run("BOLTREON.SUMRANGE", "HASH", "missing"))
+	assert.Equal(t, "$-1\r\n", run("BOLTREON.SUMRANGE", "HASH", "missing", "AGG", "AVG"))
+
+	run("SET", "hits:1", "10")
+	run("SET", "hits:2", "-2")
+	run("HSET", "u:1", "score", "1")
+	run("HSET", "u:2", "score", "2")
+	assert.Equal(t, "$1\r\n8\r\n", run("BOLTREON.SUMRANGE", "KEYS", "hits:*"))
+	assert.Equal(t, "$3\r\n1.5\r\n", run("BOLTREON.SUMRANGE", "KEYS", "u:*", "FIELD", "score", "AGG", "AVG"))
+
+	assert.Equal(t, "-ERR syntax error\r\n", run("BOLTREON.SUMRANGE", "HASH", "page", "AGG", "MEDIAN"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("BOLTREON.SUMRANGE", "HASH", "page", "FIELD", "x"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("BOLTREON.SUMRANGE", "HASH", "page", "MATCH"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("BOLTREON.SUMRANGE", "ZSET", "page"))
+	assert.Equal(t, "-"+wrongTypeErrorMessage+"\r\n", run("BOLTREON.SUMRANGE", "HASH", "hits:1"))
+}
+
+// TestScheduleCommand 测试 BOLTREON.SCHEDULE 定时命令
+func TestScheduleCommand(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	clock := store.NewManualClock(time.UnixMilli(1000))
+	handler.Db.SetClock(clock)
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	run("SET", "session", "x")
+	assert.Equal(t, "$6\r\n2000-1\r\n", run("BOLTREON.SCHEDULE", "2000", "DEL", "session"))
+	assert.Equal(t, "$6\r\n2000-2\r\n", run("BOLTREON.SCHEDULE", "2000", "LPUSH", "jobs", "j1"))
+	assert.Equal(t, "$6\r\n5000-3\r\n", run("BOLTREON.SCHEDULE", "5000", "INCR", "session"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'get' command\r\n", run("BOLTREON.SCHEDULE", "2000", "GET"))
+	assert.Equal(t, "-ERR 'blpop' cannot be scheduled\r\n", run("BOLTREON.SCHEDULE", "2000", "BLPOP", "q", "0"))
+	assert.Equal(t, "-"+errNotInteger+"\r\n", run("BOLTREON.SCHEDULE", "soon", "DEL", "a"))
+	assert.True(t, strings.HasPrefix(run("BOLTREON.SCHEDULE", "LIST"),
+		"*3\r\n*3\r\n$6\r\n2000-1\r\n:2000\r\n*2\r\n$3\r\nDEL\r\n$7\r\nsession\r\n"))
+
+	// 未到期不执行
+	assert.Equal(t, 0, handler.runDueScheduled())
+	clock.Advance(time.Second)
+	assert.Equal(t, 2, handler.runDueScheduled())
+	assert.Equal(t, ":0\r\n", run("EXISTS", "session"))
+	assert.Equal(t, ":1\r\n", run("LLEN", "jobs"))
+
+	// 执行结果写入结果 Stream
+	entries, err := handler.Db.XRange(ScheduleResultsStream, "-", "+", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "2000-1", entries[0].Fields["id"])
+	assert.Equal(t, "DEL session", entries[0].Fields["command"])
+	assert.Equal(t, "1", entries[0].Fields["ok"])
+	assert.Equal(t, ":1\r\n", entries[0].Fields["reply"])
+
+	assert.Equal(t, ":1\r\n", run("BOLTREON.SCHEDULE", "CANCEL", "5000-3"))
+	assert.Equal(t, ":0\r\n", run("BOLTREON.SCHEDULE", "CANCEL", "5000-3"))
+	clock.Advance(10 * time.Second)
+	assert.Equal(t, 0, handler.runDueScheduled())
+	assert.Equal(t, "*0\r\n", run("BOLTREON.SCHEDULE", "LIST"))
+}
+
+// TestDelayedQueueCommands 测试 QPUSH/QPOP/QACK
+func TestDelayedQueueCommands(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	clock := store.NewManualClock(time.UnixMilli(1000))
+	handler.Db.SetClock(clock)
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	assert.Equal(t, "$6\r\n1000-0\r\n", run("QPUSH", "jobs", "0", "a"))
+	assert.Equal(t, "$6\r\n1000-1\r\n", run("QPUSH", "jobs", "500", "b"))
+	assert.Equal(t, "*3\r\n$6\r\n1000-0\r\n$1\r\na\r\n:1\r\n", run("QPOP", "jobs", "1000"))
+	assert.Equal(t, "$-1\r\n", run("QPOP", "jobs", "1000"))
+	clock.Advance(500 * time.Millisecond)
+	assert.Equal(t, "*3\r\n$6\r\n1000-1\r\n$1\r\nb\r\n:1\r\n", run("QPOP", "jobs", "1000"))
+	assert.Equal(t, ":1\r\n", run("QACK", "jobs", "1000-1"))
+	clock.Advance(time.Second)
+	assert.Equal(t, "*3\r\n$6\r\n1000-0\r\n$1\r\na\r\n:2\r\n", run("QPOP", "jobs", "1000"))
+	assert.Equal(t, ":1\r\n", run("XLEN", "jobs"))
+
+	assert.Equal(t, "-ERR delay must be a non-negative integer\r\n", run("QPUSH", "jobs", "-1", "a"))
+	assert.Equal(t, "-ERR visibility timeout must be a positive integer\r\n", run("QPOP", "jobs", "0"))
+	assert.Equal(t, "-"+errNotInteger+"\r\n", run("QPOP", "jobs", "soon"))
+	run("SET", "str", "v")
+	assert.Equal(t, "-"+wrongTypeErrorMessage+"\r\n", run("QPUSH", "str", "0", "a"))
+}
+
+// TestNamespaceCommands 测试命名空间配额、默认 TTL 与 NAMESPACE FLUSH
+func TestNamespaceCommands(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	assert.Equal(t, "+OK\r\n", run("NAMESPACE", "SET", "ci:1:", "TTL", "60", "MAXKEYS", "2"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("NAMESPACE", "SET", "ci:1:", "TTL"))
+	assert.Equal(t, "*1\r\n$5\r\nci:1:\r\n", run("NAMESPACE", "LIST"))
+
+	assert.Equal(t, "+OK\r\n", run("SET", "ci:1:a", "v"))
+	assert.Equal(t, "+OK\r\n", run("SET", "ci:1:b", "v"))
+	ttl, _ := handler.Db.TTL("ci:1:a")
+	assert.Equal(t, int64(60), ttl)
+	ttl, _ = handler.Db.TTL("other")
+	assert.Equal(t, int64(-2), ttl)
+
+	// 配额只限制新建键，已有键仍可写
+	assert.Equal(t, "-ERR namespace 'ci:1:' key quota exceeded (2 keys)\r\n", run("SET", "ci:1:c", "v"))
+	assert.Equal(t, "+OK\r\n", run("SET", "ci:1:a", "w"))
+	assert.Equal(t, ":1\r\n", run("DEL", "ci:1:b"))
+	assert.Equal(t, "-ERR namespace 'ci:1:' key quota exceeded (2 keys)\r\n", run("MSET", "ci:1:c", "1", "ci:1:d", "2"))
+	assert.Equal(t, "+OK\r\n", run("SET", "other", "v"))
+
+	assert.Equal(t, "+OK\r\n", run("SET", "ci:1:c", "v"))
+	assert.Equal(t, "-ERR namespace 'ci:1:' key quota exceeded (2 keys)\r\n", run("SET", "ci:1:d", "v"))
+
+	assert.Equal(t, "*8\r\n$6\r\nprefix\r\n$5\r\nci:1:\r\n$3\r\nttl\r\n:60\r\n$7\r\nmaxkeys\r\n:2\r\n$4\r\nkeys\r\n:2\r\n",
+		run("NAMESPACE", "INFO", "ci:1:"))
+	assert.Equal(t, ":2\r\n", run("NAMESPACE", "FLUSH", "ci:1:"))
+	assert.Equal(t, ":1\r\n", run("EXISTS", "other"))
+	assert.Equal(t, "-ERR no such namespace\r\n", run("NAMESPACE", "FLUSH", "ci:2:"))
+
+	// 非 0 号数据库中按数据库中的键名匹配，配额按数据库分别统计
+	assert.Equal(t, "+OK\r\n", run("SET", "ci:1:a", "v"))
+	assert.Equal(t, "+OK\r\n", run("SELECT", "1"))
+	assert.Equal(t, "+OK\r\n", run("SET", "ci:1:a", "v"))
+	assert.Equal(t, ":60\r\n", run("TTL", "ci:1:a"))
+	assert.Equal(t, "+OK\r\n", run("SET", "ci:1:b", "v"))
+	assert.Equal(t, "-ERR namespace 'ci:1:' key quota exceeded (2 keys)\r\n", run("SET", "ci:1:c", "v"))
+	assert.Equal(t, "*8\r\n$6\r\nprefix\r\n$5\r\nci:1:\r\n$3\r\nttl\r\n:60\r\n$7\r\nmaxkeys\r\n:2\r\n$4\r\nkeys\r\n:2\r\n",
+		run("NAMESPACE", "INFO", "ci:1:"))
+	assert.Equal(t, ":2\r\n", run("NAMESPACE", "FLUSH", "ci:1:"))
+	assert.Equal(t, "+OK\r\n", run("SELECT", "0"))
+	assert.Equal(t, ":1\r\n", run("EXISTS", "ci:1:a"))
+	assert.Equal(t, ":1\r\n", run("NAMESPACE", "DEL", "ci:1:"))
+	assert.Equal(t, "-ERR unknown subcommand 'BOGUS'\r\n", run("NAMESPACE", "BOGUS"))
+}
+
+// TestTransactionStateMachine 测试 MULTI 中的命令入队、EXECABORT、阻塞命令与 WATCH
+func TestTransactionStateMachine(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	assert.Equal(t, "-ERR EXEC without MULTI\r\n", run("EXEC"))
+	assert.Equal(t, "-ERR DISCARD without MULTI\r\n", run("DISCARD"))
+
+	// 所有命令都入队，键类型在 EXEC 时检查
+	assert.Equal(t, "+OK\r\n", run("MULTI"))
+	assert.Equal(t, "-ERR MULTI calls can not be nested\r\n", run("MULTI"))
+	assert.Equal(t, "+QUEUED\r\n", run("SET", "k", "v"))
+	assert.Equal(t, "+QUEUED\r\n", run("GET", "k"))
+	assert.Equal(t, "+QUEUED\r\n", run("LPUSH", "k", "x"))
+	assert.Equal(t, "$-1\r\n", handler.executeCommand("GET", [][]byte{[]byte("k")}, "127.0.0.1:12345").String())
+	assert.Equal(t, "*3\r\n+OK\r\n$1\r\nv\r\n-"+wrongTypeErrorMessage+"\r\n", run("EXEC"))
+
+	// 参数个数错误与 SUBSCRIBE 使事务失败
+	run("MULTI")
+	assert.Equal(t, "-ERR wrong number of arguments for 'get' command\r\n", run("GET"))
+	assert.Equal(t, "+QUEUED\r\n", run("SET", "k", "w"))
+	assert.Equal(t, "-"+execAbortMessage+"\r\n", run("EXEC"))
+	run("MULTI")
+	assert.Equal(t, "-ERR Command not allowed inside a transaction\r\n", run("SUBSCRIBE", "ch"))
+	assert.Equal(t, "-"+execAbortMessage+"\r\n", run("EXEC"))
+	// 未知命令在入队时报错，EXEC 返回 EXECABORT
+	run("MULTI")
+	assert.Equal(t, "-ERR unknown command 'FOOBAR', with args beginning with: 'a' \r\n", run("FOOBAR", "a"))
+	assert.Equal(t, "-"+execAbortMessage+"\r\n", run("EXEC"))
+	assert.Equal(t, "$1\r\nv\r\n", run("GET", "k"))
+
+	// 阻塞命令在事务中不阻塞
+	run("RPUSH", "list", "a")
+	run("MULTI")
+	run("BLPOP", "list", "5")
+	run("BLPOP", "list", "5")
+	run("XREAD", "BLOCK", "5000", "STREAMS", "stream", "$")
+	start := time.Now()
+	assert.Equal(t, "*3\r\n*2\r\n$4\r\nlist\r\n$1\r\na\r\n*-1\r\n$-1\r\n", run("EXEC"))
+	assert.True(t, time.Since(start) < time.Second)
+	run("MULTI")
+	run("BRPOP", "list", "5")
+	assert.Equal(t, "*1\r\n*-1\r\n", run("EXEC"))
+
+	// DISCARD 同时取消 WATCH
+	assert.Equal(t, "+OK\r\n", run("WATCH", "w"))
+	run("MULTI")
+	assert.Equal(t, "-ERR WATCH inside MULTI is not allowed\r\n", run("WATCH", "w"))
+	assert.Equal(t, "+OK\r\n", run("DISCARD"))
+	assert.NoError(t, handler.Db.Set("w", "1"))
+	run("MULTI")
+	run("SET", "x", "1")
+	assert.Equal(t, "*1\r\n+OK\r\n", run("EXEC"))
+
+	// WATCH 的键被其他连接修改时 EXEC 返回空数组，自己在事务中修改不影响
+	assert.Equal(t, "+OK\r\n", run("WATCH", "w", "v"))
+	assert.NoError(t, handler.Db.Set("w", "2"))
+	run("MULTI")
+	run("SET", "x", "2")
+	assert.Equal(t, "*-1\r\n", run("EXEC"))
+	assert.Equal(t, "$1\r\n1\r\n", run("GET", "x"))
+	run("WATCH", "w")
+	run("MULTI")
+	run("SET", "w", "3")
+	assert.Equal(t, "*1\r\n+OK\r\n", run("EXEC"))
+
+	// 写入相同的值、删除都算修改，UNWATCH 后不再检查
+	run("WATCH", "w")
+	assert.NoError(t, handler.Db.Set("w", "3"))
+	run("MULTI")
+	assert.Equal(t, "*-1\r\n", run("EXEC"))
+	run("WATCH", "w")
+	run("DEL", "w")
+	run("MULTI")
+	assert.Equal(t, "*-1\r\n", run("EXEC"))
+	run("WATCH", "w")
+	run("SET", "w", "5")
+	assert.Equal(t, "+OK\r\n", run("UNWATCH"))
+	run("MULTI")
+	run("GET", "w")
+	assert.Equal(t, "*1\r\n$1\r\n5\r\n", run("EXEC"))
+
+	// 事务状态属于连接，其他连接的命令不受影响
+	other := handler.newConnection()
+	run("MULTI")
+	req := &proto.Array{Args: [][]byte{[]byte("SET"), []byte("y"), []byte("1")}}
+	assert.Equal(t, "+OK\r\n", other.processRequest(req, nil, "127.0.0.1:12346", nil, nil).String())
+	assert.Equal(t, "*0\r\n", run("EXEC"))
+}
+
+// TestExecAndScriptIsolation EXEC 与脚本执行期间其他连接的命令不会交错
+func TestExecAndScriptIsolation(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	runOn := func(h *Handler, args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return h.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+	// 另一个连接不断递增同一个键，直到 stop 关闭
+	hammer := func(stop chan struct{}) *sync.WaitGroup {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			other := handler.newConnection()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					runOn(other, "INCR", "n")
+				}
+			}
+		}()
+		return &wg
+	}
+
+	conn := handler.newConnection()
+	runOn(conn, "MULTI")
+	runOn(conn, "SET", "n", "0")
+	for i := 0; i < 200; i++ {
+		runOn(conn, "INCR", "n")
+	}
+	runOn(conn, "GET", "n")
+	stop := make(chan struct{})
+	wg := hammer(stop)
+	reply := runOn(conn, "EXEC")
+	close(stop)
+	wg.Wait()
+	assert.True(t, strings.HasSuffix(reply, "$3\r\n200\r\n"))
+
+	script := "redis.call('SET', KEYS[1], '0') for i = 1, 200 do redis.call('INCR', KEYS[1]) end return redis.call('GET', KEYS[1])"
+	stop = make(chan struct{})
+	wg = hammer(stop)
+	reply = runOn(conn, "EVAL", script, "1", "n")
+	close(stop)
+	wg.Wait()
+	assert.Equal(t, "$3\r\n200\r\n", reply)
+}
+
+// TestWriteStatsCommand 测试 BOLTREON.WRITESTATS 按命令统计写放大
+func TestWriteStatsCommand(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	run("SET", "before", "v")
+	assert.Equal(t, "$23\r\n# WriteStats\nenabled:0\n\r\n", run("BOLTREON.WRITESTATS"))
+
+	assert.Equal(t, "+OK\r\n", run("BOLTREON.WRITESTATS", "ON"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("BOLTREON.WRITESTATS", "MAYBE"))
+	run("ZADD", "board", "1", "a", "2", "b")
+	run("GET", "before")
+	run("DEL", "before")
+
+	report := run("BOLTREON.WRITESTATS")
+	assert.True(t, strings.Contains(report, "enabled:1\n"))
+	assert.True(t, strings.Contains(report, "cmdstat_zadd:calls=1,"))
+	assert.True(t, strings.Contains(report, "cmdstat_del:calls=1,keys_written=0,"))
+	assert.False(t, strings.Contains(report, "cmdstat_get"))
+
+	assert.Equal(t, "+OK\r\n", run("BOLTREON.WRITESTATS", "RESET"))
+	assert.Equal(t, "+OK\r\n", run("BOLTREON.WRITESTATS", "OFF"))
+	assert.Equal(t, "$23\r\n# WriteStats\nenabled:0\n\r\n", run("BOLTREON.WRITESTATS"))
+}
+
+// TestZWatchCommands 测试 BOLTREON.ZWATCH 排行榜通知
+func TestZWatchCommands(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	handler.PubSub = store.NewPubSubManager()
+	handler.Db.SetZWatchPublisher(func(channel string, message []byte) {
+		handler.PubSub.Publish(channel, message)
+	})
+	sub := store.NewSubscriber("watcher")
+	handler.PubSub.Subscribe(sub, "lb")
+
+	run := func(args ...string) string {
+		cmdArgs := make([][]byte, len(args)-1)
+		for i, a := range args[1:] {
+			cmdArgs[i] = []byte(a)
+		}
+		return handler.executeCommand(args[0], cmdArgs, "127.0.0.1:12345").String()
+	}
+
+	assert.Equal(t, "+OK\r\n", run("BOLTREON.ZWATCH", "board", "1", "lb", "REV"))
+	assert.Equal(t, "-ERR top-N must be a positive integer\r\n", run("BOLTREON.ZWATCH", "board", "0", "lb"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("BOLTREON.ZWATCH", "board", "1", "lb", "DESC"))
+
+	run("ZADD", "board", "10", "alice")
+	msg := <-sub.MessageCh
+	assert.Equal(t, "lb", msg.Channel)
+	assert.Equal(t, `{"key":"board","member":"alice","event":"enter","old_rank":-1,"new_rank":0,"old_score":null,"new_score":10}`, string(msg.Data))
+
+	// 未进入前 1 名的成员不产生事件
+	run("ZADD", "board", "5", "bob")
+	select {
+	case msg = <-sub.MessageCh:
+		t.Fatalf("unexpected event: %s", msg.Data)
+	default:
+	}
+
+	assert.Equal(t, ":1\r\n", run("BOLTREON.ZUNWATCH", "board"))
+	assert.Equal(t, ":0\r\n", run("BOLTREON.ZUNWATCH", "board"))
+}
+
 // TestErrorHandling 测试错误处理
 func TestErrorHandling(t *testing.T) {
 	handler := setupTestHandler(t)
@@ -614,3 +1113,3208 @@ func TestRealWorldScenario(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+
+// expectRESP 读取并比较一条原始 RESP 响应
+func expectRESP(t *testing.T, reader *bufio.Reader, expected proto.RESP) {
+	want := expected.String()
+	buf := make([]byte, len(want))
+	_, err := io.ReadFull(reader, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, want, string(buf))
+}
+
+// TestDurableSubscribeResume 测试 SUBSCRIBE ... RESUME <token> 补发错过的消息
+func TestDurableSubscribeResume(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	handler.PubSub = store.NewPubSubManager()
+	handler.PubSub.EnableDurable(handler.Db, 100)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		_ = handler.ServeTCP(listener)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// 未启用持久化订阅时返回错误
+	plain := &Handler{Db: handler.Db, PubSub: store.NewPubSubManager()}
+	resp := plain.handleSubscribe("SUBSCRIBE", [][]byte{[]byte("news"), []byte("RESUME"), []byte("0")}, "test", nil, nil, nil)
+	assert.Equal(t, "-ERR durable pubsub not enabled\r\n", resp.String())
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	// 第一次订阅：RESUME $ 只接收新消息
+	assert.NoError(t, proto.WriteRESP(conn, &proto.Array{Args: [][]byte{
+		[]byte("SUBSCRIBE"), []byte("news"), []byte("RESUME"), []byte("$"),
+	}}))
+	expectRESP(t, reader, subscriptionReply("subscribe", "news", 1))
+
+	handler.PubSub.Publish("news", []byte("hello"))
+	msgs, err := handler.PubSub.Replay("news", "0", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(msgs))
+	expectRESP(t, reader, messagePush(msgs[0]))
+	token := msgs[0].ID
+
+	// PING 在订阅模式下返回 pong 数组
+	assert.NoError(t, proto.WriteRESP(conn, &proto.Array{Args: [][]byte{[]byte("PING")}}))
+	expectRESP(t, reader, &proto.Array{Args: [][]byte{[]byte("pong"), []byte("")}})
+
+	// 断线期间发布的消息
+	conn.Close()
+	time.Sleep(20 * time.Millisecond)
+	handler.PubSub.Publish("news", []byte("missed1"))
+	handler.PubSub.Publish("news", []byte("missed2"))
+
+	conn2, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn2.Close()
+	reader2 := bufio.NewReader(conn2)
+
+	assert.NoError(t, proto.WriteRESP(conn2, &proto.Array{Args: [][]byte{
+		[]byte("SUBSCRIBE"), []byte("news"), []byte("RESUME"), []byte(token),
+	}}))
+	expectRESP(t, reader2, subscriptionReply("subscribe", "news", 1))
+	missed, err := handler.PubSub.Replay("news", token, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(missed))
+	expectRESP(t, reader2, messagePush(missed[0]))
+	expectRESP(t, reader2, messagePush(missed[1]))
+
+	// 全部退订后回到普通模式
+	assert.NoError(t, proto.WriteRESP(conn2, &proto.Array{Args: [][]byte{[]byte("UNSUBSCRIBE")}}))
+	expectRESP(t, reader2, subscriptionReply("unsubscribe", "news", 0))
+	resp, err = sendCommand(conn2, reader2, "PING")
+	assert.NoError(t, err)
+	assert.Equal(t, "+PONG\r\n", resp.String())
+}
+
+// TestSubscribeMode 测试 SUBSCRIBE/PSUBSCRIBE/SSUBSCRIBE 的订阅模式与消息推送
+func TestSubscribeMode(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	handler.PubSub = store.NewPubSubManager()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		_ = handler.ServeTCP(listener)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	write := func(args ...string) {
+		cmdArgs := make([][]byte, len(args))
+		for i, arg := range args {
+			cmdArgs[i] = []byte(arg)
+		}
+		assert.NoError(t, proto.WriteRESP(conn, &proto.Array{Args: cmdArgs}))
+	}
+
+	// 未订阅时退订只返回确认
+	write("UNSUBSCRIBE")
+	expectRESP(t, reader, emptyUnsubscribeReply("unsubscribe", 0))
+
+	write("SUBSCRIBE", "news", "sport")
+	expectRESP(t, reader, subscriptionReply("subscribe", "news", 1))
+	expectRESP(t, reader, subscriptionReply("subscribe", "sport", 2))
+	write("PSUBSCRIBE", "user:[0-9]*")
+	expectRESP(t, reader, subscriptionReply("psubscribe", "user:[0-9]*", 3))
+	write("SSUBSCRIBE", "orders")
+	expectRESP(t, reader, subscriptionReply("ssubscribe", "orders", 1))
+
+	assert.Equal(t, 1, handler.PubSub.Publish("news", []byte("hello")))
+	expectRESP(t, reader, messagePush(&store.Message{Channel: "news", Data: []byte("hello")}))
+	assert.Equal(t, 1, handler.PubSub.Publish("user:42", []byte("login")))
+	expectRESP(t, reader, messagePush(&store.Message{Channel: "user:42", Pattern: "user:[0-9]*", Data: []byte("login")}))
+	assert.Equal(t, 0, handler.PubSub.Publish("user:bob", []byte("login")))
+	assert.Equal(t, 1, handler.PubSub.SPublish("orders", []byte("o1")))
+	expectRESP(t, reader, messagePush(&store.Message{Channel: "orders", Data: []byte("o1"), Shard: true}))
+
+	// RESP2 连接在订阅模式下只接受订阅相关命令
+	write("GET", "k")
+	expectRESP(t, reader, proto.NewError("ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT are allowed in this context"))
+	write("PING", "hi")
+	expectRESP(t, reader, &proto.Array{Args: [][]byte{[]byte("pong"), []byte("hi")}})
+
+	// 全部退订后回到普通模式
+	write("UNSUBSCRIBE", "news")
+	expectRESP(t, reader, subscriptionReply("unsubscribe", "news", 2))
+	write("PUNSUBSCRIBE")
+	expectRESP(t, reader, subscriptionReply("punsubscribe", "user:[0-9]*", 1))
+	write("SUNSUBSCRIBE")
+	expectRESP(t, reader, subscriptionReply("sunsubscribe", "orders", 0))
+	write("UNSUBSCRIBE")
+	expectRESP(t, reader, subscriptionReply("unsubscribe", "sport", 0))
+	resp, err := sendCommand(conn, reader, "PING")
+	assert.NoError(t, err)
+	assert.Equal(t, "+PONG\r\n", resp.String())
+	assert.Equal(t, 0, handler.PubSub.Publish("sport", []byte("late")))
+}
+
+// TestLoadingState 测试加载期间返回 -LOADING，只允许 PING/INFO 等命令
+func TestLoadingState(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		_ = handler.ServeTCP(listener)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- handler.RunLoading(func() error {
+			<-release
+			return nil
+		})
+	}()
+	for !handler.IsLoading() {
+		time.Sleep(time.Millisecond)
+	}
+
+	resp, err := sendCommand(conn, reader, "SET", "k", "v")
+	assert.NoError(t, err)
+	assert.Equal(t, "-LOADING Redis is loading the dataset in memory\r\n", resp.String())
+
+	resp, err = sendCommand(conn, reader, "PING")
+	assert.NoError(t, err)
+	assert.Equal(t, "+PONG\r\n", resp.String())
+
+	resp, err = sendCommand(conn, reader, "INFO", "persistence")
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(resp.String(), "loading:1\n"))
+
+	// 加载结束后恢复正常服务
+	close(release)
+	assert.NoError(t, <-done)
+	assert.False(t, handler.IsLoading())
+
+	resp, err = sendCommand(conn, reader, "SET", "k", "v")
+	assert.NoError(t, err)
+	assert.Equal(t, "+OK\r\n", resp.String())
+
+	resp, err = sendCommand(conn, reader, "INFO", "persistence")
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(resp.String(), "loading:0\n"))
+}
+
+// TestInfoSections 测试 INFO 各部分的统计随命令与连接变化
+func TestInfoSections(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		_ = handler.ServeTCP(listener)
+	}()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	info := func(section string) string {
+		resp, err := sendCommand(conn, reader, "INFO", section)
+		assert.NoError(t, err)
+		return resp.String()
+	}
+
+	for _, args := range [][]string{
+		{"SET", "a", "1"}, {"SET", "b", "2"}, {"GET", "a"}, {"GET", "missing"},
+		{"EXISTS", "a", "nope"}, {"GET"}, {"INCR", "a", "b"},
+	} {
+		_, err := sendCommand(conn, reader, args[0], args[1:]...)
+		assert.NoError(t, err)
+	}
+
+	stats := info("stats")
+	assert.True(t, strings.Contains(stats, "total_connections_received:1\n"))
+	assert.True(t, strings.Contains(stats, "total_commands_processed:7\n"))
+	assert.True(t, strings.Contains(stats, "keyspace_hits:2\n"))
+	assert.True(t, strings.Contains(stats, "keyspace_misses:2\n"))
+	assert.True(t, strings.Contains(info("clients"), "connected_clients:1\n"))
+	assert.True(t, strings.Contains(info("persistence"), "rdb_changes_since_last_save:2\n"))
+	assert.True(t, strings.Contains(info("keyspace"), "db0:keys=2,expires=0,avg_ttl=0\n"))
+	server := info("server")
+	assert.True(t, strings.Contains(server, "tcp_port:"+strings.Split(listener.Addr().String(), ":")[1]+"\n"))
+	assert.True(t, strings.Contains(server, "run_id:"+serverRunID+"\n"))
+	memory := info("memory")
+	assert.True(t, strings.Contains(memory, "# Memory\n"))
+	assert.True(t, strings.Contains(memory, "badger_vlog_size:"))
+
+	// commandstats 只在单独请求或 ALL 时返回
+	assert.False(t, strings.Contains(info(""), "# Commandstats"))
+	assert.True(t, strings.Contains(info("all"), "# Commandstats"))
+	commands := info("commandstats")
+	assert.True(t, strings.Contains(commands, "cmdstat_set:calls=2,"))
+	assert.True(t, strings.Contains(commands, "cmdstat_get:calls=2,"))
+	assert.True(t, strings.Contains(commands, "rejected_calls=1,failed_calls=0\n"))
+	assert.True(t, strings.Contains(commands, "cmdstat_incr:calls=0,usec=0,usec_per_call=0.00,rejected_calls=1,failed_calls=0\n"))
+
+	resp, err := sendCommand(conn, reader, "CONFIG", "RESETSTAT")
+	assert.NoError(t, err)
+	assert.Equal(t, "+OK\r\n", resp.String())
+	stats = info("stats")
+	assert.True(t, strings.Contains(stats, "keyspace_hits:0\n"))
+	assert.False(t, strings.Contains(stats, "stat_reset_time:0\n"))
+	assert.False(t, strings.Contains(info("commandstats"), "cmdstat_set"))
+
+	hits, misses := keyspaceLookupResult("MGET", nil, &proto.Array{Args: [][]byte{[]byte("1"), nil, nil}})
+	assert.Equal(t, int64(1), hits)
+	assert.Equal(t, int64(2), misses)
+	hits, misses = keyspaceLookupResult("TYPE", nil, proto.NewSimpleString("none"))
+	assert.Equal(t, int64(0), hits)
+	assert.Equal(t, int64(1), misses)
+
+	assert.Equal(t, "1B", bytesToHuman(1))
+	assert.Equal(t, "1.50K", bytesToHuman(1536))
+	assert.Equal(t, "2.00G", bytesToHuman(2<<30))
+}
+
+// TestClientCommands 测试 CLIENT LIST/INFO/KILL/SETNAME/GETNAME/ID/PAUSE 使用的连接登记
+func TestClientCommands(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		_ = handler.ServeTCP(listener)
+	}()
+	dial := func() (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		assert.NoError(t, err)
+		return conn, bufio.NewReader(conn)
+	}
+	a, ra := dial()
+	defer a.Close()
+	b, rb := dial()
+	defer b.Close()
+	run := func(conn net.Conn, reader *bufio.Reader, args ...string) string {
+		resp, err := sendCommand(conn, reader, args[0], args[1:]...)
+		assert.NoError(t, err)
+		return resp.String()
+	}
+	clientID := func(conn net.Conn, reader *bufio.Reader) string {
+		return strings.TrimSuffix(strings.TrimPrefix(run(conn, reader, "CLIENT", "ID"), ":"), "\r\n")
+	}
+	idA, idB := clientID(a, ra), clientID(b, rb)
+	assert.NotEqual(t, idA, idB)
+
+	assert.True(t, strings.HasPrefix(run(a, ra, "CLIENT", "SETNAME", "bad name"), "-ERR Client names cannot contain spaces"))
+	assert.Equal(t, "$-1\r\n", run(a, ra, "CLIENT", "GETNAME"))
+	assert.Equal(t, "+OK\r\n", run(a, ra, "CLIENT", "SETNAME", "alpha"))
+	assert.Equal(t, "$5\r\nalpha\r\n", run(a, ra, "CLIENT", "GETNAME"))
+
+	list := run(a, ra, "CLIENT", "LIST")
+	assert.True(t, strings.Contains(list, "id="+idA+" addr="+a.LocalAddr().String()+" laddr="+listener.Addr().String()+" "))
+	assert.True(t, strings.Contains(list, " name=alpha "))
+	assert.True(t, strings.Contains(list, "id="+idB+" "))
+	assert.True(t, strings.Contains(list, "cmd=client|list "))
+	only := run(a, ra, "CLIENT", "LIST", "ID", idB)
+	assert.True(t, strings.Contains(only, "id="+idB+" "))
+	assert.False(t, strings.Contains(only, "id="+idA+" "))
+	info := run(a, ra, "CLIENT", "INFO")
+	assert.True(t, strings.Contains(info, "id="+idA+" "))
+	assert.True(t, strings.HasSuffix(info, "\n\r\n"))
+	assert.True(t, strings.HasPrefix(run(a, ra, "CLIENT", "LIST", "TYPE", "bogus"), "-ERR Unknown client type"))
+	assert.True(t, strings.HasPrefix(run(a, ra, "CLIENT", "FOO"), "-ERR unknown subcommand 'FOO'"))
+
+	// CLIENT NO-EVICT 在 CLIENT LIST 中显示为 e 标志
+	assert.Equal(t, "+OK\r\n", run(a, ra, "CLIENT", "NO-EVICT", "ON"))
+	assert.True(t, strings.Contains(run(a, ra, "CLIENT", "INFO"), " flags=e "))
+
+	// 旧格式找不到连接时返回错误，新格式返回关闭的连接数
+	assert.Equal(t, "-ERR No such client\r\n", run(a, ra, "CLIENT", "KILL", "127.0.0.1:1"))
+	assert.Equal(t, ":0\r\n", run(a, ra, "CLIENT", "KILL", "ID", idA))
+	assert.Equal(t, ":1\r\n", run(a, ra, "CLIENT", "KILL", "ID", idB))
+	_, err = sendCommand(b, rb, "PING")
+	assert.Error(t, err)
+	time.Sleep(20 * time.Millisecond)
+	assert.False(t, strings.Contains(run(a, ra, "CLIENT", "LIST"), "id="+idB+" "))
+
+	// CLIENT PAUSE WRITE 只暂停写命令；CLIENT UNPAUSE 立即放行等待中的命令
+	c, rc := dial()
+	defer c.Close()
+	assert.Equal(t, "+OK\r\n", run(a, ra, "CLIENT", "PAUSE", "10000", "WRITE"))
+	start := time.Now()
+	assert.Equal(t, "$-1\r\n", run(c, rc, "GET", "k"))
+	assert.True(t, time.Since(start) < time.Second)
+	done := make(chan time.Duration)
+	go func() {
+		start := time.Now()
+		resp, err := sendCommand(c, rc, "SET", "k", "v")
+		assert.NoError(t, err)
+		assert.Equal(t, "+OK\r\n", resp.String())
+		done <- time.Since(start)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, "+OK\r\n", run(a, ra, "CLIENT", "UNPAUSE"))
+	elapsed := <-done
+	assert.True(t, elapsed >= 100*time.Millisecond)
+	assert.True(t, elapsed < 5*time.Second)
+	assert.True(t, strings.HasPrefix(run(a, ra, "CLIENT", "PAUSE", "-1"), "-ERR timeout is negative"))
+
+	// SKIPME no 时可以关闭当前连接，回复写出后才关闭
+	assert.Equal(t, ":1\r\n", run(a, ra, "CLIENT", "KILL", "ID", idA, "SKIPME", "no"))
+	_, err = sendCommand(a, ra, "PING")
+	assert.Error(t, err)
+}
+
+// TestSlowLog 测试 SLOWLOG 按阈值记录命令，以及 CONFIG SET 调整阈值与长度
+func TestSlowLog(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	// 默认阈值 10ms，普通命令不会被记录
+	assert.Equal(t, "*2\r\n$23\r\nslowlog-log-slower-than\r\n$5\r\n10000\r\n", run("CONFIG", "GET", "slowlog-log-slower-than"))
+	run("SET", "k", "v")
+	assert.Equal(t, ":0\r\n", run("SLOWLOG", "LEN"))
+
+	// 阈值为 0 时记录所有命令，EXEC 中的命令逐条记录，AUTH 不记录
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "slowlog-log-slower-than", "0"))
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "slowlog-max-len", "4"))
+	run("SET", "k", strings.Repeat("x", 200))
+	run("AUTH", "secret")
+	run("MULTI")
+	run("GET", "k")
+	run("EXEC")
+	assert.Equal(t, ":4\r\n", run("SLOWLOG", "LEN"))
+	got := handler.processRequest(&proto.Array{Args: [][]byte{[]byte("SLOWLOG"), []byte("GET"), []byte("-1")}}, nil, "127.0.0.1:12345", nil, nil)
+	entries := got.(*proto.NestedArray).Elems
+	assert.Equal(t, 4, len(entries))
+	newest := entries[0].(*proto.NestedArray).Elems
+	assert.Equal(t, "*2\r\n$7\r\nSLOWLOG\r\n$3\r\nLEN\r\n", newest[3].String())
+	get := entries[1].(*proto.NestedArray).Elems
+	assert.Equal(t, "*2\r\n$3\r\nGET\r\n$1\r\nk\r\n", get[3].String())
+	assert.Equal(t, "$15\r\n127.0.0.1:12345\r\n", get[4].String())
+	// 更早的 CONFIG SET 因长度限制被丢弃，超长参数被截断
+	assert.Equal(t, "*1\r\n$5\r\nMULTI\r\n", entries[2].(*proto.NestedArray).Elems[3].String())
+	oldest := entries[3].(*proto.NestedArray).Elems
+	assert.True(t, strings.Contains(oldest[3].String(), "... (72 more bytes)"))
+	assert.True(t, *oldest[0].(*proto.Integer) < *newest[0].(*proto.Integer))
+	assert.Equal(t, "*1\r\n", run("SLOWLOG", "GET", "1")[:4])
+
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "slowlog-log-slower-than", "-1"))
+	assert.Equal(t, "+OK\r\n", run("SLOWLOG", "RESET"))
+	run("SET", "k", "v")
+	assert.Equal(t, ":0\r\n", run("SLOWLOG", "LEN"))
+	assert.True(t, strings.HasPrefix(run("SLOWLOG", "GET", "-2"), "-ERR count should be greater than or equal to -1"))
+	assert.True(t, strings.HasPrefix(run("CONFIG", "SET", "slowlog-max-len", "-1"), "-ERR CONFIG SET failed"))
+}
+
+// TestConfigCommand 测试 CONFIG GET 的 glob 模式、CONFIG SET 多个参数与回滚、配置文件的加载与 CONFIG REWRITE
+func TestConfigCommand(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	handler.SetStartupConfig("dir", "/data")
+	handler.SetStartupConfig("maxmemory", "ignored")
+	assert.Equal(t, "*4\r\n$9\r\nmaxmemory\r\n$1\r\n0\r\n$16\r\nmaxmemory-policy\r\n$10\r\nnoeviction\r\n", run("CONFIG", "GET", "maxmemory*"))
+	assert.Equal(t, "*4\r\n$10\r\nmaxclients\r\n$5\r\n10000\r\n$3\r\ndir\r\n$5\r\n/data\r\n", run("CONFIG", "GET", "MAXCLIENTS", "d?r"))
+	assert.Equal(t, "*0\r\n", run("CONFIG", "GET", "nothing*"))
+
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "maxmemory", "1mb", "maxmemory-policy", "allkeys-lru"))
+	assert.Equal(t, "*2\r\n$9\r\nmaxmemory\r\n$7\r\n1048576\r\n", run("CONFIG", "GET", "maxmemory"))
+	assert.True(t, strings.Contains(run("INFO", "memory"), "maxmemory_policy:allkeys-lru"))
+	// 任一参数失败时，已修改的参数恢复原值
+	assert.True(t, strings.HasPrefix(run("CONFIG", "SET", "maxmemory", "5mb", "maxmemory-policy", "sometimes"), "-ERR CONFIG SET failed (possibly related to argument 'maxmemory-policy')"))
+	assert.Equal(t, "*2\r\n$9\r\nmaxmemory\r\n$7\r\n1048576\r\n", run("CONFIG", "GET", "maxmemory"))
+	assert.Equal(t, "-ERR Unknown option or number of arguments for CONFIG SET - 'nosuch'\r\n", run("CONFIG", "SET", "nosuch", "1"))
+	assert.Equal(t, "-ERR CONFIG SET failed (possibly related to argument 'dir') - can't set immutable config\r\n", run("CONFIG", "SET", "dir", "/tmp"))
+	assert.True(t, strings.HasPrefix(run("CONFIG", "SET", "maxclients", "1", "MAXCLIENTS", "2"), "-ERR CONFIG SET failed (possibly related to argument 'maxclients') - duplicate parameter"))
+	assert.True(t, strings.HasPrefix(run("CONFIG", "SET", "maxmemory"), "-ERR wrong number of arguments"))
+	assert.Equal(t, "-ERR The server is running without a config file\r\n", run("CONFIG", "REWRITE"))
+
+	// 配置文件：命令行参数由调用方处理，其余为 CONFIG 参数
+	path := filepath.Join(t.TempDir(), "boltreon.conf")
+	assert.NoError(t, os.WriteFile(path, []byte("dir /data\nslowlog-max-len 7\nloglevel warning\n"), 0o600))
+	f, err := config.Load(path)
+	assert.NoError(t, err)
+	assert.NoError(t, handler.LoadConfigFile(f, func(name string) bool { return name == "dir" }))
+	assert.Equal(t, "*2\r\n$15\r\nslowlog-max-len\r\n$1\r\n7\r\n", run("CONFIG", "GET", "slowlog-max-len"))
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "slowlog-max-len", "128", "notify-keyspace-events", "Ex"))
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "REWRITE"))
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "dir /data\nslowlog-max-len 128\nloglevel warning\n# Generated by CONFIG REWRITE\n"+
+		"maxmemory 1048576\nmaxmemory-policy allkeys-lru\nnotify-keyspace-events xE\n", string(data))
+
+	assert.NoError(t, os.WriteFile(path, []byte("bogus 1\n"), 0o600))
+	f, err = config.Load(path)
+	assert.NoError(t, err)
+	assert.Error(t, handler.LoadConfigFile(f, nil))
+
+	// 超过 maxclients 的连接收到错误后被关闭
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "maxclients", "1"))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		_ = handler.ServeTCP(listener)
+	}()
+	first, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	defer first.Close()
+	_, err = sendCommand(first, bufio.NewReader(first), "PING")
+	assert.NoError(t, err)
+	second, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	defer second.Close()
+	line, err := bufio.NewReader(second).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "-ERR max number of clients reached\r\n", line)
+	assert.True(t, strings.Contains(run("INFO", "stats"), "rejected_connections:1\n"))
+}
+
+func TestReadCacheConfig(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	assert.Equal(t, "*4\r\n$15\r\ncache-max-bytes\r\n$8\r\n33554432\r\n$9\r\ncache-ttl\r\n$3\r\n300\r\n", run("CONFIG", "GET", "cache-*"))
+	run("SET", "k", "v")
+	run("GET", "k")
+	run("GET", "k")
+	info := run("INFO", "stats")
+	assert.True(t, strings.Contains(info, "read_cache_hits:1\n"))
+	assert.True(t, strings.Contains(info, "read_cache_misses:1\n"))
+	assert.True(t, strings.Contains(info, "read_cache_entries:1\n"))
+
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "cache-max-bytes", "1mb", "cache-ttl", "0"))
+	assert.Equal(t, int64(1<<20), handler.Db.ReadCacheSize())
+	assert.Equal(t, time.Duration(0), handler.Db.ReadCacheTTL())
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "cache-max-bytes", "0"))
+	assert.True(t, strings.Contains(run("INFO", "stats"), "read_cache_entries:0\n"))
+	assert.Equal(t, "$1\r\nv\r\n", run("GET", "k"))
+	assert.True(t, strings.HasPrefix(run("CONFIG", "SET", "cache-ttl", "-1"), "-ERR CONFIG SET failed"))
+}
+
+// TestGoldenFixtures 逐条执行 testdata/fixtures 中的语料，按字节比对黄金文件中的 Redis 响应
+// 黄金文件由 go run ./cmd/gen-fixtures 对照真实 Redis 生成
+func TestGoldenFixtures(t *testing.T) {
+	goldens, err := filepath.Glob(filepath.Join("testdata", "fixtures", "*.golden"))
+	assert.NoError(t, err)
+	assert.True(t, len(goldens) > 0)
+
+	for _, path := range goldens {
+		name := strings.TrimSuffix(filepath.Base(path), ".golden")
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open(path)
+			assert.NoError(t, err)
+			cases, err := fixtures.ReadGolden(f)
+			_ = f.Close()
+			assert.NoError(t, err)
+
+			// 黄金文件必须与语料保持同步
+			corpus, err := os.Open(strings.TrimSuffix(path, ".golden") + ".cmds")
+			assert.NoError(t, err)
+			cmds, err := fixtures.ReadCorpus(corpus)
+			_ = corpus.Close()
+			assert.NoError(t, err)
+			if len(cmds) != len(cases) {
+				t.Fatalf("%s is stale: %d commands in corpus, %d in golden file; rerun cmd/gen-fixtures", path, len(cmds), len(cases))
+			}
+
+			handler := setupTestHandler(t)
+			defer handler.Db.Close()
+			for i, c := range cases {
+				assert.Equal(t, cmds[i], c.Args)
+				req := &proto.Array{Args: make([][]byte, len(c.Args))}
+				for j, arg := range c.Args {
+					req.Args[j] = []byte(arg)
+				}
+				resp := handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil)
+				if got := resp.String(); got != string(c.Reply) {
+					t.Errorf("%s:%d %s\n  got:  %q\n  want: %q", path, c.Line, fixtures.FormatCommandLine(c.Args), got, c.Reply)
+				}
+			}
+		})
+	}
+}
+
+// TestListenReusePort 测试 SO_REUSEPORT 多监听器与 INFO clients 中的监听器统计
+func TestListenReusePort(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	listeners, err := ListenReusePort("127.0.0.1:0", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, listeners[0].Addr().String(), listeners[1].Addr().String())
+	defer func() {
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+	}()
+	go func() {
+		_ = handler.ServeListeners(listeners)
+	}()
+
+	const clients = 8
+	for i := 0; i < clients; i++ {
+		conn, err := net.Dial("tcp", listeners[0].Addr().String())
+		assert.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte("*1\r\n$4\r\nPING\r\n"))
+		assert.NoError(t, err)
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "+PONG\r\n", line)
+	}
+
+	info := handler.executeCommand("INFO", [][]byte{[]byte("clients")}, "127.0.0.1:12345").String()
+	var lines, connected, total int
+	for _, line := range strings.Split(info, "\n") {
+		if !strings.HasPrefix(line, "listener") {
+			continue
+		}
+		lines++
+		var idx, c, n int
+		var addr string
+		_, err := fmt.Sscanf(strings.ReplaceAll(strings.TrimSpace(line), ",", " "), "listener%d:addr=%s connected_clients=%d total_connections=%d", &idx, &addr, &c, &n)
+		assert.NoError(t, err)
+		connected += c
+		total += n
+	}
+	assert.Equal(t, 2, lines)
+	assert.Equal(t, clients, connected)
+	assert.Equal(t, clients, total)
+}
+
+func TestDebugKeyspaceLayout(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	assert.Equal(t, "*0\r\n", run("DEBUG", "KEYSPACE-LAYOUT", "missing"))
+
+	run("SET", "greeting", "hello")
+	assert.Equal(t, "*2\r\n"+
+		"*5\r\n$13\r\nTYPE_greeting\r\n$4\r\ntype\r\n:13\r\n:6\r\n:0\r\n"+
+		"*5\r\n$15\r\nSTRING:greeting\r\n$5\r\nvalue\r\n:15\r\n:5\r\n:0\r\n",
+		run("DEBUG", "KEYSPACE-LAYOUT", "greeting"))
+
+	run("HSET", "user", "name", "bolt")
+	assert.True(t, strings.HasPrefix(run("DEBUG", "KEYSPACE-LAYOUT", "user"), "*3\r\n"))
+
+	assert.Equal(t, "-ERR wrong number of arguments for 'debug|keyspace-layout' command\r\n", run("DEBUG", "KEYSPACE-LAYOUT"))
+	assert.Equal(t, "-ERR unknown subcommand 'NOPE'\r\n", run("DEBUG", "NOPE"))
+}
+
+func TestDebugTestHooks(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	clock := store.NewManualClock(time.Now())
+	handler.Db.SetClock(clock)
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	run("SET", "greeting", "hello")
+	assert.Equal(t, "+Value at:0x0 refcount:1 encoding:raw serializedlength:11 lru:0 lru_seconds_idle:0 type:string badger_keys:2 layout:type=1,value=1\r\n",
+		run("DEBUG", "OBJECT", "greeting"))
+	run("HSET", "user", "name", "bolt", "lang", "go")
+	assert.True(t, strings.Contains(run("DEBUG", "OBJECT", "user"), " type:hash badger_keys:4 layout:type=1,meta=1,field=2\r\n"))
+	assert.Equal(t, "-ERR no such key\r\n", run("DEBUG", "OBJECT", "missing"))
+
+	start := time.Now()
+	assert.Equal(t, "+OK\r\n", run("DEBUG", "SLEEP", "0.05"))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	assert.Equal(t, "+OK\r\n", run("DEBUG", "SLEEP", "0"))
+	assert.Equal(t, "-ERR value is not a valid float\r\n", run("DEBUG", "SLEEP", "soon"))
+
+	// 暂停主动过期时过期的键保留到被访问
+	run("SET", "session", "v", "PX", "100")
+	clock.Advance(time.Second)
+	assert.Equal(t, "+OK\r\n", run("DEBUG", "SET-ACTIVE-EXPIRE", "0"))
+	handler.runExpireCycle()
+	assert.True(t, strings.Contains(handler.buildInfoResponse("STATS"), "expired_keys:0\n"))
+	assert.Equal(t, "+OK\r\n", run("DEBUG", "SET-ACTIVE-EXPIRE", "1"))
+	handler.runExpireCycle()
+	assert.True(t, strings.Contains(handler.buildInfoResponse("STATS"), "expired_keys:1\n"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("DEBUG", "SET-ACTIVE-EXPIRE", "2"))
+
+	assert.Equal(t, "+Apparently Redis did not crash: test passed\r\n", run("DEBUG", "STRINGMATCH-LEN"))
+}
+
+func TestDebugFault(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	// 错误：命令不执行
+	assert.Equal(t, ":1\r\n", run("DEBUG", "FAULT", "ADD", "set*", "ERROR", "simulated failure"))
+	assert.Equal(t, "-ERR simulated failure\r\n", run("SET", "k", "v"))
+	assert.Equal(t, "$-1\r\n", run("GET", "k"))
+	assert.Equal(t, "*1\r\n*6\r\n:1\r\n$4\r\nSET*\r\n$5\r\nerror\r\n$21\r\nERR simulated failure\r\n$3\r\n100\r\n:1\r\n",
+		run("DEBUG", "FAULT", "LIST"))
+
+	// OFF 暂停规则，ON 恢复
+	assert.Equal(t, "+OK\r\n", run("DEBUG", "FAULT", "OFF"))
+	assert.Equal(t, "+OK\r\n", run("SET", "k", "v"))
+	assert.Equal(t, "+OK\r\n", run("DEBUG", "FAULT", "ON"))
+	assert.Equal(t, "-ERR simulated failure\r\n", run("SETEX", "k", "10", "v"))
+	assert.Equal(t, ":1\r\n", run("DEBUG", "FAULT", "DEL", "1"))
+	assert.Equal(t, ":0\r\n", run("DEBUG", "FAULT", "DEL", "1"))
+
+	// 丢弃回复：命令已执行但不回复
+	assert.Equal(t, ":2\r\n", run("DEBUG", "FAULT", "ADD", "INCR", "DROP"))
+	assert.Equal(t, "", run("INCR", "counter"))
+	assert.Equal(t, "$1\r\n1\r\n", run("GET", "counter"))
+
+	// 延迟
+	assert.Equal(t, ":3\r\n", run("DEBUG", "FAULT", "ADD", "PING", "LATENCY", "30", "PERCENT", "100"))
+	start := time.Now()
+	assert.Equal(t, "+PONG\r\n", run("PING"))
+	assert.True(t, time.Since(start) >= 30*time.Millisecond)
+
+	// DEBUG 本身不受影响；错误码原样保留
+	assert.Equal(t, ":4\r\n", run("DEBUG", "FAULT", "ADD", "*", "ERROR", "TRYAGAIN busy"))
+	assert.Equal(t, "-TRYAGAIN busy\r\n", run("GET", "counter"))
+	assert.Equal(t, "+OK\r\n", run("DEBUG", "FAULT", "CLEAR"))
+	assert.Equal(t, "*0\r\n", run("DEBUG", "FAULT", "LIST"))
+	assert.Equal(t, "$1\r\n1\r\n", run("GET", "counter"))
+
+	assert.Equal(t, "-ERR percent must be in (0, 100]\r\n", run("DEBUG", "FAULT", "ADD", "GET", "DROP", "PERCENT", "0"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("DEBUG", "FAULT", "ADD", "GET", "EXPLODE"))
+	assert.Equal(t, "-ERR invalid pattern 'GET['\r\n", run("DEBUG", "FAULT", "ADD", "GET[", "DROP"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'debug|fault|add' command\r\n", run("DEBUG", "FAULT", "ADD", "GET"))
+	assert.Equal(t, "-ERR unknown subcommand 'NOPE'\r\n", run("DEBUG", "FAULT", "NOPE"))
+}
+
+func TestValueAndCollectionLimits(t *testing.T) {
+	db, err := store.NewBotreonStoreWithOptions(t.TempDir(), store.StoreOptions{MaxValueSize: 8})
+	assert.NoError(t, err)
+	defer db.Close()
+	handler := &Handler{Db: db}
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	// max-value-size：写命令的参数与 APPEND 的结果都受限制，连接不断开
+	assert.Equal(t, "+OK\r\n", run("SET", "k", "12345678"))
+	assert.Equal(t, "-ERR value of 9 bytes exceeds the maximum value size of 8 bytes\r\n", run("SET", "k", "123456789"))
+	assert.Equal(t, "-ERR value of 9 bytes exceeds the maximum value size of 8 bytes\r\n", run("HSET", "h", "f", "123456789"))
+	assert.Equal(t, "-ERR value of 10 bytes exceeds the maximum value size of 8 bytes\r\n", run("APPEND", "k", "90"))
+	assert.Equal(t, "-ERR value of 100000000 bytes exceeds the maximum value size of 8 bytes\r\n", run("SETRANGE", "k", "99999999", "x"))
+	assert.Equal(t, "$8\r\n12345678\r\n", run("GET", "k"))
+
+	// max-collection-reply：超过上限时要求分页或 FORCE
+	handler.SetMaxCollectionReply(3)
+	run("RPUSH", "list", "a", "b", "c", "d", "e")
+	assert.Equal(t, "-ERR reply would contain 5 elements, more than max-collection-reply 3; request a smaller range or append FORCE\r\n",
+		run("LRANGE", "list", "0", "-1"))
+	assert.Equal(t, "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n", run("LRANGE", "list", "0", "2"))
+	assert.Equal(t, "*3\r\n$1\r\nc\r\n$1\r\nd\r\n$1\r\ne\r\n", run("LRANGE", "list", "-3", "100"))
+	assert.True(t, strings.HasPrefix(run("LRANGE", "list", "0", "-1", "force"), "*5\r\n"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("LRANGE", "list", "0", "-1", "NOPE"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("LRANGE", "list", "0", "-1", "FORCE", "x"))
+
+	run("HSET", "hash", "a", "1", "b", "2", "c", "3", "d", "4")
+	assert.Equal(t, "-ERR reply would contain 4 elements, more than max-collection-reply 3; use HSCAN or append FORCE\r\n", run("HGETALL", "hash"))
+	assert.True(t, strings.HasPrefix(run("HGETALL", "hash", "FORCE"), "*8\r\n"))
+	assert.True(t, strings.HasPrefix(run("HKEYS", "hash"), "-ERR reply would contain 4 elements"))
+	assert.True(t, strings.HasPrefix(run("HVALS", "hash", "FORCE"), "*4\r\n"))
+
+	run("SADD", "set", "a", "b", "c", "d")
+	assert.Equal(t, "-ERR reply would contain 4 elements, more than max-collection-reply 3; use SSCAN or append FORCE\r\n", run("SMEMBERS", "set"))
+	assert.Equal(t, "*0\r\n", run("SMEMBERS", "missing"))
+
+	handler.SetMaxCollectionReply(-1)
+	assert.True(t, strings.HasPrefix(run("LRANGE", "list", "0", "-1"), "*5\r\n"))
+}
+
+func TestAnalyzeCommand(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	assert.Equal(t, "-ERR no keyspace analysis available, run ANALYZE START first\r\n", run("ANALYZE"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("ANALYZE", "NOPE"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("ANALYZE", "START", "RATE"))
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", run("ANALYZE", "START", "RATE", "fast"))
+	assert.Equal(t, ":0\r\n", run("ANALYZE", "CANCEL"))
+
+	run("SET", "user:1", "a")
+	run("SET", "user:2", "b")
+	run("HSET", "cart:1", "sku", "1")
+	assert.Equal(t, "+OK\r\n", run("ANALYZE", "START", "TOP", "1", "DELIMITER", ":"))
+	for i := 0; i < 500 && strings.Contains(run("ANALYZE", "STATUS"), "running\r\n:1"); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	status := run("ANALYZE", "STATUS")
+	assert.True(t, strings.HasPrefix(status, "*6\r\n$7\r\nrunning\r\n:0\r\n$12\r\nscanned_keys\r\n:3\r\n"))
+
+	report := run("ANALYZE", "REPORT")
+	assert.True(t, strings.Contains(report, "keys:3\n"))
+	assert.True(t, strings.Contains(report, "type_string:keys=2,"))
+	assert.True(t, strings.Contains(report, "hash_members_le_1:1\n"))
+	assert.True(t, strings.Contains(report, "ttl_none:3\n"))
+	assert.True(t, strings.Contains(report, "# PrefixesByKeys\nprefix0:name=user,keys=2,"))
+	assert.False(t, strings.Contains(report, "prefix1:"))
+	assert.Equal(t, report, run("ANALYZE"))
+}
+
+// TestEncryptionInfo 测试 INFO persistence 中的加密状态
+func TestEncryptionInfo(t *testing.T) {
+	plain := setupTestHandler(t)
+	defer plain.Db.Close()
+	assert.True(t, strings.Contains(plain.buildInfoResponse("PERSISTENCE"), "encryption_enabled:0\n"))
+
+	keyFile := filepath.Join(t.TempDir(), "master.key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte(strings.Repeat("11", 32)), 0o600))
+	db, err := store.NewBotreonStoreWithOptions(t.TempDir(), store.StoreOptions{EncryptionKeySource: "file:" + keyFile})
+	assert.NoError(t, err)
+	handler := &Handler{Db: db}
+	defer handler.Db.Close()
+
+	info := handler.buildInfoResponse("PERSISTENCE")
+	assert.True(t, strings.Contains(info, "encryption_enabled:1\nencryption_key_source:file\nencryption_data_key_rotation_seconds:864000\n"))
+	assert.False(t, strings.Contains(info, keyFile))
+}
+
+// TestTieringInfo 测试冷热分层周期与 INFO persistence 中的统计
+func TestTieringInfo(t *testing.T) {
+	plain := setupTestHandler(t)
+	defer plain.Db.Close()
+	assert.True(t, strings.Contains(plain.buildInfoResponse("PERSISTENCE"), "tiering_enabled:0\n"))
+
+	// 开启加密时冷层使用同一个主密钥
+	keyFile := filepath.Join(t.TempDir(), "master.key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte(strings.Repeat("11", 32)), 0o600))
+	db, err := store.NewBotreonStoreWithOptions(t.TempDir(), store.StoreOptions{
+		EncryptionKeySource: "file:" + keyFile,
+		Tiering:             store.TieringOptions{Dir: t.TempDir(), MinValueSize: 1024, ColdAfter: time.Hour},
+	})
+	assert.NoError(t, err)
+	clock := store.NewManualClock(time.Now())
+	db.SetClock(clock)
+	handler := &Handler{Db: db}
+	defer handler.Db.Close()
+
+	// 伪随机内容，压缩后仍超过 MinValueSize
+	blob := make([]byte, 4096)
+	x := uint32(1)
+	for i := range blob {
+		x = x*1664525 + 1013904223
+		blob[i] = byte('a' + (x>>24)%26)
+	}
+	assert.NoError(t, db.Set("blob", string(blob)))
+	handler.runTierCycle()
+	clock.Advance(2 * time.Hour)
+	handler.runTierCycle()
+
+	info := handler.buildInfoResponse("PERSISTENCE")
+	assert.True(t, strings.Contains(info, "tiering_enabled:1\n"))
+	assert.True(t, strings.Contains(info, "tier_demoted:1\n"))
+	assert.True(t, strings.Contains(info, "tier_cold_bytes:"))
+
+	dump, err := db.Dump("blob")
+	assert.NoError(t, err)
+	assert.True(t, bytes.Contains(dump, blob))
+}
+
+func TestLatencyMetrics(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	handler.SetLatencyBuckets([]time.Duration{time.Hour, time.Millisecond})
+	run("SET", "k", "v")
+	run("GET", "k")
+	run("LPUSH", "k", "x") // WRONGTYPE
+	run("NOSUCHCOMMAND")
+
+	var b bytes.Buffer
+	assert.NoError(t, handler.WriteOpenMetrics(&b))
+	metrics := b.String()
+	assert.True(t, strings.HasPrefix(metrics, "# TYPE boltreon_command_duration_seconds histogram\n# UNIT boltreon_command_duration_seconds seconds\n"))
+	assert.True(t, strings.HasSuffix(metrics, "# EOF\n"))
+	assert.True(t, strings.Contains(metrics, `boltreon_command_duration_seconds_bucket{family="string",result="ok",le="0.001"} `))
+	assert.True(t, strings.Contains(metrics, `boltreon_command_duration_seconds_bucket{family="string",result="ok",le="3600"} 2`+"\n"))
+	assert.True(t, strings.Contains(metrics, `boltreon_command_duration_seconds_bucket{family="string",result="ok",le="+Inf"} 2`+"\n"))
+	assert.True(t, strings.Contains(metrics, `boltreon_command_duration_seconds_count{family="list",result="error"} 1`+"\n"))
+	assert.False(t, strings.Contains(metrics, `family="server"`))
+
+	info := handler.buildInfoResponse("LATENCYSTATS")
+	assert.True(t, strings.HasPrefix(info, "# Latencystats\nlatency_percentiles_usec_get:p50="))
+	assert.True(t, strings.Contains(info, "latency_percentiles_usec_set:p50="))
+	assert.True(t, strings.Contains(info, ",p99="))
+	assert.False(t, strings.Contains(info, "nosuchcommand"))
+
+	rec := httptest.NewRecorder()
+	handler.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, "application/openmetrics-text; version=1.0.0; charset=utf-8", rec.Header().Get("Content-Type"))
+	// 直方图之后的运行时间、内存等指标每次读取都可能变化
+	histograms := metrics[:strings.Index(metrics, "# TYPE boltreon_uptime_seconds")]
+	assert.True(t, strings.HasPrefix(rec.Body.String(), histograms))
+}
+
+func TestServerMetrics(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	handler.PubSub = store.NewPubSubManager()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	run("SET", "k", "v")
+	run("GET", "k")
+	run("GET", "missing")
+	run("LPUSH", "k", "x") // WRONGTYPE
+	run("GET")             // 参数个数错误
+	run("XADD", "events", "1-1", "f", "v")
+	run("XADD", "events", "1-2", "f", "v")
+	run("XGROUP", "CREATE", "events", "workers", "0")
+	run("XREADGROUP", "GROUP", "workers", "alice", "COUNT", "1", "STREAMS", "events", ">")
+
+	sub := store.NewSubscriber("sub")
+	handler.PubSub.Subscribe(sub, "news")
+	handler.PubSub.Publish("news", []byte("a"))
+	handler.PubSub.Publish("news", []byte("b"))
+
+	var b bytes.Buffer
+	assert.NoError(t, handler.WriteOpenMetrics(&b))
+	metrics := b.String()
+	for _, line := range []string{
+		"# TYPE boltreon_commands counter",
+		`boltreon_commands_total{command="set",result="ok"} 1`,
+		`boltreon_commands_total{command="get",result="ok"} 2`,
+		`boltreon_commands_total{command="get",result="rejected"} 1`,
+		`boltreon_commands_total{command="lpush",result="error"} 1`,
+		"boltreon_keyspace_hits_total 1",
+		"boltreon_keyspace_misses_total 1",
+		"# TYPE boltreon_connected_clients gauge",
+		"boltreon_streams 1",
+		"boltreon_stream_entries 2",
+		"boltreon_stream_groups 1",
+		"boltreon_stream_pending_entries 1",
+		"boltreon_pubsub_channels 1",
+		"boltreon_pubsub_subscribers 1",
+		"boltreon_pubsub_pending_messages 2",
+		"boltreon_pubsub_published_total 2",
+		"# TYPE boltreon_badger_puts counter",
+	} {
+		assert.True(t, strings.Contains(metrics, line+"\n"))
+	}
+	assert.True(t, strings.HasSuffix(metrics, "# EOF\n"))
+
+	// Stream 积压按 streamBacklogTTL 缓存
+	run("XADD", "events", "1-3", "f", "v")
+	b.Reset()
+	assert.NoError(t, handler.WriteOpenMetrics(&b))
+	assert.True(t, strings.Contains(b.String(), "boltreon_stream_entries 2\n"))
+}
+
+func TestLatencyHelpers(t *testing.T) {
+	buckets, err := ParseLatencyBuckets("100us, 1ms,10ms")
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{100 * time.Microsecond, time.Millisecond, 10 * time.Millisecond}, buckets)
+	_, err = ParseLatencyBuckets("1ms,fast")
+	assert.Error(t, err)
+	_, err = ParseLatencyBuckets("")
+	assert.Error(t, err)
+
+	assert.Equal(t, "zset", commandFamily("BZPOPMIN"))
+	assert.Equal(t, "stream", commandFamily("XADD"))
+	assert.Equal(t, "timeseries", commandFamily("TS.ADD"))
+	assert.Equal(t, "hash", commandFamily("HGET"))
+	assert.Equal(t, "keyspace", commandFamily("EXPIRE"))
+	assert.Equal(t, "server", commandFamily("PING"))
+
+	samples := make([]time.Duration, 100)
+	for i := range samples {
+		samples[i] = time.Duration(i+1) * time.Millisecond
+	}
+	assert.Equal(t, 50*time.Millisecond, percentile(samples, 50))
+	assert.Equal(t, 99*time.Millisecond, percentile(samples, 99))
+	assert.Equal(t, 100*time.Millisecond, percentile(samples, 99.9))
+	assert.Equal(t, time.Duration(0), percentile(nil, 99))
+}
+
+func TestExpireStatsInfo(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	clock := store.NewManualClock(time.Now())
+	handler.Db.SetClock(clock)
+
+	assert.NoError(t, handler.Db.Set("session", "v"))
+	ok, err := handler.Db.Expire("session", 30)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, handler.Db.Set("cache", "v"))
+	ok, err = handler.Db.Expire("cache", 3600*48)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	handler.runExpireCycle()
+	info := handler.buildInfoResponse("STATS")
+	assert.True(t, strings.Contains(info, "expired_keys:0\n"))
+	assert.True(t, strings.Contains(info, "expires_ttl_le_1m:1\n"))
+	assert.True(t, strings.Contains(info, "expires_ttl_le_7d:1\n"))
+
+	clock.Advance(time.Minute)
+	handler.runExpireCycle()
+	info = handler.buildInfoResponse("STATS")
+	assert.True(t, strings.Contains(info, "expired_keys:1\n"))
+	assert.True(t, strings.Contains(info, "expires_ttl_le_1m:0\n"))
+	assert.True(t, strings.Contains(info, "expires_scan_passes:2\n"))
+	exists, err := handler.Db.Exists("session")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestActiveExpireSweeper(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	handler.PubSub = store.NewPubSubManager()
+	clock := store.NewManualClock(time.Now())
+	handler.Db.SetClock(clock)
+
+	run := func(args ...string) string {
+		cmdArgs := make([][]byte, len(args)-1)
+		for i, a := range args[1:] {
+			cmdArgs[i] = []byte(a)
+		}
+		return handler.executeCommand(args[0], cmdArgs, "127.0.0.1:12345").String()
+	}
+
+	// 默认参数与 CONFIG SET 校验
+	assert.Equal(t, "*2\r\n$22\r\nactive-expire-interval\r\n$3\r\n100\r\n", run("CONFIG", "GET", "active-expire-interval"))
+	assert.Equal(t, "*2\r\n$18\r\nactive-expire-keys\r\n$3\r\n200\r\n", run("CONFIG", "GET", "active-expire-keys"))
+	assert.Equal(t, "*2\r\n$22\r\nnotify-keyspace-events\r\n$0\r\n\r\n", run("CONFIG", "GET", "notify-keyspace-events"))
+	assert.Equal(t, "-ERR CONFIG SET failed (possibly related to argument 'active-expire-keys') - argument couldn't be parsed into an integer\r\n",
+		run("CONFIG", "SET", "active-expire-keys", "many"))
+	assert.Equal(t, "-ERR CONFIG SET failed (possibly related to argument 'active-expire-interval') - argument must be between 1 and 60000 inclusive\r\n",
+		run("CONFIG", "SET", "active-expire-interval", "0"))
+	assert.True(t, strings.HasPrefix(run("CONFIG", "SET", "notify-keyspace-events", "Kq"), "-ERR CONFIG SET failed"))
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "active-expire-interval", "1000"))
+	assert.Equal(t, time.Second, handler.expireInterval())
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "active-expire-keys", "4"))
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "notify-keyspace-events", "Ex"))
+	assert.Equal(t, "*2\r\n$22\r\nnotify-keyspace-events\r\n$2\r\nxE\r\n", run("CONFIG", "GET", "notify-keyspace-events"))
+
+	sub := store.NewSubscriber("sub")
+	handler.PubSub.Subscribe(sub, "__keyevent@0__:expired", "__keyspace@0__:bulk:0")
+
+	// 批量写入的键同时过期：过期比例高时同一次调度连续执行多轮，不受每轮 4 个键的限制
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, handler.Db.SetWithTTL(fmt.Sprintf("bulk:%d", i), "v", time.Minute))
+	}
+	assert.NoError(t, handler.Db.Set("keep", "v"))
+	clock.Advance(2 * time.Minute)
+	handler.runExpireCycle()
+	exists, err := handler.Db.Exists("bulk:9")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = handler.Db.Exists("keep")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, int64(10), handler.Db.ExpireStats().ExpiredKeys)
+
+	// 只开启了 keyevent 通知
+	expired := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		msg := <-sub.MessageCh
+		assert.Equal(t, "__keyevent@0__:expired", msg.Channel)
+		expired[string(msg.Data)] = true
+	}
+	assert.Equal(t, 10, len(expired))
+	select {
+	case msg := <-sub.MessageCh:
+		t.Fatalf("unexpected event on %s", msg.Channel)
+	default:
+	}
+
+	// A 包含 x；RunExpireSweeper 按设置的间隔执行
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "notify-keyspace-events", "KA"))
+	assert.Equal(t, "*2\r\n$22\r\nnotify-keyspace-events\r\n$2\r\nAK\r\n", run("CONFIG", "GET", "notify-keyspace-events"))
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "active-expire-interval", "20"))
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		handler.RunExpireSweeper(stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+	assert.NoError(t, handler.Db.SetWithTTL("bulk:0", "v", time.Minute))
+	clock.Advance(2 * time.Minute)
+	select {
+	case msg := <-sub.MessageCh:
+		assert.Equal(t, "__keyspace@0__:bulk:0", msg.Channel)
+		assert.Equal(t, "expired", string(msg.Data))
+	case <-time.After(5 * time.Second):
+		t.Fatal("sweeper did not expire the key")
+	}
+}
+
+func TestDecompressCacheInfo(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	// HGET 命中读缓存时不会解压
+	handler.Db.SetReadCacheSize(0)
+
+	assert.NoError(t, handler.Db.HSet("h", "f", strings.Repeat("compressible ", 500)))
+	for i := 0; i < 4; i++ {
+		_, err := handler.Db.HGet("h", "f")
+		assert.NoError(t, err)
+	}
+	info := handler.buildInfoResponse("STATS")
+	assert.True(t, strings.Contains(info, "decompress_cache_hits:3\n"))
+	assert.True(t, strings.Contains(info, "decompress_cache_misses:1\n"))
+	assert.True(t, strings.Contains(info, "decompress_cache_hit_ratio:0.7500\n"))
+	assert.True(t, strings.Contains(info, "decompress_cache_entries:1\n"))
+}
+
+func TestMirrorCommand(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	// 未配置上游
+	assert.Equal(t, "$19\r\n# Mirror\nenabled:0\n\r\n", run("BOLTREON.MIRROR"))
+	assert.True(t, strings.HasPrefix(run("BOLTREON.MIRROR", "PAUSE"), "-ERR mirroring is not enabled"))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	received := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			req, err := proto.ReadRESP(r)
+			if err != nil {
+				return
+			}
+			received <- string(bytes.Join(req.Args, []byte(" ")))
+			if _, err := conn.Write([]byte("+OK\r\n")); err != nil {
+				return
+			}
+		}
+	}()
+	handler.Mirror = mirror.New(ln.Addr().String(), mirror.Options{})
+	defer handler.Mirror.Close()
+
+	// 读命令、出错的写命令与 Boltreon 特有的命令不转发
+	assert.Equal(t, "+OK\r\n", run("SET", "k", "v"))
+	assert.Equal(t, "$1\r\nv\r\n", run("GET", "k"))
+	assert.True(t, strings.HasPrefix(run("LPUSH", "k", "x"), "-WRONGTYPE"))
+	assert.Equal(t, "+OK\r\n", run("MULTI"))
+	assert.Equal(t, "+QUEUED\r\n", run("HSET", "h", "f", "v"))
+	assert.Equal(t, "*1\r\n:1\r\n", run("EXEC"))
+	assert.Equal(t, "+OK\r\n", run("BOLTREON.MIRROR", "PAUSE"))
+	assert.Equal(t, ":1\r\n", run("DEL", "k"))
+	assert.Equal(t, "+OK\r\n", run("BOLTREON.MIRROR", "RESUME"))
+	assert.Equal(t, ":1\r\n", run("INCR", "n"))
+
+	assert.Equal(t, "SET k v", <-received)
+	assert.Equal(t, "HSET h f v", <-received)
+	assert.Equal(t, "INCR n", <-received)
+	deadline := time.Now().Add(5 * time.Second)
+	for handler.Mirror.Stats().Forwarded < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	status := run("BOLTREON.MIRROR", "STATUS")
+	assert.True(t, strings.Contains(status, "forwarded:3\n"))
+	assert.True(t, strings.Contains(status, "skipped:1\n"))
+	assert.True(t, strings.Contains(status, "paused:0\n"))
+	assert.True(t, strings.Contains(status, "connected:1\n"))
+}
+
+func TestShadowRead(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+	defer func() {
+		shadowImplsMu.Lock()
+		delete(shadowImpls, "GET")
+		delete(shadowImpls, "SMEMBERS")
+		shadowImplsMu.Unlock()
+	}()
+
+	run("SET", "k", "v")
+	run("SADD", "s", "a", "b", "c")
+	wrong := false
+	RegisterShadow("GET", ShadowImpl{Run: func(h *Handler, args [][]byte) proto.RESP {
+		if wrong {
+			return proto.NewBulkString([]byte("other"))
+		}
+		return proto.NewBulkString([]byte("v"))
+	}})
+	// 顺序不同但元素相同不算不一致
+	RegisterShadow("SMEMBERS", ShadowImpl{Unordered: true, Run: func(h *Handler, args [][]byte) proto.RESP {
+		return &proto.Array{Args: [][]byte{[]byte("c"), []byte("b"), []byte("a")}}
+	}})
+
+	// 比例为 0 时不执行
+	assert.Equal(t, "$1\r\nv\r\n", run("GET", "k"))
+	assert.True(t, !strings.Contains(run("BOLTREON.SHADOW"), "shadow_get:"))
+
+	assert.Equal(t, "+OK\r\n", run("BOLTREON.SHADOW", "PERCENT", "100"))
+	assert.Equal(t, "$1\r\nv\r\n", run("GET", "k"))
+	wrong = true
+	// 客户端始终收到当前实现的回复
+	assert.Equal(t, "$1\r\nv\r\n", run("GET", "k"))
+	run("SMEMBERS", "s")
+	run("TTL", "k")
+
+	status := run("BOLTREON.SHADOW", "STATUS")
+	assert.True(t, strings.Contains(status, "percent:100\n"))
+	assert.True(t, strings.Contains(status, "registered:get,smembers\n"))
+	assert.True(t, strings.Contains(status, "shadow_get:sampled=2,mismatches=1,errors=0,"))
+	assert.True(t, strings.Contains(status, "shadow_smembers:sampled=1,mismatches=0,errors=0,"))
+	assert.True(t, !strings.Contains(status, "shadow_ttl:"))
+
+	// 候选实现 panic 计为错误，不影响回复
+	RegisterShadow("GET", ShadowImpl{Run: func(h *Handler, args [][]byte) proto.RESP { panic("boom") }})
+	assert.Equal(t, "$1\r\nv\r\n", run("GET", "k"))
+	assert.True(t, strings.Contains(run("BOLTREON.SHADOW"), "shadow_get:sampled=3,mismatches=1,errors=1,"))
+
+	assert.Equal(t, "+OK\r\n", run("BOLTREON.SHADOW", "RESET"))
+	assert.True(t, !strings.Contains(run("BOLTREON.SHADOW"), "shadow_get:"))
+	assert.True(t, strings.HasPrefix(run("BOLTREON.SHADOW", "PERCENT", "150"), "-ERR"))
+	assert.True(t, strings.HasPrefix(run("BOLTREON.SHADOW", "BOGUS"), "-ERR unknown subcommand"))
+	assert.True(t, strings.HasPrefix(run("BOLTREON.SHADOW", "RESET", "x"), "-ERR wrong number"))
+}
+
+// TestRESP3 测试 HELLO 3 协商后的带类型回复
+func TestRESP3(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	run("HSET", "h", "f", "v")
+	run("SADD", "s", "a")
+	run("ZADD", "z", "1.5", "m", "2", "n")
+	// RESP2 下不变
+	assert.Equal(t, "*2\r\n$1\r\nf\r\n$1\r\nv\r\n", run("HGETALL", "h"))
+	assert.Equal(t, "$-1\r\n", run("GET", "missing"))
+
+	reply := run("HELLO", "3")
+	assert.True(t, strings.HasPrefix(reply, "%9\r\n$6\r\nserver\r\n"))
+	assert.True(t, strings.Contains(reply, "$5\r\nproto\r\n:3\r\n"))
+
+	assert.Equal(t, "_\r\n", run("GET", "missing"))
+	assert.Equal(t, "%1\r\n$1\r\nf\r\n$1\r\nv\r\n", run("HGETALL", "h"))
+	assert.Equal(t, "~1\r\n$1\r\na\r\n", run("SMEMBERS", "s"))
+	assert.Equal(t, ",1.5\r\n", run("ZSCORE", "z", "m"))
+	assert.Equal(t, "_\r\n", run("ZSCORE", "z", "none"))
+	assert.Equal(t, "*2\r\n,1.5\r\n,2\r\n", run("ZMSCORE", "z", "m", "n"))
+	assert.Equal(t, "*2\r\n*2\r\n$1\r\nm\r\n,1.5\r\n*2\r\n$1\r\nn\r\n,2\r\n", run("ZRANGE", "z", "0", "-1", "WITHSCORES"))
+	assert.Equal(t, "*2\r\n$1\r\nm\r\n$1\r\nn\r\n", run("ZRANGE", "z", "0", "-1"))
+	assert.Equal(t, "*2\r\n$1\r\nv\r\n_\r\n", run("HMGET", "h", "f", "x"))
+	assert.True(t, strings.HasPrefix(run("CONFIG", "GET", "*"), "%29\r\n$4\r\nsave\r\n$0\r\n\r\n"))
+	assert.Equal(t, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", run("HGETALL", "s"))
+
+	// 事务中的回复逐条转换
+	run("MULTI")
+	run("ZSCORE", "z", "n")
+	run("HGETALL", "h")
+	assert.Equal(t, "*2\r\n,2\r\n%1\r\n$1\r\nf\r\n$1\r\nv\r\n", run("EXEC"))
+
+	// HELLO 2 切换回 RESP2
+	reply = run("HELLO", "2")
+	assert.True(t, strings.HasPrefix(reply, "*18\r\n"))
+	assert.Equal(t, "$-1\r\n", run("GET", "missing"))
+}
+
+// TestRESP3Push 测试 RESP3 连接上订阅确认与消息以 Push 发送
+func TestRESP3Push(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	handler.PubSub = store.NewPubSubManager()
+	handler.PubSub.EnableDurable(handler.Db, 100)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		_ = handler.ServeTCP(listener)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	// HELLO 3 的回复是 Map，读到随后的 PONG 为止
+	assert.NoError(t, proto.WriteRESP(conn, &proto.Array{Args: [][]byte{[]byte("HELLO"), []byte("3")}}))
+	assert.NoError(t, proto.WriteRESP(conn, &proto.Array{Args: [][]byte{[]byte("PING")}}))
+	for {
+		line, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		if line == "+PONG\r\n" {
+			break
+		}
+	}
+
+	assert.NoError(t, proto.WriteRESP(conn, &proto.Array{Args: [][]byte{
+		[]byte("SUBSCRIBE"), []byte("news"), []byte("RESUME"), []byte("$"),
+	}}))
+	expectRESP(t, reader, proto.NewPush([]proto.RESP{
+		proto.NewBulkString([]byte("subscribe")), proto.NewBulkString([]byte("news")), proto.NewInteger(1),
+	}))
+	handler.PubSub.Publish("news", []byte("hello"))
+	msgs, err := handler.PubSub.Replay("news", "0", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(msgs))
+	push, _ := proto.Elems(messagePush(msgs[0]))
+	expectRESP(t, reader, proto.NewPush(push))
+
+	assert.NoError(t, proto.WriteRESP(conn, &proto.Array{Args: [][]byte{[]byte("UNSUBSCRIBE")}}))
+	expectRESP(t, reader, proto.NewPush([]proto.RESP{
+		proto.NewBulkString([]byte("unsubscribe")), proto.NewBulkString([]byte("news")), proto.NewInteger(0),
+	}))
+}
+
+// TestTLSListener 测试 TLS 监听器与 -tls-auth-replicas 对 PSYNC 的限制
+func TestTLSListener(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	handler.SetRequireReplicaCert(true)
+
+	testdata := filepath.Join("..", "tlsconf", "testdata")
+	serverCfg, err := tlsconf.ServerConfig(tlsconf.Options{
+		CertFile: filepath.Join(testdata, "server.pem"),
+		KeyFile:  filepath.Join(testdata, "server-key.pem"),
+		CAFile:   filepath.Join(testdata, "ca.pem"),
+	})
+	assert.NoError(t, err)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	listener := tls.NewListener(ln, serverCfg)
+	defer listener.Close()
+	go func() {
+		_ = handler.ServeTCP(listener)
+	}()
+
+	dial := func(opts tlsconf.Options) (net.Conn, *bufio.Reader) {
+		cfg, err := tlsconf.ClientConfig(opts)
+		assert.NoError(t, err)
+		conn, err := tls.Dial("tcp", ln.Addr().String(), cfg)
+		assert.NoError(t, err)
+		return conn, bufio.NewReader(conn)
+	}
+	ca := filepath.Join(testdata, "ca.pem")
+
+	// 不出示证书的普通客户端可以执行命令，但不能 PSYNC
+	conn, reader := dial(tlsconf.Options{CAFile: ca})
+	defer conn.Close()
+	resp, err := sendCommand(conn, reader, "PING")
+	assert.NoError(t, err)
+	assert.Equal(t, "+PONG\r\n", resp.String())
+	resp, err = sendCommand(conn, reader, "PSYNC", "?", "-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "-ERR replication requires a verified TLS client certificate\r\n", resp.String())
+
+	// 出示证书的从节点通过检查
+	replica, replicaReader := dial(tlsconf.Options{
+		CertFile: filepath.Join(testdata, "client.pem"),
+		KeyFile:  filepath.Join(testdata, "client-key.pem"),
+		CAFile:   ca,
+	})
+	defer replica.Close()
+	resp, err = sendCommand(replica, replicaReader, "PSYNC", "?", "-1")
+	assert.NoError(t, err)
+	assert.True(t, !strings.Contains(resp.String(), "TLS client certificate"))
+
+	// 明文连接在 TLS 端口上无法完成握手
+	plain, err := net.Dial("tcp", ln.Addr().String())
+	assert.NoError(t, err)
+	defer plain.Close()
+	_, err = sendCommand(plain, bufio.NewReader(plain), "PING")
+	assert.Error(t, err)
+}
+
+// TestScripting 测试 EVAL/EVALSHA/SCRIPT 与 redis.call 的回复转换
+func TestScripting(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	assert.Equal(t, ":1\r\n", run("EVAL", "return 1", "0"))
+	assert.Equal(t, ":3\r\n", run("EVAL", "return 3.99", "0"))
+	assert.Equal(t, "*2\r\n$1\r\nk\r\n$1\r\nv\r\n", run("EVAL", "return {KEYS[1], ARGV[1]}", "1", "k", "v"))
+	assert.Equal(t, "*1\r\n:1\r\n", run("EVAL", "return {1, nil, 3}", "0"))
+	assert.Equal(t, "$-1\r\n", run("EVAL", "return false", "0"))
+	assert.Equal(t, "+OK\r\n", run("EVAL", "return redis.call('SET', KEYS[1], ARGV[1])", "1", "cas", "v1"))
+	assert.Equal(t, "$-1\r\n", run("EVAL", "return redis.call('GET', 'missing')", "0"))
+	assert.Equal(t, "+done\r\n", run("EVAL", "return redis.status_reply('done')", "0"))
+	assert.Equal(t, "-MYERR custom\r\n", run("EVAL", "return redis.error_reply('MYERR custom')", "0"))
+
+	// 检查再写入
+	cas := "if redis.call('GET', KEYS[1]) == ARGV[1] then redis.call('SET', KEYS[1], ARGV[2]); return 1 end; return 0"
+	assert.Equal(t, ":1\r\n", run("EVAL", cas, "1", "cas", "v1", "v2"))
+	assert.Equal(t, ":0\r\n", run("EVAL", cas, "1", "cas", "v1", "v3"))
+	assert.Equal(t, "$2\r\nv2\r\n", run("GET", "cas"))
+
+	// redis.call 的错误终止脚本并原样返回，redis.pcall 返回错误表
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", run("EVAL", "return redis.call('INCR', KEYS[1])", "1", "cas"))
+	assert.Equal(t, "$9\r\nWRONGTYPE\r\n",
+		run("EVAL", "local r = redis.pcall('LPUSH', KEYS[1], 'x'); return string.sub(r.err, 1, 9)", "1", "cas"))
+	assert.Equal(t, "-ERR This Redis command is not allowed from script\r\n", run("EVAL", "return redis.call('EVAL', 'return 1', 0)", "0"))
+	assert.True(t, strings.HasPrefix(run("EVAL", "return redis.call('NOPE')", "0"), "-ERR unknown command 'NOPE'"))
+	assert.True(t, strings.HasPrefix(run("EVAL", "error('boom')", "0"), "-ERR Error running script: "))
+	assert.True(t, strings.HasPrefix(run("EVAL", "return (", "0"), "-ERR Error compiling script"))
+
+	// 已编码的回复（SCAN）与整数参数
+	run("SET", "n", "5")
+	assert.Equal(t, "$1\r\n0\r\n", run("EVAL", "return redis.call('SCAN', 0)[1]", "0"))
+	assert.Equal(t, ":12\r\n", run("EVAL", "return redis.call('INCRBY', KEYS[1], 7)", "1", "n"))
+
+	// 不能访问文件与系统
+	assert.Equal(t, "$3\r\nnil\r\n", run("EVAL", "return type(os) .. '' .. type(io) == 'nilnil' and type(loadfile) or 'x'", "0"))
+
+	// SCRIPT LOAD / EXISTS / EVALSHA / FLUSH
+	sha := scriptSHA([]byte("return ARGV[1]"))
+	assert.Equal(t, "$40\r\n"+sha+"\r\n", run("SCRIPT", "LOAD", "return ARGV[1]"))
+	assert.Equal(t, "*2\r\n:1\r\n:0\r\n", run("SCRIPT", "EXISTS", sha, "0000"))
+	assert.Equal(t, "$2\r\nhi\r\n", run("EVALSHA", strings.ToUpper(sha), "0", "hi"))
+	assert.Equal(t, "+OK\r\n", run("SCRIPT", "FLUSH"))
+	assert.Equal(t, "-NOSCRIPT No matching script. Please use EVAL.\r\n", run("EVALSHA", sha, "0"))
+	assert.Equal(t, "-ERR unknown subcommand 'KILLALL'\r\n", run("SCRIPT", "KILLALL"))
+
+	// numkeys 检查
+	assert.Equal(t, "-ERR Number of keys can't be greater than number of args\r\n", run("EVAL", "return 1", "2", "a"))
+	assert.Equal(t, "-ERR Number of keys can't be negative\r\n", run("EVAL", "return 1", "-1"))
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", run("EVAL", "return 1", "x"))
+}
+
+// TestScriptEffectsReplication 测试脚本的写命令逐条传播，而不是传播 EVAL 本身
+func TestScriptEffectsReplication(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	repl := replication.NewReplicationManager(handler.Db)
+	handler.Replication = repl
+
+	masterSide, replicaSide := net.Pipe()
+	slave := replication.NewSlaveConnection(masterSide)
+	slave.SetReady(true)
+	repl.AddSlave(slave)
+	received := make(chan string, 1)
+	go func() {
+		b, _ := io.ReadAll(replicaSide)
+		received <- string(b)
+	}()
+
+	req := &proto.Array{Args: [][]byte{
+		[]byte("EVAL"),
+		[]byte("redis.call('SET', KEYS[1], 'x'); redis.call('GET', KEYS[1]); return redis.call('INCR', KEYS[2])"),
+		[]byte("2"), []byte("a"), []byte("n"),
+	}}
+	assert.Equal(t, ":1\r\n", handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String())
+	_ = slave.Close()
+
+	got := <-received
+	assert.Equal(t, "*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\nx\r\n*2\r\n$4\r\nINCR\r\n$1\r\nn\r\n", got)
+}
+
+// TestScanCommands 测试 SCAN/SSCAN/HSCAN/ZSCAN 的回复格式、选项与游标校验
+func TestScanCommands(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	run("SET", "k1", "v")
+	run("RPUSH", "k2", "a")
+	run("HSET", "h", "f", "v")
+	run("SADD", "s", "m")
+	run("ZADD", "z", "1.5", "m")
+
+	assert.Equal(t, "*2\r\n$1\r\n0\r\n*2\r\n$2\r\nk1\r\n$2\r\nk2\r\n", run("SCAN", "0", "MATCH", "k*"))
+	assert.Equal(t, "*2\r\n$1\r\n0\r\n*1\r\n$2\r\nk2\r\n", run("SCAN", "0", "TYPE", "list"))
+	assert.Equal(t, "*2\r\n$1\r\n0\r\n*2\r\n$1\r\nf\r\n$1\r\nv\r\n", run("HSCAN", "h", "0"))
+	assert.Equal(t, "*2\r\n$1\r\n0\r\n*1\r\n$1\r\nf\r\n", run("HSCAN", "h", "0", "NOVALUES"))
+	assert.Equal(t, "*2\r\n$1\r\n0\r\n*1\r\n$1\r\nm\r\n", run("SSCAN", "s", "0"))
+	assert.Equal(t, "*2\r\n$1\r\n0\r\n*2\r\n$1\r\nm\r\n$3\r\n1.5\r\n", run("ZSCAN", "z", "0"))
+	assert.Equal(t, "*2\r\n$1\r\n0\r\n*0\r\n", run("SSCAN", "missing", "0"))
+
+	// 分页：下一页从上一页结束的位置继续
+	first := run("SCAN", "0", "COUNT", "2")
+	assert.True(t, !strings.HasPrefix(first, "*2\r\n$1\r\n0\r\n"))
+	cursor := strings.Split(first, "\r\n")[2]
+	assert.True(t, strings.HasPrefix(run("SCAN", cursor, "COUNT", "10"), "*2\r\n$1\r\n0\r\n*3\r\n"))
+
+	assert.Equal(t, "-ERR invalid cursor\r\n", run("SCAN", "abc"))
+	assert.Equal(t, "-ERR invalid cursor\r\n", run("SCAN", "987654321"))
+	assert.Equal(t, "-ERR invalid cursor\r\n", run("HSCAN", "h", cursor))
+	assert.Equal(t, "-ERR syntax error\r\n", run("SCAN", "0", "COUNT", "0"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("SSCAN", "s", "0", "NOVALUES"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'hscan' command\r\n", run("HSCAN", "h"))
+	assert.Equal(t, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", run("ZSCAN", "h", "0"))
+}
+
+func TestAOF(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	assert.NoError(t, handler.ConfigureAOF(path, "always"))
+	assert.NoError(t, handler.StartAOF())
+
+	runOn := func(h *Handler, args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return h.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+	run := func(args ...string) string { return runOn(handler, args...) }
+	logged := func() []string {
+		var cmds []string
+		_, err := aof.Load(path, func(args [][]byte) error {
+			parts := make([]string, len(args))
+			for i, a := range args {
+				parts[i] = string(a)
+			}
+			cmds = append(cmds, strings.Join(parts, " "))
+			return nil
+		})
+		assert.NoError(t, err)
+		return cmds
+	}
+
+	run("SET", "a", "1")
+	run("INCR", "a")
+	run("GET", "a")
+	run("INCR", "missing-type-check")
+	run("RPUSH", "l", "x", "y")
+	assert.Equal(t, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", run("INCR", "l"))
+	run("SETEX", "t", "100", "v")
+	run("MULTI")
+	run("SADD", "s", "m1", "m2", "m3")
+	run("SPOP", "s")
+	run("EXEC")
+	popped := run("BLPOP", "l", "1")
+	id := run("XADD", "st", "*", "f", "v")
+
+	cmds := logged()
+	assert.Equal(t, "SET a 1", cmds[0])
+	assert.Equal(t, "INCR a", cmds[1])
+	assert.Equal(t, "INCR missing-type-check", cmds[2])
+	assert.Equal(t, "RPUSH l x y", cmds[3])
+	// 相对过期时间改为绝对时间，SPOP 改为 SREM，阻塞弹出改为非阻塞弹出，Stream ID 改为实际的值
+	assert.Equal(t, "SET t v", cmds[4])
+	assert.True(t, strings.HasPrefix(cmds[5], "PEXPIREAT t "))
+	assert.Equal(t, "SADD s m1 m2 m3", cmds[6])
+	assert.True(t, strings.HasPrefix(cmds[7], "SREM s m"))
+	assert.Equal(t, "LPOP l", cmds[8])
+	assert.Equal(t, "XADD st "+strings.Split(id, "\r\n")[1]+" f v", cmds[9])
+	assert.Equal(t, 10, len(cmds))
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(data), "*1\r\n$5\r\nMULTI\r\n*5\r\n$4\r\nSADD"))
+	assert.True(t, strings.Contains(run("INFO", "persistence"), "aof_enabled:1\n"))
+
+	// 重放到空的存储中
+	check := func(h *Handler) {
+		assert.Equal(t, "$1\r\n2\r\n", runOn(h, "GET", "a"))
+		assert.Equal(t, run("SMEMBERS", "s"), runOn(h, "SMEMBERS", "s"))
+		assert.Equal(t, "*1\r\n$1\r\ny\r\n", runOn(h, "LRANGE", "l", "0", "-1"))
+		assert.Equal(t, run("XRANGE", "st", "-", "+"), runOn(h, "XRANGE", "st", "-", "+"))
+		ttl, err := strconv.Atoi(strings.Trim(runOn(h, "TTL", "t"), ":\r\n"))
+		assert.NoError(t, err)
+		assert.True(t, ttl > 90 && ttl <= 100)
+	}
+	assert.Equal(t, "*2\r\n$1\r\nl\r\n$1\r\nx\r\n", popped)
+	replayed := setupTestHandler(t)
+	defer replayed.Db.Close()
+	assert.NoError(t, replayed.ConfigureAOF(path, "everysec"))
+	assert.NoError(t, replayed.StartAOF())
+	check(replayed)
+	assert.NoError(t, replayed.CloseAOF())
+
+	// BGREWRITEAOF 用当前数据集替换文件，之后的写命令追加到新文件
+	assert.Equal(t, "+Background append only file rewriting started\r\n", run("BGREWRITEAOF"))
+	deadline := time.Now().Add(5 * time.Second)
+	for handler.root().aof.rewriting.Load() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	run("SET", "b", "2")
+	cmds = logged()
+	assert.Equal(t, 7, len(cmds))
+	for _, c := range cmds[:6] {
+		assert.True(t, strings.HasPrefix(c, "RESTORE "))
+	}
+	assert.Equal(t, "SET b 2", cmds[6])
+	info := run("INFO", "persistence")
+	assert.True(t, strings.Contains(info, "aof_rewrites:1\n"))
+	assert.True(t, strings.Contains(info, "aof_last_bgrewrite_status:ok\n"))
+
+	rewritten := setupTestHandler(t)
+	defer rewritten.Db.Close()
+	assert.NoError(t, rewritten.ConfigureAOF(path, "no"))
+	assert.NoError(t, rewritten.StartAOF())
+	check(rewritten)
+	assert.Equal(t, "$1\r\n2\r\n", runOn(rewritten, "GET", "b"))
+	assert.NoError(t, rewritten.CloseAOF())
+
+	// CONFIG 参数
+	assert.Equal(t, "*2\r\n$10\r\nappendonly\r\n$3\r\nyes\r\n", run("CONFIG", "GET", "appendonly"))
+	assert.Equal(t, "*2\r\n$11\r\nappendfsync\r\n$6\r\nalways\r\n", run("CONFIG", "GET", "appendfsync"))
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "appendfsync", "everysec"))
+	assert.Equal(t, "-ERR CONFIG SET failed (possibly related to argument 'appendfsync') - argument must be one of the following: always, everysec, no\r\n",
+		run("CONFIG", "SET", "appendfsync", "sometimes"))
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "appendonly", "no"))
+	run("SET", "c", "3")
+	assert.Equal(t, 7, len(logged()))
+	assert.True(t, strings.Contains(run("INFO", "persistence"), "aof_enabled:0\n"))
+}
+
+// TestWait 测试 WAIT 等待从节点确认本连接最近一次写命令的复制偏移量
+func TestWait(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	repl := replication.NewReplicationManager(handler.Db)
+	handler.Replication = repl
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	masterSide, replicaSide := net.Pipe()
+	slave := replication.NewSlaveConnection(masterSide)
+	slave.SetReady(true)
+	repl.AddSlave(slave)
+	defer repl.Stop()
+	commands := make(chan string, 16)
+	go func() {
+		reader := bufio.NewReader(replicaSide)
+		for {
+			req, err := proto.ReadRESP(reader)
+			if err != nil {
+				close(commands)
+				return
+			}
+			parts := make([]string, len(req.Args))
+			for i, a := range req.Args {
+				parts[i] = string(a)
+			}
+			commands <- strings.Join(parts, " ")
+		}
+	}()
+
+	assert.Equal(t, "+OK\r\n", run("SET", "k", "v"))
+	assert.Equal(t, "SET k v", <-commands)
+	offset := repl.GetMasterReplOffset()
+	assert.True(t, offset > 0)
+
+	// 从节点尚未确认：向从节点请求确认，收到 ACK 后返回
+	done := make(chan string, 1)
+	go func() {
+		done <- run("WAIT", "1", "0")
+	}()
+	assert.Equal(t, "REPLCONF GETACK *", <-commands)
+	repl.UpdateSlaveAckOffset(slave.ID, offset)
+	assert.Equal(t, ":1\r\n", <-done)
+
+	// 确认数足够时立即返回，不足时超时后返回实际的确认数
+	assert.Equal(t, ":1\r\n", run("WAIT", "0", "0"))
+	start := time.Now()
+	assert.Equal(t, ":1\r\n", run("WAIT", "2", "50"))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	assert.Equal(t, "REPLCONF GETACK *", <-commands)
+
+	// 新的写命令尚未被确认
+	assert.Equal(t, "+OK\r\n", run("SET", "k", "v2"))
+	assert.Equal(t, "SET k v2", <-commands)
+	assert.Equal(t, ":0\r\n", run("WAIT", "1", "20"))
+
+	assert.Equal(t, "-ERR timeout is negative\r\n", run("WAIT", "1", "-1"))
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", run("WAIT", "x", "0"))
+}
+
+// TestClusterRedirect 测试集群模式下按命令的键返回 MOVED/ASK/CROSSSLOT
+func TestClusterRedirect(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	c, err := cluster.NewCluster(handler.Db, "", ":6379")
+	assert.NoError(t, err)
+	handler.Cluster = c
+	otherID := strings.Repeat("b", 40)
+	c.AddNode(cluster.NewNode(otherID, "127.0.0.1:6380"))
+	slot := cluster.Slot("{b}")
+	assert.NoError(t, c.AssignSlot(slot, otherID))
+	moved := fmt.Sprintf("-MOVED %d 127.0.0.1:6380\r\n", slot)
+
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	assert.Equal(t, "+OK\r\n", run("SET", "{a}1", "v"))
+	assert.Equal(t, moved, run("GET", "{b}1"))
+	assert.Equal(t, moved, run("MGET", "{b}1", "{b}2"))
+	assert.Equal(t, moved, run("EVAL", "return 1", "1", "{b}1"))
+	assert.Equal(t, ":1\r\n", run("EVAL", "return 1", "0"))
+	assert.Equal(t, moved, run("XREAD", "COUNT", "1", "STREAMS", "{b}s", "0"))
+	crossSlot := "-CROSSSLOT Keys in request don't hash to the same slot\r\n"
+	assert.Equal(t, crossSlot, run("MSET", "{a}1", "v", "{b}1", "v"))
+	assert.Equal(t, crossSlot, run("ZUNIONSTORE", "{a}d", "1", "{b}s"))
+	assert.Equal(t, crossSlot, run("SORT", "{a}1", "STORE", "{b}1"))
+	assert.Equal(t, "+PONG\r\n", run("PING"))
+
+	// 分片频道按槽分配；普通频道不受槽位限制
+	handler.PubSub = store.NewPubSubManager()
+	assert.Equal(t, moved, run("SPUBLISH", "{b}ch", "m"))
+	assert.Equal(t, moved, run("SSUBSCRIBE", "{b}ch"))
+	assert.Equal(t, crossSlot, run("SSUBSCRIBE", "{a}ch", "{b}ch"))
+	assert.Equal(t, ":0\r\n", run("SPUBLISH", "{a}ch", "m"))
+	assert.Equal(t, ":0\r\n", run("PUBLISH", "{b}ch", "m"))
+
+	// 事务中的命令在入队时检查，重定向使 EXEC 失败
+	assert.Equal(t, "+OK\r\n", run("MULTI"))
+	assert.Equal(t, moved, run("GET", "{b}1"))
+	assert.Equal(t, "-EXECABORT Transaction discarded because of previous errors.\r\n", run("EXEC"))
+
+	// 导入中的槽位只接受紧随 ASKING 的一条命令
+	assert.Equal(t, "+OK\r\n", run("CLUSTER", "SETSLOT", strconv.Itoa(int(slot)), "IMPORTING", otherID))
+	assert.Equal(t, moved, run("GET", "{b}1"))
+	assert.Equal(t, "+OK\r\n", run("ASKING"))
+	assert.Equal(t, "$-1\r\n", run("GET", "{b}1"))
+	assert.Equal(t, moved, run("GET", "{b}1"))
+	assert.Equal(t, "+OK\r\n", run("ASKING"))
+	assert.Equal(t, "+PONG\r\n", run("PING"))
+	assert.Equal(t, moved, run("GET", "{b}1"))
+
+	assert.Equal(t, "$40\r\n"+c.Myself.ID+"\r\n", run("CLUSTER", "MYID"))
+	assert.True(t, strings.HasPrefix(run("CLUSTER", "SHARDS"), "*2\r\n*4\r\n$5\r\nslots\r\n"))
+	assert.Equal(t, "-ERR unknown subcommand 'FOO'\r\n", run("CLUSTER", "FOO"))
+}
+
+func TestCommandKeys(t *testing.T) {
+	args := func(s string) [][]byte {
+		var out [][]byte
+		for _, f := range strings.Fields(s) {
+			out = append(out, []byte(f))
+		}
+		return out
+	}
+	tests := []struct {
+		cmd, args string
+		keys      []string
+	}{
+		{"GET", "k", []string{"k"}},
+		{"MSET", "a 1 b 2", []string{"a", "b"}},
+		{"BLPOP", "a b 0", []string{"a", "b"}},
+		{"BITOP", "AND d a b", []string{"d", "a", "b"}},
+		{"EVALSHA", "sha 2 a b c", []string{"a", "b"}},
+		{"ZINTERSTORE", "d 2 a b WEIGHTS 1 2", []string{"d", "a", "b"}},
+		{"SINTERCARD", "2 a b LIMIT 1", []string{"a", "b"}},
+		{"XREADGROUP", "GROUP g c COUNT 1 STREAMS a b > >", []string{"a", "b"}},
+		{"OBJECT", "ENCODING k", []string{"k"}},
+		{"SORT", "k BY w_* STORE d", []string{"k", "d"}},
+		{"JSON.MGET", "a b $", []string{"a", "b"}},
+		{"JSON.MSET", "a $ 1 b $.x 2", []string{"a", "b"}},
+		{"PING", "", nil},
+		{"EVAL", "s 5 a", []string{"a"}},
+		{"SSUBSCRIBE", "a b", []string{"a", "b"}},
+		{"ZMPOP", "2 a b MIN COUNT 2", []string{"a", "b"}},
+		{"BZMPOP", "0.5 1 a MAX", []string{"a"}},
+	}
+	for _, tt := range tests {
+		assert.DeepEqual(t, tt.keys, commandKeys(tt.cmd, args(tt.args)))
+	}
+}
+
+func TestSetOptions(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+	ttl := func(key string) int {
+		n, err := strconv.Atoi(strings.Trim(run("TTL", key), ":\r\n"))
+		assert.NoError(t, err)
+		return n
+	}
+
+	// NX/XX：条件不满足时返回 nil
+	assert.Equal(t, "+OK\r\n", run("SET", "lock", "a", "NX", "PX", "10000"))
+	assert.Equal(t, "$-1\r\n", run("SET", "lock", "b", "NX", "PX", "10000"))
+	assert.Equal(t, "$1\r\na\r\n", run("GET", "lock"))
+	assert.Equal(t, "$-1\r\n", run("SET", "missing", "v", "XX"))
+	assert.Equal(t, "+OK\r\n", run("SET", "lock", "c", "XX"))
+	assert.Equal(t, -1, ttl("lock"))
+
+	// GET 返回旧值，与 NX/XX 同时使用时无论是否写入都返回旧值
+	assert.Equal(t, "$1\r\nc\r\n", run("SET", "lock", "d", "XX", "GET"))
+	assert.Equal(t, "$1\r\nd\r\n", run("SET", "lock", "e", "NX", "GET"))
+	assert.Equal(t, "$1\r\nd\r\n", run("GET", "lock"))
+	assert.Equal(t, "$-1\r\n", run("SET", "new", "v", "GET"))
+	assert.Equal(t, "$-1\r\n", run("SET", "missing", "v", "XX", "GET"))
+	assert.Equal(t, "$-1\r\n", run("GET", "missing"))
+
+	// KEEPTTL 保留过期时间，普通 SET 清除过期时间
+	assert.Equal(t, "+OK\r\n", run("SET", "t", "v", "EX", "100"))
+	assert.Equal(t, "+OK\r\n", run("SET", "t", "v2", "KEEPTTL"))
+	assert.True(t, ttl("t") > 90)
+	assert.Equal(t, "+OK\r\n", run("SET", "t", "v3", "XX"))
+	assert.Equal(t, -1, ttl("t"))
+
+	// EXAT/PXAT 绝对过期时间，已经过去的时间点写入后键立即过期
+	at := time.Now().Add(100 * time.Second)
+	assert.Equal(t, "+OK\r\n", run("SET", "t", "v", "EXAT", strconv.FormatInt(at.Unix(), 10)))
+	assert.True(t, ttl("t") > 90)
+	assert.Equal(t, "+OK\r\n", run("SET", "t", "v", "PXAT", strconv.FormatInt(at.UnixMilli(), 10)))
+	assert.True(t, ttl("t") > 90)
+	assert.Equal(t, "+OK\r\n", run("SET", "t", "v", "PXAT", "1000"))
+	assert.Equal(t, "$-1\r\n", run("GET", "t"))
+
+	// 其他类型的键：GET 返回 WRONGTYPE 且不写入，否则整个替换
+	run("RPUSH", "l", "x")
+	assert.Equal(t, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", run("SET", "l", "v", "GET"))
+	assert.Equal(t, "+list\r\n", run("TYPE", "l"))
+	assert.Equal(t, "+OK\r\n", run("SET", "l", "v", "XX"))
+	assert.Equal(t, "$1\r\nv\r\n", run("GET", "l"))
+
+	// 参数错误
+	for _, args := range [][]string{
+		{"SET", "k", "v", "NX", "XX"},
+		{"SET", "k", "v", "EX", "10", "PX", "100"},
+		{"SET", "k", "v", "EX", "10", "KEEPTTL"},
+		{"SET", "k", "v", "EX"},
+		{"SET", "k", "v", "FOO"},
+	} {
+		assert.Equal(t, "-ERR syntax error\r\n", run(args...))
+	}
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", run("SET", "k", "v", "EX", "x"))
+	assert.Equal(t, "-ERR invalid expire time in 'set' command\r\n", run("SET", "k", "v", "PX", "0"))
+	assert.Equal(t, "-ERR invalid expire time in 'set' command\r\n", run("SET", "k", "v", "EX", "9223372036854775807"))
+
+	// 写入 AOF 时相对过期时间改为 PXAT
+	cmds := handler.aofCommands("SET", [][]byte{[]byte("k"), []byte("EX"), []byte("NX"), []byte("EX"), []byte("10")}, proto.OK)
+	assert.Equal(t, 1, len(cmds))
+	assert.Equal(t, "PXAT", string(cmds[0][4]))
+	assert.Equal(t, "EX", string(cmds[0][2]))
+}
+
+func TestMaxmemoryEviction(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	assert.Equal(t, "+OK\r\n", run("SET", "k0", "v"))
+	limit := handler.Db.DataSize() + 1000
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "maxmemory", strconv.FormatInt(limit, 10)))
+
+	// noeviction：超过上限后拒绝写入，删除命令仍然可以执行
+	// 值不能被压缩，否则写入很多键也不会超过上限
+	value := func(i int) string {
+		var b strings.Builder
+		x := uint32(i + 1)
+		for b.Len() < 300 {
+			x = x*1664525 + 1013904223
+			b.WriteString(strconv.FormatUint(uint64(x), 36))
+		}
+		return b.String()
+	}
+	oom := "-OOM command not allowed when used memory > 'maxmemory'.\r\n"
+	for i := 1; i <= 10; i++ {
+		if resp := run("SET", "k"+strconv.Itoa(i), value(i)); resp != "+OK\r\n" {
+			assert.Equal(t, oom, resp)
+			break
+		}
+	}
+	assert.Equal(t, oom, run("SET", "another", value(100)))
+	assert.Equal(t, ":1\r\n", run("DEL", "k1"))
+
+	// allkeys-lru：写入时淘汰最久未访问的键
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "maxmemory-policy", "allkeys-lru"))
+	for i := 20; i < 40; i++ {
+		assert.Equal(t, "+OK\r\n", run("SET", "k"+strconv.Itoa(i), value(i)))
+	}
+	assert.True(t, handler.Db.DataSize() <= limit+400)
+	assert.Equal(t, "$-1\r\n", run("GET", "k0"))
+	assert.Equal(t, ":0\r\n", run("OBJECT", "IDLETIME", "k39"))
+	assert.Equal(t, "$-1\r\n", run("OBJECT", "IDLETIME", "k0"))
+	stats := run("INFO", "stats")
+	assert.True(t, strings.Contains(stats, "evicted_keys:"))
+	assert.False(t, strings.Contains(stats, "evicted_keys:0\n"))
+}
+
+func TestXReadGroupCommand(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	run("XADD", "a", "1-1", "f", "a1")
+	run("XADD", "b", "1-1", "f", "b1")
+	run("XGROUP", "CREATE", "a", "g", "0")
+	assert.Equal(t, "-NOGROUP No such key 'b' or consumer group 'g' in XREADGROUP with GROUP option\r\n",
+		run("XREADGROUP", "GROUP", "g", "c", "STREAMS", "a", "b", ">", ">"))
+	run("XGROUP", "CREATE", "b", "g", "0")
+	assert.Equal(t, "-ERR Unbalanced 'xreadgroup' list of streams: for each stream key an ID or '>' must be specified.\r\n",
+		run("XREADGROUP", "GROUP", "g", "c", "STREAMS", "a", "b", ">"))
+
+	// 键在前、ID 在后；NOACK 时不登记待确认条目
+	assert.Equal(t, "*2\r\n*2\r\n$1\r\na\r\n*1\r\n*2\r\n$3\r\n1-1\r\n*2\r\n$1\r\nf\r\n$2\r\na1\r\n"+
+		"*2\r\n$1\r\nb\r\n*1\r\n*2\r\n$3\r\n1-1\r\n*2\r\n$1\r\nf\r\n$2\r\nb1\r\n",
+		run("XREADGROUP", "GROUP", "g", "c", "NOACK", "STREAMS", "a", "b", ">", ">"))
+	assert.True(t, strings.HasPrefix(run("XPENDING", "a", "g"), "*4\r\n:0\r\n"))
+	assert.Equal(t, "$-1\r\n", run("XREADGROUP", "GROUP", "g", "c", "STREAMS", "a", ">"))
+
+	// 历史中已删除的条目返回 [id, nil]
+	run("XADD", "a", "1-2", "f", "a2")
+	run("XREADGROUP", "GROUP", "g", "c", "STREAMS", "a", ">")
+	run("XDEL", "a", "1-2")
+	assert.Equal(t, "*1\r\n*2\r\n$1\r\na\r\n*1\r\n*2\r\n$3\r\n1-2\r\n*-1\r\n",
+		run("XREADGROUP", "GROUP", "g", "c", "STREAMS", "a", "0"))
+}
+
+func TestXAutoClaimAndXPendingReplies(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	request := func(args ...string) proto.RESP {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil)
+	}
+	run := func(args ...string) string { return request(args...).String() }
+
+	run("XADD", "s", "1-1", "f", "a")
+	run("XADD", "s", "1-2", "f", "b")
+	run("XADD", "s", "1-3", "f", "c")
+	run("XGROUP", "CREATE", "s", "g", "0")
+	run("XGROUP", "CREATE", "s", "idle", "0")
+	run("XREADGROUP", "GROUP", "g", "c1", "STREAMS", "s", ">")
+	run("XDEL", "s", "1-2")
+
+	// 摘要按消费者汇总；没有待确认条目时为 nil
+	assert.Equal(t, "*4\r\n:3\r\n$3\r\n1-1\r\n$3\r\n1-3\r\n*1\r\n*2\r\n$2\r\nc1\r\n$1\r\n3\r\n", run("XPENDING", "s", "g"))
+	assert.Equal(t, "*4\r\n:0\r\n$-1\r\n$-1\r\n*-1\r\n", run("XPENDING", "s", "idle"))
+
+	// start 本身在扫描范围内，下次从第一个未扫描的条目开始
+	assert.Equal(t, "*3\r\n$3\r\n1-2\r\n*1\r\n*2\r\n$3\r\n1-1\r\n*2\r\n$1\r\nf\r\n$1\r\na\r\n*0\r\n",
+		run("XAUTOCLAIM", "s", "g", "c2", "0", "1-1", "COUNT", "1"))
+	// 已删除的条目从待确认列表移除并单独返回，扫描到末尾时游标为 0-0
+	resp := request("XAUTOCLAIM", "s", "g", "c2", "0", "1-2", "JUSTID")
+	assert.Equal(t, "*3\r\n$3\r\n0-0\r\n*1\r\n$3\r\n1-3\r\n*1\r\n$3\r\n1-2\r\n", resp.String())
+	assert.Equal(t, "*4\r\n:2\r\n$3\r\n1-1\r\n$3\r\n1-3\r\n*1\r\n*2\r\n$2\r\nc2\r\n$1\r\n2\r\n", run("XPENDING", "s", "g"))
+
+	// 复制时改写为认领同样条目的 XCLAIM 与移除已删除条目的 XACK
+	args := [][]byte{[]byte("s"), []byte("g"), []byte("c2"), []byte("0"), []byte("1-2"), []byte("JUSTID")}
+	assert.DeepEqual(t, [][][]byte{
+		{[]byte("XCLAIM"), []byte("s"), []byte("g"), []byte("c2"), []byte("0"), []byte("1-3")},
+		{[]byte("XACK"), []byte("s"), []byte("g"), []byte("1-2")},
+	}, xautoclaimCommands(args, resp))
+
+	assert.Equal(t, "-ERR Invalid stream ID specified as stream command argument\r\n", run("XAUTOCLAIM", "s", "g", "c2", "0", "x"))
+	assert.Equal(t, "-ERR COUNT must be > 0\r\n", run("XAUTOCLAIM", "s", "g", "c2", "0", "-", "COUNT", "0"))
+}
+
+func TestXSetIDAndXInfoFull(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	assert.Equal(t, "$-1\r\n", run("XADD", "s", "NOMKSTREAM", "*", "f", "v"))
+	assert.Equal(t, "-ERR no such key\r\n", run("XSETID", "s", "1-1"))
+	assert.Equal(t, "$3\r\n1-1\r\n", run("XADD", "s", "MAXLEN", "=", "2", "1-1", "f", "a"))
+	run("XADD", "s", "MAXLEN", "2", "1-2", "f", "b")
+	run("XADD", "s", "MAXLEN", "2", "1-3", "f", "c")
+	assert.Equal(t, ":2\r\n", run("XLEN", "s"))
+	assert.Equal(t, "-ERR syntax error, LIMIT cannot be used without the special ~ option\r\n",
+		run("XTRIM", "s", "MAXLEN", "1", "LIMIT", "10"))
+	assert.Equal(t, "-ERR syntax error, MAXLEN and MINID options at the same time are not compatible\r\n",
+		run("XADD", "s", "MAXLEN", "1", "MINID", "0", "*", "f", "v"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'xadd' command\r\n", run("XADD", "s", "*", "f"))
+	// 近似裁剪不足一批时不删除
+	assert.Equal(t, ":0\r\n", run("XTRIM", "s", "MAXLEN", "~", "1", "LIMIT", "100"))
+	assert.Equal(t, ":1\r\n", run("XTRIM", "s", "MINID", "1-3"))
+
+	assert.Equal(t, "-ERR The ID specified in XSETID is smaller than the target stream top item\r\n", run("XSETID", "s", "1-2"))
+	assert.Equal(t, "+OK\r\n", run("XSETID", "s", "5-0", "ENTRIESADDED", "7", "MAXDELETEDID", "1-2"))
+	assert.Equal(t, "$3\r\n5-1\r\n", run("XADD", "s", "5-*", "f", "d"))
+
+	run("XGROUP", "CREATE", "s", "g", "0")
+	run("XREADGROUP", "GROUP", "g", "c", "COUNT", "1", "STREAMS", "s", ">")
+	full := run("XINFO", "STREAM", "s", "FULL", "COUNT", "1")
+	assert.True(t, strings.HasPrefix(full, "*18\r\n$6\r\nlength\r\n:2\r\n"))
+	assert.True(t, strings.Contains(full, "$13\r\nentries-added\r\n:8\r\n"))
+	assert.True(t, strings.Contains(full, "$20\r\nmax-deleted-entry-id\r\n$3\r\n1-2\r\n"))
+	assert.True(t, strings.Contains(full, "$7\r\nentries\r\n*1\r\n*2\r\n$3\r\n1-3\r\n*2\r\n$1\r\nf\r\n$1\r\nc\r\n"))
+	assert.True(t, strings.Contains(full, "$9\r\npel-count\r\n:1\r\n$7\r\npending\r\n*1\r\n*4\r\n$3\r\n1-3\r\n$1\r\nc\r\n"))
+	assert.Equal(t, "-ERR no such key\r\n", run("XINFO", "STREAM", "missing", "FULL"))
+}
+
+func TestXReadGroupBlock(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	run("XGROUP", "CREATE", "s", "g", "0", "MKSTREAM")
+	start := time.Now()
+	assert.Equal(t, "$-1\r\n", run("XREADGROUP", "GROUP", "g", "c", "BLOCK", "50", "STREAMS", "s", ">"))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	// BLOCK 0 一直等待到 XADD
+	done := make(chan string, 1)
+	go func() {
+		done <- run("XREADGROUP", "GROUP", "g", "c", "BLOCK", "0", "STREAMS", "s", ">")
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for handler.Db.BlockedClients() != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	run("XADD", "s", "1-1", "f", "v")
+	select {
+	case reply := <-done:
+		assert.Equal(t, "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n*2\r\n$3\r\n1-1\r\n*2\r\n$1\r\nf\r\n$1\r\nv\r\n", reply)
+	case <-time.After(2 * time.Second):
+		t.Fatal("XREADGROUP BLOCK 0 was not woken by XADD")
+	}
+}
+
+func TestZAddFlags(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	assert.Equal(t, ":2\r\n", run("ZADD", "z", "1", "a", "2", "b"))
+	// NX 只添加新成员，XX 只更新已有成员
+	assert.Equal(t, ":1\r\n", run("ZADD", "z", "NX", "10", "a", "3", "c"))
+	assert.Equal(t, "$1\r\n1\r\n", run("ZSCORE", "z", "a"))
+	assert.Equal(t, ":0\r\n", run("ZADD", "z", "XX", "5", "a", "4", "d"))
+	assert.Equal(t, "$1\r\n5\r\n", run("ZSCORE", "z", "a"))
+	assert.Equal(t, "$-1\r\n", run("ZSCORE", "z", "d"))
+
+	// GT/LT 只在分数变大/变小时更新，CH 计入分数变化的成员
+	assert.Equal(t, ":1\r\n", run("ZADD", "z", "GT", "CH", "3", "a", "6", "b"))
+	assert.Equal(t, "$1\r\n5\r\n", run("ZSCORE", "z", "a"))
+	assert.Equal(t, ":2\r\n", run("ZADD", "z", "LT", "CH", "1", "a", "7", "b", "0", "e"))
+	assert.Equal(t, "$1\r\n6\r\n", run("ZSCORE", "z", "b"))
+	assert.Equal(t, ":0\r\n", run("ZADD", "z", "CH", "1", "a"))
+
+	// INCR 与 ZINCRBY 相同，条件不满足时返回 nil
+	assert.Equal(t, "$3\r\n3.5\r\n", run("ZADD", "z", "INCR", "2.5", "a"))
+	assert.Equal(t, "$-1\r\n", run("ZADD", "z", "NX", "INCR", "1", "a"))
+	assert.Equal(t, "$-1\r\n", run("ZADD", "z", "GT", "INCR", "-1", "a"))
+	assert.Equal(t, "$-1\r\n", run("ZADD", "z", "XX", "INCR", "2", "f"))
+	assert.Equal(t, "$1\r\n2\r\n", run("ZADD", "z", "INCR", "2", "f"))
+	assert.Equal(t, ":5\r\n", run("ZCARD", "z"))
+
+	assert.Equal(t, "-ERR XX and NX options at the same time are not compatible\r\n", run("ZADD", "z", "NX", "XX", "1", "a"))
+	assert.Equal(t, "-ERR GT, LT, and/or NX options at the same time are not compatible\r\n", run("ZADD", "z", "GT", "LT", "1", "a"))
+	assert.Equal(t, "-ERR GT, LT, and/or NX options at the same time are not compatible\r\n", run("ZADD", "z", "NX", "GT", "1", "a"))
+	assert.Equal(t, "-ERR INCR option supports a single increment-element pair\r\n", run("ZADD", "z", "INCR", "1", "a", "2", "b"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("ZADD", "z", "NX", "1"))
+	assert.Equal(t, "-ERR value is not a valid float\r\n", run("ZADD", "z", "CH", "x", "a"))
+
+	run("ZADD", "inf", "+inf", "a")
+	assert.Equal(t, "-ERR resulting score is not a number (NaN)\r\n", run("ZADD", "inf", "INCR", "-inf", "a"))
+}
+
+func TestZMPopCommands(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	run("ZADD", "z", "1", "a", "2.5", "b", "3", "c")
+	assert.Equal(t, "*2\r\n$1\r\nz\r\n*1\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n", run("ZMPOP", "2", "none", "z", "MIN"))
+	assert.Equal(t, "*2\r\n$1\r\nz\r\n*2\r\n*2\r\n$1\r\nc\r\n$1\r\n3\r\n*2\r\n$1\r\nb\r\n$3\r\n2.5\r\n",
+		run("ZMPOP", "1", "z", "MAX", "COUNT", "5"))
+	assert.Equal(t, "*-1\r\n", run("ZMPOP", "1", "z", "MIN"))
+
+	// 超时以秒为单位，可以是小数
+	start := time.Now()
+	assert.Equal(t, "*-1\r\n", run("BZMPOP", "0.05", "1", "z", "MIN"))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	assert.Equal(t, "*-1\r\n", run("BZPOPMIN", "z", "0.01"))
+	assert.Equal(t, "*-1\r\n", run("BZPOPMAX", "z", "0.01"))
+	run("ZADD", "z", "4", "d")
+	assert.Equal(t, "*3\r\n$1\r\nz\r\n$1\r\nd\r\n$1\r\n4\r\n", run("BZPOPMAX", "z", "0.01"))
+
+	// 阻塞的 BZMPOP 被 ZADD 唤醒
+	done := make(chan string, 1)
+	go func() {
+		done <- run("BZMPOP", "2", "1", "z", "MIN")
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for handler.Db.BlockedClients() != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	run("ZADD", "z", "7", "e")
+	select {
+	case reply := <-done:
+		assert.Equal(t, "*2\r\n$1\r\nz\r\n*1\r\n*2\r\n$1\r\ne\r\n$1\r\n7\r\n", reply)
+	case <-time.After(2 * time.Second):
+		t.Fatal("BZMPOP was not woken by ZADD")
+	}
+
+	assert.Equal(t, "-ERR numkeys should be greater than 0\r\n", run("ZMPOP", "0", "z", "MIN"))
+	assert.Equal(t, "-ERR count should be greater than 0\r\n", run("ZMPOP", "1", "z", "MIN", "COUNT", "0"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("ZMPOP", "1", "z", "MIDDLE"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("ZMPOP", "3", "z", "MIN"))
+	assert.Equal(t, "-ERR timeout is negative\r\n", run("BZMPOP", "-1", "1", "z", "MIN"))
+	assert.Equal(t, "-ERR timeout is not a float or out of range\r\n", run("BZPOPMIN", "z", "soon"))
+
+	// AOF 中 BZMPOP 改为删除弹出的成员
+	cmds := handler.aofCommands("BZMPOP", [][]byte{[]byte("1"), []byte("1"), []byte("z"), []byte("MIN")},
+		zmpopReply("z", []store.ZSetMember{{Member: "a", Score: 1}, {Member: "b", Score: 2}}))
+	assert.DeepEqual(t, [][][]byte{{[]byte("ZREM"), []byte("z"), []byte("a"), []byte("b")}}, cmds)
+}
+
+func TestJSONPathReplies(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	assert.Equal(t, "+OK\r\n", run("JSON.SET", "doc", "$", `{"a":{"b":[1,2]}}`))
+	assert.Equal(t, "+OK\r\n", run("JSON.SET", "doc", "$.a.b[1]", `5`))
+	assert.Equal(t, "$-1\r\n", run("JSON.SET", "doc", "$.a.c", `1`, "XX"))
+	assert.Equal(t, "$7\r\n[[1,5]]\r\n", run("JSON.GET", "doc", "$.a.b"))
+	assert.Equal(t, "$26\r\n{\"$.a.b[0]\":[1],\"$..c\":[]}\r\n", run("JSON.GET", "doc", "$.a.b[0]", "$..c"))
+	assert.Equal(t, "-ERR Path '.a.c' does not exist\r\n", run("JSON.GET", "doc", ".a.c"))
+	assert.Equal(t, "-ERR invalid JSONPath\r\n", run("JSON.GET", "doc", "$.a["))
+	assert.Equal(t, "-ERR new objects must be created at the root\r\n", run("JSON.SET", "other", "$.a", `1`))
+}
+
+func TestJSONMutatorReplies(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	// JSONPath 为每个匹配回复一项，旧式路径回复单个值
+	assert.Equal(t, "+OK\r\n", run("JSON.SET", "doc", "$", `{"a":[1,2],"s":"hi","b":false}`))
+	assert.Equal(t, "*1\r\n:4\r\n", run("JSON.ARRINSERT", "doc", "$.a", "0", "-1", "0"))
+	assert.Equal(t, "*1\r\n:1\r\n", run("JSON.ARRINDEX", "doc", "$.a", "0"))
+	assert.Equal(t, ":1\r\n", run("JSON.ARRINDEX", "doc", ".a", "0"))
+	assert.Equal(t, "*1\r\n$1\r\n2\r\n", run("JSON.ARRPOP", "doc", "$.a"))
+	assert.Equal(t, "$1\r\n0\r\n", run("JSON.ARRPOP", "doc", ".a", "1"))
+	assert.Equal(t, ":3\r\n", run("JSON.ARRAPPEND", "doc", ".a", "1"))
+	assert.Equal(t, "*1\r\n:2\r\n", run("JSON.ARRTRIM", "doc", "$.a", "1", "2"))
+	assert.Equal(t, "*1\r\n:5\r\n", run("JSON.STRAPPEND", "doc", "$.s", `"!!!"`))
+	assert.Equal(t, "*1\r\n:5\r\n", run("JSON.STRLEN", "doc", "$.s"))
+	assert.Equal(t, ":5\r\n", run("JSON.STRLEN", "doc", ".s"))
+	assert.Equal(t, "$-1\r\n", run("JSON.STRLEN", "missing"))
+	assert.Equal(t, "*1\r\n:1\r\n", run("JSON.TOGGLE", "doc", "$.b"))
+	assert.Equal(t, "-ERR index out of bounds\r\n", run("JSON.ARRINSERT", "doc", "$.a", "9", "1"))
+
+	// 类型不符的匹配回复 nil，旧式路径报错
+	assert.Equal(t, "*3\r\n$-1\r\n:0\r\n$-1\r\n", run("JSON.TOGGLE", "doc", "$.*"))
+	assert.Equal(t, "*3\r\n$-1\r\n$-1\r\n:8\r\n", run("JSON.STRAPPEND", "doc", "$.*", `"!!!"`))
+	assert.Equal(t, "*3\r\n:3\r\n$-1\r\n$-1\r\n", run("JSON.ARRAPPEND", "doc", "$.*", "2"))
+	assert.Equal(t, "-ERR path does not resolve to a boolean\r\n", run("JSON.TOGGLE", "doc", ".s"))
+	assert.Equal(t, "+OK\r\n", run("JSON.SET", "doc", "$.s", `"hi!!!"`))
+	assert.Equal(t, "+OK\r\n", run("JSON.SET", "doc", "$.b", `true`))
+	assert.Equal(t, "+OK\r\n", run("JSON.SET", "doc", "$.a", `[0,1]`))
+
+	assert.Equal(t, "+OK\r\n", run("JSON.SET", "num", "$", `{"x":1,"y":"a","z":{"x":2}}`))
+	assert.Equal(t, "$15\r\n[3,null,null,4]\r\n", run("JSON.NUMINCRBY", "num", "$..*", "2"))
+	assert.Equal(t, "$1\r\n6\r\n", run("JSON.NUMMULTBY", "num", ".x", "2"))
+	assert.Equal(t, "-ERR value at path is not a number\r\n", run("JSON.NUMINCRBY", "num", ".y", "1"))
+	assert.Equal(t, "+OK\r\n", run("JSON.MERGE", "doc", "$", `{"s":null,"c":{"d":1}}`))
+	assert.Equal(t, "-ERR wrong number of arguments for 'json.mset' command\r\n", run("JSON.MSET", "doc", "$.a"))
+	assert.Equal(t, "+OK\r\n", run("JSON.MSET", "doc", "$.a", "[0,1]", "doc", "$.s", `"hi!!!"`))
+	assert.Equal(t, "$44\r\n{\"a\":[0,1],\"b\":true,\"c\":{\"d\":1},\"s\":\"hi!!!\"}\r\n", run("JSON.GET", "doc"))
+}
+
+func TestSearchCommands(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	assert.Equal(t, "+OK\r\n", run("FT.CREATE", "idx", "ON", "HASH", "PREFIX", "1", "p:", "SCHEMA",
+		"name", "TEXT", "age", "NUMERIC", "SORTABLE", "tags", "AS", "tag", "TAG", "SEPARATOR", ";"))
+	assert.Equal(t, "-ERR Index already exists\r\n", run("FT.CREATE", "idx", "SCHEMA", "name", "TEXT"))
+	assert.Equal(t, "-ERR unsupported FT.CREATE option 'STOPWORDS'\r\n", run("FT.CREATE", "idx2", "STOPWORDS", "0", "SCHEMA", "name", "TEXT"))
+	assert.Equal(t, "*1\r\n$3\r\nidx\r\n", run("FT._LIST"))
+
+	run("HSET", "p:1", "name", "Ann Lee", "age", "31", "tags", "a;b")
+	run("HSET", "p:2", "name", "Bob Lee", "age", "25", "tags", "b")
+	run("HSET", "q:1", "name", "Lee", "age", "40")
+
+	assert.Equal(t, "*3\r\n:2\r\n$3\r\np:2\r\n$3\r\np:1\r\n", run("FT.SEARCH", "idx", "lee", "NOCONTENT", "SORTBY", "age"))
+	assert.Equal(t, "*2\r\n:1\r\n$3\r\np:1\r\n", run("FT.SEARCH", "idx", "@tag:{a}", "NOCONTENT"))
+	assert.Equal(t, "*3\r\n:1\r\n$3\r\np:1\r\n*2\r\n$1\r\nn\r\n$7\r\nAnn Lee\r\n",
+		run("FT.SEARCH", "idx", "@age:[30 +inf]", "RETURN", "3", "name", "AS", "n"))
+	assert.Equal(t, "*2\r\n:2\r\n$3\r\np:2\r\n", run("FT.SEARCH", "idx", "*", "NOCONTENT", "SORTBY", "age", "DESC", "LIMIT", "1", "1"))
+	assert.Equal(t, "-ERR Unknown field `nope`\r\n", run("FT.SEARCH", "idx", "@nope:x"))
+	assert.Equal(t, "-ERR Unknown Index name\r\n", run("FT.SEARCH", "missing", "*"))
+
+	info := run("FT.INFO", "idx")
+	assert.True(t, strings.Contains(info, "$8\r\nnum_docs\r\n:2\r\n"))
+	assert.True(t, strings.Contains(info, "$10\r\nidentifier\r\n$4\r\ntags\r\n$9\r\nattribute\r\n$3\r\ntag\r\n$4\r\ntype\r\n$3\r\nTAG\r\n$9\r\nSEPARATOR\r\n$1\r\n;\r\n"))
+
+	// AOF 重写生成的参数重新解析后得到同样的定义
+	def, errResp := parseFTCreate(searchCreateArgs(store.SearchIndex{Name: "idx", On: store.KeyTypeJSON, Prefixes: []string{"a:", "b:"},
+		Fields: []store.SearchField{{Path: "$.n", Name: "n", Type: "TEXT", Sortable: true}, {Path: "$.t", Name: "t", Type: "TAG", Separator: ","}}}))
+	assert.Nil(t, errResp)
+	assert.Equal(t, []string{"a:", "b:"}, def.Prefixes)
+	assert.Equal(t, store.SearchField{Path: "$.t", Name: "t", Type: "TAG", Separator: ","}, def.Fields[1])
+	assert.True(t, def.Fields[0].Sortable)
+
+	assert.Equal(t, "+OK\r\n", run("FT.DROPINDEX", "idx", "DD"))
+	assert.Equal(t, ":1\r\n", run("EXISTS", "q:1"))
+	assert.Equal(t, ":0\r\n", run("EXISTS", "p:1"))
+	assert.Equal(t, "*0\r\n", run("FT._LIST"))
+}
+
+func TestUnlinkCommand(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	run("HSET", "h", "a", "1", "b", "2")
+	run("SET", "s", "v")
+	assert.Equal(t, ":2\r\n", run("UNLINK", "h", "s", "missing"))
+	assert.Equal(t, ":0\r\n", run("EXISTS", "h", "s"))
+	// 重新使用键名之前先完成回收，新键看不到旧字段
+	assert.Equal(t, ":1\r\n", run("HSET", "h", "c", "3"))
+	assert.Equal(t, ":1\r\n", run("HLEN", "h"))
+	assert.Equal(t, "*2\r\n$1\r\nc\r\n$1\r\n3\r\n", run("HGETALL", "h"))
+	assert.True(t, strings.Contains(run("INFO", "memory"), "lazyfree_pending_objects:"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'unlink' command\r\n", run("UNLINK"))
+}
+
+func TestWriteCoalescing(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	pipeline := func(cmds ...[]string) *bufio.Reader {
+		var b strings.Builder
+		for _, c := range cmds {
+			req := &proto.Array{Args: make([][]byte, len(c))}
+			for i, a := range c {
+				req.Args[i] = []byte(a)
+			}
+			b.WriteString(req.String())
+		}
+		return bufio.NewReader(strings.NewReader(b.String()))
+	}
+	reader := pipeline(
+		[]string{"SET", "a", "1"},
+		[]string{"HSET", "h", "f1", "v1", "f2", "v2"},
+		[]string{"SADD", "s", "x", "y", "x"},
+		[]string{"RPUSH", "l", "a", "b"},
+		[]string{"HSET", "h", "f1", "v3"},
+		// 同一批量中之前的操作可见
+		[]string{"SET", "str", "v"},
+		[]string{"SADD", "str", "m"},
+		[]string{"GET", "a"},
+	)
+	req, err := proto.ReadRESP(reader)
+	assert.NoError(t, err)
+	responses, next, _ := handler.coalesceWrites(req, reader, "127.0.0.1:12345", nil)
+	replies := make([]string, len(responses))
+	for i, r := range responses {
+		replies[i] = r.String()
+	}
+	assert.Equal(t, []string{"+OK\r\n", ":2\r\n", ":2\r\n", ":2\r\n", ":0\r\n", "+OK\r\n", "-" + wrongTypeErrorMessage + "\r\n"}, replies)
+	assert.Equal(t, "GET a", string(bytes.Join(next.Args, []byte(" "))))
+
+	v, err := handler.Db.HGet("h", "f1")
+	assert.NoError(t, err)
+	assert.Equal(t, "v3", string(v))
+	n, err := handler.Db.LLen("l")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	info := handler.executeCommand("INFO", [][]byte{[]byte("stats")}, "").String()
+	assert.True(t, strings.Contains(info, "coalesced_write_batches:1\n"))
+	assert.True(t, strings.Contains(info, "coalesced_write_commands:7\n"))
+
+	// 合并的 SET 覆盖哈希时删除原有字段
+	reader = pipeline(
+		[]string{"HSET", "h2", "a", "1", "b", "2"},
+		[]string{"SET", "h2", "v"},
+		[]string{"DEL", "h2"},
+	)
+	req, _ = proto.ReadRESP(reader)
+	responses, next, _ = handler.coalesceWrites(req, reader, "127.0.0.1:12345", nil)
+	assert.Equal(t, 2, len(responses))
+	assert.Equal(t, ":1\r\n", handler.processRequest(next, nil, "127.0.0.1:12345", nil, nil).String())
+	assert.Equal(t, ":1\r\n", handler.executeCommand("HSET", [][]byte{[]byte("h2"), []byte("c"), []byte("3")}, "").String())
+	assert.Equal(t, "*2\r\n$1\r\nc\r\n$1\r\n3\r\n", handler.executeCommand("HGETALL", [][]byte{[]byte("h2")}, "").String())
+
+	// 没有后续缓冲的请求、不可合并的命令与关闭合并时逐条执行
+	reader = pipeline([]string{"SET", "a", "2"})
+	req, _ = proto.ReadRESP(reader)
+	responses, next, _ = handler.coalesceWrites(req, reader, "127.0.0.1:12345", nil)
+	assert.Nil(t, responses)
+	assert.Equal(t, req, next)
+	reader = pipeline([]string{"SET", "a", "2", "EX", "10"}, []string{"SET", "b", "2"})
+	req, _ = proto.ReadRESP(reader)
+	responses, _, _ = handler.coalesceWrites(req, reader, "127.0.0.1:12345", nil)
+	assert.Nil(t, responses)
+	assert.Equal(t, "+OK\r\n", handler.executeCommand("CONFIG", [][]byte{[]byte("SET"), []byte("write-coalescing"), []byte("no")}, "").String())
+	reader = pipeline([]string{"SET", "a", "2"}, []string{"SET", "b", "2"})
+	req, _ = proto.ReadRESP(reader)
+	responses, _, _ = handler.coalesceWrites(req, reader, "127.0.0.1:12345", nil)
+	assert.Nil(t, responses)
+}
+
+func TestConnectionShutdown(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+	assert.Equal(t, "*2\r\n$13\r\ntcp-keepalive\r\n$3\r\n300\r\n", run("CONFIG", "GET", "tcp-keepalive"))
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "tcp-keepalive", "0"))
+	assert.Equal(t, "*2\r\n$13\r\ntcp-keepalive\r\n$1\r\n0\r\n", run("CONFIG", "GET", "tcp-keepalive"))
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "tcp-keepalive", "60"))
+	assert.Equal(t, "+OK\r\n", run("CONFIG", "SET", "tcp-nodelay", "no"))
+	assert.Equal(t, "*2\r\n$11\r\ntcp-nodelay\r\n$2\r\nno\r\n", run("CONFIG", "GET", "tcp-nodelay"))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- handler.ServeTCP(listener)
+	}()
+	dial := func() (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		assert.NoError(t, err)
+		return conn, bufio.NewReader(conn)
+	}
+	ping := func(conn net.Conn, reader *bufio.Reader) {
+		_, err := conn.Write([]byte("*1\r\n$4\r\nPING\r\n"))
+		assert.NoError(t, err)
+		line, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "+PONG\r\n", line)
+	}
+
+	// 连接关闭后处理它的 goroutine 留在池中，供下一个连接使用
+	first, firstReader := dial()
+	ping(first, firstReader)
+	_ = first.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for handler.conns.workers.idle.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, int64(1), handler.conns.workers.total.Load())
+	idle, idleReader := dial()
+	defer idle.Close()
+	ping(idle, idleReader)
+	assert.Equal(t, int64(1), handler.conns.workers.total.Load())
+
+	// 空闲的连接立即关闭，之后不再接受连接
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, handler.Shutdown(ctx))
+	assert.Equal(t, ErrServerClosed, <-serveErr)
+	_, err = idleReader.ReadString('\n')
+	assert.Equal(t, io.EOF, err)
+	_, err = net.Dial("tcp", listener.Addr().String())
+	assert.Error(t, err)
+}
+
+func TestShutdownCommand(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+	requested := func() bool {
+		select {
+		case <-handler.ShutdownRequested():
+			return true
+		default:
+			return false
+		}
+	}
+
+	assert.Equal(t, "-ERR No shutdown in progress.\r\n", run("SHUTDOWN", "ABORT"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("SHUTDOWN", "SAVE", "NOSAVE"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("SHUTDOWN", "LATER"))
+	// 保存失败时不关闭
+	assert.Equal(t, "-ERR Errors trying to SHUTDOWN. Check logs.\r\n", run("SHUTDOWN", "SAVE"))
+	assert.False(t, requested())
+	assert.False(t, handler.closeAfterReply)
+
+	// 成功时不回复，关闭连接并通知服务器进程
+	assert.Equal(t, "", run("SHUTDOWN", "SAVE", "FORCE"))
+	assert.True(t, handler.closeAfterReply)
+	assert.True(t, requested())
+	handler.closeAfterReply = false
+	assert.Equal(t, "", run("SHUTDOWN", "NOSAVE", "NOW"))
+	assert.True(t, requested())
+	assert.False(t, requested())
+}
+
+func TestSInterCardLimit(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	run("SADD", "s1", "a", "b", "c", "d")
+	run("SADD", "s2", "b", "c", "d", "e")
+	assert.Equal(t, ":3\r\n", run("SINTERCARD", "2", "s1", "s2"))
+	assert.Equal(t, ":2\r\n", run("SINTERCARD", "2", "s1", "s2", "LIMIT", "2"))
+	assert.Equal(t, ":3\r\n", run("SINTERCARD", "2", "s1", "s2", "limit", "0"))
+	assert.Equal(t, ":3\r\n", run("SINTERCARD", "2", "s1", "s2", "LIMIT", "10"))
+	assert.Equal(t, ":0\r\n", run("SINTERCARD", "2", "s1", "nosuch", "LIMIT", "1"))
+	assert.Equal(t, "-ERR LIMIT can't be negative\r\n", run("SINTERCARD", "2", "s1", "s2", "LIMIT", "-1"))
+	assert.Equal(t, "-ERR LIMIT can't be negative\r\n", run("SINTERCARD", "2", "s1", "s2", "LIMIT", "x"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("SINTERCARD", "2", "s1", "s2", "LIMIT"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("SINTERCARD", "1", "s1", "s2"))
+}
+
+func TestExpireNonStringTypes(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	clock := store.NewManualClock(time.Now())
+	handler.Db.SetClock(clock)
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	run("HSET", "h", "f", "v")
+	run("ZADD", "z", "1", "m")
+	assert.Equal(t, ":1\r\n", run("EXPIRE", "h", "10"))
+	assert.Equal(t, ":1\r\n", run("PEXPIRE", "z", "10000"))
+	assert.Equal(t, ":10\r\n", run("TTL", "h"))
+	run("HSET", "h", "f2", "v2")
+	assert.Equal(t, ":10000\r\n", run("PTTL", "h"))
+	assert.Equal(t, ":1\r\n", run("PERSIST", "z"))
+	assert.Equal(t, ":-1\r\n", run("TTL", "z"))
+
+	// 过期后按不存在处理，再次写入创建没有过期时间的新键
+	clock.Advance(11 * time.Second)
+	assert.Equal(t, "$-1\r\n", run("HGET", "h", "f"))
+	assert.Equal(t, ":-2\r\n", run("TTL", "h"))
+	assert.Equal(t, ":0\r\n", run("EXISTS", "h"))
+	assert.Equal(t, ":1\r\n", run("ZCARD", "z"))
+	run("HSET", "h", "f3", "v3")
+	assert.Equal(t, ":1\r\n", run("HLEN", "h"))
+	assert.Equal(t, ":-1\r\n", run("TTL", "h"))
+}
+
+func TestHashFieldExpireCommands(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	clock := store.NewManualClock(time.UnixMilli(1_700_000_000_000))
+	handler.Db.SetClock(clock)
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	run("HSET", "h", "a", "1", "b", "2", "c", "3")
+	assert.Equal(t, "*3\r\n:1\r\n:1\r\n:-2\r\n", run("HEXPIRE", "h", "10", "FIELDS", "3", "a", "b", "x"))
+	assert.Equal(t, "*2\r\n:0\r\n:1\r\n", run("HPEXPIRE", "h", "20000", "NX", "FIELDS", "2", "a", "c"))
+	assert.Equal(t, "*3\r\n:10\r\n:10\r\n:-2\r\n", run("HTTL", "h", "FIELDS", "3", "a", "b", "x"))
+	assert.Equal(t, "*2\r\n:10000\r\n:20000\r\n", run("HPTTL", "h", "FIELDS", "2", "a", "c"))
+	assert.Equal(t, "*1\r\n:1700000010\r\n", run("HEXPIRETIME", "h", "FIELDS", "1", "a"))
+	assert.Equal(t, "*1\r\n:1700000020000\r\n", run("HPEXPIRETIME", "h", "FIELDS", "1", "c"))
+	assert.Equal(t, "*3\r\n:1\r\n:-1\r\n:-2\r\n", run("HPERSIST", "h", "FIELDS", "3", "c", "c", "x"))
+
+	assert.Equal(t, "-ERR Mandatory argument FIELDS is missing or not at the right position\r\n", run("HEXPIRE", "h", "10", "a", "1", "b"))
+	assert.Equal(t, "-ERR The `numfields` parameter must match the number of arguments\r\n", run("HEXPIRE", "h", "10", "FIELDS", "2", "a"))
+	assert.Equal(t, "-ERR Parameter `numFields` should be greater than 0\r\n", run("HTTL", "h", "FIELDS", "0", "a"))
+	assert.Equal(t, "-ERR invalid expire time, must be >= 0 && <= 2^48\r\n", run("HPEXPIREAT", "h", "281474976710657", "FIELDS", "1", "a"))
+	run("SET", "s", "v")
+	assert.True(t, strings.HasPrefix(run("HEXPIRE", "s", "10", "FIELDS", "1", "a"), "-WRONGTYPE"))
+
+	// 访问时删除到期的字段，主动过期删除其余到期的字段，最后的字段过期后删除键
+	clock.Advance(11 * time.Second)
+	assert.Equal(t, "$-1\r\n", run("HGET", "h", "a"))
+	assert.Equal(t, ":1\r\n", run("HLEN", "h"))
+	assert.Equal(t, "*1\r\n:1\r\n", run("HPEXPIRE", "h", "100", "FIELDS", "1", "c"))
+	clock.Advance(time.Second)
+	handler.runExpireCycle()
+	assert.Equal(t, ":0\r\n", run("EXISTS", "h"))
+	assert.True(t, strings.Contains(handler.buildInfoResponse("STATS"), "expired_subkeys:3\n"))
+}
+
+func TestInlineAndProtocolErrors(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		_ = handler.ServeTCP(listener)
+	}()
+
+	dial := func() (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		assert.NoError(t, err)
+		assert.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+		return conn, bufio.NewReader(conn)
+	}
+
+	// 内联命令：空行被忽略，引号内的空格属于参数
+	conn, reader := dial()
+	defer conn.Close()
+	_, err = conn.Write([]byte("PING\r\n\r\nSET greeting \"hello world\"\r\nGET greeting\r\n"))
+	assert.NoError(t, err)
+	for _, expected := range []string{"+PONG\r\n", "+OK\r\n", "$11\r\n"} {
+		line, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, expected, line)
+	}
+	line, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world\r\n", line)
+
+	// 协议错误：先写出之前命令的回复，再回复错误并关闭连接
+	conn2, reader2 := dial()
+	defer conn2.Close()
+	_, err = conn2.Write([]byte("*1\r\n$4\r\nPING\r\n*1\r\n$abc\r\nPING\r\n"))
+	assert.NoError(t, err)
+	line, err = reader2.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "+PONG\r\n", line)
+	line, err = reader2.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "-ERR Protocol error: invalid bulk length\r\n", line)
+	_, err = reader2.ReadString('\n')
+	assert.Error(t, err)
+
+	conn3, reader3 := dial()
+	defer conn3.Close()
+	_, err = conn3.Write([]byte("SET k \"unterminated\r\n"))
+	assert.NoError(t, err)
+	line, err = reader3.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "-ERR Protocol error: unbalanced quotes in request\r\n", line)
+	_, err = reader3.ReadString('\n')
+	assert.Error(t, err)
+}
+
+func TestLogicalDatabases(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	run("SET", "k", "v0")
+	assert.Equal(t, "+OK\r\n", run("SELECT", "3"))
+	assert.Equal(t, "$-1\r\n", run("GET", "k"))
+	run("SET", "k", "v3")
+	run("RPUSH", "l", "a", "b")
+	assert.Equal(t, "*2\r\n$1\r\nk\r\n$1\r\nl\r\n", run("KEYS", "*"))
+	assert.Equal(t, ":2\r\n", run("DBSIZE"))
+	assert.Equal(t, "*2\r\n$1\r\n0\r\n*2\r\n$1\r\nk\r\n$1\r\nl\r\n", run("SCAN", "0"))
+	// 回复中的键名不带数据库前缀
+	assert.Equal(t, "*2\r\n$1\r\nl\r\n$1\r\na\r\n", run("BLPOP", "l", "0"))
+	run("MULTI")
+	run("GET", "k")
+	run("BLPOP", "l", "0")
+	assert.Equal(t, "*2\r\n$2\r\nv3\r\n*2\r\n$1\r\nl\r\n$1\r\nb\r\n", run("EXEC"))
+	assert.Equal(t, "$2\r\nv3\r\n", run("EVAL", "return redis.call('GET', KEYS[1])", "1", "k"))
+	assert.Equal(t, "$2\r\nv3\r\n", run("EVAL", "return redis.call('GET', 'k')", "0"))
+	run("DEL", "l")
+
+	// MOVE 不覆盖目标数据库中已有的键
+	assert.Equal(t, ":0\r\n", run("MOVE", "k", "0"))
+	assert.Equal(t, "-ERR source and destination objects are the same\r\n", run("MOVE", "k", "3"))
+	run("SET", "m", "x")
+	run("EXPIRE", "m", "100")
+	assert.Equal(t, ":1\r\n", run("MOVE", "m", "5"))
+	assert.Equal(t, ":0\r\n", run("EXISTS", "m"))
+	assert.Equal(t, ":1\r\n", run("COPY", "k", "k2", "DB", "5"))
+	assert.Equal(t, "-ERR DB index is out of range\r\n", run("COPY", "k", "k2", "DB", "16"))
+
+	assert.Equal(t, "+OK\r\n", run("SELECT", "5"))
+	assert.Equal(t, "$1\r\nx\r\n", run("GET", "m"))
+	assert.Equal(t, ":100\r\n", run("TTL", "m"))
+	assert.Equal(t, "$2\r\nv3\r\n", run("GET", "k2"))
+
+	run("SELECT", "0")
+	assert.Equal(t, "$2\r\nv0\r\n", run("GET", "k"))
+	assert.Equal(t, ":1\r\n", run("DBSIZE"))
+	assert.Equal(t, "*1\r\n$1\r\nk\r\n", run("KEYS", "*"))
+	assert.Equal(t, "$1\r\nk\r\n", run("RANDOMKEY"))
+	keyspace := handler.buildInfoResponse("KEYSPACE")
+	assert.True(t, strings.Contains(keyspace, "db0:keys=1,"))
+	assert.True(t, strings.Contains(keyspace, "db3:keys=1,"))
+	assert.True(t, strings.Contains(keyspace, "db5:keys=2,"))
+
+	// SWAPDB 之后两个数据库的内容互换，FLUSHDB 只清空当前数据库
+	assert.Equal(t, "+OK\r\n", run("SWAPDB", "0", "3"))
+	assert.Equal(t, "$2\r\nv3\r\n", run("GET", "k"))
+	run("SELECT", "3")
+	assert.Equal(t, "$2\r\nv0\r\n", run("GET", "k"))
+	assert.Equal(t, "+OK\r\n", run("FLUSHDB"))
+	assert.Equal(t, ":0\r\n", run("DBSIZE"))
+	run("SELECT", "0")
+	assert.Equal(t, "$2\r\nv3\r\n", run("GET", "k"))
+	run("SELECT", "5")
+	assert.Equal(t, ":2\r\n", run("DBSIZE"))
+	assert.Equal(t, "+OK\r\n", run("FLUSHALL"))
+	assert.Equal(t, ":0\r\n", run("DBSIZE"))
+
+	assert.Equal(t, "-ERR DB index is out of range\r\n", run("SELECT", "16"))
+	assert.Equal(t, "-ERR value is not an integer or out of range\r\n", run("SELECT", "x"))
+	assert.Equal(t, "-ERR invalid first DB index\r\n", run("SWAPDB", "x", "1"))
+	assert.Equal(t, "-ERR DB index is out of range\r\n", run("SWAPDB", "0", "16"))
+	handler.SetDatabases(32)
+	assert.Equal(t, "+OK\r\n", run("SELECT", "31"))
+	run("SELECT", "0")
+}
+
+func TestFlushDBAsync(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	assert.NoError(t, handler.ConfigureAOF(path, "always"))
+	assert.NoError(t, handler.StartAOF())
+	runOn := func(h *Handler, args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return h.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+	run := func(args ...string) string { return runOn(handler, args...) }
+
+	run("SET", "k", "v0")
+	run("SELECT", "2")
+	run("SET", "k", "old")
+	run("HSET", "h", "f", "v")
+	assert.Equal(t, "-ERR syntax error\r\n", run("FLUSHDB", "LAZY"))
+	assert.Equal(t, "-ERR syntax error\r\n", run("FLUSHDB", "ASYNC", "SYNC"))
+	assert.Equal(t, "+OK\r\n", run("FLUSHDB", "ASYNC"))
+	assert.Equal(t, ":0\r\n", run("DBSIZE"))
+	run("SET", "k", "new")
+	assert.Equal(t, "$3\r\nnew\r\n", run("GET", "k"))
+	run("SELECT", "0")
+	assert.Equal(t, "$2\r\nv0\r\n", run("GET", "k"))
+
+	// AOF 中的键名不带代，重放时 FLUSHDB ASYNC 同样换代
+	var cmds []string
+	_, err := aof.Load(path, func(args [][]byte) error {
+		parts := make([]string, len(args))
+		for i, a := range args {
+			parts[i] = string(a)
+		}
+		cmds = append(cmds, strings.Join(parts, " "))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "SET "+store.DBKey(2, "k")+" new", cmds[len(cmds)-1])
+	assert.Equal(t, "FLUSHDB ASYNC", cmds[len(cmds)-3])
+	check := func(h *Handler) {
+		runOn(h, "SELECT", "2")
+		assert.Equal(t, ":1\r\n", runOn(h, "DBSIZE"))
+		assert.Equal(t, "$3\r\nnew\r\n", runOn(h, "GET", "k"))
+	}
+	replayed := setupTestHandler(t)
+	defer replayed.Db.Close()
+	assert.NoError(t, replayed.ConfigureAOF(path, "no"))
+	assert.NoError(t, replayed.StartAOF())
+	check(replayed)
+	assert.NoError(t, replayed.CloseAOF())
+
+	assert.NoError(t, handler.rewriteAOF())
+	rewritten := setupTestHandler(t)
+	defer rewritten.Db.Close()
+	assert.NoError(t, rewritten.ConfigureAOF(path, "no"))
+	assert.NoError(t, rewritten.StartAOF())
+	check(rewritten)
+	assert.NoError(t, rewritten.CloseAOF())
+	assert.NoError(t, handler.CloseAOF())
+}
+
+// TestBackupCommand 测试 BOLTREON.BACKUP 与备份相关的 CONFIG 参数
+func TestBackupCommand(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	assert.Equal(t, run("BOLTREON.BACKUP"), "-ERR backup not enabled\r\n")
+	assert.True(t, strings.HasPrefix(run("CONFIG", "SET", "backup-schedule", "@daily"), "-ERR"))
+
+	dir := t.TempDir()
+	handler.Backup = backup.NewBackupManager(handler.Db, dir)
+	defer handler.Backup.Close()
+
+	assert.Equal(t, run("SET", "a", "1"), "+OK\r\n")
+	assert.True(t, strings.HasSuffix(run("BOLTREON.BACKUP"), "$4\r\nfull\r\n"))
+	assert.Equal(t, run("SET", "b", "2"), "+OK\r\n")
+	assert.True(t, strings.HasSuffix(run("BOLTREON.BACKUP", "incremental"), "$11\r\nincremental\r\n"))
+	assert.True(t, strings.HasSuffix(run("BOLTREON.BACKUP", "FULL"), "$4\r\nfull\r\n"))
+	assert.True(t, strings.HasPrefix(run("BOLTREON.BACKUP", "LIST"), "*3\r\n*4\r\n"))
+	assert.True(t, strings.HasPrefix(run("BOLTREON.BACKUP", "NOPE"), "-ERR"))
+
+	assert.True(t, strings.HasPrefix(run("CONFIG", "SET", "backup-schedule", "61 * * * *"), "-ERR"))
+	assert.Equal(t, run("CONFIG", "SET", "backup-schedule", "@daily"), "+OK\r\n")
+	assert.Equal(t, run("CONFIG", "GET", "backup-schedule"), "*2\r\n$15\r\nbackup-schedule\r\n$6\r\n@daily\r\n")
+	assert.Equal(t, run("CONFIG", "SET", "backup-schedule", ""), "+OK\r\n")
+
+	// 只保留最近一个全量备份组，下一次备份时删除更早的
+	assert.Equal(t, run("CONFIG", "SET", "backup-retention", "1"), "+OK\r\n")
+	assert.Equal(t, run("CONFIG", "GET", "backup-retention"), "*2\r\n$16\r\nbackup-retention\r\n$1\r\n1\r\n")
+	run("BOLTREON.BACKUP")
+	entries, err := backup.ListCatalog(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, len(entries), 2)
+	assert.True(t, entries[0].Full)
+	assert.False(t, entries[1].Full)
+
+	info := run("INFO", "persistence")
+	assert.True(t, strings.Contains(info, "backup_last_status:ok"))
+	assert.False(t, strings.Contains(info, "backup_last_time:-1"))
+}
+
+// TestReplicaReadOnly 测试只读从节点拒绝客户端的写命令，READWRITE 与 replica-read-only no 时允许
+func TestReplicaReadOnly(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	repl := replication.NewReplicationManager(handler.Db)
+	repl.SetRole(replication.RoleSlave)
+	handler.Replication = repl
+	runOn := func(h *Handler, args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return h.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+	run := func(args ...string) string { return runOn(handler, args...) }
+	const readOnlyErr = "-READONLY You can't write against a read only replica.\r\n"
+
+	assert.Equal(t, run("SET", "a", "1"), readOnlyErr)
+	assert.Equal(t, run("FLUSHALL"), readOnlyErr)
+	assert.Equal(t, run("XREADGROUP", "GROUP", "g", "c", "STREAMS", "s", ">"), readOnlyErr)
+	assert.Equal(t, run("SORT", "l", "STORE", "dst"), readOnlyErr)
+	assert.Equal(t, run("GET", "a"), "$-1\r\n")
+	assert.True(t, strings.Contains(run("EVAL", "return redis.call('SET', KEYS[1], '1')", "1", "a"), "READONLY"))
+	assert.Equal(t, run("EVAL", "return redis.call('GET', KEYS[1])", "1", "a"), "$-1\r\n")
+
+	// 事务中的写命令在入队时被拒绝，EXEC 放弃整个事务
+	assert.Equal(t, run("MULTI"), "+OK\r\n")
+	assert.Equal(t, run("SET", "a", "1"), readOnlyErr)
+	assert.True(t, strings.HasPrefix(run("EXEC"), "-EXECABORT"))
+
+	// 主节点传播的命令不受影响
+	handler.replicaExecutor()([][]byte{[]byte("SET"), []byte("a"), []byte("master")})
+	assert.Equal(t, run("GET", "a"), "$6\r\nmaster\r\n")
+
+	// READWRITE 的连接可以写入，READONLY 取消
+	conn := handler.newConnection()
+	assert.Equal(t, runOn(conn, "READWRITE"), "+OK\r\n")
+	assert.Equal(t, runOn(conn, "SET", "b", "1"), "+OK\r\n")
+	assert.Equal(t, run("SET", "b", "2"), readOnlyErr)
+	assert.Equal(t, runOn(conn, "READONLY"), "+OK\r\n")
+	assert.Equal(t, runOn(conn, "SET", "b", "2"), readOnlyErr)
+
+	assert.True(t, strings.Contains(run("INFO", "replication"), "slave_read_only:1\n"))
+	assert.Equal(t, run("CONFIG", "SET", "replica-read-only", "no"), "+OK\r\n")
+	assert.Equal(t, run("CONFIG", "GET", "slave-read-only"), "*2\r\n$15\r\nslave-read-only\r\n$2\r\nno\r\n")
+	assert.True(t, strings.Contains(run("INFO", "replication"), "slave_read_only:0\n"))
+	assert.Equal(t, run("SET", "b", "3"), "+OK\r\n")
+	assert.Equal(t, run("CONFIG", "SET", "slave-read-only", "yes"), "+OK\r\n")
+	assert.Equal(t, run("SET", "b", "4"), readOnlyErr)
+
+	// 流水线中合并执行的写命令同样被拒绝
+	reader := bufio.NewReader(strings.NewReader(
+		(&proto.Array{Args: [][]byte{[]byte("SET"), []byte("a"), []byte("1")}}).String() +
+			(&proto.Array{Args: [][]byte{[]byte("SADD"), []byte("s"), []byte("m")}}).String()))
+	req, err := proto.ReadRESP(reader)
+	assert.NoError(t, err)
+	responses, _, _ := handler.coalesceWrites(req, reader, "127.0.0.1:12345", nil)
+	assert.Equal(t, len(responses), 2)
+	for _, r := range responses {
+		assert.Equal(t, r.String(), readOnlyErr)
+	}
+
+	// 提升为主节点后接受写命令
+	repl.SetRole(replication.RoleMaster)
+	assert.Equal(t, run("SET", "b", "5"), "+OK\r\n")
+}
+
+func TestLatencyMonitor(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	// 默认不记录
+	assert.Equal(t, run("CONFIG", "GET", "latency-monitor-threshold"), "*2\r\n$25\r\nlatency-monitor-threshold\r\n$1\r\n0\r\n")
+	assert.Equal(t, run("DEBUG", "SLEEP", "0.02"), "+OK\r\n")
+	assert.Equal(t, run("LATENCY", "LATEST"), "*0\r\n")
+	assert.True(t, strings.Contains(run("LATENCY", "DOCTOR"), "Latency monitoring is disabled"))
+
+	assert.Equal(t, run("CONFIG", "SET", "latency-monitor-threshold", "-1"), "-ERR CONFIG SET failed (possibly related to argument 'latency-monitor-threshold') - argument must be between 0 and 9223372036854775807 inclusive\r\n")
+	assert.Equal(t, run("CONFIG", "SET", "latency-monitor-threshold", "10"), "+OK\r\n")
+	assert.Equal(t, run("SET", "k", "v"), "+OK\r\n")
+	assert.Equal(t, run("LATENCY", "LATEST"), "*0\r\n")
+	assert.Equal(t, run("DEBUG", "SLEEP", "0.02"), "+OK\r\n")
+	handler.addLatencySample(latencyEventExpireCycle, 30*time.Millisecond)
+	handler.addLatencySample(latencyEventBadgerGC, 5*time.Millisecond)
+
+	latest := run("LATENCY", "LATEST")
+	assert.True(t, strings.HasPrefix(latest, "*2\r\n*4\r\n$7\r\ncommand\r\n:"))
+	assert.True(t, strings.Contains(latest, "$12\r\nexpire-cycle\r\n"))
+	assert.True(t, strings.HasSuffix(latest, ":30\r\n:30\r\n"))
+	history := run("LATENCY", "HISTORY", "expire-cycle")
+	assert.True(t, strings.HasPrefix(history, "*1\r\n*2\r\n:"))
+	assert.True(t, strings.HasSuffix(history, ":30\r\n"))
+	assert.Equal(t, run("LATENCY", "HISTORY", "badger-gc"), "*0\r\n")
+	assert.Equal(t, run("LATENCY", "HISTORY"), "-ERR wrong number of arguments for 'latency|history' command\r\n")
+	doctor := run("LATENCY", "DOCTOR")
+	assert.True(t, strings.Contains(doctor, "2. expire-cycle: 1 latency spikes (average 30ms"))
+	assert.True(t, strings.Contains(doctor, "many keys expire at the same time"))
+
+	assert.Equal(t, run("LATENCY", "RESET", "expire-cycle", "badger-gc"), ":1\r\n")
+	assert.Equal(t, run("LATENCY", "HISTORY", "expire-cycle"), "*0\r\n")
+	assert.Equal(t, run("LATENCY", "RESET"), ":1\r\n")
+	assert.Equal(t, run("LATENCY", "LATEST"), "*0\r\n")
+	assert.Equal(t, run("LATENCY", "NOPE"), "-ERR unknown subcommand 'NOPE'\r\n")
+}
+
+func TestLatencyMonitorSeries(t *testing.T) {
+	var m latencyMonitor
+	now := time.Unix(1000, 0)
+	// 同一秒内只保留最大的样本
+	m.add("command", 20, now)
+	m.add("command", 50, now)
+	m.add("command", 30, now)
+	assert.DeepEqual(t, m.history("command"), []latencyEventSample{{time: 1000, latency: 50}})
+
+	// 超过 latencyHistoryLen 时丢弃最旧的样本，最大值保留
+	for i := 1; i <= latencyHistoryLen; i++ {
+		m.add("command", int64(10+i%10), now.Add(time.Duration(i)*time.Second))
+	}
+	history := m.history("command")
+	assert.Equal(t, len(history), latencyHistoryLen)
+	assert.Equal(t, history[0], latencyEventSample{time: 1001, latency: 11})
+	assert.Equal(t, history[latencyHistoryLen-1], latencyEventSample{time: 1000 + latencyHistoryLen, latency: 10})
+	summaries := m.summaries()
+	assert.Equal(t, len(summaries), 1)
+	assert.Equal(t, summaries[0].max, int64(50))
+	assert.Equal(t, summaries[0].last.latency, int64(10))
+}
+
+func TestValueLogGCAndCompact(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	assert.Equal(t, run("CONFIG", "GET", "badger-gc-interval"), "*2\r\n$18\r\nbadger-gc-interval\r\n$3\r\n600\r\n")
+	assert.Equal(t, run("CONFIG", "GET", "badger-gc-discard-ratio"), "*2\r\n$23\r\nbadger-gc-discard-ratio\r\n$3\r\n0.5\r\n")
+	assert.Equal(t, run("CONFIG", "SET", "badger-gc-interval", "0"), "+OK\r\n")
+	assert.Equal(t, handler.valueLogGCInterval(), time.Duration(0))
+	assert.Equal(t, run("CONFIG", "SET", "badger-gc-discard-ratio", "0.7"), "+OK\r\n")
+	assert.Equal(t, handler.valueLogGCDiscardRatio(), 0.7)
+	assert.Equal(t, run("CONFIG", "SET", "badger-gc-discard-ratio", "1"),
+		"-ERR CONFIG SET failed (possibly related to argument 'badger-gc-discard-ratio') - argument must be greater than 0 and less than 1\r\n")
+
+	info := run("INFO", "persistence")
+	assert.True(t, strings.Contains(info, "badger_gc_runs:0\nbadger_gc_last_time:-1\nbadger_gc_last_status:ok\n"))
+	assert.True(t, strings.Contains(info, "badger_compact_in_progress:0\nbadger_compact_runs:0\n"))
+
+	rewritten, err := handler.runValueLogGC(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, rewritten, 0)
+	assert.True(t, strings.Contains(run("INFO", "persistence"), "badger_gc_runs:1\n"))
+
+	assert.Equal(t, run("SET", "k", "v"), "+OK\r\n")
+	assert.Equal(t, run("BOLTREON.COMPACT"), "+Background compaction started\r\n")
+	deadline := time.Now().Add(5 * time.Second)
+	for strings.Contains(run("INFO", "persistence"), "badger_compact_in_progress:1") && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	info = run("INFO", "persistence")
+	assert.True(t, strings.Contains(info, "badger_compact_runs:1\n"))
+	assert.True(t, strings.Contains(info, "badger_compact_last_status:ok\n"))
+	assert.True(t, strings.Contains(info, "badger_gc_runs:2\n"))
+	assert.Equal(t, run("GET", "k"), "$1\r\nv\r\n")
+	assert.Equal(t, run("BOLTREON.COMPACT", "NOW"), "-ERR wrong number of arguments for 'boltreon.compact' command\r\n")
+}
+
+func TestRateLimit(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	runOn := func(h *Handler, args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return h.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+	run := func(args ...string) string { return runOn(handler, args...) }
+
+	// 命令限速：整个服务器共享
+	assert.Equal(t, run("CONFIG", "SET", "rate-limit-commands", "KEYS 2 flushall 0.01"), "+OK\r\n")
+	assert.Equal(t, run("CONFIG", "GET", "rate-limit-commands"), "*2\r\n$19\r\nrate-limit-commands\r\n$20\r\nflushall 0.01 keys 2\r\n")
+	assert.Equal(t, run("KEYS", "*"), "*0\r\n")
+	assert.Equal(t, runOn(handler.newConnection(), "KEYS", "*"), "*0\r\n")
+	assert.Equal(t, run("KEYS", "*"), "-LIMIT command rate limit exceeded for 'keys'\r\n")
+	assert.Equal(t, run("FLUSHALL"), "+OK\r\n")
+	assert.Equal(t, run("FLUSHALL"), "-LIMIT command rate limit exceeded for 'flushall'\r\n")
+	assert.Equal(t, run("GET", "k"), "$-1\r\n")
+	assert.True(t, strings.Contains(run("INFO", "stats"), "rate_limited_commands:2\n"))
+	assert.Equal(t, run("CONFIG", "SET", "rate-limit-commands", "KEYS"),
+		"-ERR CONFIG SET failed (possibly related to argument 'rate-limit-commands') - argument must be a list of command and ops per second pairs\r\n")
+	assert.Equal(t, run("CONFIG", "SET", "rate-limit-commands", "KEYS 0"),
+		"-ERR CONFIG SET failed (possibly related to argument 'rate-limit-commands') - invalid ops per second '0' for command 'KEYS'\r\n")
+	assert.Equal(t, run("CONFIG", "SET", "rate-limit-commands", ""), "+OK\r\n")
+	assert.Equal(t, run("KEYS", "*"), "*0\r\n")
+
+	// 连接限速：每个连接单独计数，事务中的命令在入队时计入
+	assert.Equal(t, run("CONFIG", "SET", "rate-limit-client", "3"), "+OK\r\n")
+	conn := handler.newConnection()
+	assert.Equal(t, runOn(conn, "MULTI"), "+OK\r\n")
+	assert.Equal(t, runOn(conn, "SET", "a", "1"), "+QUEUED\r\n")
+	assert.Equal(t, runOn(conn, "SET", "b", "2"), "+QUEUED\r\n")
+	assert.Equal(t, runOn(conn, "SET", "c", "3"), "-LIMIT client rate limit exceeded\r\n")
+	assert.Equal(t, runOn(conn, "EXEC"), "-LIMIT client rate limit exceeded\r\n")
+	other := handler.newConnection()
+	assert.Equal(t, runOn(other, "PING"), "+PONG\r\n")
+	assert.Equal(t, runOn(other, "CONFIG", "SET", "rate-limit-client", "0"), "+OK\r\n")
+	assert.Equal(t, runOn(conn, "EXEC"), "-EXECABORT Transaction discarded because of previous errors.\r\n")
+
+	// 键限速：所有连接合计，每秒清零；从一秒的开头开始，避免计数中途清零
+	assert.Equal(t, run("CONFIG", "SET", "rate-limit-key", "2"), "+OK\r\n")
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	assert.Equal(t, run("SET", "hot", "1"), "+OK\r\n")
+	assert.Equal(t, runOn(handler.newConnection(), "GET", "hot"), "$1\r\n1\r\n")
+	assert.Equal(t, run("GET", "hot"), "-LIMIT key rate limit exceeded\r\n")
+	assert.Equal(t, run("MGET", "cold", "hot"), "-LIMIT key rate limit exceeded\r\n")
+	assert.Equal(t, run("GET", "cold"), "$-1\r\n")
+	assert.Equal(t, run("CONFIG", "SET", "rate-limit-key", "0"), "+OK\r\n")
+	assert.Equal(t, run("GET", "hot"), "$1\r\n1\r\n")
+}
+
+func TestTokenBucket(t *testing.T) {
+	var b tokenBucket
+	now := time.Unix(1000, 0)
+	// 每秒 0.5 次：最多积累一个令牌，两秒补充一个
+	assert.True(t, b.take(0.5, now))
+	assert.False(t, b.take(0.5, now.Add(time.Second)))
+	assert.True(t, b.take(0.5, now.Add(3*time.Second)))
+	assert.False(t, b.take(0.5, now.Add(3*time.Second)))
+
+	b = tokenBucket{}
+	for i := 0; i < 10; i++ {
+		assert.True(t, b.take(10, now))
+	}
+	assert.False(t, b.take(10, now))
+	assert.True(t, b.take(10, now.Add(100*time.Millisecond)))
+}
+
+func TestKeysSkipExpired(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	run("SET", "gone", "v", "PX", "20")
+	run("SET", "user:1", "v")
+	run("SELECT", "1")
+	run("SET", "user:2", "v")
+	run("SELECT", "0")
+	time.Sleep(50 * time.Millisecond)
+
+	// 已过期但尚未被删除的键与其他数据库的键都不出现在 KEYS、SCAN、RANDOMKEY 中
+	assert.Equal(t, "*1\r\n$6\r\nuser:1\r\n", run("KEYS", "*"))
+	assert.Equal(t, "*1\r\n$6\r\nuser:1\r\n", run("KEYS", "user:*"))
+	assert.Equal(t, "*2\r\n$1\r\n0\r\n*1\r\n$6\r\nuser:1\r\n", run("SCAN", "0", "MATCH", "*"))
+	assert.Equal(t, "$6\r\nuser:1\r\n", run("RANDOMKEY"))
+	run("DEL", "user:1")
+	assert.Equal(t, "$-1\r\n", run("RANDOMKEY"))
+}
+
+func TestCopyPreservesTTL(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+	run := func(args ...string) string {
+		req := &proto.Array{Args: make([][]byte, len(args))}
+		for i, a := range args {
+			req.Args[i] = []byte(a)
+		}
+		return handler.processRequest(req, nil, "127.0.0.1:12345", nil, nil).String()
+	}
+
+	run("HSET", "h", "f", "v")
+	run("EXPIRE", "h", "100")
+	assert.Equal(t, ":1\r\n", run("COPY", "h", "h2"))
+	assert.Equal(t, ":100\r\n", run("TTL", "h2"))
+	assert.Equal(t, "$1\r\nv\r\n", run("HGET", "h2", "f"))
+	assert.Equal(t, ":0\r\n", run("COPY", "h", "h2"))
+	assert.Equal(t, "-ERR source and destination objects are the same\r\n", run("COPY", "h", "h", "REPLACE"))
+	run("JSON.SET", "j", "$", `{"a":1}`)
+	assert.Equal(t, ":1\r\n", run("COPY", "j", "h2", "REPLACE"))
+	assert.Equal(t, "+json\r\n", run("TYPE", "h2"))
+	assert.Equal(t, "-ERR no such key\r\n", run("RENAME", "missing", "x"))
+}
+
+// TestLoadingBeforeListen 在开始监听前进入加载状态，第一个连接也收到 -LOADING
+func TestLoadingBeforeListen(t *testing.T) {
+	handler := setupTestHandler(t)
+	defer handler.Db.Close()
+
+	handler.SetLoading(true)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		_ = handler.ServeTCP(listener)
+	}()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	resp, err := sendCommand(conn, reader, "SET", "k", "v")
+	assert.NoError(t, err)
+	assert.Equal(t, "-LOADING Redis is loading the dataset in memory\r\n", resp.String())
+	start := handler.root().loading.startTime.Load()
+
+	// RunLoading 沿用已有的加载状态，结束时清除
+	assert.NoError(t, handler.RunLoading(func() error {
+		assert.True(t, handler.IsLoading())
+		assert.Equal(t, start, handler.root().loading.startTime.Load())
+		return nil
+	}))
+	assert.False(t, handler.IsLoading())
+	resp, err = sendCommand(conn, reader, "SET", "k", "v")
+	assert.NoError(t, err)
+	assert.Equal(t, "+OK\r\n", resp.String())
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// parseHashFields 解析 FIELDS numfields field... 部分，args 从 FIELDS 开始
+func parseHashFields(args [][]byte) ([]string, proto.RESP) {
+	if len(args) < 2 || !strings.EqualFold(string(args[0]), "FIELDS") {
+		return nil, proto.NewError("ERR Mandatory argument FIELDS is missing or not at the right position")
+	}
+	n, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return nil, proto.NewError(errNotInteger)
+	}
+	if n <= 0 {
+		return nil, proto.NewError("ERR Parameter `numFields` should be greater than 0")
+	}
+	if n != int64(len(args)-2) {
+		return nil, proto.NewError("ERR The `numfields` parameter must match the number of arguments")
+	}
+	fields := make([]string, n)
+	for i := range fields {
+		fields[i] = string(args[2+i])
+	}
+	return fields, nil
+}
+
+// handleHashFieldExpire 处理 HEXPIRE、HPEXPIRE、HEXPIREAT、HPEXPIREAT：
+// key time [NX|XX|GT|LT] FIELDS numfields field...，time 换算为 Unix 毫秒后不能超过 2^48
+func (h *Handler) handleHashFieldExpire(cmd string, args [][]byte) proto.RESP {
+	if len(args) < 4 {
+		return proto.NewError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd)))
+	}
+	key := string(args[0])
+	v, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return proto.NewError(errNotInteger)
+	}
+	unit := int64(1)
+	if cmd == "HEXPIRE" || cmd == "HEXPIREAT" {
+		unit = 1000
+	}
+	if v < 0 || v > math.MaxInt64/unit {
+		return proto.NewError("ERR invalid expire time, must be >= 0 && <= 2^48")
+	}
+	at := v * unit
+	if cmd == "HEXPIRE" || cmd == "HPEXPIRE" {
+		at += h.Db.Clock().Now().UnixMilli()
+	}
+	if at > store.HashFieldMaxExpireMs {
+		return proto.NewError("ERR invalid expire time, must be >= 0 && <= 2^48")
+	}
+
+	rest := args[2:]
+	cond := ""
+	switch option := strings.ToUpper(string(rest[0])); option {
+	case "NX", "XX", "GT", "LT":
+		cond = option
+		rest = rest[1:]
+	}
+	fields, errResp := parseHashFields(rest)
+	if errResp != nil {
+		return errResp
+	}
+	results, err := h.Db.HExpireAt(key, at, cond, fields...)
+	if err != nil {
+		return proto.NewError(fmt.Sprintf("ERR %v", err))
+	}
+	return integerArray(results)
+}
+
+// handleHashFieldTTL 处理 HPERSIST、HTTL、HPTTL、HEXPIRETIME、HPEXPIRETIME：key FIELDS numfields field...
+func (h *Handler) handleHashFieldTTL(cmd string, args [][]byte) proto.RESP {
+	if len(args) < 3 {
+		return proto.NewError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd)))
+	}
+	key := string(args[0])
+	fields, errResp := parseHashFields(args[1:])
+	if errResp != nil {
+		return errResp
+	}
+	if cmd == "HPERSIST" {
+		results, err := h.Db.HPersist(key, fields...)
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		return integerArray(results)
+	}
+	results, err := h.Db.HFieldExpireTimes(key, fields...)
+	if err != nil {
+		return proto.NewError(fmt.Sprintf("ERR %v", err))
+	}
+	now := h.Db.Clock().Now().UnixMilli()
+	for i, at := range results {
+		if at < 0 {
+			continue
+		}
+		switch cmd {
+		case "HTTL":
+			results[i] = max(at-now+999, 0) / 1000
+		case "HPTTL":
+			results[i] = max(at-now, 0)
+		case "HEXPIRETIME":
+			results[i] = at / 1000
+		}
+	}
+	return integerArray(results)
+}
+
+// integerArray 整数数组回复
+func integerArray(values []int64) proto.RESP {
+	reply := make([]proto.RESP, len(values))
+	for i, v := range values {
+		reply[i] = proto.NewInteger(v)
+	}
+	return &proto.NestedArray{Elems: reply}
+}
@@ -1,23 +1,65 @@
 package server
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/backup"
+	"github.com/lbp0200/BoltDB/internal/proto"
 )
 
+// serverRunID 本进程的 run_id，每次启动都不同
+var serverRunID = newRunID()
+
+func newRunID() string {
+	b := make([]byte, 20)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// infoDefaultSections 不带参数的 INFO 返回的部分；commandstats 只在 INFO ALL/EVERYTHING 或单独请求时返回
+var infoDefaultSections = map[string]bool{
+	"SERVER": true, "CLIENTS": true, "MEMORY": true, "PERSISTENCE": true, "STATS": true,
+	"REPLICATION": true, "LATENCYSTATS": true, "CLUSTER": true, "KEYSPACE": true,
+}
+
+// infoSectionSelected 请求的 section 是否包含 name
+func infoSectionSelected(section, name string) bool {
+	switch section {
+	case "", "DEFAULT":
+		return infoDefaultSections[name]
+	case "ALL", "EVERYTHING":
+		return true
+	}
+	return section == name
+}
+
 // buildInfoResponse 构建INFO响应
 // 增强对 redis-sentinel 的兼容性
 func (h *Handler) buildInfoResponse(section string) string {
 	var builder strings.Builder
+	stats := h.root().stats.Snapshot()
 
-	if section == "" || section == "ALL" || section == "SERVER" {
+	if infoSectionSelected(section, "SERVER") {
+		uptime := int64(time.Since(stats.StartTime) / time.Second)
 		builder.WriteString("# Server\n")
 		builder.WriteString("redis_version:boltdb-8.0.0\n")
+		if h.Cluster != nil {
+			builder.WriteString("redis_mode:cluster\n")
+		} else {
+			builder.WriteString("redis_mode:standalone\n")
+		}
 		builder.WriteString("os:" + runtime.GOOS + "\n")
 		builder.WriteString("arch_bits:64\n")
-		builder.WriteString("tcp_port:6379\n")
+		builder.WriteString(fmt.Sprintf("tcp_port:%s\n", h.tcpPort()))
 		if runtime.GOOS == "linux" {
 			builder.WriteString("multiplexing_api:epoll\n")
 		} else {
@@ -25,14 +67,116 @@ func (h *Handler) buildInfoResponse(section string) string {
 		}
 		builder.WriteString("gcc_version:" + runtime.Version() + "\n")
 		builder.WriteString(fmt.Sprintf("process_id:%d\n", os.Getpid()))
-		builder.WriteString("run_id:\n")
+		builder.WriteString("run_id:" + serverRunID + "\n")
 		builder.WriteString("tcp_backlog:511\n")
-		builder.WriteString("uptime_in_seconds:0\n")
-		builder.WriteString("uptime_in_days:0\n")
+		builder.WriteString(fmt.Sprintf("server_time_usec:%d\n", time.Now().UnixMicro()))
+		builder.WriteString(fmt.Sprintf("uptime_in_seconds:%d\n", uptime))
+		builder.WriteString(fmt.Sprintf("uptime_in_days:%d\n", uptime/86400))
+		builder.WriteString("\n")
+	}
+
+	if infoSectionSelected(section, "CLIENTS") {
+		builder.WriteString("# Clients\n")
+		builder.WriteString(fmt.Sprintf("connected_clients:%d\n", stats.ConnectedClients))
+		if h.Db != nil {
+			builder.WriteString(fmt.Sprintf("blocked_clients:%d\n", h.Db.BlockedClients()))
+			builder.WriteString(fmt.Sprintf("total_blocking_keys:%d\n", h.Db.BlockingKeys()))
+		} else {
+			builder.WriteString("blocked_clients:0\n")
+			builder.WriteString("total_blocking_keys:0\n")
+		}
+		workers := &h.root().conns.workers
+		builder.WriteString(fmt.Sprintf("connection_workers:%d\n", workers.total.Load()))
+		builder.WriteString(fmt.Sprintf("connection_workers_idle:%d\n", workers.idle.Load()))
+		for i, l := range h.root().listeners.snapshot() {
+			builder.WriteString(fmt.Sprintf("listener%d:addr=%s,connected_clients=%d,total_connections=%d\n",
+				i, l.addr, l.connected.Load(), l.total.Load()))
+		}
 		builder.WriteString("\n")
 	}
 
-	if section == "" || section == "ALL" || section == "REPLICATION" {
+	if infoSectionSelected(section, "MEMORY") {
+		builder.WriteString("# Memory\n")
+		h.writeMemoryInfo(&builder)
+		builder.WriteString("\n")
+	}
+
+	if infoSectionSelected(section, "PERSISTENCE") {
+		builder.WriteString("# Persistence\n")
+		if h.IsLoading() {
+			builder.WriteString("loading:1\n")
+			builder.WriteString("async_loading:0\n")
+			builder.WriteString(fmt.Sprintf("loading_start_time:%d\n", h.root().loading.startTime.Load()))
+		} else {
+			builder.WriteString("loading:0\n")
+			builder.WriteString("async_loading:0\n")
+		}
+		builder.WriteString(fmt.Sprintf("rdb_changes_since_last_save:%d\n", stats.ChangesSinceSave))
+		if h.Backup != nil {
+			h.writeSaveInfo(&builder)
+		}
+		h.writeAOFInfo(&builder)
+		if h.Db != nil {
+			h.writeValueLogGCInfo(&builder)
+		}
+		if h.Db != nil {
+			enc := h.Db.EncryptionStatus()
+			builder.WriteString(fmt.Sprintf("encryption_enabled:%d\n", boolToInt(enc.Enabled)))
+			if enc.Enabled {
+				builder.WriteString(fmt.Sprintf("encryption_key_source:%s\n", enc.SourceKind))
+				builder.WriteString(fmt.Sprintf("encryption_data_key_rotation_seconds:%d\n", int64(enc.DataKeyRotation/time.Second)))
+			}
+			h.writeTierStats(&builder)
+		}
+		builder.WriteString("\n")
+	}
+
+	if infoSectionSelected(section, "STATS") {
+		builder.WriteString("# Stats\n")
+		builder.WriteString(fmt.Sprintf("total_connections_received:%d\n", stats.TotalConnections))
+		builder.WriteString(fmt.Sprintf("rejected_connections:%d\n", stats.RejectedConns))
+		builder.WriteString(fmt.Sprintf("total_commands_processed:%d\n", stats.TotalCommands))
+		builder.WriteString(fmt.Sprintf("instantaneous_ops_per_sec:%d\n", stats.OpsPerSec))
+		builder.WriteString(fmt.Sprintf("keyspace_hits:%d\n", stats.KeyspaceHits))
+		builder.WriteString(fmt.Sprintf("keyspace_misses:%d\n", stats.KeyspaceMisses))
+		if h.PubSub != nil {
+			builder.WriteString(fmt.Sprintf("pubsub_channels:%d\n", len(h.PubSub.GetChannels(""))))
+			builder.WriteString(fmt.Sprintf("pubsub_patterns:%d\n", h.PubSub.GetPatternCount()))
+		}
+		builder.WriteString(fmt.Sprintf("stat_reset_time:%d\n", stats.StatResetUnixTime))
+		builder.WriteString(fmt.Sprintf("rate_limited_commands:%d\n", h.root().rateLimit.rejected.Load()))
+		if h.Db != nil {
+			builder.WriteString(fmt.Sprintf("rejected_blocked_clients:%d\n", h.Db.RejectedBlockedClients()))
+			h.writeExpireStats(&builder)
+			h.writeEvictStats(&builder)
+			h.writeCoalesceStats(&builder)
+			rc := h.Db.ReadCacheStats()
+			var readHitRatio float64
+			if total := rc.Hits + rc.Misses; total > 0 {
+				readHitRatio = float64(rc.Hits) / float64(total)
+			}
+			builder.WriteString(fmt.Sprintf("read_cache_hits:%d\n", rc.Hits))
+			builder.WriteString(fmt.Sprintf("read_cache_misses:%d\n", rc.Misses))
+			builder.WriteString(fmt.Sprintf("read_cache_hit_ratio:%.4f\n", readHitRatio))
+			builder.WriteString(fmt.Sprintf("read_cache_entries:%d\n", rc.Entries))
+			builder.WriteString(fmt.Sprintf("read_cache_bytes:%d\n", rc.Bytes))
+			builder.WriteString(fmt.Sprintf("read_cache_capacity:%d\n", rc.Capacity))
+			dc := h.Db.DecompressCacheStats()
+			var hitRatio float64
+			if total := dc.Hits + dc.Misses; total > 0 {
+				hitRatio = float64(dc.Hits) / float64(total)
+			}
+			builder.WriteString(fmt.Sprintf("decompress_cache_hits:%d\n", dc.Hits))
+			builder.WriteString(fmt.Sprintf("decompress_cache_misses:%d\n", dc.Misses))
+			builder.WriteString(fmt.Sprintf("decompress_cache_hit_ratio:%.4f\n", hitRatio))
+			builder.WriteString(fmt.Sprintf("decompress_cache_entries:%d\n", dc.Entries))
+			builder.WriteString(fmt.Sprintf("decompress_cache_bytes:%d\n", dc.Bytes))
+			builder.WriteString(fmt.Sprintf("decompress_cache_capacity:%d\n", dc.Capacity))
+		}
+		builder.WriteString("\n")
+	}
+
+	if infoSectionSelected(section, "REPLICATION") {
 		builder.WriteString("# Replication\n")
 		if h.Replication != nil {
 			role := h.Replication.GetRole()
@@ -50,8 +194,10 @@ func (h *Handler) buildInfoResponse(section string) string {
 
 				slaves := h.Replication.GetSlaves()
 				for i, slave := range slaves {
-					builder.WriteString(fmt.Sprintf("slave%d:ip=%s,port=6379,state=online,offset=%d,lag=0\n",
-						i, slave.Addr, slave.GetReplOffset()))
+					// ip/port 为从节点的服务地址，offset 为从节点确认的偏移量，lag 为距最近一次确认的秒数
+					host, port := slave.ServiceAddr()
+					builder.WriteString(fmt.Sprintf("slave%d:ip=%s,port=%d,state=online,offset=%d,lag=%d\n",
+						i, host, port, slave.GetReplAckOffset(), time.Now().Unix()-slave.GetLastAckTime()))
 				}
 			} else if role == "slave" {
 				masterAddr := h.Replication.GetMasterAddr()
@@ -61,10 +207,19 @@ func (h *Handler) buildInfoResponse(section string) string {
 						builder.WriteString(fmt.Sprintf("master_port:%s\n", parts[1]))
 					}
 				}
-				builder.WriteString(fmt.Sprintf("master_link_status:%s\n", "up"))
+				linkStatus := "down"
+				if h.Replication.MasterLinkUp() {
+					linkStatus = "up"
+				}
+				builder.WriteString(fmt.Sprintf("master_link_status:%s\n", linkStatus))
 				builder.WriteString(fmt.Sprintf("master_link_down_since_seconds:0\n"))
+				builder.WriteString(fmt.Sprintf("slave_repl_offset:%d\n", h.Replication.GetMasterReplOffset()))
 				builder.WriteString(fmt.Sprintf("slave_priority:100\n"))
-				builder.WriteString(fmt.Sprintf("slave_read_only:1\n"))
+				readOnly := 1
+				if h.root().conf.replicaWritable.Load() {
+					readOnly = 0
+				}
+				builder.WriteString(fmt.Sprintf("slave_read_only:%d\n", readOnly))
 				builder.WriteString(fmt.Sprintf("replica_announced:1\n"))
 				builder.WriteString(fmt.Sprintf("connected_slaves:0\n"))
 				builder.WriteString(fmt.Sprintf("master_replid:%s\n", h.Replication.GetReplicationID()))
@@ -86,24 +241,26 @@ func (h *Handler) buildInfoResponse(section string) string {
 		builder.WriteString("\n")
 	}
 
-	if section == "" || section == "ALL" || section == "PERSISTENCE" {
-		builder.WriteString("# Persistence\n")
-		if h.Backup != nil {
-			lastSave := h.Backup.LastSave()
-			builder.WriteString(fmt.Sprintf("rdb_last_save_time:%d\n", lastSave))
-			builder.WriteString("rdb_changes_since_last_save:0\n")
+	if infoSectionSelected(section, "COMMANDSTATS") {
+		builder.WriteString("# Commandstats\n")
+		for _, c := range h.root().stats.CommandStats() {
+			var perCall float64
+			if c.Calls > 0 {
+				perCall = float64(c.Usec) / float64(c.Calls)
+			}
+			builder.WriteString(fmt.Sprintf("cmdstat_%s:calls=%d,usec=%d,usec_per_call=%.2f,rejected_calls=%d,failed_calls=%d\n",
+				c.Name, c.Calls, c.Usec, perCall, c.RejectedCalls, c.FailedCalls))
 		}
 		builder.WriteString("\n")
 	}
 
-	if section == "" || section == "ALL" || section == "STATS" {
-		builder.WriteString("# Stats\n")
-		builder.WriteString("total_commands_processed:0\n")
-		builder.WriteString("instantaneous_ops_per_sec:0\n")
+	if infoSectionSelected(section, "LATENCYSTATS") {
+		builder.WriteString("# Latencystats\n")
+		h.writeLatencyStats(&builder)
 		builder.WriteString("\n")
 	}
 
-	if section == "" || section == "ALL" || section == "CLUSTER" {
+	if infoSectionSelected(section, "CLUSTER") {
 		builder.WriteString("# Cluster\n")
 		if h.Cluster != nil {
 			builder.WriteString("cluster_enabled:1\n")
@@ -113,5 +270,127 @@ func (h *Handler) buildInfoResponse(section string) string {
 		builder.WriteString("\n")
 	}
 
+	if infoSectionSelected(section, "KEYSPACE") {
+		builder.WriteString("# Keyspace\n")
+		h.writeKeyspaceInfo(&builder)
+		builder.WriteString("\n")
+	}
+
 	return builder.String()
 }
+
+// tcpPort 第一个监听器的端口，没有监听器时为默认端口
+func (h *Handler) tcpPort() string {
+	for _, l := range h.root().listeners.snapshot() {
+		if _, port, err := net.SplitHostPort(l.addr); err == nil {
+			return port
+		}
+	}
+	return "6379"
+}
+
+// writeMemoryInfo 写入 INFO memory：Go 运行时的内存占用，以及 Badger LSM 树与值日志的磁盘占用
+func (h *Handler) writeMemoryInfo(b *strings.Builder) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	b.WriteString(fmt.Sprintf("used_memory:%d\n", ms.HeapAlloc))
+	b.WriteString(fmt.Sprintf("used_memory_human:%s\n", bytesToHuman(int64(ms.HeapAlloc)))) // #nosec G115 - 内存占用不会超过 int64
+	b.WriteString(fmt.Sprintf("used_memory_rss:%d\n", ms.Sys))
+	b.WriteString(fmt.Sprintf("used_memory_rss_human:%s\n", bytesToHuman(int64(ms.Sys)))) // #nosec G115 - 内存占用不会超过 int64
+	b.WriteString(fmt.Sprintf("used_memory_peak:%d\n", ms.HeapSys))
+	maxmemory := h.root().conf.maxmemory.Load()
+	b.WriteString(fmt.Sprintf("maxmemory:%d\n", maxmemory))
+	b.WriteString(fmt.Sprintf("maxmemory_human:%s\n", bytesToHuman(maxmemory)))
+	b.WriteString("maxmemory_policy:" + h.maxmemoryPolicy() + "\n")
+	b.WriteString("mem_allocator:" + runtime.Version() + "\n")
+	if h.Db != nil {
+		lsm, vlog := h.Db.DiskSize()
+		b.WriteString(fmt.Sprintf("badger_lsm_size:%d\n", lsm))
+		b.WriteString(fmt.Sprintf("badger_vlog_size:%d\n", vlog))
+		b.WriteString(fmt.Sprintf("used_disk:%d\n", lsm+vlog))
+		b.WriteString(fmt.Sprintf("used_disk_human:%s\n", bytesToHuman(lsm+vlog)))
+		b.WriteString(fmt.Sprintf("used_memory_dataset:%d\n", h.Db.DataSize()))
+		b.WriteString(fmt.Sprintf("lazyfree_pending_objects:%d\n", h.Db.UnlinkPending()))
+		b.WriteString(fmt.Sprintf("lazyfree_pending_databases:%d\n", h.Db.DBReclaimPending()))
+	}
+}
+
+// writeSaveInfo 写入 INFO persistence 中的保存状态
+func (h *Handler) writeSaveInfo(b *strings.Builder) {
+	status := h.Backup.Status()
+	b.WriteString(fmt.Sprintf("rdb_bgsave_in_progress:%d\n", boolToInt(status.InProgress)))
+	b.WriteString(fmt.Sprintf("rdb_last_save_time:%d\n", h.Backup.LastSave()))
+	if status.LastOK {
+		b.WriteString("rdb_last_bgsave_status:ok\n")
+	} else {
+		b.WriteString("rdb_last_bgsave_status:err\n")
+	}
+	lastTime := int64(-1)
+	if status.Saves > 0 || !status.LastOK {
+		lastTime = int64(status.LastDuration / time.Second)
+	}
+	b.WriteString(fmt.Sprintf("rdb_last_bgsave_time_sec:%d\n", lastTime))
+	b.WriteString(fmt.Sprintf("rdb_saves:%d\n", status.Saves))
+
+	// Badger 全量与增量备份（BOLTREON.BACKUP 与 backup-schedule）
+	backupStatus := h.Backup.BackupStatus()
+	lastBackup := int64(-1)
+	if !backupStatus.LastTime.IsZero() {
+		lastBackup = backupStatus.LastTime.Unix()
+	}
+	b.WriteString(fmt.Sprintf("backup_last_time:%d\n", lastBackup))
+	if backupStatus.LastOK {
+		b.WriteString("backup_last_status:ok\n")
+	} else {
+		b.WriteString("backup_last_status:err\n")
+	}
+}
+
+// writeKeyspaceInfo 写入 INFO keyspace，每个非空数据库一行。键数为当前值；带过期时间的键数与平均剩余时间（毫秒）
+// 来自主动过期最近一次完整扫描，完成第一轮扫描前为 0，主动过期不区分数据库，只有一个数据库有键时才输出。
+// 没有键的数据库与 Redis 相同不输出
+func (h *Handler) writeKeyspaceInfo(b *strings.Builder) {
+	if h.Db == nil {
+		return
+	}
+	counts, err := h.Db.DBKeyCounts()
+	if err != nil {
+		return
+	}
+	dbs := make([]int, 0, len(counts))
+	for db := range counts {
+		dbs = append(dbs, db)
+	}
+	sort.Ints(dbs)
+	expire := h.Db.ExpireStats()
+	for _, db := range dbs {
+		expires, avgTTL := int64(0), int64(0)
+		if len(dbs) == 1 {
+			expires, avgTTL = expire.Keys, expire.AvgTTL.Milliseconds()
+		}
+		b.WriteString(fmt.Sprintf("db%d:keys=%d,expires=%d,avg_ttl=%d\n", db, counts[db], expires, avgTTL))
+	}
+}
+
+// saveError SAVE/BGSAVE 失败时的回复
+func saveError(err error) proto.RESP {
+	if errors.Is(err, backup.ErrSaveInProgress) {
+		return proto.NewError("ERR Background save already in progress")
+	}
+	return proto.NewError(fmt.Sprintf("ERR %v", err))
+}
+
+// bytesToHuman 与 Redis 相同的容量格式，如 100B、1.50K、2.00G
+func bytesToHuman(n int64) string {
+	units := []string{"B", "K", "M", "G", "T", "P"}
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	v := float64(n)
+	i := 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.2f%s", v, units[i])
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// jsonError JSON 命令的错误回复：存储层以 "ERR " 开头的错误（路径语法、路径不存在等）原样返回
+func jsonError(err error) proto.RESP {
+	if strings.HasPrefix(err.Error(), "ERR ") {
+		return proto.NewError(err.Error())
+	}
+	return proto.NewError(fmt.Sprintf("ERR %v", err))
+}
+
+// jsonIntsReply 修改类 JSON 命令的整数回复：旧式路径只对应一个值，回复该整数；
+// JSONPath 为每个匹配的值回复一项，类型不符的匹配为 nil
+func jsonIntsReply(path string, results []*int64) proto.RESP {
+	if store.IsLegacyJSONPath(path) {
+		return proto.NewInteger(*results[0])
+	}
+	elems := make([]proto.RESP, len(results))
+	for i, r := range results {
+		if r == nil {
+			elems[i] = proto.NewBulkString(nil)
+			continue
+		}
+		elems[i] = proto.NewInteger(*r)
+	}
+	return &proto.NestedArray{Elems: elems}
+}
+
+// jsonNumbersReply JSON.NUMINCRBY/NUMMULTBY 的回复：旧式路径回复新值，
+// JSONPath 与 RedisJSON 相同回复各匹配新值组成的 JSON 数组，非数字的匹配为 null
+func jsonNumbersReply(path string, results []*float64) proto.RESP {
+	if store.IsLegacyJSONPath(path) {
+		return proto.NewBulkString([]byte(strconv.FormatFloat(*results[0], 'f', -1, 64)))
+	}
+	buf := []byte{'['}
+	for i, r := range results {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		if r == nil {
+			buf = append(buf, "null"...)
+			continue
+		}
+		buf = strconv.AppendFloat(buf, *r, 'f', -1, 64)
+	}
+	return proto.NewBulkString(append(buf, ']'))
+}
+
+// jsonBulkReply 一个可能为 nil 的 JSON 值
+func jsonBulkReply(value *string) proto.RESP {
+	if value == nil {
+		return proto.NewBulkString(nil)
+	}
+	return proto.NewBulkString([]byte(*value))
+}
@@ -0,0 +1,320 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// DefaultLatencyBuckets 命令延迟直方图的默认桶上界
+var DefaultLatencyBuckets = []time.Duration{
+	50 * time.Microsecond, 100 * time.Microsecond, 250 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2500 * time.Microsecond, 5 * time.Millisecond, 10 * time.Millisecond,
+	25 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond,
+	500 * time.Millisecond, time.Second, 2500 * time.Millisecond,
+}
+
+const (
+	// latencyWindow INFO latencystats 的百分位按最近这段时间内的样本计算
+	latencyWindow = time.Minute
+	// latencyWindowSamples 每个命令保留的最近样本数
+	latencyWindowSamples = 1024
+
+	// openMetricsContentType /metrics 的响应类型
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// commandFamilyPrefixes 按命令名前缀归类的命令族
+var commandFamilyPrefixes = []struct{ prefix, family string }{
+	{"TS.", "timeseries"}, {"JSON.", "json"}, {"FT.", "search"}, {"GEO", "geo"}, {"PF", "hyperloglog"},
+	{"BZ", "zset"}, {"Z", "zset"}, {"X", "stream"},
+}
+
+// commandFamilies 不在 commandKeyTypes 中、也无法按前缀归类的命令；其余命令归为 server
+var commandFamilies = map[string]string{
+	"SET": "string", "SETNX": "string", "SETEX": "string", "PSETEX": "string",
+	"MGET": "string", "MSET": "string", "MSETNX": "string", "BITOP": "string",
+	"BLPOP": "list", "BRPOP": "list", "BRPOPLPUSH": "list", "BLMOVE": "list", "RPOPLPUSH": "list", "LMOVE": "list",
+	"SMOVE": "set", "SINTER": "set", "SUNION": "set", "SDIFF": "set",
+	"SINTERSTORE": "set", "SUNIONSTORE": "set", "SDIFFSTORE": "set",
+	"DEL": "keyspace", "UNLINK": "keyspace", "EXISTS": "keyspace", "TYPE": "keyspace", "KEYS": "keyspace",
+	"SCAN": "keyspace", "RANDOMKEY": "keyspace", "RENAME": "keyspace", "RENAMENX": "keyspace",
+	"EXPIRE": "keyspace", "PEXPIRE": "keyspace", "EXPIREAT": "keyspace", "PEXPIREAT": "keyspace",
+	"PERSIST": "keyspace", "TTL": "keyspace", "PTTL": "keyspace", "DUMP": "keyspace", "RESTORE": "keyspace",
+	"UNDELETE": "keyspace", "PURGE": "keyspace", "MOVE": "keyspace", "SWAPDB": "keyspace",
+	"DBSIZE": "keyspace", "FLUSHDB": "keyspace", "FLUSHALL": "keyspace",
+	"MULTI": "transaction", "EXEC": "transaction", "DISCARD": "transaction", "WATCH": "transaction", "UNWATCH": "transaction",
+	"PUBLISH": "pubsub", "SUBSCRIBE": "pubsub", "UNSUBSCRIBE": "pubsub", "PSUBSCRIBE": "pubsub",
+	"PUNSUBSCRIBE": "pubsub", "PUBSUB": "pubsub", "SPUBLISH": "pubsub", "SSUBSCRIBE": "pubsub",
+	"SUNSUBSCRIBE": "pubsub",
+}
+
+// commandFamily 命令所属的命令族，用作延迟直方图的 family 标签（取值有限，避免标签基数失控）
+func commandFamily(cmd string) string {
+	if family, ok := commandKeyTypes[cmd]; ok {
+		return family
+	}
+	if family, ok := commandFamilies[cmd]; ok {
+		return family
+	}
+	for _, p := range commandFamilyPrefixes {
+		if strings.HasPrefix(cmd, p.prefix) {
+			return p.family
+		}
+	}
+	return "server"
+}
+
+// latencyKey 直方图按命令族和结果（ok、error）区分
+type latencyKey struct {
+	family string
+	result string
+}
+
+// latencyHistogram 一组命令族、结果的直方图：counts[i] 为落在 (buckets[i-1], buckets[i]] 中的次数，
+// 最后一个为超过所有上界的次数（+Inf）
+type latencyHistogram struct {
+	buckets []time.Duration
+	counts  []atomic.Uint64
+	count   atomic.Uint64
+	sum     atomic.Int64 // 纳秒
+}
+
+// latencySample 用于计算滚动百分位的样本
+type latencySample struct {
+	at time.Time
+	d  time.Duration
+}
+
+// rollingLatency 单个命令最近的 latencyWindowSamples 个样本（环形缓冲）
+type rollingLatency struct {
+	mu      sync.Mutex
+	samples []latencySample
+	next    int
+}
+
+func (r *rollingLatency) add(s latencySample) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.samples) < latencyWindowSamples {
+		r.samples = append(r.samples, s)
+		return
+	}
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % latencyWindowSamples
+}
+
+// recent 最近 latencyWindow 内的延迟，从小到大排列
+func (r *rollingLatency) recent(now time.Time) []time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	durations := make([]time.Duration, 0, len(r.samples))
+	for _, s := range r.samples {
+		if now.Sub(s.at) <= latencyWindow {
+			durations = append(durations, s.d)
+		}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return durations
+}
+
+// latencyTracker 服务器级的命令延迟统计
+type latencyTracker struct {
+	mu         sync.RWMutex
+	buckets    []time.Duration
+	histograms map[latencyKey]*latencyHistogram
+	commands   map[string]*rollingLatency
+}
+
+// SetLatencyBuckets 设置延迟直方图的桶上界（从小到大），已有的统计清空
+func (h *Handler) SetLatencyBuckets(buckets []time.Duration) {
+	t := &h.root().latency
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buckets = append([]time.Duration(nil), buckets...)
+	sort.Slice(t.buckets, func(i, j int) bool { return t.buckets[i] < t.buckets[j] })
+	t.histograms = nil
+}
+
+// ParseLatencyBuckets 解析逗号分隔的桶上界，如 "100us,1ms,10ms"
+func ParseLatencyBuckets(s string) ([]time.Duration, error) {
+	var buckets []time.Duration
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		d, err := time.ParseDuration(part)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid latency bucket %q", part)
+		}
+		buckets = append(buckets, d)
+	}
+	if len(buckets) == 0 {
+		return nil, fmt.Errorf("no latency buckets")
+	}
+	return buckets, nil
+}
+
+// recordLatency 记录一次命令执行的延迟。未知命令不计入，避免任意命令名占用内存
+func (h *Handler) recordLatency(cmd string, resp proto.RESP, d time.Duration) {
+	result := "ok"
+	if e, isErr := resp.(*proto.Error); isErr || resp == nil {
+		if e != nil && strings.HasPrefix(e.String(), "-ERR unknown command") {
+			return
+		}
+		result = "error"
+	}
+	t := &h.root().latency
+	hist := t.histogram(latencyKey{family: commandFamily(cmd), result: result})
+	i := sort.Search(len(hist.buckets), func(i int) bool { return d <= hist.buckets[i] })
+	hist.counts[i].Add(1)
+	hist.count.Add(1)
+	hist.sum.Add(int64(d))
+	t.rolling(cmd).add(latencySample{at: time.Now(), d: d})
+}
+
+func (t *latencyTracker) histogram(key latencyKey) *latencyHistogram {
+	t.mu.RLock()
+	hist, ok := t.histograms[key]
+	t.mu.RUnlock()
+	if ok {
+		return hist
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.buckets == nil {
+		t.buckets = DefaultLatencyBuckets
+	}
+	if t.histograms == nil {
+		t.histograms = make(map[latencyKey]*latencyHistogram)
+	}
+	if hist, ok = t.histograms[key]; !ok {
+		hist = &latencyHistogram{buckets: t.buckets, counts: make([]atomic.Uint64, len(t.buckets)+1)}
+		t.histograms[key] = hist
+	}
+	return hist
+}
+
+func (t *latencyTracker) rolling(cmd string) *rollingLatency {
+	t.mu.RLock()
+	r, ok := t.commands[cmd]
+	t.mu.RUnlock()
+	if ok {
+		return r
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.commands == nil {
+		t.commands = make(map[string]*rollingLatency)
+	}
+	if r, ok = t.commands[cmd]; !ok {
+		r = &rollingLatency{}
+		t.commands[cmd] = r
+	}
+	return r
+}
+
+// percentile 有序样本的第 p 百分位（最近秩法）
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// writeLatencyStats 输出 INFO latencystats：最近一分钟内各命令的 p50、p99、p99.9（微秒），格式与 Redis 7 相同
+func (h *Handler) writeLatencyStats(b *strings.Builder) {
+	t := &h.root().latency
+	t.mu.RLock()
+	cmds := make([]string, 0, len(t.commands))
+	for cmd := range t.commands {
+		cmds = append(cmds, cmd)
+	}
+	t.mu.RUnlock()
+	sort.Strings(cmds)
+
+	now := time.Now()
+	usec := func(d time.Duration) string {
+		return strconv.FormatFloat(float64(d)/float64(time.Microsecond), 'f', 3, 64)
+	}
+	for _, cmd := range cmds {
+		samples := t.rolling(cmd).recent(now)
+		if len(samples) == 0 {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("latency_percentiles_usec_%s:p50=%s,p99=%s,p99.9=%s\n", strings.ToLower(cmd),
+			usec(percentile(samples, 50)), usec(percentile(samples, 99)), usec(percentile(samples, 99.9))))
+	}
+}
+
+// WriteOpenMetrics 以 OpenMetrics 文本格式输出按命令族和结果聚合的延迟直方图，
+// 以及命令数、连接、Badger、Pub/Sub 与 Stream 积压等指标（见 writeServerMetrics）
+func (h *Handler) WriteOpenMetrics(w io.Writer) error {
+	t := &h.root().latency
+	t.mu.RLock()
+	keys := make([]latencyKey, 0, len(t.histograms))
+	for key := range t.histograms {
+		keys = append(keys, key)
+	}
+	histograms := make(map[latencyKey]*latencyHistogram, len(keys))
+	for _, key := range keys {
+		histograms[key] = t.histograms[key]
+	}
+	t.mu.RUnlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].family != keys[j].family {
+			return keys[i].family < keys[j].family
+		}
+		return keys[i].result < keys[j].result
+	})
+
+	const name = "boltreon_command_duration_seconds"
+	var b strings.Builder
+	b.WriteString("# TYPE " + name + " histogram\n")
+	b.WriteString("# UNIT " + name + " seconds\n")
+	b.WriteString("# HELP " + name + " Command execution latency by command family and result.\n")
+	for _, key := range keys {
+		hist := histograms[key]
+		labels := fmt.Sprintf(`family="%s",result="%s"`, key.family, key.result)
+		var cumulative uint64
+		for i := range hist.counts {
+			cumulative += hist.counts[i].Load()
+			le := "+Inf"
+			if i < len(hist.buckets) {
+				le = strconv.FormatFloat(hist.buckets[i].Seconds(), 'g', -1, 64)
+			}
+			b.WriteString(fmt.Sprintf("%s_bucket{%s,le=\"%s\"} %d\n", name, labels, le, cumulative))
+		}
+		b.WriteString(fmt.Sprintf("%s_count{%s} %d\n", name, labels, hist.count.Load()))
+		b.WriteString(fmt.Sprintf("%s_sum{%s} %s\n", name, labels,
+			strconv.FormatFloat(time.Duration(hist.sum.Load()).Seconds(), 'g', -1, 64)))
+	}
+	h.writeServerMetrics(&b)
+	b.WriteString("# EOF\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// MetricsHandler 提供 /metrics 的 HTTP 处理器（OpenMetrics 格式）
+func (h *Handler) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", openMetricsContentType)
+		_ = h.WriteOpenMetrics(w)
+	})
+}
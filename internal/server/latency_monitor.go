@@ -0,0 +1,289 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// 延迟监控的事件，与 Redis 的 LATENCY 相同按事件记录超过 latency-monitor-threshold 的延迟尖峰
+const (
+	// latencyEventCommand 单条命令的执行（不含阻塞命令的等待），流水线合并写入时为整批的耗时
+	latencyEventCommand = "command"
+	// latencyEventExpireCycle 一次主动过期（见 runExpireCycle）
+	latencyEventExpireCycle = "expire-cycle"
+	// latencyEventBadgerGC 回收一个 Badger value log 文件（见 value_log_gc.go）
+	latencyEventBadgerGC = "badger-gc"
+	// latencyEventSnapshot 一次 SAVE、BGSAVE、SHUTDOWN SAVE 或 BOLTREON.BACKUP，相当于 Redis 的 fork
+	latencyEventSnapshot = "snapshot"
+)
+
+// latencyHistoryLen 每个事件保留的样本数，与 Redis 相同
+const latencyHistoryLen = 160
+
+// latencyBlockingCommands 执行时间包含等待的命令，不计入 command 事件
+var latencyBlockingCommands = map[string]bool{
+	"BLPOP": true, "BRPOP": true, "BRPOPLPUSH": true, "BLMOVE": true, "BLMPOP": true,
+	"BZPOPMIN": true, "BZPOPMAX": true, "BZMPOP": true, "XREAD": true, "XREADGROUP": true,
+	"WAIT": true, "WAITAOF": true,
+}
+
+// latencyEventSample 一个延迟样本：同一秒内的多个尖峰只保留最大的
+type latencyEventSample struct {
+	time    int64 // Unix 时间（秒）
+	latency int64 // 毫秒
+}
+
+// latencyEventSeries 一个事件最近的样本（环形缓冲）与历史最大值
+type latencyEventSeries struct {
+	samples [latencyHistoryLen]latencyEventSample
+	next    int // 下一个样本写入的位置
+	count   int
+	max     int64
+}
+
+// last 返回最近的样本
+func (s *latencyEventSeries) last() latencyEventSample {
+	return s.samples[(s.next+latencyHistoryLen-1)%latencyHistoryLen]
+}
+
+// history 按时间先后返回保留的样本
+func (s *latencyEventSeries) history() []latencyEventSample {
+	out := make([]latencyEventSample, 0, s.count)
+	for i := s.count; i > 0; i-- {
+		out = append(out, s.samples[(s.next+latencyHistoryLen-i)%latencyHistoryLen])
+	}
+	return out
+}
+
+// latencyMonitor LATENCY 命令的数据（只保存在服务器级）。thresholdMs 为 0 时不记录
+type latencyMonitor struct {
+	thresholdMs atomic.Int64 // CONFIG latency-monitor-threshold
+
+	mu     sync.Mutex
+	events map[string]*latencyEventSeries
+}
+
+// add 记录一个样本，调用方已确认超过阈值
+func (m *latencyMonitor) add(event string, ms int64, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.events == nil {
+		m.events = make(map[string]*latencyEventSeries)
+	}
+	s := m.events[event]
+	if s == nil {
+		s = &latencyEventSeries{}
+		m.events[event] = s
+	}
+	if ms > s.max {
+		s.max = ms
+	}
+	sec := now.Unix()
+	if s.count > 0 {
+		if last := &s.samples[(s.next+latencyHistoryLen-1)%latencyHistoryLen]; last.time == sec {
+			if ms > last.latency {
+				last.latency = ms
+			}
+			return
+		}
+	}
+	s.samples[s.next] = latencyEventSample{time: sec, latency: ms}
+	s.next = (s.next + 1) % latencyHistoryLen
+	if s.count < latencyHistoryLen {
+		s.count++
+	}
+}
+
+// latencyEventSummary LATENCY LATEST 与 DOCTOR 使用的事件概况
+type latencyEventSummary struct {
+	event   string
+	last    latencyEventSample
+	max     int64
+	history []latencyEventSample
+}
+
+// summaries 按事件名返回每个事件的概况
+func (m *latencyMonitor) summaries() []latencyEventSummary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]latencyEventSummary, 0, len(m.events))
+	for event, s := range m.events {
+		out = append(out, latencyEventSummary{event: event, last: s.last(), max: s.max, history: s.history()})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].event < out[j].event })
+	return out
+}
+
+// history 返回事件的样本，没有这个事件时为空
+func (m *latencyMonitor) history(event string) []latencyEventSample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s := m.events[event]; s != nil {
+		return s.history()
+	}
+	return nil
+}
+
+// reset 清除指定的事件，没有指定时清除全部，返回清除的事件数
+func (m *latencyMonitor) reset(events []string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(events) == 0 {
+		n := len(m.events)
+		m.events = nil
+		return n
+	}
+	n := 0
+	for _, event := range events {
+		if _, ok := m.events[event]; ok {
+			delete(m.events, event)
+			n++
+		}
+	}
+	return n
+}
+
+// addLatencySample latency-monitor-threshold 大于 0 且 d 达到阈值时记录 event 的一个样本
+func (h *Handler) addLatencySample(event string, d time.Duration) {
+	m := &h.root().latencyMonitor
+	threshold := m.thresholdMs.Load()
+	if threshold <= 0 {
+		return
+	}
+	if ms := d.Milliseconds(); ms >= threshold {
+		m.add(event, ms, time.Now())
+	}
+}
+
+// monitorCommandLatency 把一条命令的执行时间计入 command 事件，阻塞命令除外
+func (h *Handler) monitorCommandLatency(cmd string, d time.Duration) {
+	if !latencyBlockingCommands[cmd] {
+		h.addLatencySample(latencyEventCommand, d)
+	}
+}
+
+// handleLatency LATENCY LATEST | HISTORY event | RESET [event ...] | DOCTOR | HELP
+func (h *Handler) handleLatency(args [][]byte) proto.RESP {
+	m := &h.root().latencyMonitor
+	switch strings.ToUpper(string(args[0])) {
+	case "LATEST":
+		if len(args) != 1 {
+			return proto.NewError("ERR wrong number of arguments for 'latency|latest' command")
+		}
+		summaries := m.summaries()
+		elems := make([]proto.RESP, len(summaries))
+		for i, s := range summaries {
+			elems[i] = &proto.NestedArray{Elems: []proto.RESP{
+				proto.NewBulkString([]byte(s.event)),
+				proto.NewInteger(s.last.time),
+				proto.NewInteger(s.last.latency),
+				proto.NewInteger(s.max),
+			}}
+		}
+		return &proto.NestedArray{Elems: elems}
+	case "HISTORY":
+		if len(args) != 2 {
+			return proto.NewError("ERR wrong number of arguments for 'latency|history' command")
+		}
+		samples := m.history(string(args[1]))
+		elems := make([]proto.RESP, len(samples))
+		for i, s := range samples {
+			elems[i] = &proto.NestedArray{Elems: []proto.RESP{proto.NewInteger(s.time), proto.NewInteger(s.latency)}}
+		}
+		return &proto.NestedArray{Elems: elems}
+	case "RESET":
+		events := make([]string, len(args)-1)
+		for i, a := range args[1:] {
+			events[i] = string(a)
+		}
+		return proto.NewInteger(int64(m.reset(events)))
+	case "DOCTOR":
+		if len(args) != 1 {
+			return proto.NewError("ERR wrong number of arguments for 'latency|doctor' command")
+		}
+		return proto.NewBulkString([]byte(latencyDoctorReport(m.thresholdMs.Load(), m.summaries())))
+	case "HELP":
+		return &proto.Array{Args: [][]byte{
+			[]byte("LATENCY LATEST - returns the latest latency spike of every event"),
+			[]byte("LATENCY HISTORY <event> - returns the latency spikes of <event> (up to 160, in milliseconds)"),
+			[]byte("LATENCY RESET [<event> ...] - resets the given events, or all events"),
+			[]byte("LATENCY DOCTOR - returns a human readable latency analysis"),
+			[]byte("LATENCY HELP - shows this help message"),
+		}}
+	default:
+		return proto.NewError(fmt.Sprintf("ERR unknown subcommand '%s'", string(args[0])))
+	}
+}
+
+// latencyDoctorAdvice 各事件出现尖峰时的建议
+var latencyDoctorAdvice = map[string]string{
+	latencyEventCommand: "Slow commands: check SLOWLOG GET and INFO latencystats for O(N) commands " +
+		"such as KEYS, SMEMBERS, HGETALL or large ZRANGE calls.",
+	latencyEventExpireCycle: "Active expire cycles are slow: many keys expire at the same time. Add jitter to TTLs " +
+		"or lower active-expire-keys.",
+	latencyEventBadgerGC: "Badger value log GC is slow: the disk may be saturated or values are large and often overwritten.",
+	latencyEventSnapshot: "Snapshots (SAVE/BGSAVE/BOLTREON.BACKUP) are slow: prefer incremental backups and make sure " +
+		"the backup directory is on a fast disk.",
+}
+
+// latencyDoctorReport LATENCY DOCTOR 的报告
+func latencyDoctorReport(thresholdMs int64, summaries []latencyEventSummary) string {
+	var b strings.Builder
+	if thresholdMs <= 0 {
+		b.WriteString("Latency monitoring is disabled. Enable it with CONFIG SET latency-monitor-threshold <milliseconds>.\n")
+		if len(summaries) == 0 {
+			return b.String()
+		}
+	}
+	if len(summaries) == 0 {
+		fmt.Fprintf(&b, "No latency spikes above %d milliseconds were observed.\n", thresholdMs)
+		return b.String()
+	}
+	b.WriteString("Latency spikes observed for the following events:\n\n")
+	for i, s := range summaries {
+		var sum int64
+		for _, sample := range s.history {
+			sum += sample.latency
+		}
+		fmt.Fprintf(&b, "%d. %s: %d latency spikes (average %dms, mean deviation %dms, period %s). "+
+			"Worst all time event %dms.\n",
+			i+1, s.event, len(s.history), sum/int64(len(s.history)), latencyMeanDeviation(s.history, sum),
+			latencyPeriod(s.history), s.max)
+	}
+	b.WriteString("\nAdvice:\n\n")
+	for _, s := range summaries {
+		if advice, ok := latencyDoctorAdvice[s.event]; ok {
+			fmt.Fprintf(&b, "- %s\n", advice)
+		}
+	}
+	return b.String()
+}
+
+// latencyMeanDeviation 样本与平均值之差的绝对值的平均
+func latencyMeanDeviation(samples []latencyEventSample, sum int64) int64 {
+	avg := sum / int64(len(samples))
+	var dev int64
+	for _, s := range samples {
+		d := s.latency - avg
+		if d < 0 {
+			d = -d
+		}
+		dev += d
+	}
+	return dev / int64(len(samples))
+}
+
+// latencyPeriod 相邻两个尖峰的平均间隔
+func latencyPeriod(samples []latencyEventSample) string {
+	if len(samples) < 2 {
+		return "n/a"
+	}
+	span := samples[len(samples)-1].time - samples[0].time
+	return (time.Duration(span/int64(len(samples)-1)) * time.Second).String()
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// DefaultMaxCollectionReply LRANGE、HGETALL 等一次返回整个集合的命令默认最多返回的元素数
+const DefaultMaxCollectionReply = 1000000
+
+// collectionReplyCommands 受 max-collection-reply 限制的命令：FORCE 之前的参数个数与超出时的分页建议
+var collectionReplyCommands = map[string]struct {
+	args int
+	hint string
+}{
+	"LRANGE":   {3, "request a smaller range"},
+	"HGETALL":  {1, "use HSCAN"},
+	"HKEYS":    {1, "use HSCAN"},
+	"HVALS":    {1, "use HSCAN"},
+	"SMEMBERS": {1, "use SSCAN"},
+}
+
+// SetMaxCollectionReply 设置 LRANGE、HGETALL 等命令最多返回的元素数，0 使用默认值，负数不限制
+func (h *Handler) SetMaxCollectionReply(n int64) {
+	h.root().maxCollectionReply = n
+}
+
+func (h *Handler) collectionReplyLimit() int64 {
+	if n := h.root().maxCollectionReply; n != 0 {
+		return n
+	}
+	return DefaultMaxCollectionReply
+}
+
+// checkCollectionReply 集合大小超过 max-collection-reply 时返回错误，要求分页或显式加上 FORCE；
+// 返回去掉 FORCE 后的参数
+func (h *Handler) checkCollectionReply(cmd string, args [][]byte) ([][]byte, proto.RESP) {
+	spec, ok := collectionReplyCommands[cmd]
+	if !ok || len(args) < spec.args {
+		return args, nil
+	}
+	if len(args) > spec.args {
+		if len(args) != spec.args+1 || !strings.EqualFold(string(args[spec.args]), "FORCE") {
+			return args, proto.NewError("ERR syntax error")
+		}
+		return args[:spec.args], nil
+	}
+	limit := h.collectionReplyLimit()
+	if limit < 0 || h.Db == nil {
+		return args, nil
+	}
+	size, err := h.collectionReplySize(cmd, args)
+	if err != nil || size <= limit {
+		return args, nil
+	}
+	return args, proto.NewError(fmt.Sprintf(
+		"ERR reply would contain %d elements, more than max-collection-reply %d; %s or append FORCE", size, limit, spec.hint))
+}
+
+// collectionReplySize 按集合的元数据计算回复的元素数（哈希按字段数计），不读取集合内容
+func (h *Handler) collectionReplySize(cmd string, args [][]byte) (int64, error) {
+	key := string(args[0])
+	switch cmd {
+	case "LRANGE":
+		n, err := h.Db.LLen(key)
+		if err != nil {
+			return 0, err
+		}
+		start, _ := strconv.ParseInt(string(args[1]), 10, 64)
+		stop, _ := strconv.ParseInt(string(args[2]), 10, 64)
+		// #nosec G115 - 列表长度在 int64 范围内
+		length := int64(n)
+		if start < 0 {
+			start = max(start+length, 0)
+		}
+		if stop < 0 {
+			stop += length
+		}
+		stop = min(stop, length-1)
+		if start > stop {
+			return 0, nil
+		}
+		return stop - start + 1, nil
+	case "SMEMBERS":
+		n, err := h.Db.SCard(key)
+		// #nosec G115 - 集合大小在 int64 范围内
+		return int64(n), err
+	default:
+		n, err := h.Db.HLen(key)
+		// #nosec G115 - 哈希大小在 int64 范围内
+		return int64(n), err
+	}
+}
+
+// checkValueSizes 写命令的参数超过 max-value-size 时返回错误，命令不执行
+func (h *Handler) checkValueSizes(cmd string, args [][]byte) proto.RESP {
+	if h.Db == nil || !isWriteCommand(cmd) {
+		return nil
+	}
+	for _, arg := range args {
+		if err := h.Db.CheckValueSize(int64(len(arg))); err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// listenerStats 单个监听器的连接统计，INFO clients 中显示为 listener<N> 行
+type listenerStats struct {
+	addr      string
+	connected atomic.Int64 // 当前连接数
+	total     atomic.Int64 // 累计接受的连接数
+}
+
+// listenerRegistry 服务器上所有监听器的统计
+type listenerRegistry struct {
+	mu    sync.Mutex
+	stats []*listenerStats
+}
+
+func (r *listenerRegistry) add(addr string) *listenerStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := &listenerStats{addr: addr}
+	r.stats = append(r.stats, stats)
+	return stats
+}
+
+func (r *listenerRegistry) snapshot() []*listenerStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*listenerStats(nil), r.stats...)
+}
+
+// ListenReusePort 在同一地址上打开 n 个设置了 SO_REUSEPORT 的监听器，
+// 由内核把新连接分配到各个监听器，使 accept 和读取分散到多个核心
+func ListenReusePort(addr string, n int) ([]net.Listener, error) {
+	if n < 1 {
+		return nil, errors.New("listener count must be positive")
+	}
+	lc := net.ListenConfig{Control: reusePortControl}
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		ln, err := lc.Listen(context.Background(), "tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, ln)
+		// 端口为 0 时，其余监听器使用第一个监听器分配到的端口
+		if _, port, err := net.SplitHostPort(addr); err == nil && port == "0" {
+			addr = ln.Addr().String()
+		}
+	}
+	return listeners, nil
+}
+
+// ServeListeners 在多个监听器上同时处理连接（共享同一个 Handler），
+// 返回第一个出错的监听器的错误
+func (h *Handler) ServeListeners(listeners []net.Listener) error {
+	if len(listeners) == 0 {
+		return errors.New("no listeners")
+	}
+	errCh := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		go func(ln net.Listener) {
+			errCh <- h.ServeTCP(ln)
+		}(ln)
+	}
+	return <-errCh
+}
+
+// SetRequireReplicaCert 要求 PSYNC 的连接出示由 -tls-ca 签发的 TLS 客户端证书（-tls-auth-replicas），
+// 监听器需用 tls.NewListener 包装并允许客户端出示证书
+func (h *Handler) SetRequireReplicaCert(required bool) {
+	h.root().requireReplicaCert = required
+}
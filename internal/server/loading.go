@@ -0,0 +1,68 @@
+package server
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// loadingErrorMessage 加载期间拒绝命令时返回的错误，与 Redis 完全一致
+const loadingErrorMessage = "LOADING Redis is loading the dataset in memory"
+
+// loadingAllowedCommands 加载期间仍然允许执行的命令
+// 负载均衡器和客户端依赖 PING/INFO 探测状态，运维需要能执行 SHUTDOWN
+var loadingAllowedCommands = map[string]bool{
+	"PING":     true,
+	"ECHO":     true,
+	"INFO":     true,
+	"SHUTDOWN": true,
+	"QUIT":     true,
+	"AUTH":     true,
+	"HELLO":    true,
+	"SELECT":   true,
+	"COMMAND":  true,
+	"CLIENT":   true,
+	"CONFIG":   true,
+	"TIME":     true,
+	"ROLE":     true,
+	"LASTSAVE": true,
+}
+
+// loadingState 启动恢复（NextStartup、预热、重放等）期间的加载状态
+type loadingState struct {
+	active    atomic.Bool
+	startTime atomic.Int64 // 开始加载的 Unix 时间（秒）
+}
+
+// SetLoading 设置服务器是否处于加载状态
+// 加载期间连接可以建立，但除 PING/INFO/SHUTDOWN 等命令外都返回 -LOADING 错误
+// 已处于加载状态时不修改开始时间
+func (h *Handler) SetLoading(loading bool) {
+	state := &h.root().loading
+	if loading && !state.active.Load() {
+		state.startTime.Store(time.Now().Unix())
+	}
+	state.active.Store(loading)
+}
+
+// IsLoading 服务器是否处于加载状态
+func (h *Handler) IsLoading() bool {
+	return h.root().loading.active.Load()
+}
+
+// RunLoading 在加载状态下执行 fn，结束后（无论成功与否）恢复正常服务。
+// 在开始监听之前已调用 SetLoading(true) 时沿用该状态，避免监听与加载之间的连接执行命令
+func (h *Handler) RunLoading(fn func() error) error {
+	h.SetLoading(true)
+	defer h.SetLoading(false)
+	return fn()
+}
+
+// checkLoading 加载期间拒绝不允许的命令，返回 nil 表示可以执行
+func (h *Handler) checkLoading(cmd string) proto.RESP {
+	if h.IsLoading() && !loadingAllowedCommands[cmd] {
+		return proto.NewError(loadingErrorMessage)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"expvar"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// streamBacklogTTL Stream 积压需要遍历键空间，/metrics 最多每隔这段时间重新统计一次
+const streamBacklogTTL = 30 * time.Second
+
+// badgerMetrics 导出的 Badger 内置统计（expvar，进程内所有 Badger 实例合计，包括冷数据层）。
+// Map 类型的按目录或层级分开，导出时求和
+var badgerMetrics = []struct {
+	expvar string
+	name   string
+	gauge  bool
+	help   string
+}{
+	{"badger_get_num_user", "badger_gets", false, "Badger Get calls."},
+	{"badger_get_with_result_num_user", "badger_gets_with_result", false, "Badger Get calls that found a value."},
+	{"badger_put_num_user", "badger_puts", false, "Badger keys written."},
+	{"badger_write_bytes_user", "badger_written_bytes", false, "Bytes written by Badger transactions."},
+	{"badger_iterator_num_user", "badger_iterators", false, "Badger iterators created."},
+	{"badger_get_num_memtable", "badger_memtable_gets", false, "Badger lookups served from memtables."},
+	{"badger_get_num_lsm", "badger_lsm_gets", false, "Badger lookups served from LSM levels."},
+	{"badger_read_bytes_lsm", "badger_lsm_read_bytes", false, "Bytes read from LSM tables."},
+	{"badger_write_bytes_l0", "badger_l0_written_bytes", false, "Bytes flushed from memtables to level 0."},
+	{"badger_write_bytes_compaction", "badger_compaction_written_bytes", false, "Bytes written by compactions."},
+	{"badger_read_num_vlog", "badger_vlog_reads", false, "Value log reads."},
+	{"badger_read_bytes_vlog", "badger_vlog_read_bytes", false, "Bytes read from the value log."},
+	{"badger_write_num_vlog", "badger_vlog_writes", false, "Value log writes."},
+	{"badger_write_bytes_vlog", "badger_vlog_written_bytes", false, "Bytes written to the value log."},
+	{"badger_compaction_current_num_lsm", "badger_compactions_running", true, "Compactions in progress."},
+	{"badger_write_pending_num_memtable", "badger_pending_writes", true, "Writes waiting to be applied to memtables."},
+}
+
+// streamBacklogCache 最近一次统计的 Stream 积压
+type streamBacklogCache struct {
+	mu      sync.Mutex
+	at      time.Time
+	backlog store.StreamBacklog
+}
+
+// metricsWriter 按 OpenMetrics 文本格式写入指标族
+type metricsWriter struct {
+	b *strings.Builder
+}
+
+// family 写入指标族的 TYPE 与 HELP。计数器的样本名需加 _total 后缀
+func (w metricsWriter) family(name, typ, help string) {
+	w.b.WriteString("# TYPE " + name + " " + typ + "\n")
+	w.b.WriteString("# HELP " + name + " " + help + "\n")
+}
+
+func (w metricsWriter) sample(name, labels string, v int64) {
+	if labels != "" {
+		name += "{" + labels + "}"
+	}
+	w.b.WriteString(name + " " + strconv.FormatInt(v, 10) + "\n")
+}
+
+func (w metricsWriter) gauge(name, help string, v int64) {
+	w.family(name, "gauge", help)
+	w.sample(name, "", v)
+}
+
+func (w metricsWriter) counter(name, help string, v int64) {
+	w.family(name, "counter", help)
+	w.sample(name+"_total", "", v)
+}
+
+// writeServerMetrics 写入延迟直方图以外的指标：命令数、连接、键空间、内存与 Badger、Pub/Sub 与 Stream 积压
+func (h *Handler) writeServerMetrics(b *strings.Builder) {
+	w := metricsWriter{b: b}
+	stats := h.root().stats.Snapshot()
+
+	w.gauge("boltreon_uptime_seconds", "Seconds since the server started.", int64(time.Since(stats.StartTime)/time.Second))
+	w.gauge("boltreon_connected_clients", "Client connections currently open.", stats.ConnectedClients)
+	w.counter("boltreon_connections_received", "Client connections accepted.", stats.TotalConnections)
+
+	const commands = "boltreon_commands"
+	w.family(commands, "counter", "Commands processed by command and result (ok, error, or rejected before execution).")
+	for _, c := range h.root().stats.CommandStats() {
+		for _, r := range []struct {
+			result string
+			n      int64
+		}{{"ok", c.Calls - c.FailedCalls}, {"error", c.FailedCalls}, {"rejected", c.RejectedCalls}} {
+			if r.n > 0 {
+				w.sample(commands+"_total", fmt.Sprintf(`command="%s",result="%s"`, c.Name, r.result), r.n)
+			}
+		}
+	}
+	w.counter("boltreon_keyspace_hits", "Key lookups by read commands that found the key.", stats.KeyspaceHits)
+	w.counter("boltreon_keyspace_misses", "Key lookups by read commands that did not find the key.", stats.KeyspaceMisses)
+	w.gauge("boltreon_changes_since_last_save", "Write commands since the last successful SAVE or BGSAVE.", stats.ChangesSinceSave)
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	w.gauge("boltreon_memory_heap_bytes", "Go heap bytes in use.", int64(ms.HeapAlloc)) // #nosec G115 - 内存占用不会超过 int64
+	if h.Db != nil {
+		w.gauge("boltreon_blocked_clients", "Clients blocked on BLPOP, XREAD BLOCK and similar commands.", int64(h.Db.BlockedClients()))
+		w.counter("boltreon_expired_keys", "Keys deleted by active expiry.", h.Db.ExpireStats().ExpiredKeys)
+		lsm, vlog := h.Db.DiskSize()
+		w.gauge("boltreon_badger_lsm_size_bytes", "Size of the Badger LSM tree on disk.", lsm)
+		w.gauge("boltreon_badger_vlog_size_bytes", "Size of the Badger value log on disk.", vlog)
+		h.writeStreamMetrics(w)
+	}
+	writeBadgerMetrics(w)
+
+	if h.PubSub != nil {
+		backlog := h.PubSub.Backlog()
+		var published, delivered, dropped int64
+		for _, c := range h.PubSub.GetChannelStats("") {
+			published += c.Published
+			delivered += c.Delivered
+			dropped += c.Dropped
+		}
+		w.gauge("boltreon_pubsub_channels", "Channels with at least one subscriber.", int64(len(h.PubSub.GetChannels(""))))
+		w.gauge("boltreon_pubsub_patterns", "Pattern subscriptions.", int64(h.PubSub.GetPatternCount()))
+		w.gauge("boltreon_pubsub_subscribers", "Connections in subscribe mode.", int64(backlog.Subscribers))
+		w.gauge("boltreon_pubsub_pending_messages", "Messages queued for subscribers but not yet written to them.", int64(backlog.Pending))
+		w.gauge("boltreon_pubsub_max_pending_messages", "Largest per-subscriber queue of undelivered messages.", int64(backlog.MaxPending))
+		w.counter("boltreon_pubsub_published", "Messages published.", published)
+		w.counter("boltreon_pubsub_delivered", "Messages delivered to subscribers.", delivered)
+		w.counter("boltreon_pubsub_dropped", "Messages dropped because a subscriber queue was full.", dropped)
+	}
+}
+
+// writeStreamMetrics 写入 Stream 积压，统计结果缓存 streamBacklogTTL
+func (h *Handler) writeStreamMetrics(w metricsWriter) {
+	c := &h.root().streamBacklog
+	c.mu.Lock()
+	if time.Since(c.at) >= streamBacklogTTL {
+		backlog, err := h.Db.StreamBacklog()
+		if err != nil {
+			logger.Logger.Error().Err(err).Msg("统计 Stream 积压失败")
+		} else {
+			c.backlog, c.at = backlog, time.Now()
+		}
+	}
+	backlog := c.backlog
+	c.mu.Unlock()
+	w.gauge("boltreon_streams", "Stream keys.", backlog.Streams)
+	w.gauge("boltreon_stream_entries", "Entries across all streams.", backlog.Entries)
+	w.gauge("boltreon_stream_groups", "Consumer groups across all streams.", backlog.Groups)
+	w.gauge("boltreon_stream_pending_entries", "Entries delivered to consumer groups but not yet acknowledged.", backlog.Pending)
+}
+
+// writeBadgerMetrics 写入 Badger 的内置统计
+func writeBadgerMetrics(w metricsWriter) {
+	for _, m := range badgerMetrics {
+		v := expvar.Get(m.expvar)
+		if v == nil {
+			continue
+		}
+		var n int64
+		switch v := v.(type) {
+		case *expvar.Int:
+			n = v.Value()
+		case *expvar.Map:
+			v.Do(func(kv expvar.KeyValue) {
+				if i, ok := kv.Value.(*expvar.Int); ok {
+					n += i.Value()
+				}
+			})
+		default:
+			continue
+		}
+		name := "boltreon_" + m.name
+		if m.gauge {
+			w.gauge(name, m.help, n)
+		} else {
+			w.counter(name, m.help, n)
+		}
+	}
+}
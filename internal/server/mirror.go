@@ -0,0 +1,75 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// mirrorSkippedCommands Boltreon 特有的写命令，上游 Redis 不支持，不镜像
+var mirrorSkippedCommands = map[string]bool{
+	"UNDELETE": true, "PURGE": true, "NAMESPACE": true, "BOLTREON.ZMERGE": true,
+	"QPUSH": true, "QPOP": true, "QACK": true,
+}
+
+// mirrorWrite 将执行成功的写命令转发到上游 Redis（--mirror-upstream）。
+// 与复制相同只在主节点转发，从节点收到的写入已由主节点镜像过
+func (h *Handler) mirrorWrite(cmd string, cmdArgs [][]byte, resp proto.RESP) {
+	m := h.root().Mirror
+	if m == nil || !isWriteCommand(cmd) || mirrorSkippedCommands[cmd] {
+		return
+	}
+	if h.Replication != nil && !h.Replication.IsMaster() {
+		return
+	}
+	if _, isErr := resp.(*proto.Error); isErr || resp == nil {
+		return
+	}
+	m.Forward(h.canonicalDBKeys(cmdArgs))
+}
+
+// handleMirror 处理 BOLTREON.MIRROR [STATUS|PAUSE|RESUME]
+func (h *Handler) handleMirror(args [][]byte) proto.RESP {
+	m := h.root().Mirror
+	sub := "STATUS"
+	if len(args) == 1 {
+		sub = strings.ToUpper(string(args[0]))
+	}
+	if sub != "STATUS" && m == nil {
+		return proto.NewError("ERR mirroring is not enabled, start the server with --mirror-upstream")
+	}
+	switch sub {
+	case "PAUSE":
+		m.Pause()
+		return proto.OK
+	case "RESUME":
+		m.Resume()
+		return proto.OK
+	}
+
+	var b strings.Builder
+	b.WriteString("# Mirror\n")
+	if m == nil {
+		b.WriteString("enabled:0\n")
+		return proto.NewBulkString([]byte(b.String()))
+	}
+	stats := m.Stats()
+	var lastErrorTime int64
+	if !stats.LastErrorAt.IsZero() {
+		lastErrorTime = stats.LastErrorAt.Unix()
+	}
+	b.WriteString("enabled:1\n")
+	b.WriteString(fmt.Sprintf("upstream:%s\n", stats.Upstream))
+	b.WriteString(fmt.Sprintf("paused:%d\n", boolToInt(stats.Paused)))
+	b.WriteString(fmt.Sprintf("connected:%d\n", boolToInt(stats.Connected)))
+	b.WriteString(fmt.Sprintf("queue_length:%d\n", stats.QueueLen))
+	b.WriteString(fmt.Sprintf("queue_capacity:%d\n", stats.QueueCap))
+	b.WriteString(fmt.Sprintf("forwarded:%d\n", stats.Forwarded))
+	b.WriteString(fmt.Sprintf("failed:%d\n", stats.Failed))
+	b.WriteString(fmt.Sprintf("dropped:%d\n", stats.Dropped))
+	b.WriteString(fmt.Sprintf("skipped:%d\n", stats.Skipped))
+	b.WriteString(fmt.Sprintf("last_error:%s\n", stats.LastError))
+	b.WriteString(fmt.Sprintf("last_error_time:%d\n", lastErrorTime))
+	return proto.NewBulkString([]byte(b.String()))
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// namespaceNonCreatingCommands 不会新建键的写命令，不受命名空间配额限制
+var namespaceNonCreatingCommands = map[string]bool{
+	"DEL": true, "UNLINK": true, "EXPIRE": true, "EXPIREAT": true, "PEXPIRE": true, "PEXPIREAT": true, "PERSIST": true,
+	"UNDELETE": true, "PURGE": true, "NAMESPACE": true,
+	"LPOP": true, "RPOP": true, "LSET": true, "LTRIM": true, "LREM": true, "LPUSHX": true, "RPUSHX": true,
+	"HDEL": true, "SREM": true, "SPOP": true, "ZREM": true,
+	"HEXPIRE": true, "HPEXPIRE": true, "HEXPIREAT": true, "HPEXPIREAT": true, "HPERSIST": true,
+	"XDEL": true, "XACK": true, "XCLAIM": true, "XGROUP": true, "XTRIM": true, "XSETID": true, "QPOP": true, "QACK": true,
+}
+
+// namespaceWriteKeys 写命令可能新建的键
+func namespaceWriteKeys(cmd string, args [][]byte) []string {
+	if namespaceNonCreatingCommands[cmd] || len(args) == 0 {
+		return nil
+	}
+	switch cmd {
+	case "MSET", "MSETNX":
+		keys := make([]string, 0, len(args)/2)
+		for i := 0; i < len(args); i += 2 {
+			keys = append(keys, string(args[i]))
+		}
+		return keys
+	case "RENAME", "RENAMENX", "SMOVE", "RPOPLPUSH":
+		if len(args) < 2 {
+			return nil
+		}
+		return []string{string(args[1])}
+	default:
+		return []string{string(args[0])}
+	}
+}
+
+// runWithNamespacePolicy 执行写命令前检查命名空间的键数配额，执行成功后为新建的键设置默认 TTL。
+// 配额按执行前的键数检查，并发写入同一命名空间时可能略微超出
+func (h *Handler) runWithNamespacePolicy(cmd string, args [][]byte, run func() proto.RESP) proto.RESP {
+	if h.Db == nil || !h.Db.HasNamespaces() || !isWriteCommand(cmd) {
+		return run()
+	}
+
+	type newKey struct {
+		key string
+		ns  store.Namespace
+	}
+	var created []newKey
+	pending := make(map[string]int64) // 前缀 -> 本命令将新建的键数
+	for _, key := range namespaceWriteKeys(cmd, args) {
+		ns, ok := h.Db.NamespaceOf(key)
+		if !ok {
+			continue
+		}
+		if exists, err := h.Db.Exists(key); err != nil || exists {
+			continue
+		}
+		if ns.MaxKeys > 0 {
+			// 键已经带上当前数据库的前缀（见 selectDBKeys），配额按键所在的数据库统计
+			db, _ := h.Db.SplitStoreKey(key)
+			count, err := h.Db.NamespaceKeyCount(db, ns.Prefix, ns.MaxKeys)
+			if err != nil {
+				return proto.NewError(fmt.Sprintf("ERR %v", err))
+			}
+			if count+pending[ns.Prefix] >= ns.MaxKeys {
+				return proto.NewError(fmt.Sprintf("ERR namespace '%s' key quota exceeded (%d keys)", ns.Prefix, ns.MaxKeys))
+			}
+		}
+		pending[ns.Prefix]++
+		created = append(created, newKey{key: key, ns: ns})
+	}
+
+	resp := run()
+	if _, isErr := resp.(*proto.Error); isErr || resp == nil {
+		return resp
+	}
+	for _, c := range created {
+		if c.ns.DefaultTTL <= 0 {
+			continue
+		}
+		// 命令自己设置了 TTL（如 SET ... EX）时不覆盖
+		if ttl, err := h.Db.PTTL(c.key); err == nil && ttl == -1 {
+			_, _ = h.Db.PExpire(c.key, c.ns.DefaultTTL.Milliseconds())
+		}
+	}
+	return resp
+}
+
+// handleNamespace 处理 NAMESPACE 命令：
+//
+//	NAMESPACE SET prefix [TTL seconds] [MAXKEYS n]  定义或更新命名空间（0 表示不限制）
+//	NAMESPACE DEL prefix                           删除定义，不删除键
+//	NAMESPACE LIST                                 列出所有前缀
+//	NAMESPACE INFO prefix                          返回 prefix、ttl、maxkeys 与当前数据库中的 keys
+//	NAMESPACE FLUSH prefix                         删除当前数据库中属于命名空间的所有键
+func (h *Handler) handleNamespace(args [][]byte) proto.RESP {
+	sub := strings.ToUpper(string(args[0]))
+	switch sub {
+	case "SET":
+		if len(args) < 2 || len(args)%2 != 0 {
+			return proto.NewError(errSyntax)
+		}
+		ns := store.Namespace{Prefix: string(args[1])}
+		if existing, ok := h.Db.GetNamespace(ns.Prefix); ok {
+			ns = existing
+		}
+		for i := 2; i < len(args); i += 2 {
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil || n < 0 {
+				return proto.NewError(errNotInteger)
+			}
+			switch strings.ToUpper(string(args[i])) {
+			case "TTL":
+				ns.DefaultTTL = time.Duration(n) * time.Second
+			case "MAXKEYS":
+				ns.MaxKeys = n
+			default:
+				return proto.NewError(errSyntax)
+			}
+		}
+		if err := h.Db.SetNamespace(ns); err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		return proto.OK
+
+	case "DEL":
+		if len(args) != 2 {
+			return wrongArgsError("NAMESPACE DEL")
+		}
+		removed, err := h.Db.DeleteNamespace(string(args[1]))
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		if removed {
+			return proto.NewInteger(1)
+		}
+		return proto.NewInteger(0)
+
+	case "LIST":
+		if len(args) != 1 {
+			return wrongArgsError("NAMESPACE LIST")
+		}
+		list := h.Db.Namespaces()
+		prefixes := make([][]byte, len(list))
+		for i, ns := range list {
+			prefixes[i] = []byte(ns.Prefix)
+		}
+		return &proto.Array{Args: prefixes}
+
+	case "INFO":
+		if len(args) != 2 {
+			return wrongArgsError("NAMESPACE INFO")
+		}
+		ns, ok := h.Db.GetNamespace(string(args[1]))
+		if !ok {
+			return proto.NewError("ERR no such namespace")
+		}
+		keys, err := h.Db.NamespaceKeyCount(h.db, ns.Prefix, 0)
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		return &proto.NestedArray{Elems: []proto.RESP{
+			proto.NewBulkString([]byte("prefix")), proto.NewBulkString([]byte(ns.Prefix)),
+			proto.NewBulkString([]byte("ttl")), proto.NewInteger(int64(ns.DefaultTTL / time.Second)),
+			proto.NewBulkString([]byte("maxkeys")), proto.NewInteger(ns.MaxKeys),
+			proto.NewBulkString([]byte("keys")), proto.NewInteger(keys),
+		}}
+
+	case "FLUSH":
+		if len(args) != 2 {
+			return wrongArgsError("NAMESPACE FLUSH")
+		}
+		if _, ok := h.Db.GetNamespace(string(args[1])); !ok {
+			return proto.NewError("ERR no such namespace")
+		}
+		deleted, err := h.Db.FlushNamespace(h.db, string(args[1]))
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		return proto.NewInteger(deleted)
+	}
+	return proto.NewError(fmt.Sprintf("ERR unknown subcommand '%s'", string(args[0])))
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// 键空间通知的事件类别，与 Redis notify-keyspace-events 的字符一一对应
+const (
+	notifyKeyspace = 1 << iota // K：发布到 __keyspace@<db>__:<key>，消息为事件名
+	notifyKeyevent             // E：发布到 __keyevent@<db>__:<event>，消息为键名
+	notifyGeneric              // g：DEL、EXPIRE、RENAME 等与类型无关的命令
+	notifyString               // $
+	notifyList                 // l
+	notifySet                  // s
+	notifyHash                 // h
+	notifyZSet                 // z
+	notifyExpired              // x：键过期
+	notifyEvicted              // e：键因 maxmemory 被淘汰
+	notifyStream               // t
+	notifyKeyMiss              // m：读取不存在的键
+	notifyNew                  // n：新建键
+
+	// notifyAll A 表示的类别（不包括 m 和 n）
+	notifyAll = notifyGeneric | notifyString | notifyList | notifySet | notifyHash |
+		notifyZSet | notifyExpired | notifyEvicted | notifyStream
+)
+
+// notifyFlagChars 各类别对应的字符，顺序即 CONFIG GET 返回的顺序
+var notifyFlagChars = []struct {
+	flag int
+	char byte
+}{
+	{notifyGeneric, 'g'}, {notifyString, '$'}, {notifyList, 'l'}, {notifySet, 's'},
+	{notifyHash, 'h'}, {notifyZSet, 'z'}, {notifyExpired, 'x'}, {notifyEvicted, 'e'},
+	{notifyStream, 't'}, {notifyKeyMiss, 'm'}, {notifyNew, 'n'},
+	{notifyKeyspace, 'K'}, {notifyKeyevent, 'E'},
+}
+
+// keyspaceNotifier notify-keyspace-events 的当前设置（只保存在服务器级）
+type keyspaceNotifier struct {
+	flags atomic.Int64
+}
+
+// parseKeyspaceEvents 解析 notify-keyspace-events 的值，含未知字符时 ok 为 false
+func parseKeyspaceEvents(value string) (flags int, ok bool) {
+	for i := 0; i < len(value); i++ {
+		if value[i] == 'A' {
+			flags |= notifyAll
+			continue
+		}
+		found := false
+		for _, f := range notifyFlagChars {
+			if f.char == value[i] {
+				flags |= f.flag
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	return flags, true
+}
+
+// formatKeyspaceEvents 把类别转换回 notify-keyspace-events 的值，包含全部 A 类别时写成 A
+func formatKeyspaceEvents(flags int) string {
+	var b strings.Builder
+	if flags&notifyAll == notifyAll {
+		b.WriteByte('A')
+	}
+	for _, f := range notifyFlagChars {
+		if flags&f.flag == 0 || (flags&notifyAll == notifyAll && f.flag&notifyAll != 0) {
+			continue
+		}
+		b.WriteByte(f.char)
+	}
+	return b.String()
+}
+
+// notifyKeyspaceEvent 按 notify-keyspace-events 的设置发布键空间通知（默认关闭）。
+// 键空间通知只在本节点发布，不复制到从节点。key 为存储中的键名，频道使用键所在的数据库
+func (h *Handler) notifyKeyspaceEvent(class int, event, key string) {
+	flags := int(h.root().notifier.flags.Load())
+	if h.PubSub == nil || flags&class == 0 {
+		return
+	}
+	db, key := h.Db.SplitStoreKey(key)
+	if flags&notifyKeyspace != 0 {
+		h.PubSub.Publish(fmt.Sprintf("__keyspace@%d__:%s", db, key), []byte(event))
+	}
+	if flags&notifyKeyevent != 0 {
+		h.PubSub.Publish(fmt.Sprintf("__keyevent@%d__:%s", db, event), []byte(key))
+	}
+}
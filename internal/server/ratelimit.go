@@ -0,0 +1,163 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// 超过限速时的回复，以 LIMIT 开头，客户端可以据此与其他错误区分并退避重试
+const (
+	errCommandRateLimit = "LIMIT command rate limit exceeded for '%s'"
+	errClientRateLimit  = "LIMIT client rate limit exceeded"
+	errKeyRateLimit     = "LIMIT key rate limit exceeded"
+)
+
+// tokenBucket 令牌桶：每秒补充 rate 个令牌，最多积累 max(rate, 1) 个，
+// 每秒少于一次的限速（如 FLUSHALL 每分钟一次）也可以表示
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take 取一个令牌，没有令牌时返回 false
+func (b *tokenBucket) take(rate float64, now time.Time) bool {
+	burst := math.Max(rate, 1)
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// rateLimiter 命令、连接与键的限速（只保存在服务器级），都未设置时不做任何检查。
+// 命令与连接使用令牌桶；键按一秒的固定窗口计数，每秒清空，内存只与一秒内访问的键数有关
+type rateLimiter struct {
+	enabled    atomic.Bool  // 设置了任意一种限速
+	clientRate atomic.Int64 // CONFIG rate-limit-client，每个连接每秒的命令数
+	keyRate    atomic.Int64 // CONFIG rate-limit-key，每个键每秒的命令数
+	rejected   atomic.Int64 // 因限速拒绝的命令数，INFO stats 的 rate_limited_commands
+
+	mu       sync.Mutex
+	commands map[string]float64 // CONFIG rate-limit-commands，命令名 -> 整个服务器每秒的次数
+	buckets  map[string]*tokenBucket
+	window   int64 // keyCount 所属的 Unix 秒
+	keyCount map[string]int64
+}
+
+// updateEnabled 在修改设置后调用
+func (l *rateLimiter) updateEnabled() {
+	l.mu.Lock()
+	n := len(l.commands)
+	l.mu.Unlock()
+	l.enabled.Store(n > 0 || l.clientRate.Load() > 0 || l.keyRate.Load() > 0)
+}
+
+// takeCommand 命令设置了限速时取一个令牌
+func (l *rateLimiter) takeCommand(cmd string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rate, ok := l.commands[cmd]
+	if !ok {
+		return true
+	}
+	b := l.buckets[cmd]
+	if b == nil {
+		b = &tokenBucket{}
+		l.buckets[cmd] = b
+	}
+	return b.take(rate, now)
+}
+
+// takeKeys 每个键在当前一秒内的计数加一，任一键超过 limit 时返回 false（已计入的不回退）
+func (l *rateLimiter) takeKeys(keys []string, limit int64, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if sec := now.Unix(); sec != l.window || l.keyCount == nil {
+		l.window, l.keyCount = sec, make(map[string]int64)
+	}
+	for _, key := range keys {
+		l.keyCount[key]++
+		if l.keyCount[key] > limit {
+			return false
+		}
+	}
+	return true
+}
+
+// setCommands 设置命令限速，格式为 "命令 每秒次数 命令 每秒次数 ..."，为空时取消
+func (l *rateLimiter) setCommands(value string) error {
+	fields := strings.Fields(value)
+	if len(fields)%2 != 0 {
+		return errors.New("argument must be a list of command and ops per second pairs")
+	}
+	commands := make(map[string]float64, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		rate, err := strconv.ParseFloat(fields[i+1], 64)
+		if err != nil || !(rate > 0) || math.IsInf(rate, 1) {
+			return fmt.Errorf("invalid ops per second '%s' for command '%s'", fields[i+1], fields[i])
+		}
+		commands[strings.ToUpper(fields[i])] = rate
+	}
+	l.mu.Lock()
+	l.commands = commands
+	l.buckets = make(map[string]*tokenBucket, len(commands))
+	l.mu.Unlock()
+	l.updateEnabled()
+	return nil
+}
+
+// commandsConfig 按命令名排序返回 CONFIG GET rate-limit-commands 的值
+func (l *rateLimiter) commandsConfig() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	names := make([]string, 0, len(l.commands))
+	for cmd := range l.commands {
+		names = append(names, cmd)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, 2*len(names))
+	for _, cmd := range names {
+		parts = append(parts, strings.ToLower(cmd), strconv.FormatFloat(l.commands[cmd], 'g', -1, 64))
+	}
+	return strings.Join(parts, " ")
+}
+
+// checkRateLimit 依次检查连接、命令与键的限速，超过时回复 -LIMIT。
+// 事务中的命令在入队时检查，EXEC 本身只计入连接的限速；脚本中的命令不检查
+func (h *Handler) checkRateLimit(cmd string, args [][]byte) proto.RESP {
+	l := &h.root().rateLimit
+	if !l.enabled.Load() {
+		return nil
+	}
+	now := time.Now()
+	if rate := l.clientRate.Load(); rate > 0 && !h.rateBucket.take(float64(rate), now) {
+		l.rejected.Add(1)
+		return proto.NewError(errClientRateLimit)
+	}
+	if !l.takeCommand(cmd, now) {
+		l.rejected.Add(1)
+		return proto.NewError(fmt.Sprintf(errCommandRateLimit, strings.ToLower(cmd)))
+	}
+	if limit := l.keyRate.Load(); limit > 0 {
+		if keys := commandKeys(cmd, args); len(keys) > 0 && !l.takeKeys(keys, limit, now) {
+			l.rejected.Add(1)
+			return proto.NewError(errKeyRateLimit)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// errReadOnlyReplica 只读从节点拒绝写命令的回复，与 Redis 相同
+const errReadOnlyReplica = "READONLY You can't write against a read only replica."
+
+// isDatasetWrite 修改数据的命令：与成功执行后写入 AOF 的命令相同（见 isAOFCommand），
+// 只读从节点拒绝这些命令，集群中执行过 READONLY 的连接只有其他命令可以在从节点上执行
+func isDatasetWrite(cmd string, args [][]byte) bool {
+	return isAOFCommand(cmd, args)
+}
+
+// isReadOnlyReplica 本节点正在复制主节点且 replica-read-only 为 yes（默认）
+func (h *Handler) isReadOnlyReplica() bool {
+	return h.Replication != nil && h.Replication.IsSlave() && !h.root().conf.replicaWritable.Load()
+}
+
+// checkReadOnlyReplica 只读从节点拒绝客户端的写命令，连接执行过 READWRITE 时除外。
+// 主节点传播的命令与 AOF 重放不经过这里
+func (h *Handler) checkReadOnlyReplica(cmd string, args [][]byte) proto.RESP {
+	if h.readWrite || !isDatasetWrite(cmd, args) || !h.isReadOnlyReplica() {
+		return nil
+	}
+	return proto.NewError(errReadOnlyReplica)
+}
+
+// handleReadOnly 处理 READONLY 与 READWRITE。READONLY：集群模式下本节点是某个槽的主节点的从节点时，
+// 这个连接读取该槽的键在本地处理而不是返回 MOVED，用于把读请求分散到从节点；写命令不受影响。
+// READWRITE：取消 READONLY，并允许这个连接在只读从节点上执行写命令
+func (h *Handler) handleReadOnly(cmd string) proto.RESP {
+	h.clusterReadOnly = cmd == "READONLY"
+	h.readWrite = cmd == "READWRITE"
+	if c := h.clientInfo; c != nil {
+		c.mu.Lock()
+		c.ReadOnly = h.clusterReadOnly
+		c.mu.Unlock()
+	}
+	return proto.NewSimpleString("OK")
+}
@@ -1,28 +1,108 @@
 package server
 
+import (
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// replicaRemoteAddr 从节点执行主节点传播的命令时命令的来源地址
+const replicaRemoteAddr = "master"
+
+// replicatedExtraCommands 除 isWriteCommand 外同样传播到从节点的命令：
+// 消费者组的读取与认领会修改待确认列表，PUBLISH 让订阅从节点的客户端也收到消息
+var replicatedExtraCommands = map[string]bool{
+	"XREADGROUP": true, "XAUTOCLAIM": true, "PUBLISH": true,
+}
+
 // isWriteCommand 检查是否是写命令
 func isWriteCommand(cmd string) bool {
 	writeCommands := map[string]bool{
 		"SET": true, "SETEX": true, "PSETEX": true, "SETNX": true,
-		"GETSET": true, "MSET": true, "MSETNX": true,
+		"GETSET": true, "GETEX": true, "GETDEL": true, "MSET": true, "MSETNX": true,
 		"INCR": true, "INCRBY": true, "DECR": true, "DECRBY": true,
 		"INCRBYFLOAT": true, "APPEND": true, "SETRANGE": true,
-		"DEL": true, "EXPIRE": true, "EXPIREAT": true,
+		"DEL": true, "UNLINK": true, "EXPIRE": true, "EXPIREAT": true,
 		"PEXPIRE": true, "PEXPIREAT": true, "PERSIST": true,
-		"RENAME": true, "RENAMENX": true,
+		"RENAME": true, "RENAMENX": true, "MOVE": true, "SWAPDB": true, "UNDELETE": true, "PURGE": true, "NAMESPACE": true,
 		"LPUSH": true, "RPUSH": true, "LPOP": true, "RPOP": true,
 		"LSET": true, "LTRIM": true, "LINSERT": true, "LREM": true,
 		"RPOPLPUSH": true, "LPUSHX": true, "RPUSHX": true,
 		"HSET": true, "HDEL": true, "HMSET": true, "HSETNX": true,
 		"HINCRBY": true, "HINCRBYFLOAT": true,
+		"HEXPIRE": true, "HPEXPIRE": true, "HEXPIREAT": true, "HPEXPIREAT": true, "HPERSIST": true,
 		"SADD": true, "SREM": true, "SPOP": true, "SMOVE": true,
 		"SINTERSTORE": true, "SUNIONSTORE": true, "SDIFFSTORE": true,
-		"ZADD": true, "ZREM": true, "ZINCRBY": true,
+		"ZADD": true, "ZREM": true, "ZINCRBY": true, "BOLTREON.ZMERGE": true,
 		// GEO commands
 		"GEOADD": true, "GEOSEARCHSTORE": true,
 		// Stream commands
 		"XADD": true, "XDEL": true, "XACK": true,
-		"XCLAIM": true, "XGROUP": true, "XTRIM": true,
+		"XCLAIM": true, "XGROUP": true, "XTRIM": true, "XSETID": true,
+		// 延迟队列
+		"QPUSH": true, "QPOP": true, "QACK": true,
 	}
 	return writeCommands[cmd]
 }
+
+// replicationCommands 传播到从节点的命令（含命令名）。结果取决于执行时状态的流命令改写为确定的形式，
+// 从节点执行后与主节点一致：XADD 的自动 ID 换成生成的 ID，XREADGROUP 改为非阻塞形式，
+// XAUTOCLAIM 改为认领同样条目的 XCLAIM；这些命令执行失败或没有效果时不传播
+func (h *Handler) replicationCommands(cmd string, cmdArgs [][]byte, resp proto.RESP) [][][]byte {
+	switch cmd {
+	case "XADD", "XREADGROUP":
+		return h.aofCommands(cmd, cmdArgs[1:], resp)
+	case "XAUTOCLAIM":
+		return xautoclaimCommands(cmdArgs[1:], resp)
+	}
+	return [][][]byte{cmdArgs}
+}
+
+// xautoclaimCommands 把 XAUTOCLAIM key group consumer ... 改写为 XCLAIM key group consumer 0 id...，
+// 扫描时移除的已删除条目改写为 XACK key group id...。回复为 [下次扫描的起点, 认领的条目, 已删除的 ID]，
+// 不带 JUSTID 时认领的条目为 [id, [field, value, ...]]
+func xautoclaimCommands(args [][]byte, resp proto.RESP) [][][]byte {
+	r, ok := resp.(*proto.NestedArray)
+	if !ok || len(r.Elems) != 3 || len(args) < 3 {
+		return nil
+	}
+	var out [][][]byte
+	claim := [][]byte{[]byte("XCLAIM"), args[0], args[1], args[2], []byte("0")}
+	switch claimed := r.Elems[1].(type) {
+	case *proto.Array:
+		claim = append(claim, claimed.Args...)
+	case *proto.NestedArray:
+		for _, e := range claimed.Elems {
+			if entry, ok := e.(*proto.NestedArray); ok && len(entry.Elems) > 0 {
+				if id, ok := entry.Elems[0].(*proto.BulkString); ok {
+					claim = append(claim, []byte(*id))
+				}
+			}
+		}
+	}
+	if len(claim) > 5 {
+		out = append(out, claim)
+	}
+	if deleted, ok := r.Elems[2].(*proto.Array); ok && len(deleted.Args) > 0 {
+		out = append(out, append([][]byte{[]byte("XACK"), args[0], args[1]}, deleted.Args...))
+	}
+	return out
+}
+
+// replicaExecutor 从节点执行主节点传播的流与发布订阅命令的函数（见 replication.ReplicationManager.SetCommandExecutor）：
+// 按普通命令执行并写入 AOF，键名按本节点当前的代换算。复制连接只有一个，所有命令共用同一个连接状态
+func (h *Handler) replicaExecutor() func(args [][]byte) {
+	exec := h.newConnection()
+	return func(args [][]byte) {
+		// 复制偏移量按收到的命令计算，不修改调用方的参数
+		args = append([][]byte(nil), args...)
+		cmd := strings.ToUpper(string(args[0]))
+		exec.resolveDBKeys(cmd, args[1:])
+		unlock := exec.lockCommand(cmd)
+		endAOF := exec.beginAOF(cmd, args[1:])
+		resp := exec.runCommand(cmd, args[1:], replicaRemoteAddr)
+		exec.feedAOF(cmd, args[1:], resp)
+		endAOF()
+		unlock()
+	}
+}
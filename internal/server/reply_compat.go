@@ -0,0 +1,82 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// wrongTypeErrorMessage 对错误类型的键执行命令时返回的错误，与 Redis 完全一致
+const wrongTypeErrorMessage = "WRONGTYPE Operation against a key holding the wrong kind of value"
+
+// commandKeyTypes 命令 -> 第一个键参数要求的类型（TYPE 命令的返回值）
+// 键存在且类型不符时返回 WRONGTYPE；SET、DEL、MGET 等不关心类型的命令不在此表中
+var commandKeyTypes = map[string]string{
+	// 字符串
+	"GET": "string", "GETSET": "string", "GETDEL": "string", "GETEX": "string",
+	"APPEND": "string", "STRLEN": "string", "GETRANGE": "string", "SETRANGE": "string",
+	"INCR": "string", "INCRBY": "string", "DECR": "string", "DECRBY": "string", "INCRBYFLOAT": "string",
+	"SETBIT": "string", "GETBIT": "string", "BITCOUNT": "string", "BITPOS": "string", "BITFIELD": "string",
+	"BITFIELD_RO": "string", "BITLEN": "string",
+	// 列表
+	"LPUSH": "list", "RPUSH": "list", "LPUSHX": "list", "RPUSHX": "list", "LPOP": "list", "RPOP": "list",
+	"LLEN": "list", "LRANGE": "list", "LINDEX": "list", "LSET": "list", "LREM": "list", "LTRIM": "list",
+	"LINSERT": "list", "LPOS": "list",
+	// 哈希
+	"HSET": "hash", "HSETNX": "hash", "HMSET": "hash", "HGET": "hash", "HMGET": "hash", "HDEL": "hash",
+	"HEXISTS": "hash", "HLEN": "hash", "HKEYS": "hash", "HVALS": "hash", "HGETALL": "hash",
+	"HINCRBY": "hash", "HINCRBYFLOAT": "hash", "HSTRLEN": "hash", "HRANDFIELD": "hash", "HSCAN": "hash",
+	"HEXPIRE": "hash", "HPEXPIRE": "hash", "HEXPIREAT": "hash", "HPEXPIREAT": "hash", "HPERSIST": "hash",
+	"HTTL": "hash", "HPTTL": "hash", "HEXPIRETIME": "hash", "HPEXPIRETIME": "hash",
+	// 集合
+	"SADD": "set", "SREM": "set", "SISMEMBER": "set", "SMISMEMBER": "set", "SCARD": "set",
+	"SMEMBERS": "set", "SPOP": "set", "SRANDMEMBER": "set", "SSCAN": "set",
+	// 有序集合
+	"ZADD": "zset", "ZREM": "zset", "ZCARD": "zset", "ZSCORE": "zset", "ZMSCORE": "zset", "ZINCRBY": "zset",
+	"ZRANGE": "zset", "ZREVRANGE": "zset", "ZRANGEBYSCORE": "zset", "ZREVRANGEBYSCORE": "zset",
+	"ZRANGEBYLEX": "zset", "ZLEXCOUNT": "zset", "ZRANK": "zset", "ZREVRANK": "zset", "ZCOUNT": "zset",
+	"ZREMRANGEBYRANK": "zset", "ZREMRANGEBYSCORE": "zset", "ZPOPMIN": "zset", "ZPOPMAX": "zset",
+	"ZRANDMEMBER": "zset", "ZSCAN": "zset", "BOLTREON.ZWATCH": "zset",
+	"BOLTREON.ZMERGE": "zset",
+	// 延迟队列（建立在 Stream 之上）
+	"QPUSH": "stream", "QPOP": "stream", "QACK": "stream",
+}
+
+// checkWrongType 检查命令的第一个键是否为命令要求的类型，类型不符时返回 WRONGTYPE 错误
+func (h *Handler) checkWrongType(cmd string, args [][]byte) proto.RESP {
+	want, ok := commandKeyTypes[cmd]
+	if !ok || len(args) == 0 || h.Db == nil {
+		return nil
+	}
+	keyType, err := h.Db.Type(string(args[0]))
+	if err != nil || keyType == "none" || keyType == want {
+		return nil
+	}
+	return proto.NewError(wrongTypeErrorMessage)
+}
+
+// unknownCommandError 未知命令的错误信息，格式与 Redis 一致：
+// ERR unknown command 'foo', with args beginning with: 'a' 'b'
+func unknownCommandError(cmd string, args [][]byte) string {
+	// 参数部分最多 128 字节，与 Redis 的截断规则相同
+	var argsPart strings.Builder
+	for _, arg := range args {
+		if argsPart.Len() >= 128 {
+			break
+		}
+		limit := 128 - argsPart.Len()
+		argsPart.WriteString("'")
+		argsPart.WriteString(truncateArg(string(arg), limit))
+		argsPart.WriteString("' ")
+	}
+	msg := "ERR unknown command '" + truncateArg(cmd, 128) + "', with args beginning with: " + argsPart.String()
+	// 错误信息不能包含换行
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(msg)
+}
+
+func truncateArg(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// DefaultReplyCompressThreshold HELLO ... COMPRESS zstd 未指定 THRESHOLD 时，
+// 编码后超过该字节数的回复才压缩
+const DefaultReplyCompressThreshold = 4096
+
+// zstdFrameMagic zstd 帧的起始字节（小端序 0xFD2FB528）
+var zstdFrameMagic = []byte{0x28, 0xB5, 0x2F, 0xFD}
+
+// replyEncoder 所有连接共享的 zstd 编码器，EncodeAll 可以并发调用
+var replyEncoder = sync.OnceValue(func() *zstd.Encoder {
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		panic(fmt.Sprintf("create zstd encoder: %v", err))
+	}
+	return enc
+})
+
+// replyCompression 连接通过 HELLO 协商的回复压缩（连接级别），nil 表示不压缩。
+//
+// 协商后编码超过 threshold 字节的回复整体压缩成一个 zstd 帧，以 bulk string 发送；
+// 客户端收到以 zstd 帧头开头的 bulk string 时解压，再按 RESP 解析得到原回复。
+// 本身以 zstd 帧头开头的 bulk string 回复无论大小都会压缩，因此不会与压缩帧混淆
+type replyCompression struct {
+	threshold int
+}
+
+// compressReply 按连接协商的设置压缩回复，未协商或压缩后不更小时原样返回
+func (h *Handler) compressReply(resp proto.RESP) proto.RESP {
+	rc := h.replyCompression
+	if rc == nil || resp == nil {
+		return resp
+	}
+	ambiguous := false
+	if b, ok := resp.(*proto.BulkString); ok && b != nil && bytes.HasPrefix(*b, zstdFrameMagic) {
+		ambiguous = true
+	}
+	encoded := resp.String()
+	if len(encoded) <= rc.threshold && !ambiguous {
+		return resp
+	}
+	frame := replyEncoder().EncodeAll([]byte(encoded), nil)
+	if len(frame) >= len(encoded) && !ambiguous {
+		return resp
+	}
+	return proto.NewBulkString(frame)
+}
+
+// handleHello HELLO [protover [AUTH username password] [SETNAME clientname] [COMPRESS zstd|none] [THRESHOLD bytes]]
+// protover 为 2 或 3，省略时保持当前协议；COMPRESS 与 THRESHOLD 是扩展选项，用于协商回复压缩，默认不压缩
+func (h *Handler) handleHello(args [][]byte, remoteAddr string) proto.RESP {
+	protocol := h.protocol
+	if len(args) > 0 {
+		ver, err := strconv.ParseInt(string(args[0]), 10, 64)
+		if err != nil {
+			return proto.NewError("ERR Protocol version is not an integer or out of range")
+		}
+		if ver != 2 && ver != 3 {
+			return proto.NewError("NOPROTO sorry, this protocol version is not supported")
+		}
+		protocol = int(ver)
+	}
+
+	var password, name string
+	var hasAuth, hasName bool
+	compress := h.replyCompression != nil
+	threshold := DefaultReplyCompressThreshold
+	if compress {
+		threshold = h.replyCompression.threshold
+	}
+	for i := 1; i < len(args); i++ {
+		opt := strings.ToUpper(string(args[i]))
+		switch {
+		case opt == "AUTH" && i+2 < len(args):
+			password = string(args[i+2])
+			hasAuth = true
+			i += 2
+		case opt == "SETNAME" && i+1 < len(args):
+			name = string(args[i+1])
+			if !validClientName(name) {
+				return proto.NewError(errInvalidClientName)
+			}
+			hasName = true
+			i++
+		case opt == "COMPRESS" && i+1 < len(args):
+			switch strings.ToLower(string(args[i+1])) {
+			case "zstd":
+				compress = true
+			case "none":
+				compress = false
+			default:
+				return proto.NewError(fmt.Sprintf("ERR unsupported reply compression '%s'", string(args[i+1])))
+			}
+			i++
+		case opt == "THRESHOLD" && i+1 < len(args):
+			n, err := strconv.Atoi(string(args[i+1]))
+			if err != nil || n < 0 {
+				return proto.NewError("ERR value is not an integer or out of range")
+			}
+			threshold = n
+			i++
+		default:
+			return proto.NewError(fmt.Sprintf("ERR Syntax error in HELLO option '%s'", string(args[i])))
+		}
+	}
+
+	// 选项全部有效后才生效
+	if hasAuth {
+		resp := h.executeCommand("AUTH", [][]byte{[]byte(password)}, remoteAddr)
+		if _, isErr := resp.(*proto.Error); isErr {
+			return resp
+		}
+	}
+	if hasName {
+		if h.clientInfo == nil {
+			h.clientInfo = &ClientInfo{Multi: -1}
+		}
+		h.clientInfo.setName(name)
+	}
+	if compress {
+		h.replyCompression = &replyCompression{threshold: threshold}
+	} else {
+		h.replyCompression = nil
+	}
+	h.protocol = protocol
+	return h.helloReply()
+}
+
+// helloReply HELLO 的回复：服务器信息的字段/值列表，RESP3 下为 Map
+func (h *Handler) helloReply() proto.RESP {
+	id := int64(1)
+	if h.clientInfo != nil && h.clientInfo.ID != 0 {
+		id = h.clientInfo.ID
+	}
+	mode := "standalone"
+	if h.Cluster != nil {
+		mode = "cluster"
+	}
+	role := "master"
+	if h.Replication != nil && h.Replication.GetRole() == "slave" {
+		role = "replica"
+	}
+	compression, threshold := "none", int64(0)
+	if h.replyCompression != nil {
+		compression, threshold = "zstd", int64(h.replyCompression.threshold)
+	}
+	protocol := int64(2)
+	if h.resp3() {
+		protocol = 3
+	}
+	bulk := func(s string) proto.RESP { return proto.NewBulkString([]byte(s)) }
+	fields := []proto.RESP{
+		bulk("server"), bulk("redis"),
+		bulk("version"), bulk("boltdb-8.0.0"),
+		bulk("proto"), proto.NewInteger(protocol),
+		bulk("id"), proto.NewInteger(id),
+		bulk("mode"), bulk(mode),
+		bulk("role"), bulk(role),
+		bulk("modules"), &proto.NestedArray{Elems: []proto.RESP{}},
+		bulk("compression"), bulk(compression),
+		bulk("compression-threshold"), proto.NewInteger(threshold),
+	}
+	if h.resp3() {
+		return proto.NewMap(fields)
+	}
+	return &proto.NestedArray{Elems: fields}
+}
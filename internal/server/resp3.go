@@ -0,0 +1,148 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// 命令按 RESP2 构造回复，连接通过 HELLO 3 切换到 RESP3 后再由 adaptReply 转换成带类型的回复：
+// 空值变为 Null，键值对数组变为 Map，集合变为 Set，分数变为 Double，订阅确认与消息变为 Push
+
+// mapReplyCommands 回复是字段、值交替数组的命令，RESP3 下返回 Map
+var mapReplyCommands = map[string]bool{
+	"HGETALL": true,
+}
+
+// setReplyCommands 回复是无序、不重复元素的命令，RESP3 下返回 Set
+var setReplyCommands = map[string]bool{
+	"SMEMBERS": true, "SINTER": true, "SUNION": true, "SDIFF": true,
+}
+
+// pushReplyCommands 订阅相关命令，RESP3 下确认以 Push 发送
+var pushReplyCommands = map[string]bool{
+	"SUBSCRIBE": true, "PSUBSCRIBE": true, "UNSUBSCRIBE": true, "PUNSUBSCRIBE": true,
+	"SSUBSCRIBE": true, "SUNSUBSCRIBE": true,
+}
+
+// resp3 连接是否已协商 RESP3
+func (h *Handler) resp3() bool {
+	return h.protocol == 3
+}
+
+// adaptReply 按连接协商的协议转换命令的回复，RESP2 连接原样返回
+func (h *Handler) adaptReply(cmd string, args [][]byte, resp proto.RESP) proto.RESP {
+	if !h.resp3() || resp == nil {
+		return resp
+	}
+	if _, isErr := resp.(*proto.Error); isErr {
+		return resp
+	}
+	return proto.ToRESP3(resp3Typed(cmd, args, resp))
+}
+
+// pushReply 订阅确认与消息在 RESP3 连接上以 Push 发送
+func (h *Handler) pushReply(resp proto.RESP) proto.RESP {
+	if !h.resp3() {
+		return resp
+	}
+	if elems, ok := proto.Elems(resp); ok {
+		return proto.ToRESP3(proto.NewPush(elems))
+	}
+	return resp
+}
+
+// resp3Typed 将命令的 RESP2 回复换成 RESP3 中对应的聚合类型与 Double
+func resp3Typed(cmd string, args [][]byte, resp proto.RESP) proto.RESP {
+	elems, isArray := proto.Elems(resp)
+	switch {
+	case mapReplyCommands[cmd] && isArray:
+		return proto.NewMap(elems)
+	case cmd == "CONFIG" && isArray && len(args) > 0 && strings.EqualFold(string(args[0]), "GET"):
+		return proto.NewMap(elems)
+	case cmd == "PUBSUB" && isArray && len(args) > 0 &&
+		(strings.EqualFold(string(args[0]), "NUMSUB") || strings.EqualFold(string(args[0]), "SHARDNUMSUB")):
+		return proto.NewMap(elems)
+	case setReplyCommands[cmd] && isArray:
+		return proto.NewSet(elems)
+	case pushReplyCommands[cmd] && isArray:
+		return proto.NewPush(elems)
+	case cmd == "ZSCORE" || cmd == "ZINCRBY":
+		return toDouble(resp)
+	case cmd == "ZMSCORE" && isArray:
+		for i := range elems {
+			elems[i] = toDouble(elems[i])
+		}
+		return &proto.NestedArray{Elems: elems}
+	case (cmd == "ZRANGE" || cmd == "ZREVRANGE" || cmd == "ZRANGEBYSCORE" || cmd == "ZREVRANGEBYSCORE") &&
+		isArray && hasOption(args, 3, "WITHSCORES"):
+		return pairs(elems, true)
+	case cmd == "HRANDFIELD" && isArray && hasOption(args, 1, "WITHVALUES"):
+		return pairs(elems, false)
+	case (cmd == "ZPOPMIN" || cmd == "ZPOPMAX") && isArray:
+		// 指定 count 时返回 [member, score] 对的数组，否则是单个 [member, score]
+		if len(args) >= 2 {
+			return pairs(elems, true)
+		}
+		if len(elems) == 2 {
+			elems[1] = toDouble(elems[1])
+		}
+		return &proto.NestedArray{Elems: elems}
+	case (cmd == "BZPOPMIN" || cmd == "BZPOPMAX") && isArray:
+		// [key, member, score]，超时返回 Null
+		if len(elems) != 3 {
+			return proto.NewNull()
+		}
+		elems[2] = toDouble(elems[2])
+		return &proto.NestedArray{Elems: elems}
+	case (cmd == "ZMPOP" || cmd == "BZMPOP") && isArray && len(elems) == 2:
+		// [key, [[member, score], ...]]
+		members, _ := proto.Elems(elems[1])
+		for i, m := range members {
+			if pair, ok := proto.Elems(m); ok && len(pair) == 2 {
+				pair[1] = toDouble(pair[1])
+				members[i] = &proto.NestedArray{Elems: pair}
+			}
+		}
+		elems[1] = &proto.NestedArray{Elems: members}
+		return &proto.NestedArray{Elems: elems}
+	}
+	return resp
+}
+
+// hasOption 检查 args[from:] 中是否有不区分大小写的选项 opt
+func hasOption(args [][]byte, from int, opt string) bool {
+	for i := from; i < len(args); i++ {
+		if strings.EqualFold(string(args[i]), opt) {
+			return true
+		}
+	}
+	return false
+}
+
+// pairs 将交替数组分组成两个元素的数组，scores 为 true 时第二个元素换成 Double
+func pairs(elems []proto.RESP, scores bool) proto.RESP {
+	out := make([]proto.RESP, 0, len(elems)/2)
+	for i := 0; i+1 < len(elems); i += 2 {
+		second := elems[i+1]
+		if scores {
+			second = toDouble(second)
+		}
+		out = append(out, &proto.NestedArray{Elems: []proto.RESP{elems[i], second}})
+	}
+	return &proto.NestedArray{Elems: out}
+}
+
+// toDouble 将表示数值的 bulk string 换成 Double，空值与无法解析的值原样返回
+func toDouble(r proto.RESP) proto.RESP {
+	b, ok := r.(*proto.BulkString)
+	if !ok || b == nil || *b == nil {
+		return r
+	}
+	f, err := strconv.ParseFloat(string(*b), 64)
+	if err != nil {
+		return r
+	}
+	return proto.NewDouble(f)
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// scanOptions SCAN/SSCAN/HSCAN/ZSCAN 的游标与选项
+type scanOptions struct {
+	cursor   uint64
+	pattern  string
+	count    int
+	keyType  string // SCAN TYPE
+	noValues bool   // HSCAN NOVALUES
+}
+
+// parseScanOptions 解析 cursor [MATCH pattern] [COUNT count] 以及命令特有的 TYPE（SCAN）、NOVALUES（HSCAN）
+func parseScanOptions(cmd string, args [][]byte) (scanOptions, proto.RESP) {
+	opts := scanOptions{count: 10}
+	cursor, err := strconv.ParseUint(string(args[0]), 10, 64)
+	if err != nil {
+		return opts, proto.NewError("ERR invalid cursor")
+	}
+	opts.cursor = cursor
+	for i := 1; i < len(args); i++ {
+		opt := strings.ToUpper(string(args[i]))
+		switch {
+		case opt == "MATCH" && i+1 < len(args):
+			opts.pattern = string(args[i+1])
+			i++
+		case opt == "COUNT" && i+1 < len(args):
+			count, err := strconv.Atoi(string(args[i+1]))
+			if err != nil {
+				return opts, proto.NewError(errNotInteger)
+			}
+			if count < 1 {
+				return opts, proto.NewError(errSyntax)
+			}
+			opts.count = count
+			i++
+		case opt == "TYPE" && cmd == "SCAN" && i+1 < len(args):
+			opts.keyType = string(args[i+1])
+			i++
+		case opt == "NOVALUES" && cmd == "HSCAN":
+			opts.noValues = true
+		default:
+			return opts, proto.NewError(errSyntax)
+		}
+	}
+	return opts, nil
+}
+
+// handleScan 执行 SCAN 系列命令，返回 [cursor, [元素...]]。游标是服务端保存的遍历位置的编号，
+// 过期或属于其他键的游标返回 ERR invalid cursor
+func (h *Handler) handleScan(cmd string, args [][]byte) proto.RESP {
+	var key string
+	if cmd != "SCAN" {
+		key, args = string(args[0]), args[1:]
+	}
+	opts, errResp := parseScanOptions(cmd, args)
+	if errResp != nil {
+		return errResp
+	}
+
+	var cursor uint64
+	var elems []string
+	var err error
+	switch cmd {
+	case "SCAN":
+		var result store.ScanResult
+		result, err = h.Db.DBScan(h.db, opts.cursor, opts.pattern, opts.count, opts.keyType)
+		cursor, elems = result.Cursor, result.Keys
+	case "SSCAN":
+		var result store.SScanResult
+		result, err = h.Db.SScan(key, opts.cursor, opts.pattern, opts.count)
+		cursor, elems = result.Cursor, result.Members
+	case "HSCAN":
+		var result store.HScanResult
+		result, err = h.Db.HScan(key, opts.cursor, opts.pattern, opts.count, opts.noValues)
+		cursor, elems = result.Cursor, result.Fields
+		if !opts.noValues {
+			elems = make([]string, 0, len(result.Fields)*2)
+			for i, field := range result.Fields {
+				elems = append(elems, field, string(result.Values[i]))
+			}
+		}
+	case "ZSCAN":
+		var result store.ZScanResult
+		result, err = h.Db.ZScan(key, opts.cursor, opts.pattern, opts.count)
+		cursor = result.Cursor
+		elems = make([]string, 0, len(result.Members)*2)
+		for _, m := range result.Members {
+			elems = append(elems, m.Member, fmt.Sprintf("%.10g", m.Score))
+		}
+	}
+	if errors.Is(err, store.ErrInvalidCursor) {
+		return proto.NewError("ERR invalid cursor")
+	}
+	if err != nil {
+		return proto.NewError(fmt.Sprintf("ERR %v", err))
+	}
+	return proto.NewScanResponse(cursor, elems)
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+const (
+	// ScheduleResultsStream 定时命令的执行结果写入此 Stream
+	ScheduleResultsStream = "boltreon:schedule:results"
+	// DefaultScheduleInterval 检查到期定时命令的默认间隔
+	DefaultScheduleInterval = 100 * time.Millisecond
+
+	// scheduleResultsMaxLen 结果 Stream 保留的最大条数
+	scheduleResultsMaxLen = 10000
+	// scheduleBatchSize 每次从存储中取出的到期命令数
+	scheduleBatchSize = 100
+	// scheduleRemoteAddr 定时命令执行时使用的客户端地址（用于日志）
+	scheduleRemoteAddr = "scheduler"
+)
+
+// scheduleRejectedCommands 不能定时执行的命令：阻塞命令、事务、订阅和复制相关命令
+var scheduleRejectedCommands = map[string]bool{
+	"MULTI": true, "EXEC": true, "DISCARD": true, "WATCH": true, "UNWATCH": true,
+	"SUBSCRIBE": true, "PSUBSCRIBE": true, "UNSUBSCRIBE": true, "PUNSUBSCRIBE": true,
+	"SSUBSCRIBE": true, "SUNSUBSCRIBE": true,
+	"BLPOP": true, "BRPOP": true, "BLMOVE": true, "BRPOPLPUSH": true, "BZPOPMIN": true, "BZPOPMAX": true, "BZMPOP": true,
+	"PSYNC": true, "SYNC": true, "REPLCONF": true, "REPLICAOF": true, "SLAVEOF": true,
+	"MONITOR": true, "SHUTDOWN": true, "QUIT": true,
+}
+
+// handleSchedule 处理 BOLTREON.SCHEDULE：
+//
+//	BOLTREON.SCHEDULE <unix_ms> <command> [arg ...]  登记定时命令，返回 ID
+//	BOLTREON.SCHEDULE CANCEL <id>                   取消尚未执行的定时命令
+//	BOLTREON.SCHEDULE LIST                          按执行时间列出 [id, unix_ms, [command, arg ...]]
+func (h *Handler) handleSchedule(args [][]byte) proto.RESP {
+	switch strings.ToUpper(string(args[0])) {
+	case "LIST":
+		if len(args) != 1 {
+			return proto.NewError(errSyntax)
+		}
+		cmds, err := h.Db.ScheduledCommands()
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		elems := make([]proto.RESP, 0, len(cmds))
+		for _, c := range cmds {
+			cmdArgs := make([][]byte, len(c.Args))
+			for i, a := range c.Args {
+				cmdArgs[i] = []byte(a)
+			}
+			elems = append(elems, &proto.NestedArray{Elems: []proto.RESP{
+				proto.NewBulkString([]byte(c.ID)),
+				proto.NewInteger(c.At),
+				&proto.Array{Args: cmdArgs},
+			}})
+		}
+		return &proto.NestedArray{Elems: elems}
+
+	case "CANCEL":
+		if len(args) != 2 {
+			return proto.NewError(errSyntax)
+		}
+		removed, err := h.Db.Unschedule(string(args[1]))
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		if removed {
+			return proto.NewInteger(1)
+		}
+		return proto.NewInteger(0)
+	}
+
+	if len(args) < 2 {
+		return wrongArgsError("BOLTREON.SCHEDULE")
+	}
+	if !isIntegerArg(args[0]) {
+		return proto.NewError(errNotInteger)
+	}
+	at, _ := strconv.ParseInt(string(args[0]), 10, 64)
+	if at < 0 {
+		return proto.NewError("ERR invalid unix time")
+	}
+	cmd := strings.ToUpper(string(args[1]))
+	if scheduleRejectedCommands[cmd] {
+		return proto.NewError("ERR '" + strings.ToLower(cmd) + "' cannot be scheduled")
+	}
+	// 参数个数错误在登记时就返回，其余错误在执行时写入结果 Stream
+	if resp := checkArity(cmd, args[2:]); resp != nil {
+		return resp
+	}
+	cmdArgs := make([]string, len(args)-1)
+	for i, a := range args[1:] {
+		cmdArgs[i] = string(a)
+	}
+	id, err := h.Db.Schedule(at, cmdArgs)
+	if err != nil {
+		return proto.NewError(fmt.Sprintf("ERR %v", err))
+	}
+	return proto.NewBulkString([]byte(id))
+}
+
+// RunScheduler 每隔 interval 采样每秒操作数、执行到期的定时命令和一轮冷热分层，直到 stop 关闭；
+// 主动过期由 RunExpireSweeper 按独立的间隔执行。
+// 从节点不执行定时命令：主节点执行后按普通写命令复制到从节点
+func (h *Handler) RunScheduler(interval time.Duration, stop <-chan struct{}) {
+	if interval <= 0 {
+		interval = DefaultScheduleInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			h.root().stats.Sample(now)
+			h.runDueScheduled()
+			h.runTierCycle()
+		}
+	}
+}
+
+// runDueScheduled 执行所有已到期的定时命令，返回执行的条数
+func (h *Handler) runDueScheduled() int {
+	if h.Replication != nil && !h.Replication.IsMaster() {
+		return 0
+	}
+	ran := 0
+	for {
+		cmds, err := h.Db.TakeDueScheduled(scheduleBatchSize)
+		if err != nil {
+			logger.Logger.Error().Err(err).Msg("读取到期的定时命令失败")
+			return ran
+		}
+		for _, c := range cmds {
+			h.runScheduled(c)
+			ran++
+		}
+		if len(cmds) < scheduleBatchSize {
+			return ran
+		}
+	}
+}
+
+// runScheduled 执行一条定时命令，并将结果写入 ScheduleResultsStream
+func (h *Handler) runScheduled(c store.ScheduledCommand) {
+	// 使用独立的 Handler，避免与客户端连接共享事务状态
+	exec := h.newConnection()
+	req := &proto.Array{Args: make([][]byte, len(c.Args))}
+	for i, a := range c.Args {
+		req.Args[i] = []byte(a)
+	}
+	resp := exec.processRequest(req, nil, scheduleRemoteAddr, nil, nil)
+
+	ok := "1"
+	reply := ""
+	if resp != nil {
+		reply = resp.String()
+		if _, isErr := resp.(*proto.Error); isErr {
+			ok = "0"
+		}
+	}
+	fields := map[string]string{
+		"id":        c.ID,
+		"command":   strings.Join(c.Args, " "),
+		"scheduled": strconv.FormatInt(c.At, 10),
+		"executed":  strconv.FormatInt(h.Db.Clock().Now().UnixMilli(), 10),
+		"ok":        ok,
+		"reply":     reply,
+	}
+	endAOF := h.beginAOF("XADD", nil)
+	defer endAOF()
+	id, err := h.Db.XAdd(ScheduleResultsStream, store.StreamXAddOptions{
+		Trim: store.StreamTrimOptions{Strategy: store.StreamTrimMaxLen, MaxLen: scheduleResultsMaxLen},
+	}, "*", fields)
+	if err != nil {
+		logger.Logger.Error().Err(err).Str("id", c.ID).Msg("写入定时命令结果失败")
+		return
+	}
+	// 以确定的 ID 复制结果并写入 AOF，从节点上与重放后的结果 Stream 与主节点一致
+	xadd := [][]byte{[]byte("XADD"), []byte(ScheduleResultsStream), []byte("MAXLEN"),
+		[]byte(strconv.Itoa(scheduleResultsMaxLen)), []byte(id)}
+	for _, name := range []string{"id", "command", "scheduled", "executed", "ok", "reply"} {
+		xadd = append(xadd, []byte(name), []byte(fields[name]))
+	}
+	if h.Replication != nil && h.Replication.IsMaster() {
+		h.Replication.PropagateCommand(xadd)
+	}
+	h.appendAOF(xadd)
+}
@@ -0,0 +1,402 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1" // #nosec G505 - 与 Redis 相同，脚本以 SHA1 摘要标识，不用于安全目的
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/proto"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// DefaultScriptTimeout 单个脚本的最长执行时间，超时的脚本被终止并返回错误（已执行的写命令不回滚）
+const DefaultScriptTimeout = 5 * time.Second
+
+// scriptForbiddenCommands 不能在脚本中通过 redis.call 执行的命令
+var scriptForbiddenCommands = map[string]bool{
+	"EVAL": true, "EVALSHA": true, "SCRIPT": true,
+	"MULTI": true, "EXEC": true, "DISCARD": true, "WATCH": true, "UNWATCH": true,
+	"HELLO": true, "AUTH": true, "QUIT": true, "SHUTDOWN": true, "REPLICAOF": true, "SLAVEOF": true,
+}
+
+// scriptRegistry 服务器级的脚本缓存（SCRIPT LOAD / EVAL 加载，SCRIPT FLUSH 清空）。
+// 脚本与 EXEC 一样持有独占的隔离锁执行（见 lockCommand）：脚本中的读写不会与任何其他命令交错，
+// 因此可以用脚本实现检查再写入
+type scriptRegistry struct {
+	mu      sync.Mutex
+	scripts map[string]*lua.FunctionProto
+}
+
+// scriptSHA 脚本内容的 SHA1 十六进制摘要
+func scriptSHA(body []byte) string {
+	sum := sha1.Sum(body) // #nosec G401
+	return hex.EncodeToString(sum[:])
+}
+
+// loadScript 编译脚本并加入缓存，返回摘要
+func (r *scriptRegistry) loadScript(body []byte) (string, *lua.FunctionProto, error) {
+	sha := scriptSHA(body)
+	r.mu.Lock()
+	fn, ok := r.scripts[sha]
+	r.mu.Unlock()
+	if ok {
+		return sha, fn, nil
+	}
+	chunk, err := parse.Parse(strings.NewReader(string(body)), "user_script")
+	if err != nil {
+		return "", nil, err
+	}
+	fn, err = lua.Compile(chunk, "user_script")
+	if err != nil {
+		return "", nil, err
+	}
+	r.mu.Lock()
+	if r.scripts == nil {
+		r.scripts = make(map[string]*lua.FunctionProto)
+	}
+	r.scripts[sha] = fn
+	r.mu.Unlock()
+	return sha, fn, nil
+}
+
+func (r *scriptRegistry) lookup(sha string) (*lua.FunctionProto, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn, ok := r.scripts[strings.ToLower(sha)]
+	return fn, ok
+}
+
+// handleEval 处理 EVAL script numkeys [key ...] [arg ...] 与 EVALSHA sha1 numkeys ...，
+// 调用方持有独占的隔离锁
+func (h *Handler) handleEval(cmd string, args [][]byte, remoteAddr string) proto.RESP {
+	numKeys, err := strconv.Atoi(string(args[1]))
+	if err != nil {
+		return proto.NewError(errNotInteger)
+	}
+	if numKeys < 0 {
+		return proto.NewError("ERR Number of keys can't be negative")
+	}
+	if numKeys > len(args)-2 {
+		return proto.NewError("ERR Number of keys can't be greater than number of args")
+	}
+	keys, argv := args[2:2+numKeys], args[2+numKeys:]
+
+	registry := &h.root().scripts
+	var fn *lua.FunctionProto
+	if cmd == "EVALSHA" {
+		var ok bool
+		if fn, ok = registry.lookup(string(args[0])); !ok {
+			return proto.NewError("NOSCRIPT No matching script. Please use EVAL.")
+		}
+	} else {
+		if _, fn, err = registry.loadScript(args[0]); err != nil {
+			return proto.NewError(fmt.Sprintf("ERR Error compiling script (new function): %v", err))
+		}
+	}
+
+	return h.runScript(fn, keys, argv, remoteAddr)
+}
+
+// handleScript 处理 SCRIPT LOAD|EXISTS|FLUSH
+func (h *Handler) handleScript(args [][]byte) proto.RESP {
+	registry := &h.root().scripts
+	sub := strings.ToUpper(string(args[0]))
+	switch sub {
+	case "LOAD":
+		if len(args) != 2 {
+			return wrongArgsError("SCRIPT LOAD")
+		}
+		sha, _, err := registry.loadScript(args[1])
+		if err != nil {
+			return proto.NewError(fmt.Sprintf("ERR Error compiling script (new function): %v", err))
+		}
+		return proto.NewBulkString([]byte(sha))
+	case "EXISTS":
+		if len(args) < 2 {
+			return wrongArgsError("SCRIPT EXISTS")
+		}
+		elems := make([]proto.RESP, len(args)-1)
+		for i, sha := range args[1:] {
+			exists := int64(0)
+			if _, ok := registry.lookup(string(sha)); ok {
+				exists = 1
+			}
+			elems[i] = proto.NewInteger(exists)
+		}
+		return &proto.NestedArray{Elems: elems}
+	case "FLUSH":
+		// SCRIPT FLUSH [ASYNC|SYNC]：两种方式都立即清空
+		if len(args) > 2 {
+			return wrongArgsError("SCRIPT FLUSH")
+		}
+		if len(args) == 2 {
+			if mode := strings.ToUpper(string(args[1])); mode != "ASYNC" && mode != "SYNC" {
+				return proto.NewError("ERR SCRIPT FLUSH only support SYNC|ASYNC option")
+			}
+		}
+		registry.mu.Lock()
+		registry.scripts = nil
+		registry.mu.Unlock()
+		return proto.OK
+	}
+	return proto.NewError(fmt.Sprintf("ERR unknown subcommand '%s'", string(args[0])))
+}
+
+// runScript 在新的 Lua 状态中执行脚本，KEYS 与 ARGV 作为全局表传入
+func (h *Handler) runScript(fn *lua.FunctionProto, keys, argv [][]byte, remoteAddr string) proto.RESP {
+	L := newScriptState()
+	defer L.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultScriptTimeout)
+	defer cancel()
+	L.SetContext(ctx)
+
+	L.SetGlobal("KEYS", stringsTable(L, keys))
+	L.SetGlobal("ARGV", stringsTable(L, argv))
+	L.SetGlobal("redis", h.redisLib(L, remoteAddr))
+
+	L.Push(L.NewFunctionFromProto(fn))
+	if err := L.PCall(0, 1, nil); err != nil {
+		if ctx.Err() != nil {
+			return proto.NewError("ERR Script killed by timeout")
+		}
+		var apiErr *lua.ApiError
+		if errors.As(err, &apiErr) {
+			// redis.call 失败或脚本 error(redis.error_reply(...)) 时原样返回错误
+			if t, ok := apiErr.Object.(*lua.LTable); ok {
+				if msg, ok := t.RawGetString("err").(lua.LString); ok {
+					return proto.NewError(string(msg))
+				}
+			}
+			return proto.NewError(fmt.Sprintf("ERR Error running script: %s", apiErr.Object.String()))
+		}
+		return proto.NewError(fmt.Sprintf("ERR Error running script: %v", err))
+	}
+	return luaToRESP(L.Get(-1))
+}
+
+// newScriptState 只打开 base/table/string/math 库，并移除可以读取文件的函数
+func newScriptState() *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "print", "module", "require"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+	return L
+}
+
+// redisLib 脚本中的 redis 表
+func (h *Handler) redisLib(L *lua.LState, remoteAddr string) *lua.LTable {
+	lib := L.NewTable()
+	lib.RawSetString("call", L.NewFunction(func(L *lua.LState) int {
+		return h.scriptCall(L, remoteAddr, true)
+	}))
+	lib.RawSetString("pcall", L.NewFunction(func(L *lua.LState) int {
+		return h.scriptCall(L, remoteAddr, false)
+	}))
+	lib.RawSetString("status_reply", L.NewFunction(func(L *lua.LState) int {
+		t := L.NewTable()
+		t.RawSetString("ok", lua.LString(L.CheckString(1)))
+		L.Push(t)
+		return 1
+	}))
+	lib.RawSetString("error_reply", L.NewFunction(func(L *lua.LState) int {
+		L.Push(errorTable(L, L.CheckString(1)))
+		return 1
+	}))
+	lib.RawSetString("sha1hex", L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LString(scriptSHA([]byte(L.CheckString(1)))))
+		return 1
+	}))
+	lib.RawSetString("log", L.NewFunction(func(L *lua.LState) int {
+		level := L.CheckInt(1)
+		parts := make([]string, 0, L.GetTop()-1)
+		for i := 2; i <= L.GetTop(); i++ {
+			parts = append(parts, L.ToStringMeta(L.Get(i)).String())
+		}
+		msg := strings.Join(parts, " ")
+		switch level {
+		case scriptLogDebug, scriptLogVerbose:
+			logger.Logger.Debug().Str("source", "script").Msg(msg)
+		case scriptLogNotice:
+			logger.Logger.Info().Str("source", "script").Msg(msg)
+		default:
+			logger.Logger.Warn().Str("source", "script").Msg(msg)
+		}
+		return 0
+	}))
+	lib.RawSetString("LOG_DEBUG", lua.LNumber(scriptLogDebug))
+	lib.RawSetString("LOG_VERBOSE", lua.LNumber(scriptLogVerbose))
+	lib.RawSetString("LOG_NOTICE", lua.LNumber(scriptLogNotice))
+	lib.RawSetString("LOG_WARNING", lua.LNumber(scriptLogWarning))
+	return lib
+}
+
+// redis.log 的级别，与 Redis 相同
+const (
+	scriptLogDebug = iota
+	scriptLogVerbose
+	scriptLogNotice
+	scriptLogWarning
+)
+
+// scriptCall 实现 redis.call（raise 为 true，出错时终止脚本）与 redis.pcall（返回错误表）
+func (h *Handler) scriptCall(L *lua.LState, remoteAddr string, raise bool) int {
+	fail := func(msg string) int {
+		if raise {
+			L.Error(errorTable(L, msg), 1)
+			return 0
+		}
+		L.Push(errorTable(L, msg))
+		return 1
+	}
+	n := L.GetTop()
+	if n == 0 {
+		return fail("ERR Please specify at least one argument for this redis lib call")
+	}
+	args := make([][]byte, n)
+	for i := 1; i <= n; i++ {
+		switch v := L.Get(i).(type) {
+		case lua.LString:
+			args[i-1] = []byte(v)
+		case lua.LNumber:
+			args[i-1] = []byte(v.String())
+		default:
+			return fail("ERR Lua redis lib command arguments must be strings or integers")
+		}
+	}
+
+	resp := h.scriptCommand(strings.ToUpper(string(args[0])), args[1:], remoteAddr)
+	if e, ok := resp.(*proto.Error); ok {
+		return fail(string(*e))
+	}
+	L.Push(respToLua(L, resp))
+	return 1
+}
+
+// scriptCommand 执行脚本中的一条命令。写命令逐条传播到从节点与镜像并写入 AOF，
+// 从节点执行的是脚本的效果而不是脚本本身，结果与主节点一致
+func (h *Handler) scriptCommand(cmd string, args [][]byte, remoteAddr string) proto.RESP {
+	if scriptForbiddenCommands[cmd] || txForbiddenCommands[cmd] {
+		return proto.NewError("ERR This Redis command is not allowed from script")
+	}
+	if resp := checkArity(cmd, args); resp != nil {
+		return resp
+	}
+	if resp := h.checkReadOnlyReplica(cmd, args); resp != nil {
+		return resp
+	}
+	args = nonBlockingArgs(cmd, args)
+	h.selectDBKeys(cmd, args)
+	resp := h.runCommand(cmd, args, remoteAddr)
+	if resp == nil {
+		return proto.NewError("ERR internal error")
+	}
+	cmdArgs := append([][]byte{[]byte(cmd)}, args...)
+	h.propagateWrite(cmd, cmdArgs, resp)
+	h.mirrorWrite(cmd, cmdArgs, resp)
+	h.feedAOF(cmd, args, resp)
+	return h.dbReply(cmd, args, resp)
+}
+
+func errorTable(L *lua.LState, msg string) *lua.LTable {
+	t := L.NewTable()
+	t.RawSetString("err", lua.LString(msg))
+	return t
+}
+
+func stringsTable(L *lua.LState, items [][]byte) *lua.LTable {
+	t := L.CreateTable(len(items), 0)
+	for _, item := range items {
+		t.Append(lua.LString(item))
+	}
+	return t
+}
+
+// respToLua 按 Redis 的规则转换命令回复：整数为数字，bulk string 为字符串，空值为 false，
+// 数组为表，状态回复为 {ok=...}，错误为 {err=...}
+func respToLua(L *lua.LState, r proto.RESP) lua.LValue {
+	switch v := r.(type) {
+	case *proto.Integer:
+		return lua.LNumber(*v)
+	case proto.Integer:
+		return lua.LNumber(v)
+	case *proto.BulkString:
+		if v == nil || *v == nil {
+			return lua.LFalse
+		}
+		return lua.LString(*v)
+	case *proto.SimpleString:
+		t := L.NewTable()
+		t.RawSetString("ok", lua.LString(*v))
+		return t
+	case *proto.Error:
+		return errorTable(L, string(*v))
+	case proto.RawString:
+		parsed, err := proto.ReadReply(bufio.NewReader(strings.NewReader(string(v))))
+		if err != nil {
+			return lua.LFalse
+		}
+		return respToLua(L, parsed)
+	}
+	if elems, ok := proto.Elems(r); ok {
+		t := L.CreateTable(len(elems), 0)
+		for _, e := range elems {
+			t.Append(respToLua(L, e))
+		}
+		return t
+	}
+	return lua.LFalse
+}
+
+// luaToRESP 按 Redis 的规则转换脚本返回值：数字截断为整数，true 为 1，false 与 nil 为空值，
+// 表按数组转换到第一个 nil 为止，{ok=...} 为状态回复，{err=...} 为错误
+func luaToRESP(lv lua.LValue) proto.RESP {
+	switch v := lv.(type) {
+	case lua.LNumber:
+		return proto.NewInteger(int64(v))
+	case lua.LString:
+		return proto.NewBulkString([]byte(v))
+	case lua.LBool:
+		if v {
+			return proto.NewInteger(1)
+		}
+	case *lua.LTable:
+		if ok, isStr := v.RawGetString("ok").(lua.LString); isStr {
+			return proto.NewSimpleString(string(ok))
+		}
+		if msg, isStr := v.RawGetString("err").(lua.LString); isStr {
+			return proto.NewError(string(msg))
+		}
+		elems := make([]proto.RESP, 0, v.Len())
+		for i := 1; ; i++ {
+			e := v.RawGetInt(i)
+			if e == lua.LNil {
+				break
+			}
+			elems = append(elems, luaToRESP(e))
+		}
+		return &proto.NestedArray{Elems: elems}
+	}
+	return proto.NewBulkString(nil)
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// searchDefaultLimit FT.SEARCH 没有 LIMIT 时返回的文档数，与 RediSearch 相同
+const searchDefaultLimit = 10
+
+// handleFTCreate FT.CREATE index [ON HASH|JSON] [PREFIX count prefix ...]
+// SCHEMA field [AS name] TEXT|NUMERIC|TAG [SEPARATOR sep] [SORTABLE] ...
+func (h *Handler) handleFTCreate(args [][]byte) proto.RESP {
+	def, errResp := parseFTCreate(args)
+	if errResp != nil {
+		return errResp
+	}
+	if err := h.Db.CreateSearchIndex(def); err != nil {
+		return proto.NewError(fmt.Sprintf("ERR %v", err))
+	}
+	return proto.OK
+}
+
+func parseFTCreate(args [][]byte) (store.SearchIndex, proto.RESP) {
+	def := store.SearchIndex{Name: string(args[0]), On: store.KeyTypeHash}
+	i := 1
+	for ; i < len(args); i++ {
+		opt := strings.ToUpper(string(args[i]))
+		if opt == "SCHEMA" {
+			i++
+			break
+		}
+		switch {
+		case opt == "ON" && i+1 < len(args):
+			i++
+			switch strings.ToUpper(string(args[i])) {
+			case "HASH":
+				def.On = store.KeyTypeHash
+			case "JSON":
+				def.On = store.KeyTypeJSON
+			default:
+				return def, proto.NewError(fmt.Sprintf("ERR unsupported index type '%s'", args[i]))
+			}
+		case opt == "PREFIX" && i+1 < len(args):
+			n, err := strconv.Atoi(string(args[i+1]))
+			if err != nil || n < 0 || i+1+n >= len(args) {
+				return def, proto.NewError("ERR invalid PREFIX count")
+			}
+			for _, p := range args[i+2 : i+2+n] {
+				def.Prefixes = append(def.Prefixes, string(p))
+			}
+			i += 1 + n
+		default:
+			return def, proto.NewError(fmt.Sprintf("ERR unsupported FT.CREATE option '%s'", args[i]))
+		}
+	}
+
+	schema := args[i:]
+	for j := 0; j < len(schema); {
+		f := store.SearchField{Path: string(schema[j])}
+		j++
+		if j+1 < len(schema) && strings.EqualFold(string(schema[j]), "AS") {
+			f.Name = string(schema[j+1])
+			j += 2
+		}
+		if j >= len(schema) {
+			return def, proto.NewError(fmt.Sprintf("ERR missing type for field '%s'", f.Path))
+		}
+		f.Type = strings.ToUpper(string(schema[j]))
+		j++
+		// 字段选项：SORTABLE 与 SEPARATOR 生效，NOSTEM、UNF、WEIGHT 不影响不计分的查询，接受后忽略
+	options:
+		for j < len(schema) {
+			switch strings.ToUpper(string(schema[j])) {
+			case "SORTABLE":
+				f.Sortable = true
+			case "NOSTEM", "UNF":
+			case "WEIGHT":
+				j++
+			case "SEPARATOR":
+				if j+1 >= len(schema) || len(schema[j+1]) != 1 {
+					return def, proto.NewError("ERR SEPARATOR must be a single character")
+				}
+				f.Separator = string(schema[j+1])
+				j++
+			default:
+				break options
+			}
+			j++
+		}
+		def.Fields = append(def.Fields, f)
+	}
+	if len(def.Fields) == 0 {
+		return def, proto.NewError("ERR schema must contain at least one field")
+	}
+	return def, nil
+}
+
+// searchCreateArgs 重新生成创建索引的 FT.CREATE 参数（不含命令名），用于 AOF 重写
+func searchCreateArgs(def store.SearchIndex) [][]byte {
+	args := [][]byte{[]byte(def.Name), []byte("ON"), []byte(def.On)}
+	if len(def.Prefixes) > 0 {
+		args = append(args, []byte("PREFIX"), []byte(strconv.Itoa(len(def.Prefixes))))
+		for _, p := range def.Prefixes {
+			args = append(args, []byte(p))
+		}
+	}
+	args = append(args, []byte("SCHEMA"))
+	for _, f := range def.Fields {
+		args = append(args, []byte(f.Path), []byte("AS"), []byte(f.Name), []byte(f.Type))
+		if f.Type == store.SearchFieldTag {
+			args = append(args, []byte("SEPARATOR"), []byte(f.Separator))
+		}
+		if f.Sortable {
+			args = append(args, []byte("SORTABLE"))
+		}
+	}
+	return args
+}
+
+// handleFTSearch FT.SEARCH index query [NOCONTENT] [RETURN count field [AS name] ...]
+// [SORTBY field [ASC|DESC]] [LIMIT offset num]
+func (h *Handler) handleFTSearch(args [][]byte) proto.RESP {
+	name, query := string(args[0]), string(args[1])
+	opts := store.SearchOptions{Limit: searchDefaultLimit}
+	aliases := make(map[string]string)
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NOCONTENT":
+			opts.NoContent = true
+		case "VERBATIM", "NOSTOPWORDS":
+			// 不做词干提取，也没有停用词
+		case "DIALECT":
+			i++
+		case "RETURN":
+			if i+1 >= len(args) {
+				return proto.NewError(errSyntax)
+			}
+			n, err := strconv.Atoi(string(args[i+1]))
+			if err != nil || n < 0 || i+1+n >= len(args) {
+				return proto.NewError("ERR bad arguments for RETURN")
+			}
+			fields := args[i+2 : i+2+n]
+			opts.Return = []string{}
+			for j := 0; j < len(fields); j++ {
+				field := string(fields[j])
+				if j+2 < len(fields) && strings.EqualFold(string(fields[j+1]), "AS") {
+					aliases[field] = string(fields[j+2])
+					j += 2
+				}
+				opts.Return = append(opts.Return, field)
+			}
+			i += 1 + n
+		case "SORTBY":
+			if i+1 >= len(args) {
+				return proto.NewError(errSyntax)
+			}
+			opts.SortBy = string(args[i+1])
+			i++
+			if i+1 < len(args) {
+				switch strings.ToUpper(string(args[i+1])) {
+				case "ASC":
+					i++
+				case "DESC":
+					opts.Desc = true
+					i++
+				}
+			}
+		case "LIMIT":
+			if i+2 >= len(args) {
+				return proto.NewError(errSyntax)
+			}
+			offset, err1 := strconv.Atoi(string(args[i+1]))
+			limit, err2 := strconv.Atoi(string(args[i+2]))
+			if err1 != nil || err2 != nil || offset < 0 || limit < 0 {
+				return proto.NewError("ERR invalid LIMIT")
+			}
+			opts.Offset, opts.Limit = offset, limit
+			i += 2
+		default:
+			return proto.NewError(fmt.Sprintf("ERR unsupported FT.SEARCH option '%s'", args[i]))
+		}
+	}
+
+	result, err := h.Db.Search(name, query, opts)
+	if err != nil {
+		return proto.NewError(fmt.Sprintf("ERR %v", err))
+	}
+	elems := []proto.RESP{proto.NewInteger(result.Total)}
+	for _, doc := range result.Docs {
+		elems = append(elems, proto.NewBulkString([]byte(doc.Key)))
+		if opts.NoContent {
+			continue
+		}
+		fields := make([]proto.RESP, len(doc.Fields))
+		for i, f := range doc.Fields {
+			if alias, ok := aliases[f]; ok && i%2 == 0 {
+				f = alias
+			}
+			fields[i] = proto.NewBulkString([]byte(f))
+		}
+		elems = append(elems, &proto.NestedArray{Elems: fields})
+	}
+	return &proto.NestedArray{Elems: elems}
+}
+
+// handleFTInfo FT.INFO index：索引定义、字段与已索引的文档数
+func (h *Handler) handleFTInfo(args [][]byte) proto.RESP {
+	info, err := h.Db.SearchIndexInfo(string(args[0]))
+	if err != nil {
+		return proto.NewError(fmt.Sprintf("ERR %v", err))
+	}
+	bulk := func(s string) proto.RESP { return proto.NewBulkString([]byte(s)) }
+	prefixes := make([]proto.RESP, len(info.Prefixes))
+	for i, p := range info.Prefixes {
+		prefixes[i] = bulk(p)
+	}
+	attributes := make([]proto.RESP, len(info.Fields))
+	for i, f := range info.Fields {
+		attr := []proto.RESP{bulk("identifier"), bulk(f.Path), bulk("attribute"), bulk(f.Name), bulk("type"), bulk(f.Type)}
+		if f.Type == store.SearchFieldTag {
+			attr = append(attr, bulk("SEPARATOR"), bulk(f.Separator))
+		}
+		if f.Sortable {
+			attr = append(attr, bulk("SORTABLE"))
+		}
+		attributes[i] = &proto.NestedArray{Elems: attr}
+	}
+	indexing, percent := int64(0), "1"
+	if info.Indexing {
+		indexing, percent = 1, "0"
+	}
+	return &proto.NestedArray{Elems: []proto.RESP{
+		bulk("index_name"), bulk(info.Name),
+		bulk("index_options"), &proto.NestedArray{Elems: []proto.RESP{}},
+		bulk("index_definition"), &proto.NestedArray{Elems: []proto.RESP{
+			bulk("key_type"), bulk(info.On),
+			bulk("prefixes"), &proto.NestedArray{Elems: prefixes},
+		}},
+		bulk("attributes"), &proto.NestedArray{Elems: attributes},
+		bulk("num_docs"), proto.NewInteger(info.NumDocs),
+		bulk("indexing"), proto.NewInteger(indexing),
+		bulk("percent_indexed"), bulk(percent),
+	}}
+}
+
+// handleFTDropIndex FT.DROPINDEX index [DD]
+func (h *Handler) handleFTDropIndex(args [][]byte) proto.RESP {
+	deleteDocs := false
+	switch {
+	case len(args) == 2 && strings.EqualFold(string(args[1]), "DD"):
+		deleteDocs = true
+	case len(args) != 1:
+		return proto.NewError(errSyntax)
+	}
+	if err := h.Db.DropSearchIndex(string(args[0]), deleteDocs); err != nil {
+		return proto.NewError(fmt.Sprintf("ERR %v", err))
+	}
+	return proto.OK
+}
+
+// handleFTList FT._LIST：所有索引的名称
+func (h *Handler) handleFTList() proto.RESP {
+	names := h.Db.SearchIndexNames()
+	elems := make([][]byte, len(names))
+	for i, name := range names {
+		elems[i] = []byte(name)
+	}
+	return &proto.Array{Args: elems}
+}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// shadowLogInterval 同一命令的不一致最多每隔这段时间记录一条日志，其间的条数计入下一条日志
+const shadowLogInterval = time.Second
+
+// ShadowImpl 读命令的候选实现（如重新设计的键布局），用于在上线前与当前实现比较结果
+type ShadowImpl struct {
+	// Run 以候选实现执行命令，args 不含命令名；不能修改数据
+	Run func(h *Handler, args [][]byte) proto.RESP
+	// Unordered 回复是元素顺序不确定的数组（SMEMBERS、HKEYS 等），比较时忽略顺序
+	Unordered bool
+}
+
+var (
+	shadowImplsMu sync.RWMutex
+	shadowImpls   = map[string]ShadowImpl{}
+)
+
+// RegisterShadow 登记读命令 cmd 的候选实现，通常在新实现的 init 中调用。
+// 写命令不能影子执行（会写两次），登记时 panic
+func RegisterShadow(cmd string, impl ShadowImpl) {
+	cmd = strings.ToUpper(cmd)
+	if isWriteCommand(cmd) {
+		panic(fmt.Sprintf("server: cannot shadow write command %s", cmd))
+	}
+	if impl.Run == nil {
+		panic(fmt.Sprintf("server: nil shadow implementation for %s", cmd))
+	}
+	shadowImplsMu.Lock()
+	defer shadowImplsMu.Unlock()
+	shadowImpls[cmd] = impl
+}
+
+func lookupShadow(cmd string) (ShadowImpl, bool) {
+	shadowImplsMu.RLock()
+	defer shadowImplsMu.RUnlock()
+	impl, ok := shadowImpls[cmd]
+	return impl, ok
+}
+
+// shadowState 影子读取的抽样比例与统计（只保存在服务器级）
+type shadowState struct {
+	// permille 抽样比例（百万分之一），0 表示关闭
+	permille atomic.Int64
+
+	mu    sync.Mutex
+	stats map[string]*shadowCommandStats
+}
+
+type shadowCommandStats struct {
+	sampled    int64
+	mismatches int64
+	errors     int64
+	primary    time.Duration
+	shadow     time.Duration
+	lastLog    time.Time
+	suppressed int64
+}
+
+// SetShadowPercent 设置读命令影子执行的比例（0-100），0 关闭
+func (h *Handler) SetShadowPercent(percent float64) error {
+	if percent < 0 || percent > 100 || percent != percent {
+		return fmt.Errorf("shadow percent must be between 0 and 100")
+	}
+	h.root().shadow.permille.Store(int64(percent * 10000))
+	return nil
+}
+
+// shadowPercent 当前的影子执行比例
+func (h *Handler) shadowPercent() float64 {
+	return float64(h.root().shadow.permille.Load()) / 10000
+}
+
+// shadowRead 按抽样比例用候选实现再执行一次读命令，与已经得到的回复比较，不一致时记录日志。
+// 客户端始终收到当前实现的回复；候选实现 panic 时计为错误
+func (h *Handler) shadowRead(cmd string, args [][]byte, resp proto.RESP, primary time.Duration) {
+	st := &h.root().shadow
+	permille := st.permille.Load()
+	if permille <= 0 || resp == nil {
+		return
+	}
+	impl, ok := lookupShadow(cmd)
+	if !ok || rand.Int64N(1000000) >= permille {
+		return
+	}
+
+	start := time.Now()
+	shadowResp, panicked := runShadow(h, impl, args)
+	elapsed := time.Since(start)
+	match := !panicked && shadowReplyEqual(resp, shadowResp, impl.Unordered)
+
+	st.mu.Lock()
+	if st.stats == nil {
+		st.stats = make(map[string]*shadowCommandStats)
+	}
+	cs := st.stats[cmd]
+	if cs == nil {
+		cs = &shadowCommandStats{}
+		st.stats[cmd] = cs
+	}
+	cs.sampled++
+	cs.primary += primary
+	cs.shadow += elapsed
+	if panicked {
+		cs.errors++
+	} else if !match {
+		cs.mismatches++
+	}
+	logNow := false
+	var suppressed int64
+	if !match {
+		if now := time.Now(); now.Sub(cs.lastLog) >= shadowLogInterval {
+			logNow, suppressed = true, cs.suppressed
+			cs.lastLog, cs.suppressed = now, 0
+		} else {
+			cs.suppressed++
+		}
+	}
+	st.mu.Unlock()
+
+	if !logNow {
+		return
+	}
+	shadowText := "<panic>"
+	if !panicked {
+		shadowText = truncateArg(shadowResp.String(), 256)
+	}
+	logger.Logger.Warn().
+		Str("command", cmd).
+		Str("args", truncateArg(string(joinArgs(args)), 256)).
+		Str("primary", truncateArg(resp.String(), 256)).
+		Str("shadow", shadowText).
+		Int64("suppressed", suppressed).
+		Msg("影子读取结果与当前实现不一致")
+}
+
+func runShadow(h *Handler, impl ShadowImpl, args [][]byte) (resp proto.RESP, panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Logger.Error().Interface("panic", r).Msg("影子读取的候选实现 panic")
+			resp, panicked = nil, true
+		}
+	}()
+	resp = impl.Run(h, args)
+	return resp, resp == nil
+}
+
+// shadowReplyEqual 比较两个回复的 RESP 编码，unordered 时数组元素排序后再比较
+func shadowReplyEqual(a, b proto.RESP, unordered bool) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if unordered {
+		if ea, ok := shadowArrayElems(a); ok {
+			if eb, ok := shadowArrayElems(b); ok {
+				sort.Strings(ea)
+				sort.Strings(eb)
+				return strings.Join(ea, "") == strings.Join(eb, "")
+			}
+		}
+	}
+	return a.String() == b.String()
+}
+
+// shadowArrayElems 数组回复中每个元素的 RESP 编码，不是数组时返回 false
+func shadowArrayElems(r proto.RESP) ([]string, bool) {
+	switch v := r.(type) {
+	case *proto.Array:
+		elems := make([]string, len(v.Args))
+		for i, arg := range v.Args {
+			elems[i] = proto.NewBulkString(arg).String()
+		}
+		return elems, true
+	case *proto.NestedArray:
+		elems := make([]string, len(v.Elems))
+		for i, e := range v.Elems {
+			elems[i] = e.String()
+		}
+		return elems, true
+	}
+	return nil, false
+}
+
+func joinArgs(args [][]byte) []byte {
+	var b []byte
+	for i, a := range args {
+		if i > 0 {
+			b = append(b, ' ')
+		}
+		b = append(b, a...)
+	}
+	return b
+}
+
+// handleShadow 处理 BOLTREON.SHADOW [STATUS|RESET|PERCENT percent]
+func (h *Handler) handleShadow(args [][]byte) proto.RESP {
+	sub := "STATUS"
+	if len(args) > 0 {
+		sub = strings.ToUpper(string(args[0]))
+	}
+	st := &h.root().shadow
+	switch {
+	case sub == "PERCENT" && len(args) == 2:
+		percent, err := strconv.ParseFloat(string(args[1]), 64)
+		if err != nil {
+			return proto.NewError("ERR value is not a valid float")
+		}
+		if err := h.SetShadowPercent(percent); err != nil {
+			return proto.NewError(fmt.Sprintf("ERR %v", err))
+		}
+		return proto.OK
+	case sub == "RESET" && len(args) == 1:
+		st.mu.Lock()
+		st.stats = nil
+		st.mu.Unlock()
+		return proto.OK
+	case sub == "STATUS" && len(args) <= 1:
+	case sub == "PERCENT" || sub == "RESET" || sub == "STATUS":
+		return proto.NewError(fmt.Sprintf("ERR wrong number of arguments for 'boltreon.shadow|%s' command", strings.ToLower(sub)))
+	default:
+		return proto.NewError(fmt.Sprintf("ERR unknown subcommand '%s'", string(args[0])))
+	}
+
+	shadowImplsMu.RLock()
+	registered := make([]string, 0, len(shadowImpls))
+	for cmd := range shadowImpls {
+		registered = append(registered, cmd)
+	}
+	shadowImplsMu.RUnlock()
+	sort.Strings(registered)
+
+	var b strings.Builder
+	b.WriteString("# Shadow\n")
+	b.WriteString(fmt.Sprintf("percent:%s\n", strconv.FormatFloat(h.shadowPercent(), 'f', -1, 64)))
+	b.WriteString(fmt.Sprintf("registered:%s\n", strings.ToLower(strings.Join(registered, ","))))
+	st.mu.Lock()
+	cmds := make([]string, 0, len(st.stats))
+	for cmd := range st.stats {
+		cmds = append(cmds, cmd)
+	}
+	sort.Strings(cmds)
+	for _, cmd := range cmds {
+		cs := st.stats[cmd]
+		b.WriteString(fmt.Sprintf("shadow_%s:sampled=%d,mismatches=%d,errors=%d,primary_usec=%d,shadow_usec=%d\n",
+			strings.ToLower(cmd), cs.sampled, cs.mismatches, cs.errors, cs.primary.Microseconds(), cs.shadow.Microseconds()))
+	}
+	st.mu.Unlock()
+	return proto.NewBulkString([]byte(b.String()))
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// shutdownState SHUTDOWN 命令发出的关闭请求（只保存在服务器级）
+type shutdownState struct {
+	once sync.Once
+	ch   chan struct{}
+}
+
+func (s *shutdownState) requests() chan struct{} {
+	s.once.Do(func() { s.ch = make(chan struct{}, 1) })
+	return s.ch
+}
+
+// ShutdownRequested 客户端执行 SHUTDOWN 后返回的通道可读。服务器进程据此调用 Shutdown 并退出；
+// 嵌入使用时没有接收者，SHUTDOWN 只关闭发出命令的连接
+func (h *Handler) ShutdownRequested() <-chan struct{} {
+	return h.root().shutdown.requests()
+}
+
+// handleShutdown SHUTDOWN [NOSAVE|SAVE] [NOW] [FORCE] [ABORT]。
+// 没有配置 save（不自动保存）时只在 SAVE 时写入 RDB，写入失败时不关闭，除非指定 FORCE。
+// 与 Redis 相同，成功时不回复，直接关闭连接
+func (h *Handler) handleShutdown(args [][]byte) proto.RESP {
+	var save, noSave, force bool
+	for _, arg := range args {
+		switch strings.ToUpper(string(arg)) {
+		case "SAVE":
+			save = true
+		case "NOSAVE":
+			noSave = true
+		case "NOW":
+			// 不等待从节点追上复制偏移量；关闭时本来就不等待
+		case "FORCE":
+			force = true
+		case "ABORT":
+			if len(args) > 1 {
+				return proto.NewError(errSyntax)
+			}
+			return proto.NewError("ERR No shutdown in progress.")
+		default:
+			return proto.NewError(errSyntax)
+		}
+	}
+	if save && noSave {
+		return proto.NewError(errSyntax)
+	}
+	if save {
+		if err := h.saveBeforeShutdown(); err != nil {
+			logger.Logger.Error().Err(err).Msg("SHUTDOWN 前保存 RDB 失败")
+			if !force {
+				return proto.NewError("ERR Errors trying to SHUTDOWN. Check logs.")
+			}
+		}
+	}
+	logger.Logger.Warn().Bool("save", save).Msg("收到 SHUTDOWN 命令")
+	select {
+	case h.root().shutdown.requests() <- struct{}{}:
+	default:
+	}
+	h.closeAfterReply = true
+	return proto.RawString("")
+}
+
+// saveBeforeShutdown 与 SAVE 相同地写入 RDB
+func (h *Handler) saveBeforeShutdown() error {
+	if h.Backup == nil {
+		return errors.New("backup not enabled")
+	}
+	changes := h.root().stats.Changes()
+	start := time.Now()
+	if err := h.Backup.Save(); err != nil {
+		return err
+	}
+	h.addLatencySample(latencyEventSnapshot, time.Since(start))
+	h.root().stats.Saved(changes)
+	return nil
+}
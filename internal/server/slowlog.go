@@ -0,0 +1,202 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+const (
+	// defaultSlowlogSlowerThan 默认记录执行超过 10ms 的命令，与 Redis 相同
+	defaultSlowlogSlowerThan = 10000
+	// defaultSlowlogMaxLen 默认最多保留的慢日志条数
+	defaultSlowlogMaxLen = 128
+	// slowlogMaxArgs 每条慢日志最多记录的参数个数（含命令名），其余参数合并为一条说明
+	slowlogMaxArgs = 32
+	// slowlogMaxArgLen 每个参数最多记录的字节数
+	slowlogMaxArgLen = 128
+)
+
+// slowlogSkipCommands 不记入慢日志的命令：EXEC 中的命令逐条记录，AUTH/HELLO 的参数含密码
+var slowlogSkipCommands = map[string]bool{
+	"EXEC": true, "AUTH": true, "HELLO": true,
+}
+
+// slowlogEntry 一条慢日志
+type slowlogEntry struct {
+	id       int64
+	time     int64 // 命令开始执行的 Unix 时间（秒）
+	duration int64 // 执行耗时（微秒）
+	args     [][]byte
+	addr     string
+	name     string
+}
+
+// slowLog 最近执行较慢的命令（只保存在服务器级）。
+// slowerThan 为负数时不记录，为 0 时记录所有命令；阈值与长度可用 CONFIG SET 调整
+type slowLog struct {
+	slowerThan atomic.Int64 // 微秒
+	maxLen     atomic.Int64
+	// configured 为 false 时 slowerThan 与 maxLen 使用默认值
+	configured atomic.Bool
+
+	mu      sync.Mutex
+	nextID  int64
+	entries []slowlogEntry // 最新的在前
+}
+
+// settings 返回当前的阈值（微秒）与最多保留的条数
+func (l *slowLog) settings() (slowerThan, maxLen int64) {
+	if !l.configured.Load() {
+		return defaultSlowlogSlowerThan, defaultSlowlogMaxLen
+	}
+	return l.slowerThan.Load(), l.maxLen.Load()
+}
+
+// configure 修改阈值或长度，另一项保持不变
+func (l *slowLog) configure(slowerThan, maxLen *int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cur, curLen := l.settings()
+	if slowerThan != nil {
+		cur = *slowerThan
+	}
+	if maxLen != nil {
+		curLen = *maxLen
+	}
+	l.slowerThan.Store(cur)
+	l.maxLen.Store(curLen)
+	l.configured.Store(true)
+	if int64(len(l.entries)) > curLen {
+		l.entries = l.entries[:curLen]
+	}
+}
+
+// add 超过阈值时记录一条慢日志，超出长度时丢弃最旧的条目
+func (l *slowLog) add(start time.Time, d time.Duration, args [][]byte, addr, name string) {
+	slowerThan, maxLen := l.settings()
+	if slowerThan < 0 || d.Microseconds() < slowerThan || maxLen == 0 {
+		return
+	}
+	entry := slowlogEntry{
+		time:     start.Unix(),
+		duration: d.Microseconds(),
+		args:     slowlogArgs(args),
+		addr:     addr,
+		name:     name,
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry.id = l.nextID
+	l.nextID++
+	if int64(len(l.entries)) >= maxLen {
+		l.entries = l.entries[:maxLen-1]
+	}
+	l.entries = append([]slowlogEntry{entry}, l.entries...)
+}
+
+// get 返回最新的 count 条，count 为负数时返回全部
+func (l *slowLog) get(count int) []slowlogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if count < 0 || count > len(l.entries) {
+		count = len(l.entries)
+	}
+	return append([]slowlogEntry(nil), l.entries[:count]...)
+}
+
+func (l *slowLog) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.entries)
+}
+
+func (l *slowLog) reset() {
+	l.mu.Lock()
+	l.entries = nil
+	l.mu.Unlock()
+}
+
+// slowlogArgs 复制命令参数，与 Redis 相同地截断过多与过长的参数
+func slowlogArgs(args [][]byte) [][]byte {
+	n := len(args)
+	if n > slowlogMaxArgs {
+		n = slowlogMaxArgs - 1
+	}
+	out := make([][]byte, 0, n+1)
+	for _, a := range args[:n] {
+		if len(a) > slowlogMaxArgLen {
+			a = []byte(fmt.Sprintf("%s... (%d more bytes)", a[:slowlogMaxArgLen], len(a)-slowlogMaxArgLen))
+		} else {
+			a = []byte(string(a))
+		}
+		out = append(out, a)
+	}
+	if n < len(args) {
+		out = append(out, []byte(fmt.Sprintf("... (%d more arguments)", len(args)-n)))
+	}
+	return out
+}
+
+// recordSlowLog 记录执行较慢的命令。cmdArgs 包含命令名
+func (h *Handler) recordSlowLog(cmd string, cmdArgs [][]byte, start time.Time, d time.Duration, remoteAddr string) {
+	if slowlogSkipCommands[cmd] {
+		return
+	}
+	name := ""
+	if h.clientInfo != nil {
+		name = h.clientInfo.name()
+	}
+	h.root().slowlog.add(start, d, cmdArgs, remoteAddr, name)
+}
+
+// handleSlowlog SLOWLOG GET [count] | LEN | RESET | HELP
+func (h *Handler) handleSlowlog(args [][]byte) proto.RESP {
+	log := &h.root().slowlog
+	switch strings.ToUpper(string(args[0])) {
+	case "GET":
+		if len(args) > 2 {
+			return proto.NewError("ERR wrong number of arguments for 'slowlog|get' command")
+		}
+		count := 10
+		if len(args) == 2 {
+			n, err := strconv.Atoi(string(args[1]))
+			if err != nil || n < -1 {
+				return proto.NewError("ERR count should be greater than or equal to -1")
+			}
+			count = n
+		}
+		entries := log.get(count)
+		elems := make([]proto.RESP, len(entries))
+		for i, e := range entries {
+			elems[i] = &proto.NestedArray{Elems: []proto.RESP{
+				proto.NewInteger(e.id),
+				proto.NewInteger(e.time),
+				proto.NewInteger(e.duration),
+				&proto.Array{Args: e.args},
+				proto.NewBulkString([]byte(e.addr)),
+				proto.NewBulkString([]byte(e.name)),
+			}}
+		}
+		return &proto.NestedArray{Elems: elems}
+	case "LEN":
+		return proto.NewInteger(int64(log.len()))
+	case "RESET":
+		log.reset()
+		return proto.OK
+	case "HELP":
+		return &proto.Array{Args: [][]byte{
+			[]byte("SLOWLOG GET <count> - returns top <count> entries from the slowlog (default 10, -1 for all)"),
+			[]byte("SLOWLOG LEN - returns the length of the slowlog"),
+			[]byte("SLOWLOG RESET - clears the slowlog"),
+			[]byte("SLOWLOG HELP - shows this help message"),
+		}}
+	default:
+		return proto.NewError(fmt.Sprintf("ERR unknown subcommand '%s'", string(args[0])))
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// parseZAddArgs 解析 ZADD key 之后的 [NX|XX] [GT|LT] [CH] [INCR] score member [score member ...]。
+// 选项组合的限制与错误文本与 Redis 相同
+func parseZAddArgs(args [][]byte) (store.ZAddOptions, []store.ZSetMember, proto.RESP) {
+	var opts store.ZAddOptions
+	i := 0
+	for i < len(args) && setZAddFlag(&opts, string(args[i])) {
+		i++
+	}
+
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return opts, nil, proto.NewError(errSyntax)
+	}
+	if opts.NX && opts.XX {
+		return opts, nil, proto.NewError("ERR XX and NX options at the same time are not compatible")
+	}
+	if (opts.GT && opts.NX) || (opts.LT && opts.NX) || (opts.GT && opts.LT) {
+		return opts, nil, proto.NewError("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	if opts.Incr && len(pairs) > 2 {
+		return opts, nil, proto.NewError("ERR INCR option supports a single increment-element pair")
+	}
+
+	members := make([]store.ZSetMember, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, err := strconv.ParseFloat(string(pairs[j]), 64)
+		if err != nil || math.IsNaN(score) {
+			return opts, nil, proto.NewError(errNotFloat)
+		}
+		members = append(members, store.ZSetMember{Member: string(pairs[j+1]), Score: score})
+	}
+	return opts, members, nil
+}
+
+// setZAddFlag 设置 ZADD 的一个选项，arg 不是选项时返回 false
+func setZAddFlag(opts *store.ZAddOptions, arg string) bool {
+	switch strings.ToUpper(arg) {
+	case "NX":
+		opts.NX = true
+	case "XX":
+		opts.XX = true
+	case "GT":
+		opts.GT = true
+	case "LT":
+		opts.LT = true
+	case "CH":
+		opts.CH = true
+	case "INCR":
+		opts.Incr = true
+	default:
+		return false
+	}
+	return true
+}
+
+// parseBlockingTimeout 解析阻塞命令以秒为单位的超时，允许小数
+func parseBlockingTimeout(arg []byte) (float64, proto.RESP) {
+	timeout, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || math.IsNaN(timeout) || math.IsInf(timeout, 0) {
+		return 0, proto.NewError("ERR timeout is not a float or out of range")
+	}
+	if timeout < 0 {
+		return 0, proto.NewError("ERR timeout is negative")
+	}
+	return timeout, nil
+}
+
+// parseZMPopArgs 解析 ZMPOP/BZMPOP 的 numkeys key [key ...] MIN|MAX [COUNT count]
+func parseZMPopArgs(args [][]byte) ([]string, bool, int, proto.RESP) {
+	numKeys, err := strconv.Atoi(string(args[0]))
+	if err != nil || numKeys <= 0 {
+		return nil, false, 0, proto.NewError("ERR numkeys should be greater than 0")
+	}
+	if numKeys > len(args)-2 {
+		return nil, false, 0, proto.NewError(errSyntax)
+	}
+	keys := make([]string, numKeys)
+	for i := range keys {
+		keys[i] = string(args[1+i])
+	}
+
+	rest := args[1+numKeys:]
+	var max bool
+	switch strings.ToUpper(string(rest[0])) {
+	case "MIN":
+	case "MAX":
+		max = true
+	default:
+		return nil, false, 0, proto.NewError(errSyntax)
+	}
+	count := 1
+	switch {
+	case len(rest) == 1:
+	case len(rest) == 3 && strings.EqualFold(string(rest[1]), "COUNT"):
+		n, err := strconv.Atoi(string(rest[2]))
+		if err != nil || n <= 0 {
+			return nil, false, 0, proto.NewError("ERR count should be greater than 0")
+		}
+		count = n
+	default:
+		return nil, false, 0, proto.NewError(errSyntax)
+	}
+	return keys, max, count, nil
+}
+
+// zmpopReply ZMPOP/BZMPOP 的回复：[key, [[member, score], ...]]，没有弹出成员时返回 nil
+func zmpopReply(key string, members []store.ZSetMember) proto.RESP {
+	if len(members) == 0 {
+		return proto.RawString("*-1\r\n")
+	}
+	elems := make([]proto.RESP, len(members))
+	for i, m := range members {
+		elems[i] = &proto.Array{Args: [][]byte{[]byte(m.Member), []byte(fmt.Sprintf("%.10g", m.Score))}}
+	}
+	return &proto.NestedArray{Elems: []proto.RESP{proto.NewBulkString([]byte(key)), &proto.NestedArray{Elems: elems}}}
+}
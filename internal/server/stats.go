@@ -0,0 +1,85 @@
+package server
+
+import (
+	"strings"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// keyspaceReadCommands 计入 keyspace_hits/keyspace_misses 的读命令。
+// 按回复判断：键不存在时这些命令返回空值、空数组、none 或 0
+var keyspaceReadCommands = map[string]bool{
+	"GET": true, "GETDEL": true, "GETEX": true, "MGET": true, "EXISTS": true, "TYPE": true,
+	"HGET": true, "HMGET": true, "HGETALL": true,
+	"LRANGE": true, "LINDEX": true, "SMEMBERS": true,
+	"ZRANGE": true, "ZSCORE": true, "XRANGE": true, "JSON.GET": true,
+}
+
+// recordCommandStats 记录一次执行的命令：命令统计、上次保存以来的写命令数与键空间命中率。
+// 未知命令不计入，避免任意命令名占用内存
+func (h *Handler) recordCommandStats(cmd string, args [][]byte, resp proto.RESP, d time.Duration) {
+	if _, known := commandArity[cmd]; !known {
+		return
+	}
+	stats := &h.root().stats
+	_, failed := resp.(*proto.Error)
+	failed = failed || resp == nil
+	stats.RecordCommand(strings.ToLower(cmd), d, failed)
+	if failed {
+		return
+	}
+	if isWriteCommand(cmd) {
+		stats.AddChanges(1)
+	}
+	if keyspaceReadCommands[cmd] {
+		stats.KeyspaceLookup(keyspaceLookupResult(cmd, args, resp))
+	}
+}
+
+// recordRejectedCommand 记录执行前被拒绝的命令（参数个数错误等）
+func (h *Handler) recordRejectedCommand(cmd string) {
+	if _, known := commandArity[cmd]; known {
+		h.root().stats.RecordRejected(strings.ToLower(cmd))
+	}
+}
+
+// keyspaceLookupResult 根据读命令的回复统计找到与未找到的键数
+func keyspaceLookupResult(cmd string, args [][]byte, resp proto.RESP) (hits, misses int64) {
+	switch r := resp.(type) {
+	case *proto.BulkString:
+		if *r == nil {
+			return 0, 1
+		}
+	case *proto.Array:
+		if cmd == "MGET" {
+			for _, v := range r.Args {
+				if v == nil {
+					misses++
+				} else {
+					hits++
+				}
+			}
+			return hits, misses
+		}
+		for _, v := range r.Args {
+			if v != nil {
+				return 1, 0
+			}
+		}
+		return 0, 1
+	case *proto.NestedArray:
+		if len(r.Elems) == 0 {
+			return 0, 1
+		}
+	case *proto.Integer:
+		if cmd == "EXISTS" {
+			return int64(*r), int64(len(args)) - int64(*r)
+		}
+	case *proto.SimpleString:
+		if *r == "none" {
+			return 0, 1
+		}
+	}
+	return 1, 0
+}
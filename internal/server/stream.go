@@ -0,0 +1,164 @@
+package server
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// parseStreamAddOrTrimArgs 解析 XADD/XTRIM 在键名之后的选项：
+// [NOMKSTREAM]（仅 XADD）[MAXLEN|MINID [=|~] threshold [LIMIT count]]。
+// XADD 遇到第一个非选项参数（ID）时停止，返回选项与该参数的位置；XTRIM 必须指定裁剪策略
+func parseStreamAddOrTrimArgs(args [][]byte, xadd bool) (store.StreamXAddOptions, int, proto.RESP) {
+	var opts store.StreamXAddOptions
+	trim := &opts.Trim
+	limitGiven := false
+	i := 1
+	for ; i < len(args); i++ {
+		moreArgs := len(args) - 1 - i
+		opt := strings.ToUpper(string(args[i]))
+		switch {
+		case (opt == "MAXLEN" || opt == "MINID") && moreArgs > 0:
+			if trim.Strategy != "" && trim.Strategy != opt {
+				return opts, 0, proto.NewError("ERR syntax error, MAXLEN and MINID options at the same time are not compatible")
+			}
+			trim.Strategy = opt
+			if next := string(args[i+1]); (next == "~" || next == "=") && moreArgs > 1 {
+				trim.Approx = next == "~"
+				i++
+			}
+			i++
+			if opt == "MAXLEN" {
+				n, err := strconv.ParseInt(string(args[i]), 10, 64)
+				if err != nil {
+					return opts, 0, proto.NewError(errNotInteger)
+				}
+				if n < 0 {
+					return opts, 0, proto.NewError("ERR The MAXLEN argument must be >= 0.")
+				}
+				trim.MaxLen = n
+			} else {
+				trim.MinID = string(args[i])
+			}
+			continue
+		case opt == "LIMIT" && moreArgs > 0:
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return opts, 0, proto.NewError(errNotInteger)
+			}
+			if n < 0 {
+				return opts, 0, proto.NewError("ERR The LIMIT argument must be >= 0.")
+			}
+			trim.Limit, limitGiven = n, true
+			i++
+			continue
+		case xadd && opt == "NOMKSTREAM":
+			opts.NoMkStream = true
+			continue
+		case xadd:
+			// 其余参数为 ID
+		default:
+			return opts, 0, proto.NewError(errSyntax)
+		}
+		break
+	}
+
+	if limitGiven && !trim.Approx {
+		return opts, 0, proto.NewError("ERR syntax error, LIMIT cannot be used without the special ~ option")
+	}
+	if trim.Approx && !limitGiven {
+		trim.Limit = store.DefaultStreamTrimLimit
+	}
+	if !xadd && trim.Strategy == "" {
+		return opts, 0, proto.NewError(errSyntax)
+	}
+	return opts, i, nil
+}
+
+// streamFieldsReply 按字段名排序返回 [field, value, ...]
+func streamFieldsReply(fields map[string]string) proto.RESP {
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	elems := make([]proto.RESP, 0, len(fields)*2)
+	for _, k := range names {
+		elems = append(elems, proto.NewBulkString([]byte(k)), proto.NewBulkString([]byte(fields[k])))
+	}
+	return &proto.NestedArray{Elems: elems}
+}
+
+// xinfoStreamFullReply 按 Redis XINFO STREAM FULL 的格式返回 Stream、记录与消费组的完整信息。
+// 不记录消费组已读条数，entries-read 与 lag 返回 nil
+func xinfoStreamFullReply(info *store.StreamFullInfo) proto.RESP {
+	bulk := func(s string) proto.RESP { return proto.NewBulkString([]byte(s)) }
+
+	entries := make([]proto.RESP, 0, len(info.Entries))
+	for _, e := range info.Entries {
+		entries = append(entries, &proto.NestedArray{Elems: []proto.RESP{bulk(e.ID), streamFieldsReply(e.Fields)}})
+	}
+
+	groups := make([]proto.RESP, 0, len(info.Groups))
+	for _, g := range info.Groups {
+		pending := make([]*store.StreamPendingEntry, 0, len(g.Pending))
+		for _, p := range g.Pending {
+			pending = append(pending, p)
+		}
+		sort.Slice(pending, func(i, j int) bool {
+			return store.CompareStreamID(pending[i].ID, pending[j].ID) < 0
+		})
+		groupPEL := make([]proto.RESP, 0, len(pending))
+		consumerPEL := make(map[string][]proto.RESP)
+		for _, p := range pending {
+			groupPEL = append(groupPEL, &proto.NestedArray{Elems: []proto.RESP{
+				bulk(p.ID), bulk(p.Consumer), proto.NewInteger(p.LastDelivery), proto.NewInteger(p.DeliveryCount),
+			}})
+			consumerPEL[p.Consumer] = append(consumerPEL[p.Consumer], &proto.NestedArray{Elems: []proto.RESP{
+				bulk(p.ID), proto.NewInteger(p.LastDelivery), proto.NewInteger(p.DeliveryCount),
+			}})
+		}
+
+		names := make([]string, 0, len(g.Consumers))
+		for name := range g.Consumers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		consumers := make([]proto.RESP, 0, len(names))
+		for _, name := range names {
+			c := g.Consumers[name]
+			consumers = append(consumers, &proto.NestedArray{Elems: []proto.RESP{
+				bulk("name"), bulk(name),
+				bulk("seen-time"), proto.NewInteger(c.LastSeen),
+				bulk("active-time"), proto.NewInteger(c.LastSeen),
+				bulk("pel-count"), proto.NewInteger(int64(len(consumerPEL[name]))),
+				bulk("pending"), &proto.NestedArray{Elems: consumerPEL[name]},
+			}})
+		}
+
+		groups = append(groups, &proto.NestedArray{Elems: []proto.RESP{
+			bulk("name"), bulk(g.Name),
+			bulk("last-delivered-id"), bulk(g.LastDeliveredID),
+			bulk("entries-read"), proto.NewBulkString(nil),
+			bulk("lag"), proto.NewBulkString(nil),
+			bulk("pel-count"), proto.NewInteger(int64(len(groupPEL))),
+			bulk("pending"), &proto.NestedArray{Elems: groupPEL},
+			bulk("consumers"), &proto.NestedArray{Elems: consumers},
+		}})
+	}
+
+	return &proto.NestedArray{Elems: []proto.RESP{
+		bulk("length"), proto.NewInteger(info.Length),
+		bulk("radix-tree-keys"), proto.NewInteger(info.RadixTreeKeys),
+		bulk("radix-tree-nodes"), proto.NewInteger(info.RadixTreeKeys),
+		bulk("last-generated-id"), bulk(info.LastGeneratedID),
+		bulk("max-deleted-entry-id"), bulk(info.MaxDeletedID),
+		bulk("entries-added"), proto.NewInteger(info.EntriesAdded),
+		bulk("recorded-first-entry-id"), bulk(info.FirstID),
+		bulk("entries"), &proto.NestedArray{Elems: entries},
+		bulk("groups"), &proto.NestedArray{Elems: groups},
+	}}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// parseExpireOption 解析 SET/GETEX 的 EX/PX/EXAT/PXAT 选项，返回绝对过期时间。
+// 与 Redis 相同，不大于 0 或换算为毫秒后溢出的值返回 invalid expire time
+func (h *Handler) parseExpireOption(cmd, option string, arg []byte) (time.Time, proto.RESP) {
+	n, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return time.Time{}, proto.NewError(errNotInteger)
+	}
+	if n <= 0 || ((option == "EX" || option == "EXAT") && n > math.MaxInt64/1000) {
+		return time.Time{}, proto.NewError(fmt.Sprintf("ERR invalid expire time in '%s' command", strings.ToLower(cmd)))
+	}
+	switch option {
+	case "EX":
+		return h.Db.Clock().Now().Add(time.Duration(n) * time.Second), nil
+	case "PX":
+		return h.Db.Clock().Now().Add(time.Duration(n) * time.Millisecond), nil
+	case "EXAT":
+		return time.Unix(n, 0), nil
+	default:
+		return time.UnixMilli(n), nil
+	}
+}
+
+// parseSetOptions 解析 SET key value 之后的 NX|XX、GET 与 EX|PX|EXAT|PXAT|KEEPTTL 选项
+func (h *Handler) parseSetOptions(args [][]byte) (store.SetOptions, proto.RESP) {
+	var opts store.SetOptions
+	hasExpire := false
+	for i := 0; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		switch option {
+		case "NX":
+			if opts.XX {
+				return opts, proto.NewError(errSyntax)
+			}
+			opts.NX = true
+		case "XX":
+			if opts.NX {
+				return opts, proto.NewError(errSyntax)
+			}
+			opts.XX = true
+		case "GET":
+			opts.Get = true
+		case "KEEPTTL":
+			if hasExpire {
+				return opts, proto.NewError(errSyntax)
+			}
+			opts.KeepTTL, hasExpire = true, true
+		case "EX", "PX", "EXAT", "PXAT":
+			if hasExpire || i+1 >= len(args) {
+				return opts, proto.NewError(errSyntax)
+			}
+			expiresAt, errResp := h.parseExpireOption("SET", option, args[i+1])
+			if errResp != nil {
+				return opts, errResp
+			}
+			opts.ExpiresAt, hasExpire = expiresAt, true
+			i++
+		default:
+			return opts, proto.NewError(errSyntax)
+		}
+	}
+	return opts, nil
+}
+
+// setReply SET 的回复：带 GET 时返回旧值（键不存在时为 nil），否则写入返回 OK、NX/XX 条件不满足返回 nil
+func setReply(opts store.SetOptions, result store.SetResult) proto.RESP {
+	if opts.Get {
+		if !result.OldExists {
+			return proto.NewBulkString(nil)
+		}
+		return proto.NewBulkString([]byte(result.Old))
+	}
+	if !result.Written {
+		return proto.NewBulkString(nil)
+	}
+	return proto.OK
+}
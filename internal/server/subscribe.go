@@ -0,0 +1,346 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// SUBSCRIBE/PSUBSCRIBE/SSUBSCRIBE 使连接进入订阅模式，之后由 handleSubscribe 读取命令，
+// 转发协程把消息写给客户端。与 Redis 相同，RESP2 连接在订阅模式下只接受订阅相关命令与 PING/QUIT，
+// RESP3 连接可以继续执行其他命令；全部退订后连接回到普通模式。
+
+// subscribeCommands 订阅与退订命令
+var subscribeCommands = map[string]bool{
+	"SUBSCRIBE": true, "PSUBSCRIBE": true, "SSUBSCRIBE": true,
+	"UNSUBSCRIBE": true, "PUNSUBSCRIBE": true, "SUNSUBSCRIBE": true,
+}
+
+// errDurableDisabled 未启用持久化订阅时使用 SUBSCRIBE ... RESUME
+var errDurableDisabled = errors.New("durable pubsub not enabled")
+
+// isDurableSubscribe 检查 SUBSCRIBE 参数是否以 RESUME <token> 结尾
+func isDurableSubscribe(args [][]byte) bool {
+	return len(args) >= 3 && strings.EqualFold(string(args[len(args)-2]), "RESUME")
+}
+
+// subscription 订阅模式的连接级状态
+type subscription struct {
+	h          *Handler
+	sub        *store.Subscriber
+	writer     *bufio.Writer
+	writeMu    sync.Mutex
+	lastMu     sync.Mutex
+	lastSeen   map[string]string // 频道 -> 已补发的最后一条消息 ID，用于实时消息去重
+	forwarding bool
+	forwardEnd chan struct{}
+	stopOnce   sync.Once
+}
+
+// messagePush 构造消息推送：
+// 频道消息 ["message", channel, data]，持久化频道的消息带第 4 个元素 token，客户端重连时通过 RESUME <token> 续传；
+// 模式消息 ["pmessage", pattern, channel, data]；分片频道消息 ["smessage", channel, data]
+func messagePush(msg *store.Message) proto.RESP {
+	var elems []proto.RESP
+	switch {
+	case msg.Shard:
+		elems = []proto.RESP{proto.NewBulkString([]byte("smessage")), proto.NewBulkString([]byte(msg.Channel))}
+	case msg.Pattern != "":
+		elems = []proto.RESP{
+			proto.NewBulkString([]byte("pmessage")),
+			proto.NewBulkString([]byte(msg.Pattern)),
+			proto.NewBulkString([]byte(msg.Channel)),
+		}
+	default:
+		elems = []proto.RESP{proto.NewBulkString([]byte("message")), proto.NewBulkString([]byte(msg.Channel))}
+	}
+	elems = append(elems, proto.NewBulkString(msg.Data))
+	if msg.ID != "" && !msg.Shard && msg.Pattern == "" {
+		elems = append(elems, proto.NewBulkString([]byte(msg.ID)))
+	}
+	return &proto.NestedArray{Elems: elems}
+}
+
+// subscriptionReply 构造订阅确认：[kind, channel, count]
+func subscriptionReply(kind, channel string, count int) proto.RESP {
+	return &proto.NestedArray{Elems: []proto.RESP{
+		proto.NewBulkString([]byte(kind)),
+		proto.NewBulkString([]byte(channel)),
+		proto.NewInteger(int64(count)),
+	}}
+}
+
+// emptyUnsubscribeReply 没有可退订的频道时的确认：[kind, nil, count]
+func emptyUnsubscribeReply(kind string, count int) proto.RESP {
+	return &proto.NestedArray{Elems: []proto.RESP{
+		proto.NewBulkString([]byte(kind)),
+		proto.NewBulkString(nil),
+		proto.NewInteger(int64(count)),
+	}}
+}
+
+// write 写入并刷新一条响应
+func (s *subscription) write(resp proto.RESP) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return proto.WriteRESP(s.writer, resp)
+}
+
+// push 写入订阅确认或消息，RESP3 连接上以 Push 发送
+func (s *subscription) push(resp proto.RESP) error {
+	return s.write(s.h.pushReply(resp))
+}
+
+// count 订阅确认中的数量：分片频道单独计数，频道与模式合并计数
+func (s *subscription) count(shard bool) int {
+	channels, patterns, shards := s.sub.SubscriptionCounts()
+	if shard {
+		return shards
+	}
+	return channels + patterns
+}
+
+// active 是否还有任何订阅
+func (s *subscription) active() bool {
+	channels, patterns, shards := s.sub.SubscriptionCounts()
+	return channels+patterns+shards > 0
+}
+
+// apply 执行一条订阅或退订命令，返回依次发送给客户端的确认（持久化订阅还包括补发的消息）
+func (s *subscription) apply(cmd string, args [][]byte) ([]proto.RESP, error) {
+	ps := s.h.PubSub
+	kind := strings.ToLower(cmd)
+	shard := cmd == "SSUBSCRIBE" || cmd == "SUNSUBSCRIBE"
+	names := make([]string, 0, len(args))
+	token := ""
+	if cmd == "SUBSCRIBE" && isDurableSubscribe(args) {
+		if !ps.DurableEnabled() {
+			return nil, errDurableDisabled
+		}
+		names, token = parseDurableSubscribeArgs(args)
+		ps.MarkDurable(names...)
+	} else {
+		for _, arg := range args {
+			names = append(names, string(arg))
+		}
+	}
+
+	var replies []proto.RESP
+	switch cmd {
+	case "SUBSCRIBE", "PSUBSCRIBE", "SSUBSCRIBE":
+		for _, name := range names {
+			switch cmd {
+			case "SUBSCRIBE":
+				// 持久化频道先订阅实时消息再补发，避免两者之间的消息丢失；重复的消息按 ID 去重
+				ps.Subscribe(s.sub, name)
+			case "PSUBSCRIBE":
+				ps.PSubscribe(s.sub, name)
+			default:
+				ps.SSubscribe(s.sub, name)
+			}
+			replies = append(replies, subscriptionReply(kind, name, s.count(shard)))
+		}
+	default:
+		unsubscribe := ps.Unsubscribe
+		switch cmd {
+		case "PUNSUBSCRIBE":
+			unsubscribe = ps.PUnsubscribe
+		case "SUNSUBSCRIBE":
+			unsubscribe = ps.SUnsubscribe
+		}
+		if len(names) == 0 {
+			// 不带参数时退订该类的全部订阅，确认中的数量逐个递减
+			removed := unsubscribe(s.sub)
+			remaining := s.count(shard)
+			if len(removed) == 0 {
+				return []proto.RESP{emptyUnsubscribeReply(kind, remaining)}, nil
+			}
+			for i, name := range removed {
+				replies = append(replies, subscriptionReply(kind, name, remaining+len(removed)-1-i))
+			}
+			return replies, nil
+		}
+		for _, name := range names {
+			unsubscribe(s.sub, name)
+			replies = append(replies, subscriptionReply(kind, name, s.count(shard)))
+		}
+		return replies, nil
+	}
+
+	if token != "" {
+		for _, ch := range names {
+			messages, err := ps.Replay(ch, token, 0)
+			if err != nil {
+				return replies, err
+			}
+			for _, msg := range messages {
+				replies = append(replies, messagePush(msg))
+				s.lastMu.Lock()
+				s.lastSeen[ch] = msg.ID
+				s.lastMu.Unlock()
+			}
+		}
+	}
+	return replies, nil
+}
+
+// forward 将实时消息转发给客户端，直到订阅者被移除
+func (s *subscription) forward() {
+	defer close(s.forwardEnd)
+	for msg := range s.sub.MessageCh {
+		if msg.ID != "" && msg.Pattern == "" {
+			s.lastMu.Lock()
+			last := s.lastSeen[msg.Channel]
+			s.lastMu.Unlock()
+			if last != "" && store.CompareResumeToken(msg.ID, last) <= 0 {
+				continue // 已在补发阶段发送过
+			}
+		}
+		if err := s.push(messagePush(msg)); err != nil {
+			logger.Logger.Debug().Err(err).Str("subscriber_id", s.sub.ID).Msg("推送订阅消息失败")
+		}
+	}
+}
+
+// stop 移除订阅者并等待转发协程退出，连接回到普通模式
+func (s *subscription) stop() {
+	s.stopOnce.Do(func() {
+		s.h.PubSub.RemoveSubscriber(s.sub)
+		if s.forwarding {
+			<-s.forwardEnd
+		}
+		if s.h.clientInfo != nil {
+			s.h.clientInfo.setPubSub(false)
+		}
+	})
+}
+
+// handleSubscribe 处理 (P|S)SUBSCRIBE 与 (P|S)UNSUBSCRIBE。
+// SUBSCRIBE channel [channel ...] RESUME <token> 先补发 token 之后错过的消息（受保留条数限制），再切换为实时投递。
+// 订阅后连接进入订阅模式，直到全部退订；确认已直接写出，返回空回复。返回 nil 表示连接需要关闭。
+func (h *Handler) handleSubscribe(cmd string, args [][]byte, remoteAddr string, reader *bufio.Reader, writer *bufio.Writer, conn net.Conn) proto.RESP {
+	if h.PubSub == nil {
+		return proto.NewError("ERR pubsub not enabled")
+	}
+
+	s := &subscription{
+		h:          h,
+		sub:        store.NewSubscriber(remoteAddr),
+		writer:     writer,
+		lastSeen:   make(map[string]string),
+		forwardEnd: make(chan struct{}),
+	}
+	replies, err := s.apply(cmd, args)
+	if err != nil {
+		s.stop()
+		return proto.NewError(fmt.Sprintf("ERR %v", err))
+	}
+	if !s.active() {
+		// 未订阅时的退订只返回确认，不进入订阅模式
+		s.stop()
+		for _, reply := range replies {
+			if err := s.push(reply); err != nil {
+				return nil
+			}
+		}
+		return proto.RawString("")
+	}
+	for _, reply := range replies {
+		if err := s.push(reply); err != nil {
+			s.stop()
+			return nil
+		}
+	}
+	s.forwarding = true
+	go s.forward()
+	if h.clientInfo != nil {
+		h.clientInfo.setPubSub(true)
+	}
+
+	for {
+		req, err := proto.ReadRESP(reader)
+		if err != nil {
+			logger.Logger.Debug().Str("remote_addr", remoteAddr).Err(err).Msg("订阅连接读取失败")
+			s.stop()
+			if resp := protocolErrorReply(err); resp != nil {
+				_ = proto.WriteRESP(writer, resp)
+			}
+			return nil
+		}
+		if len(req.Args) == 0 {
+			continue
+		}
+		cmd := strings.ToUpper(string(req.Args[0]))
+		cmdArgs := req.Args[1:]
+
+		var resp proto.RESP
+		switch {
+		case subscribeCommands[cmd]:
+			if resp = checkArity(cmd, cmdArgs); resp != nil {
+				break
+			}
+			if resp = h.checkClusterRedirect(cmd, cmdArgs); resp != nil {
+				break
+			}
+			replies, err := s.apply(cmd, cmdArgs)
+			if err != nil {
+				resp = proto.NewError(fmt.Sprintf("ERR %v", err))
+				break
+			}
+			done := !s.active()
+			if done {
+				// 全部退订：先停止转发，确认之后不会再有消息
+				s.stop()
+			}
+			for _, reply := range replies {
+				if err := s.push(reply); err != nil {
+					s.stop()
+					return nil
+				}
+			}
+			if done {
+				return proto.RawString("")
+			}
+		case cmd == "QUIT":
+			s.stop()
+			_ = proto.WriteRESP(writer, proto.OK)
+			return nil
+		case h.resp3():
+			if resp = h.processRequest(req, reader, remoteAddr, writer, conn); resp == nil {
+				s.stop()
+				return nil
+			}
+		case cmd == "PING":
+			payload := []byte("")
+			if len(cmdArgs) > 0 {
+				payload = cmdArgs[0]
+			}
+			resp = &proto.Array{Args: [][]byte{[]byte("pong"), payload}}
+		default:
+			resp = proto.NewError(fmt.Sprintf("ERR Can't execute '%s': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT are allowed in this context", strings.ToLower(cmd)))
+		}
+		if resp != nil {
+			if err := s.write(resp); err != nil {
+				logger.Logger.Debug().Str("remote_addr", remoteAddr).Err(err).Msg("订阅连接写入失败")
+				s.stop()
+				return nil
+			}
+		}
+	}
+}
+
+// parseDurableSubscribeArgs 解析 channel [channel ...] RESUME <token>
+func parseDurableSubscribeArgs(args [][]byte) ([]string, string) {
+	channels := make([]string, 0, len(args)-2)
+	for _, arg := range args[:len(args)-2] {
+		channels = append(channels, string(arg))
+	}
+	return channels, string(args[len(args)-1])
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+)
+
+// tierCycleKeys 每次调度检查的字符串值数量：按默认间隔每秒检查 2000 个
+const tierCycleKeys = 200
+
+// runTierCycle 执行一轮冷热分层。分层只影响本节点的存储位置，主从节点各自执行，不需要复制
+func (h *Handler) runTierCycle() {
+	if h.Db == nil || !h.Db.TieringEnabled() {
+		return
+	}
+	if _, err := h.Db.TierCycle(tierCycleKeys); err != nil {
+		logger.Logger.Error().Err(err).Msg("冷热分层失败")
+	}
+}
+
+// writeTierStats 写入 INFO persistence 中的冷热分层统计
+func (h *Handler) writeTierStats(b *strings.Builder) {
+	stats := h.Db.TierStats()
+	b.WriteString(fmt.Sprintf("tiering_enabled:%d\n", boolToInt(stats.Enabled)))
+	if !stats.Enabled {
+		return
+	}
+	b.WriteString(fmt.Sprintf("tier_hot_bytes:%d\n", stats.HotBytes))
+	b.WriteString(fmt.Sprintf("tier_cold_bytes:%d\n", stats.ColdBytes))
+	b.WriteString(fmt.Sprintf("tier_cold_values:%d\n", stats.ColdValues))
+	b.WriteString(fmt.Sprintf("tier_tracked_values:%d\n", stats.Tracked))
+	b.WriteString(fmt.Sprintf("tier_demoted:%d\n", stats.Demoted))
+	b.WriteString(fmt.Sprintf("tier_promoted:%d\n", stats.Promoted))
+	b.WriteString(fmt.Sprintf("tier_cold_reads:%d\n", stats.ColdReads))
+	b.WriteString(fmt.Sprintf("tier_pending_promotions:%d\n", stats.Pending))
+	b.WriteString(fmt.Sprintf("tier_scan_passes:%d\n", stats.Passes))
+	b.WriteString(fmt.Sprintf("tier_swept:%d\n", stats.Swept))
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"strings"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// txState 连接的事务状态机：
+//
+//	txNone    --MULTI-->          txMulti
+//	txMulti   --入队出错-->        txAborted
+//	txMulti   --EXEC/DISCARD-->   txNone（同时清除 WATCH）
+//	txAborted --EXEC/DISCARD-->   txNone（EXEC 返回 EXECABORT）
+//
+// WATCH 与状态无关：只能在 txNone 中使用，EXEC、DISCARD、UNWATCH 都会清除
+type txState int
+
+const (
+	txNone txState = iota
+	txMulti
+	txAborted
+)
+
+// execAbortMessage 入队时出错的事务在 EXEC 时返回的错误
+const execAbortMessage = "EXECABORT Transaction discarded because of previous errors."
+
+// txControlCommands 事务中直接执行、不入队的命令
+var txControlCommands = map[string]bool{
+	"MULTI": true, "EXEC": true, "DISCARD": true, "WATCH": true, "QUIT": true,
+}
+
+// blockingCommands 可能等待数据的命令，执行时不持有隔离锁，见 lockCommand
+var blockingCommands = map[string]bool{
+	"BLPOP": true, "BRPOP": true, "BRPOPLPUSH": true, "BLMOVE": true,
+	"BZPOPMIN": true, "BZPOPMAX": true, "BZMPOP": true, "XREAD": true, "XREADGROUP": true, "WAIT": true,
+}
+
+// lockCommand 取得命令的隔离锁并返回释放函数：EXEC 与脚本（EVAL、EVALSHA）独占执行，
+// 从比较 WATCH 的版本到最后一条命令之间不会有其他命令交错；SWAPDB 独占执行，
+// 其他命令看不到与 0 号数据库交换到一半的状态（见 BotreonStore.SwapDB）；其他命令共享执行。
+// 可能阻塞的命令不持有锁，避免等待数据期间挡住所有命令，等到数据后的弹出仍在单个存储事务中完成。
+// 事务与脚本中的命令直接经由 runCommand 执行，不再加锁
+func (h *Handler) lockCommand(cmd string) func() {
+	mu := &h.root().isolation
+	switch {
+	case cmd == "EXEC" || cmd == "EVAL" || cmd == "EVALSHA" || cmd == "SWAPDB":
+		mu.Lock()
+		return mu.Unlock
+	case blockingCommands[cmd]:
+		return func() {}
+	}
+	mu.RLock()
+	return mu.RUnlock
+}
+
+// txForbiddenCommands 会改变连接模式的命令，不能在事务中使用，入队时报错并使事务失败
+var txForbiddenCommands = map[string]bool{
+	"SUBSCRIBE": true, "PSUBSCRIBE": true, "SSUBSCRIBE": true,
+	"UNSUBSCRIBE": true, "PUNSUBSCRIBE": true, "SUNSUBSCRIBE": true,
+	"PSYNC": true, "SYNC": true, "MONITOR": true,
+}
+
+// inMulti 连接是否处于 MULTI 之后、EXEC/DISCARD 之前
+func (h *Handler) inMulti() bool {
+	return h.transaction != nil && h.transaction.state != txNone
+}
+
+// queueCommand 将 MULTI 中的命令加入队列。与 Redis 相同，未知命令、参数个数错误和禁止的命令
+// 在入队时报错并使整个事务失败；参数类型和键类型在 EXEC 时才检查
+func (h *Handler) queueCommand(cmd string, args [][]byte) proto.RESP {
+	if txForbiddenCommands[cmd] {
+		h.transaction.state = txAborted
+		return proto.NewError("ERR Command not allowed inside a transaction")
+	}
+	if !knownCommands[cmd] {
+		h.transaction.state = txAborted
+		return proto.NewError(unknownCommandError(cmd, args))
+	}
+	if resp := checkArity(cmd, args); resp != nil {
+		h.transaction.state = txAborted
+		return resp
+	}
+	if resp := h.checkClusterRedirect(cmd, args); resp != nil {
+		h.transaction.state = txAborted
+		return resp
+	}
+	if resp := h.checkReadOnlyReplica(cmd, args); resp != nil {
+		h.transaction.state = txAborted
+		return resp
+	}
+	if resp := h.checkRateLimit(cmd, args); resp != nil {
+		h.transaction.state = txAborted
+		return resp
+	}
+	h.transaction.Commands = append(h.transaction.Commands, TransactionCommand{
+		Command: cmd,
+		Args:    args,
+	})
+	return proto.NewSimpleString("QUEUED")
+}
+
+// resetTransaction 结束事务并释放 WATCH 的键（EXEC、DISCARD、UNWATCH 与连接关闭时调用）
+func (h *Handler) resetTransaction() {
+	if h.transaction == nil {
+		return
+	}
+	if len(h.transaction.WatchKeys) > 0 {
+		h.Db.ReleaseWatches(len(h.transaction.WatchKeys))
+	}
+	h.transaction = nil
+}
+
+// watchedKeysChanged WATCH 的键在 EXEC 前是否被修改（包括删除和过期），由存储层的键版本判断
+func (h *Handler) watchedKeysChanged(keys map[string]store.KeyWatch) bool {
+	for key, w := range keys {
+		if h.Db.KeyModified(key, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// nonBlockingArgs 事务中的阻塞命令不阻塞：超时参数改为 0，没有数据时立即返回空结果；
+// XREAD 的 BLOCK 0 表示一直等待，因此去掉 BLOCK 选项
+func nonBlockingArgs(cmd string, args [][]byte) [][]byte {
+	switch cmd {
+	case "BLPOP", "BRPOP", "BRPOPLPUSH", "BLMOVE", "BZPOPMIN", "BZPOPMAX":
+		if len(args) == 0 {
+			return args
+		}
+		rewritten := make([][]byte, len(args))
+		copy(rewritten, args)
+		rewritten[len(args)-1] = []byte("0")
+		return rewritten
+	case "BZMPOP":
+		if len(args) == 0 {
+			return args
+		}
+		rewritten := make([][]byte, len(args))
+		copy(rewritten, args)
+		rewritten[0] = []byte("0")
+		return rewritten
+	case "XREAD", "XREADGROUP":
+		for i := 0; i+1 < len(args); i++ {
+			if strings.ToUpper(string(args[i])) == "STREAMS" {
+				break
+			}
+			if strings.ToUpper(string(args[i])) == "BLOCK" {
+				rewritten := make([][]byte, 0, len(args)-2)
+				rewritten = append(rewritten, args[:i]...)
+				return append(rewritten, args[i+2:]...)
+			}
+		}
+	}
+	return args
+}
+
+// execTransaction 执行 EXEC：入队出错时返回 EXECABORT，WATCH 的键被修改时返回空数组，
+// 否则依次执行队列中的命令，写命令逐条传播到从节点。调用方持有独占的隔离锁（见 lockCommand）
+func (h *Handler) execTransaction(remoteAddr string) proto.RESP {
+	tx := h.transaction
+	// 先比较版本再释放 WATCH，释放后提交的写入不再递增版本
+	changed := tx.state != txAborted && tx.IsWatching && h.watchedKeysChanged(tx.WatchKeys)
+	h.resetTransaction()
+	if tx.state == txAborted {
+		return proto.NewError(execAbortMessage)
+	}
+	if changed {
+		return proto.RawString("*-1\r\n")
+	}
+
+	results := make([]proto.RESP, len(tx.Commands))
+	for i, tc := range tx.Commands {
+		args := nonBlockingArgs(tc.Command, tc.Args)
+		start := time.Now()
+		resp := h.runCommand(tc.Command, args, remoteAddr)
+		h.recordSlowLog(tc.Command, append([][]byte{[]byte(tc.Command)}, args...), start, time.Since(start), remoteAddr)
+		if resp == nil {
+			resp = proto.NewError("ERR internal error")
+		}
+		results[i] = h.adaptReply(tc.Command, args, h.dbReply(tc.Command, args, resp))
+		cmdArgs := append([][]byte{[]byte(tc.Command)}, args...)
+		h.propagateWrite(tc.Command, cmdArgs, resp)
+		h.mirrorWrite(tc.Command, cmdArgs, resp)
+		h.feedAOF(tc.Command, args, resp)
+	}
+	return &proto.NestedArray{Elems: results}
+}
@@ -0,0 +1,101 @@
+package server
+
+//go:generate go run ../../cmd/gen-validators -in commands.spec -dispatch handler.go -out validators_gen.go
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/lbp0200/BoltDB/internal/proto"
+)
+
+// 参数校验的错误信息，与 Redis 完全一致
+const (
+	errNotInteger    = "ERR value is not an integer or out of range"
+	errNotFloat      = "ERR value is not a valid float"
+	errScoreNotFloat = "ERR min or max is not a float"
+	errSyntax        = "ERR syntax error"
+)
+
+// wrongArgsError 参数个数错误，命令名小写，子命令用 '|' 连接（如 'xgroup|create'）
+func wrongArgsError(cmd string) proto.RESP {
+	name := strings.ReplaceAll(strings.ToLower(cmd), " ", "|")
+	return proto.NewError("ERR wrong number of arguments for '" + name + "' command")
+}
+
+// checkArity 按 commands.spec 中的 arity 检查参数个数，args 不含命令名
+func checkArity(cmd string, args [][]byte) proto.RESP {
+	arity, ok := commandArity[cmd]
+	if !ok {
+		return nil
+	}
+	n := len(args) + 1
+	if (arity > 0 && n != arity) || (arity < 0 && n < -arity) {
+		return wrongArgsError(cmd)
+	}
+	return nil
+}
+
+// checkArgKinds 按 commands.spec 中的参数类型检查整数、浮点数和枚举参数
+func checkArgKinds(cmd string, args [][]byte) proto.RESP {
+	if validate, ok := commandArgValidators[cmd]; ok {
+		return validate(args)
+	}
+	return nil
+}
+
+// isIntegerArg 与 Redis 的 string2ll 规则相同：可选的负号加十进制数字，
+// 不允许 '+'、前导零、空白，且必须在 int64 范围内
+func isIntegerArg(b []byte) bool {
+	s := string(b)
+	digits := strings.TrimPrefix(s, "-")
+	if digits == "" || (digits[0] == '0' && (len(digits) > 1 || len(s) > 1)) {
+		return false
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return false
+		}
+	}
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
+
+// isFloatArg 与 Redis 的 getDoubleFromObject 规则相同：允许 inf/-inf，
+// 不允许 NaN、空白和超出 float64 范围的值
+func isFloatArg(b []byte) bool {
+	s := string(b)
+	if s == "" || strings.TrimSpace(s) != s {
+		return false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return err == nil && !math.IsNaN(f)
+}
+
+// isScoreArg 分数区间端点：可带 "(" 表示开区间，超出范围的值按 ±inf 处理（与 zslParseRange 相同）
+func isScoreArg(b []byte) bool {
+	if len(b) > 0 && b[0] == '(' {
+		b = b[1:]
+	}
+	s := string(b)
+	if s == "" || strings.TrimSpace(s) != s {
+		return false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		numErr, ok := err.(*strconv.NumError)
+		return ok && numErr.Err == strconv.ErrRange
+	}
+	return !math.IsNaN(f)
+}
+
+// isEnumArg 不区分大小写地匹配枚举值
+func isEnumArg(b []byte, values ...string) bool {
+	for _, v := range values {
+		if strings.EqualFold(string(b), v) {
+			return true
+		}
+	}
+	return false
+}
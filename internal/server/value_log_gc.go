@@ -0,0 +1,200 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/logger"
+	"github.com/lbp0200/BoltDB/internal/proto"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+const (
+	// DefaultValueLogGCInterval Badger value log 回收的默认间隔
+	DefaultValueLogGCInterval = 10 * time.Minute
+	// valueLogGCCheckInterval RunValueLogGC 检查是否到期的间隔，CONFIG SET 的修改在这段时间内生效
+	valueLogGCCheckInterval = time.Second
+)
+
+// valueLogGC Badger value log 回收与 BOLTREON.COMPACT 的设置与状态（只保存在服务器级）
+type valueLogGC struct {
+	intervalSec atomic.Int64 // CONFIG badger-gc-interval，0 表示不定时回收
+	// intervalSet 为 false 时使用 DefaultValueLogGCInterval
+	intervalSet  atomic.Bool
+	discardRatio atomic.Uint64 // CONFIG badger-gc-discard-ratio（math.Float64bits），0 表示默认值
+
+	// run 定时回收与 BOLTREON.COMPACT 不同时回收，Badger 会拒绝并发的回收
+	run        sync.Mutex
+	inProgress atomic.Bool
+	compacting atomic.Bool
+
+	mu   sync.Mutex
+	gc   valueLogGCStatus
+	comp valueLogGCStatus
+}
+
+// valueLogGCStatus 最近一次回收或压缩的结果，用于 INFO persistence
+type valueLogGCStatus struct {
+	runs      int64
+	lastTime  time.Time // 最近一次完成的时间，尚未执行过时为零值
+	duration  time.Duration
+	rewritten int   // 最近一次重写的 value log 文件数
+	total     int64 // 累计重写的 value log 文件数
+	err       error
+}
+
+func (s *valueLogGCStatus) record(start time.Time, rewritten int, err error) {
+	s.runs++
+	s.lastTime = time.Now()
+	s.duration = s.lastTime.Sub(start)
+	s.rewritten = rewritten
+	s.total += int64(rewritten)
+	s.err = err
+}
+
+// valueLogGCInterval 定时回收的间隔，0 表示不定时回收
+func (h *Handler) valueLogGCInterval() time.Duration {
+	g := &h.root().valueLogGC
+	if !g.intervalSet.Load() {
+		return DefaultValueLogGCInterval
+	}
+	return time.Duration(g.intervalSec.Load()) * time.Second
+}
+
+// valueLogGCDiscardRatio value log 文件中可回收的数据达到这一比例时才重写
+func (h *Handler) valueLogGCDiscardRatio() float64 {
+	if bits := h.root().valueLogGC.discardRatio.Load(); bits != 0 {
+		return math.Float64frombits(bits)
+	}
+	return store.DefaultValueLogGCDiscardRatio
+}
+
+// RunValueLogGC 每隔 badger-gc-interval 回收一次 Badger value log，直到 stop 关闭。
+// 间隔每秒重新读取，CONFIG SET 的修改随即生效；为 0 时不定时回收
+func (h *Handler) RunValueLogGC(stop <-chan struct{}) {
+	ticker := time.NewTicker(valueLogGCCheckInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			interval := h.valueLogGCInterval()
+			if interval <= 0 || now.Sub(last) < interval {
+				continue
+			}
+			h.runValueLogGC(stop)
+			last = time.Now()
+		}
+	}
+}
+
+// runValueLogGC 回收 value log：每次重写一个文件，直到没有可回收的文件或 stop 关闭，
+// 每个文件的耗时计入 LATENCY 的 badger-gc 事件。返回重写的文件数
+func (h *Handler) runValueLogGC(stop <-chan struct{}) (int, error) {
+	g := &h.root().valueLogGC
+	g.run.Lock()
+	defer g.run.Unlock()
+	g.inProgress.Store(true)
+	defer g.inProgress.Store(false)
+
+	start := time.Now()
+	ratio := h.valueLogGCDiscardRatio()
+	rewritten := 0
+	var err error
+loop:
+	for {
+		fileStart := time.Now()
+		var ok bool
+		ok, err = h.Db.ValueLogGC(ratio)
+		h.addLatencySample(latencyEventBadgerGC, time.Since(fileStart))
+		if err != nil || !ok {
+			break
+		}
+		rewritten++
+		select {
+		case <-stop:
+			break loop
+		default:
+		}
+	}
+
+	g.mu.Lock()
+	g.gc.record(start, rewritten, err)
+	g.mu.Unlock()
+	if err != nil {
+		logger.Logger.Error().Err(err).Int("rewritten", rewritten).Msg("Badger value log 回收失败")
+	} else if rewritten > 0 {
+		logger.Logger.Info().Int("rewritten", rewritten).Dur("duration", time.Since(start)).Msg("Badger value log 回收完成")
+	}
+	return rewritten, err
+}
+
+// handleCompact 处理 BOLTREON.COMPACT：在后台把 LSM 树合并到同一层（Flatten），之后回收 value log，
+// 直到没有可回收的文件。与 BGREWRITEAOF 相同，进度与结果见 INFO persistence
+func (h *Handler) handleCompact() proto.RESP {
+	g := &h.root().valueLogGC
+	if !g.compacting.CompareAndSwap(false, true) {
+		return proto.NewError("ERR Background compaction already in progress")
+	}
+	go func() {
+		defer g.compacting.Store(false)
+		_ = h.compact()
+	}()
+	return proto.NewSimpleString("Background compaction started")
+}
+
+// compact 执行一次压缩并记录结果
+func (h *Handler) compact() error {
+	start := time.Now()
+	err := h.Db.Flatten(runtime.GOMAXPROCS(0))
+	rewritten := 0
+	if err == nil {
+		rewritten, err = h.runValueLogGC(nil)
+	}
+	g := &h.root().valueLogGC
+	g.mu.Lock()
+	g.comp.record(start, rewritten, err)
+	g.mu.Unlock()
+	if err != nil {
+		logger.Logger.Error().Err(err).Msg("BOLTREON.COMPACT 失败")
+		return err
+	}
+	logger.Logger.Info().Int("rewritten", rewritten).Dur("duration", time.Since(start)).Msg("BOLTREON.COMPACT 完成")
+	return nil
+}
+
+// writeValueLogGCInfo 写入 INFO persistence 中 value log 回收与压缩的状态
+func (h *Handler) writeValueLogGCInfo(b *strings.Builder) {
+	g := &h.root().valueLogGC
+	g.mu.Lock()
+	gc, comp := g.gc, g.comp
+	g.mu.Unlock()
+	b.WriteString(fmt.Sprintf("badger_gc_in_progress:%d\n", boolToInt(g.inProgress.Load())))
+	writeValueLogGCStatus(b, "badger_gc", gc)
+	b.WriteString(fmt.Sprintf("badger_gc_rewritten_files:%d\n", gc.total))
+	b.WriteString(fmt.Sprintf("badger_compact_in_progress:%d\n", boolToInt(g.compacting.Load())))
+	writeValueLogGCStatus(b, "badger_compact", comp)
+}
+
+func writeValueLogGCStatus(b *strings.Builder, prefix string, s valueLogGCStatus) {
+	lastTime := int64(-1)
+	if !s.lastTime.IsZero() {
+		lastTime = s.lastTime.Unix()
+	}
+	status := "ok"
+	if s.err != nil {
+		status = "err"
+	}
+	b.WriteString(fmt.Sprintf("%s_runs:%d\n", prefix, s.runs))
+	b.WriteString(fmt.Sprintf("%s_last_time:%d\n", prefix, lastTime))
+	b.WriteString(fmt.Sprintf("%s_last_status:%s\n", prefix, status))
+	b.WriteString(fmt.Sprintf("%s_last_duration_ms:%d\n", prefix, s.duration.Milliseconds()))
+	b.WriteString(fmt.Sprintf("%s_last_rewritten_files:%d\n", prefix, s.rewritten))
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// AggregateOp 服务端聚合的方式
+type AggregateOp string
+
+const (
+	AggregateSum   AggregateOp = "SUM"
+	AggregateMin   AggregateOp = "MIN"
+	AggregateMax   AggregateOp = "MAX"
+	AggregateAvg   AggregateOp = "AVG"
+	AggregateCount AggregateOp = "COUNT"
+)
+
+// ErrAggregateWrongType 聚合哈希字段时键存在但不是哈希
+var ErrAggregateWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// ParseAggregateOp 解析聚合方式（不区分大小写）
+func ParseAggregateOp(name string) (AggregateOp, error) {
+	op := AggregateOp(strings.ToUpper(name))
+	switch op {
+	case AggregateSum, AggregateMin, AggregateMax, AggregateAvg, AggregateCount:
+		return op, nil
+	}
+	return "", fmt.Errorf("unknown aggregate %s", name)
+}
+
+// AggregateResult 聚合结果。Count 为参与聚合的数值个数，为 0 时 Value 无意义（SUM 为 0）；
+// 不能解析为数值的值不参与聚合，计入 Skipped
+type AggregateResult struct {
+	Value   float64
+	Count   int64
+	Skipped int64
+}
+
+// aggregator 逐个累加数值，求和使用 Kahan 补偿，大量计数器相加时不丢失精度
+type aggregator struct {
+	op       AggregateOp
+	sum, c   float64
+	min, max float64
+	count    int64
+	skipped  int64
+}
+
+func (a *aggregator) add(raw []byte) {
+	v, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil || math.IsNaN(v) {
+		a.skipped++
+		return
+	}
+	if a.count == 0 || v < a.min {
+		a.min = v
+	}
+	if a.count == 0 || v > a.max {
+		a.max = v
+	}
+	a.count++
+	y := v - a.c
+	t := a.sum + y
+	a.c = (t - a.sum) - y
+	a.sum = t
+}
+
+func (a *aggregator) result() AggregateResult {
+	r := AggregateResult{Count: a.count, Skipped: a.skipped}
+	switch a.op {
+	case AggregateSum:
+		r.Value = a.sum
+	case AggregateMin:
+		r.Value = a.min
+	case AggregateMax:
+		r.Value = a.max
+	case AggregateAvg:
+		if a.count > 0 {
+			r.Value = a.sum / float64(a.count)
+		}
+	case AggregateCount:
+		r.Value = float64(a.count)
+	}
+	return r
+}
+
+// AggregateHash 在一次前缀扫描中聚合哈希 key 中字段名匹配 fieldPattern 的数值字段，
+// 代替 HGETALL 后在客户端计算。键不存在时结果为空，键不是哈希时返回 ErrAggregateWrongType
+func (s *BotreonStore) AggregateHash(key, fieldPattern string, op AggregateOp) (AggregateResult, error) {
+	agg := aggregator{op: op}
+	err := s.view(func(txn *storeTxn) error {
+		typ, err := txn.Get(TypeOfKeyGet(key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		keyType, err := typ.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if string(keyType) != KeyTypeHash {
+			return ErrAggregateWrongType
+		}
+
+		prefix := []byte(fmt.Sprintf("%s:%s:", KeyTypeHash, key))
+		iter := txn.NewIterator(s.iteratorOptions(prefix, -1, true))
+		defer iter.Close()
+		for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+			item := iter.Item()
+			field := string(item.Key()[len(prefix):])
+			if field == "__count__" || !matchPattern(field, fieldPattern) {
+				continue
+			}
+			val, err := s.getValueWithDecompression(item)
+			if err != nil {
+				return err
+			}
+			agg.add(val)
+		}
+		return nil
+	})
+	return agg.result(), err
+}
+
+// AggregateKeys 在一次键空间扫描中聚合名称匹配 pattern 的键：field 为空时取字符串键的值，
+// 否则取哈希键的 field 字段。其他类型的键和没有该字段的哈希被忽略，已过期的键不参与聚合
+func (s *BotreonStore) AggregateKeys(pattern, field string, op AggregateOp) (AggregateResult, error) {
+	agg := aggregator{op: op}
+	now := s.now()
+	err := s.view(func(txn *storeTxn) error {
+		iter := txn.NewIterator(s.iteratorOptions(prefixKeyTypeBytes, -1, true))
+		defer iter.Close()
+		for iter.Seek(prefixKeyTypeBytes); iter.ValidForPrefix(prefixKeyTypeBytes); iter.Next() {
+			item := iter.Item()
+			key := string(item.Key()[len(prefixKeyTypeBytes):])
+			if !matchPattern(key, pattern) {
+				continue
+			}
+			keyType, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			var valueKey []byte
+			switch {
+			case field == "" && string(keyType) == KeyTypeString:
+				valueKey = []byte(s.stringKey(key))
+			case field != "" && string(keyType) == KeyTypeHash:
+				valueKey = s.hashKey(key, field)
+			default:
+				continue
+			}
+			valueItem, err := txn.Get(valueKey)
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			// EXPIRE 写入的过期时间 Badger 不会自动隐藏；哈希的过期时间在过期时间记录中
+			exp := valueItem.ExpiresAt()
+			if field != "" {
+				if exp, err = s.expiresAtTxn(txn, key, KeyTypeHash); err != nil {
+					return err
+				}
+			}
+			if exp > 0 && !expiresAtTime(exp).After(now) {
+				continue
+			}
+			var val []byte
+			if field == "" {
+				val, err = s.stringValueTxn(txn, key, valueItem)
+			} else {
+				val, err = s.getValueWithDecompression(valueItem)
+			}
+			if err != nil {
+				return err
+			}
+			agg.add(val)
+		}
+		return nil
+	})
+	return agg.result(), err
+}
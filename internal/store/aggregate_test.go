@@ -0,0 +1,88 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestAggregateHash(t *testing.T) {
+	dbPath := t.TempDir()
+	store, _ := NewBadgerStore(dbPath)
+	defer store.Close()
+
+	assert.NoError(t, store.HSet("stats", "hits:a", "10"))
+	assert.NoError(t, store.HSet("stats", "hits:b", "2.5"))
+	assert.NoError(t, store.HSet("stats", "hits:c", "-4"))
+	assert.NoError(t, store.HSet("stats", "name", "not a number"))
+	assert.NoError(t, store.HSet("stats", "misses", "7"))
+
+	r, err := store.AggregateHash("stats", "*", AggregateSum)
+	assert.NoError(t, err)
+	assert.Equal(t, 15.5, r.Value)
+	assert.Equal(t, int64(4), r.Count)
+	assert.Equal(t, int64(1), r.Skipped)
+
+	r, err = store.AggregateHash("stats", "hits:*", AggregateSum)
+	assert.NoError(t, err)
+	assert.Equal(t, 8.5, r.Value)
+
+	r, _ = store.AggregateHash("stats", "hits:*", AggregateMin)
+	assert.Equal(t, -4.0, r.Value)
+	r, _ = store.AggregateHash("stats", "hits:*", AggregateMax)
+	assert.Equal(t, 10.0, r.Value)
+	r, _ = store.AggregateHash("stats", "hits:?", AggregateAvg)
+	assert.Equal(t, 8.5/3, r.Value)
+	r, _ = store.AggregateHash("stats", "*", AggregateCount)
+	assert.Equal(t, int64(4), r.Count)
+
+	// 键不存在时结果为空
+	r, err = store.AggregateHash("missing", "*", AggregateMax)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), r.Count)
+
+	assert.NoError(t, store.Set("str", "1"))
+	_, err = store.AggregateHash("str", "*", AggregateSum)
+	assert.Equal(t, ErrAggregateWrongType, err)
+}
+
+func TestAggregateKeys(t *testing.T) {
+	dbPath := t.TempDir()
+	store, _ := NewBadgerStore(dbPath)
+	defer store.Close()
+
+	clock := NewManualClock(time.Now())
+	store.SetClock(clock)
+
+	assert.NoError(t, store.Set("counter:1", "3"))
+	assert.NoError(t, store.Set("counter:2", "4"))
+	assert.NoError(t, store.Set("counter:3", "abc"))
+	assert.NoError(t, store.Set("other", "100"))
+	_, err := store.RPush("counter:list", "5")
+	assert.NoError(t, err)
+	assert.NoError(t, store.HSet("user:1", "score", "10"))
+	assert.NoError(t, store.HSet("user:2", "score", "20"))
+	assert.NoError(t, store.HSet("user:3", "name", "carol"))
+
+	r, err := store.AggregateKeys("counter:*", "", AggregateSum)
+	assert.NoError(t, err)
+	assert.Equal(t, 7.0, r.Value)
+	assert.Equal(t, int64(2), r.Count)
+	assert.Equal(t, int64(1), r.Skipped)
+
+	r, err = store.AggregateKeys("user:*", "score", AggregateAvg)
+	assert.NoError(t, err)
+	assert.Equal(t, 15.0, r.Value)
+	assert.Equal(t, int64(2), r.Count)
+
+	// 已过期的键不参与聚合
+	ok, err := store.Expire("counter:2", 10)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	clock.Advance(11 * time.Second)
+	r, err = store.AggregateKeys("counter:*", "", AggregateSum)
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, r.Value)
+	assert.Equal(t, int64(1), r.Count)
+}
@@ -0,0 +1,350 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"math/bits"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/lbp0200/BoltDB/internal/helper"
+)
+
+const (
+	// DefaultAnalyzeReadsPerSecond 键空间分析默认每秒最多读取的 Badger 键数
+	DefaultAnalyzeReadsPerSecond = 50000
+	// DefaultAnalyzeTopPrefixes 默认列出的前缀数
+	DefaultAnalyzeTopPrefixes = 10
+	// DefaultAnalyzePrefixDelimiter 默认的前缀分隔符：user:1000 的前缀为 user
+	DefaultAnalyzePrefixDelimiter = ":"
+
+	// analyzeBatchSize 每个读事务取出的用户键数
+	analyzeBatchSize = 256
+	// analyzeMaxPrefixes 统计的不同前缀数上限，超出后新的前缀计入 AnalyzeOtherPrefix
+	analyzeMaxPrefixes = 100000
+)
+
+// AnalyzeOtherPrefix 不同前缀过多时，超出上限的前缀合并到这一项
+const AnalyzeOtherPrefix = "(other)"
+
+// ErrAnalyzeRunning 已有分析在运行
+var ErrAnalyzeRunning = errors.New("keyspace analysis already running")
+
+// AnalyzeOptions 键空间分析参数，零值使用默认值
+type AnalyzeOptions struct {
+	ReadsPerSecond  int    // 每秒最多读取的 Badger 键数（大键按其全部 Badger 键计）
+	TopPrefixes     int    // 按键数、字节数各列出的前缀数
+	PrefixDelimiter string // 前缀为键中第一个分隔符之前的部分，没有分隔符的键前缀为整个键
+}
+
+// Histogram 按 2 的幂分桶的直方图：Buckets[i] 为落在 (2^(i-1), 2^i] 中的值的个数（Buckets[0] 含 0 和 1）
+type Histogram struct {
+	Buckets []int64
+}
+
+// Add 记录一个值
+func (h *Histogram) Add(v int64) {
+	i := 0
+	if v > 1 {
+		// #nosec G115 - v > 1
+		i = bits.Len64(uint64(v - 1))
+	}
+	for len(h.Buckets) <= i {
+		h.Buckets = append(h.Buckets, 0)
+	}
+	h.Buckets[i]++
+}
+
+// HistogramBound 第 i 个桶的上界
+func HistogramBound(i int) int64 {
+	return int64(1) << i
+}
+
+// TypeAnalysis 某一数据类型的统计
+type TypeAnalysis struct {
+	Keys    int64
+	Bytes   int64
+	Members Histogram // 每个键的成员数（字段、元素、条目等），字符串类的类型为空
+}
+
+// PrefixAnalysis 某一键前缀的统计
+type PrefixAnalysis struct {
+	Prefix string
+	Keys   int64
+	Bytes  int64
+}
+
+// AnalyzeTTLBuckets TTL 分布的各桶：剩余时间不超过 Max 的键计入第一个满足的桶，最后一桶没有上限
+var AnalyzeTTLBuckets = []struct {
+	Name string
+	Max  time.Duration
+}{
+	{"le_1m", time.Minute},
+	{"le_1h", time.Hour},
+	{"le_1d", 24 * time.Hour},
+	{"le_7d", 7 * 24 * time.Hour},
+	{"gt_7d", 0},
+}
+
+// KeyspaceAnalysis 一次完整扫描的结果。键的字节数为其全部 Badger 键的键长与值长之和
+type KeyspaceAnalysis struct {
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Keys       int64
+	Bytes      int64
+	KeySize    Histogram
+	Types      map[string]*TypeAnalysis
+	NoTTL      int64
+	TTL        []int64 // 与 AnalyzeTTLBuckets 一一对应
+
+	PrefixesByKeys  []PrefixAnalysis
+	PrefixesByBytes []PrefixAnalysis
+}
+
+// keyspaceAnalyzer 后台分析的状态，结果缓存到下次分析完成
+type keyspaceAnalyzer struct {
+	mu      sync.Mutex
+	running bool
+	stop    chan struct{}
+	last    *KeyspaceAnalysis
+	scanned atomic.Int64 // 本次分析已扫描的用户键数
+}
+
+// StartAnalyze 在后台扫描键空间，完成后替换缓存的结果。已有分析在运行时返回 ErrAnalyzeRunning
+func (s *BotreonStore) StartAnalyze(opts AnalyzeOptions) error {
+	a := &s.analyzer
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.running {
+		return ErrAnalyzeRunning
+	}
+	a.running = true
+	a.stop = make(chan struct{})
+	a.scanned.Store(0)
+	go func(stop chan struct{}) {
+		result, err := s.analyzeKeyspace(opts, stop)
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.running = false
+		if err == nil && result != nil {
+			a.last = result
+		}
+	}(a.stop)
+	return nil
+}
+
+// CancelAnalyze 停止正在运行的分析（保留上次的结果），没有分析在运行时返回 false
+func (s *BotreonStore) CancelAnalyze() bool {
+	a := &s.analyzer
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.running || a.stop == nil {
+		return false
+	}
+	close(a.stop)
+	a.stop = nil
+	return true
+}
+
+// AnalyzeStatus 返回是否有分析在运行、本次已扫描的键数和最近一次完成的结果（没有时为 nil）
+func (s *BotreonStore) AnalyzeStatus() (running bool, scanned int64, last *KeyspaceAnalysis) {
+	a := &s.analyzer
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.running, a.scanned.Load(), a.last
+}
+
+// analyzeKeyspace 按用户键顺序分批扫描，每个键在单独的读事务中统计，避免长时间持有事务；
+// 按读取的 Badger 键数限速。stop 关闭时返回 nil 结果
+func (s *BotreonStore) analyzeKeyspace(opts AnalyzeOptions, stop <-chan struct{}) (*KeyspaceAnalysis, error) {
+	if opts.ReadsPerSecond <= 0 {
+		opts.ReadsPerSecond = DefaultAnalyzeReadsPerSecond
+	}
+	if opts.TopPrefixes <= 0 {
+		opts.TopPrefixes = DefaultAnalyzeTopPrefixes
+	}
+	if opts.PrefixDelimiter == "" {
+		opts.PrefixDelimiter = DefaultAnalyzePrefixDelimiter
+	}
+
+	result := &KeyspaceAnalysis{
+		StartedAt: s.now(),
+		Types:     make(map[string]*TypeAnalysis),
+		TTL:       make([]int64, len(AnalyzeTTLBuckets)),
+	}
+	prefixes := make(map[string]*PrefixAnalysis)
+	start := time.Now()
+	var reads int64
+	var cursor []byte
+	for {
+		keys, err := s.analyzeNextKeys(cursor, analyzeBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) == 0 {
+			break
+		}
+		cursor = TypeOfKeyGet(keys[len(keys)-1])
+		for _, key := range keys {
+			select {
+			case <-stop:
+				return nil, nil
+			default:
+			}
+			n, err := s.analyzeKey(key, opts.PrefixDelimiter, result, prefixes)
+			if err != nil {
+				return nil, err
+			}
+			s.analyzer.scanned.Add(1)
+			reads += n
+			// 限速：按已读取的键数计算应耗费的时间，超前时等待
+			if wait := time.Duration(reads)*time.Second/time.Duration(opts.ReadsPerSecond) - time.Since(start); wait > 0 {
+				select {
+				case <-stop:
+					return nil, nil
+				case <-time.After(wait):
+				}
+			}
+		}
+	}
+
+	list := make([]PrefixAnalysis, 0, len(prefixes))
+	for _, p := range prefixes {
+		list = append(list, *p)
+	}
+	result.PrefixesByKeys = topPrefixes(list, opts.TopPrefixes, func(p PrefixAnalysis) int64 { return p.Keys })
+	result.PrefixesByBytes = topPrefixes(list, opts.TopPrefixes, func(p PrefixAnalysis) int64 { return p.Bytes })
+	result.FinishedAt = s.now()
+	return result, nil
+}
+
+// analyzeNextKeys 返回类型键 after 之后的至多 limit 个用户键
+func (s *BotreonStore) analyzeNextKeys(after []byte, limit int) ([]string, error) {
+	var keys []string
+	err := s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefixKeyTypeBytes
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		if after == nil {
+			it.Rewind()
+		} else {
+			it.Seek(after)
+			if it.Valid() && bytes.Equal(it.Item().Key(), after) {
+				it.Next()
+			}
+		}
+		for ; it.Valid() && len(keys) < limit; it.Next() {
+			keys = append(keys, string(it.Item().Key()[len(prefixKeyTypeBytes):]))
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// analyzeKey 统计一个用户键，返回读取的 Badger 键数
+func (s *BotreonStore) analyzeKey(key, delimiter string, result *KeyspaceAnalysis, prefixes map[string]*PrefixAnalysis) (int64, error) {
+	var reads, size int64
+	var expiresAt time.Time
+	var keyType []byte
+	roles := make(map[string]int64) // 角色 -> 成员键数
+	err := s.view(func(txn *storeTxn) error {
+		var err error
+		keyType, err = walkKeyLayout(txn, key, func(item *badger.Item, part keyLayoutPart) {
+			reads++
+			size += int64(len(item.Key())) + item.ValueSize()
+			if exp := item.ExpiresAt(); exp > 0 && expiresAtTime(exp).After(expiresAt) {
+				expiresAt = expiresAtTime(exp)
+			}
+			if part.Role == expireKeyPart.Role {
+				_ = item.Value(func(val []byte) error {
+					expiresAt = expiresAtTime(helper.BytesToUint64(val))
+					return nil
+				})
+			}
+			roles[part.Role]++
+		})
+		return err
+	})
+	if err != nil || keyType == nil {
+		// 扫描期间被删除的键直接跳过
+		return reads, err
+	}
+
+	result.Keys++
+	result.Bytes += size
+	result.KeySize.Add(size)
+	t := result.Types[string(keyType)]
+	if t == nil {
+		t = &TypeAnalysis{}
+		result.Types[string(keyType)] = t
+	}
+	t.Keys++
+	t.Bytes += size
+	if role, ok := analyzeMemberRoles[string(keyType)]; ok {
+		t.Members.Add(roles[role])
+	}
+
+	if expiresAt.IsZero() {
+		result.NoTTL++
+	} else {
+		ttl := expiresAt.Sub(s.now())
+		for i, b := range AnalyzeTTLBuckets {
+			if b.Max == 0 || ttl <= b.Max {
+				result.TTL[i]++
+				break
+			}
+		}
+	}
+
+	prefix := key
+	if i := strings.Index(key, delimiter); i >= 0 {
+		prefix = key[:i]
+	}
+	p := prefixes[prefix]
+	if p == nil {
+		if len(prefixes) >= analyzeMaxPrefixes {
+			prefix = AnalyzeOtherPrefix
+			p = prefixes[prefix]
+		}
+		if p == nil {
+			p = &PrefixAnalysis{Prefix: prefix}
+			prefixes[prefix] = p
+		}
+	}
+	p.Keys++
+	p.Bytes += size
+	return reads, nil
+}
+
+// analyzeMemberRoles 各集合类型中每个成员对应的键角色（见 keyLayouts）
+var analyzeMemberRoles = map[string]string{
+	KeyTypeList:       "element",
+	KeyTypeHash:       "field",
+	KeyTypeSet:        "member",
+	KeyTypeSortedSet:  "member",
+	KeyTypeStream:     "entry",
+	KeyTypeTimeSeries: "sample",
+	KeyTypeGeo:        "member",
+}
+
+// topPrefixes 按 value 从大到小取前 n 个前缀，相同时按前缀排序
+func topPrefixes(list []PrefixAnalysis, n int, value func(PrefixAnalysis) int64) []PrefixAnalysis {
+	sorted := make([]PrefixAnalysis, len(list))
+	copy(sorted, list)
+	sort.Slice(sorted, func(i, j int) bool {
+		if value(sorted[i]) != value(sorted[j]) {
+			return value(sorted[i]) > value(sorted[j])
+		}
+		return sorted[i].Prefix < sorted[j].Prefix
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestAnalyzeKeyspace(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBadgerStore(dir)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.Set("user:1", "alice"))
+	assert.NoError(t, store.SetWithTTL("user:2", "bob", 30*time.Second))
+	assert.NoError(t, store.SetWithTTL("session:1", "token", 2*time.Hour))
+	assert.NoError(t, store.HSet("user:1:profile", "name", "alice"))
+	assert.NoError(t, store.HSet("user:1:profile", "age", "30"))
+	_, err = store.RPush("queue", "a", "b", "c")
+	assert.NoError(t, err)
+	_, err = store.SAdd("tags", "x")
+	assert.NoError(t, err)
+
+	result, err := store.analyzeKeyspace(AnalyzeOptions{TopPrefixes: 2}, make(chan struct{}))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), result.Keys)
+
+	var histogramKeys int64
+	for _, n := range result.KeySize.Buckets {
+		histogramKeys += n
+	}
+	assert.Equal(t, result.Keys, histogramKeys)
+
+	assert.Equal(t, int64(3), result.Types[KeyTypeString].Keys)
+	assert.Equal(t, 0, len(result.Types[KeyTypeString].Members.Buckets))
+	// 2 个字段落在 (1, 2] 桶，3 个元素落在 (2, 4] 桶
+	assert.Equal(t, []int64{0, 1}, result.Types[KeyTypeHash].Members.Buckets)
+	assert.Equal(t, []int64{0, 0, 1}, result.Types[KeyTypeList].Members.Buckets)
+	assert.Equal(t, []int64{1}, result.Types[KeyTypeSet].Members.Buckets)
+
+	assert.Equal(t, int64(4), result.NoTTL)
+	assert.Equal(t, []int64{1, 0, 1, 0, 0}, result.TTL)
+
+	assert.Equal(t, 2, len(result.PrefixesByKeys))
+	assert.Equal(t, "user", result.PrefixesByKeys[0].Prefix)
+	assert.Equal(t, int64(3), result.PrefixesByKeys[0].Keys)
+	assert.Equal(t, 2, len(result.PrefixesByBytes))
+	assert.True(t, result.PrefixesByBytes[0].Bytes >= result.PrefixesByBytes[1].Bytes)
+
+	// 停止时不返回结果
+	stop := make(chan struct{})
+	close(stop)
+	result, err = store.analyzeKeyspace(AnalyzeOptions{}, stop)
+	assert.NoError(t, err)
+	assert.Nil(t, result)
+}
+
+func TestAnalyzeBackground(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBadgerStore(dir)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	for i := 0; i < 20; i++ {
+		assert.NoError(t, store.Set(string(rune('a'+i)), "v"))
+	}
+
+	// 每秒只读 10 个键：分析持续约 4 秒，期间可以取消
+	assert.NoError(t, store.StartAnalyze(AnalyzeOptions{ReadsPerSecond: 10}))
+	assert.Equal(t, ErrAnalyzeRunning, store.StartAnalyze(AnalyzeOptions{}))
+	assert.True(t, store.CancelAnalyze())
+	waitAnalyze(t, store)
+	_, _, last := store.AnalyzeStatus()
+	assert.Nil(t, last)
+	assert.False(t, store.CancelAnalyze())
+
+	assert.NoError(t, store.StartAnalyze(AnalyzeOptions{}))
+	waitAnalyze(t, store)
+	_, scanned, last := store.AnalyzeStatus()
+	assert.Equal(t, int64(20), scanned)
+	assert.Equal(t, int64(20), last.Keys)
+}
+
+func waitAnalyze(t *testing.T, store *BotreonStore) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if running, _, _ := store.AnalyzeStatus(); !running {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("analysis did not finish")
+}
+
+func TestHistogram(t *testing.T) {
+	var h Histogram
+	for _, v := range []int64{0, 1, 2, 3, 4, 5, 1024} {
+		h.Add(v)
+	}
+	assert.Equal(t, []int64{2, 1, 2, 1, 0, 0, 0, 0, 0, 0, 1}, h.Buckets)
+	assert.Equal(t, int64(1024), HistogramBound(10))
+}
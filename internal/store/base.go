@@ -14,102 +14,43 @@ import (
 
 // Del 删除键，返回删除的数量
 func (s *BotreonStore) Del(key string) (int64, error) {
-	typeKey := TypeOfKeyGet(key)
 	var deleted int64
-
-	err := s.db.Update(func(txn *badger.Txn) error {
-		item, err := txn.Get(typeKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return nil // 键不存在
-		}
-		if err != nil {
-			return err
-		}
-		valCopy, err := item.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-		keyType := string(valCopy)
-
-		// 清除读缓存
-		if s.readCache != nil {
-			s.readCache.Delete(key)
+	// 与主动过期同时删除同一个键时冲突重试
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		deleted = 0
+		ok, err := s.delTxn(txn, key)
+		if ok {
+			deleted = 1
 		}
-
-		switch keyType {
-		case KeyTypeString:
-			if err := txn.Delete(typeKey); err != nil {
-				return err
-			}
-			if err := txn.Delete([]byte(s.stringKey(key))); err != nil {
-				return err
-			}
-		case KeyTypeList:
-			if err := deleteByPrefix(txn, []byte(fmt.Sprintf("%s:%s:", KeyTypeList, key))); err != nil {
-				return err
-			}
-			if err := txn.Delete(typeKey); err != nil {
-				return err
-			}
-		case KeyTypeHash:
-			if err := deleteByPrefix(txn, []byte(fmt.Sprintf("%s:%s:", KeyTypeHash, key))); err != nil {
-				return err
-			}
-			if err := txn.Delete(typeKey); err != nil {
-				return err
-			}
-		case KeyTypeSet:
-			if err := deleteByPrefix(txn, []byte(fmt.Sprintf("%s:%s:", KeyTypeSet, key))); err != nil {
-				return err
-			}
-			if err := txn.Delete(typeKey); err != nil {
-				return err
-			}
-		case KeyTypeSortedSet:
-			if err := deleteByPrefix(txn, []byte(fmt.Sprintf("%s%s:", prefixKeySortedSetBytes, key))); err != nil {
-				return err
-			}
-			if err := txn.Delete(typeKey); err != nil {
-				return err
-			}
-		case KeyTypeJSON:
-			if err := txn.Delete([]byte(s.jsonKey(key))); err != nil {
-				return err
-			}
-			if err := txn.Delete(typeKey); err != nil {
-				return err
-			}
-		case KeyTypeTimeSeries:
-			if err := deleteByPrefix(txn, []byte(fmt.Sprintf("%s%s:", prefixTS, key))); err != nil {
-				return err
-			}
-			if err := txn.Delete(typeKey); err != nil {
-				return err
-			}
-		default:
-			if err := txn.Delete(typeKey); err != nil {
-				return err
-			}
-		}
-		deleted = 1
-		return nil
-	})
+		return err
+	}, 30)
+	if errors.Is(err, badger.ErrTxnTooBig) {
+		// 数据超过单个事务的大小上限（如几十万个字段的哈希）时分批删除
+		return s.unlinkKey(key, false)
+	}
+	if err == nil && deleted == 1 {
+		s.notifyZWatch(key, nil, nil, true)
+	}
 
 	return deleted, err
 }
 
+// delTxn 在 txn 中删除键的全部数据（按 keyLayouts 登记的编码方式），返回键是否存在
+func (s *BotreonStore) delTxn(txn *storeTxn, key string) (bool, error) {
+	keyType, err := deleteKeyLayoutTxn(txn, key)
+	if err != nil || keyType == nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (s *BotreonStore) DelString(key string) error {
 	logFuncTag := "BotreonStoreDelString"
 	bKey := []byte(key)
 	badgerTypeKey := TypeOfKeyGet(key)
 	badgerValueKey := s.stringKey(string(bKey))
 	
-	// 清除读缓存
-	if s.readCache != nil {
-		s.readCache.Delete(key)
-	}
-	
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.update(func(txn *storeTxn) error {
 		errDel := txn.Delete(badgerTypeKey)
 		if errDel != nil {
 			return fmt.Errorf("%s,Del Badger Type Key:%v", logFuncTag, errDel)
@@ -122,7 +63,7 @@ func (s *BotreonStore) DelString(key string) error {
 	})
 }
 
-func deleteByPrefix(txn *badger.Txn, prefix []byte) error {
+func deleteByPrefix(txn *storeTxn, prefix []byte) error {
 	opts := badger.DefaultIteratorOptions
 	opts.PrefetchValues = false
 	iter := txn.NewIterator(opts)
@@ -146,7 +87,7 @@ func (s *BotreonStore) getKeyValueKey(key string, keyType string) ([]byte, error
 		return []byte(s.listKey(key, "length")), nil
 	case KeyTypeHash:
 		// Hash的主键是count键
-		return []byte(fmt.Sprintf("%s:%s:count", KeyTypeHash, key)), nil
+		return s.hashCountKey(key), nil
 	case KeyTypeSet:
 		// Set的主键是count键
 		return []byte(s.setKey(key, "count")), nil
@@ -159,6 +100,8 @@ func (s *BotreonStore) getKeyValueKey(key string, keyType string) ([]byte, error
 	case KeyTypeTimeSeries:
 		// TimeSeries的主键是meta键
 		return tsMetaKey(key), nil
+	case keyTypeHyperLogLog:
+		return hllKey(key), nil
 	default:
 		return nil, fmt.Errorf("unknown key type: %s", keyType)
 	}
@@ -167,7 +110,7 @@ func (s *BotreonStore) getKeyValueKey(key string, keyType string) ([]byte, error
 // EXISTS 实现 Redis EXISTS 命令，检查键是否存在
 func (s *BotreonStore) Exists(key string) (bool, error) {
 	exists := false
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		typeKey := TypeOfKeyGet(key)
 		_, err := txn.Get(typeKey)
 		if err == nil {
@@ -185,7 +128,7 @@ func (s *BotreonStore) Exists(key string) (bool, error) {
 // Type 实现 Redis TYPE 命令，返回键的类型
 func (s *BotreonStore) Type(key string) (string, error) {
 	var keyType string
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		typeKey := TypeOfKeyGet(key)
 		item, err := txn.Get(typeKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -201,24 +144,7 @@ func (s *BotreonStore) Type(key string) (string, error) {
 		}
 		keyType = string(val)
 		// 将内部类型转换为Redis类型
-		switch keyType {
-		case KeyTypeString:
-			keyType = "string"
-		case KeyTypeList:
-			keyType = "list"
-		case KeyTypeHash:
-			keyType = "hash"
-		case KeyTypeSet:
-			keyType = "set"
-		case KeyTypeSortedSet:
-			keyType = "zset"
-		case KeyTypeJSON:
-			keyType = "json"
-		case KeyTypeTimeSeries:
-			keyType = "ts"
-		default:
-			keyType = "none"
-		}
+		keyType = redisTypeName(keyType)
 		return nil
 	})
 	return keyType, err
@@ -226,59 +152,12 @@ func (s *BotreonStore) Type(key string) (string, error) {
 
 // EXPIRE 实现 Redis EXPIRE 命令，设置键的过期时间（秒）
 func (s *BotreonStore) Expire(key string, seconds int) (bool, error) {
-	success := false
-	err := s.db.Update(func(txn *badger.Txn) error {
-		typeKey := TypeOfKeyGet(key)
-		item, err := txn.Get(typeKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return nil // 键不存在，返回false
-		}
-		if err != nil {
-			return err
-		}
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-		keyType := string(val)
-
-		// 获取值键
-		valueKey, err := s.getKeyValueKey(key, keyType)
-		if err != nil {
-			return err
-		}
-
-		// 获取当前值
-		valueItem, err := txn.Get(valueKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return nil
-		}
-		if err != nil {
-			return err
-		}
-
-		valBytes, err := valueItem.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-
-		// 直接设置TTL：计算过期时间戳（纳秒）
-		expiresAt := uint64(time.Now().UnixNano()) + uint64(seconds)*uint64(time.Second)
-		e := badger.NewEntry(valueKey, valBytes)
-		e.ExpiresAt = expiresAt
-		if err := txn.SetEntry(e); err != nil {
-			return err
-		}
-
-		success = true
-		return nil
-	})
-	return success, err
+	return s.expireAt(key, s.now().Add(time.Duration(seconds)*time.Second))
 }
 
 // EXPIREAT 实现 Redis EXPIREAT 命令，设置键的过期时间（Unix时间戳，秒）
 func (s *BotreonStore) ExpireAt(key string, timestamp int64) (bool, error) {
-	now := time.Now().Unix()
+	now := s.now().Unix()
 	ttl := timestamp - now
 	if ttl <= 0 {
 		// 时间戳已过期，删除键
@@ -290,59 +169,34 @@ func (s *BotreonStore) ExpireAt(key string, timestamp int64) (bool, error) {
 
 // PEXPIRE 实现 Redis PEXPIRE 命令，设置键的过期时间（毫秒）
 func (s *BotreonStore) PExpire(key string, milliseconds int64) (bool, error) {
-	success := false
-	err := s.db.Update(func(txn *badger.Txn) error {
-		typeKey := TypeOfKeyGet(key)
-		item, err := txn.Get(typeKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return nil // 键不存在，返回false
-		}
-		if err != nil {
-			return err
-		}
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-		keyType := string(val)
-
-		// 获取值键
-		valueKey, err := s.getKeyValueKey(key, keyType)
-		if err != nil {
-			return err
-		}
-
-		// 获取当前值
-		valueItem, err := txn.Get(valueKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return nil
-		}
-		if err != nil {
-			return err
-		}
-
-		valBytes, err := valueItem.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
+	return s.expireAt(key, s.now().Add(time.Duration(milliseconds)*time.Millisecond))
+}
 
-		// 直接设置TTL：计算过期时间戳（纳秒）
-		expiresAt := uint64(time.Now().UnixNano()) + uint64(milliseconds)*uint64(time.Millisecond)
-		e := badger.NewEntry(valueKey, valBytes)
-		e.ExpiresAt = expiresAt
-		if err := txn.SetEntry(e); err != nil {
+// expireAt 设置任意类型的键的过期时间（见 expire.go 中的过期时间记录），键不存在时返回 false。
+// 过期时间不晚于当前时间时与 Redis 相同地直接删除键
+func (s *BotreonStore) expireAt(key string, at time.Time) (bool, error) {
+	if !at.After(s.now()) {
+		deleted, err := s.Del(key)
+		return deleted > 0, err
+	}
+	success := false
+	// 字符串的值在事务中读取后重写，与并发写入或主动过期冲突时重试
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		success = false
+		keyType, err := keyTypeTxn(txn, key)
+		if err != nil || keyType == "" {
 			return err
 		}
-
-		success = true
-		return nil
-	})
+		// #nosec G115 - 过期时间在当前时间之后，UnixNano 为正数
+		success, err = s.setExpiresAtTxn(txn, key, keyType, uint64(at.UnixNano()))
+		return err
+	}, 30)
 	return success, err
 }
 
 // PEXPIREAT 实现 Redis PEXPIREAT 命令，设置键的过期时间（Unix时间戳，毫秒）
 func (s *BotreonStore) PExpireAt(key string, timestampMillis int64) (bool, error) {
-	now := time.Now().UnixNano() / int64(time.Millisecond)
+	now := s.now().UnixNano() / int64(time.Millisecond)
 	ttl := timestampMillis - now
 	if ttl <= 0 {
 		// 时间戳已过期，删除键
@@ -354,432 +208,56 @@ func (s *BotreonStore) PExpireAt(key string, timestampMillis int64) (bool, error
 
 // TTL 实现 Redis TTL 命令，获取键的剩余生存时间（秒）
 func (s *BotreonStore) TTL(key string) (int64, error) {
-	var ttl int64 = -2 // -2表示键不存在
-	err := s.db.View(func(txn *badger.Txn) error {
-		typeKey := TypeOfKeyGet(key)
-		item, err := txn.Get(typeKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return nil // 键不存在，返回-2
-		}
-		if err != nil {
-			return err
-		}
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-		keyType := string(val)
-
-		// 获取值键
-		valueKey, err := s.getKeyValueKey(key, keyType)
-		if err != nil {
-			return err
-		}
-
-		// 获取值键的TTL
-		valueItem, err := txn.Get(valueKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			ttl = -2
-			return nil
-		}
-		if err != nil {
-			return err
-		}
-
-		// ExpiresAt 返回的是 Unix 纳秒时间戳 (uint64)
-		expiresAt := valueItem.ExpiresAt()
-		if expiresAt == 0 {
-			ttl = -1 // -1表示键存在但没有设置过期时间
-			return nil
-		}
-
-		// #nosec G115 - expiresAt is a valid Unix timestamp within int64 range
-		nowNano := time.Now().UnixNano()
-		ttl = int64(expiresAt-uint64(nowNano)) / int64(time.Second)
-		if ttl < 0 {
-			ttl = -2 // 已过期
-		}
-		return nil
-	})
-	return ttl, err
+	remainingMs, err := s.PTTL(key)
+	if err != nil || remainingMs < 0 {
+		return remainingMs, err
+	}
+	// 与 Redis 一致按毫秒四舍五入到秒
+	return (remainingMs + 500) / 1000, nil
 }
 
-// PTTL 实现 Redis PTTL 命令，获取键的剩余生存时间（毫秒）
+// PTTL 实现 Redis PTTL 命令，获取键的剩余生存时间（毫秒）。
+// 键不存在或已过期时返回 -2，没有过期时间时返回 -1
 func (s *BotreonStore) PTTL(key string) (int64, error) {
-	var ttl int64 = -2 // -2表示键不存在
-	err := s.db.View(func(txn *badger.Txn) error {
-		typeKey := TypeOfKeyGet(key)
-		item, err := txn.Get(typeKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return nil // 键不存在，返回-2
-		}
-		if err != nil {
-			return err
-		}
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-		keyType := string(val)
-
-		// 获取值键
-		valueKey, err := s.getKeyValueKey(key, keyType)
-		if err != nil {
-			return err
-		}
-
-		// 获取值键的TTL
-		valueItem, err := txn.Get(valueKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			ttl = -2
-			return nil
-		}
-		if err != nil {
-			return err
-		}
-
-		// ExpiresAt 返回的是 Unix 纳秒时间戳 (uint64)
-		expiresAt := valueItem.ExpiresAt()
-		if expiresAt == 0 {
-			ttl = -1 // -1表示键存在但没有设置过期时间
-			return nil
-		}
-
-		// #nosec G115 - expiresAt is a valid Unix timestamp within int64 range
-		nowNano := time.Now().UnixNano()
-		ttl = int64(expiresAt-uint64(nowNano)) / int64(time.Millisecond)
-		if ttl < 0 {
-			ttl = -2 // 已过期
-		}
-		return nil
-	})
-	return ttl, err
-}
-
-// PERSIST 实现 Redis PERSIST 命令，移除键的过期时间
-func (s *BotreonStore) Persist(key string) (bool, error) {
-	// 先读取键的类型和值键（在 View 事务中）
-	var valueKey []byte
-	var hasTTL bool
-	err := s.db.View(func(txn *badger.Txn) error {
-		typeKey := TypeOfKeyGet(key)
-		item, err := txn.Get(typeKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return ErrKeyNotFound // 键不存在
-		}
-		if err != nil {
-			return err
-		}
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-		keyType := string(val)
-
-		// 获取值键
-		vk, err := s.getKeyValueKey(key, keyType)
-		if err != nil {
-			return err
-		}
-		valueKey = vk
-
-		// 检查是否有TTL
-		valueItem, err := txn.Get(valueKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return ErrKeyNotFound
-		}
-		if err != nil {
-			return err
-		}
-		hasTTL = valueItem.ExpiresAt() != 0
-		return nil
-	})
-	if err != nil {
-		return false, nil // 键不存在或没有TTL
+	expiresAt, exists, err := s.keyExpiresAt(key)
+	if err != nil || !exists {
+		return -2, err
 	}
-	if !hasTTL {
-		return false, nil
+	if expiresAt.IsZero() {
+		return -1, nil
 	}
-
-	// 重新读取值并写入无TTL副本（在 Update 事务中）
-	var valBytes []byte
-	err = s.db.Update(func(txn *badger.Txn) error {
-		valueItem, err := txn.Get(valueKey)
-		if err != nil {
-			return err
-		}
-		valBytes, err = valueItem.ValueCopy(nil)
-		return err
-	})
-	if err != nil {
-		return false, err
+	ttl := expiresAt.Sub(s.now()).Milliseconds()
+	if ttl < 0 {
+		return -2, nil // 已过期但尚未被删除
 	}
-
-	// 写入无TTL的值
-	return true, s.db.Update(func(txn *badger.Txn) error {
-		return txn.Set(valueKey, valBytes)
-	})
-}
-
-// RENAME 实现 Redis RENAME 命令，重命名键
-func (s *BotreonStore) Rename(key, newKey string) error {
-	// 清除读缓存
-	if s.readCache != nil {
-		s.readCache.Delete(key)
-		s.readCache.Delete(newKey)
-	}
-	return s.db.Update(func(txn *badger.Txn) error {
-		// 检查旧键是否存在
-		typeKey := TypeOfKeyGet(key)
-		item, err := txn.Get(typeKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return fmt.Errorf("no such key")
-		}
-		if err != nil {
-			return err
-		}
-
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-		keyType := string(val)
-
-		// 如果新键存在，先删除它（在同一事务中）
-		newTypeKey := TypeOfKeyGet(newKey)
-		newItem, err := txn.Get(newTypeKey)
-		if err == nil {
-			// 新键存在，需要删除
-			newVal, err := newItem.ValueCopy(nil)
-			if err != nil {
-				return err
-			}
-			newKeyType := string(newVal)
-			// 删除新键的所有相关数据
-			switch newKeyType {
-			case KeyTypeString:
-				_ = txn.Delete(newTypeKey)
-				_ = txn.Delete([]byte(s.stringKey(newKey)))
-			case KeyTypeList:
-				if err := deleteByPrefix(txn, []byte(fmt.Sprintf("%s:%s:", KeyTypeList, newKey))); err != nil {
-					return err
-				}
-				_ = txn.Delete(newTypeKey)
-			case KeyTypeHash:
-				if err := deleteByPrefix(txn, []byte(fmt.Sprintf("%s:%s:", KeyTypeHash, newKey))); err != nil {
-					return err
-				}
-				_ = txn.Delete(newTypeKey)
-			case KeyTypeSet:
-				if err := deleteByPrefix(txn, []byte(fmt.Sprintf("%s:%s:", KeyTypeSet, newKey))); err != nil {
-					return err
-				}
-				_ = txn.Delete(newTypeKey)
-			case KeyTypeSortedSet:
-				if err := deleteByPrefix(txn, []byte(fmt.Sprintf("%s%s:", prefixKeySortedSetBytes, newKey))); err != nil {
-					return err
-				}
-				_ = txn.Delete(newTypeKey)
-			default:
-				_ = txn.Delete(newTypeKey)
-			}
-		} else if !errors.Is(err, badger.ErrKeyNotFound) {
-			return err
-		}
-
-		// 根据类型复制所有相关键
-		switch keyType {
-		case KeyTypeString:
-			oldValueKey := []byte(s.stringKey(key))
-			oldValue, err := txn.Get(oldValueKey)
-			if err != nil {
-				return err
-			}
-			valueBytes, err := oldValue.ValueCopy(nil)
-			if err != nil {
-				return err
-			}
-			// 设置新键
-			if err := txn.Set(newTypeKey, []byte(keyType)); err != nil {
-				return err
-			}
-			newValueKey := []byte(s.stringKey(newKey))
-			// 保持TTL
-			expiresAt := oldValue.ExpiresAt()
-			if expiresAt > 0 {
-				// #nosec G115 - expiresAt is a valid Unix timestamp within int64 range
-				ttl := time.Until(time.Unix(int64(expiresAt), 0))
-				if ttl > 0 {
-					e := badger.NewEntry(newValueKey, valueBytes).WithTTL(ttl)
-					if err := txn.SetEntry(e); err != nil {
-						return err
-					}
-				} else {
-					// TTL已过期，不设置
-					if err := txn.Set(newValueKey, valueBytes); err != nil {
-						return err
-					}
-				}
-			} else {
-				if err := txn.Set(newValueKey, valueBytes); err != nil {
-					return err
-				}
-			}
-			// 删除旧键
-			_ = txn.Delete(typeKey)
-			_ = txn.Delete(oldValueKey)
-			return nil
-		case KeyTypeList:
-			// 复制所有LIST键
-			prefix := []byte(fmt.Sprintf("%s:%s:", KeyTypeList, key))
-			if err := copyKeysByPrefix(txn, prefix, key, newKey, KeyTypeList); err != nil {
-				return err
-			}
-			if err := txn.Set(newTypeKey, []byte(keyType)); err != nil {
-				return err
-			}
-			// 删除旧键
-			if err := deleteByPrefix(txn, prefix); err != nil {
-				return err
-			}
-			return txn.Delete(typeKey)
-		case KeyTypeHash:
-			prefix := []byte(fmt.Sprintf("%s:%s:", KeyTypeHash, key))
-			if err := copyKeysByPrefix(txn, prefix, key, newKey, KeyTypeHash); err != nil {
-				return err
-			}
-			if err := txn.Set(newTypeKey, []byte(keyType)); err != nil {
-				return err
-			}
-			// 删除旧键
-			if err := deleteByPrefix(txn, prefix); err != nil {
-				return err
-			}
-			return txn.Delete(typeKey)
-		case KeyTypeSet:
-			prefix := []byte(fmt.Sprintf("%s:%s:", KeyTypeSet, key))
-			if err := copyKeysByPrefix(txn, prefix, key, newKey, KeyTypeSet); err != nil {
-				return err
-			}
-			if err := txn.Set(newTypeKey, []byte(keyType)); err != nil {
-				return err
-			}
-			// 删除旧键
-			if err := deleteByPrefix(txn, prefix); err != nil {
-				return err
-			}
-			return txn.Delete(typeKey)
-		case KeyTypeSortedSet:
-			prefix := []byte(fmt.Sprintf("%s%s:", prefixKeySortedSetBytes, key))
-			if err := copyKeysByPrefix(txn, prefix, key, newKey, KeyTypeSortedSet); err != nil {
-				return err
-			}
-			if err := txn.Set(newTypeKey, []byte(keyType)); err != nil {
-				return err
-			}
-			// 删除旧键
-			if err := deleteByPrefix(txn, prefix); err != nil {
-				return err
-			}
-			return txn.Delete(typeKey)
-		default:
-			if err := txn.Set(newTypeKey, []byte(keyType)); err != nil {
-				return err
-			}
-			return txn.Delete(typeKey)
-		}
-	})
+	return ttl, nil
 }
 
-// copyKeysByPrefix 复制所有匹配前缀的键
-func copyKeysByPrefix(txn *badger.Txn, oldPrefix []byte, oldKey, newKey, keyType string) error {
-	opts := badger.DefaultIteratorOptions
-	opts.PrefetchValues = true
-	iter := txn.NewIterator(opts)
-	defer iter.Close()
-
-	for iter.Seek(oldPrefix); iter.ValidForPrefix(oldPrefix); iter.Next() {
-		item := iter.Item()
-		oldKeyBytes := item.KeyCopy(nil)
-		oldKeyStr := string(oldKeyBytes)
-
-		// 生成新键
-		var newKeyStr string
-		if keyType == KeyTypeSortedSet {
-			// SortedSet使用zset:前缀，格式是zset:oldKey:...
-			// 需要替换为zset:newKey:...
-			oldKeyPrefix := fmt.Sprintf("%s%s:", prefixKeySortedSetBytes, oldKey)
-			newKeyStr = fmt.Sprintf("%s%s:%s", prefixKeySortedSetBytes, newKey, oldKeyStr[len(oldKeyPrefix):])
-		} else {
-			// 其他类型使用TYPE:oldKey:...格式
-			// 需要替换为TYPE:newKey:...
-			oldKeyPrefix := fmt.Sprintf("%s:%s:", keyType, oldKey)
-			newKeyStr = fmt.Sprintf("%s:%s:%s", keyType, newKey, oldKeyStr[len(oldKeyPrefix):])
-		}
-
-		// 复制值
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-
-		// 设置新键（保持TTL）
-		expiresAt := item.ExpiresAt()
-		if expiresAt > 0 {
-			// #nosec G115 - expiresAt is a valid Unix timestamp within int64 range
-			ttl := time.Until(time.Unix(int64(expiresAt), 0))
-			if ttl > 0 {
-				e := badger.NewEntry([]byte(newKeyStr), val).WithTTL(ttl)
-				if err := txn.SetEntry(e); err != nil {
-					return err
-				}
-			} else {
-				// TTL已过期，跳过
-				if err := txn.Set([]byte(newKeyStr), val); err != nil {
-					return err
-				}
-			}
-		} else {
-			if err := txn.Set([]byte(newKeyStr), val); err != nil {
-				return err
-			}
-		}
-	}
-	return nil
-}
-
-// RENAMENX 实现 Redis RENAMENX 命令，仅当新键不存在时重命名
-func (s *BotreonStore) RenameNX(key, newKey string) (bool, error) {
+// PERSIST 实现 Redis PERSIST 命令，移除键的过期时间
+func (s *BotreonStore) Persist(key string) (bool, error) {
 	success := false
-	err := s.db.Update(func(txn *badger.Txn) error {
-		// 检查新键是否已存在
-		newTypeKey := TypeOfKeyGet(newKey)
-		_, err := txn.Get(newTypeKey)
-		if err == nil {
-			// 新键已存在，返回false
-			return nil
-		}
-		if !errors.Is(err, badger.ErrKeyNotFound) {
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		success = false
+		keyType, err := keyTypeTxn(txn, key)
+		if err != nil || keyType == "" {
 			return err
 		}
-
-		// 新键不存在，执行重命名
-		if err := s.Rename(key, newKey); err != nil {
-			return err
+		exp, err := s.expiresAtTxn(txn, key, keyType)
+		if err != nil || exp == 0 || !expiresAtTime(exp).After(s.now()) {
+			return err // 没有过期时间或已过期
 		}
-		success = true
-		return nil
-	})
+		success, err = s.setExpiresAtTxn(txn, key, keyType, 0)
+		return err
+	}, 30)
 	return success, err
 }
 
-// matchPattern 检查键是否匹配模式（支持*和?通配符）
+// matchPattern 检查键是否匹配模式，规则与 Redis 的 glob 相同：
+// * 匹配任意串，? 匹配单个字符，[abc]、[^abc]、[a-z] 匹配字符集合，\ 转义下一个字符
 func matchPattern(key, pattern string) bool {
-	// 简单的通配符匹配实现
 	if pattern == "*" {
 		return true
 	}
-	// 使用简单的字符串匹配（可以改进为正则表达式）
 	keyRunes := []rune(key)
 	patternRunes := []rune(pattern)
 
@@ -788,26 +266,29 @@ func matchPattern(key, pattern string) bool {
 	keyStar := -1
 	patternStar := -1
 
-	for keyIdx < len(keyRunes) || patternIdx < len(patternRunes) {
+	for keyIdx < len(keyRunes) {
 		if patternIdx < len(patternRunes) && patternRunes[patternIdx] == '*' {
+			for patternIdx < len(patternRunes) && patternRunes[patternIdx] == '*' {
+				patternIdx++
+			}
 			keyStar = keyIdx
 			patternStar = patternIdx
-			patternIdx++
 			continue
 		}
-		if keyIdx < len(keyRunes) && patternIdx < len(patternRunes) &&
-			(patternRunes[patternIdx] == '?' || patternRunes[patternIdx] == keyRunes[keyIdx]) {
-			keyIdx++
-			patternIdx++
-			continue
+		if patternIdx < len(patternRunes) {
+			if ok, next := matchRune(patternRunes, patternIdx, keyRunes[keyIdx]); ok {
+				keyIdx++
+				patternIdx = next
+				continue
+			}
 		}
-		if keyStar >= 0 {
-			keyStar++
-			keyIdx = keyStar
-			patternIdx = patternStar + 1
-			continue
+		// 不匹配时回到上一个 * ，让它多匹配一个字符
+		if patternStar < 0 {
+			return false
 		}
-		return false
+		keyStar++
+		keyIdx = keyStar
+		patternIdx = patternStar
 	}
 
 	// 处理pattern末尾的*
@@ -818,10 +299,60 @@ func matchPattern(key, pattern string) bool {
 	return patternIdx == len(patternRunes)
 }
 
-// Keys 实现 Redis KEYS 命令，查找所有匹配给定模式的键
+// MatchPattern 按 Redis 的 glob 规则检查 key 是否匹配 pattern，与 KEYS、SCAN MATCH 相同
+func MatchPattern(key, pattern string) bool {
+	return matchPattern(key, pattern)
+}
+
+// matchRune 检查 pattern[idx:] 开头的单字符匹配项（字面字符、?、转义或 [...] 集合）是否匹配 c，
+// 返回该匹配项之后的位置
+func matchRune(pattern []rune, idx int, c rune) (bool, int) {
+	switch pattern[idx] {
+	case '?':
+		return true, idx + 1
+	case '\\':
+		if idx+1 < len(pattern) {
+			return pattern[idx+1] == c, idx + 2
+		}
+		return c == '\\', idx + 1
+	case '[':
+		i := idx + 1
+		negate := i < len(pattern) && pattern[i] == '^'
+		if negate {
+			i++
+		}
+		matched := false
+		for i < len(pattern) && pattern[i] != ']' {
+			switch {
+			case pattern[i] == '\\' && i+1 < len(pattern):
+				matched = matched || pattern[i+1] == c
+				i += 2
+			case i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']':
+				lo, hi := pattern[i], pattern[i+2]
+				if lo > hi {
+					lo, hi = hi, lo
+				}
+				matched = matched || (c >= lo && c <= hi)
+				i += 3
+			default:
+				matched = matched || pattern[i] == c
+				i++
+			}
+		}
+		// 与 Redis 相同，缺少 ] 时集合延伸到模式末尾
+		if i < len(pattern) {
+			i++
+		}
+		return matched != negate, i
+	}
+	return pattern[idx] == c, idx + 1
+}
+
+// Keys 查找存储中所有匹配给定模式的类型键对应的键名，包括其他数据库的键（带数据库前缀），
+// 用于 FLUSHALL 等处理整个存储的操作。KEYS 命令只返回一个数据库的键，见 DBKeys
 func (s *BotreonStore) Keys(pattern string) ([]string, error) {
 	var keys []string
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
 		iter := txn.NewIterator(opts)
@@ -843,113 +374,57 @@ func (s *BotreonStore) Keys(pattern string) ([]string, error) {
 	return keys, err
 }
 
-// ScanResult 表示SCAN命令的返回结果
-type ScanResult struct {
-	Cursor uint64
-	Keys   []string
-}
-
-// Scan 实现 Redis SCAN 命令，增量迭代键空间
-func (s *BotreonStore) Scan(cursor uint64, pattern string, count int) (ScanResult, error) {
-	var result ScanResult
-	result.Cursor = 0
-	result.Keys = []string{}
-
-	if count <= 0 {
-		count = 10 // 默认值
-	}
-
-	err := s.db.View(func(txn *badger.Txn) error {
+// KeyCount 返回键的数量（包括已过期但尚未被删除的键），只遍历类型键、不读取值
+func (s *BotreonStore) KeyCount() (int64, error) {
+	var n int64
+	err := s.view(func(txn *storeTxn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
+		opts.Prefix = prefixKeyTypeBytes
 		iter := txn.NewIterator(opts)
 		defer iter.Close()
-
-		prefix := prefixKeyTypeBytes
-		currentPos := uint64(0)
-		collected := 0
-
-		// 如果cursor不为0，需要跳过前面的键
-		if cursor > 0 {
-			// 简单实现：从头开始迭代，跳过cursor个键
-			for iter.Seek(prefix); iter.ValidForPrefix(prefix) && currentPos < cursor; iter.Next() {
-				currentPos++
-			}
-		} else {
-			iter.Seek(prefix)
-		}
-
-		// 收集匹配的键
-		for iter.ValidForPrefix(prefix) && collected < count {
-			item := iter.Item()
-			keyBytes := item.KeyCopy(nil)
-			key := string(keyBytes[len(prefixKeyTypeBytes):])
-
-			if pattern == "" || pattern == "*" || matchPattern(key, pattern) {
-				result.Keys = append(result.Keys, key)
-				collected++
-			}
-
-			currentPos++
-			iter.Next()
-		}
-
-		// 检查是否还有更多键
-		if iter.ValidForPrefix(prefix) {
-			result.Cursor = currentPos
-		} else {
-			result.Cursor = 0 // 0表示迭代完成
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			n++
 		}
-
 		return nil
 	})
-	return result, err
+	return n, err
 }
 
-// RandomKey 实现 Redis RANDOMKEY 命令，随机返回一个键
-func (s *BotreonStore) RandomKey() (string, error) {
-	var key string
-	err := s.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = false
-		iter := txn.NewIterator(opts)
-		defer iter.Close()
-
-		prefix := prefixKeyTypeBytes
-
-		// 先计算总数
-		count := 0
-		for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
-			count++
-		}
-
-		if count == 0 {
-			return nil // 没有键
-		}
+// ScanResult 表示SCAN命令的返回结果
+type ScanResult struct {
+	Cursor uint64
+	Keys   []string
+}
 
-		// 随机选择一个位置
-		randPos := 0
-		if count > 1 {
-			// 使用简单的伪随机（实际应该使用crypto/rand）
-			randPos = int(time.Now().UnixNano() % int64(count))
+// Scan 实现 Redis SCAN 命令，增量迭代键空间。每次最多检查 count 个键，只返回匹配 pattern
+// 且类型为 keyType（TYPE 返回的类型名，为空时不限）的键，因此可能返回空页而游标非 0
+func (s *BotreonStore) Scan(cursor uint64, pattern string, count int, keyType string) (ScanResult, error) {
+	result := ScanResult{Keys: []string{}}
+	next, err := s.scanPrefix("scan", prefixKeyTypeBytes, cursor, count, func(item *badger.Item) error {
+		key := string(item.Key()[len(prefixKeyTypeBytes):])
+		if pattern != "" && pattern != "*" && !matchPattern(key, pattern) {
+			return nil
 		}
-
-		// 迭代到随机位置
-		iter.Rewind()
-		current := 0
-		for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
-			if current == randPos {
-				item := iter.Item()
-				keyBytes := item.KeyCopy(nil)
-				key = string(keyBytes[len(prefixKeyTypeBytes):])
+		if keyType != "" {
+			stored, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if !strings.EqualFold(redisTypeName(string(stored)), keyType) {
 				return nil
 			}
-			current++
 		}
-
+		result.Keys = append(result.Keys, key)
 		return nil
 	})
-	return key, err
+	result.Cursor = next
+	return result, err
+}
+
+// RandomKey 随机返回 0 号数据库中的一个键，见 DBRandomKey
+func (s *BotreonStore) RandomKey() (string, error) {
+	return s.DBRandomKey(0)
 }
 
 // ObjectRefCount 实现 Redis OBJECT REFCOUNT 命令，返回键的引用计数
@@ -957,7 +432,7 @@ func (s *BotreonStore) ObjectRefCount(key string) (int64, error) {
 	typeKey := TypeOfKeyGet(key)
 	var refcount int64
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		_, err := txn.Get(typeKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
 			return nil // 键不存在，返回 nil
@@ -980,7 +455,7 @@ func (s *BotreonStore) ObjectEncoding(key string) (string, error) {
 	typeKey := TypeOfKeyGet(key)
 
 	var keyType string
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		item, err := txn.Get(typeKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
 			return nil // 键不存在
@@ -1020,14 +495,6 @@ func (s *BotreonStore) ObjectEncoding(key string) (string, error) {
 	}
 }
 
-// ObjectIdleTime 实现 Redis OBJECT IDLETIME 命令，返回键的空闲时间（秒）
-// 注意：由于 BadgerDB 不直接支持 LRU 追踪，我们返回 0
-func (s *BotreonStore) ObjectIdleTime(key string) (int64, error) {
-	// BadgerDB 不维护访问时间信息，返回 0
-	// 如果需要精确实现，需要额外维护访问时间戳
-	return 0, nil
-}
-
 // writeRDBLength 写入 RDB 长度编码
 func writeRDBLength(buf *bytes.Buffer, length uint64) {
 	if length < 0x40 {
@@ -1057,7 +524,7 @@ func writeRDBBytes(buf *bytes.Buffer, b []byte) {
 // Dump 实现 Redis DUMP 命令，使用标准 RDB 格式序列化键值
 func (s *BotreonStore) Dump(key string) ([]byte, error) {
 	var serializedData []byte
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		// 获取键类型
 		typeKey := TypeOfKeyGet(key)
 		item, err := txn.Get(typeKey)
@@ -1070,19 +537,17 @@ func (s *BotreonStore) Dump(key string) ([]byte, error) {
 		valCopy, _ := item.ValueCopy(nil)
 		keyType := string(valCopy)
 
-		// 获取 TTL（毫秒）
+		// 获取 TTL（毫秒），与 PTTL 相同
 		var ttl int64 = 0
-		expireKey := []byte(key + ":EXPIRE")
-		expireItem, err := txn.Get(expireKey)
-		if err == nil {
-			expireVal, _ := expireItem.ValueCopy(nil)
-			// 过期时间存储的是 Unix 纳秒时间戳
-			expireNS, _ := strconv.ParseInt(string(expireVal), 10, 64)
-			if expireNS > 0 {
-				now := time.Now().UnixNano()
-				if expireNS > now {
-					ttl = (expireNS - now) / 1_000_000 // 转换为毫秒
-				}
+		exp, err := s.expiresAtTxn(txn, key, keyType)
+		if err != nil {
+			return err
+		}
+		if exp > 0 {
+			ttl = expiresAtTime(exp).Sub(s.now()).Milliseconds()
+			if ttl <= 0 {
+				// 已过期但尚未被删除的键视为不存在
+				return fmt.Errorf("ERR no such key")
 			}
 		}
 
@@ -1095,7 +560,7 @@ func (s *BotreonStore) Dump(key string) ([]byte, error) {
 		// 写入 TTL（毫秒精度）
 		if ttl > 0 {
 			buf.WriteByte(0xFC) // 毫秒精度过期时间
-			expireMS := time.Now().UnixMilli() + ttl
+			expireMS := s.now().UnixMilli() + ttl
 			// #nosec G115 - expireMS is within int64 range for practical purposes
 			_ = binary.Write(buf, binary.LittleEndian, uint64(expireMS))
 		}
@@ -1108,7 +573,11 @@ func (s *BotreonStore) Dump(key string) ([]byte, error) {
 			if err != nil {
 				return err
 			}
-			val, _ := valItem.ValueCopy(nil)
+			// 序列化解压后的值（冷层的值从冷层读取，分段存储的值拼接各段）
+			val, err := s.stringValueTxn(txn, key, valItem)
+			if err != nil {
+				return err
+			}
 			buf.WriteByte(0) // STRING type
 			writeRDBString(buf, key)
 			writeRDBBytes(buf, val)
@@ -1169,8 +638,13 @@ func (s *BotreonStore) Dump(key string) ([]byte, error) {
 			writeRDBLength(buf, uint64(len(members)))
 			for _, m := range members {
 				writeRDBString(buf, m.Member)
-				scoreBytes := []byte(fmt.Sprintf("%.10g", m.Score))
-				writeRDBBytes(buf, scoreBytes)
+				// 最短的可完整还原的表示，避免 %.10g 丢失精度
+				writeRDBString(buf, strconv.FormatFloat(m.Score, 'g', -1, 64))
+			}
+
+		case KeyTypeStream:
+			if err := dumpStream(txn, key, buf); err != nil {
+				return err
 			}
 
 		default:
@@ -1195,15 +669,17 @@ func readRDBLength(buf *bytes.Buffer) (uint64, error) {
 		return 0, fmt.Errorf("unexpected end of buffer")
 	}
 	b := buf.Next(1)[0]
-	if b&0x80 == 0 {
+	// 高两位：00 为 6 位长度，01 为 14 位长度，10 为随后的 32 位长度（与 writeRDBLength 对应）
+	switch b >> 6 {
+	case 0:
 		return uint64(b & 0x3F), nil
-	} else if b&0x40 == 0 {
+	case 1:
 		if buf.Len() < 1 {
 			return 0, fmt.Errorf("unexpected end of buffer")
 		}
 		b2 := buf.Next(1)[0]
-		return uint64(((uint64(b) & 0x3F) << 8) | uint64(b2)), nil
-	} else {
+		return ((uint64(b) & 0x3F) << 8) | uint64(b2), nil
+	case 2:
 		if buf.Len() < 4 {
 			return 0, fmt.Errorf("unexpected end of buffer")
 		}
@@ -1212,6 +688,8 @@ func readRDBLength(buf *bytes.Buffer) (uint64, error) {
 			return 0, err
 		}
 		return uint64(length), nil
+	default:
+		return 0, fmt.Errorf("unsupported length encoding: 0x%02x", b)
 	}
 }
 
@@ -1316,10 +794,12 @@ func (s *BotreonStore) Restore(key string, serializedData []byte, ttl time.Durat
 	if ttl > 0 {
 		finalTTL = ttl
 	} else if expireAt > 0 {
-		now := time.Now().UnixMilli()
-		if expireAt > now {
-			finalTTL = time.Duration(expireAt-now) * time.Millisecond
+		now := s.now().UnixMilli()
+		if expireAt <= now {
+			// 序列化数据中的过期时间已过：与 Redis 相同，不创建键（已存在的键已被删除）
+			return nil
 		}
+		finalTTL = time.Duration(expireAt-now) * time.Millisecond
 	}
 
 	// 读取类型
@@ -1448,6 +928,9 @@ func (s *BotreonStore) Restore(key string, serializedData []byte, ttl time.Durat
 		}
 		return nil
 
+	case rdbTypeBoltStream:
+		return s.restoreStream(key, buf, finalTTL)
+
 	default:
 		return fmt.Errorf("ERR unsupported RDB type: %d", typeByte)
 	}
@@ -1510,28 +993,19 @@ func (s *BotreonStore) restoreLegacy(key string, serializedData []byte, ttl time
 
 // getListData 获取列表的所有数据（用于 DUMP）
 func (s *BotreonStore) getListData(key string) ([]string, error) {
-	length, err := s.LLen(key)
+	elements, err := s.LRange(key, 0, -1)
 	if err != nil {
 		return nil, err
 	}
-	if length == 0 {
+	if elements == nil {
 		return []string{}, nil
 	}
-
-	var elements []string
-	for i := uint64(0); i < length; i++ {
-		val, err := s.LIndex(key, int64(i))
-		if err != nil {
-			return nil, err
-		}
-		elements = append(elements, val)
-	}
 	return elements, nil
 }
 
 // Time 实现 Redis TIME 命令，返回服务器当前时间
 func (s *BotreonStore) Time() (int64, int64, error) {
-	now := time.Now()
+	now := s.now()
 	sec := now.Unix()
 	usec := int64(now.Nanosecond() / 1000)
 	return sec, usec, nil
@@ -1542,8 +1016,16 @@ func (s *BotreonStore) Time() (int64, int64, error) {
 // 1. 清理过期键
 // 2. 清理孤立数据（没有TYPE_键的数据）
 // 3. 清理孤立TYPE_键（没有对应数据的TYPE_键）
+// 4. 将旧版本链表布局的列表转换为序号布局
+// 5. 将旧版本以 ID 字符串保存的 Stream 记录键转换为按 ID 排序的编码
 func (s *BotreonStore) NextStartup() error {
-	return s.db.Update(func(txn *badger.Txn) error {
+	if err := s.migrateLegacyLists(); err != nil {
+		return err
+	}
+	if err := s.migrateLegacyStreams(); err != nil {
+		return err
+	}
+	return s.update(func(txn *storeTxn) error {
 		// 1. 清理孤立TYPE_键（没有对应数据的TYPE_键）
 		iter := txn.NewIterator(badger.DefaultIteratorOptions)
 		defer iter.Close()
@@ -1602,7 +1084,7 @@ func (s *BotreonStore) NextStartup() error {
 }
 
 // checkDataExists 检查指定键的数据是否存在
-func (s *BotreonStore) checkDataExists(txn *badger.Txn, key, keyType string) (bool, error) {
+func (s *BotreonStore) checkDataExists(txn *storeTxn, key, keyType string) (bool, error) {
 	switch keyType {
 	case KeyTypeString:
 		strKey := s.stringKey(key)
@@ -1665,7 +1147,7 @@ func (s *BotreonStore) checkDataExists(txn *badger.Txn, key, keyType string) (bo
 }
 
 // cleanupOrphanedData 清理没有TYPE_键的String数据
-func cleanupOrphanedData(txn *badger.Txn, dataPrefix []byte) error {
+func cleanupOrphanedData(txn *storeTxn, dataPrefix []byte) error {
 	iter := txn.NewIterator(badger.DefaultIteratorOptions)
 	defer iter.Close()
 
@@ -1691,7 +1173,7 @@ func cleanupOrphanedData(txn *badger.Txn, dataPrefix []byte) error {
 }
 
 // cleanupOrphanedListData 清理没有TYPE_键的List数据
-func cleanupOrphanedListData(txn *badger.Txn) error {
+func cleanupOrphanedListData(txn *storeTxn) error {
 	iter := txn.NewIterator(badger.DefaultIteratorOptions)
 	defer iter.Close()
 
@@ -1724,7 +1206,7 @@ func cleanupOrphanedListData(txn *badger.Txn) error {
 }
 
 // cleanupOrphanedHashData 清理没有TYPE_键的Hash数据
-func cleanupOrphanedHashData(txn *badger.Txn) error {
+func cleanupOrphanedHashData(txn *storeTxn) error {
 	iter := txn.NewIterator(badger.DefaultIteratorOptions)
 	defer iter.Close()
 
@@ -1756,7 +1238,7 @@ func cleanupOrphanedHashData(txn *badger.Txn) error {
 }
 
 // cleanupOrphanedSetData 清理没有TYPE_键的Set数据
-func cleanupOrphanedSetData(txn *badger.Txn) error {
+func cleanupOrphanedSetData(txn *storeTxn) error {
 	iter := txn.NewIterator(badger.DefaultIteratorOptions)
 	defer iter.Close()
 
@@ -1788,7 +1270,7 @@ func cleanupOrphanedSetData(txn *badger.Txn) error {
 }
 
 // cleanupOrphanedZSetData 清理没有TYPE_键的SortedSet数据
-func cleanupOrphanedZSetData(txn *badger.Txn) error {
+func cleanupOrphanedZSetData(txn *storeTxn) error {
 	iter := txn.NewIterator(badger.DefaultIteratorOptions)
 	defer iter.Close()
 
@@ -1825,7 +1307,7 @@ func cleanupOrphanedZSetData(txn *badger.Txn) error {
 func (s *BotreonStore) MemoryUsage(key string) (int64, error) {
 	var size int64
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		// Get the type key first
 		typeKey := TypeOfKeyGet(key)
 		item, err := txn.Get(typeKey)
@@ -382,6 +382,39 @@ func TestDelLargeDataset(t *testing.T) {
 	assert.Equal(t, 0, len(data))
 }
 
+func TestDelBeyondTxnLimit(t *testing.T) {
+	dbPath := t.TempDir()
+	store, _ := NewBadgerStore(dbPath)
+	defer store.Close()
+
+	key := "test_huge_set"
+
+	// 成员数超过单个 Badger 事务能容纳的条目数
+	const total = 200000
+	members := make([]string, 0, 5000)
+	for i := 0; i < total; i++ {
+		members = append(members, fmt.Sprint(i))
+		if len(members) == cap(members) {
+			_, err := store.SAdd(key, members...)
+			assert.NoError(t, err)
+			members = members[:0]
+		}
+	}
+
+	n, err := store.Del(key)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	count, err := store.SCard(key)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), count)
+	assert.Equal(t, int64(0), store.UnlinkPending())
+
+	entries, err := store.KeyLayout(key)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+}
+
 func TestDelComplexSortedSet(t *testing.T) {
 	dbPath := t.TempDir()
 	store, _ := NewBadgerStore(dbPath)
@@ -754,7 +787,7 @@ func TestScan(t *testing.T) {
 	cursor := uint64(0)
 	totalKeys := 0
 	for {
-		result, err := store.Scan(cursor, "*", 5)
+		result, err := store.Scan(cursor, "*", 5, "")
 		assert.NoError(t, err)
 		totalKeys += len(result.Keys)
 		if result.Cursor == 0 {
@@ -784,3 +817,66 @@ func TestRandomKey(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, key == "key1" || key == "key2" || key == "key3")
 }
+
+func TestWarmup(t *testing.T) {
+	dbPath := t.TempDir()
+	store, err := NewBadgerStore(dbPath)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.Set("user:1", "alice"))
+	assert.NoError(t, store.Set("user:2", "bob"))
+	assert.NoError(t, store.Set("other", "x"))
+	assert.NoError(t, store.HSet("profile:1", "name", "alice"))
+
+	// 访问部分键并在关闭时持久化热点列表（只有读取填充读缓存，写入不填充）
+	_, err = store.Get("other")
+	assert.NoError(t, err)
+	_, err = store.Get("user:2")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Close())
+
+	store, err = NewBadgerStore(dbPath)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	hotKeys, err := store.LoadHotKeys()
+	assert.NoError(t, err)
+	assert.True(t, len(hotKeys) > 0)
+	assert.Equal(t, "user:2", hotKeys[0])
+
+	assert.Equal(t, 0, store.ReadCacheStats().Entries)
+	warmed, err := store.Warmup(WarmupOptions{
+		Patterns:   []string{"user:*", "profile:*", "missing:*"},
+		UseHotKeys: true,
+	})
+	assert.NoError(t, err)
+	// user:1, user:2, other(热点列表), profile:1
+	assert.Equal(t, 4, warmed)
+	_, found := store.readCache.get([]byte(store.stringKey("user:1")), store.now())
+	assert.True(t, found)
+
+	// 数量上限
+	warmed, err = store.Warmup(WarmupOptions{Patterns: []string{"*"}, MaxKeys: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, warmed)
+}
+
+// TestWarmupLayouts 预热按键的布局读取所有类型，模式只匹配 0 号数据库的键
+func TestWarmupLayouts(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	_, err = store.XAdd("stream", StreamXAddOptions{}, "1-1", map[string]string{"f": "v"})
+	assert.NoError(t, err)
+	_, err = store.GeoAdd("geo", []GeoMember{{Member: "a", Lon: 13.36, Lat: 38.11}})
+	assert.NoError(t, err)
+	assert.NoError(t, store.Set(DBKey(1, "other"), "v"))
+
+	warmed, err := store.Warmup(WarmupOptions{Patterns: []string{"*"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, warmed)
+	warmed, err = store.Warmup(WarmupOptions{Patterns: []string{"\x00DB*", "*other"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, warmed)
+}
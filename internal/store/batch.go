@@ -0,0 +1,172 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// BatchOpKind 批量写入的操作类型
+type BatchOpKind int
+
+const (
+	// BatchSet 写入字符串 Key=Value，TTL 大于 0 时设置过期时间
+	BatchSet BatchOpKind = iota
+	// BatchHSet 写入哈希字段 Key.Field=Value
+	BatchHSet
+	// BatchSAdd 向集合 Key 添加 Values
+	BatchSAdd
+	// BatchRPush 将 Values 追加到列表 Key 尾部
+	BatchRPush
+	// BatchZAdd 向有序集合 Key 添加 Members
+	BatchZAdd
+)
+
+// BatchOp 批量写入中的一个操作
+type BatchOp struct {
+	Kind    BatchOpKind
+	Key     string
+	Field   string
+	Value   string
+	Values  []string
+	Members []ZSetMember
+	TTL     time.Duration
+}
+
+// BatchResult 单个操作的结果。N 为 HSET/SADD 新增的字段/成员数、RPUSH 之后的列表长度，
+// SET 和 ZADD 为 0；Err 为该操作自身的错误（类型不符、值过大），不影响批量中的其他操作
+type BatchResult struct {
+	N   int64
+	Err error
+}
+
+var (
+	// ErrBatchWrongType 操作的键已存在且类型不符
+	ErrBatchWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	// ErrBatchNoValues SADD/RPUSH/ZADD 操作没有值
+	ErrBatchNoValues = errors.New("ERR wrong number of arguments")
+	// ErrBatchTooLarge 批量超过 Badger 单个事务的大小上限，需要拆分
+	ErrBatchTooLarge = errors.New("ERR batch exceeds the transaction size limit, split it into smaller batches")
+
+	errUnknownBatchOp = errors.New("ERR unknown batch operation")
+)
+
+// ApplyBatch 在同一个 Badger 事务中执行 ops，只提交（fsync）一次。
+// 单个操作的错误记录在对应的 BatchResult 中，其余操作照常执行；
+// 存储错误（包括 ErrBatchTooLarge）使整个批量不生效，此时返回错误且不返回结果
+func (s *BotreonStore) ApplyBatch(ops []BatchOp) ([]BatchResult, error) {
+	var results []BatchResult
+	// 元数据在事务中读写，与并发写入冲突时整体重试
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		results = make([]BatchResult, len(ops))
+		for i, op := range ops {
+			n, err := s.applyBatchOp(txn, op)
+			if err != nil && !isBatchOpError(err) {
+				return err
+			}
+			results[i] = BatchResult{N: n, Err: err}
+		}
+		return nil
+	}, 30)
+	if errors.Is(err, badger.ErrTxnTooBig) {
+		return nil, ErrBatchTooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 提交之后再通知等待者，与单个命令的顺序相同
+	for i, op := range ops {
+		if results[i].Err != nil {
+			continue
+		}
+		switch op.Kind {
+		case BatchRPush:
+			s.notifyBlockingPop(op.Key, len(op.Values))
+		case BatchZAdd:
+			s.notifyZWatch(op.Key, op.Members, nil, false)
+			s.notifyBlockingPop(op.Key, len(op.Members))
+		}
+	}
+	return results, nil
+}
+
+// applyBatchOp 在 txn 中执行单个操作；先检查类型和值大小，出错时该操作不写入任何数据
+func (s *BotreonStore) applyBatchOp(txn *storeTxn, op BatchOp) (int64, error) {
+	switch op.Kind {
+	case BatchSet:
+		if err := s.CheckValueSize(int64(len(op.Value))); err != nil {
+			return 0, err
+		}
+		return 0, s.setStringTxn(txn, op.Key, []byte(op.Value), op.TTL)
+	case BatchHSet:
+		if err := s.checkBatchOp(txn, op.Key, KeyTypeHash, op.Value); err != nil {
+			return 0, err
+		}
+		added, err := s.hsetTxn(txn, op.Key, op.Field, []byte(op.Value))
+		if added {
+			return 1, err
+		}
+		return 0, err
+	case BatchSAdd:
+		if err := s.checkBatchOp(txn, op.Key, KeyTypeSet, op.Values...); err != nil {
+			return 0, err
+		}
+		added, err := s.saddTxn(txn, op.Key, op.Values)
+		return int64(added), err
+	case BatchRPush:
+		if err := s.checkBatchOp(txn, op.Key, KeyTypeList, op.Values...); err != nil {
+			return 0, err
+		}
+		length, err := s.rpushTxn(txn, op.Key, op.Values)
+		// #nosec G115 - length is bounded by practical list size limits
+		return int64(length), err
+	case BatchZAdd:
+		if len(op.Members) == 0 {
+			return 0, ErrBatchNoValues
+		}
+		members := make([]string, len(op.Members))
+		for i, m := range op.Members {
+			members[i] = m.Member
+		}
+		if err := s.checkBatchOp(txn, op.Key, KeyTypeSortedSet, members...); err != nil {
+			return 0, err
+		}
+		return 0, s.zaddTxn(txn, op.Key, op.Members)
+	}
+	return 0, errUnknownBatchOp
+}
+
+// checkBatchOp 键已存在且类型不是 want 时返回 ErrBatchWrongType，任一值超过上限时返回 *ValueTooLargeError。
+// 类型在 txn 中读取，可以看到同一批量中之前的操作
+func (s *BotreonStore) checkBatchOp(txn *storeTxn, key, want string, values ...string) error {
+	if len(values) == 0 {
+		return ErrBatchNoValues
+	}
+	item, err := txn.Get(TypeOfKeyGet(key))
+	if err == nil {
+		typ, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if string(typ) != want {
+			return ErrBatchWrongType
+		}
+	} else if !errors.Is(err, badger.ErrKeyNotFound) {
+		return err
+	}
+	for _, v := range values {
+		if err := s.CheckValueSize(int64(len(v))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isBatchOpError 是否为只影响单个操作的错误
+func isBatchOpError(err error) bool {
+	var tooLarge *ValueTooLargeError
+	return errors.Is(err, ErrBatchWrongType) || errors.Is(err, ErrBatchNoValues) ||
+		errors.Is(err, errUnknownBatchOp) || errors.As(err, &tooLarge)
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestApplyBatch(t *testing.T) {
+	store, err := NewBotreonStoreWithOptions(t.TempDir(), StoreOptions{MaxValueSize: 8})
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.NoError(t, store.Set("str", "v"))
+
+	results, err := store.ApplyBatch([]BatchOp{
+		{Kind: BatchSet, Key: "a", Value: "1", TTL: time.Hour},
+		{Kind: BatchHSet, Key: "h", Field: "f", Value: "x"},
+		{Kind: BatchHSet, Key: "h", Field: "f", Value: "y"},
+		{Kind: BatchSAdd, Key: "s", Values: []string{"m1", "m2", "m1"}},
+		// 同一批量中对同一个列表追加两次，第二次看到第一次的结果
+		{Kind: BatchRPush, Key: "l", Values: []string{"a", "b"}},
+		{Kind: BatchRPush, Key: "l", Values: []string{"c"}},
+		{Kind: BatchZAdd, Key: "z", Members: []ZSetMember{{Member: "m", Score: 2}}},
+		// 单个操作的错误不影响其他操作
+		{Kind: BatchRPush, Key: "str", Values: []string{"x"}},
+		{Kind: BatchSet, Key: "big", Value: "123456789"},
+		{Kind: BatchSAdd, Key: "empty"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 10, len(results))
+	for i, n := range []int64{0, 1, 0, 2, 2, 3, 0} {
+		assert.NoError(t, results[i].Err)
+		assert.Equal(t, n, results[i].N)
+	}
+	assert.True(t, errors.Is(results[7].Err, ErrBatchWrongType))
+	var tooLarge *ValueTooLargeError
+	assert.True(t, errors.As(results[8].Err, &tooLarge))
+	assert.True(t, errors.Is(results[9].Err, ErrBatchNoValues))
+
+	v, err := store.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", v)
+	ttl, err := store.TTL("a")
+	assert.NoError(t, err)
+	assert.True(t, ttl > 0)
+	hv, err := store.HGet("h", "f")
+	assert.NoError(t, err)
+	assert.Equal(t, "y", string(hv))
+	hlen, err := store.HLen("h")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), hlen)
+	items, err := store.LRange("l", 0, -1)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"a", "b", "c"}, items)
+	score, found, err := store.ZScore("z", "m")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 2.0, score)
+	exists, err := store.Exists("big")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	typ, err := store.Type("empty")
+	assert.NoError(t, err)
+	assert.Equal(t, "none", typ)
+}
@@ -0,0 +1,600 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// 位图与 Redis 相同就是字符串值：GET、SETRANGE、DUMP 等可以直接读写位图，位 0 是第一个字节的最高位。
+// 位图沿用字符串的压缩存储，稀疏位图中大段的零字节压缩后几乎不占空间；
+// SETBIT、BITFIELD 改写位图时保留键原有的过期时间。
+
+// ErrBitmapWrongType 位图命令的键存在但不是字符串
+var ErrBitmapWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// BitUnit BITCOUNT/BITPOS 区间的单位
+type BitUnit int
+
+const (
+	// BitUnitByte 区间按字节计（默认）
+	BitUnitByte BitUnit = iota
+	// BitUnitBit 区间按位计
+	BitUnitBit
+)
+
+// BitRange BITCOUNT/BITPOS 的区间。Start/End 为负数时从末尾倒数，HasEnd 为 false 时到末尾
+type BitRange struct {
+	Start  int64
+	End    int64
+	HasEnd bool
+	Unit   BitUnit
+}
+
+// resolve 按值的长度（字节）把区间换算为闭区间 [start, end]，单位与 r.Unit 相同；区间为空时 ok 为 false
+func (r BitRange) resolve(length int64) (start, end int64, ok bool) {
+	total := length
+	if r.Unit == BitUnitBit {
+		total *= 8
+	}
+	start, end = r.Start, total-1
+	if r.HasEnd {
+		end = r.End
+	}
+	if start < 0 && end < 0 && start > end {
+		return 0, 0, false
+	}
+	if start < 0 {
+		start += total
+	}
+	if end < 0 {
+		end += total
+	}
+	start, end = max(start, 0), max(end, 0)
+	if end >= total {
+		end = total - 1
+	}
+	return start, end, start <= end
+}
+
+// bitmapValue 位图键的当前值
+type bitmapValue struct {
+	data      []byte
+	expiresAt uint64 // 值键的过期时间，0 表示不过期
+	exists    bool
+}
+
+// bitmapTxn 读取位图，键不存在时返回空值；键存在但不是字符串时返回 ErrBitmapWrongType。
+// 返回的字节可能与解压缓存共享，修改前必须复制
+func (s *BotreonStore) bitmapTxn(txn *storeTxn, key string) (bitmapValue, error) {
+	typeItem, err := txn.Get(TypeOfKeyGet(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return bitmapValue{}, nil
+	}
+	if err != nil {
+		return bitmapValue{}, err
+	}
+	keyType, err := typeItem.ValueCopy(nil)
+	if err != nil {
+		return bitmapValue{}, err
+	}
+	if string(keyType) != KeyTypeString {
+		return bitmapValue{}, ErrBitmapWrongType
+	}
+	item, err := txn.Get([]byte(s.stringKey(key)))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return bitmapValue{}, nil
+	}
+	if err != nil {
+		return bitmapValue{}, err
+	}
+	data, err := s.stringValueTxn(txn, key, item)
+	if err != nil {
+		return bitmapValue{}, err
+	}
+	return bitmapValue{data: data, expiresAt: item.ExpiresAt(), exists: true}, nil
+}
+
+// putBitmapTxn 写回位图，expiresAt 不为 0 时保留原有的过期时间
+func (s *BotreonStore) putBitmapTxn(txn *storeTxn, key string, data []byte, expiresAt uint64) error {
+	if err := txn.Set(TypeOfKeyGet(key), []byte(KeyTypeString)); err != nil {
+		return err
+	}
+	if err := s.dropStringChunksTxn(txn, key); err != nil {
+		return err
+	}
+	return s.setValueWithExpiresAt(txn, []byte(s.stringKey(key)), data, expiresAt)
+}
+
+// growBitmap 返回 data 的可写副本，长度至少为 n 字节（不足部分补零）
+func growBitmap(data []byte, n int64) []byte {
+	buf := make([]byte, max(int64(len(data)), n))
+	copy(buf, data)
+	return buf
+}
+
+// GetBit 实现 Redis GETBIT 命令，超出值末尾的位为 0
+func (s *BotreonStore) GetBit(key string, offset int) (int, error) {
+	var bit int
+	err := s.view(func(txn *storeTxn) error {
+		v, err := s.bitmapTxn(txn, key)
+		if err != nil {
+			return err
+		}
+		if byteIndex := offset / 8; byteIndex < len(v.data) && v.data[byteIndex]&(0x80>>(offset%8)) != 0 {
+			bit = 1
+		}
+		return nil
+	})
+	return bit, err
+}
+
+// SetBit 实现 Redis SETBIT 命令，返回该位原来的值；值不够长时补零
+func (s *BotreonStore) SetBit(key string, offset int, value int) (int, error) {
+	// 先检查结果长度，避免按过大的 offset 分配内存
+	if err := s.CheckValueSize(int64(offset)/8 + 1); err != nil {
+		return 0, err
+	}
+	var oldBit int
+	err := s.update(func(txn *storeTxn) error {
+		v, err := s.bitmapTxn(txn, key)
+		if err != nil {
+			return err
+		}
+		data := growBitmap(v.data, int64(offset)/8+1)
+		mask := byte(0x80) >> (offset % 8)
+		if data[offset/8]&mask != 0 {
+			oldBit = 1
+		}
+		if value == 1 {
+			data[offset/8] |= mask
+		} else {
+			data[offset/8] &^= mask
+		}
+		return s.putBitmapTxn(txn, key, data, v.expiresAt)
+	})
+	return oldBit, err
+}
+
+// BitCount 实现 Redis BITCOUNT 命令，统计区间内值为 1 的位数
+func (s *BotreonStore) BitCount(key string, r BitRange) (int64, error) {
+	var count int64
+	err := s.view(func(txn *storeTxn) error {
+		v, err := s.bitmapTxn(txn, key)
+		if err != nil {
+			return err
+		}
+		start, end, ok := r.resolve(int64(len(v.data)))
+		if !ok {
+			return nil
+		}
+		if r.Unit == BitUnitByte {
+			count = popcount(v.data[start : end+1])
+			return nil
+		}
+		first, last := start/8, end/8
+		headMask, tailMask := byte(0xFF)>>(start%8), byte(0xFF)<<(7-end%8)
+		if first == last {
+			count = int64(bits.OnesCount8(v.data[first] & headMask & tailMask))
+			return nil
+		}
+		count = int64(bits.OnesCount8(v.data[first]&headMask)) + popcount(v.data[first+1:last]) +
+			int64(bits.OnesCount8(v.data[last]&tailMask))
+		return nil
+	})
+	return count, err
+}
+
+// popcount 统计字节中值为 1 的位数，按 8 字节一组计算
+func popcount(data []byte) int64 {
+	var n int
+	for len(data) >= 8 {
+		n += bits.OnesCount64(uint64(data[0]) | uint64(data[1])<<8 | uint64(data[2])<<16 | uint64(data[3])<<24 |
+			uint64(data[4])<<32 | uint64(data[5])<<40 | uint64(data[6])<<48 | uint64(data[7])<<56)
+		data = data[8:]
+	}
+	for _, b := range data {
+		n += bits.OnesCount8(b)
+	}
+	return int64(n)
+}
+
+// BitPos 实现 Redis BITPOS 命令，返回区间内第一个值为 bit 的位的位置，没有时返回 -1。
+// 与 Redis 相同，查找 0 且没有指定区间终点时，全为 1 的值返回其末尾之后的第一位；键不存在时查找 0 返回 0
+func (s *BotreonStore) BitPos(key string, bit int, r BitRange) (int64, error) {
+	pos := int64(-1)
+	err := s.view(func(txn *storeTxn) error {
+		v, err := s.bitmapTxn(txn, key)
+		if err != nil {
+			return err
+		}
+		if !v.exists {
+			if bit == 0 {
+				pos = 0
+			}
+			return nil
+		}
+		start, end, ok := r.resolve(int64(len(v.data)))
+		if !ok {
+			return nil
+		}
+		// 按位的区间：首尾字节中区间外的位不参与查找
+		var headMask, tailMask byte
+		if r.Unit == BitUnitBit {
+			headMask, tailMask = ^(byte(0xFF) >> (start % 8)), byte(0xFF)>>(end%8+1)
+			start, end = start/8, end/8
+		}
+		for i := start; i <= end; i++ {
+			b := v.data[i]
+			var outside byte
+			if i == start {
+				outside |= headMask
+			}
+			if i == end {
+				outside |= tailMask
+			}
+			if bit == 0 {
+				b = ^b
+			}
+			if b &^= outside; b != 0 {
+				pos = i*8 + int64(bits.LeadingZeros8(b))
+				return nil
+			}
+		}
+		if bit == 0 && !r.HasEnd {
+			pos = (end + 1) * 8
+		}
+		return nil
+	})
+	return pos, err
+}
+
+// BitOp 实现 Redis BITOP 命令，对源键按位做 AND/OR/XOR/NOT 并写入 destKey，返回结果长度。
+// 较短的源键按补零处理；源键都不存在时删除 destKey。destKey 原有的值与过期时间被覆盖
+func (s *BotreonStore) BitOp(op string, destKey string, keys ...string) (int, error) {
+	if len(keys) == 0 {
+		return 0, errors.New("BITOP requires at least one source key")
+	}
+	if op == "NOT" && len(keys) != 1 {
+		return 0, errors.New("BITOP NOT must be called with a single source key.")
+	}
+	if op != "AND" && op != "OR" && op != "XOR" && op != "NOT" {
+		return 0, fmt.Errorf("unknown bitop operation: %s", op)
+	}
+	var resultLength int
+	err := s.update(func(txn *storeTxn) error {
+		sources := make([][]byte, len(keys))
+		maxLen := 0
+		for i, key := range keys {
+			v, err := s.bitmapTxn(txn, key)
+			if err != nil {
+				return err
+			}
+			sources[i] = v.data
+			maxLen = max(maxLen, len(v.data))
+		}
+		resultLength = maxLen
+		if _, err := s.delTxn(txn, destKey); err != nil {
+			return err
+		}
+		if maxLen == 0 {
+			return nil
+		}
+		result := make([]byte, maxLen)
+		copy(result, sources[0])
+		switch op {
+		case "NOT":
+			for i := range result {
+				result[i] = ^result[i]
+			}
+		case "AND":
+			for _, src := range sources[1:] {
+				for i := range result {
+					if i < len(src) {
+						result[i] &= src[i]
+					} else {
+						result[i] = 0
+					}
+				}
+			}
+		case "OR":
+			for _, src := range sources[1:] {
+				for i, b := range src {
+					result[i] |= b
+				}
+			}
+		case "XOR":
+			for _, src := range sources[1:] {
+				for i, b := range src {
+					result[i] ^= b
+				}
+			}
+		}
+		return s.putBitmapTxn(txn, destKey, result, 0)
+	})
+	return resultLength, err
+}
+
+// BitLen 返回值的位数（字节数 × 8）
+func (s *BotreonStore) BitLen(key string) (int, error) {
+	var length int
+	err := s.view(func(txn *storeTxn) error {
+		v, err := s.bitmapTxn(txn, key)
+		if err != nil {
+			return err
+		}
+		length = len(v.data) * 8
+		return nil
+	})
+	return length, err
+}
+
+// BitFieldOverflow BITFIELD 的溢出处理方式
+type BitFieldOverflow int
+
+const (
+	// BitFieldWrap 回绕（默认）
+	BitFieldWrap BitFieldOverflow = iota
+	// BitFieldSat 饱和到最大值或最小值
+	BitFieldSat
+	// BitFieldFail 不写入，结果为 nil
+	BitFieldFail
+)
+
+// BitFieldOp BITFIELD 的一个 GET/SET/INCRBY 子命令
+type BitFieldOp struct {
+	Op       string // GET、SET 或 INCRBY
+	Signed   bool
+	Bits     int
+	Offset   int64 // 起始位
+	Value    int64 // SET 的值或 INCRBY 的增量
+	Overflow BitFieldOverflow
+}
+
+// ParseBitFieldOps 解析 BITFIELD 的子命令，错误信息与 Redis 相同（不含 ERR 前缀）。
+// OVERFLOW 作用于其后的 SET 与 INCRBY；offset 带 # 前缀时乘以类型的位数。readOnly 为 true（BITFIELD_RO）时只接受 GET
+func ParseBitFieldOps(args []string, readOnly bool) ([]BitFieldOp, error) {
+	var ops []BitFieldOp
+	overflow := BitFieldWrap
+	for i := 0; i < len(args); {
+		sub := strings.ToUpper(args[i])
+		remaining := len(args) - i - 1
+		switch {
+		case sub == "GET" && remaining >= 2, (sub == "SET" || sub == "INCRBY") && remaining >= 3:
+		case sub == "OVERFLOW" && remaining >= 1:
+			switch strings.ToUpper(args[i+1]) {
+			case "WRAP":
+				overflow = BitFieldWrap
+			case "SAT":
+				overflow = BitFieldSat
+			case "FAIL":
+				overflow = BitFieldFail
+			default:
+				return nil, errors.New("Invalid OVERFLOW type specified")
+			}
+			i += 2
+			continue
+		default:
+			return nil, errors.New("syntax error")
+		}
+		op := BitFieldOp{Op: sub, Overflow: overflow}
+		var err error
+		if op.Signed, op.Bits, err = parseBitFieldType(args[i+1]); err != nil {
+			return nil, err
+		}
+		if op.Offset, err = parseBitFieldOffset(args[i+2], op.Bits); err != nil {
+			return nil, err
+		}
+		if sub == "GET" {
+			i += 3
+		} else {
+			if readOnly {
+				return nil, errors.New("BITFIELD_RO only supports the GET subcommand")
+			}
+			if op.Value, err = strconv.ParseInt(args[i+3], 10, 64); err != nil {
+				return nil, errors.New("value is not an integer or out of range")
+			}
+			i += 4
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// parseBitFieldType 解析 i1..i64 与 u1..u63
+func parseBitFieldType(typ string) (signed bool, n int, err error) {
+	errType := errors.New("Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+	if len(typ) < 2 || (typ[0] != 'i' && typ[0] != 'u') {
+		return false, 0, errType
+	}
+	signed = typ[0] == 'i'
+	n, err = strconv.Atoi(typ[1:])
+	if err != nil || n < 1 || (signed && n > 64) || (!signed && n > 63) {
+		return false, 0, errType
+	}
+	return signed, n, nil
+}
+
+// parseBitFieldOffset 解析位偏移，"#N" 表示第 N 个 n 位宽的字段
+func parseBitFieldOffset(arg string, n int) (int64, error) {
+	errOffset := errors.New("bit offset is not an integer or out of range")
+	index, hash := strings.CutPrefix(arg, "#")
+	offset, err := strconv.ParseInt(index, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, errOffset
+	}
+	if hash {
+		if offset > math.MaxInt64/int64(n) {
+			return 0, errOffset
+		}
+		offset *= int64(n)
+	}
+	return offset, nil
+}
+
+// BitField 实现 Redis BITFIELD/BITFIELD_RO 命令，按顺序执行子命令：
+// GET 返回字段的值，SET 返回原值，INCRBY 返回新值；OVERFLOW FAIL 下溢出的 SET/INCRBY 不写入，结果为 nil
+func (s *BotreonStore) BitField(key string, ops []BitFieldOp) ([]*int64, error) {
+	var highest int64 // 写入涉及的字节数
+	for _, op := range ops {
+		if op.Op != "GET" {
+			highest = max(highest, (op.Offset+int64(op.Bits)+7)/8)
+		}
+	}
+	run := func(txn *storeTxn) ([]*int64, error) {
+		v, err := s.bitmapTxn(txn, key)
+		if err != nil {
+			return nil, err
+		}
+		data := v.data
+		if highest > 0 {
+			data = growBitmap(v.data, highest)
+		}
+		results := make([]*int64, 0, len(ops))
+		changed := len(data) > len(v.data)
+		for _, op := range ops {
+			old := getBitField(data, op.Offset, op.Bits)
+			if op.Op == "GET" {
+				value := bitFieldInt(old, op.Bits, op.Signed)
+				results = append(results, &value)
+				continue
+			}
+			// SET 检查新值本身是否超出范围，INCRBY 检查原值加增量
+			value, incr := bitFieldInt(old, op.Bits, op.Signed), op.Value
+			if op.Op == "SET" {
+				value, incr = op.Value, 0
+			}
+			var next uint64
+			var overflow bool
+			if op.Signed {
+				next, overflow = signedBitFieldOverflow(value, incr, op.Bits, op.Overflow)
+			} else {
+				next, overflow = unsignedBitFieldOverflow(uint64(value), incr, op.Bits, op.Overflow)
+			}
+			if overflow && op.Overflow == BitFieldFail {
+				results = append(results, nil)
+				continue
+			}
+			setBitField(data, op.Offset, op.Bits, next)
+			changed = true
+			reply := bitFieldInt(next, op.Bits, op.Signed)
+			if op.Op == "SET" {
+				reply = bitFieldInt(old, op.Bits, op.Signed)
+			}
+			results = append(results, &reply)
+		}
+		if changed {
+			return results, s.putBitmapTxn(txn, key, data, v.expiresAt)
+		}
+		return results, nil
+	}
+
+	var results []*int64
+	if highest == 0 {
+		err := s.view(func(txn *storeTxn) error {
+			var err error
+			results, err = run(txn)
+			return err
+		})
+		return results, err
+	}
+	if err := s.CheckValueSize(highest); err != nil {
+		return nil, err
+	}
+	err := s.update(func(txn *storeTxn) error {
+		var err error
+		results, err = run(txn)
+		return err
+	})
+	return results, err
+}
+
+// getBitField 读取从 offset 开始的 n 位（高位在前），超出值末尾的位为 0
+func getBitField(data []byte, offset int64, n int) uint64 {
+	var value uint64
+	for i := int64(0); i < int64(n); i++ {
+		pos := offset + i
+		value <<= 1
+		if byteIndex := pos / 8; byteIndex < int64(len(data)) && data[byteIndex]&(0x80>>(pos%8)) != 0 {
+			value |= 1
+		}
+	}
+	return value
+}
+
+// setBitField 把 value 的低 n 位写到从 offset 开始的位置（高位在前）
+func setBitField(data []byte, offset int64, n int, value uint64) {
+	for i := 0; i < n; i++ {
+		pos := offset + int64(i)
+		mask := byte(0x80) >> (pos % 8)
+		if value&(1<<(n-1-i)) != 0 {
+			data[pos/8] |= mask
+		} else {
+			data[pos/8] &^= mask
+		}
+	}
+}
+
+// bitFieldInt 把 value 的低 n 位解释为整数，signed 时做符号扩展
+func bitFieldInt(value uint64, n int, signed bool) int64 {
+	if n == 64 {
+		return int64(value)
+	}
+	value &= 1<<n - 1
+	if signed && value&(1<<(n-1)) != 0 {
+		value |= ^uint64(0) << n
+	}
+	return int64(value)
+}
+
+// unsignedBitFieldOverflow 计算 n 位无符号字段 value+incr，超出范围时按 mode 回绕或饱和，
+// 第二个返回值表示是否溢出
+func unsignedBitFieldOverflow(value uint64, incr int64, n int, mode BitFieldOverflow) (uint64, bool) {
+	maxValue := uint64(1)<<n - 1
+	var limit uint64
+	switch {
+	case value > maxValue, incr > 0 && uint64(incr) > maxValue-value:
+		limit = maxValue
+	case incr < 0 && uint64(-incr) > value:
+		limit = 0
+	default:
+		return value + uint64(incr), false
+	}
+	if mode == BitFieldSat {
+		return limit, true
+	}
+	return (value + uint64(incr)) & maxValue, true
+}
+
+// signedBitFieldOverflow 计算 n 位有符号字段 value+incr，超出范围时按 mode 回绕或饱和，
+// 第二个返回值表示是否溢出
+func signedBitFieldOverflow(value, incr int64, n int, mode BitFieldOverflow) (uint64, bool) {
+	maxValue := int64(math.MaxInt64)
+	if n < 64 {
+		maxValue = 1<<(n-1) - 1
+	}
+	minValue := -maxValue - 1
+	var limit int64
+	switch {
+	case value > maxValue:
+		limit = maxValue
+	case value < minValue:
+		limit = minValue
+	// value 在范围内时 maxValue-value 与 minValue-value 不会溢出（64 位时只比较同号的一侧）
+	case incr > 0 && (n < 64 || value >= 0) && incr > maxValue-value:
+		limit = maxValue
+	case incr < 0 && (n < 64 || value < 0) && incr < minValue-value:
+		limit = minValue
+	default:
+		return uint64(value + incr), false
+	}
+	if mode == BitFieldSat {
+		return uint64(limit), true
+	}
+	return uint64(value) + uint64(incr), true
+}
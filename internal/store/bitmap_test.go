@@ -0,0 +1,203 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestBitCountRange(t *testing.T) {
+	store := setupStringTest(t)
+	defer store.Close()
+
+	// "foobar" 与 Redis 文档中的例子相同
+	assert.NoError(t, store.Set("k", "foobar"))
+	cases := []struct {
+		r    BitRange
+		want int64
+	}{
+		{BitRange{}, 26},
+		{BitRange{Start: 0, End: 0, HasEnd: true}, 4},
+		{BitRange{Start: 1, End: 1, HasEnd: true}, 6},
+		{BitRange{Start: 1, End: 1, HasEnd: true, Unit: BitUnitBit}, 1},
+		{BitRange{Start: 5, End: 30, HasEnd: true, Unit: BitUnitBit}, 17},
+		{BitRange{Start: -2, End: -1, HasEnd: true}, 7},
+		{BitRange{Start: -1, End: -2, HasEnd: true}, 0},
+		{BitRange{Start: 3, End: 1, HasEnd: true}, 0},
+		{BitRange{Start: 0, End: 100, HasEnd: true, Unit: BitUnitBit}, 26},
+	}
+	for _, c := range cases {
+		count, err := store.BitCount("k", c.r)
+		assert.NoError(t, err)
+		assert.Equal(t, c.want, count)
+	}
+}
+
+func TestBitPosRange(t *testing.T) {
+	store := setupStringTest(t)
+	defer store.Close()
+
+	assert.NoError(t, store.Set("k", "\xff\xf0\x00"))
+	cases := []struct {
+		bit  int
+		r    BitRange
+		want int64
+	}{
+		{0, BitRange{}, 12},
+		{1, BitRange{Start: 2}, -1},
+		{1, BitRange{Start: 2, End: -1, HasEnd: true, Unit: BitUnitBit}, 2},
+		{0, BitRange{Start: 7, End: 15, HasEnd: true, Unit: BitUnitBit}, 12},
+		{1, BitRange{Start: 12, End: 15, HasEnd: true, Unit: BitUnitBit}, -1},
+	}
+	for _, c := range cases {
+		pos, err := store.BitPos("k", c.bit, c.r)
+		assert.NoError(t, err)
+		assert.Equal(t, c.want, pos)
+	}
+
+	// 全为 1 时查找 0：没有指定终点返回末尾之后的第一位，指定终点返回 -1
+	assert.NoError(t, store.Set("ones", "\xff\xff"))
+	pos, err := store.BitPos("ones", 0, BitRange{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(16), pos)
+	pos, err = store.BitPos("ones", 0, BitRange{Start: 0, End: -1, HasEnd: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), pos)
+
+	// 不存在的键上查找 0 返回 0
+	pos, err = store.BitPos("missing", 0, BitRange{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), pos)
+}
+
+func TestBitOpWrongTypeAndEmpty(t *testing.T) {
+	store := setupStringTest(t)
+	defer store.Close()
+
+	_, err := store.LPush("list", "a")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Set("a", "x"))
+	_, err = store.BitOp("OR", "dest", "a", "list")
+	assert.True(t, errors.Is(err, ErrBitmapWrongType))
+
+	// 源键都不存在时删除目标键
+	assert.NoError(t, store.Set("dest", "old"))
+	n, err := store.BitOp("AND", "dest", "missing1", "missing2")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	exists, err := store.Exists("dest")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	// 较短的源键按补零处理
+	assert.NoError(t, store.Set("b", "\xff\xff"))
+	n, err = store.BitOp("AND", "dest", "b", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	value, err := store.Get("dest")
+	assert.NoError(t, err)
+	assert.Equal(t, "x\x00", value)
+}
+
+func TestSetBitKeepsTTL(t *testing.T) {
+	store := setupStringTest(t)
+	defer store.Close()
+
+	assert.NoError(t, store.SetWithTTL("k", "a", time.Hour))
+	_, err := store.SetBit("k", 100, 1)
+	assert.NoError(t, err)
+	ttl, err := store.TTL("k")
+	assert.NoError(t, err)
+	assert.True(t, ttl > 3500)
+
+	_, err = store.LPush("list", "a")
+	assert.NoError(t, err)
+	_, err = store.SetBit("list", 0, 1)
+	assert.True(t, errors.Is(err, ErrBitmapWrongType))
+}
+
+// bitFieldValues 把 BITFIELD 的结果展开，nil 用 "nil" 表示
+func bitFieldValues(results []*int64) []any {
+	out := make([]any, len(results))
+	for i, r := range results {
+		if r == nil {
+			out[i] = "nil"
+		} else {
+			out[i] = *r
+		}
+	}
+	return out
+}
+
+func TestBitField(t *testing.T) {
+	store := setupStringTest(t)
+	defer store.Close()
+
+	run := func(args ...string) []any {
+		ops, err := ParseBitFieldOps(args, false)
+		assert.NoError(t, err)
+		results, err := store.BitField("bf", ops)
+		assert.NoError(t, err)
+		return bitFieldValues(results)
+	}
+
+	// 字段高位在前：u8 的 255 写入后第一个字节为 0xff
+	assert.DeepEqual(t, []any{int64(0), int64(255), int64(15)}, run("SET", "u8", "0", "255", "GET", "u8", "0", "GET", "u4", "4"))
+	value, err := store.Get("bf")
+	assert.NoError(t, err)
+	assert.Equal(t, "\xff", value)
+
+	// # 偏移按类型的位数计
+	assert.DeepEqual(t, []any{int64(0), int64(-2)}, run("SET", "i8", "#1", "-2", "GET", "i8", "8"))
+	value, err = store.Get("bf")
+	assert.NoError(t, err)
+	assert.Equal(t, "\xff\xfe", value)
+
+	// 溢出处理
+	assert.DeepEqual(t, []any{int64(0)}, run("INCRBY", "u8", "0", "1"))
+	assert.DeepEqual(t, []any{int64(255)}, run("OVERFLOW", "SAT", "INCRBY", "u8", "0", "300"))
+	assert.DeepEqual(t, []any{"nil", int64(255)}, run("OVERFLOW", "FAIL", "INCRBY", "u8", "0", "1", "GET", "u8", "0"))
+	assert.DeepEqual(t, []any{int64(-2), int64(127)}, run("OVERFLOW", "SAT", "SET", "i8", "8", "1000", "GET", "i8", "8"))
+	assert.DeepEqual(t, []any{int64(-128)}, run("OVERFLOW", "SAT", "INCRBY", "i8", "8", "-1000"))
+	assert.DeepEqual(t, []any{int64(127)}, run("INCRBY", "i8", "8", "-1"))
+	assert.DeepEqual(t, []any{int64(-128)}, run("INCRBY", "i8", "8", "1"))
+	assert.DeepEqual(t, []any{int64(-9223372036854775808)}, run("SET", "i64", "16", "9223372036854775807", "INCRBY", "i64", "16", "1")[1:])
+	assert.DeepEqual(t, []any{int64(9223372036854775807)}, run("OVERFLOW", "SAT", "SET", "i64", "16", "9223372036854775807", "INCRBY", "i64", "16", "1")[1:])
+	assert.DeepEqual(t, []any{int64(0), int64(255)}, run("SET", "u8", "80", "-1", "GET", "u8", "80"))
+
+	// 只读取时不创建键
+	ops, err := ParseBitFieldOps([]string{"GET", "u8", "0"}, true)
+	assert.NoError(t, err)
+	results, err := store.BitField("missing", ops)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []any{int64(0)}, bitFieldValues(results))
+	exists, err := store.Exists("missing")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestParseBitFieldOpsErrors(t *testing.T) {
+	cases := []struct {
+		args     []string
+		readOnly bool
+		err      string
+	}{
+		{[]string{"GET", "u64", "0"}, false, "Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is."},
+		{[]string{"GET", "x8", "0"}, false, "Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is."},
+		{[]string{"GET", "i65", "0"}, false, "Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is."},
+		{[]string{"GET", "u8", "-1"}, false, "bit offset is not an integer or out of range"},
+		{[]string{"GET", "u8", "#x"}, false, "bit offset is not an integer or out of range"},
+		{[]string{"SET", "u8", "0", "abc"}, false, "value is not an integer or out of range"},
+		{[]string{"OVERFLOW", "NOPE"}, false, "Invalid OVERFLOW type specified"},
+		{[]string{"GET", "u8"}, false, "syntax error"},
+		{[]string{"FOO", "u8", "0"}, false, "syntax error"},
+		{[]string{"SET", "u8", "0", "1"}, true, "BITFIELD_RO only supports the GET subcommand"},
+	}
+	for _, c := range cases {
+		_, err := ParseBitFieldOps(c.args, c.readOnly)
+		assert.Error(t, err)
+		assert.Equal(t, c.err, err.Error())
+	}
+}
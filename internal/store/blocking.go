@@ -0,0 +1,226 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBlockedKeyLimit 单个键上阻塞的客户端数达到上限，调用方应立即返回空结果
+var ErrBlockedKeyLimit = errors.New("too many clients blocked on key")
+
+// ErrBlockedClientsLimit 全局阻塞的客户端数达到上限，调用方应返回错误
+var ErrBlockedClientsLimit = errors.New("max number of blocked clients reached")
+
+// BlockingLimits 阻塞命令（BLPOP、BRPOP、BLMOVE、XREAD BLOCK 等）的并发上限
+// 字段为 0 时使用默认值，小于 0 表示不限制
+type BlockingLimits struct {
+	MaxPerKey int // 单个键上最多阻塞的客户端数
+	MaxTotal  int // 全局最多阻塞的客户端数
+}
+
+// DefaultBlockingLimits 默认的阻塞客户端上限
+var DefaultBlockingLimits = BlockingLimits{
+	MaxPerKey: 1000,
+	MaxTotal:  10000,
+}
+
+// blockingBudget 阻塞客户端计数，防止大量 BLPOP 客户端同时等待同一个键时
+// 等待队列无限增长
+type blockingBudget struct {
+	mu       sync.Mutex
+	limits   BlockingLimits
+	perKey   map[string]int // 键 -> 阻塞在该键上的客户端数
+	total    int            // 当前阻塞的客户端数
+	rejected int64          // 因超出上限被拒绝的次数
+}
+
+func newBlockingBudget() *blockingBudget {
+	return &blockingBudget{
+		limits: DefaultBlockingLimits,
+		perKey: make(map[string]int),
+	}
+}
+
+// SetBlockingLimits 设置阻塞客户端上限，只影响之后开始阻塞的客户端
+func (s *BotreonStore) SetBlockingLimits(l BlockingLimits) {
+	if l.MaxPerKey == 0 {
+		l.MaxPerKey = DefaultBlockingLimits.MaxPerKey
+	}
+	if l.MaxTotal == 0 {
+		l.MaxTotal = DefaultBlockingLimits.MaxTotal
+	}
+	s.blocking.mu.Lock()
+	s.blocking.limits = l
+	s.blocking.mu.Unlock()
+}
+
+// BlockingLimits 返回当前的阻塞客户端上限
+func (s *BotreonStore) BlockingLimits() BlockingLimits {
+	s.blocking.mu.Lock()
+	defer s.blocking.mu.Unlock()
+	return s.blocking.limits
+}
+
+// acquireBlocking 为即将阻塞在 keys 上的客户端占用名额，返回释放函数
+// 任一键或全局名额用尽时返回 ErrBlockedKeyLimit / ErrBlockedClientsLimit
+func (s *BotreonStore) acquireBlocking(keys []string) (func(), error) {
+	b := s.blocking
+	unique := make([]string, 0, len(keys))
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, key)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limits.MaxTotal > 0 && b.total >= b.limits.MaxTotal {
+		b.rejected++
+		return nil, ErrBlockedClientsLimit
+	}
+	if b.limits.MaxPerKey > 0 {
+		for _, key := range unique {
+			if b.perKey[key] >= b.limits.MaxPerKey {
+				b.rejected++
+				return nil, ErrBlockedKeyLimit
+			}
+		}
+	}
+	b.total++
+	for _, key := range unique {
+		b.perKey[key]++
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.total--
+			for _, key := range unique {
+				if b.perKey[key] <= 1 {
+					delete(b.perKey, key)
+				} else {
+					b.perKey[key]--
+				}
+			}
+		})
+	}, nil
+}
+
+// BlockedClients 当前阻塞中的客户端数（INFO clients 的 blocked_clients）
+func (s *BotreonStore) BlockedClients() int {
+	s.blocking.mu.Lock()
+	defer s.blocking.mu.Unlock()
+	return s.blocking.total
+}
+
+// BlockingKeys 当前有客户端阻塞等待的键数（INFO clients 的 total_blocking_keys）
+func (s *BotreonStore) BlockingKeys() int {
+	s.blocking.mu.Lock()
+	defer s.blocking.mu.Unlock()
+	return len(s.blocking.perKey)
+}
+
+// RejectedBlockedClients 因超出阻塞上限被立即返回的次数
+func (s *BotreonStore) RejectedBlockedClients() int64 {
+	s.blocking.mu.Lock()
+	defer s.blocking.mu.Unlock()
+	return s.blocking.rejected
+}
+
+// waitBlockingPop 在 keys 上登记等待后反复调用 pop，直到取到值、timeout 到期或 pop 出错，见 waitBlocking
+func (s *BotreonStore) waitBlockingPop(keys []string, timeout time.Duration, pop func() (key, value string, err error)) (string, string, error) {
+	var key, value string
+	_, err := s.waitBlocking(keys, timeout, func() (bool, error) {
+		var err error
+		key, value, err = pop()
+		return value != "", err
+	})
+	return key, value, err
+}
+
+// waitBlocking 在 keys 上登记等待后反复调用 try，直到 try 返回 true、timeout 到期或 try 出错。
+// 先登记再调用 try，推入发生在两者之间时也会被唤醒；被唤醒后重新 try 而不是直接使用推入的值，
+// 因为值可能已被其他客户端取走，取不到时继续等待。超时返回 false
+func (s *BotreonStore) waitBlocking(keys []string, timeout time.Duration, try func() (bool, error)) (bool, error) {
+	release, err := s.acquireBlocking(keys)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	wake := make(chan struct{}, 1)
+	s.blockingMu.Lock()
+	for _, key := range keys {
+		s.blockingPopChans[key] = append(s.blockingPopChans[key], wake)
+	}
+	s.blockingMu.Unlock()
+	defer s.unregisterBlockingPop(keys, wake)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		ok, err := try()
+		if err != nil || ok {
+			return ok, err
+		}
+		select {
+		case <-wake:
+		case <-timer.C:
+			return false, nil
+		}
+	}
+}
+
+// notifyBlockingPop 推入 n 个元素后按登记顺序唤醒至多 n 个等待者，已有未处理唤醒的等待者跳过。
+// 等待者返回时才离开队列，被唤醒但没有取到值的等待者继续排在原来的位置
+func (s *BotreonStore) notifyBlockingPop(key string, n int) {
+	s.blockingMu.Lock()
+	defer s.blockingMu.Unlock()
+	s.wakeBlockingPopLocked(key, n)
+}
+
+func (s *BotreonStore) wakeBlockingPopLocked(key string, n int) {
+	for _, ch := range s.blockingPopChans[key] {
+		if n <= 0 {
+			return
+		}
+		select {
+		case ch <- struct{}{}:
+			n--
+		default:
+		}
+	}
+}
+
+// unregisterBlockingPop 移除等待通道，超时返回的客户端不再留在等待队列中。
+// 返回时仍有未处理的唤醒（如唤醒与超时同时发生）则转交给其他等待者，避免元素无人弹出
+func (s *BotreonStore) unregisterBlockingPop(keys []string, ch chan struct{}) {
+	s.blockingMu.Lock()
+	defer s.blockingMu.Unlock()
+	for _, key := range keys {
+		chans := s.blockingPopChans[key]
+		for i, c := range chans {
+			if c == ch {
+				chans = append(chans[:i], chans[i+1:]...)
+				break
+			}
+		}
+		if len(chans) == 0 {
+			delete(s.blockingPopChans, key)
+		} else {
+			s.blockingPopChans[key] = chans
+		}
+	}
+	select {
+	case <-ch:
+		for _, key := range keys {
+			s.wakeBlockingPopLocked(key, 1)
+		}
+	default:
+	}
+}
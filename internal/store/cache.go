@@ -1,155 +1,268 @@
 package store
 
 import (
+	"container/list"
+	"hash/fnv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// CacheEntry 缓存条目
-type CacheEntry struct {
-	Value     []byte
-	ExpiresAt time.Time
+const (
+	// DefaultReadCacheSize 读缓存的默认容量（字节），CONFIG SET cache-max-bytes 修改
+	DefaultReadCacheSize = 32 << 20
+	// DefaultReadCacheTTL 读缓存条目的默认最长保留时间，CONFIG SET cache-ttl 修改
+	DefaultReadCacheTTL = 5 * time.Minute
+
+	// readCacheEntryOverhead 每个条目除键和值以外的估算开销（链表节点、map 项等）
+	readCacheEntryOverhead = 128
+	// readCacheMaxEntryShare 超过容量 1/readCacheMaxEntryShare 的值不缓存，避免单个大值挤掉所有条目
+	readCacheMaxEntryShare = 8
+	// readCacheStripes 失效计数器的分片数
+	readCacheStripes = 256
+)
+
+// ReadCacheStats 读缓存的命中统计
+type ReadCacheStats struct {
+	Hits     int64
+	Misses   int64
+	Entries  int
+	Bytes    int64
+	Capacity int64
 }
 
-// IsExpired 检查是否过期
-func (e *CacheEntry) IsExpired() bool {
-	return !e.ExpiresAt.IsZero() && time.Now().After(e.ExpiresAt)
+// readThroughCache GET、HGET 的读缓存：按 Badger 键缓存解压后的值，容量按字节限制，最久未使用的先淘汰。
+//
+// 写事务提交后由 update 按事务写入的 Badger 键失效，不需要在各类型的写路径上处理；
+// FLUSHDB 等不经过事务的删除清空整个缓存。读取在事务之前取得键所在分片的失效计数，
+// 填充时计数已变化说明读取期间有写入提交，读到的可能是旧值，不填充
+type readThroughCache struct {
+	mu       sync.Mutex
+	capacity int64
+	ttl      time.Duration // 条目的最长保留时间，0 表示不限
+	size     int64
+	ll       *list.List // 表头为最近使用
+	items    map[string]*list.Element
+
+	enabled atomic.Bool // capacity > 0，写事务据此决定是否收集写入的键
+	epoch   atomic.Uint64
+	stripes [readCacheStripes]atomic.Uint64
+
+	hits   atomic.Int64
+	misses atomic.Int64
 }
 
-// LRUCache LRU 缓存实现
-type LRUCache struct {
-	mu          sync.RWMutex
-	cache       map[string]*CacheEntry
-	maxSize     int
-	ttl         time.Duration
-	accessOrder []string // 用于 LRU 排序
+type readCacheEntry struct {
+	key       string // Badger 键
+	userKey   string // 所属的用户键，用于持久化热点键
+	value     []byte
+	expiresAt uint64 // Badger 条目的过期时间，0 表示没有 TTL
+	addedAt   time.Time
 }
 
-// NewLRUCache 创建新的 LRU 缓存
-func NewLRUCache(maxSize int, ttl time.Duration) *LRUCache {
-	return &LRUCache{
-		cache:       make(map[string]*CacheEntry, maxSize),
-		maxSize:     maxSize,
-		ttl:         ttl,
-		accessOrder: make([]string, 0, maxSize),
-	}
+func (e *readCacheEntry) cost() int64 {
+	return int64(len(e.key)+len(e.userKey)+len(e.value)) + readCacheEntryOverhead
+}
+
+func newReadThroughCache(capacity int64, ttl time.Duration) *readThroughCache {
+	c := &readThroughCache{ttl: ttl, ll: list.New(), items: make(map[string]*list.Element)}
+	c.setCapacity(capacity)
+	return c
 }
 
-// Get 获取缓存值
-func (c *LRUCache) Get(key string) ([]byte, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+func readCacheStripe(key []byte) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write(key)
+	return h.Sum32() % readCacheStripes
+}
 
-	entry, exists := c.cache[key]
-	if !exists {
-		return nil, false
-	}
+// stamp 读取 key 之前调用，结果传给 add
+func (c *readThroughCache) stamp(key []byte) uint64 {
+	return c.epoch.Load() + c.stripes[readCacheStripe(key)].Load()
+}
 
-	// 检查是否过期
-	if entry.IsExpired() {
-		// 异步删除过期项
-		go c.delete(key)
+// get 返回缓存的值的副本；条目超过保留时间或 Badger 条目在 now（存储的时钟）已经过期时视为未命中
+func (c *readThroughCache) get(key []byte, now time.Time) ([]byte, bool) {
+	if !c.enabled.Load() {
 		return nil, false
 	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[string(key)]
+	if ok {
+		entry := el.Value.(*readCacheEntry)
+		stale := c.ttl > 0 && time.Since(entry.addedAt) >= c.ttl
+		if stale || entry.expiresAt > 0 && !expiresAtTime(entry.expiresAt).After(now) {
+			c.removeElement(el)
+		} else {
+			c.ll.MoveToFront(el)
+			c.hits.Add(1)
+			return append([]byte(nil), entry.value...), true
+		}
+	}
+	c.misses.Add(1)
+	return nil, false
+}
 
-	// 更新访问顺序（移动到末尾）
-	c.updateAccessOrder(key)
-
-	return entry.Value, true
+// add 缓存读到的值（保存副本）。stamp 为读取之前 stamp 的结果，之后有写入提交时不缓存
+func (c *readThroughCache) add(key []byte, userKey string, value []byte, expiresAt uint64, stamp uint64) {
+	if !c.enabled.Load() {
+		return
+	}
+	entry := &readCacheEntry{key: string(key), userKey: userKey, expiresAt: expiresAt, addedAt: time.Now()}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stamp(key) != stamp {
+		return
+	}
+	if int64(len(entry.key)+len(userKey)+len(value))+readCacheEntryOverhead > c.capacity/readCacheMaxEntryShare {
+		return
+	}
+	if el, ok := c.items[entry.key]; ok {
+		c.removeElement(el)
+	}
+	entry.value = append([]byte(nil), value...)
+	c.items[entry.key] = c.ll.PushFront(entry)
+	c.size += entry.cost()
+	c.evict()
 }
 
-// Set 设置缓存值
-func (c *LRUCache) Set(key string, value []byte) {
+// invalidate 删除已提交写入的 Badger 键的条目，并使正在读取这些键的填充失效
+func (c *readThroughCache) invalidate(keys [][]byte) {
+	for _, k := range keys {
+		c.stripes[readCacheStripe(k)].Add(1)
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	for _, k := range keys {
+		if el, ok := c.items[string(k)]; ok {
+			c.removeElement(el)
+		}
+	}
+}
 
-	// 如果已存在，更新
-	if _, exists := c.cache[key]; exists {
-		c.cache[key].Value = value
-		if c.ttl > 0 {
-			c.cache[key].ExpiresAt = time.Now().Add(c.ttl)
+// committed 写事务提交后调用。collected 为 false 表示事务开始时缓存关闭，没有收集写入的键；
+// 提交前缓存已经打开时无法确定写了哪些键，清空缓存。written 为 nil 表示读取不到待写的键，同样清空
+func (c *readThroughCache) committed(collected bool, written [][]byte) {
+	if !collected || written == nil {
+		if c.enabled.Load() {
+			c.clear()
 		}
-		c.updateAccessOrder(key)
 		return
 	}
+	c.invalidate(written)
+}
 
-	// 如果缓存已满，删除最久未使用的项
-	if len(c.cache) >= c.maxSize {
-		c.evictLRU()
-	}
+// clear 清空缓存，并使正在进行的填充失效
+func (c *readThroughCache) clear() {
+	c.epoch.Add(1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+	c.size = 0
+}
 
-	// 添加新项
-	entry := &CacheEntry{
-		Value: value,
-	}
-	if c.ttl > 0 {
-		entry.ExpiresAt = time.Now().Add(c.ttl)
+// setCapacity 修改容量（字节），小于等于 0 时关闭缓存并清空
+func (c *readThroughCache) setCapacity(capacity int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.capacity = max(capacity, 0)
+	c.enabled.Store(c.capacity > 0)
+	c.evict()
+}
+
+func (c *readThroughCache) evict() {
+	for c.size > c.capacity {
+		c.removeElement(c.ll.Back())
 	}
-	c.cache[key] = entry
-	c.accessOrder = append(c.accessOrder, key)
 }
 
-// Delete 删除缓存项
-func (c *LRUCache) Delete(key string) {
+func (c *readThroughCache) removeElement(el *list.Element) {
+	entry := el.Value.(*readCacheEntry)
+	c.ll.Remove(el)
+	delete(c.items, entry.key)
+	c.size -= entry.cost()
+}
+
+// hotKeys 按最近使用的顺序返回缓存中的用户键（去重），最多 limit 个
+func (c *readThroughCache) hotKeys(limit int) []string {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.delete(key)
-}
-
-// delete 内部删除方法（不加锁）
-func (c *LRUCache) delete(key string) {
-	if _, exists := c.cache[key]; exists {
-		delete(c.cache, key)
-		// 从访问顺序中删除
-		for i, k := range c.accessOrder {
-			if k == key {
-				c.accessOrder = append(c.accessOrder[:i], c.accessOrder[i+1:]...)
-				break
-			}
+	seen := make(map[string]bool)
+	var keys []string
+	for el := c.ll.Front(); el != nil && len(keys) < limit; el = el.Next() {
+		key := el.Value.(*readCacheEntry).userKey
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
 		}
 	}
+	return keys
 }
 
-// updateAccessOrder 更新访问顺序
-func (c *LRUCache) updateAccessOrder(key string) {
-	// 从当前位置删除
-	for i, k := range c.accessOrder {
-		if k == key {
-			// 安全地删除元素
-			if i < len(c.accessOrder)-1 {
-				c.accessOrder = append(c.accessOrder[:i], c.accessOrder[i+1:]...)
-			} else {
-				c.accessOrder = c.accessOrder[:i]
-			}
-			break
+// cachedValue 读取 Badger 键 key（属于用户键 userKey）解压后的值：先查读缓存，未命中时读取并填充。
+// 键不存在时返回 badger.ErrKeyNotFound
+func (s *BotreonStore) cachedValue(key []byte, userKey string) ([]byte, error) {
+	if value, ok := s.readCache.get(key, s.now()); ok {
+		s.touchTierAccess(key)
+		return value, nil
+	}
+	stamp := s.readCache.stamp(key)
+	var value []byte
+	var expiresAt uint64
+	err := s.view(func(txn *storeTxn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
 		}
+		expiresAt = item.ExpiresAt()
+		value, err = s.getValueWithDecompression(item)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	// 添加到末尾（最近使用）
-	c.accessOrder = append(c.accessOrder, key)
+	s.readCache.add(key, userKey, value, expiresAt, stamp)
+	return value, nil
 }
 
-// evictLRU 删除最久未使用的项
-func (c *LRUCache) evictLRU() {
-	if len(c.accessOrder) == 0 {
-		return
-	}
-	// 删除第一个（最久未使用）
-	oldestKey := c.accessOrder[0]
-	c.accessOrder = c.accessOrder[1:]
-	delete(c.cache, oldestKey)
+// SetReadCacheSize 修改读缓存的容量（字节），小于等于 0 时关闭读缓存
+func (s *BotreonStore) SetReadCacheSize(capacity int64) {
+	s.readCache.setCapacity(capacity)
 }
 
-// Clear 清空缓存
-func (c *LRUCache) Clear() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.cache = make(map[string]*CacheEntry, c.maxSize)
-	c.accessOrder = make([]string, 0, c.maxSize)
+// ReadCacheSize 读缓存的容量（字节），0 表示关闭
+func (s *BotreonStore) ReadCacheSize() int64 {
+	s.readCache.mu.Lock()
+	defer s.readCache.mu.Unlock()
+	return s.readCache.capacity
 }
 
-// Size 返回当前缓存大小
-func (c *LRUCache) Size() int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return len(c.cache)
+// SetReadCacheTTL 修改读缓存条目的最长保留时间，0 表示不限（只在写入或淘汰时删除）
+func (s *BotreonStore) SetReadCacheTTL(ttl time.Duration) {
+	s.readCache.mu.Lock()
+	defer s.readCache.mu.Unlock()
+	s.readCache.ttl = max(ttl, 0)
+}
+
+// ReadCacheTTL 读缓存条目的最长保留时间，0 表示不限
+func (s *BotreonStore) ReadCacheTTL() time.Duration {
+	s.readCache.mu.Lock()
+	defer s.readCache.mu.Unlock()
+	return s.readCache.ttl
+}
+
+// ReadCacheStats 返回读缓存的统计
+func (s *BotreonStore) ReadCacheStats() ReadCacheStats {
+	c := s.readCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ReadCacheStats{
+		Hits:     c.hits.Load(),
+		Misses:   c.misses.Load(),
+		Entries:  c.ll.Len(),
+		Bytes:    c.size,
+		Capacity: c.capacity,
+	}
 }
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestReadCache(t *testing.T) {
+	store, err := NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.Set("k", "v1"))
+	for i := 0; i < 3; i++ {
+		v, err := store.Get("k")
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", v)
+	}
+	stats := store.ReadCacheStats()
+	assert.Equal(t, int64(1), stats.Misses)
+	assert.Equal(t, int64(2), stats.Hits)
+	assert.Equal(t, 1, stats.Entries)
+
+	// 任何写入 Badger 键的事务提交后条目失效
+	assert.NoError(t, store.Set("k", "v2"))
+	v, err := store.Get("k")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", v)
+	_, err = store.APPEND("k", "!")
+	assert.NoError(t, err)
+	v, err = store.Get("k")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2!", v)
+	_, err = store.Del("k")
+	assert.NoError(t, err)
+	_, err = store.Get("k")
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	assert.NoError(t, store.HSet("h", "f", "a"))
+	got, err := store.HGet("h", "f")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(got))
+	assert.NoError(t, store.HSet("h", "f", "b"))
+	got, err = store.HGet("h", "f")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(got))
+
+	// FLUSHDB 不经过事务，清空整个缓存
+	assert.NoError(t, store.FlushDB())
+	_, err = store.HGet("h", "f")
+	assert.Error(t, err)
+	assert.Equal(t, 0, store.ReadCacheStats().Entries)
+}
+
+func TestReadCacheExpiry(t *testing.T) {
+	store, err := NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	clock := NewManualClock(time.Now())
+	store.SetClock(clock)
+
+	assert.NoError(t, store.Set("session", "v"))
+	_, err = store.Get("session")
+	assert.NoError(t, err)
+	ok, err := store.Expire("session", 10)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	_, err = store.Get("session")
+	assert.NoError(t, err)
+	// 缓存的条目带有 Badger 条目的过期时间，过期后不再命中
+	clock.Advance(11 * time.Second)
+	_, found := store.readCache.get([]byte(store.stringKey("session")), store.now())
+	assert.False(t, found)
+
+	// 超过 cache-ttl 的条目视为未命中
+	store.SetReadCacheTTL(time.Nanosecond)
+	assert.NoError(t, store.Set("k", "v"))
+	_, err = store.Get("k")
+	assert.NoError(t, err)
+	_, found = store.readCache.get([]byte(store.stringKey("k")), store.now())
+	assert.False(t, found)
+}
+
+func TestReadCacheCapacity(t *testing.T) {
+	store, err := NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	value := strings.Repeat("v", 100)
+	cost := int64(len(store.stringKey("k0"))+len("k0")+len(value)) + readCacheEntryOverhead
+	store.SetReadCacheSize(cost * 10)
+	for _, k := range []string{"k0", "k1", "k2", "k3", "k4", "k5", "k6", "k7", "k8", "k9", "ka"} {
+		assert.NoError(t, store.Set(k, value))
+		_, err := store.Get(k)
+		assert.NoError(t, err)
+	}
+	stats := store.ReadCacheStats()
+	assert.Equal(t, 10, stats.Entries)
+	assert.Equal(t, cost*10, stats.Bytes)
+	// 最久未使用的 k0 被淘汰
+	_, found := store.readCache.get([]byte(store.stringKey("k0")), store.now())
+	assert.False(t, found)
+	assert.Equal(t, []string{"ka", "k9"}, store.readCache.hotKeys(2))
+
+	// 缩小容量时立即淘汰，0 关闭缓存
+	store.SetReadCacheSize(cost * 2)
+	assert.Equal(t, 2, store.ReadCacheStats().Entries)
+	store.SetReadCacheSize(0)
+	assert.Equal(t, 0, store.ReadCacheStats().Entries)
+	_, err = store.Get("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, store.ReadCacheStats().Entries)
+}
+
+func TestReadCacheFillRace(t *testing.T) {
+	c := newReadThroughCache(1<<20, 0)
+	key := []byte("STRING:k")
+	// 读取期间有写入提交：读到的可能是旧值，不填充
+	stamp := c.stamp(key)
+	c.invalidate([][]byte{key})
+	c.add(key, "k", []byte("old"), 0, stamp)
+	_, found := c.get(key, time.Now())
+	assert.False(t, found)
+
+	stamp = c.stamp(key)
+	c.add(key, "k", []byte("new"), 0, stamp)
+	v, found := c.get(key, time.Now())
+	assert.True(t, found)
+	assert.Equal(t, "new", string(v))
+
+	// 事务开始时缓存关闭，没有收集写入的键：清空
+	c.committed(false, nil)
+	_, found = c.get(key, time.Now())
+	assert.False(t, found)
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 时间源抽象
+// TTL 计算、Stream ID 生成、待处理条目(PEL)空闲时间以及过期清理都通过它获取当前时间，
+// 测试中可以注入 ManualClock 快进时间，而不必 sleep 等待。
+//
+// 读取不按它判断过期：带过期时间的字符串值由 Badger 按系统时间隐藏，其他类型的过期键
+// 在被过期清理删除之前仍可读到。因此推进 ManualClock 后 TTL 立即变化，而要让键消失，
+// 需要再调用 ExpireCycle，由它按注入的时钟删除已过期的键。
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock 使用系统时间的默认实现
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// SystemClock 默认时钟
+var SystemClock Clock = systemClock{}
+
+// ManualClock 手动推进的时钟，用于测试。只影响 TTL 计算与过期清理，见 Clock 的说明
+type ManualClock struct {
+	mu  sync.RWMutex
+	now time.Time
+}
+
+// NewManualClock 创建一个从 start 开始的手动时钟
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now 返回当前时钟时间
+func (c *ManualClock) Now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.now
+}
+
+// Advance 将时钟向前推进 d
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set 将时钟设置为指定时间
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+// SetClock 替换存储使用的时钟，传入 nil 时恢复系统时钟
+func (s *BotreonStore) SetClock(c Clock) {
+	if c == nil {
+		c = SystemClock
+	}
+	s.clockMu.Lock()
+	s.clock = c
+	s.clockMu.Unlock()
+}
+
+// Clock 返回存储当前使用的时钟
+func (s *BotreonStore) Clock() Clock {
+	s.clockMu.RLock()
+	defer s.clockMu.RUnlock()
+	if s.clock == nil {
+		return SystemClock
+	}
+	return s.clock
+}
+
+// now 返回存储时钟的当前时间
+func (s *BotreonStore) now() time.Time {
+	return s.Clock().Now()
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestManualClockTTL(t *testing.T) {
+	dbPath := t.TempDir()
+	store, _ := NewBadgerStore(dbPath)
+	defer store.Close()
+
+	clock := NewManualClock(time.Now())
+	store.SetClock(clock)
+
+	assert.NoError(t, store.Set("ttl_key", "value"))
+	ok, err := store.Expire("ttl_key", 100)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ttl, err := store.TTL("ttl_key")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), ttl)
+
+	// 快进 40 秒
+	clock.Advance(40 * time.Second)
+	ttl, err = store.TTL("ttl_key")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(60), ttl)
+
+	pttl, err := store.PTTL("ttl_key")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(60000), pttl)
+
+	// 快进超过过期时间
+	clock.Advance(61 * time.Second)
+	ttl, err = store.TTL("ttl_key")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-2), ttl)
+
+	// 恢复系统时钟
+	store.SetClock(nil)
+	assert.Equal(t, SystemClock, store.Clock())
+}
+
+func TestManualClockStream(t *testing.T) {
+	dbPath := t.TempDir()
+	store, _ := NewBadgerStore(dbPath)
+	defer store.Close()
+
+	start := time.UnixMilli(1700000000000)
+	clock := NewManualClock(start)
+	store.SetClock(clock)
+
+	// 自动生成的 ID 使用注入的时钟
+	id1, err := store.XAdd("stream", StreamXAddOptions{}, "*", map[string]string{"f": "v"})
+	assert.NoError(t, err)
+	assert.Equal(t, formatStreamID(start.UnixMilli(), 0), id1)
+
+	id2, err := store.XAdd("stream", StreamXAddOptions{}, "*", map[string]string{"f": "v"})
+	assert.NoError(t, err)
+	assert.Equal(t, formatStreamID(start.UnixMilli(), 1), id2)
+
+	// 待处理条目的空闲时间按注入的时钟计算
+	assert.NoError(t, store.XGroupCreate("stream", "g1", "0"))
+	_, err = store.XReadGroup("g1", "c1", 10, 0, "stream")
+	assert.NoError(t, err)
+
+	claimed, err := store.XClaim("stream", "g1", "c2", 5000, id1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(claimed))
+
+	clock.Advance(6 * time.Second)
+	claimed, err = store.XClaim("stream", "g1", "c2", 5000, id1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(claimed))
+}
+
+func TestManualClockExpireCycle(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	clock := NewManualClock(time.Now())
+	store.SetClock(clock)
+
+	assert.NoError(t, store.SetWithTTL("s", "v", time.Minute))
+	assert.NoError(t, store.HSet("h", "f", "v"))
+	ok, err := store.Expire("h", 60)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// 推进时钟只改变 TTL，Badger 仍按系统时间返回值
+	clock.Advance(2 * time.Minute)
+	val, err := store.Get("s")
+	assert.NoError(t, err)
+	assert.Equal(t, "v", val)
+
+	// 过期清理按注入的时钟删除已过期的键
+	expired, err := store.ExpireCycle(100)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(expired))
+	_, err = store.Get("s")
+	assert.Equal(t, ErrKeyNotFound, err)
+	_, err = store.HGet("h", "f")
+	assert.Error(t, err)
+	exists, err := store.Exists("h")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -147,7 +147,8 @@ func shouldCompress(data []byte, compressionType CompressionType) bool {
 }
 
 // setValueWithCompression 带压缩的数据写入辅助函数
-func (s *BotreonStore) setValueWithCompression(txn *badger.Txn, key []byte, value []byte) error {
+func (s *BotreonStore) setValueWithCompression(txn *storeTxn, key []byte, value []byte) error {
+	s.recordTierAccess(key, len(value))
 	if shouldCompress(value, s.compressionType) {
 		compressed, err := compressData(value, s.compressionType)
 		if err != nil {
@@ -163,7 +164,8 @@ func (s *BotreonStore) setValueWithCompression(txn *badger.Txn, key []byte, valu
 }
 
 // setEntryWithCompression 带压缩的Entry写入辅助函数
-func (s *BotreonStore) setEntryWithCompression(txn *badger.Txn, key []byte, value []byte, ttl time.Duration) error {
+func (s *BotreonStore) setEntryWithCompression(txn *storeTxn, key []byte, value []byte, ttl time.Duration) error {
+	s.recordTierAccess(key, len(value))
 	if shouldCompress(value, s.compressionType) {
 		compressed, err := compressData(value, s.compressionType)
 		if err != nil {
@@ -192,12 +194,60 @@ func (s *BotreonStore) setEntryWithCompression(txn *badger.Txn, key []byte, valu
 	return txn.Set(key, value)
 }
 
+// setValueWithExpiresAt 带压缩的写入辅助函数，expiresAt 为条目的过期时间（0 表示不过期），
+// 用于改写值时保留或设置绝对过期时间
+func (s *BotreonStore) setValueWithExpiresAt(txn *storeTxn, key []byte, value []byte, expiresAt uint64) error {
+	if expiresAt == 0 {
+		return s.setValueWithCompression(txn, key, value)
+	}
+	s.recordTierAccess(key, len(value))
+	data := value
+	if shouldCompress(value, s.compressionType) {
+		compressed, err := compressData(value, s.compressionType)
+		if err != nil {
+			return fmt.Errorf("compression error: %w", err)
+		}
+		if len(compressed) < len(value) {
+			data = compressed
+		}
+	}
+	e := badger.NewEntry(key, data)
+	e.ExpiresAt = expiresAt
+	return txn.SetEntry(e)
+}
+
 // getValueWithDecompression 带解压缩的数据读取辅助函数
 func (s *BotreonStore) getValueWithDecompression(item *badger.Item) ([]byte, error) {
+	// 同一键的值被重写后版本变化，缓存的旧版本不会命中
+	if value, ok := s.decompressCache.get(item.Key(), item.Version()); ok {
+		s.touchTierAccess(item.Key())
+		return value, nil
+	}
 	value, err := item.ValueCopy(nil)
 	if err != nil {
 		return nil, err
 	}
-	return decompressData(value)
+	// 冷层的值在主实例中只有占位值
+	if isTierStub(value) {
+		if value, err = s.readTierStub(item.Key(), value); err != nil {
+			return nil, err
+		}
+	} else {
+		s.recordTierAccess(item.Key(), len(value))
+	}
+	if !isCompressedData(value) {
+		return decompressData(value)
+	}
+	decompressed, err := decompressData(value)
+	if err != nil {
+		return nil, err
+	}
+	s.decompressCache.add(item.Key(), item.Version(), decompressed)
+	return decompressed, nil
+}
+
+// isCompressedData 数据是否带有压缩魔数前缀
+func isCompressedData(data []byte) bool {
+	return bytes.HasPrefix(data, compressionMagicLZ4) || bytes.HasPrefix(data, compressionMagicZSTD)
 }
 
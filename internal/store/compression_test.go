@@ -163,3 +163,80 @@ func TestCompressionSwitch(t *testing.T) {
 	assert.Equal(t, largeValue, value2)
 }
 
+func TestDecompressCache(t *testing.T) {
+	store, err := NewBotreonStoreWithOptions(t.TempDir(), StoreOptions{Compression: CompressionLZ4})
+	assert.NoError(t, err)
+	defer store.Close()
+	// HGET 命中读缓存时不会解压，关闭读缓存以检查解压缓存
+	store.SetReadCacheSize(0)
+
+	large := strings.Repeat("decompress me once please. ", 200)
+	err = store.HSet("h", "f", large)
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		v, err := store.HGet("h", "f")
+		assert.NoError(t, err)
+		assert.Equal(t, large, string(v))
+	}
+	stats := store.DecompressCacheStats()
+	assert.Equal(t, int64(1), stats.Misses)
+	assert.Equal(t, int64(2), stats.Hits)
+	assert.Equal(t, 1, stats.Entries)
+
+	// 重写后版本变化，不会读到旧值
+	updated := strings.Repeat("a newer version of the value. ", 200)
+	err = store.HSet("h", "f", updated)
+	assert.NoError(t, err)
+	v, err := store.HGet("h", "f")
+	assert.NoError(t, err)
+	assert.Equal(t, updated, string(v))
+	stats = store.DecompressCacheStats()
+	assert.Equal(t, int64(2), stats.Misses)
+	assert.Equal(t, 1, stats.Entries)
+
+	// 未压缩的小值不经过缓存
+	err = store.HSet("h", "small", "x")
+	assert.NoError(t, err)
+	_, err = store.HGet("h", "small")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), store.DecompressCacheStats().Misses)
+}
+
+func TestDecompressCacheEviction(t *testing.T) {
+	value := []byte(strings.Repeat("v", 100))
+	cost := int64(len("k0")+len(value)) + decompressEntryOverhead
+	c := newDecompressCache(cost * 10)
+
+	for i := 0; i < 12; i++ {
+		c.add([]byte("k"+string(rune('a'+i))), 1, value)
+	}
+	// 超出容量时淘汰最早的条目
+	_, ok := c.get([]byte("ka"), 1)
+	assert.False(t, ok)
+	got, ok := c.get([]byte("kl"), 1)
+	assert.True(t, ok)
+	assert.Equal(t, value, got)
+	assert.Equal(t, 10, c.ll.Len())
+	assert.True(t, c.size <= c.capacity)
+
+	// 返回副本，调用方修改不影响缓存
+	got[0] = 'x'
+	again, _ := c.get([]byte("kl"), 1)
+	assert.Equal(t, byte('v'), again[0])
+
+	// 版本不同不命中，较旧的版本不会覆盖较新的
+	_, ok = c.get([]byte("kl"), 2)
+	assert.False(t, ok)
+	c.add([]byte("kl"), 0, []byte("old"))
+	_, ok = c.get([]byte("kl"), 1)
+	assert.True(t, ok)
+
+	// 超过容量 1/8 的值不缓存
+	c.add([]byte("big"), 1, make([]byte, cost*2))
+	_, ok = c.get([]byte("big"), 1)
+	assert.False(t, ok)
+
+	assert.Nil(t, newDecompressCache(effectiveDecompressCacheSize(-1)))
+	assert.Equal(t, int64(DefaultDecompressCacheSize), effectiveDecompressCacheSize(0))
+}
@@ -0,0 +1,834 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/lbp0200/BoltDB/internal/logger"
+)
+
+// 逻辑数据库（SELECT）。所有数据库共用一个 Badger 实例，非 0 号数据库的键在存储中加上
+// "\x00DB<编号>\x00" 前缀，0 号数据库不加前缀，与只有一个数据库时写入的数据兼容。
+// 以 \x00DB 开头的键名保留给非 0 号数据库，0 号数据库的 KEYS、SCAN、DBSIZE 不包括它们。
+//
+// FLUSHDB ASYNC 把非 0 号数据库换到新的代：之后的键使用前缀 "\x00DB<编号>.<代>\x00"，
+// 旧的代立即不可见，由后台分批删除。两个非 0 号数据库的 SWAPDB 交换它们的键名前缀，
+// 之后数据库使用的前缀中的编号可能是另一个数据库的（见 dbSlot）。前缀只存在于存储中的键名里，
+// AOF 与复制使用数据库编号的第 0 代键名（见 CanonicalDBKey）
+
+// dbKeyMarker 非 0 号数据库键名的开头
+const dbKeyMarker = "\x00DB"
+
+const (
+	// metaDBGenPrefix 不使用自己第 0 代前缀的数据库当前的前缀，键为 META:dbgen:<编号>。
+	// 前缀中的编号与数据库相同时值为代（8 字节大端整数），否则为前缀中的编号与代（各 8 字节）
+	metaDBGenPrefix = "META:dbgen:"
+	// metaDBReclaimPrefix 等待后台回收的旧代，键为 META:dbreclaim:<旧代的键名前缀>；重启后继续回收
+	metaDBReclaimPrefix = "META:dbreclaim:"
+	// metaDBSwapKey 正在进行的与 0 号数据库的 SWAPDB（见 swapDBZero），值为阶段（1 字节）、
+	// 另一个数据库的编号与暂存区的前缀中的编号、代（各 8 字节大端整数）；重启后据此完成或撤销交换
+	metaDBSwapKey = "META:dbswap"
+)
+
+// dbSlot 键名前缀 "\x00DB<num>.<gen>\x00"（见 dbGenPrefix）。数据库 db 最初使用 {db, 0}
+type dbSlot struct {
+	num int
+	gen uint64
+}
+
+// prefix 键名前缀
+func (sl dbSlot) prefix() string {
+	return dbGenPrefix(sl.num, sl.gen)
+}
+
+// dbGenerations 各数据库当前的键名前缀与旧代的后台回收
+type dbGenerations struct {
+	mu sync.RWMutex
+	// slots 不使用自己第 0 代前缀的数据库当前的前缀，owners 为反向映射
+	slots  map[int]dbSlot
+	owners map[dbSlot]int
+	// active 是否有数据库不使用自己第 0 代的前缀，没有时不需要查表
+	active atomic.Bool
+	// swap 串行执行 SWAPDB 与 FLUSHDB ASYNC 的换代
+	swap    sync.Mutex
+	pending atomic.Int64
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// slotLocked 数据库 db 当前的键名前缀，调用方持有 mu
+func (g *dbGenerations) slotLocked(db int) dbSlot {
+	if sl, ok := g.slots[db]; ok {
+		return sl
+	}
+	return dbSlot{num: db}
+}
+
+// setSlotLocked 把数据库 db 换到键名前缀 sl，调用方持有 mu 的写锁
+func (g *dbGenerations) setSlotLocked(db int, sl dbSlot) {
+	if old, ok := g.slots[db]; ok && g.owners[old] == db {
+		delete(g.owners, old)
+	}
+	delete(g.slots, db)
+	if sl != (dbSlot{num: db}) {
+		g.slots[db] = sl
+		g.owners[sl] = db
+	}
+	g.active.Store(len(g.slots) > 0)
+}
+
+// setDBSlotTxn 在 txn 中记录数据库 db 当前的键名前缀
+func setDBSlotTxn(txn *storeTxn, db int, sl dbSlot) error {
+	key := []byte(metaDBGenPrefix + strconv.Itoa(db))
+	switch {
+	case sl == dbSlot{num: db}:
+		return txn.Delete(key)
+	case sl.num == db:
+		return txn.Set(key, binary.BigEndian.AppendUint64(nil, sl.gen))
+	}
+	// #nosec G115 - 数据库编号为非负整数
+	val := binary.BigEndian.AppendUint64(nil, uint64(sl.num))
+	return txn.Set(key, binary.BigEndian.AppendUint64(val, sl.gen))
+}
+
+// dbGenPrefix 数据库 db 第 gen 代的键名前缀，0 号数据库为空
+func dbGenPrefix(db int, gen uint64) string {
+	if db == 0 {
+		return ""
+	}
+	if gen == 0 {
+		return dbKeyMarker + strconv.Itoa(db) + "\x00"
+	}
+	return dbKeyMarker + strconv.Itoa(db) + "." + strconv.FormatUint(gen, 10) + "\x00"
+}
+
+// dbSlotOf 数据库 db 当前的键名前缀
+func (s *BotreonStore) dbSlotOf(db int) dbSlot {
+	g := &s.dbGens
+	if !g.active.Load() {
+		return dbSlot{num: db}
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.slotLocked(db)
+}
+
+// dbOfSlot 当前使用键名前缀 sl 的数据库；sl 是正在回收的旧代或 SWAPDB 的暂存区时 ok 为 false
+func (s *BotreonStore) dbOfSlot(sl dbSlot) (db int, ok bool) {
+	g := &s.dbGens
+	if !g.active.Load() {
+		return sl.num, sl.gen == 0
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if db, ok := g.owners[sl]; ok {
+		return db, true
+	}
+	_, moved := g.slots[sl.num]
+	return sl.num, sl.gen == 0 && !moved
+}
+
+// dbPrefix 数据库 db 当前的键名前缀
+func (s *BotreonStore) dbPrefix(db int) string {
+	return s.dbSlotOf(db).prefix()
+}
+
+// DBKey 数据库 db 中的键 key 在 AOF 与复制中使用的键名（第 0 代）。存储中的键名见 BotreonStore.StoreKey
+func DBKey(db int, key string) string {
+	return dbGenPrefix(db, 0) + key
+}
+
+// StoreKey 数据库 db 中的键 key 在存储中的键名
+func (s *BotreonStore) StoreKey(db int, key string) string {
+	if db == 0 {
+		return key
+	}
+	return s.dbPrefix(db) + key
+}
+
+// splitDBPrefix 把存储中的键名拆分为前缀中的编号、代与数据库中的键名；不是合法前缀的键属于 0 号数据库
+func splitDBPrefix(physical string) (db int, gen uint64, key string) {
+	if !strings.HasPrefix(physical, dbKeyMarker) {
+		return 0, 0, physical
+	}
+	rest := physical[len(dbKeyMarker):]
+	end := strings.IndexByte(rest, 0)
+	if end <= 0 {
+		return 0, 0, physical
+	}
+	num := rest[:end]
+	if dot := strings.IndexByte(num, '.'); dot >= 0 {
+		g, err := strconv.ParseUint(num[dot+1:], 10, 64)
+		if err != nil || g == 0 {
+			return 0, 0, physical
+		}
+		num, gen = num[:dot], g
+	}
+	n, err := strconv.Atoi(num)
+	if err != nil || n <= 0 {
+		return 0, 0, physical
+	}
+	return n, gen, rest[end+1:]
+}
+
+// SplitDBKey 把 DBKey 的键名拆分为数据库编号与数据库中的键名，是 DBKey 的逆运算。
+// 存储中的键名也可以用它取得数据库中的键名，所在的数据库见 BotreonStore.SplitStoreKey
+func SplitDBKey(physical string) (db int, key string) {
+	db, _, key = splitDBPrefix(physical)
+	return db, key
+}
+
+// SplitStoreKey 把存储中的键名拆分为键当前所在的数据库与数据库中的键名，是 StoreKey 的逆运算
+func (s *BotreonStore) SplitStoreKey(physical string) (db int, key string) {
+	num, gen, key := splitDBPrefix(physical)
+	db, _ = s.dbOfSlot(dbSlot{num: num, gen: gen})
+	return db, key
+}
+
+// CanonicalDBKey 存储中的键名对应的第 0 代键名，写入 AOF 与复制流；键属于正在回收的旧代时 ok 为 false
+func (s *BotreonStore) CanonicalDBKey(physical string) (key string, ok bool) {
+	num, gen, name := splitDBPrefix(physical)
+	if gen == 0 && !s.dbGens.active.Load() {
+		return physical, true
+	}
+	db, ok := s.dbOfSlot(dbSlot{num: num, gen: gen})
+	if !ok {
+		return physical, false
+	}
+	return DBKey(db, name), true
+}
+
+// ResolveDBKey CanonicalDBKey 的逆运算：第 0 代键名对应的存储中的键名，重放 AOF 时使用
+func (s *BotreonStore) ResolveDBKey(key string) string {
+	if !s.dbGens.active.Load() {
+		return key
+	}
+	db, name := SplitDBKey(key)
+	return s.StoreKey(db, name)
+}
+
+// dbKeyOf 类型键对应的数据库中的键名，prefix 为数据库的键名前缀；不属于该数据库时 ok 为 false
+// （只在前缀为空即 0 号数据库时可能发生：遍历 TYPE_ 前缀也会遇到其他数据库的键）
+func dbKeyOf(prefix string, typeKey []byte) (key string, ok bool) {
+	physical := typeKey[len(prefixKeyTypeBytes):]
+	if prefix == "" {
+		return string(physical), !bytes.HasPrefix(physical, []byte(dbKeyMarker))
+	}
+	return string(physical[len(prefix):]), true
+}
+
+// DBKeyCounts 各个非空数据库的键数（不含正在回收的旧代），用于 INFO keyspace
+func (s *BotreonStore) DBKeyCounts() (map[int]int64, error) {
+	counts := make(map[int]int64)
+	err := s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefixKeyTypeBytes
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			num, gen, _ := splitDBPrefix(string(iter.Item().Key()[len(prefixKeyTypeBytes):]))
+			if db, ok := s.dbOfSlot(dbSlot{num: num, gen: gen}); ok {
+				counts[db]++
+			}
+		}
+		return nil
+	})
+	return counts, err
+}
+
+// FlushDatabase 实现 FLUSHDB：删除数据库 db 的全部键。开启回收站时逐个移入回收站；
+// 存储中只有这个数据库的键时用 DropAll 清空存储，否则用 DropPrefix 删除数据库的 Badger 键。
+// async 时非 0 号数据库换到新的代后立即返回，旧的代由后台回收；0 号数据库的键没有前缀、不能换代，async 与同步相同
+func (s *BotreonStore) FlushDatabase(db int, async bool) error {
+	if s.TrashRetention() > 0 {
+		keys, err := s.allDBKeys(db)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if _, err := s.DelToTrash(s.StoreKey(db, key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	others, err := s.hasKeysOutside(db)
+	if err != nil {
+		return err
+	}
+	if !others {
+		return s.FlushDB()
+	}
+	if async && db != 0 {
+		return s.swapDBGeneration(db)
+	}
+	return s.dropDB(db)
+}
+
+// hasKeysOutside 存储中是否有其他数据库的键（正在回收的旧代不算）
+func (s *BotreonStore) hasKeysOutside(db int) (bool, error) {
+	found := false
+	err := s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefixKeyTypeBytes
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			num, gen, _ := splitDBPrefix(string(iter.Item().Key()[len(prefixKeyTypeBytes):]))
+			if other, ok := s.dbOfSlot(dbSlot{num: num, gen: gen}); ok && other != db {
+				found = true
+				return nil
+			}
+		}
+		return nil
+	})
+	return found, err
+}
+
+// dbLayoutBases 各种 Badger 键中用户键之前的部分（TYPE_、EXPIRE_、STRING: 等，去重，不含 Retain 的部分）。
+// 数据库的全部 Badger 键都以某个 base 加上数据库的键名前缀开头
+func dbLayoutBases() []string {
+	seen := make(map[string]bool)
+	var bases []string
+	add := func(part keyLayoutPart) {
+		fn := part.Prefix
+		if fn == nil {
+			fn = part.Exact
+		}
+		if fn == nil || part.Retain {
+			return
+		}
+		empty, full := fn(""), fn("\x00")
+		off := 0
+		for off < len(empty) && off < len(full) && empty[off] == full[off] {
+			off++
+		}
+		if base := string(full[:off]); !seen[base] {
+			seen[base] = true
+			bases = append(bases, base)
+		}
+	}
+	add(typeKeyPart)
+	add(expireKeyPart)
+	for _, parts := range keyLayouts {
+		for _, part := range parts {
+			add(part)
+		}
+	}
+	sort.Strings(bases)
+	return bases
+}
+
+// dropDB 用 DropPrefix 删除数据库 db 的全部 Badger 键。0 号数据库的键没有前缀，
+// 按首字节分为 255 个区间删除，空键名与以 \x00 开头的键（很少见）逐个删除。
+// DropPrefix 不经过 update，之后与 FlushDB 相同地使 WATCH 与读缓存失效、重新统计数据大小，并删除搜索索引中的文档
+func (s *BotreonStore) dropDB(db int) error {
+	prefix := s.dbPrefix(db)
+	var prefixes [][]byte
+	for _, base := range dbLayoutBases() {
+		if prefix != "" {
+			prefixes = append(prefixes, []byte(base+prefix))
+			continue
+		}
+		for c := 1; c < 256; c++ {
+			prefixes = append(prefixes, append([]byte(base), byte(c)))
+		}
+	}
+	if prefix == "" {
+		keys, err := s.dbZeroLeadKeys()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if _, err := s.Del(key); err != nil {
+				return err
+			}
+		}
+	}
+	if err := s.db.DropPrefix(prefixes...); err != nil {
+		return err
+	}
+	if err := s.bumpDropEpoch(); err != nil {
+		return err
+	}
+	s.touchAll()
+	s.readCache.clear()
+	s.resetDataSize()
+	return s.unindexDB(prefix)
+}
+
+// dbZeroLeadKeys 0 号数据库中不在首字节区间内的键：空键名与以 \x00 开头、不是数据库前缀的键
+func (s *BotreonStore) dbZeroLeadKeys() ([]string, error) {
+	var keys []string
+	err := s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+		end := TypeOfKeyGet("\x01")
+		marker := TypeOfKeyGet(dbKeyMarker)
+		iter.Seek(prefixKeyTypeBytes)
+		for iter.Valid() {
+			k := iter.Item().Key()
+			if bytes.Compare(k, end) >= 0 {
+				break
+			}
+			// 跳过其他数据库的全部键：\x00DC 是 \x00DB 之后的第一个前缀
+			if bytes.HasPrefix(k, marker) {
+				iter.Seek(TypeOfKeyGet("\x00DC"))
+				continue
+			}
+			keys = append(keys, string(k[len(prefixKeyTypeBytes):]))
+			iter.Next()
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// unindexDB 删除键名前缀为 prefix 的数据库中的文档在各搜索索引中的条目（文档已不存在，重新索引即删除）
+func (s *BotreonStore) unindexDB(prefix string) error {
+	if !s.search.active.Load() {
+		return nil
+	}
+	s.search.mu.RLock()
+	indexes := make([]*searchIndex, 0, len(s.search.byName))
+	for _, idx := range s.search.byName {
+		indexes = append(indexes, idx)
+	}
+	s.search.mu.RUnlock()
+	for _, idx := range indexes {
+		docs, err := s.searchDocs(idx)
+		if err != nil {
+			return err
+		}
+		var keys []string
+		for _, key := range docs {
+			if strings.HasPrefix(key, prefix) && (prefix != "" || !strings.HasPrefix(key, dbKeyMarker)) {
+				keys = append(keys, key)
+			}
+		}
+		for len(keys) > 0 {
+			batch := keys[:min(len(keys), searchBackfillBatch)]
+			keys = keys[len(batch):]
+			err := s.retryUpdate(func(txn *storeTxn) error {
+				for _, key := range batch {
+					if err := s.searchReindexTxn(txn, idx, key); err != nil {
+						return err
+					}
+				}
+				return nil
+			}, 30)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// errDBReclaimStopped 关闭存储时中断旧代的回收，下次启动时继续
+var errDBReclaimStopped = errors.New("database reclamation stopped")
+
+// loadDBGenerations 启动时读取各数据库当前的键名前缀，并继续回收上次关闭前未完成的旧代
+func (s *BotreonStore) loadDBGenerations() error {
+	g := &s.dbGens
+	g.slots = make(map[int]dbSlot)
+	g.owners = make(map[dbSlot]int)
+	g.stop = make(chan struct{})
+	var pending []string
+	err := s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(metaDBGenPrefix)
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			db, err := strconv.Atoi(string(iter.Item().Key()[len(metaDBGenPrefix):]))
+			if err != nil {
+				continue
+			}
+			val, err := iter.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			switch len(val) {
+			case 8:
+				g.setSlotLocked(db, dbSlot{num: db, gen: binary.BigEndian.Uint64(val)})
+			case 16:
+				// #nosec G115 - 写入的是非负的数据库编号
+				g.setSlotLocked(db, dbSlot{num: int(binary.BigEndian.Uint64(val)), gen: binary.BigEndian.Uint64(val[8:])})
+			}
+		}
+
+		opts.Prefix = []byte(metaDBReclaimPrefix)
+		opts.PrefetchValues = false
+		reclaim := txn.NewIterator(opts)
+		defer reclaim.Close()
+		for reclaim.Rewind(); reclaim.Valid(); reclaim.Next() {
+			pending = append(pending, string(reclaim.Item().Key()[len(metaDBReclaimPrefix):]))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		logger.Logger.Info().Int("generations", len(pending)).Msg("继续回收 FLUSHDB ASYNC 清空的数据")
+	}
+	for _, prefix := range pending {
+		s.startDBReclaim(prefix)
+	}
+	return nil
+}
+
+// saveDBGenerations DropAll 之后重新写入各数据库当前的键名前缀：用过的键名前缀不能再次使用，
+// 否则尚未结束的回收会删除之后写入的键
+func (s *BotreonStore) saveDBGenerations() error {
+	g := &s.dbGens
+	if !g.active.Load() {
+		return nil
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return s.retryUpdate(func(txn *storeTxn) error {
+		for db, sl := range g.slots {
+			if err := setDBSlotTxn(txn, db, sl); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, 30)
+}
+
+// stopDBReclaim 停止旧代的回收，正在回收的在当前批次后中断
+func (s *BotreonStore) stopDBReclaim() {
+	if s.dbGens.stop == nil {
+		return
+	}
+	close(s.dbGens.stop)
+	s.dbGens.wg.Wait()
+}
+
+// swapDBGeneration 实现 FLUSHDB ASYNC：在一个事务中写入数据库 db 的新的代与旧代的回收记录，
+// 之后的命令使用新的键名前缀，旧代的键不再可见，由后台回收。前缀中的编号的代只增不减，新的代不会与用过的前缀重复
+func (s *BotreonStore) swapDBGeneration(db int) error {
+	g := &s.dbGens
+	g.swap.Lock()
+	defer g.swap.Unlock()
+	return s.switchDBSlot(db, func(old dbSlot) dbSlot {
+		return dbSlot{num: old.num, gen: old.gen + 1}
+	}, nil)
+}
+
+// switchDBSlot 在一个事务中把数据库 db 换到 next 返回的键名前缀，写入旧前缀的回收记录，并执行 extra（可为 nil），
+// 之后使 WATCH 与读缓存失效并在后台回收旧前缀下的键
+func (s *BotreonStore) switchDBSlot(db int, next func(old dbSlot) dbSlot, extra func(txn *storeTxn) error) error {
+	g := &s.dbGens
+	g.mu.Lock()
+	old := g.slotLocked(db)
+	sl := next(old)
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		if err := setDBSlotTxn(txn, db, sl); err != nil {
+			return err
+		}
+		if extra != nil {
+			if err := extra(txn); err != nil {
+				return err
+			}
+		}
+		return txn.Set([]byte(metaDBReclaimPrefix+old.prefix()), nil)
+	}, 30)
+	if err == nil {
+		g.setSlotLocked(db, sl)
+	}
+	g.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	s.touchAll()
+	s.readCache.clear()
+	s.startDBReclaim(old.prefix())
+	return nil
+}
+
+// DBReclaimPending 正在后台回收的旧代个数
+func (s *BotreonStore) DBReclaimPending() int64 {
+	return s.dbGens.pending.Load()
+}
+
+// startDBReclaim 在后台回收键名前缀为 prefix 的旧代
+func (s *BotreonStore) startDBReclaim(prefix string) {
+	g := &s.dbGens
+	g.pending.Add(1)
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer g.pending.Add(-1)
+		err := s.reclaimDBGeneration(prefix)
+		if err != nil && !errors.Is(err, errDBReclaimStopped) {
+			// 回收记录仍然保留，下次启动时重试
+			logger.Logger.Error().Err(err).Str("prefix", strconv.Quote(prefix)).Msg("回收 FLUSHDB ASYNC 清空的数据失败")
+		}
+	}()
+}
+
+// reclaimDBGeneration 按 dbLayoutBases 分批删除旧代的 Badger 键，完成后删除回收记录。
+// 换代之前开始执行的命令可能在换代之后才提交，所以重复遍历直到没有可删除的键
+func (s *BotreonStore) reclaimDBGeneration(prefix string) error {
+	for {
+		deleted := 0
+		for _, base := range dbLayoutBases() {
+			n, err := s.deletePrefixBatched([]byte(base + prefix))
+			if err != nil {
+				return err
+			}
+			deleted += n
+		}
+		if deleted == 0 {
+			break
+		}
+	}
+	return s.retryUpdate(func(txn *storeTxn) error {
+		return txn.Delete([]byte(metaDBReclaimPrefix + prefix))
+	}, 30)
+}
+
+// deletePrefixBatched 每个事务删除 unlinkBatch 个以 prefix 开头的 Badger 键，返回删除的键数
+func (s *BotreonStore) deletePrefixBatched(prefix []byte) (int, error) {
+	total := 0
+	for {
+		select {
+		case <-s.dbGens.stop:
+			return total, errDBReclaimStopped
+		default:
+		}
+		var keys [][]byte
+		err := s.view(func(txn *storeTxn) error {
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			opts.Prefix = prefix
+			iter := txn.NewIterator(opts)
+			defer iter.Close()
+			for iter.Rewind(); iter.Valid() && len(keys) < unlinkBatch; iter.Next() {
+				keys = append(keys, iter.Item().KeyCopy(nil))
+			}
+			return nil
+		})
+		if err != nil || len(keys) == 0 {
+			return total, err
+		}
+		err = s.retryUpdate(func(txn *storeTxn) error {
+			for _, k := range keys {
+				if err := txn.Delete(k); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, 30)
+		if err != nil {
+			return total, err
+		}
+		total += len(keys)
+	}
+}
+
+// MoveKey 实现 MOVE：把存储中的键 key 移到数据库 db 中的同名键，过期时间随键移动。
+// 源键不存在、目标数据库中已有同名键或已在该数据库中时返回 false
+func (s *BotreonStore) MoveKey(key string, db int) (bool, error) {
+	src, name := s.SplitStoreKey(key)
+	if src == db {
+		return false, nil
+	}
+	exists, err := s.Exists(key)
+	if err != nil || !exists {
+		return false, err
+	}
+	return s.RenameNX(key, s.StoreKey(db, name))
+}
+
+// SwapDB 实现 SWAPDB：交换两个数据库的全部键。两个数据库都不是 0 号时在一个事务中交换它们的键名前缀，
+// 与键数无关；0 号数据库的键没有前缀，只能逐个重命名（见 swapDBZero）。同一时刻只执行一个 SWAPDB，
+// 调用方需保证交换期间没有其他命令读写这两个数据库（服务端独占执行 SWAPDB）
+func (s *BotreonStore) SwapDB(a, b int) error {
+	if a == b {
+		return nil
+	}
+	g := &s.dbGens
+	g.swap.Lock()
+	defer g.swap.Unlock()
+	if a == 0 || b == 0 {
+		return s.swapDBZero(a + b)
+	}
+	g.mu.Lock()
+	slotA, slotB := g.slotLocked(a), g.slotLocked(b)
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		if err := setDBSlotTxn(txn, a, slotB); err != nil {
+			return err
+		}
+		return setDBSlotTxn(txn, b, slotA)
+	}, 30)
+	if err == nil {
+		g.setSlotLocked(a, slotB)
+		g.setSlotLocked(b, slotA)
+	}
+	g.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	s.touchAll()
+	return nil
+}
+
+// dbSwap 与 0 号数据库的 SWAPDB 的进度，见 metaDBSwapKey
+type dbSwap struct {
+	// phase 1：0 号数据库的键正在移到暂存区；2：db 的键正在移到 0 号数据库
+	phase   byte
+	db      int
+	staging dbSlot
+}
+
+// saveDBSwap 记录交换的进度
+func (s *BotreonStore) saveDBSwap(swap dbSwap) error {
+	// #nosec G115 - 数据库编号为非负整数
+	val := binary.BigEndian.AppendUint64([]byte{swap.phase}, uint64(swap.db))
+	// #nosec G115 - 数据库编号为非负整数
+	val = binary.BigEndian.AppendUint64(val, uint64(swap.staging.num))
+	val = binary.BigEndian.AppendUint64(val, swap.staging.gen)
+	return s.retryUpdate(func(txn *storeTxn) error {
+		return txn.Set([]byte(metaDBSwapKey), val)
+	}, 30)
+}
+
+// swapDBZero 交换 0 号数据库与数据库 x：先把 0 号数据库的键逐个重命名到 x 的下一代前缀（暂存区，
+// 不属于任何数据库），再把 x 的键重命名到 0 号数据库，最后在一个事务中把 x 换到暂存区的前缀（见 resumeDBSwap）。
+// 进度记录在 metaDBSwapKey 中：第一阶段出错时把暂存的键移回 0 号数据库，第二阶段出错时继续完成交换；
+// 仍然失败或进程中途退出时记录保留，启动时同样处理（见 recoverDBSwap）
+func (s *BotreonStore) swapDBZero(x int) error {
+	cur := s.dbSlotOf(x)
+	swap := dbSwap{phase: 1, db: x, staging: dbSlot{num: cur.num, gen: cur.gen + 1}}
+	if err := s.saveDBSwap(swap); err != nil {
+		return err
+	}
+	err := s.renameDBKeys(0, swap.staging.prefix())
+	if err != nil {
+		return errors.Join(err, s.resumeDBSwap(swap))
+	}
+	swap.phase = 2
+	if err := s.saveDBSwap(swap); err != nil {
+		return err
+	}
+	return s.resumeDBSwap(swap)
+}
+
+// resumeDBSwap 处理未完成的交换，完成后删除 metaDBSwapKey。第一阶段撤销交换：把暂存区的键移回 0 号数据库；
+// 第二阶段完成交换：把 db 剩余的键移到 0 号数据库，再在一个事务中把 db 换到暂存区的前缀并删除记录，
+// 旧前缀（此时已没有键）与 FLUSHDB ASYNC 的旧代一样回收
+func (s *BotreonStore) resumeDBSwap(swap dbSwap) error {
+	if swap.phase == 1 {
+		keys, err := s.prefixedKeys(swap.staging.prefix())
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := s.renameExisting(swap.staging.prefix()+key, key); err != nil {
+				return err
+			}
+		}
+		return s.retryUpdate(func(txn *storeTxn) error {
+			return txn.Delete([]byte(metaDBSwapKey))
+		}, 30)
+	}
+	if err := s.renameDBKeys(swap.db, ""); err != nil {
+		return err
+	}
+	return s.switchDBSlot(swap.db, func(dbSlot) dbSlot {
+		return swap.staging
+	}, func(txn *storeTxn) error {
+		return txn.Delete([]byte(metaDBSwapKey))
+	})
+}
+
+// renameDBKeys 把数据库 db 的全部键逐个重命名为 prefix 加上数据库中的键名
+func (s *BotreonStore) renameDBKeys(db int, prefix string) error {
+	keys, err := s.allDBKeys(db)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.renameExisting(s.StoreKey(db, key), prefix+key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefixedKeys 存储中以 prefix 开头的键去掉 prefix 后的键名
+func (s *BotreonStore) prefixedKeys(prefix string) ([]string, error) {
+	var keys []string
+	err := s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = TypeOfKeyGet(prefix)
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			keys = append(keys, string(iter.Item().Key()[len(opts.Prefix):]))
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// recoverDBSwap 启动时完成或撤销上次关闭前未完成的与 0 号数据库的 SWAPDB
+func (s *BotreonStore) recoverDBSwap() error {
+	var swap dbSwap
+	found := false
+	err := s.view(func(txn *storeTxn) error {
+		item, err := txn.Get([]byte(metaDBSwapKey))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			if len(val) != 25 {
+				return fmt.Errorf("invalid SWAPDB record of %d bytes", len(val))
+			}
+			found = true
+			// #nosec G115 - 写入的是非负的数据库编号
+			swap = dbSwap{
+				phase:   val[0],
+				db:      int(binary.BigEndian.Uint64(val[1:])),
+				staging: dbSlot{num: int(binary.BigEndian.Uint64(val[9:])), gen: binary.BigEndian.Uint64(val[17:])},
+			}
+			return nil
+		})
+	})
+	if err != nil || !found {
+		return err
+	}
+	logger.Logger.Info().Int("db", swap.db).Msg("继续上次未完成的 SWAPDB")
+	return s.resumeDBSwap(swap)
+}
+
+// renameExisting 重命名键，键在列出之后已被删除（过期等）时跳过
+func (s *BotreonStore) renameExisting(key, newKey string) error {
+	err := s.Rename(key, newKey)
+	if err != nil {
+		if exists, existsErr := s.Exists(key); existsErr == nil && !exists {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,267 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/zeebo/assert"
+)
+
+func TestDBKey(t *testing.T) {
+	assert.Equal(t, "k", DBKey(0, "k"))
+	for _, tt := range []struct {
+		db  int
+		key string
+	}{{0, "k"}, {3, "k"}, {12, ""}, {1, "\x00DB2\x00x"}} {
+		db, key := SplitDBKey(DBKey(tt.db, tt.key))
+		assert.Equal(t, tt.db, db)
+		assert.Equal(t, tt.key, key)
+		db, key = SplitDBKey(dbGenPrefix(tt.db, 7) + tt.key)
+		assert.Equal(t, tt.db, db)
+		assert.Equal(t, tt.key, key)
+	}
+	// 不是合法前缀的键属于 0 号数据库
+	db, key := SplitDBKey("\x00DBx\x00k")
+	assert.Equal(t, 0, db)
+	assert.Equal(t, "\x00DBx\x00k", key)
+}
+
+func TestLogicalDatabases(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.Set("a", "0"))
+	assert.NoError(t, store.Set(DBKey(1, "a"), "1"))
+	assert.NoError(t, store.Set(DBKey(1, "b"), "1"))
+	assert.NoError(t, store.Set(DBKey(10, "c"), "10"))
+
+	keys, err := store.DBKeys(0, "*")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"a"}, keys)
+	keys, err = store.DBKeys(1, "*")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"a", "b"}, keys)
+	n, err := store.DBSize(10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	result, err := store.DBScan(1, 0, "b*", 10, "")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"b"}, result.Keys)
+	counts, err := store.DBKeyCounts()
+	assert.NoError(t, err)
+	assert.DeepEqual(t, map[int]int64{0: 1, 1: 2, 10: 1}, counts)
+
+	// MOVE 保留过期时间，目标数据库已有同名键时不移动
+	assert.NoError(t, store.SetWithTTL(DBKey(1, "ttl"), "v", time.Hour))
+	moved, err := store.MoveKey(DBKey(1, "ttl"), 2)
+	assert.NoError(t, err)
+	assert.True(t, moved)
+	ttl, err := store.TTL(DBKey(2, "ttl"))
+	assert.NoError(t, err)
+	assert.True(t, ttl > 3590 && ttl <= 3600)
+	moved, err = store.MoveKey(DBKey(1, "a"), 0)
+	assert.NoError(t, err)
+	assert.False(t, moved)
+	moved, err = store.MoveKey(DBKey(1, "missing"), 0)
+	assert.NoError(t, err)
+	assert.False(t, moved)
+
+	assert.NoError(t, store.SwapDB(0, 1))
+	val, err := store.Get("b")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", val)
+	val, err = store.Get(store.StoreKey(1, "a"))
+	assert.NoError(t, err)
+	assert.Equal(t, "0", val)
+
+	// 其他数据库有键时 FLUSHDB 只删除当前数据库的键
+	assert.NoError(t, store.FlushDatabase(0, false))
+	counts, err = store.DBKeyCounts()
+	assert.NoError(t, err)
+	assert.DeepEqual(t, map[int]int64{1: 1, 2: 1, 10: 1}, counts)
+}
+
+func TestFlushDatabase(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBadgerStore(dir)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.Set("a", "0"))
+	assert.NoError(t, store.Set(DBKey(1, "s"), "1"))
+	assert.NoError(t, store.SetWithTTL(DBKey(1, "ttl"), "1", time.Hour))
+	assert.NoError(t, store.HSet(DBKey(1, "h"), "f", "v"))
+	_, err = store.RPush(DBKey(1, "l"), "x", "y")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Set(DBKey(2, "s"), "2"))
+
+	// ASYNC：换代之后旧的键立即不可见，新写入的键使用新的前缀
+	assert.NoError(t, store.FlushDatabase(1, true))
+	assert.Equal(t, uint64(0), store.DropEpoch())
+	n, err := store.DBSize(1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	physical := store.StoreKey(1, "h")
+	assert.Equal(t, dbGenPrefix(1, 1)+"h", physical)
+	canonical, ok := store.CanonicalDBKey(physical)
+	assert.True(t, ok)
+	assert.Equal(t, DBKey(1, "h"), canonical)
+	assert.Equal(t, physical, store.ResolveDBKey(canonical))
+	_, ok = store.CanonicalDBKey(DBKey(1, "h"))
+	assert.False(t, ok)
+	assert.NoError(t, store.HSet(physical, "f2", "v2"))
+
+	store.dbGens.wg.Wait()
+	assert.Equal(t, int64(0), store.DBReclaimPending())
+	err = store.view(func(txn *storeTxn) error {
+		iter := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iter.Close()
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			// 过期索引的条目到期后作为过时的条目删除
+			if bytes.HasPrefix(iter.Item().Key(), []byte(metaKeyExpirePrefix)) {
+				continue
+			}
+			assert.False(t, bytes.Contains(iter.Item().Key(), []byte(dbGenPrefix(1, 0))))
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	counts, err := store.DBKeyCounts()
+	assert.NoError(t, err)
+	assert.DeepEqual(t, map[int]int64{0: 1, 1: 1, 2: 1}, counts)
+
+	// 同步清空：其他数据库的键不受影响
+	assert.NoError(t, store.FlushDatabase(2, false))
+	counts, err = store.DBKeyCounts()
+	assert.NoError(t, err)
+	assert.DeepEqual(t, map[int]int64{0: 1, 1: 1}, counts)
+	// DropPrefix 不写删除标记，增量备份据此改做全量备份
+	assert.Equal(t, uint64(1), store.DropEpoch())
+
+	// 代在重启后保留
+	assert.NoError(t, store.Close())
+	store, err = NewBadgerStore(dir)
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.Equal(t, physical, store.StoreKey(1, "h"))
+	assert.Equal(t, uint64(1), store.DropEpoch())
+	val, err := store.HGet(physical, "f2")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", string(val))
+
+	// 只剩一个数据库时用 DropAll，之后代仍然不变
+	assert.NoError(t, store.FlushDatabase(0, false))
+	assert.NoError(t, store.FlushDatabase(1, true))
+	assert.Equal(t, int64(0), store.DBReclaimPending())
+	counts, err = store.DBKeyCounts()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(counts))
+	assert.NoError(t, store.loadDBGenerations())
+	assert.Equal(t, physical, store.StoreKey(1, "h"))
+}
+
+// TestSwapDBPrefixes 非 0 号数据库的 SWAPDB 交换键名前缀，不移动键
+func TestSwapDBPrefixes(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBadgerStore(dir)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.Set(DBKey(1, "a"), "1"))
+	assert.NoError(t, store.Set(DBKey(2, "b"), "2"))
+	assert.NoError(t, store.Set(DBKey(2, "c"), "2"))
+
+	assert.NoError(t, store.SwapDB(1, 2))
+	assert.Equal(t, dbGenPrefix(2, 0)+"b", store.StoreKey(1, "b"))
+	keys, err := store.DBKeys(1, "*")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"b", "c"}, keys)
+	counts, err := store.DBKeyCounts()
+	assert.NoError(t, err)
+	assert.DeepEqual(t, map[int]int64{1: 2, 2: 1}, counts)
+	db, key := store.SplitStoreKey(store.StoreKey(2, "a"))
+	assert.Equal(t, 2, db)
+	assert.Equal(t, "a", key)
+	canonical, ok := store.CanonicalDBKey(store.StoreKey(1, "b"))
+	assert.True(t, ok)
+	assert.Equal(t, DBKey(1, "b"), canonical)
+
+	// FLUSHDB ASYNC 换到交换来的前缀的下一代，另一个数据库不受影响
+	assert.NoError(t, store.FlushDatabase(1, true))
+	assert.Equal(t, dbGenPrefix(2, 1)+"b", store.StoreKey(1, "b"))
+	store.dbGens.wg.Wait()
+	val, err := store.Get(store.StoreKey(2, "a"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1", val)
+	assert.NoError(t, store.Set(store.StoreKey(1, "d"), "1"))
+
+	// 前缀在重启后保留，换回自己第 0 代的前缀时删除记录
+	assert.NoError(t, store.Close())
+	store, err = NewBadgerStore(dir)
+	assert.NoError(t, err)
+	defer store.Close()
+	counts, err = store.DBKeyCounts()
+	assert.NoError(t, err)
+	assert.DeepEqual(t, map[int]int64{1: 1, 2: 1}, counts)
+	assert.NoError(t, store.SwapDB(2, 1))
+	assert.Equal(t, dbGenPrefix(1, 0)+"a", store.StoreKey(1, "a"))
+	val, err = store.Get(store.StoreKey(1, "a"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1", val)
+	err = store.view(func(txn *storeTxn) error {
+		_, err := txn.Get([]byte(metaDBGenPrefix + "1"))
+		assert.Equal(t, badger.ErrKeyNotFound, err)
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+// TestSwapDBZeroRecovery 与 0 号数据库交换到一半退出时，重启后第一阶段撤销、第二阶段完成
+func TestSwapDBZeroRecovery(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBadgerStore(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Set("a", "0"))
+	assert.NoError(t, store.Set("b", "0"))
+	assert.NoError(t, store.Set(DBKey(3, "c"), "3"))
+
+	// 第一阶段：只暂存了一个键
+	swap := dbSwap{phase: 1, db: 3, staging: dbSlot{num: 3, gen: 1}}
+	assert.NoError(t, store.saveDBSwap(swap))
+	assert.NoError(t, store.Rename("a", swap.staging.prefix()+"a"))
+	assert.NoError(t, store.Close())
+	store, err = NewBadgerStore(dir)
+	assert.NoError(t, err)
+	keys, err := store.DBKeys(0, "*")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"a", "b"}, keys)
+	keys, err = store.DBKeys(3, "*")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"c"}, keys)
+
+	// 第二阶段：0 号数据库的键已全部暂存，数据库 3 的键还没有移动
+	assert.NoError(t, store.saveDBSwap(swap))
+	assert.NoError(t, store.renameDBKeys(0, swap.staging.prefix()))
+	swap.phase = 2
+	assert.NoError(t, store.saveDBSwap(swap))
+	assert.NoError(t, store.Close())
+	store, err = NewBadgerStore(dir)
+	assert.NoError(t, err)
+	defer store.Close()
+	keys, err = store.DBKeys(0, "*")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"c"}, keys)
+	keys, err = store.DBKeys(3, "*")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"a", "b"}, keys)
+	counts, err := store.DBKeyCounts()
+	assert.NoError(t, err)
+	assert.DeepEqual(t, map[int]int64{0: 1, 3: 2}, counts)
+	err = store.view(func(txn *storeTxn) error {
+		_, err := txn.Get([]byte(metaDBSwapKey))
+		assert.Equal(t, badger.ErrKeyNotFound, err)
+		return nil
+	})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// DefaultDecompressCacheSize 解压缓存的默认容量（字节）
+	DefaultDecompressCacheSize = 64 << 20
+
+	// decompressEntryOverhead 每个条目除键和值以外的估算开销（链表节点、map 项等）
+	decompressEntryOverhead = 96
+	// decompressMaxEntryShare 超过容量 1/decompressMaxEntryShare 的值不缓存，避免单个大值挤掉所有条目
+	decompressMaxEntryShare = 8
+)
+
+// DecompressCacheStats 解压缓存的命中统计。只统计压缩存储的值，未压缩的值不经过缓存
+type DecompressCacheStats struct {
+	Hits     int64
+	Misses   int64
+	Entries  int
+	Bytes    int64
+	Capacity int64
+}
+
+// decompressCache 按 Badger 键缓存解压后的值，条目记录值的版本：
+// 键被重新写入后版本变化，旧条目在下次读取时被替换，不需要在写路径上失效
+type decompressCache struct {
+	mu       sync.Mutex
+	capacity int64
+	size     int64
+	ll       *list.List // 表头为最近使用
+	items    map[string]*list.Element
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type decompressEntry struct {
+	key     string
+	version uint64
+	value   []byte
+}
+
+func (e *decompressEntry) cost() int64 {
+	return int64(len(e.key)+len(e.value)) + decompressEntryOverhead
+}
+
+// effectiveDecompressCacheSize 配置的容量，零值时使用 DefaultDecompressCacheSize，小于 0 时为 0（不缓存）
+func effectiveDecompressCacheSize(configured int64) int64 {
+	if configured == 0 {
+		return DefaultDecompressCacheSize
+	}
+	return max(configured, 0)
+}
+
+// newDecompressCache 创建容量为 capacity 字节的缓存，capacity 小于等于 0 时返回 nil（不缓存）
+func newDecompressCache(capacity int64) *decompressCache {
+	if capacity <= 0 {
+		return nil
+	}
+	return &decompressCache{capacity: capacity, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+// get 返回 key 在 version 版本的解压结果的副本（调用方可以修改）
+func (c *decompressCache) get(key []byte, version uint64) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[string(key)]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*decompressEntry)
+	if entry.version != version {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	c.hits.Add(1)
+	return append([]byte(nil), entry.value...), true
+}
+
+// add 缓存 key 在 version 版本的解压结果（保存副本），替换该键的旧版本，超出容量时淘汰最久未使用的条目
+func (c *decompressCache) add(key []byte, version uint64, value []byte) {
+	if c == nil {
+		return
+	}
+	c.misses.Add(1)
+	entry := &decompressEntry{key: string(key), version: version}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if int64(len(key)+len(value))+decompressEntryOverhead > c.capacity/decompressMaxEntryShare {
+		return
+	}
+	if el, ok := c.items[entry.key]; ok {
+		old := el.Value.(*decompressEntry)
+		if old.version > version {
+			// 并发读取时较旧的版本后到，保留较新的
+			return
+		}
+		c.removeElement(el)
+	}
+	entry.value = append([]byte(nil), value...)
+	c.items[entry.key] = c.ll.PushFront(entry)
+	c.size += entry.cost()
+	for c.size > c.capacity {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *decompressCache) removeElement(el *list.Element) {
+	entry := el.Value.(*decompressEntry)
+	c.ll.Remove(el)
+	delete(c.items, entry.key)
+	c.size -= entry.cost()
+}
+
+// DecompressCacheStats 返回解压缓存的统计，未开启时全部为 0
+func (s *BotreonStore) DecompressCacheStats() DecompressCacheStats {
+	c := s.decompressCache
+	if c == nil {
+		return DecompressCacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return DecompressCacheStats{
+		Hits:     c.hits.Load(),
+		Misses:   c.misses.Load(),
+		Entries:  c.ll.Len(),
+		Bytes:    c.size,
+		Capacity: c.capacity,
+	}
+}
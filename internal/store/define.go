@@ -2,7 +2,7 @@ package store
 
 import (
 	"sync"
-	"time"
+	"sync/atomic"
 
 	"github.com/dgraph-io/badger/v4"
 )
@@ -28,36 +28,89 @@ var (
 	prefixKeyTimeSeriesBytes = []byte("TS:")
 )
 
-// BlockingResult represents the result of a blocking pop operation
-type BlockingResult struct {
-	Key   string
-	Value string
-}
-
-// StreamReadResult represents the result of a stream read operation
-type StreamReadResult struct {
-	Key     string
-	Entries []StreamEntry
-}
-
 // BotreonStore is the main store structure
+// 共享的状态在 storeCore 中；TrackWrites 返回的句柄与原句柄共享状态，另外累计一个命令提交的写入
 type BotreonStore struct {
+	*storeCore
+	// writes 当前命令提交的写入，为 nil 时计入 InternalWriteSource，见 TrackWrites
+	writes *commandWrites
+}
+
+// storeCore 存储实例的状态
+type storeCore struct {
 	db              *badger.DB
 	compressionType CompressionType
 	// 缓存层
-	readCache  *LRUCache // 读缓存（用于 GET、HGET 等读操作）
-	writeCache *LRUCache // 写缓存（用于 SET、HSET 等写操作，减少磁盘写入）
+	readCache  *readThroughCache // 读缓存（GET、HGET），见 cache.go
+	// decompressCache 解压后的值，按 Badger 键和版本缓存，为 nil 时不缓存
+	decompressCache *decompressCache
 
 	// Key-level locking for atomic operations
 	keyLockMgr *KeyLockManager
 
 	// Blocking queue support
 	blockingMu     sync.RWMutex
-	blockingPopChans map[string][]chan BlockingResult // key -> channels waiting for data
+	blockingPopChans map[string][]chan struct{} // key -> 等待者的唤醒通道（先登记先唤醒）
+
+	// 阻塞客户端计数与上限
+	blocking *blockingBudget
+
+	// 有序集合前 N 名变化通知
+	zwatch zsetWatchers
+
+	// 回收站（DEL/FLUSHDB 软删除）
+	trash trashRetention
+
+	// 定时命令（BOLTREON.SCHEDULE）的序号
+	schedule scheduleSeq
+
+	// 命名空间（键前缀）的默认 TTL 与键数配额
+	namespaces namespaces
+
+	// 哈希与 JSON 文档的搜索索引（FT.CREATE/FT.SEARCH）
+	search searchIndexes
+	// UNLINK 的键的后台回收
+	unlink unlinkState
+	// 逻辑数据库的代（FLUSHDB ASYNC）与旧代的后台回收，见 database.go
+	dbGens dbGenerations
+	// 直接删除 Badger 数据的次数，见 DropEpoch
+	dropEpoch atomic.Uint64
+
+	// 按命令统计的写放大
+	writeStats writeStatsTracker
+	// WATCH 使用的键版本计数器
+	versions keyVersions
+	// SCAN 系列命令的游标
+	scanCursors scanCursors
+	// 键空间分析（ANALYZE）的进度与缓存结果
+	analyzer keyspaceAnalyzer
+	// 主动过期周期的游标与统计
+	expire expireState
+	// maxmemory 淘汰：键的访问信息、数据大小与统计
+	evict evictState
+	// 静态加密（Badger 主密钥）的来源与轮换记录
+	encryption encryptionState
+	// 单个值的上限（字节），见 CheckValueSize
+	maxValueSize int64
+	// 冷热分层：冷层实例、访问时间与统计
+	tier tierState
+	// 是否可能存在分段存储的大字符串，见 string_chunk.go
+	stringChunks atomic.Bool
+	// 是否可能存在过期时间记录，见 expire.go
+	expireRecords atomic.Bool
+	// 是否可能存在设置了过期时间的哈希字段，见 hash_ttl.go
+	hashFieldTTLs atomic.Bool
 
 	// Stream blocking support
 	streamBlockingMu     sync.RWMutex
-	streamBlockingChans map[string][]chan StreamReadResult // key -> channels waiting for stream data
+	streamBlockingChans map[string][]chan struct{} // key -> 等待新记录的读取者的唤醒通道
+
+	// 时间源（可在测试中替换）
+	clockMu sync.RWMutex
+	clock   Clock
+
+	// 迭代器预取参数，nil 时使用 DefaultIteratorTuning
+	iterTuning atomic.Pointer[IteratorTuning]
 }
 
 // NewBotreonStore 创建新的BotreonStore实例
@@ -67,60 +120,127 @@ func NewBotreonStore(path string) (*BotreonStore, error) {
 
 // NewBotreonStoreWithCompression 创建新的BotreonStore实例，指定压缩算法
 func NewBotreonStoreWithCompression(path string, compressionType CompressionType) (*BotreonStore, error) {
-	opts := badger.DefaultOptions(path)
-
-	// 性能优化配置
-	// 1. 增加 memtable 数量，提高写入并发性能（优化：从5增加到7，减少事务冲突）
-	opts.NumMemtables = 7             // 增加到 7，提高并发写入性能
-	opts.NumLevelZeroTables = 5       // Level 0 表数量
-	opts.NumLevelZeroTablesStall = 10 // Level 0 停滞阈值
-
-	// 2. 优化 Value Log 配置
-	opts.ValueLogFileSize = 1024 * 1024 * 1024 // 1GB（默认 1GB，适合大值）
-	opts.ValueLogMaxEntries = 1000000          // 每个 vlog 文件最大条目数
-
-	// 3. 优化 Table 配置
-	// BadgerDB v4 使用 BlockSize 而不是 MaxTableSize
-	opts.BlockSize = 4 * 1024     // 4KB 块大小（默认 4KB）
-	opts.LevelSizeMultiplier = 10 // Level 大小倍数（默认 10）
-
-	// 4. 压缩配置（BadgerDB 内置压缩）
-	// BadgerDB v4 使用 CompressionType，值为 0=无压缩, 1=Snappy, 2=ZSTD
-	opts.Compression = 2 // 使用 ZSTD 压缩（比 Snappy 更好）
-
-	// 5. 索引缓存配置
-	opts.IndexCacheSize = 100 * 1024 * 1024 // 100MB 索引缓存（默认 0，禁用）
-
-	// 6. 减少同步频率（提高性能，但降低持久性）
-	// opts.SyncWrites = false // 默认 false，异步写入提高性能
+	return NewBotreonStoreWithOptions(path, StoreOptions{Compression: compressionType})
+}
 
-	// 7. 优化垃圾回收
-	opts.NumGoroutines = 8 // GC goroutine 数量（默认 8）
+// NewBotreonStoreWithOptions 创建新的BotreonStore实例，指定压缩算法等可选配置
+func NewBotreonStoreWithOptions(path string, storeOpts StoreOptions) (*BotreonStore, error) {
+	compressionType := storeOpts.Compression
+	if compressionType == "" {
+		compressionType = CompressionLZ4
+	}
+	opts := badgerOptions(path)
+	encryptionKey, dataKeyRotation, err := applyEncryption(&opts, storeOpts.EncryptionKeySource, storeOpts.DataKeyRotation)
+	if err != nil {
+		return nil, err
+	}
 
 	db, err := badger.Open(opts)
 	if err != nil {
 		return nil, err
 	}
 
-	// 初始化缓存层
-	// 读缓存：10000 个条目，TTL 5 分钟
-	readCache := NewLRUCache(10000, 5*time.Minute)
-	// 写缓存：5000 个条目，TTL 1 分钟（用于批量写入优化）
-	writeCache := NewLRUCache(5000, 1*time.Minute)
-
-	return &BotreonStore{
+	s := &BotreonStore{storeCore: &storeCore{
 		db:              db,
 		compressionType: compressionType,
-		readCache:       readCache,
-		writeCache:      writeCache,
+		readCache:       newReadThroughCache(DefaultReadCacheSize, DefaultReadCacheTTL),
 		keyLockMgr:      NewKeyLockManager(256),
-		blockingPopChans:  make(map[string][]chan BlockingResult),
-		streamBlockingChans: make(map[string][]chan StreamReadResult),
-	}, nil
+		clock:           SystemClock,
+		blockingPopChans:  make(map[string][]chan struct{}),
+		streamBlockingChans: make(map[string][]chan struct{}),
+		blocking:            newBlockingBudget(),
+	}}
+	if storeOpts.Iterator != (IteratorTuning{}) {
+		s.SetIteratorTuning(storeOpts.Iterator)
+	}
+	if storeOpts.Blocking != (BlockingLimits{}) {
+		s.SetBlockingLimits(storeOpts.Blocking)
+	}
+	if storeOpts.TrashRetention > 0 {
+		s.SetTrashRetention(storeOpts.TrashRetention)
+	}
+	s.maxValueSize = effectiveMaxValueSize(storeOpts.MaxValueSize, opts.ValueLogFileSize)
+	s.decompressCache = newDecompressCache(effectiveDecompressCacheSize(storeOpts.DecompressCacheSize))
+	s.encryption.source = storeOpts.EncryptionKeySource
+	s.encryption.key = encryptionKey
+	s.encryption.rotation = dataKeyRotation
+	s.zwatch.watches = make(map[string]*zsetWatch)
+	if err := s.loadZWatches(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := s.loadScheduleSeq(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := s.loadDropEpoch(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := s.loadNamespaces(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := s.loadSearchIndexes(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := s.loadStringChunks(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := s.loadExpireRecords(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := s.buildExpireIndex(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := s.loadHashFieldTTLs(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := s.reclaimPendingLists(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := s.openTiering(storeOpts.Tiering); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := s.startUnlinkWorkers(); err != nil {
+		_ = s.closeTiering()
+		_ = db.Close()
+		return nil, err
+	}
+	if err := s.loadDBGenerations(); err != nil {
+		s.stopUnlinkWorkers()
+		_ = s.closeTiering()
+		_ = db.Close()
+		return nil, err
+	}
+	if err := s.recoverDBSwap(); err != nil {
+		s.stopDBReclaim()
+		s.stopUnlinkWorkers()
+		_ = s.closeTiering()
+		_ = db.Close()
+		return nil, err
+	}
+	return s, nil
 }
 
 func (s *BotreonStore) Close() error {
-	return s.db.Close()
+	// 关闭前持久化热点键列表，供下次启动预热
+	_ = s.SaveHotKeys(DefaultHotKeyLimit)
+	s.stopSearchBackfill()
+	s.stopUnlinkWorkers()
+	s.stopDBReclaim()
+	tierErr := s.closeTiering()
+	if err := s.db.Close(); err != nil {
+		return err
+	}
+	return tierErr
 }
 
 // GetDB 获取BadgerDB实例（用于复制和备份）
@@ -128,9 +248,34 @@ func (s *BotreonStore) GetDB() *badger.DB {
 	return s.db
 }
 
+// DiskSize Badger LSM 树与值日志占用的磁盘空间（字节），由 Badger 定期统计
+func (s *BotreonStore) DiskSize() (lsm, vlog int64) {
+	return s.db.Size()
+}
+
 // FlushDB 删除数据库中的所有键
 func (s *BotreonStore) FlushDB() error {
-	return s.db.DropAll()
+	if err := s.db.DropAll(); err != nil {
+		return err
+	}
+	s.touchAll()
+	s.readCache.clear()
+	s.resetDataSize()
+	if err := s.dropTier(); err != nil {
+		return err
+	}
+	// 通知配置与数据一起被清空
+	s.zwatch.mu.Lock()
+	s.zwatch.watches = make(map[string]*zsetWatch)
+	s.zwatch.mu.Unlock()
+	s.namespaces.mu.Lock()
+	s.namespaces.byName = make(map[string]Namespace)
+	s.namespaces.mu.Unlock()
+	s.resetSearchIndexes()
+	if err := s.bumpDropEpoch(); err != nil {
+		return err
+	}
+	return s.saveDBGenerations()
 }
 
 // TypeOfKeyGet 用于生成存储类型的键
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// metaDropEpochKey 直接删除 Badger 数据（DropAll/DropPrefix）的次数，值为 8 字节大端整数。
+// 这类删除不写删除标记，增量备份看不到，备份据此判断需要重新做全量备份
+var metaDropEpochKey = []byte("META:dropepoch")
+
+// DropEpoch 返回直接删除 Badger 数据（FLUSHALL、FLUSHDB、FT.DROPINDEX）的累计次数，重启后保留。
+// 两次备份之间这个值变化时，增量备份不能反映数据的变化
+func (s *BotreonStore) DropEpoch() uint64 {
+	return s.dropEpoch.Load()
+}
+
+// loadDropEpoch 启动时读取 DropEpoch
+func (s *BotreonStore) loadDropEpoch() error {
+	return s.view(func(txn *storeTxn) error {
+		item, err := txn.Get(metaDropEpochKey)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			if len(val) == 8 {
+				s.dropEpoch.Store(binary.BigEndian.Uint64(val))
+			}
+			return nil
+		})
+	})
+}
+
+// bumpDropEpoch 在 DropAll/DropPrefix 之后调用，DropAll 同时删除了之前保存的值，这里重新写入
+func (s *BotreonStore) bumpDropEpoch() error {
+	s.dropEpoch.Add(1)
+	return s.retryUpdate(func(txn *storeTxn) error {
+		// 并发的删除各自写入时都写最新的值
+		return txn.Set(metaDropEpochKey, binary.BigEndian.AppendUint64(nil, s.dropEpoch.Load()))
+	}, 30)
+}
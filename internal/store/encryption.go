@@ -0,0 +1,178 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// DefaultDataKeyRotation Badger 数据密钥的默认轮换周期（与 Badger 默认值相同）
+const DefaultDataKeyRotation = 10 * 24 * time.Hour
+
+// encryptionCommandTimeout cmd: 密钥源命令的超时时间
+const encryptionCommandTimeout = 30 * time.Second
+
+// ErrEncryptionKeyUnchanged 轮换时新旧主密钥相同
+var ErrEncryptionKeyUnchanged = errors.New("encryption key unchanged")
+
+// encryptionState 静态加密的配置
+//
+// Badger 用主密钥加密 KEYREGISTRY 中的数据密钥，数据文件由数据密钥加密，
+// 数据密钥按 DataKeyRotation 周期自动更换。轮换主密钥只需重写 KEYREGISTRY，不必重写数据，
+// 但打开的数据库持有 KEYREGISTRY 的句柄，因此在服务停止时用 RotateMasterKey 离线轮换
+type encryptionState struct {
+	mu       sync.Mutex
+	source   string // 密钥源（file:、env:、cmd:）
+	key      []byte
+	rotation time.Duration
+}
+
+// EncryptionStatus INFO 中报告的加密状态
+type EncryptionStatus struct {
+	Enabled         bool
+	SourceKind      string // file、env 或 cmd（不包含路径和命令，避免泄露）
+	DataKeyRotation time.Duration
+}
+
+// LoadEncryptionKey 从密钥源读取 AES 密钥：
+//
+//	file:<path>     读取文件
+//	env:<NAME>      读取环境变量
+//	cmd:<command>   执行命令（sh -c）并读取标准输出，用于从 KMS 取得密钥
+//
+// 内容可以是十六进制或 base64 编码的 16/24/32 字节密钥（AES-128/192/256），
+// 文件也可以直接保存原始密钥字节
+func LoadEncryptionKey(source string) ([]byte, error) {
+	kind, arg, ok := strings.Cut(source, ":")
+	if !ok || arg == "" {
+		return nil, fmt.Errorf("invalid encryption key source %q, expected file:<path>, env:<NAME> or cmd:<command>", source)
+	}
+	var raw []byte
+	switch kind {
+	case "file":
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("read encryption key: %w", err)
+		}
+		raw = data
+	case "env":
+		value, ok := os.LookupEnv(arg)
+		if !ok {
+			return nil, fmt.Errorf("read encryption key: environment variable %s is not set", arg)
+		}
+		raw = []byte(value)
+	case "cmd":
+		ctx, cancel := context.WithTimeout(context.Background(), encryptionCommandTimeout)
+		defer cancel()
+		// #nosec G204 - 命令来自启动参数，不来自客户端
+		cmd := exec.CommandContext(ctx, "sh", "-c", arg)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("run encryption key command: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		raw = out
+	default:
+		return nil, fmt.Errorf("unknown encryption key source %q", kind)
+	}
+	return decodeEncryptionKey(raw)
+}
+
+// decodeEncryptionKey 依次按十六进制、base64、原始字节解析密钥
+func decodeEncryptionKey(raw []byte) ([]byte, error) {
+	text := strings.TrimSpace(string(raw))
+	if key, err := hex.DecodeString(text); err == nil && validKeyLength(len(key)) {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && validKeyLength(len(key)) {
+		return key, nil
+	}
+	if validKeyLength(len(raw)) {
+		return raw, nil
+	}
+	return nil, errors.New("encryption key must be 16, 24 or 32 bytes (raw, hex or base64)")
+}
+
+func validKeyLength(n int) bool {
+	return n == 16 || n == 24 || n == 32
+}
+
+// applyEncryption 从密钥源加载主密钥并设置 Badger 的加密选项，source 为空时不加密
+func applyEncryption(opts *badger.Options, source string, rotation time.Duration) ([]byte, time.Duration, error) {
+	if source == "" {
+		return nil, 0, nil
+	}
+	key, err := LoadEncryptionKey(source)
+	if err != nil {
+		return nil, 0, err
+	}
+	if rotation <= 0 {
+		rotation = DefaultDataKeyRotation
+	}
+	opts.EncryptionKey = key
+	opts.EncryptionKeyRotationDuration = rotation
+	return key, rotation, nil
+}
+
+// EncryptionStatus 返回静态加密的状态
+func (s *BotreonStore) EncryptionStatus() EncryptionStatus {
+	st := &s.encryption
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.key == nil {
+		return EncryptionStatus{}
+	}
+	kind, _, _ := strings.Cut(st.source, ":")
+	return EncryptionStatus{
+		Enabled:         true,
+		SourceKind:      kind,
+		DataKeyRotation: st.rotation,
+	}
+}
+
+// RotateMasterKey 离线更换 dir 下 Badger 数据库的主密钥：用旧密钥读出 KEYREGISTRY 中的数据密钥，
+// 再用新密钥加密写回，数据文件不变（与 badger rotate 相同）。
+// 必须在服务停止后执行：先以只读方式打开数据库，确认旧密钥正确、目录没有被运行中的服务占用，
+// 并在重写期间持有目录锁，服务此时无法启动
+func RotateMasterKey(dir string, oldKey, newKey []byte) error {
+	if bytes.Equal(oldKey, newKey) {
+		return ErrEncryptionKeyUnchanged
+	}
+	opts := badgerOptions(dir)
+	opts.ReadOnly = true
+	opts.EncryptionKey = oldKey
+	opts.EncryptionKeyRotationDuration = DefaultDataKeyRotation
+	opts.Logger = nil
+	db, err := badger.Open(opts)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", dir, err)
+	}
+	defer db.Close()
+
+	regOpts := badger.KeyRegistryOptions{
+		Dir:                           dir,
+		ReadOnly:                      true,
+		EncryptionKey:                 oldKey,
+		EncryptionKeyRotationDuration: DefaultDataKeyRotation,
+	}
+	kr, err := badger.OpenKeyRegistry(regOpts)
+	if err != nil {
+		return fmt.Errorf("read key registry: %w", err)
+	}
+	regOpts.EncryptionKey = newKey
+	if err := badger.WriteKeyRegistry(kr, regOpts); err != nil {
+		return fmt.Errorf("rewrite key registry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestLoadEncryptionKey(t *testing.T) {
+	dir := t.TempDir()
+	key := bytes.Repeat([]byte{0xab}, 32)
+
+	hexFile := filepath.Join(dir, "hex.key")
+	assert.NoError(t, os.WriteFile(hexFile, []byte(hex.EncodeToString(key)+"\n"), 0o600))
+	got, err := LoadEncryptionKey("file:" + hexFile)
+	assert.NoError(t, err)
+	assert.Equal(t, key, got)
+
+	rawFile := filepath.Join(dir, "raw.key")
+	assert.NoError(t, os.WriteFile(rawFile, key[:16], 0o600))
+	got, err = LoadEncryptionKey("file:" + rawFile)
+	assert.NoError(t, err)
+	assert.Equal(t, key[:16], got)
+
+	t.Setenv("BOLTREON_TEST_KEY", base64.StdEncoding.EncodeToString(key[:24]))
+	got, err = LoadEncryptionKey("env:BOLTREON_TEST_KEY")
+	assert.NoError(t, err)
+	assert.Equal(t, key[:24], got)
+
+	got, err = LoadEncryptionKey("cmd:echo " + hex.EncodeToString(key))
+	assert.NoError(t, err)
+	assert.Equal(t, key, got)
+
+	_, err = LoadEncryptionKey("cmd:exit 3")
+	assert.Error(t, err)
+	_, err = LoadEncryptionKey("env:BOLTREON_TEST_MISSING")
+	assert.Error(t, err)
+	_, err = LoadEncryptionKey("kms:alias")
+	assert.Error(t, err)
+	_, err = LoadEncryptionKey(hexFile)
+	assert.Error(t, err)
+	assert.NoError(t, os.WriteFile(rawFile, []byte("short"), 0o600))
+	_, err = LoadEncryptionKey("file:" + rawFile)
+	assert.Error(t, err)
+}
+
+func TestEncryptionAtRest(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(t.TempDir(), "master.key")
+	key1 := bytes.Repeat([]byte{1}, 32)
+	key2 := bytes.Repeat([]byte{2}, 32)
+	assert.NoError(t, os.WriteFile(keyFile, []byte(hex.EncodeToString(key1)), 0o600))
+
+	open := func() (*BotreonStore, error) {
+		// 数据密钥每秒更换，轮换主密钥后生成的数据密钥也要能用新密钥读出
+		return NewBotreonStoreWithOptions(dir, StoreOptions{EncryptionKeySource: "file:" + keyFile, DataKeyRotation: time.Second})
+	}
+	store, err := open()
+	assert.NoError(t, err)
+	status := store.EncryptionStatus()
+	assert.True(t, status.Enabled)
+	assert.Equal(t, "file", status.SourceKind)
+	assert.Equal(t, time.Second, status.DataKeyRotation)
+
+	assert.NoError(t, store.Set("card", "plaintext-secret-4111"))
+	// 服务运行时目录被占用，不能轮换
+	assert.Error(t, RotateMasterKey(dir, key1, key2))
+	assert.NoError(t, store.Close())
+
+	// 离线轮换主密钥
+	assert.Equal(t, ErrEncryptionKeyUnchanged, RotateMasterKey(dir, key1, key1))
+	assert.Error(t, RotateMasterKey(dir, key2, key1))
+	assert.NoError(t, RotateMasterKey(dir, key1, key2))
+
+	// 旧密钥无法打开
+	_, err = open()
+	assert.Error(t, err)
+
+	assert.NoError(t, os.WriteFile(keyFile, []byte(hex.EncodeToString(key2)), 0o600))
+	store, err = open()
+	assert.NoError(t, err)
+	time.Sleep(1100 * time.Millisecond)
+	assert.NoError(t, store.Set("after", "written-after-rotation"))
+	assert.NoError(t, store.GetDB().Flatten(1))
+	assert.NoError(t, store.Close())
+
+	// 磁盘上没有明文
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		assert.NoError(t, err)
+		assert.False(t, bytes.Contains(data, []byte("plaintext-secret-4111")))
+		assert.False(t, bytes.Contains(data, []byte("written-after-rotation")))
+	}
+
+	store, err = open()
+	assert.NoError(t, err)
+	defer store.Close()
+	value, err := store.Get("card")
+	assert.NoError(t, err)
+	assert.Equal(t, "plaintext-secret-4111", value)
+	value, err = store.Get("after")
+	assert.NoError(t, err)
+	assert.Equal(t, "written-after-rotation", value)
+}
+
+func TestEncryptionDisabled(t *testing.T) {
+	store, err := NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.False(t, store.EncryptionStatus().Enabled)
+}
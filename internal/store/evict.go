@@ -0,0 +1,360 @@
+package store
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// maxmemory 淘汰：数据大小超过上限时按策略删除键。
+// 数据大小以开启淘汰时 Badger 的磁盘占用（LSM + value log）为基数，之后每个写事务提交时
+// 加上写入的键值大小、减去被覆盖或删除的旧版本大小，因此不必等 Badger 每分钟一次的重新统计。
+// 每个键的最近访问时间与 LFU 计数器保存在内存中，由命令执行后的 TouchKeys 维护；
+// 没有记录的键按开始记录时访问过一次处理
+
+// maxmemory-policy 的取值，与 Redis 相同
+const (
+	EvictNoEviction     = "noeviction"
+	EvictAllKeysLRU     = "allkeys-lru"
+	EvictAllKeysLFU     = "allkeys-lfu"
+	EvictAllKeysRandom  = "allkeys-random"
+	EvictVolatileLRU    = "volatile-lru"
+	EvictVolatileLFU    = "volatile-lfu"
+	EvictVolatileRandom = "volatile-random"
+	EvictVolatileTTL    = "volatile-ttl"
+)
+
+const (
+	// evictSampleKeys 每次挑选淘汰对象时从游标处读取的候选键数
+	evictSampleKeys = 16
+	// evictPoolSize 淘汰池保留的最佳候选数，跨多次挑选累积，与 Redis 的 EVPOOL_SIZE 相同
+	evictPoolSize = 16
+	// evictMaxScan 一次挑选最多检查的键数，volatile 策略下没有带过期时间的键时不必扫描整个键空间
+	evictMaxScan = 1024
+	// evictMaxKeys 一次 Evict 最多淘汰的键数，剩余的留给下一个写命令
+	evictMaxKeys = 512
+	// evictMaxTracked 记录访问信息的键数上限，超出后新键不再记录
+	evictMaxTracked = 1 << 20
+
+	// lfuInitVal 新键的 LFU 计数器初值，避免刚写入的键立即被淘汰
+	lfuInitVal = 5
+	// lfuLogFactor 计数器按对数增长的因子，100 万次访问约使计数器饱和
+	lfuLogFactor = 10
+	// lfuDecayTime 每经过这段时间没有访问，计数器减 1
+	lfuDecayTime = time.Minute
+)
+
+// keyAccess 键的访问信息
+type keyAccess struct {
+	at      int64 // 最近访问时间（Unix 毫秒）
+	freq    uint8 // LFU 对数计数器
+	decayAt int64 // 计数器最近一次衰减的时间（Unix 毫秒）
+}
+
+// decayed 按距上次衰减经过的时间减小后的计数器
+func (a *keyAccess) decayed(now time.Time) uint8 {
+	periods := (now.UnixMilli() - a.decayAt) / lfuDecayTime.Milliseconds()
+	if periods <= 0 {
+		return a.freq
+	}
+	if periods >= int64(a.freq) {
+		return 0
+	}
+	return a.freq - uint8(periods) // #nosec G115 - periods 小于 freq
+}
+
+// touch 记录一次访问：先衰减，再以 1/((counter-lfuInitVal)*lfuLogFactor+1) 的概率递增
+func (a *keyAccess) touch(now time.Time) {
+	if freq := a.decayed(now); freq != a.freq {
+		a.freq, a.decayAt = freq, now.UnixMilli()
+	}
+	a.at = now.UnixMilli()
+	if a.freq == 255 {
+		return
+	}
+	base := float64(a.freq) - lfuInitVal
+	if base < 0 {
+		base = 0
+	}
+	if randomFloat64() < 1/(base*lfuLogFactor+1) {
+		a.freq++
+	}
+}
+
+// evictCandidate 淘汰池中的候选键，score 越大越先淘汰
+type evictCandidate struct {
+	key   string
+	score float64
+}
+
+// evictState 访问信息、淘汰游标与统计
+type evictState struct {
+	mu      sync.Mutex
+	access  map[string]*keyAccess
+	since   int64 // 开始记录访问信息的时间（Unix 毫秒）
+	cycle   sync.Mutex
+	cursor  []byte
+	policy  string           // 淘汰池中候选键的分数所用的策略
+	pool    []evictCandidate // 按 score 升序
+	evicted atomic.Int64
+
+	sizing   atomic.Bool
+	baseSize atomic.Int64 // 开启淘汰时 Badger 的磁盘占用
+	delta    atomic.Int64 // 之后提交的写事务带来的大小变化
+}
+
+// accessLocked 键的访问信息，没有记录时返回 nil。调用者持有 e.mu
+func (e *evictState) accessLocked(key string, now time.Time) *keyAccess {
+	if e.access == nil {
+		e.access = make(map[string]*keyAccess)
+		e.since = now.UnixMilli()
+	}
+	return e.access[key]
+}
+
+// TouchKeys 记录键被命令访问，更新最近访问时间与 LFU 计数器
+func (s *BotreonStore) TouchKeys(keys ...string) {
+	now := s.now()
+	e := &s.evict
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, key := range keys {
+		a := e.accessLocked(key, now)
+		if a == nil {
+			if len(e.access) >= evictMaxTracked {
+				continue
+			}
+			a = &keyAccess{freq: lfuInitVal, decayAt: now.UnixMilli()}
+			e.access[key] = a
+		}
+		a.touch(now)
+	}
+}
+
+// keyAccessInfo 键的最近访问时间与衰减后的 LFU 计数器
+func (s *BotreonStore) keyAccessInfo(key string, now time.Time) (at int64, freq uint8) {
+	e := &s.evict
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if a := e.accessLocked(key, now); a != nil {
+		return a.at, a.decayed(now)
+	}
+	return e.since, lfuInitVal
+}
+
+// keyExists 键是否存在
+func (s *BotreonStore) keyExists(key string) (bool, error) {
+	err := s.view(func(txn *storeTxn) error {
+		_, err := txn.Get(TypeOfKeyGet(key))
+		return err
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ObjectIdleTime 实现 OBJECT IDLETIME：距最近一次访问的秒数，键不存在时返回 ErrKeyNotFound
+func (s *BotreonStore) ObjectIdleTime(key string) (int64, error) {
+	exists, err := s.keyExists(key)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, ErrKeyNotFound
+	}
+	now := s.now()
+	at, _ := s.keyAccessInfo(key, now)
+	return max(now.UnixMilli()-at, 0) / 1000, nil
+}
+
+// ObjectFreq 实现 OBJECT FREQ：键的 LFU 计数器，键不存在时返回 ErrKeyNotFound
+func (s *BotreonStore) ObjectFreq(key string) (int64, error) {
+	exists, err := s.keyExists(key)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, ErrKeyNotFound
+	}
+	_, freq := s.keyAccessInfo(key, s.now())
+	return int64(freq), nil
+}
+
+// SetEvictionTracking 开启或关闭数据大小的跟踪。开启时以当前 Badger 的磁盘占用为基数
+func (s *BotreonStore) SetEvictionTracking(on bool) {
+	e := &s.evict
+	if on == e.sizing.Load() {
+		return
+	}
+	if on {
+		lsm, vlog := s.db.Size()
+		e.baseSize.Store(lsm + vlog)
+		e.delta.Store(0)
+	}
+	e.sizing.Store(on)
+}
+
+// resetDataSize FLUSHDB 等不经过事务清空数据后重新取基数
+func (s *BotreonStore) resetDataSize() {
+	e := &s.evict
+	if e.sizing.Load() {
+		lsm, vlog := s.db.Size()
+		e.baseSize.Store(lsm + vlog)
+		e.delta.Store(0)
+	}
+	e.mu.Lock()
+	e.access = nil
+	e.mu.Unlock()
+}
+
+// DataSize 近似的数据大小（字节）。没有开启跟踪时为 Badger 最近一次统计的磁盘占用
+func (s *BotreonStore) DataSize() int64 {
+	e := &s.evict
+	if !e.sizing.Load() {
+		lsm, vlog := s.db.Size()
+		return lsm + vlog
+	}
+	return max(e.baseSize.Load()+e.delta.Load(), 0)
+}
+
+// EvictedKeys 因 maxmemory 淘汰的键数（累计）
+func (s *BotreonStore) EvictedKeys() int64 {
+	return s.evict.evicted.Load()
+}
+
+// Evict 按 policy 淘汰键，直到数据大小不超过 limit、没有可淘汰的键或本次已淘汰 evictMaxKeys 个键，
+// 返回淘汰的键。noeviction 与未知的策略不淘汰任何键
+func (s *BotreonStore) Evict(policy string, limit int64) ([]string, error) {
+	e := &s.evict
+	e.cycle.Lock()
+	defer e.cycle.Unlock()
+	var evicted []string
+	for len(evicted) < evictMaxKeys && s.DataSize() > limit {
+		key, ok, err := s.nextEvictCandidate(policy)
+		if err != nil || !ok {
+			return evicted, err
+		}
+		deleted := false
+		err = s.update(func(txn *storeTxn) error {
+			var err error
+			deleted, err = s.delTxn(txn, key)
+			return err
+		})
+		if errors.Is(err, badger.ErrTxnTooBig) {
+			// 超过单个事务大小上限的键分批回收
+			var n int64
+			n, err = s.unlinkKey(key, false)
+			deleted = n == 1
+		}
+		if errors.Is(err, badger.ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return evicted, err
+		}
+		if deleted {
+			s.notifyZWatch(key, nil, nil, true)
+			evicted = append(evicted, key)
+			e.evicted.Add(1)
+		}
+		e.mu.Lock()
+		delete(e.access, key)
+		e.mu.Unlock()
+	}
+	return evicted, nil
+}
+
+// nextEvictCandidate 从游标处继续读取候选键放入淘汰池，返回池中最应淘汰的键。
+// 检查 evictMaxScan 个键或整个键空间后仍没有候选键时返回 false
+func (s *BotreonStore) nextEvictCandidate(policy string) (string, bool, error) {
+	e := &s.evict
+	if policy != e.policy {
+		e.policy, e.pool = policy, nil
+	}
+	now := s.now()
+	for scanned, wraps := 0, 0; ; {
+		keys, err := s.analyzeNextKeys(e.cursor, evictSampleKeys)
+		if err != nil {
+			return "", false, err
+		}
+		if len(keys) < evictSampleKeys {
+			e.cursor = nil
+			wraps++
+		} else {
+			e.cursor = TypeOfKeyGet(keys[len(keys)-1])
+		}
+		for _, key := range keys {
+			score, ok, err := s.evictScore(policy, key, now)
+			if err != nil {
+				return "", false, err
+			}
+			if ok {
+				e.addCandidate(key, score)
+			}
+		}
+		if n := len(e.pool); n > 0 {
+			best := e.pool[n-1]
+			e.pool = e.pool[:n-1]
+			return best.key, true, nil
+		}
+		scanned += len(keys)
+		if scanned >= evictMaxScan || wraps >= 2 {
+			return "", false, nil
+		}
+	}
+}
+
+// addCandidate 把候选键按 score 插入淘汰池，池满时替换 score 最小的候选
+func (e *evictState) addCandidate(key string, score float64) {
+	for i, c := range e.pool {
+		if c.key == key {
+			e.pool = append(e.pool[:i], e.pool[i+1:]...)
+			break
+		}
+	}
+	if len(e.pool) >= evictPoolSize {
+		if score <= e.pool[0].score {
+			return
+		}
+		e.pool = e.pool[1:]
+	}
+	i := sort.Search(len(e.pool), func(i int) bool { return e.pool[i].score > score })
+	e.pool = append(e.pool, evictCandidate{})
+	copy(e.pool[i+1:], e.pool[i:])
+	e.pool[i] = evictCandidate{key: key, score: score}
+}
+
+// evictScore 键在 policy 下的淘汰分数，越大越先淘汰：LRU 为空闲毫秒数，LFU 为计数器的反数，
+// volatile-ttl 为过期时间的反数，random 为随机数。键不存在、volatile 策略下键没有过期时间时返回 false
+func (s *BotreonStore) evictScore(policy, key string, now time.Time) (float64, bool, error) {
+	volatile := policy == EvictVolatileLRU || policy == EvictVolatileLFU ||
+		policy == EvictVolatileRandom || policy == EvictVolatileTTL
+	var expiresAt time.Time
+	if volatile {
+		var exists bool
+		var err error
+		expiresAt, exists, err = s.keyExpiresAt(key)
+		if err != nil || !exists || expiresAt.IsZero() {
+			return 0, false, err
+		}
+	}
+	switch policy {
+	case EvictAllKeysLRU, EvictVolatileLRU:
+		at, _ := s.keyAccessInfo(key, now)
+		return float64(now.UnixMilli() - at), true, nil
+	case EvictAllKeysLFU, EvictVolatileLFU:
+		_, freq := s.keyAccessInfo(key, now)
+		return float64(255 - int(freq)), true, nil
+	case EvictAllKeysRandom, EvictVolatileRandom:
+		return randomFloat64(), true, nil
+	case EvictVolatileTTL:
+		return -float64(expiresAt.UnixMilli()), true, nil
+	default:
+		return 0, false, nil
+	}
+}
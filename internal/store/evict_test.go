@@ -0,0 +1,122 @@
+package store
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestDataSizeTracking(t *testing.T) {
+	store := setupStringTest(t)
+	defer store.Close()
+
+	store.SetEvictionTracking(true)
+	base := store.DataSize()
+	value := strings.Repeat("v", 100)
+	assert.NoError(t, store.Set("k", value))
+	size := store.DataSize()
+	assert.True(t, size > base)
+
+	// 覆盖写入只计算差值，删除后回到原来的大小
+	assert.NoError(t, store.Set("k", value))
+	assert.Equal(t, size, store.DataSize())
+	_, err := store.Del("k")
+	assert.NoError(t, err)
+	assert.Equal(t, base, store.DataSize())
+
+	// 集合类型的删除按迭代读到的条目大小减少
+	assert.NoError(t, store.HSet("h", "f1", value))
+	assert.NoError(t, store.HSet("h", "f2", value))
+	size = store.DataSize()
+	assert.NoError(t, store.HSet("h", "f1", value))
+	assert.Equal(t, size, store.DataSize())
+	_, err = store.RPush("l", "a", "b", "c")
+	assert.NoError(t, err)
+	_, err = store.Del("h")
+	assert.NoError(t, err)
+	_, err = store.Del("l")
+	assert.NoError(t, err)
+	assert.Equal(t, base, store.DataSize())
+}
+
+func TestEvictLRU(t *testing.T) {
+	store := setupStringTest(t)
+	defer store.Close()
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	store.SetClock(clock)
+	store.SetEvictionTracking(true)
+
+	value := strings.Repeat("v", 100)
+	for i := 0; i < 10; i++ {
+		key := "k" + strconv.Itoa(i)
+		assert.NoError(t, store.Set(key, value))
+		store.TouchKeys(key)
+		clock.Advance(time.Second)
+	}
+	// k0 最近被访问，k1 成为最久未访问的键
+	store.TouchKeys("k0")
+	idle, err := store.ObjectIdleTime("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), idle)
+
+	evicted, err := store.Evict(EvictAllKeysLRU, store.DataSize()-1)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"k1"}, evicted)
+	evicted, err = store.Evict(EvictAllKeysLRU, store.DataSize()-1)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"k2"}, evicted)
+	assert.Equal(t, int64(2), store.EvictedKeys())
+
+	// noeviction 不淘汰任何键
+	evicted, err = store.Evict(EvictNoEviction, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(evicted))
+}
+
+func TestEvictLFU(t *testing.T) {
+	store := setupStringTest(t)
+	defer store.Close()
+	store.SetEvictionTracking(true)
+
+	assert.NoError(t, store.Set("hot", "v"))
+	assert.NoError(t, store.Set("cold", "v"))
+	for i := 0; i < 1000; i++ {
+		store.TouchKeys("hot")
+	}
+	store.TouchKeys("cold")
+	hot, err := store.ObjectFreq("hot")
+	assert.NoError(t, err)
+	cold, err := store.ObjectFreq("cold")
+	assert.NoError(t, err)
+	assert.True(t, hot > cold)
+	_, err = store.ObjectFreq("missing")
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	evicted, err := store.Evict(EvictAllKeysLFU, store.DataSize()-1)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"cold"}, evicted)
+}
+
+func TestEvictVolatileTTL(t *testing.T) {
+	store := setupStringTest(t)
+	defer store.Close()
+	store.SetEvictionTracking(true)
+
+	assert.NoError(t, store.Set("persistent", "v"))
+	assert.NoError(t, store.SetWithTTL("later", "v", time.Hour))
+	assert.NoError(t, store.SetWithTTL("soon", "v", time.Minute))
+
+	// 先淘汰最早过期的键，没有过期时间的键不会被淘汰
+	evicted, err := store.Evict(EvictVolatileTTL, store.DataSize()-1)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"soon"}, evicted)
+	evicted, err = store.Evict(EvictVolatileTTL, 0)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"later"}, evicted)
+	exists, err := store.Exists("persistent")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
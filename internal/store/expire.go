@@ -0,0 +1,655 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/lbp0200/BoltDB/internal/helper"
+)
+
+const (
+	// expireStaleSmoothing 每轮周期的过期比例在 StalePerc 中的权重，与 Redis 的 expired_stale_perc 相同
+	expireStaleSmoothing = 0.05
+	// expireMaxSeconds 统计同一秒过期的键数时记录的不同秒数上限，超出后的新秒数不再统计
+	expireMaxSeconds = 1 << 16
+	// expireDeleteBatch 主动过期时一个事务最多删除的键数
+	expireDeleteBatch = 64
+)
+
+// ExpireStats 主动过期的统计。TTL 分布来自最近一次完成的过期索引的完整扫描，Passes 为 0 时为空
+type ExpireStats struct {
+	ExpiredKeys   int64   // 主动过期删除的键数（累计）
+	ExpiredFields int64   // 过期删除的哈希字段数（累计，包括惰性过期）
+	StalePerc     float64 // 每轮检查的键中已过期但尚未删除的比例（百分比），按轮平滑
+	Passes        int64   // 已完成的完整扫描轮数
+
+	Keys          int64         // 最近一次完整扫描中带过期时间且尚未过期的键数
+	TTL           []int64       // 带过期时间的键按剩余时间分布，与 AnalyzeTTLBuckets 一一对应
+	AvgTTL        time.Duration // 带过期时间的键的平均剩余时间
+	MaxSameSecond int64         // 在同一秒过期的键数的最大值，远大于平均值时说明 TTL 集中在同一时刻
+}
+
+// expireState 主动过期周期的游标和统计。cursor 为过期索引中下次开始读取的位置，为 nil 时从头开始；
+// statsCursor 为 TTL 分布的扫描在过期索引中下次开始读取的位置，为 nil 时开始新的一轮
+type expireState struct {
+	mu            sync.Mutex
+	cursor        []byte
+	statsCursor   []byte
+	expired       int64
+	expiredFields int64
+	stalePerc     float64
+	passes        int64
+	pass          *ttlDistribution
+	last          ttlDistribution
+}
+
+// ttlDistribution 一轮扫描中累计的 TTL 分布
+type ttlDistribution struct {
+	keys    int64
+	ttl     []int64
+	ttlSum  time.Duration
+	seconds map[int64]int64
+}
+
+func newTTLDistribution() *ttlDistribution {
+	return &ttlDistribution{ttl: make([]int64, len(AnalyzeTTLBuckets)), seconds: make(map[int64]int64)}
+}
+
+// add 记录一个键的过期时间
+func (d *ttlDistribution) add(expiresAt, now time.Time) {
+	d.keys++
+	ttl := expiresAt.Sub(now)
+	d.ttlSum += ttl
+	for i, b := range AnalyzeTTLBuckets {
+		if b.Max == 0 || ttl <= b.Max {
+			d.ttl[i]++
+			break
+		}
+	}
+	sec := expiresAt.Unix()
+	if _, ok := d.seconds[sec]; ok || len(d.seconds) < expireMaxSeconds {
+		d.seconds[sec]++
+	}
+}
+
+// ExpireCycle 主动过期：从过期索引（见 metaKeyExpirePrefix）中上次的位置继续读取至多 limit 个
+// 过期时间不晚于现在的条目，分批删除仍然过期的键并返回这些键；键已被删除、覆盖或修改了过期时间的条目直接删除。
+// 读完到期的条目后下次从索引头部开始。删除失败的键留在索引中，游标越过它继续，下次从头读取时重试；
+// 超过单个事务大小上限的键交给 UNLINK 的后台回收。
+// 同时从上次的位置继续读取过期索引的 limit 个条目累计 TTL 分布（见 expireIndexStats），读到索引末尾时一轮结束
+func (s *BotreonStore) ExpireCycle(limit int) ([]string, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+	e := &s.expire
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.pass == nil {
+		e.pass = newTTLDistribution()
+	}
+
+	now := s.now()
+	entries, next, err := s.dueExpireEntries(e.cursor, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	e.cursor = next
+	expired, err := s.expireIndexed(entries, now)
+	e.expired += int64(len(expired))
+
+	live, statsNext, statsErr := s.expireIndexStats(e.statsCursor, now, limit, e.pass)
+	if statsErr != nil {
+		return expired, errors.Join(err, statsErr)
+	}
+	if checked := live + len(expired); checked > 0 {
+		perc := float64(len(expired)) * 100 / float64(checked)
+		e.stalePerc = perc*expireStaleSmoothing + e.stalePerc*(1-expireStaleSmoothing)
+	}
+	e.statsCursor = statsNext
+	if statsNext == nil {
+		e.last = *e.pass
+		e.passes++
+		e.pass = newTTLDistribution()
+	}
+	return expired, err
+}
+
+// expireEntry 过期索引中的一个条目
+type expireEntry struct {
+	index []byte // 条目的 Badger 键
+	key   string
+}
+
+// dueExpireEntries 从 after（为 nil 时从头）开始读取至多 limit 个过期时间不晚于 now 的索引条目，
+// 还有到期的条目时 next 为下次开始读取的位置，否则为 nil
+func (s *BotreonStore) dueExpireEntries(after []byte, now time.Time, limit int) (entries []expireEntry, next []byte, err error) {
+	prefix := []byte(metaKeyExpirePrefix)
+	// #nosec G115 - 当前时间的 Unix 纳秒为正数
+	due := uint64(now.UnixNano())
+	err = s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		seek := prefix
+		if after != nil {
+			seek = after
+		}
+		for it.Seek(seek); it.Valid(); it.Next() {
+			k := it.Item().Key()
+			at, key, ok := parseExpireIndexKey(k)
+			if !ok {
+				continue
+			}
+			if at > due {
+				return nil
+			}
+			if len(entries) == limit {
+				next = it.Item().KeyCopy(nil)
+				return nil
+			}
+			entries = append(entries, expireEntry{index: it.Item().KeyCopy(nil), key: key})
+		}
+		return nil
+	})
+	return entries, next, err
+}
+
+// expireIndexStats 从 after（为 nil 时从头）开始读取过期索引的至多 limit 个条目，把其中对应的键存在、
+// 过期时间与条目相同且尚未过期的条目记入 d。返回记入的条目数，以及下次开始读取的位置，读到索引末尾时为 nil
+func (s *BotreonStore) expireIndexStats(after []byte, now time.Time, limit int, d *ttlDistribution) (live int, next []byte, err error) {
+	read := 0
+	prefix := []byte(metaKeyExpirePrefix)
+	err = s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		seek := prefix
+		if after != nil {
+			seek = after
+		}
+		for it.Seek(seek); it.Valid(); it.Next() {
+			if read == limit {
+				next = it.Item().KeyCopy(nil)
+				return nil
+			}
+			read++
+			at, key, ok := parseExpireIndexKey(it.Item().Key())
+			if !ok {
+				continue
+			}
+			keyType, err := keyTypeTxn(txn, key)
+			if err != nil {
+				return err
+			}
+			if keyType == "" {
+				continue
+			}
+			exp, err := s.expiresAtTxn(txn, key, keyType)
+			if err != nil {
+				return err
+			}
+			if exp == 0 {
+				continue
+			}
+			expiresAt := expiresAtTime(exp)
+			// #nosec G115 - 过期时间为正的 Unix 纳秒时间戳
+			if uint64(expiresAt.UnixNano()) != at || !expiresAt.After(now) {
+				continue
+			}
+			d.add(expiresAt, now)
+			live++
+		}
+		return nil
+	})
+	return live, next, err
+}
+
+// expireIndexed 每 expireDeleteBatch 个条目一个事务删除仍然过期的键与条目，返回删除的键。
+// 事务冲突、过大或出错时这一批逐个处理，一个键不影响同批的其他键；单个键的错误在处理完其余键后返回
+func (s *BotreonStore) expireIndexed(entries []expireEntry, now time.Time) ([]string, error) {
+	var expired []string
+	var errs []error
+	for start := 0; start < len(entries); start += expireDeleteBatch {
+		batch := entries[start:min(start+expireDeleteBatch, len(entries))]
+		var deleted []string
+		err := s.update(func(txn *storeTxn) error {
+			deleted = deleted[:0]
+			for _, entry := range batch {
+				ok, err := s.expireEntryTxn(txn, entry, now)
+				if err != nil {
+					return err
+				}
+				if ok {
+					deleted = append(deleted, entry.key)
+				}
+			}
+			return nil
+		})
+		if err == nil {
+			for _, key := range deleted {
+				s.notifyZWatch(key, nil, nil, true)
+			}
+			expired = append(expired, deleted...)
+			continue
+		}
+		for _, entry := range batch {
+			ok, err := s.expireEntry(entry, now)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("expire %q: %w", entry.key, err))
+				continue
+			}
+			if ok {
+				expired = append(expired, entry.key)
+			}
+		}
+	}
+	return expired, errors.Join(errs...)
+}
+
+// expireEntry 在一个事务中处理一个索引条目，见 expireEntryTxn。键的数据超过单个事务的大小上限时，
+// 确认仍已过期后交给 UNLINK 的后台回收；与并发写入冲突时保留条目，下次重试
+func (s *BotreonStore) expireEntry(entry expireEntry, now time.Time) (bool, error) {
+	deleted := false
+	err := s.update(func(txn *storeTxn) error {
+		var err error
+		deleted, err = s.expireEntryTxn(txn, entry, now)
+		return err
+	})
+	if errors.Is(err, badger.ErrTxnTooBig) {
+		deleted, err := s.unlinkExpired(entry.key, now)
+		if err != nil {
+			return false, err
+		}
+		return deleted, s.update(func(txn *storeTxn) error {
+			return txn.Delete(entry.index)
+		})
+	}
+	if errors.Is(err, badger.ErrConflict) {
+		return false, nil
+	}
+	if err == nil && deleted {
+		s.notifyZWatch(entry.key, nil, nil, true)
+	}
+	return deleted, err
+}
+
+// expireEntryTxn 在 txn 中删除索引条目，键仍已过期时一起删除
+func (s *BotreonStore) expireEntryTxn(txn *storeTxn, entry expireEntry, now time.Time) (bool, error) {
+	deleted, err := s.expireKeyTxn(txn, entry.key, now)
+	if err != nil {
+		return false, err
+	}
+	return deleted, txn.Delete(entry.index)
+}
+
+// ExpireStats 返回主动过期的统计
+func (s *BotreonStore) ExpireStats() ExpireStats {
+	e := &s.expire
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	stats := ExpireStats{
+		ExpiredKeys:   e.expired,
+		ExpiredFields: e.expiredFields,
+		StalePerc:     e.stalePerc,
+		Passes:        e.passes,
+		Keys:          e.last.keys,
+		TTL:           append([]int64(nil), e.last.ttl...),
+	}
+	if e.last.keys > 0 {
+		stats.AvgTTL = e.last.ttlSum / time.Duration(e.last.keys)
+	}
+	for _, n := range e.last.seconds {
+		stats.MaxSameSecond = max(stats.MaxSameSecond, n)
+	}
+	return stats
+}
+
+// keyExpiresAt 返回键的过期时间（没有时为零值）。包括 Badger 已经隐藏的值，
+// 因此已过期但仍留有类型键的键也能被发现
+func (s *BotreonStore) keyExpiresAt(key string) (expiresAt time.Time, exists bool, err error) {
+	err = s.view(func(txn *storeTxn) error {
+		keyType, err := keyTypeTxn(txn, key)
+		if err != nil || keyType == "" {
+			return err
+		}
+		exists = true
+		exp, err := s.expiresAtTxn(txn, key, keyType)
+		if exp > 0 {
+			expiresAt = expiresAtTime(exp)
+		}
+		return err
+	})
+	return expiresAt, exists, err
+}
+
+// expireKeys 惰性过期：每 expireDeleteBatch 个键一个事务删除已过期的键，返回实际删除的键。
+// 事务冲突或过大时这一批逐个删除，一个键被并发写入不影响同批的其他键
+func (s *BotreonStore) expireKeys(keys []string, now time.Time) ([]string, error) {
+	var expired []string
+	for start := 0; start < len(keys); start += expireDeleteBatch {
+		batch := keys[start:min(start+expireDeleteBatch, len(keys))]
+		var deleted []string
+		err := s.update(func(txn *storeTxn) error {
+			deleted = deleted[:0]
+			for _, key := range batch {
+				ok, err := s.expireKeyTxn(txn, key, now)
+				if err != nil {
+					return err
+				}
+				if ok {
+					deleted = append(deleted, key)
+				}
+			}
+			return nil
+		})
+		if errors.Is(err, badger.ErrConflict) || errors.Is(err, badger.ErrTxnTooBig) {
+			deleted = deleted[:0]
+			for _, key := range batch {
+				ok, err := s.expireKey(key, now)
+				if err != nil && !errors.Is(err, badger.ErrConflict) {
+					return expired, err
+				}
+				if ok {
+					deleted = append(deleted, key)
+				}
+			}
+		} else if err != nil {
+			return expired, err
+		} else {
+			for _, key := range deleted {
+				s.notifyZWatch(key, nil, nil, true)
+			}
+		}
+		expired = append(expired, deleted...)
+	}
+	return expired, nil
+}
+
+// expireKey 在同一事务中确认键仍已过期后删除，避免删除刚被重新写入的键。
+// 键的数据超过单个事务的大小上限时交给 UNLINK 的后台回收
+func (s *BotreonStore) expireKey(key string, now time.Time) (bool, error) {
+	deleted := false
+	err := s.update(func(txn *storeTxn) error {
+		var err error
+		deleted, err = s.expireKeyTxn(txn, key, now)
+		return err
+	})
+	if errors.Is(err, badger.ErrTxnTooBig) {
+		return s.unlinkExpired(key, now)
+	}
+	if err == nil && deleted {
+		s.notifyZWatch(key, nil, nil, true)
+	}
+	return deleted, err
+}
+
+// unlinkExpired 键仍已过期时交给 UNLINK 的后台回收，用于数据超过单个事务大小上限的键
+func (s *BotreonStore) unlinkExpired(key string, now time.Time) (bool, error) {
+	n, err := s.unlinkKeyIf(key, true, func(txn *storeTxn, keyType string) (bool, error) {
+		exp, err := s.expiresAtTxn(txn, key, keyType)
+		return exp != 0 && !expiresAtTime(exp).After(now), err
+	})
+	return n == 1, err
+}
+
+// expireKeyTxn 键在 txn 中仍已过期时删除
+func (s *BotreonStore) expireKeyTxn(txn *storeTxn, key string, now time.Time) (bool, error) {
+	keyType, err := keyTypeTxn(txn, key)
+	if err != nil || keyType == "" {
+		return false, err
+	}
+	exp, err := s.expiresAtTxn(txn, key, keyType)
+	if err != nil {
+		return false, err
+	}
+	if exp == 0 || expiresAtTime(exp).After(now) {
+		return false, nil
+	}
+	return s.delTxn(txn, key)
+}
+
+// latestExpiresAt Badger 键最新版本的过期时间（原始值，见 expiresAtTime），
+// 包括已被 Badger 隐藏的过期值；键不存在、已删除或没有过期时间时为 0
+func latestExpiresAt(txn *storeTxn, key []byte) uint64 {
+	opts := badger.DefaultIteratorOptions
+	opts.AllVersions = true
+	opts.PrefetchValues = false
+	opts.Prefix = key
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	it.Seek(key)
+	if !it.Valid() || !bytes.Equal(it.Item().Key(), key) {
+		return 0
+	}
+	return it.Item().ExpiresAt()
+}
+
+// 过期时间记录：列表、哈希、集合、有序集合、Stream、JSON 等类型的过期时间保存在每个键一个的
+// EXPIRE_<key> 中（8 字节大端 Unix 纳秒），与类型键一样随 DEL、覆盖写入删除，随 RENAME 移动。
+// 写入成员、字段时不需要保留过期时间，判断键是否过期也只需一次查找。
+// 字符串与 HyperLogLog 的值总是整体写入，过期时间仍保存在值的 Badger 条目上，SET EX、KEEPTTL 等与值一起原子地写入
+var prefixKeyExpireBytes = []byte("EXPIRE_")
+
+// expireKeyGet 键的过期时间记录 EXPIRE_<key>
+func expireKeyGet(key string) []byte {
+	return append(bytes.Clone(prefixKeyExpireBytes), key...)
+}
+
+// 过期索引：设置了过期时间的键有一条按过期时间有序的索引条目，主动过期从头读取到当前时间
+//
+//	META:kexp:<at:8><key>    at 为过期时间（Unix 纳秒，大端），值为空
+//
+// 与哈希字段的过期索引相同，条目不随键的删除、覆盖或过期时间的修改而删除：读到的条目对应的键
+// 已不存在或尚未过期时是过时的条目，直接删除。写入过期时间记录或带过期时间的字符串、HyperLogLog 的值时，
+// storeTxn 在同一事务中写入条目（见 expireIndexEntry）。旧版本的数据在打开时补建索引（见 buildExpireIndex）
+const (
+	metaKeyExpirePrefix = "META:kexp:"
+	// metaKeyExpireIndexed 存在时过期索引已包含所有带过期时间的键
+	metaKeyExpireIndexed = "META:kexp-indexed"
+)
+
+// expireIndexKey 过期时间为 exp（原始值，见 expiresAtTime）的键的索引条目
+func expireIndexKey(exp uint64, key string) []byte {
+	k := make([]byte, 0, len(metaKeyExpirePrefix)+8+len(key))
+	k = append(k, metaKeyExpirePrefix...)
+	// #nosec G115 - 过期时间为正的 Unix 纳秒时间戳
+	k = binary.BigEndian.AppendUint64(k, uint64(expiresAtTime(exp).UnixNano()))
+	return append(k, key...)
+}
+
+// parseExpireIndexKey 解析索引条目，返回过期时间（Unix 纳秒）与键，格式不符时 ok 为 false
+func parseExpireIndexKey(k []byte) (at uint64, key string, ok bool) {
+	rest := k[len(metaKeyExpirePrefix):]
+	if len(rest) < 8 {
+		return 0, "", false
+	}
+	return binary.BigEndian.Uint64(rest), string(rest[8:]), true
+}
+
+// expireIndexEntry 写入 Badger 键 k 时需要写入的索引条目：k 为过期时间记录，
+// 或 k 为字符串、HyperLogLog 的值且带过期时间 expiresAt。不需要时返回 nil
+func expireIndexEntry(k, val []byte, expiresAt uint64) []byte {
+	if bytes.HasPrefix(k, prefixKeyExpireBytes) {
+		if len(val) != 8 {
+			return nil
+		}
+		return expireIndexKey(helper.BytesToUint64(val), string(k[len(prefixKeyExpireBytes):]))
+	}
+	if expiresAt == 0 {
+		return nil
+	}
+	for _, prefix := range []string{KeyTypeString + ":", "hll:"} {
+		if bytes.HasPrefix(k, []byte(prefix)) {
+			return expireIndexKey(expiresAt, string(k[len(prefix):]))
+		}
+	}
+	return nil
+}
+
+// buildExpireIndex 为旧版本写入的带过期时间的键补建过期索引，完成后写入 metaKeyExpireIndexed。打开时调用
+func (s *BotreonStore) buildExpireIndex() error {
+	var entries [][]byte
+	err := s.view(func(txn *storeTxn) error {
+		if _, err := txn.Get([]byte(metaKeyExpireIndexed)); err == nil || !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		entries = [][]byte{}
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefixKeyTypeBytes
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			keyType, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			key := string(item.Key()[len(prefixKeyTypeBytes):])
+			exp, err := s.expiresAtTxn(txn, key, string(keyType))
+			if err != nil {
+				return err
+			}
+			if exp != 0 {
+				entries = append(entries, expireIndexKey(exp, key))
+			}
+		}
+		return nil
+	})
+	if err != nil || entries == nil {
+		return err
+	}
+	for start := 0; start < len(entries); start += unlinkBatch {
+		batch := entries[start:min(start+unlinkBatch, len(entries))]
+		if err := s.update(func(txn *storeTxn) error {
+			for _, k := range batch {
+				if err := txn.Set(k, nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return s.update(func(txn *storeTxn) error {
+		return txn.Set([]byte(metaKeyExpireIndexed), nil)
+	})
+}
+
+// ttlOnValue 过期时间保存在值条目上（而不是过期时间记录中）的类型
+func ttlOnValue(keyType string) bool {
+	return keyType == KeyTypeString || keyType == keyTypeHyperLogLog
+}
+
+// keyTypeTxn 读取键的类型，键不存在时返回空字符串
+func keyTypeTxn(txn *storeTxn, key string) (string, error) {
+	item, err := txn.Get(TypeOfKeyGet(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	val, err := item.ValueCopy(nil)
+	return string(val), err
+}
+
+// loadExpireRecords 打开时检查是否存在过期时间记录，没有时执行命令前不需要检查键是否过期
+func (s *BotreonStore) loadExpireRecords() error {
+	return s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefixKeyExpireBytes
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+		iter.Rewind()
+		s.expireRecords.Store(iter.Valid())
+		return nil
+	})
+}
+
+// expiresAtTxn 键在 txn 中的过期时间（原始值，见 expiresAtTime），没有时为 0。
+// 值条目上的过期时间包括已被 Badger 隐藏的值
+func (s *BotreonStore) expiresAtTxn(txn *storeTxn, key, keyType string) (uint64, error) {
+	if ttlOnValue(keyType) {
+		valueKey, err := s.getKeyValueKey(key, keyType)
+		if err != nil {
+			return 0, err
+		}
+		return latestExpiresAt(txn, valueKey), nil
+	}
+	item, err := txn.Get(expireKeyGet(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, err
+	}
+	return helper.BytesToUint64(val), nil
+}
+
+// setExpiresAtTxn 在 txn 中设置键的过期时间，expiresAt 为 0 时移除。
+// 值条目上的过期时间通过原样写回存储的值设置（保留压缩与冷层占位值），值不存在时返回 false
+func (s *BotreonStore) setExpiresAtTxn(txn *storeTxn, key, keyType string, expiresAt uint64) (bool, error) {
+	if !ttlOnValue(keyType) {
+		if expiresAt == 0 {
+			return true, txn.Delete(expireKeyGet(key))
+		}
+		s.expireRecords.Store(true)
+		return true, txn.Set(expireKeyGet(key), helper.Uint64ToBytes(expiresAt))
+	}
+	valueKey, err := s.getKeyValueKey(key, keyType)
+	if err != nil {
+		return false, err
+	}
+	item, err := txn.Get(valueKey)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return false, err
+	}
+	e := badger.NewEntry(valueKey, val)
+	e.ExpiresAt = expiresAt
+	return true, txn.SetEntry(e)
+}
+
+// ExpireAccessed 惰性过期：命令访问键之前调用，删除其中已过期的键并返回这些键，
+// 之后的命令按键不存在执行。没有过期时间记录时直接返回，字符串的读取本身会跳过已过期的值
+func (s *BotreonStore) ExpireAccessed(keys ...string) ([]string, error) {
+	if !s.expireRecords.Load() || len(keys) == 0 {
+		return nil, nil
+	}
+	now := s.now()
+	var stale []string
+	for _, key := range keys {
+		expiresAt, exists, err := s.keyExpiresAt(key)
+		if err != nil {
+			return nil, err
+		}
+		if exists && !expiresAt.IsZero() && !expiresAt.After(now) {
+			stale = append(stale, key)
+		}
+	}
+	if len(stale) == 0 {
+		return nil, nil
+	}
+	return s.expireKeys(stale, now)
+}
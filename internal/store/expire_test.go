@@ -0,0 +1,339 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/zeebo/assert"
+)
+
+func TestExpireCycle(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	clock := NewManualClock(time.Now())
+	store.SetClock(clock)
+
+	assert.NoError(t, store.Set("persistent", "v"))
+	for i := 0; i < 3; i++ {
+		key := fmt.Sprintf("session:%d", i)
+		assert.NoError(t, store.Set(key, "v"))
+		ok, err := store.Expire(key, 30)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+	_, err = store.RPush("queue", "a")
+	assert.NoError(t, err)
+	ok, err := store.PExpire("queue", int64(2*time.Hour/time.Millisecond))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// 第一轮：没有键过期，统计 TTL 分布
+	expired, err := store.ExpireCycle(100)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(expired))
+	stats := store.ExpireStats()
+	assert.Equal(t, int64(1), stats.Passes)
+	assert.Equal(t, int64(4), stats.Keys)
+	assert.DeepEqual(t, []int64{3, 0, 1, 0, 0}, stats.TTL)
+	assert.Equal(t, int64(3), stats.MaxSameSecond)
+
+	// 过期后按过期索引分批删除，TTL 分布的一轮在读到索引末尾时结束
+	clock.Advance(time.Minute)
+	expired, err = store.ExpireCycle(2)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"session:0", "session:1"}, expired)
+	stats = store.ExpireStats()
+	assert.Equal(t, int64(2), stats.Passes)
+	assert.Equal(t, int64(1), stats.Keys)
+	assert.DeepEqual(t, []int64{0, 0, 1, 0, 0}, stats.TTL)
+	expired, err = store.ExpireCycle(2)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"session:2"}, expired)
+
+	stats = store.ExpireStats()
+	assert.Equal(t, int64(3), stats.ExpiredKeys)
+	assert.Equal(t, int64(3), stats.Passes)
+	assert.Equal(t, int64(1), stats.Keys)
+	assert.True(t, stats.StalePerc > 0)
+	exists, err := store.Exists("session:0")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = store.Exists("queue")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestExpireCycleBatches(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	clock := NewManualClock(time.Now())
+	store.SetClock(clock)
+
+	// 超过一个删除事务的键数，且混有不同类型
+	n := expireDeleteBatch*2 + 5
+	for i := 0; i < n; i++ {
+		assert.NoError(t, store.SetWithTTL(fmt.Sprintf("bulk:%03d", i), "v", time.Minute))
+	}
+	_, err = store.RPush("q", "a")
+	assert.NoError(t, err)
+	ok, err := store.Expire("q", 30)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, store.Set("keep", "v"))
+
+	clock.Advance(2 * time.Minute)
+	expired, err := store.ExpireCycle(n + 10)
+	assert.NoError(t, err)
+	assert.Equal(t, n+1, len(expired))
+	// 按过期时间顺序删除
+	assert.Equal(t, "q", expired[0])
+	assert.Equal(t, "bulk:000", expired[1])
+	keys, err := store.Keys("*")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"keep"}, keys)
+}
+
+func TestExpireAllTypes(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	clock := NewManualClock(time.Now())
+	store.SetClock(clock)
+
+	assert.NoError(t, store.HSet("h", "f", "v"))
+	_, err = store.RPush("l", "a", "b")
+	assert.NoError(t, err)
+	_, err = store.SAdd("s", "m")
+	assert.NoError(t, err)
+	assert.NoError(t, store.ZAdd("z", []ZSetMember{{Member: "m", Score: 1}}))
+	_, err = store.XAdd("x", StreamXAddOptions{}, "1-1", map[string]string{"f": "v"})
+	assert.NoError(t, err)
+	_, err = store.JSONSet("j", "$", `{"a":1}`, false, false)
+	assert.NoError(t, err)
+	keys := []string{"h", "l", "s", "z", "x", "j"}
+
+	for _, key := range keys {
+		ok, err := store.Expire(key, 60)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		ttl, err := store.TTL(key)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(60), ttl)
+	}
+	// 写入成员不影响过期时间
+	assert.NoError(t, store.HSet("h", "f2", "v2"))
+	_, err = store.RPush("l", "c")
+	assert.NoError(t, err)
+	ttl, err := store.PTTL("h")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(60000), ttl)
+	ttl, err = store.TTL("l")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(60), ttl)
+
+	// PERSIST 删除过期时间记录
+	ok, err := store.Persist("s")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ttl, err = store.TTL("s")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), ttl)
+	ok, err = store.Persist("s")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// RENAME 移动过期时间记录
+	assert.NoError(t, store.Rename("z", "z2"))
+	ttl, err = store.TTL("z2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(60), ttl)
+
+	// 过期后惰性删除键的全部数据，未过期的键不受影响
+	clock.Advance(2 * time.Minute)
+	expired, err := store.ExpireAccessed("h", "l", "s", "z2", "x", "j")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"h", "l", "z2", "x", "j"}, expired)
+	for _, key := range []string{"h", "l", "z2", "x", "j"} {
+		layout, err := store.KeyLayout(key)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(layout))
+		ttl, err = store.TTL(key)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(-2), ttl)
+	}
+	count, err := store.SCard("s")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), count)
+
+	// 重新创建的键没有过期时间
+	assert.NoError(t, store.HSet("h", "f", "v"))
+	ttl, err = store.TTL("h")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), ttl)
+
+	// 过期时间不晚于当前时间时直接删除
+	ok, err = store.Expire("s", 0)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	exists, err := store.Exists("s")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+// expireIndexEntries 过期索引中的条目
+func expireIndexEntries(t *testing.T, store *BotreonStore) []string {
+	var keys []string
+	err := store.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(metaKeyExpirePrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			_, key, ok := parseExpireIndexKey(it.Item().Key())
+			assert.True(t, ok)
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	return keys
+}
+
+func TestExpireIndexStaleEntries(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	clock := NewManualClock(time.Now())
+	store.SetClock(clock)
+
+	// PERSIST、延长过期时间与删除后重新创建都会留下过时的条目
+	assert.NoError(t, store.SetWithTTL("persisted", "v", 10*time.Second))
+	ok, err := store.Persist("persisted")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	_, err = store.RPush("extended", "a")
+	assert.NoError(t, err)
+	_, err = store.Expire("extended", 10)
+	assert.NoError(t, err)
+	_, err = store.Expire("extended", 300)
+	assert.NoError(t, err)
+	assert.NoError(t, store.HSet("recreated", "f", "v"))
+	_, err = store.Expire("recreated", 10)
+	assert.NoError(t, err)
+	_, err = store.Del("recreated")
+	assert.NoError(t, err)
+	assert.NoError(t, store.HSet("recreated", "f", "v"))
+	entries := expireIndexEntries(t, store)
+	sort.Strings(entries)
+	assert.DeepEqual(t, []string{"extended", "extended", "persisted", "recreated"}, entries)
+
+	// TTL 分布只统计与键当前的过期时间一致的条目
+	expired, err := store.ExpireCycle(10)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(expired))
+	stats := store.ExpireStats()
+	assert.Equal(t, int64(1), stats.Keys)
+	assert.DeepEqual(t, []int64{0, 1, 0, 0, 0}, stats.TTL)
+
+	clock.Advance(time.Minute)
+	expired, err = store.ExpireCycle(10)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(expired))
+	assert.DeepEqual(t, []string{"extended"}, expireIndexEntries(t, store))
+	for _, key := range []string{"persisted", "extended", "recreated"} {
+		exists, err := store.Exists(key)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	}
+
+	clock.Advance(5 * time.Minute)
+	expired, err = store.ExpireCycle(10)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"extended"}, expired)
+	assert.Equal(t, 0, len(expireIndexEntries(t, store)))
+}
+
+// TestExpireIndexBuiltOnOpen 旧版本写入的过期时间在打开时补建索引
+func TestExpireIndexBuiltOnOpen(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBadgerStore(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, store.SetWithTTL("s", "v", time.Minute))
+	_, err = store.SAdd("set", "m")
+	assert.NoError(t, err)
+	_, err = store.Expire("set", 60)
+	assert.NoError(t, err)
+	// 删除索引与标记，模拟旧版本的数据
+	err = store.db.Update(func(txn *badger.Txn) error {
+		for _, key := range []string{"s", "set"} {
+			if err := txn.Delete(expireIndexKey(0, key)); err != nil {
+				return err
+			}
+		}
+		return txn.Delete([]byte(metaKeyExpireIndexed))
+	})
+	assert.NoError(t, err)
+	err = store.db.DropPrefix([]byte(metaKeyExpirePrefix))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(expireIndexEntries(t, store)))
+	assert.NoError(t, store.Close())
+
+	store, err = NewBadgerStore(dir)
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.Equal(t, 2, len(expireIndexEntries(t, store)))
+	clock := NewManualClock(time.Now().Add(2 * time.Minute))
+	store.SetClock(clock)
+	expired, err := store.ExpireCycle(10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(expired))
+}
+
+// TestExpireBeyondTxnLimit 数据超过单个事务大小上限的键过期后交给 UNLINK 的后台回收，不阻塞其他键
+func TestExpireBeyondTxnLimit(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	clock := NewManualClock(time.Now())
+	store.SetClock(clock)
+
+	// 成员数超过单个 Badger 事务能容纳的条目数
+	members := make([]string, 0, 5000)
+	for i := 0; i < 200000; i++ {
+		members = append(members, strconv.Itoa(i))
+		if len(members) == cap(members) {
+			_, err := store.SAdd("big", members...)
+			assert.NoError(t, err)
+			members = members[:0]
+		}
+	}
+	_, err = store.Expire("big", 10)
+	assert.NoError(t, err)
+	assert.NoError(t, store.SetWithTTL("small", "v", 20*time.Second))
+
+	clock.Advance(time.Minute)
+	expired, err := store.ExpireCycle(10)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"big", "small"}, expired)
+	assert.Equal(t, 0, len(expireIndexEntries(t, store)))
+	deadline := time.Now().Add(10 * time.Second)
+	for store.UnlinkPending() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expired key not reclaimed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	layout, err := store.KeyLayout("big")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(layout))
+	count, err := store.SCard("big")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), count)
+}
@@ -0,0 +1,197 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// geoMaxLat 可以编码的纬度范围，与 Redis 相同（Web Mercator 的有效范围）
+const geoMaxLat = 85.05112878
+
+var (
+	// ErrGeoMemberNotFound FROMMEMBER 指定的成员不存在
+	ErrGeoMemberNotFound = errors.New("could not decode requested zset member")
+	// ErrGeoUnit 不支持的距离单位
+	ErrGeoUnit = errors.New("unsupported unit provided. please use M, KM, FT, MI")
+)
+
+// GeoSearchOptions GEOSEARCH 与 GEOSEARCHSTORE 的查询条件。
+// 中心为 FromMember 的位置（非空时）或 (Lon, Lat)；范围为半径 Radius 的圆，
+// ByBox 时为以中心为中点、宽 Width 高 Height 的矩形，长度的单位均为 Unit。
+// Order 为 ASC、DESC 或空；Count 为 0 时不限制，Any 时找到 Count 个即返回，不保证是最近的
+type GeoSearchOptions struct {
+	FromMember string
+	Lon, Lat   float64
+	ByBox      bool
+	Radius     float64
+	Width      float64
+	Height     float64
+	Unit       string
+	Order      string
+	Count      int
+	Any        bool
+}
+
+// GeoUnitMeters 距离单位对应的米数
+func GeoUnitMeters(unit string) (float64, error) {
+	switch strings.ToUpper(unit) {
+	case "M", "":
+		return 1, nil
+	case "KM":
+		return 1000, nil
+	case "MI":
+		return 1609.34, nil
+	case "FT":
+		return 0.3048, nil
+	default:
+		return 0, ErrGeoUnit
+	}
+}
+
+// ValidGeoCoord 经纬度是否在可以编码的范围内
+func ValidGeoCoord(lon, lat float64) bool {
+	return lon >= -180 && lon <= 180 && lat >= -geoMaxLat && lat <= geoMaxLat
+}
+
+// geoInBox 点 (lon, lat) 是否在以 (centerLon, centerLat) 为中点、宽 widthM 高 heightM（米）的矩形内：
+// 与 Redis 相同，纬向距离沿经线计算，经向距离在点所在的纬线上计算
+func geoInBox(centerLon, centerLat, lon, lat, widthM, heightM float64) bool {
+	if calculateDistance(centerLat, centerLon, lat, centerLon) > heightM/2 {
+		return false
+	}
+	return calculateDistance(lat, centerLon, lat, lon) <= widthM/2
+}
+
+// GeoSearch 实现 GEOSEARCH：返回范围内的成员，Dist 为到中心的距离（单位为 opts.Unit）。
+// 编码的高 26 位是纬度，只扫描纬度在范围内的分数区间，再逐个按实际距离过滤
+func (s *BotreonStore) GeoSearch(key string, opts GeoSearchOptions) ([]GeoSearchResult, error) {
+	unit, err := GeoUnitMeters(opts.Unit)
+	if err != nil {
+		return nil, err
+	}
+	var results []GeoSearchResult
+	err = s.view(func(txn *storeTxn) error {
+		centerLon, centerLat := opts.Lon, opts.Lat
+		if opts.FromMember != "" {
+			item, err := txn.Get(geoIndexKey(key, opts.FromMember))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return ErrGeoMemberNotFound
+			}
+			if err != nil {
+				return err
+			}
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			centerLat, centerLon = decodeGeoHash(binary.BigEndian.Uint64(val))
+		}
+
+		halfHeight := opts.Radius * unit
+		if opts.ByBox {
+			halfHeight = opts.Height * unit / 2
+		}
+		latDelta := halfHeight / earthRadiusMeters * 180 / math.Pi
+		minLat, maxLat := math.Max(centerLat-latDelta, -90), math.Min(centerLat+latDelta, 90)
+		minScore := float64(encodeGeoHash(minLat, -180))
+		maxScore := float64(encodeGeoHash(maxLat, 180))
+
+		prefix := sortedSetIndexPrefix(key)
+		iterOpts := badger.DefaultIteratorOptions
+		iterOpts.PrefetchValues = false
+		iterOpts.Prefix = prefix
+		iter := txn.NewIterator(iterOpts)
+		defer iter.Close()
+		for iter.Seek(append(prefix, encodeScore(minScore)...)); iter.Valid(); iter.Next() {
+			member, ok := parseSortedSetIndexKey(prefix, iter.Item().Key())
+			if !ok {
+				continue
+			}
+			if member.Score > maxScore {
+				break
+			}
+			lat, lon := decodeGeoHash(uint64(member.Score))
+			dist := calculateDistance(centerLat, centerLon, lat, lon)
+			if opts.ByBox {
+				if !geoInBox(centerLon, centerLat, lon, lat, opts.Width*unit, opts.Height*unit) {
+					continue
+				}
+			} else if dist > opts.Radius*unit {
+				continue
+			}
+			results = append(results, GeoSearchResult{
+				Member: member.Member,
+				Lat:    lat,
+				Lon:    lon,
+				Dist:   dist / unit,
+				Hash:   geoHashToString(uint64(member.Score)),
+			})
+			if opts.Any && opts.Count > 0 && len(results) >= opts.Count {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 与 Redis 相同，COUNT 不带 ANY 时按距离升序取最近的 Count 个
+	order := strings.ToUpper(opts.Order)
+	if order == "" && opts.Count > 0 && !opts.Any {
+		order = "ASC"
+	}
+	if order != "" {
+		desc := order == "DESC"
+		sort.SliceStable(results, func(i, j int) bool {
+			if desc {
+				return results[i].Dist > results[j].Dist
+			}
+			return results[i].Dist < results[j].Dist
+		})
+	}
+	if opts.Count > 0 && len(results) > opts.Count {
+		results = results[:opts.Count]
+	}
+	return results, nil
+}
+
+// GeoSearchStore 实现 GEOSEARCHSTORE：把 GEOSEARCH 的结果写入 dstKey，覆盖原有的值。
+// 默认保存为地理位置集合；storeDist 时保存为有序集合，分数为到中心的距离。
+// 结果为空时删除 dstKey，返回写入的成员数
+func (s *BotreonStore) GeoSearchStore(dstKey, srcKey string, opts GeoSearchOptions, storeDist bool) (int64, error) {
+	results, err := s.GeoSearch(srcKey, opts)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.Del(dstKey); err != nil {
+		return 0, err
+	}
+	if len(results) == 0 {
+		return 0, nil
+	}
+
+	if storeDist {
+		members := make([]ZSetMember, len(results))
+		for i, r := range results {
+			members[i] = ZSetMember{Member: r.Member, Score: r.Dist}
+		}
+		if err := s.ZAdd(dstKey, members); err != nil {
+			return 0, err
+		}
+	} else {
+		members := make([]GeoMember, len(results))
+		for i, r := range results {
+			members[i] = GeoMember{Member: r.Member, Lat: r.Lat, Lon: r.Lon}
+		}
+		if _, err := s.GeoAdd(dstKey, members); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(results)), nil
+}
@@ -151,7 +151,7 @@ func geoHashToCoordKey(key string, hash uint64) []byte {
 // GeoAdd adds geographic locations to a sorted set
 func (s *BotreonStore) GeoAdd(key string, members []GeoMember) (int64, error) {
 	var added int64
-	err := s.retryUpdateSortedSet(func(txn *badger.Txn) error {
+	err := s.retryUpdateSortedSet(func(txn *storeTxn) error {
 		// Set type key
 		typeKey := TypeOfKeyGet(key)
 		if err := txn.Set(typeKey, []byte(KeyTypeGeo)); err != nil {
@@ -231,8 +231,7 @@ func (s *BotreonStore) GeoAdd(key string, members []GeoMember) (int64, error) {
 		for i, op := range ops {
 			// Delete old index entry if exists
 			if op.exists {
-				oldIndexKey := sortedSetKeyIndex(key, float64(op.oldHash), members[i].Member, 0)
-				if err := txn.Delete(oldIndexKey); err != nil {
+				if err := deleteSortedSetIndex(txn, key, float64(op.oldHash), members[i].Member); err != nil {
 					return err
 				}
 				if err := txn.Delete(op.oldScoreKey); err != nil {
@@ -244,7 +243,7 @@ func (s *BotreonStore) GeoAdd(key string, members []GeoMember) (int64, error) {
 			if err := txn.Set(sortedSetKeyMember(key, members[i].Member), op.score); err != nil {
 				return err
 			}
-			if err := txn.Set(op.indexKey, nil); err != nil {
+			if err := setSortedSetIndex(txn, key, op.indexKey); err != nil {
 				return err
 			}
 			// Store member -> hash mapping
@@ -270,7 +269,7 @@ func (s *BotreonStore) GeoAdd(key string, members []GeoMember) (int64, error) {
 // GeoPos returns the positions of all members
 func (s *BotreonStore) GeoPos(key string, members ...string) ([][2]float64, error) {
 	var results [] [2]float64
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		for _, member := range members {
 			hashKey := geoIndexKey(key, member)
 			item, err := txn.Get(hashKey)
@@ -300,7 +299,7 @@ func (s *BotreonStore) GeoPos(key string, members ...string) ([][2]float64, erro
 // GeoHash returns the geohash strings for members
 func (s *BotreonStore) GeoHash(key string, members ...string) ([]string, error) {
 	var results []string
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		for _, member := range members {
 			hashKey := geoIndexKey(key, member)
 			item, err := txn.Get(hashKey)
@@ -330,7 +329,7 @@ func (s *BotreonStore) GeoHash(key string, members ...string) ([]string, error)
 func (s *BotreonStore) GeoDist(key, member1, member2, unit string) (float64, error) {
 	var dist float64
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		// Get first member position
 		hashKey1 := geoIndexKey(key, member1)
 		item1, err := txn.Get(hashKey1)
@@ -457,7 +456,7 @@ func (s *BotreonStore) GeoRadius(key string, lon, lat, radius float64, unit stri
 	// Convert to score range (geohash)
 	minScore := float64(encodeGeoHash(minLat, minLon))
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		opts := badger.DefaultIteratorOptions
 		prefix := keyBadgerGet(prefixKeySortedSetBytes, []byte(key+sortedSetIndex))
 		opts.Prefix = prefix
@@ -532,81 +531,9 @@ func (s *BotreonStore) GeoRadius(key string, lon, lat, radius float64, unit stri
 	return results, err
 }
 
-// GeoSearch searches for members by various criteria
-func (s *BotreonStore) GeoSearch(key string, centerLon, centerLat float64, radius float64, unit string, count int, withDist, withHash, withCoord bool) ([]GeoSearchResult, error) {
-	return s.GeoRadius(key, centerLon, centerLat, radius, unit, count, withDist, withHash, withCoord)
-}
-
-// GeoSearchStore searches and stores results to a destination key
-func (s *BotreonStore) GeoSearchStore(dstKey, srcKey string, centerLon, centerLat float64, radius float64, unit string, count int, storeDist bool) (int64, error) {
-	// Search for members
-	results, err := s.GeoSearch(srcKey, centerLon, centerLat, radius, unit, count, false, false, false)
-	if err != nil {
-		return 0, err
-	}
-
-	if len(results) == 0 {
-		return 0, nil
-	}
-
-	// Get positions for all members
-	positions, err := s.GeoPos(srcKey, extractMembers(results)...)
-	if err != nil {
-		return 0, err
-	}
-
-	// Add members to destination as sorted set with their original geohash scores
-	var members []ZSetMember
-	for i, result := range results {
-		hash := encodeGeoHash(positions[i][0], positions[i][1])
-		members = append(members, ZSetMember{
-			Member: result.Member,
-			Score:  float64(hash),
-		})
-	}
-
-	if storeDist {
-		// Store as sorted set with distance as score
-		members = nil
-		for _, result := range results {
-			var dist float64
-			switch strings.ToUpper(unit) {
-			case "M", "":
-				dist = result.Dist
-			case "KM":
-				dist = result.Dist / 1000
-			case "MI":
-				dist = result.Dist / 1609.344
-			case "FT":
-				dist = result.Dist * 0.3048
-			}
-			members = append(members, ZSetMember{
-				Member: result.Member,
-				Score:  dist,
-			})
-		}
-	}
-
-	err = s.ZAdd(dstKey, members)
-	if err != nil {
-		return 0, err
-	}
-
-	return int64(len(members)), nil
-}
-
-// extractMembers extracts member names from results
-func extractMembers(results []GeoSearchResult) []string {
-	members := make([]string, len(results))
-	for i, r := range results {
-		members[i] = r.Member
-	}
-	return members
-}
-
 // GeoDel removes members from a geo set
 func (s *BotreonStore) GeoDel(key, member string) error {
-	return s.retryUpdateSortedSet(func(txn *badger.Txn) error {
+	return s.retryUpdateSortedSet(func(txn *storeTxn) error {
 		// Get hash first
 		hashKey := geoIndexKey(key, member)
 		item, err := txn.Get(hashKey)
@@ -628,7 +555,7 @@ func (s *BotreonStore) GeoDel(key, member string) error {
 		if err := txn.Delete(sortedSetKeyMember(key, member)); err != nil {
 			return err
 		}
-		if err := txn.Delete(sortedSetKeyIndex(key, float64(hash), member, 0)); err != nil {
+		if err := deleteSortedSetIndex(txn, key, float64(hash), member); err != nil {
 			return err
 		}
 		if err := txn.Delete(hashKey); err != nil {
@@ -683,14 +610,14 @@ func (s *BotreonStore) GeoRadiusByMember(key, member string, radius float64, uni
 }
 
 // retryUpdateSortedSet reuses the sorted set retry mechanism
-func (s *BotreonStore) retryUpdateGeo(fn func(*badger.Txn) error, maxRetries int) error {
+func (s *BotreonStore) retryUpdateGeo(fn func(*storeTxn) error, maxRetries int) error {
 	return s.retryUpdateSortedSet(fn, maxRetries)
 }
 
 // GeoMembers returns all members in a geo set
 func (s *BotreonStore) GeoMembers(key string) ([]string, error) {
 	var members []string
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		opts := badger.DefaultIteratorOptions
 		prefix := keyBadgerGet(prefixKeySortedSetBytes, []byte(key+sortedSetIndex))
 		opts.Prefix = prefix
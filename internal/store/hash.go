@@ -20,7 +20,7 @@ import (
 //		return fmt.Errorf("%s,%v", logFuncTag, err)
 //	}
 //	hkey := s.hashKey(key, field)
-//	return s.db.Update(func(txn *badger.Txn) error {
+//	return s.update(func(txn *storeTxn) error {
 //		err := txn.Set(hkey, bValue)
 //		if err != nil {
 //			return err
@@ -59,66 +59,50 @@ func (s *BotreonStore) HSet(key, field string, value interface{}) error {
 			return fmt.Errorf("%s,%v", logFuncTag, err)
 		}
 	}
-	hkey := s.hashKey(key, field)
-	typeKey := TypeOfKeyGet(key)
-
-	// 先在View事务中获取当前计数
-	var currentCount uint64
-	err := s.db.View(func(txn *badger.Txn) error {
-		countKey := s.hashCountKey(key)
-		countItem, err := txn.Get(countKey)
-		if err == nil {
-			val, err := countItem.ValueCopy(nil)
-			if err != nil {
-				return fmt.Errorf("HSet: failed to get count value: %v", err)
-			}
-			currentCount = helper.BytesToUint64(val)
-		} else if !errors.Is(err, badger.ErrKeyNotFound) {
-			return err
-		}
-		return nil
-	})
-	if err != nil {
+	// 计数器在同一事务中读写，并发写同一个哈希时冲突重试
+	return s.retryUpdate(func(txn *storeTxn) error {
+		_, err := s.hsetTxn(txn, key, field, bValue)
 		return err
-	}
-
-	// 然后在Update事务中写入
-	return s.db.Update(func(txn *badger.Txn) error {
-		// 检查字段是否存在
-		exists := false
-		if _, err := txn.Get(hkey); err == nil {
-			exists = true
-		}
-
-		if err := txn.Set(typeKey, []byte(KeyTypeHash)); err != nil {
-			return err
-		}
+	}, 30)
+}
 
-		// 写入字段值（带压缩）
-		if err := s.setValueWithCompression(txn, hkey, bValue); err != nil {
-			return err
-		}
+// hsetTxn 在 txn 中写入哈希字段，返回字段是否为新增
+func (s *BotreonStore) hsetTxn(txn *storeTxn, key, field string, value []byte) (bool, error) {
+	hkey := s.hashKey(key, field)
+	exists := false
+	if _, err := txn.Get(hkey); err == nil {
+		exists = true
+	}
+	if err := txn.Set(TypeOfKeyGet(key), []byte(KeyTypeHash)); err != nil {
+		return false, err
+	}
+	// 写入字段值（带压缩）
+	if err := s.setValueWithCompression(txn, hkey, value); err != nil {
+		return false, err
+	}
+	if exists {
+		// 覆盖写入清除字段的过期时间
+		return false, s.clearHashFieldTTLTxn(txn, key, field)
+	}
 
-		// 更新计数器
-		countKey := s.hashCountKey(key)
-		if !exists {
-			currentCount++
+	// 新字段：更新计数器
+	countKey := s.hashCountKey(key)
+	var count uint64
+	item, err := txn.Get(countKey)
+	if err == nil {
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return false, fmt.Errorf("HSet: failed to get count value: %v", err)
 		}
-		return txn.Set(countKey, helper.Uint64ToBytes(currentCount))
-	})
+		count = helper.BytesToUint64(val)
+	} else if !errors.Is(err, badger.ErrKeyNotFound) {
+		return false, err
+	}
+	return true, txn.Set(countKey, helper.Uint64ToBytes(count+1))
 }
+
 func (s *BotreonStore) HGet(key, field string) ([]byte, error) {
-	hkey := s.hashKey(key, field)
-	var val []byte
-	err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get(hkey)
-		if err != nil {
-			return err
-		}
-		val, err = s.getValueWithDecompression(item)
-		return err
-	})
-	return val, err
+	return s.cachedValue(s.hashKey(key, field), key)
 }
 
 func (s *BotreonStore) hashKey(key, field string) []byte {
@@ -133,7 +117,7 @@ func (s *BotreonStore) hashCountKey(key string) []byte {
 // HDel 实现 Redis HDEL 命令
 func (s *BotreonStore) HDel(key string, fields ...string) (int, error) {
 	deletedCount := 0
-	err := s.db.Update(func(txn *badger.Txn) error {
+	err := s.update(func(txn *storeTxn) error {
 		countKey := s.hashCountKey(key)
 		var currentCount uint64
 		countItem, err := txn.Get(countKey)
@@ -154,6 +138,9 @@ func (s *BotreonStore) HDel(key string, fields ...string) (int, error) {
 				if err := txn.Delete(hkey); err != nil {
 					return err
 				}
+				if err := s.clearHashFieldTTLTxn(txn, key, field); err != nil {
+					return err
+				}
 				deletedCount++
 				if currentCount > 0 {
 					currentCount--
@@ -173,7 +160,7 @@ func (s *BotreonStore) HDel(key string, fields ...string) (int, error) {
 // HLen 实现 Redis HLEN 命令
 func (s *BotreonStore) HLen(key string) (uint64, error) {
 	var count uint64
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		countKey := s.hashCountKey(key)
 		item, err := txn.Get(countKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -196,10 +183,10 @@ func (s *BotreonStore) HLen(key string) (uint64, error) {
 func (s *BotreonStore) HGetAll(key string) (map[string][]byte, error) {
 	result := make(map[string][]byte)
 	prefix := fmt.Sprintf("%s:%s:", KeyTypeHash, key)
-	err := s.db.View(func(txn *badger.Txn) error {
-		iter := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer iter.Close()
+	err := s.view(func(txn *storeTxn) error {
 		prefixBytes := []byte(prefix)
+		iter := txn.NewIterator(s.iteratorOptions(prefixBytes, -1, true))
+		defer iter.Close()
 		for iter.Seek(prefixBytes); iter.Valid(); iter.Next() {
 			k := iter.Item().Key()
 			kStr := string(k)
@@ -223,6 +210,40 @@ func (s *BotreonStore) HGetAll(key string) (map[string][]byte, error) {
 	return result, err
 }
 
+// HScanResult 定义 HSCAN 命令的返回结果
+type HScanResult struct {
+	Cursor uint64
+	Fields []string
+	Values [][]byte // NOVALUES 时为 nil
+}
+
+// HScan 实现 Redis HSCAN 命令，增量迭代哈希的字段（noValues 为 false 时同时返回值），游标语义见 scanPrefix
+func (s *BotreonStore) HScan(key string, cursor uint64, pattern string, count int, noValues bool) (HScanResult, error) {
+	result := HScanResult{Fields: []string{}}
+	prefix := []byte(fmt.Sprintf("%s:%s:", KeyTypeHash, key))
+	next, err := s.scanPrefix("hscan:"+key, prefix, cursor, count, func(item *badger.Item) error {
+		field := string(item.Key()[len(prefix):])
+		if field == "__count__" {
+			return nil
+		}
+		if pattern != "" && pattern != "*" && !matchPattern(field, pattern) {
+			return nil
+		}
+		result.Fields = append(result.Fields, field)
+		if noValues {
+			return nil
+		}
+		val, err := s.getValueWithDecompression(item)
+		if err != nil {
+			return err
+		}
+		result.Values = append(result.Values, val)
+		return nil
+	})
+	result.Cursor = next
+	return result, err
+}
+
 // splitHashKey 从哈希键中解析出key和field
 // 键格式: HASH:key:field
 // 例如: HASH:user:1:name -> key="user:1", field="name"
@@ -246,11 +267,11 @@ func splitHashKey(key []byte) (string, string) {
 }
 
 // getAllHashFields 获取哈希表中的所有字段
-func (s *BotreonStore) getAllHashFields(txn *badger.Txn, key string) ([]string, error) {
+func (s *BotreonStore) getAllHashFields(txn *storeTxn, key string) ([]string, error) {
 	var fields []string
 	prefix := fmt.Sprintf("%s:%s:", KeyTypeHash, key)
 	prefixBytes := []byte(prefix)
-	iter := txn.NewIterator(badger.DefaultIteratorOptions)
+	iter := txn.NewIterator(s.iteratorOptions(prefixBytes, -1, false))
 	defer iter.Close()
 
 	for iter.Seek(prefixBytes); iter.Valid(); iter.Next() {
@@ -270,7 +291,7 @@ func (s *BotreonStore) getAllHashFields(txn *badger.Txn, key string) ([]string,
 // HExists 实现 Redis HEXISTS 命令，检查字段是否存在
 func (s *BotreonStore) HExists(key, field string) (bool, error) {
 	exists := false
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		hkey := s.hashKey(key, field)
 		_, err := txn.Get(hkey)
 		if err == nil {
@@ -288,7 +309,7 @@ func (s *BotreonStore) HExists(key, field string) (bool, error) {
 // HKeys 实现 Redis HKEYS 命令，获取所有字段名
 func (s *BotreonStore) HKeys(key string) ([]string, error) {
 	var fields []string
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		var err error
 		fields, err = s.getAllHashFields(txn, key)
 		return err
@@ -300,10 +321,10 @@ func (s *BotreonStore) HKeys(key string) ([]string, error) {
 func (s *BotreonStore) HVals(key string) ([][]byte, error) {
 	var values [][]byte
 	prefix := fmt.Sprintf("%s:%s:", KeyTypeHash, key)
-	err := s.db.View(func(txn *badger.Txn) error {
-		iter := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer iter.Close()
+	err := s.view(func(txn *storeTxn) error {
 		prefixBytes := []byte(prefix)
+		iter := txn.NewIterator(s.iteratorOptions(prefixBytes, -1, true))
+		defer iter.Close()
 		for iter.Seek(prefixBytes); iter.Valid(); iter.Next() {
 			k := iter.Item().Key()
 			kStr := string(k)
@@ -329,7 +350,7 @@ func (s *BotreonStore) HVals(key string) ([][]byte, error) {
 // HMSet 实现 Redis HMSET 命令，批量设置多个字段
 func (s *BotreonStore) HMSet(key string, fieldValues map[string]interface{}) error {
 	typeKey := TypeOfKeyGet(key)
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.update(func(txn *storeTxn) error {
 		if err := txn.Set(typeKey, []byte(KeyTypeHash)); err != nil {
 			return err
 		}
@@ -389,6 +410,8 @@ func (s *BotreonStore) HMSet(key string, fieldValues map[string]interface{}) err
 
 			if !exists {
 				newFields++
+			} else if err := s.clearHashFieldTTLTxn(txn, key, field); err != nil {
+				return err
 			}
 		}
 
@@ -404,7 +427,7 @@ func (s *BotreonStore) HMSet(key string, fieldValues map[string]interface{}) err
 // HMGet 实现 Redis HMGET 命令，批量获取多个字段值
 func (s *BotreonStore) HMGet(key string, fields ...string) ([][]byte, error) {
 	values := make([][]byte, len(fields))
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		for i, field := range fields {
 			hkey := s.hashKey(key, field)
 			item, err := txn.Get(hkey)
@@ -458,7 +481,7 @@ func (s *BotreonStore) HSetNX(key, field string, value interface{}) (bool, error
 		}
 	}
 	hkey := s.hashKey(key, field)
-	err := s.db.Update(func(txn *badger.Txn) error {
+	err := s.update(func(txn *storeTxn) error {
 		// 检查字段是否存在
 		_, getErr := txn.Get(hkey)
 		if getErr == nil {
@@ -503,7 +526,7 @@ func (s *BotreonStore) HSetNX(key, field string, value interface{}) (bool, error
 func (s *BotreonStore) HIncrBy(key, field string, increment int64) (int64, error) {
 	var result int64
 	typeKey := TypeOfKeyGet(key)
-	err := s.db.Update(func(txn *badger.Txn) error {
+	err := s.update(func(txn *storeTxn) error {
 		if err := txn.Set(typeKey, []byte(KeyTypeHash)); err != nil {
 			return err
 		}
@@ -527,7 +550,7 @@ func (s *BotreonStore) HIncrBy(key, field string, increment int64) (int64, error
 			// 尝试解析为整数（支持字符串格式的整数）
 			intVal, err := strconv.ParseInt(string(val), 10, 64)
 			if err != nil {
-				return fmt.Errorf("HIncrBy: value is not an integer or out of range")
+				return fmt.Errorf("hash value is not an integer")
 			}
 			currentValue = intVal
 		}
@@ -568,7 +591,7 @@ func (s *BotreonStore) HIncrBy(key, field string, increment int64) (int64, error
 func (s *BotreonStore) HIncrByFloat(key, field string, increment float64) (float64, error) {
 	var result float64
 	typeKey := TypeOfKeyGet(key)
-	err := s.db.Update(func(txn *badger.Txn) error {
+	err := s.update(func(txn *storeTxn) error {
 		if err := txn.Set(typeKey, []byte(KeyTypeHash)); err != nil {
 			return err
 		}
@@ -632,7 +655,7 @@ func (s *BotreonStore) HIncrByFloat(key, field string, increment float64) (float
 // HStrLen 实现 Redis HSTRLEN 命令，获取字段值的字符串长度
 func (s *BotreonStore) HStrLen(key, field string) (int, error) {
 	var length int
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		hkey := s.hashKey(key, field)
 		item, err := txn.Get(hkey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -658,7 +681,7 @@ func (s *BotreonStore) HStrLen(key, field string) (int, error) {
 func (s *BotreonStore) HRandField(key string, count int, withValues bool) ([]string, []string, error) {
 	var fields []string
 	var values []string
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		// 获取所有字段
 		allFields, err := s.hGetAllFields(txn, key)
 		if err != nil {
@@ -710,7 +733,7 @@ func (s *BotreonStore) HRandField(key string, count int, withValues bool) ([]str
 }
 
 // hGetAllFields 获取哈希表中的所有字段（内部方法）
-func (s *BotreonStore) hGetAllFields(txn *badger.Txn, key string) ([]hashField, error) {
+func (s *BotreonStore) hGetAllFields(txn *storeTxn, key string) ([]hashField, error) {
 	var fields []hashField
 	prefix := s.hashKey(key, "")
 	opts := badger.DefaultIteratorOptions
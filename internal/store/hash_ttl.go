@@ -0,0 +1,461 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/lbp0200/BoltDB/internal/helper"
+)
+
+// 哈希字段的过期时间（HEXPIRE 等）：设置了过期时间的字段 field 有两条记录
+//
+//	HASHTTL:<key>:<field>                      8 字节大端过期时间（Unix 毫秒），随哈希删除、移动
+//	META:hfe:<at:8><len(key):4><key><field>    按过期时间有序的全局索引，主动过期从头扫描到当前时间
+//
+// 索引条目不随字段一起删除（HDEL、HSET 覆盖、DEL 整个键等只删除字段的记录）：
+// 扫描到的条目与字段当前的记录不一致时是过时的条目，直接删除。
+// 字段的过期时间由 HSET 覆盖或 HDEL 清除，HINCRBY 等修改值的命令保留；最后一个字段过期时删除整个键
+const (
+	keyTypeHashTTL       = "HASHTTL"
+	metaHashExpirePrefix = "META:hfe:"
+	// HashFieldMaxExpireMs 字段过期时间的上限（Unix 毫秒），与 Redis 相同为 2^48
+	HashFieldMaxExpireMs = int64(1) << 48
+)
+
+// ErrHashWrongType 对非哈希的键执行哈希字段过期命令
+var ErrHashWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// HashFieldExpiry 一个哈希中同时过期的字段，Deleted 表示最后的字段过期后整个键被删除
+type HashFieldExpiry struct {
+	Key     string
+	Fields  []string
+	Deleted bool
+}
+
+// hashTTLPrefix 字段过期时间记录的前缀 HASHTTL:<key>:
+func hashTTLPrefix(key string) []byte {
+	return []byte(keyTypeHashTTL + ":" + key + ":")
+}
+
+func hashTTLKey(key, field string) []byte {
+	return append(hashTTLPrefix(key), field...)
+}
+
+// hashExpireIndexKey 全局过期索引中字段的条目
+func hashExpireIndexKey(at int64, key, field string) []byte {
+	k := make([]byte, 0, len(metaHashExpirePrefix)+12+len(key)+len(field))
+	k = append(k, metaHashExpirePrefix...)
+	// #nosec G115 - 过期时间为非负的 Unix 毫秒时间戳
+	k = binary.BigEndian.AppendUint64(k, uint64(at))
+	// #nosec G115 - 键长受值大小上限约束
+	k = binary.BigEndian.AppendUint32(k, uint32(len(key)))
+	k = append(k, key...)
+	return append(k, field...)
+}
+
+// parseHashExpireIndexKey 解析全局过期索引的条目，格式不符时 ok 为 false
+func parseHashExpireIndexKey(k []byte) (at int64, key, field string, ok bool) {
+	rest := k[len(metaHashExpirePrefix):]
+	if len(rest) < 12 {
+		return 0, "", "", false
+	}
+	// #nosec G115 - 写入时为非负的 int64
+	at = int64(binary.BigEndian.Uint64(rest))
+	n := int(binary.BigEndian.Uint32(rest[8:]))
+	if len(rest) < 12+n {
+		return 0, "", "", false
+	}
+	return at, string(rest[12 : 12+n]), string(rest[12+n:]), true
+}
+
+// loadHashFieldTTLs 打开时检查是否存在字段过期索引，没有时不需要检查字段是否过期
+func (s *BotreonStore) loadHashFieldTTLs() error {
+	return s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(metaHashExpirePrefix)
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+		iter.Rewind()
+		s.hashFieldTTLs.Store(iter.Valid())
+		return nil
+	})
+}
+
+// hashFieldExpiresAtTxn 字段的过期时间（Unix 毫秒），没有时为 0
+func hashFieldExpiresAtTxn(txn *storeTxn, key, field string) (int64, error) {
+	item, err := txn.Get(hashTTLKey(key, field))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, err
+	}
+	// #nosec G115 - 写入时为非负的 int64
+	return int64(helper.BytesToUint64(val)), nil
+}
+
+// clearHashFieldTTLTxn HSET 覆盖或 HDEL 删除字段时清除字段的过期时间，全局索引中的条目之后作为过时条目删除
+func (s *BotreonStore) clearHashFieldTTLTxn(txn *storeTxn, key, field string) error {
+	if !s.hashFieldTTLs.Load() {
+		return nil
+	}
+	return txn.Delete(hashTTLKey(key, field))
+}
+
+// hashFieldsTxn 读取哈希类型的键并返回字段计数；键不存在时 exists 为 false，不是哈希时返回 ErrHashWrongType
+func (s *BotreonStore) hashFieldsTxn(txn *storeTxn, key string) (count uint64, exists bool, err error) {
+	keyType, err := keyTypeTxn(txn, key)
+	if err != nil || keyType == "" {
+		return 0, false, err
+	}
+	if keyType != KeyTypeHash {
+		return 0, false, ErrHashWrongType
+	}
+	item, err := txn.Get(s.hashCountKey(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, true, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, false, err
+	}
+	return helper.BytesToUint64(val), true, nil
+}
+
+// deleteHashFieldsTxn 删除已确认存在的字段及其过期时间记录并更新计数，字段全部删除时删除整个键
+func (s *BotreonStore) deleteHashFieldsTxn(txn *storeTxn, key string, count uint64, fields []string) (bool, error) {
+	for _, field := range fields {
+		if err := txn.Delete(s.hashKey(key, field)); err != nil {
+			return false, err
+		}
+		if err := txn.Delete(hashTTLKey(key, field)); err != nil {
+			return false, err
+		}
+	}
+	count -= min(count, uint64(len(fields)))
+	if count == 0 {
+		return s.delTxn(txn, key)
+	}
+	return false, txn.Set(s.hashCountKey(key), helper.Uint64ToBytes(count))
+}
+
+// HExpireAt 实现 HEXPIRE、HPEXPIRE、HEXPIREAT、HPEXPIREAT：把字段的过期时间设置为 at（Unix 毫秒）。
+// cond 为 NX、XX、GT、LT 或空（没有过期时间的字段按永不过期比较）。每个字段依次返回：
+// -2 键或字段不存在，0 条件不满足，1 已设置，2 过期时间不晚于当前时间、字段已删除
+func (s *BotreonStore) HExpireAt(key string, at int64, cond string, fields ...string) ([]int64, error) {
+	results := make([]int64, len(fields))
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		count, exists, err := s.hashFieldsTxn(txn, key)
+		if err != nil {
+			return err
+		}
+		now := s.now().UnixMilli()
+		var expired []string
+		for i, field := range fields {
+			results[i] = -2
+			if !exists {
+				continue
+			}
+			if _, err := txn.Get(s.hashKey(key, field)); errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			cur, err := hashFieldExpiresAtTxn(txn, key, field)
+			if err != nil {
+				return err
+			}
+			results[i] = 0
+			switch cond {
+			case "NX":
+				if cur != 0 {
+					continue
+				}
+			case "XX":
+				if cur == 0 {
+					continue
+				}
+			case "GT":
+				if cur == 0 || at <= cur {
+					continue
+				}
+			case "LT":
+				if cur != 0 && at >= cur {
+					continue
+				}
+			}
+			if at <= now {
+				// 同一命令中重复的字段之后按不存在处理
+				if err := txn.Delete(s.hashKey(key, field)); err != nil {
+					return err
+				}
+				expired = append(expired, field)
+				results[i] = 2
+				continue
+			}
+			if cur != 0 {
+				if err := txn.Delete(hashExpireIndexKey(cur, key, field)); err != nil {
+					return err
+				}
+			}
+			s.hashFieldTTLs.Store(true)
+			// #nosec G115 - at 在当前时间之后
+			if err := txn.Set(hashTTLKey(key, field), helper.Uint64ToBytes(uint64(at))); err != nil {
+				return err
+			}
+			if err := txn.Set(hashExpireIndexKey(at, key, field), nil); err != nil {
+				return err
+			}
+			results[i] = 1
+		}
+		if len(expired) > 0 {
+			_, err = s.deleteHashFieldsTxn(txn, key, count, expired)
+		}
+		return err
+	}, 30)
+	return results, err
+}
+
+// HPersist 实现 HPERSIST，移除字段的过期时间。每个字段返回：-2 键或字段不存在，-1 没有过期时间，1 已移除
+func (s *BotreonStore) HPersist(key string, fields ...string) ([]int64, error) {
+	results := make([]int64, len(fields))
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		_, exists, err := s.hashFieldsTxn(txn, key)
+		if err != nil {
+			return err
+		}
+		for i, field := range fields {
+			results[i] = -2
+			if !exists {
+				continue
+			}
+			if _, err := txn.Get(s.hashKey(key, field)); errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			cur, err := hashFieldExpiresAtTxn(txn, key, field)
+			if err != nil {
+				return err
+			}
+			results[i] = -1
+			if cur == 0 {
+				continue
+			}
+			if err := txn.Delete(hashTTLKey(key, field)); err != nil {
+				return err
+			}
+			if err := txn.Delete(hashExpireIndexKey(cur, key, field)); err != nil {
+				return err
+			}
+			results[i] = 1
+		}
+		return nil
+	}, 30)
+	return results, err
+}
+
+// HFieldExpireTimes 实现 HTTL、HPTTL、HEXPIRETIME、HPEXPIRETIME，返回字段的过期时间（Unix 毫秒）：
+// -2 键或字段不存在，-1 没有过期时间
+func (s *BotreonStore) HFieldExpireTimes(key string, fields ...string) ([]int64, error) {
+	results := make([]int64, len(fields))
+	err := s.view(func(txn *storeTxn) error {
+		_, exists, err := s.hashFieldsTxn(txn, key)
+		if err != nil {
+			return err
+		}
+		for i, field := range fields {
+			results[i] = -2
+			if !exists {
+				continue
+			}
+			if _, err := txn.Get(s.hashKey(key, field)); errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			at, err := hashFieldExpiresAtTxn(txn, key, field)
+			if err != nil {
+				return err
+			}
+			results[i] = -1
+			if at != 0 {
+				results[i] = at
+			}
+		}
+		return nil
+	})
+	return results, err
+}
+
+// hashExpireDue 全局索引中到期的一个条目
+type hashExpireDue struct {
+	at         int64
+	key, field string
+	indexEntry []byte
+}
+
+// expireHashFieldsTxn 删除 key 中到期的字段（due 来自全局索引，已按 key 分组），返回删除的字段和是否删除了整个键。
+// 字段的记录与索引条目不一致（字段已被覆盖、删除或重新设置过期时间）时只删除索引条目
+func (s *BotreonStore) expireHashFieldsTxn(txn *storeTxn, key string, due []hashExpireDue) ([]string, bool, error) {
+	var fields []string
+	for _, d := range due {
+		if err := txn.Delete(d.indexEntry); err != nil {
+			return nil, false, err
+		}
+		cur, err := hashFieldExpiresAtTxn(txn, key, d.field)
+		if err != nil {
+			return nil, false, err
+		}
+		if cur != d.at {
+			continue
+		}
+		if _, err := txn.Get(s.hashKey(key, d.field)); errors.Is(err, badger.ErrKeyNotFound) {
+			if err := txn.Delete(hashTTLKey(key, d.field)); err != nil {
+				return nil, false, err
+			}
+			continue
+		} else if err != nil {
+			return nil, false, err
+		}
+		fields = append(fields, d.field)
+	}
+	if len(fields) == 0 {
+		return nil, false, nil
+	}
+	count, exists, err := s.hashFieldsTxn(txn, key)
+	if errors.Is(err, ErrHashWrongType) {
+		return nil, false, nil
+	}
+	if err != nil || !exists {
+		return nil, false, err
+	}
+	deleted, err := s.deleteHashFieldsTxn(txn, key, count, fields)
+	return fields, deleted, err
+}
+
+// expireHashFieldGroups 按键分组删除到期的字段，每个键一个事务
+func (s *BotreonStore) expireHashFieldGroups(due []hashExpireDue) ([]HashFieldExpiry, error) {
+	var keys []string
+	groups := make(map[string][]hashExpireDue)
+	for _, d := range due {
+		if _, ok := groups[d.key]; !ok {
+			keys = append(keys, d.key)
+		}
+		groups[d.key] = append(groups[d.key], d)
+	}
+	var expired []HashFieldExpiry
+	for _, key := range keys {
+		var fields []string
+		var deleted bool
+		err := s.retryUpdate(func(txn *storeTxn) error {
+			var err error
+			fields, deleted, err = s.expireHashFieldsTxn(txn, key, groups[key])
+			return err
+		}, 30)
+		if err != nil {
+			return expired, err
+		}
+		if len(fields) > 0 {
+			expired = append(expired, HashFieldExpiry{Key: key, Fields: fields, Deleted: deleted})
+			if deleted {
+				s.notifyZWatch(key, nil, nil, true)
+			}
+		}
+	}
+	return expired, nil
+}
+
+// ExpireHashFieldCycle 主动过期哈希字段：按全局索引删除至多 limit 个已到期的条目，返回过期的字段
+func (s *BotreonStore) ExpireHashFieldCycle(limit int) ([]HashFieldExpiry, error) {
+	if !s.hashFieldTTLs.Load() || limit <= 0 {
+		return nil, nil
+	}
+	now := s.now().UnixMilli()
+	var due []hashExpireDue
+	err := s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(metaHashExpirePrefix)
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+		for iter.Rewind(); iter.Valid() && len(due) < limit; iter.Next() {
+			k := iter.Item().KeyCopy(nil)
+			at, key, field, ok := parseHashExpireIndexKey(k)
+			if ok && at > now {
+				break
+			}
+			due = append(due, hashExpireDue{at: at, key: key, field: field, indexEntry: k})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.expire.mu.Lock()
+	defer s.expire.mu.Unlock()
+	expired, err := s.expireHashFieldGroups(due)
+	for _, e := range expired {
+		s.expire.expiredFields += int64(len(e.Fields))
+	}
+	return expired, err
+}
+
+// ExpireHashFields 惰性过期：命令访问的哈希中已到期的字段先删除，返回过期的字段。
+// 没有字段设置过过期时间时直接返回
+func (s *BotreonStore) ExpireHashFields(keys ...string) ([]HashFieldExpiry, error) {
+	if !s.hashFieldTTLs.Load() || len(keys) == 0 {
+		return nil, nil
+	}
+	now := s.now().UnixMilli()
+	var due []hashExpireDue
+	err := s.view(func(txn *storeTxn) error {
+		for _, key := range keys {
+			prefix := hashTTLPrefix(key)
+			iter := txn.NewIterator(s.iteratorOptions(prefix, -1, true))
+			for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+				item := iter.Item()
+				var at int64
+				if err := item.Value(func(val []byte) error {
+					// #nosec G115 - 写入时为非负的 int64
+					at = int64(helper.BytesToUint64(val))
+					return nil
+				}); err != nil {
+					iter.Close()
+					return err
+				}
+				if at > now {
+					continue
+				}
+				// HASHTTL:<key>: 也是以 <key>: 开头的其他键的前缀，以全局索引中的条目确认归属
+				field := string(item.Key()[len(prefix):])
+				entry := hashExpireIndexKey(at, key, field)
+				if _, err := txn.Get(entry); err != nil {
+					continue
+				}
+				due = append(due, hashExpireDue{at: at, key: key, field: field, indexEntry: entry})
+			}
+			iter.Close()
+		}
+		return nil
+	})
+	if err != nil || len(due) == 0 {
+		return nil, err
+	}
+	s.expire.mu.Lock()
+	defer s.expire.mu.Unlock()
+	expired, err := s.expireHashFieldGroups(due)
+	for _, e := range expired {
+		s.expire.expiredFields += int64(len(e.Fields))
+	}
+	return expired, err
+}
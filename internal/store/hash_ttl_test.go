@@ -0,0 +1,117 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestHashFieldExpire(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	clock := NewManualClock(time.UnixMilli(1_700_000_000_000))
+	store.SetClock(clock)
+	now := clock.Now().UnixMilli()
+
+	assert.NoError(t, store.HMSet("h", map[string]interface{}{"a": "1", "b": "2", "c": "3"}))
+	results, err := store.HExpireAt("h", now+10_000, "", "a", "b", "missing")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 1, -2}, results)
+	results, err = store.HExpireAt("nokey", now+10_000, "", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{-2}, results)
+
+	// NX 只对没有过期时间的字段生效，GT 把没有过期时间视为永不过期
+	results, err = store.HExpireAt("h", now+20_000, "NX", "a", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{0, 1}, results)
+	results, err = store.HExpireAt("h", now+30_000, "GT", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1}, results)
+	results, err = store.HExpireAt("h", now+5_000, "XX", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1}, results)
+
+	times, err := store.HFieldExpireTimes("h", "a", "b", "c", "missing")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{now + 10_000, now + 5_000, now + 20_000, -2}, times)
+
+	// HINCRBY 保留过期时间，HSET 覆盖时清除
+	_, err = store.HIncrBy("h", "c", 1)
+	assert.NoError(t, err)
+	assert.NoError(t, store.HSet("h", "a", "x"))
+	times, err = store.HFieldExpireTimes("h", "a", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{-1, now + 20_000}, times)
+
+	results, err = store.HPersist("h", "a", "c", "missing")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{-1, 1, -2}, results)
+
+	// 只有 b 到期：a 的过期时间已被 HSET 清除，其索引条目过时
+	clock.Advance(15 * time.Second)
+	expired, err := store.ExpireHashFieldCycle(100)
+	assert.NoError(t, err)
+	assert.Equal(t, []HashFieldExpiry{{Key: "h", Fields: []string{"b"}}}, expired)
+	n, err := store.HLen("h")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), n)
+	assert.Equal(t, int64(1), store.ExpireStats().ExpiredFields)
+
+	// 过期时间不晚于当前时间时直接删除字段，最后的字段删除后键不存在
+	results, err = store.HExpireAt("h", clock.Now().UnixMilli(), "", "a", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 2}, results)
+	typ, err := store.Type("h")
+	assert.NoError(t, err)
+	assert.Equal(t, "none", typ)
+
+	assert.NoError(t, store.Set("s", "v"))
+	_, err = store.HExpireAt("s", now, "", "a")
+	assert.True(t, errors.Is(err, ErrHashWrongType))
+}
+
+func TestHashFieldExpireLazyAndRename(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	clock := NewManualClock(time.UnixMilli(1_700_000_000_000))
+	store.SetClock(clock)
+	now := clock.Now().UnixMilli()
+
+	assert.NoError(t, store.HMSet("h", map[string]interface{}{"a": "1", "b": "2"}))
+	_, err = store.HExpireAt("h", now+1_000, "", "a")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Rename("h", "h2"))
+	times, err := store.HFieldExpireTimes("h2", "a", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{now + 1_000, -1}, times)
+
+	clock.Advance(2 * time.Second)
+	expired, err := store.ExpireHashFields("h", "h2")
+	assert.NoError(t, err)
+	assert.Equal(t, []HashFieldExpiry{{Key: "h2", Fields: []string{"a"}}}, expired)
+	fields, err := store.HKeys("h2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, fields)
+
+	// 重新设置的过期时间以最后一次为准，旧的索引条目不会删除字段
+	_, err = store.HExpireAt("h2", clock.Now().UnixMilli()+1_000, "", "b")
+	assert.NoError(t, err)
+	_, err = store.HExpireAt("h2", clock.Now().UnixMilli()+60_000, "", "b")
+	assert.NoError(t, err)
+	clock.Advance(2 * time.Second)
+	expired, err = store.ExpireHashFieldCycle(100)
+	assert.NoError(t, err)
+	assert.Equal(t, len(expired), 0)
+	clock.Advance(time.Minute)
+	expired, err = store.ExpireHashFields("h2")
+	assert.NoError(t, err)
+	assert.Equal(t, []HashFieldExpiry{{Key: "h2", Fields: []string{"b"}, Deleted: true}}, expired)
+	exists, err := store.Exists("h2")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
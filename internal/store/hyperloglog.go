@@ -1,428 +1,283 @@
 package store
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
-	"hash/fnv"
 	"math"
 
 	"github.com/dgraph-io/badger/v4"
 )
 
-// HyperLogLog 实现（基于 14 位寄存器的稀疏编码优化）
+// HyperLogLog 与 Redis 使用相同的参数与算法：2^14 个寄存器，元素用 MurmurHash64A 散列，
+// 低 14 位选择寄存器，其余位中从低位起连续 0 的个数加 1 作为寄存器的候选值（最大 51），
+// 基数用 Redis 的改进估计算法（Ertl）计算，因此小基数时的结果与 Redis 一致。
+// 寄存器以密集表示保存在 hll:<key>：首字节为编码，之后每个寄存器一字节，共 16KB。
+// 类型键保存 keyTypeHyperLogLog，其他类型的键上执行 PF 命令返回 ErrHyperLogLogWrongType。
+
 const (
-	hllSparseEncoding     = 1
-	hllDenseEncoding      = 2
-	hllRegisterBits       = 14                        // 每个寄存器使用 14 位
-	hllRegisterCount      = 1 << hllRegisterBits      // 16384 个寄存器
-	hllRegisterMask       = hllRegisterCount - 1      // 0x3FFF
-	hllCachedBytesChanged = 1024                      // 稀疏编码阈值
-	hllP                 = 1 << (hllRegisterBits - 2) // 2^12 = 4096，用于偏差校正
-	hllAlpha             = 0.721347520444481703739965215 // 调和平均数常数
+	hllDenseEncoding = 2
+	hllRegisterBits  = 14
+	hllRegisterCount = 1 << hllRegisterBits
+	hllRegisterMask  = hllRegisterCount - 1
+	hllQ             = 64 - hllRegisterBits    // 散列值中用于计算寄存器值的位数
+	hllAlphaInf      = 0.721347520444481703680 // 寄存器数趋于无穷时的偏差校正常数
+	hllHashSeed      = 0xadc83b19              // 与 Redis 相同的散列种子
+	hllDenseSize     = 1 + hllRegisterCount    // 密集表示的值长度
 )
 
-// HyperLogLog 结构
-type HyperLogLog struct {
-	 registers []uint8 // 每个寄存器 6 位 (存储 count)
-	 encoding  byte    // 0=未初始化, 1=稀疏, 2=密集
-}
-
-// encodeRegister 将 6 位 count 编码到字节
-func encodeRegister(count uint8) byte {
-	return count & 0x3F // 6 位掩码
-}
-
-// decodeRegister 从字节解码 count
-func decodeRegister(b byte) uint8 {
-	return b & 0x3F // 取低 6 位
+// ErrHyperLogLogWrongType PF 命令的键存在但不是 HyperLogLog
+var ErrHyperLogLogWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// hllRegisters 密集表示的寄存器，每个寄存器一字节
+type hllRegisters []uint8
+
+// murmurHash64A Redis 使用的 64 位 MurmurHash2（小端读取）
+func murmurHash64A(data []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+	h := seed ^ (uint64(len(data)) * m)
+	for len(data) >= 8 {
+		k := binary.LittleEndian.Uint64(data)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+		data = data[8:]
+	}
+	if len(data) > 0 {
+		for i := len(data) - 1; i >= 0; i-- {
+			h ^= uint64(data[i]) << (8 * i)
+		}
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
 }
 
-// newHyperLogLog 创建新的 HyperLogLog
-func newHyperLogLog() *HyperLogLog {
-	return &HyperLogLog{
-		encoding: 1, // 稀疏编码
+// hllPosition 返回元素对应的寄存器下标与候选值
+func hllPosition(elem []byte) (int, uint8) {
+	hash := murmurHash64A(elem, hllHashSeed)
+	index := int(hash & hllRegisterMask)
+	hash >>= hllRegisterBits
+	hash |= 1 << hllQ // 保证循环在 hllQ+1 处结束
+	count := uint8(1)
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
 	}
+	return index, count
 }
 
-// Estimate 估计基数
-func (h *HyperLogLog) Estimate() float64 {
-	if h.encoding == 0 {
-		return 0
+// add 添加元素，返回寄存器是否发生了变化
+func (r hllRegisters) add(elem []byte) bool {
+	index, count := hllPosition(elem)
+	if count > r[index] {
+		r[index] = count
+		return true
 	}
+	return false
+}
 
-	sum := 0.0
-	switch h.encoding {
-	case 1: // 稀疏编码
-		// 稀疏编码格式: [offset:14bits][count:6bits]
-		// 这里简化处理，假设每个寄存器初始为 0
-		sum = float64(h.registerCount()) * hllAlpha / float64(1<<hllRegisterBits-hllRegisterCount)
-	case 2: // 密集编码
-		for _, reg := range h.registers {
-			count := decodeRegister(reg)
-			sum += 1.0 / float64(uint64(1) << count)
+// merge 把 other 合并进 r：每个寄存器取两者中的较大值
+func (r hllRegisters) merge(other hllRegisters) {
+	for i, v := range other {
+		if v > r[i] {
+			r[i] = v
 		}
 	}
+}
 
-	estimate := hllP / sum
-	// 线性计数处理小基数情况
-	zeros := h.countZeros()
-	if zeros > 0 {
-		linearCount := float64(hllRegisterCount) * math.Log(float64(hllRegisterCount)/float64(zeros))
-		if estimate < linearCount/2 {
-			return linearCount
+// hllSigma 估计算法中零寄存器部分的修正项
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if prev == z {
+			return z
 		}
 	}
-	return estimate
-}
-
-// registerCount 返回已设置的寄存器数量
-func (h *HyperLogLog) registerCount() int {
-	return hllRegisterCount
 }
 
-// countZeros 计算零寄存器数量（用于线性计数）
-func (h *HyperLogLog) countZeros() int {
-	zeros := 0
-	for _, reg := range h.registers {
-		if decodeRegister(reg) == 0 {
-			zeros++
+// hllTau 估计算法中饱和寄存器部分的修正项
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= (1 - x) * (1 - x) * y
+		if prev == z {
+			return z / 3
 		}
 	}
-	return zeros
 }
 
-// add 添加元素，返回是否发生了变化
-func (h *HyperLogLog) add(data []byte) bool {
-	// 初始化寄存器（如果尚未初始化）
-	if len(h.registers) == 0 {
-		h.encoding = 2 // 使用密集编码
-		h.registers = make([]byte, hllRegisterCount)
-	}
-
-	// 计算哈希值
-	hashVal := hashData(data)
-
-	// 使用前 14 位作为寄存器索引
-	registerIdx := hashVal & hllRegisterMask
-
-	// 计算尾随零的数量 + 1
-	tz := countTrailingZeros(hashVal >> hllRegisterBits)
-	count := tz + 1
-	if count > 63 {
-		count = 63 // 最大值
+// estimate 按寄存器值的分布估计基数
+func (r hllRegisters) estimate() int64 {
+	var histogram [hllQ + 2]int
+	for _, v := range r {
+		histogram[min(int(v), hllQ+1)]++
 	}
-
-	// 更新寄存器
-	oldCount := decodeRegister(h.registers[registerIdx])
-	if count > int(oldCount) {
-		h.registers[registerIdx] = encodeRegister(uint8(count))
-		return true
+	m := float64(hllRegisterCount)
+	z := m * hllTau((m-float64(histogram[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
 	}
-	return false
+	z += m * hllSigma(float64(histogram[0])/m)
+	return int64(math.Round(hllAlphaInf * m * m / z))
 }
 
-// count 返回精确计数（用于小基数优化）
-func (h *HyperLogLog) count() int {
-	count := 0
-	for _, reg := range h.registers {
-		if decodeRegister(reg) > 0 {
-			count++
-		}
-	}
-	return count
+// hllKey HyperLogLog 寄存器的存储键
+func hllKey(key string) []byte {
+	return []byte(fmt.Sprintf("hll:%s", key))
 }
 
-// hashData 计算数据的哈希值
-func hashData(data []byte) uint64 {
-	h := fnv.New64a()
-	h.Write(data)
-	return h.Sum64()
+// hllValue HyperLogLog 键的当前值
+type hllValue struct {
+	regs      hllRegisters
+	expiresAt uint64 // 值键的过期时间，0 表示不过期
+	exists    bool
 }
 
-// countTrailingZeros 计算尾随零的数量
-func countTrailingZeros(x uint64) int {
-	if x == 0 {
-		return 64
+// hllTxn 读取 HyperLogLog，键不存在时返回全零的寄存器；键存在但不是 HyperLogLog 时返回 ErrHyperLogLogWrongType
+func hllTxn(txn *storeTxn, key string) (hllValue, error) {
+	v := hllValue{regs: make(hllRegisters, hllRegisterCount)}
+	typeItem, err := txn.Get(TypeOfKeyGet(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return v, nil
 	}
-	n := 0
-	for (x & 1) == 0 {
-		n++
-		x >>= 1
+	if err != nil {
+		return v, err
 	}
-	return n
-}
-
-// merge 合并另一个 HyperLogLog
-func (h *HyperLogLog) merge(other *HyperLogLog) bool {
-	changed := false
-	if h.encoding != other.encoding {
-		// 确保编码一致
-		if other.encoding == 0 {
-			return false
-		}
-		// 转换为密集编码
-		h.encoding = 2
-		h.registers = make([]byte, hllRegisterCount)
+	keyType, err := typeItem.ValueCopy(nil)
+	if err != nil {
+		return v, err
 	}
-
-	for i := 0; i < hllRegisterCount; i++ {
-		otherCount := decodeRegister(other.registers[i])
-		hCount := decodeRegister(h.registers[i])
-		if otherCount > hCount {
-			h.registers[i] = encodeRegister(otherCount)
-			changed = true
-		}
+	if string(keyType) != keyTypeHyperLogLog {
+		return v, ErrHyperLogLogWrongType
 	}
-	return changed
-}
-
-// PFAdd 实现 Redis PFADD 命令
-func (s *BotreonStore) PFAdd(key string, elements ...string) (int64, error) {
-	var changed int64
-
-	err := s.db.Update(func(txn *badger.Txn) error {
-		// 设置类型
-		typeKey := TypeOfKeyGet(key)
-		if err := txn.Set(typeKey, []byte("hyperloglog")); err != nil {
-			return err
-		}
-
-		// 获取或创建 HyperLogLog
-		hll, err := s.getOrCreateHLL(txn, key)
-		if err != nil {
-			return err
-		}
-
-		// 添加元素
-		for _, elem := range elements {
-			if hll.add([]byte(elem)) {
-				changed = 1
-			}
+	v.exists = true
+	item, err := txn.Get(hllKey(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return v, nil
+	}
+	if err != nil {
+		return v, err
+	}
+	v.expiresAt = item.ExpiresAt()
+	err = item.Value(func(val []byte) error {
+		// 旧版本写入的稀疏值不含寄存器，按全零处理
+		if len(val) == hllDenseSize && val[0] == hllDenseEncoding {
+			copy(v.regs, val[1:])
 		}
-
-		// 保存
-		return s.saveHLL(txn, key, hll)
+		return nil
 	})
-
-	return changed, err
+	return v, err
 }
 
-// getOrCreateHLL 获取或创建 HyperLogLog
-func (s *BotreonStore) getOrCreateHLL(txn *badger.Txn, key string) (*HyperLogLog, error) {
-	hllKey := []byte(fmt.Sprintf("hll:%s", key))
-
-	var hll *HyperLogLog
-	item, err := txn.Get(hllKey)
-	if err == nil {
-		// 存在，读取
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return nil, err
-		}
-		hll = &HyperLogLog{
-			encoding: val[0],
-			registers: val[1:],
-		}
-	} else if err != badger.ErrKeyNotFound {
-		return nil, err
-	} else {
-		// 不存在，创建新的
-		hll = newHyperLogLog()
+// putHLLTxn 写回 HyperLogLog，expiresAt 不为 0 时保留原有的过期时间
+func putHLLTxn(txn *storeTxn, key string, regs hllRegisters, expiresAt uint64) error {
+	if err := txn.Set(TypeOfKeyGet(key), []byte(keyTypeHyperLogLog)); err != nil {
+		return err
 	}
-
-	return hll, nil
-}
-
-// saveHLL 保存 HyperLogLog
-func (s *BotreonStore) saveHLL(txn *badger.Txn, key string, hll *HyperLogLog) error {
-	hllKey := []byte(fmt.Sprintf("hll:%s", key))
-	data := make([]byte, 1+len(hll.registers))
-	data[0] = hll.encoding
-	copy(data[1:], hll.registers)
-	return txn.Set(hllKey, data)
+	data := make([]byte, hllDenseSize)
+	data[0] = hllDenseEncoding
+	copy(data[1:], regs)
+	e := badger.NewEntry(hllKey(key), data)
+	e.ExpiresAt = expiresAt
+	return txn.SetEntry(e)
 }
 
-// PFCount 实现 Redis PFCOUNT 命令
-func (s *BotreonStore) PFCount(keys ...string) (int64, error) {
-	if len(keys) == 1 {
-		return s.pfCountOne(keys[0])
-	}
-	return s.pfCountMultiple(keys)
-}
-
-// pfCountOne 计算单个 key 的基数
-func (s *BotreonStore) pfCountOne(key string) (int64, error) {
-	var estimate float64
-
-	err := s.db.View(func(txn *badger.Txn) error {
-		typeKey := TypeOfKeyGet(key)
-		_, err := txn.Get(typeKey)
-		if err == badger.ErrKeyNotFound {
-			estimate = 0
-			return nil
-		}
+// PFAdd 实现 Redis PFADD 命令：寄存器有变化或新建了键时返回 1，否则返回 0
+func (s *BotreonStore) PFAdd(key string, elements ...string) (int64, error) {
+	var changed int64
+	err := s.update(func(txn *storeTxn) error {
+		v, err := hllTxn(txn, key)
 		if err != nil {
 			return err
 		}
-
-		// 读取 HyperLogLog
-		hllKey := []byte(fmt.Sprintf("hll:%s", key))
-		item, err := txn.Get(hllKey)
-		if err == badger.ErrKeyNotFound {
-			estimate = 0
-			return nil
+		if !v.exists {
+			changed = 1
 		}
-		if err != nil {
-			return err
+		for _, elem := range elements {
+			if v.regs.add([]byte(elem)) {
+				changed = 1
+			}
 		}
-
-		val, _ := item.ValueCopy(nil)
-		if len(val) == 0 {
-			estimate = 0
+		if changed == 0 {
 			return nil
 		}
-
-		hll := &HyperLogLog{
-			encoding: val[0],
-			registers: val[1:],
-		}
-		estimate = hll.Estimate()
-		return nil
+		return putHLLTxn(txn, key, v.regs, v.expiresAt)
 	})
-
-	// 四舍五入到整数
-	return int64(math.Round(estimate)), err
+	return changed, err
 }
 
-// pfCountMultiple 计算多个 key 的基数（合并后计算）
-func (s *BotreonStore) pfCountMultiple(keys []string) (int64, error) {
-	if len(keys) == 0 {
-		return 0, nil
-	}
-
-	var merged *HyperLogLog
-
-	err := s.db.View(func(txn *badger.Txn) error {
+// PFCount 实现 Redis PFCOUNT 命令，多个键时返回合并后的基数，不存在的键按空集处理
+func (s *BotreonStore) PFCount(keys ...string) (int64, error) {
+	var count int64
+	err := s.view(func(txn *storeTxn) error {
+		merged := make(hllRegisters, hllRegisterCount)
 		for _, key := range keys {
-			// 检查键是否存在
-			typeKey := TypeOfKeyGet(key)
-			_, err := txn.Get(typeKey)
-			if err == badger.ErrKeyNotFound {
-				continue
-			}
-			if err != nil {
-				return err
-			}
-
-			// 读取 HyperLogLog
-			hllKey := []byte(fmt.Sprintf("hll:%s", key))
-			item, err := txn.Get(hllKey)
-			if err == badger.ErrKeyNotFound {
-				continue
-			}
+			v, err := hllTxn(txn, key)
 			if err != nil {
 				return err
 			}
-
-			val, _ := item.ValueCopy(nil)
-			if len(val) == 0 {
-				continue
-			}
-
-			other := &HyperLogLog{
-				encoding: val[0],
-				registers: val[1:],
-			}
-
-			if merged == nil {
-				merged = other
-			} else {
-				merged.merge(other)
-			}
+			merged.merge(v.regs)
 		}
+		count = merged.estimate()
 		return nil
 	})
-
-	if err != nil {
-		return 0, err
-	}
-
-	if merged == nil {
-		return 0, nil
-	}
-
-	estimate := merged.Estimate()
-	return int64(math.Round(estimate)), nil
+	return count, err
 }
 
-// PFMerge 实现 Redis PFMERGE 命令
+// PFMerge 实现 Redis PFMERGE 命令：把源键合并进目标键（包括目标键原有的元素），不存在的源键被忽略
 func (s *BotreonStore) PFMerge(destKey string, sourceKeys ...string) error {
-	return s.db.Update(func(txn *badger.Txn) error {
-		// 设置目标键类型
-		typeKey := TypeOfKeyGet(destKey)
-		if err := txn.Set(typeKey, []byte("hyperloglog")); err != nil {
-			return err
-		}
-
-		// 获取或创建目标 HyperLogLog
-		destHLL, err := s.getOrCreateHLL(txn, destKey)
+	return s.update(func(txn *storeTxn) error {
+		dest, err := hllTxn(txn, destKey)
 		if err != nil {
 			return err
 		}
-
-		// 合并所有源键
-		for _, sourceKey := range sourceKeys {
-			sourceHLL, err := s.getOrCreateHLL(txn, sourceKey)
+		for _, key := range sourceKeys {
+			v, err := hllTxn(txn, key)
 			if err != nil {
 				return err
 			}
-			destHLL.merge(sourceHLL)
+			dest.regs.merge(v.regs)
 		}
-
-		// 保存目标
-		return s.saveHLL(txn, destKey, destHLL)
+		return putHLLTxn(txn, destKey, dest.regs, dest.expiresAt)
 	})
 }
 
-// PFInfo 实现 Redis PFINFO 命令（可选）
-func (s *BotreonStore) PFInfo(key string) (map[string]int64, error) {
-	info := make(map[string]int64)
-
-	err := s.db.View(func(txn *badger.Txn) error {
-		// 检查键是否存在
-		typeKey := TypeOfKeyGet(key)
-		_, err := txn.Get(typeKey)
-		if err == badger.ErrKeyNotFound {
-			return fmt.Errorf("key does not exist")
-		}
-		if err != nil {
-			return err
-		}
+// HLLInfo PFINFO 返回的信息
+type HLLInfo struct {
+	Encoding string // 存储表示，目前总是 dense
+	Card     int64  // 估计的基数
+	Size     int64  // 存储的字节数
+}
 
-		// 读取 HyperLogLog
-		hllKey := []byte(fmt.Sprintf("hll:%s", key))
-		item, err := txn.Get(hllKey)
-		if err == badger.ErrKeyNotFound {
-			return fmt.Errorf("key does not exist")
-		}
-		if err != nil {
+// PFInfo 实现 PFINFO 命令（扩展命令），键不存在时返回 nil
+func (s *BotreonStore) PFInfo(key string) (*HLLInfo, error) {
+	var info *HLLInfo
+	err := s.view(func(txn *storeTxn) error {
+		v, err := hllTxn(txn, key)
+		if err != nil || !v.exists {
 			return err
 		}
-
-		val, _ := item.ValueCopy(nil)
-		if len(val) == 0 {
-			return nil
-		}
-
-		hll := &HyperLogLog{
-			encoding: val[0],
-			registers: val[1:],
-		}
-
-		info["encoding"] = int64(hll.encoding)
-		info["card"] = int64(math.Round(hll.Estimate()))
-		info["size"] = int64(len(val))
+		info = &HLLInfo{Encoding: "dense", Card: v.regs.estimate(), Size: hllDenseSize}
 		return nil
 	})
-
 	return info, err
 }
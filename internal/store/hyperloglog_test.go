@@ -0,0 +1,146 @@
+package store
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/zeebo/assert"
+)
+
+func TestPFCountAccuracy(t *testing.T) {
+	store := setupStringTest(t)
+	defer store.Close()
+
+	// 小基数时估计值是精确的
+	n, err := store.PFAdd("small", "a", "b", "c", "d", "e", "f", "g")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	count, err := store.PFCount("small")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), count)
+
+	// 标准误差约 0.81%，10 万个元素的误差不应超过 3%
+	elements := make([]string, 0, 1000)
+	for i := 0; i < 100000; i++ {
+		elements = append(elements, "element:"+strconv.Itoa(i))
+		if len(elements) == cap(elements) {
+			_, err := store.PFAdd("large", elements...)
+			assert.NoError(t, err)
+			elements = elements[:0]
+		}
+	}
+	count, err = store.PFCount("large")
+	assert.NoError(t, err)
+	assert.True(t, count > 97000 && count < 103000)
+
+	count, err = store.PFCount("missing")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestPFAddEmptyCreatesKey(t *testing.T) {
+	store := setupStringTest(t)
+	defer store.Close()
+
+	n, err := store.PFAdd("hll")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	n, err = store.PFAdd("hll")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	keyType, err := store.Type("hll")
+	assert.NoError(t, err)
+	assert.Equal(t, "string", keyType)
+}
+
+func TestPFMerge(t *testing.T) {
+	store := setupStringTest(t)
+	defer store.Close()
+
+	_, err := store.PFAdd("h1", "a", "b", "c")
+	assert.NoError(t, err)
+	_, err = store.PFAdd("h2", "c", "d")
+	assert.NoError(t, err)
+	_, err = store.PFAdd("dest", "z")
+	assert.NoError(t, err)
+
+	// 目标键原有的元素保留，不存在的源键被忽略
+	assert.NoError(t, store.PFMerge("dest", "h1", "missing", "h2"))
+	count, err := store.PFCount("dest")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), count)
+
+	count, err = store.PFCount("h1", "h2", "missing")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+
+	// 只有目标键时创建空的 HyperLogLog
+	assert.NoError(t, store.PFMerge("empty"))
+	info, err := store.PFInfo("empty")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), info.Card)
+}
+
+func TestHyperLogLogWrongType(t *testing.T) {
+	store := setupStringTest(t)
+	defer store.Close()
+
+	_, err := store.LPush("list", "a")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Set("str", "v"))
+	_, err = store.PFAdd("hll", "a")
+	assert.NoError(t, err)
+
+	_, err = store.PFAdd("list", "a")
+	assert.True(t, errors.Is(err, ErrHyperLogLogWrongType))
+	_, err = store.PFAdd("str", "a")
+	assert.True(t, errors.Is(err, ErrHyperLogLogWrongType))
+	_, err = store.PFCount("hll", "list")
+	assert.True(t, errors.Is(err, ErrHyperLogLogWrongType))
+	assert.True(t, errors.Is(store.PFMerge("hll", "str"), ErrHyperLogLogWrongType))
+
+	// 类型没有被覆盖
+	keyType, err := store.Type("list")
+	assert.NoError(t, err)
+	assert.Equal(t, "list", keyType)
+}
+
+func TestHyperLogLogKeyspace(t *testing.T) {
+	store := setupStringTest(t)
+	defer store.Close()
+
+	_, err := store.PFAdd("hll", "a", "b", "c")
+	assert.NoError(t, err)
+
+	// 过期时间在 PFADD、PFMERGE 与 RENAME 后保留
+	ok, err := store.Expire("hll", 3600)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	_, err = store.PFAdd("hll", "d")
+	assert.NoError(t, err)
+	assert.NoError(t, store.PFMerge("hll", "hll"))
+	assert.NoError(t, store.Rename("hll", "renamed"))
+	ttl, err := store.TTL("renamed")
+	assert.NoError(t, err)
+	assert.True(t, ttl > 3500)
+	count, err := store.PFCount("renamed")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+
+	// DEL 同时删除寄存器，重新添加时从空集开始
+	deleted, err := store.Del("renamed")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	err = store.view(func(txn *storeTxn) error {
+		_, err := txn.Get(hllKey("renamed"))
+		return err
+	})
+	assert.True(t, errors.Is(err, badger.ErrKeyNotFound))
+	_, err = store.PFAdd("renamed", "x")
+	assert.NoError(t, err)
+	count, err = store.PFCount("renamed")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
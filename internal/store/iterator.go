@@ -0,0 +1,81 @@
+package store
+
+import (
+	"math"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// IteratorTuning 迭代器预取参数
+//
+// Badger 迭代器在 PrefetchValues 开启时会在后台预读 PrefetchSize 条值。
+// 单成员检查或小范围读取预读过多会浪费 value log 读取，而 HGETALL、LRANGE 0 -1
+// 这类大范围读取预读太少又会频繁等待磁盘。这里按命令预期读取的条数选择预取大小。
+type IteratorTuning struct {
+	RangePrefetchSize   int   // 普通范围读取的最大预取条数
+	LargePrefetchSize   int   // 大范围读取的预取条数
+	LargeRangeThreshold int64 // 预期读取条数达到该值时视为大范围读取
+}
+
+// DefaultIteratorTuning 默认的自适应预取参数
+var DefaultIteratorTuning = IteratorTuning{
+	RangePrefetchSize:   100,
+	LargePrefetchSize:   1000,
+	LargeRangeThreshold: 1000,
+}
+
+// BadgerIteratorTuning 与 badger.DefaultIteratorOptions 等价的固定预取参数（不做自适应，用于基准对比）
+var BadgerIteratorTuning = IteratorTuning{
+	RangePrefetchSize:   100,
+	LargePrefetchSize:   100,
+	LargeRangeThreshold: math.MaxInt64,
+}
+
+// SetIteratorTuning 设置迭代器预取参数，字段为 0 时使用默认值
+func (s *BotreonStore) SetIteratorTuning(t IteratorTuning) {
+	if t.RangePrefetchSize <= 0 {
+		t.RangePrefetchSize = DefaultIteratorTuning.RangePrefetchSize
+	}
+	if t.LargePrefetchSize <= 0 {
+		t.LargePrefetchSize = DefaultIteratorTuning.LargePrefetchSize
+	}
+	if t.LargeRangeThreshold <= 0 {
+		t.LargeRangeThreshold = DefaultIteratorTuning.LargeRangeThreshold
+	}
+	s.iterTuning.Store(&t)
+}
+
+// IteratorTuning 返回当前的迭代器预取参数
+func (s *BotreonStore) IteratorTuning() IteratorTuning {
+	if t := s.iterTuning.Load(); t != nil {
+		return *t
+	}
+	return DefaultIteratorTuning
+}
+
+// isLargeRange 预期读取条数是否达到大范围阈值，expected < 0 表示读取全部
+func (s *BotreonStore) isLargeRange(expected int64) bool {
+	return expected < 0 || expected >= s.IteratorTuning().LargeRangeThreshold
+}
+
+// iteratorOptions 根据预期读取的条数生成迭代器配置
+// expected < 0 表示读取前缀下的全部数据；withValues 为 false 时只遍历键
+func (s *BotreonStore) iteratorOptions(prefix []byte, expected int64, withValues bool) badger.IteratorOptions {
+	t := s.IteratorTuning()
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	opts.PrefetchValues = withValues
+	switch {
+	case !withValues:
+		// 只读键时不预取值
+	case expected >= 0 && expected <= 1:
+		// 单成员检查：预取没有意义
+		opts.PrefetchValues = false
+	case s.isLargeRange(expected):
+		opts.PrefetchSize = t.LargePrefetchSize
+	default:
+		// 小范围读取：预取条数不超过实际需要
+		opts.PrefetchSize = int(min(expected, int64(t.RangePrefetchSize)))
+	}
+	return opts
+}
@@ -0,0 +1,176 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestIteratorOptions(t *testing.T) {
+	dbPath := t.TempDir()
+	store, _ := NewBadgerStore(dbPath)
+	defer store.Close()
+
+	prefix := []byte("HASH:h:")
+
+	// 单成员检查不预取值
+	opts := store.iteratorOptions(prefix, 1, true)
+	assert.False(t, opts.PrefetchValues)
+
+	// 小范围只预取需要的条数
+	opts = store.iteratorOptions(prefix, 10, true)
+	assert.True(t, opts.PrefetchValues)
+	assert.Equal(t, 10, opts.PrefetchSize)
+
+	// 全量读取使用大范围预取
+	opts = store.iteratorOptions(prefix, -1, true)
+	assert.Equal(t, DefaultIteratorTuning.LargePrefetchSize, opts.PrefetchSize)
+	assert.Equal(t, string(prefix), string(opts.Prefix))
+
+	// 只遍历键时不预取值
+	opts = store.iteratorOptions(prefix, -1, false)
+	assert.False(t, opts.PrefetchValues)
+
+	// 自定义参数，零值字段使用默认值
+	store.SetIteratorTuning(IteratorTuning{LargePrefetchSize: 5000})
+	assert.Equal(t, IteratorTuning{
+		RangePrefetchSize:   DefaultIteratorTuning.RangePrefetchSize,
+		LargePrefetchSize:   5000,
+		LargeRangeThreshold: DefaultIteratorTuning.LargeRangeThreshold,
+	}, store.IteratorTuning())
+}
+
+func TestLRangeScanPath(t *testing.T) {
+	dbPath := t.TempDir()
+	store, _ := NewBadgerStore(dbPath)
+	defer store.Close()
+
+	_, err := store.RPush("list", "c", "d", "e")
+	assert.NoError(t, err)
+	_, err = store.LPush("list", "b", "a")
+	assert.NoError(t, err)
+	_, err = store.RPush("list", "f")
+	assert.NoError(t, err)
+	// 前缀相同的另一个列表不应混入结果
+	_, err = store.RPush("list:other", "x", "y")
+	assert.NoError(t, err)
+
+	// 逐节点读取的结果
+	store.SetIteratorTuning(BadgerIteratorTuning)
+	walked, err := store.LRange("list", 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e", "f"}, walked)
+
+	// 阈值调低后走前缀扫描，结果一致
+	store.SetIteratorTuning(IteratorTuning{LargeRangeThreshold: 2})
+	scanned, err := store.LRange("list", 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, walked, scanned)
+
+	scanned, err = store.LRange("list", 1, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "c", "d", "e"}, scanned)
+
+	// 范围只覆盖列表的一小部分时仍然逐节点读取
+	small, err := store.LRange("list", 4, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"e", "f"}, small)
+}
+
+// benchCollectionSize 基准测试的集合大小，可通过 BOLTDB_BENCH_SIZE 调整（默认一百万）
+func benchCollectionSize(b *testing.B) int {
+	if v := os.Getenv("BOLTDB_BENCH_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			b.Fatalf("invalid BOLTDB_BENCH_SIZE: %s", v)
+		}
+		return n
+	}
+	return 1000000
+}
+
+// BenchmarkRangeReads 对比 Badger 默认预取参数与自适应预取参数下的大集合范围读取吞吐量
+//
+//	go test ./internal/store -run '^$' -bench RangeReads -benchtime 5x
+func BenchmarkRangeReads(b *testing.B) {
+	size := benchCollectionSize(b)
+	db, err := NewBotreonStore(b.TempDir())
+	if err != nil {
+		b.Fatalf("Failed to create store: %v", err)
+	}
+	defer db.Close()
+
+	const batch = 1000
+	values := make([]string, 0, batch)
+	fields := make(map[string]interface{}, batch)
+	members := make([]ZSetMember, 0, batch)
+	for i := 0; i < size; i++ {
+		v := fmt.Sprintf("value-%08d", i)
+		values = append(values, v)
+		fields[fmt.Sprintf("field-%08d", i)] = v
+		// 相同分数，成员按字典序排列
+		members = append(members, ZSetMember{Member: fmt.Sprintf("member-%08d", i), Score: 0})
+		if len(values) == batch || i == size-1 {
+			if _, err := db.RPush("benchlist", values...); err != nil {
+				b.Fatalf("RPush: %v", err)
+			}
+			if err := db.HMSet("benchhash", fields); err != nil {
+				b.Fatalf("HMSet: %v", err)
+			}
+			if err := db.ZAdd("benchzset", members); err != nil {
+				b.Fatalf("ZAdd: %v", err)
+			}
+			values = values[:0]
+			fields = make(map[string]interface{}, batch)
+			members = members[:0]
+		}
+	}
+
+	tunings := []struct {
+		name   string
+		tuning IteratorTuning
+	}{
+		{"badger-default", BadgerIteratorTuning},
+		{"adaptive", DefaultIteratorTuning},
+	}
+	for _, tc := range tunings {
+		b.Run("LRANGE/"+tc.name, func(b *testing.B) {
+			db.SetIteratorTuning(tc.tuning)
+			for i := 0; i < b.N; i++ {
+				res, err := db.LRange("benchlist", 0, -1)
+				if err != nil || len(res) != size {
+					b.Fatalf("LRange: %d items, %v", len(res), err)
+				}
+			}
+		})
+		b.Run("LRANGE-head/"+tc.name, func(b *testing.B) {
+			db.SetIteratorTuning(tc.tuning)
+			for i := 0; i < b.N; i++ {
+				if _, err := db.LRange("benchlist", 0, 9); err != nil {
+					b.Fatalf("LRange: %v", err)
+				}
+			}
+		})
+		b.Run("ZRANGE/"+tc.name, func(b *testing.B) {
+			db.SetIteratorTuning(tc.tuning)
+			for i := 0; i < b.N; i++ {
+				res, err := db.ZRange("benchzset", 0, -1)
+				if err != nil || len(res) != size {
+					b.Fatalf("ZRange: %d items, %v", len(res), err)
+				}
+			}
+		})
+		b.Run("HGETALL/"+tc.name, func(b *testing.B) {
+			db.SetIteratorTuning(tc.tuning)
+			for i := 0; i < b.N; i++ {
+				res, err := db.HGetAll("benchhash")
+				if err != nil || len(res) != size {
+					b.Fatalf("HGetAll: %d items, %v", len(res), err)
+				}
+			}
+		})
+	}
+}
@@ -4,11 +4,23 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 
 	"github.com/dgraph-io/badger/v4"
 )
 
+// JSON errors, worded as in RedisJSON
+var (
+	ErrJSONNewAtRoot = errors.New("ERR new objects must be created at the root")
+	ErrJSONNotArray  = errors.New("ERR path does not resolve to an array")
+	ErrJSONNotObject = errors.New("ERR path does not resolve to an object")
+	ErrJSONNotNumber = errors.New("ERR value at path is not a number")
+	ErrJSONNotString = errors.New("ERR path does not resolve to a string")
+	ErrJSONNotBool   = errors.New("ERR path does not resolve to a boolean")
+	ErrJSONIndex     = errors.New("ERR index out of bounds")
+)
+
 // JSONValue represents a JSON value stored in the database
 type JSONValue struct {
 	Data interface{}
@@ -25,12 +37,161 @@ func (s *BotreonStore) jsonKey(key string) string {
 	return fmt.Sprintf("%s%s", prefixKeyJSONBytes, key)
 }
 
+// jsonDocTxn reads and parses the document stored at key, returning ErrKeyNotFound if there is none
+func (s *BotreonStore) jsonDocTxn(txn *storeTxn, key string) (interface{}, error) {
+	item, err := txn.Get([]byte(s.jsonKey(key)))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var root interface{}
+	err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &root)
+	})
+	if err != nil {
+		return nil, errors.New("ERR invalid JSON data")
+	}
+	return root, nil
+}
+
+// jsonView parses the document stored at key for reading
+func (s *BotreonStore) jsonView(key string) (interface{}, error) {
+	var root interface{}
+	err := s.view(func(txn *storeTxn) error {
+		var err error
+		root, err = s.jsonDocTxn(txn, key)
+		return err
+	})
+	return root, err
+}
+
+// jsonUpdate parses the document stored at key and passes it to fn; when fn reports
+// a change the document is written back in the same transaction. fn may run more
+// than once on transaction conflicts.
+func (s *BotreonStore) jsonUpdate(key string, fn func(root *interface{}) (bool, error)) error {
+	return s.retryUpdate(func(txn *storeTxn) error {
+		root, err := s.jsonDocTxn(txn, key)
+		if err != nil {
+			return err
+		}
+		changed, err := fn(&root)
+		if err != nil || !changed {
+			return err
+		}
+		data, err := json.Marshal(root)
+		if err != nil {
+			return err
+		}
+		return txn.Set([]byte(s.jsonKey(key)), data)
+	}, 30)
+}
+
+// jsonEachFunc handles one matched value. ok is false when the value does not have
+// the type the command operates on; result may be nil for handled values too.
+type jsonEachFunc func(n *jsonNode) (result interface{}, ok bool, err error)
+
+// jsonEach calls fn for every node and returns the results in match order, with nil
+// for values of the wrong type. A legacy path addresses a single value and returns
+// typeErr when that value has the wrong type, as in RedisJSON.
+func jsonEach(p *jsonPath, nodes []*jsonNode, typeErr error, fn jsonEachFunc) ([]interface{}, bool, error) {
+	results := make([]interface{}, len(nodes))
+	handled := false
+	for i, n := range nodes {
+		result, ok, err := fn(n)
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok && p.legacy {
+			return nil, false, typeErr
+		}
+		results[i] = result
+		handled = handled || ok
+	}
+	return results, handled, nil
+}
+
+// jsonModify calls fn for every value matched by path (see jsonEach) and writes the
+// document back when any value was handled.
+func (s *BotreonStore) jsonModify(key, path string, typeErr error, fn jsonEachFunc) ([]interface{}, error) {
+	if _, err := parseJSONPath(path); err != nil {
+		return nil, err
+	}
+	var results []interface{}
+	err := s.jsonUpdate(key, func(root *interface{}) (bool, error) {
+		p, nodes, err := evalJSONPath(root, path)
+		if err != nil {
+			return false, err
+		}
+		var handled bool
+		results, handled, err = jsonEach(p, nodes, typeErr, fn)
+		return handled, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// jsonRead calls fn for every value matched by path (see jsonEach) without writing
+func (s *BotreonStore) jsonRead(key, path string, typeErr error, fn jsonEachFunc) ([]interface{}, error) {
+	root, err := s.jsonView(key)
+	if err != nil {
+		return nil, err
+	}
+	p, nodes, err := evalJSONPath(&root, path)
+	if err != nil {
+		return nil, err
+	}
+	results, _, err := jsonEach(p, nodes, typeErr, fn)
+	return results, err
+}
+
+// jsonInts converts results holding int64 values, keeping nil entries
+func jsonInts(results []interface{}) []*int64 {
+	out := make([]*int64, len(results))
+	for i, r := range results {
+		if v, ok := r.(int64); ok {
+			out[i] = &v
+		}
+	}
+	return out
+}
+
+// jsonFloats converts results holding float64 values, keeping nil entries
+func jsonFloats(results []interface{}) []*float64 {
+	out := make([]*float64, len(results))
+	for i, r := range results {
+		if v, ok := r.(float64); ok {
+			out[i] = &v
+		}
+	}
+	return out
+}
+
+// jsonStrings converts results holding string values, keeping nil entries
+func jsonStrings(results []interface{}) []*string {
+	out := make([]*string, len(results))
+	for i, r := range results {
+		if v, ok := r.(string); ok {
+			out[i] = &v
+		}
+	}
+	return out
+}
+
 // JSONSet implements JSON.SET command
 // JSON.SET key path value [NX | XX]
+//
+// The root path replaces the whole document. Other paths require an existing
+// document; every matched value is replaced, and a missing last member is added
+// to the objects matched by the rest of the path. Returns "" when nothing was
+// written because of NX/XX or because the path matched nothing.
 func (s *BotreonStore) JSONSet(key, path, value string, nx, xx bool) (string, error) {
-	// Only support root path for now
-	if path != "$" && path != "." {
-		return "", errors.New("ERR path must be '$' or '.'")
+	p, err := parseJSONPath(path)
+	if err != nil {
+		return "", err
 	}
 
 	// Validate JSON first
@@ -39,331 +200,499 @@ func (s *BotreonStore) JSONSet(key, path, value string, nx, xx bool) (string, er
 		return "", errors.New("ERR invalid JSON")
 	}
 
-	// Check if key exists
-	var exists bool
-	err := s.db.View(func(txn *badger.Txn) error {
-		jsonKey := []byte(s.jsonKey(key))
-		_, err := txn.Get(jsonKey)
-		if err == nil {
-			exists = true
-		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+	written := false
+	err = s.retryUpdate(func(txn *storeTxn) error {
+		var err error
+		written, err = s.jsonSetTxn(txn, key, p, newValue, nx, xx)
+		return err
+	}, 30)
+	if err != nil {
+		return "", err
+	}
+	// The root path keeps its historical reply of OK when NX/XX is not met
+	if !written && !p.isRoot() {
+		return "", nil
+	}
+	return "OK", nil
+}
+
+// JSONMSet implements JSON.MSET command
+// JSON.MSET key path value [key path value ...]
+//
+// Every triplet is applied as JSON.SET without options, all in one transaction:
+// either all of them are written or none is.
+func (s *BotreonStore) JSONMSet(keys, paths, values []string) error {
+	compiled := make([]*jsonPath, len(paths))
+	parsed := make([]interface{}, len(values))
+	for i := range keys {
+		p, err := parseJSONPath(paths[i])
+		if err != nil {
 			return err
 		}
+		compiled[i] = p
+		if err := json.Unmarshal([]byte(values[i]), &parsed[i]); err != nil {
+			return errors.New("ERR invalid JSON")
+		}
+	}
+
+	return s.retryUpdate(func(txn *storeTxn) error {
+		for i, key := range keys {
+			if _, err := s.jsonSetTxn(txn, key, compiled[i], parsed[i], false, false); err != nil {
+				return err
+			}
+		}
 		return nil
-	})
-	if err != nil {
-		return "", err
+	}, 30)
+}
+
+// jsonSetTxn applies JSON.SET to key inside txn and reports whether anything was written
+func (s *BotreonStore) jsonSetTxn(txn *storeTxn, key string, p *jsonPath, value interface{}, nx, xx bool) (bool, error) {
+	if p.isRoot() {
+		_, err := txn.Get([]byte(s.jsonKey(key)))
+		exists := err == nil
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return false, err
+		}
+		// Handle NX/XX options
+		if (nx && exists) || (xx && !exists) {
+			return false, nil
+		}
+		return true, s.jsonWriteTxn(txn, key, value)
 	}
 
-	// Handle NX/XX options
-	if nx && exists {
-		return "OK", nil // NX: do not update if exists
+	root, err := s.jsonDocTxn(txn, key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return false, ErrJSONNewAtRoot
+	}
+	if err != nil {
+		return false, err
 	}
-	if xx && !exists {
-		return "OK", nil // XX: do not create if not exists
+	if !jsonSetPath(&root, p, value, nx, xx) {
+		return false, nil
 	}
+	return true, s.jsonWriteTxn(txn, key, root)
+}
 
-	// Store the JSON
-	jsonData, err := json.Marshal(newValue)
+// jsonWriteTxn stores root as the document at key
+func (s *BotreonStore) jsonWriteTxn(txn *storeTxn, key string, root interface{}) error {
+	data, err := json.Marshal(root)
 	if err != nil {
-		return "", err
+		return err
 	}
+	// Set type
+	if err := txn.Set(TypeOfKeyGet(key), []byte(KeyTypeJSON)); err != nil {
+		return err
+	}
+	return txn.Set([]byte(s.jsonKey(key)), data)
+}
 
-	// Clear read cache
-	if s.readCache != nil {
-		s.readCache.Delete(key)
+// JSONMerge implements JSON.MERGE command
+// JSON.MERGE key path value
+//
+// value is applied to every value matched by path as an RFC 7396 merge patch:
+// object members are merged recursively, null removes a member and any other
+// value replaces the target. Objects missing along the patch are created. When
+// path matches nothing, the merged value is added like JSON.SET; a missing key
+// can only be created at the root.
+func (s *BotreonStore) JSONMerge(key, path, value string) error {
+	p, err := parseJSONPath(path)
+	if err != nil {
+		return err
+	}
+	var patch interface{}
+	if err := json.Unmarshal([]byte(value), &patch); err != nil {
+		return errors.New("ERR invalid JSON")
 	}
 
-	err = s.db.Update(func(txn *badger.Txn) error {
-		// Set type
-		if err := txn.Set(TypeOfKeyGet(key), []byte(KeyTypeJSON)); err != nil {
+	return s.retryUpdate(func(txn *storeTxn) error {
+		root, err := s.jsonDocTxn(txn, key)
+		if errors.Is(err, ErrKeyNotFound) {
+			if !p.isRoot() {
+				return ErrJSONNewAtRoot
+			}
+			return s.jsonWriteTxn(txn, key, jsonMergePatch(nil, patch))
+		}
+		if err != nil {
 			return err
 		}
-		// Set JSON value
-		return txn.Set([]byte(s.jsonKey(key)), jsonData)
-	})
 
-	if err != nil {
-		return "", err
+		nodes := p.eval(&root)
+		if p.legacy && len(nodes) > 1 {
+			nodes = nodes[:1]
+		}
+		switch {
+		case len(nodes) == 0:
+			if patch == nil || !jsonSetPath(&root, p, jsonMergePatch(nil, patch), false, false) {
+				return nil
+			}
+		case patch == nil && !p.isRoot():
+			// A null patch removes the matched values from their parents
+			jsonDeleteNodes(nodes)
+		default:
+			for _, n := range nodes {
+				n.set(jsonMergePatch(n.value, patch))
+			}
+		}
+		return s.jsonWriteTxn(txn, key, root)
+	}, 30)
+}
+
+// jsonMergePatch applies patch to target as described in RFC 7396. target is
+// modified in place; patch is left untouched.
+func jsonMergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
 	}
-	return "OK", nil
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = make(map[string]interface{}, len(patchObj))
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(targetObj, k)
+			continue
+		}
+		targetObj[k] = jsonMergePatch(targetObj[k], v)
+	}
+	return targetObj
 }
 
 // JSONGet implements JSON.GET command
 // JSON.GET key [path [path ...]]
-func (s *BotreonStore) JSONGet(key string, paths ...string) ([]string, error) {
+//
+// Without a path the whole document is returned. A JSONPath ("$...") returns a
+// JSON array of all matches and a legacy path returns the single matched value.
+// With several paths the result is an object keyed by path; if any of them is a
+// JSONPath, all of them are evaluated as JSONPaths.
+func (s *BotreonStore) JSONGet(key string, paths ...string) (string, error) {
 	// Default to root path
 	if len(paths) == 0 {
-		paths = []string{"$"}
+		paths = []string{"."}
 	}
 
-	jsonKey := s.jsonKey(key)
-	var jsonData []byte
-
-	err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(jsonKey))
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return ErrKeyNotFound
-		}
+	compiled := make([]*jsonPath, len(paths))
+	legacy := true
+	for i, path := range paths {
+		p, err := parseJSONPath(path)
 		if err != nil {
-			return err
+			return "", err
 		}
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-		jsonData = val
-		return nil
-	})
+		compiled[i] = p
+		legacy = legacy && p.legacy
+	}
+
+	root, err := s.jsonView(key)
 	if err != nil {
-		if errors.Is(err, ErrKeyNotFound) {
-			return nil, ErrKeyNotFound
-		}
-		return nil, err
+		return "", err
 	}
 
-	// Parse JSON
-	var root interface{}
-	if err := json.Unmarshal(jsonData, &root); err != nil {
-		return nil, errors.New("ERR invalid JSON data")
+	if len(paths) == 1 {
+		return jsonPathReply(&root, paths[0], compiled[0], legacy)
 	}
 
-	results := make([]string, 0, len(paths))
-	for _, path := range paths {
-		result, err := getValueByPath(root, path)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, path := range paths {
+		reply, err := jsonPathReply(&root, path, compiled[i], legacy)
 		if err != nil {
-			results = append(results, "")
-			continue
+			return "", err
 		}
-		resultJSON, err := json.Marshal(result)
-		if err != nil {
-			results = append(results, "")
-			continue
+		name, _ := json.Marshal(path)
+		if i > 0 {
+			b.WriteByte(',')
 		}
-		results = append(results, string(resultJSON))
+		b.Write(name)
+		b.WriteByte(':')
+		b.WriteString(reply)
 	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
 
-	return results, nil
+// jsonPathReply encodes the values matched by p: the single value for a legacy
+// path, or an array of all matches otherwise
+func jsonPathReply(root *interface{}, path string, p *jsonPath, legacy bool) (string, error) {
+	nodes := p.eval(root)
+	var result interface{}
+	if legacy {
+		if len(nodes) == 0 {
+			return "", fmt.Errorf("ERR Path '%s' does not exist", path)
+		}
+		result = nodes[0].value
+	} else {
+		values := make([]interface{}, len(nodes))
+		for i, n := range nodes {
+			values[i] = n.value
+		}
+		result = values
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
 }
 
 // JSONDel implements JSON.DEL command
 // JSON.DEL key [path ...]
+//
+// Deleting the root removes the key. Returns the number of values deleted.
 func (s *BotreonStore) JSONDel(key string, paths ...string) (int64, error) {
 	// Default to root path (delete entire key)
 	if len(paths) == 0 {
 		paths = []string{"$"}
 	}
 
-	jsonKey := s.jsonKey(key)
-	var jsonData []byte
-
-	err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(jsonKey))
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return ErrKeyNotFound
-		}
+	compiled := make([]*jsonPath, len(paths))
+	deleteKey := false
+	for i, path := range paths {
+		p, err := parseJSONPath(path)
 		if err != nil {
-			return err
-		}
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-		jsonData = val
-		return nil
-	})
-	if err != nil {
-		if errors.Is(err, ErrKeyNotFound) {
-			return 0, nil
+			return 0, err
 		}
-		return 0, err
+		compiled[i] = p
+		deleteKey = deleteKey || p.isRoot()
 	}
 
-	// Parse JSON
-	var root interface{}
-	if err := json.Unmarshal(jsonData, &root); err != nil {
-		return 0, errors.New("ERR invalid JSON data")
-	}
+	if deleteKey {
 
-	deleted := int64(0)
-
-	// If deleting root, delete the entire key
-	if len(paths) == 1 && (paths[0] == "$" || paths[0] == ".") {
-		// Clear read cache
-		if s.readCache != nil {
-			s.readCache.Delete(key)
-		}
-
-		err = s.db.Update(func(txn *badger.Txn) error {
+		var deleted int64
+		err := s.retryUpdate(func(txn *storeTxn) error {
+			deleted = 0
+			jsonKey := []byte(s.jsonKey(key))
+			_, err := txn.Get(jsonKey)
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
 			if err := txn.Delete(TypeOfKeyGet(key)); err != nil {
 				return err
 			}
-			return txn.Delete([]byte(jsonKey))
-		})
-		if err != nil {
-			return 0, err
+			deleted = 1
+			return txn.Delete(jsonKey)
+		}, 30)
+		return deleted, err
+	}
+
+	var deleted int64
+	err := s.jsonUpdate(key, func(root *interface{}) (bool, error) {
+		deleted = 0
+		for _, p := range compiled {
+			nodes := p.eval(root)
+			if p.legacy && len(nodes) > 1 {
+				nodes = nodes[:1]
+			}
+			deleted += jsonDeleteNodes(nodes)
 		}
-		return 1, nil
+		return deleted > 0, nil
+	})
+	if errors.Is(err, ErrKeyNotFound) {
+		return 0, nil
 	}
-
-	// For now, we only support deleting the entire key
-	// Partial path deletion would require more complex JSON manipulation
-	return deleted, nil
+	return deleted, err
 }
 
 // JSONType implements JSON.TYPE command
 // JSON.TYPE key [path]
+//
+// Returns the type of the first value matched by path, or "" if nothing matches.
 func (s *BotreonStore) JSONType(key string, path string) (string, error) {
 	if path == "" {
 		path = "$"
 	}
 
-	jsonKey := s.jsonKey(key)
-	var jsonData []byte
-
-	err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(jsonKey))
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return ErrKeyNotFound
-		}
-		if err != nil {
-			return err
-		}
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-		jsonData = val
-		return nil
-	})
+	root, err := s.jsonView(key)
 	if err != nil {
-		if errors.Is(err, ErrKeyNotFound) {
-			return "", ErrKeyNotFound
-		}
 		return "", err
 	}
-
-	// Parse JSON
-	var root interface{}
-	if err := json.Unmarshal(jsonData, &root); err != nil {
-		return "", errors.New("ERR invalid JSON data")
+	_, nodes, err := evalJSONPath(&root, path)
+	if errors.Is(err, ErrJSONPathSyntax) {
+		return "", err
 	}
-
-	// Get value at path
-	value, err := getValueByPath(root, path)
-	if err != nil {
+	if err != nil || len(nodes) == 0 {
 		return "", nil
 	}
 
-	return getJSONType(value), nil
+	return getJSONType(nodes[0].value), nil
 }
 
 // JSONMGet implements JSON.MGET command
 // JSON.MGET key [key ...] [path]
+//
+// Keys that do not exist or where the path cannot be evaluated yield "".
 func (s *BotreonStore) JSONMGet(path string, keys ...string) ([]string, error) {
 	results := make([]string, len(keys))
 	for i, key := range keys {
 		result, err := s.JSONGet(key, path)
 		if err != nil {
-			if errors.Is(err, ErrKeyNotFound) {
-				results[i] = ""
-				continue
-			}
 			results[i] = ""
 			continue
 		}
-		if len(result) > 0 {
-			results[i] = result[0]
-		} else {
-			results[i] = ""
-		}
+		results[i] = result
 	}
 	return results, nil
 }
 
 // JSONArrAppend implements JSON.ARRAPPEND command
 // JSON.ARRAPPEND key path value [value ...]
-func (s *BotreonStore) JSONArrAppend(key, path string, values ...string) (int64, error) {
-	if path != "$" && path != "." {
-		return 0, errors.New("ERR path must be '$' or '.'")
-	}
-
-	jsonKey := s.jsonKey(key)
-	var jsonData []byte
-
-	err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(jsonKey))
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return ErrKeyNotFound
-		}
-		if err != nil {
-			return err
-		}
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-		jsonData = val
-		return nil
+//
+// The values are appended to every array matched by path. Returns the new
+// length of each matched array, nil for matches that are not arrays.
+func (s *BotreonStore) JSONArrAppend(key, path string, values ...string) ([]*int64, error) {
+	parsed := parseJSONValues(values)
+
+	results, err := s.jsonModify(key, path, ErrJSONNotArray, func(n *jsonNode) (interface{}, bool, error) {
+		arr, ok := n.value.([]interface{})
+		if !ok {
+			return nil, false, nil
+		}
+		arr = append(arr, parsed...)
+		n.set(arr)
+		return int64(len(arr)), true, nil
 	})
-	if err != nil {
-		if errors.Is(err, ErrKeyNotFound) {
-			return 0, ErrKeyNotFound
-		}
-		return 0, err
-	}
-
-	// Parse JSON into a pointer to interface{} so modifications persist
-	var rootPtr *interface{}
-	if err := json.Unmarshal(jsonData, &rootPtr); err != nil {
-		return 0, errors.New("ERR invalid JSON data")
-	}
-
-	if rootPtr == nil {
-		return 0, errors.New("ERR invalid JSON data")
-	}
-
-	root := *rootPtr
-
-	// Get the array at root
-	arr, ok := root.([]interface{})
-	if !ok {
-		return 0, errors.New("ERR path does not resolve to an array")
-	}
+	return jsonInts(results), err
+}
 
-	// Parse and append new values
+// parseJSONValues parses command arguments as JSON values
+func parseJSONValues(values []string) []interface{} {
+	parsed := make([]interface{}, 0, len(values))
 	for _, valStr := range values {
 		var val interface{}
 		if err := json.Unmarshal([]byte(valStr), &val); err != nil {
 			// Treat as string if not valid JSON
 			val = valStr
 		}
-		// Append to the slice
-		arr = append(arr, val)
+		parsed = append(parsed, val)
 	}
+	return parsed
+}
 
-	// Update the root pointer with the modified array
-	*rootPtr = arr
-
-	// Marshal back
-	newData, err := json.Marshal(rootPtr)
-	if err != nil {
-		return 0, err
-	}
+// JSONArrInsert implements JSON.ARRINSERT command
+// JSON.ARRINSERT key path index value [value ...]
+//
+// The values are inserted before index (negative counts from the end) in every
+// array matched by path. Returns the new length of each matched array, nil for
+// matches that are not arrays.
+func (s *BotreonStore) JSONArrInsert(key, path string, index int, values ...string) ([]*int64, error) {
+	parsed := parseJSONValues(values)
+
+	results, err := s.jsonModify(key, path, ErrJSONNotArray, func(n *jsonNode) (interface{}, bool, error) {
+		arr, ok := n.value.([]interface{})
+		if !ok {
+			return nil, false, nil
+		}
+		i := index
+		if i < 0 {
+			i += len(arr)
+		}
+		if i < 0 || i > len(arr) {
+			return nil, false, ErrJSONIndex
+		}
+		inserted := make([]interface{}, 0, len(arr)+len(parsed))
+		inserted = append(inserted, arr[:i]...)
+		inserted = append(inserted, parsed...)
+		inserted = append(inserted, arr[i:]...)
+		n.set(inserted)
+		return int64(len(inserted)), true, nil
+	})
+	return jsonInts(results), err
+}
 
-	// Clear read cache
-	if s.readCache != nil {
-		s.readCache.Delete(key)
-	}
+// JSONArrPop implements JSON.ARRPOP command
+// JSON.ARRPOP key [path [index]]
+//
+// Removes the element at index (negative counts from the end, out-of-range
+// indexes are clamped) from every array matched by path. Returns the JSON
+// encoding of each removed element, nil for empty arrays and for matches that
+// are not arrays.
+func (s *BotreonStore) JSONArrPop(key, path string, index int) ([]*string, error) {
+	results, err := s.jsonModify(key, path, ErrJSONNotArray, func(n *jsonNode) (interface{}, bool, error) {
+		arr, ok := n.value.([]interface{})
+		if !ok {
+			return nil, false, nil
+		}
+		if len(arr) == 0 {
+			return nil, true, nil
+		}
+		i := index
+		if i < 0 {
+			i += len(arr)
+		}
+		i = max(0, min(i, len(arr)-1))
+		data, err := json.Marshal(arr[i])
+		if err != nil {
+			return nil, false, err
+		}
+		n.set(append(arr[:i:i], arr[i+1:]...))
+		return string(data), true, nil
+	})
+	return jsonStrings(results), err
+}
 
-	err = s.db.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte(jsonKey), newData)
+// JSONArrIndex implements JSON.ARRINDEX command
+// JSON.ARRINDEX key path value [start [stop]]
+//
+// Searches every array matched by path for value within [start, stop)
+// (negative indexes count from the end, stop 0 means the end of the array).
+// Returns the index of the first equal element or -1 for each matched array,
+// nil for matches that are not arrays.
+func (s *BotreonStore) JSONArrIndex(key, path, value string, start, stop int) ([]*int64, error) {
+	var want interface{}
+	if err := json.Unmarshal([]byte(value), &want); err != nil {
+		return nil, errors.New("ERR invalid JSON")
+	}
+
+	results, err := s.jsonRead(key, path, ErrJSONNotArray, func(n *jsonNode) (interface{}, bool, error) {
+		arr, ok := n.value.([]interface{})
+		if !ok {
+			return nil, false, nil
+		}
+		from, to := start, stop
+		if to == 0 {
+			to = len(arr)
+		}
+		from, to = clampJSONIndex(from, len(arr)), clampJSONIndex(to, len(arr))
+		for i := from; i < to; i++ {
+			if reflect.DeepEqual(arr[i], want) {
+				return int64(i), true, nil
+			}
+		}
+		return int64(-1), true, nil
 	})
-	if err != nil {
-		return 0, err
-	}
+	return jsonInts(results), err
+}
 
-	return int64(len(arr)), nil
+// JSONArrTrim implements JSON.ARRTRIM command
+// JSON.ARRTRIM key path start stop
+//
+// Keeps only the elements in the inclusive range [start, stop] of every array
+// matched by path, like LTRIM. Returns the new length of each matched array,
+// nil for matches that are not arrays.
+func (s *BotreonStore) JSONArrTrim(key, path string, start, stop int) ([]*int64, error) {
+	results, err := s.jsonModify(key, path, ErrJSONNotArray, func(n *jsonNode) (interface{}, bool, error) {
+		arr, ok := n.value.([]interface{})
+		if !ok {
+			return nil, false, nil
+		}
+		from, to := start, stop
+		if from < 0 {
+			from += len(arr)
+		}
+		if to < 0 {
+			to += len(arr)
+		}
+		from, to = max(from, 0), min(to, len(arr)-1)
+		trimmed := []interface{}{}
+		if from <= to {
+			trimmed = append(trimmed, arr[from:to+1]...)
+		}
+		n.set(trimmed)
+		return int64(len(trimmed)), true, nil
+	})
+	return jsonInts(results), err
 }
 
 // JSONArrLen implements JSON.ARRLEN command
@@ -373,46 +702,23 @@ func (s *BotreonStore) JSONArrLen(key, path string) (int64, error) {
 		path = "$"
 	}
 
-	jsonKey := s.jsonKey(key)
-	var jsonData []byte
-
-	err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(jsonKey))
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return ErrKeyNotFound
-		}
-		if err != nil {
-			return err
-		}
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-		jsonData = val
-		return nil
-	})
+	root, err := s.jsonView(key)
 	if err != nil {
-		if errors.Is(err, ErrKeyNotFound) {
-			return 0, ErrKeyNotFound
-		}
 		return 0, err
 	}
 
-	// Parse JSON
-	var root interface{}
-	if err := json.Unmarshal(jsonData, &root); err != nil {
-		return 0, errors.New("ERR invalid JSON data")
-	}
-
 	// Get the array at path
-	value, err := getValueByPath(root, path)
-	if err != nil {
+	_, nodes, err := evalJSONPath(&root, path)
+	if errors.Is(err, ErrJSONPathSyntax) {
+		return 0, err
+	}
+	if err != nil || len(nodes) == 0 {
 		return 0, nil
 	}
 
-	arr, ok := value.([]interface{})
+	arr, ok := nodes[0].value.([]interface{})
 	if !ok {
-		return 0, errors.New("ERR path does not resolve to an array")
+		return 0, ErrJSONNotArray
 	}
 
 	return int64(len(arr)), nil
@@ -420,320 +726,164 @@ func (s *BotreonStore) JSONArrLen(key, path string) (int64, error) {
 
 // JSONObjKeys implements JSON.OBJKEYS command
 // JSON.OBJKEYS key [path]
+//
+// Returns the keys of the first object matched by path in sorted order.
 func (s *BotreonStore) JSONObjKeys(key, path string) ([]string, error) {
 	if path == "" {
 		path = "$"
 	}
 
-	jsonKey := s.jsonKey(key)
-	var jsonData []byte
-
-	err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(jsonKey))
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return ErrKeyNotFound
-		}
-		if err != nil {
-			return err
-		}
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-		jsonData = val
-		return nil
-	})
+	root, err := s.jsonView(key)
 	if err != nil {
-		if errors.Is(err, ErrKeyNotFound) {
-			return nil, ErrKeyNotFound
-		}
 		return nil, err
 	}
 
-	// Parse JSON
-	var root interface{}
-	if err := json.Unmarshal(jsonData, &root); err != nil {
-		return nil, errors.New("ERR invalid JSON data")
-	}
-
 	// Get the object at path
-	value, err := getValueByPath(root, path)
-	if err != nil {
+	_, nodes, err := evalJSONPath(&root, path)
+	if errors.Is(err, ErrJSONPathSyntax) {
+		return nil, err
+	}
+	if err != nil || len(nodes) == 0 {
 		return nil, nil
 	}
 
-	obj, ok := value.(map[string]interface{})
+	obj, ok := nodes[0].value.(map[string]interface{})
 	if !ok {
-		return nil, errors.New("ERR path does not resolve to an object")
+		return nil, ErrJSONNotObject
 	}
 
-	keys := make([]string, 0, len(obj))
-	for k := range obj {
-		keys = append(keys, k)
-	}
-	return keys, nil
+	return sortedJSONKeys(obj), nil
 }
 
 // JSONNumIncrBy implements JSON.NUMINCRBY command
 // JSON.NUMINCRBY key path increment
-func (s *BotreonStore) JSONNumIncrBy(key, path string, increment float64) (float64, error) {
-	if path != "$" && path != "." {
-		return 0, errors.New("ERR path must be '$' or '.'")
-	}
-
-	jsonKey := s.jsonKey(key)
-	var jsonData []byte
-
-	err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(jsonKey))
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return ErrKeyNotFound
-		}
-		if err != nil {
-			return err
-		}
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-		jsonData = val
-		return nil
-	})
-	if err != nil {
-		if errors.Is(err, ErrKeyNotFound) {
-			return 0, ErrKeyNotFound
-		}
-		return 0, err
-	}
-
-	// Parse JSON into a pointer to interface{} so modifications persist
-	var rootPtr *interface{}
-	if err := json.Unmarshal(jsonData, &rootPtr); err != nil {
-		return 0, errors.New("ERR invalid JSON data")
-	}
-
-	if rootPtr == nil {
-		return 0, errors.New("ERR invalid JSON data")
-	}
-
-	root := *rootPtr
-
-	// Get the value at root
-	value, err := getValueByPath(root, path)
-	if err != nil {
-		return 0, err
-	}
-
-	// Check if it's a number
-	num, ok := value.(float64)
-	if !ok {
-		// Try int
-		if intVal, ok := value.(int); ok {
-			num = float64(intVal)
-		} else if int64Val, ok := value.(int64); ok {
-			num = float64(int64Val)
-		} else {
-			return 0, errors.New("ERR value at path is not a number")
-		}
-	}
-
-	// Increment
-	num += increment
-
-	// Update root
-	if m, ok := root.(map[string]interface{}); ok {
-		m[""] = num
-	} else if arr, ok := root.([]interface{}); ok {
-		if len(arr) > 0 {
-			arr[0] = num
-		}
-	}
-
-	// Update the root pointer
-	*rootPtr = root
-
-	// Marshal back
-	newData, err := json.Marshal(rootPtr)
-	if err != nil {
-		return 0, err
-	}
-
-	// Clear read cache
-	if s.readCache != nil {
-		s.readCache.Delete(key)
-	}
-
-	err = s.db.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte(jsonKey), newData)
+func (s *BotreonStore) JSONNumIncrBy(key, path string, increment float64) ([]*float64, error) {
+	return s.jsonNumOp(key, path, func(num float64) float64 {
+		return num + increment
 	})
-	if err != nil {
-		return 0, err
-	}
-
-	return num, nil
 }
 
 // JSONNumMultBy implements JSON.NUMMULTBY command
 // JSON.NUMMULTBY key path multiplier
-func (s *BotreonStore) JSONNumMultBy(key, path string, multiplier float64) (float64, error) {
-	if path != "$" && path != "." {
-		return 0, errors.New("ERR path must be '$' or '.'")
-	}
-
-	jsonKey := s.jsonKey(key)
-	var jsonData []byte
-
-	err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(jsonKey))
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return ErrKeyNotFound
-		}
-		if err != nil {
-			return err
-		}
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-		jsonData = val
-		return nil
+func (s *BotreonStore) JSONNumMultBy(key, path string, multiplier float64) ([]*float64, error) {
+	return s.jsonNumOp(key, path, func(num float64) float64 {
+		return num * multiplier
 	})
-	if err != nil {
-		if errors.Is(err, ErrKeyNotFound) {
-			return 0, ErrKeyNotFound
-		}
-		return 0, err
-	}
-
-	// Parse JSON into a pointer to interface{} so modifications persist
-	var rootPtr *interface{}
-	if err := json.Unmarshal(jsonData, &rootPtr); err != nil {
-		return 0, errors.New("ERR invalid JSON data")
-	}
-
-	if rootPtr == nil {
-		return 0, errors.New("ERR invalid JSON data")
-	}
-
-	root := *rootPtr
-
-	// Get the value at root
-	value, err := getValueByPath(root, path)
-	if err != nil {
-		return 0, err
-	}
-
-	// Check if it's a number
-	num, ok := value.(float64)
-	if !ok {
-		// Try int
-		if intVal, ok := value.(int); ok {
-			num = float64(intVal)
-		} else if int64Val, ok := value.(int64); ok {
-			num = float64(int64Val)
-		} else {
-			return 0, errors.New("ERR value at path is not a number")
-		}
-	}
-
-	// Multiply
-	num = num * multiplier
-
-	// Update root
-	if m, ok := root.(map[string]interface{}); ok {
-		m[""] = num
-	} else if arr, ok := root.([]interface{}); ok {
-		if len(arr) > 0 {
-			arr[0] = num
-		}
-	}
-
-	// Update the root pointer
-	*rootPtr = root
+}
 
-	// Marshal back
-	newData, err := json.Marshal(rootPtr)
-	if err != nil {
-		return 0, err
-	}
+// jsonNumOp applies op to every number matched by path and returns the new values,
+// nil for non-numeric matches, which are left unchanged.
+func (s *BotreonStore) jsonNumOp(key, path string, op func(float64) float64) ([]*float64, error) {
+	results, err := s.jsonModify(key, path, ErrJSONNotNumber, func(n *jsonNode) (interface{}, bool, error) {
+		num, ok := n.value.(float64)
+		if !ok {
+			return nil, false, nil
+		}
+		num = op(num)
+		n.set(num)
+		return num, true, nil
+	})
+	return jsonFloats(results), err
+}
 
-	// Clear read cache
-	if s.readCache != nil {
-		s.readCache.Delete(key)
-	}
+// JSONStrAppend implements JSON.STRAPPEND command
+// JSON.STRAPPEND key [path] value
+//
+// value must be a JSON string. It is appended to every string matched by path;
+// returns the new length of each one, nil for matches that are not strings.
+func (s *BotreonStore) JSONStrAppend(key, path, value string) ([]*int64, error) {
+	var suffix string
+	if err := json.Unmarshal([]byte(value), &suffix); err != nil {
+		return nil, errors.New("ERR invalid JSON string")
+	}
+
+	results, err := s.jsonModify(key, path, ErrJSONNotString, func(n *jsonNode) (interface{}, bool, error) {
+		str, ok := n.value.(string)
+		if !ok {
+			return nil, false, nil
+		}
+		str += suffix
+		n.set(str)
+		return int64(len(str)), true, nil
+	})
+	return jsonInts(results), err
+}
 
-	err = s.db.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte(jsonKey), newData)
+// JSONStrLen implements JSON.STRLEN command
+// JSON.STRLEN key [path]
+//
+// Returns the length in bytes of every string matched by path, nil for matches
+// that are not strings.
+func (s *BotreonStore) JSONStrLen(key, path string) ([]*int64, error) {
+	results, err := s.jsonRead(key, path, ErrJSONNotString, func(n *jsonNode) (interface{}, bool, error) {
+		str, ok := n.value.(string)
+		if !ok {
+			return nil, false, nil
+		}
+		return int64(len(str)), true, nil
 	})
-	if err != nil {
-		return 0, err
-	}
+	return jsonInts(results), err
+}
 
-	return num, nil
+// JSONToggle implements JSON.TOGGLE command
+// JSON.TOGGLE key path
+//
+// Flips every boolean matched by path and returns the new values as 1 or 0,
+// nil for matches that are not booleans.
+func (s *BotreonStore) JSONToggle(key, path string) ([]*int64, error) {
+	results, err := s.jsonModify(key, path, ErrJSONNotBool, func(n *jsonNode) (interface{}, bool, error) {
+		b, ok := n.value.(bool)
+		if !ok {
+			return nil, false, nil
+		}
+		n.set(!b)
+		if b {
+			return int64(0), true, nil
+		}
+		return int64(1), true, nil
+	})
+	return jsonInts(results), err
 }
 
 // JSONClear implements JSON.CLEAR command
 // JSON.CLEAR key [path]
+//
+// Arrays and objects matched by path are emptied and numbers are set to 0.
+// Returns the number of values cleared.
 func (s *BotreonStore) JSONClear(key, path string) (int64, error) {
 	if path == "" {
 		path = "$"
 	}
-
-	jsonKey := s.jsonKey(key)
-	var jsonData []byte
-
-	err := s.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(jsonKey))
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return ErrKeyNotFound
-		}
-		if err != nil {
-			return err
-		}
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return err
-		}
-		jsonData = val
-		return nil
-	})
+	p, err := parseJSONPath(path)
 	if err != nil {
-		if errors.Is(err, ErrKeyNotFound) {
-			return 0, ErrKeyNotFound
-		}
 		return 0, err
 	}
 
-	// Parse JSON
-	var root interface{}
-	if err := json.Unmarshal(jsonData, &root); err != nil {
-		return 0, errors.New("ERR invalid JSON data")
-	}
-
-	// For now, clear means empty array or object
-	cleared := int64(0)
-
-	// Marshal back
-	var newData []byte
-	if path == "$" || path == "." {
-		newData = []byte("{}")
-		cleared = 1
-	} else {
-		newData, _ = json.Marshal(root)
-	}
-
-	// Clear read cache
-	if s.readCache != nil {
-		s.readCache.Delete(key)
-	}
-
-	err = s.db.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte(jsonKey), newData)
+	var cleared int64
+	err = s.jsonUpdate(key, func(root *interface{}) (bool, error) {
+		cleared = 0
+		nodes := p.eval(root)
+		if p.legacy && len(nodes) > 1 {
+			nodes = nodes[:1]
+		}
+		for _, n := range nodes {
+			switch n.value.(type) {
+			case map[string]interface{}:
+				n.set(map[string]interface{}{})
+			case []interface{}:
+				n.set([]interface{}{})
+			case float64:
+				n.set(float64(0))
+			default:
+				continue
+			}
+			cleared++
+		}
+		return cleared > 0, nil
 	})
 	if err != nil {
 		return 0, err
 	}
-
 	return cleared, nil
 }
 
@@ -744,7 +894,7 @@ func (s *BotreonStore) JSONDebugMemory(key, _ string) (int64, error) {
 	jsonKey := s.jsonKey(key)
 	var jsonData []byte
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		item, err := txn.Get([]byte(jsonKey))
 		if errors.Is(err, badger.ErrKeyNotFound) {
 			return ErrKeyNotFound
@@ -770,41 +920,6 @@ func (s *BotreonStore) JSONDebugMemory(key, _ string) (int64, error) {
 	return int64(len(jsonData)), nil
 }
 
-// getValueByPath gets a value from JSON by path (simplified JSONPath)
-func getValueByPath(root interface{}, path string) (interface{}, error) {
-	// Normalize path
-	path = strings.TrimPrefix(path, "$")
-	if len(path) > 0 && path[0] == '.' {
-		path = path[1:]
-	}
-	if path == "" {
-		return root, nil
-	}
-
-	// Split path by dot
-	parts := strings.Split(path, ".")
-	current := root
-
-	for _, part := range parts {
-		if part == "" {
-			continue
-		}
-
-		switch v := current.(type) {
-		case map[string]interface{}:
-			val, ok := v[part]
-			if !ok {
-				return nil, fmt.Errorf("path not found: %s", part)
-			}
-			current = val
-		default:
-			return nil, fmt.Errorf("path not traversable: %s", part)
-		}
-	}
-
-	return current, nil
-}
-
 // getJSONType returns the type of a JSON value
 func getJSONType(value interface{}) string {
 	switch value.(type) {
@@ -0,0 +1,438 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrJSONPathSyntax is returned for paths that cannot be parsed
+var ErrJSONPathSyntax = errors.New("ERR invalid JSONPath")
+
+// jsonPath is a compiled path. Paths starting with "$" use JSONPath syntax and
+// may match any number of values; other paths (".", ".a.b", "a[0]") are legacy
+// paths that address a single value, as in RedisJSON v1.
+type jsonPath struct {
+	legacy bool
+	steps  []jsonPathStep
+}
+
+type jsonStepKind int
+
+const (
+	jsonStepKeys     jsonStepKind = iota // .name, ['a','b']
+	jsonStepIndexes                      // [0], [1,-1]
+	jsonStepSlice                        // [start:end:step]
+	jsonStepWildcard                     // .*, [*]
+)
+
+// jsonPathStep selects children of every matched value. A recursive step
+// (introduced by "..") applies to the value and all of its descendants.
+type jsonPathStep struct {
+	kind      jsonStepKind
+	recursive bool
+	keys      []string
+	indexes   []int
+	start     *int
+	end       *int
+	step      int
+}
+
+// jsonNode is a value matched by a path together with the slot holding it,
+// so that the value can be replaced or removed from its parent.
+type jsonNode struct {
+	value  interface{}
+	holder *interface{} // set for the root only
+	parent *jsonNode
+	key    string // slot in a parent object
+	index  int    // slot in a parent array
+}
+
+// set replaces the value in its slot
+func (n *jsonNode) set(v interface{}) {
+	n.value = v
+	if n.holder != nil {
+		*n.holder = v
+		return
+	}
+	switch p := n.parent.value.(type) {
+	case map[string]interface{}:
+		p[n.key] = v
+	case []interface{}:
+		p[n.index] = v
+	}
+}
+
+// IsLegacyJSONPath reports whether path is a legacy path. Commands reply to a
+// legacy path with the single addressed value and to a JSONPath with one entry
+// per match.
+func IsLegacyJSONPath(path string) bool {
+	return !strings.HasPrefix(path, "$")
+}
+
+// parseJSONPath compiles a JSONPath or legacy path
+func parseJSONPath(path string) (*jsonPath, error) {
+	p := &jsonPath{}
+	rest := path
+	switch {
+	case strings.HasPrefix(path, "$"):
+		rest = path[1:]
+	case path == "" || path == ".":
+		p.legacy = true
+		return p, nil
+	default:
+		p.legacy = true
+		if path[0] != '.' && path[0] != '[' {
+			rest = "." + path
+		}
+	}
+
+	for len(rest) > 0 {
+		var st jsonPathStep
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			st.recursive = true
+			rest = rest[2:]
+			if strings.HasPrefix(rest, "[") {
+				var err error
+				if st, rest, err = parseJSONBracket(rest, true); err != nil {
+					return nil, err
+				}
+				p.steps = append(p.steps, st)
+				continue
+			}
+		case rest[0] == '.':
+			rest = rest[1:]
+		case rest[0] == '[':
+			var err error
+			if st, rest, err = parseJSONBracket(rest, false); err != nil {
+				return nil, err
+			}
+			p.steps = append(p.steps, st)
+			continue
+		default:
+			return nil, ErrJSONPathSyntax
+		}
+
+		// 点号之后是成员名或 *
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		name := rest[:end]
+		rest = rest[end:]
+		switch name {
+		case "":
+			return nil, ErrJSONPathSyntax
+		case "*":
+			st.kind = jsonStepWildcard
+		default:
+			st.kind = jsonStepKeys
+			st.keys = []string{name}
+		}
+		p.steps = append(p.steps, st)
+	}
+	return p, nil
+}
+
+// parseJSONBracket parses a bracket step at the start of s and returns the rest of s
+func parseJSONBracket(s string, recursive bool) (jsonPathStep, string, error) {
+	st := jsonPathStep{recursive: recursive}
+	// 找到与 '[' 匹配的 ']'，引号内的 ']' 不算
+	var quote byte
+	end := -1
+	for i := 1; i < len(s) && end < 0; i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ']':
+			end = i
+		}
+	}
+	if end < 0 {
+		return st, "", ErrJSONPathSyntax
+	}
+	body := strings.TrimSpace(s[1:end])
+	rest := s[end+1:]
+
+	switch {
+	case body == "*":
+		st.kind = jsonStepWildcard
+	case strings.HasPrefix(body, "'") || strings.HasPrefix(body, `"`):
+		st.kind = jsonStepKeys
+		for _, part := range splitJSONUnion(body) {
+			key, err := unquoteJSONKey(part)
+			if err != nil {
+				return st, "", err
+			}
+			st.keys = append(st.keys, key)
+		}
+	case strings.Contains(body, ":"):
+		st.kind = jsonStepSlice
+		st.step = 1
+		parts := strings.Split(body, ":")
+		if len(parts) > 3 {
+			return st, "", ErrJSONPathSyntax
+		}
+		bounds := []**int{&st.start, &st.end}
+		for i, part := range parts {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return st, "", ErrJSONPathSyntax
+			}
+			if i == 2 {
+				if n <= 0 {
+					return st, "", ErrJSONPathSyntax
+				}
+				st.step = n
+			} else {
+				*bounds[i] = &n
+			}
+		}
+	default:
+		st.kind = jsonStepIndexes
+		for _, part := range strings.Split(body, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return st, "", ErrJSONPathSyntax
+			}
+			st.indexes = append(st.indexes, n)
+		}
+	}
+	return st, rest, nil
+}
+
+// splitJSONUnion splits "'a', 'b'" at commas outside quotes
+func splitJSONUnion(body string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(body); i++ {
+		switch c := body[i]; {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ',':
+			parts = append(parts, strings.TrimSpace(body[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(body[start:]))
+}
+
+// unquoteJSONKey decodes a single- or double-quoted member name
+func unquoteJSONKey(s string) (string, error) {
+	if len(s) < 2 || s[0] != s[len(s)-1] || (s[0] != '\'' && s[0] != '"') {
+		return "", ErrJSONPathSyntax
+	}
+	var b strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] == '\\' && i+1 < len(s)-1 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String(), nil
+}
+
+// isRoot reports whether the path addresses only the whole document
+func (p *jsonPath) isRoot() bool {
+	return len(p.steps) == 0
+}
+
+// eval returns the values matched by the path, in document order.
+// Object members are visited in key order.
+func (p *jsonPath) eval(root *interface{}) []*jsonNode {
+	nodes := []*jsonNode{{value: *root, holder: root}}
+	for _, st := range p.steps {
+		var next []*jsonNode
+		for _, n := range nodes {
+			if st.recursive {
+				for _, d := range jsonDescendants(n, nil) {
+					next = st.apply(d, next)
+				}
+			} else {
+				next = st.apply(n, next)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// apply appends the children of n selected by the step to out
+func (st jsonPathStep) apply(n *jsonNode, out []*jsonNode) []*jsonNode {
+	switch v := n.value.(type) {
+	case map[string]interface{}:
+		switch st.kind {
+		case jsonStepKeys:
+			for _, k := range st.keys {
+				if child, ok := v[k]; ok {
+					out = append(out, &jsonNode{value: child, parent: n, key: k})
+				}
+			}
+		case jsonStepWildcard:
+			for _, k := range sortedJSONKeys(v) {
+				out = append(out, &jsonNode{value: v[k], parent: n, key: k})
+			}
+		}
+	case []interface{}:
+		switch st.kind {
+		case jsonStepIndexes:
+			for _, i := range st.indexes {
+				if i < 0 {
+					i += len(v)
+				}
+				if i >= 0 && i < len(v) {
+					out = append(out, &jsonNode{value: v[i], parent: n, index: i})
+				}
+			}
+		case jsonStepSlice:
+			start, end := 0, len(v)
+			if st.start != nil {
+				start = clampJSONIndex(*st.start, len(v))
+			}
+			if st.end != nil {
+				end = clampJSONIndex(*st.end, len(v))
+			}
+			for i := start; i < end; i += st.step {
+				out = append(out, &jsonNode{value: v[i], parent: n, index: i})
+			}
+		case jsonStepWildcard:
+			for i := range v {
+				out = append(out, &jsonNode{value: v[i], parent: n, index: i})
+			}
+		}
+	}
+	return out
+}
+
+// clampJSONIndex resolves a negative slice bound and clamps it to [0, n]
+func clampJSONIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	return max(0, min(i, n))
+}
+
+// jsonDescendants appends n and all values below it to out, parents first
+func jsonDescendants(n *jsonNode, out []*jsonNode) []*jsonNode {
+	out = append(out, n)
+	switch v := n.value.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedJSONKeys(v) {
+			out = jsonDescendants(&jsonNode{value: v[k], parent: n, key: k}, out)
+		}
+	case []interface{}:
+		for i := range v {
+			out = jsonDescendants(&jsonNode{value: v[i], parent: n, index: i}, out)
+		}
+	}
+	return out
+}
+
+func sortedJSONKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// evalJSONPath compiles path and evaluates it against root. Legacy paths that match
+// nothing return an error naming the path, as RedisJSON does.
+func evalJSONPath(root *interface{}, path string) (*jsonPath, []*jsonNode, error) {
+	p, err := parseJSONPath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	nodes := p.eval(root)
+	if p.legacy {
+		if len(nodes) == 0 {
+			return p, nil, fmt.Errorf("ERR Path '%s' does not exist", path)
+		}
+		nodes = nodes[:1]
+	}
+	return p, nodes, nil
+}
+
+// jsonSetPath sets the value at path. Existing matches are replaced (unless nx);
+// when nothing matches and the last step names a single object member, the member
+// is added to every object matched by the parent path (unless xx). Returns whether
+// anything was written.
+func jsonSetPath(root *interface{}, p *jsonPath, value interface{}, nx, xx bool) bool {
+	if nodes := p.eval(root); len(nodes) > 0 {
+		if nx {
+			return false
+		}
+		for _, n := range nodes {
+			n.set(value)
+		}
+		return true
+	}
+	if xx || p.isRoot() {
+		return false
+	}
+	last := p.steps[len(p.steps)-1]
+	if last.kind != jsonStepKeys || last.recursive || len(last.keys) != 1 {
+		return false
+	}
+	parent := &jsonPath{legacy: p.legacy, steps: p.steps[:len(p.steps)-1]}
+	written := false
+	for _, n := range parent.eval(root) {
+		if obj, ok := n.value.(map[string]interface{}); ok {
+			obj[last.keys[0]] = value
+			written = true
+			if p.legacy {
+				break
+			}
+		}
+	}
+	return written
+}
+
+// jsonDeleteNodes removes the matched values from their parents and returns the
+// number removed. The root cannot be removed this way.
+func jsonDeleteNodes(nodes []*jsonNode) int64 {
+	// 数组元素按下标从大到小删除，同一数组中前面元素的下标不受影响
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].index > nodes[j].index
+	})
+	var deleted int64
+	for _, n := range nodes {
+		if n.parent == nil {
+			continue
+		}
+		switch p := n.parent.value.(type) {
+		case map[string]interface{}:
+			if _, ok := p[n.key]; ok {
+				delete(p, n.key)
+				deleted++
+			}
+		case []interface{}:
+			if n.index < len(p) {
+				n.parent.set(append(p[:n.index:n.index], p[n.index+1:]...))
+				deleted++
+			}
+		}
+	}
+	return deleted
+}
@@ -0,0 +1,326 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestJSONPathEval(t *testing.T) {
+	var root interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"store": {
+			"book": [
+				{"title": "a", "price": 8, "tags": ["x"]},
+				{"title": "b", "price": 12},
+				{"title": "c", "price": 9, "isbn": "123"}
+			],
+			"bicycle": {"color": "red", "price": 20}
+		},
+		"odd key": {"it's": 1}
+	}`), &root))
+
+	eval := func(path string) string {
+		p, err := parseJSONPath(path)
+		assert.NoError(t, err)
+		nodes := p.eval(&root)
+		values := make([]interface{}, len(nodes))
+		for i, n := range nodes {
+			values[i] = n.value
+		}
+		data, err := json.Marshal(values)
+		assert.NoError(t, err)
+		return string(data)
+	}
+
+	assert.Equal(t, `["red"]`, eval("$.store.bicycle.color"))
+	assert.Equal(t, `["red"]`, eval(`$['store']["bicycle"].color`))
+	assert.Equal(t, `["b"]`, eval("$.store.book[1].title"))
+	assert.Equal(t, `["c"]`, eval("$.store.book[-1].title"))
+	assert.Equal(t, `["a","c"]`, eval("$.store.book[0,2].title"))
+	assert.Equal(t, `["a","b"]`, eval("$.store.book[:2].title"))
+	assert.Equal(t, `["a","c"]`, eval("$.store.book[::2].title"))
+	assert.Equal(t, `["b","c"]`, eval("$.store.book[-2:].title"))
+	assert.Equal(t, `[8,12,9]`, eval("$.store.book[*].price"))
+	assert.Equal(t, `[20,8,12,9]`, eval("$..price"))
+	assert.Equal(t, `["123"]`, eval("$..isbn"))
+	assert.Equal(t, `[1]`, eval(`$["odd key"]['it\'s']`))
+	assert.Equal(t, `[]`, eval("$.store.missing"))
+	assert.Equal(t, `[]`, eval("$.store.book[7]"))
+	assert.Equal(t, `["red"]`, eval("store.bicycle.color"))
+	assert.Equal(t, `["red"]`, eval(".store.bicycle.color"))
+	assert.Equal(t, `["red",20]`, eval("$.store.bicycle.*"))
+	assert.Equal(t, `["red",20]`, eval("$.store.bicycle[*]"))
+
+	for _, bad := range []string{"$.", "$..", "$[", "$[1:2:0]", "$[abc]", "$x", "$['a'"} {
+		_, err := parseJSONPath(bad)
+		assert.Equal(t, ErrJSONPathSyntax, err)
+	}
+}
+
+func TestJSONPathCommands(t *testing.T) {
+	db, err := NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.JSONSet("user", "$", `{"name":"Ann","address":{"city":"Oslo","zip":"0150"},"items":[{"qty":1},{"qty":2},{"qty":3}]}`, false, false)
+	assert.NoError(t, err)
+
+	// JSONPath 返回所有匹配组成的数组，旧式路径返回单个值
+	result, err := db.JSONGet("user", "$.address.city")
+	assert.NoError(t, err)
+	assert.Equal(t, `["Oslo"]`, result)
+	result, err = db.JSONGet("user", ".address.city")
+	assert.NoError(t, err)
+	assert.Equal(t, `"Oslo"`, result)
+	result, err = db.JSONGet("user", "$.items[*].qty")
+	assert.NoError(t, err)
+	assert.Equal(t, `[1,2,3]`, result)
+	result, err = db.JSONGet("user", "$.nothing")
+	assert.NoError(t, err)
+	assert.Equal(t, `[]`, result)
+	_, err = db.JSONGet("user", ".nothing")
+	assert.Equal(t, "ERR Path '.nothing' does not exist", err.Error())
+
+	// 多个路径返回以路径为键的对象
+	result, err = db.JSONGet("user", "$.name", "$.items[0].qty")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"$.name":["Ann"],"$.items[0].qty":[1]}`, result)
+	result, err = db.JSONGet("user", ".name", "address.zip")
+	assert.NoError(t, err)
+	assert.Equal(t, `{".name":"Ann","address.zip":"0150"}`, result)
+
+	// 修改嵌套的值，缺少的最后一个成员被添加
+	result, err = db.JSONSet("user", "$.items[2].qty", `10`, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", result)
+	result, err = db.JSONSet("user", "$.address.country", `"NO"`, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", result)
+	result, err = db.JSONSet("user", "$.address.city", `"Bergen"`, true, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "", result)
+	result, err = db.JSONSet("user", "$.address.street", `"x"`, false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "", result)
+	result, err = db.JSONGet("user", "$.address")
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"city":"Oslo","country":"NO","zip":"0150"}]`, result)
+	_, err = db.JSONSet("missing", "$.a", `1`, false, false)
+	assert.Equal(t, ErrJSONNewAtRoot, err)
+
+	// 数值运算作用于所有匹配的数字，非数字的匹配结果为 nil
+	nums, err := db.JSONNumIncrBy("user", "$.items[*].qty", 5)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{float64(6), float64(7), float64(15)}, floatResults(nums))
+	nums, err = db.JSONNumMultBy("user", "$.items[1].qty", 2)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{float64(14)}, floatResults(nums))
+	nums, err = db.JSONNumIncrBy("user", "$.name", 1)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{nil}, floatResults(nums))
+	_, err = db.JSONNumIncrBy("user", ".name", 1)
+	assert.Equal(t, ErrJSONNotNumber, err)
+	result, err = db.JSONGet("user", "$..qty")
+	assert.NoError(t, err)
+	assert.Equal(t, `[6,14,15]`, result)
+
+	typ, err := db.JSONType("user", "$.items")
+	assert.NoError(t, err)
+	assert.Equal(t, "array", typ)
+	lengths, err := db.JSONArrAppend("user", "$.items", `{"qty":0}`)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{int64(4)}, intResults(lengths))
+	keys, err := db.JSONObjKeys("user", "$.address")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"city", "country", "zip"}, keys)
+
+	// 删除数组元素与对象成员
+	deleted, err := db.JSONDel("user", "$.items[0,2]", "$.address.zip")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	result, err = db.JSONGet("user", "$.items", "$.address")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"$.items":[[{"qty":14},{"qty":0}]],"$.address":[{"city":"Oslo","country":"NO"}]}`, result)
+
+	cleared, err := db.JSONClear("user", "$.items")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), cleared)
+	result, err = db.JSONGet("user")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"address":{"city":"Oslo","country":"NO"},"items":[],"name":"Ann"}`, result)
+
+	_, err = db.JSONGet("user", "$.[")
+	assert.Equal(t, ErrJSONPathSyntax, err)
+}
+
+func TestJSONArrayStringMutators(t *testing.T) {
+	db, err := NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.JSONSet("doc", "$", `{"tags":["a","b"],"name":"Ann","active":true,"nested":{"tags":[1]}}`, false, false)
+	assert.NoError(t, err)
+
+	// 在下标之前插入，负数下标从末尾计算
+	lengths, err := db.JSONArrInsert("doc", "$.tags", 1, `"x"`, `"y"`)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{int64(4)}, intResults(lengths))
+	lengths, err = db.JSONArrInsert("doc", ".tags", -1, `"z"`)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{int64(5)}, intResults(lengths))
+	_, err = db.JSONArrInsert("doc", "$.tags", 6, `"w"`)
+	assert.Equal(t, ErrJSONIndex, err)
+	_, err = db.JSONArrInsert("doc", ".name", 0, `"w"`)
+	assert.Equal(t, ErrJSONNotArray, err)
+	lengths, err = db.JSONArrInsert("doc", "$.name", 0, `"w"`)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{nil}, intResults(lengths))
+	result, err := db.JSONGet("doc", "$.tags")
+	assert.NoError(t, err)
+	assert.Equal(t, `[["a","x","y","z","b"]]`, result)
+
+	indexes, err := db.JSONArrIndex("doc", "$.tags", `"y"`, 0, 0)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{int64(2)}, intResults(indexes))
+	indexes, err = db.JSONArrIndex("doc", "$.tags", `"y"`, 3, 0)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{int64(-1)}, intResults(indexes))
+	indexes, err = db.JSONArrIndex("doc", "$..tags", `"b"`, -2, 0)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{int64(4), int64(-1)}, intResults(indexes))
+
+	// 弹出默认取最后一个元素，越界下标被截断
+	popped, err := db.JSONArrPop("doc", "$.tags", -1)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{`"b"`}, stringResults(popped))
+	popped, err = db.JSONArrPop("doc", "$.tags", 100)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{`"z"`}, stringResults(popped))
+	popped, err = db.JSONArrPop("doc", ".tags", 0)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{`"a"`}, stringResults(popped))
+
+	// 对所有匹配的数组生效，逐个返回结果
+	lengths, err = db.JSONArrInsert("doc", "$..tags", 0, `0`)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{int64(3), int64(2)}, intResults(lengths))
+	lengths, err = db.JSONArrTrim("doc", "$..tags", 1, -1)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{int64(2), int64(1)}, intResults(lengths))
+	result, err = db.JSONGet("doc", "$..tags")
+	assert.NoError(t, err)
+	assert.Equal(t, `[["x","y"],[1]]`, result)
+	lengths, err = db.JSONArrTrim("doc", "$.tags", 2, 5)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{int64(0)}, intResults(lengths))
+	popped, err = db.JSONArrPop("doc", "$.*", -1)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{nil, nil, nil, nil}, stringResults(popped))
+
+	lengths, err = db.JSONStrAppend("doc", "$.name", `"-Marie"`)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{int64(9)}, intResults(lengths))
+	lengths, err = db.JSONStrLen("doc", ".name")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{int64(9)}, intResults(lengths))
+	_, err = db.JSONStrAppend("doc", "$.name", `-Marie`)
+	assert.Error(t, err)
+	lengths, err = db.JSONStrLen("doc", "$.active")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{nil}, intResults(lengths))
+	_, err = db.JSONStrLen("doc", ".active")
+	assert.Equal(t, ErrJSONNotString, err)
+
+	toggled, err := db.JSONToggle("doc", "$.active")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{int64(0)}, intResults(toggled))
+	toggled, err = db.JSONToggle("doc", "$.active")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{int64(1)}, intResults(toggled))
+	// 不是布尔值的匹配结果为 nil，旧式路径则报错
+	toggled, err = db.JSONToggle("doc", "$.name")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []interface{}{nil}, intResults(toggled))
+	_, err = db.JSONToggle("doc", ".name")
+	assert.Equal(t, ErrJSONNotBool, err)
+
+	result, err = db.JSONGet("doc")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"active":true,"name":"Ann-Marie","nested":{"tags":[1]},"tags":[]}`, result)
+}
+
+func TestJSONMergeAndMSet(t *testing.T) {
+	db, err := NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// 键不存在时只能在根路径创建，补丁中的 null 被去掉
+	assert.NoError(t, db.JSONMerge("doc", "$", `{"a":1,"b":{"c":2,"d":null}}`))
+	assert.Equal(t, ErrJSONNewAtRoot, db.JSONMerge("other", "$.a", `1`))
+	result, err := db.JSONGet("doc")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":1,"b":{"c":2}}`, result)
+
+	// RFC 7396：对象递归合并，null 删除成员，其它值整体替换，缺少的中间对象被创建
+	assert.NoError(t, db.JSONMerge("doc", "$", `{"a":null,"b":{"e":[1,2]},"f":{"g":{"h":true}}}`))
+	result, err = db.JSONGet("doc")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"b":{"c":2,"e":[1,2]},"f":{"g":{"h":true}}}`, result)
+	assert.NoError(t, db.JSONMerge("doc", "$.b", `{"e":[3]}`))
+	assert.NoError(t, db.JSONMerge("doc", "$.f.g", `"x"`))
+	assert.NoError(t, db.JSONMerge("doc", "$.new", `{"k":null,"v":1}`))
+	assert.NoError(t, db.JSONMerge("doc", "$.b.c", `null`))
+	result, err = db.JSONGet("doc")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"b":{"e":[3]},"f":{"g":"x"},"new":{"v":1}}`, result)
+
+	// 所有三元组在同一事务中写入，任何一个出错都不写入
+	assert.NoError(t, db.JSONMSet(
+		[]string{"doc", "m1", "m1"},
+		[]string{"$.b.e", "$", "$.tags"},
+		[]string{`[]`, `{"n":1}`, `["a"]`},
+	))
+	result, err = db.JSONGet("m1")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"n":1,"tags":["a"]}`, result)
+	err = db.JSONMSet([]string{"m2", "m3"}, []string{"$", "$.a"}, []string{`{}`, `1`})
+	assert.Equal(t, ErrJSONNewAtRoot, err)
+	_, err = db.JSONGet("m2")
+	assert.Equal(t, ErrKeyNotFound, err)
+	result, err = db.JSONGet("doc", "$.b")
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"e":[]}]`, result)
+}
+
+// intResults 将逐个匹配的结果转换为便于比较的切片，nil 表示类型不符的匹配
+func intResults(results []*int64) []interface{} {
+	out := make([]interface{}, len(results))
+	for i, r := range results {
+		if r != nil {
+			out[i] = *r
+		}
+	}
+	return out
+}
+
+func floatResults(results []*float64) []interface{} {
+	out := make([]interface{}, len(results))
+	for i, r := range results {
+		if r != nil {
+			out[i] = *r
+		}
+	}
+	return out
+}
+
+func stringResults(results []*string) []interface{} {
+	out := make([]interface{}, len(results))
+	for i, r := range results {
+		if r != nil {
+			out[i] = *r
+		}
+	}
+	return out
+}
@@ -71,8 +71,8 @@ func TestJSONGet(t *testing.T) {
 	if err != nil {
 		t.Fatalf("JSON.GET failed: %v", err)
 	}
-	if len(result) != 1 {
-		t.Errorf("Expected 1 result, got %d", len(result))
+	if result != `{"age":30,"name":"John"}` {
+		t.Errorf("Expected the whole document, got %s", result)
 	}
 }
 
@@ -152,8 +152,8 @@ func TestJSONArrAppend(t *testing.T) {
 	if err != nil {
 		t.Fatalf("JSON.ARRAPPEND failed: %v", err)
 	}
-	if count != 3 {
-		t.Errorf("Expected 3, got %d", count)
+	if len(count) != 1 || *count[0] != 3 {
+		t.Errorf("Expected [3], got %v", count)
 	}
 
 	// Verify array length
@@ -209,8 +209,8 @@ func TestJSONNumIncrBy(t *testing.T) {
 	if err != nil {
 		t.Fatalf("JSON.NUMINCRBY failed: %v", err)
 	}
-	if result != 15 {
-		t.Errorf("Expected 15, got %f", result)
+	if len(result) != 1 || *result[0] != 15 {
+		t.Errorf("Expected [15], got %v", result)
 	}
 }
 
@@ -233,8 +233,8 @@ func TestJSONNumMultBy(t *testing.T) {
 	if err != nil {
 		t.Fatalf("JSON.NUMMULTBY failed: %v", err)
 	}
-	if result != 20 {
-		t.Errorf("Expected 20, got %f", result)
+	if len(result) != 1 || *result[0] != 20 {
+		t.Errorf("Expected [20], got %v", result)
 	}
 }
 
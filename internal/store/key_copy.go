@@ -0,0 +1,351 @@
+package store
+
+import (
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/lbp0200/BoltDB/internal/helper"
+	"github.com/lbp0200/BoltDB/internal/logger"
+)
+
+// COPY 与 RENAME（以及 RENAMENX、MOVE、SWAPDB）：按 keyLayouts 逐个复制组成键的 Badger 键，
+// 只改写其中的用户键部分，值（包括压缩数据与冷层占位值）与条目上的过期时间原样保留，不解码整个结构。
+//
+// Badger 键不超过 relocateBatch 个（且不超过 relocateBatchBytes）时在一个事务中完成，与 Redis 相同是原子的。
+// 更大的键分批复制，内存只与一批的大小有关，但不是原子的：
+//   - 目标已存在且允许覆盖时先删除，并等待 UNLINK 的回收完成，之后写入的数据不会与旧数据混在一起。
+//     旧的目标不会恢复：之后的复制失败时目标保持删除，与先执行了 DEL 相同；
+//   - 从同一个只读快照中流式读取源键，每批提交一次。目标的类型键尚未写入，这些数据不可见；
+//   - 最后一个事务重新遍历源键，确认快照之后源键没有被修改（没有更新的条目，条目数相同），再写入目标的类型键，
+//     使目标连同过期时间一起可见；RENAME 在同一事务中删除源的类型键，源的数据交给 UNLINK 的后台回收。
+//
+// 源键在复制期间被修改时，已复制的数据交给 UNLINK 的后台回收并重新复制，最多 relocateAttempts 次，
+// 仍被修改时返回错误，源键保持不变。分批复制期间目标键暂时不存在，
+// 最后一个事务在写入类型键前重新检查目标：期间被其他命令创建时，不允许覆盖（RENAMENX、不带 REPLACE 的 COPY）
+// 则放弃复制，已复制的数据留在新目标键下；允许覆盖时用 UNLINK 删除它，连同已复制的数据一起回收后重新复制
+
+const (
+	// relocateBatch 分批复制时每个事务写入的 Badger 键数，也是在一个事务中完成的上限
+	relocateBatch = 1000
+	// relocateBatchBytes 每个事务写入的键与值的总字节数上限，避免大值超出 Badger 的事务大小限制
+	relocateBatchBytes = 4 << 20
+	// relocateAttempts 分批复制期间源键被修改时最多复制的次数
+	relocateAttempts = 3
+)
+
+var (
+	// ErrNoSuchKey RENAME 的源键不存在
+	ErrNoSuchKey = errors.New("no such key")
+	// errRelocateTooLarge 键超过一个事务的上限，改为分批复制
+	errRelocateTooLarge = errors.New("key too large to relocate in one transaction")
+	// errRelocateSourceChanged 分批复制期间源键被修改（不能含 "conflict"，否则 retryUpdate 会重试）
+	errRelocateSourceChanged = errors.New("source key was modified while being copied, try again")
+	// errRelocateTargetChanged 分批复制期间目标键被创建，允许覆盖时删除后重新复制
+	errRelocateTargetChanged = errors.New("target key was created while being copied, try again")
+	// errRelocateTargetExists 分批复制期间目标键被创建且不允许覆盖
+	errRelocateTargetExists = errors.New("target key was created while being copied")
+)
+
+// relocatedEntry 复制到目标键下的一个 Badger 条目
+type relocatedEntry struct {
+	key       []byte
+	value     []byte
+	expiresAt uint64
+	userMeta  byte
+}
+
+func (e relocatedEntry) size() int {
+	return len(e.key) + len(e.value)
+}
+
+// relocatedEntries 源键 src 的一个 Badger 键在目标键 dst 下对应的条目。
+// 哈希字段的过期时间除了字段的记录还要写入全局索引（见 hash_ttl.go）
+func relocatedEntries(item *badger.Item, part keyLayoutPart, src, dst string) ([]relocatedEntry, error) {
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	var key []byte
+	if part.Exact != nil {
+		key = part.Exact(dst)
+	} else {
+		key = append(part.Prefix(dst), item.Key()[len(part.Prefix(src)):]...)
+	}
+	entries := []relocatedEntry{{key: key, value: value, expiresAt: item.ExpiresAt(), userMeta: item.UserMeta()}}
+	if part.Role == "field-ttl" {
+		field := string(key[len(hashTTLPrefix(dst)):])
+		// #nosec G115 - 写入时为非负的 int64
+		at := int64(helper.BytesToUint64(value))
+		entries = append(entries, relocatedEntry{key: hashExpireIndexKey(at, dst, field)})
+	}
+	return entries, nil
+}
+
+// setRelocatedTxn 在 txn 中写入复制的条目
+func setRelocatedTxn(txn *storeTxn, entries []relocatedEntry) error {
+	for _, e := range entries {
+		entry := badger.NewEntry(e.key, e.value).WithMeta(e.userMeta)
+		entry.ExpiresAt = e.expiresAt
+		if err := txn.SetEntry(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Copy 实现 COPY：把 src 复制为 dst，保留过期时间。src 不存在，或 dst 已存在且 replace 为 false 时返回 false
+func (s *BotreonStore) Copy(src, dst string, replace bool) (bool, error) {
+	return s.relocate(src, dst, false, replace)
+}
+
+// Rename 实现 RENAME：重命名键，覆盖已存在的 newKey，保留过期时间。key 不存在时返回 ErrNoSuchKey
+func (s *BotreonStore) Rename(key, newKey string) error {
+	ok, err := s.relocate(key, newKey, true, true)
+	if err == nil && !ok {
+		err = ErrNoSuchKey
+	}
+	return err
+}
+
+// RenameNX 实现 RENAMENX：newKey 不存在时重命名，已存在时返回 false。key 不存在时返回 ErrNoSuchKey
+func (s *BotreonStore) RenameNX(key, newKey string) (bool, error) {
+	exists, err := s.Exists(key)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, ErrNoSuchKey
+	}
+	return s.relocate(key, newKey, true, false)
+}
+
+// relocate 把 src 复制为 dst，move 时同时删除 src。返回是否复制：src 不存在，
+// 或 dst 已存在且 replace 为 false 时返回 false
+func (s *BotreonStore) relocate(src, dst string, move, replace bool) (bool, error) {
+	if src == dst {
+		// 新旧键相同时不做任何修改，与 Redis 相同
+		exists, err := s.Exists(src)
+		return exists && move, err
+	}
+	s.AwaitUnlink(src, dst)
+	var done bool
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		var err error
+		done, err = s.relocateTxn(txn, src, dst, move, replace)
+		return err
+	}, 30)
+	if errors.Is(err, errRelocateTooLarge) || errors.Is(err, badger.ErrTxnTooBig) {
+		for i := 0; i < relocateAttempts; i++ {
+			done, err = s.relocateInBatches(src, dst, move, replace)
+			if !errors.Is(err, errRelocateSourceChanged) && !errors.Is(err, errRelocateTargetChanged) {
+				break
+			}
+		}
+	}
+	return done, err
+}
+
+// relocateTxn 在一个事务中完成 relocate，键超过一个事务的上限时返回 errRelocateTooLarge
+func (s *BotreonStore) relocateTxn(txn *storeTxn, src, dst string, move, replace bool) (bool, error) {
+	srcType, err := keyTypeTxn(txn, src)
+	if err != nil || srcType == "" {
+		return false, err
+	}
+	dstType, err := keyTypeTxn(txn, dst)
+	if err != nil {
+		return false, err
+	}
+	if dstType != "" {
+		if !replace {
+			return false, nil
+		}
+		if _, err := s.delTxn(txn, dst); err != nil {
+			return false, err
+		}
+	}
+	// 目标的类型键最先写入：dst 以 src 开头时（如 a 与 a:b），之后遍历 src 的前缀
+	// 遇到的目标的键由 ownedByLongerKey 归属于 dst
+	var srcKeys [][]byte
+	n, size := 0, 0
+	_, err = walkKeyLayoutUntil(txn, src, func(item *badger.Item, part keyLayoutPart) error {
+		if part.Retain {
+			return nil
+		}
+		if n++; n > relocateBatch {
+			return errRelocateTooLarge
+		}
+		entries, err := relocatedEntries(item, part, src, dst)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if size += e.size(); size > relocateBatchBytes {
+				return errRelocateTooLarge
+			}
+		}
+		if move {
+			srcKeys = append(srcKeys, item.KeyCopy(nil))
+		}
+		return setRelocatedTxn(txn, entries)
+	})
+	if err != nil {
+		return false, err
+	}
+	// 源的哈希字段过期索引条目不删除，之后作为过时条目清理
+	for _, k := range srcKeys {
+		if err := txn.Delete(k); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// relocateInBatches 分批完成 relocate，见文件开头的说明
+func (s *BotreonStore) relocateInBatches(src, dst string, move, replace bool) (bool, error) {
+	// 快照中旧的目标键与源键前缀重叠的数据（如 a 与 a:b）由 ownedByLongerKey 归属于旧的目标键，不会被复制
+	view := &storeTxn{Txn: s.db.NewTransaction(false)}
+	defer view.Discard()
+	srcType, err := keyTypeTxn(view, src)
+	if err != nil || srcType == "" {
+		return false, err
+	}
+	exists, err := s.Exists(dst)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		if !replace {
+			return false, nil
+		}
+		if _, err := s.Unlink(dst); err != nil {
+			return false, err
+		}
+		s.AwaitUnlink(dst)
+	}
+
+	var keyType []byte
+	var batch []relocatedEntry
+	size, visited := 0, 0
+	flush := func() error {
+		err := s.retryUpdate(func(txn *storeTxn) error {
+			return setRelocatedTxn(txn, batch)
+		}, 30)
+		batch, size = batch[:0], 0
+		return err
+	}
+	keyType, err = walkKeyLayoutUntil(view, src, func(item *badger.Item, part keyLayoutPart) error {
+		if part.Retain {
+			return nil
+		}
+		// 类型键最后写入
+		if visited++; part.Role == typeKeyPart.Role {
+			return nil
+		}
+		entries, err := relocatedEntries(item, part, src, dst)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if len(batch) == relocateBatch || (len(batch) > 0 && size+e.size() > relocateBatchBytes) {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			batch = append(batch, e)
+			size += e.size()
+		}
+		return nil
+	})
+	if err == nil && keyType == nil {
+		return false, nil
+	}
+	if err == nil && len(batch) > 0 {
+		err = flush()
+	}
+	if err != nil {
+		s.discardRelocated(dst, []byte(srcType))
+		return false, err
+	}
+
+	var job *unlinkJob
+	if move {
+		// 源的数据由 UNLINK 的后台回收删除
+		job = s.registerUnlink(src)
+		job.mu.Lock()
+	}
+	readTs := view.ReadTs()
+	err = s.retryUpdate(func(txn *storeTxn) error {
+		// 开始时的检查不在事务中，目标可能在复制期间被创建
+		dstType, err := keyTypeTxn(txn, dst)
+		if err != nil {
+			return err
+		}
+		if dstType != "" {
+			if !replace {
+				return errRelocateTargetExists
+			}
+			// 新目标的数据与已复制的数据可能共用 Badger 键（过期时间记录、相同类型的字段等），
+			// 在这里删除会一起删除，因此两者都回收后重新复制
+			return errRelocateTargetChanged
+		}
+		// 先写入目标的类型键，遍历源键时与其前缀重叠的目标数据归属于目标（见 relocateTxn）
+		if err := txn.Set(TypeOfKeyGet(dst), keyType); err != nil {
+			return err
+		}
+		// 源键的读取记录在事务中，提交前源键被写入时 Badger 报告冲突，retryUpdate 重新检查
+		n := 0
+		_, err = walkKeyLayoutUntil(txn, src, func(item *badger.Item, part keyLayoutPart) error {
+			if part.Retain {
+				return nil
+			}
+			if n++; item.Version() > readTs {
+				return errRelocateSourceChanged
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if n != visited {
+			return errRelocateSourceChanged
+		}
+		if move {
+			return markUnlinkedTxn(txn, src, keyType)
+		}
+		return nil
+	}, 30)
+	if move {
+		s.finishUnlink(job, err == nil)
+	}
+	if errors.Is(err, errRelocateTargetExists) {
+		// 不能回收已复制的数据：其中可能有新目标键的数据
+		return false, nil
+	}
+	if errors.Is(err, errRelocateTargetChanged) {
+		if _, unlinkErr := s.Unlink(dst); unlinkErr != nil {
+			return false, unlinkErr
+		}
+		s.AwaitUnlink(dst)
+	}
+	if err != nil {
+		s.discardRelocated(dst, keyType)
+		return false, err
+	}
+	return true, nil
+}
+
+// discardRelocated 分批复制失败时删除已写入 dst 的数据。目标的类型键尚未写入，这些数据不可见，
+// 交给 UNLINK 的回收并等待完成，之后 dst 可以重新使用
+func (s *BotreonStore) discardRelocated(dst string, keyType []byte) {
+	job := s.registerUnlink(dst)
+	job.mu.Lock()
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		return markUnlinkedTxn(txn, dst, keyType)
+	}, 30)
+	s.finishUnlink(job, err == nil)
+	if err != nil {
+		logger.Logger.Error().Err(err).Str("key", dst).Msg("删除复制失败的目标键数据失败")
+		return
+	}
+	s.AwaitUnlink(dst)
+}
@@ -0,0 +1,342 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/zeebo/assert"
+)
+
+// badgerKeysWithPrefix 存储中以 prefix 开头的 Badger 键数
+func badgerKeysWithPrefix(t *testing.T, store *BotreonStore, prefix string) int {
+	n := 0
+	assert.NoError(t, store.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(prefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			n++
+		}
+		return nil
+	}))
+	return n
+}
+
+func TestCopyAllTypes(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.SetWithTTL("s", "v", time.Hour))
+	_, err = store.RPush("l", "a", "b")
+	assert.NoError(t, err)
+	assert.NoError(t, store.HSet("h", "f", "v"))
+	assert.NoError(t, store.HSet("h", "g", "w"))
+	at := time.Now().Add(time.Hour).UnixMilli()
+	_, err = store.HExpireAt("h", at, "", "f")
+	assert.NoError(t, err)
+	_, err = store.SAdd("set", "m")
+	assert.NoError(t, err)
+	assert.NoError(t, store.ZAdd("z", []ZSetMember{{Member: "m", Score: 1}, {Member: "n", Score: 2}}))
+	ok, err := store.Expire("z", 100)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	_, err = store.JSONSet("j", "$", `{"a":1}`, false, false)
+	assert.NoError(t, err)
+	_, err = store.XAdd("x", StreamXAddOptions{}, "1-1", map[string]string{"f": "v"})
+	assert.NoError(t, err)
+	_, err = store.PFAdd("hll", "a", "b")
+	assert.NoError(t, err)
+
+	for _, key := range []string{"s", "l", "h", "set", "z", "j", "x", "hll"} {
+		copied, err := store.Copy(key, key+":copy", false)
+		assert.NoError(t, err)
+		assert.True(t, copied)
+		// 目标已存在且不替换时不复制
+		copied, err = store.Copy(key, key+":copy", false)
+		assert.NoError(t, err)
+		assert.False(t, copied)
+	}
+
+	val, err := store.Get("s:copy")
+	assert.NoError(t, err)
+	assert.Equal(t, "v", val)
+	ttl, err := store.TTL("s:copy")
+	assert.NoError(t, err)
+	assert.True(t, ttl > 3500)
+	items, err := store.LRange("l:copy", 0, -1)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"a", "b"}, items)
+	fields, err := store.HGetAll("h:copy")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(fields))
+	times, err := store.HFieldExpireTimes("h:copy", "f", "g")
+	assert.NoError(t, err)
+	assert.Equal(t, at, times[0])
+	assert.Equal(t, int64(-1), times[1])
+	members, err := store.SMembers("set:copy")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"m"}, members)
+	card, err := store.ZCard("z:copy")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), card)
+	ttl, err = store.TTL("z:copy")
+	assert.NoError(t, err)
+	assert.True(t, ttl > 90)
+	doc, err := store.JSONGet("j:copy")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":1}`, doc)
+	n, err := store.XLen("x:copy")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	count, err := store.PFCount("hll:copy")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// 源键不受影响，修改副本不影响源键
+	assert.NoError(t, store.HSet("h:copy", "f", "changed"))
+	v, err := store.HGet("h", "f")
+	assert.NoError(t, err)
+	assert.Equal(t, "v", string(v))
+
+	// REPLACE 覆盖其他类型的目标键
+	copied, err := store.Copy("l", "s:copy", true)
+	assert.NoError(t, err)
+	assert.True(t, copied)
+	keyType, err := store.Type("s:copy")
+	assert.NoError(t, err)
+	assert.Equal(t, "list", keyType)
+	ttl, err = store.TTL("s:copy")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), ttl)
+
+	copied, err = store.Copy("missing", "dst", true)
+	assert.NoError(t, err)
+	assert.False(t, copied)
+}
+
+func TestRenameJSONAndStream(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	_, err = store.JSONSet("j", "$", `{"a":1}`, false, false)
+	assert.NoError(t, err)
+	_, err = store.XAdd("x", StreamXAddOptions{}, "1-1", map[string]string{"f": "v"})
+	assert.NoError(t, err)
+	assert.NoError(t, store.Rename("j", "j2"))
+	assert.NoError(t, store.Rename("x", "x2"))
+
+	doc, err := store.JSONGet("j2")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":1}`, doc)
+	n, err := store.XLen("x2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	for _, key := range []string{"j", "x"} {
+		layout, err := store.KeyLayout(key)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(layout))
+	}
+	assert.Equal(t, 0, badgerKeysWithPrefix(t, store, "stream:x:"))
+	assert.Equal(t, 1, badgerKeysWithPrefix(t, store, "JSON:j"))
+}
+
+func TestRenameLargeHash(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	const fields = 3*relocateBatch + 10
+	values := make(map[string]interface{}, fields)
+	for i := 0; i < fields; i++ {
+		values[fmt.Sprintf("f%05d", i)] = "v"
+	}
+	assert.NoError(t, store.HMSet("big", values))
+	ok, err := store.Expire("big", 100)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	_, err = store.HExpireAt("big", time.Now().Add(time.Hour).UnixMilli(), "", "f00000")
+	assert.NoError(t, err)
+	assert.NoError(t, store.HSet("big:copy", "old", "x"))
+
+	// 超过一个事务的上限：分批复制，目标已存在时先删除
+	copied, err := store.Copy("big", "big:copy", true)
+	assert.NoError(t, err)
+	assert.True(t, copied)
+	n, err := store.HLen("big:copy")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(fields), n)
+	_, err = store.HGet("big:copy", "old")
+	assert.Error(t, err)
+	ttl, err := store.TTL("big:copy")
+	assert.NoError(t, err)
+	assert.True(t, ttl > 90)
+
+	// 重命名为以源键开头的键：源的数据由后台回收，不影响目标
+	assert.NoError(t, store.Rename("big", "big:renamed"))
+	store.AwaitUnlink("big")
+	exists, err := store.Exists("big")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	n, err = store.HLen("big:renamed")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(fields), n)
+	times, err := store.HFieldExpireTimes("big:renamed", "f00000", "f00001")
+	assert.NoError(t, err)
+	assert.True(t, times[0] > 0)
+	assert.Equal(t, int64(-1), times[1])
+	ttl, err = store.TTL("big:renamed")
+	assert.NoError(t, err)
+	assert.True(t, ttl > 90)
+
+	// 源的字段全部被回收，只剩两个目标键的数据（每个字段一个，加上计数键）
+	total := badgerKeysWithPrefix(t, store, "HASH:big:")
+	assert.Equal(t, 2*(fields+1), total)
+	layout, err := store.KeyLayout("big:renamed")
+	assert.NoError(t, err)
+	roles := make(map[string]int)
+	for _, e := range layout {
+		roles[e.Role]++
+	}
+	assert.Equal(t, fields, roles["field"])
+	assert.Equal(t, 1, roles["field-ttl"])
+
+	// RENAMENX 不覆盖已存在的键
+	renamed, err := store.RenameNX("big:renamed", "big:copy")
+	assert.NoError(t, err)
+	assert.False(t, renamed)
+	_, err = store.RenameNX("big", "other")
+	assert.True(t, err == ErrNoSuchKey)
+}
+
+func TestRelocateLargeString(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	// 超过一个事务字节数上限的值
+	value := strings.Repeat("x", relocateBatchBytes+1)
+	assert.NoError(t, store.SetWithTTL("s", value, time.Hour))
+	assert.NoError(t, store.Rename("s", "t"))
+	got, err := store.Get("t")
+	assert.NoError(t, err)
+	assert.Equal(t, len(value), len(got))
+	ttl, err := store.TTL("t")
+	assert.NoError(t, err)
+	assert.True(t, ttl > 3500)
+	store.AwaitUnlink("s")
+	exists, err := store.Exists("s")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestRelocateSourceModified(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	const fields = 2*relocateBatch + 10
+	values := make(map[string]interface{}, fields)
+	for i := 0; i < fields; i++ {
+		values[fmt.Sprintf("f%05d", i)] = "v"
+	}
+	assert.NoError(t, store.HMSet("src", values))
+	assert.NoError(t, store.HSet("dst", "old", "x"))
+
+	// 分批复制期间源键一直被写入：放弃复制，源键保持不变，已复制的数据被回收；
+	// 覆盖的目标在复制开始前已被删除
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			_ = store.HSet("src", "w", fmt.Sprint(i))
+		}
+	}()
+	err = store.Rename("src", "dst")
+	close(stop)
+	<-done
+	assert.Equal(t, errRelocateSourceChanged, err)
+	n, err := store.HLen("src")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(fields+1), n)
+	exists, err := store.Exists("dst")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	store.AwaitUnlink("dst")
+	assert.Equal(t, 0, badgerKeysWithPrefix(t, store, "HASH:dst:"))
+
+	// 没有并发写入时正常完成
+	assert.NoError(t, store.Rename("src", "dst"))
+	n, err = store.HLen("dst")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(fields+1), n)
+}
+
+func TestRelocateTargetCreated(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	const fields = 10 * relocateBatch
+	values := make(map[string]interface{}, fields)
+	for i := 0; i < fields; i++ {
+		values[fmt.Sprintf("f%05d", i)] = "v"
+	}
+	assert.NoError(t, store.HMSet("src", values))
+
+	// 第一批提交后创建目标键，此时分批复制还没有写入目标的类型键
+	createTarget := func(dst string) chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			staged := []byte("HASH:" + dst + ":f00000")
+			for {
+				err := store.view(func(txn *storeTxn) error {
+					_, err := txn.Get(staged)
+					return err
+				})
+				if err == nil {
+					break
+				}
+			}
+			assert.NoError(t, store.Set(dst, "x"))
+		}()
+		return done
+	}
+
+	// RENAMENX 不覆盖复制期间创建的目标
+	done := createTarget("nx")
+	renamed, err := store.RenameNX("src", "nx")
+	<-done
+	assert.NoError(t, err)
+	assert.False(t, renamed)
+	v, err := store.Get("nx")
+	assert.NoError(t, err)
+	assert.Equal(t, "x", v)
+	n, err := store.HLen("src")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(fields), n)
+
+	// COPY ... REPLACE 删除复制期间创建的目标后重新复制
+	done = createTarget("dst")
+	copied, err := store.Copy("src", "dst", true)
+	<-done
+	assert.NoError(t, err)
+	assert.True(t, copied)
+	n, err = store.HLen("dst")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(fields), n)
+	store.AwaitUnlink("dst")
+	assert.Equal(t, 0, badgerKeysWithPrefix(t, store, "STRING:dst"))
+}
@@ -0,0 +1,326 @@
+package store
+
+//go:generate go run ../../cmd/gen-keylayout -out ../../docs/KEY_LAYOUT.md
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// keyLayoutPart 组成用户键的一类 Badger 键：Exact 为单个键，Prefix 为以此开头的一组键
+// （同一类型中先匹配 Exact，剩余的键再按 Prefix 归类）
+type keyLayoutPart struct {
+	Role    string
+	Pattern string // 文档中的编码格式，<key> 为用户键
+	Exact   func(key string) []byte
+	Prefix  func(key string) []byte
+	Retain  bool // 删除键时保留：属于键名的配置而不是键的数据，键重新创建后继续生效
+}
+
+func exactKey(format string) func(key string) []byte {
+	return func(key string) []byte { return []byte(fmt.Sprintf(format, key)) }
+}
+
+// typeKeyPart 所有类型共有的类型键
+var typeKeyPart = keyLayoutPart{Role: "type", Pattern: "TYPE_<key>", Exact: TypeOfKeyGet}
+
+// expireKeyPart 除字符串与 HyperLogLog 外所有类型共有的过期时间记录，只在设置了过期时间时存在（见 expire.go）
+var expireKeyPart = keyLayoutPart{Role: "expire", Pattern: "EXPIRE_<key>", Exact: expireKeyGet}
+
+// keyLayouts 各数据类型的键编码方式。新增按用户键派生的 Badger 键时必须在这里登记：
+// DEL（以及过期、淘汰、覆盖写入）按此删除键的全部数据，DEBUG KEYSPACE-LAYOUT 与 docs/KEY_LAYOUT.md 也由此生成
+var keyLayouts = map[string][]keyLayoutPart{
+	KeyTypeString: {
+		{Role: "value", Pattern: "STRING:<key>", Exact: exactKey(KeyTypeString + ":%s")},
+		{Role: "chunk", Pattern: "STRCHUNK:<key>:<index:8>", Prefix: stringChunkPrefix},
+	},
+	KeyTypeList: {
+		{Role: "meta", Pattern: "LIST:<key>:length", Exact: exactKey(KeyTypeList + ":%s:length")},
+		{Role: "meta", Pattern: "LIST:<key>:head", Exact: exactKey(KeyTypeList + ":%s:head")},
+		{Role: "element", Pattern: "LIST:<key>:e:<seq:8>", Prefix: listElemPrefix},
+	},
+	KeyTypeHash: {
+		{Role: "meta", Pattern: "HASH:<key>:__count__", Exact: exactKey(KeyTypeHash + ":%s:__count__")},
+		{Role: "field", Pattern: "HASH:<key>:<field>", Prefix: exactKey(KeyTypeHash + ":%s:")},
+		{Role: "field-ttl", Pattern: "HASHTTL:<key>:<field>", Prefix: hashTTLPrefix},
+	},
+	KeyTypeSet: {
+		{Role: "meta", Pattern: "SET:<key>:count", Exact: exactKey(KeyTypeSet + ":%s:count")},
+		{Role: "member", Pattern: "SET:<key>:member:<member>", Prefix: exactKey(KeyTypeSet + ":%s:member:")},
+	},
+	KeyTypeSortedSet: {
+		{Role: "meta", Pattern: "zset:<key>:meta", Exact: sortedSetKeyMeta},
+		{Role: "index", Pattern: "zset:<key>:index:<score:8>:<member>:<version:4>", Prefix: exactKey(prefixKeySortedSetBytes + "%s" + sortedSetIndex)},
+		{Role: "member", Pattern: "zset:<key>:data:<member>", Prefix: exactKey(prefixKeySortedSetBytes + "%s" + sortedSetData)},
+		{Role: "rank", Pattern: "zset:<key>:rank:[<score:8>:<member>:<version:4>]", Prefix: sortedSetRankPrefix},
+		{Role: "watch", Pattern: "META:zwatch:<key>", Exact: exactKey(metaZWatchPrefix + "%s"), Retain: true},
+	},
+	KeyTypeJSON: {
+		{Role: "value", Pattern: "JSON:<key>", Exact: exactKey(string(prefixKeyJSONBytes) + "%s")},
+	},
+	KeyTypeStream: {
+		{Role: "meta", Pattern: "stream:<key>:meta", Exact: streamKey},
+		{Role: "entry", Pattern: "stream:<key>:entry:<ms:8><seq:8>", Prefix: streamDataPrefix},
+		{Role: "groups", Pattern: "stream:<key>:groups", Exact: exactKey(prefixStream + "%s" + streamGroups)},
+		{Role: "group", Pattern: "stream:<key>:groups:<group>", Prefix: streamGroupDataPrefix},
+		{Role: "pending", Pattern: "stream:<key>:pending:<group>", Prefix: exactKey(prefixStream + "%s" + streamPending + ":")},
+		{Role: "queue-index", Pattern: "stream:<key>:qready:<ready at:8><id>", Prefix: streamQueueReadyPrefix},
+		{Role: "queue-message", Pattern: "stream:<key>:qmsg:<id>", Prefix: streamQueueMsgPrefix},
+	},
+	KeyTypeTimeSeries: {
+		{Role: "meta", Pattern: "ts:<key>:meta", Exact: tsMetaKey},
+		{Role: "sample", Pattern: "ts:<key>:data:<timestamp>", Prefix: tsDataPrefix},
+	},
+	KeyTypeGeo: {
+		{Role: "meta", Pattern: "geo:<key>:meta", Exact: geoKey},
+		{Role: "index", Pattern: "geo:<key>:index:<member>", Prefix: exactKey(prefixKeyGeoBytes + "%s" + geoIndex + ":")},
+		{Role: "member", Pattern: "geo:<key>:members:<member>", Prefix: geoMembersKey},
+		{Role: "hash", Pattern: "geo:<key>:hash:<geohash:8>", Prefix: exactKey(prefixKeyGeoBytes + "%s:hash:")},
+		// 地理位置同时以 geohash 为分数写入有序集合的编码，供按距离排序与 ZSCORE 等命令使用
+		{Role: "score", Pattern: "zset:<key>:data:<member>", Prefix: exactKey(prefixKeySortedSetBytes + "%s" + sortedSetData)},
+		{Role: "score-index", Pattern: "zset:<key>:index:<geohash:8>:<member>:<version:4>", Prefix: exactKey(prefixKeySortedSetBytes + "%s" + sortedSetIndex)},
+		{Role: "rank", Pattern: "zset:<key>:rank:[<geohash:8>:<member>:<version:4>]", Prefix: sortedSetRankPrefix},
+	},
+	keyTypeHyperLogLog: {
+		{Role: "value", Pattern: "hll:<key>", Exact: exactKey("hll:%s")},
+	},
+}
+
+// keyTypeHyperLogLog HyperLogLog 在类型键中保存的类型名
+const keyTypeHyperLogLog = "hyperloglog"
+
+// KeyLayoutEntry 组成用户键的一个 Badger 键
+type KeyLayoutEntry struct {
+	Key       []byte
+	Role      string
+	KeySize   int
+	ValueSize int64
+	ExpiresAt int64 // Unix 毫秒，0 表示没有 TTL
+}
+
+// KeyLayout 列出组成用户键的所有 Badger 键（类型键、元数据、成员、索引等）及其大小和过期时间。
+// 键不存在时返回空列表
+func (s *BotreonStore) KeyLayout(key string) ([]KeyLayoutEntry, error) {
+	var entries []KeyLayoutEntry
+	err := s.view(func(txn *storeTxn) error {
+		_, err := walkKeyLayout(txn, key, func(item *badger.Item, part keyLayoutPart) {
+			k := item.KeyCopy(nil)
+			entry := KeyLayoutEntry{Key: k, Role: part.Role, KeySize: len(k), ValueSize: item.ValueSize()}
+			if exp := item.ExpiresAt(); exp > 0 {
+				entry.ExpiresAt = expiresAtTime(exp).UnixMilli()
+			}
+			entries = append(entries, entry)
+		})
+		return err
+	})
+	return entries, err
+}
+
+// expiresAtTime 转换 Badger 条目的过期时间：WithTTL 写入的是 Unix 秒，
+// EXPIRE/PEXPIRE 直接写入 Unix 纳秒，两种都会出现
+func expiresAtTime(exp uint64) time.Time {
+	// #nosec G115 - 过期时间戳在 int64 范围内
+	if exp < 1<<40 {
+		return time.Unix(int64(exp), 0)
+	}
+	return time.Unix(0, int64(exp))
+}
+
+// deleteKeyLayoutTxn 在 txn 中删除组成用户键的所有 Badger 键（Retain 的除外），返回键的类型；
+// 键不存在时返回 nil。类型没有登记编码方式时返回错误而不是只删除类型键，避免留下无法访问的数据
+func deleteKeyLayoutTxn(txn *storeTxn, key string) ([]byte, error) {
+	var keys [][]byte
+	keyType, err := walkKeyLayout(txn, key, func(item *badger.Item, part keyLayoutPart) {
+		if !part.Retain {
+			keys = append(keys, item.KeyCopy(nil))
+		}
+	})
+	if err != nil || keyType == nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if err := txn.Delete(k); err != nil {
+			return nil, err
+		}
+	}
+	return keyType, nil
+}
+
+// walkKeyLayout 依次访问组成用户键的 Badger 键（每个键只访问一次），返回键的类型；
+// 键不存在时返回 nil。visit 中的 item 只在回调期间有效
+func walkKeyLayout(txn *storeTxn, key string, visit func(item *badger.Item, part keyLayoutPart)) ([]byte, error) {
+	return walkKeyLayoutUntil(txn, key, func(item *badger.Item, part keyLayoutPart) error {
+		visit(item, part)
+		return nil
+	})
+}
+
+// walkKeyLayoutUntil 与 walkKeyLayout 相同，visit 返回错误时停止并返回该错误。
+// 前缀下的键逐个流式访问，内存与键的大小无关：只记住单个键（Exact）用于去重，
+// 同时落在更早的前缀下的键由那个前缀访问
+func walkKeyLayoutUntil(txn *storeTxn, key string, visit func(item *badger.Item, part keyLayoutPart) error) ([]byte, error) {
+	item, err := txn.Get(TypeOfKeyGet(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	keyType, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	parts, ok := keyLayouts[string(keyType)]
+	if !ok {
+		return nil, fmt.Errorf("no key layout registered for type %q", keyType)
+	}
+
+	exact := map[string]bool{string(item.Key()): true}
+	if err := visit(item, typeKeyPart); err != nil {
+		return nil, err
+	}
+	if !ttlOnValue(string(keyType)) {
+		parts = append([]keyLayoutPart{expireKeyPart}, parts...)
+	}
+	for _, part := range parts {
+		if part.Exact == nil {
+			continue
+		}
+		k := part.Exact(key)
+		if exact[string(k)] {
+			continue
+		}
+		item, err := txn.Get(k)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		exact[string(k)] = true
+		if err := visit(item, part); err != nil {
+			return nil, err
+		}
+	}
+	owners := make(map[string]bool) // 以 key 开头的更长用户键 -> 是否与 key 同类型
+	var walked [][]byte             // 已经遍历过的前缀
+	for _, part := range parts {
+		if part.Prefix == nil {
+			continue
+		}
+		prefix := part.Prefix(key)
+		err := func() error {
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			opts.Prefix = prefix
+			it := txn.NewIterator(opts)
+			defer it.Close()
+		next:
+			for it.Rewind(); it.Valid(); it.Next() {
+				k := it.Item().Key()
+				if exact[string(k)] {
+					continue
+				}
+				for _, p := range walked {
+					if bytes.HasPrefix(k, p) {
+						continue next
+					}
+				}
+				if ownedByLongerKey(txn, part, key, keyType, k, owners) {
+					continue
+				}
+				if err := visit(it.Item(), part); err != nil {
+					return err
+				}
+			}
+			return nil
+		}()
+		if err != nil {
+			return nil, err
+		}
+		walked = append(walked, prefix)
+	}
+	return keyType, nil
+}
+
+// ownedByLongerKey 前缀编码有歧义：HASH:user: 也是 user:1 的字段键 HASH:user:1:name 的前缀。
+// k 同样能由以 key 开头的另一个同类型用户键生成时，归属于那个键
+func ownedByLongerKey(txn *storeTxn, part keyLayoutPart, key string, keyType, k []byte, owners map[string]bool) bool {
+	// 用户键在 Badger 键中的起始位置
+	empty, full := part.Prefix(""), part.Prefix(key)
+	off := 0
+	for off < len(empty) && off < len(full) && empty[off] == full[off] {
+		off++
+	}
+	for end := off + len(key) + 1; end <= len(k); end++ {
+		longer := string(k[off:end])
+		if !bytes.HasPrefix(k, part.Prefix(longer)) {
+			continue
+		}
+		same, ok := owners[longer]
+		if !ok {
+			if item, err := txn.Get(TypeOfKeyGet(longer)); err == nil {
+				t, err := item.ValueCopy(nil)
+				same = err == nil && bytes.Equal(t, keyType)
+			}
+			owners[longer] = same
+		}
+		if same {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyLayoutDoc 生成各数据类型键编码方式的 Markdown 文档（docs/KEY_LAYOUT.md）
+func KeyLayoutDoc() string {
+	types := make([]string, 0, len(keyLayouts))
+	for t := range keyLayouts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	var b strings.Builder
+	b.WriteString("# Key Layout\n\n")
+	b.WriteString("Generated from the key layout registry in `internal/store/key_layout.go`; do not edit by hand.\n")
+	b.WriteString("Run `go generate ./internal/store` after changing the registry. `DEBUG KEYSPACE-LAYOUT <key>` lists the Badger keys of a live key.\n\n")
+	b.WriteString("Every key also has a type key `" + typeKeyPart.Pattern + "` whose value is the type name below. ")
+	b.WriteString("Exact keys are matched first; prefix rows cover the remaining keys that start with the prefix.\n")
+	b.WriteString("Keys of every type except strings and HyperLogLogs also have an expiration record `" + expireKeyPart.Pattern + "` ")
+	b.WriteString("holding the Unix expiry time in nanoseconds while a TTL is set; strings and HyperLogLogs keep the TTL on their value entry.\n")
+	for _, t := range types {
+		b.WriteString("\n## " + t + "\n\n")
+		b.WriteString("| Role | Badger key | Kind |\n")
+		b.WriteString("|------|------------|------|\n")
+		for _, part := range keyLayouts[t] {
+			kind := "exact"
+			if part.Prefix != nil {
+				kind = "prefix"
+			}
+			if part.Retain {
+				kind += ", kept by DEL"
+			}
+			b.WriteString(fmt.Sprintf("| %s | `%s` | %s |\n", part.Role, part.Pattern, kind))
+		}
+	}
+	return b.String()
+}
+
+// FormatLayoutKey 以可读形式显示 Badger 键：可打印字符原样输出，其余字节转为 \xNN
+func FormatLayoutKey(key []byte) string {
+	var b bytes.Buffer
+	for _, c := range key {
+		if c >= 0x20 && c < 0x7f && c != '\\' {
+			b.WriteByte(c)
+		} else {
+			b.WriteString(fmt.Sprintf("\\x%02x", c))
+		}
+	}
+	return b.String()
+}
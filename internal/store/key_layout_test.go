@@ -0,0 +1,151 @@
+package store
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/zeebo/assert"
+)
+
+func TestKeyLayout(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBadgerStore(dir)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	roles := func(key string) map[string]int {
+		entries, err := store.KeyLayout(key)
+		assert.NoError(t, err)
+		count := make(map[string]int)
+		for _, e := range entries {
+			assert.Equal(t, len(e.Key), e.KeySize)
+			count[e.Role]++
+		}
+		return count
+	}
+
+	// 不存在的键
+	entries, err := store.KeyLayout("missing")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+
+	assert.NoError(t, store.ZAdd("board", []ZSetMember{{Member: "a", Score: 1}, {Member: "b", Score: 2}}))
+	assert.Equal(t, map[string]int{"type": 1, "meta": 1, "index": 2, "member": 2, "rank": 1}, roles("board"))
+
+	assert.NoError(t, store.HSet("user", "name", "bolt"))
+	assert.NoError(t, store.HSet("user", "age", "3"))
+	assert.Equal(t, map[string]int{"type": 1, "meta": 1, "field": 2}, roles("user"))
+
+	// 前缀相同的其他键不计入
+	assert.NoError(t, store.HSet("user:1", "name", "other"))
+	assert.Equal(t, map[string]int{"type": 1, "meta": 1, "field": 2}, roles("user"))
+
+	assert.NoError(t, store.Set("session", "token"))
+	ok, err := store.PExpire("session", 60000)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	entries, err = store.KeyLayout("session")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "type", entries[0].Role)
+	assert.Equal(t, "value", entries[1].Role)
+	assert.Equal(t, "STRING:session", string(entries[1].Key))
+	assert.Equal(t, int64(len("token")), entries[1].ValueSize)
+	now := time.Now().UnixMilli()
+	assert.True(t, entries[1].ExpiresAt > now && entries[1].ExpiresAt <= now+60000)
+
+	assert.Equal(t, `a\x00b\x5c`, FormatLayoutKey([]byte("a\x00b\\")))
+}
+
+// 注册表变化后需重新运行 go generate ./internal/store
+func TestKeyLayoutDocUpToDate(t *testing.T) {
+	doc, err := os.ReadFile("../../docs/KEY_LAYOUT.md")
+	assert.NoError(t, err)
+	assert.Equal(t, KeyLayoutDoc(), string(doc))
+}
+
+// allBadgerKeys 返回实例中的所有 Badger 键
+func allBadgerKeys(t *testing.T, store *BotreonStore) []string {
+	t.Helper()
+	keys := []string{}
+	err := store.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			keys = append(keys, string(it.Item().Key()))
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	return keys
+}
+
+func TestDelRemovesAllKeyData(t *testing.T) {
+	store, err := NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	before := allBadgerKeys(t, store)
+
+	assert.NoError(t, store.Set("str", "v"))
+	_, err = store.RPush("list", "a", "b")
+	assert.NoError(t, err)
+	assert.NoError(t, store.HSet("hash", "f", "v"))
+	_, err = store.SAdd("set", "m")
+	assert.NoError(t, err)
+	assert.NoError(t, store.ZAdd("board", []ZSetMember{{Member: "a", Score: 1}}))
+	assert.NoError(t, store.ZWatch("board", ZWatchConfig{TopN: 3, Channel: "ch"}))
+	_, err = store.JSONSet("doc", "$", `{"a":1}`, false, false)
+	assert.NoError(t, err)
+	_, err = store.XAdd("stream", StreamXAddOptions{}, "*", map[string]string{"f": "v"})
+	assert.NoError(t, err)
+	assert.NoError(t, store.XGroupCreate("stream", "g", "0"))
+	_, err = store.XReadGroup("g", "c", 10, -1, "stream")
+	assert.NoError(t, err)
+	_, err = store.TSAdd("ts", 1000, 1.5, TSAddOptions{})
+	assert.NoError(t, err)
+	_, err = store.GeoAdd("geo", []GeoMember{{Member: "p", Lat: 40, Lon: 116}})
+	assert.NoError(t, err)
+	_, err = store.PFAdd("hll", "x")
+	assert.NoError(t, err)
+
+	keys := []string{"str", "list", "hash", "set", "board", "doc", "stream", "ts", "geo", "hll"}
+	for _, key := range keys {
+		n, err := store.Del(key)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+	}
+	// 只剩排行榜通知配置，它属于键名而不是键的数据
+	assert.Equal(t, append(before, "META:zwatch:board"), allBadgerKeys(t, store))
+	_, watched := store.ZWatchConfigOf("board")
+	assert.True(t, watched)
+
+	// 删除键不影响以它开头的其他键
+	_, err = store.RPush("q", "1")
+	assert.NoError(t, err)
+	_, err = store.RPush("q:1", "2")
+	assert.NoError(t, err)
+	_, err = store.Del("q")
+	assert.NoError(t, err)
+	values, err := store.LRange("q:1", 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2"}, values)
+
+	// RENAME 覆盖目标键时同样删除目标的全部数据，重命名为自身不修改键
+	_, err = store.GeoAdd("geo", []GeoMember{{Member: "p", Lat: 40, Lon: 116}})
+	assert.NoError(t, err)
+	assert.NoError(t, store.Rename("q:1", "geo"))
+	assert.NoError(t, store.Rename("geo", "geo"))
+	values, err = store.LRange("geo", 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2"}, values)
+	entries, err := store.KeyLayout("geo")
+	assert.NoError(t, err)
+	for _, e := range entries {
+		assert.False(t, strings.HasPrefix(string(e.Key), "geo:"))
+	}
+}
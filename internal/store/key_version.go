@@ -0,0 +1,122 @@
+package store
+
+import (
+	"bytes"
+	"hash/fnv"
+	"sync/atomic"
+	"time"
+)
+
+// keyVersionStripes 键版本计数器的分片数。版本按键名哈希到分片上，
+// 同一分片的键互相影响：修改一个键也会使 WATCH 同分片其他键的事务失败（客户端重试即可）
+const keyVersionStripes = 4096
+
+// keyVersions WATCH 使用的键版本计数器。写事务提交后递增所写用户键的版本，
+// FLUSHDB 递增 epoch 使所有键的版本变化。没有连接 WATCH 时不解析写入的键
+type keyVersions struct {
+	watchers atomic.Int64 // 所有连接 WATCH 中的键数
+	epoch    atomic.Uint64
+	stripes  [keyVersionStripes]atomic.Uint64
+}
+
+// exactVersionPrefixes 前缀之后整个剩余部分就是用户键的 Badger 键
+var exactVersionPrefixes = []string{
+	string(prefixKeyTypeBytes), string(prefixKeyExpireBytes), KeyTypeString + ":", string(prefixKeyJSONBytes), "hll:", metaZWatchPrefix,
+}
+
+// compositeVersionPrefixes 用户键之后还有 ":<成员/元数据>" 的 Badger 键。用户键本身可能含 ':'，
+// 无法确定在哪个 ':' 处结束，因此每个可能的前缀都递增（只会多判为修改，不会漏判）
+var compositeVersionPrefixes = []string{
+	KeyTypeList + ":", KeyTypeHash + ":", keyTypeHashTTL + ":", KeyTypeSet + ":",
+	prefixKeySortedSetBytes, prefixStream, prefixTS, prefixKeyGeoBytes,
+}
+
+// KeyWatch WATCH 时记录的键状态，见 WatchKey
+type KeyWatch struct {
+	version   uint64
+	expiresAt time.Time // 零值表示 WATCH 时键没有 TTL
+}
+
+// WatchKey 开始监视 key，返回当前状态供 EXEC 时用 KeyModified 比较。
+// 每次调用都要在 EXEC/DISCARD/UNWATCH 或连接关闭时用 ReleaseWatches 释放
+func (s *BotreonStore) WatchKey(key string) KeyWatch {
+	// 先登记再读取版本，此后提交的写事务一定会递增版本
+	s.versions.watchers.Add(1)
+	w := KeyWatch{version: s.keyVersion(key)}
+	if ttl, err := s.PTTL(key); err == nil && ttl >= 0 {
+		w.expiresAt = s.now().Add(time.Duration(ttl) * time.Millisecond)
+	}
+	return w
+}
+
+// KeyModified key 在 WatchKey 之后是否被写入、删除或已过期
+func (s *BotreonStore) KeyModified(key string, w KeyWatch) bool {
+	if s.keyVersion(key) != w.version {
+		return true
+	}
+	return !w.expiresAt.IsZero() && !s.now().Before(w.expiresAt)
+}
+
+// ReleaseWatches 释放 n 个 WatchKey 登记的监视
+func (s *BotreonStore) ReleaseWatches(n int) {
+	s.versions.watchers.Add(-int64(n))
+}
+
+// keyVersion 键的当前版本。epoch 与分片计数器都只增不减，任一变化都会使和变化
+func (s *BotreonStore) keyVersion(key string) uint64 {
+	return s.versions.epoch.Load() + s.versions.stripes[keyVersionStripe(key)].Load()
+}
+
+func keyVersionStripe(key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum32() % keyVersionStripes
+}
+
+// touchAll 使所有键的版本变化（FLUSHDB 等不经过事务的删除）
+func (s *BotreonStore) touchAll() {
+	s.versions.epoch.Add(1)
+}
+
+// committed 写事务提交后递增所写用户键的版本。watched 为 false 表示事务开始时没有监视，
+// 未收集写入的键；若提交前有连接开始监视，无法确定写了哪些键，只能使所有键的版本变化。
+// written 为 nil 表示读取不到待写的键，同样使所有键的版本变化
+func (s *BotreonStore) committed(watched bool, written [][]byte) {
+	if !watched {
+		if s.versions.watchers.Load() > 0 {
+			s.touchAll()
+		}
+		return
+	}
+	if written == nil {
+		s.touchAll()
+		return
+	}
+	for _, k := range written {
+		userKeyCandidates(k, func(key []byte) {
+			s.versions.stripes[keyVersionStripe(string(key))].Add(1)
+		})
+	}
+}
+
+// userKeyCandidates 对 Badger 键可能所属的每个用户键调用 visit，内部元数据键不属于任何用户键
+func userKeyCandidates(k []byte, visit func(key []byte)) {
+	for _, prefix := range exactVersionPrefixes {
+		if bytes.HasPrefix(k, []byte(prefix)) {
+			visit(k[len(prefix):])
+			return
+		}
+	}
+	for _, prefix := range compositeVersionPrefixes {
+		if !bytes.HasPrefix(k, []byte(prefix)) {
+			continue
+		}
+		rest := k[len(prefix):]
+		for i, c := range rest {
+			if c == ':' {
+				visit(rest[:i])
+			}
+		}
+		return
+	}
+}
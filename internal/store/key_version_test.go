@@ -0,0 +1,104 @@
+package store
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/zeebo/assert"
+)
+
+func TestKeyVersions(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.Set("s", "1"))
+	_, err = store.LPush("list:1", "a")
+	assert.NoError(t, err)
+
+	// 没有 WATCH 时写入不改变版本
+	before := store.keyVersion("s")
+	assert.NoError(t, store.Set("s", "2"))
+	assert.Equal(t, before, store.keyVersion("s"))
+
+	// 写入相同的值也算修改
+	w := store.WatchKey("s")
+	other := store.WatchKey("other")
+	assert.False(t, store.KeyModified("s", w))
+	assert.NoError(t, store.Set("s", "2"))
+	assert.True(t, store.KeyModified("s", w))
+	assert.False(t, store.KeyModified("other", other))
+
+	// 集合类型按成员键归到用户键，键名中的 ':' 不影响
+	w = store.WatchKey("list:1")
+	_, err = store.RPush("list:1", "b")
+	assert.NoError(t, err)
+	assert.True(t, store.KeyModified("list:1", w))
+	w = store.WatchKey("h")
+	assert.NoError(t, store.HSet("h", "f", "v"))
+	assert.True(t, store.KeyModified("h", w))
+	w = store.WatchKey("z")
+	assert.NoError(t, store.ZAdd("z", []ZSetMember{{Member: "m", Score: 1}}))
+	assert.True(t, store.KeyModified("z", w))
+
+	// 删除与 FLUSHDB
+	w = store.WatchKey("s")
+	_, err = store.Del("s")
+	assert.NoError(t, err)
+	assert.True(t, store.KeyModified("s", w))
+	assert.False(t, store.KeyModified("other", other))
+	assert.NoError(t, store.FlushDB())
+	assert.True(t, store.KeyModified("other", other))
+
+	// WATCH 之后过期
+	clock := NewManualClock(time.Now())
+	store.SetClock(clock)
+	assert.NoError(t, store.SetWithTTL("ttl", "v", time.Hour))
+	w = store.WatchKey("ttl")
+	assert.False(t, store.KeyModified("ttl", w))
+	clock.Advance(2 * time.Hour)
+	assert.True(t, store.KeyModified("ttl", w))
+
+	store.ReleaseWatches(7)
+	assert.Equal(t, int64(0), store.versions.watchers.Load())
+}
+
+func TestUserKeyCandidates(t *testing.T) {
+	collect := func(k string) []string {
+		var keys []string
+		userKeyCandidates([]byte(k), func(key []byte) { keys = append(keys, string(key)) })
+		return keys
+	}
+	assert.Equal(t, []string{"a:b"}, collect("TYPE_a:b"))
+	assert.Equal(t, []string{"a:b"}, collect("STRING:a:b"))
+	assert.Equal(t, []string{"user", "user:1"}, collect("HASH:user:1:name"))
+	assert.Equal(t, []string{"q"}, collect("LIST:q:length"))
+	assert.Equal(t, 0, len(collect("META:schedule:1")))
+}
+
+func TestStoreTxnPendingKeys(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	// 有连接 WATCH 时记录写入、条目与删除的键，同一个键只记录一次
+	store.WatchKey("s")
+	var written [][]byte
+	assert.NoError(t, store.update(func(txn *storeTxn) error {
+		assert.NoError(t, txn.Set([]byte("a"), []byte("1")))
+		assert.NoError(t, txn.SetEntry(badger.NewEntry([]byte("b"), []byte("2"))))
+		assert.NoError(t, txn.Delete([]byte("a")))
+		written = txn.pendingKeys()
+		return nil
+	}))
+	sort.Slice(written, func(i, j int) bool { return string(written[i]) < string(written[j]) })
+	assert.DeepEqual(t, [][]byte{[]byte("a"), []byte("b")}, written)
+
+	// 只读事务不记录
+	assert.NoError(t, store.view(func(txn *storeTxn) error {
+		assert.Nil(t, txn.pendingKeys())
+		return nil
+	}))
+}
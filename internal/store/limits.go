@@ -0,0 +1,49 @@
+package store
+
+import "fmt"
+
+const (
+	// DefaultMaxValueSize 单个值（字符串、字段值、成员等）的默认上限，与 Redis 的 proto-max-bulk-len 相同
+	DefaultMaxValueSize = 512 << 20
+	// valueLogHeadroom Badger 条目不能超过 value log 文件大小，为条目头和键预留的空间
+	valueLogHeadroom = 1 << 20
+)
+
+// ValueTooLargeError 写入的值超过 max-value-size
+type ValueTooLargeError struct {
+	Size  int64
+	Limit int64
+}
+
+func (e *ValueTooLargeError) Error() string {
+	return fmt.Sprintf("value of %d bytes exceeds the maximum value size of %d bytes", e.Size, e.Limit)
+}
+
+// effectiveMaxValueSize 配置的上限，零值时使用 DefaultMaxValueSize；
+// Badger 无法保存大于 value log 文件的条目，上限不超过 valueLogFileSize - valueLogHeadroom
+func effectiveMaxValueSize(configured, valueLogFileSize int64) int64 {
+	limit := configured
+	if limit <= 0 {
+		limit = DefaultMaxValueSize
+	}
+	if valueLogFileSize > valueLogHeadroom {
+		limit = min(limit, valueLogFileSize-valueLogHeadroom)
+	}
+	return limit
+}
+
+// MaxValueSize 返回单个值的上限（字节）
+func (s *BotreonStore) MaxValueSize() int64 {
+	if s.maxValueSize <= 0 {
+		return DefaultMaxValueSize
+	}
+	return s.maxValueSize
+}
+
+// CheckValueSize 值的长度超过上限时返回 *ValueTooLargeError
+func (s *BotreonStore) CheckValueSize(n int64) error {
+	if limit := s.MaxValueSize(); n > limit {
+		return &ValueTooLargeError{Size: n, Limit: limit}
+	}
+	return nil
+}
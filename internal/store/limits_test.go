@@ -0,0 +1,49 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestMaxValueSize(t *testing.T) {
+	store, err := NewBotreonStoreWithOptions(t.TempDir(), StoreOptions{MaxValueSize: 16})
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.Equal(t, int64(16), store.MaxValueSize())
+
+	var tooLarge *ValueTooLargeError
+	assert.NoError(t, store.CheckValueSize(16))
+	err = store.CheckValueSize(17)
+	assert.True(t, errors.As(err, &tooLarge))
+	assert.Equal(t, int64(17), tooLarge.Size)
+	assert.Equal(t, int64(16), tooLarge.Limit)
+
+	// APPEND 按追加后的长度检查
+	n, err := store.APPEND("k", "0123456789")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, n)
+	_, err = store.APPEND("k", "0123456789")
+	assert.True(t, errors.As(err, &tooLarge))
+	assert.Equal(t, int64(20), tooLarge.Size)
+	value, err := store.Get("k")
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", value)
+
+	// 过大的 offset 在分配内存之前就被拒绝
+	_, err = store.SetRange("k", 1<<32, "x")
+	assert.True(t, errors.As(err, &tooLarge))
+	_, err = store.SetBit("bits", 1<<35, 1)
+	assert.True(t, errors.As(err, &tooLarge))
+	_, err = store.SetBit("bits", 127, 1)
+	assert.NoError(t, err)
+}
+
+func TestEffectiveMaxValueSize(t *testing.T) {
+	assert.Equal(t, int64(DefaultMaxValueSize), effectiveMaxValueSize(0, 1<<30))
+	assert.Equal(t, int64(1024), effectiveMaxValueSize(1024, 1<<30))
+	// 不超过 value log 文件大小
+	assert.Equal(t, int64(256<<20-valueLogHeadroom), effectiveMaxValueSize(0, 256<<20))
+	assert.Equal(t, int64(1<<30-valueLogHeadroom), effectiveMaxValueSize(4<<30, 1<<30))
+}
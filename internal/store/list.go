@@ -1,6 +1,8 @@
 package store
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"strings"
@@ -9,19 +11,60 @@ import (
 	"github.com/lbp0200/BoltDB/internal/helper"
 
 	"github.com/dgraph-io/badger/v4"
-	"github.com/google/uuid"
 )
 
-// ListNode 结构体定义了链表节点的结构
-// ID 是节点的唯一标识符，使用字符串存储
-// Value 是节点存储的数据，以字节切片形式存储
-// Prev 是指向前一个节点的 ID，使用字符串存储
-// Next 是指向后一个节点的 ID，使用字符串存储
-type ListNode struct {
-	ID    string
-	Value []byte // 节点存储的值，以字节切片形式表示
-	Prev  string // 指向前一个节点的 ID
-	Next  string // 指向后一个节点的 ID
+// 列表的存储布局：
+//
+//	LIST:<key>:length        元素个数
+//	LIST:<key>:head          第一个元素的序号
+//	LIST:<key>:e:<seq:8>     元素，序号为大端 uint64，从 head 到 head+length-1 连续
+//
+// LPUSH/LPOP 在 head 一侧、RPUSH/RPOP 在尾部一侧分配或删除序号，只读写常数个键；
+// 元素键按序号排序，LRANGE 等范围读取用一个迭代器顺序扫描
+const listSeqOrigin = uint64(1) << 63 // 空列表第一个元素的序号，两侧各有 2^63 个序号可用
+
+// listMeta 列表的元数据
+type listMeta struct {
+	length uint64
+	head   uint64
+}
+
+// tail 最后一个元素的序号，length 为 0 时没有意义
+func (m listMeta) tail() uint64 {
+	return m.head + m.length - 1
+}
+
+// seq 将 Redis 索引（支持负数）转换为序号，越界时返回 false
+func (m listMeta) seq(index int64) (uint64, bool) {
+	// #nosec G115 - length is bounded by practical list size limits
+	n := int64(m.length)
+	if index < 0 {
+		index += n
+	}
+	if index < 0 || index >= n {
+		return 0, false
+	}
+	// #nosec G115 - index is non-negative here
+	return m.head + uint64(index), true
+}
+
+// span 将 Redis 的 [start, stop] 范围（支持负数）裁剪到列表内，范围为空时返回 false
+func (m listMeta) span(start, stop int64) (int64, int64, bool) {
+	// #nosec G115 - length is bounded by practical list size limits
+	n := int64(m.length)
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	return start, stop, start <= stop
 }
 
 // listKey 方法用于生成存储在 Badger 数据库中的键
@@ -32,12 +75,22 @@ func (s *BotreonStore) listKey(key string, parts ...string) string {
 	return fmt.Sprintf("%s:%s:%s", KeyTypeList, key, strings.Join(parts, ":"))
 }
 
+// listElemPrefix 列表元素键的前缀 LIST:<key>:e:
+func listElemPrefix(key string) []byte {
+	return []byte(KeyTypeList + ":" + key + ":e:")
+}
+
+// listElemKey 序号为 seq 的元素键
+func listElemKey(key string, seq uint64) []byte {
+	return binary.BigEndian.AppendUint64(listElemPrefix(key), seq)
+}
+
 // listLength 方法用于获取链表的长度
 // key 是链表的主键，以字节切片形式传入
 // 返回链表的长度（无符号 64 位整数）和可能出现的错误
 func (s *BotreonStore) listLength(key string) (uint64, error) {
 	var length uint64
-	errView := s.db.View(func(txn *badger.Txn) error {
+	errView := s.view(func(txn *storeTxn) error {
 		// 获取长度
 		// 通过 listKey 方法生成存储长度信息的键
 		lengthItem, err := txn.Get([]byte(s.listKey(key, "length")))
@@ -57,189 +110,204 @@ func (s *BotreonStore) listLength(key string) (uint64, error) {
 	return length, errView
 }
 
-func (s *BotreonStore) listGetMeta(keyRedis string) (length uint64, start, end string, err error) {
-	err = s.db.View(func(txn *badger.Txn) error {
-		// 获取长度
-		lengthItem, errGet := txn.Get([]byte(s.listKey(keyRedis, "length")))
-		if errGet != nil {
-			if errors.Is(errGet, badger.ErrKeyNotFound) {
-				length = 0
-				start = ""
-				end = ""
-				return nil // 列表不存在，返回默认值
-			}
-			return errGet
-		}
-		lengthVal, errValueCopy := lengthItem.ValueCopy(nil)
-		if errValueCopy != nil {
-			return errValueCopy
-		}
-		length = helper.BytesToUint64(lengthVal)
-
-		// 获取起始节点
-		startItem, errStart := txn.Get([]byte(s.listKey(keyRedis, "start")))
-		if errStart == nil {
-			startVal, _ := startItem.ValueCopy(nil)
-			start = string(startVal)
-		}
+// listMetaTxn 在 txn 中读取列表的长度与首元素序号，列表不存在时长度为 0
+func (s *BotreonStore) listMetaTxn(txn *storeTxn, key string) (listMeta, error) {
+	meta := listMeta{head: listSeqOrigin}
+	item, err := txn.Get([]byte(s.listKey(key, "length")))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return meta, nil
+	}
+	if err != nil {
+		return meta, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return meta, err
+	}
+	meta.length = helper.BytesToUint64(val)
 
-		// 获取结束节点
-		endItem, errEnd := txn.Get([]byte(s.listKey(keyRedis, "end")))
-		if errEnd == nil {
-			endVal, _ := endItem.ValueCopy(nil)
-			end = string(endVal)
-		}
-		return nil
-	})
-	return
+	item, err = txn.Get([]byte(s.listKey(key, "head")))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return meta, nil
+	}
+	if err != nil {
+		return meta, err
+	}
+	val, err = item.ValueCopy(nil)
+	if err != nil {
+		return meta, err
+	}
+	meta.head = helper.BytesToUint64(val)
+	return meta, nil
 }
 
-func (s *BotreonStore) listUpdateMeta(txn *badger.Txn, key string, length uint64, start, end string) error {
-	// 更新长度
-	if err := txn.Set([]byte(s.listKey(key, "length")), helper.Uint64ToBytes(length)); err != nil {
+// listSetMeta 在 txn 中写入列表的元数据
+func (s *BotreonStore) listSetMeta(txn *storeTxn, key string, meta listMeta) error {
+	if err := txn.Set([]byte(s.listKey(key, "length")), helper.Uint64ToBytes(meta.length)); err != nil {
 		return err
 	}
-	// 更新起始节点
-	if err := txn.Set([]byte(s.listKey(key, "start")), []byte(start)); err != nil {
-		return err
-	}
-	// 更新结束节点
-	return txn.Set([]byte(s.listKey(key, "end")), []byte(end))
+	return txn.Set([]byte(s.listKey(key, "head")), helper.Uint64ToBytes(meta.head))
 }
 
-func (s *BotreonStore) createNode(txn *badger.Txn, key string, value []byte) (string, error) {
-	nodeID := uuid.New().String()
-	nodeKey := s.listKey(key, nodeID)
-	if err := txn.Set([]byte(nodeKey), value); err != nil {
+// listGetTxn 在 txn 中读取序号为 seq 的元素
+func listGetTxn(txn *storeTxn, key string, seq uint64) (string, error) {
+	item, err := txn.Get(listElemKey(key, seq))
+	if err != nil {
 		return "", err
 	}
-	return nodeID, nil
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return "", err
+	}
+	return string(val), nil
 }
 
-func (s *BotreonStore) linkNodes(txn *badger.Txn, key string, prevID, nextID string) error {
-	// 更新前节点的next指针
-	if prevID != "" {
-		prevNextKey := s.listKey(key, prevID, "next")
-		if err := txn.Set([]byte(prevNextKey), []byte(nextID)); err != nil {
+// listScan 从序号 from 开始顺序（reverse 时逆序）遍历 meta 范围内的列表元素，fn 返回 false 时停止。
+// 范围外可能还有 LTRIM 等尚未回收的元素键（见 reclaimList），不会访问。
+// expected 为预期读取的元素个数（-1 表示不确定），用于选择迭代器的预取大小
+func (s *BotreonStore) listScan(txn *storeTxn, key string, meta listMeta, from uint64, reverse bool, expected int64, fn func(seq uint64, item *badger.Item) (bool, error)) error {
+	prefix := listElemPrefix(key)
+	opts := s.iteratorOptions(prefix, expected, true)
+	opts.Reverse = reverse
+	iter := txn.NewIterator(opts)
+	defer iter.Close()
+	for iter.Seek(listElemKey(key, from)); iter.Valid(); iter.Next() {
+		item := iter.Item()
+		k := item.Key()
+		if len(k) != len(prefix)+8 {
+			continue // 名称以 <key>:e: 开头的其他列表的键
+		}
+		seq := binary.BigEndian.Uint64(k[len(prefix):])
+		if meta.length == 0 || seq < meta.head || seq > meta.tail() {
+			return nil
+		}
+		more, err := fn(seq, item)
+		if err != nil || !more {
 			return err
 		}
 	}
-	// 更新后节点的prev指针
-	if nextID != "" {
-		nextPrevKey := s.listKey(key, nextID, "prev")
-		return txn.Set([]byte(nextPrevKey), []byte(prevID))
-	}
 	return nil
 }
 
+// pushTxn 在 txn 中将值推入列表头部（left）或尾部，返回推入后的长度
+func (s *BotreonStore) pushTxn(txn *storeTxn, key string, values []string, left bool) (uint64, error) {
+	if err := txn.Set(TypeOfKeyGet(key), []byte(KeyTypeList)); err != nil {
+		return 0, err
+	}
+	meta, err := s.listMetaTxn(txn, key)
+	if err != nil {
+		return 0, err
+	}
+	if meta.length == 0 {
+		meta.head = listSeqOrigin
+	}
+	for _, value := range values {
+		seq := meta.head + meta.length
+		switch {
+		case meta.length == 0:
+			seq = meta.head
+		case left:
+			meta.head--
+			seq = meta.head
+		}
+		if err := txn.Set(listElemKey(key, seq), []byte(value)); err != nil {
+			return 0, err
+		}
+		meta.length++
+	}
+	return meta.length, s.listSetMeta(txn, key, meta)
+}
+
+// popTxn 在 txn 中弹出列表头部（left）或尾部的元素，列表为空时 ok 为 false
+func (s *BotreonStore) popTxn(txn *storeTxn, key string, left bool) (value string, ok bool, err error) {
+	meta, err := s.listMetaTxn(txn, key)
+	if err != nil || meta.length == 0 {
+		return "", false, err
+	}
+	seq := meta.tail()
+	if left {
+		seq = meta.head
+		meta.head++
+	}
+	if value, err = listGetTxn(txn, key, seq); err != nil {
+		return "", false, err
+	}
+	if err := txn.Delete(listElemKey(key, seq)); err != nil {
+		return "", false, err
+	}
+	meta.length--
+	if meta.length == 0 {
+		// 与 Redis 相同，弹出最后一个元素后删除键
+		_, err = s.delTxn(txn, key)
+		return value, true, err
+	}
+	return value, true, s.listSetMeta(txn, key, meta)
+}
+
+// popCountTxn 在 txn 中从列表头部（left）或尾部弹出最多 count 个元素，键不存在时 ok 为 false
+func (s *BotreonStore) popCountTxn(txn *storeTxn, key string, count int64, left bool) (values []string, ok bool, err error) {
+	meta, err := s.listMetaTxn(txn, key)
+	if err != nil || meta.length == 0 {
+		return nil, false, err
+	}
+	// #nosec G115 - count 非负
+	n := min(uint64(count), meta.length)
+	values = make([]string, 0, n)
+	for i := uint64(0); i < n; i++ {
+		seq := meta.tail() - i
+		if left {
+			seq = meta.head + i
+		}
+		value, err := listGetTxn(txn, key, seq)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := txn.Delete(listElemKey(key, seq)); err != nil {
+			return nil, false, err
+		}
+		values = append(values, value)
+	}
+	if n == meta.length {
+		_, err = s.delTxn(txn, key)
+		return values, true, err
+	}
+	if left {
+		meta.head += n
+	}
+	meta.length -= n
+	return values, true, s.listSetMeta(txn, key, meta)
+}
+
 // LPush Redis LPUSH 实现
 func (s *BotreonStore) LPush(key string, values ...string) (int, error) {
 	s.keyLockMgr.Lock(key)
 	defer s.keyLockMgr.Unlock(key)
 
 	var finalLength uint64
-	err := s.db.Update(func(txn *badger.Txn) error {
-		if err := txn.Set(TypeOfKeyGet(key), []byte(KeyTypeList)); err != nil {
-			return err
-		}
-		length, start, end, _ := s.listGetMeta(key)
-		for _, value := range values {
-			// 创建新节点
-			nodeID, err := s.createNode(txn, key, []byte(value))
-			if err != nil {
-				return err
-			}
-
-			// 链接节点
-			if length == 0 { // 空链表
-				start = nodeID
-				end = nodeID
-				if err := s.linkNodes(txn, key, nodeID, nodeID); err != nil {
-					return err
-				}
-			} else {
-				// 链接新节点和原头节点
-				if err := s.linkNodes(txn, key, nodeID, start); err != nil {
-					return err
-				}
-				// 更新原头节点的prev指针
-				if err := txn.Set([]byte(s.listKey(key, start, "prev")), []byte(nodeID)); err != nil {
-					return err
-				}
-				start = nodeID
-			}
-			length++
-		}
-
-		// 更新元数据
-		finalLength = length
-		return s.listUpdateMeta(txn, key, length, start, end)
-	})
+	// 元数据在同一事务中读写，与并发的 LPOP/RPOP 冲突时重试
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		var err error
+		finalLength, err = s.pushTxn(txn, key, values, true)
+		return err
+	}, 30)
 
-	// Notify blocking pop waiters
-	s.notifyBlockingPop(key, values[0])
+	if err == nil {
+		s.notifyBlockingPop(key, len(values))
+	}
 
+	// #nosec G115 - length is bounded by practical list size limits
 	return int(finalLength), err // 返回操作后列表的长度（Redis规范）
 }
 
 // RPOP 实现
 func (s *BotreonStore) RPop(key string) (string, error) {
 	var value string
-	err := s.db.Update(func(txn *badger.Txn) error {
-		length, start, end, err := s.listGetMeta(key)
-		if err != nil {
-			// 如果列表不存在，返回空字符串
-			if length == 0 {
-				return nil
-			}
-			return err
-		}
-		if length == 0 {
-			return nil
-		}
-
-		// 获取尾节点值
-		endNodeKey := s.listKey(key, end)
-		item, err := txn.Get([]byte(endNodeKey))
-		if err != nil {
-			return err
-		}
-		valueBytes, _ := item.ValueCopy(nil)
-		value = string(valueBytes)
-
-		// 获取新的尾节点
-		var newEnd string
-		if length > 1 {
-			newEndKey := s.listKey(key, end, "prev")
-			item, err = txn.Get([]byte(newEndKey))
-			if err != nil {
-				return err
-			}
-			newEndVal, _ := item.ValueCopy(nil)
-			newEnd = string(newEndVal)
-		} else {
-			newEnd = ""
-			start = ""
-		}
-
-		// 更新链表关系
-		if length > 1 {
-			// 断开旧尾节点连接
-			if err := s.linkNodes(txn, key, newEnd, start); err != nil {
-				return err
-			}
-		}
-
-		// 删除旧节点数据
-		if err := txn.Delete([]byte(endNodeKey)); err != nil {
-			return err
-		}
-		_ = txn.Delete([]byte(s.listKey(key, end, "prev")))
-		_ = txn.Delete([]byte(s.listKey(key, end, "next")))
-
-		// 更新元数据
-		return s.listUpdateMeta(txn, key, length-1, start, newEnd)
-	})
+	// 元数据在同一事务中读取，并发弹出同一元素时冲突重试，不会重复返回
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		var err error
+		value, _, err = s.popTxn(txn, key, false)
+		return err
+	}, 30)
 	return value, err
 }
 
@@ -249,786 +317,479 @@ func (s *BotreonStore) LLen(key string) (uint64, error) {
 	return length, err
 }
 
-// getNodeByIndex 根据索引获取节点ID和值
-func (s *BotreonStore) getNodeByIndex(txn *badger.Txn, key string, index int64) (string, string, error) {
-	length, start, _, err := s.listGetMeta(key)
-	if err != nil {
-		return "", "", err
-	}
-	if length == 0 {
-		return "", "", nil
-	}
-
-	// 处理负数索引
-	if index < 0 {
-	// #nosec G115 - length is bounded by practical list size limits
-		index = int64(length) + index
-	}
-	// #nosec G115 - length is bounded by practical list size limits
-	if index < 0 || index >= int64(length) {
-		return "", "", nil
-	}
-
-	// 从头部或尾部开始遍历
-	var currentNodeID string
-	var currentIndex int64
-	visited := make(map[string]bool)
-	// #nosec G115 - length is bounded by practical list size limits
-	if index < int64(length)/2 {
-		// 从头部开始
-		currentNodeID = start
-		currentIndex = 0
-		for currentIndex < index {
-			// 防止循环链表导致的无限循环
-			if visited[currentNodeID] {
-				return "", "", nil
-			}
-			visited[currentNodeID] = true
-			nextKey := s.listKey(key, currentNodeID, "next")
-			item, err := txn.Get([]byte(nextKey))
-			if err != nil {
-				return "", "", err
-			}
-			nextVal, _ := item.ValueCopy(nil)
-			currentNodeID = string(nextVal)
-			currentIndex++
-		}
-	} else {
-		// 从尾部开始
-		_, _, end, _ := s.listGetMeta(key)
-		currentNodeID = end
-	// #nosec G115 - length is bounded by practical list size limits
-		currentIndex = int64(length) - 1
-		for currentIndex > index {
-			// 防止循环链表导致的无限循环
-			if visited[currentNodeID] {
-				return "", "", nil
-			}
-			visited[currentNodeID] = true
-			prevKey := s.listKey(key, currentNodeID, "prev")
-			item, err := txn.Get([]byte(prevKey))
-			if err != nil {
-				return "", "", err
-			}
-			prevVal, _ := item.ValueCopy(nil)
-			currentNodeID = string(prevVal)
-			currentIndex--
-		}
-	}
-
-	// 获取节点值
-	nodeKey := s.listKey(key, currentNodeID)
-	item, err := txn.Get([]byte(nodeKey))
-	if err != nil {
-		return "", "", err
-	}
-	valueBytes, _ := item.ValueCopy(nil)
-	return currentNodeID, string(valueBytes), nil
-}
-
 // RPUSH 实现 Redis RPUSH 命令
 func (s *BotreonStore) RPush(key string, values ...string) (int, error) {
 	s.keyLockMgr.Lock(key)
 	defer s.keyLockMgr.Unlock(key)
 
+	// 元数据在同一事务中读写，与并发的 LPOP/RPOP 冲突时重试
 	var finalLength uint64
-	err := s.db.Update(func(txn *badger.Txn) error {
-		if err := txn.Set(TypeOfKeyGet(key), []byte(KeyTypeList)); err != nil {
-			return err
-		}
-		length, start, end, _ := s.listGetMeta(key)
-		for _, value := range values {
-			// 创建新节点
-			nodeID, err := s.createNode(txn, key, []byte(value))
-			if err != nil {
-				return err
-			}
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		var err error
+		finalLength, err = s.rpushTxn(txn, key, values)
+		return err
+	}, 30)
 
-			// 链接节点
-			if length == 0 { // 空链表
-				start = nodeID
-				end = nodeID
-				if err := s.linkNodes(txn, key, nodeID, nodeID); err != nil {
-					return err
-				}
-			} else {
-				// 链接新节点和原尾节点
-				if err := s.linkNodes(txn, key, end, nodeID); err != nil {
-					return err
-				}
-				// 更新原尾节点的next指针
-				if err := txn.Set([]byte(s.listKey(key, end, "next")), []byte(nodeID)); err != nil {
-					return err
-				}
-				end = nodeID
-			}
-			length++
-		}
+	if err == nil {
+		s.notifyBlockingPop(key, len(values))
+	}
 
-		// 更新元数据
-		finalLength = length
-		return s.listUpdateMeta(txn, key, length, start, end)
-	})
 	// #nosec G115 - length is bounded by practical list size limits
-
-	// Notify blocking pop waiters
-	s.notifyBlockingPop(key, values[0])
-
 	return int(finalLength), err // 返回操作后列表的长度（Redis规范）
 }
 
+// rpushTxn 在 txn 中将值追加到列表尾部，返回追加后的长度
+func (s *BotreonStore) rpushTxn(txn *storeTxn, key string, values []string) (uint64, error) {
+	return s.pushTxn(txn, key, values, false)
+}
+
 // LPOP 实现 Redis LPOP 命令
 func (s *BotreonStore) LPop(key string) (string, error) {
 	var value string
-	err := s.db.Update(func(txn *badger.Txn) error {
-		length, start, end, err := s.listGetMeta(key)
-		if err != nil {
-			if length == 0 {
-				return nil
-			}
-			return err
-		}
-		if length == 0 {
-			return nil
-		}
-
-		// 获取头节点值
-		startNodeKey := s.listKey(key, start)
-		item, err := txn.Get([]byte(startNodeKey))
-		if err != nil {
-			return err
-		}
-		valueBytes, _ := item.ValueCopy(nil)
-		value = string(valueBytes)
-
-		// 获取新的头节点
-		var newStart string
-		if length > 1 {
-			newStartKey := s.listKey(key, start, "next")
-			item, err = txn.Get([]byte(newStartKey))
-			if err != nil {
-				return err
-			}
-			newStartVal, _ := item.ValueCopy(nil)
-			newStart = string(newStartVal)
-		} else {
-			newStart = ""
-			end = ""
-		}
+	// 元数据在同一事务中读取，并发弹出同一元素时冲突重试，不会重复返回
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		var err error
+		value, _, err = s.popTxn(txn, key, true)
+		return err
+	}, 30)
+	return value, err
+}
 
-		// 更新链表关系
-		if length > 1 {
-			// 断开旧头节点连接
-			if err := s.linkNodes(txn, key, end, newStart); err != nil {
-				return err
-			}
-		}
+// LPopCount 实现 LPOP key count：从头部弹出最多 count 个元素，键不存在时 ok 为 false。
+// 元素可以是空字符串，调用方按 ok 区分键不存在
+func (s *BotreonStore) LPopCount(key string, count int64) ([]string, bool, error) {
+	return s.popCount(key, count, true)
+}
 
-		// 删除旧节点数据
-		if err := txn.Delete([]byte(startNodeKey)); err != nil {
-			return err
-		}
-		_ = txn.Delete([]byte(s.listKey(key, start, "prev")))
-		_ = txn.Delete([]byte(s.listKey(key, start, "next")))
+// RPopCount 实现 RPOP key count，见 LPopCount
+func (s *BotreonStore) RPopCount(key string, count int64) ([]string, bool, error) {
+	return s.popCount(key, count, false)
+}
 
-		// 更新元数据
-		return s.listUpdateMeta(txn, key, length-1, newStart, end)
-	})
-	return value, err
+func (s *BotreonStore) popCount(key string, count int64, left bool) ([]string, bool, error) {
+	var values []string
+	var ok bool
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		var err error
+		values, ok, err = s.popCountTxn(txn, key, count, left)
+		return err
+	}, 30)
+	return values, ok, err
 }
 
 // LINDEX 实现 Redis LINDEX 命令
 func (s *BotreonStore) LIndex(key string, index int64) (string, error) {
 	var value string
-	err := s.db.View(func(txn *badger.Txn) error {
-		_, val, err := s.getNodeByIndex(txn, key, index)
+	err := s.view(func(txn *storeTxn) error {
+		meta, err := s.listMetaTxn(txn, key)
 		if err != nil {
 			return err
 		}
-		value = val
-		return nil
+		seq, ok := meta.seq(index)
+		if !ok {
+			return nil
+		}
+		value, err = listGetTxn(txn, key, seq)
+		return err
 	})
 	return value, err
 }
 
-// LRANGE 实现 Redis LRANGE 命令
+// LRANGE 实现 Redis LRANGE 命令，从起始元素开始用一个迭代器顺序读取
 func (s *BotreonStore) LRange(key string, start, stop int64) ([]string, error) {
 	var result []string
-	err := s.db.View(func(txn *badger.Txn) error {
-		length, startID, _, err := s.listGetMeta(key)
+	err := s.view(func(txn *storeTxn) error {
+		meta, err := s.listMetaTxn(txn, key)
 		if err != nil {
-			return nil // 列表不存在，返回空列表
-		}
-		if length == 0 {
-			return nil
-		}
-
-		// 处理负数索引
-		if start < 0 {
-		// #nosec G115 - length is bounded by practical list size limits
-		start = int64(length) + start
-		}
-		if stop < 0 {
-		// #nosec G115 - length is bounded by practical list size limits
-		stop = int64(length) + stop
-		}
-		if start < 0 {
-			start = 0
-		}
-	// #nosec G115 - length is bounded by practical list size limits
-		if stop >= int64(length) {
-			stop = int64(length) - 1
+			return err
 		}
-		if start > stop {
+		start, stop, ok := meta.span(start, stop)
+		if !ok {
 			return nil
 		}
-
-		// 找到起始节点
-		currentNodeID := startID
-		currentIndex := int64(0)
-		visitedStart := make(map[string]bool)
-		for currentIndex < start {
-			// 防止循环链表导致的无限循环
-			if visitedStart[currentNodeID] {
-				return nil
-			}
-			visitedStart[currentNodeID] = true
-			nextKey := s.listKey(key, currentNodeID, "next")
-			item, err := txn.Get([]byte(nextKey))
+		count := stop - start + 1
+		result = make([]string, 0, count)
+		// #nosec G115 - start is non-negative here
+		return s.listScan(txn, key, meta, meta.head+uint64(start), false, count, func(_ uint64, item *badger.Item) (bool, error) {
+			val, err := item.ValueCopy(nil)
 			if err != nil {
-				return err
-			}
-			nextVal, _ := item.ValueCopy(nil)
-			currentNodeID = string(nextVal)
-			currentIndex++
-		}
-
-		// 收集范围内的值
-		visited := make(map[string]bool)
-		for currentIndex <= stop {
-			// 防止循环链表导致的无限循环
-			if visited[currentNodeID] {
-				break
-			}
-			visited[currentNodeID] = true
-
-			nodeKey := s.listKey(key, currentNodeID)
-			item, err := txn.Get([]byte(nodeKey))
-			if err != nil {
-				return err
+				return false, err
 			}
-			valueBytes, _ := item.ValueCopy(nil)
-			result = append(result, string(valueBytes))
-
-			if currentIndex < stop {
-				nextKey := s.listKey(key, currentNodeID, "next")
-				item, err = txn.Get([]byte(nextKey))
-				if err != nil {
-					return err
-				}
-				nextVal, _ := item.ValueCopy(nil)
-				currentNodeID = string(nextVal)
-			}
-			currentIndex++
-		}
-		return nil
+			result = append(result, string(val))
+			return int64(len(result)) < count, nil
+		})
 	})
 	return result, err
 }
 
 // LSET 实现 Redis LSET 命令
 func (s *BotreonStore) LSet(key string, index int64, value string) error {
-	return s.db.Update(func(txn *badger.Txn) error {
-		nodeID, _, err := s.getNodeByIndex(txn, key, index)
+	return s.update(func(txn *storeTxn) error {
+		if _, err := txn.Get(TypeOfKeyGet(key)); errors.Is(err, badger.ErrKeyNotFound) {
+			return fmt.Errorf("no such key")
+		}
+		meta, err := s.listMetaTxn(txn, key)
 		if err != nil {
 			return err
 		}
-		if nodeID == "" {
+		seq, ok := meta.seq(index)
+		if !ok {
 			return fmt.Errorf("index out of range")
 		}
-
-		// 更新节点值
-		nodeKey := s.listKey(key, nodeID)
-		return txn.Set([]byte(nodeKey), []byte(value))
+		return txn.Set(listElemKey(key, seq), []byte(value))
 	})
 }
 
 // LPos 实现 Redis LPOS 命令，返回元素在列表中的索引
 // element: 要查找的元素
-// rank: 正数从头部开始，负数从尾部开始
+// rank: 正数从头部开始，负数从尾部开始，跳过前 |rank|-1 个匹配（0 与 1 相同）
 // count: 返回多个匹配位置，0 表示返回所有
 // maxlen: 最大扫描长度，0 表示扫描整个列表
 func (s *BotreonStore) LPos(key string, element string, rank, count, maxlen int64) ([]int64, error) {
 	var results []int64
-
-	err := s.db.View(func(txn *badger.Txn) error {
-		length, _, _, err := s.listGetMeta(key)
-		if err != nil {
-			return nil // 列表不存在，返回空
-		}
-		if length == 0 {
-			return nil
-		}
-
-		// 计算实际扫描长度
-		scanLen := int64(length)
-		if maxlen > 0 && maxlen < scanLen {
-			scanLen = maxlen
+	err := s.view(func(txn *storeTxn) error {
+		meta, err := s.listMetaTxn(txn, key)
+		if err != nil || meta.length == 0 {
+			return err
 		}
-
-		// 计算起始索引
-		startIdx := int64(0)
-		if rank < 0 {
-			// 从尾部开始，需要调整
-			// #nosec G115 - length is bounded by practical list size limits
-			startIdx = int64(length) + rank
-			if startIdx < 0 {
-				startIdx = 0
+		reverse := rank < 0
+		skip := max(rank, -rank, 1) - 1
+		from := meta.head
+		if reverse {
+			from = meta.tail()
+		}
+		target := []byte(element)
+		scanned := int64(0)
+		return s.listScan(txn, key, meta, from, reverse, -1, func(seq uint64, item *badger.Item) (bool, error) {
+			if maxlen > 0 && scanned >= maxlen {
+				return false, nil
+			}
+			scanned++
+			var match bool
+			if err := item.Value(func(val []byte) error {
+				match = bytes.Equal(val, target)
+				return nil
+			}); err != nil {
+				return false, err
 			}
-		}
-
-		matches := int64(0)
-		found := int64(0)
-		for i := startIdx; i < scanLen; i++ {
-			_, val, err := s.getNodeByIndex(txn, key, i)
-			if err != nil {
-				continue
+			if !match {
+				return true, nil
 			}
-			if val == element {
-				matches++
-				// 检查是否达到 rank
-				if rank > 0 && matches < rank {
-					continue
-				}
-				if rank < 0 && matches < -rank {
-					continue
-				}
-				found++
-				results = append(results, i)
-
-				// 如果只需要一个结果，或者达到了 count 限制
-				if (count == 0 && rank == 0) || (count > 0 && found >= count) {
-					break
-				}
+			if skip > 0 {
+				skip--
+				return true, nil
 			}
-		}
-
-		return nil
+			// #nosec G115 - seq is within [head, tail]
+			results = append(results, int64(seq-meta.head))
+			// 没有 COUNT 与 RANK 时只返回第一个匹配
+			if count == 0 && rank == 0 {
+				return false, nil
+			}
+			return count == 0 || int64(len(results)) < count, nil
+		})
 	})
-
 	return results, err
 }
 
-// LTRIM 实现 Redis LTRIM 命令
+// LTRIM 实现 Redis LTRIM 命令，只删除范围两侧的元素。删除的元素不超过 listBatch 个时在同一个事务中删除，
+// 否则事务中只更新元数据并登记回收范围，提交后分批删除（见 reclaimList）
 func (s *BotreonStore) LTrim(key string, start, stop int64) error {
-	return s.db.Update(func(txn *badger.Txn) error {
-		length, startID, _, err := s.listGetMeta(key)
-		if err != nil {
-			return nil // 列表不存在，无需操作
-		}
-		if length == 0 {
-			return nil
-		}
-
-		// 处理负数索引
-		if start < 0 {
-		// #nosec G115 - length is bounded by practical list size limits
-		start = int64(length) + start
-		}
-		if stop < 0 {
-		// #nosec G115 - length is bounded by practical list size limits
-		stop = int64(length) + stop
-		}
-		if start < 0 {
-			start = 0
-		}
-	// #nosec G115 - length is bounded by practical list size limits
-		if stop >= int64(length) {
-			stop = int64(length) - 1
+	reclaim := false
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		reclaim = false
+		meta, err := s.listMetaTxn(txn, key)
+		if err != nil || meta.length == 0 {
+			return err
 		}
-		if start > stop {
+		start, stop, ok := meta.span(start, stop)
+		if !ok {
 			// 删除整个列表
-			return s.deleteList(txn, key)
+			reclaim, err = s.deleteList(txn, key, meta)
+			return err
 		}
-
-		// 找到新的起始和结束节点
-		var newStartID, newEndID string
-		currentNodeID := startID
-		currentIndex := int64(0)
-		visitedTrim := make(map[string]bool)
-
-		// 找到起始节点
-		for currentIndex < start {
-			// 防止循环链表导致的无限循环
-			if visitedTrim[currentNodeID] {
-				return nil
-			}
-			visitedTrim[currentNodeID] = true
-			nextKey := s.listKey(key, currentNodeID, "next")
-			item, err := txn.Get([]byte(nextKey))
-			if err != nil {
+		// #nosec G115 - start and stop are within [0, length) here
+		first, last := meta.head+uint64(start), meta.head+uint64(stop)
+		if meta.length-(last-first+1) > listBatch {
+			reclaim = true
+			if err := markListReclaimTxn(txn, key, meta.head, meta.tail()); err != nil {
 				return err
 			}
-			nextVal, _ := item.ValueCopy(nil)
-			oldNodeID := currentNodeID
-			currentNodeID = string(nextVal)
-			// 删除旧节点
-			s.deleteNode(txn, key, oldNodeID)
-			currentIndex++
+			return s.listSetMeta(txn, key, listMeta{length: last - first + 1, head: first})
 		}
-		newStartID = currentNodeID
-
-		// 找到结束节点
-		for currentIndex < stop {
-			// 防止循环链表导致的无限循环
-			if visitedTrim[currentNodeID] {
-				return nil
-			}
-			visitedTrim[currentNodeID] = true
-			nextKey := s.listKey(key, currentNodeID, "next")
-			item, err := txn.Get([]byte(nextKey))
-			if err != nil {
+		for seq := meta.head; seq < first; seq++ {
+			if err := txn.Delete(listElemKey(key, seq)); err != nil {
 				return err
 			}
-			nextVal, _ := item.ValueCopy(nil)
-			currentNodeID = string(nextVal)
-			currentIndex++
 		}
-		newEndID = currentNodeID
-
-		// 删除结束节点之后的所有节点
-		visitedDelete := make(map[string]bool)
-		visitedDelete[newStartID] = true
-		visitedDelete[newEndID] = true
-		currentNodeID = newEndID
-		for {
-			nextKey := s.listKey(key, currentNodeID, "next")
-			item, err := txn.Get([]byte(nextKey))
-			if err != nil {
-				break
-			}
-			nextVal, _ := item.ValueCopy(nil)
-			nextID := string(nextVal)
-			if nextID == newStartID {
-				break // 循环回到起始节点
-			}
-			if visitedDelete[nextID] {
-				break // 防止无限循环
+		for seq := last + 1; seq <= meta.tail(); seq++ {
+			if err := txn.Delete(listElemKey(key, seq)); err != nil {
+				return err
 			}
-			visitedDelete[nextID] = true
-			s.deleteNode(txn, key, nextID)
-			currentNodeID = nextID
 		}
-
-		// 更新链表关系
-		if err := txn.Set([]byte(s.listKey(key, newStartID, "prev")), []byte(newEndID)); err != nil {
-			return err
-		}
-		if err := txn.Set([]byte(s.listKey(key, newEndID, "next")), []byte(newStartID)); err != nil {
-			return err
-		}
-
-		// 更新元数据
-	// #nosec G115 - stop and start are bounded by practical list size limits
-		newLength := uint64(stop - start + 1)
-		return s.listUpdateMeta(txn, key, newLength, newStartID, newEndID)
-	})
+		return s.listSetMeta(txn, key, listMeta{length: last - first + 1, head: first})
+	}, 30)
+	if err == nil && reclaim {
+		err = s.reclaimList(key)
+	}
+	return err
 }
 
-// deleteNode 删除一个节点
-func (s *BotreonStore) deleteNode(txn *badger.Txn, key, nodeID string) {
-	_ = txn.Delete([]byte(s.listKey(key, nodeID)))
-	_ = txn.Delete([]byte(s.listKey(key, nodeID, "prev")))
-	_ = txn.Delete([]byte(s.listKey(key, nodeID, "next")))
+// deleteList 在 txn 中删除整个列表。元素不超过 listBatch 个时全部删除；否则只删除类型键、过期时间与元数据，
+// 键立即不存在，登记元素的回收范围并返回 true，调用方提交后调用 reclaimList
+func (s *BotreonStore) deleteList(txn *storeTxn, key string, meta listMeta) (bool, error) {
+	if meta.length <= listBatch {
+		_, err := s.delTxn(txn, key)
+		return false, err
+	}
+	if err := s.deleteListMetaTxn(txn, key); err != nil {
+		return false, err
+	}
+	return true, markListReclaimTxn(txn, key, meta.head, meta.tail())
 }
 
-// deleteList 删除整个列表
-func (s *BotreonStore) deleteList(txn *badger.Txn, key string) error {
-	_, start, _, _ := s.listGetMeta(key)
-	if start != "" {
-		// 遍历删除所有节点
-		currentNodeID := start
-		visited := make(map[string]bool)
-		for {
-			if visited[currentNodeID] {
-				break
-			}
-			visited[currentNodeID] = true
-			s.deleteNode(txn, key, currentNodeID)
-			nextKey := s.listKey(key, currentNodeID, "next")
-			item, err := txn.Get([]byte(nextKey))
-			if err != nil {
-				break
-			}
-			nextVal, _ := item.ValueCopy(nil)
-			currentNodeID = string(nextVal)
-			if currentNodeID == start {
-				break
-			}
+// deleteListMetaTxn 在 txn 中删除列表的类型键、过期时间与元数据，不删除元素
+func (s *BotreonStore) deleteListMetaTxn(txn *storeTxn, key string) error {
+	for _, k := range [][]byte{TypeOfKeyGet(key), expireKeyGet(key), []byte(s.listKey(key, "length")), []byte(s.listKey(key, "head"))} {
+		if err := txn.Delete(k); err != nil {
+			return err
 		}
 	}
-	// 删除元数据
-	_ = txn.Delete([]byte(s.listKey(key, "length")))
-	_ = txn.Delete([]byte(s.listKey(key, "start")))
-	_ = txn.Delete([]byte(s.listKey(key, "end")))
 	return nil
 }
 
-// LINSERT 实现 Redis LINSERT 命令
+// LINSERT 实现 Redis LINSERT 命令。顺序扫描找到 pivot 后，
+// 把插入位置较短一侧的元素各移动一个序号，腾出位置。
+// 移动的元素超过单个事务的大小上限时改为重写整个列表（见 listRewrite）
 func (s *BotreonStore) LInsert(key string, where string, pivot, value string) (int, error) {
 	count := 0
-	err := s.db.Update(func(txn *badger.Txn) error {
-		length, start, _, err := s.listGetMeta(key)
-		if err != nil || length == 0 {
-			return nil // 列表不存在或为空
-		}
-
-		// 查找pivot节点
-		currentNodeID := start
-		var pivotNodeID string
-		visited := make(map[string]bool)
-		for i := uint64(0); i < length; i++ {
-			// 防止循环链表导致的无限循环
-			if visited[currentNodeID] {
-				break
-			}
-			visited[currentNodeID] = true
-			nodeKey := s.listKey(key, currentNodeID)
-			item, err := txn.Get([]byte(nodeKey))
-			if err != nil {
-				// 节点不存在，停止遍历
-				break
-			}
-			valueBytes, _ := item.ValueCopy(nil)
-			if string(valueBytes) == pivot {
-				pivotNodeID = currentNodeID
-				break
+	target := []byte(pivot)
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		count = 0
+		meta, err := s.listMetaTxn(txn, key)
+		if err != nil || meta.length == 0 {
+			return err // 列表不存在或为空
+		}
+
+		// 查找 pivot
+		var pivotSeq uint64
+		found := false
+		err = s.listScan(txn, key, meta, meta.head, false, -1, func(seq uint64, item *badger.Item) (bool, error) {
+			err := item.Value(func(val []byte) error {
+				found = bytes.Equal(val, target)
+				return nil
+			})
+			pivotSeq = seq
+			return !found, err
+		})
+		if err != nil || !found {
+			return err // pivot不存在
+		}
+
+		// 新元素插入后的位置
+		pos := pivotSeq - meta.head
+		if where != "BEFORE" {
+			pos++
+		}
+		var seq uint64
+		if pos <= meta.length/2 {
+			// 前 pos 个元素向头部移动一位
+			if err := s.listShift(txn, key, meta, meta.head, pos, false); err != nil {
+				return err
 			}
-			nextKey := s.listKey(key, currentNodeID, "next")
-			item, err = txn.Get([]byte(nextKey))
-			if err != nil {
-				// next指针不存在，停止遍历
-				break
+			meta.head--
+			seq = meta.head + pos
+		} else {
+			// 从 pos 开始的元素向尾部移动一位
+			if err := s.listShift(txn, key, meta, meta.tail(), meta.length-pos, true); err != nil {
+				return err
 			}
-			nextVal, _ := item.ValueCopy(nil)
-			currentNodeID = string(nextVal)
-		}
-
-		if pivotNodeID == "" {
-			return nil // pivot不存在
+			seq = meta.head + pos
 		}
-
-		// 创建新节点
-		newNodeID, err := s.createNode(txn, key, []byte(value))
-		if err != nil {
+		if err := txn.Set(listElemKey(key, seq), []byte(value)); err != nil {
 			return err
 		}
+		meta.length++
+		count = 1
+		return s.listSetMeta(txn, key, meta)
+	}, 30)
+	if !errors.Is(err, badger.ErrTxnTooBig) {
+		return count, err
+	}
 
-		if where == "BEFORE" {
-			// 在pivot之前插入
-			prevKey := s.listKey(key, pivotNodeID, "prev")
-			item, err := txn.Get([]byte(prevKey))
-			var prevNodeID string
-			if err == nil {
-				prevVal, _ := item.ValueCopy(nil)
-				prevNodeID = string(prevVal)
-			} else if errors.Is(err, badger.ErrKeyNotFound) {
-				// 如果prev不存在，说明pivot是第一个节点，prevNodeID应该是end（循环链表）
-				_, _, end, _ := s.listGetMeta(key)
-				prevNodeID = end
-			} else {
-				return err
-			}
-
-			// 更新链接
-			if err := s.linkNodes(txn, key, prevNodeID, newNodeID); err != nil {
-				return err
-			}
-			if err := s.linkNodes(txn, key, newNodeID, pivotNodeID); err != nil {
-				return err
-			}
-
-			// 如果插入在头部，更新start
-			_, _, end, _ := s.listGetMeta(key)
-			newStart := start
-			newEnd := end
-			if pivotNodeID == start {
-				newStart = newNodeID
-			}
-			if err := s.listUpdateMeta(txn, key, length+1, newStart, newEnd); err != nil {
-				return err
-			}
-		} else {
-			// 在pivot之后插入
-			nextKey := s.listKey(key, pivotNodeID, "next")
-			item, err := txn.Get([]byte(nextKey))
+	err = s.listRewrite(key, func(snap *storeTxn, meta listMeta, emit func(val []byte) error) (bool, error) {
+		count = 0
+		err := s.listScan(snap, key, meta, meta.head, false, -1, func(_ uint64, item *badger.Item) (bool, error) {
+			val, err := item.ValueCopy(nil)
 			if err != nil {
-				return err
-			}
-			nextVal, _ := item.ValueCopy(nil)
-			nextNodeID := string(nextVal)
-
-			// 更新链接
-			if err := s.linkNodes(txn, key, pivotNodeID, newNodeID); err != nil {
-				return err
-			}
-			if err := s.linkNodes(txn, key, newNodeID, nextNodeID); err != nil {
-				return err
+				return false, err
 			}
-
-			// 如果插入在尾部，更新end
-			_, _, end, err := s.listGetMeta(key)
-			if err != nil {
-				return err
+			match := count == 0 && bytes.Equal(val, target)
+			if match && where == "BEFORE" {
+				if err := emit([]byte(value)); err != nil {
+					return false, err
+				}
 			}
-			newStart := start
-			newEnd := end
-			if pivotNodeID == end {
-				newEnd = newNodeID
+			if err := emit(val); err != nil {
+				return false, err
 			}
-			if err := s.listUpdateMeta(txn, key, length+1, newStart, newEnd); err != nil {
-				return err
+			if match {
+				count = 1
+				if where != "BEFORE" {
+					return true, emit([]byte(value))
+				}
 			}
-		}
-		count = 1
-		return nil
+			return true, nil
+		})
+		return count == 1, err
 	})
 	return count, err
 }
 
-// LREM 实现 Redis LREM 命令
+// listShift 将从序号 from 开始的 n 个元素各移动一位：reverse 为 false 时向前（序号减一），
+// 为 true 时从 from 逆序遍历并向后移动（序号加一）。先遍历到的元素先移动，不会覆盖尚未读取的元素
+func (s *BotreonStore) listShift(txn *storeTxn, key string, meta listMeta, from, n uint64, reverse bool) error {
+	if n == 0 {
+		return nil
+	}
+	moved := uint64(0)
+	// #nosec G115 - n is bounded by practical list size limits
+	return s.listScan(txn, key, meta, from, reverse, int64(n), func(seq uint64, item *badger.Item) (bool, error) {
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return false, err
+		}
+		dst := seq - 1
+		if reverse {
+			dst = seq + 1
+		}
+		if err := txn.Set(listElemKey(key, dst), val); err != nil {
+			return false, err
+		}
+		moved++
+		return moved < n, nil
+	})
+}
+
+// LREM 实现 Redis LREM 命令。count >= 0 时从头部扫描，删除匹配的元素，
+// 之后的元素在同一次扫描中依次前移填补空位；count < 0 时从尾部反向进行。
+// 扫描结束后删除末端腾出的序号，不需要把整个列表读入内存。
+// 移动与删除的元素超过单个事务的大小上限时改为重写整个列表（见 listRewrite）
 func (s *BotreonStore) LRem(key string, count int64, value string) (int, error) {
 	removed := 0
-	err := s.db.Update(func(txn *badger.Txn) error {
-		length, start, _, err := s.listGetMeta(key)
-		if err != nil || length == 0 {
-			return nil
+	target := []byte(value)
+	limit := max(count, -count)
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		removed = 0
+		meta, err := s.listMetaTxn(txn, key)
+		if err != nil || meta.length == 0 {
+			return err
 		}
 
-		var nodesToRemove []string
-		currentNodeID := start
-		visitedCount := 0
-		visitedNodes := make(map[string]bool)
-
-		// 收集要删除的节点
-	// #nosec G115 - length is bounded by practical list size limits
-		for visitedCount < int(length) {
-			// 防止循环链表导致的无限循环
-			if visitedNodes[currentNodeID] {
-				break
-			}
-			visitedNodes[currentNodeID] = true
-
-			nodeKey := s.listKey(key, currentNodeID)
-			item, err := txn.Get([]byte(nodeKey))
-			if err != nil {
-				break
+		reverse := count < 0
+		from := meta.head
+		if reverse {
+			from = meta.tail()
+		}
+		var next uint64 // 下一个保留元素写入的序号
+		err = s.listScan(txn, key, meta, from, reverse, -1, func(seq uint64, item *badger.Item) (bool, error) {
+			var match bool
+			if limit == 0 || int64(removed) < limit {
+				if err := item.Value(func(val []byte) error {
+					match = bytes.Equal(val, target)
+					return nil
+				}); err != nil {
+					return false, err
+				}
 			}
-			valueBytes, _ := item.ValueCopy(nil)
-			if string(valueBytes) == value {
-				nodesToRemove = append(nodesToRemove, currentNodeID)
-				if count > 0 && len(nodesToRemove) >= int(count) {
-					break
+			if match {
+				if removed == 0 {
+					next = seq
 				}
+				removed++
+				return true, nil
 			}
-
-			nextKey := s.listKey(key, currentNodeID, "next")
-			item, err = txn.Get([]byte(nextKey))
+			if removed == 0 {
+				return true, nil
+			}
+			val, err := item.ValueCopy(nil)
 			if err != nil {
-				break
+				return false, err
 			}
-			nextVal, _ := item.ValueCopy(nil)
-			currentNodeID = string(nextVal)
-			visitedCount++
-		}
-
-		// 如果count < 0，从尾部开始
-		if count < 0 {
-			_, _, end, _ := s.listGetMeta(key)
-			currentNodeID = end
-			visitedCount = 0
-			visitedNodes = make(map[string]bool)
-			nodesToRemove = []string{}
-		// #nosec G115 - length is bounded by practical list size limits
-		for visitedCount < int(length) {
-				// 防止循环链表导致的无限循环
-				if visitedNodes[currentNodeID] {
-					break
-				}
-				visitedNodes[currentNodeID] = true
-
-				nodeKey := s.listKey(key, currentNodeID)
-				item, err := txn.Get([]byte(nodeKey))
-				if err != nil {
-					break
-				}
-				valueBytes, _ := item.ValueCopy(nil)
-				if string(valueBytes) == value {
-					nodesToRemove = append(nodesToRemove, currentNodeID)
-					if len(nodesToRemove) >= int(-count) {
-						break
-					}
-				}
-
-				prevKey := s.listKey(key, currentNodeID, "prev")
-				item, err = txn.Get([]byte(prevKey))
-				if err != nil {
-					break
-				}
-				prevVal, _ := item.ValueCopy(nil)
-				currentNodeID = string(prevVal)
-				visitedCount++
+			if err := txn.Set(listElemKey(key, next), val); err != nil {
+				return false, err
 			}
+			if reverse {
+				next--
+			} else {
+				next++
+			}
+			return true, nil
+		})
+		if err != nil || removed == 0 {
+			return err
 		}
 
-		// 获取当前start和end
-		_, currentStart, currentEnd, _ := s.listGetMeta(key)
-
-		// 删除节点
-		for _, nodeID := range nodesToRemove {
-			// 获取前后节点
-			prevKey := s.listKey(key, nodeID, "prev")
-			nextKey := s.listKey(key, nodeID, "next")
-			prevItem, _ := txn.Get([]byte(prevKey))
-			nextItem, _ := txn.Get([]byte(nextKey))
-			var prevID, nextID string
-			if prevItem != nil {
-				prevVal, _ := prevItem.ValueCopy(nil)
-				prevID = string(prevVal)
-			}
-			if nextItem != nil {
-				nextVal, _ := nextItem.ValueCopy(nil)
-				nextID = string(nextVal)
+		// 删除末端腾出的序号
+		// #nosec G115 - removed is bounded by length
+		n := uint64(removed)
+		for i := uint64(0); i < n; i++ {
+			seq := meta.tail() - i
+			if reverse {
+				seq = meta.head + i
 			}
-
-			// 更新链接
-			if err := s.linkNodes(txn, key, prevID, nextID); err != nil {
+			if err := txn.Delete(listElemKey(key, seq)); err != nil {
 				return err
 			}
-
-			// 更新start和end
-			if nodeID == currentStart {
-				currentStart = nextID
-			}
-			if nodeID == currentEnd {
-				currentEnd = prevID
-			}
-
-			// 删除节点
-			s.deleteNode(txn, key, nodeID)
-			removed++
 		}
+		if n == meta.length {
+			// 与 Redis 相同，删除最后一个元素后删除键
+			_, err := s.delTxn(txn, key)
+			return err
+		}
+		if reverse {
+			meta.head += n
+		}
+		meta.length -= n
+		return s.listSetMeta(txn, key, meta)
+	}, 30)
+	if !errors.Is(err, badger.ErrTxnTooBig) {
+		return removed, err
+	}
 
-		// 更新元数据
-		if removed > 0 {
-			newLength := length - uint64(removed)
-			if newLength == 0 {
-				currentStart = ""
-				currentEnd = ""
+	err = s.listRewrite(key, func(snap *storeTxn, meta listMeta, emit func(val []byte) error) (bool, error) {
+		removed = 0
+		// count < 0 时删除最后 -count 个匹配：先数出匹配数，保留前面多出的匹配
+		keep := int64(0)
+		if count < 0 {
+			matches := int64(0)
+			err := s.listScan(snap, key, meta, meta.head, false, -1, func(_ uint64, item *badger.Item) (bool, error) {
+				return true, item.Value(func(val []byte) error {
+					if bytes.Equal(val, target) {
+						matches++
+					}
+					return nil
+				})
+			})
+			if err != nil {
+				return false, err
 			}
-			return s.listUpdateMeta(txn, key, newLength, currentStart, currentEnd)
+			keep = max(matches-limit, 0)
 		}
-		return nil
+		err := s.listScan(snap, key, meta, meta.head, false, -1, func(_ uint64, item *badger.Item) (bool, error) {
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return false, err
+			}
+			if (limit == 0 || int64(removed) < limit) && bytes.Equal(val, target) {
+				if keep == 0 {
+					removed++
+					return true, nil
+				}
+				keep--
+			}
+			return true, emit(val)
+		})
+		return removed > 0, err
 	})
 	return removed, err
 }
@@ -1036,88 +797,132 @@ func (s *BotreonStore) LRem(key string, count int64, value string) (int, error)
 // RPOPLPUSH 实现 Redis RPOPLPUSH 命令
 func (s *BotreonStore) RPopLPush(source, destination string) (string, error) {
 	var value string
-	err := s.db.Update(func(txn *badger.Txn) error {
+	// 两个列表的元数据都在事务中读取，与并发的推入/弹出冲突时重试
+	err := s.retryUpdate(func(txn *storeTxn) error {
 		// 从源列表弹出
-		sourceLength, sourceStart, sourceEnd, err := s.listGetMeta(source)
-		if err != nil || sourceLength == 0 {
-			return nil // 源列表不存在或为空
+		v, ok, err := s.popTxn(txn, source, false)
+		value = v
+		if err != nil || !ok {
+			return err // 源列表不存在或为空
 		}
+		// 推入目标列表头部
+		_, err = s.pushTxn(txn, destination, []string{v}, true)
+		return err
+	}, 30)
+	if err == nil && value != "" {
+		s.notifyBlockingPop(destination, 1)
+	}
+	return value, err
+}
 
-		// 获取源列表尾节点值
-		endNodeKey := s.listKey(source, sourceEnd)
-		item, err := txn.Get([]byte(endNodeKey))
-		if err != nil {
-			return err
-		}
-		valueBytes, _ := item.ValueCopy(nil)
-		value = string(valueBytes)
-
-		// 获取新的源列表尾节点
-		var newSourceEnd string
-		if sourceLength > 1 {
-			newEndKey := s.listKey(source, sourceEnd, "prev")
-			item, err = txn.Get([]byte(newEndKey))
-			if err != nil {
+// migrateLegacyLists 将旧版本以 UUID 双向链表保存的列表（LIST:<key>:start、LIST:<key>:end、
+// LIST:<key>:<node id>[:prev, :next]）转换为序号布局，每个列表一个事务。启动时调用
+func (s *BotreonStore) migrateLegacyLists() error {
+	var keys []string
+	err := s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefixKeyTypeBytes
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			item := iter.Item()
+			isList := false
+			if err := item.Value(func(val []byte) error {
+				isList = string(val) == KeyTypeList
+				return nil
+			}); err != nil {
 				return err
 			}
-			newEndVal, _ := item.ValueCopy(nil)
-			newSourceEnd = string(newEndVal)
-		} else {
-			newSourceEnd = ""
-			sourceStart = ""
-		}
-
-		// 更新源列表
-		if sourceLength > 1 {
-			if err := s.linkNodes(txn, source, newSourceEnd, sourceStart); err != nil {
+			if !isList {
+				continue
+			}
+			key := string(item.Key()[len(prefixKeyTypeBytes):])
+			if _, err := txn.Get([]byte(s.listKey(key, "start"))); err == nil {
+				keys = append(keys, key)
+			} else if !errors.Is(err, badger.ErrKeyNotFound) {
 				return err
 			}
 		}
-		s.deleteNode(txn, source, sourceEnd)
-		if err := s.listUpdateMeta(txn, source, sourceLength-1, sourceStart, newSourceEnd); err != nil {
-			return err
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.update(func(txn *storeTxn) error {
+			return s.migrateLegacyList(txn, key)
+		}); err != nil {
+			return fmt.Errorf("migrate list %q: %w", key, err)
 		}
+	}
+	return nil
+}
 
-		// 推入目标列表头部
-		if err := txn.Set(TypeOfKeyGet(destination), []byte(KeyTypeList)); err != nil {
+// migrateLegacyList 沿 next 指针遍历旧链表，按顺序写入元素键并删除旧节点
+func (s *BotreonStore) migrateLegacyList(txn *storeTxn, key string) error {
+	item, err := txn.Get([]byte(s.listKey(key, "length")))
+	if err != nil {
+		return err
+	}
+	lengthVal, err := item.ValueCopy(nil)
+	if err != nil {
+		return err
+	}
+	length := helper.BytesToUint64(lengthVal)
+	nodeID := ""
+	if item, err := txn.Get([]byte(s.listKey(key, "start"))); err == nil {
+		val, err := item.ValueCopy(nil)
+		if err != nil {
 			return err
 		}
-		destLength, destStart, destEnd, _ := s.listGetMeta(destination)
+		nodeID = string(val)
+	}
 
-		// 创建新节点
-		newNodeID, err := s.createNode(txn, destination, []byte(value))
+	meta := listMeta{head: listSeqOrigin}
+	visited := make(map[string]bool)
+	for meta.length < length && nodeID != "" && !visited[nodeID] {
+		visited[nodeID] = true
+		value, err := txn.Get([]byte(s.listKey(key, nodeID)))
+		if err != nil {
+			break // 链表不完整，保留已经读到的部分
+		}
+		val, err := value.ValueCopy(nil)
 		if err != nil {
 			return err
 		}
-
-		// 链接节点
-		if destLength == 0 {
-			destStart = newNodeID
-			destEnd = newNodeID
-			if err := s.linkNodes(txn, destination, newNodeID, newNodeID); err != nil {
-				return err
-			}
-		} else {
-			if err := s.linkNodes(txn, destination, newNodeID, destStart); err != nil {
+		if err := txn.Set(listElemKey(key, meta.head+meta.length), val); err != nil {
+			return err
+		}
+		meta.length++
+		next := ""
+		if item, err := txn.Get([]byte(s.listKey(key, nodeID, "next"))); err == nil {
+			v, err := item.ValueCopy(nil)
+			if err != nil {
 				return err
 			}
-			if err := txn.Set([]byte(s.listKey(destination, destStart, "prev")), []byte(newNodeID)); err != nil {
+			next = string(v)
+		}
+		for _, k := range []string{s.listKey(key, nodeID), s.listKey(key, nodeID, "prev"), s.listKey(key, nodeID, "next")} {
+			if err := txn.Delete([]byte(k)); err != nil {
 				return err
 			}
-			destStart = newNodeID
 		}
-
-		// 更新目标列表元数据
-		return s.listUpdateMeta(txn, destination, destLength+1, destStart, destEnd)
-	})
-	return value, err
+		nodeID = next
+	}
+	if err := txn.Delete([]byte(s.listKey(key, "start"))); err != nil {
+		return err
+	}
+	if err := txn.Delete([]byte(s.listKey(key, "end"))); err != nil {
+		return err
+	}
+	return s.listSetMeta(txn, key, meta)
 }
 
 // LPUSHX 实现 Redis LPUSHX 命令，仅当键存在时左推入
 func (s *BotreonStore) LPUSHX(key string, values ...string) (int, error) {
 	// 先检查键是否存在且是List类型（在 View 事务中）
 	var isList bool
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		typeKey := TypeOfKeyGet(key)
 		item, err := txn.Get(typeKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -1152,7 +957,7 @@ func (s *BotreonStore) LPUSHX(key string, values ...string) (int, error) {
 func (s *BotreonStore) RPUSHX(key string, values ...string) (int, error) {
 	// 先检查键是否存在且是List类型（在 View 事务中）
 	var isList bool
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		typeKey := TypeOfKeyGet(key)
 		item, err := txn.Get(typeKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -1253,102 +1058,40 @@ func (s *BotreonStore) BLMove(source, destination, sourceDirection, destinationD
 	return s.LMove(source, destination, sourceDirection, destinationDirection)
 }
 
-// notifyBlockingPop notifies one waiting channel for a key
-func (s *BotreonStore) notifyBlockingPop(key, value string) {
-	s.blockingMu.Lock()
-	defer s.blockingMu.Unlock()
-
-	chans, exists := s.blockingPopChans[key]
-	if !exists || len(chans) == 0 {
-		return
-	}
-
-	// Notify the first waiting channel
-	select {
-	case chans[0] <- BlockingResult{Key: key, Value: value}:
-		// Remove the notified channel
-		s.blockingPopChans[key] = chans[1:]
-	default:
-		// Channel not ready, keep it
-	}
-}
-
-// registerBlockingPop registers a channel to wait for a key
-func (s *BotreonStore) registerBlockingPop(key string, ch chan BlockingResult) {
-	s.blockingMu.Lock()
-	defer s.blockingMu.Unlock()
-
-	s.blockingPopChans[key] = append(s.blockingPopChans[key], ch)
-}
-
 // BLPOPBlocking implements blocking left pop with timeout
 func (s *BotreonStore) BLPOPBlocking(keys []string, timeout int) (string, string, error) {
+	pop := func() (string, string, error) { return s.popFirst(keys, s.LPop) }
 	// Try non-blocking first
-	for _, key := range keys {
-		value, err := s.LPop(key)
-		if err == nil && value != "" {
-			return key, value, nil
-		}
-	}
-
-	// If timeout is 0, return immediately
-	if timeout == 0 {
-		return "", "", nil
-	}
-
-	// Create result channel
-	resultCh := make(chan BlockingResult, 1)
-	timeoutCh := time.After(time.Duration(timeout) * time.Second)
-
-	// Register this channel for each key
-	s.blockingMu.Lock()
-	for _, key := range keys {
-		s.blockingPopChans[key] = append(s.blockingPopChans[key], resultCh)
-	}
-	s.blockingMu.Unlock()
-
-	// Wait for data or timeout
-	select {
-	case result := <-resultCh:
-		return result.Key, result.Value, nil
-	case <-timeoutCh:
-		return "", "", nil
+	key, value, err := pop()
+	if err != nil || value != "" || timeout == 0 {
+		return key, value, err
 	}
+	return s.waitBlockingPop(keys, time.Duration(timeout)*time.Second, pop)
 }
 
 // BRPOPBlocking implements blocking right pop with timeout
 func (s *BotreonStore) BRPOPBlocking(keys []string, timeout int) (string, string, error) {
+	pop := func() (string, string, error) { return s.popFirst(keys, s.RPop) }
 	// Try non-blocking first
-	for _, key := range keys {
-		value, err := s.RPop(key)
-		if err == nil && value != "" {
-			return key, value, nil
-		}
-	}
-
-	// If timeout is 0, return immediately
-	if timeout == 0 {
-		return "", "", nil
+	key, value, err := pop()
+	if err != nil || value != "" || timeout == 0 {
+		return key, value, err
 	}
+	return s.waitBlockingPop(keys, time.Duration(timeout)*time.Second, pop)
+}
 
-	// Create result channel
-	resultCh := make(chan BlockingResult, 1)
-	timeoutCh := time.After(time.Duration(timeout) * time.Second)
-
-	// Register this channel for each key
-	s.blockingMu.Lock()
+// popFirst 按顺序对 keys 调用 pop，返回第一个取到的值
+func (s *BotreonStore) popFirst(keys []string, pop func(key string) (string, error)) (string, string, error) {
 	for _, key := range keys {
-		s.blockingPopChans[key] = append(s.blockingPopChans[key], resultCh)
-	}
-	s.blockingMu.Unlock()
-
-	// Wait for data or timeout
-	select {
-	case result := <-resultCh:
-		return result.Key, result.Value, nil
-	case <-timeoutCh:
-		return "", "", nil
+		value, err := pop(key)
+		if err != nil {
+			return "", "", err
+		}
+		if value != "" {
+			return key, value, nil
+		}
 	}
+	return "", "", nil
 }
 
 // BRPOPLPUSHBlocking implements blocking rpoplpush with timeout
@@ -1361,21 +1104,11 @@ func (s *BotreonStore) BRPOPLPUSHBlocking(source, destination string, timeout in
 		return value, nil
 	}
 
-	timeoutCh := time.After(time.Duration(timeout) * time.Second)
-
-	for {
+	_, value, err := s.waitBlockingPop([]string{source}, time.Duration(timeout)*time.Second, func() (string, string, error) {
 		value, err := s.RPopLPush(source, destination)
-		if err == nil && value != "" {
-			return value, nil
-		}
-
-		select {
-		case <-timeoutCh:
-			return "", nil
-		default:
-			time.Sleep(10 * time.Millisecond)
-		}
-	}
+		return source, value, err
+	})
+	return value, err
 }
 
 // BLMoveBlocking implements blocking lmove with timeout
@@ -1384,19 +1117,9 @@ func (s *BotreonStore) BLMoveBlocking(source, destination, sourceDirection, dest
 		return s.LMove(source, destination, sourceDirection, destinationDirection)
 	}
 
-	timeoutCh := time.After(time.Duration(timeout) * time.Second)
-
-	for {
+	_, value, err := s.waitBlockingPop([]string{source}, time.Duration(timeout*float64(time.Second)), func() (string, string, error) {
 		value, err := s.LMove(source, destination, sourceDirection, destinationDirection)
-		if err == nil && value != "" {
-			return value, nil
-		}
-
-		select {
-		case <-timeoutCh:
-			return "", nil
-		default:
-			time.Sleep(10 * time.Millisecond)
-		}
-	}
+		return source, value, err
+	})
+	return value, err
 }
@@ -0,0 +1,270 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/lbp0200/BoltDB/internal/logger"
+)
+
+// metaListReclaimPrefix 有元素键等待回收的列表（不带 TYPE_ 前缀，不会出现在 KEYS/SCAN 中），
+// 值为需要检查的序号范围 [from, to]，两个大端 uint64。范围内序号不在列表 [head, tail] 中的元素键
+// 都是 LTRIM、DEL 大列表或重写留下的，读取时不会访问（见 listScan）；回收完成后删除条目，重启后继续回收
+const metaListReclaimPrefix = "META:listreclaim:"
+
+const (
+	// listBatch 分批修改大列表时每个事务写入或删除的元素数，避免超出 Badger 的事务大小限制
+	listBatch = 1000
+	// listRewriteAttempts 列表在重写期间被其他命令修改时重新开始的次数
+	listRewriteAttempts = 3
+)
+
+// errListChanged 重写期间列表被其他命令修改
+var errListChanged = errors.New("list was modified during rewrite")
+
+// markListReclaimTxn 在 txn 中登记需要回收的序号范围，与尚未回收完成的范围合并
+func markListReclaimTxn(txn *storeTxn, key string, from, to uint64) error {
+	marker := []byte(metaListReclaimPrefix + key)
+	item, err := txn.Get(marker)
+	if err == nil {
+		err = item.Value(func(val []byte) error {
+			if len(val) == 16 {
+				from = min(from, binary.BigEndian.Uint64(val))
+				to = max(to, binary.BigEndian.Uint64(val[8:]))
+			}
+			return nil
+		})
+	}
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		return err
+	}
+	return txn.Set(marker, binary.BigEndian.AppendUint64(binary.BigEndian.AppendUint64(nil, from), to))
+}
+
+// reclaimList 分批删除登记范围内不属于列表的元素键，完成后删除条目。
+// 每个批次在事务中重新读取元数据，范围内已被重新使用的序号（如 LPUSH 写入了腾出的位置）保留
+func (s *BotreonStore) reclaimList(key string) error {
+	marker := []byte(metaListReclaimPrefix + key)
+	var from, to, version uint64
+	err := s.view(func(txn *storeTxn) error {
+		item, err := txn.Get(marker)
+		if err != nil {
+			return err
+		}
+		version = item.Version()
+		return item.Value(func(val []byte) error {
+			if len(val) != 16 {
+				return fmt.Errorf("invalid list reclaim range for %q", key)
+			}
+			from, to = binary.BigEndian.Uint64(val), binary.BigEndian.Uint64(val[8:])
+			return nil
+		})
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	prefix := listElemPrefix(key)
+	for seek, done := from, false; !done; {
+		err := s.retryUpdate(func(txn *storeTxn) error {
+			done = true
+			meta, err := s.listMetaTxn(txn, key)
+			if err != nil {
+				return err
+			}
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			opts.Prefix = prefix
+			it := txn.NewIterator(opts)
+			var keys [][]byte
+			for it.Seek(listElemKey(key, seek)); it.Valid(); it.Next() {
+				k := it.Item().Key()
+				if len(k) != len(prefix)+8 {
+					continue // 名称以 <key>:e: 开头的其他列表的键
+				}
+				seq := binary.BigEndian.Uint64(k[len(prefix):])
+				if seq > to {
+					break
+				}
+				if len(keys) == listBatch {
+					seek, done = seq, false
+					break
+				}
+				if meta.length > 0 && seq >= meta.head && seq <= meta.tail() {
+					continue
+				}
+				keys = append(keys, it.Item().KeyCopy(nil))
+			}
+			it.Close()
+			for _, k := range keys {
+				if err := txn.Delete(k); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, 30)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 回收期间又登记了新的范围时保留条目，由登记的调用方回收
+	return s.retryUpdate(func(txn *storeTxn) error {
+		item, err := txn.Get(marker)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if item.Version() != version {
+			return nil
+		}
+		return txn.Delete(marker)
+	}, 30)
+}
+
+// reclaimPendingLists 回收上次关闭前未完成回收的列表。启动时调用
+func (s *BotreonStore) reclaimPendingLists() error {
+	var keys []string
+	err := s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(metaListReclaimPrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			keys = append(keys, string(it.Item().Key()[len(metaListReclaimPrefix):]))
+		}
+		return nil
+	})
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	logger.Logger.Info().Int("keys", len(keys)).Msg("继续回收列表的元素")
+	for _, key := range keys {
+		if err := s.reclaimList(key); err != nil {
+			return fmt.Errorf("reclaim list %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// listRewrite 用于单个事务放不下的 LREM 与 LINSERT：rewrite 在同一个快照中顺序读取列表，
+// 把新列表的元素依次交给 emit，分批写入尾部之后的新序号区间；全部写入后在一个事务中把元数据切换到新区间，
+// 再回收旧的元素。切换前读者看到的一直是原来的列表。rewrite 返回 false 时列表不变，丢弃已写入的元素。
+// 列表在此期间被其他命令修改时重新开始，listRewriteAttempts 次后返回 errListChanged
+func (s *BotreonStore) listRewrite(key string, rewrite func(snap *storeTxn, meta listMeta, emit func(val []byte) error) (bool, error)) error {
+	var err error
+	for i := 0; i < listRewriteAttempts; i++ {
+		if err = s.listRewriteOnce(key, rewrite); !errors.Is(err, errListChanged) {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *BotreonStore) listRewriteOnce(key string, rewrite func(snap *storeTxn, meta listMeta, emit func(val []byte) error) (bool, error)) error {
+	snap := &storeTxn{Txn: s.db.NewTransaction(false)}
+	defer snap.Discard()
+	meta, err := s.listMetaTxn(snap, key)
+	if err != nil || meta.length == 0 {
+		return err
+	}
+	readTs := snap.ReadTs()
+
+	// 先登记旧区间与新区间（新列表最多比原来多一个元素），中途失败或进程退出时都能回收
+	from := meta.tail() + 1
+	err = s.retryUpdate(func(txn *storeTxn) error {
+		if err := s.listUnchanged(txn, key, meta, readTs, false); err != nil {
+			return err
+		}
+		return markListReclaimTxn(txn, key, meta.head, from+meta.length)
+	}, 30)
+	if err != nil {
+		return err
+	}
+
+	next := from
+	batch := make([][]byte, 0, listBatch)
+	flush := func() error {
+		err := s.retryUpdate(func(txn *storeTxn) error {
+			if err := s.listUnchanged(txn, key, meta, readTs, false); err != nil {
+				return err
+			}
+			for i, val := range batch {
+				if err := txn.Set(listElemKey(key, next+uint64(i)), val); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, 30)
+		next += uint64(len(batch))
+		batch = batch[:0]
+		return err
+	}
+	changed, err := rewrite(snap, meta, func(val []byte) error {
+		batch = append(batch, val)
+		if len(batch) == listBatch {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil && changed && len(batch) > 0 {
+		err = flush()
+	}
+	if err == nil && changed {
+		// 切换时检查每个旧元素的版本，快照之后的 LSET 等修改都会使重写重新开始
+		err = s.retryUpdate(func(txn *storeTxn) error {
+			if err := s.listUnchanged(txn, key, meta, readTs, true); err != nil {
+				return err
+			}
+			if next == from {
+				return s.deleteListMetaTxn(txn, key)
+			}
+			return s.listSetMeta(txn, key, listMeta{length: next - from, head: from})
+		}, 30)
+	}
+	if reclaimErr := s.reclaimList(key); err == nil {
+		err = reclaimErr
+	}
+	return err
+}
+
+// listUnchanged 在 txn 中确认列表在快照 readTs 之后没有被修改：元数据相同且版本不晚于快照，
+// elements 为 true 时还检查每个元素的版本。读取的键进入事务的冲突检测，并发提交的修改使事务冲突重试
+func (s *BotreonStore) listUnchanged(txn *storeTxn, key string, meta listMeta, readTs uint64, elements bool) error {
+	cur, err := s.listMetaTxn(txn, key)
+	if err != nil {
+		return err
+	}
+	if cur != meta {
+		return errListChanged
+	}
+	for _, k := range [][]byte{TypeOfKeyGet(key), []byte(s.listKey(key, "length")), []byte(s.listKey(key, "head"))} {
+		item, err := txn.Get(k)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if item.Version() > readTs {
+			return errListChanged
+		}
+	}
+	if !elements {
+		return nil
+	}
+	return s.listScan(txn, key, meta, meta.head, false, -1, func(_ uint64, item *badger.Item) (bool, error) {
+		if item.Version() > readTs {
+			return false, errListChanged
+		}
+		return true, nil
+	})
+}
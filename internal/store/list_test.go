@@ -1,8 +1,14 @@
 package store
 
 import (
+	"errors"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/dgraph-io/badger/v4"
+	"github.com/lbp0200/BoltDB/internal/helper"
 	"github.com/zeebo/assert"
 )
 
@@ -505,3 +511,353 @@ func TestBRPOPLPUSH(t *testing.T) {
 	val, _ := store.LIndex("dest", 0)
 	assert.Equal(t, "value1", val)
 }
+
+func TestBlockingLimits(t *testing.T) {
+	store := setupListTest(t)
+	defer store.Close()
+	store.SetBlockingLimits(BlockingLimits{MaxPerKey: 2, MaxTotal: 3})
+
+	var wg sync.WaitGroup
+	block := func(key string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, _ = store.BLPOPBlocking([]string{key}, 1)
+		}()
+	}
+	waitBlocked := func(n int) {
+		deadline := time.Now().Add(2 * time.Second)
+		for store.BlockedClients() != n && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		assert.Equal(t, n, store.BlockedClients())
+	}
+
+	block("queue")
+	block("queue")
+	waitBlocked(2)
+	assert.Equal(t, 1, store.BlockingKeys())
+
+	// 单个键名额用尽：立即返回，不进入等待队列
+	start := time.Now()
+	_, _, err := store.BLPOPBlocking([]string{"queue"}, 1)
+	assert.True(t, errors.Is(err, ErrBlockedKeyLimit))
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+
+	// 其他键仍可阻塞，直到全局名额用尽
+	block("other")
+	waitBlocked(3)
+	_, _, err = store.BLPOPBlocking([]string{"third"}, 1)
+	assert.True(t, errors.Is(err, ErrBlockedClientsLimit))
+	assert.Equal(t, int64(2), store.RejectedBlockedClients())
+
+	// 超时返回后释放名额并离开等待队列
+	wg.Wait()
+	assert.Equal(t, 0, store.BlockedClients())
+	assert.Equal(t, 0, store.BlockingKeys())
+	store.blockingMu.Lock()
+	assert.Equal(t, 0, len(store.blockingPopChans))
+	store.blockingMu.Unlock()
+}
+
+// TestBlockingPopDeliversOnce 被唤醒的等待者自己弹出元素，元素不会既返回给等待者又留在列表中
+func TestBlockingPopDeliversOnce(t *testing.T) {
+	store := setupListTest(t)
+	defer store.Close()
+
+	type popped struct{ key, value string }
+	results := make(chan popped, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			key, value, err := store.BLPOPBlocking([]string{"q"}, 2)
+			assert.NoError(t, err)
+			results <- popped{key, value}
+		}()
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for store.BlockedClients() != 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	_, err := store.RPush("q", "a", "b")
+	assert.NoError(t, err)
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		r := <-results
+		assert.Equal(t, "q", r.key)
+		got[r.value] = true
+	}
+	assert.Equal(t, map[string]bool{"a": true, "b": true}, got)
+	length, err := store.LLen("q")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), int64(length))
+
+	store.blockingMu.Lock()
+	assert.Equal(t, 0, len(store.blockingPopChans))
+	store.blockingMu.Unlock()
+}
+
+// TestListSequenceLayout 两端交替推入、弹出后索引与范围读取仍然连续，LINSERT 与 LREM 移动较短的一侧
+func TestListSequenceLayout(t *testing.T) {
+	store := setupListTest(t)
+	defer store.Close()
+
+	key := "seqlist"
+	var want []string
+	for i := 0; i < 200; i++ {
+		v := strconv.Itoa(i)
+		if i%2 == 0 {
+			_, err := store.LPush(key, v)
+			assert.NoError(t, err)
+			want = append([]string{v}, want...)
+		} else {
+			_, err := store.RPush(key, v)
+			assert.NoError(t, err)
+			want = append(want, v)
+		}
+	}
+	val, err := store.LPop(key)
+	assert.NoError(t, err)
+	assert.Equal(t, want[0], val)
+	val, err = store.RPop(key)
+	assert.NoError(t, err)
+	assert.Equal(t, want[len(want)-1], val)
+	want = want[1 : len(want)-1]
+
+	values, err := store.LRange(key, 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, want, values)
+	values, err = store.LRange(key, 50, 52)
+	assert.NoError(t, err)
+	assert.Equal(t, want[50:53], values)
+	val, err = store.LIndex(key, -3)
+	assert.NoError(t, err)
+	assert.Equal(t, want[len(want)-3], val)
+
+	// 插入在头部附近与尾部附近
+	_, err = store.LInsert(key, "AFTER", want[1], "near-head")
+	assert.NoError(t, err)
+	want = append(want[:2], append([]string{"near-head"}, want[2:]...)...)
+	_, err = store.LInsert(key, "BEFORE", want[len(want)-1], "near-tail")
+	assert.NoError(t, err)
+	want = append(want[:len(want)-1], "near-tail", want[len(want)-1])
+	values, err = store.LRange(key, 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, want, values)
+
+	// 从尾部删除两个
+	_, err = store.RPush(key, "dup", "x", "dup", "y", "dup")
+	assert.NoError(t, err)
+	n, err := store.LRem(key, -2, "dup")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	want = append(want, "dup", "x", "y")
+	values, err = store.LRange(key, 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, want, values)
+	length, err := store.LLen(key)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(len(want)), length)
+
+	positions, err := store.LPos(key, "dup", -1, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{int64(len(want) - 3)}, positions)
+}
+
+// TestMigrateLegacyLists 旧版本的链表布局在启动时转换为序号布局
+func TestMigrateLegacyLists(t *testing.T) {
+	store := setupListTest(t)
+	defer store.Close()
+
+	key := "legacy"
+	ids := []string{"n1", "n2", "n3"}
+	err := store.update(func(txn *storeTxn) error {
+		set := func(k, v string) { assert.NoError(t, txn.Set([]byte(k), []byte(v))) }
+		set(string(TypeOfKeyGet(key)), KeyTypeList)
+		assert.NoError(t, txn.Set([]byte(store.listKey(key, "length")), helper.Uint64ToBytes(3)))
+		set(store.listKey(key, "start"), "n1")
+		set(store.listKey(key, "end"), "n3")
+		for i, id := range ids {
+			set(store.listKey(key, id), "v"+strconv.Itoa(i+1))
+			set(store.listKey(key, id, "next"), ids[(i+1)%3])
+			set(store.listKey(key, id, "prev"), ids[(i+2)%3])
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.NextStartup())
+	values, err := store.LRange(key, 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"v1", "v2", "v3"}, values)
+
+	// 旧节点与 start、end 已删除，只剩 length、head 与三个元素
+	keys := 0
+	err = store.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(store.listKey(key))
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			keys++
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, keys)
+	_, err = store.RPush(key, "v4")
+	assert.NoError(t, err)
+	val, err := store.LIndex(key, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, "v4", val)
+}
+
+// listElemCount 列表的元素键数（包括范围外尚未回收的）
+func listElemCount(t *testing.T, store *BotreonStore, key string) int {
+	n := 0
+	err := store.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = listElemPrefix(key)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			n++
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	return n
+}
+
+// TestLTrimLargeRange 删除的元素超过 listBatch 时提交元数据后分批回收
+func TestLTrimLargeRange(t *testing.T) {
+	store := setupListTest(t)
+	defer store.Close()
+
+	key := "biglist"
+	var want []string
+	for i := 0; i < 5000; i++ {
+		want = append(want, strconv.Itoa(i))
+	}
+	for i := 0; i < len(want); i += 1000 {
+		_, err := store.RPush(key, want[i:i+1000]...)
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, store.LTrim(key, 1000, 2499))
+	values, err := store.LRange(key, 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, want[1000:2500], values)
+	assert.Equal(t, 1500, listElemCount(t, store, key))
+
+	// 修剪为空时删除键
+	assert.NoError(t, store.LTrim(key, 5, 1))
+	exists, err := store.Exists(key)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, 0, listElemCount(t, store, key))
+
+	_ = store.view(func(txn *storeTxn) error {
+		_, err := txn.Get([]byte(metaListReclaimPrefix + key))
+		assert.True(t, errors.Is(err, badger.ErrKeyNotFound))
+		return nil
+	})
+}
+
+// TestListReclaimAfterRestart 回收完成前关闭时，重启后继续回收范围外的元素
+func TestListReclaimAfterRestart(t *testing.T) {
+	dbPath := t.TempDir()
+	store, err := NewBadgerStore(dbPath)
+	assert.NoError(t, err)
+
+	key := "restartlist"
+	_, err = store.RPush(key, "a", "b", "c", "d", "e")
+	assert.NoError(t, err)
+	// 只提交 LTRIM 的元数据与回收范围，模拟回收前退出
+	err = store.update(func(txn *storeTxn) error {
+		meta, err := store.listMetaTxn(txn, key)
+		if err != nil {
+			return err
+		}
+		if err := markListReclaimTxn(txn, key, meta.head, meta.tail()); err != nil {
+			return err
+		}
+		return store.listSetMeta(txn, key, listMeta{length: 2, head: meta.head + 1})
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, listElemCount(t, store, key))
+	values, err := store.LRange(key, 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, values)
+	pos, err := store.LPos(key, "d", 0, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(pos))
+	assert.NoError(t, store.Close())
+
+	store, err = NewBadgerStore(dbPath)
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.Equal(t, 2, listElemCount(t, store, key))
+	values, err = store.LRange(key, 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, values)
+}
+
+// TestListRewriteBeyondTxnLimit 移动的元素超过单个事务的大小上限时，LINSERT 与 LREM 分批重写列表
+func TestListRewriteBeyondTxnLimit(t *testing.T) {
+	store := setupListTest(t)
+	defer store.Close()
+
+	key := "hugelist"
+	// 每个元素约 1KB，移动一半元素就超过 Badger 单个事务的大小上限
+	pad := string(make([]byte, 1024))
+	var want []string
+	for i := 0; i < 24000; i++ {
+		if i%3 == 0 {
+			want = append(want, "x")
+		} else {
+			want = append(want, strconv.Itoa(i)+pad)
+		}
+	}
+	for i := 0; i < len(want); i += 1000 {
+		_, err := store.RPush(key, want[i:i+1000]...)
+		assert.NoError(t, err)
+	}
+
+	pivot := want[12001]
+	n, err := store.LInsert(key, "AFTER", pivot, "inserted")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	want = append(want[:12002], append([]string{"inserted"}, want[12002:]...)...)
+	values, err := store.LRange(key, 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, want, values)
+
+	// 从尾部删除两个
+	n, err = store.LRem(key, -2, "x")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	want = append(want[:len(want)-3], want[len(want)-2:]...)
+	want = append(want[:len(want)-5], want[len(want)-4:]...)
+	values, err = store.LRange(key, 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, want, values)
+
+	n, err = store.LRem(key, 0, "x")
+	assert.NoError(t, err)
+	assert.Equal(t, 7998, n)
+	var kept []string
+	for _, v := range want {
+		if v != "x" {
+			kept = append(kept, v)
+		}
+	}
+	values, err = store.LRange(key, 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, kept, values)
+	length, err := store.LLen(key)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(len(kept)), length)
+	assert.Equal(t, len(kept), listElemCount(t, store, key))
+}
@@ -0,0 +1,407 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// 逻辑键：用户通过 KEYS、SCAN、RANDOMKEY、DBSIZE 看到的键。每个键有且只有一个类型键 TYPE_<存储中的键名>，
+// 成员、计数、过期时间等其他 Badger 键都不是逻辑键。遍历一个数据库的逻辑键只需遍历
+// TYPE_<数据库的键名前缀>，并且：
+//   - 0 号数据库的前缀为空，TYPE_ 下还有其他数据库、旧代与 SWAPDB 暂存的键，它们都以 \x00DB 开头、
+//     在键的顺序中连续，遇到时直接 Seek 到这一段之后，不逐个跳过；
+//   - 模式开头的字面部分（第一个 *、?、[ 之前）缩小遍历的范围，KEYS user:* 只遍历以 user: 开头的键；
+//   - KEYS、SCAN、RANDOMKEY 跳过已过期但尚未被删除的键，DBSIZE 与 Redis 相同包括它们
+
+var (
+	// hiddenTypeKeys 0 号数据库遍历时跳过的类型键：TYPE_\x00DB 开头的一段
+	hiddenTypeKeys = TypeOfKeyGet(dbKeyMarker)
+	// hiddenTypeKeysEnd 这一段之后的第一个可能的键
+	hiddenTypeKeysEnd = prefixSuccessor(hiddenTypeKeys)
+)
+
+const (
+	// randomKeyTries RANDOMKEY 连续选中已过期的键时重新选择的次数，超过后顺序查找
+	randomKeyTries = 100
+	// randomKeyWalk RANDOMKEY 在随机位置 Seek 之后再向后随机走的最多步数
+	randomKeyWalk = 16
+)
+
+// prefixSuccessor 大于所有以 prefix 开头的键的最小键，prefix 不能全为 0xFF
+func prefixSuccessor(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	i := len(end) - 1
+	for end[i] == 0xFF {
+		i--
+	}
+	end[i]++
+	return end[:i+1]
+}
+
+// globLiteralPrefix 模式开头的字面部分：所有匹配 pattern 的键都以它开头。
+// 转义的字符按字面处理，遇到 *、?、[ 或无效的 UTF-8（matchPattern 按字符比较，无效字节不能按字节缩小范围）时结束
+func globLiteralPrefix(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); {
+		c := pattern[i]
+		switch c {
+		case '*', '?', '[':
+			return b.String()
+		case '\\':
+			if i+1 == len(pattern) {
+				b.WriteByte(c)
+				return b.String()
+			}
+			i++
+		}
+		r, size := utf8.DecodeRuneInString(pattern[i:])
+		if r == utf8.RuneError && size <= 1 {
+			return b.String()
+		}
+		b.WriteString(pattern[i : i+size])
+		i += size
+	}
+	return b.String()
+}
+
+// logicalKeyIter 一个数据库中逻辑键的迭代器，按键名顺序只访问以模式的字面前缀开头、
+// 不属于其他数据库的类型键。是否匹配模式与是否过期由调用方判断（见 matches、logicalKeyExpired）
+type logicalKeyIter struct {
+	iter    *storeIterator
+	dbLen   int    // 类型键中数据库前缀之前的长度（TYPE_ 加上数据库的键名前缀）
+	prefix  []byte // TYPE_ + 数据库的键名前缀 + 模式的字面前缀
+	pattern string
+	hideDB  bool // 0 号数据库：跳过 hiddenTypeKeys 一段
+}
+
+// newLogicalKeyIter 在 txn 中创建数据库 db 的逻辑键迭代器，pattern 为空时等同于 *。
+// 0 号数据库中字面前缀以 \x00DB 开头的模式没有匹配的键
+func (s *BotreonStore) newLogicalKeyIter(txn *storeTxn, db int, pattern string) *logicalKeyIter {
+	if pattern == "" {
+		pattern = "*"
+	}
+	dbPrefix := s.dbPrefix(db)
+	it := &logicalKeyIter{
+		dbLen:   len(prefixKeyTypeBytes) + len(dbPrefix),
+		prefix:  TypeOfKeyGet(dbPrefix + globLiteralPrefix(pattern)),
+		pattern: pattern,
+		hideDB:  dbPrefix == "",
+	}
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = it.prefix
+	it.iter = txn.NewIterator(opts)
+	return it
+}
+
+func (it *logicalKeyIter) close() {
+	it.iter.Close()
+}
+
+// skipHidden 位于 0 号数据库跳过的一段时移到它之后
+func (it *logicalKeyIter) skipHidden() {
+	if !it.hideDB || !it.iter.Valid() || !bytes.HasPrefix(it.iter.Item().Key(), hiddenTypeKeys) {
+		return
+	}
+	it.iter.Seek(hiddenTypeKeysEnd)
+}
+
+// rewind 移到第一个键
+func (it *logicalKeyIter) rewind() {
+	it.iter.Seek(it.prefix)
+	it.skipHidden()
+}
+
+// seek 移到不小于类型键 key 的第一个键
+func (it *logicalKeyIter) seek(key []byte) {
+	if bytes.Compare(key, it.prefix) < 0 {
+		key = it.prefix
+	}
+	it.iter.Seek(key)
+	it.skipHidden()
+}
+
+// seekAfter 移到大于类型键 key 的第一个键，用于从 SCAN 游标继续
+func (it *logicalKeyIter) seekAfter(key []byte) {
+	it.seek(key)
+	if it.valid() && bytes.Equal(it.iter.Item().Key(), key) {
+		it.next()
+	}
+}
+
+func (it *logicalKeyIter) valid() bool {
+	return it.iter.ValidForPrefix(it.prefix)
+}
+
+func (it *logicalKeyIter) next() {
+	it.iter.Next()
+	it.skipHidden()
+}
+
+func (it *logicalKeyIter) item() *badger.Item {
+	return it.iter.Item()
+}
+
+// key 当前键在数据库中的键名
+func (it *logicalKeyIter) key() string {
+	return string(it.iter.Item().Key()[it.dbLen:])
+}
+
+// physicalKey 当前键在存储中的键名
+func (it *logicalKeyIter) physicalKey() string {
+	return string(it.iter.Item().Key()[len(prefixKeyTypeBytes):])
+}
+
+// matches 当前键是否匹配模式
+func (it *logicalKeyIter) matches() bool {
+	return it.pattern == "*" || matchPattern(it.key(), it.pattern)
+}
+
+// logicalKeyExpired 迭代器当前的键在 now 是否已过期（尚未被主动或惰性过期删除）。
+// 字符串与 HyperLogLog 的值过期后被 Badger 隐藏，找不到值也按已过期处理；
+// 其他类型只在存在过期时间记录时才需要查找
+func (s *BotreonStore) logicalKeyExpired(txn *storeTxn, it *logicalKeyIter, now time.Time) (bool, error) {
+	keyType, err := it.item().ValueCopy(nil)
+	if err != nil {
+		return false, err
+	}
+	key := it.physicalKey()
+	if !ttlOnValue(string(keyType)) {
+		if !s.expireRecords.Load() {
+			return false, nil
+		}
+		exp, err := s.expiresAtTxn(txn, key, string(keyType))
+		return err == nil && exp > 0 && !expiresAtTime(exp).After(now), err
+	}
+	valueKey, err := s.getKeyValueKey(key, string(keyType))
+	if err != nil {
+		return false, err
+	}
+	item, err := txn.Get(valueKey)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	exp := item.ExpiresAt()
+	return exp > 0 && !expiresAtTime(exp).After(now), nil
+}
+
+// forEachDBKey 依次访问数据库 db 中匹配 pattern 的键（数据库中的键名）。
+// live 为 true 时跳过已过期但尚未被删除的键
+func (s *BotreonStore) forEachDBKey(db int, pattern string, live bool, visit func(key string)) error {
+	now := s.now()
+	return s.view(func(txn *storeTxn) error {
+		it := s.newLogicalKeyIter(txn, db, pattern)
+		defer it.close()
+		for it.rewind(); it.valid(); it.next() {
+			if !it.matches() {
+				continue
+			}
+			if live {
+				expired, err := s.logicalKeyExpired(txn, it, now)
+				if err != nil {
+					return err
+				}
+				if expired {
+					continue
+				}
+			}
+			visit(it.key())
+		}
+		return nil
+	})
+}
+
+// DBKeys 实现 KEYS：返回数据库 db 中匹配 pattern 的键，不包括已过期但尚未被删除的键
+func (s *BotreonStore) DBKeys(db int, pattern string) ([]string, error) {
+	var keys []string
+	err := s.forEachDBKey(db, pattern, true, func(key string) {
+		keys = append(keys, key)
+	})
+	return keys, err
+}
+
+// allDBKeys 数据库 db 的全部键，包括已过期但尚未被删除的键，用于 FLUSHDB、SWAPDB 等整体处理数据库的操作
+func (s *BotreonStore) allDBKeys(db int) ([]string, error) {
+	var keys []string
+	err := s.forEachDBKey(db, "*", false, func(key string) {
+		keys = append(keys, key)
+	})
+	return keys, err
+}
+
+// DBSize 实现 DBSIZE：数据库 db 中的键数（包括已过期但尚未被删除的键）
+func (s *BotreonStore) DBSize(db int) (int64, error) {
+	var n int64
+	err := s.forEachDBKey(db, "*", false, func(string) { n++ })
+	return n, err
+}
+
+// DBScan 实现 SCAN：与 Scan 相同，只遍历数据库 db 的键，返回数据库中的键名，不包括已过期但尚未被删除的键。
+// 只检查以 pattern 的字面前缀开头的键，0 号数据库跳过其他数据库的键时不计入 count
+func (s *BotreonStore) DBScan(db int, cursor uint64, pattern string, count int, keyType string) (ScanResult, error) {
+	var last []byte
+	if cursor != 0 {
+		var err error
+		if last, err = s.scanCursors.load("scan", cursor); err != nil {
+			return ScanResult{}, err
+		}
+	}
+	if count <= 0 {
+		count = 10 // 默认值
+	}
+	result := ScanResult{Keys: []string{}}
+	now := s.now()
+	err := s.view(func(txn *storeTxn) error {
+		it := s.newLogicalKeyIter(txn, db, pattern)
+		defer it.close()
+		if last == nil {
+			it.rewind()
+		} else {
+			it.seekAfter(last)
+		}
+		var seen []byte
+		for examined := 0; examined < count && it.valid(); it.next() {
+			seen = it.item().KeyCopy(seen)
+			examined++
+			if !it.matches() {
+				continue
+			}
+			if keyType != "" {
+				stored, err := it.item().ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				if !strings.EqualFold(redisTypeName(string(stored)), keyType) {
+					continue
+				}
+			}
+			expired, err := s.logicalKeyExpired(txn, it, now)
+			if err != nil {
+				return err
+			}
+			if !expired {
+				result.Keys = append(result.Keys, it.key())
+			}
+		}
+		if it.valid() {
+			result.Cursor = s.scanCursors.save("scan", seen)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// DBRandomKey 实现 RANDOMKEY：随机返回数据库 db 中一个未过期的键，数据库为空时返回空串。
+// 不遍历整个数据库：在第一个与最后一个键之间随机取一个位置 Seek，再向后随机走 0 到 randomKeyWalk-1 步
+// （到末尾时回到开头）。键较少时各键被选中的概率接近相等；键名分布不均匀时，
+// 前面间隔大的键更容易被选中（与 Redis 按哈希桶随机相似，不保证均匀）
+func (s *BotreonStore) DBRandomKey(db int) (string, error) {
+	var key string
+	now := s.now()
+	err := s.view(func(txn *storeTxn) error {
+		it := s.newLogicalKeyIter(txn, db, "*")
+		defer it.close()
+		it.rewind()
+		if !it.valid() {
+			return nil
+		}
+		first := it.item().KeyCopy(nil)
+		last := s.lastDBTypeKey(txn, db)
+		for range randomKeyTries {
+			it.seek(randomKeyBetween(first, last))
+			if !it.valid() {
+				it.rewind()
+			}
+			// #nosec G404 - 随机选择键不需要密码学随机数
+			for steps := rand.IntN(randomKeyWalk); steps > 0; steps-- {
+				if it.next(); !it.valid() {
+					it.rewind()
+				}
+			}
+			expired, err := s.logicalKeyExpired(txn, it, now)
+			if err != nil {
+				return err
+			}
+			if !expired {
+				key = it.key()
+				return nil
+			}
+		}
+		// 连续选中已过期的键：大部分键已过期，从随机位置开始顺序查找一个未过期的键
+		for start := it.item().KeyCopy(nil); ; {
+			it.next()
+			if !it.valid() {
+				it.rewind()
+			}
+			if bytes.Equal(it.item().Key(), start) {
+				return nil
+			}
+			expired, err := s.logicalKeyExpired(txn, it, now)
+			if err != nil {
+				return err
+			}
+			if !expired {
+				key = it.key()
+				return nil
+			}
+		}
+	})
+	return key, err
+}
+
+// lastDBTypeKey 数据库 db 的最后一个类型键，调用方已确认数据库不为空
+func (s *BotreonStore) lastDBTypeKey(txn *storeTxn, db int) []byte {
+	prefix := TypeOfKeyGet(s.dbPrefix(db))
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	opts.Reverse = true
+	iter := txn.NewIterator(opts)
+	defer iter.Close()
+	// 反向 Seek 定位到不大于给定键的最后一个键；恰好等于 end 的键不属于这个前缀
+	seekBefore := func(end []byte) {
+		iter.Seek(end)
+		if iter.Valid() && bytes.Equal(iter.Item().Key(), end) {
+			iter.Next()
+		}
+	}
+	seekBefore(prefixSuccessor(prefix))
+	if db == 0 && iter.ValidForPrefix(hiddenTypeKeys) {
+		seekBefore(hiddenTypeKeys)
+	}
+	if !iter.ValidForPrefix(prefix) {
+		return prefix
+	}
+	return iter.Item().KeyCopy(nil)
+}
+
+// randomKeyBetween first 与 last 之间（按字节序）的一个随机键：两者共同的前缀加上
+// 之后 8 个字节按大端整数在两者之间均匀取值
+func randomKeyBetween(first, last []byte) []byte {
+	common := 0
+	for common < len(first) && common < len(last) && first[common] == last[common] {
+		common++
+	}
+	var lo, hi [8]byte
+	copy(lo[:], first[common:])
+	copy(hi[:], last[common:])
+	from, to := binary.BigEndian.Uint64(lo[:]), binary.BigEndian.Uint64(hi[:])
+	if to <= from {
+		return first
+	}
+	// #nosec G404 - 随机选择键不需要密码学随机数
+	n := from + rand.Uint64N(to-from+1)
+	key := append(bytes.Clone(first[:common]), make([]byte, 8)...)
+	binary.BigEndian.PutUint64(key[common:], n)
+	return key
+}
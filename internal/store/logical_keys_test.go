@@ -0,0 +1,146 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestGlobLiteralPrefix(t *testing.T) {
+	for pattern, want := range map[string]string{
+		"*":            "",
+		"user:*":       "user:",
+		"user:1":       "user:1",
+		"a?c":          "a",
+		"a[bc]*":       "a",
+		`a\*b*`:        "a*b",
+		`a\`:           `a\`,
+		"键:*":          "键:",
+		"ab\xffcd*":    "ab",
+		"\x00DB1\x00*": "\x00DB1\x00",
+	} {
+		assert.Equal(t, want, globLiteralPrefix(pattern))
+	}
+}
+
+func TestLogicalKeysHideOtherDatabases(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.Set("\x01before", "v"))
+	assert.NoError(t, store.Set("user:1", "v"))
+	assert.NoError(t, store.Set("user:2", "v"))
+	assert.NoError(t, store.Set("order:1", "v"))
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, store.Set(DBKey(1, fmt.Sprintf("user:%d", i)), "v"))
+	}
+	assert.NoError(t, store.Set(dbGenPrefix(1, 3)+"user:9", "v"))
+
+	keys, err := store.DBKeys(0, "*")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"\x01before", "order:1", "user:1", "user:2"}, keys)
+	keys, err = store.DBKeys(0, "user:*")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"user:1", "user:2"}, keys)
+	keys, err = store.DBKeys(0, "\x00DB1\x00*")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(keys))
+	n, err := store.DBSize(0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), n)
+
+	// 其他数据库的键不计入 SCAN 的 COUNT，一页即可遍历 0 号数据库
+	result, err := store.DBScan(0, 0, "", 4, "")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"\x01before", "order:1", "user:1", "user:2"}, result.Keys)
+	assert.Equal(t, uint64(0), result.Cursor)
+
+	var scanned []string
+	cursor := uint64(0)
+	for {
+		result, err := store.DBScan(1, cursor, "user:1*", 3, "string")
+		assert.NoError(t, err)
+		scanned = append(scanned, result.Keys...)
+		if cursor = result.Cursor; cursor == 0 {
+			break
+		}
+	}
+	assert.Equal(t, 11, len(scanned))
+
+	for i := 0; i < 20; i++ {
+		key, err := store.DBRandomKey(0)
+		assert.NoError(t, err)
+		assert.True(t, key == "\x01before" || key == "order:1" || key == "user:1" || key == "user:2")
+	}
+}
+
+func TestLogicalKeysSkipExpired(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	clock := NewManualClock(time.Now())
+	store.SetClock(clock)
+
+	assert.NoError(t, store.SetWithTTL("s", "v", time.Minute))
+	assert.NoError(t, store.HSet("h", "f", "v"))
+	ok, err := store.Expire("h", 60)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, store.Set("keep", "v"))
+	clock.Advance(2 * time.Minute)
+
+	keys, err := store.DBKeys(0, "*")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"keep"}, keys)
+	result, err := store.DBScan(0, 0, "*", 10, "")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"keep"}, result.Keys)
+	for i := 0; i < 20; i++ {
+		key, err := store.DBRandomKey(0)
+		assert.NoError(t, err)
+		assert.Equal(t, "keep", key)
+	}
+	// DBSIZE 与 Redis 相同包括尚未被删除的过期键
+	n, err := store.DBSize(0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	_, err = store.Del("keep")
+	assert.NoError(t, err)
+	key, err := store.DBRandomKey(0)
+	assert.NoError(t, err)
+	assert.Equal(t, "", key)
+}
+
+func TestDBRandomKeyCoversAllKeys(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	key, err := store.DBRandomKey(0)
+	assert.NoError(t, err)
+	assert.Equal(t, "", key)
+
+	want := []string{"a", "k0", "k1", "k2", "k3", "zzzz"}
+	for _, key := range want {
+		assert.NoError(t, store.Set(key, "v"))
+	}
+	assert.NoError(t, store.Set(DBKey(3, "other"), "v"))
+	seen := make(map[string]bool)
+	for i := 0; i < 1000 && len(seen) < len(want); i++ {
+		key, err := store.DBRandomKey(0)
+		assert.NoError(t, err)
+		seen[key] = true
+	}
+	for _, key := range want {
+		assert.True(t, seen[key])
+	}
+	assert.Equal(t, len(want), len(seen))
+
+	key, err = store.DBRandomKey(3)
+	assert.NoError(t, err)
+	assert.Equal(t, "other", key)
+}
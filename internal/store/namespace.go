@@ -0,0 +1,192 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/lbp0200/BoltDB/internal/logger"
+)
+
+// metaNamespacePrefix 命名空间配置的内部键前缀（不带 TYPE_ 前缀，不会出现在 KEYS/SCAN 中）
+const metaNamespacePrefix = "META:ns:"
+
+// ErrNamespaceQuota 命名空间的键数已达上限
+var ErrNamespaceQuota = errors.New("namespace key quota exceeded")
+
+// Namespace 以键前缀划分的命名空间：新建的键自动设置默认 TTL，键数不超过 MaxKeys。
+// 定义对所有逻辑数据库生效，前缀匹配数据库中的键名，键数按数据库分别统计
+type Namespace struct {
+	Prefix     string        `json:"prefix"`
+	DefaultTTL time.Duration `json:"default_ttl"` // 0 表示不设置
+	MaxKeys    int64         `json:"max_keys"`    // 0 表示不限制
+}
+
+// namespaces 已定义的命名空间，启动时从存储加载
+type namespaces struct {
+	mu     sync.RWMutex
+	byName map[string]Namespace
+}
+
+func (s *BotreonStore) loadNamespaces() error {
+	prefix := []byte(metaNamespacePrefix)
+	s.namespaces.byName = make(map[string]Namespace)
+	return s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			var ns Namespace
+			err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &ns)
+			})
+			if err != nil || ns.Prefix == "" {
+				logger.Logger.Warn().Err(err).Str("key", string(it.Item().Key())).Msg("忽略无效的命名空间配置")
+				continue
+			}
+			s.namespaces.byName[ns.Prefix] = ns
+		}
+		return nil
+	})
+}
+
+// SetNamespace 定义或更新命名空间，配置持久化，重启后仍然有效
+func (s *BotreonStore) SetNamespace(ns Namespace) error {
+	if ns.Prefix == "" {
+		return errors.New("namespace prefix must not be empty")
+	}
+	if ns.DefaultTTL < 0 || ns.MaxKeys < 0 {
+		return errors.New("namespace TTL and key quota must not be negative")
+	}
+	value, err := json.Marshal(ns)
+	if err != nil {
+		return err
+	}
+	err = s.update(func(txn *storeTxn) error {
+		return txn.Set([]byte(metaNamespacePrefix+ns.Prefix), value)
+	})
+	if err != nil {
+		return err
+	}
+	s.namespaces.mu.Lock()
+	s.namespaces.byName[ns.Prefix] = ns
+	s.namespaces.mu.Unlock()
+	return nil
+}
+
+// DeleteNamespace 删除命名空间定义（不删除其中的键），返回是否存在
+func (s *BotreonStore) DeleteNamespace(prefix string) (bool, error) {
+	s.namespaces.mu.Lock()
+	defer s.namespaces.mu.Unlock()
+	if _, ok := s.namespaces.byName[prefix]; !ok {
+		return false, nil
+	}
+	err := s.update(func(txn *storeTxn) error {
+		return txn.Delete([]byte(metaNamespacePrefix + prefix))
+	})
+	if err != nil {
+		return false, err
+	}
+	delete(s.namespaces.byName, prefix)
+	return true, nil
+}
+
+// Namespaces 按前缀排序返回所有命名空间
+func (s *BotreonStore) Namespaces() []Namespace {
+	s.namespaces.mu.RLock()
+	defer s.namespaces.mu.RUnlock()
+	list := make([]Namespace, 0, len(s.namespaces.byName))
+	for _, ns := range s.namespaces.byName {
+		list = append(list, ns)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Prefix < list[j].Prefix })
+	return list
+}
+
+// GetNamespace 按前缀查找命名空间
+func (s *BotreonStore) GetNamespace(prefix string) (Namespace, bool) {
+	s.namespaces.mu.RLock()
+	defer s.namespaces.mu.RUnlock()
+	ns, ok := s.namespaces.byName[prefix]
+	return ns, ok
+}
+
+// HasNamespaces 是否定义了命名空间（未定义时写命令不做任何检查）
+func (s *BotreonStore) HasNamespaces() bool {
+	s.namespaces.mu.RLock()
+	defer s.namespaces.mu.RUnlock()
+	return len(s.namespaces.byName) > 0
+}
+
+// NamespaceOf 返回存储中的键 key 所属的命名空间，按去掉数据库前缀后的键名匹配，前缀嵌套时取最长的前缀
+func (s *BotreonStore) NamespaceOf(key string) (Namespace, bool) {
+	_, key = SplitDBKey(key)
+	s.namespaces.mu.RLock()
+	defer s.namespaces.mu.RUnlock()
+	var best Namespace
+	found := false
+	for prefix, ns := range s.namespaces.byName {
+		if strings.HasPrefix(key, prefix) && (!found || len(prefix) > len(best.Prefix)) {
+			best = ns
+			found = true
+		}
+	}
+	return best, found
+}
+
+// NamespaceKeyCount 统计数据库 db 中以 prefix 开头的键数，limit > 0 时数到 limit 为止
+func (s *BotreonStore) NamespaceKeyCount(db int, prefix string, limit int64) (int64, error) {
+	var count int64
+	err := s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = TypeOfKeyGet(s.StoreKey(db, prefix))
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			count++
+			if limit > 0 && count >= limit {
+				break
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+// FlushNamespace 删除数据库 db 中以 prefix 开头的所有键（开启回收站时移入回收站），返回删除的键数。
+// 与 FLUSHDB 不同，命名空间的定义保留
+func (s *BotreonStore) FlushNamespace(db int, prefix string) (int64, error) {
+	if prefix == "" {
+		return 0, errors.New("namespace prefix must not be empty")
+	}
+	var keys []string
+	err := s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = TypeOfKeyGet(s.StoreKey(db, prefix))
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			keys = append(keys, string(it.Item().Key()[len(prefixKeyTypeBytes):]))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	var deleted int64
+	for _, key := range keys {
+		n, err := s.DelToTrash(key)
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+	}
+	return deleted, nil
+}
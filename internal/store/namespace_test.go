@@ -0,0 +1,78 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestNamespaces(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBadgerStore(dir)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.SetNamespace(Namespace{Prefix: "ci:", MaxKeys: 10}))
+	assert.NoError(t, store.SetNamespace(Namespace{Prefix: "ci:job1:", DefaultTTL: time.Minute}))
+	assert.Error(t, store.SetNamespace(Namespace{Prefix: ""}))
+
+	// 嵌套时取最长前缀
+	ns, ok := store.NamespaceOf("ci:job1:cache")
+	assert.True(t, ok)
+	assert.Equal(t, "ci:job1:", ns.Prefix)
+	ns, ok = store.NamespaceOf("ci:other")
+	assert.True(t, ok)
+	assert.Equal(t, int64(10), ns.MaxKeys)
+	_, ok = store.NamespaceOf("prod:x")
+	assert.False(t, ok)
+
+	for _, key := range []string{"ci:a", "ci:b", "ci:job1:c", "prod:x"} {
+		assert.NoError(t, store.Set(key, "v"))
+	}
+	count, err := store.NamespaceKeyCount(0, "ci:", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	count, err = store.NamespaceKeyCount(0, "ci:", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	deleted, err := store.FlushNamespace(0, "ci:job1:")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	exists, _ := store.Exists("ci:a")
+	assert.True(t, exists)
+
+	// 非 0 号数据库按去掉前缀的键名匹配，键数按数据库分别统计
+	ns, ok = store.NamespaceOf(store.StoreKey(1, "ci:job1:x"))
+	assert.True(t, ok)
+	assert.Equal(t, "ci:job1:", ns.Prefix)
+	assert.NoError(t, store.Set(store.StoreKey(1, "ci:a"), "v"))
+	count, err = store.NamespaceKeyCount(1, "ci:", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	count, err = store.NamespaceKeyCount(0, "ci:", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	deleted, err = store.FlushNamespace(1, "ci:")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	exists, _ = store.Exists("ci:a")
+	assert.True(t, exists)
+
+	// 配置持久化
+	assert.NoError(t, store.Close())
+	store, err = NewBadgerStore(dir)
+	assert.NoError(t, err)
+	defer store.Close()
+	list := store.Namespaces()
+	assert.Equal(t, 2, len(list))
+	assert.Equal(t, Namespace{Prefix: "ci:job1:", DefaultTTL: time.Minute}, list[1])
+
+	removed, err := store.DeleteNamespace("ci:")
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = store.DeleteNamespace("ci:")
+	assert.NoError(t, err)
+	assert.False(t, removed)
+	assert.True(t, store.HasNamespaces())
+}
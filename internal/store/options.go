@@ -0,0 +1,62 @@
+package store
+
+import (
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// StoreOptions 创建存储时的可选配置
+type StoreOptions struct {
+	Compression CompressionType // 应用层压缩算法，为空时使用 LZ4
+	Iterator    IteratorTuning  // 迭代器预取参数，零值时使用 DefaultIteratorTuning
+	Blocking    BlockingLimits  // 阻塞客户端上限，零值时使用 DefaultBlockingLimits
+	// TrashRetention 回收站保留时间，大于 0 时 DEL/FLUSHDB 先将键移入回收站
+	TrashRetention time.Duration
+	// EncryptionKeySource 静态加密主密钥的来源（file:、env:、cmd:，见 LoadEncryptionKey），为空时不加密
+	EncryptionKeySource string
+	// DataKeyRotation Badger 数据密钥的轮换周期，零值时使用 DefaultDataKeyRotation
+	DataKeyRotation time.Duration
+	// MaxValueSize 单个值的上限（字节），零值时使用 DefaultMaxValueSize，不超过 Badger value log 文件大小
+	MaxValueSize int64
+	// DecompressCacheSize 解压缓存的容量（字节），零值时使用 DefaultDecompressCacheSize，小于 0 时不缓存
+	DecompressCacheSize int64
+	// Tiering 冷热分层，Dir 为空时不分层
+	Tiering TieringOptions
+}
+
+// badgerOptions 生成 Badger 配置。所有数据类型共用一个 Badger 实例：TYPE_ 元数据键和各类型的数据键
+// 在同一事务中读写，以保证 DEL、RENAME、MULTI 等操作的原子性，因此不按类型分别调优
+func badgerOptions(path string) badger.Options {
+	opts := badger.DefaultOptions(path)
+
+	// 性能优化配置
+	// 1. 增加 memtable 数量，提高写入并发性能（优化：从5增加到7，减少事务冲突）
+	opts.NumMemtables = 7             // 增加到 7，提高并发写入性能
+	opts.NumLevelZeroTables = 5       // Level 0 表数量
+	opts.NumLevelZeroTablesStall = 10 // Level 0 停滞阈值
+
+	// 2. 优化 Value Log 配置
+	opts.ValueLogFileSize = 1024 * 1024 * 1024 // 1GB（默认 1GB，适合大值）
+	opts.ValueLogMaxEntries = 1000000          // 每个 vlog 文件最大条目数
+
+	// 3. 优化 Table 配置
+	// BadgerDB v4 使用 BlockSize 而不是 MaxTableSize
+	opts.BlockSize = 4 * 1024     // 4KB 块大小（默认 4KB）
+	opts.LevelSizeMultiplier = 10 // Level 大小倍数（默认 10）
+
+	// 4. 压缩配置（BadgerDB 内置压缩）
+	// BadgerDB v4 使用 CompressionType，值为 0=无压缩, 1=Snappy, 2=ZSTD
+	opts.Compression = 2 // 使用 ZSTD 压缩（比 Snappy 更好）
+
+	// 5. 索引缓存配置
+	opts.IndexCacheSize = 100 * 1024 * 1024 // 100MB 索引缓存（默认 0，禁用）
+
+	// 6. 减少同步频率（提高性能，但降低持久性）
+	// opts.SyncWrites = false // 默认 false，异步写入提高性能
+
+	// 7. 优化垃圾回收
+	opts.NumGoroutines = 8 // GC goroutine 数量（默认 8）
+
+	return opts
+}
@@ -1,7 +1,9 @@
 package store
 
 import (
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/lbp0200/BoltDB/internal/logger"
 )
@@ -11,7 +13,27 @@ type PubSubManager struct {
 	mu           sync.RWMutex
 	channels     map[string]map[*Subscriber]bool // 频道 -> 订阅者映射
 	patterns     map[string]map[*Subscriber]bool // 模式 -> 订阅者映射
+	globPatterns map[string]struct{}             // 含通配符的模式，发布时需要逐个匹配
+	shards       map[string]map[*Subscriber]bool // 分片频道 -> 订阅者映射（SSUBSCRIBE）
 	subscribers  map[*Subscriber]bool             // 所有订阅者
+	durable      *durablePubSub                   // 持久化订阅（可选）
+
+	statsMu sync.RWMutex
+	stats   map[string]*channelCounters // 频道 -> 投递统计
+}
+
+// ChannelStats 频道投递统计
+type ChannelStats struct {
+	Published int64 // 发布次数
+	Delivered int64 // 成功投递给订阅者的消息数
+	Dropped   int64 // 因订阅者通道已满而丢弃的消息数
+}
+
+// channelCounters 频道统计计数器（原子更新）
+type channelCounters struct {
+	published atomic.Int64
+	delivered atomic.Int64
+	dropped   atomic.Int64
 }
 
 // Subscriber 订阅者
@@ -19,6 +41,7 @@ type Subscriber struct {
 	ID       string
 	Channels map[string]bool
 	Patterns map[string]bool
+	Shards   map[string]bool
 	MessageCh chan *Message
 	mu       sync.RWMutex
 }
@@ -28,6 +51,8 @@ type Message struct {
 	Channel string
 	Pattern string
 	Data    []byte
+	ID      string // 持久化频道的消息 ID（resume token），非持久化频道为空
+	Shard   bool   // 是否为分片频道消息（SPUBLISH）
 }
 
 // NewPubSubManager 创建新的Pub/Sub管理器
@@ -35,7 +60,10 @@ func NewPubSubManager() *PubSubManager {
 	return &PubSubManager{
 		channels:    make(map[string]map[*Subscriber]bool),
 		patterns:    make(map[string]map[*Subscriber]bool),
+		globPatterns: make(map[string]struct{}),
+		shards:      make(map[string]map[*Subscriber]bool),
 		subscribers: make(map[*Subscriber]bool),
+		stats:       make(map[string]*channelCounters),
 	}
 }
 
@@ -45,10 +73,18 @@ func NewSubscriber(id string) *Subscriber {
 		ID:        id,
 		Channels:  make(map[string]bool),
 		Patterns:  make(map[string]bool),
+		Shards:    make(map[string]bool),
 		MessageCh: make(chan *Message, 100),
 	}
 }
 
+// SubscriptionCounts 订阅者订阅的频道、模式与分片频道数量
+func (s *Subscriber) SubscriptionCounts() (channels, patterns, shards int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.Channels), len(s.Patterns), len(s.Shards)
+}
+
 // Subscribe 订阅频道
 func (psm *PubSubManager) Subscribe(subscriber *Subscriber, channels ...string) []string {
 	psm.mu.Lock()
@@ -87,6 +123,9 @@ func (psm *PubSubManager) PSubscribe(subscriber *Subscriber, patterns ...string)
 
 		if psm.patterns[pattern] == nil {
 			psm.patterns[pattern] = make(map[*Subscriber]bool)
+			if isGlobPattern(pattern) {
+				psm.globPatterns[pattern] = struct{}{}
+			}
 		}
 		psm.patterns[pattern][subscriber] = true
 		subscribed = append(subscribed, pattern)
@@ -176,6 +215,7 @@ func (psm *PubSubManager) punsubscribeLocked(subscriber *Subscriber, patterns ..
 			delete(subs, subscriber)
 			if len(subs) == 0 {
 				delete(psm.patterns, pattern)
+				delete(psm.globPatterns, pattern)
 			}
 		}
 	}
@@ -194,46 +234,70 @@ func (psm *PubSubManager) Publish(channel string, message []byte) int {
 		Data:    message,
 	}
 
-	// 发送给频道订阅者
-	if subs, exists := psm.channels[channel]; exists {
-		for sub := range subs {
-			select {
-			case sub.MessageCh <- msg:
-				count++
-			default:
-				logger.Logger.Warn().
-					Str("subscriber_id", sub.ID).
-					Str("channel", channel).
-					Msg("订阅者消息通道已满，跳过消息")
-			}
+	// 持久化频道先写入 Stream，保证 ID 单调递增后再投递
+	if psm.durable != nil && psm.durable.isDurable(channel) {
+		id, err := psm.durable.append(channel, message)
+		if err != nil {
+			logger.Logger.Error().Err(err).Str("channel", channel).Msg("持久化订阅消息写入失败")
+		} else {
+			msg.ID = id
 		}
 	}
 
-	// 发送给模式订阅者
-	for pattern, subs := range psm.patterns {
+	counters := psm.channelCounters(channel)
+	counters.published.Add(1)
+
+	// 发送给频道订阅者（精确匹配，直接查表）
+	if subs, exists := psm.channels[channel]; exists {
+		count += psm.deliver(subs, msg, counters)
+	}
+
+	// 不含通配符的模式等价于精确匹配，同样直接查表
+	if subs, exists := psm.patterns[channel]; exists && !isGlobPattern(channel) {
+		count += psm.deliver(subs, &Message{Channel: channel, Pattern: channel, Data: message, ID: msg.ID}, counters)
+	}
+
+	// 只有含通配符的模式需要逐个匹配
+	for pattern := range psm.globPatterns {
 		if matchPattern(channel, pattern) {
 			patternMsg := &Message{
 				Channel: channel,
 				Pattern: pattern,
 				Data:    message,
+				ID:      msg.ID,
 			}
-			for sub := range subs {
-				select {
-				case sub.MessageCh <- patternMsg:
-					count++
-				default:
-					logger.Logger.Warn().
-						Str("subscriber_id", sub.ID).
-						Str("pattern", pattern).
-						Msg("订阅者消息通道已满，跳过消息")
-				}
-			}
+			count += psm.deliver(psm.patterns[pattern], patternMsg, counters)
 		}
 	}
 
 	return count
 }
 
+// deliver 将消息非阻塞地投递给一组订阅者，返回成功投递的数量，要求已持有psm.mu读锁
+func (psm *PubSubManager) deliver(subs map[*Subscriber]bool, msg *Message, counters *channelCounters) int {
+	count := 0
+	for sub := range subs {
+		select {
+		case sub.MessageCh <- msg:
+			count++
+		default:
+			counters.dropped.Add(1)
+			logger.Logger.Warn().
+				Str("subscriber_id", sub.ID).
+				Str("channel", msg.Channel).
+				Str("pattern", msg.Pattern).
+				Msg("订阅者消息通道已满，跳过消息")
+		}
+	}
+	counters.delivered.Add(int64(count))
+	return count
+}
+
+// isGlobPattern 判断模式是否包含通配符、字符集合或转义
+func isGlobPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[\\")
+}
+
 // GetSubscriberCount 获取订阅者数量
 func (psm *PubSubManager) GetSubscriberCount(channel string) int {
 	psm.mu.RLock()
@@ -260,10 +324,15 @@ func (psm *PubSubManager) RemoveSubscriber(subscriber *Subscriber) {
 	for pattern := range subscriber.Patterns {
 		patterns = append(patterns, pattern)
 	}
+	shards := make([]string, 0, len(subscriber.Shards))
+	for shard := range subscriber.Shards {
+		shards = append(shards, shard)
+	}
 	subscriber.mu.RUnlock()
 
 	psm.unsubscribeLocked(subscriber, channels...)
 	psm.punsubscribeLocked(subscriber, patterns...)
+	psm.sunsubscribeLocked(subscriber, shards...)
 
 	delete(psm.subscribers, subscriber)
 	close(subscriber.MessageCh)
@@ -284,16 +353,169 @@ func (psm *PubSubManager) GetChannels(pattern string) []string {
 	return channels
 }
 
-// GetPatternCount 获取模式订阅数量
+// GetPatternCount 获取被订阅的模式数量（不同订阅者订阅同一模式只算一次，与 Redis PUBSUB NUMPAT 相同）
 func (psm *PubSubManager) GetPatternCount() int {
 	psm.mu.RLock()
 	defer psm.mu.RUnlock()
+	return len(psm.patterns)
+}
 
-	count := 0
+// SSubscribe 订阅分片频道
+func (psm *PubSubManager) SSubscribe(subscriber *Subscriber, channels ...string) []string {
+	psm.mu.Lock()
+	defer psm.mu.Unlock()
+
+	psm.subscribers[subscriber] = true
+	subscribed := make([]string, 0)
+
+	for _, channel := range channels {
+		subscriber.mu.Lock()
+		subscriber.Shards[channel] = true
+		subscriber.mu.Unlock()
+
+		if psm.shards[channel] == nil {
+			psm.shards[channel] = make(map[*Subscriber]bool)
+		}
+		psm.shards[channel][subscriber] = true
+		subscribed = append(subscribed, channel)
+	}
+
+	return subscribed
+}
+
+// SUnsubscribe 取消订阅分片频道
+func (psm *PubSubManager) SUnsubscribe(subscriber *Subscriber, channels ...string) []string {
+	psm.mu.Lock()
+	defer psm.mu.Unlock()
+	return psm.sunsubscribeLocked(subscriber, channels...)
+}
+
+// sunsubscribeLocked 实际执行取消分片订阅，要求已持有psm.mu锁
+func (psm *PubSubManager) sunsubscribeLocked(subscriber *Subscriber, channels ...string) []string {
+	unsubscribed := make([]string, 0)
+
+	if len(channels) == 0 {
+		subscriber.mu.RLock()
+		for channel := range subscriber.Shards {
+			channels = append(channels, channel)
+		}
+		subscriber.mu.RUnlock()
+	}
+
+	for _, channel := range channels {
+		subscriber.mu.Lock()
+		if subscriber.Shards[channel] {
+			delete(subscriber.Shards, channel)
+			unsubscribed = append(unsubscribed, channel)
+		}
+		subscriber.mu.Unlock()
+
+		if subs, exists := psm.shards[channel]; exists {
+			delete(subs, subscriber)
+			if len(subs) == 0 {
+				delete(psm.shards, channel)
+			}
+		}
+	}
+
+	return unsubscribed
+}
+
+// SPublish 向分片频道发布消息，只投递给 SSUBSCRIBE 的订阅者
+func (psm *PubSubManager) SPublish(channel string, message []byte) int {
+	psm.mu.RLock()
+	defer psm.mu.RUnlock()
+
+	counters := psm.channelCounters(channel)
+	counters.published.Add(1)
+	subs, exists := psm.shards[channel]
+	if !exists {
+		return 0
+	}
+	return psm.deliver(subs, &Message{Channel: channel, Data: message, Shard: true}, counters)
+}
+
+// GetShardChannels 获取活跃的分片频道
+func (psm *PubSubManager) GetShardChannels(pattern string) []string {
+	psm.mu.RLock()
+	defer psm.mu.RUnlock()
+
+	channels := make([]string, 0)
+	for channel := range psm.shards {
+		if pattern == "" || pattern == "*" || matchPattern(channel, pattern) {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// GetShardSubscriberCount 获取分片频道的订阅者数量
+func (psm *PubSubManager) GetShardSubscriberCount(channel string) int {
+	psm.mu.RLock()
+	defer psm.mu.RUnlock()
+	return len(psm.shards[channel])
+}
+
+// channelCounters 获取（必要时创建）频道的统计计数器
+func (psm *PubSubManager) channelCounters(channel string) *channelCounters {
+	psm.statsMu.RLock()
+	counters, exists := psm.stats[channel]
+	psm.statsMu.RUnlock()
+	if exists {
+		return counters
+	}
+
+	psm.statsMu.Lock()
+	defer psm.statsMu.Unlock()
+	if counters, exists = psm.stats[channel]; !exists {
+		counters = &channelCounters{}
+		psm.stats[channel] = counters
+	}
+	return counters
+}
+
+// GetChannelStats 获取匹配模式的频道投递统计
+func (psm *PubSubManager) GetChannelStats(pattern string) map[string]ChannelStats {
+	psm.statsMu.RLock()
+	defer psm.statsMu.RUnlock()
+
+	result := make(map[string]ChannelStats)
+	for channel, counters := range psm.stats {
+		if pattern == "" || pattern == "*" || matchPattern(channel, pattern) {
+			result[channel] = ChannelStats{
+				Published: counters.published.Load(),
+				Delivered: counters.delivered.Load(),
+				Dropped:   counters.dropped.Load(),
+			}
+		}
+	}
+	return result
+}
+
+// PubSubBacklog 订阅者的待投递消息：消息先放入每个订阅者的通道，再由连接写给客户端
+type PubSubBacklog struct {
+	Subscribers int // 订阅者数
+	Pending     int // 所有订阅者通道中尚未写给客户端的消息数
+	MaxPending  int // 单个订阅者的最大待投递消息数，接近通道容量时新消息会被丢弃
+}
+
+// Backlog 返回订阅者的待投递消息统计
+func (psm *PubSubManager) Backlog() PubSubBacklog {
+	psm.mu.RLock()
+	defer psm.mu.RUnlock()
+	var b PubSubBacklog
 	for sub := range psm.subscribers {
-		sub.mu.RLock()
-		count += len(sub.Patterns)
-		sub.mu.RUnlock()
+		n := len(sub.MessageCh)
+		b.Subscribers++
+		b.Pending += n
+		b.MaxPending = max(b.MaxPending, n)
 	}
-	return count
+	return b
+}
+
+// ResetChannelStats 清空频道投递统计
+func (psm *PubSubManager) ResetChannelStats() {
+	psm.statsMu.Lock()
+	defer psm.statsMu.Unlock()
+	psm.stats = make(map[string]*channelCounters)
 }
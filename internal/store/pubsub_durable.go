@@ -0,0 +1,129 @@
+package store
+
+import (
+	"sync"
+)
+
+// durablePubSubPrefix 持久化订阅消息所使用的 Stream 键前缀
+const durablePubSubPrefix = "__pubsub__:"
+
+// DefaultDurableRetention 每个持久化频道默认保留的消息条数
+const DefaultDurableRetention = 1000
+
+// durablePubSub 基于 Stream 的持久化订阅支持
+// 频道一旦被 SUBSCRIBE ... RESUME 订阅过，之后发布到该频道的消息都会追加到
+// 对应的 Stream 中（按 retention 条数裁剪），客户端断线重连时可凭最后收到的
+// 消息 ID（resume token）补发错过的消息，实现至少一次投递。
+type durablePubSub struct {
+	db        *BotreonStore
+	retention int64
+	mu        sync.RWMutex
+	channels  map[string]bool // 频道 -> 是否持久化（缓存 Stream 是否存在的检查结果）
+	appendMu  sync.Mutex      // 串行化写入，避免同一 Stream 上的事务冲突
+}
+
+// DurableStreamKey 返回持久化频道对应的 Stream 键
+func DurableStreamKey(channel string) string {
+	return durablePubSubPrefix + channel
+}
+
+// EnableDurable 启用持久化订阅，retention 为每个频道保留的最大消息数
+func (psm *PubSubManager) EnableDurable(db *BotreonStore, retention int64) {
+	if retention <= 0 {
+		retention = DefaultDurableRetention
+	}
+	psm.mu.Lock()
+	defer psm.mu.Unlock()
+	psm.durable = &durablePubSub{
+		db:        db,
+		retention: retention,
+		channels:  make(map[string]bool),
+	}
+}
+
+// DurableEnabled 是否启用了持久化订阅
+func (psm *PubSubManager) DurableEnabled() bool {
+	psm.mu.RLock()
+	defer psm.mu.RUnlock()
+	return psm.durable != nil
+}
+
+// MarkDurable 将频道标记为持久化，之后发布的消息都会写入 Stream
+func (psm *PubSubManager) MarkDurable(channels ...string) {
+	psm.mu.RLock()
+	d := psm.durable
+	psm.mu.RUnlock()
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	for _, ch := range channels {
+		d.channels[ch] = true
+	}
+	d.mu.Unlock()
+}
+
+// isDurable 检查频道是否持久化（首次检查时查看 Stream 是否存在，兼容重启）
+func (d *durablePubSub) isDurable(channel string) bool {
+	d.mu.RLock()
+	durable, known := d.channels[channel]
+	d.mu.RUnlock()
+	if known {
+		return durable
+	}
+	exists, err := d.db.Exists(DurableStreamKey(channel))
+	if err != nil {
+		return false
+	}
+	d.mu.Lock()
+	d.channels[channel] = exists
+	d.mu.Unlock()
+	return exists
+}
+
+// append 将消息追加到频道的 Stream，返回消息 ID（resume token）
+func (d *durablePubSub) append(channel string, message []byte) (string, error) {
+	d.appendMu.Lock()
+	defer d.appendMu.Unlock()
+	return d.db.XAdd(DurableStreamKey(channel), StreamXAddOptions{Trim: StreamTrimOptions{Strategy: StreamTrimMaxLen, MaxLen: d.retention}}, "*",
+		map[string]string{"data": string(message)})
+}
+
+// Replay 返回频道中 ID 大于 token 的消息（受 retention 限制），
+// token 为 "0" 时返回全部保留的消息，为 "$" 时不补发
+func (psm *PubSubManager) Replay(channel, token string, count int64) ([]*Message, error) {
+	psm.mu.RLock()
+	d := psm.durable
+	psm.mu.RUnlock()
+	if d == nil || token == "$" {
+		return nil, nil
+	}
+	start := token
+	if start == "" || start == "0" {
+		start = "-"
+	}
+	entries, err := d.db.XRange(DurableStreamKey(channel), start, "+", 0)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]*Message, 0, len(entries))
+	for _, e := range entries {
+		if start != "-" && compareStreamID(e.ID, token) <= 0 {
+			continue
+		}
+		messages = append(messages, &Message{
+			Channel: channel,
+			Data:    []byte(e.Fields["data"]),
+			ID:      e.ID,
+		})
+		if count > 0 && int64(len(messages)) >= count {
+			break
+		}
+	}
+	return messages, nil
+}
+
+// CompareResumeToken 比较两个 resume token，返回 -1、0、1
+func CompareResumeToken(a, b string) int {
+	return compareStreamID(a, b)
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -367,3 +368,157 @@ func TestPUnsubscribeFromNonSubscribedPattern(t *testing.T) {
 	unsubscribed := psm.PUnsubscribe(sub, "neverpattern")
 	assert.Equal(t, 0, len(unsubscribed))
 }
+
+func TestDurablePubSubReplay(t *testing.T) {
+	db, err := NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	defer db.Close()
+
+	psm := NewPubSubManager()
+	psm.EnableDurable(db, 3)
+
+	// 未标记持久化的频道不写入 Stream
+	psm.Publish("plain", []byte("m0"))
+	exists, err := db.Exists(DurableStreamKey("plain"))
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	psm.MarkDurable("news")
+	sub := NewSubscriber("sub1")
+	psm.Subscribe(sub, "news")
+	for i := 1; i <= 5; i++ {
+		psm.Publish("news", []byte(fmt.Sprintf("m%d", i)))
+	}
+
+	// 实时消息带有 resume token
+	var ids []string
+	for i := 0; i < 5; i++ {
+		msg := <-sub.MessageCh
+		assert.NotEqual(t, "", msg.ID)
+		ids = append(ids, msg.ID)
+	}
+
+	// 只保留最近 3 条
+	messages, err := psm.Replay("news", "0", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(messages))
+	assert.Equal(t, "m3", string(messages[0].Data))
+
+	// 从 token 之后续传
+	messages, err = psm.Replay("news", ids[3], 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "m5", string(messages[0].Data))
+	assert.Equal(t, ids[4], messages[0].ID)
+
+	messages, err = psm.Replay("news", "$", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(messages))
+
+	// 重启后通过 Stream 是否存在识别持久化频道
+	psm2 := NewPubSubManager()
+	psm2.EnableDurable(db, 3)
+	psm2.Publish("news", []byte("m6"))
+	messages, err = psm2.Replay("news", ids[4], 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "m6", string(messages[0].Data))
+}
+
+func TestPublishLiteralAndGlobPatterns(t *testing.T) {
+	psm := NewPubSubManager()
+	literal := NewSubscriber("literal")
+	glob := NewSubscriber("glob")
+
+	psm.PSubscribe(literal, "news")
+	psm.PSubscribe(glob, "news.*")
+	assert.Equal(t, 1, len(psm.globPatterns))
+
+	// 不含通配符的模式只匹配同名频道
+	assert.Equal(t, 1, psm.Publish("news", []byte("a")))
+	msg := <-literal.MessageCh
+	assert.Equal(t, "news", msg.Pattern)
+
+	assert.Equal(t, 1, psm.Publish("news.sport", []byte("b")))
+	msg = <-glob.MessageCh
+	assert.Equal(t, "news.*", msg.Pattern)
+
+	psm.PUnsubscribe(glob, "news.*")
+	assert.Equal(t, 0, len(psm.globPatterns))
+	assert.Equal(t, 0, psm.Publish("news.sport", []byte("c")))
+}
+
+func TestShardChannelsAndStats(t *testing.T) {
+	psm := NewPubSubManager()
+	sub := NewSubscriber("shard")
+
+	psm.SSubscribe(sub, "orders", "users")
+	assert.Equal(t, 1, psm.GetShardSubscriberCount("orders"))
+	assert.Equal(t, 2, len(psm.GetShardChannels("*")))
+	assert.Equal(t, 1, len(psm.GetShardChannels("ord*")))
+
+	// 分片消息不会投递给普通订阅者
+	assert.Equal(t, 0, psm.Publish("orders", []byte("x")))
+	assert.Equal(t, 1, psm.SPublish("orders", []byte("y")))
+	msg := <-sub.MessageCh
+	assert.True(t, msg.Shard)
+	assert.Equal(t, "y", string(msg.Data))
+
+	// 填满通道后统计丢弃数
+	for i := 0; i < cap(sub.MessageCh)+2; i++ {
+		psm.SPublish("users", []byte("z"))
+	}
+	stats := psm.GetChannelStats("*")
+	assert.Equal(t, int64(2), stats["orders"].Published)
+	assert.Equal(t, int64(1), stats["orders"].Delivered)
+	assert.Equal(t, int64(cap(sub.MessageCh)), stats["users"].Delivered)
+	assert.Equal(t, int64(2), stats["users"].Dropped)
+
+	psm.ResetChannelStats()
+	assert.Equal(t, 0, len(psm.GetChannelStats("*")))
+
+	psm.RemoveSubscriber(sub)
+	assert.Equal(t, 0, len(psm.GetShardChannels("*")))
+}
+
+func TestPublishGlobCharacterClasses(t *testing.T) {
+	psm := NewPubSubManager()
+	sub := NewSubscriber("classes")
+	psm.PSubscribe(sub, "h[ae]llo", "user:[^0-9]*", "log.[a-c]", `lit\*`)
+	assert.Equal(t, 4, len(psm.globPatterns))
+
+	cases := map[string]int{
+		"hello": 1, "hallo": 1, "hillo": 0,
+		"user:bob": 1, "user:42": 0,
+		"log.b": 1, "log.d": 0,
+		"lit*": 1, "literal": 0,
+	}
+	for channel, want := range cases {
+		assert.Equal(t, want, psm.Publish(channel, []byte("x")))
+		if want == 1 {
+			<-sub.MessageCh
+		}
+	}
+
+	// 多个订阅者订阅同一模式只算一个模式
+	other := NewSubscriber("other")
+	psm.PSubscribe(other, "h[ae]llo")
+	assert.Equal(t, 4, psm.GetPatternCount())
+	channels, patterns, shards := other.SubscriptionCounts()
+	assert.Equal(t, 0, channels)
+	assert.Equal(t, 1, patterns)
+	assert.Equal(t, 0, shards)
+}
+
+func TestMatchPattern(t *testing.T) {
+	assert.True(t, matchPattern("", "*"))
+	assert.True(t, matchPattern("abc", "a*c"))
+	assert.True(t, matchPattern("abbbc", "a*b?c"))
+	assert.False(t, matchPattern("abc", "a*d"))
+	assert.True(t, matchPattern("a[b", `a\[b`))
+	assert.True(t, matchPattern("x-9", "x-[0-9]"))
+	assert.True(t, matchPattern("x-9", "x-[9-0]"))
+	assert.True(t, matchPattern("a]", `a[\]]`))
+	assert.False(t, matchPattern("ab", "a[^b]"))
+	assert.True(t, matchPattern("中文", "中?"))
+}
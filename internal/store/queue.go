@@ -0,0 +1,236 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// 延迟队列建立在 Stream 之上：每条消息是一条 Stream 记录（字段 payload），
+// 另外维护一个按可投递时间排序的索引，作用与过期索引相同：
+//
+//	stream:<key>:qready:<8 字节可投递时间（Unix 毫秒）><id>  -> 空
+//	stream:<key>:qmsg:<id>                                 -> 8 字节可投递时间 + 8 字节投递次数
+//
+// QPOP 取出一条到期消息后把它的可投递时间推迟 visibility timeout，
+// 超时仍未 QACK 的消息会被再次投递（至少一次语义）
+const (
+	streamQueueReady = ":qready:"
+	streamQueueMsg   = ":qmsg:"
+
+	// QueuePayloadField 消息内容在 Stream 记录中的字段名
+	QueuePayloadField = "payload"
+)
+
+// QueueMessage QPOP 取出的消息
+type QueueMessage struct {
+	ID         string
+	Payload    string
+	Deliveries int64 // 包括本次在内的投递次数
+}
+
+func streamQueueReadyPrefix(key string) []byte {
+	return []byte(prefixStream + key + streamQueueReady)
+}
+
+func streamQueueReadyKey(key string, readyAt int64, id string) []byte {
+	prefix := streamQueueReadyPrefix(key)
+	k := make([]byte, len(prefix)+8, len(prefix)+8+len(id))
+	copy(k, prefix)
+	// #nosec G115 - 可投递时间为非负的 Unix 毫秒时间戳
+	binary.BigEndian.PutUint64(k[len(prefix):], uint64(readyAt))
+	return append(k, id...)
+}
+
+func streamQueueMsgPrefix(key string) []byte {
+	return []byte(prefixStream + key + streamQueueMsg)
+}
+
+func streamQueueMsgKey(key, id string) []byte {
+	return append(streamQueueMsgPrefix(key), id...)
+}
+
+func encodeQueueMsg(readyAt, deliveries int64) []byte {
+	b := make([]byte, 16)
+	// #nosec G115 - 均为非负数
+	binary.BigEndian.PutUint64(b[:8], uint64(readyAt))
+	// #nosec G115 - 均为非负数
+	binary.BigEndian.PutUint64(b[8:], uint64(deliveries))
+	return b
+}
+
+func decodeQueueMsg(b []byte) (readyAt, deliveries int64, err error) {
+	if len(b) != 16 {
+		return 0, 0, errors.New("invalid queue message metadata")
+	}
+	// #nosec G115 - 写入时为非负数
+	return int64(binary.BigEndian.Uint64(b[:8])), int64(binary.BigEndian.Uint64(b[8:])), nil
+}
+
+// QPush 向延迟队列追加一条消息，delay 之后才能被 QPOP 取出，返回消息 ID（即 Stream 记录 ID）
+func (s *BotreonStore) QPush(key string, delay time.Duration, payload string) (string, error) {
+	if delay < 0 {
+		delay = 0
+	}
+	readyAt := s.now().Add(delay).UnixMilli()
+	fields := map[string]string{QueuePayloadField: payload}
+	var id string
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		var err error
+		id, err = s.xaddTxn(txn, key, StreamXAddOptions{}, "*", fields)
+		if err != nil {
+			return err
+		}
+		if err := txn.Set(streamQueueReadyKey(key, readyAt, id), nil); err != nil {
+			return err
+		}
+		return txn.Set(streamQueueMsgKey(key, id), encodeQueueMsg(readyAt, 0))
+	}, 20)
+	if err != nil {
+		return "", err
+	}
+	s.notifyStreamRead(key)
+	return id, nil
+}
+
+// QPop 取出一条已到投递时间的消息，并在 visibility 之内对其他 QPOP 不可见；
+// 超时未确认时再次投递。没有可投递的消息时返回 nil
+func (s *BotreonStore) QPop(key string, visibility time.Duration) (*QueueMessage, error) {
+	if visibility < 0 {
+		visibility = 0
+	}
+	var msg *QueueMessage
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		msg = nil
+		now := s.now()
+		prefix := streamQueueReadyPrefix(key)
+
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		var readyKey []byte
+		var id string
+		var stale []string
+		for it.Rewind(); it.Valid(); it.Next() {
+			k := it.Item().Key()
+			if len(k) < len(prefix)+8 {
+				continue
+			}
+			// #nosec G115 - 写入时为非负的 Unix 毫秒时间戳
+			if int64(binary.BigEndian.Uint64(k[len(prefix):])) > now.UnixMilli() {
+				break
+			}
+			candidate := string(k[len(prefix)+8:])
+			if _, err := txn.Get(streamEntryIDKey(key, candidate)); errors.Is(err, badger.ErrKeyNotFound) {
+				stale = append(stale, string(k))
+				continue
+			} else if err != nil {
+				it.Close()
+				return err
+			}
+			readyKey = it.Item().KeyCopy(nil)
+			id = candidate
+			break
+		}
+		it.Close()
+
+		// 消息已被 XDEL/XTRIM 删除时顺便清理索引
+		for _, k := range stale {
+			if err := txn.Delete([]byte(k)); err != nil {
+				return err
+			}
+			if err := txn.Delete(streamQueueMsgKey(key, k[len(prefix)+8:])); err != nil {
+				return err
+			}
+		}
+		if readyKey == nil {
+			return nil
+		}
+
+		var deliveries int64
+		item, err := txn.Get(streamQueueMsgKey(key, id))
+		if err == nil {
+			err = item.Value(func(val []byte) error {
+				_, deliveries, err = decodeQueueMsg(val)
+				return err
+			})
+		}
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		deliveries++
+
+		var fields map[string]string
+		item, err = txn.Get(streamEntryIDKey(key, id))
+		if err != nil {
+			return err
+		}
+		if err := item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &fields)
+		}); err != nil {
+			return err
+		}
+
+		// 推迟可投递时间，超时未确认时再次投递
+		readyAt := now.Add(visibility).UnixMilli()
+		if err := txn.Delete(readyKey); err != nil {
+			return err
+		}
+		if err := txn.Set(streamQueueReadyKey(key, readyAt, id), nil); err != nil {
+			return err
+		}
+		if err := txn.Set(streamQueueMsgKey(key, id), encodeQueueMsg(readyAt, deliveries)); err != nil {
+			return err
+		}
+		msg = &QueueMessage{ID: id, Payload: fields[QueuePayloadField], Deliveries: deliveries}
+		return nil
+	}, 20)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// QAck 确认消息已处理，从队列和 Stream 中删除，返回删除的消息数
+func (s *BotreonStore) QAck(key string, ids ...string) (int64, error) {
+	var acked int64
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		acked = 0
+		var live []string
+		for _, id := range ids {
+			msgKey := streamQueueMsgKey(key, id)
+			item, err := txn.Get(msgKey)
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			var readyAt int64
+			if err := item.Value(func(val []byte) error {
+				readyAt, _, err = decodeQueueMsg(val)
+				return err
+			}); err != nil {
+				return err
+			}
+			if err := txn.Delete(streamQueueReadyKey(key, readyAt, id)); err != nil {
+				return err
+			}
+			if err := txn.Delete(msgKey); err != nil {
+				return err
+			}
+			live = append(live, id)
+		}
+		if len(live) == 0 {
+			return nil
+		}
+		var err error
+		acked, err = s.xdelTxn(txn, key, live)
+		return err
+	}, 20)
+	return acked, err
+}
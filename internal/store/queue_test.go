@@ -0,0 +1,86 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestDelayedQueue(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	clock := NewManualClock(time.UnixMilli(1_000_000))
+	store.SetClock(clock)
+
+	id1, err := store.QPush("q", 5*time.Second, "later")
+	assert.NoError(t, err)
+	id2, err := store.QPush("q", 0, "now")
+	assert.NoError(t, err)
+
+	// 消息保存为 Stream 记录
+	keyType, _ := store.Type("q")
+	assert.Equal(t, "stream", keyType)
+	length, _ := store.XLen("q")
+	assert.Equal(t, int64(2), length)
+
+	msg, err := store.QPop("q", 10*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, &QueueMessage{ID: id2, Payload: "now", Deliveries: 1}, msg)
+
+	// 第一条还未到期，第二条在可见性超时内
+	msg, err = store.QPop("q", 10*time.Second)
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+
+	clock.Advance(5 * time.Second)
+	msg, err = store.QPop("q", 10*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, id1, msg.ID)
+	acked, err := store.QAck("q", id1, "1-1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), acked)
+
+	// 未确认的消息超时后再次投递
+	clock.Advance(5 * time.Second)
+	msg, err = store.QPop("q", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, &QueueMessage{ID: id2, Payload: "now", Deliveries: 2}, msg)
+
+	// DUMP/RESTORE 保留投递状态
+	data, err := store.Dump("q")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Restore("copy", data, 0, false))
+	clock.Advance(time.Second)
+	msg, err = store.QPop("copy", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, &QueueMessage{ID: id2, Payload: "now", Deliveries: 3}, msg)
+
+	acked, err = store.QAck("q", id2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), acked)
+	length, _ = store.XLen("q")
+	assert.Equal(t, int64(0), length)
+	clock.Advance(time.Hour)
+	msg, err = store.QPop("q", time.Second)
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+
+	// 被 XDEL 删除的消息不再投递，DEL 清理队列索引
+	id3, err := store.QPush("copy", 0, "x")
+	assert.NoError(t, err)
+	_, err = store.XDel("copy", id2)
+	assert.NoError(t, err)
+	msg, err = store.QPop("copy", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, id3, msg.ID)
+	_, err = store.Del("copy")
+	assert.NoError(t, err)
+	_, err = store.XAdd("copy", StreamXAddOptions{}, "*", map[string]string{"payload": "plain"})
+	assert.NoError(t, err)
+	clock.Advance(time.Hour)
+	msg, err = store.QPop("copy", time.Second)
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+}
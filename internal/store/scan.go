@@ -0,0 +1,149 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// ErrInvalidCursor SCAN 系列命令的游标不存在：已过期、服务器已重启，或属于其他命令或键
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	// scanCursorTTL 游标创建后的有效期，超过后需要从 0 重新开始遍历
+	scanCursorTTL = time.Hour
+	// maxScanCursors 最多保留的游标数，超过时淘汰最早创建的
+	maxScanCursors = 100000
+)
+
+// scanCursor 一页遍历结束的位置
+type scanCursor struct {
+	scope   string // 命令与键，游标只能用于创建它的遍历
+	last    []byte // 本页最后检查的 Badger 键
+	created time.Time
+}
+
+// scanCursors SCAN/SSCAN/HSCAN/ZSCAN 的游标表。客户端只能处理整数游标，
+// 因此把上一页最后检查的 Badger 键保存在服务端，游标是它的编号
+type scanCursors struct {
+	mu      sync.Mutex
+	next    uint64
+	entries map[uint64]scanCursor
+	order   []uint64 // 按创建顺序，用于淘汰
+}
+
+// save 保存一页的结束位置，返回新游标（非 0）
+func (c *scanCursors) save(scope string, last []byte) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.entries == nil {
+		c.entries = make(map[uint64]scanCursor)
+		// 编号从时间派生，服务器重启后旧游标大概率无效而不是指向别的位置；
+		// 保持在 2^53 以内，JavaScript 等客户端也能精确表示
+		c.next = uint64(now.UnixNano()>>12) + 1 // #nosec G115 - 时间戳为正数
+	}
+	for len(c.order) > 0 {
+		oldest, ok := c.entries[c.order[0]]
+		if ok && len(c.entries) < maxScanCursors && now.Sub(oldest.created) < scanCursorTTL {
+			break
+		}
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	id := c.next
+	c.next++
+	c.entries[id] = scanCursor{scope: scope, last: last, created: now}
+	c.order = append(c.order, id)
+	return id
+}
+
+// load 返回游标对应的结束位置
+func (c *scanCursors) load(scope string, id uint64) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[id]
+	if !ok || entry.scope != scope || time.Since(entry.created) >= scanCursorTTL {
+		return nil, ErrInvalidCursor
+	}
+	return entry.last, nil
+}
+
+// scanPrefix 从 cursor 之后按键的顺序继续遍历 prefix 下的 Badger 键，最多检查 count 个，
+// 对每个键调用 visit，返回下一页的游标（0 表示遍历结束）。
+// 下一页从上一页最后检查的键之后 Seek，因此并发的插入和删除不会使整个遍历期间都存在的键
+// 被跳过或重复返回；每页至少前进一个键，遍历一定会结束
+func (s *BotreonStore) scanPrefix(scope string, prefix []byte, cursor uint64, count int, visit func(item *badger.Item) error) (uint64, error) {
+	var last []byte
+	if cursor != 0 {
+		var err error
+		if last, err = s.scanCursors.load(scope, cursor); err != nil {
+			return 0, err
+		}
+	}
+	if count <= 0 {
+		count = 10 // 默认值
+	}
+
+	var next uint64
+	err := s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+
+		if last == nil {
+			iter.Seek(prefix)
+		} else {
+			iter.Seek(last)
+			if iter.ValidForPrefix(prefix) && bytes.Equal(iter.Item().Key(), last) {
+				iter.Next()
+			}
+		}
+		var seen []byte
+		for examined := 0; examined < count && iter.ValidForPrefix(prefix); iter.Next() {
+			item := iter.Item()
+			if err := visit(item); err != nil {
+				return err
+			}
+			seen = item.KeyCopy(seen)
+			examined++
+		}
+		if iter.ValidForPrefix(prefix) {
+			next = s.scanCursors.save(scope, seen)
+		}
+		return nil
+	})
+	return next, err
+}
+
+// redisTypeName 类型键中保存的内部类型对应的 Redis 类型名（TYPE 命令的返回值）
+func redisTypeName(keyType string) string {
+	switch keyType {
+	case KeyTypeString:
+		return "string"
+	case KeyTypeList:
+		return "list"
+	case KeyTypeHash:
+		return "hash"
+	case KeyTypeSet:
+		return "set"
+	case KeyTypeSortedSet:
+		return "zset"
+	case KeyTypeJSON:
+		return "json"
+	case KeyTypeTimeSeries:
+		return "ts"
+	case KeyTypeStream:
+		return "stream"
+	case keyTypeHyperLogLog:
+		// 与 Redis 相同，HyperLogLog 的类型是 string
+		return "string"
+	default:
+		return "none"
+	}
+}
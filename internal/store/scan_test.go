@@ -0,0 +1,104 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestScanStableUnderWrites(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, store.Set(fmt.Sprintf("stable:%03d", i), "v"))
+	}
+
+	// 每页之间插入和删除其他键，整个遍历期间都存在的键恰好返回一次
+	seen := make(map[string]int)
+	cursor := uint64(0)
+	for page := 0; ; page++ {
+		result, err := store.Scan(cursor, "stable:*", 7, "")
+		assert.NoError(t, err)
+		for _, key := range result.Keys {
+			seen[key]++
+		}
+		assert.NoError(t, store.Set(fmt.Sprintf("a:%03d", page), "v"))
+		assert.NoError(t, store.Set(fmt.Sprintf("stable:%03d:new", page), "v"))
+		_, err = store.Del(fmt.Sprintf("a:%03d", page-1))
+		assert.NoError(t, err)
+		if result.Cursor == 0 {
+			break
+		}
+		assert.True(t, page < 100)
+		cursor = result.Cursor
+	}
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, 1, seen[fmt.Sprintf("stable:%03d", i)])
+	}
+
+	// TYPE 过滤
+	_, err = store.LPush("stable:list", "x")
+	assert.NoError(t, err)
+	var lists []string
+	for cursor = 0; ; {
+		result, err := store.Scan(cursor, "", 50, "list")
+		assert.NoError(t, err)
+		lists = append(lists, result.Keys...)
+		if result.Cursor == 0 {
+			break
+		}
+		cursor = result.Cursor
+	}
+	assert.Equal(t, []string{"stable:list"}, lists)
+
+	// 未知的游标与属于其他遍历的游标
+	_, err = store.Scan(12345, "", 10, "")
+	assert.Equal(t, ErrInvalidCursor, err)
+	result, err := store.Scan(0, "", 1, "")
+	assert.NoError(t, err)
+	_, err = store.SScan("set", result.Cursor, "", 10)
+	assert.Equal(t, ErrInvalidCursor, err)
+}
+
+func TestScanCollections(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.HSet("h", "f1", "v1"))
+	assert.NoError(t, store.HSet("h", "f:2", "v2"))
+	result, err := store.HScan("h", 0, "", 1, false)
+	assert.NoError(t, err)
+	assert.True(t, result.Cursor != 0)
+	fields := result.Fields
+	values := result.Values
+	result, err = store.HScan("h", result.Cursor, "", 10, false)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), result.Cursor)
+	fields = append(fields, result.Fields...)
+	values = append(values, result.Values...)
+	assert.Equal(t, []string{"f1", "f:2"}, fields)
+	assert.Equal(t, [][]byte{[]byte("v1"), []byte("v2")}, values)
+	result, err = store.HScan("h", 0, "f1", 10, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"f1"}, result.Fields)
+	assert.Nil(t, result.Values)
+
+	_, err = store.SAdd("s", "a", "b", "c")
+	assert.NoError(t, err)
+	sresult, err := store.SScan("s", 0, "", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, sresult.Members)
+	sresult, err = store.SScan("s", sresult.Cursor, "c*", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), sresult.Cursor)
+	assert.Equal(t, []string{"c"}, sresult.Members)
+
+	assert.NoError(t, store.ZAdd("z", []ZSetMember{{Member: "m:1", Score: 1.5}, {Member: "n", Score: -2}}))
+	zresult, err := store.ZScan("z", 0, "", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZSetMember{{Member: "m:1", Score: 1.5}, {Member: "n", Score: -2}}, zresult.Members)
+}
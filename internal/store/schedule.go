@@ -0,0 +1,201 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/lbp0200/BoltDB/internal/logger"
+)
+
+// metaSchedulePrefix 定时命令的内部键前缀（不带 TYPE_ 前缀，不会出现在 KEYS/SCAN 中）
+// 键为前缀加 8 字节执行时间（Unix 毫秒）和 8 字节序号，按执行时间有序
+const metaSchedulePrefix = "META:schedule:"
+
+// ErrInvalidScheduleID 定时命令 ID 格式错误
+var ErrInvalidScheduleID = errors.New("invalid schedule id")
+
+// ScheduledCommand 一条定时命令，ID 格式为 "<执行时间毫秒>-<序号>"
+type ScheduledCommand struct {
+	ID   string   `json:"id"`
+	At   int64    `json:"at"`
+	Args []string `json:"args"`
+}
+
+// scheduleSeq 定时命令序号，启动时从已保存的最大序号继续
+type scheduleSeq struct {
+	next atomic.Uint64
+}
+
+func scheduleKey(at int64, seq uint64) []byte {
+	key := make([]byte, len(metaSchedulePrefix)+16)
+	copy(key, metaSchedulePrefix)
+	// #nosec G115 - 执行时间为非负的 Unix 毫秒时间戳
+	binary.BigEndian.PutUint64(key[len(metaSchedulePrefix):], uint64(at))
+	binary.BigEndian.PutUint64(key[len(metaSchedulePrefix)+8:], seq)
+	return key
+}
+
+// parseScheduleID 解析 "<at>-<seq>"
+func parseScheduleID(id string) (int64, uint64, error) {
+	atPart, seqPart, ok := strings.Cut(id, "-")
+	if !ok {
+		return 0, 0, ErrInvalidScheduleID
+	}
+	at, err := strconv.ParseInt(atPart, 10, 64)
+	if err != nil || at < 0 {
+		return 0, 0, ErrInvalidScheduleID
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidScheduleID
+	}
+	return at, seq, nil
+}
+
+// loadScheduleSeq 启动时恢复序号，保证新 ID 不与已保存的定时命令重复
+func (s *BotreonStore) loadScheduleSeq() error {
+	prefix := []byte(metaSchedulePrefix)
+	var maxSeq uint64
+	err := s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			key := it.Item().Key()
+			if len(key) != len(prefix)+16 {
+				continue
+			}
+			if seq := binary.BigEndian.Uint64(key[len(prefix)+8:]); seq > maxSeq {
+				maxSeq = seq
+			}
+		}
+		return nil
+	})
+	s.schedule.next.Store(maxSeq)
+	return err
+}
+
+// Schedule 登记一条在 at（Unix 毫秒）执行的命令，返回其 ID。定时命令随数据一起持久化，重启后仍会执行
+func (s *BotreonStore) Schedule(at int64, args []string) (string, error) {
+	if at < 0 {
+		at = 0
+	}
+	seq := s.schedule.next.Add(1)
+	cmd := ScheduledCommand{
+		ID:   strconv.FormatInt(at, 10) + "-" + strconv.FormatUint(seq, 10),
+		At:   at,
+		Args: args,
+	}
+	value, err := json.Marshal(cmd)
+	if err != nil {
+		return "", err
+	}
+	err = s.update(func(txn *storeTxn) error {
+		return txn.Set(scheduleKey(at, seq), value)
+	})
+	if err != nil {
+		return "", err
+	}
+	return cmd.ID, nil
+}
+
+// Unschedule 取消尚未执行的定时命令，返回是否存在
+func (s *BotreonStore) Unschedule(id string) (bool, error) {
+	at, seq, err := parseScheduleID(id)
+	if err != nil {
+		return false, err
+	}
+	key := scheduleKey(at, seq)
+	removed := false
+	err = s.update(func(txn *storeTxn) error {
+		if _, err := txn.Get(key); err != nil {
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			return err
+		}
+		removed = true
+		return txn.Delete(key)
+	})
+	return removed, err
+}
+
+// ScheduledCommands 按执行时间顺序返回所有尚未执行的定时命令
+func (s *BotreonStore) ScheduledCommands() ([]ScheduledCommand, error) {
+	var cmds []ScheduledCommand
+	err := s.view(func(txn *storeTxn) error {
+		var err error
+		cmds, _, err = scanScheduled(txn, -1, 0)
+		return err
+	})
+	return cmds, err
+}
+
+// TakeDueScheduled 取出最多 limit 条已到执行时间的定时命令，并在同一事务中删除。
+// 取出后由调用方执行，因此每条命令最多执行一次
+func (s *BotreonStore) TakeDueScheduled(limit int) ([]ScheduledCommand, error) {
+	now := s.now().UnixMilli()
+	var cmds []ScheduledCommand
+	err := s.update(func(txn *storeTxn) error {
+		var keys [][]byte
+		var err error
+		cmds, keys, err = scanScheduled(txn, now, limit)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cmds, nil
+}
+
+// scanScheduled 按执行时间顺序读取定时命令；until >= 0 时只读取执行时间不晚于 until 的，limit > 0 时限制条数
+func scanScheduled(txn *storeTxn, until int64, limit int) ([]ScheduledCommand, [][]byte, error) {
+	prefix := []byte(metaSchedulePrefix)
+	var cmds []ScheduledCommand
+	var keys [][]byte
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		if limit > 0 && len(cmds) >= limit {
+			break
+		}
+		item := it.Item()
+		key := item.KeyCopy(nil)
+		if len(key) != len(prefix)+16 {
+			continue
+		}
+		// #nosec G115 - 写入时为非负的 Unix 毫秒时间戳
+		if until >= 0 && int64(binary.BigEndian.Uint64(key[len(prefix):])) > until {
+			break
+		}
+		var cmd ScheduledCommand
+		err := item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &cmd)
+		})
+		if err != nil || len(cmd.Args) == 0 {
+			logger.Logger.Warn().Err(err).Msg("丢弃无效的定时命令")
+			keys = append(keys, key)
+			continue
+		}
+		cmds = append(cmds, cmd)
+		keys = append(keys, key)
+	}
+	return cmds, keys, nil
+}
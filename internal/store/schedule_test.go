@@ -0,0 +1,75 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestSchedule(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBadgerStore(dir)
+	assert.NoError(t, err)
+	clock := NewManualClock(time.UnixMilli(1000))
+	store.SetClock(clock)
+
+	id1, err := store.Schedule(3000, []string{"DEL", "a"})
+	assert.NoError(t, err)
+	id2, err := store.Schedule(2000, []string{"LPUSH", "jobs", "x"})
+	assert.NoError(t, err)
+	id3, err := store.Schedule(2000, []string{"SET", "k", "v"})
+	assert.NoError(t, err)
+	assert.Equal(t, "3000-1", id1)
+
+	// 按执行时间排序，同一时间按登记顺序
+	cmds, err := store.ScheduledCommands()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(cmds))
+	assert.Equal(t, id2, cmds[0].ID)
+	assert.Equal(t, id3, cmds[1].ID)
+	assert.Equal(t, []string{"DEL", "a"}, cmds[2].Args)
+
+	due, err := store.TakeDueScheduled(10)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(due))
+
+	// 重启后定时命令仍在，新 ID 不与旧 ID 重复
+	assert.NoError(t, store.Close())
+	store, err = NewBadgerStore(dir)
+	assert.NoError(t, err)
+	defer store.Close()
+	store.SetClock(clock)
+	id4, err := store.Schedule(2500, []string{"INCR", "n"})
+	assert.NoError(t, err)
+	assert.Equal(t, "2500-4", id4)
+
+	removed, err := store.Unschedule(id4)
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = store.Unschedule(id4)
+	assert.NoError(t, err)
+	assert.False(t, removed)
+	_, err = store.Unschedule("bogus")
+	assert.Equal(t, ErrInvalidScheduleID, err)
+
+	clock.Advance(time.Second)
+	due, err = store.TakeDueScheduled(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(due))
+	assert.Equal(t, id2, due[0].ID)
+	due, err = store.TakeDueScheduled(10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(due))
+	assert.Equal(t, id3, due[0].ID)
+
+	// 取出后不再重复返回
+	clock.Advance(time.Second)
+	due, err = store.TakeDueScheduled(10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(due))
+	assert.Equal(t, id1, due[0].ID)
+	cmds, err = store.ScheduledCommands()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(cmds))
+}
@@ -0,0 +1,945 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/lbp0200/BoltDB/internal/logger"
+)
+
+// metaSearchPrefix 搜索索引定义的内部键前缀（不带 TYPE_ 前缀，不会出现在 KEYS/SCAN 中）
+const metaSearchPrefix = "META:ft:"
+
+// searchKeyPrefix 索引条目的键前缀，完整前缀为 FT:<索引名>\x00<创建时间>\x00。
+// 索引名后用 \x00 分隔，按前缀删除一个索引不会误删名字以它开头的其他索引；
+// 创建时间区分同名索引的前后两代，删除索引时并发写入的残留条目不会被新索引读到
+const searchKeyPrefix = "FT:"
+
+// searchBackfillBatch 后台为已有键建立索引时每个事务处理的键数
+const searchBackfillBatch = 100
+
+// 搜索索引的字段类型
+const (
+	SearchFieldText    = "TEXT"
+	SearchFieldNumeric = "NUMERIC"
+	SearchFieldTag     = "TAG"
+)
+
+// 搜索错误，文本与 RediSearch 相同
+var (
+	ErrSearchIndexExists  = errors.New("Index already exists")
+	ErrSearchUnknownIndex = errors.New("Unknown Index name")
+)
+
+// SearchIndex 搜索索引的定义：为键名以 Prefixes 之一开头的哈希或 JSON 文档建立 Fields 的索引
+type SearchIndex struct {
+	Name     string        `json:"name"`
+	On       string        `json:"on"` // KeyTypeHash 或 KeyTypeJSON
+	Prefixes []string      `json:"prefixes,omitempty"`
+	Fields   []SearchField `json:"fields"`
+	Created  int64         `json:"created"` // 创建时间（纳秒），区分同名索引
+	Ready    bool          `json:"ready"`   // 已有键的后台索引已完成
+}
+
+// SearchField 索引的一个字段
+type SearchField struct {
+	Path      string `json:"path"` // 哈希字段名，或 JSON 文档中的 JSONPath
+	Name      string `json:"name"` // 查询中使用的属性名（AS），默认与 Path 相同
+	Type      string `json:"type"` // SearchFieldText、SearchFieldNumeric 或 SearchFieldTag
+	Sortable  bool   `json:"sortable,omitempty"`
+	Separator string `json:"separator,omitempty"` // 哈希中 TAG 字段的分隔符，默认 ","
+}
+
+// searchIndex 已加载的索引
+type searchIndex struct {
+	def     SearchIndex
+	paths   []*jsonPath // JSON 索引各字段编译后的路径
+	prefix  string      // 索引条目的键前缀
+	dropped atomic.Bool // 已删除或数据库已清空，后台索引应当停止
+	done    chan struct{}
+}
+
+// searchIndexes 已定义的搜索索引，启动时从存储加载
+type searchIndexes struct {
+	mu     sync.RWMutex
+	byName map[string]*searchIndex
+	active atomic.Bool // 是否定义了索引，未定义时写事务不做任何额外工作
+	ddl    sync.Mutex  // 串行执行创建与删除
+	wg     sync.WaitGroup
+}
+
+func newSearchIndex(def SearchIndex) (*searchIndex, error) {
+	idx := &searchIndex{
+		def:    def,
+		prefix: fmt.Sprintf("%s%s\x00%d\x00", searchKeyPrefix, def.Name, def.Created),
+		done:   make(chan struct{}),
+	}
+	if def.On == KeyTypeJSON {
+		for _, f := range def.Fields {
+			p, err := parseJSONPath(f.Path)
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSONPath '%s' in schema", f.Path)
+			}
+			idx.paths = append(idx.paths, p)
+		}
+	}
+	if def.Ready {
+		close(idx.done)
+	}
+	return idx, nil
+}
+
+// field 按属性名查找字段
+func (idx *searchIndex) field(name string) (int, bool) {
+	for i, f := range idx.def.Fields {
+		if f.Name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// matches 键名是否在索引范围内
+func (idx *searchIndex) matches(key string) bool {
+	if len(idx.def.Prefixes) == 0 {
+		return true
+	}
+	for _, p := range idx.def.Prefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// 索引条目的编码（均以 idx.prefix 开头，值为空）：
+//
+//	d\x00<key>                              已索引的文档，值为该文档的条目列表
+//	t\x00<field>\x00<term>\x00<key>         TEXT 字段中的词
+//	g\x00<field>\x00<tag>\x00<key>          TAG 字段中的标签
+//	n\x00<field>\x00<value:8><key>          NUMERIC 字段的值，按数值排序
+func (idx *searchIndex) docKey(key string) []byte {
+	return []byte(idx.prefix + "d\x00" + key)
+}
+
+func (idx *searchIndex) docPrefix() []byte {
+	return []byte(idx.prefix + "d\x00")
+}
+
+func (idx *searchIndex) termPrefix(field, term string) []byte {
+	return []byte(idx.prefix + "t\x00" + field + "\x00" + term)
+}
+
+func (idx *searchIndex) tagPrefix(field, tag string) []byte {
+	return []byte(idx.prefix + "g\x00" + field + "\x00" + tag + "\x00")
+}
+
+func (idx *searchIndex) numericPrefix(field string) []byte {
+	return []byte(idx.prefix + "n\x00" + field + "\x00")
+}
+
+func (s *BotreonStore) loadSearchIndexes() error {
+	prefix := []byte(metaSearchPrefix)
+	s.search.byName = make(map[string]*searchIndex)
+	err := s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			var def SearchIndex
+			err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &def)
+			})
+			var idx *searchIndex
+			if err == nil {
+				idx, err = newSearchIndex(def)
+			}
+			if err != nil || def.Name == "" {
+				logger.Logger.Warn().Err(err).Str("key", string(it.Item().Key())).Msg("忽略无效的搜索索引定义")
+				continue
+			}
+			s.search.byName[def.Name] = idx
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.search.active.Store(len(s.search.byName) > 0)
+	// 上次退出时没有完成的后台索引从头继续
+	for _, idx := range s.search.byName {
+		if !idx.def.Ready {
+			s.startSearchBackfill(idx)
+		}
+	}
+	return nil
+}
+
+// CreateSearchIndex 定义搜索索引（FT.CREATE），定义持久化。新写入的文档在写入的事务中建立索引，
+// 已有的键在后台建立索引，完成前的查询结果可能不完整
+func (s *BotreonStore) CreateSearchIndex(def SearchIndex) error {
+	if def.Name == "" || strings.ContainsRune(def.Name, 0) {
+		return errors.New("invalid index name")
+	}
+	if def.On != KeyTypeHash && def.On != KeyTypeJSON {
+		return fmt.Errorf("unsupported index type '%s'", def.On)
+	}
+	if len(def.Fields) == 0 {
+		return errors.New("schema must contain at least one field")
+	}
+	names := make(map[string]bool, len(def.Fields))
+	for i := range def.Fields {
+		f := &def.Fields[i]
+		if f.Name == "" {
+			f.Name = f.Path
+		}
+		if f.Path == "" || strings.ContainsRune(f.Name, 0) {
+			return errors.New("invalid field name in schema")
+		}
+		if names[f.Name] {
+			return fmt.Errorf("Duplicate field in schema - %s", f.Name)
+		}
+		names[f.Name] = true
+		switch f.Type {
+		case SearchFieldText, SearchFieldNumeric:
+		case SearchFieldTag:
+			if f.Separator == "" {
+				f.Separator = ","
+			}
+		default:
+			return fmt.Errorf("invalid field type for field '%s'", f.Name)
+		}
+	}
+	def.Created = s.now().UnixNano()
+	def.Ready = false
+	idx, err := newSearchIndex(def)
+	if err != nil {
+		return err
+	}
+
+	s.search.ddl.Lock()
+	defer s.search.ddl.Unlock()
+	s.search.mu.RLock()
+	_, exists := s.search.byName[def.Name]
+	s.search.mu.RUnlock()
+	if exists {
+		return ErrSearchIndexExists
+	}
+	if err := s.saveSearchIndex(def); err != nil {
+		return err
+	}
+	s.search.mu.Lock()
+	s.search.byName[def.Name] = idx
+	s.search.active.Store(true)
+	s.search.mu.Unlock()
+	s.startSearchBackfill(idx)
+	return nil
+}
+
+func (s *BotreonStore) saveSearchIndex(def SearchIndex) error {
+	value, err := json.Marshal(def)
+	if err != nil {
+		return err
+	}
+	return s.update(func(txn *storeTxn) error {
+		return txn.Set([]byte(metaSearchPrefix+def.Name), value)
+	})
+}
+
+// DropSearchIndex 删除搜索索引（FT.DROPINDEX）及其全部条目，deleteDocs 为 true 时同时删除已索引的文档
+func (s *BotreonStore) DropSearchIndex(name string, deleteDocs bool) error {
+	s.search.ddl.Lock()
+	defer s.search.ddl.Unlock()
+	s.search.mu.Lock()
+	idx, ok := s.search.byName[name]
+	if ok {
+		delete(s.search.byName, name)
+		s.search.active.Store(len(s.search.byName) > 0)
+	}
+	s.search.mu.Unlock()
+	if !ok {
+		return ErrSearchUnknownIndex
+	}
+	idx.dropped.Store(true)
+	<-idx.done
+
+	err := s.update(func(txn *storeTxn) error {
+		return txn.Delete([]byte(metaSearchPrefix + name))
+	})
+	if err != nil {
+		return err
+	}
+	var docs []string
+	if deleteDocs {
+		if docs, err = s.searchDocs(idx); err != nil {
+			return err
+		}
+	}
+	if err := s.db.DropPrefix([]byte(idx.prefix)); err != nil {
+		return err
+	}
+	if err := s.bumpDropEpoch(); err != nil {
+		return err
+	}
+	for _, key := range docs {
+		if _, err := s.Del(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchIndexNames 按名称排序返回所有搜索索引（FT._LIST）
+func (s *BotreonStore) SearchIndexNames() []string {
+	s.search.mu.RLock()
+	defer s.search.mu.RUnlock()
+	names := make([]string, 0, len(s.search.byName))
+	for name := range s.search.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SearchIndexInfo FT.INFO 的内容
+type SearchIndexInfo struct {
+	SearchIndex
+	NumDocs  int64 // 已索引的文档数
+	Indexing bool  // 后台索引尚未完成
+}
+
+// SearchIndexInfo 返回索引的定义与已索引的文档数
+func (s *BotreonStore) SearchIndexInfo(name string) (SearchIndexInfo, error) {
+	idx, err := s.searchIndex(name)
+	if err != nil {
+		return SearchIndexInfo{}, err
+	}
+	info := SearchIndexInfo{SearchIndex: idx.def}
+	select {
+	case <-idx.done:
+		info.Ready = true
+	default:
+		info.Indexing = true
+	}
+	docs, err := s.searchDocs(idx)
+	info.NumDocs = int64(len(docs))
+	return info, err
+}
+
+func (s *BotreonStore) searchIndex(name string) (*searchIndex, error) {
+	s.search.mu.RLock()
+	defer s.search.mu.RUnlock()
+	idx, ok := s.search.byName[name]
+	if !ok {
+		return nil, ErrSearchUnknownIndex
+	}
+	return idx, nil
+}
+
+// searchDocs 已索引的全部文档
+func (s *BotreonStore) searchDocs(idx *searchIndex) ([]string, error) {
+	var docs []string
+	err := s.view(func(txn *storeTxn) error {
+		set, err := searchScan(txn, idx.docPrefix(), func(rest []byte) []byte { return rest })
+		for key := range set {
+			docs = append(docs, key)
+		}
+		return err
+	})
+	sort.Strings(docs)
+	return docs, err
+}
+
+// resetSearchIndexes 数据库清空后索引定义与条目一起被删除
+func (s *BotreonStore) resetSearchIndexes() {
+	s.search.mu.Lock()
+	for _, idx := range s.search.byName {
+		idx.dropped.Store(true)
+	}
+	s.search.byName = make(map[string]*searchIndex)
+	s.search.active.Store(false)
+	s.search.mu.Unlock()
+}
+
+// stopSearchBackfill 关闭存储前停止后台索引，下次启动时重新开始
+func (s *BotreonStore) stopSearchBackfill() {
+	s.search.mu.RLock()
+	for _, idx := range s.search.byName {
+		idx.dropped.Store(true)
+	}
+	s.search.mu.RUnlock()
+	s.search.wg.Wait()
+}
+
+// startSearchBackfill 在后台为已有的键建立索引，完成后在定义中记录
+func (s *BotreonStore) startSearchBackfill(idx *searchIndex) {
+	s.search.wg.Add(1)
+	go func() {
+		defer s.search.wg.Done()
+		defer close(idx.done)
+		start := time.Now()
+		indexed, err := s.backfillSearchIndex(idx)
+		if err != nil {
+			logger.Logger.Error().Err(err).Str("index", idx.def.Name).Msg("后台建立搜索索引失败")
+			return
+		}
+		if idx.dropped.Load() {
+			return
+		}
+		def := idx.def
+		def.Ready = true
+		if err := s.saveSearchIndex(def); err != nil {
+			logger.Logger.Error().Err(err).Str("index", def.Name).Msg("保存搜索索引状态失败")
+			return
+		}
+		logger.Logger.Info().Str("index", def.Name).Int("docs", indexed).Dur("duration", time.Since(start)).Msg("搜索索引建立完成")
+	}()
+}
+
+// backfillSearchIndex 按前缀扫描类型键，每 searchBackfillBatch 个键一个事务建立索引，返回处理的键数。
+// 与同一文档的并发写入冲突时重试，写入方已在自己的事务中更新了索引
+func (s *BotreonStore) backfillSearchIndex(idx *searchIndex) (int, error) {
+	prefixes := idx.def.Prefixes
+	if len(prefixes) == 0 {
+		prefixes = []string{""}
+	}
+	total := 0
+	for _, prefix := range prefixes {
+		typePrefix := TypeOfKeyGet(prefix)
+		seek := typePrefix
+		for !idx.dropped.Load() {
+			var keys []string
+			err := s.view(func(txn *storeTxn) error {
+				opts := badger.DefaultIteratorOptions
+				opts.PrefetchValues = false
+				opts.Prefix = typePrefix
+				it := txn.NewIterator(opts)
+				defer it.Close()
+				for it.Seek(seek); it.Valid() && len(keys) < searchBackfillBatch; it.Next() {
+					keys = append(keys, string(it.Item().Key()[len(prefixKeyTypeBytes):]))
+				}
+				return nil
+			})
+			if err != nil {
+				return total, err
+			}
+			if len(keys) == 0 {
+				break
+			}
+			err = s.retryUpdate(func(txn *storeTxn) error {
+				for _, key := range keys {
+					if err := s.searchReindexTxn(txn, idx, key); err != nil {
+						return err
+					}
+				}
+				return nil
+			}, 30)
+			if err != nil {
+				return total, err
+			}
+			total += len(keys)
+			// 下一批从最后一个键之后开始
+			seek = append(TypeOfKeyGet(keys[len(keys)-1]), 0)
+		}
+	}
+	return total, nil
+}
+
+// indexPendingTxn 在写事务提交前更新受影响文档的索引：由待写入的类型键、JSON 键与哈希字段键
+// 得到可能被修改的用户键，对每个范围内的索引重新计算条目
+func (s *BotreonStore) indexPendingTxn(txn *storeTxn) error {
+	docs := make(map[string]struct{})
+	for _, k := range txn.pendingKeys() {
+		if !bytes.HasPrefix(k, prefixKeyTypeBytes) && !bytes.HasPrefix(k, prefixKeyJSONBytes) &&
+			!bytes.HasPrefix(k, []byte(KeyTypeHash+":")) {
+			continue
+		}
+		userKeyCandidates(k, func(key []byte) {
+			docs[string(key)] = struct{}{}
+		})
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+	s.search.mu.RLock()
+	indexes := make([]*searchIndex, 0, len(s.search.byName))
+	for _, idx := range s.search.byName {
+		indexes = append(indexes, idx)
+	}
+	s.search.mu.RUnlock()
+	for key := range docs {
+		for _, idx := range indexes {
+			if !idx.matches(key) {
+				continue
+			}
+			if err := s.searchReindexTxn(txn, idx, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// searchReindexTxn 按 key 在 txn 中的当前内容更新它在索引中的条目：删除不再存在的条目，写入新的条目
+func (s *BotreonStore) searchReindexTxn(txn *storeTxn, idx *searchIndex, key string) error {
+	docKey := idx.docKey(key)
+	old := make(map[string]bool)
+	item, err := txn.Get(docKey)
+	if err == nil {
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		for _, e := range decodeSearchEntries(val) {
+			old[e] = true
+		}
+	} else if !errors.Is(err, badger.ErrKeyNotFound) {
+		return err
+	}
+
+	entries, indexed, err := s.searchEntriesTxn(txn, idx, key)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if old[e] {
+			delete(old, e)
+			continue
+		}
+		if err := txn.Set([]byte(idx.prefix+e), nil); err != nil {
+			return err
+		}
+	}
+	for e := range old {
+		if err := txn.Delete([]byte(idx.prefix + e)); err != nil {
+			return err
+		}
+	}
+	if !indexed {
+		if item == nil {
+			return nil
+		}
+		return txn.Delete(docKey)
+	}
+	return txn.Set(docKey, encodeSearchEntries(entries))
+}
+
+// searchEntriesTxn 计算文档的索引条目（不含 idx.prefix）。键不存在或类型不是索引的类型时 indexed 为 false
+func (s *BotreonStore) searchEntriesTxn(txn *storeTxn, idx *searchIndex, key string) (entries []string, indexed bool, err error) {
+	item, err := txn.Get(TypeOfKeyGet(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	keyType, err := item.ValueCopy(nil)
+	if err != nil || string(keyType) != idx.def.On {
+		return nil, false, err
+	}
+
+	values := make([][]interface{}, len(idx.def.Fields))
+	switch idx.def.On {
+	case KeyTypeHash:
+		for i, f := range idx.def.Fields {
+			item, err := txn.Get(s.hashKey(key, f.Path))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, false, err
+			}
+			val, err := s.readValueTxn(item)
+			if err != nil {
+				return nil, false, err
+			}
+			values[i] = []interface{}{string(val)}
+		}
+	case KeyTypeJSON:
+		root, err := s.jsonDocTxn(txn, key)
+		if errors.Is(err, ErrKeyNotFound) {
+			return nil, false, nil
+		}
+		if err != nil {
+			// 无法解析的文档不建立索引，不影响写入
+			return nil, true, nil
+		}
+		for i := range idx.def.Fields {
+			for _, n := range idx.paths[i].eval(&root) {
+				values[i] = append(values[i], n.value)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	add := func(e string) {
+		if !seen[e] {
+			seen[e] = true
+			entries = append(entries, e)
+		}
+	}
+	for i, f := range idx.def.Fields {
+		for _, v := range values[i] {
+			switch f.Type {
+			case SearchFieldText:
+				if str, ok := v.(string); ok {
+					for _, term := range searchTokens(str) {
+						add("t\x00" + f.Name + "\x00" + term + "\x00" + key)
+					}
+				}
+			case SearchFieldTag:
+				for _, tag := range searchTagValues(v, f.Separator, idx.def.On == KeyTypeHash) {
+					add("g\x00" + f.Name + "\x00" + tag + "\x00" + key)
+				}
+			case SearchFieldNumeric:
+				if num, ok := searchNumber(v); ok {
+					add("n\x00" + f.Name + "\x00" + string(encodeScore(num)) + key)
+				}
+			}
+		}
+	}
+	sort.Strings(entries)
+	return entries, true, nil
+}
+
+// readValueTxn 读取并解压值。不使用解压缓存：事务中待写入的值与已提交的值可能有相同的版本号
+func (s *BotreonStore) readValueTxn(item *badger.Item) ([]byte, error) {
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	if isTierStub(value) {
+		if value, err = s.readTierStub(item.Key(), value); err != nil {
+			return nil, err
+		}
+	}
+	return decompressData(value)
+}
+
+// searchTokens 把文本切分为小写的词：字母、数字与下划线组成的连续片段
+func searchTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// searchTagValues TAG 字段的标签：哈希字段按分隔符切分，JSON 取字符串或字符串数组的元素。
+// 标签去掉首尾空白后转为小写
+func searchTagValues(v interface{}, separator string, split bool) []string {
+	var raw []string
+	switch v := v.(type) {
+	case string:
+		if split {
+			raw = strings.Split(v, separator)
+		} else {
+			raw = []string{v}
+		}
+	case bool:
+		raw = []string{strconv.FormatBool(v)}
+	case []interface{}:
+		for _, e := range v {
+			if str, ok := e.(string); ok {
+				raw = append(raw, str)
+			}
+		}
+	}
+	tags := raw[:0]
+	for _, t := range raw {
+		t = strings.ToLower(strings.TrimSpace(strings.ReplaceAll(t, "\x00", "")))
+		if t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// searchNumber NUMERIC 字段的值：JSON 数字，或可以解析为数字的字符串
+func searchNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		num, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return num, err == nil && !math.IsNaN(num)
+	}
+	return 0, false
+}
+
+// encodeSearchEntries 文档的条目列表：每个条目为长度（uvarint）加内容
+func encodeSearchEntries(entries []string) []byte {
+	var buf []byte
+	for _, e := range entries {
+		buf = binary.AppendUvarint(buf, uint64(len(e)))
+		buf = append(buf, e...)
+	}
+	return buf
+}
+
+func decodeSearchEntries(buf []byte) []string {
+	var entries []string
+	for len(buf) > 0 {
+		n, size := binary.Uvarint(buf)
+		if size <= 0 || uint64(len(buf)-size) < n {
+			break
+		}
+		entries = append(entries, string(buf[size:size+int(n)]))
+		buf = buf[size+int(n):]
+	}
+	return entries
+}
+
+// searchScan 扫描 prefix 下的条目，key 从条目中 prefix 之后的部分取出文档的键
+func searchScan(txn *storeTxn, prefix []byte, key func(rest []byte) []byte) (map[string]struct{}, error) {
+	set := make(map[string]struct{})
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		if k := key(it.Item().Key()[len(prefix):]); k != nil {
+			set[string(k)] = struct{}{}
+		}
+	}
+	return set, nil
+}
+
+// SearchOptions FT.SEARCH 的选项
+type SearchOptions struct {
+	Offset    int
+	Limit     int // 返回的文档数上限，0 表示只返回匹配的文档数
+	SortBy    string
+	Desc      bool
+	NoContent bool
+	Return    []string // 只返回这些属性或字段，nil 表示返回整个文档
+}
+
+// SearchDoc 一个匹配的文档
+type SearchDoc struct {
+	Key    string
+	Fields []string // 名称与值交替排列
+}
+
+// SearchResult FT.SEARCH 的结果
+type SearchResult struct {
+	Total int64
+	Docs  []SearchDoc
+}
+
+// Search 在索引中执行查询（FT.SEARCH）。不计算相关度：没有 SORTBY 时按键名排序
+func (s *BotreonStore) Search(name, query string, opts SearchOptions) (SearchResult, error) {
+	idx, err := s.searchIndex(name)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	q, err := parseSearchQuery(query)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	sortField := -1
+	if opts.SortBy != "" {
+		i, ok := idx.field(opts.SortBy)
+		if !ok {
+			return SearchResult{}, fmt.Errorf("Property `%s` not loaded nor in schema", opts.SortBy)
+		}
+		sortField = i
+	}
+
+	var matched map[string]struct{}
+	err = s.view(func(txn *storeTxn) error {
+		var err error
+		matched, err = s.searchEval(txn, idx, q)
+		return err
+	})
+	if err != nil {
+		return SearchResult{}, err
+	}
+	keys := make([]string, 0, len(matched))
+	for key := range matched {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if sortField >= 0 {
+		if keys, err = s.searchSort(idx, keys, sortField, opts.Desc); err != nil {
+			return SearchResult{}, err
+		}
+	}
+
+	result := SearchResult{Total: int64(len(keys)), Docs: []SearchDoc{}}
+	start := min(max(opts.Offset, 0), len(keys))
+	end := min(start+max(opts.Limit, 0), len(keys))
+	for _, key := range keys[start:end] {
+		doc := SearchDoc{Key: key}
+		if !opts.NoContent {
+			if doc.Fields, err = s.searchDocFields(idx, key, opts.Return); err != nil {
+				return SearchResult{}, err
+			}
+		}
+		result.Docs = append(result.Docs, doc)
+	}
+	return result, nil
+}
+
+// searchFieldValues 读取文档中一个字段的值，文档或字段不存在时返回 nil
+func (s *BotreonStore) searchFieldValues(idx *searchIndex, key string, i int) ([]interface{}, error) {
+	f := idx.def.Fields[i]
+	if idx.def.On == KeyTypeHash {
+		val, err := s.HGet(key, f.Path)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{string(val)}, nil
+	}
+	root, err := s.jsonView(key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var values []interface{}
+	for _, n := range idx.paths[i].eval(&root) {
+		values = append(values, n.value)
+	}
+	return values, nil
+}
+
+// searchSort 按字段排序：NUMERIC 字段按数值，其他字段按小写文本；没有值的文档排在最后，值相同时按键名
+func (s *BotreonStore) searchSort(idx *searchIndex, keys []string, field int, desc bool) ([]string, error) {
+	type sortKey struct {
+		key     string
+		has     bool
+		num     float64
+		text    string
+		numeric bool
+	}
+	numeric := idx.def.Fields[field].Type == SearchFieldNumeric
+	items := make([]sortKey, len(keys))
+	for i, key := range keys {
+		values, err := s.searchFieldValues(idx, key, field)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = sortKey{key: key}
+		if len(values) == 0 {
+			continue
+		}
+		if numeric {
+			items[i].num, items[i].has = searchNumber(values[0])
+		} else if str, ok := values[0].(string); ok {
+			items[i].text, items[i].has = strings.ToLower(str), true
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if a.has != b.has {
+			return a.has
+		}
+		if !a.has {
+			return false
+		}
+		if numeric {
+			if a.num == b.num {
+				return false
+			}
+			return (a.num < b.num) != desc
+		}
+		if a.text == b.text {
+			return false
+		}
+		return (a.text < b.text) != desc
+	})
+	for i := range items {
+		keys[i] = items[i].key
+	}
+	return keys, nil
+}
+
+// searchDocFields 返回文档的内容：哈希为全部字段，JSON 为 "$" 与整个文档；
+// 指定了 names 时只返回这些属性（或哈希字段、JSONPath）的值
+func (s *BotreonStore) searchDocFields(idx *searchIndex, key string, names []string) ([]string, error) {
+	if names == nil {
+		if idx.def.On == KeyTypeJSON {
+			doc, err := s.JSONGet(key)
+			if errors.Is(err, ErrKeyNotFound) {
+				return []string{}, nil
+			}
+			return []string{"$", doc}, err
+		}
+		all, err := s.HGetAll(key)
+		if err != nil {
+			return nil, err
+		}
+		fields := make([]string, 0, 2*len(all))
+		for _, f := range sortedHashFields(all) {
+			fields = append(fields, f, string(all[f]))
+		}
+		return fields, nil
+	}
+
+	fields := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		var values []interface{}
+		var err error
+		if i, ok := idx.field(name); ok {
+			values, err = s.searchFieldValues(idx, key, i)
+		} else if idx.def.On == KeyTypeHash {
+			var val []byte
+			if val, err = s.HGet(key, name); err == nil {
+				values = []interface{}{string(val)}
+			} else if errors.Is(err, badger.ErrKeyNotFound) {
+				err = nil
+			}
+		} else if p, perr := parseJSONPath(name); perr == nil {
+			var root interface{}
+			if root, err = s.jsonView(key); err == nil {
+				for _, n := range p.eval(&root) {
+					values = append(values, n.value)
+				}
+			} else if errors.Is(err, ErrKeyNotFound) {
+				err = nil
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			continue
+		}
+		value, ok := values[0].(string)
+		if !ok {
+			data, err := json.Marshal(values[0])
+			if err != nil {
+				return nil, err
+			}
+			value = string(data)
+		}
+		fields = append(fields, name, value)
+	}
+	return fields, nil
+}
+
+func sortedHashFields(m map[string][]byte) []string {
+	fields := make([]string, 0, len(m))
+	for f := range m {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return fields
+}
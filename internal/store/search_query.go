@@ -0,0 +1,450 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// searchQueryKind 查询节点的类型
+type searchQueryKind int
+
+const (
+	searchQueryAll     searchQueryKind = iota // *
+	searchQueryTerm                           // word、@field:word
+	searchQueryPrefix                         // wor*
+	searchQueryTag                            // @field:{a | b}
+	searchQueryNumeric                        // @field:[min max]
+	searchQueryAnd                            // a b
+	searchQueryOr                             // a | b
+	searchQueryNot                            // -a
+)
+
+// searchQuery FT.SEARCH 查询语言的一个子集：词（与、或、非、括号分组、前缀匹配），
+// 限定字段的词，TAG 字段的 {a | b}，NUMERIC 字段的 [min max]（"(" 表示不含边界，支持 -inf/+inf）
+type searchQuery struct {
+	kind         searchQueryKind
+	field        string // 为空时在所有 TEXT 字段中查找词
+	term         string
+	tags         []string
+	min, max     float64
+	minExclusive bool
+	maxExclusive bool
+	children     []*searchQuery
+}
+
+type searchQueryParser struct {
+	s   []rune
+	pos int
+}
+
+// parseSearchQuery 解析查询字符串
+func parseSearchQuery(query string) (*searchQuery, error) {
+	p := &searchQueryParser{s: []rune(query)}
+	p.skipSpace()
+	if p.pos == len(p.s) {
+		return nil, p.errorf("empty query")
+	}
+	q, err := p.parseUnion("")
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return nil, p.errorf("unexpected '%c'", p.s[p.pos])
+	}
+	return q, nil
+}
+
+func (p *searchQueryParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("Syntax error at offset %d near %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *searchQueryParser) skipSpace() {
+	for p.pos < len(p.s) && unicode.IsSpace(p.s[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *searchQueryParser) peek() rune {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *searchQueryParser) consume(r rune) bool {
+	p.skipSpace()
+	if p.peek() == r {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// readWord 读取由字母、数字与下划线组成的词
+func (p *searchQueryParser) readWord() string {
+	start := p.pos
+	for p.pos < len(p.s) && isSearchWordRune(p.s[p.pos]) {
+		p.pos++
+	}
+	return string(p.s[start:p.pos])
+}
+
+func isSearchWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// parseUnion 以 | 分隔的若干个交集
+func (p *searchQueryParser) parseUnion(field string) (*searchQuery, error) {
+	var alts []*searchQuery
+	for {
+		q, err := p.parseIntersect(field)
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, q)
+		if !p.consume('|') {
+			break
+		}
+	}
+	if len(alts) == 1 {
+		return alts[0], nil
+	}
+	return &searchQuery{kind: searchQueryOr, children: alts}, nil
+}
+
+// parseIntersect 以空白分隔的若干个条件，全部满足
+func (p *searchQueryParser) parseIntersect(field string) (*searchQuery, error) {
+	var parts []*searchQuery
+	for {
+		p.skipSpace()
+		if c := p.peek(); c == 0 || c == '|' || c == ')' {
+			break
+		}
+		q, err := p.parseUnary(field)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, q)
+	}
+	switch len(parts) {
+	case 0:
+		return nil, p.errorf("missing term")
+	case 1:
+		return parts[0], nil
+	}
+	return &searchQuery{kind: searchQueryAnd, children: parts}, nil
+}
+
+func (p *searchQueryParser) parseUnary(field string) (*searchQuery, error) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == '-':
+		p.pos++
+		q, err := p.parseUnary(field)
+		if err != nil {
+			return nil, err
+		}
+		return &searchQuery{kind: searchQueryNot, children: []*searchQuery{q}}, nil
+	case c == '(':
+		p.pos++
+		q, err := p.parseUnion(field)
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(')') {
+			return nil, p.errorf("missing ')'")
+		}
+		return q, nil
+	case c == '@':
+		if field != "" {
+			return nil, p.errorf("nested field modifier")
+		}
+		p.pos++
+		name := p.readWord()
+		if name == "" || !p.consume(':') {
+			return nil, p.errorf("invalid field modifier")
+		}
+		p.skipSpace()
+		switch p.peek() {
+		case '{':
+			p.pos++
+			return p.parseTags(name)
+		case '[':
+			p.pos++
+			return p.parseRange(name)
+		}
+		return p.parseUnary(name)
+	case c == '"':
+		// 短语按其中所有词都出现处理，不检查词的位置
+		p.pos++
+		start := p.pos
+		for p.pos < len(p.s) && p.s[p.pos] != '"' {
+			p.pos++
+		}
+		if p.pos == len(p.s) {
+			return nil, p.errorf("unterminated phrase")
+		}
+		terms := searchTokens(string(p.s[start:p.pos]))
+		p.pos++
+		if len(terms) == 0 {
+			return nil, p.errorf("empty phrase")
+		}
+		q := &searchQuery{kind: searchQueryAnd}
+		for _, t := range terms {
+			q.children = append(q.children, &searchQuery{kind: searchQueryTerm, field: field, term: t})
+		}
+		if len(q.children) == 1 {
+			return q.children[0], nil
+		}
+		return q, nil
+	case c == '*' && field == "":
+		p.pos++
+		return &searchQuery{kind: searchQueryAll}, nil
+	}
+	word := p.readWord()
+	if word == "" {
+		return nil, p.errorf("'%c'", p.peek())
+	}
+	q := &searchQuery{kind: searchQueryTerm, field: field, term: strings.ToLower(word)}
+	if p.peek() == '*' {
+		p.pos++
+		q.kind = searchQueryPrefix
+	}
+	return q, nil
+}
+
+// parseTags 解析 { 之后的 tag | tag ... }，反斜杠转义下一个字符
+func (p *searchQueryParser) parseTags(field string) (*searchQuery, error) {
+	q := &searchQuery{kind: searchQueryTag, field: field}
+	var b strings.Builder
+	flush := func() {
+		if tag := strings.ToLower(strings.TrimSpace(b.String())); tag != "" {
+			q.tags = append(q.tags, tag)
+		}
+		b.Reset()
+	}
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch c {
+		case '\\':
+			if p.pos < len(p.s) {
+				b.WriteRune(p.s[p.pos])
+				p.pos++
+			}
+		case '|':
+			flush()
+		case '}':
+			flush()
+			if len(q.tags) == 0 {
+				return nil, p.errorf("empty tag list")
+			}
+			return q, nil
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return nil, p.errorf("missing '}'")
+}
+
+// parseRange 解析 [ 之后的 min max]
+func (p *searchQueryParser) parseRange(field string) (*searchQuery, error) {
+	start := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] != ']' {
+		p.pos++
+	}
+	if p.pos == len(p.s) {
+		return nil, p.errorf("missing ']'")
+	}
+	bounds := strings.Fields(strings.ReplaceAll(string(p.s[start:p.pos]), ",", " "))
+	p.pos++
+	if len(bounds) != 2 {
+		return nil, p.errorf("invalid numeric range")
+	}
+	q := &searchQuery{kind: searchQueryNumeric, field: field}
+	var err error
+	if q.min, q.minExclusive, err = parseSearchBound(bounds[0]); err != nil {
+		return nil, p.errorf("invalid numeric range")
+	}
+	if q.max, q.maxExclusive, err = parseSearchBound(bounds[1]); err != nil {
+		return nil, p.errorf("invalid numeric range")
+	}
+	return q, nil
+}
+
+func parseSearchBound(s string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(s, "(")
+	s = strings.TrimPrefix(s, "(")
+	switch strings.ToLower(s) {
+	case "-inf":
+		return math.Inf(-1), exclusive, nil
+	case "inf", "+inf":
+		return math.Inf(1), exclusive, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err == nil && math.IsNaN(v) {
+		err = strconv.ErrSyntax
+	}
+	return v, exclusive, err
+}
+
+// searchEval 计算匹配查询的文档集合
+func (s *BotreonStore) searchEval(txn *storeTxn, idx *searchIndex, q *searchQuery) (map[string]struct{}, error) {
+	if q.field != "" {
+		i, ok := idx.field(q.field)
+		if !ok {
+			return nil, fmt.Errorf("Unknown field `%s`", q.field)
+		}
+		want := SearchFieldText
+		switch q.kind {
+		case searchQueryTag:
+			want = SearchFieldTag
+		case searchQueryNumeric:
+			want = SearchFieldNumeric
+		}
+		if t := idx.def.Fields[i].Type; t != want {
+			return nil, fmt.Errorf("field `%s` is a %s field, not %s", q.field, t, want)
+		}
+	}
+
+	switch q.kind {
+	case searchQueryAll:
+		return searchScan(txn, idx.docPrefix(), func(rest []byte) []byte { return rest })
+	case searchQueryTerm, searchQueryPrefix:
+		fields := []string{q.field}
+		if q.field == "" {
+			fields = fields[:0]
+			for _, f := range idx.def.Fields {
+				if f.Type == SearchFieldText {
+					fields = append(fields, f.Name)
+				}
+			}
+		}
+		result := make(map[string]struct{})
+		for _, f := range fields {
+			prefix := idx.termPrefix(f, q.term)
+			if q.kind == searchQueryTerm {
+				prefix = append(prefix, 0)
+			}
+			set, err := searchScan(txn, prefix, func(rest []byte) []byte {
+				if q.kind == searchQueryTerm {
+					return rest
+				}
+				// 前缀之后是词的剩余部分、\x00 与键
+				if i := bytes.IndexByte(rest, 0); i >= 0 {
+					return rest[i+1:]
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			searchUnion(result, set)
+		}
+		return result, nil
+	case searchQueryTag:
+		result := make(map[string]struct{})
+		for _, tag := range q.tags {
+			set, err := searchScan(txn, idx.tagPrefix(q.field, tag), func(rest []byte) []byte { return rest })
+			if err != nil {
+				return nil, err
+			}
+			searchUnion(result, set)
+		}
+		return result, nil
+	case searchQueryNumeric:
+		return searchNumericRange(txn, idx, q), nil
+	case searchQueryOr:
+		result := make(map[string]struct{})
+		for _, c := range q.children {
+			set, err := s.searchEval(txn, idx, c)
+			if err != nil {
+				return nil, err
+			}
+			searchUnion(result, set)
+		}
+		return result, nil
+	case searchQueryAnd:
+		// 先求肯定条件的交集，再去掉否定条件匹配的文档
+		var result map[string]struct{}
+		var excluded []map[string]struct{}
+		for _, c := range q.children {
+			child := c
+			negated := c.kind == searchQueryNot
+			if negated {
+				child = c.children[0]
+			}
+			set, err := s.searchEval(txn, idx, child)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case negated:
+				excluded = append(excluded, set)
+			case result == nil:
+				result = set
+			default:
+				for key := range result {
+					if _, ok := set[key]; !ok {
+						delete(result, key)
+					}
+				}
+			}
+		}
+		if result == nil {
+			all, err := s.searchEval(txn, idx, &searchQuery{kind: searchQueryAll})
+			if err != nil {
+				return nil, err
+			}
+			result = all
+		}
+		for _, set := range excluded {
+			for key := range set {
+				delete(result, key)
+			}
+		}
+		return result, nil
+	case searchQueryNot:
+		return s.searchEval(txn, idx, &searchQuery{kind: searchQueryAnd, children: []*searchQuery{q}})
+	}
+	return nil, fmt.Errorf("unsupported query")
+}
+
+// searchNumericRange 按数值顺序扫描字段的条目，直到超过上界
+func searchNumericRange(txn *storeTxn, idx *searchIndex, q *searchQuery) map[string]struct{} {
+	result := make(map[string]struct{})
+	prefix := idx.numericPrefix(q.field)
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	for it.Seek(append(prefix, encodeScore(q.min)...)); it.Valid(); it.Next() {
+		rest := it.Item().Key()[len(prefix):]
+		if len(rest) < 8 {
+			continue
+		}
+		v := decodeScore(rest[:8])
+		if v > q.max || (q.maxExclusive && v == q.max) {
+			break
+		}
+		if q.minExclusive && v == q.min {
+			continue
+		}
+		result[string(rest[8:])] = struct{}{}
+	}
+	return result
+}
+
+func searchUnion(dst, src map[string]struct{}) {
+	for key := range src {
+		dst[key] = struct{}{}
+	}
+}
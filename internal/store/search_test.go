@@ -0,0 +1,218 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+// waitSearchReady 等待后台索引完成
+func waitSearchReady(t *testing.T, store *BotreonStore, name string) SearchIndexInfo {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := store.SearchIndexInfo(name)
+		assert.NoError(t, err)
+		if !info.Indexing {
+			return info
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("index %s still indexing", name)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// searchKeys 返回查询匹配的键，按返回顺序
+func searchKeys(t *testing.T, store *BotreonStore, index, query string, opts SearchOptions) []string {
+	t.Helper()
+	if opts.Limit == 0 {
+		opts.Limit = 100
+	}
+	opts.NoContent = true
+	result, err := store.Search(index, query, opts)
+	assert.NoError(t, err)
+	keys := []string{}
+	for _, doc := range result.Docs {
+		keys = append(keys, doc.Key)
+	}
+	return keys
+}
+
+func TestSearchHash(t *testing.T) {
+	store, err := NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	// 建立索引之前已有的键由后台索引
+	for i := 0; i < 250; i++ {
+		assert.NoError(t, store.HMSet(fmt.Sprintf("bulk:%03d", i), map[string]interface{}{
+			"title": fmt.Sprintf("bulk item %d", i), "price": i,
+		}))
+	}
+	assert.NoError(t, store.HMSet("item:1", map[string]interface{}{
+		"title": "Red running shoes", "price": "59.5", "tags": "Sport, outdoor",
+	}))
+	assert.NoError(t, store.HMSet("other:1", map[string]interface{}{"title": "red herring"}))
+
+	err = store.CreateSearchIndex(SearchIndex{Name: "items", On: KeyTypeHash, Prefixes: []string{"item:", "bulk:"}, Fields: []SearchField{
+		{Path: "title", Type: SearchFieldText},
+		{Path: "price", Type: SearchFieldNumeric, Sortable: true},
+		{Path: "tags", Type: SearchFieldTag},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, ErrSearchIndexExists, store.CreateSearchIndex(SearchIndex{Name: "items", On: KeyTypeHash, Fields: []SearchField{{Path: "a", Type: SearchFieldText}}}))
+	info := waitSearchReady(t, store, "items")
+	assert.Equal(t, int64(251), info.NumDocs)
+
+	// 建立索引之后写入的键在写入的事务中索引
+	assert.NoError(t, store.HMSet("item:2", map[string]interface{}{
+		"title": "Blue running jacket", "price": 120, "tags": "outdoor,winter",
+	}))
+	assert.NoError(t, store.HMSet("item:3", map[string]interface{}{"title": "Red scarf", "price": 15, "tags": "winter"}))
+
+	assert.Equal(t, []string{"item:1", "item:3"}, searchKeys(t, store, "items", "red", SearchOptions{}))
+	assert.Equal(t, []string{"item:1"}, searchKeys(t, store, "items", "red running", SearchOptions{}))
+	assert.Equal(t, []string{"item:1", "item:2", "item:3"}, searchKeys(t, store, "items", "red | @title:jacket", SearchOptions{}))
+	assert.Equal(t, []string{"item:2"}, searchKeys(t, store, "items", "runn* -red", SearchOptions{}))
+	assert.Equal(t, []string{"item:2", "item:3"}, searchKeys(t, store, "items", "@tags:{winter}", SearchOptions{}))
+	assert.Equal(t, []string{"item:1", "item:2"}, searchKeys(t, store, "items", "@tags:{ SPORT | outdoor }", SearchOptions{}))
+	assert.Equal(t, []string{"item:1", "item:3"}, searchKeys(t, store, "items", "@price:[15 (120] @title:(red | scarf)", SearchOptions{}))
+	assert.Equal(t, []string{"bulk:248", "bulk:249"}, searchKeys(t, store, "items", "@price:[(247 +inf]", SearchOptions{}))
+	assert.Equal(t, []string{"bulk:000", "bulk:001"}, searchKeys(t, store, "items", "@price:[-inf 1]", SearchOptions{}))
+
+	// 排序与分页
+	assert.Equal(t, []string{"item:2", "item:1", "item:3"}, searchKeys(t, store, "items", "@tags:{winter|sport}", SearchOptions{SortBy: "price", Desc: true}))
+	assert.Equal(t, []string{"bulk:002", "bulk:003"}, searchKeys(t, store, "items", "bulk", SearchOptions{SortBy: "price", Offset: 2, Limit: 2}))
+	result, err := store.Search("items", "*", SearchOptions{Limit: 0})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(253), result.Total)
+	assert.Equal(t, 0, len(result.Docs))
+
+	// 文档内容与 RETURN
+	result, err = store.Search("items", "scarf", SearchOptions{Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []SearchDoc{{Key: "item:3", Fields: []string{"price", "15", "tags", "winter", "title", "Red scarf"}}}, result.Docs)
+	result, err = store.Search("items", "scarf", SearchOptions{Limit: 10, Return: []string{"title", "missing"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"title", "Red scarf"}, result.Docs[0].Fields)
+
+	// 修改与删除文档后索引随之更新
+	assert.NoError(t, store.HSet("item:1", "title", "Green running shoes"))
+	assert.Equal(t, []string{"item:3"}, searchKeys(t, store, "items", "red", SearchOptions{}))
+	_, err = store.HDel("item:1", "tags")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"item:2"}, searchKeys(t, store, "items", "@tags:{outdoor}", SearchOptions{}))
+	_, err = store.Del("item:3")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"item:2"}, searchKeys(t, store, "items", "@tags:{winter}", SearchOptions{}))
+	assert.NoError(t, store.Rename("item:2", "gone:2"))
+	assert.Equal(t, []string{}, searchKeys(t, store, "items", "jacket", SearchOptions{}))
+	info, err = store.SearchIndexInfo("items")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(251), info.NumDocs)
+
+	// 查询错误
+	_, err = store.Search("items", "@nope:x", SearchOptions{})
+	assert.Equal(t, "Unknown field `nope`", err.Error())
+	_, err = store.Search("items", "@price:{x}", SearchOptions{})
+	assert.Error(t, err)
+	_, err = store.Search("items", "(red", SearchOptions{})
+	assert.Error(t, err)
+	_, err = store.Search("missing", "*", SearchOptions{})
+	assert.Equal(t, ErrSearchUnknownIndex, err)
+}
+
+func TestSearchJSON(t *testing.T) {
+	store, err := NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	err = store.CreateSearchIndex(SearchIndex{Name: "users", On: KeyTypeJSON, Prefixes: []string{"user:"}, Fields: []SearchField{
+		{Path: "$.name", Name: "name", Type: SearchFieldText},
+		{Path: "$.age", Name: "age", Type: SearchFieldNumeric},
+		{Path: "$.roles[*]", Name: "roles", Type: SearchFieldTag},
+	}})
+	assert.NoError(t, err)
+	waitSearchReady(t, store, "users")
+
+	_, err = store.JSONSet("user:1", "$", `{"name":"Ann Smith","age":31,"roles":["admin","dev"]}`, false, false)
+	assert.NoError(t, err)
+	_, err = store.JSONSet("user:2", "$", `{"name":"Bob Smith","age":25,"roles":["dev"]}`, false, false)
+	assert.NoError(t, err)
+	_, err = store.JSONSet("user:3", "$", `{"name":"Cid","age":"n/a"}`, false, false)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"user:1", "user:2"}, searchKeys(t, store, "users", "@name:smith", SearchOptions{}))
+	assert.Equal(t, []string{"user:1"}, searchKeys(t, store, "users", "@roles:{admin} @age:[30 40]", SearchOptions{}))
+	assert.Equal(t, []string{"user:3"}, searchKeys(t, store, "users", "-@roles:{dev}", SearchOptions{}))
+	assert.Equal(t, []string{"user:2", "user:1", "user:3"}, searchKeys(t, store, "users", "*", SearchOptions{SortBy: "age"}))
+
+	// 路径修改同样更新索引
+	_, err = store.JSONArrAppend("user:2", "$.roles", `"admin"`)
+	assert.NoError(t, err)
+	_, err = store.JSONNumIncrBy("user:1", "$.age", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"user:2"}, searchKeys(t, store, "users", "@roles:{admin} @age:[30 40]|@age:[0 30]", SearchOptions{}))
+
+	result, err := store.Search("users", "bob", SearchOptions{Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"$", `{"age":25,"name":"Bob Smith","roles":["dev","admin"]}`}, result.Docs[0].Fields)
+	result, err = store.Search("users", "bob", SearchOptions{Limit: 10, Return: []string{"name", "age", "$.roles"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"name", "Bob Smith", "age", "25", "$.roles", `["dev","admin"]`}, result.Docs[0].Fields)
+}
+
+func TestSearchIndexLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBotreonStore(dir)
+	assert.NoError(t, err)
+
+	def := SearchIndex{Name: "docs", On: KeyTypeHash, Prefixes: []string{"doc:"}, Fields: []SearchField{{Path: "body", Type: SearchFieldText}}}
+	assert.NoError(t, store.CreateSearchIndex(def))
+	assert.NoError(t, store.HSet("doc:1", "body", "hello world"))
+	assert.NoError(t, store.HSet("doc:2", "body", "hello there"))
+	waitSearchReady(t, store, "docs")
+	assert.NoError(t, store.Close())
+
+	// 索引定义与条目在重启后仍然有效
+	store, err = NewBotreonStore(dir)
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.Equal(t, []string{"docs"}, store.SearchIndexNames())
+	assert.Equal(t, []string{"doc:1", "doc:2"}, searchKeys(t, store, "docs", "hello", SearchOptions{}))
+
+	// 删除索引，DD 时同时删除文档
+	assert.NoError(t, store.DropSearchIndex("docs", true))
+	assert.Equal(t, ErrSearchUnknownIndex, store.DropSearchIndex("docs", false))
+	exists, err := store.Exists("doc:1")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	// 同名索引重新建立后不包含旧的条目
+	assert.NoError(t, store.HSet("doc:3", "body", "hello again"))
+	assert.NoError(t, store.CreateSearchIndex(def))
+	waitSearchReady(t, store, "docs")
+	assert.Equal(t, []string{"doc:3"}, searchKeys(t, store, "docs", "hello", SearchOptions{}))
+
+	// FLUSHDB 删除索引定义
+	assert.NoError(t, store.FlushDB())
+	assert.Equal(t, []string{}, store.SearchIndexNames())
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	for _, query := range []string{"", "a |", "(a b", "@f", "@f:{}", "@f:[1]", "@f:[a b]", `"a b`, "@a:@b:c", "a)"} {
+		_, err := parseSearchQuery(query)
+		assert.Error(t, err)
+	}
+	q, err := parseSearchQuery(`hello @t:{a\ b | C} -@n:[(1 +inf] "Big Deal" wor*`)
+	assert.NoError(t, err)
+	assert.Equal(t, searchQueryAnd, q.kind)
+	assert.Equal(t, 5, len(q.children))
+	assert.Equal(t, []string{"a b", "c"}, q.children[1].tags)
+	assert.True(t, q.children[2].children[0].minExclusive)
+	assert.Equal(t, 2, len(q.children[3].children))
+	assert.Equal(t, searchQueryPrefix, q.children[4].kind)
+}
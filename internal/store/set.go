@@ -4,6 +4,8 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"errors"
+	"math"
+	"sort"
 	"strings"
 	"time"
 
@@ -20,17 +22,18 @@ func randomFloat64() float64 {
 }
 
 // retryUpdate 重试执行 BadgerDB Update 操作，处理事务冲突
-func (s *BotreonStore) retryUpdate(fn func(*badger.Txn) error, maxRetries int) error {
+func (s *BotreonStore) retryUpdate(fn func(*storeTxn) error, maxRetries int) error {
 	var err error
 	for i := 0; i < maxRetries; i++ {
-		err = s.db.Update(fn)
+		err = s.update(fn)
 		if err == nil {
 			return nil
 		}
 		// 检查是否是事务冲突错误
 		// BadgerDB 在事务冲突时会返回包含 "Transaction Conflict" 的错误
+		// DropAll/DropPrefix 期间 Badger 拒绝写入，同样等待后重试
 		errStr := err.Error()
-		if strings.Contains(errStr, "Transaction Conflict") ||
+		if errors.Is(err, badger.ErrBlockedWrites) || strings.Contains(errStr, "Transaction Conflict") ||
 			strings.Contains(errStr, "conflict") ||
 			strings.Contains(errStr, "Conflict") {
 			// 指数退避 + 随机抖动：避免所有请求同时重试
@@ -63,51 +66,58 @@ func (s *BotreonStore) setKey(key string, parts ...string) string {
 // SAdd 实现 Redis SADD 命令
 func (s *BotreonStore) SAdd(key string, members ...string) (int, error) {
 	added := 0
-	err := s.retryUpdate(func(txn *badger.Txn) error {
-		if err := txn.Set(TypeOfKeyGet(key), []byte(KeyTypeSet)); err != nil {
-			return err
-		}
-		countKey := s.setKey(key, "count")
-		var count uint64
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		var err error
+		added, err = s.saddTxn(txn, key, members)
+		return err
+	}, 30) // 最多重试 30 次（高并发时需要更多重试）
+	return added, err
+}
 
-		// 获取当前计数器值
-		item, err := txn.Get([]byte(countKey))
-		if err != badger.ErrKeyNotFound {
-			if err != nil {
-				return err
-			}
-			countBytes, _ := item.ValueCopy(nil)
-			count = helper.BytesToUint64(countBytes)
+// saddTxn 在 txn 中添加集合成员，返回新增的个数
+func (s *BotreonStore) saddTxn(txn *storeTxn, key string, members []string) (int, error) {
+	if err := txn.Set(TypeOfKeyGet(key), []byte(KeyTypeSet)); err != nil {
+		return 0, err
+	}
+	countKey := s.setKey(key, "count")
+	var count uint64
+
+	// 获取当前计数器值
+	item, err := txn.Get([]byte(countKey))
+	if err != badger.ErrKeyNotFound {
+		if err != nil {
+			return 0, err
 		}
+		countBytes, _ := item.ValueCopy(nil)
+		count = helper.BytesToUint64(countBytes)
+	}
 
-		added = 0
-		for _, member := range members {
-			memberKey := s.setKey(key, "member", member)
+	added := 0
+	for _, member := range members {
+		memberKey := s.setKey(key, "member", member)
 
-			// 检查成员是否存在
-			if _, err := txn.Get([]byte(memberKey)); err == badger.ErrKeyNotFound {
-				// 新成员：写入成员键并增加计数器
-				if err := txn.Set([]byte(memberKey), []byte{}); err != nil {
-					return err
-				}
-				count++
-				added++
+		// 检查成员是否存在
+		if _, err := txn.Get([]byte(memberKey)); err == badger.ErrKeyNotFound {
+			// 新成员：写入成员键并增加计数器
+			if err := txn.Set([]byte(memberKey), []byte{}); err != nil {
+				return 0, err
 			}
+			count++
+			added++
 		}
+	}
 
-		// 更新计数器
-		if added > 0 {
-			return txn.Set([]byte(countKey), helper.Uint64ToBytes(count))
-		}
-		return nil
-	}, 30) // 最多重试 30 次（高并发时需要更多重试）
-	return added, err
+	// 更新计数器
+	if added > 0 {
+		return added, txn.Set([]byte(countKey), helper.Uint64ToBytes(count))
+	}
+	return 0, nil
 }
 
 // SRem 实现 Redis SREM 命令
 func (s *BotreonStore) SRem(key string, members ...string) (int, error) {
 	removed := 0
-	err := s.retryUpdate(func(txn *badger.Txn) error {
+	err := s.retryUpdate(func(txn *storeTxn) error {
 		countKey := s.setKey(key, "count")
 		var count uint64
 
@@ -147,7 +157,7 @@ func (s *BotreonStore) SRem(key string, members ...string) (int, error) {
 // SCard 实现 Redis SCARD 命令
 func (s *BotreonStore) SCard(key string) (uint64, error) {
 	var count uint64
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		countKey := s.setKey(key, "count")
 		item, err := txn.Get([]byte(countKey))
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -166,7 +176,7 @@ func (s *BotreonStore) SCard(key string) (uint64, error) {
 // SIsMember 实现 Redis SISMEMBER 命令
 func (s *BotreonStore) SIsMember(key string, member string) (bool, error) {
 	exists := false
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		memberKey := s.setKey(key, "member", member)
 		_, err := txn.Get([]byte(memberKey))
 		if err == nil {
@@ -182,11 +192,12 @@ func (s *BotreonStore) SIsMember(key string, member string) (bool, error) {
 }
 
 // getAllMembers 获取集合中的所有成员
-func (s *BotreonStore) getAllMembers(txn *badger.Txn, key string) ([]string, error) {
+func (s *BotreonStore) getAllMembers(txn *storeTxn, key string) ([]string, error) {
 	var members []string
 	prefix := s.setKey(key, "member")
 	prefixBytes := []byte(prefix + ":")
-	iter := txn.NewIterator(badger.DefaultIteratorOptions)
+	// 成员名在键中，只遍历键
+	iter := txn.NewIterator(s.iteratorOptions(prefixBytes, -1, false))
 	defer iter.Close()
 
 	for iter.Seek(prefixBytes); iter.ValidForPrefix(prefixBytes); iter.Next() {
@@ -205,7 +216,7 @@ func (s *BotreonStore) getAllMembers(txn *badger.Txn, key string) ([]string, err
 // SMembers 实现 Redis SMEMBERS 命令
 func (s *BotreonStore) SMembers(key string) ([]string, error) {
 	var members []string
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		var err error
 		members, err = s.getAllMembers(txn, key)
 		return err
@@ -213,116 +224,127 @@ func (s *BotreonStore) SMembers(key string) ([]string, error) {
 	return members, err
 }
 
-// SPop 实现 Redis SPOP 命令，随机弹出并删除一个成员
-// 优化：使用迭代器随机选择，避免加载所有成员
-func (s *BotreonStore) SPop(key string) (string, error) {
-	var member string
-	err := s.retryUpdate(func(txn *badger.Txn) error {
-		// 先获取集合大小
-		countKey := s.setKey(key, "count")
-		item, err := txn.Get([]byte(countKey))
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return nil // 集合为空
-		}
-		if err != nil {
-			return err
-		}
-		countBytes, _ := item.ValueCopy(nil)
-		count := helper.BytesToUint64(countBytes)
-		if count == 0 {
-			return nil // 集合为空
-		}
+// 随机选取成员时只遍历成员键、不读取值，内存占用与选取的个数成正比，与集合大小无关：
+// 选取单个成员或允许重复时按计数键生成随机位置，一次遍历取出这些位置上的成员；
+// 不重复地选取多个成员时用蓄水池抽样（Algorithm L），随机数的个数约为 k·log(n/k)。
 
-		// 随机选择一个索引（0 到 count-1）
-	// #nosec G115 - count is bounded by practical set size limits
-		targetIndex := randomIntn(int(count))
+// setMemberPrefix 集合成员键的前缀
+func (s *BotreonStore) setMemberPrefix(key string) []byte {
+	return []byte(s.setKey(key, "member") + ":")
+}
 
-		// 使用迭代器遍历到目标索引位置
-		prefix := s.setKey(key, "member")
-		prefixBytes := []byte(prefix + ":")
-		iter := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer iter.Close()
+// setCountTxn 读取集合的成员计数
+func (s *BotreonStore) setCountTxn(txn *storeTxn, key string) (uint64, error) {
+	item, err := txn.Get([]byte(s.setKey(key, "count")))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	countBytes, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, err
+	}
+	return helper.BytesToUint64(countBytes), nil
+}
 
-		currentIndex := 0
-		for iter.Seek(prefixBytes); iter.ValidForPrefix(prefixBytes); iter.Next() {
-			if currentIndex == targetIndex {
-				// 找到目标成员
-				k := iter.Item().Key()
-				kStr := string(k)
-				// 提取成员名：SET:key:member:memberName
-				if strings.HasPrefix(kStr, prefix+":") {
-					member = kStr[len(prefix)+1:] // 跳过 "SET:key:member:" 前缀
-
-					// 删除成员
-					if err := txn.Delete(k); err != nil {
-						return err
-					}
+// setMembersAt 返回升序位置 positions（可以重复）上的成员，遍历到最后一个位置即停止。
+// 计数键大于实际成员数时超出末尾的位置被忽略
+func (s *BotreonStore) setMembersAt(txn *storeTxn, key string, positions []int) []string {
+	if len(positions) == 0 {
+		return nil
+	}
+	prefix := s.setMemberPrefix(key)
+	iter := txn.NewIterator(s.iteratorOptions(prefix, -1, false))
+	defer iter.Close()
 
-					// 更新计数器
-					count--
-					return txn.Set([]byte(countKey), helper.Uint64ToBytes(count))
-				}
-			}
-			currentIndex++
+	members := make([]string, 0, len(positions))
+	index := 0
+	for iter.Seek(prefix); iter.ValidForPrefix(prefix) && len(members) < len(positions); iter.Next() {
+		for len(members) < len(positions) && positions[len(members)] == index {
+			members = append(members, string(iter.Item().Key()[len(prefix):]))
 		}
-
-		// 如果迭代器没有找到（理论上不应该发生），回退到旧方法
-		// 但这种情况应该很少见
-		return nil
-	}, 30) // 最多重试 30 次（高并发时需要更多重试）
-	return member, err
+		index++
+	}
+	return members
 }
 
-// SPopN 实现 Redis SPOP 命令（带count参数），随机弹出并删除多个成员
-func (s *BotreonStore) SPopN(key string, count int) ([]string, error) {
-	var members []string
-	err := s.retryUpdate(func(txn *badger.Txn) error {
-		allMembers, err := s.getAllMembers(txn, key)
-		if err != nil {
-			return err
-		}
-		if len(allMembers) == 0 {
-			return nil
-		}
+// sampleSetMembers 用蓄水池抽样不重复地随机选取 k 个成员（成员少于 k 个时全部返回），结果顺序随机
+func (s *BotreonStore) sampleSetMembers(txn *storeTxn, key string, k int) []string {
+	prefix := s.setMemberPrefix(key)
+	iter := txn.NewIterator(s.iteratorOptions(prefix, -1, false))
+	defer iter.Close()
 
-		// 限制count不超过集合大小
-		if count > len(allMembers) {
-			count = len(allMembers)
+	sample := make([]string, 0, k)
+	iter.Seek(prefix)
+	for ; iter.ValidForPrefix(prefix) && len(sample) < k; iter.Next() {
+		sample = append(sample, string(iter.Item().Key()[len(prefix):]))
+	}
+	// Algorithm L：每次按几何分布跳过若干成员，再替换蓄水池中的随机一个
+	// 1-randomFloat64() 在 (0, 1] 内，避免 log(0)
+	w := math.Exp(math.Log(1-randomFloat64()) / float64(k))
+	for iter.ValidForPrefix(prefix) {
+		skip := int(math.Floor(math.Log(1-randomFloat64()) / math.Log(1-w)))
+		for ; skip > 0 && iter.ValidForPrefix(prefix); skip-- {
+			iter.Next()
 		}
-		if count < 0 {
-			count = 0
+		if !iter.ValidForPrefix(prefix) {
+			break
 		}
+		sample[randomIntn(k)] = string(iter.Item().Key()[len(prefix):])
+		w *= math.Exp(math.Log(1-randomFloat64()) / float64(k))
+		iter.Next()
+	}
+	randomShuffle(len(sample), func(i, j int) {
+		sample[i], sample[j] = sample[j], sample[i]
+	})
+	return sample
+}
 
-		// 随机选择成员
-		randomShuffle(len(allMembers), func(i, j int) {
-			allMembers[i], allMembers[j] = allMembers[j], allMembers[i]
-		})
+// removeSetMembersTxn 删除成员并更新计数，members 必须都在集合中
+func (s *BotreonStore) removeSetMembersTxn(txn *storeTxn, key string, count uint64, members []string) error {
+	for _, member := range members {
+		if err := txn.Delete([]byte(s.setKey(key, "member", member))); err != nil {
+			return err
+		}
+	}
+	count -= min(count, uint64(len(members)))
+	return txn.Set([]byte(s.setKey(key, "count")), helper.Uint64ToBytes(count))
+}
 
-		// 删除选中的成员
-		for i := 0; i < count; i++ {
-			member := allMembers[i]
-			memberKey := s.setKey(key, "member", member)
-			if err := txn.Delete([]byte(memberKey)); err != nil {
-				return err
-			}
-			members = append(members, member)
+// SPop 实现 Redis SPOP 命令，随机弹出并删除一个成员
+func (s *BotreonStore) SPop(key string) (string, error) {
+	var member string
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		member = ""
+		count, err := s.setCountTxn(txn, key)
+		if err != nil || count == 0 {
+			return err
 		}
+		// #nosec G115 - count is bounded by practical set size limits
+		members := s.setMembersAt(txn, key, []int{randomIntn(int(count))})
+		if len(members) == 0 {
+			return nil
+		}
+		member = members[0]
+		return s.removeSetMembersTxn(txn, key, count, members)
+	}, 30) // 最多重试 30 次（高并发时需要更多重试）
+	return member, err
+}
 
-		// 更新计数器
-		if count > 0 {
-			countKey := s.setKey(key, "count")
-			item, err := txn.Get([]byte(countKey))
-			if err == nil {
-				countBytes, _ := item.ValueCopy(nil)
-				currentCount := helper.BytesToUint64(countBytes)
-				// #nosec G115 - count is bounded by practical set size limits
-			if currentCount >= uint64(count) {
-					currentCount -= uint64(count)
-					return txn.Set([]byte(countKey), helper.Uint64ToBytes(currentCount))
-				}
-			}
+// SPopN 实现 Redis SPOP 命令（带count参数），随机弹出并删除多个成员
+func (s *BotreonStore) SPopN(key string, count int) ([]string, error) {
+	var members []string
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		members = nil
+		size, err := s.setCountTxn(txn, key)
+		if err != nil || size == 0 || count <= 0 {
+			return err
 		}
-		return nil
+		// #nosec G115 - size is bounded by practical set size limits
+		members = s.sampleSetMembers(txn, key, min(count, int(size)))
+		return s.removeSetMembersTxn(txn, key, size, members)
 	}, 30) // 最多重试 30 次（高并发时需要更多重试）
 	return members, err
 }
@@ -330,53 +352,45 @@ func (s *BotreonStore) SPopN(key string, count int) ([]string, error) {
 // SRandMember 实现 Redis SRANDMEMBER 命令，随机获取一个成员（不删除）
 func (s *BotreonStore) SRandMember(key string) (string, error) {
 	var member string
-	err := s.db.View(func(txn *badger.Txn) error {
-		members, err := s.getAllMembers(txn, key)
-		if err != nil {
+	err := s.view(func(txn *storeTxn) error {
+		count, err := s.setCountTxn(txn, key)
+		if err != nil || count == 0 {
 			return err
 		}
-		if len(members) == 0 {
-			return nil
+		// #nosec G115 - count is bounded by practical set size limits
+		if members := s.setMembersAt(txn, key, []int{randomIntn(int(count))}); len(members) > 0 {
+			member = members[0]
 		}
-
-		// 随机选择一个成员
-		index := randomIntn(len(members))
-		member = members[index]
 		return nil
 	})
 	return member, err
 }
 
-// SRandMemberN 实现 Redis SRANDMEMBER 命令（带count参数），随机获取多个成员（不删除）
+// SRandMemberN 实现 Redis SRANDMEMBER 命令（带count参数），随机获取多个成员（不删除）。
+// count 为正数时不重复（至多返回整个集合），为负数时允许重复、返回 -count 个成员
 func (s *BotreonStore) SRandMemberN(key string, count int) ([]string, error) {
 	var members []string
-	err := s.db.View(func(txn *badger.Txn) error {
-		allMembers, err := s.getAllMembers(txn, key)
-		if err != nil {
+	err := s.view(func(txn *storeTxn) error {
+		size, err := s.setCountTxn(txn, key)
+		if err != nil || size == 0 || count == 0 {
 			return err
 		}
-		if len(allMembers) == 0 {
+		if count > 0 {
+			// #nosec G115 - size is bounded by practical set size limits
+			members = s.sampleSetMembers(txn, key, min(count, int(size)))
 			return nil
 		}
-
-		// 如果count为负数，允许重复
-		if count < 0 {
-			count = -count
-			for i := 0; i < count; i++ {
-				index := randomIntn(len(allMembers))
-				members = append(members, allMembers[index])
-			}
-		} else {
-			// 限制count不超过集合大小
-			if count > len(allMembers) {
-				count = len(allMembers)
-			}
-			// 随机选择不重复的成员
-			randomShuffle(len(allMembers), func(i, j int) {
-				allMembers[i], allMembers[j] = allMembers[j], allMembers[i]
-			})
-			members = allMembers[:count]
+		// 允许重复：生成 -count 个随机位置，排序后一次遍历取出，再打乱顺序
+		positions := make([]int, -count)
+		for i := range positions {
+			// #nosec G115 - size is bounded by practical set size limits
+			positions[i] = randomIntn(int(size))
 		}
+		sort.Ints(positions)
+		members = s.setMembersAt(txn, key, positions)
+		randomShuffle(len(members), func(i, j int) {
+			members[i], members[j] = members[j], members[i]
+		})
 		return nil
 	})
 	return members, err
@@ -385,7 +399,9 @@ func (s *BotreonStore) SRandMemberN(key string, count int) ([]string, error) {
 // SMove 实现 Redis SMOVE 命令，将成员从源集合移动到目标集合
 func (s *BotreonStore) SMove(source, destination, member string) (bool, error) {
 	moved := false
-	err := s.db.Update(func(txn *badger.Txn) error {
+	// 源集合与目标集合在同一事务中修改，与并发写冲突时重试
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		moved = false
 		// 检查成员是否在源集合中
 		sourceMemberKey := s.setKey(source, "member", member)
 		_, err := txn.Get([]byte(sourceMemberKey))
@@ -445,14 +461,14 @@ func (s *BotreonStore) SMove(source, destination, member string) (bool, error) {
 
 		moved = true
 		return nil
-	})
+	}, 30)
 	return moved, err
 }
 
 // SInter 实现 Redis SINTER 命令，计算多个集合的交集
 func (s *BotreonStore) SInter(keys ...string) ([]string, error) {
 	var result []string
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		if len(keys) == 0 {
 			return nil
 		}
@@ -493,7 +509,7 @@ func (s *BotreonStore) SInter(keys ...string) ([]string, error) {
 func (s *BotreonStore) SUnion(keys ...string) ([]string, error) {
 	var result []string
 	seen := make(map[string]bool)
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		for _, key := range keys {
 			members, err := s.getAllMembers(txn, key)
 			if err != nil {
@@ -514,7 +530,7 @@ func (s *BotreonStore) SUnion(keys ...string) ([]string, error) {
 // SDiff 实现 Redis SDIFF 命令，计算第一个集合与其他集合的差集
 func (s *BotreonStore) SDiff(keys ...string) ([]string, error) {
 	var result []string
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		if len(keys) == 0 {
 			return nil
 		}
@@ -551,7 +567,7 @@ func (s *BotreonStore) SDiff(keys ...string) ([]string, error) {
 // SInterStore 实现 Redis SINTERSTORE 命令，计算交集并存储到目标集合
 func (s *BotreonStore) SInterStore(destination string, keys ...string) (int, error) {
 	var count int
-	err := s.db.Update(func(txn *badger.Txn) error {
+	err := s.retryUpdate(func(txn *storeTxn) error {
 		// 在事务中计算交集
 		var result []string
 		if len(keys) > 0 {
@@ -608,14 +624,14 @@ func (s *BotreonStore) SInterStore(destination string, keys ...string) (int, err
 		countKey := s.setKey(destination, "count")
 	// #nosec G115 - count is bounded by practical set size limits
 		return txn.Set([]byte(countKey), helper.Uint64ToBytes(uint64(count)))
-	})
+	}, 30)
 	return count, err
 }
 
 // SUnionStore 实现 Redis SUNIONSTORE 命令，计算并集并存储到目标集合
 func (s *BotreonStore) SUnionStore(destination string, keys ...string) (int, error) {
 	var count int
-	err := s.db.Update(func(txn *badger.Txn) error {
+	err := s.retryUpdate(func(txn *storeTxn) error {
 		// 在事务中计算并集
 		var result []string
 		seen := make(map[string]bool)
@@ -659,14 +675,14 @@ func (s *BotreonStore) SUnionStore(destination string, keys ...string) (int, err
 		countKey := s.setKey(destination, "count")
 	// #nosec G115 - count is bounded by practical set size limits
 		return txn.Set([]byte(countKey), helper.Uint64ToBytes(uint64(count)))
-	})
+	}, 30)
 	return count, err
 }
 
 // SDiffStore 实现 Redis SDIFFSTORE 命令，计算差集并存储到目标集合
 func (s *BotreonStore) SDiffStore(destination string, keys ...string) (int, error) {
 	var count int
-	err := s.db.Update(func(txn *badger.Txn) error {
+	err := s.retryUpdate(func(txn *storeTxn) error {
 		// 在事务中计算差集
 		var result []string
 		if len(keys) > 0 {
@@ -723,14 +739,14 @@ func (s *BotreonStore) SDiffStore(destination string, keys ...string) (int, erro
 		countKey := s.setKey(destination, "count")
 	// #nosec G115 - count is bounded by practical set size limits
 		return txn.Set([]byte(countKey), helper.Uint64ToBytes(uint64(count)))
-	})
+	}, 30)
 	return count, err
 }
 
 // SMIsMember 实现 Redis SMISMEMBER 命令，检查多个成员是否在集合中
 func (s *BotreonStore) SMIsMember(key string, members ...string) ([]int64, error) {
 	results := make([]int64, len(members))
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		for i, member := range members {
 			memberKey := s.setKey(key, "member", member)
 			_, err := txn.Get([]byte(memberKey))
@@ -747,29 +763,59 @@ func (s *BotreonStore) SMIsMember(key string, members ...string) ([]int64, error
 	return results, err
 }
 
-// SInterCard 实现 Redis SINTERCARD 命令，返回多个集合的交集基数
-func (s *BotreonStore) SInterCard(keys ...string) (int64, error) {
+// SInterCard 实现 Redis SINTERCARD 命令，返回多个集合交集的基数，limit > 0 时计数到 limit 即停止。
+// 按计数键从小到大排列集合（任一集合为空时交集为空，不再遍历），只遍历最小集合的成员键，
+// 逐个在其余集合中查找，不物化交集，耗时由最小集合与 limit 决定
+func (s *BotreonStore) SInterCard(limit int64, keys ...string) (int64, error) {
 	var count int64
-	err := s.db.View(func(txn *badger.Txn) error {
-		if len(keys) == 0 {
+	err := s.view(func(txn *storeTxn) error {
+		count = 0
+		sizes := make(map[string]uint64, len(keys))
+		for _, key := range keys {
+			if _, ok := sizes[key]; ok {
+				continue
+			}
+			n, err := s.setCountTxn(txn, key)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return nil
+			}
+			sizes[key] = n
+		}
+		if len(sizes) == 0 {
 			return nil
 		}
+		ordered := make([]string, 0, len(sizes))
+		for key := range sizes {
+			ordered = append(ordered, key)
+		}
+		sort.Slice(ordered, func(i, j int) bool {
+			if sizes[ordered[i]] != sizes[ordered[j]] {
+				return sizes[ordered[i]] < sizes[ordered[j]]
+			}
+			return ordered[i] < ordered[j]
+		})
 
-		// 获取第一个集合的所有成员
-		firstMembers, err := s.getAllMembers(txn, keys[0])
-		if err != nil {
-			return err
+		smallest, others := ordered[0], ordered[1:]
+		prefix := s.setMemberPrefix(smallest)
+		expected := int64(-1)
+		if sizes[smallest] <= math.MaxInt64 {
+			// #nosec G115 - checked above
+			expected = int64(sizes[smallest])
 		}
-		if len(firstMembers) == 0 {
-			return nil
+		if limit > 0 && len(others) == 0 {
+			expected = min(expected, limit)
 		}
-
-		// 统计在所有集合中都存在的成员
-		for _, member := range firstMembers {
+		// 成员名在键中，只遍历键
+		iter := txn.NewIterator(s.iteratorOptions(prefix, expected, false))
+		defer iter.Close()
+		for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+			member := string(iter.Item().Key()[len(prefix):])
 			inAll := true
-			for i := 1; i < len(keys); i++ {
-				memberKey := s.setKey(keys[i], "member", member)
-				_, err := txn.Get([]byte(memberKey))
+			for _, key := range others {
+				_, err := txn.Get([]byte(s.setKey(key, "member", member)))
 				if errors.Is(err, badger.ErrKeyNotFound) {
 					inAll = false
 					break
@@ -778,8 +824,12 @@ func (s *BotreonStore) SInterCard(keys ...string) (int64, error) {
 					return err
 				}
 			}
-			if inAll {
-				count++
+			if !inAll {
+				continue
+			}
+			count++
+			if limit > 0 && count >= limit {
+				return nil
 			}
 		}
 		return nil
@@ -793,62 +843,18 @@ type SScanResult struct {
 	Members  []string
 }
 
-// SScan 实现 Redis SSCAN 命令，增量迭代集合的成员
+// SScan 实现 Redis SSCAN 命令，增量迭代集合的成员，游标语义见 scanPrefix
 func (s *BotreonStore) SScan(key string, cursor uint64, pattern string, count int) (SScanResult, error) {
-	var result SScanResult
-	result.Cursor = 0
-	result.Members = []string{}
-
-	if count <= 0 {
-		count = 10 // 默认值
-	}
-
-	err := s.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		prefix := []byte(KeyTypeSet + ":" + key + ":member:")
-		opts.Prefix = prefix
-		opts.PrefetchValues = false
-
-		iter := txn.NewIterator(opts)
-		defer iter.Close()
-
-		currentPos := uint64(0)
-		collected := 0
-
-		// 如果cursor不为0，需要跳过前面的成员
-		if cursor > 0 {
-			for iter.Seek(prefix); iter.ValidForPrefix(prefix) && currentPos < cursor; iter.Next() {
-				currentPos++
-			}
-		} else {
-			iter.Seek(prefix)
+	result := SScanResult{Members: []string{}}
+	prefix := []byte(KeyTypeSet + ":" + key + ":member:")
+	next, err := s.scanPrefix("sscan:"+key, prefix, cursor, count, func(item *badger.Item) error {
+		// 解析键格式: SET:key:member:memberValue
+		member := string(item.Key()[len(prefix):])
+		if pattern == "" || pattern == "*" || matchPattern(member, pattern) {
+			result.Members = append(result.Members, member)
 		}
-
-		// 收集匹配的成员
-		for iter.ValidForPrefix(prefix) && collected < count {
-			item := iter.Item()
-			keyBytes := item.KeyCopy(nil)
-
-			// 解析键格式: set:key:member:memberValue
-			member := string(keyBytes[len(prefix):])
-
-			if pattern == "" || pattern == "*" || matchPattern(member, pattern) {
-				result.Members = append(result.Members, member)
-				collected++
-			}
-
-			currentPos++
-			iter.Next()
-		}
-
-		// 检查是否还有更多成员
-		if iter.ValidForPrefix(prefix) {
-			result.Cursor = currentPos
-		} else {
-			result.Cursor = 0 // 0表示迭代完成
-		}
-
 		return nil
 	})
+	result.Cursor = next
 	return result, err
 }
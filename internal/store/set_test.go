@@ -1,6 +1,7 @@
 package store
 
 import (
+	"strconv"
 	"testing"
 
 	"github.com/zeebo/assert"
@@ -566,3 +567,101 @@ func TestSetEdgeCases(t *testing.T) {
 	members, _ = store.SMembers("special")
 	assert.Equal(t, 3, len(members))
 }
+
+func TestSetRandomSampling(t *testing.T) {
+	dbPath := t.TempDir()
+	store, _ := NewBadgerStore(dbPath)
+	defer store.Close()
+
+	key := "bigset"
+	members := make([]string, 2000)
+	for i := range members {
+		members[i] = "m" + strconv.Itoa(i)
+	}
+	_, err := store.SAdd(key, members...)
+	assert.NoError(t, err)
+
+	// 不重复：返回的成员互不相同且都在集合中
+	sample, err := store.SRandMemberN(key, 300)
+	assert.NoError(t, err)
+	assert.Equal(t, 300, len(sample))
+	seen := make(map[string]bool)
+	for _, m := range sample {
+		assert.False(t, seen[m])
+		seen[m] = true
+		exists, _ := store.SIsMember(key, m)
+		assert.True(t, exists)
+	}
+
+	// 允许重复：返回 -count 个成员
+	sample, err = store.SRandMemberN(key, -3000)
+	assert.NoError(t, err)
+	assert.Equal(t, 3000, len(sample))
+
+	// 抽样覆盖整个集合：多次抽样后末尾的成员也会被选中
+	counts := make(map[string]int)
+	for i := 0; i < 50; i++ {
+		sample, err = store.SRandMemberN(key, 100)
+		assert.NoError(t, err)
+		for _, m := range sample {
+			counts[m]++
+		}
+	}
+	tail := 0
+	for i := 1000; i < 2000; i++ {
+		tail += counts[members[i]]
+	}
+	// 期望约为 2500
+	assert.True(t, tail > 2000 && tail < 3000)
+
+	// SPOP count 删除选中的成员并更新计数
+	popped, err := store.SPopN(key, 500)
+	assert.NoError(t, err)
+	assert.Equal(t, 500, len(popped))
+	for _, m := range popped {
+		exists, _ := store.SIsMember(key, m)
+		assert.False(t, exists)
+	}
+	count, _ := store.SCard(key)
+	assert.Equal(t, uint64(1500), count)
+	all, err := store.SMembers(key)
+	assert.NoError(t, err)
+	assert.Equal(t, 1500, len(all))
+}
+
+func TestSInterCard(t *testing.T) {
+	dbPath := t.TempDir()
+	store, _ := NewBadgerStore(dbPath)
+	defer store.Close()
+
+	big := make([]string, 1000)
+	for i := range big {
+		big[i] = "m" + strconv.Itoa(i)
+	}
+	_, err := store.SAdd("big", big...)
+	assert.NoError(t, err)
+	_, err = store.SAdd("small", "m1", "m2", "m3", "x")
+	assert.NoError(t, err)
+
+	count, err := store.SInterCard(0, "big", "small")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	// 重复的键不影响结果
+	count, err = store.SInterCard(0, "small", "big", "small")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	// 达到 LIMIT 即停止
+	count, err = store.SInterCard(2, "big", "small")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	count, err = store.SInterCard(10, "big")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), count)
+
+	// 任一集合不存在时交集为空
+	count, err = store.SInterCard(0, "big", "missing", "small")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
@@ -76,6 +76,23 @@ func decodeMeta(b []byte) (ZSetsMetaValue, error) {
 	return ZSetsMetaValue{Card: card, Version: version}, nil
 }
 
+// sortedSetMetaTxn 读取有序集合的元数据，集合不存在时返回零值
+func sortedSetMetaTxn(txn *storeTxn, zSetName string) (ZSetsMetaValue, error) {
+	item, err := txn.Get(sortedSetKeyMeta(zSetName))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return ZSetsMetaValue{}, nil
+	}
+	if err != nil {
+		return ZSetsMetaValue{}, err
+	}
+	var meta ZSetsMetaValue
+	err = item.Value(func(val []byte) error {
+		meta, err = decodeMeta(val)
+		return err
+	})
+	return meta, err
+}
+
 func sortedSetKeyMeta(zSetName string) []byte {
 	return keyBadgerGet(prefixKeySortedSetBytes, []byte(zSetName+":meta"))
 }
@@ -88,6 +105,35 @@ func sortedSetKeyIndex(zSetName string, score float64, member string, version ui
 	return keyBadgerGet(prefixKeySortedSetBytes, key)
 }
 
+// deleteSortedSetIndex 删除成员的索引键并更新排名索引。索引键带有写入时的版本号，与元数据中的
+// 当前版本不一定相同，因此按 <分数>:<member>: 前缀查找，而不是拼出完整的键
+func deleteSortedSetIndex(txn *storeTxn, zSetName string, score float64, member string) error {
+	prefix := keyBadgerGet(prefixKeySortedSetBytes, []byte(zSetName+sortedSetIndex))
+	prefix = append(prefix, encodeScore(score)...)
+	prefix = append(prefix, []byte(":"+member+":")...)
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	var keys [][]byte
+	for it.Rewind(); it.Valid(); it.Next() {
+		if key := it.Item().Key(); len(key) == len(prefix)+4 {
+			keys = append(keys, it.Item().KeyCopy(nil))
+		}
+	}
+	it.Close()
+	rank := newZSetRankIndex(txn, zSetName)
+	for _, key := range keys {
+		if err := txn.Delete(key); err != nil {
+			return err
+		}
+		if err := rank.remove(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func sortedSetKeyMember(zSetName, member string) []byte {
 	return keyBadgerGet(prefixKeySortedSetBytes, []byte(zSetName+sortedSetData+member))
 }
@@ -103,10 +149,10 @@ func keyBadgerGet(prefix string, key []byte) []byte {
 }
 
 // retryUpdate 重试执行 BadgerDB Update 操作，处理事务冲突
-func (s *BotreonStore) retryUpdateSortedSet(fn func(*badger.Txn) error, maxRetries int) error {
+func (s *BotreonStore) retryUpdateSortedSet(fn func(*storeTxn) error, maxRetries int) error {
 	var err error
 	for i := 0; i < maxRetries; i++ {
-		err = s.db.Update(fn)
+		err = s.update(fn)
 		if err == nil {
 			return nil
 		}
@@ -140,119 +186,135 @@ func (s *BotreonStore) ZAdd(zSetName string, members []ZSetMember) error {
 	if len(members) == 0 {
 		return nil
 	}
-	return s.retryUpdateSortedSet(func(txn *badger.Txn) error {
-		badgerTypeKey := TypeOfKeyGet(zSetName)
-		if err := txn.Set(badgerTypeKey, []byte(KeyTypeSortedSet)); err != nil {
-			logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZAdd: Failed to set type key")
+	err := s.retryUpdateSortedSet(func(txn *storeTxn) error {
+		return s.zaddTxn(txn, zSetName, members)
+	}, 20) // 最多重试 20 次（优化：减少重试次数，大部分冲突在前几次重试就能解决）
+	if err == nil {
+		s.notifyZWatch(zSetName, members, nil, false)
+		s.notifyBlockingPop(zSetName, len(members))
+	}
+	return err
+}
+
+// zaddTxn 在 txn 中添加或更新有序集合成员
+func (s *BotreonStore) zaddTxn(txn *storeTxn, zSetName string, members []ZSetMember) error {
+	badgerTypeKey := TypeOfKeyGet(zSetName)
+	if err := txn.Set(badgerTypeKey, []byte(KeyTypeSortedSet)); err != nil {
+		logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZAdd: Failed to set type key")
+		return err
+	}
+
+	// 获取元数据
+	metaKey := sortedSetKeyMeta(zSetName)
+	var meta ZSetsMetaValue
+	item, err := txn.Get(metaKey)
+	if err == nil {
+		err = item.Value(func(val []byte) error {
+			meta, err = decodeMeta(val)
+			return err
+		})
+		if err != nil {
+			logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZAdd: Failed to decode meta")
 			return err
 		}
+	} else if !errors.Is(err, badger.ErrKeyNotFound) {
+		logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZAdd: Failed to get meta")
+		return err
+	}
+	newMembers := int64(len(members))
+	meta.Version++
+	if err := newZSetRankIndex(txn, zSetName).ensure(); err != nil {
+		logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZAdd: Failed to build rank index")
+		return err
+	}
 
-		// 获取元数据
-		metaKey := sortedSetKeyMeta(zSetName)
-		var meta ZSetsMetaValue
-		item, err := txn.Get(metaKey)
+	// 批量收集操作
+	type operation struct {
+		member   string
+		dataKey  []byte
+		indexKey []byte
+		oldScore *float64
+		score    []byte
+	}
+	ops := make([]operation, 0, len(members))
+
+	for _, m := range members {
+		member := m.Member
+		score := m.Score
+		dataKey := sortedSetKeyMember(zSetName, member)
+
+		// 检查旧分数
+		var oldScore float64
+		item, err := txn.Get(dataKey)
 		if err == nil {
+			var oldScoreBytes []byte
 			err = item.Value(func(val []byte) error {
-				meta, err = decodeMeta(val)
-				return err
+				oldScoreBytes = val
+				return nil
 			})
 			if err != nil {
-				logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZAdd: Failed to decode meta")
+				logger.Logger.Error().Err(err).Str("zset_name", zSetName).Str("member", member).Msg("ZAdd: Failed to get old score")
 				return err
 			}
+			oldScore = decodeScore(oldScoreBytes)
+			newMembers-- // 替换现有成员，计数不变
 		} else if !errors.Is(err, badger.ErrKeyNotFound) {
-			logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZAdd: Failed to get meta")
+			logger.Logger.Error().Err(err).Str("zset_name", zSetName).Str("member", member).Msg("ZAdd: Failed to check member")
 			return err
 		}
-		newMembers := int64(len(members))
-		meta.Version++
 
-		// 批量收集操作
-		type operation struct {
-			dataKey     []byte
-			indexKey    []byte
-			oldIndexKey []byte
-			score       []byte
+		// 准备操作
+		op := operation{
+			member:   member,
+			dataKey:  dataKey,
+			indexKey: sortedSetKeyIndex(zSetName, score, member, meta.Version),
+			score:    encodeScore(score),
+		}
+		if err == nil {
+			op.oldScore = &oldScore
 		}
-		ops := make([]operation, 0, len(members))
+		ops = append(ops, op)
+	}
 
-		for _, m := range members {
-			member := m.Member
-			score := m.Score
-			dataKey := sortedSetKeyMember(zSetName, member)
+	// 更新元数据计数
+	meta.Card += newMembers
+	if err := txn.Set(metaKey, encodeMeta(meta)); err != nil {
+		logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZAdd: Failed to set meta")
+		return err
+	}
 
-			// 检查旧分数
-			var oldScore float64
-			item, err := txn.Get(dataKey)
-			if err == nil {
-				var oldScoreBytes []byte
-				err = item.Value(func(val []byte) error {
-					oldScoreBytes = val
-					return nil
-				})
-				if err != nil {
-					logger.Logger.Error().Err(err).Str("zset_name", zSetName).Str("member", member).Msg("ZAdd: Failed to get old score")
-					return err
-				}
-				oldScore = decodeScore(oldScoreBytes)
-				newMembers-- // 替换现有成员，计数不变
-			} else if !errors.Is(err, badger.ErrKeyNotFound) {
-				logger.Logger.Error().Err(err).Str("zset_name", zSetName).Str("member", member).Msg("ZAdd: Failed to check member")
+	// 批量执行操作
+	for _, op := range ops {
+		if op.oldScore != nil {
+			if err := deleteSortedSetIndex(txn, zSetName, *op.oldScore, op.member); err != nil {
+				logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZAdd: Failed to delete old index")
 				return err
 			}
-
-			// 准备操作
-			op := operation{
-				dataKey:  dataKey,
-				indexKey: sortedSetKeyIndex(zSetName, score, member, meta.Version),
-				score:    encodeScore(score),
-			}
-			if err == nil {
-				op.oldIndexKey = sortedSetKeyIndex(zSetName, oldScore, member, meta.Version-1)
-			}
-			ops = append(ops, op)
 		}
-
-		// 更新元数据计数
-		meta.Card += newMembers
-		if err := txn.Set(metaKey, encodeMeta(meta)); err != nil {
-			logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZAdd: Failed to set meta")
+		if err := txn.Set(op.dataKey, op.score); err != nil {
+			logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZAdd: Failed to set data key")
 			return err
 		}
-
-		// 批量执行操作
-		for _, op := range ops {
-			if op.oldIndexKey != nil {
-				if err := txn.Delete(op.oldIndexKey); err != nil {
-					logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZAdd: Failed to delete old index")
-					return err
-				}
-			}
-			if err := txn.Set(op.dataKey, op.score); err != nil {
-				logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZAdd: Failed to set data key")
-				return err
-			}
-			if err := txn.Set(op.indexKey, nil); err != nil {
-				logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZAdd: Failed to set index key")
-				return err
-			}
+		if err := setSortedSetIndex(txn, zSetName, op.indexKey); err != nil {
+			logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZAdd: Failed to set index key")
+			return err
 		}
+	}
 
-		// 成功路径不记录日志，避免性能影响
-		// 只在 DEBUG 级别记录详细信息
-		logger.Logger.Debug().
-			Int("members_count", len(members)).
-			Str("zset_name", zSetName).
-			Int64("card", meta.Card).
-			Msg("ZAdd: Successfully added members")
-		return nil
-	}, 20) // 最多重试 20 次（优化：减少重试次数，大部分冲突在前几次重试就能解决）
+	// 成功路径不记录日志，避免性能影响
+	// 只在 DEBUG 级别记录详细信息
+	logger.Logger.Debug().
+		Int("members_count", len(members)).
+		Str("zset_name", zSetName).
+		Int64("card", meta.Card).
+		Msg("ZAdd: Successfully added members")
+	return nil
 }
 
 // ZRangeByScore 获取分数范围内的成员
 func (s *BotreonStore) ZRangeByScore(zSetName string, minScore, maxScore float64, offset, count int, minExclusive, maxExclusive bool) ([]ZSetMember, error) {
 	var results []ZSetMember
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		opts := badger.DefaultIteratorOptions
 		prefix := keyBadgerGet(prefixKeySortedSetBytes, []byte(zSetName+sortedSetIndex)) // e.g., "zset:myset:index:"
 		opts.Prefix = prefix
@@ -318,7 +380,7 @@ func (s *BotreonStore) ZRangeByScore(zSetName string, minScore, maxScore float64
 
 // ZRem 删除成员
 func (s *BotreonStore) ZRem(zSetName, member string) error {
-	return s.retryUpdateSortedSet(func(txn *badger.Txn) error {
+	err := s.retryUpdateSortedSet(func(txn *storeTxn) error {
 		dataKey := sortedSetKeyMember(zSetName, member)
 		item, err := txn.Get(dataKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -364,8 +426,7 @@ func (s *BotreonStore) ZRem(zSetName, member string) error {
 			return err
 		}
 
-		indexKey := sortedSetKeyIndex(zSetName, score, member, meta.Version)
-		if err := txn.Delete(indexKey); err != nil {
+		if err := deleteSortedSetIndex(txn, zSetName, score, member); err != nil {
 			logger.Logger.Error().Err(err).Msg("ZRem: Failed to delete index key")
 			return err
 		}
@@ -393,6 +454,10 @@ func (s *BotreonStore) ZRem(zSetName, member string) error {
 			Msg("ZRem: Successfully removed member")
 		return nil
 	}, 20) // 最多重试 20 次（优化：减少重试次数）
+	if err == nil {
+		s.notifyZWatch(zSetName, nil, []string{member}, false)
+	}
+	return err
 }
 
 // ZScore 获取成员分数
@@ -400,7 +465,7 @@ func (s *BotreonStore) ZScore(zSetName, member string) (float64, bool, error) {
 	var score float64
 	dataKey := sortedSetKeyMember(zSetName, member)
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		item, err := txn.Get(dataKey)
 		if err != nil {
 			return err
@@ -420,94 +485,32 @@ func (s *BotreonStore) ZScore(zSetName, member string) (float64, bool, error) {
 	return score, true, err
 }
 
-// ZRange 获取指定排名范围的成员
+// ZRange 获取指定排名范围的成员。通过排名索引直接定位到 start 所在的位置，不需要从头遍历
 func (s *BotreonStore) ZRange(zSetName string, start, stop int64) ([]*ZSetMember, error) {
 	var results []*ZSetMember
-	err := s.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		//prefix := []byte(zSetName + sortedSetIndex) // e.g., "myset:index:"
-		//opts.Prefix = prefix
-		prefix := keyBadgerGet(prefixKeySortedSetBytes, []byte(zSetName+sortedSetIndex)) // e.g., "zset:myset:index:"
-		opts.Prefix = prefix
-		opts.PrefetchValues = false
-
-		// 获取元数据
-		metaKey := sortedSetKeyMeta(zSetName)
-		var totalCount int64
-		item, err := txn.Get(metaKey)
-		if err == nil {
-			var meta ZSetsMetaValue
-			err = item.Value(func(val []byte) error {
-				meta, err = decodeMeta(val)
-				return err
-			})
-			if err != nil {
-				logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZRange: Failed to decode meta")
-				return err
-			}
-			totalCount = meta.Card
-		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+	err := s.view(func(txn *storeTxn) error {
+		meta, err := sortedSetMetaTxn(txn, zSetName)
+		if err != nil {
 			logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZRange: Failed to get meta")
 			return err
 		}
-
-		// 处理负索引
-		if start < 0 {
-			start = totalCount + start
-		}
-		if stop < 0 {
-			stop = totalCount + stop
-		}
-		if start < 0 {
-			start = 0
-		}
-		if stop >= totalCount {
-			stop = totalCount - 1
-		}
-		if start > stop || totalCount == 0 {
+		start, stop, ok := normalizeZSetRange(start, stop, meta.Card)
+		if !ok {
 			return nil
 		}
 
-		it := txn.NewIterator(opts)
+		startKey, err := newZSetRankIndex(txn, zSetName).keyAt(start)
+		if err != nil || startKey == nil {
+			return err
+		}
+		prefix := sortedSetIndexPrefix(zSetName)
+		// 成员和分数都编码在索引键中，只遍历键
+		it := txn.NewIterator(s.iteratorOptions(prefix, stop-start+1, false))
 		defer it.Close()
-
-		currentIndex := int64(0)
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			if currentIndex < start {
-				currentIndex++
-				continue
-			}
-			if currentIndex > stop {
-				break
+		for it.Seek(startKey); it.ValidForPrefix(prefix) && int64(len(results)) <= stop-start; it.Next() {
+			if m, ok := parseSortedSetIndexKey(prefix, it.Item().Key()); ok {
+				results = append(results, &ZSetMember{Member: m.Member, Score: m.Score})
 			}
-
-			item := it.Item()
-			key := item.Key()
-
-			// 键格式: zset:zSetName:index:scoreBytes:member:versionBytes
-			// 去掉前缀 "zset:"
-			keyWithoutPrefix := key[len(prefixKeySortedSetBytes):]
-			// 格式: zSetName:index:scoreBytes:member:versionBytes
-			keyParts := bytes.Split(keyWithoutPrefix, []byte(":"))
-			if len(keyParts) < 5 {
-				logger.Logger.Debug().Str("key", string(key)).Msg("ZRange: Invalid key format")
-				continue
-			}
-			// keyParts[0] = zSetName
-			// keyParts[1] = "index"
-			// keyParts[2] = scoreBytes (8 bytes)
-			// keyParts[3] = member
-			// keyParts[4] = versionBytes (4 bytes)
-			scoreBytes := keyParts[2]
-			member := string(keyParts[3])
-			if len(scoreBytes) != 8 {
-				logger.Logger.Debug().Int("length", len(scoreBytes)).Msg("ZRange: Invalid score bytes length")
-				continue
-			}
-			score := decodeScore(scoreBytes)
-
-			results = append(results, &ZSetMember{Member: member, Score: score})
-			currentIndex++
 		}
 		// 成功路径不记录日志，避免性能影响
 		logger.Logger.Debug().
@@ -519,32 +522,40 @@ func (s *BotreonStore) ZRange(zSetName string, start, stop int64) ([]*ZSetMember
 	return results, err
 }
 
+// normalizeZSetRange 把可能为负数的排名区间转换为 [0, card) 内的区间，区间为空时 ok 为 false
+func normalizeZSetRange(start, stop, card int64) (int64, int64, bool) {
+	if start < 0 {
+		start = card + start
+	}
+	if stop < 0 {
+		stop = card + stop
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= card {
+		stop = card - 1
+	}
+	return start, stop, start <= stop && card > 0
+}
+
 // ZSetDel 删除整个排序集
 func (s *BotreonStore) ZSetDel(zSetName string) error {
-	return s.retryUpdateSortedSet(func(txn *badger.Txn) error {
-		dataPrefix := []byte(zSetName + sortedSetData)
-		indexPrefix := []byte(zSetName + sortedSetIndex)
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = false
-
-		// 删除数据键
-		it := txn.NewIterator(opts)
-		defer it.Close()
-		for it.Rewind(); it.ValidForPrefix(dataPrefix); it.Next() {
-			if err := txn.Delete(it.Item().Key()); err != nil {
-				logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZSetDel: Failed to delete data key")
-				return err
-			}
+	err := s.retryUpdateSortedSet(func(txn *storeTxn) error {
+		// 删除数据键和索引键
+		dataPrefix := keyBadgerGet(prefixKeySortedSetBytes, []byte(zSetName+sortedSetData))
+		if err := deleteByPrefix(txn, dataPrefix); err != nil {
+			logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZSetDel: Failed to delete data key")
+			return err
 		}
-
-		// 删除索引键
-		it = txn.NewIterator(opts)
-		defer it.Close()
-		for it.Rewind(); it.ValidForPrefix(indexPrefix); it.Next() {
-			if err := txn.Delete(it.Item().Key()); err != nil {
-				logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZSetDel: Failed to delete index key")
-				return err
-			}
+		indexPrefix := keyBadgerGet(prefixKeySortedSetBytes, []byte(zSetName+sortedSetIndex))
+		if err := deleteByPrefix(txn, indexPrefix); err != nil {
+			logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZSetDel: Failed to delete index key")
+			return err
+		}
+		if err := deleteByPrefix(txn, sortedSetRankPrefix(zSetName)); err != nil {
+			logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZSetDel: Failed to delete rank index")
+			return err
 		}
 
 		// 删除元数据和类型键
@@ -561,12 +572,16 @@ func (s *BotreonStore) ZSetDel(zSetName string) error {
 		logger.Logger.Debug().Str("zset_name", zSetName).Msg("ZSetDel: Successfully deleted set")
 		return nil
 	}, 20) // 最多重试 20 次（优化：减少重试次数）
+	if err == nil {
+		s.notifyZWatch(zSetName, nil, nil, true)
+	}
+	return err
 }
 
 // ZCard 实现 Redis ZCARD 命令，获取有序集合中成员的数量
 func (s *BotreonStore) ZCard(zSetName string) (int64, error) {
 	var card int64
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		metaKey := sortedSetKeyMeta(zSetName)
 		item, err := txn.Get(metaKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -591,7 +606,7 @@ func (s *BotreonStore) ZCard(zSetName string) (int64, error) {
 // ZCount 实现 Redis ZCOUNT 命令，计算在有序集合中指定区间分数的成员数
 func (s *BotreonStore) ZCount(zSetName string, minScore, maxScore float64) (int64, error) {
 	var count int64
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		opts := badger.DefaultIteratorOptions
 		prefix := keyBadgerGet(prefixKeySortedSetBytes, []byte(zSetName+sortedSetIndex))
 		opts.Prefix = prefix
@@ -628,7 +643,7 @@ func (s *BotreonStore) ZCount(zSetName string, minScore, maxScore float64) (int6
 // ZIncrBy 实现 Redis ZINCRBY 命令，增加成员的分数
 func (s *BotreonStore) ZIncrBy(zSetName, member string, increment float64) (float64, error) {
 	var newScore float64
-	err := s.retryUpdateSortedSet(func(txn *badger.Txn) error {
+	err := s.retryUpdateSortedSet(func(txn *storeTxn) error {
 		badgerTypeKey := TypeOfKeyGet(zSetName)
 		if err := txn.Set(badgerTypeKey, []byte(KeyTypeSortedSet)); err != nil {
 			return err
@@ -655,6 +670,9 @@ func (s *BotreonStore) ZIncrBy(zSetName, member string, increment float64) (floa
 
 		// 计算新分数
 		newScore = currentScore + increment
+		if math.IsNaN(newScore) {
+			return ErrZSetScoreNaN
+		}
 
 		// 获取元数据
 		metaKey := sortedSetKeyMeta(zSetName)
@@ -672,17 +690,17 @@ func (s *BotreonStore) ZIncrBy(zSetName, member string, increment float64) (floa
 			return err
 		}
 
-		var oldIndexKey []byte
-		if memberExists {
-			oldIndexKey = sortedSetKeyIndex(zSetName, currentScore, member, meta.Version)
-		} else {
+		if !memberExists {
 			meta.Card++
 		}
 		meta.Version++
+		if err := newZSetRankIndex(txn, zSetName).ensure(); err != nil {
+			return err
+		}
 
 		// 删除旧索引
-		if oldIndexKey != nil {
-			if err := txn.Delete(oldIndexKey); err != nil {
+		if memberExists {
+			if err := deleteSortedSetIndex(txn, zSetName, currentScore, member); err != nil {
 				return err
 			}
 		}
@@ -692,270 +710,293 @@ func (s *BotreonStore) ZIncrBy(zSetName, member string, increment float64) (floa
 			return err
 		}
 		newIndexKey := sortedSetKeyIndex(zSetName, newScore, member, meta.Version)
-		if err := txn.Set(newIndexKey, nil); err != nil {
+		if err := setSortedSetIndex(txn, zSetName, newIndexKey); err != nil {
 			return err
 		}
 
 		// 更新元数据
 		return txn.Set(metaKey, encodeMeta(meta))
 	}, 20) // 最多重试 20 次（优化：减少重试次数）
+	if err == nil {
+		s.notifyZWatch(zSetName, []ZSetMember{{Member: member, Score: newScore}}, nil, false)
+		s.notifyBlockingPop(zSetName, 1)
+	}
 	return newScore, err
 }
 
-// ZRank 实现 Redis ZRANK 命令，返回成员的排名（从0开始，分数从小到大）
+// ZRank 实现 Redis ZRANK 命令，返回成员的排名（从0开始，分数从小到大），成员不存在时返回 -1
 func (s *BotreonStore) ZRank(zSetName, member string) (int64, error) {
 	var rank int64 = -1
-	err := s.db.View(func(txn *badger.Txn) error {
-		// 获取成员分数
-		dataKey := sortedSetKeyMember(zSetName, member)
-		item, err := txn.Get(dataKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return nil
+	err := s.view(func(txn *storeTxn) error {
+		var err error
+		rank, err = zsetRankTxn(txn, zSetName, member)
+		return err
+	})
+	return rank, err
+}
+
+// zsetRankTxn 按排名索引计算成员的正向排名，成员不存在时返回 -1
+func zsetRankTxn(txn *storeTxn, zSetName, member string) (int64, error) {
+	item, err := txn.Get(sortedSetKeyMember(zSetName, member))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return -1, nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	var score []byte
+	if err := item.Value(func(val []byte) error {
+		score = bytes.Clone(val)
+		return nil
+	}); err != nil {
+		return -1, err
+	}
+	// 成员的索引键以 <分数>:<member>: 开头，排在它之前的索引键数即为排名
+	suffix := append(score, []byte(":"+member+":")...)
+	return newZSetRankIndex(txn, zSetName).rankOf(suffix)
+}
+
+// ZRevRank 实现 Redis ZREVRANK 命令，返回成员的排名（从0开始，分数从大到小）
+func (s *BotreonStore) ZRevRank(zSetName, member string) (int64, error) {
+	var rank int64 = -1
+	err := s.view(func(txn *storeTxn) error {
+		forwardRank, err := zsetRankTxn(txn, zSetName, member)
+		if err != nil || forwardRank < 0 {
+			return err
 		}
+		meta, err := sortedSetMetaTxn(txn, zSetName)
 		if err != nil {
 			return err
 		}
-		var score float64
-		err = item.Value(func(val []byte) error {
-			score = decodeScore(val)
-			return nil
-		})
+		rank = meta.Card - 1 - forwardRank
+		return nil
+	})
+	return rank, err
+}
+
+// ZRevRange 实现 Redis ZREVRANGE 命令，返回有序集中指定区间内的成员，通过索引，分数从高到低。
+// 反向排名 start 对应正向排名 card-1-start，从该索引键开始反向遍历
+func (s *BotreonStore) ZRevRange(zSetName string, start, stop int64) ([]*ZSetMember, error) {
+	results := []*ZSetMember{}
+	err := s.view(func(txn *storeTxn) error {
+		meta, err := sortedSetMetaTxn(txn, zSetName)
 		if err != nil {
 			return err
 		}
+		start, stop, ok := normalizeZSetRange(start, stop, meta.Card)
+		if !ok {
+			return nil
+		}
+
+		startKey, err := newZSetRankIndex(txn, zSetName).keyAt(meta.Card - 1 - start)
+		if err != nil || startKey == nil {
+			return err
+		}
+		prefix := sortedSetIndexPrefix(zSetName)
+		opts := s.iteratorOptions(prefix, stop-start+1, false)
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(startKey); it.ValidForPrefix(prefix) && int64(len(results)) <= stop-start; it.Next() {
+			if m, ok := parseSortedSetIndexKey(prefix, it.Item().Key()); ok {
+				results = append(results, &ZSetMember{Member: m.Member, Score: m.Score})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
 
-		// 遍历索引，计算排名
+// ZRevRangeByScore 实现 Redis ZREVRANGEBYSCORE 命令，返回有序集中指定分数区间内的成员，分数从高到低排序。
+// 反向遍历索引，直接定位到 maxScore，遇到小于 minScore 的成员即停止
+func (s *BotreonStore) ZRevRangeByScore(zSetName string, maxScore, minScore float64, offset, count int, minExclusive, maxExclusive bool) ([]ZSetMember, error) {
+	results := []ZSetMember{}
+	err := s.view(func(txn *storeTxn) error {
+		prefix := sortedSetIndexPrefix(zSetName)
 		opts := badger.DefaultIteratorOptions
-		prefix := keyBadgerGet(prefixKeySortedSetBytes, []byte(zSetName+sortedSetIndex))
 		opts.Prefix = prefix
 		opts.PrefetchValues = false
-
+		opts.Reverse = true
 		it := txn.NewIterator(opts)
 		defer it.Close()
 
-		rank = 0
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			item := it.Item()
-			key := item.Key()
-
-			keyParts := bytes.Split(key[len(prefixKeySortedSetBytes):], []byte(":"))
-			if len(keyParts) != 5 {
+		// 分数为 maxScore 的索引键都以 <prefix><maxScore> 开头，从它们之后开始反向遍历
+		seek := append(append(bytes.Clone(prefix), encodeScore(maxScore)...), 0xFF)
+		skipped := 0
+		for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+			m, ok := parseSortedSetIndexKey(prefix, it.Item().Key())
+			if !ok {
 				continue
 			}
-			scoreBytes := keyParts[2]
-			memberName := string(keyParts[3])
-			if len(scoreBytes) != 8 {
+			if m.Score > maxScore || (maxExclusive && m.Score == maxScore) {
 				continue
 			}
-			memberScore := decodeScore(scoreBytes)
-
-			if memberScore < score || (memberScore == score && memberName < member) {
-				rank++
-			} else if memberScore == score && memberName == member {
-				return nil
-			} else {
+			if m.Score < minScore || (minExclusive && m.Score == minScore) {
+				break
+			}
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			if count > 0 && len(results) >= count {
 				break
 			}
+			results = append(results, m)
 		}
-		rank = -1 // 未找到
 		return nil
 	})
-	return rank, err
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
 }
 
-// ZRevRank 实现 Redis ZREVRANK 命令，返回成员的排名（从0开始，分数从大到小）
-func (s *BotreonStore) ZRevRank(zSetName, member string) (int64, error) {
-	var rank int64 = -1
-	err := s.db.View(func(txn *badger.Txn) error {
-		// 检查成员是否存在
-		dataKey := sortedSetKeyMember(zSetName, member)
-		_, err := txn.Get(dataKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return nil
-		}
+// ZRemRangeByRank 实现 Redis ZREMRANGEBYRANK 命令，移除有序集中指定排名区间的所有成员
+func (s *BotreonStore) ZRemRangeByRank(zSetName string, start, stop int64) (int64, error) {
+	removed, err := s.zremRange(zSetName, func(txn *storeTxn) ([]ZSetMember, error) {
+		meta, err := sortedSetMetaTxn(txn, zSetName)
 		if err != nil {
-			return err
+			return nil, err
 		}
-
-		// 获取总数
-		metaKey := sortedSetKeyMeta(zSetName)
-		var totalCount int64
-		metaItem, err := txn.Get(metaKey)
-		if err == nil {
-			err = metaItem.Value(func(val []byte) error {
-				meta, err := decodeMeta(val)
-				if err != nil {
-					return err
-				}
-				totalCount = meta.Card
-				return nil
-			})
-			if err != nil {
-				return err
-			}
+		start, stop, ok := normalizeZSetRange(start, stop, meta.Card)
+		if !ok {
+			return nil, nil
 		}
-
-		// 计算正向排名，然后转换为反向排名
-		forwardRank, err := s.ZRank(zSetName, member)
-		if err != nil {
-			return err
+		startKey, err := newZSetRankIndex(txn, zSetName).keyAt(start)
+		if err != nil || startKey == nil {
+			return nil, err
 		}
-		if forwardRank == -1 {
-			rank = -1
-			return nil
+		prefix := sortedSetIndexPrefix(zSetName)
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		var members []ZSetMember
+		for it.Seek(startKey); it.ValidForPrefix(prefix) && int64(len(members)) <= stop-start; it.Next() {
+			if m, ok := parseSortedSetIndexKey(prefix, it.Item().Key()); ok {
+				members = append(members, m)
+			}
 		}
-		rank = totalCount - 1 - forwardRank
-		return nil
+		return members, nil
 	})
-	return rank, err
-}
-
-// ZRevRange 实现 Redis ZREVRANGE 命令，返回有序集中指定区间内的成员，通过索引，分数从高到低
-func (s *BotreonStore) ZRevRange(zSetName string, start, stop int64) ([]*ZSetMember, error) {
-	// 先获取正向范围
-	forwardResults, err := s.ZRange(zSetName, 0, -1)
-	if err != nil {
-		return nil, err
-	}
-
-	// 反转结果
-	totalCount := int64(len(forwardResults))
-	if totalCount == 0 {
-		return []*ZSetMember{}, nil
-	}
-
-	// 处理负索引
-	if start < 0 {
-		start = totalCount + start
-	}
-	if stop < 0 {
-		stop = totalCount + stop
-	}
-	if start < 0 {
-		start = 0
-	}
-	if stop >= totalCount {
-		stop = totalCount - 1
-	}
-	if start > stop {
-		return []*ZSetMember{}, nil
-	}
-
-	// 反转并提取范围
-	reversed := make([]*ZSetMember, totalCount)
-	for i := int64(0); i < totalCount; i++ {
-		reversed[i] = forwardResults[totalCount-1-i]
-	}
-
-	return reversed[start : stop+1], nil
+	return int64(len(removed)), err
 }
 
-// ZRevRangeByScore 实现 Redis ZREVRANGEBYSCORE 命令，返回有序集中指定分数区间内的成员，分数从高到低排序
-func (s *BotreonStore) ZRevRangeByScore(zSetName string, maxScore, minScore float64, offset, count int, minExclusive, maxExclusive bool) ([]ZSetMember, error) {
-	// 先获取正向范围
-	forwardResults, err := s.ZRangeByScore(zSetName, minScore, maxScore, 0, 0, minExclusive, maxExclusive)
-	if err != nil {
-		return nil, err
-	}
-
-	// 反转结果
-	var results []ZSetMember
-	for i := len(forwardResults) - 1; i >= 0; i-- {
-		results = append(results, forwardResults[i])
-	}
-
-	// 应用offset和count
-	if offset > 0 && offset < len(results) {
-		results = results[offset:]
-	} else if offset >= len(results) {
-		results = []ZSetMember{}
-	}
-
-	if count > 0 && count < len(results) {
-		results = results[:count]
-	}
-
-	return results, nil
-}
-
-// ZRemRangeByRank 实现 Redis ZREMRANGEBYRANK 命令，移除有序集中指定排名区间的所有成员
-func (s *BotreonStore) ZRemRangeByRank(zSetName string, start, stop int64) (int64, error) {
-	// 先获取范围内的成员
-	members, err := s.ZRange(zSetName, start, stop)
-	if err != nil {
-		return 0, err
-	}
-
-	// 删除每个成员
-	var removed int64
-	for _, member := range members {
-		if err := s.ZRem(zSetName, member.Member); err != nil {
-			return removed, err
+// ZRemRangeByScore 实现 Redis ZREMRANGEBYSCORE 命令，移除有序集中指定分数区间的所有成员
+func (s *BotreonStore) ZRemRangeByScore(zSetName string, minScore, maxScore float64, minExclusive, maxExclusive bool) (int64, error) {
+	removed, err := s.zremRange(zSetName, func(txn *storeTxn) ([]ZSetMember, error) {
+		prefix := sortedSetIndexPrefix(zSetName)
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		var members []ZSetMember
+		for it.Seek(append(bytes.Clone(prefix), encodeScore(minScore)...)); it.ValidForPrefix(prefix); it.Next() {
+			m, ok := parseSortedSetIndexKey(prefix, it.Item().Key())
+			if !ok || (minExclusive && m.Score == minScore) {
+				continue
+			}
+			if m.Score > maxScore || (maxExclusive && m.Score == maxScore) {
+				break
+			}
+			members = append(members, m)
 		}
-		removed++
-	}
-	return removed, nil
+		return members, nil
+	})
+	return int64(len(removed)), err
 }
 
-// ZRemRangeByScore 实现 Redis ZREMRANGEBYSCORE 命令，移除有序集中指定分数区间的所有成员
-func (s *BotreonStore) ZRemRangeByScore(zSetName string, minScore, maxScore float64, minExclusive, maxExclusive bool) (int64, error) {
-	// 先获取范围内的成员
-	members, err := s.ZRangeByScore(zSetName, minScore, maxScore, 0, 0, minExclusive, maxExclusive)
+// zremRange 在一个事务中删除 collect 选出的成员，按 collect 的顺序返回删除的成员
+func (s *BotreonStore) zremRange(zSetName string, collect func(txn *storeTxn) ([]ZSetMember, error)) ([]ZSetMember, error) {
+	var removed []ZSetMember
+	err := s.retryUpdateSortedSet(func(txn *storeTxn) error {
+		removed = nil
+		members, err := collect(txn)
+		if err != nil || len(members) == 0 {
+			return err
+		}
+		meta, err := sortedSetMetaTxn(txn, zSetName)
+		if err != nil {
+			return err
+		}
+		for _, m := range members {
+			if err := txn.Delete(sortedSetKeyMember(zSetName, m.Member)); err != nil {
+				return err
+			}
+			if err := deleteSortedSetIndex(txn, zSetName, m.Score, m.Member); err != nil {
+				return err
+			}
+		}
+		removed = members
+		meta.Card -= int64(len(members))
+		if meta.Card <= 0 {
+			return txn.Delete(sortedSetKeyMeta(zSetName))
+		}
+		return txn.Set(sortedSetKeyMeta(zSetName), encodeMeta(meta))
+	}, 20)
 	if err != nil {
-		return 0, err
+		logger.Logger.Error().Err(err).Str("zset_name", zSetName).Msg("ZRemRange: Failed to remove members")
+		return nil, err
 	}
-
-	// 删除每个成员
-	var removed int64
-	for _, member := range members {
-		if err := s.ZRem(zSetName, member.Member); err != nil {
-			return removed, err
+	if len(removed) > 0 {
+		names := make([]string, len(removed))
+		for i, m := range removed {
+			names[i] = m.Member
 		}
-		removed++
+		s.notifyZWatch(zSetName, nil, names, false)
 	}
 	return removed, nil
 }
 
 // ZPopMax 实现 Redis ZPOPMAX 命令，移除并返回有序集合中分数最高的成员
 func (s *BotreonStore) ZPopMax(zSetName string, count int) ([]ZSetMember, error) {
-	// 先获取最后count个成员（分数最高的）
-	members, err := s.ZRevRange(zSetName, 0, int64(count-1))
-	if err != nil {
-		return nil, err
-	}
-
-	// 删除并返回
-	var results []ZSetMember
-	for _, member := range members {
-		if err := s.ZRem(zSetName, member.Member); err != nil {
-			return results, err
-		}
-		results = append(results, ZSetMember{Member: member.Member, Score: member.Score})
-	}
-	return results, nil
+	return s.zpop(zSetName, count, true)
 }
 
 // ZPopMin 实现 Redis ZPOPMIN 命令，移除并返回有序集合中分数最低的成员
 func (s *BotreonStore) ZPopMin(zSetName string, count int) ([]ZSetMember, error) {
-	// 先获取前count个成员（分数最低的）
-	members, err := s.ZRange(zSetName, 0, int64(count-1))
-	if err != nil {
-		return nil, err
-	}
+	return s.zpop(zSetName, count, false)
+}
 
-	// 删除并返回
-	var results []ZSetMember
-	for _, member := range members {
-		if err := s.ZRem(zSetName, member.Member); err != nil {
-			return results, err
-		}
-		results = append(results, ZSetMember{Member: member.Member, Score: member.Score})
+// zpop 在一个事务中弹出至多 count 个分数最低（max 时最高）的成员，按弹出顺序返回
+func (s *BotreonStore) zpop(zSetName string, count int, max bool) ([]ZSetMember, error) {
+	if count <= 0 {
+		return nil, nil
 	}
-	return results, nil
+	return s.zremRange(zSetName, func(txn *storeTxn) ([]ZSetMember, error) {
+		prefix := sortedSetIndexPrefix(zSetName)
+		opts := s.iteratorOptions(prefix, int64(count), false)
+		opts.Reverse = max
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		seek := prefix
+		if max {
+			seek = append(bytes.Clone(prefix), 0xFF)
+		}
+		var members []ZSetMember
+		for it.Seek(seek); it.ValidForPrefix(prefix) && len(members) < count; it.Next() {
+			if m, ok := parseSortedSetIndexKey(prefix, it.Item().Key()); ok {
+				members = append(members, m)
+			}
+		}
+		return members, nil
+	})
 }
 
-// ZUnionStore 实现 Redis ZUNIONSTORE 命令，计算并集并存储到目标集合
+// ZUnionStore 实现 Redis ZUNIONSTORE 命令，在一个事务中计算并集并替换目标集合
 func (s *BotreonStore) ZUnionStore(destination string, keys []string, weights []float64, aggregate string) (int64, error) {
+	return s.zstore(destination, func(txn *storeTxn) ([]ZSetMember, error) {
+		return zunionTxn(txn, keys, weights, aggregate)
+	})
+}
+
+// zunionTxn 在 txn 中计算多个有序集合的并集
+func zunionTxn(txn *storeTxn, keys []string, weights []float64, aggregate string) ([]ZSetMember, error) {
 	// 先收集所有成员的分数（考虑权重和聚合方式）
 	memberScores := make(map[string]float64)
 
@@ -966,9 +1007,9 @@ func (s *BotreonStore) ZUnionStore(destination string, keys []string, weights []
 		}
 
 		// 获取所有成员
-		members, err := s.ZRange(key, 0, -1)
+		members, err := zsetMembersTxn(txn, key)
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
 
 		for _, member := range members {
@@ -994,37 +1035,26 @@ func (s *BotreonStore) ZUnionStore(destination string, keys []string, weights []
 			}
 		}
 	}
-
-	// 删除目标集合的现有数据
-	_ = s.ZSetDel(destination)
-
-	// 添加所有成员到目标集合
-	zsetMembers := make([]ZSetMember, 0, len(memberScores))
-	for member, score := range memberScores {
-		zsetMembers = append(zsetMembers, ZSetMember{Member: member, Score: score})
-	}
-
-	if len(zsetMembers) > 0 {
-		if err := s.ZAdd(destination, zsetMembers); err != nil {
-			return 0, err
-		}
-	}
-
-	return int64(len(memberScores)), nil
+	return zsetMembersOf(memberScores), nil
 }
 
-// ZInterStore 实现 Redis ZINTERSTORE 命令，计算交集并存储到目标集合
+// ZInterStore 实现 Redis ZINTERSTORE 命令，在一个事务中计算交集并替换目标集合
 func (s *BotreonStore) ZInterStore(destination string, keys []string, weights []float64, aggregate string) (int64, error) {
+	return s.zstore(destination, func(txn *storeTxn) ([]ZSetMember, error) {
+		return zinterTxn(txn, keys, weights, aggregate)
+	})
+}
+
+// zinterTxn 在 txn 中计算多个有序集合的交集，没有键时结果为空
+func zinterTxn(txn *storeTxn, keys []string, weights []float64, aggregate string) ([]ZSetMember, error) {
 	if len(keys) == 0 {
-		// 删除目标集合
-		_ = s.ZSetDel(destination)
-		return 0, nil
+		return nil, nil
 	}
 
 	// 获取第一个集合的所有成员
-	firstMembers, err := s.ZRange(keys[0], 0, -1)
+	firstMembers, err := zsetMembersTxn(txn, keys[0])
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	// 收集所有成员的分数（考虑权重和聚合方式）
@@ -1046,9 +1076,9 @@ func (s *BotreonStore) ZInterStore(destination string, keys []string, weights []
 			weight = weights[i]
 		}
 
-		otherMembers, err := s.ZRange(keys[i], 0, -1)
+		otherMembers, err := zsetMembersTxn(txn, keys[i])
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
 
 		otherMemberMap := make(map[string]float64)
@@ -1078,45 +1108,34 @@ func (s *BotreonStore) ZInterStore(destination string, keys []string, weights []
 			}
 		}
 	}
-
-	// 删除目标集合的现有数据
-	_ = s.ZSetDel(destination)
-
-	// 添加所有成员到目标集合
-	zsetMembers := make([]ZSetMember, 0, len(memberScores))
-	for member, score := range memberScores {
-		zsetMembers = append(zsetMembers, ZSetMember{Member: member, Score: score})
-	}
-
-	if len(zsetMembers) > 0 {
-		if err := s.ZAdd(destination, zsetMembers); err != nil {
-			return 0, err
-		}
-	}
-
-	return int64(len(memberScores)), nil
+	return zsetMembersOf(memberScores), nil
 }
 
-// ZDiffStore 实现 Redis ZDIFFSTORE 命令，计算差集并存储到目标集合
+// ZDiffStore 实现 Redis ZDIFFSTORE 命令，在一个事务中计算差集并替换目标集合
 func (s *BotreonStore) ZDiffStore(destination string, keys []string) (int64, error) {
+	return s.zstore(destination, func(txn *storeTxn) ([]ZSetMember, error) {
+		return zdiffTxn(txn, keys)
+	})
+}
+
+// zdiffTxn 在 txn 中计算第一个有序集合与其他集合的差集，没有键时结果为空
+func zdiffTxn(txn *storeTxn, keys []string) ([]ZSetMember, error) {
 	if len(keys) == 0 {
-		// 删除目标集合
-		_ = s.ZSetDel(destination)
-		return 0, nil
+		return nil, nil
 	}
 
 	// 获取第一个集合的所有成员
-	firstMembers, err := s.ZRange(keys[0], 0, -1)
+	firstMembers, err := zsetMembersTxn(txn, keys[0])
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	// 构建其他集合的成员集合
 	otherMembers := make(map[string]bool)
 	for i := 1; i < len(keys); i++ {
-		members, err := s.ZRange(keys[i], 0, -1)
+		members, err := zsetMembersTxn(txn, keys[i])
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
 		for _, member := range members {
 			otherMembers[member.Member] = true
@@ -1130,18 +1149,161 @@ func (s *BotreonStore) ZDiffStore(destination string, keys []string) (int64, err
 			zsetMembers = append(zsetMembers, ZSetMember{Member: member.Member, Score: member.Score})
 		}
 	}
+	return zsetMembers, nil
+}
 
-	// 删除目标集合的现有数据
-	_ = s.ZSetDel(destination)
+// ZReplace 用 members 替换 destination 原有的值（可以是任意类型），members 为空时删除 destination。
+// 删除与写入在同一事务中完成，返回写入的成员数
+func (s *BotreonStore) ZReplace(destination string, members []ZSetMember) (int64, error) {
+	return s.zstore(destination, func(*storeTxn) ([]ZSetMember, error) {
+		return members, nil
+	})
+}
 
-	// 添加所有成员到目标集合
-	if len(zsetMembers) > 0 {
-		if err := s.ZAdd(destination, zsetMembers); err != nil {
-			return 0, err
+// zstore 在一个事务中用 compute 的结果替换 destination 原有的值，compute 在同一事务中读取源集合。
+// 并发的读者只会看到 destination 的旧值或新值，不会看到删除之后、写入之前的空集合
+func (s *BotreonStore) zstore(destination string, compute func(txn *storeTxn) ([]ZSetMember, error)) (int64, error) {
+	var members []ZSetMember
+	var existed bool
+	err := s.retryUpdateSortedSet(func(txn *storeTxn) error {
+		var err error
+		members, err = compute(txn)
+		if err != nil {
+			return err
+		}
+		existed, err = s.delTxn(txn, destination)
+		if err != nil || len(members) == 0 {
+			return err
 		}
+		return s.zaddTxn(txn, destination, members)
+	}, 20)
+	if err != nil {
+		logger.Logger.Error().Err(err).Str("destination", destination).Msg("zstore: Failed to store result")
+		return 0, err
+	}
+	if existed {
+		s.notifyZWatch(destination, nil, nil, true)
+	}
+	if len(members) > 0 {
+		s.notifyZWatch(destination, members, nil, false)
+		s.notifyBlockingPop(destination, len(members))
 	}
+	return int64(len(members)), nil
+}
 
-	return int64(len(zsetMembers)), nil
+// zsetMembersTxn 在 txn 中读取有序集合的全部成员，按成员名排序
+func zsetMembersTxn(txn *storeTxn, zSetName string) ([]ZSetMember, error) {
+	dataPrefix := keyBadgerGet(prefixKeySortedSetBytes, []byte(zSetName+sortedSetData))
+	var members []ZSetMember
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = dataPrefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+		member := string(item.Key()[len(dataPrefix):])
+		if err := item.Value(func(val []byte) error {
+			members = append(members, ZSetMember{Member: member, Score: decodeScore(val)})
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return members, nil
+}
+
+// zsetMembersOf 将成员到分数的映射转换为成员列表
+func zsetMembersOf(memberScores map[string]float64) []ZSetMember {
+	members := make([]ZSetMember, 0, len(memberScores))
+	for member, score := range memberScores {
+		members = append(members, ZSetMember{Member: member, Score: score})
+	}
+	return members
+}
+
+// ZMerge 将 source 的成员合并到 destination，同一成员保留较大（useMin 为 true 时较小）的分数。
+// 在一个事务中完成，返回 destination 中新增或分数发生变化的成员数；source 不存在时返回 0
+func (s *BotreonStore) ZMerge(destination, source string, useMin bool) (int64, error) {
+	if destination == source {
+		return 0, nil
+	}
+	var changed []ZSetMember
+	err := s.retryUpdateSortedSet(func(txn *storeTxn) error {
+		changed = changed[:0]
+
+		// 读取 source 的全部成员
+		members, err := zsetMembersTxn(txn, source)
+		if err != nil || len(members) == 0 {
+			return err
+		}
+
+		metaKey := sortedSetKeyMeta(destination)
+		var meta ZSetsMetaValue
+		item, err := txn.Get(metaKey)
+		if err == nil {
+			err = item.Value(func(val []byte) error {
+				meta, err = decodeMeta(val)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		meta.Version++
+		if err := newZSetRankIndex(txn, destination).ensure(); err != nil {
+			return err
+		}
+
+		for _, m := range members {
+			dataKey := sortedSetKeyMember(destination, m.Member)
+			item, err := txn.Get(dataKey)
+			if err == nil {
+				var oldScore float64
+				if err := item.Value(func(val []byte) error {
+					oldScore = decodeScore(val)
+					return nil
+				}); err != nil {
+					return err
+				}
+				if (!useMin && m.Score <= oldScore) || (useMin && m.Score >= oldScore) {
+					continue
+				}
+				if err := deleteSortedSetIndex(txn, destination, oldScore, m.Member); err != nil {
+					return err
+				}
+			} else if errors.Is(err, badger.ErrKeyNotFound) {
+				meta.Card++
+			} else {
+				return err
+			}
+			if err := txn.Set(dataKey, encodeScore(m.Score)); err != nil {
+				return err
+			}
+			if err := setSortedSetIndex(txn, destination, sortedSetKeyIndex(destination, m.Score, m.Member, meta.Version)); err != nil {
+				return err
+			}
+			changed = append(changed, m)
+		}
+		if len(changed) == 0 {
+			return nil
+		}
+
+		if err := txn.Set(TypeOfKeyGet(destination), []byte(KeyTypeSortedSet)); err != nil {
+			return err
+		}
+		return txn.Set(metaKey, encodeMeta(meta))
+	}, 20)
+	if err != nil {
+		logger.Logger.Error().Err(err).Str("destination", destination).Str("source", source).Msg("ZMerge: Failed to merge")
+		return 0, err
+	}
+	if len(changed) > 0 {
+		s.notifyZWatch(destination, changed, nil, false)
+		s.notifyBlockingPop(destination, len(changed))
+	}
+	return int64(len(changed)), nil
 }
 
 // ZLexCount 实现 Redis ZLEXCOUNT 命令，计算有序集合中成员值介于min和max之间的成员数量（字典序）
@@ -1312,7 +1474,7 @@ func (s *BotreonStore) ZRevRangeByLex(zSetName, max, min string, offset, count i
 // ZRemRangeByLex 实现 Redis ZREMRANGEBYLEX 命令，移除有序集合中成员值介于min和max之间的成员（字典序）
 func (s *BotreonStore) ZRemRangeByLex(zSetName, min, max string) (int64, error) {
 	var removed int64
-	err := s.retryUpdateSortedSet(func(txn *badger.Txn) error {
+	err := s.retryUpdateSortedSet(func(txn *storeTxn) error {
 		// 获取范围内的成员
 		members, err := s.ZRangeByLex(zSetName, min, max, 0, 0)
 		if err != nil {
@@ -1348,105 +1510,76 @@ func (s *BotreonStore) ZMScore(zSetName string, members ...string) ([]float64, e
 	return scores, nil
 }
 
-// BZPopMax 实现 Redis BZPOPMAX 命令，阻塞式弹出分数最高的成员（简化版本：非阻塞）
-func (s *BotreonStore) BZPopMax(keys []string, timeout int) (string, *ZSetMember, error) {
-	for _, key := range keys {
-		members, err := s.ZPopMax(key, 1)
-		if err != nil {
-			return "", nil, err
-		}
-		if len(members) > 0 {
-			return key, &members[0], nil
-		}
+// BZPopMax 实现 Redis BZPOPMAX 命令，从第一个非空的有序集合弹出分数最高的成员。
+// 所有键都为空时阻塞至多 timeout 秒，等待 ZADD 写入；timeout 为 0 时不阻塞。超时返回空键名
+func (s *BotreonStore) BZPopMax(keys []string, timeout float64) (string, *ZSetMember, error) {
+	return s.bzpop(keys, timeout, true)
+}
+
+// BZPopMin 实现 Redis BZPOPMIN 命令，从第一个非空的有序集合弹出分数最低的成员，阻塞规则同 BZPopMax
+func (s *BotreonStore) BZPopMin(keys []string, timeout float64) (string, *ZSetMember, error) {
+	return s.bzpop(keys, timeout, false)
+}
+
+func (s *BotreonStore) bzpop(keys []string, timeout float64, max bool) (string, *ZSetMember, error) {
+	key, members, err := s.BZMPop(keys, max, 1, timeout)
+	if err != nil || len(members) == 0 {
+		return "", nil, err
 	}
-	return "", nil, nil
+	return key, &members[0], nil
 }
 
-// BZPopMin 实现 Redis BZPOPMIN 命令，阻塞式弹出分数最低的成员（简化版本：非阻塞）
-func (s *BotreonStore) BZPopMin(keys []string, timeout int) (string, *ZSetMember, error) {
+// ZMPop 实现 Redis ZMPOP 命令，依次检查 keys，从第一个非空的有序集合弹出至多 count 个
+// 分数最低（max 时最高）的成员。所有键都为空时返回空键名
+func (s *BotreonStore) ZMPop(keys []string, max bool, count int) (string, []ZSetMember, error) {
 	for _, key := range keys {
-		members, err := s.ZPopMin(key, 1)
+		members, err := s.zpop(key, count, max)
 		if err != nil {
 			return "", nil, err
 		}
 		if len(members) > 0 {
-			return key, &members[0], nil
+			return key, members, nil
 		}
 	}
 	return "", nil, nil
 }
 
+// BZMPop 实现 Redis BZMPOP 命令，与 ZMPop 相同，所有键都为空时阻塞至多 timeout 秒，
+// 被 ZADD/ZINCRBY 唤醒后重新弹出；timeout 为 0 时不阻塞。超时返回空键名
+func (s *BotreonStore) BZMPop(keys []string, max bool, count int, timeout float64) (string, []ZSetMember, error) {
+	key, members, err := s.ZMPop(keys, max, count)
+	if err != nil || len(members) > 0 || timeout <= 0 {
+		return key, members, err
+	}
+	_, err = s.waitBlocking(keys, time.Duration(timeout*float64(time.Second)), func() (bool, error) {
+		var err error
+		key, members, err = s.ZMPop(keys, max, count)
+		return len(members) > 0, err
+	})
+	return key, members, err
+}
+
 // ZScanResult 定义 ZSCAN 命令的返回结果
 type ZScanResult struct {
 	Cursor  uint64
 	Members []ZSetMember
 }
 
-// ZScan 实现 Redis ZSCAN 命令，增量迭代有序集合的成员
+// ZScan 实现 Redis ZSCAN 命令，增量迭代有序集合的成员，游标语义见 scanPrefix。
+// 按成员遍历数据键（zset:zSetName:data:member，值为分数），成员中的 ':' 不影响解析
 func (s *BotreonStore) ZScan(zSetName string, cursor uint64, pattern string, count int) (ZScanResult, error) {
-	var result ZScanResult
-	result.Cursor = 0
-	result.Members = []ZSetMember{}
-
-	if count <= 0 {
-		count = 10 // 默认值
-	}
-
-	err := s.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		prefix := keyBadgerGet(prefixKeySortedSetBytes, []byte(zSetName+sortedSetIndex))
-		opts.Prefix = prefix
-		opts.PrefetchValues = false
-
-		iter := txn.NewIterator(opts)
-		defer iter.Close()
-
-		currentPos := uint64(0)
-		collected := 0
-
-		// 如果cursor不为0，需要跳过前面的成员
-		if cursor > 0 {
-			for iter.Seek(prefix); iter.ValidForPrefix(prefix) && currentPos < cursor; iter.Next() {
-				currentPos++
-			}
-		} else {
-			iter.Seek(prefix)
-		}
-
-		// 收集匹配的成员
-		for iter.ValidForPrefix(prefix) && collected < count {
-			item := iter.Item()
-			key := item.Key()
-
-			// 解析键格式: zset:zSetName:index:scoreBytes:member:versionBytes
-			keyWithoutPrefix := key[len(prefixKeySortedSetBytes):]
-			keyParts := bytes.Split(keyWithoutPrefix, []byte(":"))
-			if len(keyParts) >= 4 {
-				scoreBytes := keyParts[2]
-				member := string(keyParts[3])
-
-				if len(scoreBytes) == 8 {
-					score := decodeScore(scoreBytes)
-
-					if pattern == "" || pattern == "*" || matchPattern(member, pattern) {
-						result.Members = append(result.Members, ZSetMember{Member: member, Score: score})
-						collected++
-					}
-				}
-			}
-
-			currentPos++
-			iter.Next()
-		}
-
-		// 检查是否还有更多成员
-		if iter.ValidForPrefix(prefix) {
-			result.Cursor = currentPos
-		} else {
-			result.Cursor = 0 // 0表示迭代完成
+	result := ZScanResult{Members: []ZSetMember{}}
+	prefix := keyBadgerGet(prefixKeySortedSetBytes, []byte(zSetName+sortedSetData))
+	next, err := s.scanPrefix("zscan:"+zSetName, prefix, cursor, count, func(item *badger.Item) error {
+		member := string(item.Key()[len(prefix):])
+		if pattern != "" && pattern != "*" && !matchPattern(member, pattern) {
+			return nil
 		}
-
-		return nil
+		return item.Value(func(val []byte) error {
+			result.Members = append(result.Members, ZSetMember{Member: member, Score: decodeScore(val)})
+			return nil
+		})
 	})
+	result.Cursor = next
 	return result, err
 }
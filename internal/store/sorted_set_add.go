@@ -0,0 +1,99 @@
+package store
+
+import (
+	"errors"
+	"math"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// ErrZSetScoreNaN ZADD INCR 或 ZINCRBY 的结果不是数字，文本与 Redis 一致
+var ErrZSetScoreNaN = errors.New("ERR resulting score is not a number (NaN)")
+
+// ZAddOptions ZADD 命令的选项
+type ZAddOptions struct {
+	NX   bool // 只添加新成员，不更新已有成员
+	XX   bool // 只更新已有成员，不添加新成员
+	GT   bool // 已有成员只在新分数更大时更新，不影响新增成员
+	LT   bool // 已有成员只在新分数更小时更新，不影响新增成员
+	CH   bool // 返回值包括分数发生变化的已有成员
+	Incr bool // 分数作为增量加到原分数上，与 ZINCRBY 相同
+}
+
+// ZAddResult ZADD 命令的结果
+type ZAddResult struct {
+	Count   int64   // 新增的成员数，CH 时加上分数发生变化的已有成员数
+	Score   float64 // Incr 时成员的新分数
+	Applied bool    // Incr 时 NX/XX/GT/LT 条件满足、分数已写入
+}
+
+// ZAddWithOptions 实现带选项的 Redis ZADD 命令，条件检查与写入在同一事务中完成。
+// 同一成员出现多次时按顺序处理，后面的分数覆盖前面的
+func (s *BotreonStore) ZAddWithOptions(zSetName string, members []ZSetMember, opts ZAddOptions) (ZAddResult, error) {
+	var result ZAddResult
+	var written []ZSetMember
+	err := s.retryUpdateSortedSet(func(txn *storeTxn) error {
+		result = ZAddResult{}
+		written = written[:0]
+		scores := make(map[string]float64, len(members))
+		positions := make(map[string]int, len(members))
+		for _, m := range members {
+			old, exists := scores[m.Member]
+			if !exists {
+				item, err := txn.Get(sortedSetKeyMember(zSetName, m.Member))
+				if err == nil {
+					exists = true
+					if err := item.Value(func(val []byte) error {
+						old = decodeScore(val)
+						return nil
+					}); err != nil {
+						return err
+					}
+				} else if !errors.Is(err, badger.ErrKeyNotFound) {
+					return err
+				}
+			}
+			if (opts.NX && exists) || (opts.XX && !exists) {
+				continue
+			}
+			score := m.Score
+			if opts.Incr {
+				score += old
+				if math.IsNaN(score) {
+					return ErrZSetScoreNaN
+				}
+			}
+			if exists && ((opts.GT && score <= old) || (opts.LT && score >= old)) {
+				continue
+			}
+			result.Score, result.Applied = score, true
+			switch {
+			case !exists:
+				result.Count++
+			case score == old:
+				continue
+			case opts.CH:
+				result.Count++
+			}
+			scores[m.Member] = score
+			if i, ok := positions[m.Member]; ok {
+				written[i].Score = score
+			} else {
+				positions[m.Member] = len(written)
+				written = append(written, ZSetMember{Member: m.Member, Score: score})
+			}
+		}
+		if len(written) == 0 {
+			return nil
+		}
+		return s.zaddTxn(txn, zSetName, written)
+	}, 20)
+	if err != nil {
+		return ZAddResult{}, err
+	}
+	if len(written) > 0 {
+		s.notifyZWatch(zSetName, written, nil, false)
+		s.notifyBlockingPop(zSetName, len(written))
+	}
+	return result, nil
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"math"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestZAddWithOptions(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	// 同一成员出现多次时按顺序处理，只计一次新增
+	result, err := store.ZAddWithOptions("z", []ZSetMember{{Member: "a", Score: 1}, {Member: "a", Score: 3}, {Member: "b", Score: 2}}, ZAddOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result.Count)
+	score, _, _ := store.ZScore("z", "a")
+	assert.Equal(t, 3.0, score)
+	card, _ := store.ZCard("z")
+	assert.Equal(t, int64(2), card)
+
+	result, err = store.ZAddWithOptions("z", []ZSetMember{{Member: "a", Score: 5}, {Member: "b", Score: 1}, {Member: "c", Score: 0}}, ZAddOptions{GT: true, CH: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result.Count)
+	members, err := store.ZRange("z", 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []*ZSetMember{{Member: "c", Score: 0}, {Member: "b", Score: 2}, {Member: "a", Score: 5}}, members)
+
+	result, err = store.ZAddWithOptions("z", []ZSetMember{{Member: "b", Score: 1.5}}, ZAddOptions{Incr: true, XX: true})
+	assert.NoError(t, err)
+	assert.True(t, result.Applied)
+	assert.Equal(t, 3.5, result.Score)
+	result, err = store.ZAddWithOptions("z", []ZSetMember{{Member: "b", Score: -1}}, ZAddOptions{Incr: true, GT: true})
+	assert.NoError(t, err)
+	assert.False(t, result.Applied)
+	rank, _ := store.ZRank("z", "b")
+	assert.Equal(t, int64(1), rank)
+
+	_, err = store.ZAddWithOptions("inf", []ZSetMember{{Member: "a", Score: math.Inf(1)}}, ZAddOptions{})
+	assert.NoError(t, err)
+	_, err = store.ZAddWithOptions("inf", []ZSetMember{{Member: "a", Score: math.Inf(-1)}}, ZAddOptions{Incr: true})
+	assert.Equal(t, ErrZSetScoreNaN, err)
+	_, err = store.ZIncrBy("inf", "a", math.Inf(-1))
+	assert.Equal(t, ErrZSetScoreNaN, err)
+}
@@ -0,0 +1,313 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// 有序集合的排名索引把按分数排序的索引键分成连续的块，每块一个键记录块的起点与成员数：
+//
+//	zset:<key>:rank:<块中第一个索引键去掉 zset:<key>:index: 后的部分> -> 成员数（8 字节）
+//
+// 第一块的起点为空，覆盖排在最前面的索引键。ZRANK 累加目标之前各块的成员数，只在目标所在的块内扫描索引；
+// ZRANGE/ZREVRANGE 按块跳过 start 之前的成员。索引键增删时同步调整所在块的计数，
+// 块超过 2 倍块大小时拆分，不足 1/4 时与后一块合并。
+// 升级前写入或只由 GEOADD 写入的集合没有排名索引，下次 ZADD/ZINCRBY 时建立，建立之前按索引顺序扫描
+const (
+	sortedSetRank     = ":rank:"
+	zsetRankBlockSize = 128
+)
+
+func sortedSetIndexPrefix(zSetName string) []byte {
+	return keyBadgerGet(prefixKeySortedSetBytes, []byte(zSetName+sortedSetIndex))
+}
+
+func sortedSetRankPrefix(zSetName string) []byte {
+	return keyBadgerGet(prefixKeySortedSetBytes, []byte(zSetName+sortedSetRank))
+}
+
+// zsetRankIndex 在 txn 中读写一个有序集合的排名索引
+type zsetRankIndex struct {
+	txn         *storeTxn
+	indexPrefix []byte
+	rankPrefix  []byte
+}
+
+// zsetRankBlock 排名索引中的一块，start 为块起点的索引键后缀
+type zsetRankBlock struct {
+	start []byte
+	count int64
+}
+
+func newZSetRankIndex(txn *storeTxn, zSetName string) *zsetRankIndex {
+	return &zsetRankIndex{
+		txn:         txn,
+		indexPrefix: sortedSetIndexPrefix(zSetName),
+		rankPrefix:  sortedSetRankPrefix(zSetName),
+	}
+}
+
+// exists 是否已建立排名索引：建立后第一块一直存在，直到集合为空
+func (r *zsetRankIndex) exists() (bool, error) {
+	_, err := r.txn.Get(r.rankPrefix)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ensure 排名索引不存在时按现有的索引键建立
+func (r *zsetRankIndex) ensure() error {
+	ok, err := r.exists()
+	if err != nil || ok {
+		return err
+	}
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = r.indexPrefix
+	opts.PrefetchValues = false
+	it := r.txn.NewIterator(opts)
+	blocks := []zsetRankBlock{{start: []byte{}}}
+	for it.Seek(r.indexPrefix); it.ValidForPrefix(r.indexPrefix); it.Next() {
+		last := &blocks[len(blocks)-1]
+		if last.count == zsetRankBlockSize {
+			blocks = append(blocks, zsetRankBlock{start: bytes.Clone(it.Item().Key()[len(r.indexPrefix):])})
+			last = &blocks[len(blocks)-1]
+		}
+		last.count++
+	}
+	it.Close()
+	for _, b := range blocks {
+		if err := r.setBlock(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *zsetRankIndex) blockKey(start []byte) []byte {
+	return append(bytes.Clone(r.rankPrefix), start...)
+}
+
+func (r *zsetRankIndex) setBlock(b zsetRankBlock) error {
+	val := make([]byte, 8)
+	// #nosec G115 - 块内成员数为正数
+	binary.BigEndian.PutUint64(val, uint64(b.count))
+	return r.txn.Set(r.blockKey(b.start), val)
+}
+
+func (r *zsetRankIndex) readBlock(item *badger.Item) (zsetRankBlock, error) {
+	b := zsetRankBlock{start: bytes.Clone(item.Key()[len(r.rankPrefix):])}
+	err := item.Value(func(val []byte) error {
+		if len(val) != 8 {
+			return errors.New("invalid zset rank block")
+		}
+		// #nosec G115 - 块内成员数不超过集合大小
+		b.count = int64(binary.BigEndian.Uint64(val))
+		return nil
+	})
+	return b, err
+}
+
+// blockOf 返回包含索引键后缀 suffix 的块，即起点不大于 suffix 的最后一块
+func (r *zsetRankIndex) blockOf(suffix []byte) (zsetRankBlock, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = r.rankPrefix
+	opts.Reverse = true
+	it := r.txn.NewIterator(opts)
+	defer it.Close()
+	it.Seek(r.blockKey(suffix))
+	if !it.ValidForPrefix(r.rankPrefix) {
+		return zsetRankBlock{}, errors.New("zset rank index has no first block")
+	}
+	return r.readBlock(it.Item())
+}
+
+// nextBlock 返回 b 之后的一块，ok 为 false 表示 b 是最后一块
+func (r *zsetRankIndex) nextBlock(b zsetRankBlock) (zsetRankBlock, bool, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = r.rankPrefix
+	it := r.txn.NewIterator(opts)
+	defer it.Close()
+	it.Seek(append(r.blockKey(b.start), 0))
+	if !it.ValidForPrefix(r.rankPrefix) {
+		return zsetRankBlock{}, false, nil
+	}
+	next, err := r.readBlock(it.Item())
+	return next, err == nil, err
+}
+
+// insert 记录新写入的索引键。没有排名索引时不做任何事
+func (r *zsetRankIndex) insert(indexKey []byte) error {
+	if ok, err := r.exists(); err != nil || !ok {
+		return err
+	}
+	b, err := r.blockOf(indexKey[len(r.indexPrefix):])
+	if err != nil {
+		return err
+	}
+	b.count++
+	if b.count <= 2*zsetRankBlockSize {
+		return r.setBlock(b)
+	}
+
+	// 拆分：前 zsetRankBlockSize 个成员留在原块，其余成为新的一块
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = r.indexPrefix
+	opts.PrefetchValues = false
+	it := r.txn.NewIterator(opts)
+	var n int64
+	var start []byte
+	for it.Seek(append(bytes.Clone(r.indexPrefix), b.start...)); it.ValidForPrefix(r.indexPrefix); it.Next() {
+		if n == zsetRankBlockSize {
+			start = bytes.Clone(it.Item().Key()[len(r.indexPrefix):])
+			break
+		}
+		n++
+	}
+	it.Close()
+	if start == nil {
+		return r.setBlock(b)
+	}
+	if err := r.setBlock(zsetRankBlock{start: start, count: b.count - zsetRankBlockSize}); err != nil {
+		return err
+	}
+	b.count = zsetRankBlockSize
+	return r.setBlock(b)
+}
+
+// remove 记录删除的索引键。没有排名索引时不做任何事
+func (r *zsetRankIndex) remove(indexKey []byte) error {
+	if ok, err := r.exists(); err != nil || !ok {
+		return err
+	}
+	b, err := r.blockOf(indexKey[len(r.indexPrefix):])
+	if err != nil {
+		return err
+	}
+	b.count--
+	if b.count < zsetRankBlockSize/4 {
+		next, ok, err := r.nextBlock(b)
+		if err != nil {
+			return err
+		}
+		if ok && b.count+next.count <= 2*zsetRankBlockSize {
+			if err := r.txn.Delete(r.blockKey(next.start)); err != nil {
+				return err
+			}
+			b.count += next.count
+		}
+	}
+	if b.count <= 0 {
+		// 变空的块直接删除。第一块总能与后一块合并，只有集合为空时才会变空，排名索引随之删除
+		return r.txn.Delete(r.blockKey(b.start))
+	}
+	return r.setBlock(b)
+}
+
+// rankOf 返回排在索引键后缀 suffix 之前的索引键数量
+func (r *zsetRankIndex) rankOf(suffix []byte) (int64, error) {
+	ok, err := r.exists()
+	if err != nil {
+		return 0, err
+	}
+	var rank int64
+	start := []byte{}
+	if ok {
+		b, err := r.blockOf(suffix)
+		if err != nil {
+			return 0, err
+		}
+		start = b.start
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = r.rankPrefix
+		it := r.txn.NewIterator(opts)
+		for it.Seek(r.rankPrefix); it.ValidForPrefix(r.rankPrefix); it.Next() {
+			if bytes.Equal(it.Item().Key()[len(r.rankPrefix):], start) {
+				break
+			}
+			before, err := r.readBlock(it.Item())
+			if err != nil {
+				it.Close()
+				return 0, err
+			}
+			rank += before.count
+		}
+		it.Close()
+	}
+
+	target := append(bytes.Clone(r.indexPrefix), suffix...)
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = r.indexPrefix
+	opts.PrefetchValues = false
+	it := r.txn.NewIterator(opts)
+	defer it.Close()
+	for it.Seek(append(bytes.Clone(r.indexPrefix), start...)); it.ValidForPrefix(r.indexPrefix); it.Next() {
+		if bytes.Compare(it.Item().Key(), target) >= 0 {
+			break
+		}
+		rank++
+	}
+	return rank, nil
+}
+
+// keyAt 返回正向排名为 rank 的索引键，超出范围时返回 nil
+func (r *zsetRankIndex) keyAt(rank int64) ([]byte, error) {
+	ok, err := r.exists()
+	if err != nil {
+		return nil, err
+	}
+	start := []byte{}
+	if ok {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = r.rankPrefix
+		it := r.txn.NewIterator(opts)
+		found := false
+		for it.Seek(r.rankPrefix); it.ValidForPrefix(r.rankPrefix); it.Next() {
+			b, err := r.readBlock(it.Item())
+			if err != nil {
+				it.Close()
+				return nil, err
+			}
+			if rank < b.count {
+				start, found = b.start, true
+				break
+			}
+			rank -= b.count
+		}
+		it.Close()
+		if !found {
+			return nil, nil
+		}
+	}
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = r.indexPrefix
+	opts.PrefetchValues = false
+	it := r.txn.NewIterator(opts)
+	defer it.Close()
+	for it.Seek(append(bytes.Clone(r.indexPrefix), start...)); it.ValidForPrefix(r.indexPrefix); it.Next() {
+		if rank == 0 {
+			return it.Item().KeyCopy(nil), nil
+		}
+		rank--
+	}
+	return nil, nil
+}
+
+// setSortedSetIndex 写入成员的索引键并更新排名索引
+func setSortedSetIndex(txn *storeTxn, zSetName string, indexKey []byte) error {
+	_, err := txn.Get(indexKey)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, badger.ErrKeyNotFound) {
+		return err
+	}
+	if err := txn.Set(indexKey, nil); err != nil {
+		return err
+	}
+	return newZSetRankIndex(txn, zSetName).insert(indexKey)
+}
@@ -0,0 +1,176 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/zeebo/assert"
+)
+
+// checkZSetRanks 对比有序集合与按 (分数, 成员) 排序的期望结果，并检查排名索引各块的计数之和等于集合大小
+func checkZSetRanks(t *testing.T, store *BotreonStore, key string, want map[string]float64) {
+	t.Helper()
+	expected := make([]ZSetMember, 0, len(want))
+	for m, score := range want {
+		expected = append(expected, ZSetMember{Member: m, Score: score})
+	}
+	sort.Slice(expected, func(i, j int) bool {
+		if expected[i].Score != expected[j].Score {
+			return expected[i].Score < expected[j].Score
+		}
+		return expected[i].Member < expected[j].Member
+	})
+	n := int64(len(expected))
+
+	all, err := store.ZRange(key, 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, len(expected), len(all))
+	for i, m := range all {
+		assert.Equal(t, expected[i], *m)
+	}
+	for _, i := range []int64{0, 1, n / 3, n / 2, n - 2, n - 1} {
+		if i < 0 || i >= n {
+			continue
+		}
+		m := expected[i]
+		rank, err := store.ZRank(key, m.Member)
+		assert.NoError(t, err)
+		assert.Equal(t, i, rank)
+		revRank, err := store.ZRevRank(key, m.Member)
+		assert.NoError(t, err)
+		assert.Equal(t, n-1-i, revRank)
+
+		page, err := store.ZRange(key, i, i+4)
+		assert.NoError(t, err)
+		assert.Equal(t, m, *page[0])
+		revPage, err := store.ZRevRange(key, n-1-i, n-1-i+4)
+		assert.NoError(t, err)
+		assert.Equal(t, m, *revPage[0])
+		if i > 0 {
+			assert.Equal(t, expected[i-1], *revPage[1])
+		}
+	}
+
+	var total int64
+	assert.NoError(t, store.view(func(txn *storeTxn) error {
+		r := newZSetRankIndex(txn, key)
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = r.rankPrefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			b, err := r.readBlock(it.Item())
+			if err != nil {
+				return err
+			}
+			assert.True(t, b.count > 0 && b.count <= 2*zsetRankBlockSize)
+			total += b.count
+		}
+		return nil
+	}))
+	assert.Equal(t, n, total)
+}
+
+func TestZSetRankIndex(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	key := "ranked"
+	want := make(map[string]float64)
+	var members []ZSetMember
+	for i := 0; i < 1000; i++ {
+		m := ZSetMember{Member: fmt.Sprintf("m%04d", i), Score: float64((i * 37) % 101)}
+		members = append(members, m)
+		want[m.Member] = m.Score
+	}
+	// 分批写入，让排名索引经历多次拆分
+	for i := 0; i < len(members); i += 50 {
+		assert.NoError(t, store.ZAdd(key, members[i:i+50]))
+	}
+	checkZSetRanks(t, store, key, want)
+
+	// 更新分数、删除成员后排名仍然正确，删除会合并过小的块
+	for i := 0; i < 1000; i += 3 {
+		m := fmt.Sprintf("m%04d", i)
+		score, err := store.ZIncrBy(key, m, -200)
+		assert.NoError(t, err)
+		want[m] = score
+	}
+	for i := 1; i < 1000; i += 4 {
+		m := fmt.Sprintf("m%04d", i)
+		assert.NoError(t, store.ZRem(key, m))
+		delete(want, m)
+	}
+	checkZSetRanks(t, store, key, want)
+
+	all, err := store.ZRange(key, 0, -1)
+	assert.NoError(t, err)
+	removed, err := store.ZRemRangeByRank(key, 10, 409)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(400), removed)
+	for _, m := range all[10:410] {
+		delete(want, m.Member)
+	}
+	removed, err = store.ZRemRangeByScore(key, 50, 60, false, true)
+	assert.NoError(t, err)
+	for m, score := range want {
+		if score >= 50 && score < 60 {
+			delete(want, m)
+			removed--
+		}
+	}
+	assert.Equal(t, int64(0), removed)
+	checkZSetRanks(t, store, key, want)
+
+	rank, err := store.ZRank(key, "missing")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), rank)
+
+	// 删除全部成员后排名索引一并删除
+	_, err = store.ZRemRangeByRank(key, 0, -1)
+	assert.NoError(t, err)
+	assert.NoError(t, store.view(func(txn *storeTxn) error {
+		ok, err := newZSetRankIndex(txn, key).exists()
+		assert.False(t, ok)
+		return err
+	}))
+}
+
+func TestZSetRankIndexLegacy(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	key := "legacy"
+	want := make(map[string]float64)
+	var members []ZSetMember
+	for i := 0; i < 300; i++ {
+		m := ZSetMember{Member: fmt.Sprintf("m%03d", i), Score: float64(i % 7)}
+		members = append(members, m)
+		want[m.Member] = m.Score
+	}
+	assert.NoError(t, store.ZAdd(key, members))
+
+	// 模拟升级前写入、没有排名索引的集合：按索引顺序扫描
+	assert.NoError(t, store.update(func(txn *storeTxn) error {
+		return deleteByPrefix(txn, sortedSetRankPrefix(key))
+	}))
+	// 分数 0 到 5 各有 43 个成员，分数 6 有 42 个
+	rank, err := store.ZRank(key, "m010")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3*43+1), rank)
+	revRank, err := store.ZRevRank(key, "m006")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(41), revRank)
+	page, err := store.ZRange(key, 43, 43)
+	assert.NoError(t, err)
+	assert.Equal(t, ZSetMember{Member: "m001", Score: 1}, *page[0])
+
+	// 下一次写入时建立排名索引
+	assert.NoError(t, store.ZAdd(key, []ZSetMember{{Member: "extra", Score: 3.5}}))
+	want["extra"] = 3.5
+	checkZSetRanks(t, store, key, want)
+}
@@ -1,7 +1,10 @@
 package store
 
 import (
+	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/zeebo/assert"
 )
@@ -692,6 +695,67 @@ func TestZDiffStore(t *testing.T) {
 	assert.Equal(t, "a", members[0].Member)
 }
 
+// TestZStoreAtomic ZUNIONSTORE 等命令在一个事务中替换目标集合，并发的读者不会看到空的目标集合
+func TestZStoreAtomic(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	var members []ZSetMember
+	for i := 0; i < 50; i++ {
+		members = append(members, ZSetMember{Member: fmt.Sprintf("m%02d", i), Score: float64(i)})
+	}
+	assert.NoError(t, store.ZAdd("src1", members[:30]))
+	assert.NoError(t, store.ZAdd("src2", members[20:]))
+	_, err = store.ZUnionStore("dest", []string{"src1", "src2"}, nil, "")
+	assert.NoError(t, err)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			var err error
+			switch i % 3 {
+			case 0:
+				_, err = store.ZUnionStore("dest", []string{"src1", "src2"}, nil, "MAX")
+			case 1:
+				_, err = store.ZInterStore("dest", []string{"src1", "src2"}, nil, "")
+			case 2:
+				_, err = store.ZDiffStore("dest", []string{"src1", "src2"})
+			}
+			assert.NoError(t, err)
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		card, err := store.ZCard("dest")
+		assert.NoError(t, err)
+		assert.True(t, card == 50 || card == 10 || card == 20)
+	}
+	close(stop)
+	<-done
+
+	// 目标键原来是其他类型时被整体替换
+	assert.NoError(t, store.Set("str", "v"))
+	count, err := store.ZDiffStore("str", []string{"src1", "src2"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20), count)
+	keyType, err := store.Type("str")
+	assert.NoError(t, err)
+	assert.Equal(t, "zset", keyType)
+	count, err = store.ZReplace("str", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+	keyType, err = store.Type("str")
+	assert.NoError(t, err)
+	assert.Equal(t, "none", keyType)
+}
+
 func TestZLexCount(t *testing.T) {
 	dbPath := t.TempDir()
 	store, _ := NewBadgerStore(dbPath)
@@ -811,3 +875,220 @@ func TestZMScore(t *testing.T) {
 	assert.Equal(t, 0.0, scores[2]) // 不存在的成员
 	assert.Equal(t, 3.0, scores[3])
 }
+
+func TestZWatchTopN(t *testing.T) {
+	dbPath := t.TempDir()
+	store, _ := NewBadgerStore(dbPath)
+
+	var events []ZRankEvent
+	publish := func(channel string, message []byte) {
+		assert.Equal(t, "board:events", channel)
+		var event ZRankEvent
+		assert.NoError(t, json.Unmarshal(message, &event))
+		events = append(events, event)
+	}
+	store.SetZWatchPublisher(publish)
+
+	assert.NoError(t, store.ZAdd("board", []ZSetMember{
+		{Member: "alice", Score: 100},
+		{Member: "bob", Score: 90},
+		{Member: "carol", Score: 80},
+	}))
+	// 按分数从高到低关注前 2 名
+	assert.NoError(t, store.ZWatch("board", ZWatchConfig{TopN: 2, Channel: "board:events", Rev: true}))
+
+	// 前 2 名之外的变化不产生事件
+	assert.NoError(t, store.ZAdd("board", []ZSetMember{{Member: "dave", Score: 10}}))
+	assert.Equal(t, 0, len(events))
+
+	// carol 进入前 2 名，bob 被挤出
+	_, err := store.ZIncrBy("board", "carol", 15)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, "enter", events[0].Event)
+	assert.Equal(t, "carol", events[0].Member)
+	assert.Equal(t, int64(-1), events[0].OldRank)
+	assert.Equal(t, int64(1), events[0].NewRank)
+	assert.Equal(t, 95.0, *events[0].NewScore)
+	assert.Equal(t, "leave", events[1].Event)
+	assert.Equal(t, "bob", events[1].Member)
+	assert.Equal(t, int64(1), events[1].OldRank)
+	assert.Equal(t, int64(-1), events[1].NewRank)
+	assert.Equal(t, 90.0, *events[1].NewScore)
+
+	// 前 2 名内名次交换
+	events = nil
+	assert.NoError(t, store.ZAdd("board", []ZSetMember{{Member: "carol", Score: 120}}))
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, "move", events[0].Event)
+	assert.Equal(t, "carol", events[0].Member)
+	assert.Equal(t, int64(0), events[0].NewRank)
+	assert.Equal(t, 95.0, *events[0].OldScore)
+	assert.Equal(t, "alice", events[1].Member)
+	assert.Equal(t, int64(1), events[1].NewRank)
+
+	// 删除前 2 名内的成员，bob 补位
+	events = nil
+	assert.NoError(t, store.ZRem("board", "alice"))
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, "enter", events[0].Event)
+	assert.Equal(t, "bob", events[0].Member)
+	assert.Equal(t, "leave", events[1].Event)
+	assert.Equal(t, "alice", events[1].Member)
+	assert.True(t, events[1].NewScore == nil)
+
+	// 配置在重启后保留
+	assert.NoError(t, store.Close())
+	store, _ = NewBadgerStore(dbPath)
+	defer store.Close()
+	store.SetZWatchPublisher(publish)
+	config, ok := store.ZWatchConfigOf("board")
+	assert.True(t, ok)
+	assert.Equal(t, 2, config.TopN)
+	assert.True(t, config.Rev)
+
+	// 重启后第一次变更只建立快照，之后照常通知
+	events = nil
+	assert.NoError(t, store.ZAdd("board", []ZSetMember{{Member: "dave", Score: 11}}))
+	assert.Equal(t, 0, len(events))
+	_, err = store.Del("board")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, "leave", events[0].Event)
+	assert.Equal(t, "leave", events[1].Event)
+
+	removed, err := store.ZUnwatch("board")
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = store.ZUnwatch("board")
+	assert.NoError(t, err)
+	assert.False(t, removed)
+}
+
+func TestZRemAfterVersionBump(t *testing.T) {
+	dbPath := t.TempDir()
+	store, _ := NewBadgerStore(dbPath)
+	defer store.Close()
+
+	// 成员在不同版本写入，删除和更新时必须清理各自的索引键
+	assert.NoError(t, store.ZAdd("z", []ZSetMember{{Member: "a", Score: 1}}))
+	assert.NoError(t, store.ZAdd("z", []ZSetMember{{Member: "b", Score: 2}}))
+	assert.NoError(t, store.ZRem("z", "a"))
+	assert.NoError(t, store.ZAdd("z", []ZSetMember{{Member: "c", Score: 3}}))
+	assert.NoError(t, store.ZAdd("z", []ZSetMember{{Member: "b", Score: 5}}))
+
+	top, err := store.zsetTopN("z", 10, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZSetMember{{Member: "c", Score: 3}, {Member: "b", Score: 5}}, top)
+
+	// ZSetDel 删除所有成员，重新添加时不会残留旧成员
+	assert.NoError(t, store.ZSetDel("z"))
+	assert.NoError(t, store.ZAdd("z", []ZSetMember{{Member: "d", Score: 4}}))
+	_, exists, _ := store.ZScore("z", "b")
+	assert.False(t, exists)
+	top, err = store.zsetTopN("z", 10, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZSetMember{{Member: "d", Score: 4}}, top)
+}
+
+func TestZMerge(t *testing.T) {
+	dbPath := t.TempDir()
+	store, _ := NewBadgerStore(dbPath)
+	defer store.Close()
+
+	assert.NoError(t, store.ZAdd("dst", []ZSetMember{{Member: "a", Score: 5}, {Member: "b", Score: 1}}))
+	assert.NoError(t, store.ZAdd("src", []ZSetMember{{Member: "a", Score: 3}, {Member: "b", Score: 4}, {Member: "c", Score: 2}}))
+
+	// 保留较大分数：a 不变，b 更新，c 新增
+	changed, err := store.ZMerge("dst", "src", false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), changed)
+	card, _ := store.ZCard("dst")
+	assert.Equal(t, int64(3), card)
+	members, err := store.ZRange("dst", 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []*ZSetMember{{Member: "c", Score: 2}, {Member: "b", Score: 4}, {Member: "a", Score: 5}}, members)
+
+	// 再次合并没有变化
+	changed, err = store.ZMerge("dst", "src", false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), changed)
+
+	// 保留较小分数
+	changed, err = store.ZMerge("dst", "src", true)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), changed)
+	score, _, _ := store.ZScore("dst", "a")
+	assert.Equal(t, 3.0, score)
+	top, err := store.zsetTopN("dst", 10, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(top))
+
+	// source 不存在时不创建 destination
+	changed, err = store.ZMerge("new", "missing", false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), changed)
+	keyType, _ := store.Type("new")
+	assert.Equal(t, "none", keyType)
+
+	changed, err = store.ZMerge("new", "src", false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), changed)
+	card, _ = store.ZCard("new")
+	assert.Equal(t, int64(3), card)
+}
+
+func TestZMPopAndBlockingPop(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.ZAdd("z2", []ZSetMember{{Member: "a", Score: 1}, {Member: "b", Score: 2}, {Member: "c", Score: 3}}))
+	// 跳过空键，从第一个非空集合弹出
+	key, members, err := store.ZMPop([]string{"z1", "z2"}, true, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "z2", key)
+	assert.Equal(t, []ZSetMember{{Member: "c", Score: 3}, {Member: "b", Score: 2}}, members)
+	key, members, err = store.ZMPop([]string{"z2"}, false, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "z2", key)
+	assert.Equal(t, []ZSetMember{{Member: "a", Score: 1}}, members)
+	key, _, err = store.ZMPop([]string{"z1", "z2"}, false, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "", key)
+
+	// 超时返回空键名
+	start := time.Now()
+	key, member, err := store.BZPopMin([]string{"z1"}, 0.05)
+	assert.NoError(t, err)
+	assert.Equal(t, "", key)
+	assert.Nil(t, member)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	// 阻塞的客户端被 ZADD 唤醒
+	type popped struct {
+		key     string
+		members []ZSetMember
+	}
+	done := make(chan popped, 1)
+	go func() {
+		key, members, err := store.BZMPop([]string{"z1", "z2"}, false, 5, 2)
+		assert.NoError(t, err)
+		done <- popped{key, members}
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for store.BlockedClients() != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.NoError(t, store.ZAdd("z2", []ZSetMember{{Member: "x", Score: 5}, {Member: "y", Score: 4}}))
+	select {
+	case r := <-done:
+		assert.Equal(t, "z2", r.key)
+		assert.Equal(t, []ZSetMember{{Member: "y", Score: 4}, {Member: "x", Score: 5}}, r.members)
+	case <-time.After(2 * time.Second):
+		t.Fatal("BZMPOP was not woken by ZADD")
+	}
+	card, err := store.ZCard("z2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), card)
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -19,7 +20,8 @@ const (
 	KeyTypeStream = "STREAM"
 	prefixStream  = "stream:"
 	streamMeta    = ":meta"
-	streamData    = ":data"
+	streamData    = ":data" // 旧版记录键 stream:<key>:data:<id>，启动时迁移为 streamEntry
+	streamEntry   = ":entry:"
 	streamGroups  = ":groups"
 	streamPending = ":pending"
 )
@@ -67,9 +69,8 @@ type StreamInfo struct {
 
 // StreamXAddOptions contains options for XADD
 type StreamXAddOptions struct {
-	MaxLen      int64 // Maximum number of entries
-	MaxLenApprox int64 // Approximate maximum (more efficient)
-	MinID       string // Only add entries with ID >= minID
+	NoMkStream bool              // Don't create the stream if it doesn't exist
+	Trim       StreamTrimOptions // MAXLEN/MINID trimming applied after the append
 }
 
 // parseStreamID parses a stream ID string to (timestamp, sequence)
@@ -108,11 +109,8 @@ func parseStreamID(id string) (int64, int64, error) {
 	return 0, 0, fmt.Errorf("invalid stream ID format: %s", id)
 }
 
-// formatStreamID formats (timestamp, sequence) to string
+// formatStreamID formats (timestamp, sequence) to string，与 Redis 相同总是带序号
 func formatStreamID(timestamp, sequence int64) string {
-	if sequence == 0 {
-		return fmt.Sprintf("%d", timestamp)
-	}
 	return fmt.Sprintf("%d-%d", timestamp, sequence)
 }
 
@@ -141,13 +139,50 @@ func streamKey(key string) []byte {
 	return []byte(prefixStream + key + streamMeta)
 }
 
-// streamDataKey returns the key for stream entry data
-func streamDataKey(key, id string) []byte {
-	return []byte(prefixStream + key + streamData + ":" + id)
+// streamDataKey returns the key for stream entry data。
+// ID 编码为定长大端的毫秒数与序号，键的字节序与 ID 顺序一致，可以按 ID 顺序遍历并直接定位到某个 ID
+func streamDataKey(key string, ts, seq int64) []byte {
+	prefix := streamDataPrefix(key)
+	k := make([]byte, len(prefix)+16)
+	copy(k, prefix)
+	// #nosec G115 - Stream ID 的毫秒数与序号非负
+	binary.BigEndian.PutUint64(k[len(prefix):], uint64(ts))
+	// #nosec G115 - Stream ID 的毫秒数与序号非负
+	binary.BigEndian.PutUint64(k[len(prefix)+8:], uint64(seq))
+	return k
+}
+
+// streamEntryIDKey 返回 ID 字符串对应的记录键，ID 无效时返回 nil
+func streamEntryIDKey(key, id string) []byte {
+	if id == "*" {
+		return nil
+	}
+	ts, seq, err := parseStreamID(id)
+	if err != nil {
+		return nil
+	}
+	return streamDataKey(key, ts, seq)
 }
 
 // streamDataPrefix returns the prefix for all entry data keys
 func streamDataPrefix(key string) []byte {
+	return []byte(prefixStream + key + streamEntry)
+}
+
+// streamDataKeyID 从记录键中解析 ID，k 不是 prefix 下的记录键时 ok 为 false
+func streamDataKeyID(prefix, k []byte) (ts, seq int64, ok bool) {
+	if len(k) != len(prefix)+16 {
+		return 0, 0, false
+	}
+	// #nosec G115 - 写入时为非负的毫秒数与序号
+	ts = int64(binary.BigEndian.Uint64(k[len(prefix):]))
+	// #nosec G115 - 写入时为非负的毫秒数与序号
+	seq = int64(binary.BigEndian.Uint64(k[len(prefix)+8:]))
+	return ts, seq, true
+}
+
+// streamLegacyDataPrefix 旧版以 ID 字符串为后缀的记录键前缀，字符串顺序与 ID 顺序不一致
+func streamLegacyDataPrefix(key string) []byte {
 	return []byte(prefixStream + key + streamData + ":")
 }
 
@@ -161,6 +196,11 @@ func streamGroupDataKey(key, group string) []byte {
 	return []byte(prefixStream + key + streamGroups + ":" + group)
 }
 
+// streamGroupDataPrefix returns the prefix for all group keys
+func streamGroupDataPrefix(key string) []byte {
+	return []byte(prefixStream + key + streamGroups + ":")
+}
+
 // streamPendingKey returns the key for pending entries in a group
 func streamPendingKey(key, group string) []byte {
 	return []byte(prefixStream + key + streamPending + ":" + group)
@@ -175,21 +215,30 @@ type streamMetaData struct {
 	LastSeq      int64
 	MaxDeletedID int64 // timestamp
 	MaxDelSeq    int64
+	EntriesAdded int64 // 累计追加的条数，包括已删除的记录
 }
 
+// streamMetaSizeV1 旧版元数据长度（不含 MaxDelSeq）
+const streamMetaSizeV1 = 48
+
+// streamMetaSizeV2 不含 EntriesAdded 的元数据长度
+const streamMetaSizeV2 = 56
+
 func encodeStreamMeta(m *streamMetaData) []byte {
-	b := make([]byte, 48)
+	b := make([]byte, 64)
 	binary.BigEndian.PutUint64(b[:8], uint64(m.Length))
 	binary.BigEndian.PutUint64(b[8:16], uint64(m.FirstID))
 	binary.BigEndian.PutUint64(b[16:24], uint64(m.FirstSeq))
 	binary.BigEndian.PutUint64(b[24:32], uint64(m.LastID))
 	binary.BigEndian.PutUint64(b[32:40], uint64(m.LastSeq))
 	binary.BigEndian.PutUint64(b[40:48], uint64(m.MaxDeletedID))
+	binary.BigEndian.PutUint64(b[48:56], uint64(m.MaxDelSeq))
+	binary.BigEndian.PutUint64(b[56:64], uint64(m.EntriesAdded))
 	return b
 }
 
 func decodeStreamMeta(b []byte) (*streamMetaData, error) {
-	if len(b) != 48 {
+	if len(b) != 64 && len(b) != streamMetaSizeV2 && len(b) != streamMetaSizeV1 {
 		return nil, errors.New("invalid stream metadata size")
 	}
 	m := &streamMetaData{}
@@ -199,167 +248,185 @@ func decodeStreamMeta(b []byte) (*streamMetaData, error) {
 	m.LastID = int64(binary.BigEndian.Uint64(b[24:32]))
 	m.LastSeq = int64(binary.BigEndian.Uint64(b[32:40]))
 	m.MaxDeletedID = int64(binary.BigEndian.Uint64(b[40:48]))
+	if len(b) > streamMetaSizeV1 {
+		m.MaxDelSeq = int64(binary.BigEndian.Uint64(b[48:56]))
+	}
+	if len(b) > streamMetaSizeV2 {
+		m.EntriesAdded = int64(binary.BigEndian.Uint64(b[56:64]))
+	} else {
+		// 旧版元数据没有记录追加次数，以当前长度作为下限
+		m.EntriesAdded = m.Length
+	}
 	return m, nil
 }
 
+// streamMetaTxn 在事务中读取 Stream 元数据，Stream 不存在时返回 nil
+func streamMetaTxn(txn *storeTxn, key string) (*streamMetaData, error) {
+	item, err := txn.Get(streamKey(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var meta *streamMetaData
+	err = item.Value(func(val []byte) error {
+		meta, err = decodeStreamMeta(val)
+		return err
+	})
+	return meta, err
+}
+
+// Stream ID 分配相关错误，文本与 Redis 一致
+var (
+	ErrStreamInvalidID  = errors.New("ERR Invalid stream ID specified as stream command argument")
+	ErrStreamIDZero     = errors.New("ERR The ID specified in XADD must be greater than 0-0")
+	ErrStreamIDTooSmall = errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	ErrStreamExhausted  = errors.New("ERR The stream has exhausted the last possible ID, unable to add more items")
+)
+
+// streamIDGreater 判断 ts-seq 是否严格大于 lastTS-lastSeq
+func streamIDGreater(ts, seq, lastTS, lastSeq int64) bool {
+	return ts > lastTS || (ts == lastTS && seq > lastSeq)
+}
+
+// nextStreamID 为 XADD * 生成严格大于上一个 ID 的新 ID。
+// 当前毫秒数大于上一个 ID 时使用 now-0；同一毫秒内或时钟回拨时沿用上一个 ID 的毫秒数并递增序号，
+// 序号用尽时进位到下一毫秒，因此重启或时钟回拨都不会产生重复或倒退的 ID。
+func nextStreamID(lastTS, lastSeq, nowMs int64) (int64, int64, error) {
+	if nowMs > lastTS {
+		return nowMs, 0, nil
+	}
+	if lastSeq < math.MaxInt64 {
+		return lastTS, lastSeq + 1, nil
+	}
+	if lastTS == math.MaxInt64 {
+		return 0, 0, ErrStreamExhausted
+	}
+	return lastTS + 1, 0, nil
+}
+
+// nextStreamSeq 为 XADD <ms>-* 分配序号
+func nextStreamSeq(lastTS, lastSeq, ts int64) (int64, error) {
+	switch {
+	case ts > lastTS:
+		return 0, nil
+	case ts == lastTS && lastSeq < math.MaxInt64:
+		// 空 Stream 上的 0-* 也会得到 0-1
+		return lastSeq + 1, nil
+	default:
+		return 0, ErrStreamIDTooSmall
+	}
+}
+
 // XAdd adds a new entry to a stream
 func (s *BotreonStore) XAdd(key string, opts StreamXAddOptions, id string, fields map[string]string) (string, error) {
 	var resultID string
 
-	err := s.db.Update(func(txn *badger.Txn) error {
-		// Set type key
-		typeKey := TypeOfKeyGet(key)
-		if err := txn.Set(typeKey, []byte(KeyTypeStream)); err != nil {
-			logger.Logger.Error().Err(err).Str("key", key).Msg("XAdd: Failed to set type")
-			return err
-		}
+	// 元数据在事务中读写，并发 XADD 同一个 Stream 时冲突重试
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		var err error
+		resultID, err = s.xaddTxn(txn, key, opts, id, fields)
+		return err
+	}, 30)
 
-		// Get or create metadata
-		metaKey := streamKey(key)
-		var meta *streamMetaData
+	// Notify waiting stream readers
+	if err == nil && resultID != "" {
+		s.notifyStreamRead(key)
+	}
 
-		item, err := txn.Get(metaKey)
-		if err == nil && !errors.Is(err, badger.ErrKeyNotFound) {
-			err = item.Value(func(val []byte) error {
-				meta, err = decodeStreamMeta(val)
-				return err
-			})
-			if err != nil {
-				return err
-			}
-		} else {
-			meta = &streamMetaData{}
-		}
+	return resultID, err
+}
 
-		// Parse or generate ID
-		if id == "" || id == "*" {
-			// Auto-generate ID
-			timestamp := time.Now().UnixNano() / int64(time.Millisecond)
-			if timestamp == meta.LastID {
-				meta.LastSeq++
-			} else {
-				meta.LastSeq = 0
-			}
-			meta.LastID = timestamp
-			id = formatStreamID(meta.LastID, meta.LastSeq)
-		} else {
-			ts, seq, err := parseStreamID(id)
-			if err != nil {
-				return err
-			}
-			// Check if ID is valid (must be greater than last ID)
-			if compareStreamID(id, formatStreamID(meta.LastID, meta.LastSeq)) < 0 {
-				return fmt.Errorf("ERR ID must be greater than the last entry ID (%d-%d)", meta.LastID, meta.LastSeq)
-			}
-			meta.LastID = ts
-			meta.LastSeq = seq
-			id = formatStreamID(ts, seq)
+// xaddTxn 在事务中追加一条 Stream 记录，返回分配的 ID（不通知阻塞的读取者）
+// 带 NoMkStream 且 Stream 不存在时不写入，返回空 ID
+func (s *BotreonStore) xaddTxn(txn *storeTxn, key string, opts StreamXAddOptions, id string, fields map[string]string) (string, error) {
+	// Get or create metadata
+	metaKey := streamKey(key)
+	meta, err := streamMetaTxn(txn, key)
+	if err != nil {
+		return "", err
+	}
+	if meta == nil {
+		if opts.NoMkStream {
+			return "", nil
 		}
+		meta = &streamMetaData{}
+	}
 
-		// Check MINID if specified
-		if opts.MinID != "" {
-			if compareStreamID(id, opts.MinID) < 0 {
-				return fmt.Errorf("ERR ID must be >= MINID (%s)", opts.MinID)
-			}
-		}
+	// Set type key
+	typeKey := TypeOfKeyGet(key)
+	if err := txn.Set(typeKey, []byte(KeyTypeStream)); err != nil {
+		logger.Logger.Error().Err(err).Str("key", key).Msg("XAdd: Failed to set type")
+		return "", err
+	}
 
-		// Store entry data
-		entryData, err := json.Marshal(fields)
+	// Parse or generate ID
+	// 最后分配的 ID 保存在元数据中，重启后继续保证单调递增
+	var ts, seq int64
+	switch {
+	case id == "" || id == "*":
+		ts, seq, err = nextStreamID(meta.LastID, meta.LastSeq, s.now().UnixMilli())
+	case strings.HasSuffix(id, "-*"):
+		// <ms>-*：指定毫秒数，序号自动分配
+		ts, err = strconv.ParseInt(strings.TrimSuffix(id, "-*"), 10, 64)
+		if err != nil || ts < 0 {
+			return "", ErrStreamInvalidID
+		}
+		seq, err = nextStreamSeq(meta.LastID, meta.LastSeq, ts)
+	default:
+		ts, seq, err = parseStreamID(id)
 		if err != nil {
-			return err
+			return "", ErrStreamInvalidID
 		}
-		dataKey := streamDataKey(key, id)
-		if err := txn.Set(dataKey, entryData); err != nil {
-			return err
+		if ts == 0 && seq == 0 {
+			return "", ErrStreamIDZero
 		}
-
-		// Update metadata
-		meta.Length++
-		if meta.Length == 1 {
-			meta.FirstID = meta.LastID
-			meta.FirstSeq = meta.LastSeq
-		}
-
-		// Handle MAXLEN
-		if opts.MaxLen > 0 {
-			// Calculate how many entries to remove
-			entriesToRemove := meta.Length - opts.MaxLen
-			if entriesToRemove > 0 {
-				// Remove oldest entries
-				removeMeta := &streamMetaData{
-					Length:       entriesToRemove,
-					FirstID:      meta.FirstID,
-					FirstSeq:     meta.FirstSeq,
-					MaxDeletedID: meta.FirstID,
-					MaxDelSeq:    meta.FirstSeq,
-				}
-				// Update first ID to the next entry
-				// For simplicity, we just iterate and delete
-				prefix := streamDataPrefix(key)
-				it := txn.NewIterator(badger.DefaultIteratorOptions)
-				defer it.Close()
-
-				count := int64(0)
-				currentID := meta.FirstID
-				currentSeq := meta.FirstSeq
-				for it.Seek(prefix); it.ValidForPrefix(prefix) && count < entriesToRemove; it.Next() {
-					item := it.Item()
-					_ = txn.Delete(item.Key())
-					count++
-					// Move to next ID
-					currentSeq++
-					if count < entriesToRemove {
-						removeMeta.MaxDelSeq = currentSeq
-					}
-				}
-				meta.Length -= count
-				if meta.Length > 0 {
-					// Set first ID to the next entry
-					currentSeqStr := formatStreamID(currentID, currentSeq)
-					nextKey := streamDataKey(key, currentSeqStr)
-					_, err := txn.Get(nextKey)
-					if err == nil || errors.Is(err, badger.ErrKeyNotFound) {
-						// Found next entry, set as first
-						nextTS, nextSeq, _ := parseStreamID(currentSeqStr)
-						meta.FirstID = nextTS
-						meta.FirstSeq = nextSeq
-					}
-				} else {
-					meta.FirstID = meta.LastID
-					meta.FirstSeq = meta.LastSeq
-				}
-				meta.MaxDeletedID = removeMeta.MaxDeletedID
-				meta.MaxDelSeq = removeMeta.MaxDelSeq
-			}
+		if !streamIDGreater(ts, seq, meta.LastID, meta.LastSeq) {
+			return "", ErrStreamIDTooSmall
 		}
+	}
+	if err != nil {
+		return "", err
+	}
+	meta.LastID = ts
+	meta.LastSeq = seq
+	id = formatStreamID(ts, seq)
 
-		// Save metadata
-		if err := txn.Set(metaKey, encodeStreamMeta(meta)); err != nil {
-			return err
-		}
+	// Store entry data
+	entryData, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	if err := txn.Set(streamDataKey(key, ts, seq), entryData); err != nil {
+		return "", err
+	}
 
-		resultID = id
-		return nil
-	})
+	// Update metadata
+	meta.Length++
+	meta.EntriesAdded++
+	if meta.Length == 1 {
+		meta.FirstID = meta.LastID
+		meta.FirstSeq = meta.LastSeq
+	}
 
-	// Notify waiting stream readers
-	if err == nil && resultID != "" {
-		s.notifyStreamRead(key, []StreamEntry{
-			{
-				ID:     resultID,
-				Fields: fields,
-			},
-		})
+	if _, err := trimStreamTxn(txn, key, meta, opts.Trim); err != nil {
+		return "", err
 	}
 
-	return resultID, err
+	// Save metadata
+	if err := txn.Set(metaKey, encodeStreamMeta(meta)); err != nil {
+		return "", err
+	}
+
+	return id, nil
 }
 
 // XLen returns the number of entries in a stream
 func (s *BotreonStore) XLen(key string) (int64, error) {
 	var length int64
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		metaKey := streamKey(key)
 		item, err := txn.Get(metaKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -381,198 +448,131 @@ func (s *BotreonStore) XLen(key string) (int64, error) {
 	return length, err
 }
 
-// XRead reads entries from one or more streams
+// XRead reads entries from one or more streams.
+// block 小于 0 时不阻塞，等于 0 时一直等待到有新记录，大于 0 时最多等待 block 毫秒
 func (s *BotreonStore) XRead(count int64, block int64, args ...string) ([]map[string][]StreamEntry, error) {
 	if len(args) < 2 || len(args)%2 != 0 {
-		return nil, errors.New("ERR wrong number of arguments for 'XREAD' command")
+		return nil, errors.New("ERR wrong number of arguments for 'xread' command")
 	}
-
-	result := make([]map[string][]StreamEntry, 0)
-
-	err := s.db.View(func(txn *badger.Txn) error {
-		for i := 0; i < len(args); i += 2 {
-			key := args[i]
-			startID := args[i+1]
-
-			// Get stream metadata
-			metaKey := streamKey(key)
-			var meta *streamMetaData
-			item, err := txn.Get(metaKey)
-			if errors.Is(err, badger.ErrKeyNotFound) {
-				continue // Skip non-existent streams
-			}
-			if err != nil {
-				return err
-			}
-			if err := item.Value(func(val []byte) error {
-				meta, err = decodeStreamMeta(val)
-				return err
-			}); err != nil {
-				return err
-			}
-
-			// Get entries after start ID
-			entries := make([]StreamEntry, 0)
-			prefix := streamDataPrefix(key)
-			it := txn.NewIterator(badger.DefaultIteratorOptions)
-			defer it.Close()
-
-			startTS, startSeq, _ := parseStreamID(startID)
-			if startID == "$" {
-				// Only get new entries after last ID
-				startTS = meta.LastID
-				startSeq = meta.LastSeq + 1
-			}
-
-			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-				item := it.Item()
-				id := string(bytes.TrimPrefix(item.Key(), prefix))
-
-				ts, seq, _ := parseStreamID(id)
-				if compareStreamID(id, startID) <= 0 && startID != "$" {
-					continue
-				}
-				if startID == "$" && (ts < startTS || (ts == startTS && seq <= startSeq)) {
-					continue
-				}
-
-				var fields map[string]string
-				if err := item.Value(func(val []byte) error {
-					return json.Unmarshal(val, &fields)
-				}); err != nil {
-					return err
-				}
-
-				entries = append(entries, StreamEntry{
-					ID:        id,
-					Fields:    fields,
-					Timestamp: ts,
-					Sequence:  seq,
-				})
-
-				if count > 0 && int64(len(entries)) >= count {
-					break
-				}
-			}
-
-			if len(entries) > 0 {
-				result = append(result, map[string][]StreamEntry{key: entries})
-			}
-		}
-		return nil
-	})
-
-	if block > 0 && len(result) == 0 {
-		// Implement blocking behavior
-		return s.xReadBlocking(count, block, args)
+	if block < 0 {
+		return s.xReadImmediate(count, args...)
 	}
-
-	return result, err
+	return s.xReadBlocking(count, block, args)
 }
 
-// xReadBlocking implements blocking XREAD
+// xReadBlocking implements blocking XREAD. 等待者先登记再读取，登记之后的 XADD 一定会唤醒它；
+// 被唤醒后按原来的 ID 重新读取，COUNT 和多个 Stream 的语义与立即读取相同
 func (s *BotreonStore) xReadBlocking(count int64, block int64, args []string) ([]map[string][]StreamEntry, error) {
-	// Create a channel for this read request
-	resultCh := make(chan StreamReadResult, 1)
+	keys := make([]string, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		keys = append(keys, args[i])
+	}
+	// "$" 在开始等待时解析为当前最后一条记录的 ID，之后追加的记录才返回
+	args, err := s.resolveStreamLastIDs(args)
+	if err != nil {
+		return nil, err
+	}
 
-	// Set up timeout FIRST - this is critical
-	var timeoutCh <-chan time.Time
-	if block > 0 {
-		timeoutCh = time.After(time.Duration(block) * time.Millisecond)
+	// 有数据时立即返回，不占用阻塞名额
+	result, err := s.xReadImmediate(count, args...)
+	if err != nil || len(result) > 0 {
+		return result, err
+	}
+
+	release, err := s.acquireBlocking(keys)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
-	// Register this channel for each key BEFORE trying immediate read
+	wake := make(chan struct{}, 1)
 	s.streamBlockingMu.Lock()
-	for i := 0; i < len(args); i += 2 {
-		key := args[i]
-		s.streamBlockingChans[key] = append(s.streamBlockingChans[key], resultCh)
+	for _, key := range keys {
+		s.streamBlockingChans[key] = append(s.streamBlockingChans[key], wake)
 	}
 	s.streamBlockingMu.Unlock()
+	defer s.unregisterStreamRead(keys, wake)
 
-	// Try immediate read AFTER registering the channel
-	result, err := s.xReadImmediate(count, args...)
-	if err != nil {
-		// Clean up channels before returning error
-		s.streamBlockingMu.Lock()
-		for i := 0; i < len(args); i += 2 {
-			key := args[i]
-			chans := s.streamBlockingChans[key]
-			for j, ch := range chans {
-				if ch == resultCh {
-					s.streamBlockingChans[key] = append(chans[:j], chans[j+1:]...)
-					break
-				}
-			}
-		}
-		s.streamBlockingMu.Unlock()
-		return nil, err
+	// block 为 0 时一直等待
+	var timeoutCh <-chan time.Time
+	if block > 0 {
+		timer := time.NewTimer(time.Duration(block) * time.Millisecond)
+		defer timer.Stop()
+		timeoutCh = timer.C
 	}
-	if len(result) > 0 {
-		// Clean up channels before returning
-		s.streamBlockingMu.Lock()
-		for i := 0; i < len(args); i += 2 {
-			key := args[i]
-			chans := s.streamBlockingChans[key]
-			for j, ch := range chans {
-				if ch == resultCh {
-					s.streamBlockingChans[key] = append(chans[:j], chans[j+1:]...)
-					break
-				}
-			}
+
+	for {
+		// 登记之后再读一次，覆盖第一次读取与登记之间的 XADD
+		result, err := s.xReadImmediate(count, args...)
+		if err != nil || len(result) > 0 {
+			return result, err
+		}
+		select {
+		case <-wake:
+		case <-timeoutCh:
+			return nil, nil
 		}
-		s.streamBlockingMu.Unlock()
-		return result, nil
 	}
+}
 
-	// If block is 0 (infinite wait), we wait forever until data arrives
-	if block == 0 {
-		for {
-			select {
-			case streamResult := <-resultCh:
-				if len(streamResult.Entries) > 0 {
-					return []map[string][]StreamEntry{{streamResult.Key: streamResult.Entries}}, nil
-				}
+// resolveStreamLastIDs 将 key/ID 参数中的 "$" 替换为 Stream 当前最后一条记录的 ID，
+// Stream 不存在时替换为 0-0
+func (s *BotreonStore) resolveStreamLastIDs(args []string) ([]string, error) {
+	resolved := append([]string(nil), args...)
+	err := s.view(func(txn *storeTxn) error {
+		for i := 0; i < len(resolved); i += 2 {
+			if resolved[i+1] != "$" {
+				continue
 			}
-			// Check if channel is still valid, if not, retry immediate read
-			select {
-			case <-resultCh:
-				// Already handled above
-			default:
+			resolved[i+1] = "0-0"
+			item, err := txn.Get(streamKey(resolved[i]))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := item.Value(func(val []byte) error {
+				meta, err := decodeStreamMeta(val)
+				if err != nil {
+					return err
+				}
+				resolved[i+1] = fmt.Sprintf("%d-%d", meta.LastID, meta.LastSeq)
+				return nil
+			}); err != nil {
+				return err
 			}
 		}
-	}
-
-	// Wait for data or timeout
-	select {
-	case streamResult := <-resultCh:
-		if len(streamResult.Entries) > 0 {
-			return []map[string][]StreamEntry{{streamResult.Key: streamResult.Entries}}, nil
-		}
-	case <-timeoutCh:
-	}
+		return nil
+	})
+	return resolved, err
+}
 
-	// Clean up - remove the channel
+// unregisterStreamRead 移除等待通道，键上没有等待者时删除该键
+func (s *BotreonStore) unregisterStreamRead(keys []string, ch chan struct{}) {
 	s.streamBlockingMu.Lock()
-	for i := 0; i < len(args); i += 2 {
-		key := args[i]
+	defer s.streamBlockingMu.Unlock()
+	for _, key := range keys {
 		chans := s.streamBlockingChans[key]
-		for j, ch := range chans {
-			if ch == resultCh {
-				s.streamBlockingChans[key] = append(chans[:j], chans[j+1:]...)
+		for i, c := range chans {
+			if c == ch {
+				chans = append(chans[:i], chans[i+1:]...)
 				break
 			}
 		}
+		if len(chans) == 0 {
+			delete(s.streamBlockingChans, key)
+		} else {
+			s.streamBlockingChans[key] = chans
+		}
 	}
-	s.streamBlockingMu.Unlock()
-
-	return nil, nil
 }
 
 // xReadImmediate performs an immediate (non-blocking) XREAD
 func (s *BotreonStore) xReadImmediate(count int64, args ...string) ([]map[string][]StreamEntry, error) {
 	result := make([]map[string][]StreamEntry, 0)
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		for i := 0; i < len(args); i += 2 {
 			key := args[i]
 			startID := args[i+1]
@@ -604,18 +604,14 @@ func (s *BotreonStore) xReadImmediate(count int64, args ...string) ([]map[string
 			if startID == "$" {
 				// Only get new entries after last ID
 				startTS = meta.LastID
-				startSeq = meta.LastSeq + 1
+				startSeq = meta.LastSeq
 			}
 
-			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			// 记录键按 ID 排序，从起始 ID 处开始遍历
+			for it.Seek(streamDataKey(key, startTS, startSeq)); it.ValidForPrefix(prefix); it.Next() {
 				item := it.Item()
-				id := string(bytes.TrimPrefix(item.Key(), prefix))
-
-				ts, seq, _ := parseStreamID(id)
-				if compareStreamID(id, startID) <= 0 && startID != "$" {
-					continue
-				}
-				if startID == "$" && (ts < startTS || (ts == startTS && seq <= startSeq)) {
+				ts, seq, ok := streamDataKeyID(prefix, item.Key())
+				if !ok || !streamIDGreater(ts, seq, startTS, startSeq) {
 					continue
 				}
 
@@ -627,7 +623,7 @@ func (s *BotreonStore) xReadImmediate(count int64, args ...string) ([]map[string
 				}
 
 				entries = append(entries, StreamEntry{
-					ID:        id,
+					ID:        formatStreamID(ts, seq),
 					Fields:    fields,
 					Timestamp: ts,
 					Sequence:  seq,
@@ -648,17 +644,15 @@ func (s *BotreonStore) xReadImmediate(count int64, args ...string) ([]map[string
 	return result, err
 }
 
-// notifyStreamRead notifies waiting stream readers about new data
-func (s *BotreonStore) notifyStreamRead(key string, entries []StreamEntry) {
-	s.streamBlockingMu.Lock()
-	defer s.streamBlockingMu.Unlock()
-
-	chans := s.streamBlockingChans[key]
-	for _, ch := range chans {
+// notifyStreamRead 唤醒 key 上所有等待的读取者，由它们重新读取（每个读取者都应看到新记录）
+func (s *BotreonStore) notifyStreamRead(key string) {
+	s.streamBlockingMu.RLock()
+	defer s.streamBlockingMu.RUnlock()
+	for _, ch := range s.streamBlockingChans[key] {
 		select {
-		case ch <- StreamReadResult{Key: key, Entries: entries}:
+		case ch <- struct{}{}:
 		default:
-			// Channel not ready
+			// 已有未处理的唤醒
 		}
 	}
 }
@@ -667,7 +661,7 @@ func (s *BotreonStore) notifyStreamRead(key string, entries []StreamEntry) {
 func (s *BotreonStore) XRange(key, start, stop string, count int64) ([]StreamEntry, error) {
 	var entries []StreamEntry
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		prefix := streamDataPrefix(key)
 		it := txn.NewIterator(badger.DefaultIteratorOptions)
 		defer it.Close()
@@ -684,11 +678,16 @@ func (s *BotreonStore) XRange(key, start, stop string, count int64) ([]StreamEnt
 			stopSeq = math.MaxInt64
 		}
 
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		seek := prefix
+		if startTS > 0 || startSeq > 0 {
+			seek = streamDataKey(key, startTS, startSeq)
+		}
+		for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
 			item := it.Item()
-			id := string(bytes.TrimPrefix(item.Key(), prefix))
-
-			ts, seq, _ := parseStreamID(id)
+			ts, seq, ok := streamDataKeyID(prefix, item.Key())
+			if !ok {
+				continue
+			}
 
 			// Check if within range
 			if ts < startTS || (ts == startTS && seq < startSeq) {
@@ -706,7 +705,7 @@ func (s *BotreonStore) XRange(key, start, stop string, count int64) ([]StreamEnt
 			}
 
 			entries = append(entries, StreamEntry{
-				ID:        id,
+				ID:        formatStreamID(ts, seq),
 				Fields:    fields,
 				Timestamp: ts,
 				Sequence:  seq,
@@ -741,110 +740,107 @@ func (s *BotreonStore) XRevRange(key, start, stop string, count int64) ([]Stream
 func (s *BotreonStore) XDel(key string, ids ...string) (int64, error) {
 	var deleted int64
 
-	err := s.db.Update(func(txn *badger.Txn) error {
-		metaKey := streamKey(key)
-		var meta *streamMetaData
+	err := s.update(func(txn *storeTxn) error {
+		var err error
+		deleted, err = s.xdelTxn(txn, key, ids)
+		return err
+	})
 
-		item, err := txn.Get(metaKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return nil
-		}
-		if err != nil {
-			return err
-		}
-		if err := item.Value(func(val []byte) error {
-			meta, err = decodeStreamMeta(val)
-			return err
-		}); err != nil {
-			return err
-		}
+	return deleted, err
+}
 
-		for _, id := range ids {
-			dataKey := streamDataKey(key, id)
-			ts, seq, _ := parseStreamID(id)
+// xdelTxn 在事务中删除 Stream 记录，返回删除的条数
+func (s *BotreonStore) xdelTxn(txn *storeTxn, key string, ids []string) (int64, error) {
+	var deleted int64
 
-			// Check if entry exists
-			_, err := txn.Get(dataKey)
-			if errors.Is(err, badger.ErrKeyNotFound) {
-				continue
-			}
-			if err != nil {
-				return err
-			}
+	metaKey := streamKey(key)
+	var meta *streamMetaData
 
-			// Delete entry
-			if err := txn.Delete(dataKey); err != nil {
-				return err
-			}
-			deleted++
+	item, err := txn.Get(metaKey)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := item.Value(func(val []byte) error {
+		meta, err = decodeStreamMeta(val)
+		return err
+	}); err != nil {
+		return 0, err
+	}
 
-			// Update max deleted ID if needed
-			if ts > meta.MaxDeletedID || (ts == meta.MaxDeletedID && seq > meta.MaxDelSeq) {
-				meta.MaxDeletedID = ts
-				meta.MaxDelSeq = seq
-			}
+	firstDeleted := false
+	for _, id := range ids {
+		dataKey := streamEntryIDKey(key, id)
+		if dataKey == nil {
+			continue
+		}
+		ts, seq, _ := parseStreamID(id)
 
-			// Update first ID if deleting the first entry
-			if ts == meta.FirstID && seq == meta.FirstSeq {
-				// Find next entry
-				nextID := formatStreamID(ts, seq+1)
-				nextKey := streamDataKey(key, nextID)
-				nextItem, err := txn.Get(nextKey)
-				if err == nil && !errors.Is(err, badger.ErrKeyNotFound) {
-					if err := nextItem.Value(func(val []byte) error {
-						meta.FirstID = ts
-						meta.FirstSeq = seq + 1
-						return nil
-					}); err != nil {
-						return err
-					}
-				} else {
-					// Need to scan forward
-					prefix := streamDataPrefix(key)
-					it := txn.NewIterator(badger.DefaultIteratorOptions)
-					defer it.Close()
-
-					found := false
-					for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-						nextEntryID := string(bytes.TrimPrefix(it.Item().Key(), prefix))
-						nextTS, nextSeq, _ := parseStreamID(nextEntryID)
-						meta.FirstID = nextTS
-						meta.FirstSeq = nextSeq
-						found = true
-						break
-					}
-					if !found {
-						// Stream is empty
-						meta.FirstID = meta.LastID
-						meta.FirstSeq = meta.LastSeq
-					}
-				}
-			}
+		// Check if entry exists
+		_, err := txn.Get(dataKey)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, err
 		}
 
-		meta.Length -= deleted
-		if meta.Length == 0 {
-			// Clear metadata
-			meta.FirstID = 0
-			meta.FirstSeq = 0
+		// Delete entry
+		if err := txn.Delete(dataKey); err != nil {
+			return 0, err
 		}
+		deleted++
 
-		// Save metadata
-		if err := txn.Set(metaKey, encodeStreamMeta(meta)); err != nil {
-			return err
+		// Update max deleted ID if needed
+		if ts > meta.MaxDeletedID || (ts == meta.MaxDeletedID && seq > meta.MaxDelSeq) {
+			meta.MaxDeletedID = ts
+			meta.MaxDelSeq = seq
 		}
+		if ts == meta.FirstID && seq == meta.FirstSeq {
+			firstDeleted = true
+		}
+	}
 
-		return nil
-	})
+	// 删除了第一条记录时，记录键按 ID 排序，剩下的第一个键就是新的首个 ID
+	if firstDeleted {
+		prefix := streamDataPrefix(key)
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		// 没有剩余记录时与之前一样使用最后一个 ID
+		meta.FirstID, meta.FirstSeq = meta.LastID, meta.LastSeq
+		for it.Rewind(); it.Valid(); it.Next() {
+			if ts, seq, ok := streamDataKeyID(prefix, it.Item().Key()); ok {
+				meta.FirstID, meta.FirstSeq = ts, seq
+				break
+			}
+		}
+		it.Close()
+	}
 
-	return deleted, err
+	meta.Length -= deleted
+	if meta.Length == 0 {
+		// Clear metadata
+		meta.FirstID = 0
+		meta.FirstSeq = 0
+	}
+
+	// Save metadata
+	if err := txn.Set(metaKey, encodeStreamMeta(meta)); err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
 }
 
 // XInfo returns stream information
 func (s *BotreonStore) XInfo(key string) (*StreamInfo, error) {
 	var info StreamInfo
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		metaKey := streamKey(key)
 		item, err := txn.Get(metaKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -891,107 +887,20 @@ func (s *BotreonStore) XInfo(key string) (*StreamInfo, error) {
 	return &info, err
 }
 
-// XTrim trims a stream
-func (s *BotreonStore) XTrim(key string, maxLen int64, minID string) (int64, error) {
+// XTrim trims a stream, returning the number of deleted entries
+func (s *BotreonStore) XTrim(key string, opts StreamTrimOptions) (int64, error) {
 	var trimmed int64
 
-	err := s.db.Update(func(txn *badger.Txn) error {
-		metaKey := streamKey(key)
-		var meta *streamMetaData
-
-		item, err := txn.Get(metaKey)
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return nil
-		}
-		if err != nil {
-			return err
-		}
-		if err := item.Value(func(val []byte) error {
-			meta, err = decodeStreamMeta(val)
-			return err
-		}); err != nil {
+	err := s.update(func(txn *storeTxn) error {
+		meta, err := streamMetaTxn(txn, key)
+		if err != nil || meta == nil {
 			return err
 		}
-
-		prefix := streamDataPrefix(key)
-		it := txn.NewIterator(badger.DefaultIteratorOptions)
-		defer it.Close()
-
-		var entriesToDelete []string
-
-		if maxLen > 0 && meta.Length > maxLen {
-			// Delete oldest entries
-			entriesToDelete = append(entriesToDelete, "")
-			count := int64(0)
-			for it.Seek(prefix); it.ValidForPrefix(prefix) && count < meta.Length-maxLen; it.Next() {
-				id := string(bytes.TrimPrefix(it.Item().Key(), prefix))
-				entriesToDelete = append(entriesToDelete, id)
-				ts, seq, _ := parseStreamID(id)
-				if ts > meta.MaxDeletedID || (ts == meta.MaxDeletedID && seq > meta.MaxDelSeq) {
-					meta.MaxDeletedID = ts
-					meta.MaxDelSeq = seq
-				}
-				count++
-			}
-		}
-
-		if minID != "" {
-			// Delete entries before minID
-			minTS, minSeq, _ := parseStreamID(minID)
-			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-				id := string(bytes.TrimPrefix(it.Item().Key(), prefix))
-				ts, seq, _ := parseStreamID(id)
-				if ts < minTS || (ts == minTS && seq < minSeq) {
-					entriesToDelete = append(entriesToDelete, id)
-					if ts > meta.MaxDeletedID || (ts == meta.MaxDeletedID && seq > meta.MaxDelSeq) {
-						meta.MaxDeletedID = ts
-						meta.MaxDelSeq = seq
-					}
-				} else {
-					break
-				}
-			}
-		}
-
-		// Delete entries
-		for _, id := range entriesToDelete[1:] {
-			if id == "" {
-				continue
-			}
-			dataKey := streamDataKey(key, id)
-			if err := txn.Delete(dataKey); err != nil {
-				return err
-			}
-			trimmed++
-		}
-
-		meta.Length -= trimmed
-		if meta.Length == 0 {
-			meta.FirstID = meta.LastID
-			meta.FirstSeq = meta.LastSeq
-		} else if trimmed > 0 {
-			// Update first ID
-			nextKey := streamDataPrefix(key)
-			nextItem, err := txn.Get(nextKey)
-			if err == nil && !errors.Is(err, badger.ErrKeyNotFound) {
-				if err := nextItem.Value(func(val []byte) error {
-					nextID := string(bytes.TrimPrefix(nextKey, prefix))
-					nextTS, nextSeq, _ := parseStreamID(nextID)
-					meta.FirstID = nextTS
-					meta.FirstSeq = nextSeq
-					return nil
-				}); err != nil {
-					return err
-				}
-			}
-		}
-
-		// Save metadata
-		if err := txn.Set(metaKey, encodeStreamMeta(meta)); err != nil {
+		trimmed, err = trimStreamTxn(txn, key, meta, opts)
+		if err != nil || trimmed == 0 {
 			return err
 		}
-
-		return nil
+		return txn.Set(streamKey(key), encodeStreamMeta(meta))
 	})
 
 	return trimmed, err
@@ -999,11 +908,15 @@ func (s *BotreonStore) XTrim(key string, maxLen int64, minID string) (int64, err
 
 // XGroupCreate creates a consumer group
 func (s *BotreonStore) XGroupCreate(key, group, startID string) error {
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.update(func(txn *storeTxn) error {
+		lastID, err := streamGroupStartIDTxn(txn, key, startID)
+		if err != nil {
+			return err
+		}
 		groupKey := streamGroupDataKey(key, group)
 		groupData := &StreamGroup{
 			Name:           group,
-			LastDeliveredID: startID,
+			LastDeliveredID: lastID,
 			Consumers:      make(map[string]*StreamConsumer),
 			Pending:        make(map[string]*StreamPendingEntry),
 		}
@@ -1015,10 +928,27 @@ func (s *BotreonStore) XGroupCreate(key, group, startID string) error {
 	})
 }
 
+// streamGroupStartIDTxn 解析 XGROUP CREATE / SETID 的 ID：$ 表示 Stream 当前的最后一个 ID
+// （Stream 不存在时为 0-0），其余 ID 统一为 <ms>-<seq>
+func streamGroupStartIDTxn(txn *storeTxn, key, id string) (string, error) {
+	if id == "$" {
+		meta, err := streamMetaTxn(txn, key)
+		if err != nil || meta == nil {
+			return formatStreamID(0, 0), err
+		}
+		return formatStreamID(meta.LastID, meta.LastSeq), nil
+	}
+	ts, seq, err := parseStreamID(id)
+	if err != nil || id == "*" {
+		return "", ErrStreamInvalidID
+	}
+	return formatStreamID(ts, seq), nil
+}
+
 // XGroupDelConsumer removes a consumer from a group
 func (s *BotreonStore) XGroupDelConsumer(key, group, consumer string) (int64, error) {
 	var removed int64
-	err := s.db.Update(func(txn *badger.Txn) error {
+	err := s.update(func(txn *storeTxn) error {
 		groupKey := streamGroupDataKey(key, group)
 		item, err := txn.Get(groupKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -1058,7 +988,7 @@ func (s *BotreonStore) XGroupDelConsumer(key, group, consumer string) (int64, er
 
 // XGroupDestroy destroys a consumer group
 func (s *BotreonStore) XGroupDestroy(key, group string) error {
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.update(func(txn *storeTxn) error {
 		groupKey := streamGroupDataKey(key, group)
 		return txn.Delete(groupKey)
 	})
@@ -1066,7 +996,7 @@ func (s *BotreonStore) XGroupDestroy(key, group string) error {
 
 // XGroupSetID sets the last delivered ID for a group
 func (s *BotreonStore) XGroupSetID(key, group, id string) error {
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.update(func(txn *storeTxn) error {
 		groupKey := streamGroupDataKey(key, group)
 		item, err := txn.Get(groupKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -1083,7 +1013,11 @@ func (s *BotreonStore) XGroupSetID(key, group, id string) error {
 			return err
 		}
 
-		groupData.LastDeliveredID = id
+		lastID, err := streamGroupStartIDTxn(txn, key, id)
+		if err != nil {
+			return err
+		}
+		groupData.LastDeliveredID = lastID
 		data, err := json.Marshal(groupData)
 		if err != nil {
 			return err
@@ -1092,129 +1026,295 @@ func (s *BotreonStore) XGroupSetID(key, group, id string) error {
 	})
 }
 
-// XReadGroup reads from a consumer group
-func (s *BotreonStore) XReadGroup(group, consumer string, count int64, block int64, keys ...string) ([]map[string][]StreamEntry, error) {
-	result := make([]map[string][]StreamEntry, 0)
-
-	err := s.db.Update(func(txn *badger.Txn) error {
-		now := time.Now().UnixNano() / int64(time.Millisecond)
-
-		for _, key := range keys {
-			// Get group info
-			groupKey := streamGroupDataKey(key, group)
-			item, err := txn.Get(groupKey)
-			if errors.Is(err, badger.ErrKeyNotFound) {
-				return nil
-			}
-			if err != nil {
-				return err
-			}
-
-			var groupData *StreamGroup
-			if err := item.Value(func(val []byte) error {
-				return json.Unmarshal(val, &groupData)
-			}); err != nil {
-				return err
-			}
-
-			// Ensure consumers map exists
-			if groupData.Consumers == nil {
-				groupData.Consumers = make(map[string]*StreamConsumer)
-			}
+// XReadGroupOptions XREADGROUP 的选项
+type XReadGroupOptions struct {
+	Count int64 // 每个 Stream 最多返回的条目数，0 表示不限
+	NoAck bool  // 读取新条目时不加入待确认列表（PEL）
+	Block   bool  // 以 > 读取且没有新条目时等待 XADD
+	Timeout int64 // 阻塞的最长毫秒数，0 表示一直等待
+}
 
-			// Update or add consumer
-			if _, exists := groupData.Consumers[consumer]; !exists {
-				groupData.Consumers[consumer] = &StreamConsumer{
-					Name:     consumer,
-					LastSeen: now,
-				}
-			} else {
-				groupData.Consumers[consumer].LastSeen = now
-			}
+// StreamReadGroupResult 一个 Stream 的 XREADGROUP 结果
+type StreamReadGroupResult struct {
+	Key     string
+	Entries []StreamEntry // 读取历史时已被删除的条目 Fields 为 nil
+	History bool          // 是否是按 ID 读取消费者自己的待确认条目
+}
 
-			// Ensure pending map exists
-			if groupData.Pending == nil {
-				groupData.Pending = make(map[string]*StreamPendingEntry)
-			}
+// ErrStreamNoGroup 键不存在或没有该消费组，完整的回复由 XReadGroupStreams 等返回的包装错误给出
+var ErrStreamNoGroup = errors.New("NOGROUP")
 
-			// Get entries after last delivered ID
-			entries := make([]StreamEntry, 0)
-			prefix := streamDataPrefix(key)
-			it := txn.NewIterator(badger.DefaultIteratorOptions)
-			defer it.Close()
+// streamGroupTxn 读取消费组，键或消费组不存在时返回 nil
+func streamGroupTxn(txn *storeTxn, key, group string) (*StreamGroup, error) {
+	item, err := txn.Get(streamGroupDataKey(key, group))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var groupData *StreamGroup
+	if err := item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &groupData)
+	}); err != nil {
+		return nil, err
+	}
+	if groupData.Consumers == nil {
+		groupData.Consumers = make(map[string]*StreamConsumer)
+	}
+	if groupData.Pending == nil {
+		groupData.Pending = make(map[string]*StreamPendingEntry)
+	}
+	return groupData, nil
+}
 
-			lastTS, lastSeq, _ := parseStreamID(groupData.LastDeliveredID)
-			var lastID string
+// streamEntryTxn 读取一个条目，不存在时返回 nil
+func streamEntryTxn(txn *storeTxn, key, id string) (*StreamEntry, error) {
+	dataKey := streamEntryIDKey(key, id)
+	if dataKey == nil {
+		return nil, nil
+	}
+	item, err := txn.Get(dataKey)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]string
+	if err := item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &fields)
+	}); err != nil {
+		return nil, err
+	}
+	ts, seq, _ := parseStreamID(id)
+	return &StreamEntry{ID: formatStreamID(ts, seq), Fields: fields, Timestamp: ts, Sequence: seq}, nil
+}
 
-			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-				item := it.Item()
-				id := string(bytes.TrimPrefix(item.Key(), prefix))
+// XReadGroup 以 > 读取每个 Stream 中尚未投递给消费组的条目
+func (s *BotreonStore) XReadGroup(group, consumer string, count int64, block int64, keys ...string) ([]map[string][]StreamEntry, error) {
+	ids := make([]string, len(keys))
+	for i := range ids {
+		ids[i] = ">"
+	}
+	results, err := s.XReadGroupStreams(group, consumer, XReadGroupOptions{Count: count}, keys, ids)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string][]StreamEntry, 0, len(results))
+	for _, r := range results {
+		if len(r.Entries) > 0 {
+			result = append(result, map[string][]StreamEntry{r.Key: r.Entries})
+		}
+	}
+	return result, nil
+}
 
-				ts, seq, _ := parseStreamID(id)
-				if ts < lastTS || (ts == lastTS && seq <= lastSeq) {
-					continue
-				}
+// XReadGroupStreams 实现 XREADGROUP，在一个事务中完成所有 Stream 的读取与消费组的更新。
+// ID 为 > 时返回 LastDeliveredID 之后的新条目，推进 LastDeliveredID，并把条目加入该消费者的待确认列表
+// （NOACK 时不加入，已在列表中的条目改归该消费者、投递次数重置为 1）；没有新条目的 Stream 不出现在结果中。
+// 其他 ID 返回该消费者待确认列表中大于该 ID 的条目并递增投递次数，结果中总是包含该 Stream。
+// 消费者不存在时创建，并更新最近活动时间。任一键或消费组不存在时返回包装 ErrStreamNoGroup 的错误。
+// 带 Block 且有 Stream 以 > 读取时，没有新条目则等待 XADD 唤醒后重新读取，超时返回空结果
+func (s *BotreonStore) XReadGroupStreams(group, consumer string, opts XReadGroupOptions, keys, ids []string) ([]StreamReadGroupResult, error) {
+	results, err := s.xReadGroupOnce(group, consumer, opts, keys, ids)
+	if err != nil || len(results) > 0 || !opts.Block {
+		return results, err
+	}
+	// 只读取历史的请求总是有结果，走到这里说明至少有一个 >，需要阻塞等待
+	release, err := s.acquireBlocking(keys)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
-				var fields map[string]string
-				if err := item.Value(func(val []byte) error {
-					return json.Unmarshal(val, &fields)
-				}); err != nil {
-					return err
-				}
+	wake := make(chan struct{}, 1)
+	s.streamBlockingMu.Lock()
+	for _, key := range keys {
+		s.streamBlockingChans[key] = append(s.streamBlockingChans[key], wake)
+	}
+	s.streamBlockingMu.Unlock()
+	defer s.unregisterStreamRead(keys, wake)
 
-				entries = append(entries, StreamEntry{
-					ID:        id,
-					Fields:    fields,
-					Timestamp: ts,
-					Sequence:  seq,
-				})
+	var timeoutCh <-chan time.Time
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(time.Duration(opts.Timeout) * time.Millisecond)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
 
-				// Add to pending if not already pending
-				if _, pending := groupData.Pending[id]; !pending {
-					groupData.Pending[id] = &StreamPendingEntry{
-						ID:            id,
-						Consumer:      consumer,
-						DeliveryCount: 1,
-						LastDelivery:  now,
-					}
-				}
+	for {
+		// 登记之后再读一次，覆盖第一次读取与登记之间的 XADD。
+		// 同组的多个消费者被同一次 XADD 唤醒时，事务冲突保证每个条目只投递给其中一个
+		results, err := s.xReadGroupOnce(group, consumer, opts, keys, ids)
+		if err != nil || len(results) > 0 {
+			return results, err
+		}
+		select {
+		case <-wake:
+		case <-timeoutCh:
+			return nil, nil
+		}
+	}
+}
 
-				lastID = id
+// xReadGroupOnce 不阻塞地执行一次 XREADGROUP，事务冲突时重试
+func (s *BotreonStore) xReadGroupOnce(group, consumer string, opts XReadGroupOptions, keys, ids []string) ([]StreamReadGroupResult, error) {
+	for _, id := range ids {
+		if id == ">" {
+			continue
+		}
+		if _, _, err := parseStreamID(id); err != nil || id == "*" {
+			return nil, ErrStreamInvalidID
+		}
+	}
+	var results []StreamReadGroupResult
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		results = results[:0]
+		now := s.now().UnixMilli()
+		groups := make([]*StreamGroup, len(keys))
+		for i, key := range keys {
+			groupData, err := streamGroupTxn(txn, key, group)
+			if err != nil {
+				return err
+			}
+			if groupData == nil {
+				return fmt.Errorf("%w No such key '%s' or consumer group '%s' in XREADGROUP with GROUP option", ErrStreamNoGroup, key, group)
+			}
+			groups[i] = groupData
+		}
 
-				if count > 0 && int64(len(entries)) >= count {
-					break
-				}
+		for i, key := range keys {
+			groupData := groups[i]
+			if c, ok := groupData.Consumers[consumer]; ok {
+				c.LastSeen = now
+			} else {
+				groupData.Consumers[consumer] = &StreamConsumer{Name: consumer, LastSeen: now}
 			}
 
-			// Update last delivered ID
-			if lastID != "" {
-				groupData.LastDeliveredID = lastID
+			var entries []StreamEntry
+			var err error
+			if ids[i] == ">" {
+				entries, err = s.readGroupNewTxn(txn, key, groupData, consumer, opts, now)
+			} else {
+				entries, err = s.readGroupHistoryTxn(txn, key, groupData, consumer, ids[i], opts.Count, now)
+			}
+			if err != nil {
+				return err
 			}
 
-			// Save updated group data
 			data, err := json.Marshal(groupData)
 			if err != nil {
 				return err
 			}
-			if err := txn.Set(groupKey, data); err != nil {
+			if err := txn.Set(streamGroupDataKey(key, group), data); err != nil {
 				return err
 			}
-
-			if len(entries) > 0 {
-				result = append(result, map[string][]StreamEntry{key: entries})
-			}
+			results = append(results, StreamReadGroupResult{Key: key, Entries: entries, History: ids[i] != ">"})
 		}
 		return nil
+	}, 30)
+	if err != nil {
+		return nil, err
+	}
+	// 结果中省略没有新条目的 Stream
+	filtered := results[:0]
+	for _, r := range results {
+		if r.History || len(r.Entries) > 0 {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered, nil
+}
+
+// readGroupNewTxn 读取 LastDeliveredID 之后的条目，推进 LastDeliveredID 并登记待确认条目
+func (s *BotreonStore) readGroupNewTxn(txn *storeTxn, key string, groupData *StreamGroup, consumer string, opts XReadGroupOptions, now int64) ([]StreamEntry, error) {
+	lastTS, lastSeq, err := parseStreamID(groupData.LastDeliveredID)
+	if err != nil {
+		return nil, fmt.Errorf("consumer group %s: last delivered ID %q: %w", groupData.Name, groupData.LastDeliveredID, err)
+	}
+	prefix := streamDataPrefix(key)
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	// 条目键按 ID 排序，从 LastDeliveredID 开始读取，跳过等于它的那一条
+	var entries []StreamEntry
+	for it.Seek(streamDataKey(key, lastTS, lastSeq)); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		ts, seq, ok := streamDataKeyID(prefix, item.Key())
+		if !ok || !streamIDGreater(ts, seq, lastTS, lastSeq) {
+			continue
+		}
+		var fields map[string]string
+		if err := item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &fields)
+		}); err != nil {
+			return nil, err
+		}
+		entries = append(entries, StreamEntry{ID: formatStreamID(ts, seq), Fields: fields, Timestamp: ts, Sequence: seq})
+		if opts.Count > 0 && int64(len(entries)) >= opts.Count {
+			break
+		}
+	}
+
+	for _, entry := range entries {
+		if opts.NoAck {
+			continue
+		}
+		groupData.Pending[entry.ID] = &StreamPendingEntry{
+			ID:            entry.ID,
+			Consumer:      consumer,
+			DeliveryCount: 1,
+			LastDelivery:  now,
+		}
+	}
+	if len(entries) > 0 {
+		groupData.LastDeliveredID = entries[len(entries)-1].ID
+	}
+	return entries, nil
+}
+
+// readGroupHistoryTxn 按 ID 顺序返回消费者待确认列表中大于 after 的条目，并递增投递次数。
+// 已被 XDEL 或裁剪删除的条目仍返回 ID，Fields 为 nil
+func (s *BotreonStore) readGroupHistoryTxn(txn *storeTxn, key string, groupData *StreamGroup, consumer, after string, count, now int64) ([]StreamEntry, error) {
+	afterTS, afterSeq, _ := parseStreamID(after)
+	var pending []*StreamPendingEntry
+	for id, p := range groupData.Pending {
+		ts, seq, _ := parseStreamID(id)
+		if p.Consumer == consumer && streamIDGreater(ts, seq, afterTS, afterSeq) {
+			pending = append(pending, p)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return compareStreamID(pending[i].ID, pending[j].ID) < 0
 	})
+	if count > 0 && int64(len(pending)) > count {
+		pending = pending[:count]
+	}
 
-	return result, err
+	entries := make([]StreamEntry, 0, len(pending))
+	for _, p := range pending {
+		entry, err := streamEntryTxn(txn, key, p.ID)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			ts, seq, _ := parseStreamID(p.ID)
+			entries = append(entries, StreamEntry{ID: p.ID, Timestamp: ts, Sequence: seq})
+			continue
+		}
+		p.DeliveryCount++
+		p.LastDelivery = now
+		entries = append(entries, *entry)
+	}
+	return entries, nil
 }
 
 // XAck acknowledges messages in a stream
 func (s *BotreonStore) XAck(key, group string, ids ...string) (int64, error) {
 	var acknowledged int64
 
-	err := s.db.Update(func(txn *badger.Txn) error {
+	err := s.update(func(txn *storeTxn) error {
 		groupKey := streamGroupDataKey(key, group)
 		item, err := txn.Get(groupKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -1232,6 +1332,7 @@ func (s *BotreonStore) XAck(key, group string, ids ...string) (int64, error) {
 		}
 
 		for _, id := range ids {
+			id = normalizeStreamID(id)
 			if _, exists := groupData.Pending[id]; exists {
 				delete(groupData.Pending, id)
 				acknowledged++
@@ -1252,7 +1353,7 @@ func (s *BotreonStore) XAck(key, group string, ids ...string) (int64, error) {
 func (s *BotreonStore) XPending(key, group string) ([]StreamPendingEntry, error) {
 	var pending []StreamPendingEntry
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		groupKey := streamGroupDataKey(key, group)
 		item, err := txn.Get(groupKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -1278,11 +1379,49 @@ func (s *BotreonStore) XPending(key, group string) ([]StreamPendingEntry, error)
 	return pending, err
 }
 
+// StreamPendingSummary XPENDING 不带范围时的摘要，Consumers 按消费者名称排序
+type StreamPendingSummary struct {
+	Count     int64
+	MinID     string
+	MaxID     string
+	Consumers []StreamConsumerPending
+}
+
+// StreamConsumerPending 消费者的待确认条目数
+type StreamConsumerPending struct {
+	Name  string
+	Count int64
+}
+
+// XPendingSummary 返回消费组待确认条目的数量、最小与最大 ID 以及每个消费者的条目数
+func (s *BotreonStore) XPendingSummary(key, group string) (*StreamPendingSummary, error) {
+	pending, err := s.XPending(key, group)
+	if err != nil {
+		return nil, err
+	}
+	summary := &StreamPendingSummary{Count: int64(len(pending))}
+	counts := make(map[string]int64)
+	for _, p := range pending {
+		if summary.MinID == "" || compareStreamID(p.ID, summary.MinID) < 0 {
+			summary.MinID = p.ID
+		}
+		if summary.MaxID == "" || compareStreamID(p.ID, summary.MaxID) > 0 {
+			summary.MaxID = p.ID
+		}
+		counts[p.Consumer]++
+	}
+	for name, n := range counts {
+		summary.Consumers = append(summary.Consumers, StreamConsumerPending{Name: name, Count: n})
+	}
+	sort.Slice(summary.Consumers, func(i, j int) bool { return summary.Consumers[i].Name < summary.Consumers[j].Name })
+	return summary, nil
+}
+
 // XClaim claims pending messages
 func (s *BotreonStore) XClaim(key, group, consumer string, minIdleTime int64, ids ...string) ([]string, error) {
 	var claimed []string
 
-	err := s.db.Update(func(txn *badger.Txn) error {
+	err := s.update(func(txn *storeTxn) error {
 		groupKey := streamGroupDataKey(key, group)
 		item, err := txn.Get(groupKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -1299,9 +1438,10 @@ func (s *BotreonStore) XClaim(key, group, consumer string, minIdleTime int64, id
 			return err
 		}
 
-		now := time.Now().UnixNano() / int64(time.Millisecond)
+		now := s.now().UnixNano() / int64(time.Millisecond)
 
 		for _, id := range ids {
+			id = normalizeStreamID(id)
 			if p, exists := groupData.Pending[id]; exists {
 				if minIdleTime > 0 {
 					idleTime := now - p.LastDelivery
@@ -1330,7 +1470,7 @@ func (s *BotreonStore) XClaim(key, group, consumer string, minIdleTime int64, id
 func (s *BotreonStore) XInfoGroups(key string) ([]*StreamGroup, error) {
 	var groups []*StreamGroup
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		prefix := streamGroupKey(key)
 		it := txn.NewIterator(badger.DefaultIteratorOptions)
 		defer it.Close()
@@ -1355,7 +1495,7 @@ func (s *BotreonStore) XInfoGroups(key string) ([]*StreamGroup, error) {
 func (s *BotreonStore) XInfoConsumers(key, group string) ([]*StreamConsumer, error) {
 	var consumers []*StreamConsumer
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		groupKey := streamGroupDataKey(key, group)
 		item, err := txn.Get(groupKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -1384,7 +1524,7 @@ func (s *BotreonStore) XInfoConsumers(key, group string) ([]*StreamConsumer, err
 // StreamType checks if a key is a stream
 func (s *BotreonStore) StreamType(key string) (bool, error) {
 	var exists bool
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		metaKey := streamKey(key)
 		_, err := txn.Get(metaKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -1404,8 +1544,11 @@ func (s *BotreonStore) StreamType(key string) (bool, error) {
 func (s *BotreonStore) GetStreamEntry(key, id string) (*StreamEntry, error) {
 	var entry *StreamEntry
 
-	err := s.db.View(func(txn *badger.Txn) error {
-		dataKey := streamDataKey(key, id)
+	err := s.view(func(txn *storeTxn) error {
+		dataKey := streamEntryIDKey(key, id)
+		if dataKey == nil {
+			return fmt.Errorf("ERR no such entry")
+		}
 		item, err := txn.Get(dataKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
 			return fmt.Errorf("ERR no such entry")
@@ -1423,7 +1566,7 @@ func (s *BotreonStore) GetStreamEntry(key, id string) (*StreamEntry, error) {
 
 		ts, seq, _ := parseStreamID(id)
 		entry = &StreamEntry{
-			ID:        id,
+			ID:        formatStreamID(ts, seq),
 			Fields:    fields,
 			Timestamp: ts,
 			Sequence:  seq,
@@ -1445,14 +1588,28 @@ type XAutoClaimResult struct {
 	NextID    string
 	ClaimedIDs []string
 	Messages  []StreamEntry
+	// DeletedIDs 待确认但已从流中删除的条目，扫描时从待确认列表移除
+	DeletedIDs []string
 }
 
+// xautoclaimAttemptsFactor 与 Redis 相同，每次最多扫描 COUNT 的 10 倍个待确认条目
+const xautoclaimAttemptsFactor = 10
+
 // XAutoClaim automatically claims pending messages
 // XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID]
+// 按 ID 顺序从 start（含）开始扫描待确认条目，NextID 是下次扫描的起点，扫描到末尾时为 0-0
 func (s *BotreonStore) XAutoClaim(key, group, consumer string, minIdleTime int64, start string, opts XAutoClaimOptions) (*XAutoClaimResult, error) {
-	var result XAutoClaimResult
+	result := XAutoClaimResult{NextID: "0-0"}
+
+	startTS, startSeq := int64(0), int64(0)
+	if start != "-" {
+		var err error
+		if startTS, startSeq, err = parseStreamID(start); err != nil || start == "*" {
+			return nil, ErrStreamInvalidID
+		}
+	}
 
-	err := s.db.Update(func(txn *badger.Txn) error {
+	err := s.update(func(txn *storeTxn) error {
 		groupKey := streamGroupDataKey(key, group)
 		item, err := txn.Get(groupKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -1469,76 +1626,249 @@ func (s *BotreonStore) XAutoClaim(key, group, consumer string, minIdleTime int64
 			return err
 		}
 
-		now := time.Now().UnixNano() / int64(time.Millisecond)
-
-		// Parse start ID
-		startTS, startSeq, _ := parseStreamID(start)
+		now := s.now().UnixNano() / int64(time.Millisecond)
 
-		// Find pending entries that meet the idle time requirement
-		for id, pending := range groupData.Pending {
+		ids := make([]string, 0, len(groupData.Pending))
+		for id := range groupData.Pending {
 			idTS, idSeq, _ := parseStreamID(id)
+			if idTS < startTS || (idTS == startTS && idSeq < startSeq) {
+				continue
+			}
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return compareStreamID(ids[i], ids[j]) < 0 })
 
-			// Skip if ID is before start
-			if idTS < startTS || (idTS == startTS && idSeq <= startSeq) {
+		attempts := int64(len(ids))
+		if opts.Count > 0 {
+			attempts = opts.Count * xautoclaimAttemptsFactor
+		}
+		scanned := 0
+		for _, id := range ids {
+			if attempts == 0 || (opts.Count > 0 && int64(len(result.ClaimedIDs)) >= opts.Count) {
+				break
+			}
+			attempts--
+			scanned++
+
+			pending := groupData.Pending[id]
+			if now-pending.LastDelivery < minIdleTime {
 				continue
 			}
 
-			// Check idle time
-			idleTime := now - pending.LastDelivery
-			if idleTime < minIdleTime {
+			idTS, idSeq, _ := parseStreamID(id)
+			msgItem, err := txn.Get(streamDataKey(key, idTS, idSeq))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				delete(groupData.Pending, id)
+				result.DeletedIDs = append(result.DeletedIDs, id)
 				continue
 			}
+			if err != nil {
+				return err
+			}
 
 			// Claim the entry
 			pending.Consumer = consumer
 			pending.LastDelivery = now
 			pending.DeliveryCount++
-
 			result.ClaimedIDs = append(result.ClaimedIDs, id)
 
-			// Get the message content
-			dataKey := streamDataKey(key, id)
-			msgItem, err := txn.Get(dataKey)
-			if err == nil && !errors.Is(err, badger.ErrKeyNotFound) {
-				var fields map[string]string
-				if err := msgItem.Value(func(val []byte) error {
-					return json.Unmarshal(val, &fields)
-				}); err == nil {
-					result.Messages = append(result.Messages, StreamEntry{
-						ID:        id,
-						Fields:    fields,
-						Timestamp: idTS,
-						Sequence:  idSeq,
-					})
+			if opts.JustID {
+				continue
+			}
+			var fields map[string]string
+			if err := msgItem.Value(func(val []byte) error {
+				return json.Unmarshal(val, &fields)
+			}); err != nil {
+				return err
+			}
+			result.Messages = append(result.Messages, StreamEntry{
+				ID:        id,
+				Fields:    fields,
+				Timestamp: idTS,
+				Sequence:  idSeq,
+			})
+		}
+
+		// 下次从第一个未扫描的条目开始
+		if scanned < len(ids) {
+			result.NextID = ids[scanned]
+		}
+
+		if len(result.ClaimedIDs) == 0 && len(result.DeletedIDs) == 0 {
+			return nil
+		}
+		data, err := json.Marshal(groupData)
+		if err != nil {
+			return err
+		}
+		return txn.Set(groupKey, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// streamMigrateBatch 迁移旧版 Stream 记录键时每个事务转换的条数
+const streamMigrateBatch = 1000
+
+// migrateLegacyStreams 将旧版本以 ID 字符串为后缀保存的 Stream 记录键（stream:<key>:data:<id>）
+// 转换为按 ID 排序的编码，并把消费组中的 ID 统一为 <ms>-<seq> 格式。启动时调用
+func (s *BotreonStore) migrateLegacyStreams() error {
+	var keys []string
+	err := s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefixKeyTypeBytes
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			item := iter.Item()
+			isStream := false
+			if err := item.Value(func(val []byte) error {
+				isStream = string(val) == KeyTypeStream
+				return nil
+			}); err != nil {
+				return err
+			}
+			if !isStream {
+				continue
+			}
+			key := string(item.Key()[len(prefixKeyTypeBytes):])
+			legacy := streamLegacyDataPrefix(key)
+			dataOpts := badger.DefaultIteratorOptions
+			dataOpts.PrefetchValues = false
+			dataOpts.Prefix = legacy
+			it := txn.NewIterator(dataOpts)
+			it.Rewind()
+			if it.Valid() {
+				keys = append(keys, key)
+			}
+			it.Close()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.migrateLegacyStream(key); err != nil {
+			return fmt.Errorf("migrate stream %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// migrateLegacyStream 分批转换一个 Stream 的记录键，中断后再次启动时从剩余的旧键继续
+func (s *BotreonStore) migrateLegacyStream(key string) error {
+	legacy := streamLegacyDataPrefix(key)
+	seek := legacy
+	for seek != nil {
+		err := s.update(func(txn *storeTxn) error {
+			opts := badger.DefaultIteratorOptions
+			opts.Prefix = legacy
+			it := txn.NewIterator(opts)
+			type entry struct {
+				newKey, oldKey, value []byte
+			}
+			var batch []entry
+			next := seek
+			seek = nil
+			for it.Seek(next); it.Valid(); it.Next() {
+				if len(batch) == streamMigrateBatch {
+					seek = it.Item().KeyCopy(nil)
+					break
+				}
+				item := it.Item()
+				// 无法解析的键属于键名以 "<key>:data:" 开头的另一个 Stream，留给它自己迁移
+				newKey := streamEntryIDKey(key, string(item.Key()[len(legacy):]))
+				if newKey == nil {
+					continue
+				}
+				value, err := item.ValueCopy(nil)
+				if err != nil {
+					it.Close()
+					return err
 				}
+				batch = append(batch, entry{newKey: newKey, oldKey: item.KeyCopy(nil), value: value})
 			}
+			it.Close()
 
-			// Check count limit
-			if opts.Count > 0 && int64(len(result.ClaimedIDs)) >= opts.Count {
-				break
+			for _, e := range batch {
+				if err := txn.Set(e.newKey, e.value); err != nil {
+					return err
+				}
+				if err := txn.Delete(e.oldKey); err != nil {
+					return err
+				}
 			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
+	}
 
-		// Update the next start ID
-		if len(result.ClaimedIDs) > 0 {
-			lastID := result.ClaimedIDs[len(result.ClaimedIDs)-1]
-			lastTS, lastSeq, _ := parseStreamID(lastID)
-			if lastSeq > 0 {
-				result.NextID = formatStreamID(lastTS, lastSeq)
-			} else {
-				result.NextID = formatStreamID(lastTS, lastSeq)
+	return s.update(func(txn *storeTxn) error {
+		prefix := streamGroupDataPrefix(key)
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		groups := make(map[string]*StreamGroup)
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			var groupData *StreamGroup
+			if err := item.Value(func(val []byte) error {
+				return json.Unmarshal(val, &groupData)
+			}); err != nil {
+				it.Close()
+				return err
 			}
-		} else {
-			result.NextID = start
+			groups[string(item.Key())] = groupData
 		}
+		it.Close()
 
-		// Save group data
-		data, err := json.Marshal(groupData)
+		lastID, err := streamGroupStartIDTxn(txn, key, "$")
 		if err != nil {
 			return err
 		}
-		return txn.Set(groupKey, data)
+		for groupKey, groupData := range groups {
+			normalizeStreamGroupIDs(groupData, lastID)
+			data, err := json.Marshal(groupData)
+			if err != nil {
+				return err
+			}
+			if err := txn.Set([]byte(groupKey), data); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
+}
 
-	return &result, err
+// normalizeStreamGroupIDs 把旧版本写入的 <ms> 格式 ID 统一为 <ms>-<seq>，
+// 旧版本原样保存的 $ 解析为 lastID（创建时的位置已无法得知，以迁移时为准）
+func normalizeStreamGroupIDs(groupData *StreamGroup, lastID string) {
+	if groupData.LastDeliveredID == "$" {
+		groupData.LastDeliveredID = lastID
+	}
+	groupData.LastDeliveredID = normalizeStreamID(groupData.LastDeliveredID)
+	pending := make(map[string]*StreamPendingEntry, len(groupData.Pending))
+	for id, p := range groupData.Pending {
+		id = normalizeStreamID(id)
+		p.ID = id
+		pending[id] = p
+	}
+	groupData.Pending = pending
+}
+
+// normalizeStreamID 将 ID 统一为 <ms>-<seq> 格式，无法解析的 ID 原样返回
+func normalizeStreamID(id string) string {
+	if id == "*" {
+		return id
+	}
+	ts, seq, err := parseStreamID(id)
+	if err != nil {
+		return id
+	}
+	return formatStreamID(ts, seq)
 }
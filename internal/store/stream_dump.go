@@ -0,0 +1,207 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// rdbTypeBoltStream DUMP 中 Stream 的类型号。
+// 这是 BoltDB 私有的编码（与 Redis 的 listpack 格式不兼容），只用于 BoltDB 实例之间迁移，
+// 除了条目之外还携带消费者组、消费者、last-delivered-id 和 PEL，RESTORE 后未确认的消息不会丢失。
+//
+// 布局：类型号、键名、元数据（streamMetaData 编码）、条目数、每个条目的 ID 与字段 JSON、
+// 消费者组数、每个组的名称与 JSON（StreamGroup）；
+// 作为延迟队列使用时（QPUSH）最后再写入消息数和每条消息的 ID 与投递状态，没有时省略这一段
+const rdbTypeBoltStream = 200
+
+// dumpStream 将 Stream 键的条目和消费者组状态写入 buf
+func dumpStream(txn *storeTxn, key string, buf *bytes.Buffer) error {
+	metaItem, err := txn.Get(streamKey(key))
+	if err != nil {
+		return err
+	}
+	meta, err := metaItem.ValueCopy(nil)
+	if err != nil {
+		return err
+	}
+
+	type record struct {
+		name  string
+		value []byte
+	}
+	collect := func(prefix []byte) ([]record, error) {
+		var records []record
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, record{name: string(item.Key()[len(prefix):]), value: value})
+		}
+		return records, nil
+	}
+
+	entries, err := collect(streamDataPrefix(key))
+	if err != nil {
+		return err
+	}
+	// 记录键以二进制编码 ID，DUMP 中仍写入 ID 字符串
+	for i := range entries {
+		ts, seq, ok := streamDataKeyID(nil, []byte(entries[i].name))
+		if !ok {
+			return fmt.Errorf("invalid stream entry key for %q", key)
+		}
+		entries[i].name = formatStreamID(ts, seq)
+	}
+	groups, err := collect(streamGroupDataPrefix(key))
+	if err != nil {
+		return err
+	}
+	queued, err := collect(streamQueueMsgPrefix(key))
+	if err != nil {
+		return err
+	}
+
+	buf.WriteByte(rdbTypeBoltStream)
+	writeRDBString(buf, key)
+	writeRDBBytes(buf, meta)
+	writeRDBLength(buf, uint64(len(entries)))
+	for _, e := range entries {
+		writeRDBString(buf, e.name)
+		writeRDBBytes(buf, e.value)
+	}
+	writeRDBLength(buf, uint64(len(groups)))
+	for _, g := range groups {
+		writeRDBString(buf, g.name)
+		writeRDBBytes(buf, g.value)
+	}
+	if len(queued) > 0 {
+		writeRDBLength(buf, uint64(len(queued)))
+		for _, q := range queued {
+			writeRDBString(buf, q.name)
+			writeRDBBytes(buf, q.value)
+		}
+	}
+	return nil
+}
+
+// restoreStream 从 buf 恢复 Stream 键，条目、元数据和消费者组在同一个事务中写入
+func (s *BotreonStore) restoreStream(key string, buf *bytes.Buffer, ttl time.Duration) error {
+	if _, err := readRDBString(buf); err != nil {
+		return fmt.Errorf("ERR invalid RDB format: %v", err)
+	}
+	meta, err := readRDBBytes(buf)
+	if err != nil {
+		return fmt.Errorf("ERR invalid RDB format: %v", err)
+	}
+	if _, err := decodeStreamMeta(meta); err != nil {
+		return fmt.Errorf("ERR invalid RDB format: %v", err)
+	}
+
+	type record struct {
+		name  string
+		value []byte
+	}
+	readRecords := func(validate func(name string, value []byte) error) ([]record, error) {
+		length, err := readRDBLength(buf)
+		if err != nil {
+			return nil, err
+		}
+		records := make([]record, 0, length)
+		for i := uint64(0); i < length; i++ {
+			name, err := readRDBString(buf)
+			if err != nil {
+				return nil, err
+			}
+			value, err := readRDBBytes(buf)
+			if err != nil {
+				return nil, err
+			}
+			if err := validate(name, value); err != nil {
+				return nil, err
+			}
+			records = append(records, record{name: name, value: append([]byte(nil), value...)})
+		}
+		return records, nil
+	}
+
+	entries, err := readRecords(func(id string, value []byte) error {
+		if streamEntryIDKey(key, id) == nil {
+			return fmt.Errorf("invalid stream ID: %s", id)
+		}
+		var fields map[string]string
+		return json.Unmarshal(value, &fields)
+	})
+	if err != nil {
+		return fmt.Errorf("ERR invalid RDB format: %v", err)
+	}
+	groups, err := readRecords(func(name string, value []byte) error {
+		var group StreamGroup
+		if err := json.Unmarshal(value, &group); err != nil {
+			return err
+		}
+		if group.Name != name {
+			return fmt.Errorf("group name mismatch: %s", name)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("ERR invalid RDB format: %v", err)
+	}
+	// 延迟队列状态是可选的一段，紧跟在 footer（0xFF）之前
+	var queued []record
+	if buf.Len() > 0 && buf.Bytes()[0] != 0xFF {
+		queued, err = readRecords(func(id string, value []byte) error {
+			_, _, err := decodeQueueMsg(value)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("ERR invalid RDB format: %v", err)
+		}
+	}
+
+	err = s.update(func(txn *storeTxn) error {
+		if err := txn.Set(TypeOfKeyGet(key), []byte(KeyTypeStream)); err != nil {
+			return err
+		}
+		if err := txn.Set(streamKey(key), meta); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := txn.Set(streamEntryIDKey(key, e.name), e.value); err != nil {
+				return err
+			}
+		}
+		for _, g := range groups {
+			if err := txn.Set(streamGroupDataKey(key, g.name), g.value); err != nil {
+				return err
+			}
+		}
+		for _, q := range queued {
+			readyAt, _, _ := decodeQueueMsg(q.value)
+			if err := txn.Set(streamQueueReadyKey(key, readyAt, q.name), nil); err != nil {
+				return err
+			}
+			if err := txn.Set(streamQueueMsgKey(key, q.name), q.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		_, _ = s.PExpire(key, ttl.Milliseconds())
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// XSETID 与 XINFO STREAM FULL 的错误，文本与 Redis 一致
+var (
+	ErrStreamNoSuchKey       = errors.New("ERR no such key")
+	ErrStreamSetIDTooSmall   = errors.New("ERR The ID specified in XSETID is smaller than the target stream top item")
+	ErrStreamSetIDMaxDeleted = errors.New("ERR The ID specified in XSETID is smaller than the provided max_deleted_entry_id")
+	ErrStreamEntriesAdded    = errors.New("ERR The entries_added specified in XSETID is smaller than the target stream length")
+)
+
+// XSetIDOptions XSETID 的可选参数
+type XSetIDOptions struct {
+	EntriesAdded int64  // ENTRIESADDED，小于 0 表示不修改
+	MaxDeletedID string // MAXDELETEDID，为空表示不修改
+}
+
+// XSetID 设置 Stream 最后生成的 ID，之后 XADD * 从该 ID 之后分配。
+// 新 ID 不能小于 Stream 中现存的最大 ID，Stream 不存在时返回 ErrStreamNoSuchKey
+func (s *BotreonStore) XSetID(key, id string, opts XSetIDOptions) error {
+	ts, seq, err := parseStreamID(id)
+	if err != nil || id == "*" {
+		return ErrStreamInvalidID
+	}
+	var delTS, delSeq int64
+	if opts.MaxDeletedID != "" {
+		delTS, delSeq, err = parseStreamID(opts.MaxDeletedID)
+		if err != nil || opts.MaxDeletedID == "*" {
+			return ErrStreamInvalidID
+		}
+		if streamIDGreater(delTS, delSeq, ts, seq) {
+			return ErrStreamSetIDMaxDeleted
+		}
+	}
+
+	return s.update(func(txn *storeTxn) error {
+		meta, err := streamMetaTxn(txn, key)
+		if err != nil {
+			return err
+		}
+		if meta == nil {
+			return ErrStreamNoSuchKey
+		}
+		if opts.EntriesAdded >= 0 && opts.EntriesAdded < meta.Length {
+			return ErrStreamEntriesAdded
+		}
+		if meta.Length > 0 {
+			topTS, topSeq, err := streamTopIDTxn(txn, key, meta)
+			if err != nil {
+				return err
+			}
+			if streamIDGreater(topTS, topSeq, ts, seq) {
+				return ErrStreamSetIDTooSmall
+			}
+		}
+
+		meta.LastID = ts
+		meta.LastSeq = seq
+		if opts.EntriesAdded >= 0 {
+			meta.EntriesAdded = opts.EntriesAdded
+		}
+		if opts.MaxDeletedID != "" && (delTS != 0 || delSeq != 0) {
+			meta.MaxDeletedID = delTS
+			meta.MaxDelSeq = delSeq
+		}
+		return txn.Set(streamKey(key), encodeStreamMeta(meta))
+	})
+}
+
+// streamTopIDTxn 返回 Stream 中现存的最大 ID。最后生成的记录通常仍然存在，
+// 被删除时才扫描全部记录
+func streamTopIDTxn(txn *storeTxn, key string, meta *streamMetaData) (int64, int64, error) {
+	_, err := txn.Get(streamDataKey(key, meta.LastID, meta.LastSeq))
+	if err == nil {
+		return meta.LastID, meta.LastSeq, nil
+	}
+	if !errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, 0, err
+	}
+	ids := streamIDsTxn(txn, key)
+	if len(ids) == 0 {
+		return 0, 0, nil
+	}
+	top := ids[len(ids)-1]
+	return top.ts, top.seq, nil
+}
+
+// CompareStreamID 比较两个 Stream ID，小于、等于、大于时分别返回 -1、0、1
+func CompareStreamID(id1, id2 string) int {
+	return compareStreamID(id1, id2)
+}
+
+// StreamFullInfo XINFO STREAM FULL 的结果
+type StreamFullInfo struct {
+	Length          int64
+	LastGeneratedID string
+	MaxDeletedID    string
+	EntriesAdded    int64
+	RadixTreeKeys   int64          // 按每批 streamNodeEntries 条折算的节点数
+	FirstID         string         // 现存的第一条记录的 ID
+	Entries         []StreamEntry  // 按 ID 顺序的前 count 条记录
+	Groups          []*StreamGroup // 按名称排序
+}
+
+// XInfoFull 返回 Stream 的完整信息，包括前 count 条记录（count 为 0 时返回全部）
+// 以及每个消费组的待确认列表与消费者。Stream 不存在时返回 ErrStreamNoSuchKey
+func (s *BotreonStore) XInfoFull(key string, count int64) (*StreamFullInfo, error) {
+	var info StreamFullInfo
+
+	err := s.view(func(txn *storeTxn) error {
+		meta, err := streamMetaTxn(txn, key)
+		if err != nil {
+			return err
+		}
+		if meta == nil {
+			return ErrStreamNoSuchKey
+		}
+		info.Length = meta.Length
+		info.LastGeneratedID = formatStreamID(meta.LastID, meta.LastSeq)
+		info.MaxDeletedID = formatStreamID(meta.MaxDeletedID, meta.MaxDelSeq)
+		info.EntriesAdded = meta.EntriesAdded
+		info.FirstID = formatStreamID(meta.FirstID, meta.FirstSeq)
+		info.RadixTreeKeys = (meta.Length + streamNodeEntries - 1) / streamNodeEntries
+
+		ids := streamIDsTxn(txn, key)
+		if count > 0 && int64(len(ids)) > count {
+			ids = ids[:count]
+		}
+		for _, e := range ids {
+			entry, err := streamEntryTxn(txn, key, e.id)
+			if err != nil {
+				return err
+			}
+			if entry != nil {
+				info.Entries = append(info.Entries, *entry)
+			}
+		}
+
+		prefix := streamGroupDataPrefix(key)
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var groupData StreamGroup
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &groupData)
+			}); err != nil {
+				return err
+			}
+			info.Groups = append(info.Groups, &groupData)
+		}
+		sort.Slice(info.Groups, func(i, j int) bool {
+			return info.Groups[i].Name < info.Groups[j].Name
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
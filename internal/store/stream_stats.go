@@ -0,0 +1,83 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// StreamBacklog 所有 Stream 的积压：条目数与消费者组中已投递但尚未 XACK 的条目数
+type StreamBacklog struct {
+	Streams int64
+	Entries int64 // 各 Stream 的长度之和
+	Groups  int64
+	Pending int64 // 各消费者组待确认列表（PEL）的长度之和
+}
+
+// StreamBacklog 遍历类型键找出所有 Stream 并汇总积压。需要遍历整个键空间，调用方应控制频率
+func (s *BotreonStore) StreamBacklog() (StreamBacklog, error) {
+	var b StreamBacklog
+	err := s.view(func(txn *storeTxn) error {
+		var streams []string
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefixKeyTypeBytes
+		iter := txn.NewIterator(opts)
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			item := iter.Item()
+			if err := item.Value(func(val []byte) error {
+				if string(val) == KeyTypeStream {
+					streams = append(streams, string(item.Key()[len(prefixKeyTypeBytes):]))
+				}
+				return nil
+			}); err != nil {
+				iter.Close()
+				return err
+			}
+		}
+		iter.Close()
+
+		for _, key := range streams {
+			item, err := txn.Get(streamKey(key))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			var meta *streamMetaData
+			if err := item.Value(func(val []byte) error {
+				meta, err = decodeStreamMeta(val)
+				return err
+			}); err != nil {
+				return err
+			}
+			b.Streams++
+			b.Entries += meta.Length
+			if err := streamGroupsBacklog(txn, key, &b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return b, err
+}
+
+// streamGroupsBacklog 累加 key 的消费者组数与待确认条目数
+func streamGroupsBacklog(txn *storeTxn, key string, b *StreamBacklog) error {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = streamGroupDataPrefix(key)
+	iter := txn.NewIterator(opts)
+	defer iter.Close()
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		var group StreamGroup
+		if err := iter.Item().Value(func(val []byte) error {
+			return json.Unmarshal(val, &group)
+		}); err != nil {
+			return err
+		}
+		b.Groups++
+		b.Pending += int64(len(group.Pending))
+	}
+	return nil
+}
@@ -0,0 +1,639 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/zeebo/assert"
+)
+
+func TestNextStreamID(t *testing.T) {
+	// 时钟前进：使用当前毫秒数
+	ts, seq, err := nextStreamID(1000, 5, 2000)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2000), ts)
+	assert.Equal(t, int64(0), seq)
+
+	// 同一毫秒与时钟回拨：沿用上一个 ID 的毫秒数
+	ts, seq, err = nextStreamID(1000, 5, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1000), ts)
+	assert.Equal(t, int64(6), seq)
+
+	ts, seq, err = nextStreamID(1000, 5, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1000), ts)
+	assert.Equal(t, int64(6), seq)
+
+	// 序号用尽时进位到下一毫秒
+	ts, seq, err = nextStreamID(1000, math.MaxInt64, 900)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1001), ts)
+	assert.Equal(t, int64(0), seq)
+
+	_, _, err = nextStreamID(math.MaxInt64, math.MaxInt64, 0)
+	assert.Equal(t, ErrStreamExhausted, err)
+}
+
+func TestXAddClockRegression(t *testing.T) {
+	dbPath := t.TempDir()
+	store, err := NewBadgerStore(dbPath)
+	assert.NoError(t, err)
+
+	clock := NewManualClock(time.UnixMilli(1700000000000))
+	store.SetClock(clock)
+
+	fields := map[string]string{"f": "v"}
+	first, err := store.XAdd("events", StreamXAddOptions{}, "*", fields)
+	assert.NoError(t, err)
+	assert.Equal(t, "1700000000000-0", first)
+
+	// 同一毫秒内：序号递增
+	second, err := store.XAdd("events", StreamXAddOptions{}, "*", fields)
+	assert.NoError(t, err)
+	assert.Equal(t, "1700000000000-1", second)
+
+	// 时钟回拨一秒：ID 不倒退
+	clock.Advance(-time.Second)
+	third, err := store.XAdd("events", StreamXAddOptions{}, "*", fields)
+	assert.NoError(t, err)
+	assert.Equal(t, "1700000000000-2", third)
+
+	// 重启后在同一毫秒内继续分配，不与已有 ID 冲突
+	assert.NoError(t, store.Close())
+	store, err = NewBadgerStore(dbPath)
+	assert.NoError(t, err)
+	defer store.Close()
+	store.SetClock(clock)
+
+	fourth, err := store.XAdd("events", StreamXAddOptions{}, "*", fields)
+	assert.NoError(t, err)
+	assert.Equal(t, "1700000000000-3", fourth)
+
+	length, err := store.XLen("events")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), length)
+
+	// 时钟恢复后回到当前毫秒数
+	clock.Advance(2 * time.Second)
+	fifth, err := store.XAdd("events", StreamXAddOptions{}, "*", fields)
+	assert.NoError(t, err)
+	assert.Equal(t, "1700000001000-0", fifth)
+}
+
+// TestXAddSameMillisecondOrder 同一毫秒内超过 10 条记录时仍按 ID 顺序读取（10-1 之前的记录键不会排在 2 之后）
+func TestXAddSameMillisecondOrder(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	store.SetClock(NewManualClock(time.UnixMilli(100)))
+
+	var want []string
+	for i := 0; i < 12; i++ {
+		id, err := store.XAdd("s", StreamXAddOptions{}, "*", map[string]string{"n": strconv.Itoa(i)})
+		assert.NoError(t, err)
+		assert.Equal(t, "100-"+strconv.Itoa(i), id)
+		want = append(want, id)
+	}
+
+	entries, err := store.XRange("s", "-", "+", 0)
+	assert.NoError(t, err)
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.ID)
+	}
+	assert.DeepEqual(t, want, got)
+
+	entries, err = store.XRange("s", "100-2", "100-10", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(entries))
+	assert.Equal(t, "100-2", entries[0].ID)
+	assert.Equal(t, "100-4", entries[2].ID)
+
+	result, err := store.XRead(2, -1, "s", "100-9")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(result[0]["s"]))
+	assert.Equal(t, "100-10", result[0]["s"][0].ID)
+	assert.Equal(t, "100-11", result[0]["s"][1].ID)
+
+	// 删除第一条记录后首个 ID 为下一条
+	_, err = store.XDel("s", "100-0")
+	assert.NoError(t, err)
+	info, err := store.XInfo("s")
+	assert.NoError(t, err)
+	assert.Equal(t, "100-1", info.FirstID)
+}
+
+// TestXReadGroupSameMillisecond > 按 ID 顺序分批读取同一毫秒内的条目，$ 从创建时的最后一个 ID 之后开始
+func TestXReadGroupSameMillisecond(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	store.SetClock(NewManualClock(time.UnixMilli(100)))
+
+	for i := 0; i < 12; i++ {
+		_, err := store.XAdd("s", StreamXAddOptions{}, "*", map[string]string{"n": strconv.Itoa(i)})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, store.XGroupCreate("s", "g", "0"))
+	assert.NoError(t, store.XGroupCreate("s", "tail", "$"))
+	assert.Equal(t, ErrStreamInvalidID, store.XGroupCreate("s", "bad", "x"))
+
+	var got []string
+	for {
+		results, err := store.XReadGroupStreams("g", "c", XReadGroupOptions{Count: 3}, []string{"s"}, []string{">"})
+		assert.NoError(t, err)
+		if len(results) == 0 {
+			break
+		}
+		assert.True(t, len(results[0].Entries) <= 3)
+		for _, e := range results[0].Entries {
+			got = append(got, e.ID)
+		}
+	}
+	var want []string
+	for i := 0; i < 12; i++ {
+		want = append(want, "100-"+strconv.Itoa(i))
+	}
+	assert.DeepEqual(t, want, got)
+
+	results, err := store.XReadGroupStreams("tail", "c", XReadGroupOptions{Count: 3}, []string{"s"}, []string{">"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(results))
+	_, err = store.XAdd("s", StreamXAddOptions{}, "*", map[string]string{"n": "12"})
+	assert.NoError(t, err)
+	results, err = store.XReadGroupStreams("tail", "c", XReadGroupOptions{Count: 3}, []string{"s"}, []string{">"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(results[0].Entries))
+	assert.Equal(t, "100-12", results[0].Entries[0].ID)
+
+	// SETID $ 同样解析为当前最后一个 ID
+	assert.NoError(t, store.XGroupSetID("s", "g", "$"))
+	results, err = store.XReadGroupStreams("g", "c", XReadGroupOptions{}, []string{"s"}, []string{">"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(results))
+}
+
+// TestMigrateLegacyStreams 旧版本以 ID 字符串保存的记录键在启动时转换为按 ID 排序的编码
+func TestMigrateLegacyStreams(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	key := "legacy"
+	legacyIDs := []string{"100", "100-1", "100-2", "100-10", "100-11"}
+	group := &StreamGroup{
+		Name:            "g",
+		LastDeliveredID: "100",
+		Consumers:       map[string]*StreamConsumer{},
+		Pending:         map[string]*StreamPendingEntry{"100": {ID: "100", Consumer: "c", DeliveryCount: 1}},
+	}
+	err = store.update(func(txn *storeTxn) error {
+		assert.NoError(t, txn.Set(TypeOfKeyGet(key), []byte(KeyTypeStream)))
+		meta := &streamMetaData{Length: 5, FirstID: 100, LastID: 100, LastSeq: 11, EntriesAdded: 5}
+		assert.NoError(t, txn.Set(streamKey(key), encodeStreamMeta(meta)))
+		for _, id := range legacyIDs {
+			assert.NoError(t, txn.Set(append(streamLegacyDataPrefix(key), id...), []byte(`{"id":"`+id+`"}`)))
+		}
+		data, err := json.Marshal(group)
+		assert.NoError(t, err)
+		assert.NoError(t, txn.Set(streamGroupDataKey(key, "g"), data))
+		// 旧版本原样保存 $
+		data, err = json.Marshal(&StreamGroup{Name: "tail", LastDeliveredID: "$"})
+		assert.NoError(t, err)
+		return txn.Set(streamGroupDataKey(key, "tail"), data)
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.NextStartup())
+	entries, err := store.XRange(key, "-", "+", 0)
+	assert.NoError(t, err)
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.ID)
+	}
+	assert.DeepEqual(t, []string{"100-0", "100-1", "100-2", "100-10", "100-11"}, got)
+	assert.Equal(t, "100-10", entries[3].Fields["id"])
+
+	// 消费组中的 ID 统一为 <ms>-<seq>，按旧格式确认也能匹配
+	results, err := store.XReadGroupStreams("g", "c", XReadGroupOptions{Count: 1}, []string{key}, []string{">"})
+	assert.NoError(t, err)
+	assert.Equal(t, "100-1", results[0].Entries[0].ID)
+	acked, err := store.XAck(key, "g", "100")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), acked)
+	results, err = store.XReadGroupStreams("tail", "c", XReadGroupOptions{}, []string{key}, []string{">"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(results))
+
+	// 旧键已全部删除
+	err = store.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = streamLegacyDataPrefix(key)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		it.Rewind()
+		assert.False(t, it.Valid())
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestXAddExplicitIDs(t *testing.T) {
+	dbPath := t.TempDir()
+	store, _ := NewBadgerStore(dbPath)
+	defer store.Close()
+
+	fields := map[string]string{"f": "v"}
+	_, err := store.XAdd("s", StreamXAddOptions{}, "0-0", fields)
+	assert.Equal(t, ErrStreamIDZero, err)
+
+	id, err := store.XAdd("s", StreamXAddOptions{}, "0-*", fields)
+	assert.NoError(t, err)
+	assert.Equal(t, "0-1", id)
+
+	id, err = store.XAdd("s", StreamXAddOptions{}, "5-3", fields)
+	assert.NoError(t, err)
+	assert.Equal(t, "5-3", id)
+
+	// 与最后一个 ID 相等或更小
+	_, err = store.XAdd("s", StreamXAddOptions{}, "5-3", fields)
+	assert.Equal(t, ErrStreamIDTooSmall, err)
+	_, err = store.XAdd("s", StreamXAddOptions{}, "4-*", fields)
+	assert.Equal(t, ErrStreamIDTooSmall, err)
+
+	id, err = store.XAdd("s", StreamXAddOptions{}, "5-*", fields)
+	assert.NoError(t, err)
+	assert.Equal(t, "5-4", id)
+
+	id, err = store.XAdd("s", StreamXAddOptions{}, "7-*", fields)
+	assert.NoError(t, err)
+	assert.Equal(t, "7-0", id)
+
+	_, err = store.XAdd("s", StreamXAddOptions{}, "abc", fields)
+	assert.Equal(t, ErrStreamInvalidID, err)
+}
+
+func TestDumpRestoreStreamGroups(t *testing.T) {
+	src, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer src.Close()
+	dst, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer dst.Close()
+
+	for _, id := range []string{"1-1", "2-1", "3-1"} {
+		_, err := src.XAdd("orders", StreamXAddOptions{}, id, map[string]string{"id": id})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, src.XGroupCreate("orders", "workers", "0"))
+	_, err = src.XReadGroup("workers", "w1", 2, 0, "orders")
+	assert.NoError(t, err)
+	acked, err := src.XAck("orders", "workers", "1-1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), acked)
+
+	data, err := src.Dump("orders")
+	assert.NoError(t, err)
+
+	// 目标上已有同名 Stream：不带 REPLACE 时拒绝，带 REPLACE 时整体替换
+	_, err = dst.XAdd("orders", StreamXAddOptions{}, "9-9", map[string]string{"stale": "1"})
+	assert.NoError(t, err)
+	assert.Error(t, dst.Restore("orders", data, 0, false))
+	assert.NoError(t, dst.Restore("orders", data, 0, true))
+
+	length, err := dst.XLen("orders")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), length)
+	stale, err := dst.GetStreamEntry("orders", "9-9")
+	assert.True(t, err != nil || stale == nil)
+
+	// 未确认的消息仍在 PEL 中，归属原消费者
+	pending, err := dst.XPending("orders", "workers")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(pending))
+	assert.Equal(t, "2-1", pending[0].ID)
+	assert.Equal(t, "w1", pending[0].Consumer)
+	assert.Equal(t, int64(1), pending[0].DeliveryCount)
+
+	groups, err := dst.XInfoGroups("orders")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(groups))
+	assert.Equal(t, "2-1", groups[0].LastDeliveredID)
+	assert.NotNil(t, groups[0].Consumers["w1"])
+
+	// 消费者组从 last-delivered-id 之后继续读取
+	result, err := dst.XReadGroup("workers", "w2", 10, 0, "orders")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, 1, len(result[0]["orders"]))
+	assert.Equal(t, "3-1", result[0]["orders"][0].ID)
+
+	// 自动生成的 ID 不会小于恢复前的最后一个 ID
+	id, err := dst.XAdd("orders", StreamXAddOptions{}, "3-*", map[string]string{"f": "v"})
+	assert.NoError(t, err)
+	assert.Equal(t, "3-2", id)
+
+	// DEL 删除条目和消费者组
+	deleted, err := dst.Del("orders")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	groups, err = dst.XInfoGroups("orders")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(groups))
+	length, err = dst.XLen("orders")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), length)
+}
+
+func TestXReadBlocking(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	first, err := store.XAdd("s", StreamXAddOptions{}, "*", map[string]string{"n": "1"})
+	assert.NoError(t, err)
+
+	// 不阻塞时 "$" 没有新记录
+	result, err := store.XRead(0, -1, "s", "$")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(result))
+
+	// BLOCK 0 一直等待，"$" 在开始等待时解析，只返回之后追加的记录
+	done := make(chan []map[string][]StreamEntry, 1)
+	go func() {
+		result, err := store.XRead(0, 0, "s", "$")
+		assert.NoError(t, err)
+		done <- result
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for store.BlockedClients() != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	second, err := store.XAdd("s", StreamXAddOptions{}, "*", map[string]string{"n": "2"})
+	assert.NoError(t, err)
+	select {
+	case result = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("XREAD BLOCK 0 was not woken by XADD")
+	}
+	assert.Equal(t, 1, len(result))
+	assert.Equal(t, 1, len(result[0]["s"]))
+	assert.Equal(t, second, result[0]["s"][0].ID)
+	assert.NotEqual(t, first, second)
+
+	// 超时返回空结果
+	start := time.Now()
+	result, err = store.XRead(0, 50, "s", "$")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(result))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	// 返回后离开等待队列
+	assert.Equal(t, 0, store.BlockedClients())
+	store.streamBlockingMu.Lock()
+	assert.Equal(t, 0, len(store.streamBlockingChans))
+	store.streamBlockingMu.Unlock()
+}
+
+func TestXReadGroupPending(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	clock := NewManualClock(time.UnixMilli(1700000000000))
+	store.SetClock(clock)
+
+	for _, id := range []string{"1-1", "1-2", "1-3", "1-4"} {
+		_, err := store.XAdd("s", StreamXAddOptions{}, id, map[string]string{"f": id})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, store.XGroupCreate("s", "g", "0"))
+
+	// 键或消费组不存在
+	_, err = store.XReadGroupStreams("missing", "c1", XReadGroupOptions{}, []string{"s"}, []string{">"})
+	assert.True(t, errors.Is(err, ErrStreamNoGroup))
+	assert.Equal(t, "NOGROUP No such key 's' or consumer group 'missing' in XREADGROUP with GROUP option", err.Error())
+	_, err = store.XReadGroupStreams("g", "c1", XReadGroupOptions{}, []string{"s"}, []string{"bad"})
+	assert.Equal(t, ErrStreamInvalidID, err)
+
+	// > 读取新条目，登记到消费者的待确认列表并推进 last-delivered-id
+	results, err := store.XReadGroupStreams("g", "c1", XReadGroupOptions{Count: 2}, []string{"s"}, []string{">"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, 2, len(results[0].Entries))
+	assert.Equal(t, "1-1", results[0].Entries[0].ID)
+	pending, err := store.XPending("s", "g")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(pending))
+	consumers, err := store.XInfoConsumers("s", "g")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(consumers))
+	assert.Equal(t, int64(1700000000000), consumers[0].LastSeen)
+
+	// NOACK：推进 last-delivered-id 但不登记
+	results, err = store.XReadGroupStreams("g", "c2", XReadGroupOptions{Count: 1, NoAck: true}, []string{"s"}, []string{">"})
+	assert.NoError(t, err)
+	assert.Equal(t, "1-3", results[0].Entries[0].ID)
+	pending, err = store.XPending("s", "g")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(pending))
+
+	// 按 ID 读取自己的待确认条目，投递次数递增；已删除的条目只返回 ID
+	clock.Advance(time.Second)
+	_, err = store.XDel("s", "1-2")
+	assert.NoError(t, err)
+	results, err = store.XReadGroupStreams("g", "c1", XReadGroupOptions{}, []string{"s"}, []string{"0"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, 2, len(results[0].Entries))
+	assert.Equal(t, "1-1", results[0].Entries[0].ID)
+	assert.Equal(t, "1-1", results[0].Entries[0].Fields["f"])
+	assert.Equal(t, "1-2", results[0].Entries[1].ID)
+	assert.Nil(t, results[0].Entries[1].Fields)
+	pending, err = store.XPending("s", "g")
+	assert.NoError(t, err)
+	for _, p := range pending {
+		if p.ID == "1-1" {
+			assert.Equal(t, int64(2), p.DeliveryCount)
+			assert.Equal(t, int64(1700000001000), p.LastDelivery)
+		}
+	}
+
+	// 其他消费者的历史为空，但仍返回该 Stream
+	results, err = store.XReadGroupStreams("g", "c2", XReadGroupOptions{}, []string{"s"}, []string{"0"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, 0, len(results[0].Entries))
+
+	// 确认后不再出现在历史中；没有新条目时 > 不返回该 Stream
+	acked, err := store.XAck("s", "g", "1-1", "1-2")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), acked)
+	results, err = store.XReadGroupStreams("g", "c1", XReadGroupOptions{}, []string{"s"}, []string{"0"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(results[0].Entries))
+	results, err = store.XReadGroupStreams("g", "c1", XReadGroupOptions{}, []string{"s"}, []string{">"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, "1-4", results[0].Entries[0].ID)
+	results, err = store.XReadGroupStreams("g", "c1", XReadGroupOptions{}, []string{"s"}, []string{">"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(results))
+}
+
+func TestXTrimStrategies(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	// ID 的数值顺序与字符串顺序不同（9-1 < 10-1），裁剪按数值顺序删除最旧的记录
+	for i := 1; i <= 250; i++ {
+		_, err := store.XAdd("s", StreamXAddOptions{}, strconv.Itoa(i)+"-1", map[string]string{"f": "v"})
+		assert.NoError(t, err)
+	}
+
+	// 近似裁剪只删除整批记录：超出 50 条不足一批时不删除
+	trimmed, err := store.XTrim("s", StreamTrimOptions{Strategy: StreamTrimMaxLen, MaxLen: 200, Approx: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), trimmed)
+	trimmed, err = store.XTrim("s", StreamTrimOptions{Strategy: StreamTrimMaxLen, MaxLen: 10, Approx: true, Limit: 1000})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(200), trimmed)
+
+	// LIMIT 限制单次删除的条数
+	trimmed, err = store.XTrim("s", StreamTrimOptions{Strategy: StreamTrimMaxLen, MaxLen: 0, Approx: true, Limit: 99})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), trimmed)
+
+	trimmed, err = store.XTrim("s", StreamTrimOptions{Strategy: StreamTrimMinID, MinID: "210"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), trimmed)
+	info, err := store.XInfo("s")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(41), info.Length)
+	assert.Equal(t, "210-1", info.FirstID)
+
+	// 精确裁剪
+	_, err = store.XAdd("s", StreamXAddOptions{Trim: StreamTrimOptions{Strategy: StreamTrimMaxLen, MaxLen: 5}}, "*", map[string]string{"f": "v"})
+	assert.NoError(t, err)
+	info, err = store.XInfo("s")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), info.Length)
+	assert.Equal(t, "247-1", info.FirstID)
+	assert.Equal(t, "246-1", info.MaxDeletedID)
+
+	// NOMKSTREAM 不创建 Stream
+	id, err := store.XAdd("missing", StreamXAddOptions{NoMkStream: true}, "*", map[string]string{"f": "v"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", id)
+	exists, err := store.StreamType("missing")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestXSetIDAndInfoFull(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.Equal(t, ErrStreamNoSuchKey, store.XSetID("s", "1-1", XSetIDOptions{EntriesAdded: -1}))
+	for _, id := range []string{"1-1", "2-1", "3-1"} {
+		_, err := store.XAdd("s", StreamXAddOptions{}, id, map[string]string{"f": id})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, ErrStreamSetIDTooSmall, store.XSetID("s", "2-5", XSetIDOptions{EntriesAdded: -1}))
+	assert.Equal(t, ErrStreamEntriesAdded, store.XSetID("s", "5-0", XSetIDOptions{EntriesAdded: 2}))
+	assert.Equal(t, ErrStreamSetIDMaxDeleted, store.XSetID("s", "5-0", XSetIDOptions{EntriesAdded: -1, MaxDeletedID: "6-0"}))
+
+	// 删除最后一条后可以把 last ID 设置到现存最大 ID 之后
+	_, err = store.XDel("s", "3-1")
+	assert.NoError(t, err)
+	assert.NoError(t, store.XSetID("s", "2-1", XSetIDOptions{EntriesAdded: 10, MaxDeletedID: "1-5"}))
+	id, err := store.XAdd("s", StreamXAddOptions{}, "2-*", map[string]string{"f": "new"})
+	assert.NoError(t, err)
+	assert.Equal(t, "2-2", id)
+
+	assert.NoError(t, store.XGroupCreate("s", "g", "0"))
+	_, err = store.XReadGroupStreams("g", "c", XReadGroupOptions{Count: 1}, []string{"s"}, []string{">"})
+	assert.NoError(t, err)
+
+	full, err := store.XInfoFull("s", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), full.Length)
+	assert.Equal(t, int64(11), full.EntriesAdded)
+	assert.Equal(t, "2-2", full.LastGeneratedID)
+	assert.Equal(t, "1-5", full.MaxDeletedID)
+	assert.Equal(t, 2, len(full.Entries))
+	assert.Equal(t, "1-1", full.Entries[0].ID)
+	assert.Equal(t, "2-1", full.Entries[1].ID)
+	assert.Equal(t, 1, len(full.Groups))
+	assert.Equal(t, "g", full.Groups[0].Name)
+	assert.Equal(t, 1, len(full.Groups[0].Pending))
+	assert.Equal(t, "c", full.Groups[0].Pending["1-1"].Consumer)
+
+	_, err = store.XInfoFull("missing", 10)
+	assert.Equal(t, ErrStreamNoSuchKey, err)
+}
+
+func TestXReadGroupBlocking(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	_, err = store.XAdd("s", StreamXAddOptions{}, "1-1", map[string]string{"n": "1"})
+	assert.NoError(t, err)
+	assert.NoError(t, store.XGroupCreate("s", "g", "$"))
+	assert.NoError(t, store.XSetID("s", "1-1", XSetIDOptions{EntriesAdded: -1}))
+	assert.NoError(t, store.XGroupSetID("s", "g", "1-1"))
+
+	// 同组的两个消费者阻塞等待，一条新记录只投递给其中一个
+	done := make(chan []StreamReadGroupResult, 2)
+	for _, consumer := range []string{"c1", "c2"} {
+		go func(consumer string) {
+			results, err := store.XReadGroupStreams("g", consumer, XReadGroupOptions{Block: true, Timeout: 300}, []string{"s"}, []string{">"})
+			assert.NoError(t, err)
+			done <- results
+		}(consumer)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for store.BlockedClients() != 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	_, err = store.XAdd("s", StreamXAddOptions{}, "2-1", map[string]string{"n": "2"})
+	assert.NoError(t, err)
+
+	delivered := 0
+	for i := 0; i < 2; i++ {
+		select {
+		case results := <-done:
+			for _, r := range results {
+				assert.Equal(t, "2-1", r.Entries[0].ID)
+				delivered += len(r.Entries)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("XREADGROUP BLOCK did not return")
+		}
+	}
+	assert.Equal(t, 1, delivered)
+	pending, err := store.XPending("s", "g")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(pending))
+
+	// 只读取历史时不阻塞
+	start := time.Now()
+	results, err := store.XReadGroupStreams("g", "c3", XReadGroupOptions{Block: true}, []string{"s"}, []string{"0"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, 0, len(results[0].Entries))
+	assert.True(t, time.Since(start) < time.Second)
+
+	assert.Equal(t, 0, store.BlockedClients())
+	store.streamBlockingMu.Lock()
+	assert.Equal(t, 0, len(store.streamBlockingChans))
+	store.streamBlockingMu.Unlock()
+}
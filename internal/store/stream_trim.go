@@ -0,0 +1,114 @@
+package store
+
+import (
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Stream 裁剪策略
+const (
+	StreamTrimMaxLen = "MAXLEN"
+	StreamTrimMinID  = "MINID"
+)
+
+// streamNodeEntries 近似裁剪（~）每批删除的条数，对应 Redis 默认的 stream-node-max-entries
+const streamNodeEntries = 100
+
+// DefaultStreamTrimLimit 近似裁剪未指定 LIMIT 时单次最多删除的条数，与 Redis 相同为批大小的 100 倍
+const DefaultStreamTrimLimit = 100 * streamNodeEntries
+
+// StreamTrimOptions XADD 与 XTRIM 的裁剪条件
+type StreamTrimOptions struct {
+	Strategy string // StreamTrimMaxLen 或 StreamTrimMinID，为空时不裁剪
+	MaxLen   int64  // MAXLEN：最多保留的条数
+	MinID    string // MINID：删除 ID 小于该值的记录
+	Approx   bool   // ~：只删除整批记录，保留的记录可能多于阈值
+	Limit    int64  // 近似裁剪单次最多删除的条数，0 表示不限制
+}
+
+// streamIDEntry 解析后的记录 ID
+type streamIDEntry struct {
+	id      string
+	ts, seq int64
+}
+
+// streamIDsTxn 返回 Stream 中全部记录的 ID，按 ID 从小到大排序（记录键的顺序即 ID 顺序）
+func streamIDsTxn(txn *storeTxn, key string) []streamIDEntry {
+	prefix := streamDataPrefix(key)
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var ids []streamIDEntry
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if ts, seq, ok := streamDataKeyID(prefix, it.Item().Key()); ok {
+			ids = append(ids, streamIDEntry{id: formatStreamID(ts, seq), ts: ts, seq: seq})
+		}
+	}
+	return ids
+}
+
+// trimStreamTxn 按裁剪条件删除最旧的记录，更新 meta 中的长度、首个 ID 与最大删除 ID（不写回元数据），
+// 返回删除的条数。近似裁剪只删除整批记录且不超过 Limit，
+// 不足一批时直接返回，因此大多数 XADD 不需要扫描记录
+func trimStreamTxn(txn *storeTxn, key string, meta *streamMetaData, opts StreamTrimOptions) (int64, error) {
+	var ids []streamIDEntry
+	var remove int64
+	switch opts.Strategy {
+	case StreamTrimMaxLen:
+		excess := meta.Length - opts.MaxLen
+		if excess <= 0 || (opts.Approx && excess < streamNodeEntries) {
+			return 0, nil
+		}
+		ids = streamIDsTxn(txn, key)
+		remove = int64(len(ids)) - opts.MaxLen
+	case StreamTrimMinID:
+		minTS, minSeq, err := parseStreamID(opts.MinID)
+		if err != nil {
+			return 0, ErrStreamInvalidID
+		}
+		if meta.Length == 0 || !streamIDGreater(minTS, minSeq, meta.FirstID, meta.FirstSeq) {
+			return 0, nil
+		}
+		ids = streamIDsTxn(txn, key)
+		for _, e := range ids {
+			if !streamIDGreater(minTS, minSeq, e.ts, e.seq) {
+				break
+			}
+			remove++
+		}
+	default:
+		return 0, nil
+	}
+
+	if opts.Approx {
+		if opts.Limit > 0 && remove > opts.Limit {
+			remove = opts.Limit
+		}
+		remove -= remove % streamNodeEntries
+	}
+	if remove <= 0 {
+		return 0, nil
+	}
+
+	for _, e := range ids[:remove] {
+		if err := txn.Delete(streamDataKey(key, e.ts, e.seq)); err != nil {
+			return 0, err
+		}
+	}
+	last := ids[remove-1]
+	if streamIDGreater(last.ts, last.seq, meta.MaxDeletedID, meta.MaxDelSeq) {
+		meta.MaxDeletedID = last.ts
+		meta.MaxDelSeq = last.seq
+	}
+	meta.Length = int64(len(ids)) - remove
+	if meta.Length > 0 {
+		meta.FirstID = ids[remove].ts
+		meta.FirstSeq = ids[remove].seq
+	} else {
+		meta.FirstID = 0
+		meta.FirstSeq = 0
+	}
+	return remove, nil
+}
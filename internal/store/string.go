@@ -23,10 +23,10 @@ func randomFloat64String() float64 {
 }
 
 // retryUpdateWithFn 重试执行 BadgerDB Update 操作，处理事务冲突
-func (s *BotreonStore) retryUpdateWithFn(fn func(*badger.Txn) error, maxRetries int) error {
+func (s *BotreonStore) retryUpdateWithFn(fn func(*storeTxn) error, maxRetries int) error {
 	var err error
 	for i := 0; i < maxRetries; i++ {
-		err = s.db.Update(fn)
+		err = s.update(fn)
 		if err == nil {
 			return nil
 		}
@@ -57,33 +57,134 @@ func (s *BotreonStore) stringKey(key string) string {
 
 // Set 实现 Redis SET 命令
 func (s *BotreonStore) Set(key string, value string) error {
-	// 先更新写缓存
-	if s.writeCache != nil {
-		s.writeCache.Set(key, []byte(value))
-	}
-	// 同时更新读缓存（避免后续读取时缓存未命中）
-	if s.readCache != nil {
-		s.readCache.Set(key, []byte(value))
-	}
+	// 读取了类型键，与并发写入冲突时重试
+	return s.retryUpdate(func(txn *storeTxn) error {
+		return s.setStringTxn(txn, key, []byte(value), 0)
+	}, 30)
+}
 
-	return s.db.Update(func(txn *badger.Txn) error {
+// SetWithTTL 字符串操作，设置键值对并设置过期时间
+func (s *BotreonStore) SetWithTTL(key, value string, ttl time.Duration) error {
+	return s.retryUpdate(func(txn *storeTxn) error {
+		return s.setStringTxn(txn, key, []byte(value), ttl)
+	}, 30)
+}
+
+// ErrStringWrongType SET ... GET 的键存在但不是字符串
+var ErrStringWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// SetOptions SET 命令的选项
+type SetOptions struct {
+	NX        bool      // 只在键不存在时写入
+	XX        bool      // 只在键存在时写入
+	Get       bool      // 返回旧值，旧值不是字符串时返回 ErrStringWrongType
+	KeepTTL   bool      // 保留键原有的过期时间
+	ExpiresAt time.Time // 不为零时设置过期时间，已经过去的时间点写入后键立即过期
+}
+
+// SetResult SET 命令的结果
+type SetResult struct {
+	Written   bool   // NX/XX 条件满足，值已写入
+	Old       string // 旧值，只在 SetOptions.Get 时读取
+	OldExists bool   // 键原来是否存在
+}
+
+// SetWithOptions 实现带选项的 Redis SET 命令：条件检查、读取旧值与写入在同一事务中完成。
+// 键原来是其他类型时整个替换；没有 KeepTTL 时写入会清除原有的过期时间
+func (s *BotreonStore) SetWithOptions(key, value string, opts SetOptions) (SetResult, error) {
+	var result SetResult
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		result = SetResult{}
+		keyType := ""
+		typeItem, err := txn.Get(TypeOfKeyGet(key))
+		if err == nil {
+			val, err := typeItem.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			keyType = string(val)
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		var expiresAt uint64
+		if keyType != "" {
+			exp, err := s.expiresAtTxn(txn, key, keyType)
+			if err != nil {
+				return err
+			}
+			// 已过期但还未被删除时按不存在处理
+			if exp == 0 || expiresAtTime(exp).After(s.now()) {
+				result.OldExists, expiresAt = true, exp
+			}
+			if result.OldExists && keyType == KeyTypeString {
+				item, err := txn.Get([]byte(s.stringKey(key)))
+				if errors.Is(err, badger.ErrKeyNotFound) {
+					result.OldExists, expiresAt = false, 0
+				} else if err != nil {
+					return err
+				} else if opts.Get {
+					old, err := s.stringValueTxn(txn, key, item)
+					if err != nil {
+						return err
+					}
+					result.Old = string(old)
+				}
+			}
+		}
+		if opts.Get && result.OldExists && keyType != KeyTypeString {
+			return ErrStringWrongType
+		}
+		if (opts.NX && result.OldExists) || (opts.XX && !result.OldExists) {
+			return nil
+		}
+		result.Written = true
+
+		if keyType != "" && keyType != KeyTypeString {
+			if _, err := s.delTxn(txn, key); err != nil {
+				return err
+			}
+		}
+		switch {
+		case !opts.ExpiresAt.IsZero():
+			if !opts.ExpiresAt.After(s.now()) {
+				_, err := s.delTxn(txn, key)
+				return err
+			}
+			// #nosec G115 - ExpiresAt is after now, so UnixNano is positive
+			expiresAt = uint64(opts.ExpiresAt.UnixNano())
+		case !opts.KeepTTL:
+			expiresAt = 0
+		}
 		if err := txn.Set(TypeOfKeyGet(key), []byte(KeyTypeString)); err != nil {
 			return err
 		}
-		strKey := s.stringKey(key)
-		return s.setValueWithCompression(txn, []byte(strKey), []byte(value))
-	})
+		if err := s.dropStringChunksTxn(txn, key); err != nil {
+			return err
+		}
+		return s.setValueWithExpiresAt(txn, []byte(s.stringKey(key)), []byte(value), expiresAt)
+	}, 30)
+	return result, err
 }
 
-// SetWithTTL 字符串操作，设置键值对并设置过期时间
-func (s *BotreonStore) SetWithTTL(key, value string, ttl time.Duration) error {
-	return s.db.Update(func(txn *badger.Txn) error {
-		if err := txn.Set(TypeOfKeyGet(key), []byte(KeyTypeString)); err != nil {
+// setStringTxn 在 txn 中写入字符串键，ttl 为 0 时不过期；键原来是其他类型时先删除原有数据
+func (s *BotreonStore) setStringTxn(txn *storeTxn, key string, value []byte, ttl time.Duration) error {
+	keyType, err := keyTypeTxn(txn, key)
+	if err != nil {
+		return err
+	}
+	if keyType != "" && keyType != KeyTypeString {
+		if _, err := s.delTxn(txn, key); err != nil {
 			return err
 		}
-		strKey := s.stringKey(key)
-		return s.setEntryWithCompression(txn, []byte(strKey), []byte(value), ttl)
-	})
+	}
+	if err := txn.Set(TypeOfKeyGet(key), []byte(KeyTypeString)); err != nil {
+		return err
+	}
+	if err := s.dropStringChunksTxn(txn, key); err != nil {
+		return err
+	}
+	return s.setEntryWithCompression(txn, []byte(s.stringKey(key)), value, ttl)
 }
 
 // SetEX 实现 Redis SETEX 命令，设置键值对并设置过期时间（秒）
@@ -99,7 +200,7 @@ func (s *BotreonStore) PSETEX(key string, value string, milliseconds int64) erro
 // SetNX 实现 Redis SETNX 命令，仅当键不存在时设置
 func (s *BotreonStore) SetNX(key string, value string) (bool, error) {
 	success := false
-	err := s.db.Update(func(txn *badger.Txn) error {
+	err := s.update(func(txn *storeTxn) error {
 		strKey := s.stringKey(key)
 		_, err := txn.Get([]byte(strKey))
 		if err == nil {
@@ -126,11 +227,11 @@ func (s *BotreonStore) SetNX(key string, value string) (bool, error) {
 func (s *BotreonStore) GetSet(key string, value string) (string, error) {
 	// 先读取旧值（在 View 事务中）
 	var oldValue string
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		strKey := s.stringKey(key)
 		item, err := txn.Get([]byte(strKey))
 		if err == nil {
-			val, err := s.getValueWithDecompression(item)
+			val, err := s.stringValueTxn(txn, key, item)
 			if err != nil {
 				return err
 			}
@@ -150,7 +251,7 @@ func (s *BotreonStore) GetSet(key string, value string) (string, error) {
 // MGet 实现 Redis MGET 命令，获取多个键的值
 func (s *BotreonStore) MGet(keys ...string) ([]string, error) {
 	values := make([]string, len(keys))
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		for i, key := range keys {
 			strKey := s.stringKey(key)
 			item, err := txn.Get([]byte(strKey))
@@ -161,7 +262,7 @@ func (s *BotreonStore) MGet(keys ...string) ([]string, error) {
 				}
 				return err
 			}
-			val, err := s.getValueWithDecompression(item)
+			val, err := s.stringValueTxn(txn, key, item)
 			if err != nil {
 				return err
 			}
@@ -177,20 +278,14 @@ func (s *BotreonStore) MSet(keyValues ...string) error {
 	if len(keyValues)%2 != 0 {
 		return errors.New("MSET requires an even number of arguments")
 	}
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.retryUpdate(func(txn *storeTxn) error {
 		for i := 0; i < len(keyValues); i += 2 {
-			key := keyValues[i]
-			value := keyValues[i+1]
-			if err := txn.Set(TypeOfKeyGet(key), []byte(KeyTypeString)); err != nil {
-				return err
-			}
-			strKey := s.stringKey(key)
-			if err := txn.Set([]byte(strKey), []byte(value)); err != nil {
+			if err := s.setStringTxn(txn, keyValues[i], []byte(keyValues[i+1]), 0); err != nil {
 				return err
 			}
 		}
 		return nil
-	})
+	}, 30)
 }
 
 // MSetNX 实现 Redis MSETNX 命令，仅当所有键都不存在时设置多个键值对
@@ -199,7 +294,7 @@ func (s *BotreonStore) MSetNX(keyValues ...string) (bool, error) {
 		return false, errors.New("MSETNX requires an even number of arguments")
 	}
 	success := false
-	err := s.db.Update(func(txn *badger.Txn) error {
+	err := s.update(func(txn *storeTxn) error {
 		// 先检查所有键是否都不存在
 		for i := 0; i < len(keyValues); i += 2 {
 			key := keyValues[i]
@@ -233,44 +328,104 @@ func (s *BotreonStore) MSetNX(keyValues ...string) (bool, error) {
 
 // Get 实现 Redis GET 命令
 func (s *BotreonStore) Get(key string) (string, error) {
-	// 先检查读缓存
-	if s.readCache != nil {
-		if cachedValue, found := s.readCache.Get(key); found {
-			return string(cachedValue), nil
+	val, err := s.cachedValue([]byte(s.stringKey(key)), key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return "", ErrKeyNotFound // 返回特定错误表示键不存在
+	}
+	if _, chunked := decodeStringManifest(val); chunked && err == nil {
+		// 分段存储的值不进读缓存（缓存的是清单），在一个事务中读取清单与各段
+		err = s.view(func(txn *storeTxn) error {
+			item, err := txn.Get([]byte(s.stringKey(key)))
+			if err != nil {
+				return err
+			}
+			val, err = s.stringValueTxn(txn, key, item)
+			return err
+		})
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return "", ErrKeyNotFound
 		}
 	}
+	return string(val), err
+}
 
-	var val string
-	err := s.db.View(func(txn *badger.Txn) error {
-		strKey := s.stringKey(key)
-		item, err := txn.Get([]byte(strKey))
+// GetEx 实现 Redis GETEX 命令，返回值并在同一事务中修改过期时间：
+// persist 为 true 时移除过期时间；expiresAt 不为零时设置新的过期时间，已经过去的时间点会删除键；两者都没有时不修改。
+// 键不存在时返回 ErrKeyNotFound
+func (s *BotreonStore) GetEx(key string, expiresAt time.Time, persist bool) (string, error) {
+	var value string
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		valueKey := []byte(s.stringKey(key))
+		item, err := txn.Get(valueKey)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return ErrKeyNotFound
+		}
 		if err != nil {
-			if errors.Is(err, badger.ErrKeyNotFound) {
-				return ErrKeyNotFound // 返回特定错误表示键不存在
-			}
 			return err
 		}
-		valBytes, err := s.getValueWithDecompression(item)
+		valBytes, err := s.stringValueTxn(txn, key, item)
 		if err != nil {
 			return err
 		}
-		val = string(valBytes)
-		return nil
-	})
-
-	// 如果成功，更新读缓存
-	if err == nil && s.readCache != nil {
-		s.readCache.Set(key, []byte(val))
+		value = string(valBytes)
+		switch {
+		case persist:
+			if item.ExpiresAt() == 0 {
+				return nil
+			}
+		case !expiresAt.IsZero():
+			if !expiresAt.After(s.now()) {
+				_, err := s.delTxn(txn, key)
+				return err
+			}
+		default:
+			return nil
+		}
+		// 按原样（可能是压缩后的字节）写回，只修改过期时间
+		raw, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		e := badger.NewEntry(valueKey, raw)
+		if !persist {
+			// #nosec G115 - expiresAt is after now, so UnixNano is positive
+			e.ExpiresAt = uint64(expiresAt.UnixNano())
+		}
+		return txn.SetEntry(e)
+	}, 30)
+	if errors.Is(err, ErrKeyNotFound) {
+		return "", ErrKeyNotFound
 	}
+	return value, err
+}
 
+// GetDel 实现 Redis GETDEL 命令，返回值并删除键；键不存在时返回 ErrKeyNotFound
+func (s *BotreonStore) GetDel(key string) (string, error) {
+	var value string
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		item, err := txn.Get([]byte(s.stringKey(key)))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return ErrKeyNotFound
+		}
+		if err != nil {
+			return err
+		}
+		valBytes, err := s.stringValueTxn(txn, key, item)
+		if err != nil {
+			return err
+		}
+		value = string(valBytes)
+		_, err = s.delTxn(txn, key)
+		return err
+	}, 30)
 	if errors.Is(err, ErrKeyNotFound) {
 		return "", ErrKeyNotFound
 	}
-	return val, err
+	return value, err
 }
 
 // getIntValue 获取整数值，如果键不存在或不是整数，返回0和错误
-func (s *BotreonStore) getIntValue(txn *badger.Txn, key string) (int64, error) {
+func (s *BotreonStore) getIntValue(txn *storeTxn, key string) (int64, error) {
 	strKey := s.stringKey(key)
 	item, err := txn.Get([]byte(strKey))
 	if err != nil {
@@ -285,13 +440,13 @@ func (s *BotreonStore) getIntValue(txn *badger.Txn, key string) (int64, error) {
 	}
 	intVal, err := strconv.ParseInt(string(val), 10, 64)
 	if err != nil {
-		return 0, fmt.Errorf("value is not an integer")
+		return 0, fmt.Errorf("value is not an integer or out of range")
 	}
 	return intVal, nil
 }
 
 // setIntValue 设置整数值
-func (s *BotreonStore) setIntValue(txn *badger.Txn, key string, value int64) error {
+func (s *BotreonStore) setIntValue(txn *storeTxn, key string, value int64) error {
 	if err := txn.Set(TypeOfKeyGet(key), []byte(KeyTypeString)); err != nil {
 		return err
 	}
@@ -305,7 +460,7 @@ func (s *BotreonStore) INCR(key string) (int64, error) {
 	defer s.keyLockMgr.Unlock(key)
 
 	var newValue int64
-	err := s.db.Update(func(txn *badger.Txn) error {
+	err := s.update(func(txn *storeTxn) error {
 		oldValue, err := s.getIntValue(txn, key)
 		if err != nil {
 			return err
@@ -322,7 +477,7 @@ func (s *BotreonStore) INCRBY(key string, increment int64) (int64, error) {
 	defer s.keyLockMgr.Unlock(key)
 
 	var newValue int64
-	err := s.db.Update(func(txn *badger.Txn) error {
+	err := s.update(func(txn *storeTxn) error {
 		oldValue, err := s.getIntValue(txn, key)
 		if err != nil {
 			return err
@@ -361,75 +516,70 @@ func (s *BotreonStore) INCRBYFLOAT(key string, increment float64) (float64, erro
 	return newValue, s.Set(key, newValueStr)
 }
 
-// APPEND 实现 Redis APPEND 命令，追加字符串
+// APPEND 实现 Redis APPEND 命令，追加字符串。分段存储的大值只改写末尾的分段
 func (s *BotreonStore) APPEND(key string, value string) (int, error) {
-	// 先读取旧值（在 View 事务中）
-	var existingValue string
-	err := s.db.View(func(txn *badger.Txn) error {
-		strKey := s.stringKey(key)
-		item, err := txn.Get([]byte(strKey))
-		if err == nil {
-			val, err := s.getValueWithDecompression(item)
-			if err != nil {
-				return err
-			}
-			existingValue = string(val)
-		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+	var newLength uint64
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		item, err := s.stringItemTxn(txn, key)
+		if err != nil {
 			return err
 		}
-		return nil
-	})
-	if err != nil {
-		return 0, err
+		newLength, err = s.writeStringRangeTxn(txn, key, item, -1, []byte(value))
+		return err
+	}, 30)
+	// #nosec G115 - length is bounded by the value size limit
+	return int(newLength), err
+}
+
+// stringItemTxn 在 txn 中读取 STRING:<key>，键不存在时返回 nil
+func (s *BotreonStore) stringItemTxn(txn *storeTxn, key string) (*badger.Item, error) {
+	item, err := txn.Get([]byte(s.stringKey(key)))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
 	}
-	// 然后写入新值
-	newValue := existingValue + value
-	newLength := len(newValue)
-	return newLength, s.Set(key, newValue)
+	return item, err
 }
 
-// StrLen 实现 Redis STRLEN 命令，获取字符串长度
+// StrLen 实现 Redis STRLEN 命令，获取字符串长度。分段存储的值直接返回清单中的长度
 func (s *BotreonStore) StrLen(key string) (int, error) {
 	var length int
-	err := s.db.View(func(txn *badger.Txn) error {
-		strKey := s.stringKey(key)
-		item, err := txn.Get([]byte(strKey))
-		if err != nil {
-			if errors.Is(err, badger.ErrKeyNotFound) {
-				length = 0
-				return nil
-			}
+	err := s.view(func(txn *storeTxn) error {
+		item, err := s.stringItemTxn(txn, key)
+		if err != nil || item == nil {
 			return err
 		}
 		val, err := s.getValueWithDecompression(item)
 		if err != nil {
 			return err
 		}
-		length = len(string(val))
+		length = len(val)
+		if n, chunked := decodeStringManifest(val); chunked {
+			// #nosec G115 - length is bounded by the value size limit
+			length = int(n)
+		}
 		return nil
 	})
 	return length, err
 }
 
-// GetRange 实现 Redis GETRANGE 命令，获取字符串的子串
+// GetRange 实现 Redis GETRANGE 命令，获取字符串的子串。分段存储的值只读取范围涉及的分段
 func (s *BotreonStore) GetRange(key string, start, end int) (string, error) {
 	var result string
-	err := s.db.View(func(txn *badger.Txn) error {
-		strKey := s.stringKey(key)
-		item, err := txn.Get([]byte(strKey))
-		if err != nil {
-			if errors.Is(err, badger.ErrKeyNotFound) {
-				result = ""
-				return nil
-			}
+	err := s.view(func(txn *storeTxn) error {
+		item, err := s.stringItemTxn(txn, key)
+		if err != nil || item == nil {
 			return err
 		}
 		val, err := s.getValueWithDecompression(item)
 		if err != nil {
 			return err
 		}
-		str := string(val)
-		strLen := len(str)
+		strLen := len(val)
+		length, chunked := decodeStringManifest(val)
+		if chunked {
+			// #nosec G115 - length is bounded by the value size limit
+			strLen = int(length)
+		}
 		// 处理负数索引
 		if start < 0 {
 			start = strLen + start
@@ -444,606 +594,45 @@ func (s *BotreonStore) GetRange(key string, start, end int) (string, error) {
 			}
 		}
 		if start > strLen {
-			result = ""
 			return nil
 		}
 		if end >= strLen {
 			end = strLen - 1
 		}
 		if start > end {
-			result = ""
 			return nil
 		}
-		result = str[start : end+1]
-		return nil
-	})
-	return result, err
-}
-
-// SetRange 实现 Redis SETRANGE 命令，设置字符串的子串
-func (s *BotreonStore) SetRange(key string, offset int, value string) (int, error) {
-	// 先读取旧值（在 View 事务中）
-	var existingValue string
-	err := s.db.View(func(txn *badger.Txn) error {
-		strKey := s.stringKey(key)
-		item, err := txn.Get([]byte(strKey))
-		if err == nil {
-			val, err := s.getValueWithDecompression(item)
-			if err != nil {
-				return err
-			}
-			existingValue = string(val)
-		} else if !errors.Is(err, badger.ErrKeyNotFound) {
-			return err
-		}
-		return nil
-	})
-	if err != nil {
-		return 0, err
-	}
-	// 如果offset超出当前长度，用null字节填充
-	if offset > len(existingValue) {
-		existingValue += string(make([]byte, offset-len(existingValue)))
-	}
-	// 构建新字符串
-	var newValue string
-	if offset > 0 {
-		newValue = existingValue[:offset]
-	}
-	newValue += value
-	if offset+len(value) < len(existingValue) {
-		newValue += existingValue[offset+len(value):]
-	}
-	newLength := len(newValue)
-	// 写入新值
-	return newLength, s.Set(key, newValue)
-}
-
-// getStringBytes 获取字符串的字节数组
-func (s *BotreonStore) getStringBytes(txn *badger.Txn, key string) ([]byte, error) {
-	strKey := s.stringKey(key)
-	item, err := txn.Get([]byte(strKey))
-	if err != nil {
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			return []byte{}, nil
-		}
-		return nil, err
-	}
-	val, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, err
-	}
-	return decompressData(val)
-}
-
-// GetBit 实现 Redis GETBIT 命令，获取指定位的值
-func (s *BotreonStore) GetBit(key string, offset int) (int, error) {
-	var bit int
-	err := s.db.View(func(txn *badger.Txn) error {
-		data, err := s.getStringBytes(txn, key)
-		if err != nil {
-			return err
-		}
-		byteIndex := offset / 8
-		bitIndex := offset % 8
-		if byteIndex >= len(data) {
-			bit = 0
+		if !chunked {
+			result = string(val[start : end+1])
 			return nil
 		}
-		if (data[byteIndex] & (1 << (7 - bitIndex))) != 0 {
-			bit = 1
-		} else {
-			bit = 0
-		}
-		return nil
+		// #nosec G115 - start and end are within [0, strLen) here
+		part, err := readStringChunks(txn, key, uint64(start), uint64(end)+1)
+		result = string(part)
+		return err
 	})
-	return bit, err
+	return result, err
 }
 
-// SetBit 实现 Redis SETBIT 命令，设置指定位的值
-func (s *BotreonStore) SetBit(key string, offset int, value int) (int, error) {
-	// 清除读缓存
-	if s.readCache != nil {
-		s.readCache.Delete(key)
+// SetRange 实现 Redis SETRANGE 命令，设置字符串的子串，超出当前长度的部分用零字节填充。
+// 分段存储的大值只改写范围涉及的分段
+func (s *BotreonStore) SetRange(key string, offset int, value string) (int, error) {
+	if offset < 0 {
+		return 0, errors.New("offset is out of range")
 	}
-	var oldBit int
-	err := s.db.Update(func(txn *badger.Txn) error {
-		data, err := s.getStringBytes(txn, key)
-		if err != nil {
-			return err
-		}
-		byteIndex := offset / 8
-		bitIndex := offset % 8
-		// 扩展数据如果需要
-		if byteIndex >= len(data) {
-			newData := make([]byte, byteIndex+1)
-			copy(newData, data)
-			data = newData
-		}
-		// 获取旧位值
-		if (data[byteIndex] & (1 << (7 - bitIndex))) != 0 {
-			oldBit = 1
-		} else {
-			oldBit = 0
-		}
-		// 设置新位值
-		if value == 1 {
-			data[byteIndex] |= (1 << (7 - bitIndex))
-		} else {
-			data[byteIndex] &^= (1 << (7 - bitIndex))
-		}
-		// 保存（带压缩）
-		if err := txn.Set(TypeOfKeyGet(key), []byte(KeyTypeString)); err != nil {
-			return err
-		}
-		strKey := s.stringKey(key)
-		return s.setValueWithCompression(txn, []byte(strKey), data)
-	})
-	return oldBit, err
-}
-
-// BitCount 实现 Redis BITCOUNT 命令，计算字符串中1的位数
-func (s *BotreonStore) BitCount(key string, start, end int) (int, error) {
-	var count int
-	err := s.db.View(func(txn *badger.Txn) error {
-		data, err := s.getStringBytes(txn, key)
-		if err != nil {
-			return err
-		}
-		if len(data) == 0 {
-			return nil
-		}
-		// 处理负数索引
-		if start < 0 {
-			start = len(data) + start
-			if start < 0 {
-				start = 0
-			}
-		}
-		if end < 0 {
-			end = len(data) + end
-			if end < 0 {
-				end = -1
-			}
-		}
-		if start > len(data) {
-			return nil
-		}
-		if end >= len(data) {
-			end = len(data) - 1
-		}
-		if start > end {
-			return nil
-		}
-		// 计算指定范围内的1的位数
-		for i := start; i <= end; i++ {
-			for j := 0; j < 8; j++ {
-				if (data[i] & (1 << (7 - j))) != 0 {
-					count++
-				}
-			}
-		}
-		return nil
-	})
-	return count, err
-}
-
-// BitOp 实现 Redis BITOP 命令，位操作
-func (s *BotreonStore) BitOp(op string, destKey string, keys ...string) (int, error) {
-	// 清除读缓存
-	if s.readCache != nil {
-		s.readCache.Delete(destKey)
+	// 先检查结果长度，避免按过大的 offset 分配内存
+	if err := s.CheckValueSize(int64(offset) + int64(len(value))); err != nil {
+		return 0, err
 	}
-	var resultLength int
-	err := s.db.Update(func(txn *badger.Txn) error {
-		if len(keys) == 0 {
-			return errors.New("BITOP requires at least one source key")
-		}
-		// 获取所有源键的数据
-		sources := make([][]byte, len(keys))
-		maxLen := 0
-		for i, key := range keys {
-			data, err := s.getStringBytes(txn, key)
-			if err != nil {
-				return err
-			}
-			sources[i] = data
-			if len(data) > maxLen {
-				maxLen = len(data)
-			}
-		}
-		if maxLen == 0 {
-			// 所有键都为空，结果也为空
-			if err := txn.Set(TypeOfKeyGet(destKey), []byte(KeyTypeString)); err != nil {
-				return err
-			}
-			strKey := s.stringKey(destKey)
-			return txn.Set([]byte(strKey), []byte{})
-		}
-		// 执行位操作
-		result := make([]byte, maxLen)
-		for i := 0; i < maxLen; i++ {
-			var byteVal byte
-			switch op {
-			case "AND":
-				byteVal = 0xFF
-				for _, src := range sources {
-					var b byte
-					if i < len(src) {
-						b = src[i]
-					}
-					byteVal &= b
-				}
-			case "OR":
-				for _, src := range sources {
-					var b byte
-					if i < len(src) {
-						b = src[i]
-					}
-					byteVal |= b
-				}
-			case "XOR":
-				for _, src := range sources {
-					var b byte
-					if i < len(src) {
-						b = src[i]
-					}
-					byteVal ^= b
-				}
-			case "NOT":
-				if len(keys) != 1 {
-					return errors.New("BITOP NOT requires exactly one source key")
-				}
-				var b byte
-				if i < len(sources[0]) {
-					b = sources[0][i]
-				}
-				byteVal = ^b
-			default:
-				return fmt.Errorf("unknown bitop operation: %s", op)
-			}
-			result[i] = byteVal
-		}
-		resultLength = len(result)
-		// 保存结果
-		if err := txn.Set(TypeOfKeyGet(destKey), []byte(KeyTypeString)); err != nil {
-			return err
-		}
-		strKey := s.stringKey(destKey)
-		return txn.Set([]byte(strKey), result)
-	})
-	return resultLength, err
-}
-
-// BitPos 实现 Redis BITPOS 命令，查找第一个设置或清除的位
-func (s *BotreonStore) BitPos(key string, bit int, start, end int) (int, error) {
-	var pos int = -1
-	err := s.db.View(func(txn *badger.Txn) error {
-		data, err := s.getStringBytes(txn, key)
+	var newLength uint64
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		item, err := s.stringItemTxn(txn, key)
 		if err != nil {
 			return err
 		}
-		if len(data) == 0 {
-			if bit == 1 {
-				pos = -1
-			} else {
-				pos = 0
-			}
-			return nil
-		}
-		// 处理负数索引
-		if start < 0 {
-			start = len(data) + start
-			if start < 0 {
-				start = 0
-			}
-		}
-		if end < 0 {
-			end = len(data) + end
-			if end < 0 {
-				end = -1
-			}
-		}
-		if start > len(data) {
-			pos = -1
-			return nil
-		}
-		if end >= len(data) {
-			end = len(data) - 1
-		}
-		if start > end {
-			pos = -1
-			return nil
-		}
-		// 查找指定位
-		for i := start; i <= end; i++ {
-			for j := 0; j < 8; j++ {
-				bitOffset := i*8 + j
-				currentBit := 0
-				if (data[i] & (1 << (7 - j))) != 0 {
-					currentBit = 1
-				}
-				if currentBit == bit {
-					pos = bitOffset
-					return nil
-				}
-			}
-		}
-		// 未找到
-		pos = -1
-		return nil
-	})
-	return pos, err
-}
-
-// BitLen 实现 Redis BITLEN 命令，获取字符串的位长度
-func (s *BotreonStore) BitLen(key string) (int, error) {
-	var length int
-	err := s.db.View(func(txn *badger.Txn) error {
-		data, err := s.getStringBytes(txn, key)
-		if err != nil {
-			return err
-		}
-		length = len(data) * 8
-		return nil
-	})
-	return length, err
-}
-
-// BitFieldResult represents the result of a BITFIELD operation
-type BitFieldResult struct {
-	Value int64 // The result value (nil if overflow)
-	Overshifted bool // Whether the result was overflowed/undershifted
-}
-
-// BitFieldResultWithOverflow wraps a result with overflow information
-type BitFieldResultWithOverflow struct {
-	Value int64
-	Overflow string // "overflow", "sat", "fail"
-}
-
-// parseBitFieldType parses a type string like "i8", "u16", "i32", "u64" etc.
-func parseBitFieldType(typeStr string) (isSigned bool, bits int, err error) {
-	if len(typeStr) < 2 {
-		return false, 0, fmt.Errorf("invalid type: %s", typeStr)
-	}
-	if typeStr[0] == 'i' {
-		isSigned = true
-	} else if typeStr[0] == 'u' {
-		isSigned = false
-	} else {
-		return false, 0, fmt.Errorf("invalid type: %s", typeStr)
-	}
-	bits, err = strconv.Atoi(typeStr[1:])
-	if err != nil {
-		return false, 0, fmt.Errorf("invalid bit count: %s", typeStr)
-	}
-	if bits <= 0 || bits > 64 {
-		return false, 0, fmt.Errorf("invalid bit count: %d", bits)
-	}
-	return isSigned, bits, nil
-}
-
-// BitField implements the BITFIELD command
-// BITFIELD key [GET type offset | SET type offset value | INCRBY type offset increment] ...
-func (s *BotreonStore) BitField(key string, operations []string) ([]interface{}, error) {
-	// Parse operations
-	type operation struct {
-		op string // "GET", "SET", "INCRBY"
-		isSigned bool
-		bits int
-		offset int64
-		value int64
-	}
-
-	ops := make([]operation, 0, len(operations))
-	for i := 0; i < len(operations); {
-		opName := operations[i]
-		i++
-
-		if i >= len(operations) {
-			return nil, fmt.Errorf("BITFIELD: missing arguments for %s", opName)
-		}
-
-		// Next argument should be the type (e.g., "u8", "i16")
-		typeStr := operations[i]
-		i++
-
-		isSigned, bits, err := parseBitFieldType(typeStr)
-		if err != nil {
-			return nil, err
-		}
-
-		if i >= len(operations) {
-			return nil, fmt.Errorf("BITFIELD: missing offset for %s %s", opName, typeStr)
-		}
-
-		offsetStr := operations[i]
-		i++
-
-		offset, err := strconv.ParseInt(offsetStr, 10, 64)
-		if err != nil {
-			return nil, fmt.Errorf("BITFIELD: invalid offset: %s", offsetStr)
-		}
-
-		var value int64 = 0
-		if opName == "SET" || opName == "INCRBY" {
-			if i >= len(operations) {
-				return nil, fmt.Errorf("BITFIELD: missing value for %s %s", opName, typeStr)
-			}
-			valueStr := operations[i]
-			i++
-			value, err = strconv.ParseInt(valueStr, 10, 64)
-			if err != nil {
-				return nil, fmt.Errorf("BITFIELD: invalid value: %s", valueStr)
-			}
-		}
-
-		ops = append(ops, operation{
-			op: opName,
-			isSigned: isSigned,
-			bits: bits,
-			offset: offset,
-			value: value,
-		})
-	}
-
-	// Execute operations in a transaction
-	results := make([]interface{}, 0, len(ops))
-
-	err := s.db.Update(func(txn *badger.Txn) error {
-		// First, get the current value
-		data, err := s.getStringBytes(txn, key)
-		if err != nil {
-			if errors.Is(err, ErrKeyNotFound) {
-				// Key doesn't exist, treat as all zeros
-				data = []byte{}
-			} else {
-				return err
-			}
-		}
-		originalLen := len(data)
-
-		for _, op := range ops {
-			// Convert offset (can be negative, meaning from end)
-			bitOffset := op.offset
-			if bitOffset < 0 {
-				// Negative offset means from the end
-				// For simplicity, we don't support this for now
-				return fmt.Errorf("BITFIELD: negative offset not supported")
-			}
-
-			// Calculate byte and bit positions
-			byteIndex := int(bitOffset) / 8
-			_ = byteIndex
-			numBytes := (int(bitOffset) + int(op.bits) + 7) / 8
-
-			// Ensure data is long enough
-			if byteIndex >= len(data) {
-				newData := make([]byte, numBytes)
-				copy(newData, data)
-				data = newData
-			}
-
-			// Extract value from data
-			var extractedValue int64 = 0
-			for j := 0; j < op.bits; j++ {
-				currentBitOffset := bitOffset + int64(j)
-				currentByteIndex := int(currentBitOffset) / 8
-				currentBitIndex := uint(currentBitOffset) % 8
-				if currentByteIndex < len(data) && (data[currentByteIndex]&(1<<(7-currentBitIndex))) != 0 {
-					extractedValue |= int64(1) << uint(j)
-				}
-			}
-
-			// For signed types, convert from unsigned to signed
-			if op.isSigned {
-				// Check if the sign bit is set
-				signBit := int64(1) << uint(op.bits-1)
-				if (extractedValue & signBit) != 0 {
-					// Negative number: convert to negative
-					mask := int64(1)<<uint(op.bits) - 1
-					extractedValue = extractedValue | ^mask
-				}
-			}
-
-			var resultValue int64
-			switch op.op {
-			case "GET":
-				resultValue = extractedValue
-				results = append(results, resultValue)
-			case "SET":
-				// Write value to data
-				for j := 0; j < op.bits; j++ {
-					currentBitOffset := bitOffset + int64(j)
-					currentByteIndex := int(currentBitOffset) / 8
-					currentBitIndex := uint(currentBitOffset) % 8
-					bitValue := (op.value >> uint(j)) & 1
-					if bitValue == 1 {
-						data[currentByteIndex] |= (1 << (7 - currentBitIndex))
-					} else {
-						data[currentByteIndex] &^= (1 << (7 - currentBitIndex))
-					}
-				}
-				resultValue = extractedValue
-				results = append(results, resultValue)
-			case "INCRBY":
-				// Calculate new value with overflow handling
-				// Default overflow mode is WRAP (wrap around)
-				newValue := extractedValue + op.value
-
-				// For WRAP mode (default), wrap around
-				if op.isSigned {
-					// Signed wrap around (modulo 2^N)
-					bits := op.bits
-					if bits < 64 {
-						mod := int64(1) << uint(bits)
-						newValue = newValue % mod
-						if newValue >= int64(1)<<uint(bits-1) {
-							newValue -= mod
-						}
-					}
-					// For 64-bit, Go's int64 already wraps
-				} else {
-					maxVal := int64(1)<<uint(op.bits) - 1
-					// For unsigned, wrap around naturally (modulo behavior)
-					if newValue > maxVal {
-						newValue = newValue % (maxVal + 1)
-					} else if newValue < 0 {
-						// For negative values, wrap to positive
-						newValue = (maxVal + 1) - ((-newValue - 1) % (maxVal + 1))
-					}
-				}
-
-				// Write new value to data
-				for j := 0; j < op.bits; j++ {
-					currentBitOffset := bitOffset + int64(j)
-					currentByteIndex := int(currentBitOffset) / 8
-					currentBitIndex := uint(currentBitOffset) % 8
-					bitValue := (newValue >> uint(j)) & 1
-					if bitValue == 1 {
-						data[currentByteIndex] |= (1 << (7 - currentBitIndex))
-					} else {
-						data[currentByteIndex] &^= (1 << (7 - currentBitIndex))
-					}
-				}
-				results = append(results, newValue)
-			}
-
-			// Update TTL if needed
-			if len(data) > originalLen {
-				// Get the current TTL if any
-				typeKey := TypeOfKeyGet(key)
-				if item, err := txn.Get(typeKey); err == nil {
-					val, _ := item.ValueCopy(nil)
-					if string(val) == KeyTypeString {
-						strKey := s.stringKey(key)
-						if strItem, err := txn.Get([]byte(strKey)); err == nil {
-							if expiresAt := strItem.ExpiresAt(); expiresAt > 0 {
-								ttl := time.Duration(int64(expiresAt) - time.Now().UnixNano())
-								if ttl > 0 {
-									_ = txn.Delete([]byte(strKey))
-								}
-							}
-						}
-					}
-				}
-			}
-		}
-
-		// Save the updated data
-		if len(data) > 0 {
-			strKey := s.stringKey(key)
-			if err := txn.Set(TypeOfKeyGet(key), []byte(KeyTypeString)); err != nil {
-				return err
-			}
-			if err := txn.Set([]byte(strKey), data); err != nil {
-				return err
-			}
-		}
-
-		return nil
-	})
-
-	return results, err
+		newLength, err = s.writeStringRangeTxn(txn, key, item, int64(offset), []byte(value))
+		return err
+	}, 30)
+	// #nosec G115 - length is bounded by the value size limit
+	return int(newLength), err
 }
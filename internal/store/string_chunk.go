@@ -0,0 +1,206 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// 大字符串的分段存储：SETRANGE、APPEND 使值超过 stringChunkSize 时，值按固定大小分段保存在
+// STRCHUNK:<key>:<index:8> 中，STRING:<key> 中只保留清单（魔数与总长度），过期时间也只在清单上。
+// 之后的 SETRANGE、APPEND、GETRANGE 只读写涉及的分段；GET 等读取整个值时在同一事务中拼接。
+// 分段不存在或比 stringChunkSize 短时，缺少的部分按零字节处理。
+// SET 等整体写入总是写回普通的值，并删除旧的分段
+const (
+	stringChunkSize    = 64 << 10
+	keyTypeStringChunk = "STRCHUNK"
+)
+
+// stringChunkMagic 清单的前缀，之后是 8 字节大端总长度。压缩数据与冷层占位值的前缀不同，不会混淆
+var stringChunkMagic = []byte("\x00BCHUNK\x01")
+
+// stringChunkPrefix 分段键的前缀 STRCHUNK:<key>:
+func stringChunkPrefix(key string) []byte {
+	return []byte(keyTypeStringChunk + ":" + key + ":")
+}
+
+// stringChunkKey 第 index 个分段的键
+func stringChunkKey(key string, index uint64) []byte {
+	return binary.BigEndian.AppendUint64(stringChunkPrefix(key), index)
+}
+
+func encodeStringManifest(length uint64) []byte {
+	return binary.BigEndian.AppendUint64(bytes.Clone(stringChunkMagic), length)
+}
+
+// decodeStringManifest 值是清单时返回总长度
+func decodeStringManifest(val []byte) (uint64, bool) {
+	if len(val) != len(stringChunkMagic)+8 || !bytes.HasPrefix(val, stringChunkMagic) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(val[len(stringChunkMagic):]), true
+}
+
+// loadStringChunks 打开时检查是否存在分段字符串，没有时覆盖写入不需要查找旧的分段
+func (s *BotreonStore) loadStringChunks() error {
+	return s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(keyTypeStringChunk + ":")
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+		iter.Rewind()
+		s.stringChunks.Store(iter.Valid())
+		return nil
+	})
+}
+
+// stringValueTxn 读取字符串键 item（STRING:<key>）的完整值，分段存储时在 txn 中拼接各段
+func (s *BotreonStore) stringValueTxn(txn *storeTxn, key string, item *badger.Item) ([]byte, error) {
+	val, err := s.getValueWithDecompression(item)
+	if err != nil {
+		return nil, err
+	}
+	length, ok := decodeStringManifest(val)
+	if !ok {
+		return val, nil
+	}
+	return readStringChunks(txn, key, 0, length)
+}
+
+// readStringChunks 读取分段字符串 [start, end) 范围的字节，只读取涉及的分段
+func readStringChunks(txn *storeTxn, key string, start, end uint64) ([]byte, error) {
+	result := make([]byte, end-start)
+	for index := start / stringChunkSize; index*stringChunkSize < end; index++ {
+		item, err := txn.Get(stringChunkKey(key, index))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		base := index * stringChunkSize
+		if err := item.Value(func(chunk []byte) error {
+			lo, hi := max(start, base), min(end, base+uint64(len(chunk)))
+			if lo < hi {
+				copy(result[lo-start:], chunk[lo-base:hi-base])
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// writeStringChunks 把 data 写入分段字符串的 offset 处，只改写涉及的分段
+func writeStringChunks(txn *storeTxn, key string, offset uint64, data []byte) error {
+	end := offset + uint64(len(data))
+	for index := offset / stringChunkSize; index*stringChunkSize < end; index++ {
+		base := index * stringChunkSize
+		lo, hi := max(offset, base), min(end, base+stringChunkSize)
+		chunkKey := stringChunkKey(key, index)
+		var chunk []byte
+		if lo > base || hi < base+stringChunkSize {
+			// 只覆盖分段的一部分，保留其余字节
+			item, err := txn.Get(chunkKey)
+			if err == nil {
+				if chunk, err = item.ValueCopy(nil); err != nil {
+					return err
+				}
+			} else if !errors.Is(err, badger.ErrKeyNotFound) {
+				return err
+			}
+		}
+		if n := hi - base; uint64(len(chunk)) < n {
+			chunk = append(chunk, make([]byte, n-uint64(len(chunk)))...)
+		}
+		copy(chunk[lo-base:], data[lo-offset:hi-offset])
+		if err := txn.Set(chunkKey, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropStringChunksTxn 整体覆盖字符串之前删除 key 旧的分段
+func (s *BotreonStore) dropStringChunksTxn(txn *storeTxn, key string) error {
+	if !s.stringChunks.Load() {
+		return nil
+	}
+	prefix := stringChunkPrefix(key)
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	iter := txn.NewIterator(opts)
+	defer iter.Close()
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		k := iter.Item().Key()
+		if len(k) != len(prefix)+8 {
+			continue // 以 <key>: 开头的其他键的分段
+		}
+		if err := txn.Delete(bytes.Clone(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeStringRangeTxn 在 txn 中把 data 写入字符串 key 的 offset 处（SETRANGE 与 APPEND），
+// 返回写入后的长度。item 为 STRING:<key> 当前的值，键不存在时为 nil；offset 为 -1 时追加到末尾。
+// 结果不超过 stringChunkSize 的普通值整体改写；更长时转换为分段存储（只在第一次复制整个值），
+// 之后只改写涉及的分段。过期时间保持不变，结果超过 CheckValueSize 的上限时返回错误
+func (s *BotreonStore) writeStringRangeTxn(txn *storeTxn, key string, item *badger.Item, offset int64, data []byte) (uint64, error) {
+	var current []byte
+	var expiresAt uint64
+	length, chunked := uint64(0), false
+	if item != nil {
+		expiresAt = item.ExpiresAt()
+		val, err := s.getValueWithDecompression(item)
+		if err != nil {
+			return 0, err
+		}
+		if length, chunked = decodeStringManifest(val); !chunked {
+			current, length = val, uint64(len(val))
+		}
+	}
+	if offset < 0 {
+		// #nosec G115 - length is bounded by the value size limit
+		offset = int64(length)
+	}
+	// #nosec G115 - offset is non-negative here
+	off := uint64(offset)
+	newLength := max(length, off+uint64(len(data)))
+	// #nosec G115 - newLength is bounded by offset and value sizes within int64
+	if err := s.CheckValueSize(int64(newLength)); err != nil {
+		return 0, err
+	}
+	if err := txn.Set(TypeOfKeyGet(key), []byte(KeyTypeString)); err != nil {
+		return 0, err
+	}
+	valueKey := []byte(s.stringKey(key))
+
+	if !chunked && newLength <= stringChunkSize {
+		value := make([]byte, newLength)
+		copy(value, current)
+		copy(value[off:], data)
+		return newLength, s.setValueWithExpiresAt(txn, valueKey, value, expiresAt)
+	}
+	if !chunked {
+		s.stringChunks.Store(true)
+		// 可能留有整体覆盖时并发写入的分段
+		if err := s.dropStringChunksTxn(txn, key); err != nil {
+			return 0, err
+		}
+		if err := writeStringChunks(txn, key, 0, current); err != nil {
+			return 0, err
+		}
+	}
+	if err := writeStringChunks(txn, key, off, data); err != nil {
+		return 0, err
+	}
+	// 清单总是重写：长度可能变化，读缓存与 WATCH 也依赖 STRING:<key> 的写入
+	return newLength, s.setValueWithExpiresAt(txn, valueKey, encodeStringManifest(newLength), expiresAt)
+}
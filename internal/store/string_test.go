@@ -1,8 +1,11 @@
 package store
 
 import (
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/dgraph-io/badger/v4"
 	"github.com/zeebo/assert"
 )
 
@@ -60,6 +63,13 @@ func TestPSETEX(t *testing.T) {
 	value, err := store.Get("key1")
 	assert.NoError(t, err)
 	assert.Equal(t, "value1", value)
+	// WithTTL 写入的过期时间为秒，TTL/PTTL 同样能读出剩余时间
+	pttl, err := store.PTTL("key1")
+	assert.NoError(t, err)
+	assert.True(t, pttl > 8000 && pttl <= 10000)
+	ttl, err := store.TTL("key1")
+	assert.NoError(t, err)
+	assert.True(t, ttl >= 9 && ttl <= 10)
 }
 
 // TestSetNX 测试 SETNX 命令
@@ -467,19 +477,19 @@ func TestBitCount(t *testing.T) {
 	assert.NoError(t, err)
 
 	// BITCOUNT 整个字符串
-	count, err := store.BitCount("key1", 0, -1)
+	count, err := store.BitCount("key1", BitRange{})
 	assert.NoError(t, err)
-	assert.Equal(t, 2, count)
+	assert.Equal(t, int64(2), count)
 
 	// BITCOUNT 指定范围
-	count, err = store.BitCount("key1", 0, 0)
+	count, err = store.BitCount("key1", BitRange{End: 0, HasEnd: true})
 	assert.NoError(t, err)
-	assert.Equal(t, 2, count)
+	assert.Equal(t, int64(2), count)
 
 	// 不存在的键
-	count, err = store.BitCount("nonexistent", 0, -1)
+	count, err = store.BitCount("nonexistent", BitRange{})
 	assert.NoError(t, err)
-	assert.Equal(t, 0, count)
+	assert.Equal(t, int64(0), count)
 }
 
 // TestBitOp 测试 BITOP 命令
@@ -540,19 +550,19 @@ func TestBitPos(t *testing.T) {
 	assert.NoError(t, err)
 
 	// BITPOS 查找第一个1
-	pos, err := store.BitPos("key1", 1, 0, -1)
+	pos, err := store.BitPos("key1", 1, BitRange{})
 	assert.NoError(t, err)
-	assert.Equal(t, 8, pos) // 第8位（第二个字节的第一位）
+	assert.Equal(t, int64(8), pos) // 第8位（第二个字节的第一位）
 
 	// BITPOS 查找第一个0
-	pos, err = store.BitPos("key1", 0, 0, -1)
+	pos, err = store.BitPos("key1", 0, BitRange{})
 	assert.NoError(t, err)
-	assert.Equal(t, 0, pos) // 第0位
+	assert.Equal(t, int64(0), pos) // 第0位
 
 	// 不存在的键
-	pos, err = store.BitPos("nonexistent", 1, 0, -1)
+	pos, err = store.BitPos("nonexistent", 1, BitRange{})
 	assert.NoError(t, err)
-	assert.Equal(t, -1, pos) // 未找到
+	assert.Equal(t, int64(-1), pos) // 未找到
 }
 
 // TestStringEdgeCases 测试边界情况
@@ -582,3 +592,222 @@ func TestStringEdgeCases(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "\x00\x01\x02\xFF", value)
 }
+
+func TestGetExGetDel(t *testing.T) {
+	store := setupStringTest(t)
+	defer store.Close()
+
+	clock := NewManualClock(time.Now())
+	store.SetClock(clock)
+
+	// 不修改过期时间
+	assert.NoError(t, store.SetWithTTL("k", "v", 100*time.Second))
+	value, err := store.GetEx("k", time.Time{}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "v", value)
+	ttl, err := store.TTL("k")
+	assert.NoError(t, err)
+	assert.True(t, ttl > 0)
+
+	// 设置新的过期时间
+	value, err = store.GetEx("k", clock.Now().Add(50*time.Second), false)
+	assert.NoError(t, err)
+	assert.Equal(t, "v", value)
+	ttl, err = store.TTL("k")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(50), ttl)
+
+	// 移除过期时间
+	_, err = store.GetEx("k", time.Time{}, true)
+	assert.NoError(t, err)
+	ttl, err = store.TTL("k")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), ttl)
+
+	// 已经过去的时间点删除键，但仍返回值
+	value, err = store.GetEx("k", clock.Now().Add(-time.Second), false)
+	assert.NoError(t, err)
+	assert.Equal(t, "v", value)
+	_, err = store.Get("k")
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+	_, err = store.GetEx("k", time.Time{}, true)
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+
+	assert.NoError(t, store.Set("d", "v"))
+	value, err = store.GetDel("d")
+	assert.NoError(t, err)
+	assert.Equal(t, "v", value)
+	exists, err := store.Exists("d")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	_, err = store.GetDel("d")
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+}
+
+func TestSetWithOptions(t *testing.T) {
+	store := setupStringTest(t)
+	defer store.Close()
+
+	clock := NewManualClock(time.Now())
+	store.SetClock(clock)
+
+	result, err := store.SetWithOptions("k", "v1", SetOptions{NX: true, ExpiresAt: clock.Now().Add(time.Minute)})
+	assert.NoError(t, err)
+	assert.True(t, result.Written)
+	assert.False(t, result.OldExists)
+
+	result, err = store.SetWithOptions("k", "v2", SetOptions{NX: true, Get: true})
+	assert.NoError(t, err)
+	assert.False(t, result.Written)
+	assert.Equal(t, "v1", result.Old)
+
+	// KEEPTTL 保留原有的过期时间
+	result, err = store.SetWithOptions("k", "v3", SetOptions{XX: true, KeepTTL: true})
+	assert.NoError(t, err)
+	assert.True(t, result.Written)
+	ttl, err := store.TTL("k")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(60), ttl)
+
+	// 过期后按不存在处理
+	clock.Advance(2 * time.Minute)
+	result, err = store.SetWithOptions("k", "v4", SetOptions{XX: true})
+	assert.NoError(t, err)
+	assert.False(t, result.Written)
+
+	// 其他类型的键被整个替换
+	_, err = store.LPush("l", "a", "b")
+	assert.NoError(t, err)
+	_, err = store.SetWithOptions("l", "v", SetOptions{Get: true})
+	assert.True(t, errors.Is(err, ErrStringWrongType))
+	_, err = store.SetWithOptions("l", "v", SetOptions{})
+	assert.NoError(t, err)
+	value, err := store.Get("l")
+	assert.NoError(t, err)
+	assert.Equal(t, "v", value)
+	keyType, err := store.Type("l")
+	assert.NoError(t, err)
+	assert.Equal(t, "string", keyType)
+}
+
+// countStringChunks 返回 key 的分段个数
+func countStringChunks(t *testing.T, store *BotreonStore, key string) int {
+	n := 0
+	err := store.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = stringChunkPrefix(key)
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			n++
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	return n
+}
+
+// TestStringChunks 大字符串的 SETRANGE/APPEND 转为分段存储，只写入涉及的分段，读取结果与整体存储相同
+func TestStringChunks(t *testing.T) {
+	store := setupStringTest(t)
+	defer store.Close()
+
+	key := "blob"
+	assert.NoError(t, store.SetWithTTL(key, "head", time.Hour))
+	want := []byte("head")
+
+	// 远超分段大小的 offset：中间的分段不写入
+	offset := 5*stringChunkSize + 10
+	n, err := store.SetRange(key, offset, "tail")
+	assert.NoError(t, err)
+	want = append(want, make([]byte, offset-len(want))...)
+	want = append(want, "tail"...)
+	assert.Equal(t, len(want), n)
+	assert.Equal(t, 2, countStringChunks(t, store, key))
+
+	// 跨越分段边界的写入
+	n, err = store.SetRange(key, stringChunkSize-2, "edge")
+	assert.NoError(t, err)
+	assert.Equal(t, len(want), n)
+	copy(want[stringChunkSize-2:], "edge")
+	n, err = store.APPEND(key, "!")
+	assert.NoError(t, err)
+	want = append(want, '!')
+	assert.Equal(t, len(want), n)
+
+	length, err := store.StrLen(key)
+	assert.NoError(t, err)
+	assert.Equal(t, len(want), length)
+	part, err := store.GetRange(key, stringChunkSize-4, stringChunkSize+3)
+	assert.NoError(t, err)
+	assert.Equal(t, string(want[stringChunkSize-4:stringChunkSize+4]), part)
+	part, err = store.GetRange(key, -5, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, "tail!", part)
+	val, err := store.Get(key)
+	assert.NoError(t, err)
+	assert.Equal(t, string(want), val)
+
+	// 过期时间保持不变
+	ttl, err := store.TTL(key)
+	assert.NoError(t, err)
+	assert.True(t, ttl > 0)
+
+	// RENAME 移动分段，SET 覆盖时删除分段
+	assert.NoError(t, store.Rename(key, "blob2"))
+	assert.Equal(t, 0, countStringChunks(t, store, key))
+	val, err = store.Get("blob2")
+	assert.NoError(t, err)
+	assert.Equal(t, string(want), val)
+	assert.NoError(t, store.Set("blob2", "small"))
+	assert.Equal(t, 0, countStringChunks(t, store, "blob2"))
+	val, err = store.Get("blob2")
+	assert.NoError(t, err)
+	assert.Equal(t, "small", val)
+}
+
+// TestSetReplacesOtherType SET 覆盖其他类型的键时删除原有数据，DEL 后重建的键看不到旧数据
+func TestSetReplacesOtherType(t *testing.T) {
+	store := setupStringTest(t)
+	defer store.Close()
+
+	sets := map[string]func(key string) error{
+		"Set":        func(key string) error { return store.Set(key, "v") },
+		"SetWithTTL": func(key string) error { return store.SetWithTTL(key, "v", time.Hour) },
+		"MSet":       func(key string) error { return store.MSet(key, "v") },
+		"BatchSet": func(key string) error {
+			results, err := store.ApplyBatch([]BatchOp{{Kind: BatchSet, Key: key, Value: "v"}})
+			if err != nil {
+				return err
+			}
+			return results[0].Err
+		},
+	}
+	for name, set := range sets {
+		hash := name + ":h"
+		assert.NoError(t, store.HSet(hash, "a", "1"))
+		assert.NoError(t, store.HSet(hash, "b", "2"))
+		assert.NoError(t, set(hash))
+		v, err := store.Get(hash)
+		assert.NoError(t, err)
+		assert.Equal(t, "v", v)
+		_, err = store.Del(hash)
+		assert.NoError(t, err)
+		assert.NoError(t, store.HSet(hash, "c", "3"))
+		fields, err := store.HGetAll(hash)
+		assert.NoError(t, err)
+		assert.DeepEqual(t, map[string][]byte{"c": []byte("3")}, fields)
+
+		list := name + ":l"
+		_, err = store.RPush(list, "a", "b")
+		assert.NoError(t, err)
+		assert.NoError(t, set(list))
+		_, err = store.Del(list)
+		assert.NoError(t, err)
+		_, err = store.RPush(list, "c")
+		assert.NoError(t, err)
+		items, err := store.LRange(list, 0, -1)
+		assert.NoError(t, err)
+		assert.DeepEqual(t, []string{"c"}, items)
+	}
+}
@@ -0,0 +1,585 @@
+package store
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	// DefaultTierMinValueSize 默认只移动不小于 64KB 的值
+	DefaultTierMinValueSize = 64 << 10
+	// DefaultTierColdAfter 默认 7 天未访问的值视为冷数据
+	DefaultTierColdAfter = 7 * 24 * time.Hour
+
+	// tierMinValueSizeFloor MinValueSize 的下限，占位值远小于它，不会被再次移动
+	tierMinValueSizeFloor = 1024
+	// tierPromoteQueueMax 等待提升的值的上限，超出后读取仍从冷层返回，只是暂不提升
+	tierPromoteQueueMax = 4096
+	// tierIDLen 冷层键的长度：16 位十六进制的创建时间（纳秒）加 8 位随机数
+	tierIDLen = 24
+)
+
+var (
+	// tierStubMagic 主实例中占位值的前缀，之后是冷层键。压缩数据以 LZ4/ZSTD 魔数开头，不会与之混淆
+	tierStubMagic = []byte("\x00BTIER\x01")
+	tierStubLen   = len(tierStubMagic) + tierIDLen
+
+	// metaTierAccessKey 持久化访问时间的内部键，重启后继续按原来的访问时间判断冷热
+	metaTierAccessKey = []byte("META:tier_access")
+	tierValuePrefix   = []byte(KeyTypeString + ":")
+)
+
+// TieringOptions 冷热分层：长时间未访问的大字符串值移到另一个 Badger 实例（可以放在更便宜的存储上），
+// 主实例中只保留指向它的占位值。读取时透明地从冷层返回，并在下一轮分层周期中提升回主实例
+type TieringOptions struct {
+	Dir          string        // 冷层 Badger 目录，为空时不分层
+	MinValueSize int64         // 只移动存储大小不小于该字节数的值，零值时使用 DefaultTierMinValueSize
+	ColdAfter    time.Duration // 超过该时间未访问的值视为冷数据，零值时使用 DefaultTierColdAfter
+}
+
+// TierStats 冷热分层的统计
+type TierStats struct {
+	Enabled    bool
+	HotBytes   int64 // 主实例的磁盘占用（LSM + value log）
+	ColdBytes  int64 // 冷层的磁盘占用
+	ColdValues int64 // 最近一次完整扫描时位于冷层的值的个数
+	Tracked    int   // 记录了访问时间的大值个数
+	Demoted    int64 // 移到冷层的值（累计）
+	Promoted   int64 // 提升回主实例的值（累计）
+	ColdReads  int64 // 从冷层读取的次数（累计）
+	Pending    int   // 等待提升的值
+	Passes     int64 // 完成的完整扫描轮数
+	Swept      int64 // 清理的冷层孤立值（累计），如所在的键已被删除或覆盖
+}
+
+// tierState 冷热分层的状态，cold 为 nil 时不分层
+type tierState struct {
+	cold         *badger.DB
+	minValueSize int
+	coldAfter    time.Duration
+
+	// cycleMu 保证同一时间只有一轮分层周期
+	cycleMu sync.Mutex
+	cursor  []byte
+	// 冷层中的值只有在连续两轮完整扫描中都没有占位值引用时才清理：
+	// RENAME 等操作可能把占位值移到本轮已经扫描过的位置
+	seen     map[string]struct{}
+	prevSeen map[string]struct{}
+	passFrom time.Time
+	prevFrom time.Time
+
+	mu         sync.Mutex
+	access     map[string]int64  // Badger 键 -> 最近访问时间（Unix 秒），只记录大值
+	promote    map[string][]byte // Badger 键 -> 读取时的占位值
+	demoted    int64
+	promoted   int64
+	coldReads  int64
+	coldValues int64
+	passes     int64
+	swept      int64
+}
+
+// openTiering 打开冷层实例并加载持久化的访问时间
+func (s *BotreonStore) openTiering(opts TieringOptions) error {
+	if opts.Dir == "" {
+		return nil
+	}
+	coldOpts := badger.DefaultOptions(opts.Dir)
+	// 冷层以大值为主，值都写入 value log
+	coldOpts.ValueThreshold = 1024
+	coldOpts.Logger = nil
+	// 开启静态加密时冷层使用同一个主密钥
+	if s.encryption.key != nil {
+		coldOpts.EncryptionKey = s.encryption.key
+		coldOpts.EncryptionKeyRotationDuration = s.encryption.rotation
+		coldOpts.IndexCacheSize = 16 << 20
+	}
+	cold, err := badger.Open(coldOpts)
+	if err != nil {
+		return fmt.Errorf("open cold tier: %w", err)
+	}
+	t := &s.tier
+	t.cold = cold
+	t.minValueSize = int(max(opts.MinValueSize, tierMinValueSizeFloor))
+	if opts.MinValueSize == 0 {
+		t.minValueSize = DefaultTierMinValueSize
+	}
+	t.coldAfter = opts.ColdAfter
+	if t.coldAfter <= 0 {
+		t.coldAfter = DefaultTierColdAfter
+	}
+	t.access = make(map[string]int64)
+	t.promote = make(map[string][]byte)
+	if err := s.loadTierAccess(); err != nil {
+		_ = cold.Close()
+		t.cold = nil
+		return err
+	}
+	return nil
+}
+
+// closeTiering 持久化访问时间并关闭冷层
+func (s *BotreonStore) closeTiering() error {
+	t := &s.tier
+	if t.cold == nil {
+		return nil
+	}
+	saveErr := s.saveTierAccess()
+	if err := t.cold.Close(); err != nil {
+		return err
+	}
+	return saveErr
+}
+
+// TieringEnabled 是否开启了冷热分层
+func (s *BotreonStore) TieringEnabled() bool {
+	return s.tier.cold != nil
+}
+
+func isTierStub(raw []byte) bool {
+	return len(raw) == tierStubLen && bytes.HasPrefix(raw, tierStubMagic)
+}
+
+func newTierID(now time.Time) []byte {
+	var r [4]byte
+	_, _ = rand.Read(r[:])
+	// #nosec G115 - 当前时间的纳秒时间戳为正数
+	return []byte(fmt.Sprintf("%016x%s", uint64(now.UnixNano()), hex.EncodeToString(r[:])))
+}
+
+// tierIDTime 冷层键的创建时间
+func tierIDTime(id []byte) time.Time {
+	ns, err := strconv.ParseUint(string(id[:16]), 16, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	// #nosec G115 - 由 newTierID 写入
+	return time.Unix(0, int64(ns))
+}
+
+// recordTierAccess 记录大字符串值的访问（读取或写入）时间
+func (s *BotreonStore) recordTierAccess(key []byte, size int) {
+	t := &s.tier
+	if t.cold == nil || size < t.minValueSize || !bytes.HasPrefix(key, tierValuePrefix) {
+		return
+	}
+	now := s.now().Unix()
+	t.mu.Lock()
+	t.access[string(key)] = now
+	t.mu.Unlock()
+}
+
+// touchTierAccess 更新已记录的访问时间（读缓存命中时不知道值的大小）
+func (s *BotreonStore) touchTierAccess(key []byte) {
+	t := &s.tier
+	if t.cold == nil {
+		return
+	}
+	now := s.now().Unix()
+	t.mu.Lock()
+	if _, ok := t.access[string(key)]; ok {
+		t.access[string(key)] = now
+	}
+	t.mu.Unlock()
+}
+
+// readTierStub 读取占位值指向的冷层数据（存储格式，可能是压缩的），并登记在下一轮周期中提升
+func (s *BotreonStore) readTierStub(key, stub []byte) ([]byte, error) {
+	t := &s.tier
+	if t.cold == nil {
+		return nil, errors.New("value is in the cold tier but tiering is not enabled")
+	}
+	var raw []byte
+	err := t.cold.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(stub[len(tierStubMagic):])
+		if err != nil {
+			return err
+		}
+		raw, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read cold tier: %w", err)
+	}
+	t.mu.Lock()
+	t.coldReads++
+	if len(t.promote) < tierPromoteQueueMax {
+		t.promote[string(key)] = append([]byte(nil), stub...)
+	}
+	t.mu.Unlock()
+	return raw, nil
+}
+
+// TierCycle 执行一轮冷热分层：先把上次周期之后读取过的冷数据提升回主实例，
+// 再从上次的位置继续检查至多 limit 个字符串值，把超过 ColdAfter 未访问的大值移到冷层，返回移动的个数。
+// 扫描到末尾时一轮结束，清理冷层中不再被引用的值。未开启分层时不做任何事
+func (s *BotreonStore) TierCycle(limit int) (int, error) {
+	t := &s.tier
+	if t.cold == nil || limit <= 0 {
+		return 0, nil
+	}
+	t.cycleMu.Lock()
+	defer t.cycleMu.Unlock()
+
+	if err := s.promoteTierValues(); err != nil {
+		return 0, err
+	}
+
+	now := s.now()
+	if t.seen == nil {
+		t.seen = make(map[string]struct{})
+		t.passFrom = now
+	}
+	type candidate struct {
+		key     []byte
+		version uint64
+	}
+	var candidates []candidate
+	scanned := 0
+	var last []byte
+	err := s.view(func(txn *storeTxn) error {
+		iter := txn.NewIterator(badger.IteratorOptions{Prefix: tierValuePrefix})
+		defer iter.Close()
+		start := tierValuePrefix
+		if t.cursor != nil {
+			start = t.cursor
+		}
+		for iter.Seek(start); iter.ValidForPrefix(tierValuePrefix) && scanned < limit; iter.Next() {
+			item := iter.Item()
+			if t.cursor != nil && bytes.Equal(item.Key(), t.cursor) {
+				continue
+			}
+			scanned++
+			last = item.KeyCopy(nil)
+			size := int(item.ValueSize())
+			if size == tierStubLen {
+				raw, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				if isTierStub(raw) {
+					t.seen[string(raw[len(tierStubMagic):])] = struct{}{}
+				}
+				continue
+			}
+			if size < t.minValueSize {
+				continue
+			}
+			if exp := item.ExpiresAt(); exp > 0 && !expiresAtTime(exp).After(now) {
+				continue
+			}
+			if s.tierAccessedWithin(last, now) {
+				continue
+			}
+			candidates = append(candidates, candidate{key: last, version: item.Version()})
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	demoted := 0
+	for _, c := range candidates {
+		moved, err := s.demoteTierValue(c.key, c.version, now)
+		if err != nil {
+			return demoted, err
+		}
+		if moved {
+			demoted++
+		}
+	}
+
+	t.cursor = last
+	if scanned < limit {
+		t.cursor = nil
+		if err := s.finishTierPass(now); err != nil {
+			return demoted, err
+		}
+	}
+	return demoted, nil
+}
+
+// tierAccessedWithin 值是否在 ColdAfter 内访问过。第一次见到的值从现在开始计时
+func (s *BotreonStore) tierAccessedWithin(key []byte, now time.Time) bool {
+	t := &s.tier
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	last, ok := t.access[string(key)]
+	if !ok {
+		t.access[string(key)] = now.Unix()
+		return true
+	}
+	return now.Sub(time.Unix(last, 0)) < t.coldAfter
+}
+
+// demoteTierValue 把 key 的值写入冷层，并在同一主实例事务中换成占位值（保留过期时间）。
+// 值在扫描之后被修改（版本变化）或与并发写入冲突时放弃
+func (s *BotreonStore) demoteTierValue(key []byte, version uint64, now time.Time) (bool, error) {
+	t := &s.tier
+	id := newTierID(now)
+	written := false
+	err := s.update(func(txn *storeTxn) error {
+		item, err := txn.Get(key)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if item.Version() != version {
+			return nil
+		}
+		raw, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if err := t.cold.Update(func(ctxn *badger.Txn) error { return ctxn.Set(id, raw) }); err != nil {
+			return err
+		}
+		written = true
+		e := badger.NewEntry(key, append(append([]byte(nil), tierStubMagic...), id...))
+		e.ExpiresAt = item.ExpiresAt()
+		return txn.SetEntry(e)
+	})
+	if err != nil {
+		if written {
+			_ = t.cold.Update(func(ctxn *badger.Txn) error { return ctxn.Delete(id) })
+		}
+		if errors.Is(err, badger.ErrConflict) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !written {
+		return false, nil
+	}
+	t.mu.Lock()
+	t.seen[string(id)] = struct{}{}
+	delete(t.access, string(key))
+	t.demoted++
+	t.mu.Unlock()
+	return true, nil
+}
+
+// promoteTierValues 把读取过的冷数据写回主实例。占位值在读取之后被修改的键跳过
+func (s *BotreonStore) promoteTierValues() error {
+	t := &s.tier
+	t.mu.Lock()
+	pending := t.promote
+	t.promote = make(map[string][]byte)
+	t.mu.Unlock()
+
+	for key, stub := range pending {
+		id := stub[len(tierStubMagic):]
+		promoted := false
+		err := s.update(func(txn *storeTxn) error {
+			promoted = false
+			item, err := txn.Get([]byte(key))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			raw, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(raw, stub) {
+				return nil
+			}
+			var value []byte
+			err = t.cold.View(func(ctxn *badger.Txn) error {
+				coldItem, err := ctxn.Get(id)
+				if err != nil {
+					return err
+				}
+				value, err = coldItem.ValueCopy(nil)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			e := badger.NewEntry([]byte(key), value)
+			e.ExpiresAt = item.ExpiresAt()
+			if err := txn.SetEntry(e); err != nil {
+				return err
+			}
+			promoted = true
+			return nil
+		})
+		if errors.Is(err, badger.ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !promoted {
+			continue
+		}
+		if err := t.cold.Update(func(ctxn *badger.Txn) error { return ctxn.Delete(id) }); err != nil {
+			return err
+		}
+		t.mu.Lock()
+		t.promoted++
+		t.access[key] = s.now().Unix()
+		t.mu.Unlock()
+	}
+	return nil
+}
+
+// finishTierPass 一轮扫描结束：删除连续两轮都没有被引用、且在上一轮开始之前写入的冷层值
+func (s *BotreonStore) finishTierPass(now time.Time) error {
+	t := &s.tier
+	var stale [][]byte
+	if t.prevSeen != nil {
+		err := t.cold.View(func(txn *badger.Txn) error {
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			iter := txn.NewIterator(opts)
+			defer iter.Close()
+			for iter.Rewind(); iter.Valid(); iter.Next() {
+				id := iter.Item().Key()
+				if len(id) != tierIDLen || !tierIDTime(id).Before(t.prevFrom) {
+					continue
+				}
+				if _, ok := t.seen[string(id)]; ok {
+					continue
+				}
+				if _, ok := t.prevSeen[string(id)]; ok {
+					continue
+				}
+				stale = append(stale, append([]byte(nil), id...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(stale) > 0 {
+		wb := t.cold.NewWriteBatch()
+		for _, id := range stale {
+			if err := wb.Delete(id); err != nil {
+				wb.Cancel()
+				return err
+			}
+		}
+		if err := wb.Flush(); err != nil {
+			return err
+		}
+	}
+
+	t.mu.Lock()
+	t.coldValues = int64(len(t.seen))
+	t.passes++
+	t.swept += int64(len(stale))
+	t.mu.Unlock()
+	t.prevSeen, t.prevFrom = t.seen, t.passFrom
+	t.seen, t.passFrom = make(map[string]struct{}), now
+	return nil
+}
+
+// dropTier FLUSHDB 时清空冷层与访问记录
+func (s *BotreonStore) dropTier() error {
+	t := &s.tier
+	if t.cold == nil {
+		return nil
+	}
+	t.cycleMu.Lock()
+	defer t.cycleMu.Unlock()
+	if err := t.cold.DropAll(); err != nil {
+		return err
+	}
+	t.cursor, t.seen, t.prevSeen = nil, nil, nil
+	t.mu.Lock()
+	t.access = make(map[string]int64)
+	t.promote = make(map[string][]byte)
+	t.coldValues = 0
+	t.mu.Unlock()
+	return nil
+}
+
+// TierStats 返回冷热分层的统计，未开启时只有 Enabled 为 false
+func (s *BotreonStore) TierStats() TierStats {
+	t := &s.tier
+	if t.cold == nil {
+		return TierStats{}
+	}
+	hotLSM, hotVlog := s.db.Size()
+	coldLSM, coldVlog := t.cold.Size()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return TierStats{
+		Enabled:    true,
+		HotBytes:   hotLSM + hotVlog,
+		ColdBytes:  coldLSM + coldVlog,
+		ColdValues: t.coldValues,
+		Tracked:    len(t.access),
+		Demoted:    t.demoted,
+		Promoted:   t.promoted,
+		ColdReads:  t.coldReads,
+		Pending:    len(t.promote),
+		Passes:     t.passes,
+		Swept:      t.swept,
+	}
+}
+
+// saveTierAccess 持久化访问时间：依次为 uvarint 键长、键、varint 访问时间
+func (s *BotreonStore) saveTierAccess() error {
+	t := &s.tier
+	t.mu.Lock()
+	var buf []byte
+	for key, at := range t.access {
+		buf = binary.AppendUvarint(buf, uint64(len(key)))
+		buf = append(buf, key...)
+		buf = binary.AppendVarint(buf, at)
+	}
+	t.mu.Unlock()
+	return s.db.Update(func(txn *badger.Txn) error {
+		if len(buf) == 0 {
+			return txn.Delete(metaTierAccessKey)
+		}
+		return txn.Set(metaTierAccessKey, buf)
+	})
+}
+
+func (s *BotreonStore) loadTierAccess() error {
+	t := &s.tier
+	return s.view(func(txn *storeTxn) error {
+		item, err := txn.Get(metaTierAccessKey)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(buf []byte) error {
+			for len(buf) > 0 {
+				n, w := binary.Uvarint(buf)
+				if w <= 0 || uint64(len(buf)-w) < n {
+					return errors.New("corrupt tier access times")
+				}
+				key := string(buf[w : w+int(n)])
+				buf = buf[w+int(n):]
+				at, w := binary.Varint(buf)
+				if w <= 0 {
+					return errors.New("corrupt tier access times")
+				}
+				buf = buf[w:]
+				t.access[key] = at
+			}
+			return nil
+		})
+	})
+}
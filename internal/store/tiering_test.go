@@ -0,0 +1,137 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func newTieredStore(t *testing.T) (*BotreonStore, *ManualClock) {
+	t.Helper()
+	store, err := NewBotreonStoreWithOptions(t.TempDir(), StoreOptions{
+		Tiering: TieringOptions{Dir: t.TempDir(), MinValueSize: 1024, ColdAfter: time.Hour},
+	})
+	assert.NoError(t, err)
+	clock := NewManualClock(time.Now())
+	store.SetClock(clock)
+	return store, clock
+}
+
+// randomValue 不可压缩的值，压缩后仍超过 MinValueSize
+func randomValue(t *testing.T, n int) string {
+	b := make([]byte, n/2)
+	_, err := rand.Read(b)
+	assert.NoError(t, err)
+	return hex.EncodeToString(b)
+}
+
+func rawStringValue(t *testing.T, s *BotreonStore, key string) []byte {
+	var raw []byte
+	err := s.view(func(txn *storeTxn) error {
+		item, err := txn.Get([]byte(s.stringKey(key)))
+		if err != nil {
+			return err
+		}
+		raw, err = item.ValueCopy(nil)
+		return err
+	})
+	assert.NoError(t, err)
+	return raw
+}
+
+func TestTierDemoteAndPromote(t *testing.T) {
+	store, clock := newTieredStore(t)
+	defer store.Close()
+
+	blob := randomValue(t, 8192)
+	assert.NoError(t, store.Set("blob", blob))
+	assert.NoError(t, store.Set("small", "v"))
+	ok, err := store.Expire("blob", 86400)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// 刚写入的值不移动
+	n, err := store.TierCycle(100)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	clock.Advance(2 * time.Hour)
+	n, err = store.TierCycle(100)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.True(t, isTierStub(rawStringValue(t, store, "blob")))
+	assert.Equal(t, "v", string(rawStringValue(t, store, "small")))
+
+	// 过期时间保留在占位值上
+	ttl, err := store.TTL("blob")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(86400-7200), ttl)
+
+	// 读取透明地从冷层返回，并在下一轮周期中提升
+	store.readCache.clear()
+	got, err := store.Get("blob")
+	assert.NoError(t, err)
+	assert.Equal(t, blob, got)
+	length, err := store.StrLen("blob")
+	assert.NoError(t, err)
+	assert.Equal(t, len(blob), length)
+	stats := store.TierStats()
+	assert.Equal(t, int64(1), stats.Demoted)
+	assert.Equal(t, 1, stats.Pending)
+
+	_, err = store.TierCycle(100)
+	assert.NoError(t, err)
+	assert.False(t, isTierStub(rawStringValue(t, store, "blob")))
+	stats = store.TierStats()
+	assert.Equal(t, int64(1), stats.Promoted)
+	assert.Equal(t, 0, stats.Pending)
+	ttl, _ = store.TTL("blob")
+	assert.Equal(t, int64(86400-7200), ttl)
+
+	// 提升后重新计时
+	clock.Advance(30 * time.Minute)
+	n, _ = store.TierCycle(100)
+	assert.Equal(t, 0, n)
+}
+
+func TestTierRenameAndSweep(t *testing.T) {
+	store, clock := newTieredStore(t)
+	defer store.Close()
+
+	a, b := randomValue(t, 4096), randomValue(t, 4096)
+	assert.NoError(t, store.Set("a", a))
+	assert.NoError(t, store.Set("b", b))
+	clock.Advance(2 * time.Hour)
+	n, err := store.TierCycle(100)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	// RENAME 移动占位值，值仍可读取
+	assert.NoError(t, store.Rename("a", "renamed"))
+	got, err := store.Get("renamed")
+	assert.NoError(t, err)
+	assert.Equal(t, a, got)
+	dump, err := store.Dump("renamed")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Restore("copy", dump, 0, false))
+	got, err = store.Get("copy")
+	assert.NoError(t, err)
+	assert.Equal(t, a, got)
+
+	// 删除的键在冷层中的值在连续两轮扫描都未被引用后清理
+	_, err = store.Del("b")
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Minute)
+		_, err = store.TierCycle(100)
+		assert.NoError(t, err)
+	}
+	stats := store.TierStats()
+	assert.Equal(t, int64(1), stats.Swept)
+	got, err = store.Get("renamed")
+	assert.NoError(t, err)
+	assert.Equal(t, a, got)
+}
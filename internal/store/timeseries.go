@@ -120,7 +120,7 @@ func (s *BotreonStore) TSCreate(key string, opts TSCreateOptions) error {
 
 	// Set type
 	typeKey := TypeOfKeyGet(key)
-	err = s.db.Update(func(txn *badger.Txn) error {
+	err = s.update(func(txn *storeTxn) error {
 		if err := txn.Set(typeKey, []byte(KeyTypeTimeSeries)); err != nil {
 			return err
 		}
@@ -148,7 +148,7 @@ func (s *BotreonStore) TSCreate(key string, opts TSCreateOptions) error {
 func (s *BotreonStore) TSAdd(key string, timestamp int64, value float64, opts TSAddOptions) (int64, error) {
 	var addedTimestamp int64
 
-	err := s.db.Update(func(txn *badger.Txn) error {
+	err := s.update(func(txn *storeTxn) error {
 		// Set type key if not exists
 		typeKey := TypeOfKeyGet(key)
 		_, err := txn.Get(typeKey)
@@ -278,7 +278,7 @@ func (s *BotreonStore) TSAdd(key string, timestamp int64, value float64, opts TS
 func (s *BotreonStore) TSGet(key string) (*TimeSeriesDataPoint, error) {
 	var result *TimeSeriesDataPoint
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		// Get metadata
 		metaKey := tsMetaKey(key)
 		item, err := txn.Get(metaKey)
@@ -334,7 +334,7 @@ func (s *BotreonStore) TSGet(key string) (*TimeSeriesDataPoint, error) {
 func (s *BotreonStore) TSRange(key string, start, stop string, count int64) ([]TimeSeriesDataPoint, error) {
 	var result []TimeSeriesDataPoint
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		// Parse timestamps
 		startTS, err := parseTimestamp(start)
 		if err != nil {
@@ -398,7 +398,7 @@ func (s *BotreonStore) TSRange(key string, start, stop string, count int64) ([]T
 func (s *BotreonStore) TSDel(key string, start, stop string) (int64, error) {
 	var deleted int64
 
-	err := s.db.Update(func(txn *badger.Txn) error {
+	err := s.update(func(txn *storeTxn) error {
 		// Get metadata
 		metaKey := tsMetaKey(key)
 		item, err := txn.Get(metaKey)
@@ -502,7 +502,7 @@ func (s *BotreonStore) TSMGet(filter string, keys ...string) ([]*TimeSeriesDataP
 func (s *BotreonStore) TSInfo(key string) (*TimeSeriesInfo, error) {
 	var info *TimeSeriesInfo
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		metaKey := tsMetaKey(key)
 		item, err := txn.Get(metaKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -538,7 +538,7 @@ func (s *BotreonStore) TSInfo(key string) (*TimeSeriesInfo, error) {
 func (s *BotreonStore) TSLen(key string) (int64, error) {
 	var length int64
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		metaKey := tsMetaKey(key)
 		item, err := txn.Get(metaKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -566,7 +566,7 @@ func (s *BotreonStore) TSLen(key string) (int64, error) {
 // TimeSeriesType checks if a key is a time series
 func (s *BotreonStore) TimeSeriesType(key string) (bool, error) {
 	var exists bool
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.view(func(txn *storeTxn) error {
 		metaKey := tsMetaKey(key)
 		_, err := txn.Get(metaKey)
 		if errors.Is(err, badger.ErrKeyNotFound) {
@@ -0,0 +1,212 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/lbp0200/BoltDB/internal/logger"
+)
+
+// metaTrashPrefix 回收站条目的内部键前缀（不带 TYPE_ 前缀，不会出现在 KEYS/SCAN 中）
+// 值为 8 字节删除时间（Unix 毫秒）加上键的 DUMP 数据
+const metaTrashPrefix = "META:trash:"
+
+// trashRetention 回收站保留时间（纳秒），0 表示未开启
+type trashRetention struct {
+	nanos atomic.Int64
+}
+
+// SetTrashRetention 开启回收站：DEL、FLUSHDB 删除的键先移入回收站，保留 d 后自动清除。
+// d <= 0 关闭回收站，已在回收站中的键不受影响
+func (s *BotreonStore) SetTrashRetention(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	s.trash.nanos.Store(int64(d))
+}
+
+// TrashRetention 返回回收站保留时间，0 表示未开启
+func (s *BotreonStore) TrashRetention() time.Duration {
+	return time.Duration(s.trash.nanos.Load())
+}
+
+// DelToTrash 删除键；开启回收站时先将键的 DUMP 数据移入回收站。
+// 同名键再次删除时覆盖回收站中较早的版本。DUMP 不支持的类型直接删除
+func (s *BotreonStore) DelToTrash(key string) (int64, error) {
+	retention := s.TrashRetention()
+	if retention <= 0 {
+		return s.Del(key)
+	}
+	data, err := s.Dump(key)
+	if err != nil {
+		exists, existsErr := s.Exists(key)
+		if existsErr == nil && exists {
+			logger.Logger.Warn().Err(err).Str("key", key).Msg("键类型不支持移入回收站，直接删除")
+		}
+		return s.Del(key)
+	}
+
+	value := make([]byte, 8, 8+len(data))
+	// #nosec G115 - Unix 毫秒时间戳为正数
+	binary.BigEndian.PutUint64(value, uint64(s.now().UnixMilli()))
+	value = append(value, data...)
+	err = s.update(func(txn *storeTxn) error {
+		return txn.SetEntry(badger.NewEntry([]byte(metaTrashPrefix+key), value).WithTTL(retention))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return s.Del(key)
+}
+
+// FlushDBToTrash 清空数据库；开启回收站时逐个将键移入回收站，返回移入的键数。
+// 未开启回收站时等同于 FlushDB
+func (s *BotreonStore) FlushDBToTrash() (int, error) {
+	if s.TrashRetention() <= 0 {
+		return 0, s.FlushDB()
+	}
+	keys, err := s.Keys("*")
+	if err != nil {
+		return 0, err
+	}
+	moved := 0
+	for _, key := range keys {
+		deleted, err := s.DelToTrash(key)
+		if err != nil {
+			return moved, err
+		}
+		moved += int(deleted)
+	}
+	return moved, nil
+}
+
+// TrashEntry 回收站中的一个键
+type TrashEntry struct {
+	Key       string
+	DeletedAt time.Time
+	data      []byte
+}
+
+// TrashList 列出回收站中匹配 pattern 的键（不包括已超过保留时间的）
+func (s *BotreonStore) TrashList(pattern string) ([]TrashEntry, error) {
+	var entries []TrashEntry
+	err := s.view(func(txn *storeTxn) error {
+		var err error
+		entries, err = s.trashEntries(txn, pattern)
+		return err
+	})
+	return entries, err
+}
+
+func (s *BotreonStore) trashEntries(txn *storeTxn, pattern string) ([]TrashEntry, error) {
+	prefix := []byte(metaTrashPrefix)
+	retention := s.TrashRetention()
+	now := s.now()
+
+	var entries []TrashEntry
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+		key := string(item.Key()[len(prefix):])
+		if !matchPattern(key, pattern) {
+			continue
+		}
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(value) < 8 {
+			continue
+		}
+		// #nosec G115 - 写入时为正数的 Unix 毫秒时间戳
+		deletedAt := time.UnixMilli(int64(binary.BigEndian.Uint64(value[:8])))
+		// 保留时间按存储的时钟计算；Badger 的 TTL 只负责最终回收空间
+		if retention > 0 && !now.Before(deletedAt.Add(retention)) {
+			continue
+		}
+		entries = append(entries, TrashEntry{Key: key, DeletedAt: deletedAt, data: value[8:]})
+	}
+	return entries, nil
+}
+
+// Undelete 从回收站恢复匹配 pattern 的键，返回恢复的键数。
+// 目标键已存在时跳过（replace 为 true 时覆盖）；在回收站期间已过期的键直接丢弃
+func (s *BotreonStore) Undelete(pattern string, replace bool) (int, error) {
+	entries, err := s.TrashList(pattern)
+	if err != nil {
+		return 0, err
+	}
+	restored := 0
+	for _, e := range entries {
+		if expireAt, ok := dumpExpireAt(e.data); ok && expireAt <= s.now().UnixMilli() {
+			if err := s.purgeTrashKey(e.Key); err != nil {
+				return restored, err
+			}
+			continue
+		}
+		if err := s.Restore(e.Key, e.data, 0, replace); err != nil {
+			if strings.Contains(err.Error(), "target key already exists") {
+				continue
+			}
+			return restored, err
+		}
+		if err := s.purgeTrashKey(e.Key); err != nil {
+			return restored, err
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// PurgeTrash 永久删除回收站中匹配 pattern 的键，返回删除的键数
+func (s *BotreonStore) PurgeTrash(pattern string) (int, error) {
+	purged := 0
+	err := s.update(func(txn *storeTxn) error {
+		prefix := []byte(metaTrashPrefix)
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		var keys [][]byte
+		for it.Rewind(); it.Valid(); it.Next() {
+			if matchPattern(string(it.Item().Key()[len(prefix):]), pattern) {
+				keys = append(keys, it.Item().KeyCopy(nil))
+			}
+		}
+		it.Close()
+		for _, key := range keys {
+			if err := txn.Delete(key); err != nil {
+				return err
+			}
+		}
+		purged = len(keys)
+		return nil
+	})
+	return purged, err
+}
+
+func (s *BotreonStore) purgeTrashKey(key string) error {
+	return s.update(func(txn *storeTxn) error {
+		err := txn.Delete([]byte(metaTrashPrefix + key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+// dumpExpireAt 读取 DUMP 数据中的过期时间（Unix 毫秒）
+func dumpExpireAt(data []byte) (int64, bool) {
+	if len(data) < 9 || !bytes.HasPrefix(data, []byte("REDIS")) {
+		return 0, false
+	}
+	return readRDBExpireTime(bytes.NewBuffer(data[9:]))
+}
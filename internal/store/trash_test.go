@@ -0,0 +1,102 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestTrashDelAndUndelete(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	clock := NewManualClock(time.UnixMilli(1700000000000))
+	store.SetClock(clock)
+
+	// 未开启回收站时直接删除
+	assert.NoError(t, store.Set("plain", "v"))
+	deleted, err := store.DelToTrash("plain")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	restored, err := store.Undelete("*", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, restored)
+
+	store.SetTrashRetention(time.Hour)
+	assert.NoError(t, store.Set("s", "v"))
+	_, err = store.RPush("l", "a", "b")
+	assert.NoError(t, err)
+	assert.NoError(t, store.HSet("h", "f", "v"))
+
+	deleted, err = store.DelToTrash("s")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	deleted, err = store.DelToTrash("missing")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+
+	// FLUSHDB 把剩下的键也移入回收站
+	moved, err := store.FlushDBToTrash()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, moved)
+	keys, _ := store.Keys("*")
+	assert.Equal(t, 0, len(keys))
+
+	entries, err := store.TrashList("*")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(entries))
+
+	// 已存在的键不覆盖，REPLACE 时覆盖
+	assert.NoError(t, store.Set("s", "new"))
+	restored, err = store.Undelete("s", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, restored)
+	restored, err = store.Undelete("s", true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, restored)
+	val, _ := store.Get("s")
+	assert.Equal(t, "v", val)
+
+	restored, err = store.Undelete("*", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, restored)
+	list, _ := store.LRange("l", 0, -1)
+	assert.Equal(t, []string{"a", "b"}, list)
+	field, _ := store.HGet("h", "f")
+	assert.Equal(t, "v", string(field))
+
+	entries, err = store.TrashList("*")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+}
+
+func TestTrashRetentionAndPurge(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	clock := NewManualClock(time.UnixMilli(1700000000000))
+	store.SetClock(clock)
+	store.SetTrashRetention(time.Minute)
+
+	for _, key := range []string{"a:1", "a:2", "b:1"} {
+		assert.NoError(t, store.Set(key, "v"))
+		_, err := store.DelToTrash(key)
+		assert.NoError(t, err)
+	}
+
+	purged, err := store.PurgeTrash("a:*")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, purged)
+	entries, err := store.TrashList("*")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "b:1", entries[0].Key)
+
+	// 超过保留时间后不可恢复
+	clock.Advance(2 * time.Minute)
+	restored, err := store.Undelete("*", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, restored)
+
+}
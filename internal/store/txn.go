@@ -0,0 +1,170 @@
+package store
+
+import (
+	"github.com/dgraph-io/badger/v4"
+)
+
+// storeTxn 存储层的事务，包装 Badger 事务。写事务需要时（见 update）记录待提交的键，
+// 提交后据此统计写入量、累计数据大小的变化、递增 WATCH 版本、使读缓存失效并更新搜索索引。
+// 只读事务与不需要记录的写事务 pending 为 nil
+type storeTxn struct {
+	*badger.Txn
+	// pending 待提交（写入或删除）的 Badger 键，同一个键以最后一次写入为准
+	pending map[string]pendingWrite
+	// sizes 开启 maxmemory 时，事务在写入某个键之前读到的已提交大小（键加值，不存在为 0），
+	// 提交时据此减去旧版本的大小。为 nil 时不记录
+	sizes map[string]int64
+}
+
+// pendingWrite 待提交的写入
+type pendingWrite struct {
+	deleted bool
+	size    int64 // 写入的键加值的字节数
+}
+
+// Get 读取键，需要时记录已提交的大小
+func (t *storeTxn) Get(key []byte) (*badger.Item, error) {
+	item, err := t.Txn.Get(key)
+	if err == nil && t.sizes != nil {
+		t.observe(item)
+	}
+	return item, err
+}
+
+// NewIterator 创建迭代器，需要时记录迭代到的键的已提交大小
+func (t *storeTxn) NewIterator(opt badger.IteratorOptions) *storeIterator {
+	return &storeIterator{Iterator: t.Txn.NewIterator(opt), txn: t}
+}
+
+// observe 记录事务写入 item 的键之前读到的大小，同一个键只记录第一次读到的
+func (t *storeTxn) observe(item *badger.Item) {
+	key := item.Key()
+	if t.observed(key) {
+		return
+	}
+	t.sizes[string(key)] = int64(len(key)) + item.ValueSize()
+}
+
+// observeWrite 在写入之前记录键的旧大小。写入路径大多已经通过 Get 或迭代器读过要修改的键，
+// 没有读过的键（直接覆盖或删除）在本事务中读取一次
+func (t *storeTxn) observeWrite(key []byte) {
+	if t.sizes == nil || t.observed(key) {
+		return
+	}
+	var size int64
+	if item, err := t.Txn.Get(key); err == nil {
+		size = int64(len(key)) + item.ValueSize()
+	}
+	t.sizes[string(key)] = size
+}
+
+// observed 是否已记录键的旧大小或已写入过该键（之后读到的是本事务写入的值）
+func (t *storeTxn) observed(key []byte) bool {
+	if _, ok := t.sizes[string(key)]; ok {
+		return true
+	}
+	_, ok := t.pending[string(key)]
+	return ok
+}
+
+// Set 写入键值并记录，写入过期时间记录时同时写入过期索引的条目
+func (t *storeTxn) Set(key, val []byte) error {
+	t.observeWrite(key)
+	if err := t.Txn.Set(key, val); err != nil {
+		return err
+	}
+	t.record(key, pendingWrite{size: int64(len(key) + len(val))})
+	if index := expireIndexEntry(key, val, 0); index != nil {
+		return t.Set(index, nil)
+	}
+	return nil
+}
+
+// SetEntry 写入条目并记录，写入带过期时间的值时同时写入过期索引的条目
+func (t *storeTxn) SetEntry(e *badger.Entry) error {
+	t.observeWrite(e.Key)
+	if err := t.Txn.SetEntry(e); err != nil {
+		return err
+	}
+	t.record(e.Key, pendingWrite{size: int64(len(e.Key) + len(e.Value))})
+	if index := expireIndexEntry(e.Key, e.Value, e.ExpiresAt); index != nil {
+		return t.Set(index, nil)
+	}
+	return nil
+}
+
+// Delete 删除键并记录
+func (t *storeTxn) Delete(key []byte) error {
+	t.observeWrite(key)
+	if err := t.Txn.Delete(key); err != nil {
+		return err
+	}
+	t.record(key, pendingWrite{deleted: true})
+	return nil
+}
+
+func (t *storeTxn) record(key []byte, w pendingWrite) {
+	if t.pending != nil {
+		t.pending[string(key)] = w
+	}
+}
+
+// pendingKeys 待提交的 Badger 键，没有记录时为 nil
+func (t *storeTxn) pendingKeys() [][]byte {
+	if t.pending == nil {
+		return nil
+	}
+	keys := make([][]byte, 0, len(t.pending))
+	for k := range t.pending {
+		keys = append(keys, []byte(k))
+	}
+	return keys
+}
+
+// writeStats 待提交的写入量
+func (t *storeTxn) writeStats() WriteStats {
+	var stats WriteStats
+	for _, w := range t.pending {
+		if w.deleted {
+			stats.KeysDeleted++
+			continue
+		}
+		stats.KeysWritten++
+		stats.BytesWritten += w.size
+	}
+	return stats
+}
+
+// sizeDelta 提交后数据大小的变化：写入的键值大小减去旧版本的大小
+func (t *storeTxn) sizeDelta() int64 {
+	var delta int64
+	for k, w := range t.pending {
+		if !w.deleted {
+			delta += w.size
+		}
+		delta -= t.sizes[k]
+	}
+	return delta
+}
+
+// storeIterator 存储层事务的迭代器
+type storeIterator struct {
+	*badger.Iterator
+	txn *storeTxn
+}
+
+// Item 当前条目，需要时记录它的已提交大小
+func (it *storeIterator) Item() *badger.Item {
+	item := it.Iterator.Item()
+	if it.txn.sizes != nil {
+		it.txn.observe(item)
+	}
+	return item
+}
+
+// view 执行只读事务
+func (s *BotreonStore) view(fn func(txn *storeTxn) error) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		return fn(&storeTxn{Txn: txn})
+	})
+}
@@ -0,0 +1,390 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/lbp0200/BoltDB/internal/logger"
+)
+
+// metaUnlinkPrefix UNLINK 后等待后台回收的键（不带 TYPE_ 前缀，不会出现在 KEYS/SCAN 中），值为键的类型。
+// 条目的版本即 UNLINK 提交的版本，回收只删除不晚于它写入的 Badger 键；重启后继续回收
+const metaUnlinkPrefix = "META:unlink:"
+
+const (
+	// unlinkWorkers 后台回收的并发数
+	unlinkWorkers = 2
+	// unlinkQueueSize 等待回收的键的上限，队列满时 UNLINK 等待空位（背压）
+	unlinkQueueSize = 256
+	// unlinkBatch 每个回收事务检查的 Badger 键数，避免大键超出 Badger 的事务大小限制
+	unlinkBatch = 1000
+)
+
+// errUnlinkStopped 关闭存储时中断回收，未完成的键在下次启动时继续
+var errUnlinkStopped = errors.New("unlink reclamation stopped")
+
+// unlinkJob 一个等待回收数据的键。后台 worker 与访问该键的命令谁先执行 run 谁回收，其余等待
+type unlinkJob struct {
+	key  string
+	mu   sync.Mutex
+	done bool
+}
+
+// unlinkState UNLINK 的后台回收：jobs 为尚未回收完成的键，pending 为其数量
+type unlinkState struct {
+	mu      sync.Mutex
+	jobs    map[string]*unlinkJob
+	pending atomic.Int64
+	queue   chan *unlinkJob
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// startUnlinkWorkers 启动后台回收，并继续回收上次关闭前未完成的键
+func (s *BotreonStore) startUnlinkWorkers() error {
+	u := &s.unlink
+	u.jobs = make(map[string]*unlinkJob)
+	u.queue = make(chan *unlinkJob, unlinkQueueSize)
+	u.stop = make(chan struct{})
+
+	var keys []string
+	err := s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = []byte(metaUnlinkPrefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			keys = append(keys, string(it.Item().Key()[len(metaUnlinkPrefix):]))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < unlinkWorkers; i++ {
+		u.wg.Add(1)
+		go func() {
+			defer u.wg.Done()
+			for {
+				select {
+				case job := <-u.queue:
+					s.runUnlinkJob(job)
+				case <-u.stop:
+					return
+				}
+			}
+		}()
+	}
+	if len(keys) > 0 {
+		logger.Logger.Info().Int("keys", len(keys)).Msg("继续回收 UNLINK 的键")
+		jobs := make([]*unlinkJob, len(keys))
+		for i, key := range keys {
+			jobs[i] = s.registerUnlink(key)
+		}
+		// 超过队列长度时不能阻塞启动
+		u.wg.Add(1)
+		go func() {
+			defer u.wg.Done()
+			for _, job := range jobs {
+				if !s.enqueueUnlink(job) {
+					return
+				}
+			}
+		}()
+	}
+	return nil
+}
+
+// stopUnlinkWorkers 停止后台回收，正在回收的键在当前批次后中断
+func (s *BotreonStore) stopUnlinkWorkers() {
+	if s.unlink.stop == nil {
+		return
+	}
+	close(s.unlink.stop)
+	s.unlink.wg.Wait()
+}
+
+// registerUnlink 登记等待回收的键（已登记时返回原来的任务）
+func (s *BotreonStore) registerUnlink(key string) *unlinkJob {
+	u := &s.unlink
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if job, ok := u.jobs[key]; ok {
+		return job
+	}
+	job := &unlinkJob{key: key}
+	u.jobs[key] = job
+	u.pending.Add(1)
+	return job
+}
+
+// enqueueUnlink 把任务交给后台 worker，队列满时等待；存储关闭时返回 false
+func (s *BotreonStore) enqueueUnlink(job *unlinkJob) bool {
+	select {
+	case s.unlink.queue <- job:
+		return true
+	case <-s.unlink.stop:
+		return false
+	}
+}
+
+// runUnlinkJob 回收任务对应的键，已回收时直接返回
+func (s *BotreonStore) runUnlinkJob(job *unlinkJob) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	if job.done {
+		return
+	}
+	err := s.reclaimUnlinked(job.key)
+	if errors.Is(err, errUnlinkStopped) {
+		return
+	}
+	if err != nil {
+		// 条目仍然保留，下次启动时重试
+		logger.Logger.Error().Err(err).Str("key", job.key).Msg("回收 UNLINK 的键失败")
+	}
+	job.done = true
+	u := &s.unlink
+	u.mu.Lock()
+	if u.jobs[job.key] == job {
+		delete(u.jobs, job.key)
+		u.pending.Add(-1)
+	}
+	u.mu.Unlock()
+}
+
+// UnlinkPending 等待后台回收的键数
+func (s *BotreonStore) UnlinkPending() int64 {
+	return s.unlink.pending.Load()
+}
+
+// AwaitUnlink 等待键的后台回收完成（尚未开始时由调用者回收）。回收完成之前键名不能重新使用，
+// 否则新键会看到尚未删除的旧数据；命令执行前对其访问的键调用
+func (s *BotreonStore) AwaitUnlink(keys ...string) {
+	u := &s.unlink
+	if u.pending.Load() == 0 {
+		return
+	}
+	for _, key := range keys {
+		u.mu.Lock()
+		job := u.jobs[key]
+		u.mu.Unlock()
+		if job != nil {
+			s.runUnlinkJob(job)
+		}
+	}
+}
+
+// Unlink 删除键并立即返回，返回删除的数量：同步删除类型键使键立即不可见，成员、字段、记录等数据由后台回收。
+// 单个值的类型（字符串、JSON、HyperLogLog）直接删除；开启回收站时与 DEL 相同
+func (s *BotreonStore) Unlink(key string) (int64, error) {
+	if s.TrashRetention() > 0 {
+		return s.DelToTrash(key)
+	}
+	return s.unlinkKey(key, true)
+}
+
+// unlinkKey 同步删除类型键使键立即不可见，其余数据分批回收：background 为 true 时交给后台 worker，
+// 单个值的类型直接删除；否则在当前调用中回收，用于数据超过单个事务大小上限的 DEL
+func (s *BotreonStore) unlinkKey(key string, background bool) (int64, error) {
+	return s.unlinkKeyIf(key, background, nil)
+}
+
+// unlinkKeyIf 与 unlinkKey 相同，cond 不为 nil 时只在它在同一事务中返回 true 时删除，
+// 用于过期删除超过单个事务大小上限的键：确认键仍已过期，不会删除刚被重新写入的键
+func (s *BotreonStore) unlinkKeyIf(key string, background bool, cond func(txn *storeTxn, keyType string) (bool, error)) (int64, error) {
+	// 同一个键上一次 UNLINK 的回收必须先完成，条目只记录一个版本
+	s.AwaitUnlink(key)
+
+	// 提交之前登记并持有任务，同时访问该键的命令等待提交后再回收
+	job := s.registerUnlink(key)
+	job.mu.Lock()
+	var deleted int64
+	queued := false
+	err := s.retryUpdate(func(txn *storeTxn) error {
+		deleted, queued = 0, false
+		typeKey := TypeOfKeyGet(key)
+		item, err := txn.Get(typeKey)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		keyType, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if cond != nil {
+			if ok, err := cond(txn, string(keyType)); err != nil || !ok {
+				return err
+			}
+		}
+		deleted = 1
+		switch string(keyType) {
+		case KeyTypeString, KeyTypeJSON, keyTypeHyperLogLog:
+			if background {
+				_, err := s.delTxn(txn, key)
+				return err
+			}
+		}
+		queued = true
+		return markUnlinkedTxn(txn, key, keyType)
+	}, 30)
+	if queued && err == nil && !background {
+		// 没有交给 worker，持有任务期间访问该键的命令等待回收完成
+		job.mu.Unlock()
+		s.runUnlinkJob(job)
+	} else {
+		s.finishUnlink(job, queued && err == nil)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if deleted == 1 {
+		s.notifyZWatch(key, nil, nil, true)
+	}
+	return deleted, nil
+}
+
+// markUnlinkedTxn 在 txn 中删除键的类型键与过期时间记录使键立即不可见，并写入等待回收的条目。
+// 调用方在事务之前登记并持有任务（registerUnlink），提交后调用 finishUnlink
+func markUnlinkedTxn(txn *storeTxn, key string, keyType []byte) error {
+	if err := txn.Delete(TypeOfKeyGet(key)); err != nil {
+		return err
+	}
+	// 过期时间记录同步删除，不会作用到回收完成前重新创建的同名键
+	if err := txn.Delete(expireKeyGet(key)); err != nil {
+		return err
+	}
+	return txn.Set([]byte(metaUnlinkPrefix+key), keyType)
+}
+
+// finishUnlink 释放事务之前持有的任务：写入了回收条目时交给后台回收，否则取消登记
+func (s *BotreonStore) finishUnlink(job *unlinkJob, queued bool) {
+	if !queued {
+		job.done = true
+		s.unlink.mu.Lock()
+		delete(s.unlink.jobs, job.key)
+		s.unlink.pending.Add(-1)
+		s.unlink.mu.Unlock()
+	}
+	job.mu.Unlock()
+	if queued {
+		s.enqueueUnlink(job)
+	}
+}
+
+// reclaimUnlinked 按 keyLayouts 分批删除 UNLINK 的键留下的数据，完成后删除条目。
+// 只删除版本不晚于 UNLINK 的 Badger 键：之后写入的属于同名的新键
+func (s *BotreonStore) reclaimUnlinked(key string) error {
+	marker := []byte(metaUnlinkPrefix + key)
+	var keyType []byte
+	var version uint64
+	err := s.view(func(txn *storeTxn) error {
+		item, err := txn.Get(marker)
+		if err != nil {
+			return err
+		}
+		version = item.Version()
+		keyType, err = item.ValueCopy(nil)
+		return err
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	parts := keyLayouts[string(keyType)]
+	err = s.retryUpdate(func(txn *storeTxn) error {
+		for _, part := range parts {
+			if part.Exact == nil || part.Retain {
+				continue
+			}
+			item, err := txn.Get(part.Exact(key))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if item.Version() <= version {
+				if err := txn.Delete(part.Exact(key)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}, 30)
+	if err != nil {
+		return err
+	}
+
+	owners := make(map[string]bool)
+	for _, part := range parts {
+		if part.Prefix == nil || part.Retain {
+			continue
+		}
+		seek := part.Prefix(key)
+		for seek != nil {
+			select {
+			case <-s.unlink.stop:
+				return errUnlinkStopped
+			default:
+			}
+			var next []byte
+			err := s.retryUpdate(func(txn *storeTxn) error {
+				next = nil
+				opts := badger.DefaultIteratorOptions
+				opts.PrefetchValues = false
+				opts.Prefix = part.Prefix(key)
+				it := txn.NewIterator(opts)
+				var keys [][]byte
+				n := 0
+				for it.Seek(seek); it.Valid(); it.Next() {
+					if n == unlinkBatch {
+						next = it.Item().KeyCopy(nil)
+						break
+					}
+					n++
+					item := it.Item()
+					if item.Version() > version || ownedByLongerKey(txn, part, key, keyType, item.Key(), owners) {
+						continue
+					}
+					keys = append(keys, item.KeyCopy(nil))
+				}
+				it.Close()
+				for _, k := range keys {
+					if err := txn.Delete(k); err != nil {
+						return err
+					}
+				}
+				return nil
+			}, 30)
+			if err != nil {
+				return err
+			}
+			seek = next
+		}
+	}
+
+	return s.retryUpdate(func(txn *storeTxn) error {
+		item, err := txn.Get(marker)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if item.Version() != version {
+			return nil
+		}
+		return txn.Delete(marker)
+	}, 30)
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/zeebo/assert"
+)
+
+func TestUnlink(t *testing.T) {
+	store, err := NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+	before := allBadgerKeys(t, store)
+
+	for i := 0; i < 2500; i++ {
+		assert.NoError(t, store.HSet("big", fmt.Sprintf("f%04d", i), "v"))
+	}
+	assert.NoError(t, store.HSet("big:1", "f", "v"))
+	assert.NoError(t, store.Set("str", "v"))
+
+	n, err := store.Unlink("big")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	n, err = store.Unlink("missing")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	// 类型键已同步删除
+	keyType, err := store.Type("big")
+	assert.NoError(t, err)
+	assert.Equal(t, "none", keyType)
+
+	// 单个值的类型直接删除，不进入后台回收
+	n, err = store.Unlink("str")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	store.AwaitUnlink("big")
+	assert.Equal(t, int64(0), store.UnlinkPending())
+	_, err = store.Del("big:1")
+	assert.NoError(t, err)
+	assert.Equal(t, before, allBadgerKeys(t, store))
+}
+
+func TestUnlinkKeepsNewerWrites(t *testing.T) {
+	store, err := NewBotreonStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, store.HSet("h", fmt.Sprintf("old%d", i), "v"))
+	}
+	_, err = store.Unlink("h")
+	assert.NoError(t, err)
+	// 不经过 AwaitUnlink 直接写入同名键（命令执行前都应先等待回收）：回收只删除 UNLINK 之前写入的 Badger 键
+	assert.NoError(t, store.HSet("h", "new", "v"))
+	store.AwaitUnlink("h")
+	fields, err := store.HGetAll("h")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"new": []byte("v")}, fields)
+}
+
+func TestUnlinkResumesAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBotreonStore(dir)
+	assert.NoError(t, err)
+	_, err = store.RPush("list", "a", "b", "c")
+	assert.NoError(t, err)
+	before := len(allBadgerKeys(t, store))
+	// 模拟 UNLINK 之后、回收之前关闭
+	err = store.update(func(txn *storeTxn) error {
+		if err := txn.Delete(TypeOfKeyGet("list")); err != nil {
+			return err
+		}
+		return txn.Set([]byte(metaUnlinkPrefix+"list"), []byte(KeyTypeList))
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, store.Close())
+
+	store, err = NewBotreonStore(dir)
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.True(t, len(allBadgerKeys(t, store)) >= before)
+	deadline := time.Now().Add(5 * time.Second)
+	for store.UnlinkPending() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("unlinked key not reclaimed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, []string{metaKeyExpireIndexed}, allBadgerKeys(t, store))
+}
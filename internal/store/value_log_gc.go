@@ -0,0 +1,31 @@
+package store
+
+import (
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// DefaultValueLogGCDiscardRatio value log 文件中可回收的数据达到这一比例时才重写该文件
+const DefaultValueLogGCDiscardRatio = 0.5
+
+// ValueLogGC 回收 Badger value log 中被覆盖或删除的数据，每次最多重写一个文件。
+// 返回 true 表示重写了一个文件，可以继续调用回收下一个；没有可回收的文件、
+// 另一次回收正在进行或数据库只在内存中时返回 false 且不报错
+func (s *BotreonStore) ValueLogGC(discardRatio float64) (bool, error) {
+	err := s.db.RunValueLogGC(discardRatio)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, badger.ErrNoRewrite), errors.Is(err, badger.ErrRejected), errors.Is(err, badger.ErrGCInMemoryMode):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// Flatten 把 LSM 树的所有表合并到同一层，回收被覆盖与删除的键占用的空间，用于 BOLTREON.COMPACT。
+// workers 为并发压缩的数量
+func (s *BotreonStore) Flatten(workers int) error {
+	return s.db.Flatten(workers)
+}
@@ -0,0 +1,35 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestValueLogGC(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	// 没有可回收的 value log 文件时不报错
+	assert.NoError(t, store.Set("k", "v"))
+	rewritten, err := store.ValueLogGC(DefaultValueLogGCDiscardRatio)
+	assert.NoError(t, err)
+	assert.False(t, rewritten)
+}
+
+func TestFlatten(t *testing.T) {
+	store, err := NewBadgerStore(t.TempDir())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, store.Set("k", "v"))
+	}
+	_, err = store.Del("k")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Flatten(2))
+	exists, err := store.Exists("k")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// metaHotKeysKey 持久化热点键列表的内部键（不带 TYPE_ 前缀，不会出现在 KEYS/SCAN 中）
+var metaHotKeysKey = []byte("META:hotkeys")
+
+// DefaultHotKeyLimit 默认持久化/预热的热点键数量上限
+const DefaultHotKeyLimit = 10000
+
+// WarmupOptions 启动预热配置
+type WarmupOptions struct {
+	Patterns   []string // 需要预热的键模式（支持 * 和 ? 通配符）
+	UseHotKeys bool     // 是否加载上次运行持久化的热点键列表
+	MaxKeys    int      // 最多预热的键数量，<=0 时使用 DefaultHotKeyLimit
+}
+
+// SaveHotKeys 将读缓存中最近访问的键持久化，供下次启动预热使用
+func (s *BotreonStore) SaveHotKeys(limit int) error {
+	if limit <= 0 {
+		limit = DefaultHotKeyLimit
+	}
+	// 最热的在前
+	keys := s.readCache.hotKeys(limit)
+	return s.update(func(txn *storeTxn) error {
+		if len(keys) == 0 {
+			return txn.Delete(metaHotKeysKey)
+		}
+		return txn.Set(metaHotKeysKey, []byte(strings.Join(keys, "\n")))
+	})
+}
+
+// LoadHotKeys 读取上次持久化的热点键列表（最热的在前）
+func (s *BotreonStore) LoadHotKeys() ([]string, error) {
+	var keys []string
+	err := s.view(func(txn *storeTxn) error {
+		item, err := txn.Get(metaHotKeysKey)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		for _, k := range strings.Split(string(val), "\n") {
+			if k != "" {
+				keys = append(keys, k)
+			}
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// Warmup 在监听端口打开之前预读热点键，填充读缓存和 Badger 块缓存，
+// 减少部署后冷启动的延迟尖刺。返回实际预热的键数量。
+func (s *BotreonStore) Warmup(opts WarmupOptions) (int, error) {
+	maxKeys := opts.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = DefaultHotKeyLimit
+	}
+
+	seen := make(map[string]struct{})
+	var candidates []string
+	add := func(key string) bool {
+		if len(candidates) >= maxKeys {
+			return false
+		}
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			candidates = append(candidates, key)
+		}
+		return true
+	}
+
+	if opts.UseHotKeys {
+		hotKeys, err := s.LoadHotKeys()
+		if err != nil {
+			return 0, err
+		}
+		for _, k := range hotKeys {
+			if !add(k) {
+				break
+			}
+		}
+	}
+
+	// 模式只匹配 0 号数据库的逻辑键，跳过其他数据库与已过期但尚未被删除的键
+	now := s.now()
+	for _, pattern := range opts.Patterns {
+		if len(candidates) >= maxKeys {
+			break
+		}
+		err := s.view(func(txn *storeTxn) error {
+			it := s.newLogicalKeyIter(txn, 0, pattern)
+			defer it.close()
+			for it.rewind(); it.valid(); it.next() {
+				if !it.matches() {
+					continue
+				}
+				expired, err := s.logicalKeyExpired(txn, it, now)
+				if err != nil {
+					return err
+				}
+				if !expired && !add(it.key()) {
+					return nil
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	warmed := 0
+	for _, key := range candidates {
+		ok, err := s.warmupKey(key)
+		if err != nil {
+			return warmed, err
+		}
+		if ok {
+			warmed++
+		}
+	}
+	return warmed, nil
+}
+
+// warmupKey 预读单个键的数据，键不存在时返回 false。按键的布局（见 walkKeyLayout）读取组成它的
+// 每个 Badger 键的值，使对应的数据块进入 Badger 块缓存；字符串再通过 Get 填充读缓存
+func (s *BotreonStore) warmupKey(key string) (bool, error) {
+	var keyType []byte
+	err := s.view(func(txn *storeTxn) error {
+		var err error
+		keyType, err = walkKeyLayoutUntil(txn, key, func(item *badger.Item, _ keyLayoutPart) error {
+			return item.Value(func([]byte) error { return nil })
+		})
+		return err
+	})
+	if err != nil || keyType == nil {
+		return false, err
+	}
+	if string(keyType) == KeyTypeString {
+		if _, err := s.Get(key); err != nil && !errors.Is(err, ErrKeyNotFound) {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,188 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// InternalWriteSource 不属于任何命令的写入（过期清理、定时命令调度等后台任务）的统计名
+const InternalWriteSource = "(internal)"
+
+// WriteStats 写放大统计：命令执行次数，以及写入、删除的 Badger 键数和写入的字节数（键加值）。
+// FLUSHDB 等直接调用 DropAll/DropPrefix 的操作不经过事务，不计入
+type WriteStats struct {
+	Calls        int64
+	KeysWritten  int64
+	KeysDeleted  int64
+	BytesWritten int64
+}
+
+func (w *WriteStats) add(o WriteStats) {
+	w.KeysWritten += o.KeysWritten
+	w.KeysDeleted += o.KeysDeleted
+	w.BytesWritten += o.BytesWritten
+}
+
+// CommandWriteStats 单个命令的写放大统计
+type CommandWriteStats struct {
+	Command string
+	WriteStats
+}
+
+// writeStatsTracker 按命令汇总写入量
+type writeStatsTracker struct {
+	enabled   atomic.Bool
+	mu        sync.Mutex
+	byCommand map[string]*WriteStats
+}
+
+// commandWrites 一个命令已提交的写入。命令结束后（finish）提交的写入，
+// 如命令启动的后台回收，不再计入该命令
+type commandWrites struct {
+	mu    sync.Mutex
+	stats WriteStats
+	done  bool
+}
+
+// add 累加写入，命令已结束时返回 false
+func (w *commandWrites) add(stats WriteStats) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.done {
+		return false
+	}
+	w.stats.add(stats)
+	return true
+}
+
+func (w *commandWrites) finish() WriteStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.done = true
+	return w.stats
+}
+
+// EnableWriteStats 开启或关闭写放大统计（关闭时不影响写入性能），已有统计保留
+func (s *BotreonStore) EnableWriteStats(on bool) {
+	s.writeStats.enabled.Store(on)
+}
+
+// WriteStatsEnabled 是否正在统计写放大
+func (s *BotreonStore) WriteStatsEnabled() bool {
+	return s.writeStats.enabled.Load()
+}
+
+// TrackWrites 返回把写入计入 command 的存储句柄，命令应通过该句柄执行，返回的函数在命令结束时调用。
+// 未开启统计时返回 s 本身
+func (s *BotreonStore) TrackWrites(command string) (*BotreonStore, func()) {
+	if !s.writeStats.enabled.Load() {
+		return s, func() {}
+	}
+	writes := &commandWrites{}
+	return &BotreonStore{storeCore: s.storeCore, writes: writes}, func() {
+		s.recordWrites(command, writes.finish(), true)
+	}
+}
+
+// WriteStatsByCommand 按写入字节数从多到少返回各命令的统计
+func (s *BotreonStore) WriteStatsByCommand() []CommandWriteStats {
+	s.writeStats.mu.Lock()
+	list := make([]CommandWriteStats, 0, len(s.writeStats.byCommand))
+	for command, stats := range s.writeStats.byCommand {
+		list = append(list, CommandWriteStats{Command: command, WriteStats: *stats})
+	}
+	s.writeStats.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].BytesWritten != list[j].BytesWritten {
+			return list[i].BytesWritten > list[j].BytesWritten
+		}
+		return list[i].Command < list[j].Command
+	})
+	return list
+}
+
+// ResetWriteStats 清空写放大统计
+func (s *BotreonStore) ResetWriteStats() {
+	s.writeStats.mu.Lock()
+	s.writeStats.byCommand = nil
+	s.writeStats.mu.Unlock()
+}
+
+func (s *BotreonStore) recordWrites(command string, stats WriteStats, call bool) {
+	s.writeStats.mu.Lock()
+	defer s.writeStats.mu.Unlock()
+	if s.writeStats.byCommand == nil {
+		s.writeStats.byCommand = make(map[string]*WriteStats)
+	}
+	total, ok := s.writeStats.byCommand[command]
+	if !ok {
+		total = &WriteStats{}
+		s.writeStats.byCommand[command] = total
+	}
+	total.add(stats)
+	if call {
+		total.Calls++
+	}
+}
+
+// update 执行写事务，开启统计时把提交成功的写入计入当前命令，有连接 WATCH 时递增所写键的版本，
+// 开启读缓存时使所写的 Badger 键失效，开启 maxmemory 时累计数据大小的变化，
+// 定义了搜索索引时更新所写文档的索引。存储层的写事务都应通过这里提交
+func (s *BotreonStore) update(fn func(txn *storeTxn) error) error {
+	searching := s.search.active.Load()
+	tracking := s.writeStats.enabled.Load()
+	watched := s.versions.watchers.Load() > 0
+	caching := s.readCache.enabled.Load()
+	sizing := s.evict.sizing.Load()
+	if !searching && !tracking && !watched && !caching && !sizing {
+		err := s.db.Update(func(txn *badger.Txn) error {
+			return fn(&storeTxn{Txn: txn})
+		})
+		if err == nil {
+			s.committed(false, nil)
+			s.readCache.committed(false, nil)
+		}
+		return err
+	}
+	var stats WriteStats
+	var written [][]byte
+	var delta int64
+	err := s.db.Update(func(txn *badger.Txn) error {
+		t := &storeTxn{Txn: txn, pending: make(map[string]pendingWrite)}
+		if sizing {
+			t.sizes = make(map[string]int64)
+		}
+		if err := fn(t); err != nil {
+			return err
+		}
+		if searching {
+			if err := s.indexPendingTxn(t); err != nil {
+				return err
+			}
+		}
+		if tracking {
+			stats = t.writeStats()
+		}
+		written = t.pendingKeys()
+		if sizing {
+			delta = t.sizeDelta()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.committed(watched, written)
+	s.readCache.committed(caching, written)
+	s.evict.delta.Add(delta)
+	if stats == (WriteStats{}) {
+		return nil
+	}
+	if s.writes == nil || !s.writes.add(stats) {
+		s.recordWrites(InternalWriteSource, stats, false)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+func TestWriteStats(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewBadgerStore(dir)
+	assert.NoError(t, err)
+
+	// 未开启时不统计
+	cmd, done := store.TrackWrites("SET")
+	assert.NoError(t, cmd.Set("a", "1"))
+	done()
+	assert.Equal(t, 0, len(store.WriteStatsByCommand()))
+
+	store.EnableWriteStats(true)
+	assert.True(t, store.WriteStatsEnabled())
+
+	// 事务中的写入与删除按 Badger 键计数
+	cmd, done = store.TrackWrites("RAW")
+	assert.NoError(t, cmd.update(func(txn *storeTxn) error {
+		if err := txn.Set([]byte("k1"), []byte("vvv")); err != nil {
+			return err
+		}
+		if err := txn.Set([]byte("k2"), []byte("v")); err != nil {
+			return err
+		}
+		return txn.Delete([]byte("k3"))
+	}))
+	done()
+	stats := store.WriteStatsByCommand()
+	assert.Equal(t, 1, len(stats))
+	assert.Equal(t, CommandWriteStats{Command: "RAW", WriteStats: WriteStats{Calls: 1, KeysWritten: 2, KeysDeleted: 1, BytesWritten: 8}}, stats[0])
+
+	for i := 0; i < 2; i++ {
+		cmd, done = store.TrackWrites("SET")
+		assert.NoError(t, cmd.Set("a", "1"))
+		done()
+	}
+	// 不通过命令句柄的写入与命令结束后通过句柄提交的写入归入 (internal)
+	assert.NoError(t, store.Set("b", "1"))
+	assert.NoError(t, cmd.Set("b", "2"))
+
+	byCommand := make(map[string]WriteStats)
+	for _, s := range store.WriteStatsByCommand() {
+		byCommand[s.Command] = s.WriteStats
+	}
+	assert.Equal(t, int64(2), byCommand["SET"].Calls)
+	assert.Equal(t, int64(4), byCommand["SET"].KeysWritten)
+	assert.Equal(t, int64(0), byCommand[InternalWriteSource].Calls)
+	assert.Equal(t, int64(4), byCommand[InternalWriteSource].KeysWritten)
+
+	store.ResetWriteStats()
+	assert.Equal(t, 0, len(store.WriteStatsByCommand()))
+}
@@ -0,0 +1,300 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/lbp0200/BoltDB/internal/logger"
+)
+
+// metaZWatchPrefix 排行榜通知配置的内部键前缀（不带 TYPE_ 前缀，不会出现在 KEYS/SCAN 中）
+const metaZWatchPrefix = "META:zwatch:"
+
+// ZWatchConfig 有序集合前 N 名变化通知的配置
+type ZWatchConfig struct {
+	TopN    int    // 关注的名次范围（前 N 名）
+	Channel string // 发布事件的频道
+	Rev     bool   // true 时按分数从高到低排名（ZREVRANK），否则从低到高（ZRANK）
+}
+
+// ZRankEvent 成员进入、离开前 N 名或在前 N 名内名次变化时发布的事件
+// 名次为 -1 表示在前 N 名之外；不在前 N 名内的旧分数未知，此时 OldScore 为 nil
+type ZRankEvent struct {
+	Key      string   `json:"key"`
+	Member   string   `json:"member"`
+	Event    string   `json:"event"` // enter、leave、move
+	OldRank  int64    `json:"old_rank"`
+	NewRank  int64    `json:"new_rank"`
+	OldScore *float64 `json:"old_score"`
+	NewScore *float64 `json:"new_score"`
+}
+
+// zsetWatch 一个被关注的有序集合，top 缓存当前的前 N 名
+type zsetWatch struct {
+	mu     sync.Mutex
+	config ZWatchConfig
+	top    []ZSetMember // nil 表示尚未加载
+}
+
+// zsetWatchers 所有被关注的有序集合
+type zsetWatchers struct {
+	mu      sync.RWMutex
+	watches map[string]*zsetWatch
+	publish func(channel string, message []byte)
+}
+
+// SetZWatchPublisher 设置排行榜事件的发布函数（通常为 PubSubManager.Publish）
+func (s *BotreonStore) SetZWatchPublisher(fn func(channel string, message []byte)) {
+	s.zwatch.mu.Lock()
+	s.zwatch.publish = fn
+	s.zwatch.mu.Unlock()
+}
+
+// ZWatch 开启有序集合前 N 名变化通知，配置会持久化，重启后继续生效
+func (s *BotreonStore) ZWatch(key string, config ZWatchConfig) error {
+	if config.TopN <= 0 {
+		return errors.New("top-N must be a positive integer")
+	}
+	if config.Channel == "" {
+		return errors.New("channel must not be empty")
+	}
+	err := s.update(func(txn *storeTxn) error {
+		return txn.Set([]byte(metaZWatchPrefix+key), encodeZWatchConfig(config))
+	})
+	if err != nil {
+		return err
+	}
+	w := &zsetWatch{config: config}
+	top, err := s.zsetTopN(key, config.TopN, config.Rev)
+	if err != nil {
+		return err
+	}
+	w.top = top
+	s.zwatch.mu.Lock()
+	s.zwatch.watches[key] = w
+	s.zwatch.mu.Unlock()
+	return nil
+}
+
+// ZUnwatch 关闭有序集合的前 N 名变化通知，返回之前是否开启
+func (s *BotreonStore) ZUnwatch(key string) (bool, error) {
+	s.zwatch.mu.Lock()
+	_, existed := s.zwatch.watches[key]
+	delete(s.zwatch.watches, key)
+	s.zwatch.mu.Unlock()
+	if !existed {
+		return false, nil
+	}
+	return true, s.update(func(txn *storeTxn) error {
+		return txn.Delete([]byte(metaZWatchPrefix + key))
+	})
+}
+
+// ZWatchConfigOf 返回有序集合的通知配置
+func (s *BotreonStore) ZWatchConfigOf(key string) (ZWatchConfig, bool) {
+	s.zwatch.mu.RLock()
+	defer s.zwatch.mu.RUnlock()
+	w, ok := s.zwatch.watches[key]
+	if !ok {
+		return ZWatchConfig{}, false
+	}
+	return w.config, true
+}
+
+// loadZWatches 启动时加载持久化的通知配置，前 N 名在第一次变更时再读取
+func (s *BotreonStore) loadZWatches() error {
+	prefix := []byte(metaZWatchPrefix)
+	return s.view(func(txn *storeTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			key := string(item.Key()[len(prefix):])
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			config, err := decodeZWatchConfig(val)
+			if err != nil {
+				logger.Logger.Warn().Err(err).Str("key", key).Msg("忽略无效的排行榜通知配置")
+				continue
+			}
+			s.zwatch.watches[key] = &zsetWatch{config: config}
+		}
+		return nil
+	})
+}
+
+func encodeZWatchConfig(c ZWatchConfig) []byte {
+	rev := "0"
+	if c.Rev {
+		rev = "1"
+	}
+	return []byte(strconv.Itoa(c.TopN) + "\n" + rev + "\n" + c.Channel)
+}
+
+func decodeZWatchConfig(b []byte) (ZWatchConfig, error) {
+	parts := strings.SplitN(string(b), "\n", 3)
+	if len(parts) != 3 {
+		return ZWatchConfig{}, fmt.Errorf("invalid zwatch config: %q", b)
+	}
+	topN, err := strconv.Atoi(parts[0])
+	if err != nil || topN <= 0 {
+		return ZWatchConfig{}, fmt.Errorf("invalid zwatch top-N: %q", parts[0])
+	}
+	return ZWatchConfig{TopN: topN, Rev: parts[1] == "1", Channel: parts[2]}, nil
+}
+
+// zsetTopN 只读取索引的前 n 项（rev 时从尾部反向读取），不需要遍历整个有序集合
+func (s *BotreonStore) zsetTopN(key string, n int, rev bool) ([]ZSetMember, error) {
+	prefix := keyBadgerGet(prefixKeySortedSetBytes, []byte(key+sortedSetIndex))
+	top := make([]ZSetMember, 0, n)
+	err := s.view(func(txn *storeTxn) error {
+		opts := s.iteratorOptions(prefix, int64(n), false)
+		opts.Reverse = rev
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		seek := prefix
+		if rev {
+			seek = append(append([]byte{}, prefix...), 0xFF)
+		}
+		for it.Seek(seek); it.ValidForPrefix(prefix) && len(top) < n; it.Next() {
+			if m, ok := parseSortedSetIndexKey(prefix, it.Item().Key()); ok {
+				top = append(top, m)
+			}
+		}
+		return nil
+	})
+	return top, err
+}
+
+// parseSortedSetIndexKey 解析索引键 <prefix><8 字节分数>:<member>:<4 字节版本>
+// 按固定长度切分，成员或分数中包含 ':' 时也能正确解析
+func parseSortedSetIndexKey(prefix, key []byte) (ZSetMember, bool) {
+	rest := key[len(prefix):]
+	if len(rest) < 8+1+1+4 || rest[8] != ':' || rest[len(rest)-5] != ':' {
+		return ZSetMember{}, false
+	}
+	return ZSetMember{
+		Member: string(rest[9 : len(rest)-5]),
+		Score:  decodeScore(rest[:8]),
+	}, true
+}
+
+// zsetRanksBefore 判断按排名方向 a 是否排在 b 之前
+func zsetRanksBefore(a, b ZSetMember, rev bool) bool {
+	if a.Score != b.Score {
+		return (a.Score < b.Score) != rev
+	}
+	return (a.Member < b.Member) != rev
+}
+
+// notifyZWatch 有序集合发生变更后检查前 N 名是否变化。
+// updated 为新增或修改分数的成员，removed 为删除的成员，all 表示整个集合被删除。
+// 与前 N 名无关的变更（成员不在前 N 名内且新分数排不进前 N 名）直接返回，不读取存储。
+func (s *BotreonStore) notifyZWatch(key string, updated []ZSetMember, removed []string, all bool) {
+	s.zwatch.mu.RLock()
+	w, ok := s.zwatch.watches[key]
+	publish := s.zwatch.publish
+	s.zwatch.mu.RUnlock()
+	if !ok || publish == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.top != nil && !w.affected(updated, removed, all) {
+		return
+	}
+
+	top, err := s.zsetTopN(key, w.config.TopN, w.config.Rev)
+	if err != nil {
+		logger.Logger.Warn().Err(err).Str("key", key).Msg("读取排行榜前 N 名失败")
+		return
+	}
+	old := w.top
+	w.top = top
+	if old == nil {
+		// 重启后第一次变更：只建立快照
+		return
+	}
+	for _, event := range s.diffZWatch(key, old, top) {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			continue
+		}
+		publish(w.config.Channel, payload)
+	}
+}
+
+// affected 判断变更是否可能影响前 N 名
+func (w *zsetWatch) affected(updated []ZSetMember, removed []string, all bool) bool {
+	if all {
+		return len(w.top) > 0
+	}
+	inTop := make(map[string]bool, len(w.top))
+	for _, m := range w.top {
+		inTop[m.Member] = true
+	}
+	for _, member := range removed {
+		if inTop[member] {
+			return true
+		}
+	}
+	for _, m := range updated {
+		if inTop[m.Member] || len(w.top) < w.config.TopN {
+			return true
+		}
+		if zsetRanksBefore(m, w.top[len(w.top)-1], w.config.Rev) {
+			return true
+		}
+	}
+	return false
+}
+
+// diffZWatch 比较新旧前 N 名，生成 enter/leave/move 事件
+func (s *BotreonStore) diffZWatch(key string, old, top []ZSetMember) []ZRankEvent {
+	oldRank := make(map[string]int, len(old))
+	for i, m := range old {
+		oldRank[m.Member] = i
+	}
+	newRank := make(map[string]int, len(top))
+	for i, m := range top {
+		newRank[m.Member] = i
+	}
+
+	var events []ZRankEvent
+	for i, m := range top {
+		newScore := m.Score
+		j, was := oldRank[m.Member]
+		switch {
+		case !was:
+			events = append(events, ZRankEvent{Key: key, Member: m.Member, Event: "enter",
+				OldRank: -1, NewRank: int64(i), NewScore: &newScore})
+		case i != j:
+			oldScore := old[j].Score
+			events = append(events, ZRankEvent{Key: key, Member: m.Member, Event: "move",
+				OldRank: int64(j), NewRank: int64(i), OldScore: &oldScore, NewScore: &newScore})
+		}
+	}
+	for j, m := range old {
+		if _, still := newRank[m.Member]; still {
+			continue
+		}
+		oldScore := m.Score
+		event := ZRankEvent{Key: key, Member: m.Member, Event: "leave",
+			OldRank: int64(j), NewRank: -1, OldScore: &oldScore}
+		if score, exists, err := s.ZScore(key, m.Member); err == nil && exists {
+			event.NewScore = &score
+		}
+		events = append(events, event)
+	}
+	return events
+}
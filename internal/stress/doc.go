@@ -0,0 +1,8 @@
+// Package stress 对阻塞命令、Stream、过期和 Pub/Sub 做并发压力测试，本包只有测试。
+//
+// 每个测试的调度（操作的选择、顺序和间隔）由种子决定，失败时日志中会打印种子，可以复现：
+//
+//	go test -race ./internal/stress -stress.seed=1700000000
+//
+// 种子为 0（默认）时取当前时间。-short 时缩小规模。
+package stress
@@ -0,0 +1,493 @@
+package stress
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/store"
+	"github.com/zeebo/assert"
+)
+
+var seedFlag = flag.Int64("stress.seed", 0, "seed for the randomized schedules; 0 picks one from the clock")
+
+// stressTimeout 单个测试的最长时间，超过时认为有等待者被遗漏（没有被唤醒）
+const stressTimeout = 30 * time.Second
+
+// stressSeed 返回本次测试使用的种子并记录在日志中
+func stressSeed(t *testing.T) int64 {
+	seed := *seedFlag
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	t.Logf("seed %d (rerun with -stress.seed=%d)", seed, seed)
+	return seed
+}
+
+// workerRand 每个协程独立的随机数，调度只取决于种子和协程编号
+func workerRand(seed int64, worker int) *rand.Rand {
+	return rand.New(rand.NewSource(seed + int64(worker)*7919)) // #nosec G404 - 测试调度不需要密码学随机数
+}
+
+// pause 随机让出或短暂休眠，打乱协程之间的交错
+func pause(r *rand.Rand) {
+	if r.Intn(4) == 0 {
+		time.Sleep(time.Duration(r.Intn(300)) * time.Microsecond)
+		return
+	}
+	runtime.Gosched()
+}
+
+// scale -short 时缩小规模
+func scale(n int) int {
+	if testing.Short() {
+		return max(n/4, 1)
+	}
+	return n
+}
+
+func openStore(t *testing.T) *store.BotreonStore {
+	s, err := store.NewBotreonStoreWithOptions(t.TempDir(), store.StoreOptions{})
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// wait 等待 wg，超过 stressTimeout 时失败
+func wait(t *testing.T, wg *sync.WaitGroup, what string) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(stressTimeout):
+		t.Fatalf("%s did not finish within %v", what, stressTimeout)
+	}
+}
+
+// assertUnblocked 所有阻塞的客户端都已返回并释放名额
+func assertUnblocked(t *testing.T, s *store.BotreonStore) {
+	assert.Equal(t, 0, s.BlockedClients())
+	assert.Equal(t, 0, s.BlockingKeys())
+}
+
+// streamIDLess 按 Stream ID 的数值顺序比较
+func streamIDLess(a, b string) bool {
+	parse := func(id string) (int64, int64) {
+		ts, seq, _ := strings.Cut(id, "-")
+		t, _ := strconv.ParseInt(ts, 10, 64)
+		n, _ := strconv.ParseInt(seq, 10, 64)
+		return t, n
+	}
+	at, as := parse(a)
+	bt, bs := parse(b)
+	return at < bt || (at == bt && as < bs)
+}
+
+// TestStreamBlockingReaders 多个写者并发 XADD，读者交替使用 BLOCK 0 和短超时的 XREAD BLOCK，
+// 每个读者都必须恰好看到每条记录一次
+func TestStreamBlockingReaders(t *testing.T) {
+	s := openStore(t)
+	seed := stressSeed(t)
+	writers, readers, perWriter := 4, 6, scale(60)
+	total := writers * perWriter
+
+	var wg sync.WaitGroup
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			rng := workerRand(seed, 100+r)
+			seen := make(map[string]bool, total)
+			last := "0-0"
+			for len(seen) < total {
+				var block int64 // 0：一直等待
+				if rng.Intn(3) == 0 {
+					block = int64(1 + rng.Intn(20))
+				}
+				result, err := s.XRead(0, block, "stream", last)
+				if err != nil {
+					t.Errorf("reader %d: %v", r, err)
+					return
+				}
+				for _, streams := range result {
+					for _, entry := range streams["stream"] {
+						if seen[entry.ID] {
+							t.Errorf("reader %d saw %s twice", r, entry.ID)
+							return
+						}
+						if !streamIDLess(last, entry.ID) {
+							t.Errorf("reader %d got %s at or before %s", r, entry.ID, last)
+							return
+						}
+						seen[entry.ID] = true
+					}
+				}
+				// 同一批内的记录不保证有序，下一次从最大的 ID 之后读
+				for id := range seen {
+					if streamIDLess(last, id) {
+						last = id
+					}
+				}
+				pause(rng)
+			}
+		}(r)
+	}
+
+	var writerWG sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		writerWG.Add(1)
+		go func(w int) {
+			defer writerWG.Done()
+			rng := workerRand(seed, w)
+			for i := 0; i < perWriter; i++ {
+				fields := map[string]string{"writer": strconv.Itoa(w), "n": strconv.Itoa(i)}
+				if _, err := s.XAdd("stream", store.StreamXAddOptions{}, "*", fields); err != nil {
+					t.Errorf("writer %d: %v", w, err)
+					return
+				}
+				pause(rng)
+			}
+		}(w)
+	}
+	wait(t, &writerWG, "stream writers")
+	wait(t, &wg, "stream readers")
+
+	length, err := s.XLen("stream")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(total), length)
+	assertUnblocked(t, s)
+}
+
+// TestBlockingPopStorm 大量客户端在两个列表上 BLPOP/BRPOP，推入者用 LPUSH/RPUSH 推入，
+// 另有客户端非阻塞 LPOP 抢占。每个元素必须恰好被取出一次，没有等待者被遗漏
+func TestBlockingPopStorm(t *testing.T) {
+	s := openStore(t)
+	seed := stressSeed(t)
+	waiters, pushers, perPusher := 16, 4, scale(100)
+	total := pushers * perPusher
+	keys := []string{"q1", "q2"}
+	const stop = "stop"
+
+	popped := make(chan string, total+waiters)
+	var consumed atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < waiters; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rng := workerRand(seed, 100+w)
+			order := append([]string(nil), keys...)
+			for {
+				rng.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+				// 超时长于整个测试：等待者只能被推入唤醒，遗漏的唤醒会使测试超时
+				var value string
+				var err error
+				if rng.Intn(2) == 0 {
+					_, value, err = s.BLPOPBlocking(order, int(2*stressTimeout/time.Second))
+				} else {
+					_, value, err = s.BRPOPBlocking(order, int(2*stressTimeout/time.Second))
+				}
+				if err != nil {
+					t.Errorf("waiter %d: %v", w, err)
+					return
+				}
+				if value == stop {
+					return
+				}
+				if value != "" {
+					popped <- value
+					consumed.Add(1)
+				}
+			}
+		}(w)
+	}
+
+	var pushWG sync.WaitGroup
+	for p := 0; p < pushers; p++ {
+		pushWG.Add(1)
+		go func(p int) {
+			defer pushWG.Done()
+			rng := workerRand(seed, p)
+			for i := 0; i < perPusher; {
+				n := min(1+rng.Intn(3), perPusher-i)
+				values := make([]string, n)
+				for j := range values {
+					values[j] = fmt.Sprintf("p%d-%d", p, i+j)
+				}
+				key := keys[rng.Intn(len(keys))]
+				var err error
+				if rng.Intn(2) == 0 {
+					_, err = s.LPush(key, values...)
+				} else {
+					_, err = s.RPush(key, values...)
+				}
+				if err != nil {
+					t.Errorf("pusher %d: %v", p, err)
+					return
+				}
+				i += n
+				pause(rng)
+			}
+		}(p)
+	}
+
+	// 非阻塞 LPOP 与等待者抢同一个元素
+	stealDone := make(chan struct{})
+	go func() {
+		defer close(stealDone)
+		rng := workerRand(seed, 1000)
+		for consumed.Load() < int64(total) {
+			value, err := s.LPop(keys[rng.Intn(len(keys))])
+			if err != nil {
+				t.Errorf("stealer: %v", err)
+				return
+			}
+			if value != "" {
+				popped <- value
+				consumed.Add(1)
+			}
+			time.Sleep(time.Duration(rng.Intn(500)) * time.Microsecond)
+		}
+	}()
+
+	wait(t, &pushWG, "pushers")
+	deadline := time.Now().Add(stressTimeout)
+	for consumed.Load() < int64(total) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if consumed.Load() < int64(total) {
+		t.Fatalf("only %d of %d values were popped", consumed.Load(), total)
+	}
+	<-stealDone
+
+	// 每个等待者取到一个 stop 后退出
+	stops := make([]string, waiters)
+	for i := range stops {
+		stops[i] = stop
+	}
+	_, err := s.RPush(keys[0], stops...)
+	assert.NoError(t, err)
+	wait(t, &wg, "blocked poppers")
+
+	close(popped)
+	var got []string
+	for v := range popped {
+		got = append(got, v)
+	}
+	sort.Strings(got)
+	var want []string
+	for p := 0; p < pushers; p++ {
+		for i := 0; i < perPusher; i++ {
+			want = append(want, fmt.Sprintf("p%d-%d", p, i))
+		}
+	}
+	sort.Strings(want)
+	assert.Equal(t, want, got)
+	for _, key := range keys {
+		length, err := s.LLen(key)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(0), length)
+	}
+	assertUnblocked(t, s)
+}
+
+// TestExpireRacingReads 写者不断写入带过期时间的键，读者并发读取，主动过期周期在时钟前进时删除键。
+// 读到的值必须属于该键；时钟越过所有过期时间并完成一轮扫描后，所有键及其类型键都已删除
+func TestExpireRacingReads(t *testing.T) {
+	s := openStore(t)
+	seed := stressSeed(t)
+	clock := store.NewManualClock(time.Now())
+	s.SetClock(clock)
+	writers, readers, keysPerWriter, rounds := 4, 4, 16, scale(200)
+	keyName := func(w, i int) string { return fmt.Sprintf("k:%d:%d", w, i) }
+
+	var done atomic.Bool
+	var wg sync.WaitGroup
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			rng := workerRand(seed, 100+r)
+			for !done.Load() {
+				key := keyName(rng.Intn(writers), rng.Intn(keysPerWriter))
+				switch rng.Intn(4) {
+				case 0:
+					value, err := s.Get(key)
+					if err == nil && !strings.HasPrefix(value, key+"=") {
+						t.Errorf("GET %s returned %q", key, value)
+					}
+				case 1:
+					if _, err := s.TTL(key); err != nil {
+						t.Errorf("TTL %s: %v", key, err)
+					}
+				case 2:
+					if _, err := s.Exists(key); err != nil {
+						t.Errorf("EXISTS %s: %v", key, err)
+					}
+				default:
+					if _, err := s.Type(key); err != nil {
+						t.Errorf("TYPE %s: %v", key, err)
+					}
+				}
+				pause(rng)
+			}
+		}(r)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rng := workerRand(seed, 1000)
+		for !done.Load() {
+			clock.Advance(time.Duration(rng.Intn(50)) * time.Millisecond)
+			if _, err := s.ExpireCycle(1 + rng.Intn(16)); err != nil {
+				t.Errorf("expire cycle: %v", err)
+			}
+			pause(rng)
+		}
+	}()
+
+	// 每个键只有一个写者，SET 与 PEXPIRE 之间不会被其他写者插入不带过期时间的值
+	var writeWG sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		writeWG.Add(1)
+		go func(w int) {
+			defer writeWG.Done()
+			rng := workerRand(seed, w)
+			for i := 0; i < rounds; i++ {
+				key := keyName(w, rng.Intn(keysPerWriter))
+				if rng.Intn(5) == 0 {
+					if _, err := s.Del(key); err != nil {
+						t.Errorf("DEL %s: %v", key, err)
+					}
+					continue
+				}
+				if err := s.Set(key, fmt.Sprintf("%s=%d", key, i)); err != nil {
+					t.Errorf("SET %s: %v", key, err)
+					continue
+				}
+				if _, err := s.PExpire(key, int64(1+rng.Intn(200))); err != nil {
+					t.Errorf("PEXPIRE %s: %v", key, err)
+				}
+				pause(rng)
+			}
+		}(w)
+	}
+	wait(t, &writeWG, "writers")
+	done.Store(true)
+	wait(t, &wg, "readers")
+
+	// 当前一轮可能从中间开始，再完成一整轮才覆盖所有键
+	clock.Advance(time.Hour)
+	target := s.ExpireStats().Passes + 2
+	for s.ExpireStats().Passes < target {
+		_, err := s.ExpireCycle(64)
+		assert.NoError(t, err)
+	}
+	for w := 0; w < writers; w++ {
+		for i := 0; i < keysPerWriter; i++ {
+			key := keyName(w, i)
+			exists, err := s.Exists(key)
+			assert.NoError(t, err)
+			if exists {
+				t.Errorf("%s still exists after its TTL", key)
+			}
+			typ, err := s.Type(key)
+			assert.NoError(t, err)
+			assert.Equal(t, "none", typ)
+		}
+	}
+}
+
+// TestPubSubChurn 订阅者不断订阅、退订、替换，发布者并发发布。全部移除后不留下空频道或模式
+func TestPubSubChurn(t *testing.T) {
+	seed := stressSeed(t)
+	psm := store.NewPubSubManager()
+	subscribers, publishers, ops := 8, 4, scale(500)
+	channels := []string{"news", "sports", "weather", "chat:1", "chat:2"}
+	patterns := []string{"chat:*", "news", "w*"}
+
+	var done atomic.Bool
+	var pubWG sync.WaitGroup
+	for p := 0; p < publishers; p++ {
+		pubWG.Add(1)
+		go func(p int) {
+			defer pubWG.Done()
+			rng := workerRand(seed, 100+p)
+			for !done.Load() {
+				ch := channels[rng.Intn(len(channels))]
+				if rng.Intn(3) == 0 {
+					psm.SPublish(ch, []byte("shard"))
+				} else {
+					psm.Publish(ch, []byte("msg"))
+				}
+				pause(rng)
+			}
+		}(p)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < subscribers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rng := workerRand(seed, i)
+			var drained sync.WaitGroup
+			newSub := func(n int) *store.Subscriber {
+				sub := store.NewSubscriber(fmt.Sprintf("sub-%d-%d", i, n))
+				drained.Add(1)
+				go func() {
+					defer drained.Done()
+					for range sub.MessageCh {
+					}
+				}()
+				return sub
+			}
+			sub := newSub(0)
+			for n := 0; n < ops; n++ {
+				switch rng.Intn(7) {
+				case 0:
+					psm.Subscribe(sub, channels[rng.Intn(len(channels))])
+				case 1:
+					psm.Unsubscribe(sub, channels[rng.Intn(len(channels))])
+				case 2:
+					psm.PSubscribe(sub, patterns[rng.Intn(len(patterns))])
+				case 3:
+					psm.PUnsubscribe(sub)
+				case 4:
+					psm.SSubscribe(sub, channels[rng.Intn(len(channels))])
+				case 5:
+					psm.SUnsubscribe(sub)
+				default:
+					// 连接断开后重新连接
+					psm.RemoveSubscriber(sub)
+					sub = newSub(n + 1)
+				}
+				pause(rng)
+			}
+			psm.RemoveSubscriber(sub)
+			drained.Wait()
+		}(i)
+	}
+	wait(t, &wg, "subscribers")
+	done.Store(true)
+	wait(t, &pubWG, "publishers")
+
+	for _, ch := range channels {
+		assert.Equal(t, 0, psm.GetSubscriberCount(ch))
+		assert.Equal(t, 0, psm.GetShardSubscriberCount(ch))
+	}
+	assert.Equal(t, 0, len(psm.GetChannels("")))
+	assert.Equal(t, 0, len(psm.GetShardChannels("")))
+	assert.Equal(t, 0, psm.GetPatternCount())
+}
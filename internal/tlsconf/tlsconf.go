@@ -0,0 +1,107 @@
+// Package tlsconf 根据 -tls-cert/-tls-key/-tls-ca 构造客户端监听器与复制链路使用的 TLS 配置
+//
+// 服务端与复制客户端共用同一对证书：服务端用它向客户端证明身份，从节点连接主节点时
+// 用它作为客户端证书。-tls-ca 同时用于校验客户端证书与主节点证书。
+package tlsconf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// Options TLS 证书配置
+type Options struct {
+	CertFile string // PEM 证书（可包含中间证书）
+	KeyFile  string // PEM 私钥
+	CAFile   string // 校验对端证书的 CA；为空时服务端不校验客户端证书，客户端使用系统根证书
+	// AuthClients 所有客户端都必须出示由 CAFile 签发的证书；为 false 时证书可选，
+	// 出示了的仍会校验（复制连接是否必须出示证书由服务端单独控制）
+	AuthClients bool
+}
+
+// Enabled 是否配置了证书
+func (o Options) Enabled() bool {
+	return o.CertFile != "" || o.KeyFile != ""
+}
+
+// ServerConfig 客户端监听器使用的 TLS 配置
+func ServerConfig(o Options) (*tls.Config, error) {
+	cert, err := o.loadCertificate()
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if o.CAFile != "" {
+		pool, err := loadCAPool(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if o.AuthClients {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	} else if o.AuthClients {
+		return nil, errors.New("tls: client authentication requires a CA file")
+	}
+	return cfg, nil
+}
+
+// ClientConfig 从节点连接主节点使用的 TLS 配置：出示自己的证书，并用 CAFile 校验主节点证书
+func ClientConfig(o Options) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if o.Enabled() {
+		cert, err := o.loadCertificate()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if o.CAFile != "" {
+		pool, err := loadCAPool(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// VerifiedPeer 连接是否是出示了已校验证书的 TLS 连接
+func VerifiedPeer(conn net.Conn) bool {
+	tc, ok := conn.(*tls.Conn)
+	if !ok {
+		return false
+	}
+	return len(tc.ConnectionState().VerifiedChains) > 0
+}
+
+func (o Options) loadCertificate() (tls.Certificate, error) {
+	if o.CertFile == "" || o.KeyFile == "" {
+		return tls.Certificate{}, errors.New("tls: both a certificate and a key file are required")
+	}
+	cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("tls: load key pair: %w", err)
+	}
+	return cert, nil
+}
+
+func loadCAPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path) // #nosec G304 - path comes from the operator's command line
+	if err != nil {
+		return nil, fmt.Errorf("tls: read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("tls: no certificates found in %s", path)
+	}
+	return pool, nil
+}
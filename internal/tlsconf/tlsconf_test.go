@@ -0,0 +1,104 @@
+package tlsconf
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+var (
+	serverOpts = Options{CertFile: "testdata/server.pem", KeyFile: "testdata/server-key.pem", CAFile: "testdata/ca.pem"}
+	clientOpts = Options{CertFile: "testdata/client.pem", KeyFile: "testdata/client-key.pem", CAFile: "testdata/ca.pem"}
+)
+
+// handshake 在内存管道上完成一次握手，返回服务端连接与双方的握手错误
+func handshake(t *testing.T, serverCfg, clientCfg *tls.Config) (*tls.Conn, error, error) {
+	t.Helper()
+	sc, cc := net.Pipe()
+	t.Cleanup(func() {
+		_ = sc.Close()
+		_ = cc.Close()
+	})
+	server := tls.Server(sc, serverCfg)
+	client := tls.Client(cc, clientCfg)
+	errCh := make(chan error, 1)
+	go func() {
+		err := client.Handshake()
+		if err != nil {
+			_ = cc.Close()
+		} else {
+			// TLS 1.3 下服务端在客户端握手完成后才校验客户端证书，继续读取以接收告警
+			go func() { _, _ = io.Copy(io.Discard, client) }()
+		}
+		errCh <- err
+	}()
+	serverErr := server.Handshake()
+	if serverErr != nil {
+		_ = sc.Close()
+	}
+	return server, serverErr, <-errCh
+}
+
+func TestConfigErrors(t *testing.T) {
+	_, err := ServerConfig(Options{CertFile: "testdata/server.pem"})
+	assert.Error(t, err)
+	_, err = ServerConfig(Options{CertFile: "testdata/server.pem", KeyFile: "testdata/missing.pem"})
+	assert.Error(t, err)
+	_, err = ServerConfig(Options{CertFile: "testdata/server.pem", KeyFile: "testdata/server-key.pem", AuthClients: true})
+	assert.Error(t, err)
+	_, err = ServerConfig(Options{CertFile: "testdata/server.pem", KeyFile: "testdata/server-key.pem", CAFile: "testdata/server-key.pem"})
+	assert.Error(t, err)
+	assert.False(t, Options{CAFile: "testdata/ca.pem"}.Enabled())
+}
+
+func TestMutualTLS(t *testing.T) {
+	serverCfg, err := ServerConfig(serverOpts)
+	assert.NoError(t, err)
+	assert.Equal(t, tls.VerifyClientCertIfGiven, serverCfg.ClientAuth)
+
+	// 出示由 CA 签发的证书
+	clientCfg, err := ClientConfig(clientOpts)
+	assert.NoError(t, err)
+	clientCfg.ServerName = "localhost"
+	conn, serverErr, clientErr := handshake(t, serverCfg, clientCfg)
+	assert.NoError(t, serverErr)
+	assert.NoError(t, clientErr)
+	assert.True(t, VerifiedPeer(conn))
+
+	// 不出示证书时允许连接，但不算已校验
+	anonCfg, err := ClientConfig(Options{CAFile: "testdata/ca.pem"})
+	assert.NoError(t, err)
+	anonCfg.ServerName = "localhost"
+	conn, serverErr, clientErr = handshake(t, serverCfg, anonCfg)
+	assert.NoError(t, serverErr)
+	assert.NoError(t, clientErr)
+	assert.False(t, VerifiedPeer(conn))
+
+	// 出示其他 CA 签发的证书被拒绝
+	rogueCfg, err := ClientConfig(Options{CertFile: "testdata/untrusted.pem", KeyFile: "testdata/untrusted-key.pem", CAFile: "testdata/ca.pem"})
+	assert.NoError(t, err)
+	rogueCfg.ServerName = "localhost"
+	// 客户端默认只出示与服务端接受的 CA 匹配的证书，这里强制发送
+	rogue := rogueCfg.Certificates[0]
+	rogueCfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) { return &rogue, nil }
+	_, serverErr, _ = handshake(t, serverCfg, rogueCfg)
+	assert.Error(t, serverErr)
+
+	// AuthClients 要求所有客户端出示证书
+	strict := serverOpts
+	strict.AuthClients = true
+	strictCfg, err := ServerConfig(strict)
+	assert.NoError(t, err)
+	_, serverErr, _ = handshake(t, strictCfg, anonCfg)
+	assert.Error(t, serverErr)
+
+	// 客户端用 CA 校验服务端证书
+	wrongName := clientCfg.Clone()
+	wrongName.ServerName = "example.com"
+	_, _, clientErr = handshake(t, serverCfg, wrongName)
+	assert.Error(t, clientErr)
+	assert.False(t, VerifiedPeer(&net.TCPConn{}))
+}
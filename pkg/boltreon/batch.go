@@ -0,0 +1,102 @@
+package boltreon
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// ErrBatchTooLarge 批量超过单个 Badger 事务的大小上限，没有写入任何数据，需要拆分后重试
+var ErrBatchTooLarge = store.ErrBatchTooLarge
+
+// Batch 批量写入：排队的操作在 Commit 时放在同一个 Badger 事务中执行，只 fsync 一次，
+// 适合一次导入大量小写入。与 Update 不同，某个操作出错（类型不符、值过大）只影响该操作，
+// 错误记录在对应的 Result 中，其余操作照常提交
+//
+//	b := db.NewBatch()
+//	b.HSet("user:1", "name", "bolt")
+//	b.RPush("events", "signup")
+//	results, err := b.Commit()
+type Batch struct {
+	db  *DB
+	ops []store.BatchOp
+}
+
+// Result 批量中单个操作的结果。N 为 HSet/SAdd 新增的字段/成员数、RPush 之后的列表长度，
+// Set、SetEX 和 ZAdd 为 0
+type Result struct {
+	N   int64
+	Err error
+}
+
+// NewBatch 创建空的批量
+func (db *DB) NewBatch() *Batch {
+	return &Batch{db: db}
+}
+
+// Len 已排队的操作数
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// Set 排队写入字符串
+func (b *Batch) Set(key, value string) {
+	b.ops = append(b.ops, store.BatchOp{Kind: store.BatchSet, Key: key, Value: value})
+}
+
+// SetEX 排队写入带过期时间的字符串
+func (b *Batch) SetEX(key, value string, ttl time.Duration) {
+	b.ops = append(b.ops, store.BatchOp{Kind: store.BatchSet, Key: key, Value: value, TTL: ttl})
+}
+
+// HSet 排队写入哈希字段
+func (b *Batch) HSet(key, field, value string) {
+	b.ops = append(b.ops, store.BatchOp{Kind: store.BatchHSet, Key: key, Field: field, Value: value})
+}
+
+// SAdd 排队向集合添加成员
+func (b *Batch) SAdd(key string, members ...string) {
+	members = append([]string(nil), members...)
+	b.ops = append(b.ops, store.BatchOp{Kind: store.BatchSAdd, Key: key, Values: members})
+}
+
+// RPush 排队向列表尾部追加
+func (b *Batch) RPush(key string, values ...string) {
+	values = append([]string(nil), values...)
+	b.ops = append(b.ops, store.BatchOp{Kind: store.BatchRPush, Key: key, Values: values})
+}
+
+// ZAdd 排队向有序集合添加成员
+func (b *Batch) ZAdd(key string, members ...Z) {
+	zs := make([]store.ZSetMember, len(members))
+	for i, m := range members {
+		zs[i] = store.ZSetMember{Member: m.Member, Score: m.Score}
+	}
+	b.ops = append(b.ops, store.BatchOp{Kind: store.BatchZAdd, Key: key, Members: zs})
+}
+
+// Commit 执行排队的操作并清空批量，结果与排队顺序一一对应。
+// 返回错误时（如 ErrBatchTooLarge）没有写入任何数据
+func (b *Batch) Commit() ([]Result, error) {
+	ops := b.ops
+	b.ops = nil
+	if len(ops) == 0 {
+		return nil, nil
+	}
+	// 与 Update 的提交互不交错
+	b.db.txMu.Lock()
+	results, err := b.db.s.ApplyBatch(ops)
+	b.db.txMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Result, len(results))
+	for i, r := range results {
+		out[i] = Result{N: r.N, Err: r.Err}
+		if errors.Is(r.Err, store.ErrBatchWrongType) {
+			out[i].Err = ErrWrongType
+		}
+	}
+	return out, nil
+}
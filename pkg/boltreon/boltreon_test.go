@@ -0,0 +1,235 @@
+package boltreon
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/store"
+	"github.com/zeebo/assert"
+)
+
+func openTestDB(t *testing.T, opts *Options) *DB {
+	db, err := Open(t.TempDir(), opts)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestStringsAndKeys(t *testing.T) {
+	db := openTestDB(t, nil)
+
+	_, err := db.Get("missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = db.TTL("missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	assert.NoError(t, db.Set("greeting", "hello"))
+	v, err := db.Get("greeting")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", v)
+	ttl, err := db.TTL("greeting")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(-1), ttl)
+
+	assert.NoError(t, db.SetEX("session", "s1", time.Hour))
+	ttl, err = db.TTL("session")
+	assert.NoError(t, err)
+	assert.True(t, ttl > 59*time.Minute && ttl <= time.Hour)
+
+	ok, err := db.SetNX("greeting", "again")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	n, err := db.IncrBy("counter", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+
+	typ, err := db.Type("counter")
+	assert.NoError(t, err)
+	assert.Equal(t, "string", typ)
+	deleted, err := db.Del("greeting", "counter", "missing")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	exists, err := db.Exists("greeting")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestCollections(t *testing.T) {
+	db := openTestDB(t, nil)
+
+	added, err := db.HSet("user:1", "name", "bolt")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, added)
+	added, err = db.HSet("user:1", "name", "reon")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, added)
+	name, err := db.HGet("user:1", "name")
+	assert.NoError(t, err)
+	assert.Equal(t, "reon", name)
+	_, err = db.HGet("user:1", "age")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	fields, err := db.HGetAll("user:1")
+	assert.NoError(t, err)
+	assert.DeepEqual(t, map[string]string{"name": "reon"}, fields)
+
+	length, err := db.RPush("queue", "a", "b", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, length)
+	items, err := db.LRange("queue", 0, -1)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"a", "b", "c"}, items)
+	first, err := db.LPop("queue")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", first)
+	_, err = db.LPop("empty")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	added, err = db.SAdd("tags", "x", "y", "x")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, added)
+	isMember, err := db.SIsMember("tags", "y")
+	assert.NoError(t, err)
+	assert.True(t, isMember)
+
+	assert.NoError(t, db.ZAdd("board", Z{Member: "a", Score: 3}, Z{Member: "b", Score: 1}))
+	top, err := db.ZRevRange("board", 0, 0)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []Z{{Member: "a", Score: 3}}, top)
+	score, err := db.ZIncrBy("board", "b", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 6.0, score)
+	_, err = db.ZScore("board", "missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// 类型不符
+	_, err = db.Get("board")
+	assert.True(t, errors.Is(err, ErrWrongType))
+	_, err = db.LPush("user:1", "x")
+	assert.True(t, errors.Is(err, ErrWrongType))
+}
+
+func TestMaxValueSize(t *testing.T) {
+	db := openTestDB(t, &Options{MaxValueSize: 4})
+	var tooLarge *store.ValueTooLargeError
+	assert.True(t, errors.As(db.Set("k", "12345"), &tooLarge))
+	_, err := db.SAdd("s", "ok", "toolong")
+	assert.True(t, errors.As(err, &tooLarge))
+	assert.NoError(t, db.Set("k", "1234"))
+}
+
+func TestUpdate(t *testing.T) {
+	db := openTestDB(t, nil)
+	assert.NoError(t, db.Set("balance", "100"))
+
+	err := db.Update(func(tx *Tx) error {
+		tx.Set("balance", "90")
+		tx.RPush("ledger", "-10")
+		return nil
+	}, "balance")
+	assert.NoError(t, err)
+	v, _ := db.Get("balance")
+	assert.Equal(t, "90", v)
+
+	// 监视的键在提交前被修改：不执行
+	err = db.Update(func(tx *Tx) error {
+		assert.NoError(t, db.Set("balance", "50"))
+		tx.Set("balance", "80")
+		tx.RPush("ledger", "-10")
+		return nil
+	}, "balance")
+	assert.True(t, errors.Is(err, ErrTxConflict))
+	v, _ = db.Get("balance")
+	assert.Equal(t, "50", v)
+	n, _ := db.LLen("ledger")
+	assert.Equal(t, int64(1), n)
+
+	// fn 返回错误：不执行
+	abort := errors.New("abort")
+	assert.Equal(t, abort, db.Update(func(tx *Tx) error {
+		tx.Del("balance")
+		return abort
+	}))
+	exists, _ := db.Exists("balance")
+	assert.True(t, exists)
+}
+
+func TestBatch(t *testing.T) {
+	db := openTestDB(t, nil)
+	assert.NoError(t, db.Set("str", "v"))
+
+	b := db.NewBatch()
+	b.Set("a", "1")
+	b.SetEX("session", "s1", time.Hour)
+	b.HSet("user:1", "name", "bolt")
+	b.SAdd("tags", "x", "y")
+	b.RPush("queue", "a")
+	b.RPush("queue", "b", "c")
+	b.ZAdd("board", Z{Member: "a", Score: 1})
+	b.HSet("str", "f", "v")
+	assert.Equal(t, 8, b.Len())
+
+	results, err := b.Commit()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, b.Len())
+	assert.Equal(t, 8, len(results))
+	for i, n := range []int64{0, 0, 1, 2, 1, 3, 0} {
+		assert.NoError(t, results[i].Err)
+		assert.Equal(t, n, results[i].N)
+	}
+	assert.True(t, errors.Is(results[7].Err, ErrWrongType))
+
+	items, err := db.LRange("queue", 0, -1)
+	assert.NoError(t, err)
+	assert.DeepEqual(t, []string{"a", "b", "c"}, items)
+	ttl, err := db.TTL("session")
+	assert.NoError(t, err)
+	assert.True(t, ttl > 59*time.Minute)
+	v, err := db.Get("str")
+	assert.NoError(t, err)
+	assert.Equal(t, "v", v)
+
+	results, err = b.Commit()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(results))
+}
+
+func TestServe(t *testing.T) {
+	db := openTestDB(t, nil)
+	assert.NoError(t, db.Set("greeting", "hello"))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	served := make(chan error, 1)
+	go func() { served <- db.Serve(ln) }()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	send := func(cmd string) string {
+		_, err := conn.Write([]byte(cmd))
+		assert.NoError(t, err)
+		line, err := r.ReadString('\n')
+		assert.NoError(t, err)
+		if line[0] == '$' {
+			value, err := r.ReadString('\n')
+			assert.NoError(t, err)
+			line += value
+		}
+		return line
+	}
+
+	// 嵌入写入的数据可以通过 RESP 读取，反之亦然
+	assert.Equal(t, "$5\r\nhello\r\n", send("*2\r\n$3\r\nGET\r\n$8\r\ngreeting\r\n"))
+	assert.Equal(t, "+OK\r\n", send("*3\r\n$3\r\nSET\r\n$4\r\nfrom\r\n$4\r\nresp\r\n"))
+	v, err := db.Get("from")
+	assert.NoError(t, err)
+	assert.Equal(t, "resp", v)
+
+	assert.NoError(t, ln.Close())
+	assert.Error(t, <-served)
+}
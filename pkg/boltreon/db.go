@@ -0,0 +1,188 @@
+package boltreon
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/backup"
+	"github.com/lbp0200/BoltDB/internal/replication"
+	"github.com/lbp0200/BoltDB/internal/server"
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+var (
+	// ErrNotFound 键（或字段、成员）不存在
+	ErrNotFound = errors.New("boltreon: not found")
+	// ErrWrongType 键的类型与方法不符，与 Redis 的 WRONGTYPE 错误相同
+	ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+)
+
+// Options 打开存储的参数，零值使用与 boltDB 服务相同的默认值
+type Options struct {
+	// TrashRetention 大于 0 时 Del 先将键移入回收站，保留这段时间
+	TrashRetention time.Duration
+	// EncryptionKeySource 静态加密主密钥的来源（file:、env:、cmd:），为空时不加密
+	EncryptionKeySource string
+	// DataKeyRotation Badger 数据密钥的轮换周期
+	DataKeyRotation time.Duration
+	// MaxValueSize 单个值的上限（字节），更大的写入返回 *store.ValueTooLargeError
+	MaxValueSize int64
+	// DecompressCacheSize 解压缓存的容量（字节），小于 0 时不缓存
+	DecompressCacheSize int64
+}
+
+// DB 嵌入模式打开的存储
+type DB struct {
+	s    *store.BotreonStore
+	path string
+
+	// txMu 串行化 Update 的提交，使同一进程内的事务互不交错
+	txMu sync.Mutex
+
+	serveOnce sync.Once
+	handler   *server.Handler
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// Open 打开（不存在时创建）path 目录下的存储，opts 为 nil 时使用默认值
+func Open(path string, opts *Options) (*DB, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	s, err := store.NewBotreonStoreWithOptions(path, store.StoreOptions{
+		TrashRetention:      opts.TrashRetention,
+		EncryptionKeySource: opts.EncryptionKeySource,
+		DataKeyRotation:     opts.DataKeyRotation,
+		MaxValueSize:        opts.MaxValueSize,
+		DecompressCacheSize: opts.DecompressCacheSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 与服务启动时相同，清理过期键和孤立数据
+	if err := s.NextStartup(); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return &DB{s: s, path: path, stop: make(chan struct{})}, nil
+}
+
+// Close 停止 Serve 启动的后台任务并关闭存储。Serve 使用的监听器由调用方关闭
+func (db *DB) Close() error {
+	var err error
+	db.closeOnce.Do(func() {
+		close(db.stop)
+		// 通过 CONFIG SET backup-schedule 开启的定时备份在关闭存储之前停止
+		if db.handler != nil {
+			db.handler.Backup.Close()
+		}
+		err = db.s.Close()
+	})
+	return err
+}
+
+// Serve 在 ln 上为同一个存储提供 RESP 服务，直到 ln 关闭（返回 Accept 的错误）。
+// 多次调用（如同时监听 TCP 和 Unix socket）共享同一个服务器状态
+func (db *DB) Serve(ln net.Listener) error {
+	return db.server().ServeListeners([]net.Listener{ln})
+}
+
+// server 第一次调用时创建服务器级 Handler，组装方式与 boltDB 服务相同
+func (db *DB) server() *server.Handler {
+	db.serveOnce.Do(func() {
+		pubsub := store.NewPubSubManager()
+		pubsub.EnableDurable(db.s, store.DefaultDurableRetention)
+		db.s.SetZWatchPublisher(func(channel string, message []byte) {
+			pubsub.Publish(channel, message)
+		})
+		db.handler = &server.Handler{
+			Db:          db.s,
+			Replication: replication.NewReplicationManager(db.s),
+			Backup:      backup.NewBackupManager(db.s, filepath.Join(db.path, "backup")),
+			PubSub:      pubsub,
+		}
+		go db.handler.RunScheduler(server.DefaultScheduleInterval, db.stop)
+		go db.handler.RunExpireSweeper(db.stop)
+		go db.handler.RunValueLogGC(db.stop)
+	})
+	return db.handler
+}
+
+// checkType 键存在且类型不是 want 时返回 ErrWrongType
+func (db *DB) checkType(key, want string) error {
+	t, err := db.s.Type(key)
+	if err != nil {
+		return err
+	}
+	if t != "none" && t != want {
+		return ErrWrongType
+	}
+	return nil
+}
+
+// checkSize 任一值超过 MaxValueSize 时返回 *store.ValueTooLargeError
+func (db *DB) checkSize(values ...string) error {
+	for _, v := range values {
+		if err := db.s.CheckValueSize(int64(len(v))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Del 删除键，返回删除的个数
+func (db *DB) Del(keys ...string) (int, error) {
+	n := 0
+	for _, key := range keys {
+		deleted, err := db.s.Del(key)
+		if err != nil {
+			return n, err
+		}
+		n += int(deleted)
+	}
+	return n, nil
+}
+
+// Exists 键是否存在
+func (db *DB) Exists(key string) (bool, error) {
+	return db.s.Exists(key)
+}
+
+// Type 键的类型（string、hash、list、set、zset 等），不存在时为 none
+func (db *DB) Type(key string) (string, error) {
+	return db.s.Type(key)
+}
+
+// Keys 返回匹配 glob 模式的键
+func (db *DB) Keys(pattern string) ([]string, error) {
+	return db.s.DBKeys(0, pattern)
+}
+
+// Expire 设置键的剩余生存时间（精确到毫秒），键不存在时返回 false
+func (db *DB) Expire(key string, ttl time.Duration) (bool, error) {
+	return db.s.PExpire(key, ttl.Milliseconds())
+}
+
+// Persist 移除键的过期时间，键不存在或没有过期时间时返回 false
+func (db *DB) Persist(key string) (bool, error) {
+	return db.s.Persist(key)
+}
+
+// TTL 键的剩余生存时间，没有过期时间时为 -1，键不存在时返回 ErrNotFound
+func (db *DB) TTL(key string) (time.Duration, error) {
+	ms, err := db.s.PTTL(key)
+	if err != nil {
+		return 0, err
+	}
+	switch ms {
+	case -2:
+		return 0, ErrNotFound
+	case -1:
+		return -1, nil
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
@@ -0,0 +1,21 @@
+// Package boltreon 以嵌入模式使用 Boltreon：在应用进程内直接打开存储，不经过 TCP，
+// 数据结构与 RESP 服务完全相同（同一个目录既可嵌入打开，也可由 boltDB 服务打开，但不能同时打开）。
+//
+//	db, err := boltreon.Open("/var/lib/app", nil)
+//	if err != nil {
+//		return err
+//	}
+//	defer db.Close()
+//
+//	_ = db.Set("greeting", "hello")
+//	_, _ = db.HSet("user:1", "name", "bolt")
+//	_, _ = db.ZAdd("leaderboard", boltreon.Z{Member: "bolt", Score: 42})
+//
+// 需要时可以在同一个存储上启动 RESP 服务，让其他进程用 Redis 客户端访问：
+//
+//	ln, _ := net.Listen("tcp", ":6379")
+//	go db.Serve(ln)
+//
+// 键不存在时读取方法返回 ErrNotFound，键的类型与方法不符时返回 ErrWrongType。
+// DB 的方法可以并发调用；多个写入需要一起生效时使用 Update（见 Tx），大量写入需要减少 fsync 时使用 Batch。
+package boltreon
@@ -0,0 +1,82 @@
+package boltreon
+
+import (
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// HSet 设置哈希字段，返回新增的字段数（0 或 1）
+func (db *DB) HSet(key, field, value string) (int, error) {
+	if err := db.checkType(key, "hash"); err != nil {
+		return 0, err
+	}
+	if err := db.checkSize(field, value); err != nil {
+		return 0, err
+	}
+	exists, err := db.s.HExists(key, field)
+	if err != nil {
+		return 0, err
+	}
+	if err := db.s.HSet(key, field, value); err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// HGet 返回哈希字段的值，键或字段不存在时返回 ErrNotFound
+func (db *DB) HGet(key, field string) (string, error) {
+	if err := db.checkType(key, "hash"); err != nil {
+		return "", err
+	}
+	v, err := db.s.HGet(key, field)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return "", ErrNotFound
+	}
+	return string(v), err
+}
+
+// HDel 删除哈希字段，返回删除的个数
+func (db *DB) HDel(key string, fields ...string) (int, error) {
+	if err := db.checkType(key, "hash"); err != nil {
+		return 0, err
+	}
+	return db.s.HDel(key, fields...)
+}
+
+// HGetAll 返回哈希的所有字段，键不存在时为空 map
+func (db *DB) HGetAll(key string) (map[string]string, error) {
+	if err := db.checkType(key, "hash"); err != nil {
+		return nil, err
+	}
+	data, err := db.s.HGetAll(key)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string, len(data))
+	for f, v := range data {
+		fields[f] = string(v)
+	}
+	return fields, nil
+}
+
+// HLen 哈希的字段数
+func (db *DB) HLen(key string) (int64, error) {
+	if err := db.checkType(key, "hash"); err != nil {
+		return 0, err
+	}
+	n, err := db.s.HLen(key)
+	// #nosec G115 - 字段数在 int64 范围内
+	return int64(n), err
+}
+
+// HIncrBy 将哈希字段的整数值加上 n，返回新值
+func (db *DB) HIncrBy(key, field string, n int64) (int64, error) {
+	if err := db.checkType(key, "hash"); err != nil {
+		return 0, err
+	}
+	return db.s.HIncrBy(key, field, n)
+}
@@ -0,0 +1,65 @@
+package boltreon
+
+// LPush 将值依次插入列表头部，返回插入后的长度
+func (db *DB) LPush(key string, values ...string) (int, error) {
+	if err := db.checkType(key, "list"); err != nil {
+		return 0, err
+	}
+	if err := db.checkSize(values...); err != nil {
+		return 0, err
+	}
+	return db.s.LPush(key, values...)
+}
+
+// RPush 将值依次追加到列表尾部，返回追加后的长度
+func (db *DB) RPush(key string, values ...string) (int, error) {
+	if err := db.checkType(key, "list"); err != nil {
+		return 0, err
+	}
+	if err := db.checkSize(values...); err != nil {
+		return 0, err
+	}
+	return db.s.RPush(key, values...)
+}
+
+// LPop 移除并返回列表的第一个元素，列表为空时返回 ErrNotFound
+func (db *DB) LPop(key string) (string, error) {
+	return db.pop(key, db.s.LPop)
+}
+
+// RPop 移除并返回列表的最后一个元素，列表为空时返回 ErrNotFound
+func (db *DB) RPop(key string) (string, error) {
+	return db.pop(key, db.s.RPop)
+}
+
+func (db *DB) pop(key string, pop func(key string) (string, error)) (string, error) {
+	if err := db.checkType(key, "list"); err != nil {
+		return "", err
+	}
+	n, err := db.s.LLen(key)
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return "", ErrNotFound
+	}
+	return pop(key)
+}
+
+// LRange 返回下标 start 到 stop（含）的元素，负数下标从尾部计数，与 LRANGE 相同
+func (db *DB) LRange(key string, start, stop int64) ([]string, error) {
+	if err := db.checkType(key, "list"); err != nil {
+		return nil, err
+	}
+	return db.s.LRange(key, start, stop)
+}
+
+// LLen 列表的长度
+func (db *DB) LLen(key string) (int64, error) {
+	if err := db.checkType(key, "list"); err != nil {
+		return 0, err
+	}
+	n, err := db.s.LLen(key)
+	// #nosec G115 - 列表长度在 int64 范围内
+	return int64(n), err
+}
@@ -0,0 +1,46 @@
+package boltreon
+
+// SAdd 添加集合成员，返回新增的个数
+func (db *DB) SAdd(key string, members ...string) (int, error) {
+	if err := db.checkType(key, "set"); err != nil {
+		return 0, err
+	}
+	if err := db.checkSize(members...); err != nil {
+		return 0, err
+	}
+	return db.s.SAdd(key, members...)
+}
+
+// SRem 删除集合成员，返回删除的个数
+func (db *DB) SRem(key string, members ...string) (int, error) {
+	if err := db.checkType(key, "set"); err != nil {
+		return 0, err
+	}
+	return db.s.SRem(key, members...)
+}
+
+// SMembers 返回集合的所有成员
+func (db *DB) SMembers(key string) ([]string, error) {
+	if err := db.checkType(key, "set"); err != nil {
+		return nil, err
+	}
+	return db.s.SMembers(key)
+}
+
+// SIsMember 成员是否在集合中
+func (db *DB) SIsMember(key, member string) (bool, error) {
+	if err := db.checkType(key, "set"); err != nil {
+		return false, err
+	}
+	return db.s.SIsMember(key, member)
+}
+
+// SCard 集合的成员数
+func (db *DB) SCard(key string) (int64, error) {
+	if err := db.checkType(key, "set"); err != nil {
+		return 0, err
+	}
+	n, err := db.s.SCard(key)
+	// #nosec G115 - 成员数在 int64 范围内
+	return int64(n), err
+}
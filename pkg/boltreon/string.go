@@ -0,0 +1,52 @@
+package boltreon
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lbp0200/BoltDB/internal/store"
+)
+
+// Get 返回字符串键的值
+func (db *DB) Get(key string) (string, error) {
+	if err := db.checkType(key, "string"); err != nil {
+		return "", err
+	}
+	v, err := db.s.Get(key)
+	if errors.Is(err, store.ErrKeyNotFound) {
+		return "", ErrNotFound
+	}
+	return v, err
+}
+
+// Set 设置字符串键的值并清除过期时间，与 SET 命令相同
+func (db *DB) Set(key, value string) error {
+	if err := db.checkSize(value); err != nil {
+		return err
+	}
+	return db.s.Set(key, value)
+}
+
+// SetEX 设置字符串键的值和剩余生存时间（精确到毫秒）
+func (db *DB) SetEX(key, value string, ttl time.Duration) error {
+	if err := db.checkSize(value); err != nil {
+		return err
+	}
+	return db.s.PSETEX(key, value, ttl.Milliseconds())
+}
+
+// SetNX 仅在键不存在时设置，返回是否设置
+func (db *DB) SetNX(key, value string) (bool, error) {
+	if err := db.checkSize(value); err != nil {
+		return false, err
+	}
+	return db.s.SetNX(key, value)
+}
+
+// IncrBy 将字符串键的整数值加上 n（键不存在时视为 0），返回新值
+func (db *DB) IncrBy(key string, n int64) (int64, error) {
+	if err := db.checkType(key, "string"); err != nil {
+		return 0, err
+	}
+	return db.s.INCRBY(key, n)
+}
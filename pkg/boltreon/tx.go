@@ -0,0 +1,142 @@
+package boltreon
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+// ErrTxConflict Update 监视的键在提交前被修改，事务没有执行
+var ErrTxConflict = errors.New("boltreon: watched key changed, transaction not executed")
+
+// Tx 事务，语义与 WATCH/MULTI/EXEC 相同：fn 中调用的写方法只是排队，
+// fn 返回 nil 后检查被监视的键未被修改，再依次执行。读取直接使用 DB
+//
+//	err := db.Update(func(tx *boltreon.Tx) error {
+//		balance, err := db.Get("balance")
+//		if err != nil {
+//			return err
+//		}
+//		n, _ := strconv.Atoi(balance)
+//		tx.Set("balance", strconv.Itoa(n-10))
+//		tx.RPush("ledger", "-10")
+//		return nil
+//	}, "balance")
+type Tx struct {
+	db  *DB
+	ops []func() error
+}
+
+// Update 执行事务。fn 返回错误时不执行任何写入；watch 中的键在 fn 开始之后被修改
+// （包括通过 RESP 服务修改）时返回 ErrTxConflict，调用方可以重试。
+// 与 EXEC 相同，某个写入出错时不回滚之前的写入；此时停止执行并返回该错误
+func (db *DB) Update(fn func(tx *Tx) error, watch ...string) error {
+	snapshots := make(map[string][]byte, len(watch))
+	for _, key := range watch {
+		snapshots[key] = db.snapshot(key)
+	}
+	tx := &Tx{db: db}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	db.txMu.Lock()
+	defer db.txMu.Unlock()
+	for key, snapshot := range snapshots {
+		if !bytes.Equal(db.snapshot(key), snapshot) {
+			return ErrTxConflict
+		}
+	}
+	for _, op := range tx.ops {
+		if err := op(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshot 键的序列化值，用于判断键是否被修改（不存在时为 nil）
+func (db *DB) snapshot(key string) []byte {
+	data, err := db.s.Dump(key)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// queue 将写入加入队列；可变参数先复制，调用方之后修改切片不影响事务
+func (tx *Tx) queue(op func() error) {
+	tx.ops = append(tx.ops, op)
+}
+
+// Set 见 DB.Set
+func (tx *Tx) Set(key, value string) {
+	tx.queue(func() error { return tx.db.Set(key, value) })
+}
+
+// SetEX 见 DB.SetEX
+func (tx *Tx) SetEX(key, value string, ttl time.Duration) {
+	tx.queue(func() error { return tx.db.SetEX(key, value, ttl) })
+}
+
+// IncrBy 见 DB.IncrBy
+func (tx *Tx) IncrBy(key string, n int64) {
+	tx.queue(func() error { _, err := tx.db.IncrBy(key, n); return err })
+}
+
+// Del 见 DB.Del
+func (tx *Tx) Del(keys ...string) {
+	keys = append([]string(nil), keys...)
+	tx.queue(func() error { _, err := tx.db.Del(keys...); return err })
+}
+
+// Expire 见 DB.Expire
+func (tx *Tx) Expire(key string, ttl time.Duration) {
+	tx.queue(func() error { _, err := tx.db.Expire(key, ttl); return err })
+}
+
+// HSet 见 DB.HSet
+func (tx *Tx) HSet(key, field, value string) {
+	tx.queue(func() error { _, err := tx.db.HSet(key, field, value); return err })
+}
+
+// HDel 见 DB.HDel
+func (tx *Tx) HDel(key string, fields ...string) {
+	fields = append([]string(nil), fields...)
+	tx.queue(func() error { _, err := tx.db.HDel(key, fields...); return err })
+}
+
+// LPush 见 DB.LPush
+func (tx *Tx) LPush(key string, values ...string) {
+	values = append([]string(nil), values...)
+	tx.queue(func() error { _, err := tx.db.LPush(key, values...); return err })
+}
+
+// RPush 见 DB.RPush
+func (tx *Tx) RPush(key string, values ...string) {
+	values = append([]string(nil), values...)
+	tx.queue(func() error { _, err := tx.db.RPush(key, values...); return err })
+}
+
+// SAdd 见 DB.SAdd
+func (tx *Tx) SAdd(key string, members ...string) {
+	members = append([]string(nil), members...)
+	tx.queue(func() error { _, err := tx.db.SAdd(key, members...); return err })
+}
+
+// SRem 见 DB.SRem
+func (tx *Tx) SRem(key string, members ...string) {
+	members = append([]string(nil), members...)
+	tx.queue(func() error { _, err := tx.db.SRem(key, members...); return err })
+}
+
+// ZAdd 见 DB.ZAdd
+func (tx *Tx) ZAdd(key string, members ...Z) {
+	members = append([]Z(nil), members...)
+	tx.queue(func() error { return tx.db.ZAdd(key, members...) })
+}
+
+// ZRem 见 DB.ZRem
+func (tx *Tx) ZRem(key, member string) {
+	tx.queue(func() error { return tx.db.ZRem(key, member) })
+}
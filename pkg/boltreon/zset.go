@@ -0,0 +1,91 @@
+package boltreon
+
+import "github.com/lbp0200/BoltDB/internal/store"
+
+// Z 有序集合的成员及其分数
+type Z struct {
+	Member string
+	Score  float64
+}
+
+// ZAdd 添加成员或更新已有成员的分数
+func (db *DB) ZAdd(key string, members ...Z) error {
+	if err := db.checkType(key, "zset"); err != nil {
+		return err
+	}
+	list := make([]store.ZSetMember, len(members))
+	for i, m := range members {
+		if err := db.checkSize(m.Member); err != nil {
+			return err
+		}
+		list[i] = store.ZSetMember{Member: m.Member, Score: m.Score}
+	}
+	return db.s.ZAdd(key, list)
+}
+
+// ZRem 删除成员
+func (db *DB) ZRem(key, member string) error {
+	if err := db.checkType(key, "zset"); err != nil {
+		return err
+	}
+	return db.s.ZRem(key, member)
+}
+
+// ZScore 返回成员的分数，成员不存在时返回 ErrNotFound
+func (db *DB) ZScore(key, member string) (float64, error) {
+	if err := db.checkType(key, "zset"); err != nil {
+		return 0, err
+	}
+	score, ok, err := db.s.ZScore(key, member)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return score, nil
+}
+
+// ZIncrBy 将成员的分数加上 n（成员不存在时视为 0），返回新分数
+func (db *DB) ZIncrBy(key, member string, n float64) (float64, error) {
+	if err := db.checkType(key, "zset"); err != nil {
+		return 0, err
+	}
+	if err := db.checkSize(member); err != nil {
+		return 0, err
+	}
+	return db.s.ZIncrBy(key, member, n)
+}
+
+// ZRange 按分数从小到大返回排名 start 到 stop（含）的成员，负数排名从尾部计数
+func (db *DB) ZRange(key string, start, stop int64) ([]Z, error) {
+	return db.zrange(key, start, stop, db.s.ZRange)
+}
+
+// ZRevRange 按分数从大到小返回排名 start 到 stop（含）的成员
+func (db *DB) ZRevRange(key string, start, stop int64) ([]Z, error) {
+	return db.zrange(key, start, stop, db.s.ZRevRange)
+}
+
+func (db *DB) zrange(key string, start, stop int64, fn func(string, int64, int64) ([]*store.ZSetMember, error)) ([]Z, error) {
+	if err := db.checkType(key, "zset"); err != nil {
+		return nil, err
+	}
+	members, err := fn(key, start, stop)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Z, len(members))
+	for i, m := range members {
+		result[i] = Z{Member: m.Member, Score: m.Score}
+	}
+	return result, nil
+}
+
+// ZCard 有序集合的成员数
+func (db *DB) ZCard(key string) (int64, error) {
+	if err := db.checkType(key, "zset"); err != nil {
+		return 0, err
+	}
+	return db.s.ZCard(key)
+}